@@ -0,0 +1,158 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package alerts sends alerts about critical dataplane conditions to external systems, such as
+// an SNMP manager or a webhook.  Components raise alerts with Raise; the alerts are delivered,
+// asynchronously, to the sinks configured with Configure.  Raising an alert before Configure
+// has been called, or with no sinks configured, does nothing.
+package alerts
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Conditions that raise alerts.
+const (
+	// ConditionDataplaneProgrammingFailed is raised when Felix gives up programming the
+	// dataplane after repeated failures (just before it restarts).
+	ConditionDataplaneProgrammingFailed = "DataplaneProgrammingFailed"
+	// ConditionIPSetOverflow is raised when an IP set needs more members than its maximum size.
+	ConditionIPSetOverflow = "IPSetOverflow"
+	// ConditionConntrackTableFull is raised when the kernel's conntrack table is nearly full.
+	ConditionConntrackTableFull = "ConntrackTableFull"
+	// ConditionIPSetMatchUnavailable is raised when iptables can't match on IP sets, so policy
+	// is rendered with the IP sets expanded inline, and when an IP set is too big to expand.
+	ConditionIPSetMatchUnavailable = "IPSetMatchUnavailable"
+)
+
+type Severity string
+
+const (
+	SeverityCritical Severity = "critical"
+	SeverityWarning  Severity = "warning"
+)
+
+var (
+	countAlertsRaised = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "felix_alerts_raised",
+		Help: "Number of alerts raised, by condition.",
+	}, []string{"condition"})
+	countAlertSendErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "felix_alerts_send_errors",
+		Help: "Number of failures to send an alert, by sink.",
+	}, []string{"sink"})
+)
+
+func init() {
+	prometheus.MustRegister(countAlertsRaised)
+	prometheus.MustRegister(countAlertSendErrors)
+}
+
+// Alert describes an occurrence of a critical condition.
+type Alert struct {
+	Condition string   `json:"condition"`
+	Severity  Severity `json:"severity"`
+	// Subject identifies the affected object, if any; for example the name of an IP set.
+	Subject  string    `json:"subject,omitempty"`
+	Message  string    `json:"message"`
+	Hostname string    `json:"hostname"`
+	Time     time.Time `json:"time"`
+}
+
+// Sink delivers alerts to an external system.
+type Sink interface {
+	// Name identifies the sink in logs and metrics.
+	Name() string
+	Send(a Alert) error
+}
+
+var (
+	lock           sync.Mutex
+	sinks          []Sink
+	hostname       string
+	repeatInterval time.Duration
+	lastRaised     = map[string]time.Time{}
+	inFlight       sync.WaitGroup
+
+	// Shim for testing.
+	now = time.Now
+)
+
+// Configure sets the sinks that alerts are delivered to.  An alert for the same condition and
+// subject is only delivered once per repeatAfter.
+func Configure(host string, repeatAfter time.Duration, s ...Sink) {
+	lock.Lock()
+	defer lock.Unlock()
+	hostname = host
+	repeatInterval = repeatAfter
+	sinks = s
+	lastRaised = map[string]time.Time{}
+}
+
+// Raise raises an alert.  It doesn't block; use Flush to wait for delivery.
+func Raise(condition string, severity Severity, subject, message string) {
+	lock.Lock()
+	defer lock.Unlock()
+	logCxt := log.WithFields(log.Fields{"condition": condition, "subject": subject})
+	if len(sinks) == 0 {
+		return
+	}
+	t := now()
+	key := condition + "/" + subject
+	if last, ok := lastRaised[key]; ok && t.Sub(last) < repeatInterval {
+		logCxt.Debug("Suppressing repeated alert.")
+		return
+	}
+	lastRaised[key] = t
+	countAlertsRaised.WithLabelValues(condition).Inc()
+
+	a := Alert{
+		Condition: condition,
+		Severity:  severity,
+		Subject:   subject,
+		Message:   message,
+		Hostname:  hostname,
+		Time:      t,
+	}
+	logCxt.WithField("message", message).Info("Raising alert.")
+	for _, s := range sinks {
+		inFlight.Add(1)
+		go func(s Sink) {
+			defer inFlight.Done()
+			if err := s.Send(a); err != nil {
+				logCxt.WithError(err).WithField("sink", s.Name()).Warn("Failed to send alert.")
+				countAlertSendErrors.WithLabelValues(s.Name()).Inc()
+			}
+		}(s)
+	}
+}
+
+// Flush waits, for at most the given timeout, for alerts to be delivered.  It is used to make
+// sure that an alert gets out before Felix exits.
+func Flush(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Warn("Timed out waiting for alerts to be sent.")
+	}
+}
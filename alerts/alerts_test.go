@@ -0,0 +1,230 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerts
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type mockSink struct {
+	lock   sync.Mutex
+	alerts []Alert
+	err    error
+}
+
+func (s *mockSink) Name() string {
+	return "mock"
+}
+
+func (s *mockSink) Send(a Alert) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.alerts = append(s.alerts, a)
+	return s.err
+}
+
+func (s *mockSink) sent() []Alert {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]Alert(nil), s.alerts...)
+}
+
+var _ = Describe("Raise", func() {
+	var (
+		sink    *mockSink
+		fakeNow time.Time
+	)
+
+	BeforeEach(func() {
+		sink = &mockSink{}
+		fakeNow = time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+		now = func() time.Time { return fakeNow }
+		Configure("node1", time.Minute, sink)
+	})
+
+	AfterEach(func() {
+		Configure("", 0)
+		now = time.Now
+	})
+
+	It("should deliver alerts to the sinks", func() {
+		Raise(ConditionIPSetOverflow, SeverityCritical, "s:abcd", "too big")
+		Flush(time.Second)
+		Expect(sink.sent()).To(Equal([]Alert{{
+			Condition: ConditionIPSetOverflow,
+			Severity:  SeverityCritical,
+			Subject:   "s:abcd",
+			Message:   "too big",
+			Hostname:  "node1",
+			Time:      fakeNow,
+		}}))
+	})
+
+	It("should suppress repeats within the repeat interval", func() {
+		Raise(ConditionIPSetOverflow, SeverityCritical, "s:abcd", "too big")
+		Raise(ConditionIPSetOverflow, SeverityCritical, "s:abcd", "still too big")
+		Raise(ConditionIPSetOverflow, SeverityCritical, "s:efgh", "too big")
+		fakeNow = fakeNow.Add(time.Minute)
+		Raise(ConditionIPSetOverflow, SeverityCritical, "s:abcd", "still too big")
+		Flush(time.Second)
+		Expect(sink.sent()).To(HaveLen(3))
+	})
+
+	It("should tolerate failing sinks", func() {
+		sink.err = errors.New("down")
+		Raise(ConditionConntrackTableFull, SeverityCritical, "", "full")
+		Flush(time.Second)
+		Expect(sink.sent()).To(HaveLen(1))
+	})
+
+	It("should do nothing with no sinks", func() {
+		Configure("node1", time.Minute)
+		Raise(ConditionConntrackTableFull, SeverityCritical, "", "full")
+		Flush(time.Second)
+		Expect(sink.sent()).To(BeEmpty())
+	})
+})
+
+var _ = Describe("WebhookSink", func() {
+	It("should POST the alert as JSON", func() {
+		received := make(chan Alert, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var a Alert
+			Expect(json.NewDecoder(req.Body).Decode(&a)).To(Succeed())
+			received <- a
+		}))
+		defer server.Close()
+
+		a := Alert{Condition: ConditionConntrackTableFull, Severity: SeverityCritical, Message: "full"}
+		Expect(NewWebhookSink(server.URL).Send(a)).To(Succeed())
+		Expect((<-received).Message).To(Equal("full"))
+	})
+
+	It("should report errors", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+		Expect(NewWebhookSink(server.URL).Send(Alert{})).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("SNMPTrapSink", func() {
+	It("should reject invalid OIDs", func() {
+		for _, oid := range []string{"", "1", "1.3.x", "3.1", "1.40"} {
+			_, err := NewSNMPTrapSink("127.0.0.1:162", "public", oid)
+			Expect(err).To(HaveOccurred(), oid)
+		}
+	})
+
+	It("should encode BER values", func() {
+		Expect(berInt(0)).To(Equal([]byte{0}))
+		Expect(berInt(127)).To(Equal([]byte{0x7f}))
+		Expect(berInt(128)).To(Equal([]byte{0, 0x80}))
+		Expect(berInt(-1)).To(Equal([]byte{0xff}))
+		Expect(berInt(-129)).To(Equal([]byte{0xff, 0x7f}))
+		Expect(berUint(0xff)).To(Equal([]byte{0, 0xff}))
+		Expect(berOIDBytes([]uint32{1, 3, 6, 1, 4, 1, 311})).To(Equal([]byte{0x2b, 6, 1, 4, 1, 0x82, 0x37}))
+		Expect(berTLV(berOctetString, []byte("ab"))).To(Equal([]byte{4, 2, 'a', 'b'}))
+		long := berTLV(berOctetString, make([]byte, 300))
+		Expect(long[:4]).To(Equal([]byte{4, 0x82, 0x01, 0x2c}))
+		Expect(long).To(HaveLen(304))
+	})
+
+	It("should send an SNMPv2c trap", func() {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+
+		sink, err := NewSNMPTrapSink(conn.LocalAddr().String(), "secret", "1.3.6.1.4.1.8072.9999.1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sink.Send(Alert{Condition: ConditionIPSetOverflow, Message: "too big"})).To(Succeed())
+
+		buf := make([]byte, 1500)
+		Expect(conn.SetReadDeadline(time.Now().Add(5 * time.Second))).To(Succeed())
+		n, _, err := conn.ReadFrom(buf)
+		Expect(err).NotTo(HaveOccurred())
+		msg := buf[:n]
+
+		// SEQUENCE { INTEGER 1, OCTET STRING "secret", Trap-PDU ... }
+		Expect(msg[0]).To(Equal(byte(berSequence)))
+		Expect(msg[1]).To(Equal(byte(0x81)))
+		Expect(int(msg[2])).To(Equal(n - 3))
+		Expect(msg[3:6]).To(Equal([]byte{berInteger, 1, snmpVersion2c}))
+		Expect(msg[6:14]).To(Equal(append([]byte{berOctetString, 6}, "secret"...)))
+		Expect(msg[14]).To(Equal(byte(snmpV2Trap)))
+		Expect(string(msg)).To(ContainSubstring(ConditionIPSetOverflow))
+		Expect(string(msg)).To(ContainSubstring("too big"))
+		Expect(string(msg)).To(ContainSubstring(string(berTLV(berOID, berOIDBytes(oidSnmpTrapOID)))))
+	})
+})
+
+var _ = Describe("ConntrackWatcher", func() {
+	var (
+		sink  *mockSink
+		w     *ConntrackWatcher
+		files map[string]string
+	)
+
+	BeforeEach(func() {
+		sink = &mockSink{}
+		Configure("node1", time.Minute, sink)
+		files = map[string]string{
+			conntrackCountFile: "899\n",
+			conntrackMaxFile:   "1000\n",
+		}
+		w = NewConntrackWatcher(90, time.Second)
+		w.readFile = func(path string) ([]byte, error) {
+			if s, ok := files[path]; ok {
+				return []byte(s), nil
+			}
+			return nil, errors.New("not found")
+		}
+	})
+
+	AfterEach(func() {
+		Configure("", 0)
+	})
+
+	It("should not alert below the threshold", func() {
+		w.check()
+		Flush(time.Second)
+		Expect(sink.sent()).To(BeEmpty())
+	})
+
+	It("should alert at the threshold", func() {
+		files[conntrackCountFile] = "900\n"
+		w.check()
+		Flush(time.Second)
+		Expect(sink.sent()).To(HaveLen(1))
+		Expect(sink.sent()[0].Condition).To(Equal(ConditionConntrackTableFull))
+	})
+
+	It("should do nothing if conntrack isn't loaded", func() {
+		delete(files, conntrackMaxFile)
+		w.check()
+		Flush(time.Second)
+		Expect(sink.sent()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,83 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerts
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	conntrackCountFile = "/proc/sys/net/netfilter/nf_conntrack_count"
+	conntrackMaxFile   = "/proc/sys/net/netfilter/nf_conntrack_max"
+)
+
+// ConntrackWatcher raises ConditionConntrackTableFull when the kernel's conntrack table is more
+// than a given percentage full.  Once the table is full, the kernel drops new connections.
+type ConntrackWatcher struct {
+	thresholdPercent int
+	interval         time.Duration
+
+	// Shim for testing.
+	readFile func(string) ([]byte, error)
+}
+
+func NewConntrackWatcher(thresholdPercent int, interval time.Duration) *ConntrackWatcher {
+	return &ConntrackWatcher{
+		thresholdPercent: thresholdPercent,
+		interval:         interval,
+		readFile:         ioutil.ReadFile,
+	}
+}
+
+func (w *ConntrackWatcher) Start() {
+	go w.loop()
+}
+
+func (w *ConntrackWatcher) loop() {
+	for range time.NewTicker(w.interval).C {
+		w.check()
+	}
+}
+
+func (w *ConntrackWatcher) check() {
+	count, err := w.readInt(conntrackCountFile)
+	if err != nil {
+		log.WithError(err).Debug("Failed to read conntrack table size.")
+		return
+	}
+	max, err := w.readInt(conntrackMaxFile)
+	if err != nil || max <= 0 {
+		log.WithError(err).Debug("Failed to read conntrack table limit.")
+		return
+	}
+	if count*100 >= max*int64(w.thresholdPercent) {
+		Raise(ConditionConntrackTableFull, SeverityCritical, "",
+			fmt.Sprintf("Conntrack table has %d entries, limit is %d.", count, max))
+	}
+}
+
+func (w *ConntrackWatcher) readInt(path string) (int64, error) {
+	data, err := w.readFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
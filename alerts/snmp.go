@@ -0,0 +1,195 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerts
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BER tags used in SNMP messages.
+const (
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berOID         = 0x06
+	berSequence    = 0x30
+	berTimeTicks   = 0x43
+	snmpV2Trap     = 0xa7
+
+	snmpVersion2c = 1
+)
+
+var (
+	oidSysUpTime    = []uint32{1, 3, 6, 1, 2, 1, 1, 3, 0}
+	oidSnmpTrapOID  = []uint32{1, 3, 6, 1, 6, 3, 1, 1, 4, 1, 0}
+	snmpStartupTime = time.Now()
+)
+
+// SNMPTrapSink sends each alert as an SNMPv2c trap.  The trap's snmpTrapOID is <trapOID> and it
+// carries the alert's fields as octet string varbinds:
+//
+//   <trapOID>.1  condition
+//   <trapOID>.2  severity
+//   <trapOID>.3  subject
+//   <trapOID>.4  message
+//   <trapOID>.5  hostname
+type SNMPTrapSink struct {
+	target    string
+	community string
+	trapOID   []uint32
+}
+
+// NewSNMPTrapSink creates a sink that sends traps to target, a host:port.  trapOID is a dotted
+// OID such as "1.3.6.1.4.1.8072.9999.1".
+func NewSNMPTrapSink(target, community, trapOID string) (*SNMPTrapSink, error) {
+	oid, err := parseOID(trapOID)
+	if err != nil {
+		return nil, err
+	}
+	return &SNMPTrapSink{
+		target:    target,
+		community: community,
+		trapOID:   oid,
+	}, nil
+}
+
+func (s *SNMPTrapSink) Name() string {
+	return "snmp"
+}
+
+func (s *SNMPTrapSink) Send(a Alert) error {
+	conn, err := net.DialTimeout("udp", s.target, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write(s.encodeTrap(a, rand.Int31(), time.Since(snmpStartupTime)))
+	return err
+}
+
+func (s *SNMPTrapSink) encodeTrap(a Alert, requestID int32, upTime time.Duration) []byte {
+	field := func(n uint32) []uint32 {
+		return append(append([]uint32{}, s.trapOID...), n)
+	}
+	varBinds := berTLV(berSequence,
+		varBind(oidSysUpTime, berTLV(berTimeTicks, berUint(uint64(upTime/(10*time.Millisecond))))),
+		varBind(oidSnmpTrapOID, berTLV(berOID, berOIDBytes(s.trapOID))),
+		varBind(field(1), berTLV(berOctetString, []byte(a.Condition))),
+		varBind(field(2), berTLV(berOctetString, []byte(a.Severity))),
+		varBind(field(3), berTLV(berOctetString, []byte(a.Subject))),
+		varBind(field(4), berTLV(berOctetString, []byte(a.Message))),
+		varBind(field(5), berTLV(berOctetString, []byte(a.Hostname))),
+	)
+	pdu := berTLV(snmpV2Trap,
+		berTLV(berInteger, berInt(int64(requestID))),
+		berTLV(berInteger, berInt(0)), // error-status
+		berTLV(berInteger, berInt(0)), // error-index
+		varBinds,
+	)
+	return berTLV(berSequence,
+		berTLV(berInteger, berInt(snmpVersion2c)),
+		berTLV(berOctetString, []byte(s.community)),
+		pdu,
+	)
+}
+
+func varBind(oid []uint32, value []byte) []byte {
+	return berTLV(berSequence, berTLV(berOID, berOIDBytes(oid)), value)
+}
+
+func parseOID(s string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimPrefix(s, "."), ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid OID %q", s)
+	}
+	oid := make([]uint32, len(parts))
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OID %q: %w", s, err)
+		}
+		oid[i] = uint32(n)
+	}
+	if oid[0] > 2 || (oid[0] < 2 && oid[1] >= 40) {
+		return nil, fmt.Errorf("invalid OID %q", s)
+	}
+	return oid, nil
+}
+
+// berTLV encodes a BER tag-length-value with the concatenation of the given contents.
+func berTLV(tag byte, contents ...[]byte) []byte {
+	var value []byte
+	for _, c := range contents {
+		value = append(value, c...)
+	}
+	out := []byte{tag}
+	if len(value) < 0x80 {
+		out = append(out, byte(len(value)))
+	} else {
+		lenBytes := berUint(uint64(len(value)))
+		if lenBytes[0] == 0 {
+			lenBytes = lenBytes[1:]
+		}
+		out = append(out, 0x80|byte(len(lenBytes)))
+		out = append(out, lenBytes...)
+	}
+	return append(out, value...)
+}
+
+// berInt encodes a signed integer in the minimum number of two's complement bytes.
+func berInt(v int64) []byte {
+	var out []byte
+	for {
+		out = append([]byte{byte(v)}, out...)
+		v >>= 8
+		if (v == 0 && out[0]&0x80 == 0) || (v == -1 && out[0]&0x80 != 0) {
+			return out
+		}
+	}
+}
+
+// berUint encodes an unsigned integer, adding a leading zero byte if needed to keep it positive.
+func berUint(v uint64) []byte {
+	var out []byte
+	for {
+		out = append([]byte{byte(v)}, out...)
+		v >>= 8
+		if v == 0 {
+			break
+		}
+	}
+	if out[0]&0x80 != 0 {
+		out = append([]byte{0}, out...)
+	}
+	return out
+}
+
+func berOIDBytes(oid []uint32) []byte {
+	var out []byte
+	subIDs := append([]uint32{oid[0]*40 + oid[1]}, oid[2:]...)
+	for _, n := range subIDs {
+		var b []byte
+		b = append(b, byte(n&0x7f))
+		for n >>= 7; n > 0; n >>= 7 {
+			b = append([]byte{byte(n&0x7f) | 0x80}, b...)
+		}
+		out = append(out, b...)
+	}
+	return out
+}
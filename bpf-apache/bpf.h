@@ -63,6 +63,7 @@ MAKEFUNC(int, msg_redirect_hash,
 MAKEFUNC(int, sock_hash_update,
 	struct bpf_sock_ops*, struct bpf_map_def*, void*, __u64)
 MAKEFUNC(void*, map_lookup_elem, void*, const void*)
+MAKEFUNC(int, tail_call, void*, struct bpf_map_def*, __u32)
 
 /*
  * Data types, structs, and unions
@@ -53,6 +53,14 @@ CALI_BPF_INLINE static int extract_ports(__u32 len, struct iphdr * h,
 }
 
 
+// xdp_pass passes the packet on to the chained XDP program, if there is one, or to the kernel.
+CALI_BPF_INLINE static enum xdp_action xdp_pass(struct xdp_md* xdp)
+{
+	bpf_tail_call(xdp, &calico_xdp_chain, 0);
+	// Only reached if there is no chained program.
+	return XDP_PASS;
+}
+
 __attribute__((section("prefilter_func")))
 enum xdp_action prefilter(struct xdp_md* xdp)
 {
@@ -73,7 +81,7 @@ enum xdp_action prefilter(struct xdp_md* xdp)
 	// does not handle e.g. V[X]LAN encapsulation.
 	ehdr = (void*)(long)xdp->data;
 	if (be16_to_host(ETH_P_IP) != ehdr->h_proto) {
-		return XDP_PASS;
+		return xdp_pass(xdp);
 	}
 
 	// Parse l4 protocols and ports.
@@ -83,7 +91,7 @@ enum xdp_action prefilter(struct xdp_md* xdp)
 	if (extract_ports(xdp->data_end - xdp->data, ihdr, &dport)) {
 		// Check failsafe ports and XDP_PASS early
 		if (NULL != bpf_map_lookup_elem(&calico_failsafe_ports, &dport)) {
-			return XDP_PASS;
+			return xdp_pass(xdp);
 		}
 	}
 
@@ -96,7 +104,7 @@ enum xdp_action prefilter(struct xdp_md* xdp)
 	}
 
 	// Not in blacklist - pass.
-	return XDP_PASS;
+	return xdp_pass(xdp);
 }
 
 char ____license[] __attribute__((section("license")))  = "Apache-2.0";
@@ -35,3 +35,12 @@ struct bpf_map_def __attribute__((section("maps"))) calico_failsafe_ports = {
 	.max_entries    = 65535,
 	.map_flags      = BPF_F_NO_PREALLOC,
 };
+
+// calico_xdp_chain holds the XDP program, if any, that we displaced when we attached to the
+// interface.  We pass the packets that we allow on to it.
+struct bpf_map_def __attribute__((section("maps"))) calico_xdp_chain = {
+	.type           = BPF_MAP_TYPE_PROG_ARRAY,
+	.key_size       = sizeof(__u32),
+	.value_size     = sizeof(__u32),
+	.max_entries    = 1,
+};
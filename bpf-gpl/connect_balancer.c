@@ -38,20 +38,22 @@ int cali_noop_v4(struct bpf_sock_addr *ctx)
 	return 1;
 }
 
-static CALI_BPF_INLINE void do_nat_common(struct bpf_sock_addr *ctx, __u8 proto)
+/* client_ip is the source address of the connection, which keys the ClientIP affinity.  The caller
+ * passes the address that the socket is bound to (or that sendmsg was asked to send from) if there
+ * is one.  Otherwise, we do not know what the source address is yet, we only know that it is the
+ * localhost, so we might just use 0.0.0.0.  That would not conflict with traffic from elsewhere.
+ *
+ * XXX it means that all workloads that use the cgroup hook from unbound sockets have the
+ * XXX same affinity, which (a) is sub-optimal and (b) leaks info between
+ * XXX workloads.
+ */
+static CALI_BPF_INLINE void do_nat_common(struct bpf_sock_addr *ctx, __u8 proto, __be32 client_ip)
 {
-	/* We do not know what the source address is yet, we only know that it
-	 * is the localhost, so we might just use 0.0.0.0. That would not
-	 * conflict with traffic from elsewhere.
-	 *
-	 * XXX it means that all workloads that use the cgroup hook have the
-	 * XXX same affinity, which (a) is sub-optimal and (b) leaks info between
-	 * XXX workloads.
-	 */
 	nat_lookup_result res = NAT_LOOKUP_ALLOW;
 	__u16 dport_he = (__u16)(bpf_ntohl(ctx->user_port)>>16);
 	struct calico_nat_dest *nat_dest;
-	nat_dest = calico_v4_nat_lookup(0, ctx->user_ip4, proto, dport_he, &res);
+	CALI_DEBUG("NAT: client %x\n", bpf_ntohl(client_ip));
+	nat_dest = calico_v4_nat_lookup(client_ip, ctx->user_ip4, proto, dport_he, &res);
 	if (!nat_dest) {
 		CALI_INFO("NAT miss.\n");
 		goto out;
@@ -135,7 +137,7 @@ int cali_ctlb_v4(struct bpf_sock_addr *ctx)
 		goto out;
 	}
 
-	do_nat_common(ctx, ip_proto);
+	do_nat_common(ctx, ip_proto, ctx->sk->src_ip4);
 
 out:
 	return 1;
@@ -152,7 +154,11 @@ int cali_ctlb_sendmsg_v4(struct bpf_sock_addr *ctx)
 		goto out;
 	}
 
-	do_nat_common(ctx, IPPROTO_UDP);
+	__be32 client_ip = ctx->msg_src_ip4;
+	if (!client_ip) {
+		client_ip = ctx->sk->src_ip4;
+	}
+	do_nat_common(ctx, IPPROTO_UDP, client_ip);
 
 out:
 	return 1;
@@ -150,6 +150,10 @@ skip_redir_ifindex:
 		CALI_DEBUG("FIB ipv4_dst=%x\n", bpf_ntohl(fib_params.ipv4_dst));
 
 		CALI_DEBUG("Traffic is towards the host namespace, doing Linux FIB lookup\n");
+		/* Without BPF_FIB_LOOKUP_DIRECT, the lookup goes through the routing rules,
+		 * including the l3mdev rule, so packets from an interface that is enslaved to
+		 * a VRF are routed by the VRF's table.
+		 */
 		rc = bpf_fib_lookup(ctx->skb, &fib_params, sizeof(fib_params), ctx->fwd.fib_flags);
 		if (rc == 0) {
 			CALI_DEBUG("FIB lookup succeeded\n");
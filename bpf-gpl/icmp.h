@@ -163,6 +163,22 @@ static CALI_BPF_INLINE int icmp_v4_port_unreachable(struct cali_tc_ctx *ctx)
 	return icmp_v4_reply(ctx, ICMP_DEST_UNREACH, ICMP_PORT_UNREACH, 0);
 }
 
+/* icmp_v4_related_port_csum fixes up the checksum of an ICMP error after we changed a port in the
+ * L4 header of the original packet that it carries.  The ICMP checksum covers that header and,
+ * unlike the inner IP header, the inner L4 checksum does not compensate for the change.  A bad
+ * checksum would make the client drop the error, which would blackhole PMTU discovery for NATted
+ * flows.
+ */
+static CALI_BPF_INLINE int icmp_v4_related_port_csum(struct cali_tc_ctx *ctx, __be16 from, __be16 to)
+{
+	if (from == to) {
+		return 0;
+	}
+
+	return bpf_l4_csum_replace(ctx->skb, skb_iphdr_offset(ctx->skb) + sizeof(struct iphdr) +
+					offsetof(struct icmphdr, checksum), from, to, 2);
+}
+
 static CALI_BPF_INLINE bool icmp_type_is_err(__u8 type)
 {
 	switch (type) {
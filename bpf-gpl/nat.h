@@ -64,9 +64,81 @@ static CALI_BPF_INLINE int skb_nat_l4_csum_ipv4(struct __sk_buff *skb, size_t of
 	return ret;
 }
 
+static CALI_BPF_INLINE __u32 nat_hash_mix(__u32 h, __u32 k)
+{
+	k *= 0xcc9e2d51;
+	k = (k << 15) | (k >> 17);
+	k *= 0x1b873593;
+	h ^= k;
+	h = (h << 13) | (h >> 19);
+	return h * 5 + 0xe6546b64;
+}
+
+/* nat_flow_hash hashes the flow's 5-tuple (murmur3).  It must give the same result on every node
+ * so that Maglev picks the same backend for a flow whichever node it arrives at.
+ */
+static CALI_BPF_INLINE __u32 nat_flow_hash(__be32 ip_src, __be32 ip_dst, __u8 ip_proto,
+					   __u16 sport, __u16 dport)
+{
+	__u32 h = 0;
+
+	h = nat_hash_mix(h, ip_src);
+	h = nat_hash_mix(h, ip_dst);
+	h = nat_hash_mix(h, ((__u32)sport << 16) | dport);
+	h = nat_hash_mix(h, ip_proto);
+	h ^= h >> 16;
+	h *= 0x85ebca6b;
+	h ^= h >> 13;
+	h *= 0xc2b2ae35;
+	h ^= h >> 16;
+	return h;
+}
+
+/* nat_select_ordinal picks the ordinal of the backend for a new flow to a service with count
+ * backends, according to the service's backend selection map entries.
+ */
+static CALI_BPF_INLINE __u32 nat_select_ordinal(__u32 id, __u32 count, __be32 ip_src, __be32 ip_dst,
+						__u8 ip_proto, __u16 sport, __u16 dport)
+{
+	struct calico_nat_v4_sel_key sel_key = {
+		.id = id,
+		.slot = NAT_SEL_RR_SLOT,
+	};
+	struct calico_nat_v4_sel_val *sel_val;
+
+	sel_val = cali_v4_nat_sel_lookup_elem(&sel_key);
+	if (sel_val) {
+		/* We can't use the result of the atomic add on older kernels so CPUs racing on the
+		 * counter may pick the same backend; that's close enough to round robin.
+		 */
+		__u32 ordinal = sel_val->ordinal;
+		__sync_fetch_and_add(&sel_val->ordinal, 1);
+		CALI_DEBUG("NAT: round robin ordinal %d\n", ordinal);
+		return ordinal % count;
+	}
+
+	/* The connect-time load balancer doesn't know the source of the flow yet, hashing would send
+	 * all local clients to the same backend.
+	 */
+	if (!CALI_F_CGROUP) {
+		sel_key.slot = nat_flow_hash(ip_src, ip_dst, ip_proto, sport, dport) % NAT_MAGLEV_TABLE_SIZE;
+		sel_val = cali_v4_nat_sel_lookup_elem(&sel_key);
+		/* With a node-local traffic policy, count only covers the local backends so we may
+		 * have to fall back to a random one.
+		 */
+		if (sel_val && sel_val->ordinal < count) {
+			CALI_DEBUG("NAT: Maglev slot %d ordinal %d\n", sel_key.slot, sel_val->ordinal);
+			return sel_val->ordinal;
+		}
+	}
+
+	return bpf_get_prandom_u32() % count;
+}
+
 static CALI_BPF_INLINE struct calico_nat_dest* calico_v4_nat_lookup2(__be32 ip_src,
 								     __be32 ip_dst,
 								     __u8 ip_proto,
+								     __u16 sport,
 								     __u16 dport,
 								     bool from_tun,
 								     nat_lookup_result *res)
@@ -188,8 +260,8 @@ static CALI_BPF_INLINE struct calico_nat_dest* calico_v4_nat_lookup2(__be32 ip_s
 
 skip_affinity:
 	nat_lv2_key.id = nat_lv1_val->id;
-	nat_lv2_key.ordinal = bpf_get_prandom_u32();
-	nat_lv2_key.ordinal %= count;
+	nat_lv2_key.ordinal = nat_select_ordinal(nat_lv1_val->id, count, ip_src, ip_dst, ip_proto,
+						 sport, dport);
 
 	CALI_DEBUG("NAT: 1st level hit; id=%d ordinal=%d\n", nat_lv2_key.id, nat_lv2_key.ordinal);
 
@@ -221,7 +293,7 @@ skip_affinity:
 static CALI_BPF_INLINE struct calico_nat_dest* calico_v4_nat_lookup(__be32 ip_src, __be32 ip_dst,
 								    __u8 ip_proto, __u16 dport, nat_lookup_result *res)
 {
-	return calico_v4_nat_lookup2(ip_src, ip_dst, ip_proto, dport, false, res);
+	return calico_v4_nat_lookup2(ip_src, ip_dst, ip_proto, 0, dport, false, res);
 }
 
 static CALI_BPF_INLINE int vxlan_v4_encap(struct cali_tc_ctx *ctx,  __be32 ip_src, __be32 ip_dst)
@@ -92,6 +92,27 @@ CALI_MAP_V1(cali_v4_nat_be,
 		struct calico_nat_secondary_v4_key, struct calico_nat_dest,
 		510000, BPF_F_NO_PREALLOC, MAP_PIN_GLOBAL)
 
+/* The proxy programs the backend selection map for services that don't pick a random backend:
+ * a round-robin service has a counter in NAT_SEL_RR_SLOT and a Maglev service has a lookup
+ * table of backend ordinals in slots 0 to NAT_MAGLEV_TABLE_SIZE-1.
+ */
+#define NAT_SEL_RR_SLOT		0xffffffff
+#define NAT_MAGLEV_TABLE_SIZE	1021
+
+struct calico_nat_v4_sel_key {
+	__u32 id;
+	__u32 slot;
+};
+
+struct calico_nat_v4_sel_val {
+	__u32 ordinal;
+};
+
+CALI_MAP_V1(cali_v4_nat_sel,
+		BPF_MAP_TYPE_HASH,
+		struct calico_nat_v4_sel_key, struct calico_nat_v4_sel_val,
+		1021000, BPF_F_NO_PREALLOC, MAP_PIN_GLOBAL)
+
 struct calico_nat_v4_affinity_key {
 	struct calico_nat_v4 nat_key;
 	__u32 client_ip;
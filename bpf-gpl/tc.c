@@ -310,7 +310,7 @@ static CALI_BPF_INLINE int calico_tc(struct __sk_buff *skb)
 	/* No conntrack entry, check if we should do NAT */
 	nat_lookup_result nat_res = NAT_LOOKUP_ALLOW;
 	ctx.nat_dest = calico_v4_nat_lookup2(ctx.state->ip_src, ctx.state->ip_dst,
-					     ctx.state->ip_proto, ctx.state->dport,
+					     ctx.state->ip_proto, ctx.state->sport, ctx.state->dport,
 					     ctx.state->tun_ip != 0, &nat_res);
 
 	if (nat_res == NAT_FE_LOOKUP_DROP) {
@@ -674,6 +674,9 @@ static CALI_BPF_INLINE struct fwd calico_tc_skb_accepted(struct cali_tc_ctx *ctx
 
 	int res = 0;
 	bool encap_needed = false;
+	/* The port that we replace when we NAT the L4 header of a packet that an ICMP error
+	 * carries. */
+	__be16 orig_port = 0;
 
 	if (state->ip_proto == IPPROTO_ICMP && ct_related) {
 		/* do not fix up embedded L4 checksum for related ICMP */
@@ -856,9 +859,11 @@ static CALI_BPF_INLINE struct fwd calico_tc_skb_accepted(struct cali_tc_ctx *ctx
 
 		switch (ctx->ip_header->protocol) {
 		case IPPROTO_TCP:
+			orig_port = ctx->tcp_header->dest;
 			ctx->tcp_header->dest = bpf_htons(state->post_nat_dport);
 			break;
 		case IPPROTO_UDP:
+			orig_port = ctx->udp_header->dest;
 			ctx->udp_header->dest = bpf_htons(state->post_nat_dport);
 			break;
 		}
@@ -870,6 +875,8 @@ static CALI_BPF_INLINE struct fwd calico_tc_skb_accepted(struct cali_tc_ctx *ctx
 					state->post_nat_ip_dst,	bpf_htons(state->dport),
 					bpf_htons(state->post_nat_dport),
 					ctx->ip_header->protocol == IPPROTO_UDP ? BPF_F_MARK_MANGLED_0 : 0);
+		} else if (ct_related && state->ip_proto == IPPROTO_ICMP && orig_port) {
+			res = icmp_v4_related_port_csum(ctx, orig_port, bpf_htons(state->post_nat_dport));
 		}
 
 		res |= bpf_l3_csum_replace(skb, l3_csum_off, state->ip_dst, state->post_nat_ip_dst, 4);
@@ -939,9 +946,11 @@ static CALI_BPF_INLINE struct fwd calico_tc_skb_accepted(struct cali_tc_ctx *ctx
 
 		switch (ctx->ip_header->protocol) {
 		case IPPROTO_TCP:
+			orig_port = ctx->tcp_header->source;
 			ctx->tcp_header->source = bpf_htons(state->ct_result.nat_port);
 			break;
 		case IPPROTO_UDP:
+			orig_port = ctx->udp_header->source;
 			ctx->udp_header->source = bpf_htons(state->ct_result.nat_port);
 			break;
 		}
@@ -953,6 +962,8 @@ static CALI_BPF_INLINE struct fwd calico_tc_skb_accepted(struct cali_tc_ctx *ctx
 					state->ct_result.nat_ip, bpf_htons(state->sport),
 					bpf_htons(state->ct_result.nat_port),
 					ctx->ip_header->protocol == IPPROTO_UDP ? BPF_F_MARK_MANGLED_0 : 0);
+		} else if (ct_related && state->ip_proto == IPPROTO_ICMP && orig_port) {
+			res = icmp_v4_related_port_csum(ctx, orig_port, bpf_htons(state->ct_result.nat_port));
 		}
 
 		CALI_VERB("L3 checksum update (csum is at %d) port from %x to %x\n",
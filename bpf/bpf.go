@@ -66,6 +66,8 @@ const (
 	xdpProgVersion        = "v1"
 	failsafeMapName       = "calico_failsafe_ports_" + failsafeMapVersion
 	failsafeSymbolMapName = "calico_failsafe_ports" // no need to version the symbol name
+	xdpChainMapVersion    = "v1"
+	xdpChainSymbolMapName = "calico_xdp_chain"
 
 	// sockmap
 	sockopsProgVersion         = "v1"
@@ -327,6 +329,9 @@ type BPFDataplane interface {
 	GetXDPObjTag(objPath string) (string, error)
 	GetXDPObjTagAuto() (string, error)
 	GetXDPTag(ifName string) (string, error)
+	GetPinnedXDPID(ifName string) (int, error)
+	GetChainedXDPID(ifName string) (int, error)
+	ChainXDP(ifName string, progID int) error
 	IsValidMap(ifName string, family IPFamily) (bool, error)
 	ListCIDRMaps(family IPFamily) ([]string, error)
 	LoadXDP(objPath, ifName string, mode XDPMode) error
@@ -373,6 +378,10 @@ func getProgName(ifName string) string {
 	return fmt.Sprintf("prefilter_%s_%s", xdpProgVersion, ifName)
 }
 
+func getXDPChainMapName(ifName string) string {
+	return fmt.Sprintf("%s_xdp_chain_%s", ifName, xdpChainMapVersion)
+}
+
 func newMap(name, path, kind string, entries, keySize, valueSize, flags int) (string, error) {
 	// FIXME: for some reason this function was called several times for a
 	// particular map, just assume it's created if the pinned file is there for
@@ -457,6 +466,22 @@ func (b *BPFLib) NewCIDRMap(ifName string, family IPFamily) (string, error) {
 	)
 }
 
+// newXDPChainMap creates the program array through which our XDP program on the interface passes
+// packets to the program that it displaced.
+func (b *BPFLib) newXDPChainMap(ifName string) (string, error) {
+	mapName := getXDPChainMapName(ifName)
+	mapPath := filepath.Join(b.xdpDir, mapName)
+
+	return newMap(mapName,
+		mapPath,
+		"prog_array",
+		1,
+		4,
+		4,
+		0,
+	)
+}
+
 func (b *BPFLib) ListCIDRMaps(family IPFamily) ([]string, error) {
 	var ifNames []string
 	maps, err := ioutil.ReadDir(b.xdpDir)
@@ -981,6 +1006,10 @@ func (b *BPFLib) loadXDPRaw(objPath, ifName string, mode XDPMode, mapArgs []stri
 		mode.String(),
 		"pinned",
 		progPath}
+	if chainedID, err := b.GetChainedXDPID(ifName); err == nil && chainedID >= 0 {
+		// We're taking the place of the chained program, which is still attached.
+		args = append([]string{"-force"}, args...)
+	}
 
 	printCommand(prog, args...)
 	output, err := exec.Command(prog, args...).CombinedOutput()
@@ -1006,9 +1035,12 @@ func (b *BPFLib) getMapArgs(ifName string) ([]string, error) {
 
 	// key: symbol of the map definition in the XDP program
 	// value: path where the map is pinned
+	chainMapPath := filepath.Join(b.xdpDir, getXDPChainMapName(ifName))
+
 	maps := map[string]string{
 		"calico_prefilter_v4": mapPath,
 		failsafeSymbolMapName: failsafeMapPath,
+		xdpChainSymbolMapName: chainMapPath,
 	}
 
 	var mapArgs []string
@@ -1025,6 +1057,10 @@ func (b *BPFLib) getMapArgs(ifName string) ([]string, error) {
 }
 
 func (b *BPFLib) LoadXDP(objPath, ifName string, mode XDPMode) error {
+	if _, err := b.newXDPChainMap(ifName); err != nil {
+		return err
+	}
+
 	mapArgs, err := b.getMapArgs(ifName)
 	if err != nil {
 		return err
@@ -1041,6 +1077,22 @@ func (b *BPFLib) RemoveXDP(ifName string, mode XDPMode) error {
 	progName := getProgName(ifName)
 	progPath := filepath.Join(b.xdpDir, progName)
 
+	chainedID, err := b.GetChainedXDPID(ifName)
+	if err == nil && chainedID >= 0 {
+		if curMode, err := b.GetXDPMode(ifName); err == nil && curMode == mode {
+			// Put the program that we displaced back in our place.
+			if err := b.restoreChainedXDP(ifName, chainedID, mode); err != nil {
+				return err
+			}
+			return os.Remove(progPath)
+		}
+	} else if err == nil {
+		chainMapPath := filepath.Join(b.xdpDir, getXDPChainMapName(ifName))
+		if err := os.Remove(chainMapPath); err != nil && !os.IsNotExist(err) {
+			log.WithError(err).WithField("map", chainMapPath).Warn("Failed to remove XDP chain map")
+		}
+	}
+
 	prog := "ip"
 	args := []string{
 		"link",
@@ -1090,6 +1142,155 @@ func (b *BPFLib) GetXDPTag(ifName string) (string, error) {
 	return p.Tag, nil
 }
 
+// GetPinnedXDPID returns the ID of the XDP program that we pinned for the interface, which is
+// only attached if GetXDPID returns the same ID.
+func (b *BPFLib) GetPinnedXDPID(ifName string) (int, error) {
+	progName := getProgName(ifName)
+	progPath := filepath.Join(b.xdpDir, progName)
+
+	prog := "bpftool"
+	args := []string{
+		"--json",
+		"--pretty",
+		"prog",
+		"show",
+		"pinned",
+		progPath}
+
+	printCommand(prog, args...)
+	output, err := exec.Command(prog, args...).CombinedOutput()
+	if err != nil {
+		return -1, fmt.Errorf("failed to show XDP program (%s): %s\n%s", progPath, err, output)
+	}
+
+	p := progInfo{}
+	err = json.Unmarshal(output, &p)
+	if err != nil {
+		return -1, fmt.Errorf("cannot parse json output: %v\n%s", err, output)
+	}
+	if p.Err != "" {
+		return -1, fmt.Errorf("%s", p.Err)
+	}
+
+	return p.Id, nil
+}
+
+// GetChainedXDPID returns the ID of the XDP program that our program on the interface passes
+// packets to, or -1 if there isn't one.
+func (b *BPFLib) GetChainedXDPID(ifName string) (int, error) {
+	mapName := getXDPChainMapName(ifName)
+	mapPath := filepath.Join(b.xdpDir, mapName)
+
+	if _, err := os.Stat(mapPath); os.IsNotExist(err) {
+		return -1, nil
+	}
+
+	prog := "bpftool"
+	args := []string{
+		"--json",
+		"--pretty",
+		"map",
+		"dump",
+		"pinned",
+		mapPath}
+
+	printCommand(prog, args...)
+	output, err := exec.Command(prog, args...).CombinedOutput()
+	if err != nil {
+		return -1, fmt.Errorf("failed to dump map (%s): %s\n%s", mapName, err, output)
+	}
+
+	var entries []mapEntry
+	err = json.Unmarshal(output, &entries)
+	if err != nil {
+		return -1, fmt.Errorf("cannot parse json output: %v\n%s", err, output)
+	}
+	for _, e := range entries {
+		if e.Err != "" {
+			return -1, fmt.Errorf("%s", e.Err)
+		}
+		// The map has a single slot; for a program array, the kernel gives us the program's ID.
+		id, err := hexToCIDRMapValue(e.Value)
+		if err != nil {
+			return -1, err
+		}
+		return int(id), nil
+	}
+
+	return -1, nil
+}
+
+// ChainXDP makes our XDP program on the interface pass the packets that it allows to the given
+// program, which is normally the one that we're about to displace.
+func (b *BPFLib) ChainXDP(ifName string, progID int) error {
+	mapPath, err := b.newXDPChainMap(ifName)
+	if err != nil {
+		return err
+	}
+
+	prog := "bpftool"
+	args := []string{
+		"map",
+		"update",
+		"pinned",
+		mapPath,
+		"key",
+		"0", "0", "0", "0",
+		"value",
+		"id",
+		strconv.Itoa(progID)}
+
+	printCommand(prog, args...)
+	output, err := exec.Command(prog, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to chain XDP program %d on %s: %s\n%s", progID, ifName, err, output)
+	}
+
+	return nil
+}
+
+func (b *BPFLib) restoreChainedXDP(ifName string, progID int, mode XDPMode) error {
+	tmpPath := filepath.Join(b.xdpDir, fmt.Sprintf("chained_%s", ifName))
+
+	prog := "bpftool"
+	args := []string{
+		"prog",
+		"pin",
+		"id",
+		strconv.Itoa(progID),
+		tmpPath}
+
+	printCommand(prog, args...)
+	output, err := exec.Command(prog, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to pin chained XDP program %d: %s\n%s", progID, err, output)
+	}
+	defer func() {
+		if err := os.Remove(tmpPath); err != nil {
+			log.WithError(err).WithField("path", tmpPath).Warn("Failed to remove temporary pin")
+		}
+	}()
+
+	prog = "ip"
+	args = []string{
+		"-force",
+		"link",
+		"set",
+		"dev",
+		ifName,
+		mode.String(),
+		"pinned",
+		tmpPath}
+
+	printCommand(prog, args...)
+	output, err = exec.Command(prog, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to restore chained XDP program %d on %s: %s\n%s", progID, ifName, err, output)
+	}
+
+	return os.Remove(filepath.Join(b.xdpDir, getXDPChainMapName(ifName)))
+}
+
 func (b *BPFLib) GetXDPObjTag(objPath string) (tag string, err error) {
 	// To find out what tag is assigned to an XDP object we create a temporary
 	// veth pair and load the program. Then, the kernel will assign the tag and
@@ -97,6 +97,13 @@ func (m *Manager) ResyncFailsafes() error {
 			return
 		}
 
+		if p.Interface != "" {
+			// The failsafe map is keyed on protocol, port and CIDR only.  Err on the side of
+			// keeping the port reachable.
+			log.WithField("iface", p.Interface).Warn(
+				"Interface-qualified failsafe ports are not supported in BPF mode; applying to all interfaces.")
+		}
+
 		// Parse the CIDR and split out the IP and mask
 		cidr := p.Net
 		if p.Net == "" {
@@ -53,6 +53,9 @@ type bpfIPSets struct {
 	dirtyIPSetIDs   set.Set
 	resyncScheduled bool
 
+	// numInconsistencies counts the unexpected and missing entries that we've found when resyncing.
+	numInconsistencies int
+
 	opRecorder logutils.OpRecorder
 }
 
@@ -201,6 +204,12 @@ func (m *bpfIPSets) RemoveMembers(setID string, removedMembers []string) {
 	m.markIPSetDirty(ipSet)
 }
 
+// NumInconsistencies returns the number of unexpected and missing entries that we've found (and
+// queued for repair) since we were created.
+func (m *bpfIPSets) NumInconsistencies() int {
+	return m.numInconsistencies
+}
+
 // QueueResync forces a resync with the dataplane on the next ApplyUpdates() call.
 func (m *bpfIPSets) QueueResync() {
 	log.Debug("Asked to resync with the dataplane on next update.")
@@ -243,8 +252,18 @@ func (m *bpfIPSets) ApplyUpdates() {
 		m.dirtyIPSetIDs.Clear()
 
 		// Start by configuring every IP set to add all its entries to the dataplane.  Then, as we scan the dataplane,
-		// we'll make sure that each gets cleaned up.
+		// we'll make sure that each gets cleaned up.  Remember the changes that were already pending so that we
+		// only count the changes that the resync finds as inconsistencies.
+		alreadyPending := set.New()
 		for _, ipSet := range m.ipSets {
+			ipSet.PendingAdds.Iter(func(item interface{}) error {
+				alreadyPending.Add(item)
+				return nil
+			})
+			ipSet.PendingRemoves.Iter(func(item interface{}) error {
+				alreadyPending.Add(item)
+				return nil
+			})
 			ipSet.PendingAdds = ipSet.DesiredEntries.Copy()
 			ipSet.PendingRemoves.Clear()
 		}
@@ -278,6 +297,18 @@ func (m *bpfIPSets) ApplyUpdates() {
 			m.resyncScheduled = true
 		}
 
+		for _, ipSet := range m.ipSets {
+			for _, s := range []set.Set{ipSet.PendingAdds, ipSet.PendingRemoves} {
+				s.Iter(func(item interface{}) error {
+					if !alreadyPending.Contains(item) {
+						m.numInconsistencies++
+					}
+					return nil
+				})
+			}
+		}
+		m.numInconsistencies += len(unknownEntries)
+
 		for _, entry := range unknownEntries {
 			err := m.bpfMap.Delete(entry[:])
 			if err != nil {
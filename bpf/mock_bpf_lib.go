@@ -73,6 +73,8 @@ type XDPInfo struct {
 	Maps  []int
 	Bytes []byte
 	Mode  XDPMode
+	// Foreign is set for programs that weren't loaded by us, so aren't pinned.
+	Foreign bool
 }
 
 type SockMapInfo struct {
@@ -96,6 +98,7 @@ type SkMsgInfo struct {
 type MockBPFLib struct {
 	binDir              string
 	XDPProgs            map[string]XDPInfo      // iface -> []maps
+	ChainedXDPProgs     map[string]XDPInfo      // iface -> program that ours passes packets to
 	CIDRMaps            map[CIDRMapsKey]CIDRMap // iface -> map[ip]refCount
 	SockopsProg         *SockopsInfo
 	SockMap             *SockMap
@@ -107,10 +110,11 @@ type MockBPFLib struct {
 
 func NewMockBPFLib(binDir string) *MockBPFLib {
 	return &MockBPFLib{
-		binDir:      binDir,
-		XDPProgs:    make(map[string]XDPInfo),
-		CIDRMaps:    make(map[CIDRMapsKey]CIDRMap),
-		CgroupV2Dir: "/sys/fs/cgroup/unified",
+		binDir:          binDir,
+		XDPProgs:        make(map[string]XDPInfo),
+		ChainedXDPProgs: make(map[string]XDPInfo),
+		CIDRMaps:        make(map[CIDRMapsKey]CIDRMap),
+		CgroupV2Dir:     "/sys/fs/cgroup/unified",
 	}
 }
 
@@ -264,6 +268,31 @@ func (b *MockBPFLib) GetXDPTag(ifName string) (string, error) {
 	return GetMockXDPTag(info.Bytes), nil
 }
 
+func (b *MockBPFLib) GetPinnedXDPID(ifName string) (int, error) {
+	info, ok := b.XDPProgs[ifName]
+	if !ok || info.Foreign {
+		return -1, errors.New("xdp program not found")
+	}
+	return info.Id, nil
+}
+
+func (b *MockBPFLib) GetChainedXDPID(ifName string) (int, error) {
+	info, ok := b.ChainedXDPProgs[ifName]
+	if !ok {
+		return -1, nil
+	}
+	return info.Id, nil
+}
+
+func (b *MockBPFLib) ChainXDP(ifName string, progID int) error {
+	info, ok := b.XDPProgs[ifName]
+	if !ok || info.Id != progID {
+		return fmt.Errorf("xdp program %d not found", progID)
+	}
+	b.ChainedXDPProgs[ifName] = info
+	return nil
+}
+
 func (b *MockBPFLib) IsValidMap(ifName string, family IPFamily) (bool, error) {
 	key := CIDRMapsKey{
 		IfName: ifName,
@@ -433,6 +462,13 @@ func (b *MockBPFLib) RemoveXDP(ifName string, mode XDPMode) error {
 		return fmt.Errorf("xdp program has mode %s, not %s", info.Mode.String(), mode.String())
 	}
 
+	if chained, ok := b.ChainedXDPProgs[ifName]; ok {
+		// Like the real library, we restore the chained program in our program's mode.
+		chained.Mode = mode
+		b.XDPProgs[ifName] = chained
+		delete(b.ChainedXDPProgs, ifName)
+		return nil
+	}
 	delete(b.XDPProgs, ifName)
 	return nil
 }
@@ -127,7 +127,11 @@ out:
 	return nil
 }
 
-func InstallConnectTimeLoadBalancer(frontendMap, backendMap, rtMap bpf.Map, cgroupv2 string, logLevel string) error {
+// InstallConnectTimeLoadBalancer attaches the connect-time programs.  They share the affinity map
+// with the TC programs so that the proxy expires their ClientIP affinity entries too, and the
+// backend selection map so that they use the same round-robin counters.  They key the affinity on
+// the address that the socket is bound to; unbound sockets share the affinity of 0.0.0.0.
+func InstallConnectTimeLoadBalancer(frontendMap, backendMap, affinityMap, selectionMap, rtMap bpf.Map, cgroupv2 string, logLevel string) error {
 	bpfMount, err := bpf.MaybeMountBPFfs()
 	if err != nil {
 		log.WithError(err).Error("Failed to mount bpffs, unable to do connect-time load balancing")
@@ -159,7 +163,7 @@ func InstallConnectTimeLoadBalancer(frontendMap, backendMap, rtMap bpf.Map, cgro
 		return errors.WithMessage(err, "failed to create all-NATs BPF Map")
 	}
 
-	maps := []bpf.Map{frontendMap, backendMap, rtMap, sendrecvMap, allNATsMap}
+	maps := []bpf.Map{frontendMap, backendMap, affinityMap, selectionMap, rtMap, sendrecvMap, allNATsMap}
 
 	err = installProgram("connect", "4", bpfMount, cgroupPath, logLevel, maps...)
 	if err != nil {
@@ -362,6 +362,91 @@ func BackendMapMemIter(m BackendMapMem) bpf.IterCallback {
 	}
 }
 
+// struct calico_nat_v4_sel_key {
+//    uint32_t id;
+//    uint32_t slot;
+// };
+//
+// struct calico_nat_v4_sel_val {
+//    uint32_t ordinal;
+// };
+const (
+	selectionKeySize   = 8
+	selectionValueSize = 4
+)
+
+const (
+	// RoundRobinSlot is the slot of the backend selection map that holds a round-robin service's
+	// counter.
+	RoundRobinSlot = 0xffffffff
+	// MaglevTableSize is the number of slots in a Maglev service's lookup table.  Maglev needs a
+	// prime, and it should be much larger than the number of backends.
+	MaglevTableSize = 1021
+)
+
+// SelectionKey is a key into the backend selection map: a service ID and a slot.
+type SelectionKey [selectionKeySize]byte
+
+func NewSelectionKey(id, slot uint32) SelectionKey {
+	var k SelectionKey
+	binary.LittleEndian.PutUint32(k[:4], id)
+	binary.LittleEndian.PutUint32(k[4:8], slot)
+	return k
+}
+
+func (k SelectionKey) ID() uint32 {
+	return binary.LittleEndian.Uint32(k[:4])
+}
+
+func (k SelectionKey) Slot() uint32 {
+	return binary.LittleEndian.Uint32(k[4:8])
+}
+
+func (k SelectionKey) String() string {
+	return fmt.Sprintf("NATSelectionKey{ID:%d,Slot:%d}", k.ID(), k.Slot())
+}
+
+func (k SelectionKey) AsBytes() []byte {
+	return k[:]
+}
+
+// SelectionValue is the ordinal of a backend in a Maglev lookup table or, in the round-robin
+// slot, a counter.
+type SelectionValue [selectionValueSize]byte
+
+func NewSelectionValue(ordinal uint32) SelectionValue {
+	var v SelectionValue
+	binary.LittleEndian.PutUint32(v[:], ordinal)
+	return v
+}
+
+func (v SelectionValue) Ordinal() uint32 {
+	return binary.LittleEndian.Uint32(v[:])
+}
+
+func (v SelectionValue) String() string {
+	return fmt.Sprintf("NATSelectionValue{Ordinal:%d}", v.Ordinal())
+}
+
+func (v SelectionValue) AsBytes() []byte {
+	return v[:]
+}
+
+var SelectionMapParameters = bpf.MapParameters{
+	Filename:   "/sys/fs/bpf/tc/globals/cali_v4_nat_sel",
+	Type:       "hash",
+	KeySize:    selectionKeySize,
+	ValueSize:  selectionValueSize,
+	MaxEntries: 1021000,
+	Name:       "cali_v4_nat_sel",
+	Flags:      unix.BPF_F_NO_PREALLOC,
+}
+
+// SelectionMap returns an instance of the backend selection map
+func SelectionMap(mc *bpf.MapContext) bpf.Map {
+	return mc.NewPinnedMap(SelectionMapParameters)
+}
+
 // struct calico_nat_v4_affinity_key {
 //    struct calico_nat_v4 nat_key;
 // 	  uint32_t client_ip;
@@ -149,6 +149,9 @@ type Rules struct {
 	// DefaultEndpointToHostAction.
 	SuppressNormalHostPolicy bool
 
+	// AllowIGMP allows IGMP before any policy, so that workloads can join multicast groups.
+	AllowIGMP bool
+
 	// Workload policy.
 	Tiers    []Tier
 	Profiles []Profile
@@ -174,6 +177,11 @@ func (p *Builder) Instructions(rules Rules) (Insns, error) {
 	p.b = NewBlock()
 	p.writeProgramHeader()
 
+	if rules.AllowIGMP {
+		p.b.Load8(R1, R9, stateOffIPProto)
+		p.b.JumpEqImm64(R1, 2 /* IGMP */, "allow")
+	}
+
 	// Pre-DNAT policy: on a host interface, or host-* policy on a workload interface.  Traffic
 	// is allowed to continue if there is no applicable pre-DNAT policy.
 	p.writeTiers(rules.HostPreDnatTiers, legDestPreNAT, "allowed_by_host_policy")
@@ -373,6 +381,20 @@ func (p *Builder) writePolicyRules(policy Policy, actionLabels map[string]string
 			log.Debug("Skipping log rule.  Not supported in BPF mode.")
 			continue
 		}
+		if len(rule.DstDomains) > 0 {
+			// The IP sets behind domain matches are only maintained in iptables mode, so fail
+			// closed: an allow rule matches nothing, while a deny or pass rule denies everything
+			// that its other matches select, whatever the destination.
+			if action == "allow" {
+				log.Debug("Destination domains aren't supported in BPF mode, skipping allow rule.")
+				continue
+			}
+			log.Debug("Destination domains aren't supported in BPF mode, denying all destinations.")
+			withoutDomains := *rule.Rule
+			withoutDomains.DstDomains = nil
+			rule = Rule{Rule: &withoutDomains}
+			action = "deny"
+		}
 		p.writeRule(rule, actionLabels[action], destLeg)
 		log.Debugf("End of rule %d", ruleIdx)
 	}
@@ -19,6 +19,7 @@ import (
 
 	. "github.com/onsi/gomega"
 
+	"github.com/projectcalico/felix/bpf/asm"
 	"github.com/projectcalico/felix/idalloc"
 	"github.com/projectcalico/felix/proto"
 )
@@ -99,3 +100,38 @@ func TestLogActionIgnored(t *testing.T) {
 	Expect(err).NotTo(HaveOccurred())
 	Expect(noOpInsns).To(Equal(insns))
 }
+
+func TestDomainRulesFailClosed(t *testing.T) {
+	RegisterTestingT(t)
+	alloc := idalloc.New()
+
+	instructions := func(rules ...*proto.Rule) asm.Insns {
+		var polRules []Rule
+		for _, r := range rules {
+			polRules = append(polRules, Rule{Rule: r})
+		}
+		pg := NewBuilder(alloc, 1, 2, 3)
+		insns, err := pg.Instructions(Rules{
+			Tiers: []Tier{{
+				Name: "default",
+				Policies: []Policy{{
+					Name:  "test policy",
+					Rules: polRules,
+				}},
+			}}})
+		Expect(err).NotTo(HaveOccurred())
+		return insns
+	}
+	domains := []string{"example.com"}
+	tcp := &proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "TCP"}}
+
+	// An allow rule with domains matches nothing.
+	Expect(instructions(&proto.Rule{Action: "Allow", Protocol: tcp, DstDomains: domains})).To(
+		Equal(instructions()))
+	// Deny and pass rules with domains deny all destinations.
+	denyTCP := instructions(&proto.Rule{Action: "Deny", Protocol: tcp})
+	Expect(instructions(&proto.Rule{Action: "Deny", Protocol: tcp, DstDomains: domains})).To(
+		Equal(denyTCP))
+	Expect(instructions(&proto.Rule{Action: "Pass", Protocol: tcp, DstDomains: domains})).To(
+		Equal(denyTCP))
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8sp "k8s.io/kubernetes/pkg/proxy"
+
+	"github.com/projectcalico/felix/bpf/nat"
+)
+
+// HostPort is a hostPort of a local pod: connections to HostIP:HostPort (or, if HostIP is nil, to
+// any of the node's IPs) are forwarded to PodIP:Port.
+type HostPort struct {
+	Pod      types.NamespacedName
+	HostIP   net.IP
+	HostPort int
+	Protocol v1.Protocol
+	PodIP    net.IP
+	Port     int
+}
+
+// hostPortsOfPod returns the (IPv4) hostPorts of the pod's containers.  Pods that use the host's
+// network namespace don't need their hostPorts forwarding so they have none.
+func hostPortsOfPod(pod *v1.Pod) []HostPort {
+	if pod.Spec.HostNetwork || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		return nil
+	}
+	podIP := net.ParseIP(pod.Status.PodIP).To4()
+	if podIP == nil {
+		return nil
+	}
+	var hostPorts []HostPort
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.HostPort == 0 {
+				continue
+			}
+			hp := HostPort{
+				Pod:      types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name},
+				HostPort: int(p.HostPort),
+				Protocol: p.Protocol,
+				PodIP:    podIP,
+				Port:     int(p.ContainerPort),
+			}
+			if hp.Protocol == "" {
+				hp.Protocol = v1.ProtocolTCP
+			}
+			if p.HostIP != "" {
+				hostIP := net.ParseIP(p.HostIP).To4()
+				if hostIP == nil {
+					// We only handle IPv4.
+					continue
+				}
+				if !hostIP.IsUnspecified() {
+					hp.HostIP = hostIP
+				}
+			}
+			hostPorts = append(hostPorts, hp)
+		}
+	}
+	return hostPorts
+}
+
+// sortHostPorts sorts the hostPorts by pod, so that conflicts are resolved the same way on every
+// Apply().
+func sortHostPorts(hostPorts []HostPort) {
+	sort.SliceStable(hostPorts, func(i, j int) bool {
+		return hostPorts[i].Pod.String() < hostPorts[j].Pod.String()
+	})
+}
+
+// withHostPorts returns a copy of the state with a pseudo-service for each hostPort on each of the
+// IPs that it applies to.  A hostPort that clashes with a node port, or with a frontend of a
+// service or an earlier hostPort, is left out.
+func (s *Syncer) withHostPorts(state DPSyncerState) DPSyncerState {
+	if len(state.HostPorts) == 0 {
+		return state
+	}
+
+	svcs := make(k8sp.ServiceMap, len(state.SvcMap)+len(state.HostPorts))
+	eps := make(k8sp.EndpointsMap, len(state.EpsMap)+len(state.HostPorts))
+	for k, v := range state.SvcMap {
+		svcs[k] = v
+	}
+	for k, v := range state.EpsMap {
+		eps[k] = v
+	}
+
+	type portProto struct {
+		port  int
+		proto v1.Protocol
+	}
+	nodePorts := map[portProto]k8sp.ServicePortName{}
+	frontends := map[nat.FrontendKey]string{}
+	addFrontend := func(addr string, port int, proto v1.Protocol, owner string) {
+		if ip := net.ParseIP(addr); ip != nil {
+			frontends[nat.NewNATKey(ip, uint16(port), ProtoV1ToIntPanic(proto))] = owner
+		}
+	}
+	for sname, sinfo := range state.SvcMap {
+		addFrontend(sinfo.ClusterIP().String(), sinfo.Port(), sinfo.Protocol(), sname.String())
+		for _, extIP := range sinfo.ExternalIPStrings() {
+			addFrontend(extIP, sinfo.Port(), sinfo.Protocol(), sname.String())
+		}
+		for _, lbIP := range sinfo.LoadBalancerIPStrings() {
+			addFrontend(lbIP, sinfo.Port(), sinfo.Protocol(), sname.String())
+		}
+		if sinfo.NodePort() != 0 {
+			nodePorts[portProto{sinfo.NodePort(), sinfo.Protocol()}] = sname
+		}
+	}
+
+	var hostIPs []net.IP
+	for _, ip := range s.nodePortIPs {
+		if !ip.Equal(podNPIP) {
+			hostIPs = append(hostIPs, ip)
+		}
+	}
+
+	for _, hp := range state.HostPorts {
+		logCxt := log.WithFields(log.Fields{
+			"pod":      hp.Pod,
+			"hostPort": hp.HostPort,
+			"protocol": hp.Protocol,
+		})
+		if _, err := ProtoV1ToInt(hp.Protocol); err != nil {
+			logCxt.WithError(err).Warn("Unsupported hostPort protocol, ignoring hostPort.")
+			continue
+		}
+		if sname, ok := nodePorts[portProto{hp.HostPort, hp.Protocol}]; ok {
+			logCxt.WithField("service", sname).Warn("HostPort conflicts with a node port, ignoring hostPort.")
+			continue
+		}
+		ips := hostIPs
+		if hp.HostIP != nil {
+			ips = []net.IP{hp.HostIP}
+		}
+		for _, ip := range ips {
+			key := nat.NewNATKey(ip, uint16(hp.HostPort), ProtoV1ToIntPanic(hp.Protocol))
+			if owner, ok := frontends[key]; ok {
+				logCxt.WithFields(log.Fields{"hostIP": ip, "owner": owner}).Warn(
+					"HostPort conflicts with another service or hostPort, ignoring hostPort.")
+				continue
+			}
+			frontends[key] = hp.Pod.String()
+
+			sname := k8sp.ServicePortName{
+				NamespacedName: hp.Pod,
+				// Port names can't contain a ":" so this can't clash with a real service.
+				Port:     fmt.Sprintf("hostport:%s:%d", ip, hp.HostPort),
+				Protocol: hp.Protocol,
+			}
+			svcs[sname] = NewK8sServicePort(ip, hp.HostPort, hp.Protocol)
+			eps[sname] = []k8sp.Endpoint{&k8sp.BaseEndpointInfo{
+				Endpoint: net.JoinHostPort(hp.PodIP.String(), strconv.Itoa(hp.Port)),
+				IsLocal:  true,
+			}}
+		}
+	}
+
+	state.SvcMap = svcs
+	state.EpsMap = eps
+	return state
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy_test
+
+import (
+	"net"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8sp "k8s.io/kubernetes/pkg/proxy"
+
+	"github.com/projectcalico/felix/bpf/cachingmap"
+	"github.com/projectcalico/felix/bpf/nat"
+	proxy "github.com/projectcalico/felix/bpf/proxy"
+)
+
+var _ = Describe("BPF host ports", func() {
+	pod1 := types.NamespacedName{Namespace: "default", Name: "pod1"}
+	pod2 := types.NamespacedName{Namespace: "default", Name: "pod2"}
+	tcp := proxy.ProtoV1ToIntPanic(v1.ProtocolTCP)
+	nodeIPs := []net.IP{net.IPv4(192, 168, 0, 1), net.IPv4(10, 123, 0, 1)}
+
+	var (
+		svcs *mockNATMap
+		eps  *mockNATBackendMap
+		s    *proxy.Syncer
+	)
+
+	BeforeEach(func() {
+		svcs = newMockNATMap()
+		eps = newMockNATBackendMap()
+		var err error
+		s, err = proxy.NewSyncer(nodeIPs,
+			cachingmap.New(nat.FrontendMapParameters, svcs),
+			cachingmap.New(nat.BackendMapParameters, eps),
+			newMockAffinityMap(), proxy.NewRTCache())
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	backendOf := func(key nat.FrontendKey) nat.BackendValue {
+		fe, ok := svcs.m[key]
+		ExpectWithOffset(1, ok).To(BeTrue(), "missing frontend %v", key)
+		return eps.m[nat.NewNATBackendKey(fe.ID(), 0)]
+	}
+
+	It("should forward a hostPort on all the node's IPs, or on its hostIP", func() {
+		Expect(s.Apply(proxy.DPSyncerState{
+			SvcMap: k8sp.ServiceMap{},
+			EpsMap: k8sp.EndpointsMap{},
+			HostPorts: []proxy.HostPort{
+				{Pod: pod1, HostPort: 8080, Protocol: v1.ProtocolTCP, PodIP: net.IPv4(10, 65, 0, 1), Port: 80},
+				{Pod: pod2, HostIP: nodeIPs[1], HostPort: 8081, Protocol: v1.ProtocolTCP,
+					PodIP: net.IPv4(10, 65, 0, 2), Port: 80},
+			},
+		})).To(Succeed())
+
+		Expect(svcs.m).To(HaveLen(3))
+		for _, ip := range nodeIPs {
+			Expect(backendOf(nat.NewNATKey(ip, 8080, tcp))).To(
+				Equal(nat.NewNATBackendValue(net.IPv4(10, 65, 0, 1), 80)))
+		}
+		Expect(backendOf(nat.NewNATKey(nodeIPs[1], 8081, tcp))).To(
+			Equal(nat.NewNATBackendValue(net.IPv4(10, 65, 0, 2), 80)))
+	})
+
+	It("should ignore hostPorts that conflict with node ports or earlier hostPorts", func() {
+		svcKey := k8sp.ServicePortName{NamespacedName: types.NamespacedName{Namespace: "default", Name: "np"}}
+		Expect(s.Apply(proxy.DPSyncerState{
+			SvcMap: k8sp.ServiceMap{
+				svcKey: proxy.NewK8sServicePort(net.IPv4(10, 96, 0, 10), 80, v1.ProtocolTCP,
+					proxy.K8sSvcWithNodePort(30080)),
+			},
+			EpsMap: k8sp.EndpointsMap{},
+			HostPorts: []proxy.HostPort{
+				{Pod: pod1, HostPort: 30080, Protocol: v1.ProtocolTCP, PodIP: net.IPv4(10, 65, 0, 1), Port: 80},
+				{Pod: pod1, HostIP: nodeIPs[0], HostPort: 8080, Protocol: v1.ProtocolTCP,
+					PodIP: net.IPv4(10, 65, 0, 1), Port: 80},
+				{Pod: pod2, HostPort: 8080, Protocol: v1.ProtocolTCP, PodIP: net.IPv4(10, 65, 0, 2), Port: 80},
+			},
+		})).To(Succeed())
+
+		Expect(backendOf(nat.NewNATKey(nodeIPs[0], 30080, tcp))).NotTo(
+			Equal(nat.NewNATBackendValue(net.IPv4(10, 65, 0, 1), 80)))
+		Expect(backendOf(nat.NewNATKey(nodeIPs[0], 8080, tcp))).To(
+			Equal(nat.NewNATBackendValue(net.IPv4(10, 65, 0, 1), 80)))
+		Expect(backendOf(nat.NewNATKey(nodeIPs[1], 8080, tcp))).To(
+			Equal(nat.NewNATBackendValue(net.IPv4(10, 65, 0, 2), 80)))
+	})
+
+	It("should pass the hostPorts of the local pods to the syncer", func() {
+		pod := &v1.Pod{
+			TypeMeta:   typeMetaV1("Pod"),
+			ObjectMeta: objectMeataV1("pod1"),
+			Spec: v1.PodSpec{
+				NodeName: "testnode",
+				Containers: []v1.Container{{
+					Name: "web",
+					Ports: []v1.ContainerPort{
+						{ContainerPort: 80, HostPort: 8080},
+						{ContainerPort: 53, HostPort: 5353, Protocol: v1.ProtocolUDP, HostIP: "10.123.0.1"},
+						{ContainerPort: 9090},
+					},
+				}},
+			},
+			Status: v1.PodStatus{PodIP: "10.65.0.1"},
+		}
+
+		syncStop := make(chan struct{})
+		dp := newMockSyncer(syncStop)
+		p, err := proxy.New(fake.NewSimpleClientset(pod), dp, "testnode",
+			proxy.WithImmediateSync(), proxy.WithHostPorts())
+		Expect(err).NotTo(HaveOccurred())
+		defer func() {
+			close(syncStop)
+			p.Stop()
+		}()
+
+		dp.checkState(func(s proxy.DPSyncerState) {
+			Expect(s.HostPorts).To(Equal([]proxy.HostPort{
+				{Pod: pod1, HostPort: 8080, Protocol: v1.ProtocolTCP, PodIP: net.IPv4(10, 65, 0, 1).To4(), Port: 80},
+				{Pod: pod1, HostIP: net.IPv4(10, 123, 0, 1).To4(), HostPort: 5353, Protocol: v1.ProtocolUDP,
+					PodIP: net.IPv4(10, 65, 0, 1).To4(), Port: 53},
+			}))
+		})
+	})
+})
@@ -52,6 +52,9 @@ type KubeProxy struct {
 	opts        []Option
 
 	dsrEnabled bool
+
+	backendSelection string
+	selectionMap     bpf.Map
 }
 
 // StartKubeProxy start a new kube-proxy if there was no error
@@ -118,6 +121,13 @@ func (kp *KubeProxy) run(hostIPs []net.IP) error {
 		return errors.WithMessage(err, "new bpf syncer")
 	}
 
+	if kp.selectionMap != nil {
+		selCache := cachingmap.New(nat.SelectionMapParameters, kp.selectionMap)
+		if err := syncer.SetBackendSelection(kp.backendSelection, selCache); err != nil {
+			return errors.WithMessage(err, "bpf syncer backend selection")
+		}
+	}
+
 	proxy, err := New(kp.k8s, syncer, kp.hostname, kp.opts...)
 	if err != nil {
 		return errors.WithMessage(err, "new proxy")
@@ -0,0 +1,72 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"hash/fnv"
+	"sort"
+)
+
+// maglevTable builds a Maglev lookup table with m slots (m must be prime) for the given backends
+// and returns, for each slot, the index of its backend in backends.  The table only depends on the
+// set of backends and not on their order, so nodes that order the backends differently still
+// agree on which backend each slot leads to.  Adding or removing a backend only moves a small
+// share of the slots.
+func maglevTable(backends []string, m int) []uint32 {
+	n := len(backends)
+	if n == 0 {
+		return nil
+	}
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return backends[order[a]] < backends[order[b]]
+	})
+
+	offset := make([]uint64, n)
+	skip := make([]uint64, n)
+	for i, idx := range order {
+		h1 := fnv.New64a()
+		_, _ = h1.Write([]byte(backends[idx]))
+		h2 := fnv.New64()
+		_, _ = h2.Write([]byte(backends[idx]))
+		offset[i] = h1.Sum64() % uint64(m)
+		skip[i] = h2.Sum64()%uint64(m-1) + 1
+	}
+
+	table := make([]uint32, m)
+	filled := make([]bool, m)
+	next := make([]uint64, n)
+
+	for count := 0; ; {
+		for i, idx := range order {
+			c := (offset[i] + next[i]*skip[i]) % uint64(m)
+			for filled[c] {
+				next[i]++
+				c = (offset[i] + next[i]*skip[i]) % uint64(m)
+			}
+			table[c] = uint32(idx)
+			filled[c] = true
+			next[i]++
+			count++
+			if count == m {
+				return table
+			}
+		}
+	}
+}
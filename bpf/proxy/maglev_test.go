@@ -0,0 +1,90 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func maglevBackends(n int) []string {
+	backends := make([]string, n)
+	for i := range backends {
+		backends[i] = fmt.Sprintf("10.0.%d.%d:8080", i/256, i%256)
+	}
+	return backends
+}
+
+func TestMaglevTableEmpty(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(maglevTable(nil, 13)).To(BeNil())
+}
+
+func TestMaglevTableBalanced(t *testing.T) {
+	RegisterTestingT(t)
+
+	table := maglevTable(maglevBackends(10), 1021)
+	Expect(table).To(HaveLen(1021))
+
+	counts := make([]int, 10)
+	for _, idx := range table {
+		Expect(idx).To(BeNumerically("<", 10))
+		counts[idx]++
+	}
+	for _, c := range counts {
+		// Maglev fills the slots round robin so the backends differ by at most one slot.
+		Expect(c).To(BeNumerically(">=", 102))
+		Expect(c).To(BeNumerically("<=", 103))
+	}
+}
+
+func TestMaglevTableOrderIndependent(t *testing.T) {
+	RegisterTestingT(t)
+
+	backends := maglevBackends(5)
+	reversed := make([]string, len(backends))
+	for i, b := range backends {
+		reversed[len(backends)-1-i] = b
+	}
+
+	table := maglevTable(backends, 1021)
+	revTable := maglevTable(reversed, 1021)
+	for slot := range table {
+		Expect(reversed[revTable[slot]]).To(Equal(backends[table[slot]]))
+	}
+}
+
+func TestMaglevTableMinimalDisruption(t *testing.T) {
+	RegisterTestingT(t)
+
+	backends := maglevBackends(10)
+	table := maglevTable(backends, 1021)
+	table2 := maglevTable(backends[:9], 1021)
+
+	moved := 0
+	for slot := range table {
+		if int(table[slot]) == 9 {
+			continue
+		}
+		if table2[slot] != table[slot] {
+			moved++
+		}
+	}
+	// Only the removed backend's slots need to move; allow some slack for the repermutation.
+	Expect(moved).To(BeNumerically("<", 1021/10))
+}
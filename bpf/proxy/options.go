@@ -18,6 +18,8 @@ import (
 	"time"
 
 	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/bpf"
 )
 
 // Option defines Proxy options
@@ -69,6 +71,15 @@ func WithEndpointsSlices() Option {
 	})
 }
 
+// WithHostPorts enables forwarding of the local pods' hostPorts
+func WithHostPorts() Option {
+	return makeOption(func(p *proxy) error {
+		p.hostPortsEnabled = true
+		log.Infof("proxy.WithHostPorts()")
+		return nil
+	})
+}
+
 // WithDSREnabled sets the DSR mode
 func WithDSREnabled() Option {
 	return makeKubeProxyOption(func(kp *KubeProxy) error {
@@ -76,3 +87,14 @@ func WithDSREnabled() Option {
 		return nil
 	})
 }
+
+// WithBackendSelection sets the algorithm that picks the backend for new flows to a service and
+// the map that the algorithm's state is programmed into.
+func WithBackendSelection(alg string, selMap bpf.Map) Option {
+	return makeKubeProxyOption(func(kp *KubeProxy) error {
+		kp.backendSelection = alg
+		kp.selectionMap = selMap
+		log.Infof("proxy.WithBackendSelection(%s)", alg)
+		return nil
+	})
+}
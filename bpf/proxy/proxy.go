@@ -19,6 +19,7 @@ package proxy
 
 import (
 	"net"
+	"reflect"
 	"sync"
 	"time"
 
@@ -27,11 +28,15 @@ import (
 	v1 "k8s.io/api/core/v1"
 	discovery "k8s.io/api/discovery/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	k8sp "k8s.io/kubernetes/pkg/proxy"
 	"k8s.io/kubernetes/pkg/proxy/apis"
@@ -51,6 +56,9 @@ type Proxy interface {
 type DPSyncerState struct {
 	SvcMap k8sp.ServiceMap
 	EpsMap k8sp.EndpointsMap
+	// HostPorts are the hostPorts of the local pods, sorted by pod, if host port support is
+	// enabled.
+	HostPorts []HostPort
 }
 
 // DPSyncer is an interface representing the dataplane syncer that applies the
@@ -79,6 +87,12 @@ type proxy struct {
 
 	endpointSlicesEnabled bool
 
+	// hostPortsEnabled enables the pod watcher that fills in hostPorts.  The watcher's
+	// handlers run in the informer's goroutine, hence the lock.
+	hostPortsEnabled bool
+	hostPortsLock    sync.Mutex
+	hostPorts        map[types.NamespacedName][]HostPort
+
 	dpSyncer DPSyncer
 	// executes periodic the dataplane updates
 	runner *async.BoundedFrequencyRunner
@@ -189,6 +203,15 @@ func New(k8s kubernetes.Interface, dp DPSyncer, hostname string, opts ...Option)
 		epsRunner = epsConfig
 	}
 
+	// If we're not watching pods, they count as synced straight away.  That has to happen before
+	// the services and endpoints can sync, or their syncs would find us uninitialised and the
+	// first sync of the dataplane would wait for the periodic resync.
+	if p.hostPortsEnabled {
+		p.startHostPortsWatcher()
+	} else {
+		p.setPodsSynced()
+	}
+
 	p.startRoutine(func() { p.runner.Loop(p.stopCh) })
 	p.startRoutine(func() { epsRunner.Run(p.stopCh) })
 	p.startRoutine(func() { informerFactory.Start(p.stopCh) })
@@ -242,8 +265,9 @@ func (p *proxy) invokeDPSyncer() {
 	}
 
 	err := p.dpSyncer.Apply(DPSyncerState{
-		SvcMap: p.svcMap,
-		EpsMap: p.epsMap,
+		SvcMap:    p.svcMap,
+		EpsMap:    p.epsMap,
+		HostPorts: p.currentHostPorts(),
 	})
 
 	if err != nil {
@@ -295,6 +319,74 @@ func (p *proxy) OnEndpointsSynced() {
 	p.forceSyncDP()
 }
 
+// startHostPortsWatcher starts watching the pods on this node for hostPorts.
+func (p *proxy) startHostPortsWatcher() {
+	p.hostPorts = map[types.NamespacedName][]HostPort{}
+	informer := coreinformers.NewFilteredPodInformer(p.k8s, metav1.NamespaceAll, p.syncPeriod,
+		cache.Indexers{}, func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("spec.nodeName", p.hostname).String()
+		})
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			p.onPodUpdate(obj, false)
+		},
+		UpdateFunc: func(_, newObj interface{}) {
+			p.onPodUpdate(newObj, false)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			p.onPodUpdate(obj, true)
+		},
+	})
+	p.startRoutine(func() { informer.Run(p.stopCh) })
+	p.startRoutine(func() {
+		if cache.WaitForCacheSync(p.stopCh, informer.HasSynced) {
+			p.setPodsSynced()
+			p.forceSyncDP()
+		}
+	})
+}
+
+func (p *proxy) onPodUpdate(obj interface{}, deleted bool) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		return
+	}
+	key := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}
+	var hostPorts []HostPort
+	if !deleted {
+		hostPorts = hostPortsOfPod(pod)
+	}
+
+	p.hostPortsLock.Lock()
+	changed := !reflect.DeepEqual(hostPorts, p.hostPorts[key])
+	if len(hostPorts) > 0 {
+		p.hostPorts[key] = hostPorts
+	} else {
+		delete(p.hostPorts, key)
+	}
+	p.hostPortsLock.Unlock()
+
+	if changed && p.isInitialized() {
+		p.syncDP()
+	}
+}
+
+// currentHostPorts returns the hostPorts of all the local pods, sorted by pod.
+func (p *proxy) currentHostPorts() []HostPort {
+	p.hostPortsLock.Lock()
+	defer p.hostPortsLock.Unlock()
+
+	var hostPorts []HostPort
+	for _, hps := range p.hostPorts {
+		hostPorts = append(hostPorts, hps...)
+	}
+	sortHostPorts(hostPorts)
+	return hostPorts
+}
+
 func (p *proxy) OnEndpointSliceAdd(eps *discovery.EndpointSlice) {
 	if p.epsChanges.EndpointSliceUpdate(eps, false) && p.isInitialized() {
 		p.syncDP()
@@ -322,12 +414,13 @@ type initState struct {
 	lck        sync.RWMutex
 	svcsSynced bool
 	epsSynced  bool
+	podsSynced bool
 }
 
 func (is *initState) isInitialized() bool {
 	is.lck.RLock()
 	defer is.lck.RUnlock()
-	return is.svcsSynced && is.epsSynced
+	return is.svcsSynced && is.epsSynced && is.podsSynced
 }
 
 func (is *initState) setSvcsSynced() {
@@ -342,6 +435,12 @@ func (is *initState) setEpsSynced() {
 	is.epsSynced = true
 }
 
+func (is *initState) setPodsSynced() {
+	is.lck.Lock()
+	defer is.lck.Unlock()
+	is.podsSynced = true
+}
+
 type loggerRecorder struct{}
 
 func (r *loggerRecorder) Event(object runtime.Object, eventtype, reason, message string) {
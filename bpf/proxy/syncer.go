@@ -94,6 +94,13 @@ func isSvcKeyDerived(skey svcKey) bool {
 	return hasSvcKeyExtra(skey, svcTypeExternalIP) || hasSvcKeyExtra(skey, svcTypeNodePort) || hasSvcKeyExtra(skey, svcTypeLoadBalancer)
 }
 
+// Backend selection algorithms for new flows to a service.
+const (
+	BackendSelectionRandom     = "Random"
+	BackendSelectionRoundRobin = "RoundRobin"
+	BackendSelectionMaglev     = "Maglev"
+)
+
 type stickyFrontend struct {
 	id    uint32
 	timeo time.Duration
@@ -106,6 +113,14 @@ type Syncer struct {
 	bpfEps  *cachingmap.CachingMap
 	bpfAff  bpf.Map
 
+	// bpfSel is nil unless the services use a backend selection other than random.
+	bpfSel           *cachingmap.CachingMap
+	backendSelection string
+	// maglevTables holds the Maglev tables that we computed in this Apply(), keyed by the
+	// backends they were computed for, prevMaglevTables those from the previous Apply().
+	maglevTables     map[string][]uint32
+	prevMaglevTables map[string][]uint32
+
 	nextSvcID uint32
 
 	nodePortIPs []net.IP
@@ -209,6 +224,25 @@ func NewSyncer(nodePortIPs []net.IP, svcsmap, epsmap *cachingmap.CachingMap, aff
 	return s, nil
 }
 
+// SetBackendSelection makes the syncer program the backend selection map for the given algorithm.
+// It must be called before the first Apply().
+func (s *Syncer) SetBackendSelection(alg string, selmap *cachingmap.CachingMap) error {
+	switch alg {
+	case BackendSelectionRandom, BackendSelectionRoundRobin, BackendSelectionMaglev:
+	default:
+		return errors.Errorf("unknown backend selection %q", alg)
+	}
+
+	// Even with random selection, we clean up any entries that a previous run left behind.
+	if err := selmap.LoadCacheFromDataplane(); err != nil {
+		return err
+	}
+
+	s.bpfSel = selmap
+	s.backendSelection = alg
+	return nil
+}
+
 func (s *Syncer) loadOrigs() error {
 	err := s.bpfEps.LoadCacheFromDataplane()
 	if err != nil {
@@ -403,6 +437,8 @@ func (s *Syncer) applyExpandedNP(sname k8sp.ServicePortName, sinfo k8sp.ServiceP
 	si := serviceInfoFromK8sServicePort(sinfo)
 	si.clusterIP = node.AsNetIP()
 	si.port = nport
+	// The endpoints are all on the remote node; the internal policy doesn't apply to a node port.
+	si.nodeLocalInternal = false
 
 	if err := s.applySvc(skey, si, eps); err != nil {
 		return errors.Errorf("apply NodePortRemote for %s node %s", sname, node)
@@ -486,8 +522,8 @@ func (s *Syncer) applyDerived(
 
 	skey = getSvcKey(sname, getSvcKeyExtra(t, sinfo.ClusterIP().String()))
 	switch t {
-	case svcTypeLoadBalancer:
-		// Handle LB services the same as NodePort type.
+	case svcTypeLoadBalancer, svcTypeExternalIP:
+		// Handle LB services and external IPs the same as NodePort type.
 		fallthrough
 	case svcTypeNodePort:
 		if sinfo.NodeLocalExternal() {
@@ -537,6 +573,11 @@ func (s *Syncer) apply(state DPSyncerState) error {
 	// let CachingMap calculate deltas...
 	s.bpfSvcs.DeleteAllDesired()
 	s.bpfEps.DeleteAllDesired()
+	if s.bpfSel != nil {
+		s.bpfSel.DeleteAllDesired()
+	}
+	s.prevMaglevTables = s.maglevTables
+	s.maglevTables = make(map[string][]uint32)
 
 	// insert or update existing services
 	for sname, sinfo := range state.SvcMap {
@@ -616,6 +657,13 @@ func (s *Syncer) apply(state DPSyncerState) error {
 	if err != nil {
 		return err
 	}
+	// Until the selection entries are updated, new flows may go to a backend that isn't the
+	// preferred one, but always to a valid one, so there's no point in failing the whole apply.
+	if s.bpfSel != nil {
+		if err := s.bpfSel.ApplyAllChanges(); err != nil {
+			log.WithError(err).Warn("Failed to update NAT backend selection map.")
+		}
+	}
 
 	log.Info("new state written")
 
@@ -626,6 +674,8 @@ func (s *Syncer) apply(state DPSyncerState) error {
 
 // Apply applies the new state
 func (s *Syncer) Apply(state DPSyncerState) error {
+	state = s.withHostPorts(state)
+
 	if !s.synced {
 		log.Infof("Loading BPF map state from dataplane")
 		if err := s.startupSync(state); err != nil {
@@ -707,7 +757,14 @@ func (s *Syncer) updateService(sname k8sp.ServicePortName, sinfo k8sp.ServicePor
 		cnt++
 	}
 
-	if err := s.writeSvc(sinfo, id, cnt, local); err != nil {
+	// With internalTrafficPolicy Local, the cluster IP only leads to the local endpoints.  The
+	// derived frontends still get all the endpoints and apply the external policy.
+	feCount := cnt
+	if sinfo.NodeLocalInternal() {
+		feCount = local
+	}
+	s.writeSvcSelection(id, cpEps)
+	if err := s.writeSvc(sinfo, id, feCount, local); err != nil {
 		return 0, 0, err
 	}
 
@@ -716,6 +773,44 @@ func (s *Syncer) updateService(sname k8sp.ServicePortName, sinfo k8sp.ServicePor
 	return cnt, local, nil
 }
 
+// writeSvcSelection programs the backend selection entries for a service, eps are the service's
+// backends in the order of their ordinals.
+func (s *Syncer) writeSvcSelection(svcID uint32, eps []k8sp.Endpoint) {
+	if s.bpfSel == nil || len(eps) == 0 {
+		return
+	}
+
+	switch s.backendSelection {
+	case BackendSelectionRoundRobin:
+		key := nat.NewSelectionKey(svcID, nat.RoundRobinSlot)
+		val := nat.NewSelectionValue(0)
+		if cur := s.bpfSel.GetDataplaneCache(key[:]); cur != nil {
+			// Keep the counter going rather than restarting from the first backend.
+			copy(val[:], cur)
+		}
+		s.bpfSel.SetDesired(key[:], val[:])
+	case BackendSelectionMaglev:
+		names := make([]string, len(eps))
+		for i, ep := range eps {
+			names[i] = ep.String()
+		}
+		tableKey := strings.Join(names, ",")
+		table, ok := s.maglevTables[tableKey]
+		if !ok {
+			table, ok = s.prevMaglevTables[tableKey]
+			if !ok {
+				table = maglevTable(names, nat.MaglevTableSize)
+			}
+			s.maglevTables[tableKey] = table
+		}
+		for slot, ordinal := range table {
+			key := nat.NewSelectionKey(svcID, uint32(slot))
+			val := nat.NewSelectionValue(ordinal)
+			s.bpfSel.SetDesired(key[:], val[:])
+		}
+	}
+}
+
 func (s *Syncer) writeSvcBackend(svcID uint32, idx uint32, ep k8sp.Endpoint) error {
 	if log.GetLevel() >= log.DebugLevel {
 		log.WithFields(log.Fields{
@@ -1398,6 +1493,15 @@ func K8sSvcWithLocalOnly() K8sServicePortOption {
 	}
 }
 
+// K8sSvcWithInternalLocalOnly sets internalTrafficPolicy to Local
+func K8sSvcWithInternalLocalOnly() K8sServicePortOption {
+	return func(s interface{}) {
+		local := v1.ServiceInternalTrafficPolicyLocal
+		s.(*serviceInfo).internalTrafficPolicy = &local
+		s.(*serviceInfo).nodeLocalInternal = true
+	}
+}
+
 // K8sSvcWithStickyClientIP sets ServiceAffinityClientIP to seconds
 func K8sSvcWithStickyClientIP(seconds int) K8sServicePortOption {
 	return func(s interface{}) {
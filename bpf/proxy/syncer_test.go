@@ -782,6 +782,58 @@ var _ = Describe("BPF Syncer", func() {
 				NotTo(Equal(eps.m[nat.NewNATBackendKey(val1.ID(), 3)]))
 		}))
 
+		By("inserting a service with internal traffic policy Local", makestep(func() {
+			state.SvcMap[svcKey2] = proxy.NewK8sServicePort(
+				net.IPv4(10, 0, 0, 2),
+				2222,
+				v1.ProtocolTCP,
+				proxy.K8sSvcWithExternalIPs([]string{"35.0.0.2"}),
+				proxy.K8sSvcWithInternalLocalOnly(),
+			)
+
+			err := s.Apply(state)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(svcs.m).To(HaveLen(2))
+
+			val1, ok := svcs.m[nat.NewNATKey(net.IPv4(10, 0, 0, 2), 2222, proxy.ProtoV1ToIntPanic(v1.ProtocolTCP))]
+			Expect(ok).To(BeTrue())
+			Expect(val1.Count()).To(Equal(uint32(2)))
+			Expect(val1.LocalCount()).To(Equal(uint32(2)))
+
+			val2, ok := svcs.m[nat.NewNATKey(net.IPv4(35, 0, 0, 2), 2222, proxy.ProtoV1ToIntPanic(v1.ProtocolTCP))]
+			Expect(ok).To(BeTrue())
+			Expect(val2.ID()).To(Equal(val1.ID()))
+			Expect(val2.Count()).To(Equal(uint32(4)))
+
+			Expect(eps.m).To(HaveLen(4))
+		}))
+
+		By("adding external traffic policy Local to the service", makestep(func() {
+			state.SvcMap[svcKey2] = proxy.NewK8sServicePort(
+				net.IPv4(10, 0, 0, 2),
+				2222,
+				v1.ProtocolTCP,
+				proxy.K8sSvcWithExternalIPs([]string{"35.0.0.2"}),
+				proxy.K8sSvcWithInternalLocalOnly(),
+				proxy.K8sSvcWithLocalOnly(),
+			)
+
+			err := s.Apply(state)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(svcs.m).To(HaveLen(2))
+
+			val1, ok := svcs.m[nat.NewNATKey(net.IPv4(10, 0, 0, 2), 2222, proxy.ProtoV1ToIntPanic(v1.ProtocolTCP))]
+			Expect(ok).To(BeTrue())
+			Expect(val1.Count()).To(Equal(uint32(2)))
+
+			val2, ok := svcs.m[nat.NewNATKey(net.IPv4(35, 0, 0, 2), 2222, proxy.ProtoV1ToIntPanic(v1.ProtocolTCP))]
+			Expect(ok).To(BeTrue())
+			Expect(val2.Count()).To(Equal(uint32(2)))
+			Expect(val2.LocalCount()).To(Equal(uint32(2)))
+		}))
+
 		By("inserting service with affinity v1.ServiceAffinityClientIP", makestep(func() {
 			state.SvcMap[svcKey2] = proxy.NewK8sServicePort(
 				net.IPv4(10, 0, 0, 2),
@@ -935,6 +987,127 @@ var _ = Describe("BPF Syncer", func() {
 	})
 })
 
+var _ = Describe("BPF Syncer backend selection", func() {
+	var (
+		svcs *mockNATMap
+		eps  *mockNATBackendMap
+		sel  *mock.Map
+		s    *proxy.Syncer
+	)
+
+	svcKey := k8sp.ServicePortName{
+		NamespacedName: types.NamespacedName{
+			Namespace: "default",
+			Name:      "test-service",
+		},
+	}
+
+	makeState := func() proxy.DPSyncerState {
+		return proxy.DPSyncerState{
+			SvcMap: k8sp.ServiceMap{
+				svcKey: proxy.NewK8sServicePort(net.IPv4(10, 0, 0, 1), 1234, v1.ProtocolTCP),
+			},
+			EpsMap: k8sp.EndpointsMap{
+				svcKey: []k8sp.Endpoint{
+					&k8sp.BaseEndpointInfo{Endpoint: "10.1.0.1:5555"},
+					&k8sp.BaseEndpointInfo{Endpoint: "10.1.0.2:5555"},
+				},
+			},
+		}
+	}
+
+	svcID := func() uint32 {
+		val, ok := svcs.m[nat.NewNATKey(net.IPv4(10, 0, 0, 1), 1234, proxy.ProtoV1ToIntPanic(v1.ProtocolTCP))]
+		Expect(ok).To(BeTrue())
+		return val.ID()
+	}
+
+	setup := func(alg string) {
+		feCache := cachingmap.New(nat.FrontendMapParameters, svcs)
+		beCache := cachingmap.New(nat.BackendMapParameters, eps)
+
+		var err error
+		s, err = proxy.NewSyncer([]net.IP{net.IPv4(192, 168, 0, 1)}, feCache, beCache,
+			newMockAffinityMap(), proxy.NewRTCache())
+		Expect(err).NotTo(HaveOccurred())
+		err = s.SetBackendSelection(alg, cachingmap.New(nat.SelectionMapParameters, sel))
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		svcs = newMockNATMap()
+		eps = newMockNATBackendMap()
+		sel = mock.NewMockMap(nat.SelectionMapParameters)
+	})
+
+	It("should reject an unknown algorithm", func() {
+		syncer, err := proxy.NewSyncer(nil, cachingmap.New(nat.FrontendMapParameters, svcs),
+			cachingmap.New(nat.BackendMapParameters, eps), newMockAffinityMap(), proxy.NewRTCache())
+		Expect(err).NotTo(HaveOccurred())
+		err = syncer.SetBackendSelection("Hash", cachingmap.New(nat.SelectionMapParameters, sel))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should clean up stale entries with random selection", func() {
+		k := nat.NewSelectionKey(1, nat.RoundRobinSlot)
+		v := nat.NewSelectionValue(0)
+		sel.Contents[string(k[:])] = string(v[:])
+
+		setup(proxy.BackendSelectionRandom)
+		Expect(s.Apply(makeState())).NotTo(HaveOccurred())
+		Expect(sel.Contents).To(BeEmpty())
+	})
+
+	It("should program and keep a round-robin counter", func() {
+		setup(proxy.BackendSelectionRoundRobin)
+		Expect(s.Apply(makeState())).NotTo(HaveOccurred())
+
+		k := nat.NewSelectionKey(svcID(), nat.RoundRobinSlot)
+		v := nat.NewSelectionValue(0)
+		Expect(sel.Contents).To(Equal(map[string]string{string(k[:]): string(v[:])}))
+
+		By("restarting the syncer after the counter moved on")
+		v = nat.NewSelectionValue(7)
+		sel.Contents[string(k[:])] = string(v[:])
+		setup(proxy.BackendSelectionRoundRobin)
+		Expect(s.Apply(makeState())).NotTo(HaveOccurred())
+		Expect(sel.Contents).To(Equal(map[string]string{string(k[:]): string(v[:])}))
+
+		By("removing the service")
+		Expect(s.Apply(proxy.DPSyncerState{
+			SvcMap: k8sp.ServiceMap{},
+			EpsMap: k8sp.EndpointsMap{},
+		})).NotTo(HaveOccurred())
+		Expect(sel.Contents).To(BeEmpty())
+	})
+
+	It("should program a Maglev table", func() {
+		setup(proxy.BackendSelectionMaglev)
+		Expect(s.Apply(makeState())).NotTo(HaveOccurred())
+
+		id := svcID()
+		Expect(sel.Contents).To(HaveLen(nat.MaglevTableSize))
+		counts := map[uint32]int{}
+		for slot := uint32(0); slot < nat.MaglevTableSize; slot++ {
+			k := nat.NewSelectionKey(id, slot)
+			vstr, ok := sel.Contents[string(k[:])]
+			Expect(ok).To(BeTrue())
+			var v nat.SelectionValue
+			copy(v[:], vstr)
+			counts[v.Ordinal()]++
+		}
+		Expect(counts).To(HaveLen(2))
+		Expect(counts[0]).To(BeNumerically("~", nat.MaglevTableSize/2, 1))
+
+		By("removing the service")
+		Expect(s.Apply(proxy.DPSyncerState{
+			SvcMap: k8sp.ServiceMap{},
+			EpsMap: k8sp.EndpointsMap{},
+		})).NotTo(HaveOccurred())
+		Expect(sel.Contents).To(BeEmpty())
+	})
+})
+
 type mockNATMap struct {
 	mock.DummyMap
 	sync.Mutex
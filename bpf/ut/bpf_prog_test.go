@@ -294,8 +294,8 @@ func bpftool(args ...string) ([]byte, error) {
 var (
 	mapInitOnce sync.Once
 
-	natMap, natBEMap, ctMap, rtMap, ipsMap, stateMap, testStateMap, jumpMap, affinityMap, arpMap, fsafeMap bpf.Map
-	allMaps, progMaps                                                                                      []bpf.Map
+	natMap, natBEMap, natSelMap, ctMap, rtMap, ipsMap, stateMap, testStateMap, jumpMap, affinityMap, arpMap, fsafeMap bpf.Map
+	allMaps, progMaps                                                                                                 []bpf.Map
 )
 
 func initMapsOnce() {
@@ -304,6 +304,7 @@ func initMapsOnce() {
 
 		natMap = nat.FrontendMap(mc)
 		natBEMap = nat.BackendMap(mc)
+		natSelMap = nat.SelectionMap(mc)
 		ctMap = conntrack.Map(mc)
 		rtMap = routes.Map(mc)
 		ipsMap = ipsets.Map(mc)
@@ -314,7 +315,7 @@ func initMapsOnce() {
 		arpMap = arp.Map(mc)
 		fsafeMap = failsafes.Map(mc)
 
-		allMaps = []bpf.Map{natMap, natBEMap, ctMap, rtMap, ipsMap, stateMap, testStateMap, jumpMap, affinityMap, arpMap, fsafeMap}
+		allMaps = []bpf.Map{natMap, natBEMap, natSelMap, ctMap, rtMap, ipsMap, stateMap, testStateMap, jumpMap, affinityMap, arpMap, fsafeMap}
 		for _, m := range allMaps {
 			err := m.EnsureExists()
 			if err != nil {
@@ -325,6 +326,7 @@ func initMapsOnce() {
 		progMaps = []bpf.Map{
 			natMap,
 			natBEMap,
+			natSelMap,
 			ctMap,
 			rtMap,
 			jumpMap,
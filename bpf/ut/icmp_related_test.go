@@ -20,6 +20,7 @@ import (
 
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
+	"github.com/google/netstack/tcpip/header"
 	. "github.com/onsi/gomega"
 
 	"github.com/projectcalico/felix/bpf/nat"
@@ -299,6 +300,16 @@ func checkICMP(bytes []byte, outSrc, outDst, innerSrc, innerDst net.IP,
 	Expect(ipv4R.SrcIP.String()).To(Equal(outSrc.String()))
 	Expect(ipv4R.DstIP.String()).To(Equal(outDst.String()))
 
+	// The ICMP checksum covers the original packet so it must still be valid after we NATted it,
+	// otherwise the receiver drops the error and, for instance, PMTU discovery breaks.
+	icmpL := icmpPkt.Layer(layers.LayerTypeICMPv4)
+	Expect(icmpL).NotTo(BeNil())
+	icmpR := icmpL.(*layers.ICMPv4)
+	toCSum := make([]byte, len(icmpR.Contents)+len(icmpR.Payload))
+	copy(toCSum, icmpR.Contents)
+	copy(toCSum[len(icmpR.Contents):], icmpR.Payload)
+	Expect(header.Checksum(toCSum, 0)).To(Equal(uint16(0xffff)))
+
 	payloadL := icmpPkt.ApplicationLayer()
 	Expect(payloadL).NotTo(BeNil())
 	origPkt := gopacket.NewPacket(payloadL.Payload(), layers.LayerTypeIPv4, gopacket.Default)
@@ -1242,6 +1242,24 @@ var hostPolProgramTests = []polProgramTest{
 			udpPkt("123.0.0.1:1024", "10.96.5.10:53").fromHost(),
 		},
 	},
+	{
+		PolicyName: "IGMP allowed before policy",
+		Policy: polprog.Rules{
+			AllowIGMP: true,
+			Tiers: []polprog.Tier{{
+				Name:     "default",
+				Policies: allowDestElseDeny("p1", "10.96.0.10/32"),
+			}},
+		},
+		AllowedPackets: []packet{
+			packetNoPorts(2, "10.0.0.1", "224.0.0.22"),
+			udpPkt("123.0.0.1:1024", "10.96.0.10:53"),
+		},
+		DroppedPackets: []packet{
+			udpPkt("123.0.0.1:1024", "224.0.0.22:53"),
+			packetNoPorts(4, "10.0.0.1", "224.0.0.22"),
+		},
+	},
 	{
 		PolicyName: "pre-DNAT policy + normal profiles",
 		Policy: polprog.Rules{
@@ -15,6 +15,7 @@
 package calc
 
 import (
+	"context"
 	"reflect"
 	"time"
 
@@ -27,6 +28,7 @@ import (
 
 	"github.com/projectcalico/felix/config"
 	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/tracing"
 )
 
 const (
@@ -84,6 +86,9 @@ type AsyncCalcGraph struct {
 	healthTicks      <-chan time.Time
 	flushLeakyBucket int
 	dirty            bool
+	// traceCtx is the tracing context of the most recently processed batch of updates.  The
+	// next flush is traced as part of that batch's trace.
+	traceCtx context.Context
 
 	debugHangC <-chan time.Time
 }
@@ -119,9 +124,18 @@ func NewAsyncCalcGraph(
 	return g
 }
 
+// tracedUpdates is a batch of datastore updates, along with the tracing context that was
+// started when the batch was received.
+type tracedUpdates struct {
+	ctx     context.Context
+	updates []api.Update
+}
+
 func (acg *AsyncCalcGraph) OnUpdates(updates []api.Update) {
 	log.Debugf("Got %v updates; queueing", len(updates))
-	acg.inputEvents <- updates
+	ctx, span := tracing.StartSpan(context.Background(), "calc.ReceiveUpdates")
+	span.SetAttribute("numUpdates", len(updates))
+	acg.inputEvents <- tracedUpdates{ctx: ctx, updates: updates}
 }
 
 func (acg *AsyncCalcGraph) OnStatusUpdated(status api.SyncStatus) {
@@ -140,15 +154,16 @@ func (acg *AsyncCalcGraph) loop() {
 		select {
 		case update := <-acg.inputEvents:
 			switch update := update.(type) {
-			case []api.Update:
+			case tracedUpdates:
 				// Update; send it to the dispatcher.
 				log.Debug("Pulled []KVPair off channel")
-				for i, upd := range update {
+				_, span := tracing.StartSpan(update.ctx, "calc.ProcessUpdates")
+				for i, upd := range update.updates {
 					// Send the updates individually so that we can report live in between
 					// each update.  (The dispatcher sends individual updates anyway so this makes
 					// no difference.)
 					updStartTime := time.Now()
-					acg.AllUpdDispatcher.OnUpdates(update[i : i+1])
+					acg.AllUpdDispatcher.OnUpdates(update.updates[i : i+1])
 					summaryUpdateTime.Observe(time.Since(updStartTime).Seconds())
 					// Record stats for the number of messages processed.
 					typeName := reflect.TypeOf(upd.Key).Name()
@@ -156,6 +171,9 @@ func (acg *AsyncCalcGraph) loop() {
 					count.Inc()
 					acg.reportHealth()
 				}
+				span.End()
+				tracing.SpanFromContext(update.ctx).End()
+				acg.traceCtx = update.ctx
 			case api.SyncStatus:
 				// Sync status changed, check if we're now in-sync.
 				log.WithField("status", update).Debug(
@@ -210,9 +228,20 @@ func (acg *AsyncCalcGraph) maybeFlush() {
 	if acg.flushLeakyBucket > 0 {
 		log.Debug("Not throttled: flushing event buffer")
 		acg.flushLeakyBucket--
+		traceCtx := acg.traceCtx
+		if traceCtx == nil {
+			traceCtx = context.Background()
+		}
+		acg.traceCtx = nil
+		ctx, span := tracing.StartSpan(traceCtx, "calc.Flush")
+		if span != nil {
+			// Let the dataplane record its handling of the flushed messages in the same trace.
+			acg.onEvent(&tracing.BatchStart{Ctx: ctx})
+		}
 		flushStart := time.Now()
 		acg.eventSequencer.Flush()
 		flushDuration := time.Since(flushStart)
+		span.End()
 		if flushDuration > time.Second {
 			log.WithField("time", flushDuration).Info("Flush took over 1s.")
 		}
@@ -23,9 +23,11 @@ import (
 	"github.com/projectcalico/felix/dispatcher"
 	"github.com/projectcalico/felix/labelindex"
 	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
 	"github.com/projectcalico/libcalico-go/lib/backend/api"
 	"github.com/projectcalico/libcalico-go/lib/backend/model"
 	"github.com/projectcalico/libcalico-go/lib/net"
+	"github.com/projectcalico/libcalico-go/lib/selector"
 )
 
 var (
@@ -128,6 +130,11 @@ func NewCalculationGraph(callbacks PipelineCallbacks, conf *config.Config) *Calc
 	//
 	allUpdDispatcher := dispatcher.NewDispatcher()
 
+	// Deduplicate the strings in endpoints and the selectors in policies and profiles before any
+	// of the receivers store them.  These must be the first receivers.
+	NewEndpointInterner().RegisterWith(allUpdDispatcher)
+	NewSelectorInterner().RegisterWith(allUpdDispatcher)
+
 	// Some of the receivers only need to know about local endpoints. Create a second dispatcher
 	// that will filter out non-local endpoints.
 	//
@@ -225,6 +232,10 @@ func NewCalculationGraph(callbacks PipelineCallbacks, conf *config.Config) *Calc
 	ipsetMemberIndex := labelindex.NewSelectorAndNamedPortIndex()
 	// Wire up the inputs to the IP set member index.
 	ipsetMemberIndex.RegisterWith(allUpdDispatcher)
+	if conf.NodeSelectorIPSetsEnabled {
+		// Let selectors match nodes as well as endpoints.
+		NewNodeEndpointIndexer(ipsetMemberIndex).RegisterWith(allUpdDispatcher)
+	}
 	ruleScanner.OnIPSetActive = func(ipSet *IPSetData) {
 		log.WithField("ipSet", ipSet).Info("IPSet now active")
 		callbacks.OnIPSetAdded(ipSet.UniqueID(), ipSet.DataplaneProtocolType())
@@ -257,6 +268,45 @@ func NewCalculationGraph(callbacks PipelineCallbacks, conf *config.Config) *Calc
 		callbacks.OnIPSetMemberRemoved(ipSetID, member)
 	}
 
+	// Some features select endpoints with selectors that come from config rather than from a
+	// policy, so they aren't tracked by the rule scanner; we add their IP sets directly and they
+	// stay active for the lifetime of the graph.  Each gets its own ID so that it's independent of
+	// any policy IP set with the same selector.
+	addConfigIPSet := func(ipSetID, selStr, paramName string) {
+		sel, err := selector.Parse(selStr)
+		if err != nil {
+			// Shouldn't happen, the config parser validates the selector.
+			log.WithError(err).Panicf("Failed to parse %s selector", paramName)
+		}
+		log.WithFields(log.Fields{
+			"ipSetID":  ipSetID,
+			"selector": sel.String(),
+		}).Infof("Adding IP set for %s", paramName)
+		callbacks.OnIPSetAdded(ipSetID, proto.IPSetUpdate_NET)
+		ipsetMemberIndex.UpdateIPSet(ipSetID, sel, labelindex.ProtocolNone, "")
+	}
+	if !conf.BPFEnabled {
+		if conf.NATOutgoingExclusionSelector != "" {
+			addConfigIPSet(rules.IPSetIDNATOutgoingExclusions, conf.NATOutgoingExclusionSelector,
+				"NATOutgoingExclusionSelector")
+		}
+		for i, pool := range conf.NATOutgoingSourcePools {
+			addConfigIPSet(rules.NATOutgoingSourcePoolIPSetID(i), pool.Selector, "NATOutgoingSourcePools")
+		}
+		if conf.IpInIpEnabled {
+			for i, egwRule := range conf.EgressGatewaySteering {
+				addConfigIPSet(rules.EgressGatewayClientIPSetID(i), egwRule.ClientSelector, "EgressGatewaySteering")
+				addConfigIPSet(rules.EgressGatewayIPSetID(i), egwRule.GatewaySelector, "EgressGatewaySteering")
+			}
+		}
+		for i, proxyRule := range conf.WorkloadProxyNeighbors {
+			addConfigIPSet(rules.ProxyNeighborIPSetID(i), proxyRule.Selector, "WorkloadProxyNeighbors")
+		}
+		for i, helperRule := range conf.ConntrackHelpers {
+			addConfigIPSet(rules.ConntrackHelperIPSetID(i), helperRule.Selector, "ConntrackHelpers")
+		}
+	}
+
 	// The endpoint policy resolver marries up the active policies with local endpoints and
 	// calculates the complete, ordered set of policies that apply to each endpoint.
 	//
@@ -314,7 +364,8 @@ func NewCalculationGraph(callbacks PipelineCallbacks, conf *config.Config) *Calc
 		//         |
 		//      <dataplane>
 		//
-		l3RR := NewL3RouteResolver(hostname, callbacks, conf.UseNodeResourceUpdates(), conf.RouteSource)
+		l3RR := NewL3RouteResolver(hostname, callbacks, conf.UseNodeResourceUpdates(), conf.RouteSource,
+			conf.RouteBorrowedIPsFromWorkloads)
 		l3RR.RegisterWith(allUpdDispatcher, localEndpointDispatcher)
 	}
 
@@ -331,7 +382,7 @@ func NewCalculationGraph(callbacks PipelineCallbacks, conf *config.Config) *Calc
 	//      <dataplane>
 	//
 	if conf.VXLANEnabled {
-		vxlanResolver := NewVXLANResolver(hostname, callbacks, conf.UseNodeResourceUpdates())
+		vxlanResolver := NewVXLANResolver(hostname, callbacks, conf.UseNodeResourceUpdates(), conf.VXLANFabricPlanes)
 		vxlanResolver.RegisterWith(allUpdDispatcher)
 	}
 
@@ -368,6 +419,13 @@ func NewCalculationGraph(callbacks PipelineCallbacks, conf *config.Config) *Calc
 	profileDecoder := NewProfileDecoder(callbacks)
 	profileDecoder.RegisterWith(allUpdDispatcher)
 
+	// The implicit host endpoint for AutoHostEndpointInterfaces isn't in the datastore so we
+	// feed it in as if it were.  That way, policy applies to it like any other host endpoint; the
+	// dataplane decides which interfaces it applies to.
+	if len(conf.AutoHostEndpointInterfaces) > 0 {
+		allUpdDispatcher.OnUpdate(autoHostEndpointUpdate(conf))
+	}
+
 	return &CalcGraph{
 		AllUpdDispatcher:      allUpdDispatcher,
 		activeRulesCalculator: activeRulesCalc,
@@ -415,3 +473,27 @@ func (f *endpointHostnameFilter) OnUpdate(update api.Update) (filterOut bool) {
 	}
 	return
 }
+
+// autoHostEndpointUpdate returns the update that creates the implicit host endpoint.
+func autoHostEndpointUpdate(conf *config.Config) api.Update {
+	hostEp := &model.HostEndpoint{
+		Labels: map[string]string{config.AutoHostEndpointLabel: "true"},
+	}
+	if conf.AutoHostEndpointProfile != "" {
+		hostEp.ProfileIDs = []string{conf.AutoHostEndpointProfile}
+	}
+	log.WithFields(log.Fields{
+		"interfaces": conf.AutoHostEndpointInterfaces,
+		"profile":    conf.AutoHostEndpointProfile,
+	}).Info("Adding implicit host endpoint")
+	return api.Update{
+		KVPair: model.KVPair{
+			Key: model.HostEndpointKey{
+				Hostname:   conf.FelixHostname,
+				EndpointID: config.AutoHostEndpointID,
+			},
+			Value: hostEp,
+		},
+		UpdateType: api.UpdateTypeKVNew,
+	}
+}
@@ -340,11 +340,14 @@ var baseTests = []StateList{
 	{
 		// Test L3 route resolver in node resource mode using WorkloadIPs as the route source.
 		// This test starts with a single remote workload, then moves to two remote workloads with the same
-		// IP address on different nodes, and then back to a single workload.
+		// IP address on different nodes, and then back to a single workload, which then gains and
+		// loses a floating IP.
 		vxlanWithWEPIPs,
 		vxlanWithWEPIPsAndWEP,
 		vxlanWithWEPIPsAndWEPDuplicate,
 		vxlanWithWEPIPsAndWEP,
+		vxlanWithWEPIPsAndFloatingIP,
+		vxlanWithWEPIPsAndWEP,
 	},
 	{
 		// Test corner case where the IP pool and block share a /32.
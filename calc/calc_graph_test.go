@@ -19,6 +19,7 @@ import (
 	"github.com/projectcalico/felix/config"
 
 	"reflect"
+	"regexp"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
@@ -109,6 +110,22 @@ var _ = DescribeTable("Calculation graph pass-through tests",
 		proto.IPAMPoolRemove{
 			Id: "10.0.0.0-16",
 		}),
+	Entry("IPPool disabled",
+		model.IPPoolKey{CIDR: mustParseNet("10.0.0.0/16")},
+		&model.IPPool{
+			CIDR:     mustParseNet("10.0.0.0/16"),
+			Disabled: true,
+		},
+		proto.IPAMPoolUpdate{
+			Id: "10.0.0.0-16",
+			Pool: &proto.IPAMPool{
+				Cidr:     "10.0.0.0/16",
+				Disabled: true,
+			},
+		},
+		proto.IPAMPoolRemove{
+			Id: "10.0.0.0-16",
+		}),
 	Entry("HostIP",
 		model.HostIPKey{Hostname: "foo"},
 		&testIP,
@@ -296,3 +313,26 @@ var _ = Describe("specific scenario tests", func() {
 		Expect(mockDataplane.NumEventsRecorded()).To(Equal(numEventsBeforeSendingDupe))
 	})
 })
+
+var _ = Describe("Implicit host endpoint", func() {
+	It("should send the implicit host endpoint once in sync", func() {
+		eb := NewEventSequencer(nil)
+		var hostEpUpdates []*proto.HostEndpointUpdate
+		eb.Callback = func(message interface{}) {
+			if upd, ok := message.(*proto.HostEndpointUpdate); ok {
+				hostEpUpdates = append(hostEpUpdates, upd)
+			}
+		}
+		conf := config.New()
+		conf.FelixHostname = "hostname"
+		conf.AutoHostEndpointInterfaces = []*regexp.Regexp{regexp.MustCompile("^eth0$")}
+		conf.AutoHostEndpointProfile = "allow-all"
+		cg := NewCalculationGraph(eb, conf).AllUpdDispatcher
+		cg.OnStatusUpdated(api.InSync)
+		eb.Flush()
+
+		Expect(hostEpUpdates).To(HaveLen(1))
+		Expect(*hostEpUpdates[0].Id).To(Equal(proto.HostEndpointID{EndpointId: "felix-auto-host-endpoint"}))
+		Expect(hostEpUpdates[0].Endpoint.ProfileIds).To(Equal([]string{"allow-all"}))
+	})
+})
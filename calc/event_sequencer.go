@@ -356,6 +356,7 @@ func ModelWorkloadEndpointToProto(ep *model.WorkloadEndpoint, tiers []*proto.Tie
 	if ep.Mac != nil {
 		mac = ep.Mac.String()
 	}
+	ipv4NAT, ipv6NAT := workloadNATsToProto(ep)
 	return &proto.WorkloadEndpoint{
 		State:      ep.State,
 		Name:       ep.Name,
@@ -364,8 +365,9 @@ func ModelWorkloadEndpointToProto(ep *model.WorkloadEndpoint, tiers []*proto.Tie
 		Ipv4Nets:   netsToStrings(ep.IPv4Nets),
 		Ipv6Nets:   netsToStrings(ep.IPv6Nets),
 		Tiers:      tiers,
-		Ipv4Nat:    natsToProtoNatInfo(ep.IPv4NAT),
-		Ipv6Nat:    natsToProtoNatInfo(ep.IPv6NAT),
+		Ipv4Nat:    ipv4NAT,
+		Ipv6Nat:    ipv6NAT,
+		Labels:     ep.Labels,
 	}
 }
 
@@ -408,13 +410,17 @@ func (buf *EventSequencer) flushEndpointTierUpdates() {
 		switch key := key.(type) {
 		case model.WorkloadEndpointKey:
 			wlep := endpoint.(*model.WorkloadEndpoint)
+			protoEp := ModelWorkloadEndpointToProto(wlep, tiers)
+			// Include the implicit endpoint ID label so that the dataplane's selectors, such as
+			// those of WorkloadExtraRoutes, can pick out one of a pod's interfaces.
+			protoEp.Labels = labelindex.WorkloadEndpointLabels(key, wlep)
 			buf.Callback(&proto.WorkloadEndpointUpdate{
 				Id: &proto.WorkloadEndpointID{
 					OrchestratorId: key.OrchestratorID,
 					WorkloadId:     key.WorkloadID,
 					EndpointId:     key.EndpointID,
 				},
-				Endpoint: ModelWorkloadEndpointToProto(wlep, tiers),
+				Endpoint: protoEp,
 			})
 		case model.HostEndpointKey:
 			hep := endpoint.(*model.HostEndpoint)
@@ -510,6 +516,7 @@ func (buf *EventSequencer) flushIPPoolUpdates() {
 			Pool: &proto.IPAMPool{
 				Cidr:       pool.CIDR.String(),
 				Masquerade: pool.Masquerade,
+				Disabled:   pool.Disabled,
 			},
 		})
 		buf.sentIPPools.Add(key)
@@ -947,13 +954,42 @@ func ipsToStrings(ips []net.IP) []string {
 	return output
 }
 
-func natsToProtoNatInfo(nats []model.IPNAT) []*proto.NatInfo {
-	protoNats := make([]*proto.NatInfo, len(nats))
-	for ii, nat := range nats {
-		protoNats[ii] = &proto.NatInfo{
-			ExtIp: nat.ExtIP.String(),
-			IntIp: nat.IntIP.String(),
+// workloadNATsToProto converts the workload's floating IPs to NatInfos, grouped by the IP version of
+// the floating (external) IP.  A floating IP that was paired with an internal IP of the other IP
+// version, as can happen for the floating IP annotation on a dual-stack pod, is re-paired with the
+// workload's first IP of the floating IP's version; if the workload has no such IP, the floating IP
+// is dropped.
+func workloadNATsToProto(ep *model.WorkloadEndpoint) (ipv4NAT, ipv6NAT []*proto.NatInfo) {
+	ipv4NAT = make([]*proto.NatInfo, 0, len(ep.IPv4NAT))
+	ipv6NAT = make([]*proto.NatInfo, 0, len(ep.IPv6NAT))
+	for _, nats := range [][]model.IPNAT{ep.IPv4NAT, ep.IPv6NAT} {
+		for _, nat := range nats {
+			extIsV4 := nat.ExtIP.To4() != nil
+			intIP := nat.IntIP.String()
+			if (nat.IntIP.To4() != nil) != extIsV4 {
+				nets := ep.IPv6Nets
+				if extIsV4 {
+					nets = ep.IPv4Nets
+				}
+				if len(nets) == 0 {
+					log.WithFields(log.Fields{
+						"workload": ep.Name,
+						"extIP":    nat.ExtIP.String(),
+					}).Warn("Workload has no IP of the same IP version as its floating IP, ignoring floating IP.")
+					continue
+				}
+				intIP = nets[0].IP.String()
+			}
+			natInfo := &proto.NatInfo{
+				ExtIp: nat.ExtIP.String(),
+				IntIp: intIP,
+			}
+			if extIsV4 {
+				ipv4NAT = append(ipv4NAT, natInfo)
+			} else {
+				ipv6NAT = append(ipv6NAT, natInfo)
+			}
 		}
 	}
-	return protoNats
+	return
 }
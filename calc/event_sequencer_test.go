@@ -64,6 +64,44 @@ var _ = DescribeTable("ModelWorkloadEndpointToProto",
 		},
 		Ipv6Nat: []*proto.NatInfo{},
 	}),
+	Entry("dual-stack workload endpoint with an IPv6 floating IP paired with its IPv4 address", model.WorkloadEndpoint{
+		State:      "up",
+		Name:       "bill",
+		Mac:        mustParseMac("01:02:03:04:05:06"),
+		ProfileIDs: []string{},
+		IPv4Nets:   []net.IPNet{mustParseNet("10.28.0.13/32")},
+		IPv6Nets:   []net.IPNet{mustParseNet("dead:beef::13/128")},
+		IPv4NAT: []model.IPNAT{
+			{
+				IntIP: mustParseIP("10.28.0.13"),
+				ExtIP: mustParseIP("172.16.1.3"),
+			},
+			{
+				IntIP: mustParseIP("10.28.0.13"),
+				ExtIP: mustParseIP("dead:beef::1:3"),
+			},
+		},
+	}, proto.WorkloadEndpoint{
+		State:      "up",
+		Name:       "bill",
+		Mac:        "01:02:03:04:05:06",
+		ProfileIds: []string{},
+		Ipv4Nets:   []string{"10.28.0.13/32"},
+		Ipv6Nets:   []string{"dead:beef::13/128"},
+		Tiers:      []*proto.TierInfo{},
+		Ipv4Nat: []*proto.NatInfo{
+			{
+				ExtIp: "172.16.1.3",
+				IntIp: "10.28.0.13",
+			},
+		},
+		Ipv6Nat: []*proto.NatInfo{
+			{
+				ExtIp: "dead:beef::1:3",
+				IntIp: "dead:beef::13",
+			},
+		},
+	}),
 )
 
 var _ = Describe("ParsedRulesToActivePolicyUpdate", func() {
@@ -16,6 +16,7 @@ package calc
 
 import (
 	"fmt"
+	"net"
 	"reflect"
 	"sort"
 
@@ -67,8 +68,26 @@ type L3RouteResolver struct {
 	workloadIDToCIDRs      map[model.WorkloadEndpointKey][]cnet.IPNet
 	useNodeResourceUpdates bool
 	routeSource            string
+
+	// When routing borrowed IPs from workloads, we track the IPs of all the remote workloads and
+	// add a workload ref for the ones that IPAM doesn't route to the workload's node.
+	routeBorrowedIPsFromWorkloads bool
+	remoteWorkloadIDToCIDRs       map[model.WorkloadEndpointKey][]ip.V4CIDR
+	// remoteWorkloadCIDRToOwners maps each remote workload IP to the workloads that have it,
+	// oldest first.  An IP briefly has two owners if we hear about its new workload before the
+	// old one is deleted; for example, when it moves between nodes.  The newest owner wins.
+	remoteWorkloadCIDRToOwners map[ip.V4CIDR][]model.WorkloadEndpointKey
+	// remoteWorkloadCIDRsBySlab indexes the remote workload IPs by their enclosing
+	// borrowedIPSlabPrefixLen CIDR so that a block update only rechecks the IPs that may be in
+	// the block.
+	remoteWorkloadCIDRsBySlab map[ip.V4CIDR]set.Set
+	borrowedIPToNode          map[ip.V4CIDR]string
 }
 
+// borrowedIPSlabPrefixLen is the prefix length of the largest IPv4 IPAM block, so every block is
+// inside a single slab of the remote workload IP index.
+const borrowedIPSlabPrefixLen = 20
+
 type l3rrNodeInfo struct {
 	Addr ip.V4Addr
 	CIDR ip.V4CIDR
@@ -139,7 +158,13 @@ func (i l3rrNodeInfo) AddresesV4AsCIDRs() []ip.V4CIDR {
 	return cidrs
 }
 
-func NewL3RouteResolver(hostname string, callbacks PipelineCallbacks, useNodeResourceUpdates bool, routeSource string) *L3RouteResolver {
+func NewL3RouteResolver(
+	hostname string,
+	callbacks PipelineCallbacks,
+	useNodeResourceUpdates bool,
+	routeSource string,
+	routeBorrowedIPsFromWorkloads bool,
+) *L3RouteResolver {
 	logrus.Info("Creating L3 route resolver")
 	return &L3RouteResolver{
 		myNodeName: hostname,
@@ -154,6 +179,12 @@ func NewL3RouteResolver(hostname string, callbacks PipelineCallbacks, useNodeRes
 		useNodeResourceUpdates: useNodeResourceUpdates,
 		routeSource:            routeSource,
 		nodeRoutes:             newNodeRoutes(),
+
+		routeBorrowedIPsFromWorkloads: routeBorrowedIPsFromWorkloads,
+		remoteWorkloadIDToCIDRs:       map[model.WorkloadEndpointKey][]ip.V4CIDR{},
+		remoteWorkloadCIDRToOwners:    map[ip.V4CIDR][]model.WorkloadEndpointKey{},
+		remoteWorkloadCIDRsBySlab:     map[ip.V4CIDR]set.Set{},
+		borrowedIPToNode:              map[ip.V4CIDR]string{},
 	}
 }
 
@@ -174,6 +205,10 @@ func (c *L3RouteResolver) RegisterWith(allUpdDispatcher, localDispatcher *dispat
 	if c.routeSource == "WorkloadIPs" {
 		// Driven off of workload IP addressess. Register for all WEP udpates.
 		allUpdDispatcher.Register(model.WorkloadEndpointKey{}, c.OnWorkloadUpdate)
+	} else if c.routeBorrowedIPsFromWorkloads {
+		// Driven off of IPAM data but we need remote WEPs too, to find their borrowed IPs.
+		allUpdDispatcher.Register(model.BlockKey{}, c.OnBlockUpdate)
+		allUpdDispatcher.Register(model.WorkloadEndpointKey{}, c.OnWorkloadUpdate)
 	} else {
 		// Driven off of IPAM data. Register for blocks and local WEP updates.
 		allUpdDispatcher.Register(model.BlockKey{}, c.OnBlockUpdate)
@@ -186,6 +221,11 @@ func (c *L3RouteResolver) OnWorkloadUpdate(update api.Update) (_ bool) {
 
 	key := update.Key.(model.WorkloadEndpointKey)
 
+	if c.routeSource != "WorkloadIPs" && c.routeBorrowedIPsFromWorkloads && key.Hostname != c.myNodeName {
+		c.onRemoteWorkloadUpdate(key, update.Value)
+		return
+	}
+
 	// Look up the (possibly nil) old CIDRs.
 	oldCIDRs := c.workloadIDToCIDRs[key]
 
@@ -194,6 +234,10 @@ func (c *L3RouteResolver) OnWorkloadUpdate(update api.Update) (_ bool) {
 	if update.Value != nil {
 		newWorkload := update.Value.(*model.WorkloadEndpoint)
 		newCIDRs = newWorkload.IPv4Nets
+		if c.routeSource == "WorkloadIPs" {
+			// Other nodes need to route the workload's floating IPs to its node too.
+			newCIDRs = append(newCIDRs[:len(newCIDRs):len(newCIDRs)], floatingIPv4CIDRs(newWorkload)...)
+		}
 		logrus.WithField("workload", key).WithField("newCIDRs", newCIDRs).Debug("Workload update")
 	}
 
@@ -227,6 +271,186 @@ func (c *L3RouteResolver) OnWorkloadUpdate(update api.Update) (_ bool) {
 	return
 }
 
+// onRemoteWorkloadUpdate tracks the IPs of a remote workload when we're routing borrowed IPs from
+// workloads.
+func (c *L3RouteResolver) onRemoteWorkloadUpdate(key model.WorkloadEndpointKey, value interface{}) {
+	var newCIDRs []ip.V4CIDR
+	if value != nil {
+		for _, n := range value.(*model.WorkloadEndpoint).IPv4Nets {
+			newCIDRs = append(newCIDRs, ip.CIDRFromCalicoNet(n).(ip.V4CIDR))
+		}
+	}
+	oldCIDRs := c.remoteWorkloadIDToCIDRs[key]
+	for _, cidr := range oldCIDRs {
+		c.removeRemoteWorkloadOwner(cidr, key)
+	}
+	for _, cidr := range newCIDRs {
+		c.addRemoteWorkloadOwner(cidr, key)
+	}
+	if len(newCIDRs) > 0 {
+		c.remoteWorkloadIDToCIDRs[key] = newCIDRs
+	} else {
+		delete(c.remoteWorkloadIDToCIDRs, key)
+	}
+	for _, cidr := range oldCIDRs {
+		c.updateBorrowedIP(cidr)
+	}
+	for _, cidr := range newCIDRs {
+		c.updateBorrowedIP(cidr)
+	}
+}
+
+func (c *L3RouteResolver) addRemoteWorkloadOwner(cidr ip.V4CIDR, key model.WorkloadEndpointKey) {
+	owners := c.remoteWorkloadCIDRToOwners[cidr]
+	if len(owners) == 0 {
+		slab := borrowedIPSlab(cidr)
+		cidrs := c.remoteWorkloadCIDRsBySlab[slab]
+		if cidrs == nil {
+			cidrs = set.New()
+			c.remoteWorkloadCIDRsBySlab[slab] = cidrs
+		}
+		cidrs.Add(cidr)
+	}
+	c.remoteWorkloadCIDRToOwners[cidr] = append(owners, key)
+}
+
+// removeRemoteWorkloadOwner removes the given workload from the owners of the IP.  Other
+// workloads' ownership is left alone so that deleting an IP's old workload doesn't remove its new
+// one's route.
+func (c *L3RouteResolver) removeRemoteWorkloadOwner(cidr ip.V4CIDR, key model.WorkloadEndpointKey) {
+	var owners []model.WorkloadEndpointKey
+	for _, owner := range c.remoteWorkloadCIDRToOwners[cidr] {
+		if owner != key {
+			owners = append(owners, owner)
+		}
+	}
+	if len(owners) > 0 {
+		c.remoteWorkloadCIDRToOwners[cidr] = owners
+		return
+	}
+	delete(c.remoteWorkloadCIDRToOwners, cidr)
+	slab := borrowedIPSlab(cidr)
+	if cidrs := c.remoteWorkloadCIDRsBySlab[slab]; cidrs != nil {
+		cidrs.Discard(cidr)
+		if cidrs.Len() == 0 {
+			delete(c.remoteWorkloadCIDRsBySlab, slab)
+		}
+	}
+}
+
+// remoteWorkloadNode returns the node of the newest workload that has the given IP, or "" if no
+// remote workload has it.
+func (c *L3RouteResolver) remoteWorkloadNode(cidr ip.V4CIDR) string {
+	owners := c.remoteWorkloadCIDRToOwners[cidr]
+	if len(owners) == 0 {
+		return ""
+	}
+	return owners[len(owners)-1].Hostname
+}
+
+func borrowedIPSlab(cidr ip.V4CIDR) ip.V4CIDR {
+	return ip.CIDRFromAddrAndPrefix(cidr.Addr(), borrowedIPSlabPrefixLen).(ip.V4CIDR)
+}
+
+// updateBorrowedIP adds a workload ref for the given remote workload IP if IPAM doesn't route it
+// to the workload's node; for example, because the IP was borrowed from another node's block but
+// IPAM didn't record which node borrowed it.  Without the ref, the IP would be routed to the
+// block's node, which would blackhole it.  It removes the ref once it's no longer needed.
+func (c *L3RouteResolver) updateBorrowedIP(cidr ip.V4CIDR) {
+	nodeName := c.remoteWorkloadNode(cidr)
+	if nodeName != "" && c.ipamNodeForCIDR(cidr) == nodeName {
+		// IPAM already routes the IP to the right node.
+		nodeName = ""
+	}
+	if oldNodeName, ok := c.borrowedIPToNode[cidr]; ok {
+		if oldNodeName == nodeName {
+			return
+		}
+		logrus.WithFields(logrus.Fields{"cidr": cidr, "node": oldNodeName}).Debug("Removing borrowed IP route")
+		c.trie.RemoveRef(cidr, oldNodeName, RefTypeWEP)
+		c.nodeRoutes.Remove(nodenameRoute{nodeName: oldNodeName, dst: cidr})
+		delete(c.borrowedIPToNode, cidr)
+	}
+	if nodeName != "" {
+		logrus.WithFields(logrus.Fields{"cidr": cidr, "node": nodeName}).Debug("Adding borrowed IP route")
+		c.trie.AddRef(cidr, nodeName, RefTypeWEP)
+		c.nodeRoutes.Add(nodenameRoute{nodeName: nodeName, dst: cidr})
+		c.borrowedIPToNode[cidr] = nodeName
+	}
+}
+
+// ipamNodeForCIDR returns the node that IPAM routes the given CIDR to, if any.  We can't use
+// LookupPath here because the CIDR itself may not be in the trie, so we walk up the enclosing
+// CIDRs using longest prefix matches instead.
+func (c *L3RouteResolver) ipamNodeForCIDR(cidr ip.V4CIDR) string {
+	for {
+		match, data := c.trie.t.LPM(cidr)
+		if data == nil {
+			return ""
+		}
+		if nodeName := data.(RouteInfo).Block.NodeName; nodeName != "" {
+			return nodeName
+		}
+		if match.Prefix() == 0 {
+			return ""
+		}
+		cidr = ip.CIDRFromAddrAndPrefix(match.Addr(), int(match.Prefix())-1).(ip.V4CIDR)
+	}
+}
+
+// updateBorrowedIPsInBlock rechecks the remote workload IPs in the given block after the block's
+// routes change.
+func (c *L3RouteResolver) updateBorrowedIPsInBlock(block ip.V4CIDR) {
+	// We only add borrowed IP routes for remote workload IPs, and remove them as soon as the IP
+	// has no workload, so the remote workload IPs cover all the borrowed IPs.
+	var cidrsInBlock []ip.V4CIDR
+	checkSlab := func(cidrs set.Set) {
+		cidrs.Iter(func(item interface{}) error {
+			cidr := item.(ip.V4CIDR)
+			if block.ContainsV4(cidr.Addr().(ip.V4Addr)) {
+				cidrsInBlock = append(cidrsInBlock, cidr)
+			}
+			return nil
+		})
+	}
+	if block.Prefix() >= borrowedIPSlabPrefixLen {
+		if cidrs := c.remoteWorkloadCIDRsBySlab[borrowedIPSlab(block)]; cidrs != nil {
+			checkSlab(cidrs)
+		}
+	} else {
+		// Bigger than any valid block; check every slab in it.
+		for slab, cidrs := range c.remoteWorkloadCIDRsBySlab {
+			if block.ContainsV4(slab.Addr().(ip.V4Addr)) {
+				checkSlab(cidrs)
+			}
+		}
+	}
+	for _, cidr := range cidrsInBlock {
+		c.updateBorrowedIP(cidr)
+	}
+}
+
+// floatingIPv4CIDRs returns the workload's IPv4 floating IPs as /32 CIDRs.  Like the rest of the
+// L3RouteResolver, it only handles IPv4: an IPv6 floating IP is still DNATted on the workload's
+// node but other nodes don't get a route to it.
+func floatingIPv4CIDRs(wep *model.WorkloadEndpoint) []cnet.IPNet {
+	var cidrs []cnet.IPNet
+	for _, nats := range [][]model.IPNAT{wep.IPv4NAT, wep.IPv6NAT} {
+		for _, nat := range nats {
+			if nat.ExtIP.To4() == nil {
+				logrus.WithFields(logrus.Fields{
+					"workload":   wep.Name,
+					"floatingIP": nat.ExtIP.String(),
+				}).Warn("IPv6 floating IPs aren't routed between nodes; the floating IP is only " +
+					"reachable on the workload's node.")
+				continue
+			}
+			cidrs = append(cidrs, cnet.IPNet{IPNet: net.IPNet{IP: nat.ExtIP.To4(), Mask: net.CIDRMask(32, 32)}})
+		}
+	}
+	return cidrs
+}
+
 func (c *L3RouteResolver) OnBlockUpdate(update api.Update) (_ bool) {
 	// Queue up a flush.
 	defer c.flush()
@@ -296,6 +520,9 @@ func (c *L3RouteResolver) OnBlockUpdate(update api.Update) (_ bool) {
 			c.nodeRoutes.Add(nr)
 			return nil
 		})
+		if c.routeBorrowedIPsFromWorkloads && (deletes.Len() > 0 || adds.Len() > 0) {
+			c.updateBorrowedIPsInBlockKey(update.Key)
+		}
 	} else {
 		// Block has been deleted. Clean up routes that were contributed by this block.
 		logrus.WithField("update", update).Debug("IPAM block deleted")
@@ -308,10 +535,21 @@ func (c *L3RouteResolver) OnBlockUpdate(update api.Update) (_ bool) {
 			})
 		}
 		delete(c.blockToRoutes, key)
+		if c.routeBorrowedIPsFromWorkloads {
+			c.updateBorrowedIPsInBlockKey(update.Key)
+		}
 	}
 	return
 }
 
+func (c *L3RouteResolver) updateBorrowedIPsInBlockKey(key model.Key) {
+	blockCIDR := ip.CIDRFromCalicoNet(key.(model.BlockKey).CIDR)
+	if blockCIDR.Version() != 4 {
+		return
+	}
+	c.updateBorrowedIPsInBlock(blockCIDR.(ip.V4CIDR))
+}
+
 func (c *L3RouteResolver) OnResourceUpdate(update api.Update) (_ bool) {
 	// We only care about nodes, not other resources.
 	resourceKey := update.Key.(model.ResourceKey)
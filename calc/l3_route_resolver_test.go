@@ -0,0 +1,176 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calc_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/libcalico-go/lib/backend/api"
+	"github.com/projectcalico/libcalico-go/lib/backend/model"
+	"github.com/projectcalico/libcalico-go/lib/net"
+
+	"github.com/projectcalico/felix/calc"
+	"github.com/projectcalico/felix/proto"
+)
+
+// routeRecorder records the routes emitted by the L3RouteResolver, keyed on destination.  Only
+// the route callbacks are implemented.
+type routeRecorder struct {
+	calc.PipelineCallbacks
+	routes map[string]*proto.RouteUpdate
+}
+
+func (r *routeRecorder) OnRouteUpdate(update *proto.RouteUpdate) {
+	r.routes[update.Dst] = update
+}
+
+func (r *routeRecorder) OnRouteRemove(dst string) {
+	delete(r.routes, dst)
+}
+
+var _ = Describe("L3RouteResolver borrowed IPs", func() {
+	var (
+		uut      *calc.L3RouteResolver
+		recorder *routeRecorder
+	)
+
+	BeforeEach(func() {
+		recorder = &routeRecorder{routes: map[string]*proto.RouteUpdate{}}
+		uut = calc.NewL3RouteResolver(localHostname, recorder, false, "CalicoIPAM", true)
+
+		// 10.0.1.0 is allocated in the block but IPAM doesn't say which node has it.
+		uut.OnBlockUpdate(api.Update{KVPair: model.KVPair{
+			Key:   remoteIPAMBlockKey,
+			Value: &remoteIPAMBlockWithBorrows,
+		}})
+	})
+
+	sendWorkload := func(hostname, workloadID, cidr string) {
+		key := model.WorkloadEndpointKey{
+			Hostname:       hostname,
+			OrchestratorID: "orch",
+			WorkloadID:     workloadID,
+			EndpointID:     "ep1",
+		}
+		var value interface{}
+		if cidr != "" {
+			value = &model.WorkloadEndpoint{IPv4Nets: []net.IPNet{mustParseNet(cidr)}}
+		}
+		uut.OnWorkloadUpdate(api.Update{KVPair: model.KVPair{Key: key, Value: value}})
+	}
+
+	routeNodes := func() map[string]string {
+		nodes := map[string]string{}
+		for dst, r := range recorder.routes {
+			nodes[dst] = r.DstNodeName
+		}
+		return nodes
+	}
+
+	It("should only emit the IPAM routes to start with", func() {
+		Expect(routeNodes()).To(Equal(map[string]string{
+			"10.0.1.0/29": remoteHostname,
+			"10.0.1.2/32": remoteHostname2,
+		}))
+	})
+
+	It("should route a borrowed IP to the workload's node", func() {
+		sendWorkload(remoteHostname2, "wl1", "10.0.1.0/32")
+		Expect(routeNodes()).To(Equal(map[string]string{
+			"10.0.1.0/29": remoteHostname,
+			"10.0.1.0/32": remoteHostname2,
+			"10.0.1.2/32": remoteHostname2,
+		}))
+
+		By("removing the route when the workload is deleted")
+		sendWorkload(remoteHostname2, "wl1", "")
+		Expect(routeNodes()).To(Equal(map[string]string{
+			"10.0.1.0/29": remoteHostname,
+			"10.0.1.2/32": remoteHostname2,
+		}))
+	})
+
+	It("should keep the route to an IP's new node when its old workload is deleted", func() {
+		sendWorkload(remoteHostname2, "wl1", "10.0.1.0/32")
+		sendWorkload("remotehostname3", "wl2", "10.0.1.0/32")
+		Expect(routeNodes()).To(HaveKeyWithValue("10.0.1.0/32", "remotehostname3"))
+
+		sendWorkload(remoteHostname2, "wl1", "")
+		Expect(routeNodes()).To(Equal(map[string]string{
+			"10.0.1.0/29": remoteHostname,
+			"10.0.1.0/32": "remotehostname3",
+			"10.0.1.2/32": remoteHostname2,
+		}))
+
+		By("removing the route when the new workload is deleted too")
+		sendWorkload("remotehostname3", "wl2", "")
+		Expect(routeNodes()).To(Equal(map[string]string{
+			"10.0.1.0/29": remoteHostname,
+			"10.0.1.2/32": remoteHostname2,
+		}))
+	})
+
+	It("should recheck workload IPs when a block that contains them changes", func() {
+		sendWorkload(remoteHostname, "wl1", "10.0.1.1/32")
+		sendWorkload(remoteHostname2, "wl2", "10.0.64.1/32")
+		Expect(routeNodes()).NotTo(HaveKey("10.0.1.1/32"))
+
+		// Once the block is gone, IPAM no longer routes the IP to the workload's node.
+		uut.OnBlockUpdate(api.Update{KVPair: model.KVPair{Key: remoteIPAMBlockKey}})
+		Expect(routeNodes()).To(Equal(map[string]string{
+			"10.0.1.1/32":  remoteHostname,
+			"10.0.64.1/32": remoteHostname2,
+		}))
+	})
+
+	It("should not add routes for IPs that IPAM already routes to the workload's node", func() {
+		sendWorkload(remoteHostname, "wl1", "10.0.1.1/32")
+		sendWorkload(remoteHostname2, "wl2", "10.0.1.2/32")
+		Expect(routeNodes()).To(Equal(map[string]string{
+			"10.0.1.0/29": remoteHostname,
+			"10.0.1.2/32": remoteHostname2,
+		}))
+	})
+
+	It("should hand over to IPAM once it records the borrowing node", func() {
+		sendWorkload(remoteHostname2, "wl1", "10.0.1.0/32")
+
+		block := remoteIPAMBlockWithBorrows
+		block.Attributes = append([]model.AllocationAttribute{}, block.Attributes...)
+		block.Attributes[0] = model.AllocationAttribute{AttrSecondary: map[string]string{
+			model.IPAMBlockAttributeNode: remoteHostname2,
+		}}
+		uut.OnBlockUpdate(api.Update{KVPair: model.KVPair{Key: remoteIPAMBlockKey, Value: &block}})
+		Expect(routeNodes()).To(Equal(map[string]string{
+			"10.0.1.0/29": remoteHostname,
+			"10.0.1.0/32": remoteHostname2,
+			"10.0.1.2/32": remoteHostname2,
+		}))
+
+		By("keeping the IPAM route when the workload is deleted")
+		sendWorkload(remoteHostname2, "wl1", "")
+		Expect(routeNodes()).To(Equal(map[string]string{
+			"10.0.1.0/29": remoteHostname,
+			"10.0.1.0/32": remoteHostname2,
+			"10.0.1.2/32": remoteHostname2,
+		}))
+	})
+
+	It("should still route local workloads by their own refs", func() {
+		sendWorkload(localHostname, "wl1", "10.0.2.1/32")
+		Expect(routeNodes()).To(HaveKeyWithValue("10.0.2.1/32", localHostname))
+	})
+})
@@ -0,0 +1,112 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calc
+
+import (
+	"github.com/projectcalico/libcalico-go/lib/backend/api"
+	"github.com/projectcalico/libcalico-go/lib/backend/model"
+
+	"github.com/projectcalico/felix/dispatcher"
+	"github.com/projectcalico/felix/stringutils"
+)
+
+// EndpointInterner deduplicates the labels, profile IDs and named port names of endpoints and
+// network sets as they enter the calculation graph.  Each datastore update carries its own
+// copies of those strings but, on a node with many endpoints, most of them are the same (for
+// example, the namespace and orchestrator labels), and the calculation graph holds on to them
+// in several places.  Interning them before anything else sees the update means that all of
+// those places share one copy.
+//
+// It must be registered with the dispatcher before any other receivers.  It updates the
+// endpoint in place, which is safe because the calculation graph owns the updates it receives.
+type EndpointInterner struct {
+	interner *stringutils.Interner
+	// endpoints holds the current value of each endpoint so that we can release its strings
+	// when it is updated or deleted.
+	endpoints map[model.Key]interface{}
+}
+
+func NewEndpointInterner() *EndpointInterner {
+	return &EndpointInterner{
+		interner:  stringutils.NewInterner(),
+		endpoints: map[model.Key]interface{}{},
+	}
+}
+
+func (e *EndpointInterner) RegisterWith(allUpdDispatcher *dispatcher.Dispatcher) {
+	allUpdDispatcher.Register(model.WorkloadEndpointKey{}, e.OnUpdate)
+	allUpdDispatcher.Register(model.HostEndpointKey{}, e.OnUpdate)
+	allUpdDispatcher.Register(model.NetworkSetKey{}, e.OnUpdate)
+}
+
+func (e *EndpointInterner) OnUpdate(update api.Update) (filterOut bool) {
+	old, haveOld := e.endpoints[update.Key]
+	if haveOld && old == update.Value {
+		// Same value again, it's already interned.
+		return
+	}
+	// Intern the new value before releasing the old one so that strings that are in both
+	// aren't forgotten in between.
+	if update.Value != nil {
+		e.intern(update.Value)
+	}
+	if haveOld {
+		e.release(old)
+	}
+	if update.Value != nil {
+		e.endpoints[update.Key] = update.Value
+	} else {
+		delete(e.endpoints, update.Key)
+	}
+	return
+}
+
+func (e *EndpointInterner) intern(value interface{}) {
+	switch v := value.(type) {
+	case *model.WorkloadEndpoint:
+		v.Labels = e.interner.InternMap(v.Labels)
+		e.interner.InternSlice(v.ProfileIDs)
+		for i := range v.Ports {
+			v.Ports[i].Name = e.interner.Intern(v.Ports[i].Name)
+		}
+	case *model.HostEndpoint:
+		v.Labels = e.interner.InternMap(v.Labels)
+		e.interner.InternSlice(v.ProfileIDs)
+		for i := range v.Ports {
+			v.Ports[i].Name = e.interner.Intern(v.Ports[i].Name)
+		}
+	case *model.NetworkSet:
+		v.Labels = e.interner.InternMap(v.Labels)
+	}
+}
+
+func (e *EndpointInterner) release(value interface{}) {
+	switch v := value.(type) {
+	case *model.WorkloadEndpoint:
+		e.interner.ReleaseMap(v.Labels)
+		e.interner.ReleaseSlice(v.ProfileIDs)
+		for _, p := range v.Ports {
+			e.interner.Release(p.Name)
+		}
+	case *model.HostEndpoint:
+		e.interner.ReleaseMap(v.Labels)
+		e.interner.ReleaseSlice(v.ProfileIDs)
+		for _, p := range v.Ports {
+			e.interner.Release(p.Name)
+		}
+	case *model.NetworkSet:
+		e.interner.ReleaseMap(v.Labels)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calc
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/libcalico-go/lib/backend/api"
+	"github.com/projectcalico/libcalico-go/lib/backend/model"
+	"github.com/projectcalico/libcalico-go/lib/numorstring"
+)
+
+// newTestWorkloadEndpoint returns an endpoint with freshly allocated strings, as if it had just
+// been decoded from the datastore.
+func newTestWorkloadEndpoint(n int) *model.WorkloadEndpoint {
+	ns := fmt.Sprint("namespace-", n%10)
+	return &model.WorkloadEndpoint{
+		Name: fmt.Sprint("cali", n),
+		Labels: map[string]string{
+			fmt.Sprint("projectcalico.org/namespace"):    ns,
+			fmt.Sprint("projectcalico.org/orchestrator"): fmt.Sprint("k8s"),
+			fmt.Sprint("app"): fmt.Sprint("app-", n%100),
+		},
+		ProfileIDs: []string{fmt.Sprint("kns.", ns)},
+		Ports: []model.EndpointPort{
+			{Name: fmt.Sprint("http"), Protocol: numorstring.ProtocolFromString("TCP"), Port: 80},
+		},
+	}
+}
+
+func testWorkloadEndpointKey(n int) model.WorkloadEndpointKey {
+	return model.WorkloadEndpointKey{
+		Hostname:       "host",
+		OrchestratorID: "k8s",
+		WorkloadID:     fmt.Sprint("wep-", n),
+		EndpointID:     "eth0",
+	}
+}
+
+var _ = Describe("EndpointInterner", func() {
+	var uut *EndpointInterner
+
+	BeforeEach(func() {
+		uut = NewEndpointInterner()
+	})
+
+	send := func(n int, value interface{}) {
+		uut.OnUpdate(api.Update{KVPair: model.KVPair{Key: testWorkloadEndpointKey(n), Value: value}})
+	}
+
+	It("should share strings between endpoints without changing them", func() {
+		ep1 := newTestWorkloadEndpoint(0)
+		ep2 := newTestWorkloadEndpoint(10)
+		send(1, ep1)
+		send(2, ep2)
+		Expect(ep1).To(Equal(newTestWorkloadEndpoint(0)))
+		Expect(ep2).To(Equal(newTestWorkloadEndpoint(10)))
+		// Namespace, orchestrator and profile labels and values and the port name are shared;
+		// "app-0" and "app-10" aren't.
+		Expect(uut.interner.Len()).To(Equal(9))
+	})
+
+	It("should release strings when endpoints are updated and deleted", func() {
+		send(1, newTestWorkloadEndpoint(0))
+		send(1, newTestWorkloadEndpoint(1))
+		Expect(uut.interner.Len()).To(Equal(8))
+		send(1, nil)
+		Expect(uut.interner.Len()).To(Equal(0))
+		Expect(uut.endpoints).To(BeEmpty())
+	})
+
+	It("should handle the same value being sent twice", func() {
+		ep := newTestWorkloadEndpoint(0)
+		send(1, ep)
+		send(1, ep)
+		send(1, nil)
+		Expect(uut.interner.Len()).To(Equal(0))
+	})
+})
+
+func BenchmarkEndpointHeapPlain10000(b *testing.B) {
+	benchmarkEndpointHeap(b, 10000, false)
+}
+
+func BenchmarkEndpointHeapInterned10000(b *testing.B) {
+	benchmarkEndpointHeap(b, 10000, true)
+}
+
+// benchmarkEndpointHeap reports the heap retained by numEndpoints endpoints, with and without
+// interning.
+func benchmarkEndpointHeap(b *testing.B, numEndpoints int, intern bool) {
+	var retained int64
+	for i := 0; i < b.N; i++ {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+
+		uut := NewEndpointInterner()
+		endpoints := make([]*model.WorkloadEndpoint, numEndpoints)
+		for n := range endpoints {
+			endpoints[n] = newTestWorkloadEndpoint(n)
+			if intern {
+				uut.OnUpdate(api.Update{KVPair: model.KVPair{
+					Key:   testWorkloadEndpointKey(n),
+					Value: endpoints[n],
+				}})
+			}
+		}
+
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		retained += int64(after.HeapAlloc) - int64(before.HeapAlloc)
+		runtime.KeepAlive(endpoints)
+		runtime.KeepAlive(uut)
+	}
+	b.ReportMetric(float64(retained)/float64(b.N)/float64(numEndpoints), "heap-bytes/endpoint")
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calc
+
+import (
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+
+	apiv3 "github.com/projectcalico/libcalico-go/lib/apis/v3"
+	"github.com/projectcalico/libcalico-go/lib/backend/api"
+	"github.com/projectcalico/libcalico-go/lib/backend/model"
+	cnet "github.com/projectcalico/libcalico-go/lib/net"
+
+	"github.com/projectcalico/felix/dispatcher"
+	"github.com/projectcalico/felix/ip"
+)
+
+// LabelNodeEndpoint is set, to the node's name, on the pseudo-endpoint that represents each node
+// when NodeSelectorIPSetsEnabled is set.  Only selectors that refer to this label match nodes;
+// for example, "has(projectcalico.org/node-endpoint) && topology.kubernetes.io/zone == 'zone-a'".
+// That stops existing selectors, such as all(), from silently starting to match nodes.
+const LabelNodeEndpoint = "projectcalico.org/node-endpoint"
+
+// NodeEndpointKey identifies a node's pseudo-endpoint in the IP set member index.
+type NodeEndpointKey struct {
+	Name string
+}
+
+// RequiredSelectorLabel implements labelindex.ExplicitlySelectedID so that only selectors that
+// refer to LabelNodeEndpoint match nodes.
+func (k NodeEndpointKey) RequiredSelectorLabel() string {
+	return LabelNodeEndpoint
+}
+
+type nodeEndpointIndex interface {
+	UpdateEndpointOrSet(id interface{}, labels map[string]string, nets []ip.CIDR, ports []model.EndpointPort, parentIDs []string)
+	DeleteEndpoint(id interface{})
+}
+
+// NodeEndpointIndexer adds each node to the IP set member index as if it were an endpoint with
+// the node's labels and addresses.  That lets policy selectors match nodes, for example to allow
+// traffic to host-networked monitoring agents on the nodes in a particular zone, which selectors
+// over pods can't express.  The node's addresses include its tunnel addresses so that traffic
+// that the node sources from those is matched too.
+type NodeEndpointIndexer struct {
+	index nodeEndpointIndex
+}
+
+func NewNodeEndpointIndexer(index nodeEndpointIndex) *NodeEndpointIndexer {
+	return &NodeEndpointIndexer{index: index}
+}
+
+func (n *NodeEndpointIndexer) RegisterWith(allUpdDispatcher *dispatcher.Dispatcher) {
+	allUpdDispatcher.Register(model.ResourceKey{}, n.OnResourceUpdate)
+}
+
+func (n *NodeEndpointIndexer) OnResourceUpdate(update api.Update) (_ bool) {
+	resourceKey := update.Key.(model.ResourceKey)
+	if resourceKey.Kind != apiv3.KindNode {
+		return
+	}
+	key := NodeEndpointKey{Name: resourceKey.Name}
+	if update.Value == nil {
+		n.index.DeleteEndpoint(key)
+		return
+	}
+	node := update.Value.(*apiv3.Node)
+	labels := make(map[string]string, len(node.Labels)+1)
+	for k, v := range node.Labels {
+		labels[k] = v
+	}
+	labels[LabelNodeEndpoint] = node.Name
+	n.index.UpdateEndpointOrSet(key, labels, nodeAddrCIDRs(node), nil, nil)
+	return
+}
+
+// nodeAddrCIDRs returns the node's addresses, including its tunnel addresses, as /32 and /128
+// CIDRs.
+func nodeAddrCIDRs(node *apiv3.Node) []ip.CIDR {
+	var addrs []string
+	if bgp := node.Spec.BGP; bgp != nil {
+		addrs = append(addrs, bgp.IPv4Address, bgp.IPv6Address, bgp.IPv4IPIPTunnelAddr)
+	}
+	addrs = append(addrs, node.Spec.IPv4VXLANTunnelAddr)
+	if wg := node.Spec.Wireguard; wg != nil {
+		addrs = append(addrs, wg.InterfaceIPv4Address)
+	}
+	for _, a := range node.Spec.Addresses {
+		addrs = append(addrs, a.Address)
+	}
+
+	seen := map[ip.CIDR]bool{}
+	var cidrs []ip.CIDR
+	for _, a := range addrs {
+		if a == "" {
+			continue
+		}
+		addr, _, err := cnet.ParseCIDROrIP(a)
+		if err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"node": node.Name,
+				"addr": a,
+			}).Warn("Ignoring unparseable node address.")
+			continue
+		}
+		cidr := ip.FromCalicoIP(*addr).AsCIDR()
+		if !seen[cidr] {
+			seen[cidr] = true
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	sort.Slice(cidrs, func(i, j int) bool {
+		return cidrs[i].String() < cidrs[j].String()
+	})
+	return cidrs
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calc_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	apiv3 "github.com/projectcalico/libcalico-go/lib/apis/v3"
+	"github.com/projectcalico/libcalico-go/lib/backend/api"
+	"github.com/projectcalico/libcalico-go/lib/backend/model"
+	"github.com/projectcalico/libcalico-go/lib/selector"
+
+	"github.com/projectcalico/felix/calc"
+	"github.com/projectcalico/felix/labelindex"
+)
+
+var _ = Describe("NodeEndpointIndexer", func() {
+	var (
+		idx     *labelindex.SelectorAndNamedPortIndex
+		uut     *calc.NodeEndpointIndexer
+		members map[string]bool
+	)
+
+	BeforeEach(func() {
+		idx = labelindex.NewSelectorAndNamedPortIndex()
+		members = map[string]bool{}
+		idx.OnMemberAdded = func(ipSetID string, member labelindex.IPSetMember) {
+			members[member.CIDR.String()] = true
+		}
+		idx.OnMemberRemoved = func(ipSetID string, member labelindex.IPSetMember) {
+			delete(members, member.CIDR.String())
+		}
+		sel, err := selector.Parse("has(projectcalico.org/node-endpoint) && zone == 'a'")
+		Expect(err).NotTo(HaveOccurred())
+		idx.UpdateIPSet("zone-a-nodes", sel, labelindex.ProtocolNone, "")
+		uut = calc.NewNodeEndpointIndexer(idx)
+	})
+
+	sendNode := func(name, zone string) {
+		node := apiv3.NewNode()
+		node.Name = name
+		node.Labels = map[string]string{"zone": zone}
+		node.Spec.BGP = &apiv3.NodeBGPSpec{
+			IPv4Address:        "192.168.0." + name + "/24",
+			IPv4IPIPTunnelAddr: "10.0.0." + name,
+		}
+		node.Spec.IPv4VXLANTunnelAddr = "10.0.1." + name
+		node.Spec.Addresses = []apiv3.NodeAddress{
+			{Address: "192.168.0." + name},
+			{Address: "fd00::" + name},
+		}
+		uut.OnResourceUpdate(api.Update{KVPair: model.KVPair{
+			Key:   model.ResourceKey{Kind: apiv3.KindNode, Name: name},
+			Value: node,
+		}})
+	}
+
+	It("should add the addresses of the nodes that match", func() {
+		sendNode("1", "a")
+		sendNode("2", "b")
+		Expect(members).To(Equal(map[string]bool{
+			"192.168.0.1/32": true,
+			"10.0.0.1/32":    true,
+			"10.0.1.1/32":    true,
+			"fd00::1/128":    true,
+		}))
+	})
+
+	It("should only match selectors that depend on the node endpoint label", func() {
+		sel, err := selector.Parse("zone == 'a'")
+		Expect(err).NotTo(HaveOccurred())
+		idx.UpdateIPSet("zone-a", sel, labelindex.ProtocolNone, "")
+		sel, err = selector.Parse("all()")
+		Expect(err).NotTo(HaveOccurred())
+		idx.UpdateIPSet("all", sel, labelindex.ProtocolNone, "")
+		sel, err = selector.Parse("owner != 'projectcalico.org/node-endpoint'")
+		Expect(err).NotTo(HaveOccurred())
+		idx.UpdateIPSet("mentions-label", sel, labelindex.ProtocolNone, "")
+		var matchedIPSets []string
+		idx.OnMemberAdded = func(ipSetID string, member labelindex.IPSetMember) {
+			matchedIPSets = append(matchedIPSets, ipSetID)
+		}
+
+		sendNode("1", "a")
+		Expect(matchedIPSets).NotTo(BeEmpty())
+		for _, id := range matchedIPSets {
+			Expect(id).To(Equal("zone-a-nodes"))
+		}
+	})
+
+	It("should update the members when a node's labels change", func() {
+		sendNode("1", "a")
+		sendNode("1", "b")
+		Expect(members).To(BeEmpty())
+	})
+
+	It("should remove a deleted node", func() {
+		sendNode("1", "a")
+		uut.OnResourceUpdate(api.Update{KVPair: model.KVPair{
+			Key: model.ResourceKey{Kind: apiv3.KindNode, Name: "1"},
+		}})
+		Expect(members).To(BeEmpty())
+	})
+
+	It("should ignore other resources", func() {
+		uut.OnResourceUpdate(api.Update{KVPair: model.KVPair{
+			Key:   model.ResourceKey{Kind: apiv3.KindNetworkPolicy, Name: "1"},
+			Value: apiv3.NewNetworkPolicy(),
+		}})
+		Expect(members).To(BeEmpty())
+	})
+})
@@ -214,6 +214,20 @@ var remoteWlEp1 = WorkloadEndpoint{
 	},
 }
 
+var remoteWlEp1WithFloatingIP = WorkloadEndpoint{
+	State:      "active",
+	Name:       "remote-wep-1",
+	Mac:        mustParseMac("01:02:03:04:05:06"),
+	ProfileIDs: []string{"prof-1", "prof-2", "prof-missing"},
+	IPv4Nets:   []net.IPNet{mustParseNet("10.0.0.5/32")},
+	IPv4NAT: []IPNAT{
+		{IntIP: mustParseIP("10.0.0.5"), ExtIP: mustParseIP("10.0.1.5")},
+	},
+	Labels: map[string]string{
+		"id": "rem-ep-1",
+	},
+}
+
 var hostEpWithName = HostEndpoint{
 	Name:       "eth1",
 	ProfileIDs: []string{"prof-1", "prof-2", "prof-missing"},
@@ -114,6 +114,8 @@ func parsedRuleToProtoRule(in *ParsedRule) *proto.Rule {
 		NotSrcIpSetIds:          in.NotSrcIPSetIDs,
 		NotDstIpSetIds:          in.NotDstIPSetIDs,
 
+		DstDomains: in.DstDomains,
+
 		// Pass through fields for the policy sync API.
 		OriginalSrcSelector:          in.OriginalSrcSelector,
 		OriginalSrcNamespaceSelector: in.OriginalSrcNamespaceSelector,
@@ -73,6 +73,8 @@ var fullyLoadedParsedRule = ParsedRule{
 	NotSrcIPSetIDs: []string{"srcID3", "srcID4"},
 	NotDstIPSetIDs: []string{"dstID3", "dstID4"},
 
+	DstDomains: []string{"*.example.com", "example.org"},
+
 	OriginalSrcSelector:          "has(original-src)",
 	OriginalDstSelector:          "has(original-dst)",
 	OriginalNotSrcSelector:       "has(original-not-src)",
@@ -137,6 +139,8 @@ var fullyLoadedProtoRule = proto.Rule{
 	NotSrcIpSetIds: []string{"srcID3", "srcID4"},
 	NotDstIpSetIds: []string{"dstID3", "dstID4"},
 
+	DstDomains: []string{"*.example.com", "example.org"},
+
 	OriginalSrcSelector:          "has(original-src)",
 	OriginalDstSelector:          "has(original-dst)",
 	OriginalNotSrcSelector:       "has(original-not-src)",
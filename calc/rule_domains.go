@@ -0,0 +1,65 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calc
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/libcalico-go/lib/backend/model"
+)
+
+// DestinationDomainsAnnotation is the rule annotation that carries a rule's destination.domains
+// match: a comma-separated list of domain names, each of which may start with "*." to match
+// any subdomain.  A rule with domains only matches destinations that the domains have been seen
+// to resolve to.
+const DestinationDomainsAnnotation = "projectcalico.org/destination-domains"
+
+var domainRegexp = regexp.MustCompile(`^(\*\.)?([a-z0-9_]([-a-z0-9_]*[a-z0-9_])?\.)*[a-z0-9_]([-a-z0-9_]*[a-z0-9_])?$`)
+
+// parseDstDomains returns the normalised, sorted domain names from the rule's
+// DestinationDomainsAnnotation, if it has one.
+func parseDstDomains(md *model.RuleMetadata) []string {
+	if md == nil {
+		return nil
+	}
+	annotation, ok := md.Annotations[DestinationDomainsAnnotation]
+	if !ok {
+		return nil
+	}
+	seen := map[string]bool{}
+	var domains []string
+	for _, d := range strings.Split(annotation, ",") {
+		d = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(d), "."))
+		if d == "" || seen[d] {
+			continue
+		}
+		if !domainRegexp.MatchString(d) {
+			// Keep it: it'll never resolve so the rule won't match anything for it, which is
+			// safer than dropping the match.
+			log.WithField("domain", d).Warn("Invalid domain name in rule, it will never match")
+		}
+		seen[d] = true
+		domains = append(domains, d)
+	}
+	if len(domains) == 0 {
+		log.WithField("annotation", annotation).Warn("Rule has an empty destination domains annotation, ignoring it")
+	}
+	sort.Strings(domains)
+	return domains
+}
@@ -288,6 +288,10 @@ type ParsedRule struct {
 	NotSrcIPSetIDs          []string
 	NotDstIPSetIDs          []string
 
+	// DstDomains are the domain names that the destination must have resolved to.  They come from
+	// the DestinationDomainsAnnotation on the rule.
+	DstDomains []string
+
 	// These fields allow us to pass through the raw match criteria from the V3 datamodel,
 	// unmodified. The selectors above are formed in the update processor layer by combining the
 	// original selectors, namespace selectors an service account matches into one.
@@ -403,6 +407,8 @@ func ruleToParsedRule(rule *model.Rule) (parsedRule *ParsedRule, allIPSets []*IP
 		NotICMPType: rule.NotICMPType,
 		NotICMPCode: rule.NotICMPCode,
 
+		DstDomains: parseDstDomains(rule.Metadata),
+
 		// Pass through original values of some fields for the policy API.
 		OriginalSrcSelector:               rule.OriginalSrcSelector,
 		OriginalSrcNamespaceSelector:      rule.OriginalSrcNamespaceSelector,
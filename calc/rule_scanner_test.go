@@ -158,6 +158,16 @@ var _ = DescribeTable("RuleScanner rule conversion should generate correct Parse
 	Entry("Metadata",
 		model.Rule{Metadata: &model.RuleMetadata{Annotations: map[string]string{"key": "value"}}},
 		ParsedRule{Metadata: &model.RuleMetadata{Annotations: map[string]string{"key": "value"}}}),
+	Entry("Destination domains",
+		model.Rule{Metadata: &model.RuleMetadata{Annotations: map[string]string{
+			DestinationDomainsAnnotation: "Example.org., *.example.com, example.org",
+		}}},
+		ParsedRule{
+			Metadata: &model.RuleMetadata{Annotations: map[string]string{
+				DestinationDomainsAnnotation: "Example.org., *.example.com, example.org",
+			}},
+			DstDomains: []string{"*.example.com", "example.org"},
+		}),
 
 	// Tags/Selectors.
 	Entry("source tag", model.Rule{SrcTag: "tag1"}, ParsedRule{SrcIPSetIDs: []string{tag1ID}}),
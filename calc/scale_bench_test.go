@@ -0,0 +1,197 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calc_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	. "github.com/projectcalico/felix/calc"
+	"github.com/projectcalico/felix/config"
+
+	"github.com/projectcalico/libcalico-go/lib/backend/api"
+	"github.com/projectcalico/libcalico-go/lib/backend/model"
+	"github.com/projectcalico/libcalico-go/lib/net"
+)
+
+// The scale benchmarks synthesize a cluster with the given number of endpoints (a tenth of them
+// local) and policies, and measure how long the calculation graph takes to get from an empty
+// start to in sync, and then to handle label churn.  For example:
+//
+//     go test ./calc -run XXX -bench BenchmarkCalcGraphStartup -benchtime 3x
+//
+// Use -cpuprofile/-memprofile to see where the time goes.
+
+func BenchmarkCalcGraphStartup1000Eps100Pols(b *testing.B) {
+	benchmarkCalcGraphStartup(b, 1000, 100)
+}
+
+func BenchmarkCalcGraphStartup10000Eps1000Pols(b *testing.B) {
+	benchmarkCalcGraphStartup(b, 10000, 1000)
+}
+
+func BenchmarkCalcGraphLabelChurn10000Eps1000Pols(b *testing.B) {
+	benchmarkCalcGraphLabelChurn(b, 10000, 1000)
+}
+
+const (
+	scaleNumApps       = 100
+	scaleNumNamespaces = 10
+)
+
+func scaleEndpointKey(n int) model.WorkloadEndpointKey {
+	hostname := fmt.Sprint("remote-host-", n%100)
+	if n%10 == 0 {
+		hostname = localHostname
+	}
+	return model.WorkloadEndpointKey{
+		Hostname:       hostname,
+		OrchestratorID: "k8s",
+		WorkloadID:     fmt.Sprint("wl-", n),
+		EndpointID:     "eth0",
+	}
+}
+
+func scaleEndpoint(n int, app int) *model.WorkloadEndpoint {
+	ns := fmt.Sprint("ns-", n%scaleNumNamespaces)
+	return &model.WorkloadEndpoint{
+		State:    "active",
+		Name:     fmt.Sprint("cali", n),
+		IPv4Nets: []net.IPNet{mustParseNet(fmt.Sprintf("10.%d.%d.%d/32", n>>16&0xff, n>>8&0xff, n&0xff))},
+		Labels: map[string]string{
+			"projectcalico.org/namespace": ns,
+			"app":                         fmt.Sprint("app-", app),
+		},
+		ProfileIDs: []string{"kns." + ns},
+	}
+}
+
+func scalePolicy(n int) *model.Policy {
+	order := float64(n)
+	return &model.Policy{
+		Order:    &order,
+		Selector: fmt.Sprintf("app == 'app-%d'", n%scaleNumApps),
+		InboundRules: []model.Rule{
+			{Action: "allow", SrcSelector: fmt.Sprintf("app == 'app-%d'", (n+1)%scaleNumApps)},
+			{Action: "deny", SrcSelector: fmt.Sprintf("projectcalico.org/namespace == 'ns-%d'", n%scaleNumNamespaces)},
+		},
+		OutboundRules: []model.Rule{
+			{Action: "allow", DstSelector: fmt.Sprintf("app in {'app-%d', 'app-%d'}", (n+2)%scaleNumApps, (n+3)%scaleNumApps)},
+		},
+		Types: []string{"ingress", "egress"},
+	}
+}
+
+// synthesizeScaleUpdates returns the updates for a cluster with the given number of endpoints
+// and policies.
+func synthesizeScaleUpdates(numEndpoints, numPolicies int) []api.Update {
+	var updates []api.Update
+	for n := 0; n < scaleNumNamespaces; n++ {
+		key := model.ProfileRulesKey{ProfileKey: model.ProfileKey{Name: fmt.Sprint("kns.ns-", n)}}
+		updates = append(updates, api.Update{
+			KVPair:     model.KVPair{Key: key, Value: &model.ProfileRules{}},
+			UpdateType: api.UpdateTypeKVNew,
+		})
+	}
+	for n := 0; n < numPolicies; n++ {
+		updates = append(updates, api.Update{
+			KVPair:     model.KVPair{Key: model.PolicyKey{Name: fmt.Sprint("pol-", n)}, Value: scalePolicy(n)},
+			UpdateType: api.UpdateTypeKVNew,
+		})
+	}
+	for n := 0; n < numEndpoints; n++ {
+		updates = append(updates, api.Update{
+			KVPair:     model.KVPair{Key: scaleEndpointKey(n), Value: scaleEndpoint(n, n%scaleNumApps)},
+			UpdateType: api.UpdateTypeKVNew,
+		})
+	}
+	return updates
+}
+
+type scaleCalcGraph struct {
+	calcGraph   *CalcGraph
+	eventBuf    *EventSequencer
+	numMessages int
+}
+
+func newScaleCalcGraph() *scaleCalcGraph {
+	s := &scaleCalcGraph{}
+	conf := config.New()
+	conf.FelixHostname = localHostname
+	s.eventBuf = NewEventSequencer(nil)
+	s.eventBuf.Callback = func(message interface{}) {
+		s.numMessages++
+	}
+	s.calcGraph = NewCalculationGraph(s.eventBuf, conf)
+	return s
+}
+
+func benchmarkCalcGraphStartup(b *testing.B, numEndpoints, numPolicies int) {
+	logLevel := log.GetLevel()
+	log.SetLevel(log.WarnLevel)
+	defer log.SetLevel(logLevel)
+
+	var totalTime time.Duration
+	var numMessages int
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		updates := synthesizeScaleUpdates(numEndpoints, numPolicies)
+		s := newScaleCalcGraph()
+		b.StartTimer()
+
+		start := time.Now()
+		s.calcGraph.AllUpdDispatcher.OnUpdates(updates)
+		s.calcGraph.AllUpdDispatcher.OnStatusUpdated(api.InSync)
+		s.eventBuf.Flush()
+		totalTime += time.Since(start)
+		numMessages += s.numMessages
+	}
+	b.ReportMetric(totalTime.Seconds()/float64(b.N), "secs-to-in-sync")
+	b.ReportMetric(float64(numMessages)/float64(b.N), "dataplane-msgs")
+}
+
+func benchmarkCalcGraphLabelChurn(b *testing.B, numEndpoints, numPolicies int) {
+	logLevel := log.GetLevel()
+	log.SetLevel(log.WarnLevel)
+	defer log.SetLevel(logLevel)
+
+	s := newScaleCalcGraph()
+	s.calcGraph.AllUpdDispatcher.OnUpdates(synthesizeScaleUpdates(numEndpoints, numPolicies))
+	s.calcGraph.AllUpdDispatcher.OnStatusUpdated(api.InSync)
+	s.eventBuf.Flush()
+
+	// Move local endpoints between apps, which changes both the policies that apply to them and
+	// the IP sets that they're in.
+	updates := make([]api.Update, b.N)
+	for i := range updates {
+		n := (i * 10) % numEndpoints
+		updates[i] = api.Update{
+			KVPair:     model.KVPair{Key: scaleEndpointKey(n), Value: scaleEndpoint(n, (n+i+1)%scaleNumApps)},
+			UpdateType: api.UpdateTypeKVUpdated,
+		}
+	}
+	s.numMessages = 0
+
+	b.ResetTimer()
+	for i := range updates {
+		s.calcGraph.AllUpdDispatcher.OnUpdate(updates[i])
+		s.eventBuf.Flush()
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(s.numMessages)/float64(b.N), "dataplane-msgs/op")
+}
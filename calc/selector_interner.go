@@ -0,0 +1,108 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calc
+
+import (
+	"github.com/projectcalico/libcalico-go/lib/backend/api"
+	"github.com/projectcalico/libcalico-go/lib/backend/model"
+
+	"github.com/projectcalico/felix/dispatcher"
+	"github.com/projectcalico/felix/stringutils"
+)
+
+// SelectorInterner deduplicates the selectors of policies and profiles as they enter the
+// calculation graph.  Generated policies (for example, one per namespace) tend to repeat the same
+// handful of selectors, and the calculation graph holds on to the policies and to the selectors
+// of their active rules.
+//
+// Like the EndpointInterner, it must be registered with the dispatcher before any other
+// receivers and it updates the policies in place.
+type SelectorInterner struct {
+	interner *stringutils.Interner
+	// resources holds the current value of each policy and profile so that we can release its
+	// selectors when it is updated or deleted.
+	resources map[model.Key]interface{}
+}
+
+func NewSelectorInterner() *SelectorInterner {
+	return &SelectorInterner{
+		interner:  stringutils.NewInterner(),
+		resources: map[model.Key]interface{}{},
+	}
+}
+
+func (s *SelectorInterner) RegisterWith(allUpdDispatcher *dispatcher.Dispatcher) {
+	allUpdDispatcher.Register(model.PolicyKey{}, s.OnUpdate)
+	allUpdDispatcher.Register(model.ProfileRulesKey{}, s.OnUpdate)
+}
+
+func (s *SelectorInterner) OnUpdate(update api.Update) (filterOut bool) {
+	old, haveOld := s.resources[update.Key]
+	if haveOld && old == update.Value {
+		// Same value again, it's already interned.
+		return
+	}
+	if update.Value != nil {
+		for _, sel := range resourceSelectors(update.Value) {
+			*sel = s.interner.Intern(*sel)
+		}
+	}
+	if haveOld {
+		for _, sel := range resourceSelectors(old) {
+			s.interner.Release(*sel)
+		}
+	}
+	if update.Value != nil {
+		s.resources[update.Key] = update.Value
+	} else {
+		delete(s.resources, update.Key)
+	}
+	return
+}
+
+// resourceSelectors returns pointers to the selectors of a policy or profile.
+func resourceSelectors(value interface{}) (selectors []*string) {
+	var inbound, outbound []model.Rule
+	switch v := value.(type) {
+	case *model.Policy:
+		selectors = append(selectors, &v.Selector)
+		inbound, outbound = v.InboundRules, v.OutboundRules
+	case *model.ProfileRules:
+		inbound, outbound = v.InboundRules, v.OutboundRules
+	}
+	for _, rules := range [][]model.Rule{inbound, outbound} {
+		for i := range rules {
+			selectors = append(selectors, ruleSelectors(&rules[i])...)
+		}
+	}
+	return
+}
+
+func ruleSelectors(r *model.Rule) []*string {
+	return []*string{
+		&r.SrcSelector,
+		&r.DstSelector,
+		&r.NotSrcSelector,
+		&r.NotDstSelector,
+		&r.OriginalSrcSelector,
+		&r.OriginalDstSelector,
+		&r.OriginalNotSrcSelector,
+		&r.OriginalNotDstSelector,
+		&r.OriginalSrcNamespaceSelector,
+		&r.OriginalDstNamespaceSelector,
+		&r.OriginalSrcServiceAccountSelector,
+		&r.OriginalDstServiceAccountSelector,
+	}
+}
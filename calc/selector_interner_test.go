@@ -0,0 +1,121 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calc
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/libcalico-go/lib/backend/api"
+	"github.com/projectcalico/libcalico-go/lib/backend/model"
+)
+
+// newTestPolicy returns a policy with freshly allocated selectors, as if it had just been decoded
+// from the datastore.
+func newTestPolicy(n int) *model.Policy {
+	return &model.Policy{
+		Selector: fmt.Sprint("projectcalico.org/namespace == 'namespace-", n%10, "'"),
+		InboundRules: []model.Rule{
+			{
+				Action:              "allow",
+				SrcSelector:         fmt.Sprint("app == 'app-", n%100, "'"),
+				OriginalSrcSelector: fmt.Sprint("app == 'app-", n%100, "'"),
+			},
+		},
+		OutboundRules: []model.Rule{
+			{Action: "allow", DstSelector: fmt.Sprint("has(projectcalico.org/namespace)")},
+		},
+	}
+}
+
+func testPolicyKey(n int) model.PolicyKey {
+	return model.PolicyKey{Tier: "default", Name: fmt.Sprint("policy-", n)}
+}
+
+var _ = Describe("SelectorInterner", func() {
+	var uut *SelectorInterner
+
+	BeforeEach(func() {
+		uut = NewSelectorInterner()
+	})
+
+	send := func(key model.Key, value interface{}) {
+		uut.OnUpdate(api.Update{KVPair: model.KVPair{Key: key, Value: value}})
+	}
+
+	It("should share selectors between policies and profiles without changing them", func() {
+		pol1 := newTestPolicy(0)
+		pol2 := newTestPolicy(10)
+		prof := &model.ProfileRules{InboundRules: newTestPolicy(0).InboundRules}
+		send(testPolicyKey(1), pol1)
+		send(testPolicyKey(2), pol2)
+		send(model.ProfileRulesKey{ProfileKey: model.ProfileKey{Name: "prof"}}, prof)
+		Expect(pol1).To(Equal(newTestPolicy(0)))
+		Expect(pol2).To(Equal(newTestPolicy(10)))
+		// The namespace selector, "has(...)" and the two app selectors.
+		Expect(uut.interner.Len()).To(Equal(4))
+	})
+
+	It("should release selectors when policies are updated and deleted", func() {
+		send(testPolicyKey(1), newTestPolicy(0))
+		send(testPolicyKey(1), newTestPolicy(1))
+		Expect(uut.interner.Len()).To(Equal(3))
+		send(testPolicyKey(1), nil)
+		Expect(uut.interner.Len()).To(Equal(0))
+		Expect(uut.resources).To(BeEmpty())
+	})
+})
+
+func BenchmarkPolicyHeapPlain10000(b *testing.B) {
+	benchmarkPolicyHeap(b, 10000, false)
+}
+
+func BenchmarkPolicyHeapInterned10000(b *testing.B) {
+	benchmarkPolicyHeap(b, 10000, true)
+}
+
+// benchmarkPolicyHeap reports the heap retained by numPolicies policies, with and without
+// interning.
+func benchmarkPolicyHeap(b *testing.B, numPolicies int, intern bool) {
+	var retained int64
+	for i := 0; i < b.N; i++ {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+
+		uut := NewSelectorInterner()
+		policies := make([]*model.Policy, numPolicies)
+		for n := range policies {
+			policies[n] = newTestPolicy(n)
+			if intern {
+				uut.OnUpdate(api.Update{KVPair: model.KVPair{
+					Key:   testPolicyKey(n),
+					Value: policies[n],
+				}})
+			}
+		}
+
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		retained += int64(after.HeapAlloc) - int64(before.HeapAlloc)
+		runtime.KeepAlive(policies)
+		runtime.KeepAlive(uut)
+	}
+	b.ReportMetric(float64(retained)/float64(b.N)/float64(numPolicies), "heap-bytes/policy")
+}
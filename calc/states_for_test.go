@@ -1065,6 +1065,30 @@ var vxlanWithWEPIPsAndWEP = vxlanWithWEPIPs.withKVUpdates(
 	},
 )
 
+// Gives the workload on remoteHost2 a floating IP, which should be routed to remoteHost2 too.
+var vxlanWithWEPIPsAndFloatingIP = vxlanWithWEPIPs.withKVUpdates(
+	KVPair{Key: remoteWlEpKey2, Value: &remoteWlEp1WithFloatingIP},
+).withName("VXLAN using WorkloadIPs and a WEP with a floating IP").withRoutes(
+	routeUpdateIPPoolVXLAN,
+	routeUpdateRemoteHost2,
+	proto.RouteUpdate{
+		Type:        proto.RouteType_REMOTE_WORKLOAD,
+		IpPoolType:  proto.IPPoolType_VXLAN,
+		Dst:         "10.0.0.5/32",
+		DstNodeName: remoteHostname2,
+		DstNodeIp:   remoteHost2IP.String(),
+		NatOutgoing: true,
+	},
+	proto.RouteUpdate{
+		Type:        proto.RouteType_REMOTE_WORKLOAD,
+		IpPoolType:  proto.IPPoolType_VXLAN,
+		Dst:         "10.0.1.5/32",
+		DstNodeName: remoteHostname2,
+		DstNodeIp:   remoteHost2IP.String(),
+		NatOutgoing: true,
+	},
+)
+
 // Add in another workload with the same IP, but on a different node - remoteHost1.
 // Since this new host sorts lower than the original, its should mask the route of the
 // WEP on the other node.
@@ -17,6 +17,7 @@ package calc
 import (
 	"crypto/sha1"
 	gonet "net"
+	"reflect"
 
 	"github.com/sirupsen/logrus"
 
@@ -26,6 +27,7 @@ import (
 	cnet "github.com/projectcalico/libcalico-go/lib/net"
 	"github.com/projectcalico/libcalico-go/lib/set"
 
+	"github.com/projectcalico/felix/config"
 	"github.com/projectcalico/felix/dispatcher"
 	"github.com/projectcalico/felix/proto"
 )
@@ -49,6 +51,9 @@ import (
 //
 // If a VTEP changes (e.g., due to a vxlan tunnel address changing), this component will treat
 // it as a delete followed by an add.
+//
+// If fabric planes are configured, each VTEP also carries the node's address on each plane, taken
+// from the addresses on its Node resource, so that the dataplane can fail over between planes.
 type VXLANResolver struct {
 	hostname  string
 	callbacks vxlanCallbacks
@@ -62,9 +67,24 @@ type VXLANResolver struct {
 	blockToRoutes             map[string]set.Set
 	vxlanPools                map[string]model.IPPool
 	useNodeResourceUpdates    bool
+	fabricPlanes              []*gonet.IPNet
+	nodeNameToPlaneIPs        map[string][]string
 }
 
-func NewVXLANResolver(hostname string, callbacks vxlanCallbacks, useNodeResourceUpdates bool) *VXLANResolver {
+func NewVXLANResolver(
+	hostname string,
+	callbacks vxlanCallbacks,
+	useNodeResourceUpdates bool,
+	fabricPlanes []config.FabricPlane,
+) *VXLANResolver {
+	var planeCIDRs []*gonet.IPNet
+	for _, plane := range fabricPlanes {
+		_, cidr, err := gonet.ParseCIDR(plane.CIDR)
+		if err != nil {
+			logrus.WithError(err).WithField("plane", plane).Panic("Failed to parse fabric plane CIDR")
+		}
+		planeCIDRs = append(planeCIDRs, cidr)
+	}
 	return &VXLANResolver{
 		hostname:                  hostname,
 		callbacks:                 callbacks,
@@ -75,6 +95,8 @@ func NewVXLANResolver(hostname string, callbacks vxlanCallbacks, useNodeResource
 		blockToRoutes:             map[string]set.Set{},
 		vxlanPools:                map[string]model.IPPool{},
 		useNodeResourceUpdates:    useNodeResourceUpdates,
+		fabricPlanes:              planeCIDRs,
+		nodeNameToPlaneIPs:        map[string][]string{},
 	}
 }
 
@@ -107,15 +129,55 @@ func (c *VXLANResolver) OnResourceUpdate(update api.Update) (_ bool) {
 			return
 		}
 
+		if c.onNodePlaneIPsUpdate(nodeName, node) && c.vtepSent(nodeName) &&
+			c.nodeNameToIPAddr[nodeName] == ipv4.String() {
+			// Only the node's plane addresses changed, resend the VTEP.
+			logCxt.Info("Node's fabric plane addresses changed, updating VTEP")
+			c.sendVTEPUpdate(nodeName)
+			return
+		}
 		c.onNodeIPUpdate(nodeName, ipv4.String())
 	} else {
 		delete(c.nodeNameToNode, nodeName)
+		delete(c.nodeNameToPlaneIPs, nodeName)
 		c.onRemoveNode(nodeName)
 	}
 
 	return
 }
 
+// onNodePlaneIPsUpdate finds the node's address on each fabric plane and returns whether any of
+// them changed.
+func (c *VXLANResolver) onNodePlaneIPsUpdate(nodeName string, node *apiv3.Node) bool {
+	if len(c.fabricPlanes) == 0 {
+		return false
+	}
+	var nodeIPs []gonet.IP
+	if ipv4, _, err := cnet.ParseCIDROrIP(node.Spec.BGP.IPv4Address); err == nil {
+		nodeIPs = append(nodeIPs, ipv4.IP)
+	}
+	for _, addr := range node.Spec.Addresses {
+		if nodeIP, _, err := cnet.ParseCIDROrIP(addr.Address); err == nil {
+			nodeIPs = append(nodeIPs, nodeIP.IP)
+		}
+	}
+	planeIPs := make([]string, len(c.fabricPlanes))
+	for i, plane := range c.fabricPlanes {
+		for _, nodeIP := range nodeIPs {
+			if nodeIP.To4() != nil && plane.Contains(nodeIP) {
+				planeIPs[i] = nodeIP.To4().String()
+				break
+			}
+		}
+	}
+	if reflect.DeepEqual(c.nodeNameToPlaneIPs[nodeName], planeIPs) {
+		return false
+	}
+	logrus.WithFields(logrus.Fields{"node": nodeName, "planeIPs": planeIPs}).Debug("Node's plane IPs updated")
+	c.nodeNameToPlaneIPs[nodeName] = planeIPs
+	return true
+}
+
 // OnHostIPUpdate gets called whenever a node IP address changes. On an add/update,
 // we need to check if there is a VTEP which is now valid, and trigger programming
 // of them to the data plane. On a delete, we need to withdraw the VTEP associated
@@ -259,6 +321,7 @@ func (c *VXLANResolver) sendVTEPUpdate(node string) bool {
 		ParentDeviceIp: parentDeviceIP,
 		Mac:            c.vtepMACForHost(node),
 		Ipv4Addr:       tunlAddr,
+		FabricPlaneIps: c.nodeNameToPlaneIPs[node],
 	}
 	c.callbacks.OnVTEPUpdate(vtep)
 	return true
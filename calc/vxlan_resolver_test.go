@@ -0,0 +1,86 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calc_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	apiv3 "github.com/projectcalico/libcalico-go/lib/apis/v3"
+	"github.com/projectcalico/libcalico-go/lib/backend/api"
+	"github.com/projectcalico/libcalico-go/lib/backend/model"
+
+	"github.com/projectcalico/felix/calc"
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/proto"
+)
+
+type vtepRecorder struct {
+	vteps map[string]*proto.VXLANTunnelEndpointUpdate
+}
+
+func (r *vtepRecorder) OnVTEPUpdate(update *proto.VXLANTunnelEndpointUpdate) {
+	r.vteps[update.Node] = update
+}
+
+func (r *vtepRecorder) OnVTEPRemove(node string) {
+	delete(r.vteps, node)
+}
+
+var _ = Describe("VXLANResolver fabric planes", func() {
+	var (
+		uut      *calc.VXLANResolver
+		recorder *vtepRecorder
+	)
+
+	BeforeEach(func() {
+		recorder = &vtepRecorder{vteps: map[string]*proto.VXLANTunnelEndpointUpdate{}}
+		uut = calc.NewVXLANResolver(localHostname, recorder, true, []config.FabricPlane{
+			{Interface: "eth0", CIDR: "10.1.0.0/16"},
+			{Interface: "eth1", CIDR: "10.2.0.0/16"},
+		})
+		uut.OnHostConfigUpdate(api.Update{KVPair: model.KVPair{
+			Key:   model.HostConfigKey{Hostname: remoteHostname, Name: "IPv4VXLANTunnelAddr"},
+			Value: "192.168.0.1",
+		}})
+	})
+
+	sendNode := func(addrs ...string) {
+		node := apiv3.NewNode()
+		node.Name = remoteHostname
+		node.Spec.BGP = &apiv3.NodeBGPSpec{IPv4Address: "10.1.0.2/16"}
+		for _, a := range addrs {
+			node.Spec.Addresses = append(node.Spec.Addresses, apiv3.NodeAddress{Address: a})
+		}
+		uut.OnResourceUpdate(api.Update{KVPair: model.KVPair{
+			Key:   model.ResourceKey{Kind: apiv3.KindNode, Name: remoteHostname},
+			Value: node,
+		}})
+	}
+
+	It("should send the node's address on each plane", func() {
+		sendNode("10.1.0.2", "10.2.0.2/16", "fd00::2")
+		Expect(recorder.vteps).To(HaveKey(remoteHostname))
+		Expect(recorder.vteps[remoteHostname].ParentDeviceIp).To(Equal("10.1.0.2"))
+		Expect(recorder.vteps[remoteHostname].FabricPlaneIps).To(Equal([]string{"10.1.0.2", "10.2.0.2"}))
+	})
+
+	It("should resend the VTEP when only the plane addresses change", func() {
+		sendNode()
+		Expect(recorder.vteps[remoteHostname].FabricPlaneIps).To(Equal([]string{"10.1.0.2", ""}))
+		sendNode("10.2.0.2")
+		Expect(recorder.vteps[remoteHostname].FabricPlaneIps).To(Equal([]string{"10.1.0.2", "10.2.0.2"}))
+	})
+})
@@ -0,0 +1,334 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package capture runs packet captures on local workload endpoints.  A capture selects workload
+// endpoints with a label selector and writes the packets on their interfaces, optionally
+// filtered by a tcpdump-style filter expression, to rotated pcap files under a host directory.
+//
+// Captures are controlled through the debug server: see Manager.ServeHTTP.
+package capture
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/bpf"
+
+	"github.com/projectcalico/libcalico-go/lib/selector"
+
+	"github.com/projectcalico/felix/proto"
+)
+
+const (
+	defaultMaxFileSize = 10 * 1024 * 1024
+	defaultMaxFiles    = 5
+
+	StateCapturing = "Capturing"
+	StateFinished  = "Finished"
+)
+
+var nameRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+
+// Spec describes a packet capture.
+type Spec struct {
+	// Selector selects the workload endpoints to capture on, using their labels.
+	Selector string `json:"selector"`
+	// Filter is an optional tcpdump-style filter expression.
+	Filter string `json:"filter,omitempty"`
+	// MaxFileSize is the size at which each interface's capture file is rotated; MaxFiles is the
+	// number of files kept per interface.
+	MaxFileSize int64 `json:"maxFileSize,omitempty"`
+	MaxFiles    int   `json:"maxFiles,omitempty"`
+	// Duration, if non-zero, stops the capture after the given time.
+	Duration Duration `json:"duration,omitempty"`
+}
+
+// Duration is a time.Duration that is represented in JSON as a string such as "5m".
+type Duration time.Duration
+
+// Status reports the state of a capture.
+type Status struct {
+	Name       string   `json:"name"`
+	Spec       Spec     `json:"spec"`
+	State      string   `json:"state"`
+	StartTime  string   `json:"startTime"`
+	Interfaces []string `json:"interfaces"`
+	Files      []string `json:"files"`
+}
+
+type endpointInfo struct {
+	iface  string
+	labels map[string]string
+}
+
+type ifaceCapture struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+type activeCapture struct {
+	spec      Spec
+	selector  selector.Selector
+	filter    []bpf.RawInstruction
+	state     string
+	startTime time.Time
+	timer     *time.Timer
+	ifaces    map[string]*ifaceCapture
+}
+
+// Manager runs the packet captures.  It implements the dataplane's Manager interface so that it
+// can be registered with the dataplane driver to receive workload endpoint updates.
+type Manager struct {
+	dir string
+
+	lock      sync.Mutex
+	endpoints map[proto.WorkloadEndpointID]endpointInfo
+	captures  map[string]*activeCapture
+	dirty     bool
+
+	// Shims for testing.
+	captureIface  func(iface string, filter []bpf.RawInstruction, w *rotatingWriter, stop <-chan struct{}) error
+	compileFilter func(expr string) ([]bpf.RawInstruction, error)
+	afterFunc     func(d time.Duration, f func()) *time.Timer
+	now           func() time.Time
+}
+
+// NewManager creates a Manager that writes capture files under dir/<capture name>/.
+func NewManager(dir string) *Manager {
+	return &Manager{
+		dir:           dir,
+		endpoints:     map[proto.WorkloadEndpointID]endpointInfo{},
+		captures:      map[string]*activeCapture{},
+		captureIface:  captureIface,
+		compileFilter: compileFilter,
+		afterFunc:     time.AfterFunc,
+		now:           time.Now,
+	}
+}
+
+func (m *Manager) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *proto.WorkloadEndpointUpdate:
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		m.endpoints[*msg.Id] = endpointInfo{iface: msg.Endpoint.Name, labels: msg.Endpoint.Labels}
+		m.dirty = true
+	case *proto.WorkloadEndpointRemove:
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		delete(m.endpoints, *msg.Id)
+		m.dirty = true
+	}
+}
+
+func (m *Manager) CompleteDeferredWork() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if !m.dirty {
+		return nil
+	}
+	for name := range m.captures {
+		m.reconcileLocked(name)
+	}
+	m.dirty = false
+	return nil
+}
+
+// Start starts (or restarts, with the new spec) the named capture.
+func (m *Manager) Start(name string, spec Spec) error {
+	if !nameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid capture name %q", name)
+	}
+	sel, err := selector.Parse(spec.Selector)
+	if err != nil {
+		return fmt.Errorf("invalid selector: %w", err)
+	}
+	var filter []bpf.RawInstruction
+	if spec.Filter != "" {
+		filter, err = m.compileFilter(spec.Filter)
+		if err != nil {
+			return err
+		}
+	}
+	if spec.MaxFileSize <= 0 {
+		spec.MaxFileSize = defaultMaxFileSize
+	}
+	if spec.MaxFiles <= 0 {
+		spec.MaxFiles = defaultMaxFiles
+	}
+
+	m.lock.Lock()
+	done := m.stopLocked(name)
+	delete(m.captures, name)
+	m.lock.Unlock()
+	for _, d := range done {
+		<-d
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	c := &activeCapture{
+		spec:      spec,
+		selector:  sel,
+		filter:    filter,
+		state:     StateCapturing,
+		startTime: m.now(),
+		ifaces:    map[string]*ifaceCapture{},
+	}
+	m.captures[name] = c
+	if spec.Duration > 0 {
+		c.timer = m.afterFunc(time.Duration(spec.Duration), func() {
+			m.lock.Lock()
+			defer m.lock.Unlock()
+			if m.captures[name] == c {
+				log.WithField("capture", name).Info("Packet capture reached its duration.")
+				c.state = StateFinished
+				m.reconcileLocked(name)
+			}
+		})
+	}
+	log.WithFields(log.Fields{"capture": name, "spec": spec}).Info("Starting packet capture.")
+	m.reconcileLocked(name)
+	return nil
+}
+
+// Stop stops the named capture and, if deleteFiles is set, deletes its files.  It waits for the
+// per-interface captures to close their files, so that the files are complete when it returns.
+func (m *Manager) Stop(name string, deleteFiles bool) error {
+	m.lock.Lock()
+	if _, ok := m.captures[name]; !ok {
+		m.lock.Unlock()
+		return os.ErrNotExist
+	}
+	done := m.stopLocked(name)
+	delete(m.captures, name)
+	m.lock.Unlock()
+
+	for _, d := range done {
+		<-d
+	}
+	if deleteFiles {
+		return os.RemoveAll(filepath.Join(m.dir, name))
+	}
+	return nil
+}
+
+func (m *Manager) stopLocked(name string) (done []chan struct{}) {
+	c := m.captures[name]
+	if c == nil {
+		return nil
+	}
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	for iface := range c.ifaces {
+		done = append(done, m.stopIfaceLocked(c, iface))
+	}
+	return done
+}
+
+// stopIfaceLocked signals the capture on the interface to stop.  It doesn't wait for it to do
+// so, to avoid blocking the dataplane; it returns a channel that is closed once it has.
+func (m *Manager) stopIfaceLocked(c *activeCapture, iface string) chan struct{} {
+	ic := c.ifaces[iface]
+	close(ic.stop)
+	delete(c.ifaces, iface)
+	return ic.done
+}
+
+// reconcileLocked starts and stops per-interface captures so that the capture is running on
+// exactly the interfaces of the endpoints that match its selector.
+func (m *Manager) reconcileLocked(name string) {
+	c := m.captures[name]
+	wanted := map[string]bool{}
+	if c.state == StateCapturing {
+		for _, ep := range m.endpoints {
+			if ep.iface != "" && c.selector.Evaluate(ep.labels) {
+				wanted[ep.iface] = true
+			}
+		}
+	}
+	for iface := range c.ifaces {
+		if !wanted[iface] {
+			log.WithFields(log.Fields{"capture": name, "iface": iface}).Info("Stopping capture on interface.")
+			m.stopIfaceLocked(c, iface)
+		}
+	}
+	for iface := range wanted {
+		if _, ok := c.ifaces[iface]; ok {
+			continue
+		}
+		log.WithFields(log.Fields{"capture": name, "iface": iface}).Info("Starting capture on interface.")
+		ic := &ifaceCapture{stop: make(chan struct{}), done: make(chan struct{})}
+		c.ifaces[iface] = ic
+		// Include the start time in the file names so that restarting the capture on an
+		// interface doesn't overwrite the earlier files.
+		prefix := iface + "_" + m.now().UTC().Format("20060102T150405")
+		w := newRotatingWriter(filepath.Join(m.dir, name), prefix, snapLen, c.spec.MaxFileSize, c.spec.MaxFiles)
+		go func(iface string) {
+			defer close(ic.done)
+			if err := m.captureIface(iface, c.filter, w, ic.stop); err != nil {
+				log.WithError(err).WithFields(log.Fields{"capture": name, "iface": iface}).Warn(
+					"Packet capture on interface failed.")
+			}
+		}(iface)
+	}
+}
+
+// Statuses returns the status of all captures, sorted by name.
+func (m *Manager) Statuses() []Status {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var statuses []Status
+	for name, c := range m.captures {
+		s := Status{
+			Name:       name,
+			Spec:       c.spec,
+			State:      c.state,
+			StartTime:  c.startTime.UTC().Format(time.RFC3339),
+			Interfaces: []string{},
+			Files:      m.filesLocked(name),
+		}
+		for iface := range c.ifaces {
+			s.Interfaces = append(s.Interfaces, iface)
+		}
+		sort.Strings(s.Interfaces)
+		statuses = append(statuses, s)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+func (m *Manager) filesLocked(name string) []string {
+	files := []string{}
+	entries, err := ioutil.ReadDir(filepath.Join(m.dir, name))
+	if err != nil {
+		return files
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			files = append(files, e.Name())
+		}
+	}
+	return files
+}
@@ -0,0 +1,271 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/bpf"
+
+	"github.com/projectcalico/felix/proto"
+)
+
+var _ = Describe("rotatingWriter", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "capture")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(dir)
+	})
+
+	It("should write a pcap header and records", func() {
+		w := newRotatingWriter(dir, "eth0", 100, 1024, 2)
+		Expect(w.WritePacket(time.Unix(10, 5000), []byte{1, 2, 3}, 60)).To(Succeed())
+		Expect(w.Close()).To(Succeed())
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, "eth0_0.pcap"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(HaveLen(pcapGlobalHeaderLen + pcapRecordHeaderLen + 3))
+		Expect(binary.LittleEndian.Uint32(data[0:4])).To(Equal(uint32(pcapMagic)))
+		Expect(binary.LittleEndian.Uint32(data[16:20])).To(Equal(uint32(100)))
+		Expect(binary.LittleEndian.Uint32(data[20:24])).To(Equal(uint32(pcapLinkTypeEth)))
+		rec := data[pcapGlobalHeaderLen:]
+		Expect(binary.LittleEndian.Uint32(rec[0:4])).To(Equal(uint32(10)))
+		Expect(binary.LittleEndian.Uint32(rec[4:8])).To(Equal(uint32(5)))
+		Expect(binary.LittleEndian.Uint32(rec[8:12])).To(Equal(uint32(3)))
+		Expect(binary.LittleEndian.Uint32(rec[12:16])).To(Equal(uint32(60)))
+		Expect(rec[16:]).To(Equal([]byte{1, 2, 3}))
+	})
+
+	It("should rotate files and keep at most maxFiles", func() {
+		w := newRotatingWriter(dir, "eth0", 100, pcapGlobalHeaderLen+2*(pcapRecordHeaderLen+10), 2)
+		for i := 0; i < 7; i++ {
+			Expect(w.WritePacket(time.Now(), make([]byte, 10), 10)).To(Succeed())
+		}
+		Expect(w.Close()).To(Succeed())
+
+		entries, err := ioutil.ReadDir(dir)
+		Expect(err).NotTo(HaveOccurred())
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		Expect(names).To(ConsistOf("eth0_2.pcap", "eth0_3.pcap"))
+	})
+})
+
+var _ = Describe("parseDecimalBPF", func() {
+	It("should parse tcpdump -ddd output", func() {
+		prog, err := parseDecimalBPF([]byte("2\n40 0 0 12\n6 0 0 65535\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(prog).To(Equal([]bpf.RawInstruction{
+			{Op: 40, K: 12},
+			{Op: 6, K: 65535},
+		}))
+	})
+
+	It("should reject a truncated program", func() {
+		_, err := parseDecimalBPF([]byte("3\n40 0 0 12\n"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject garbage", func() {
+		_, err := parseDecimalBPF([]byte("1\n40 0 zero 12\n"))
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Manager", func() {
+	var (
+		dir       string
+		m         *Manager
+		lock      sync.Mutex
+		running   map[string]bool
+		filters   map[string][]bpf.RawInstruction
+		afterFunc func()
+	)
+
+	isRunning := func() map[string]bool {
+		lock.Lock()
+		defer lock.Unlock()
+		r := map[string]bool{}
+		for k, v := range running {
+			if v {
+				r[k] = v
+			}
+		}
+		return r
+	}
+
+	addEndpoint := func(name, iface string, labels map[string]string) {
+		m.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id:       &proto.WorkloadEndpointID{OrchestratorId: "k8s", WorkloadId: name, EndpointId: "eth0"},
+			Endpoint: &proto.WorkloadEndpoint{Name: iface, Labels: labels},
+		})
+		Expect(m.CompleteDeferredWork()).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "capture")
+		Expect(err).NotTo(HaveOccurred())
+		running = map[string]bool{}
+		filters = map[string][]bpf.RawInstruction{}
+		afterFunc = nil
+
+		m = NewManager(dir)
+		m.captureIface = func(iface string, filter []bpf.RawInstruction, w *rotatingWriter, stop <-chan struct{}) error {
+			lock.Lock()
+			running[iface] = true
+			filters[iface] = filter
+			lock.Unlock()
+			Expect(w.WritePacket(time.Now(), []byte{1}, 1)).To(Succeed())
+			<-stop
+			lock.Lock()
+			running[iface] = false
+			lock.Unlock()
+			return w.Close()
+		}
+		m.compileFilter = func(expr string) ([]bpf.RawInstruction, error) {
+			return []bpf.RawInstruction{{Op: 6, K: uint32(len(expr))}}, nil
+		}
+		m.afterFunc = func(d time.Duration, f func()) *time.Timer {
+			afterFunc = f
+			return time.NewTimer(time.Hour)
+		}
+
+		addEndpoint("ns/web", "cali1", map[string]string{"app": "web"})
+		addEndpoint("ns/db", "cali2", map[string]string{"app": "db"})
+	})
+
+	AfterEach(func() {
+		for _, s := range m.Statuses() {
+			Expect(m.Stop(s.Name, false)).To(Succeed())
+		}
+		_ = os.RemoveAll(dir)
+	})
+
+	It("should capture on the interfaces of matching endpoints", func() {
+		Expect(m.Start("web", Spec{Selector: "app == 'web'", Filter: "tcp"})).To(Succeed())
+		Eventually(isRunning).Should(Equal(map[string]bool{"cali1": true}))
+		lock.Lock()
+		Expect(filters["cali1"]).To(Equal([]bpf.RawInstruction{{Op: 6, K: 3}}))
+		lock.Unlock()
+
+		statuses := m.Statuses()
+		Expect(statuses).To(HaveLen(1))
+		Expect(statuses[0].State).To(Equal(StateCapturing))
+		Expect(statuses[0].Interfaces).To(Equal([]string{"cali1"}))
+		Expect(statuses[0].Spec.MaxFiles).To(Equal(defaultMaxFiles))
+	})
+
+	It("should follow endpoint updates", func() {
+		Expect(m.Start("web", Spec{Selector: "app == 'web'"})).To(Succeed())
+		Eventually(isRunning).Should(Equal(map[string]bool{"cali1": true}))
+
+		addEndpoint("ns/web2", "cali3", map[string]string{"app": "web"})
+		Eventually(isRunning).Should(Equal(map[string]bool{"cali1": true, "cali3": true}))
+
+		m.OnUpdate(&proto.WorkloadEndpointRemove{
+			Id: &proto.WorkloadEndpointID{OrchestratorId: "k8s", WorkloadId: "ns/web", EndpointId: "eth0"},
+		})
+		Expect(m.CompleteDeferredWork()).To(Succeed())
+		Eventually(isRunning).Should(Equal(map[string]bool{"cali3": true}))
+	})
+
+	It("should stop the capture after its duration", func() {
+		Expect(m.Start("web", Spec{Selector: "app == 'web'", Duration: Duration(time.Minute)})).To(Succeed())
+		Eventually(isRunning).Should(Equal(map[string]bool{"cali1": true}))
+		Expect(afterFunc).NotTo(BeNil())
+		afterFunc()
+		Eventually(isRunning).Should(BeEmpty())
+		Expect(m.Statuses()[0].State).To(Equal(StateFinished))
+	})
+
+	It("should stop a capture and delete its files", func() {
+		Expect(m.Start("web", Spec{Selector: "all()"})).To(Succeed())
+		Eventually(isRunning).Should(HaveLen(2))
+		Expect(m.Stop("web", false)).To(Succeed())
+		Expect(isRunning()).To(BeEmpty())
+		Expect(filepath.Join(dir, "web")).To(BeADirectory())
+
+		Expect(m.Start("web", Spec{Selector: "all()"})).To(Succeed())
+		Expect(m.Stop("web", true)).To(Succeed())
+		Expect(filepath.Join(dir, "web")).NotTo(BeADirectory())
+
+		Expect(m.Stop("web", false)).To(MatchError(os.ErrNotExist))
+	})
+
+	It("should reject invalid captures", func() {
+		Expect(m.Start("Bad/Name", Spec{Selector: "all()"})).NotTo(Succeed())
+		Expect(m.Start("bad-selector", Spec{Selector: "app =="})).NotTo(Succeed())
+		Expect(m.Statuses()).To(BeEmpty())
+	})
+
+	Describe("HTTP API", func() {
+		do := func(method, path, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			rec := httptest.NewRecorder()
+			m.ServeHTTP(rec, req)
+			return rec
+		}
+
+		It("should start, list, download and stop captures", func() {
+			rec := do("PUT", URLPrefix+"web", `{"selector": "app == 'web'", "duration": "5m"}`)
+			Expect(rec.Code).To(Equal(http.StatusCreated))
+			Eventually(isRunning).Should(Equal(map[string]bool{"cali1": true}))
+
+			rec = do("GET", URLPrefix, "")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			var statuses []Status
+			Expect(json.Unmarshal(rec.Body.Bytes(), &statuses)).To(Succeed())
+			Expect(statuses).To(HaveLen(1))
+			Expect(statuses[0].Name).To(Equal("web"))
+			Expect(statuses[0].Spec.Duration).To(Equal(Duration(5 * time.Minute)))
+
+			Expect(do("DELETE", URLPrefix+"web", "").Code).To(Equal(http.StatusNoContent))
+			files := m.filesLocked("web")
+			Expect(files).To(HaveLen(1))
+			rec = do("GET", URLPrefix+"web/"+files[0], "")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Body.Len()).To(Equal(pcapGlobalHeaderLen + pcapRecordHeaderLen + 1))
+
+			Expect(do("DELETE", URLPrefix+"web", "").Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("should reject bad requests", func() {
+			Expect(do("PUT", URLPrefix+"web", `{"selector": 1}`).Code).To(Equal(http.StatusBadRequest))
+			Expect(do("PUT", URLPrefix+"web", `{"selector": "app =="}`).Code).To(Equal(http.StatusBadRequest))
+			Expect(do("GET", URLPrefix+"web/.hidden", "").Code).To(Equal(http.StatusNotFound))
+			Expect(do("POST", URLPrefix, "").Code).To(Equal(http.StatusNotFound))
+		})
+	})
+})
@@ -0,0 +1,94 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// URLPrefix is the path under which the Manager expects to be served.
+const URLPrefix = "/debug/captures/"
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// ServeHTTP implements the capture API:
+//
+//   GET    /debug/captures/                 lists the captures and their status, as JSON.
+//   PUT    /debug/captures/<name>           starts a capture; the body is a JSON Spec.
+//   DELETE /debug/captures/<name>           stops a capture; add ?deleteFiles=true to delete
+//                                           its files too.
+//   GET    /debug/captures/<name>/<file>    downloads one of the capture's pcap files.
+func (m *Manager) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, URLPrefix), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "" && req.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(m.Statuses()); err != nil {
+			log.WithError(err).Warn("Failed to write capture statuses.")
+		}
+	case len(parts) == 1 && parts[0] != "" && req.Method == http.MethodPut:
+		var spec Spec
+		if err := json.NewDecoder(req.Body).Decode(&spec); err != nil {
+			http.Error(w, "invalid capture spec: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := m.Start(parts[0], spec); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	case len(parts) == 1 && parts[0] != "" && req.Method == http.MethodDelete:
+		err := m.Stop(parts[0], req.URL.Query().Get("deleteFiles") == "true")
+		if os.IsNotExist(err) {
+			http.NotFound(w, req)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 2 && req.Method == http.MethodGet:
+		if !nameRegexp.MatchString(parts[0]) || parts[1] == "" || strings.HasPrefix(parts[1], ".") {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.tcpdump.pcap")
+		http.ServeFile(w, req, filepath.Join(m.dir, parts[0], parts[1]))
+	default:
+		http.Error(w, "Not found or method not allowed", http.StatusNotFound)
+	}
+}
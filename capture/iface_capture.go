@@ -0,0 +1,158 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	snapLen = 65535
+	// readTimeout bounds how long it takes a capture to notice that it has been stopped.
+	readTimeout = time.Second
+)
+
+// compileFilter compiles a tcpdump-style filter expression to a classic BPF program for
+// Ethernet interfaces.  Felix doesn't link against libpcap so it uses the tcpdump binary to do
+// the compilation.  The "--" stops tcpdump from taking an expression that starts with "-" as
+// options.
+var compileFilter = func(expr string) ([]bpf.RawInstruction, error) {
+	out, err := exec.Command("tcpdump", "-ddd", "-y", "EN10MB", "--", expr).Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("failed to compile filter %q: %s", expr, strings.TrimSpace(string(ee.Stderr)))
+		}
+		return nil, fmt.Errorf("failed to compile filter %q: %w", expr, err)
+	}
+	return parseDecimalBPF(out)
+}
+
+// parseDecimalBPF parses the output of "tcpdump -ddd": the number of instructions followed by
+// one "<code> <jt> <jf> <k>" line per instruction.
+func parseDecimalBPF(out []byte) ([]bpf.RawInstruction, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("empty BPF program")
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+	if err != nil {
+		return nil, fmt.Errorf("bad BPF instruction count: %w", err)
+	}
+	var prog []bpf.RawInstruction
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("bad BPF instruction %q", scanner.Text())
+		}
+		var vals [4]uint64
+		for i, f := range fields {
+			vals[i], err = strconv.ParseUint(f, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("bad BPF instruction %q: %w", scanner.Text(), err)
+			}
+		}
+		prog = append(prog, bpf.RawInstruction{
+			Op: uint16(vals[0]),
+			Jt: uint8(vals[1]),
+			Jf: uint8(vals[2]),
+			K:  uint32(vals[3]),
+		})
+	}
+	if len(prog) != n {
+		return nil, fmt.Errorf("expected %d BPF instructions, got %d", n, len(prog))
+	}
+	return prog, nil
+}
+
+// captureIface captures packets on the named interface until stop is closed, writing them to w.
+func captureIface(iface string, filter []bpf.RawInstruction, w *rotatingWriter, stop <-chan struct{}) error {
+	defer func() {
+		if err := w.Close(); err != nil {
+			log.WithError(err).WithField("iface", iface).Warn("Failed to close capture file.")
+		}
+	}()
+	ifc, err := net.InterfaceByName(iface)
+	if err != nil {
+		return err
+	}
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, int(htons(unix.ETH_P_ALL)))
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	if len(filter) > 0 {
+		sockFilter := make([]unix.SockFilter, len(filter))
+		for i, ins := range filter {
+			sockFilter[i] = unix.SockFilter{Code: ins.Op, Jt: ins.Jt, Jf: ins.Jf, K: ins.K}
+		}
+		prog := unix.SockFprog{Len: uint16(len(sockFilter)), Filter: &sockFilter[0]}
+		if err := unix.SetsockoptSockFprog(fd, unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, &prog); err != nil {
+			return err
+		}
+	}
+	if err := unix.Bind(fd, &unix.SockaddrLinklayer{Protocol: htons(unix.ETH_P_ALL), Ifindex: ifc.Index}); err != nil {
+		return err
+	}
+	tv := unix.NsecToTimeval(readTimeout.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		return err
+	}
+
+	buf := make([]byte, snapLen)
+	for {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+		// MSG_TRUNC makes recvfrom return the full length of the packet, even if it was
+		// truncated to fit the buffer.
+		n, _, err := unix.Recvfrom(fd, buf, unix.MSG_TRUNC)
+		if err == unix.EAGAIN || err == unix.EINTR {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+		captured := n
+		if captured > len(buf) {
+			captured = len(buf)
+		}
+		if err := w.WritePacket(time.Now(), buf[:captured], n); err != nil {
+			return err
+		}
+	}
+}
+
+func htons(v uint16) uint16 {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	return *(*uint16)(unsafe.Pointer(&b[0]))
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	pcapMagic        = 0xa1b2c3d4
+	pcapVersionMajor = 2
+	pcapVersionMinor = 4
+	pcapLinkTypeEth  = 1
+
+	pcapGlobalHeaderLen = 24
+	pcapRecordHeaderLen = 16
+)
+
+// rotatingWriter writes packets to a series of pcap files named <prefix>_<n>.pcap in dir.  When
+// the current file would exceed maxFileSize, it starts a new file, deleting the oldest file if
+// there would otherwise be more than maxFiles.
+type rotatingWriter struct {
+	dir         string
+	prefix      string
+	snapLen     int
+	maxFileSize int64
+	maxFiles    int
+
+	file  *os.File
+	buf   *bufio.Writer
+	size  int64
+	seq   int
+	files []string
+}
+
+func newRotatingWriter(dir, prefix string, snapLen int, maxFileSize int64, maxFiles int) *rotatingWriter {
+	return &rotatingWriter{
+		dir:         dir,
+		prefix:      prefix,
+		snapLen:     snapLen,
+		maxFileSize: maxFileSize,
+		maxFiles:    maxFiles,
+	}
+}
+
+// WritePacket writes one packet record.  data is the captured part of the packet, origLen its
+// length on the wire.
+func (w *rotatingWriter) WritePacket(ts time.Time, data []byte, origLen int) error {
+	recordLen := int64(pcapRecordHeaderLen + len(data))
+	if w.file == nil || (w.size+recordLen > w.maxFileSize && w.size > pcapGlobalHeaderLen) {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	var hdr [pcapRecordHeaderLen]byte
+	binary.LittleEndian.PutUint32(hdr[0:4], uint32(ts.Unix()))
+	binary.LittleEndian.PutUint32(hdr[4:8], uint32(ts.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(hdr[8:12], uint32(len(data)))
+	binary.LittleEndian.PutUint32(hdr[12:16], uint32(origLen))
+	if _, err := w.buf.Write(hdr[:]); err != nil {
+		return err
+	}
+	if _, err := w.buf.Write(data); err != nil {
+		return err
+	}
+	w.size += recordLen
+	return nil
+}
+
+// Flush flushes buffered packets to the current file.
+func (w *rotatingWriter) Flush() error {
+	if w.buf == nil {
+		return nil
+	}
+	return w.buf.Flush()
+}
+
+func (w *rotatingWriter) rotate() error {
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(w.dir, 0700); err != nil {
+		return err
+	}
+	name := filepath.Join(w.dir, fmt.Sprintf("%s_%d.pcap", w.prefix, w.seq))
+	w.seq++
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	w.file = f
+	w.buf = bufio.NewWriter(f)
+	w.files = append(w.files, name)
+	for len(w.files) > w.maxFiles {
+		_ = os.Remove(w.files[0])
+		w.files = w.files[1:]
+	}
+
+	var hdr [pcapGlobalHeaderLen]byte
+	binary.LittleEndian.PutUint32(hdr[0:4], pcapMagic)
+	binary.LittleEndian.PutUint16(hdr[4:6], pcapVersionMajor)
+	binary.LittleEndian.PutUint16(hdr[6:8], pcapVersionMinor)
+	binary.LittleEndian.PutUint32(hdr[16:20], uint32(w.snapLen))
+	binary.LittleEndian.PutUint32(hdr[20:24], pcapLinkTypeEth)
+	_, err = w.buf.Write(hdr[:])
+	w.size = pcapGlobalHeaderLen
+	return err
+}
+
+// Close flushes and closes the current file, if any.
+func (w *rotatingWriter) Close() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.buf.Flush()
+	if cErr := w.file.Close(); err == nil {
+		err = cErr
+	}
+	w.file = nil
+	w.buf = nil
+	return err
+}
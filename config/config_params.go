@@ -157,6 +157,20 @@ type Config struct {
 	// Configuration parameters.
 	UseInternalDataplaneDriver bool   `config:"bool;true"`
 	DataplaneDriver            string `config:"file(must-exist,executable);calico-iptables-plugin;non-zero,die-on-fail,skip-default-validation"`
+	// DataplaneDriverGRPCAddress, if set, tells Felix to connect to an external dataplane driver
+	// that implements the DataplaneDriverV1 gRPC API at the given unix socket path or host:port,
+	// instead of starting DataplaneDriver as a child process.
+	DataplaneDriverGRPCAddress         string        `config:"string;"`
+	DataplaneDriverHealthCheckInterval time.Duration `config:"seconds;10"`
+	// SimulatedDataplaneEnabled replaces the dataplane driver with an in-memory model of the IP
+	// sets, policy chains, endpoints and routes that Felix would program, including the iptables
+	// chains and kernel IP sets that the Linux dataplane would render for them.  It needs no
+	// privileges, so it can be used to check the effect of policy changes in CI.  The model is
+	// written, as JSON, to SimulatedDataplaneStateFile whenever it changes, and is available
+	// from the debug server at /debug/state/sim-dataplane.  Only the policy and profile chains are
+	// modelled, not the static or per-endpoint chains that lead to them.
+	SimulatedDataplaneEnabled   bool   `config:"bool;false"`
+	SimulatedDataplaneStateFile string `config:"string;"`
 
 	// Wireguard configuration
 	WireguardEnabled             bool   `config:"bool;false"`
@@ -164,6 +178,10 @@ type Config struct {
 	WireguardRoutingRulePriority int    `config:"int;99"`
 	WireguardInterfaceName       string `config:"iface-param;wireguard.cali;non-zero"`
 	WireguardMTU                 int    `config:"int;0"`
+	// WireguardDSCP sets the DSCP of outgoing WireGuard packets.  WireGuard can't inherit the
+	// DSCP of the inner packet, which is encrypted, so this is a fixed value; 0 (the default) leaves
+	// the packets unmarked.
+	WireguardDSCP int `config:"int(0,63);0"`
 
 	BPFEnabled                         bool           `config:"bool;false"`
 	BPFDisableUnprivileged             bool           `config:"bool;true"`
@@ -175,6 +193,11 @@ type Config struct {
 	BPFKubeProxyMinSyncPeriod          time.Duration  `config:"seconds;1"`
 	BPFKubeProxyEndpointSlicesEnabled  bool           `config:"bool;false"`
 	BPFExtToServiceConnmark            int            `config:"int;0"`
+	BPFNATBackendSelection             string         `config:"oneof(Random,RoundRobin,Maglev);Random;non-zero"`
+	// BPFHostPortsEnabled makes Felix forward the hostPorts of local pods in the BPF NAT maps, so
+	// that they work with connect-time load balancing.  Disable the portmap CNI plugin when
+	// enabling it.  A hostPort that clashes with a node port is ignored.
+	BPFHostPortsEnabled bool `config:"bool;false"`
 
 	// DebugBPFCgroupV2 controls the cgroup v2 path that we apply the connect-time load balancer to.  Most distros
 	// are configured for cgroup v1, which prevents all but hte root cgroup v2 from working so this is only useful
@@ -186,6 +209,42 @@ type Config struct {
 
 	DatastoreType string `config:"oneof(kubernetes,etcdv3);etcdv3;non-zero,die-on-fail,local"`
 
+	// After a datastore watch fails, the re-list of that resource is delayed by a random amount
+	// up to DatastoreResyncBaseDelay, doubling on each consecutive failure up to
+	// DatastoreResyncMaxDelay.  This spreads out the load on the datastore when many Felix
+	// instances lose their watches at once.  Setting DatastoreResyncMaxDelay to 0 disables
+	// the delay.
+	DatastoreResyncBaseDelay time.Duration `config:"seconds;1"`
+	DatastoreResyncMaxDelay  time.Duration `config:"seconds;10"`
+	// DatastoreRecordFile, if set, makes Felix record the datastore updates that it receives, with
+	// their timing, to the given file, which must not already exist.  DatastoreReplayFile, if set,
+	// makes Felix play back such a recording instead of syncing with the datastore or Typha.  The
+	// datastore connection is still used to load Felix's initial configuration and to report
+	// status.
+	DatastoreRecordFile string `config:"string;"`
+	DatastoreReplayFile string `config:"string;"`
+
+	// When using the Kubernetes datastore without Typha, KubernetesPodInformerEnabled makes Felix
+	// watch pods through a shared informer, which can be restricted to pods matching
+	// KubernetesPodInformerLabelSelector.  KubernetesPodInformerLocalNodeOnly further restricts it
+	// to pods on this node; that is only safe if no policy uses a selector to match pods on
+	// other nodes, since Felix won't know about them.
+	KubernetesPodInformerEnabled       bool          `config:"bool;false"`
+	KubernetesPodInformerResyncPeriod  time.Duration `config:"seconds;0"`
+	KubernetesPodInformerLocalNodeOnly bool          `config:"bool;false"`
+	KubernetesPodInformerLabelSelector string        `config:"string;;"`
+
+	// If StartupResyncSlots is non-zero, Felix instances take turns to do their expensive initial
+	// resync, so that, after a cluster-wide upgrade, at most StartupResyncSlots of them load the
+	// API server and their own nodes at once.  Each slot is a Kubernetes Lease in
+	// StartupResyncNamespace.  Felix waits up to StartupResyncMaxWait for a slot and then holds it
+	// until it is ready, or for at most StartupResyncMaxHold.  Requires the Kubernetes datastore
+	// and permission to manage leases in the namespace.
+	StartupResyncSlots     int           `config:"int(0,1000);0"`
+	StartupResyncNamespace string        `config:"string;kube-system;non-zero"`
+	StartupResyncMaxWait   time.Duration `config:"seconds;600"`
+	StartupResyncMaxHold   time.Duration `config:"seconds;300"`
+
 	FelixHostname string `config:"hostname;;local,non-zero"`
 
 	EtcdAddr      string   `config:"authority;127.0.0.1:2379;local"`
@@ -201,6 +260,13 @@ type Config struct {
 	TyphaReadTimeout    time.Duration `config:"seconds;30;local"`
 	TyphaWriteTimeout   time.Duration `config:"seconds;10;local"`
 
+	// If TyphaRebalanceInterval is non-zero, Felix periodically checks the load on each Typha
+	// (using the typha_connections_active metric, read from TyphaLoadHintsPort) and moves to a
+	// less-loaded Typha if its own exceeds the average by more than TyphaRebalanceThreshold.
+	TyphaRebalanceInterval  time.Duration `config:"seconds;0;local"`
+	TyphaRebalanceThreshold float64       `config:"float;0.2;local"`
+	TyphaLoadHintsPort      int           `config:"int(0,65535);9093;local"`
+
 	// Client-side TLS config for Felix's communication with Typha.  If any of these are
 	// specified, they _all_ must be - except that either TyphaCN or TyphaURISAN may be left
 	// unset.  Felix will then initiate a secure (TLS) connection to Typha.  Typha must present
@@ -212,11 +278,19 @@ type Config struct {
 	TyphaCN       string `config:"string;;local"`
 	TyphaURISAN   string `config:"string;;local"`
 
+	// If TyphaTLSReloadInterval is non-zero, Felix checks the Typha TLS files for changes at
+	// that interval and, when they change, reconnects to Typha using the new certificate.
+	TyphaTLSReloadInterval time.Duration `config:"seconds;0;local"`
+
 	Ipv6Support bool `config:"bool;true"`
 
 	IptablesBackend                    string            `config:"oneof(legacy,nft,auto);auto"`
 	RouteRefreshInterval               time.Duration     `config:"seconds;90"`
-	InterfaceRefreshInterval           time.Duration     `config:"seconds;90"`
+	InterfaceRefreshInterval           time.Duration     `config:"seconds;0"`
+	InterfaceFlapDampingWindow         time.Duration     `config:"seconds;0"`
+	SysctlRefreshInterval              time.Duration     `config:"seconds;90"`
+	SysctlOverrides                    map[string]string `config:"sysctl-list;;"`
+	InterfaceRPFModes                  []RPFModeOverride `config:"rpf-mode-list;;"`
 	DeviceRouteSourceAddress           net.IP            `config:"ipv4;"`
 	DeviceRouteProtocol                int               `config:"int;3"`
 	RemoveExternalRoutes               bool              `config:"bool;true"`
@@ -229,8 +303,46 @@ type Config struct {
 	IpsetsRefreshInterval              time.Duration     `config:"seconds;10"`
 	MaxIpsetSize                       int               `config:"int;1048576;non-zero"`
 	XDPRefreshInterval                 time.Duration     `config:"seconds;90"`
-
-	PolicySyncPathPrefix string `config:"file;;"`
+	// IptablesHookChains lists user-owned chains that Felix jumps to at fixed points in its own
+	// chains; see HookChain.
+	IptablesHookChains []HookChain `config:"hook-chain-list;;"`
+	// BackgroundResyncMaxCPUPressure, if non-zero, makes Felix defer its periodic iptables, IP set
+	// and route refreshes, by up to one refresh interval, while the CPU pressure (the percentage
+	// of time, averaged over 10s, that tasks in Felix's cgroup are stalled waiting for CPU) is
+	// above the given value.  That leaves the CPU for programming updates on busy nodes.  Needs a
+	// kernel with pressure stall information (4.20+).
+	BackgroundResyncMaxCPUPressure float64 `config:"float;0"`
+	// IptablesOtherBackendCleanupEnabled makes Felix remove its chains and rules from the
+	// iptables backend (legacy or nft) that it isn't using.  They are typically left behind when
+	// an OS upgrade switches the backend and, since both rulesets apply to traffic, they can make
+	// stale policy look as if it's still in force.
+	IptablesOtherBackendCleanupEnabled bool `config:"bool;true"`
+	// IptablesIPSetInlineMaxMembers is the largest IP set that Felix expands into CIDR matches in
+	// policy and in its own rules when iptables can't match on IP sets, typically because the
+	// kernel lacks the xt_set module.  Rules that use bigger sets fail closed: deny rules match
+	// more traffic and other rules are left out.  The sets of host IPs that the rules allowing
+	// IPIP and VXLAN traffic from other hosts use are always expanded, however big they are.
+	IptablesIPSetInlineMaxMembers int `config:"int;32"`
+
+	// DataplaneApplyThrottleInterval and DataplaneApplyThrottleBurst limit how often Felix applies
+	// updates to the dataplane: it applies at most DataplaneApplyThrottleBurst batches of updates
+	// in a row and then one per DataplaneApplyThrottleInterval.  DataplaneMaxBatchSize limits how
+	// many updates from the calculation graph Felix gathers into each batch.
+	DataplaneApplyThrottleInterval time.Duration `config:"millis;100;non-zero"`
+	DataplaneApplyThrottleBurst    int           `config:"int(1,1000);10;non-zero"`
+	DataplaneMaxBatchSize          int           `config:"int(1,100000);100;non-zero"`
+	// DataplaneApplyDebounceInterval, if non-zero, makes Felix wait for that long after the first
+	// update before it applies a batch, so that it can gather more updates into the batch.  Endpoint
+	// deletions and policies with deny rules skip the debounce interval and the throttle.
+	DataplaneApplyDebounceInterval time.Duration `config:"millis;0"`
+
+	PolicySyncPathPrefix             string   `config:"file;;"`
+	PolicySyncAllowedServiceAccounts []string `config:"service-account-list;;"`
+	// PolicySyncAppProtocolHintsEnabled makes Felix watch EndpointSlices (Kubernetes only) and
+	// add the appProtocols of the Service ports that each pod backs to the WorkloadEndpoints that
+	// it sends to policy sync clients.  Felix needs permission to list and watch
+	// discovery.k8s.io/v1 endpointslices.
+	PolicySyncAppProtocolHintsEnabled bool `config:"bool;false"`
 
 	NetlinkTimeoutSecs time.Duration `config:"seconds;10"`
 
@@ -242,6 +354,23 @@ type Config struct {
 	InterfacePrefix  string           `config:"iface-list;cali;non-zero,die-on-fail"`
 	InterfaceExclude []*regexp.Regexp `config:"iface-list-regexp;kube-ipvs0"`
 
+	// AutoHostEndpointInterfaces makes Felix protect the host interfaces that match one of these
+	// names or regexps with an implicit host endpoint, so that nodes can be protected without
+	// creating HostEndpoint resources.  Interfaces that an explicit host endpoint applies to are
+	// left to that host endpoint.  Policies select the implicit host endpoint by its
+	// AutoHostEndpointLabel label.
+	AutoHostEndpointInterfaces []*regexp.Regexp `config:"iface-list-regexp;"`
+	// AutoHostEndpointProfile is the profile of the implicit host endpoint; for example, a
+	// profile that allows all traffic so that only policy restricts it.  If empty, traffic that
+	// no policy allows is denied.
+	AutoHostEndpointProfile string `config:"string;"`
+	// NodeSelectorIPSetsEnabled makes policy selectors match nodes as well as endpoints, using
+	// the node's labels and addresses, including its tunnel addresses.  Each node also has the
+	// projectcalico.org/node-endpoint label, set to its name.  Only selectors that refer to that
+	// label match nodes, for example "has(projectcalico.org/node-endpoint) && zone == 'a'", so
+	// enabling this doesn't change what existing selectors, such as all(), match.
+	NodeSelectorIPSetsEnabled bool `config:"bool;false"`
+
 	ChainInsertMode             string `config:"oneof(insert,append);insert;non-zero,die-on-fail"`
 	DefaultEndpointToHostAction string `config:"oneof(DROP,RETURN,ACCEPT);DROP;non-zero,die-on-fail"`
 	IptablesFilterAllowAction   string `config:"oneof(ACCEPT,RETURN);ACCEPT;non-zero,die-on-fail"`
@@ -254,25 +383,226 @@ type Config struct {
 	LogSeverityScreen string `config:"oneof(DEBUG,INFO,WARNING,ERROR,FATAL);INFO"`
 	LogSeveritySys    string `config:"oneof(DEBUG,INFO,WARNING,ERROR,FATAL);INFO"`
 
+	// DataplaneCommandCaptureDir, if set, is a directory to which Felix writes the full input and
+	// output of each failed iptables-restore, ipset restore and conntrack command, so that
+	// transient programming failures can be analysed after the fact.  Only the most recent
+	// DataplaneCommandCaptureMaxFiles captures are kept.
+	DataplaneCommandCaptureDir      string `config:"file;;"`
+	DataplaneCommandCaptureMaxFiles int    `config:"int(1,10000);20"`
+
 	VXLANEnabled        bool   `config:"bool;false"`
 	VXLANPort           int    `config:"int;4789"`
 	VXLANVNI            int    `config:"int;4096"`
 	VXLANMTU            int    `config:"int;0"`
 	IPv4VXLANTunnelAddr net.IP `config:"ipv4;"`
 	VXLANTunnelMACAddr  string `config:"string;"`
+	// VXLANFabricPlanes lists the fabric planes of a dual-ToR (or multi-plane) network, in order
+	// of preference.  It is a comma-separated list of "<interface>=<cidr>" items, for example
+	// "eth0=10.1.0.0/16,eth1=10.2.0.0/16", giving each plane's local uplink interface and the
+	// CIDR of the node addresses on that plane.  Felix learns each node's plane addresses from the
+	// addresses on its Node resource and tunnels VXLAN traffic to each node over the most preferred
+	// plane whose local uplink is up and on which that node answers probes (see
+	// VXLANFabricPlaneProbePort), failing over to the next plane when either check fails.
+	// Requires node resource updates (for example, the Kubernetes datastore).
+	VXLANFabricPlanes []FabricPlane `config:"fabric-plane-list;"`
+	// VXLANFabricPlaneProbePort is the UDP port that Felix uses to check that each remote node
+	// is reachable on each of its fabric plane addresses.  Felix only tunnels to a remote node
+	// over a plane on which the node has answered within VXLANFabricPlaneProbeTimeout, so that
+	// both nodes move off a plane that is broken between them, even when their uplinks are up.
+	// The port must be open between nodes.  Set to 0 to disable probing, in which case failover
+	// only depends on the local uplinks.
+	VXLANFabricPlaneProbePort     int           `config:"int(0,65535);9097"`
+	VXLANFabricPlaneProbeInterval time.Duration `config:"seconds;1"`
+	VXLANFabricPlaneProbeTimeout  time.Duration `config:"seconds;3"`
+	// VXLANMulticastGroup, if set, is the underlay multicast group of the VXLAN device.  Felix
+	// still programs a forwarding entry for each remote VTEP; the group carries the broadcast
+	// and multicast frames, including those to the groups in MulticastGroupRoutes, to all nodes.
+	// If it isn't set and MulticastEnabled is, Felix adds a flood entry for each remote VTEP
+	// instead, so that the device copies those frames to every node.
+	VXLANMulticastGroup net.IP `config:"ipv4;"`
 
 	IpInIpEnabled    bool   `config:"bool;false"`
 	IpInIpMtu        int    `config:"int;0"`
 	IpInIpTunnelAddr net.IP `config:"ipv4;"`
 
+	// IPIPDSCP and VXLANDSCP set the DSCP of the outer header of IPIP and VXLAN packets to
+	// "inherit", to copy the inner packet's DSCP, or to a fixed value from 0 to 63.  The kernel
+	// always carries ECN across the tunnel.
+	IPIPDSCP  TunnelDSCP `config:"tunnel-dscp;0"`
+	VXLANDSCP TunnelDSCP `config:"tunnel-dscp;0"`
+
+	// MulticastEnabled lets workloads use multicast: Felix accepts IGMP (and, in iptables mode,
+	// MLD) from workloads, whatever their policy, and, if they are non-zero, forces the IGMP and
+	// MLD versions of workload interfaces to MulticastIGMPVersion and MulticastMLDVersion.  Policy still
+	// applies to the multicast traffic itself, which is relayed between interfaces by a
+	// multicast routing daemon (such as smcroute or pimd), not by Felix.
+	MulticastEnabled     bool `config:"bool;false"`
+	MulticastIGMPVersion int  `config:"int(0,3);0"`
+	MulticastMLDVersion  int  `config:"int(0,2);0"`
+	// MulticastGroupRoutes lists IPv4 multicast group CIDRs that Felix routes over the VXLAN
+	// device, so that traffic to those groups that the host sends, or that a multicast routing
+	// daemon relays by following the routing table, reaches the other nodes.
+	MulticastGroupRoutes []string `config:"cidr-list;"`
+
 	// Knobs provided to explicitly control whether we add rules to drop encap traffic
 	// from workloads. We always add them unless explicitly requested not to add them.
 	AllowVXLANPacketsFromWorkloads bool `config:"bool;false"`
 	AllowIPIPPacketsFromWorkloads  bool `config:"bool;false"`
 
+	// WorkloadProxyNeighbors adds proxy ARP/NDP entries to the interfaces of selected local
+	// workloads so that the host answers neighbor requests for the given IPs on their links; for
+	// example, for the gateway address that a bridged VM expects.  It is a semicolon-separated
+	// list of "<selector>=<ip>[,<ip>...]" items, for example
+	// "kubevirt.io == 'virt-launcher'=169.254.1.1,fe80::1".  Felix owns the proxy entries on
+	// workload interfaces: it removes any entries that aren't configured.
+	WorkloadProxyNeighbors []ProxyNeighborRule `config:"proxy-neighbor-list;"`
+
+	// WorkloadExtraRoutes routes additional CIDRs to selected local workloads, via the workload's
+	// own IP; for example, the networks behind a VM that runs in a pod.  It is a semicolon-separated
+	// list of "<selector>=<cidr>[,<cidr>...]" items, for example
+	// "projectcalico.org/namespace == 'vms' && vm == 'router'=10.65.0.0/24,fd00:65::/64".  Since
+	// pod owners control their pods' labels, selectors should include a label that they can't set,
+	// such as projectcalico.org/namespace.  Felix only routes CIDRs that lie inside a disabled IP
+	// pool, so that they can't take over other workloads' IPs.  For a pod with several interfaces,
+	// the projectcalico.org/endpoint label selects one of them by its name inside the pod.
+	WorkloadExtraRoutes []ExtraRouteRule `config:"extra-route-list;"`
+
+	// ConntrackHelpers attaches kernel conntrack helpers to the connections of selected local
+	// workloads, for protocols such as FTP that open related connections.  Felix assigns each
+	// helper explicitly, with a CT rule, so it works with the kernel's automatic helper assignment
+	// (nf_conntrack_helper) turned off.  It is a semicolon-separated list of
+	// "<selector>=<helper>[:<port>][,<helper>[:<port>]...]" items, where the helper is "ftp",
+	// "tftp" or "sip"; for example "projectcalico.org/namespace == 'legacy'=ftp,sip:5080".  The
+	// helper applies to the workloads' connections, in both directions, to the helper's port.
+	ConntrackHelpers []ConntrackHelperRule `config:"conntrack-helper-list;"`
+
+	// WorkloadBandwidthLimitsEnabled enables tc rate limiting of local pods that have the
+	// kubernetes.io/ingress-bandwidth or kubernetes.io/egress-bandwidth annotations, which Felix
+	// watches on the Kubernetes API, and of the workloads that WorkloadBandwidthLimits selects.
+	// Leave it disabled if the bandwidth CNI plugin is in use; the two would fight over the qdiscs
+	// on the workload interfaces.
+	WorkloadBandwidthLimitsEnabled bool `config:"bool;false"`
+	// WorkloadBandwidthLimits overrides the bandwidth annotations of selected local workloads.  It
+	// is a semicolon-separated list of "<selector>=<ingress>,<egress>" items, where the limits are
+	// in bits per second with the usual quantity suffixes and 0 means unlimited; for example
+	// "projectcalico.org/namespace == 'batch'=100M,10M".  The first item that selects a workload
+	// sets both of its limits.
+	WorkloadBandwidthLimits []BandwidthLimitRule `config:"bandwidth-limit-list;"`
+
+	// WorkloadConnectionLimits limits the connections from selected local workloads.  It is a
+	// semicolon-separated list of "<selector>=<max-connections>,<new-connections-per-second>"
+	// items, where 0 means unlimited; for example "projectcalico.org/namespace == 'batch'=1000,50".
+	// The first item that selects a workload sets both of its limits.  Since pod owners control
+	// their pods' labels, selectors should include a label that they can't set, such as
+	// projectcalico.org/namespace.  Only the iptables dataplane enforces the limits.
+	WorkloadConnectionLimits []ConnectionLimitRule `config:"connection-limit-list;"`
+
+	// FlowOffloadEnabled makes Felix offload established forwarded flows, once they have passed
+	// policy, to an nftables flowtable so that their packets skip per-packet rule evaluation.
+	// Flows are offloaded between local workload interfaces and the host interfaces that match
+	// FlowOffloadHostInterfaces.  Workloads that match FlowOffloadExcludeSelector are never
+	// offloaded.  FlowOffloadHardware asks the NIC to offload the flows; if any of the interfaces
+	// doesn't support hardware offload (veths don't), Felix falls back to software offload.
+	// Needs the nft tool and a kernel with flowtable support.  Offloaded flows skip any rules
+	// that match on established traffic, for example flow logs and per-packet counters.
+	FlowOffloadEnabled         bool             `config:"bool;false"`
+	FlowOffloadHardware        bool             `config:"bool;false"`
+	FlowOffloadHostInterfaces  []*regexp.Regexp `config:"iface-list-regexp;"`
+	FlowOffloadExcludeSelector string           `config:"selector;"`
+
+	// TCPolicyOffloadInterfaces lists the host endpoint interfaces on which Felix copies the
+	// simple rules of untracked policy (those that only match on protocol, CIDRs and destination
+	// ports) to tc flower filters, asking the NIC to run them in hardware.  Needs a NIC in
+	// switchdev mode with hw-tc-offload enabled; filters that the NIC refuses run in software.
+	// iptables still enforces the full policy.  Felix takes over the ingress qdisc of matching
+	// interfaces.
+	TCPolicyOffloadInterfaces []*regexp.Regexp `config:"iface-list-regexp;"`
+
+	// VRFSupportEnabled makes Felix support hosts whose interfaces are enslaved to VRFs.  Felix
+	// programs the routes of a workload interface that is in a VRF into the VRF's routing table.
+	// In iptables mode, since the kernel presents packets that arrive on an interface in a VRF on
+	// the VRF device, Felix marks packets from workloads in a VRF with their endpoint mark before
+	// that happens and applies their policy by mark, and the VRF device gets the host endpoint
+	// of the interfaces in the VRF, if they all have the same one; otherwise, give the VRF device
+	// a host endpoint of its own.  In BPF mode, the programs on the workload and host interfaces
+	// see packets before the VRF device does, and their FIB lookups use the VRF's table.
+	VRFSupportEnabled bool `config:"bool;false"`
+	// HostEndpointsFollowExpectedIPs makes an all-interfaces ("*") host endpoint that lists
+	// expected IPs apply only while at least one of those IPs is present on the host, so that,
+	// for example, a host endpoint for a VIP follows the VIP when it fails over between hosts.
+	// By default, such host endpoints always apply, as for other host endpoints.
+	HostEndpointsFollowExpectedIPs bool `config:"bool;false"`
+	// HostEndpointsCoverChildInterfaces makes a host endpoint also apply to the VLAN
+	// sub-interfaces of its interface and, if its interface is a bond, to the bond's slaves, unless
+	// they have host endpoints of their own.  Without it, traffic on a VLAN bypasses the policy of
+	// the host endpoint of the underlying interface.
+	HostEndpointsCoverChildInterfaces bool `config:"bool;false"`
+
+	// ControlPlanePriorityIfacePattern matches the host's uplink interfaces on which Felix
+	// prioritises host control plane traffic over workload traffic, so that workloads that saturate
+	// the uplink can't starve node heartbeats.  Felix replaces the root qdisc of matching interfaces
+	// with a two-band prio qdisc; traffic to or from ControlPlanePriorityPorts goes in the high
+	// priority band and everything else is fair queued in the low priority band.  Empty (the
+	// default) disables the feature.
+	ControlPlanePriorityIfacePattern *regexp.Regexp `config:"regexp;"`
+	// ControlPlanePriorityPorts lists the control plane ports whose traffic is prioritised, as
+	// either source or destination port.  The default covers BGP, etcd, Typha and the Kubernetes
+	// API server.
+	ControlPlanePriorityPorts []ProtoPort `config:"port-list;tcp:179,tcp:2379,tcp:2380,tcp:5473,tcp:6443"`
+
 	AWSSrcDstCheck string `config:"oneof(DoNothing,Enable,Disable);DoNothing;non-zero"`
+	// KubeNodeConditionsEnabled makes Felix set a PolicyReady condition on its Kubernetes Node,
+	// which is False until Felix has finished its initial programming of the dataplane.  Until
+	// then, Felix also sets NetworkUnavailable=True, which stops the scheduler from scheduling
+	// pods to the node before their policy can be enforced.  Once it has, Felix sets
+	// PolicyReady=True and NetworkUnavailable=False.  It also sets a PolicyDegraded condition that
+	// lists the policies and features that Felix can't enforce as written on the node.  Felix
+	// needs permission to patch the nodes/status subresource.
+	KubeNodeConditionsEnabled bool `config:"bool;false"`
+	// KubeNodeDataplaneSummaryInterval, if non-zero, is the interval at which Felix writes a
+	// summary of its dataplane (the number of endpoints, policies, rules, IP sets and routes,
+	// the latency of the last update and the number of failed updates) to the
+	// projectcalico.org/dataplaneSummary annotation of its Kubernetes Node, if the counts have
+	// changed.  It only applies if KubeNodeConditionsEnabled is set, and Felix needs permission
+	// to patch nodes as well as nodes/status.
+	KubeNodeDataplaneSummaryInterval time.Duration `config:"seconds;0"`
+	// WorkloadStatsPort, if non-zero, makes Felix serve the network statistics of its local pods'
+	// interfaces, in the format of the kubelet's /stats/summary API, at /stats/summary on
+	// WorkloadStatsHost:WorkloadStatsPort.
+	WorkloadStatsHost string `config:"host-address;localhost"`
+	WorkloadStatsPort int    `config:"int(0,65535);0"`
+	// ServiceCIDRCheckEnabled makes Felix look up the cluster's service CIDRs, from the
+	// kube-apiserver pods or kubeadm's config, and warn if the service cluster IPs in the
+	// BGPConfiguration, which drive service loop prevention, don't match them.  Felix needs
+	// permission to list pods and to get the kubeadm-config configmap in kube-system; without it,
+	// or if neither source has the CIDRs, Felix logs a warning and skips the check.
+	ServiceCIDRCheckEnabled bool `config:"bool;false"`
+	// KubePodConditionsEnabled makes Felix set a projectcalico.org/PolicyProgrammed condition on
+	// each local pod once its policy is programmed, and record the programmed policy generation
+	// in the pod's projectcalico.org/policyGeneration annotation.  Pods can list the condition in
+	// their readinessGates so that they only receive traffic once their policy is in force.
+	KubePodConditionsEnabled bool `config:"bool;false"`
+	// KubernetesProfilelessModeEnabled is an optimisation for clusters where workloads only have
+	// the profiles that Calico generates for Kubernetes namespaces and service accounts.  The
+	// namespaces' default allow is rendered directly into each workload's chains, rather than as
+	// jumps to the profiles' chains, which are then not programmed at all.
+	KubernetesProfilelessModeEnabled bool `config:"bool;false"`
 
 	ServiceLoopPrevention string `config:"oneof(Drop,Reject,Disabled);Drop"`
+	// CIDRBlocklist is a list of extra CIDRs, such as decommissioned ranges, whose traffic is
+	// dropped or rejected, whether the host forwards it or sends it itself.  The CIDRs are blocked
+	// independently of ServiceLoopPrevention and of the BGP configuration.  It is a
+	// comma-separated list of "<cidr>[=<action>]" items, where the action is Drop (the default)
+	// or Reject; for example "10.99.0.0/16,fd00:99::/64=Reject".
+	CIDRBlocklist []BlockedCIDR `config:"cidr-blocklist;"`
+	// CIDRBlocklistMetricsEnabled counts the packets blocked by each CIDRBlocklist entry in
+	// the felix_cidr_blocklist_packets metric.  The packets are counted by copying them to NFLOG,
+	// so this has a cost if a lot of traffic is blocked.
+	CIDRBlocklistMetricsEnabled bool `config:"bool;false"`
+	// NAT64Prefixes lists the NAT64 prefixes (RFC 6052 /96s such as 64:ff9b::/96) in use in an
+	// IPv6-only cluster.  IPv4 CIDRs in policy rules are also matched against their NAT64-mapped
+	// IPv6 equivalents so that policy written for IPv4 peers keeps working through NAT64.
+	NAT64Prefixes []string `config:"nat64-prefix-list;"`
 
 	ReportingIntervalSecs time.Duration `config:"seconds;30"`
 	ReportingTTLSecs      time.Duration `config:"seconds;90"`
@@ -283,6 +613,18 @@ type Config struct {
 	IptablesMarkMask uint32 `config:"mark-bitmask;0xffff0000;non-zero,die-on-fail"`
 
 	DisableConntrackInvalidCheck bool `config:"bool;false"`
+	// SCTPMultihomingEnabled stops Felix from dropping SCTP HEARTBEAT and HEARTBEAT ACK packets
+	// that conntrack considers INVALID, leaving them to policy.  Older kernels mark the heartbeats
+	// that probe the secondary paths of multihomed associations as INVALID.  Other INVALID SCTP
+	// packets are still dropped.
+	SCTPMultihomingEnabled bool `config:"bool;false"`
+	// GTPUNotrackPort, if non-zero, exempts GTP-U traffic (UDP to the given port, normally 2152)
+	// from connection tracking.  GTP-U is stateless and, on a UPF, its flows can fill the
+	// conntrack table.  Untracked packets never match established connections so policy needs
+	// to allow GTP-U in both directions.  NAT needs conntrack too, so GTP-U is neither DNATed
+	// to Kubernetes service backends nor SNATed by NAT outgoing; GTP-U peers must use pod or
+	// host IPs directly, and pods that send GTP-U outside the cluster need routable IPs.
+	GTPUNotrackPort int `config:"int(0,65535);0"`
 
 	HealthEnabled                   bool   `config:"bool;false"`
 	HealthPort                      int    `config:"int(0,65535);9099"`
@@ -293,13 +635,173 @@ type Config struct {
 	PrometheusGoMetricsEnabled      bool   `config:"bool;true"`
 	PrometheusProcessMetricsEnabled bool   `config:"bool;true"`
 	PrometheusWireGuardMetricsEnabled bool `config:"bool;true"`
+	// HostEndpointPolicyCountersEnabled exports, for each host endpoint policy, the packets and
+	// bytes that it was applied to on the local and forward paths, as the
+	// felix_host_endpoint_policy_packets/bytes metrics and as the hep-policy-counters debug
+	// state.  This shows the effect of applyOnForward.  The counts are read with iptables-save,
+	// which can be slow with a lot of rules, whenever the metrics are scraped.
+	HostEndpointPolicyCountersEnabled bool `config:"bool;false"`
+	// PrometheusWorkloadMetricsEnabled enables per-workload byte and packet counters, labelled
+	// with the workload's namespace and name.
+	PrometheusWorkloadMetricsEnabled bool `config:"bool;false"`
+	// PrometheusWorkloadTCPStatsEnabled enables TCP socket statistics (RTT, retransmits, lost
+	// packets and socket drops) for the sockets in local workloads, aggregated per namespace.
+	PrometheusWorkloadTCPStatsEnabled bool `config:"bool;false"`
+
+	// FlowLogsEnabled enables collection of flow logs for allowed and denied traffic.  Flow logs
+	// are aggregated over FlowLogsFlushInterval and written to the enabled sinks: the file at
+	// FlowLogsFilePath (set to "none" to disable), syslog, an OTLP/HTTP collector and/or an
+	// IPFIX or NetFlow v9 collector at FlowLogsIPFIXCollector (host:port, UDP).
+	FlowLogsEnabled               bool          `config:"bool;false"`
+	FlowLogsFlushInterval         time.Duration `config:"seconds;300"`
+	FlowLogsConntrackPollInterval time.Duration `config:"seconds;10"`
+	FlowLogsFilePath              string        `config:"file;/var/log/calico/flowlogs/flows.log"`
+	FlowLogsSyslogEnabled         bool          `config:"bool;false"`
+	FlowLogsOTLPEndpoint          string        `config:"string;"`
+	FlowLogsIPFIXCollector        string        `config:"authority;"`
+	FlowLogsIPFIXProtocol         string        `config:"oneof(IPFIX,NetFlowV9);IPFIX;non-zero"`
+	// FlowLogsDenyEventsWebhookURL, if set, enables a stream of events, one per denied packet
+	// (up to FlowLogsDenyEventsRateLimit per second), attributed to the local workload and the
+	// denying policy and rule.  Events are POSTed to the URL as JSON arrays.
+	FlowLogsDenyEventsWebhookURL string `config:"string;"`
+	FlowLogsDenyEventsRateLimit  int    `config:"int(1,100000);10;non-zero"`
+
+	// DNSVisibilityEnabled enables capture of DNS responses that traverse the node.  Felix keeps
+	// a cache of recently resolved IP to domain name mappings, which is available from the debug
+	// server (as the "dns-cache" state dump) and is used to annotate denied-packet events.
+	DNSVisibilityEnabled bool `config:"bool;false"`
+
+	// DNSPolicyEnabled enables policy rules that match on destination domains (given by the
+	// rule's projectcalico.org/destination-domains annotation).  Felix fills an IP set for each
+	// rule with the addresses that its domains resolve to, from looking the domains up itself every
+	// DNSPolicyRefreshInterval and from the DNS responses that workloads receive from the
+	// DNSPolicyTrustedServers.  It only uses a response if it answers a query that the workload
+	// sent to that server and the host is delivering it to the workload, so that workloads can't
+	// add addresses by forging responses.  Set DNSPolicyTrustedServers to the cluster DNS service
+	// IP (and any node-local DNS cache address).  When disabled, such rules match nothing.
+	DNSPolicyEnabled         bool          `config:"bool;false"`
+	DNSPolicyRefreshInterval time.Duration `config:"seconds;30"`
+	DNSPolicyTrustedServers  []string      `config:"cidr-list;"`
+
+	// PacketCaptureEnabled enables on-demand packet captures on local workload endpoints.
+	// Captures are started, stopped and downloaded through the debug server, under
+	// /debug/captures/; their pcap files are written under PacketCaptureDir.
+	PacketCaptureEnabled bool   `config:"bool;false"`
+	PacketCaptureDir     string `config:"file;/var/log/calico/pcap"`
+
+	// AlertsWebhookURL and AlertsSNMPTrapTarget (host:port) enable alerts for critical
+	// dataplane conditions: failure to program the dataplane, IP sets that overflow their
+	// maximum size and a conntrack table that is more than AlertsConntrackThresholdPercent
+	// full.  Alerts are POSTed to the webhook as JSON and/or sent as SNMPv2c traps with the
+	// given community and trap OID.  The same alert is repeated at most once per
+	// AlertsRepeatInterval.
+	AlertsWebhookURL                string        `config:"string;"`
+	AlertsSNMPTrapTarget            string        `config:"authority;"`
+	AlertsSNMPCommunity             string        `config:"string;public"`
+	AlertsSNMPTrapOID               string        `config:"string;1.3.6.1.4.1.8072.9999.9999.1"`
+	AlertsConntrackThresholdPercent int           `config:"int(1,100);90"`
+	AlertsRepeatInterval            time.Duration `config:"seconds;300"`
+
+	// WindowsPolicyRuleCompactionEnabled enables merging of Windows HNS ACL rules that differ only
+	// in their addresses, reducing the number of ACLs programmed per endpoint.
+	WindowsPolicyRuleCompactionEnabled bool `config:"bool;false"`
+	// WindowsVXLANRouteCheckInterval is the interval at which Felix checks the VXLAN routes in
+	// HNS and repairs any that have been dropped or duplicated by out-of-band HNS operations.
+	// Zero disables the check.
+	WindowsVXLANRouteCheckInterval time.Duration `config:"seconds;60"`
+	// WindowsVFPMetricsEnabled enables export of per-workload traffic counters and per-rule
+	// policy hit counters, read from VFP, on the Prometheus metrics endpoint.
+	WindowsVFPMetricsEnabled bool `config:"bool;false"`
+	// WindowsDNSExceptions and WindowsMetadataExceptions list platform DNS and metadata
+	// servers that Windows workloads may always reach, regardless of policy, as
+	// <protocol>:<cidr>:<port> entries (port 0 means any port).  For example, on AWS:
+	// "udp:169.254.169.253/32:53,tcp:169.254.169.253/32:53" and "tcp:169.254.169.254/32:80".
+	// These are in addition to any rules in the static-rules.json file.
+	WindowsDNSExceptions      []ProtoPort `config:"port-list;;die-on-fail"`
+	WindowsMetadataExceptions []ProtoPort `config:"port-list;;die-on-fail"`
+
+	// PrometheusMetricsCertFile and PrometheusMetricsKeyFile enable TLS on the Prometheus
+	// metrics endpoint.  If PrometheusMetricsCAFile is also set then clients must present a
+	// certificate signed by one of its CAs.  The files are reloaded when they change.
+	PrometheusMetricsCertFile string `config:"file(must-exist);;local"`
+	PrometheusMetricsKeyFile  string `config:"file(must-exist);;local"`
+	PrometheusMetricsCAFile   string `config:"file(must-exist);;local"`
+
+	// TracingOTLPEndpoint enables tracing of the update pipeline when set.  It is the base URL of
+	// an OpenTelemetry collector's OTLP/HTTP receiver, for example "http://otel-collector:4318".
+	TracingOTLPEndpoint string `config:"string;"`
+	// TracingSampleRatio is the fraction of update batches that are traced.
+	TracingSampleRatio float64 `config:"float;1.0"`
 
 	FailsafeInboundHostPorts  []ProtoPort `config:"port-list;tcp:22,udp:68,tcp:179,tcp:2379,tcp:2380,tcp:5473,tcp:6443,tcp:6666,tcp:6667;die-on-fail"`
 	FailsafeOutboundHostPorts []ProtoPort `config:"port-list;udp:53,udp:67,tcp:179,tcp:2379,tcp:2380,tcp:5473,tcp:6443,tcp:6666,tcp:6667;die-on-fail"`
+	// FailsafeAuditEnabled makes host endpoint traffic that matches a failsafe port go through
+	// policy first.  Traffic that policy would deny is still accepted but it is counted, per
+	// failsafe port, in the felix_failsafe_audit_packets metric.  Only supported in iptables mode.
+	FailsafeAuditEnabled bool `config:"bool;false"`
+
+	// The ClusterService* parameters control "cluster service" allow rules, which are applied
+	// to workload endpoints ahead of policy, much like the failsafe rules for host endpoints, so
+	// that locked-down policy doesn't break cluster plumbing.  They are all off by default.
+	// ClusterServiceAllowNodeLocalDNS allows workloads to reach the node-local DNS cache on port
+	// 53 of ClusterServiceNodeLocalDNSAddrs.  ClusterServiceAllowKubeletProbes allows the kubelet's
+	// probes: TCP connections from a local address of this host to the workload's own IP.  Traffic
+	// from the host that has been DNATed, such as NodePort and service traffic, still goes through
+	// policy.  ClusterServiceAllowKubeletAPI allows workloads to reach the kubelet API on this host.
+	ClusterServiceAllowNodeLocalDNS  bool     `config:"bool;false"`
+	ClusterServiceNodeLocalDNSAddrs  []string `config:"cidr-list;169.254.20.10;die-on-fail"`
+	ClusterServiceAllowKubeletProbes bool     `config:"bool;false"`
+	ClusterServiceAllowKubeletAPI    bool     `config:"bool;false"`
+	ClusterServiceKubeletAPIPort     int      `config:"int(1,65535);10250"`
 
 	KubeNodePortRanges []numorstring.Port `config:"portrange-list;30000:32767"`
 	NATPortRange       numorstring.Port   `config:"portrange;"`
 	NATOutgoingAddress net.IP             `config:"ipv4;"`
+	// NATOutgoingExclusionSelector selects endpoints and network sets whose IPs and nets are
+	// excluded from NAT outgoing, in addition to the IP pools.  For example, a network set
+	// labelled "on-prem" can be used to preserve source IPs toward on-premises networks.
+	NATOutgoingExclusionSelector string `config:"selector;"`
+	// NATOutgoingSourcePools maps selected workloads to their own SNAT source addresses so that
+	// egress traffic from different tenants can be told apart by upstream firewalls.  It is a
+	// semicolon-separated list of "<selector>=<source>" items, where the source is an IP, an IP
+	// range or a CIDR, for example
+	// "projectcalico.org/namespace == 'tenant-a'=192.0.2.10-192.0.2.19".  The first matching
+	// pool applies; workloads that match no pool use the normal NAT outgoing rules.
+	NATOutgoingSourcePools []SNATSourcePool `config:"snat-source-pool-list;"`
+	// EgressGatewaySteering steers the egress traffic of selected local workloads to gateway pods,
+	// which forward it with their own, fixed, egress IP.  It is a semicolon-separated list of
+	// "<workload selector>=><gateway selector>" items, for example
+	// "projectcalico.org/namespace == 'billing'=>egress-gateway == 'billing'".  Since Felix doesn't
+	// see annotations, workloads and gateways are selected by their (namespace) labels.  Each node
+	// sends a rule's traffic, over IPIP, to one of that rule's gateways; the first matching rule
+	// applies and traffic to IP pools and hosts isn't steered.  Requires IPIP and iptables mode.
+	EgressGatewaySteering []EgressGatewayRule `config:"egress-gateway-list;"`
+	// EgressGatewayRoutingRulePriority is the priority of the routing rules that send steered
+	// workloads' traffic to the egress gateway routing tables.
+	EgressGatewayRoutingRulePriority int `config:"int;105"`
+	// EgressInterfaces selects the host interface that traffic leaves by, on nodes with separate
+	// networks (for example storage, management and data networks).  It is a comma-separated
+	// list of "<from|to>:<cidr>=<interface>[@<gateway>]" items; "from" items match the source,
+	// typically an IP pool, and "to" items the destination.  For example,
+	// "from:10.65.0.0/16=eth1@192.168.1.1,to:10.200.0.0/16=eth2" sends traffic from the
+	// 10.65.0.0/16 pool out of eth1, via 192.168.1.1, and all traffic to 10.200.0.0/16 out of
+	// eth2.  Traffic from a "from" CIDR to the IP pools and hosts isn't redirected, and "to"
+	// items take precedence over "from" items.  IPv4 only.
+	EgressInterfaces []EgressInterfaceRule `config:"egress-interface-list;"`
+	// EgressInterfaceRoutingRulePriority is the priority of the routing rules for the "to"
+	// EgressInterfaces items; the rules for the "from" items use the next priority.
+	EgressInterfaceRoutingRulePriority int `config:"int;102"`
+	// NATPortRangePartition splits the NAT port range into equal partitions and limits this node
+	// to one of them.  It has the form "<index>/<count>"; for example, "2/8" uses the third of
+	// eight partitions.  Giving each node behind a shared NAT gateway its own partition avoids
+	// source port collisions and lets external firewalls attribute flows to nodes.  If
+	// NATPortRange isn't set, the partitions are taken from 1024-65535.  The partition applies to
+	// the whole node; the NATOutgoingSourcePools that are CIDRs don't use it, since NETMAP can't
+	// remap ports.
+	NATPortRangePartition PortRangePartition `config:"portrange-partition;"`
+	// NATOutgoingPreservePorts renders the NAT outgoing rules without --random-fully so that the
+	// kernel keeps a flow's source port unless it collides with another flow.
+	NATOutgoingPreservePorts bool `config:"bool;false"`
 
 	UsageReportingEnabled          bool          `config:"bool;true"`
 	UsageReportingInitialDelaySecs time.Duration `config:"seconds;300"`
@@ -318,10 +820,62 @@ type Config struct {
 	DebugPanicAfter                 time.Duration `config:"seconds;0"`
 	DebugSimulateDataRace           bool          `config:"bool;false"`
 
+	// DebugServerEnabled enables the debug HTTP server, which serves pprof profiles, runtime
+	// stats, goroutine dumps and dumps of the dataplane state, and can trigger the profile dumps
+	// that SIGUSR1 and SIGUSR2 write to DebugMemoryProfilePath and DebugCPUProfilePath.  If DebugServerSocketPath is set,
+	// the server listens on that unix socket; otherwise it listens on DebugServerHost and
+	// DebugServerPort and, if DebugServerAllowedCIDRs is non-empty, only accepts requests from
+	// those CIDRs.
+	DebugServerEnabled      bool     `config:"bool;false"`
+	DebugServerHost         string   `config:"host-address;localhost"`
+	DebugServerPort         int      `config:"int(0,65535);6061"`
+	DebugServerSocketPath   string   `config:"file;;"`
+	DebugServerAllowedCIDRs []string `config:"cidr-list;"`
+
+	// ShutdownMode controls what Felix does to the dataplane when it is stopped with SIGTERM or
+	// SIGINT.  leave-dataplane leaves the dataplane as it is so that traffic keeps flowing, with
+	// the last policy, until Felix restarts.  flush-calico-chains removes Felix's iptables
+	// chains and the rules that jump to them, so traffic is no longer policed.  full-cleanup
+	// also removes Felix's IP sets, routes, routing rules, VXLAN and WireGuard devices, IPIP
+	// device address and XDP and BPF programs and maps.  When Felix restarts for a config change
+	// or a failure it always leaves the dataplane.  If the debug server is enabled, a POST to
+	// /debug/shutdown?mode=<mode> stops Felix with the given mode.  flush-calico-chains is
+	// ignored in BPF mode.
+	ShutdownMode string `config:"oneof(leave-dataplane,flush-calico-chains,full-cleanup);leave-dataplane;non-zero"`
+	// DataplaneCheckpointFile, if set, is where Felix saves the state of its IP sets, iptables
+	// chains and routes when it shuts down without cleaning up the dataplane, or restarts.  The
+	// next Felix loads the checkpoint so that it only needs to make the changes since then,
+	// instead of rewriting every IP set and reloading every chain and route before it programs
+	// the dataplane.  It still checks the dataplane soon afterwards, and corrects anything that
+	// changed while it wasn't running.  Felix ignores a checkpoint that was taken on another node
+	// or by a Felix with a different checkpoint format, and one that isn't a regular file owned by
+	// Felix's user and writable only by it.
+	DataplaneCheckpointFile string `config:"file;;"`
+	// HandoffSocketPath, if set, is a unix socket that lets a new Felix take over from a running
+	// one, for example during an upgrade where the new calico/node pod starts before the old one
+	// stops.  When it starts, the new Felix asks the old one, over the socket, to stop programming
+	// the dataplane and to write its checkpoint, then waits up to HandoffTimeout for it to do so
+	// before it starts its own dataplane driver.  It then carries on from the old Felix's state:
+	// the dataplane that was left in place, the checkpoint and, in BPF mode, the pinned BPF maps.
+	// The old Felix exits once it has handed off.  If the old Felix doesn't confirm the handoff
+	// in time, the new Felix discards the checkpoint rather than trust one that may be partial or
+	// out of date.  A Felix that took over less than a minute ago refuses to hand off in its
+	// turn, so that two Felixes can't keep handing off to each other; the new Felix exits and is
+	// retried.  The path must be on a volume that both Felixes can see.
+	HandoffSocketPath string        `config:"file;;"`
+	HandoffTimeout    time.Duration `config:"seconds;30"`
+
 	// Configure where Felix gets its routing information.
-	// - workloadIPs: use workload endpoints to construct routes.
+	// - workloadIPs: use workload endpoints to construct routes.  Workloads' IPv4 floating IPs
+	//   are routed too; IPv6 floating IPs are only reachable on the workload's node.
 	// - calicoIPAM: use IPAM data to contruct routes.
 	RouteSource string `config:"oneof(WorkloadIPs,CalicoIPAM);CalicoIPAM"`
+	// RouteBorrowedIPsFromWorkloads, when RouteSource is CalicoIPAM, makes Felix also route each
+	// remote workload IP that IPAM doesn't route to the workload's node; for example, an IP that
+	// was borrowed from another node's block where IPAM didn't record the borrowing node.  Those
+	// IPs get their own /32 routes, instead of following the block route and being blackholed by
+	// the block's node.  It requires Felix to track all workload endpoints, not just local ones.
+	RouteBorrowedIPsFromWorkloads bool `config:"bool;false"`
 
 	RouteTableRange idalloc.IndexRange `config:"route-table-range;1-250;die-on-fail"`
 
@@ -330,6 +884,12 @@ type Config struct {
 	SidecarAccelerationEnabled bool `config:"bool;false"`
 	XDPEnabled                 bool `config:"bool;true"`
 	GenericXDPEnabled          bool `config:"bool;false"`
+	// If XDPChainingEnabled is true, then, when an interface that needs Felix's XDP program
+	// already has another XDP program attached (for example, a load balancer or DDoS filter),
+	// Felix attaches its program in the other's place and passes the packets that its policy
+	// allows on to the other program.  Otherwise, Felix replaces the other program.  When Felix
+	// no longer needs XDP on the interface, it reattaches the other program.
+	XDPChainingEnabled bool `config:"bool;false"`
 
 	Variant string `config:"string;Calico"`
 
@@ -381,10 +941,222 @@ func (config *Config) Copy() *Config {
 	return &cp
 }
 
+// ProtoPort is an entry in a port list such as FailsafeInboundHostPorts.  Net optionally limits
+// the entry to a source (inbound) or destination (outbound) CIDR and Interface optionally limits it
+// to an interface name, which may end in "+" to match any interface with that prefix.  In iptables
+// mode, an entry with a CIDR of one IP version applies to all addresses of the other.  BPF mode
+// doesn't support interfaces; entries that have one apply to all interfaces.
 type ProtoPort struct {
-	Net      string
+	Net       string
+	Protocol  string
+	Port      uint16
+	Interface string
+}
+
+// TunnelDSCP is the DSCP of the outer header of tunnelled traffic: either inherited from the inner
+// packet or a fixed value.  The kernel always propagates ECN between the inner and outer headers.
+type TunnelDSCP struct {
+	Inherit bool
+	Value   uint8
+}
+
+// TOS returns the TOS setting of a tunnel device, where 1 tells the kernel to inherit the TOS of
+// the inner packet.
+func (d TunnelDSCP) TOS() uint8 {
+	if d.Inherit {
+		return 1
+	}
+	return d.Value << 2
+}
+
+func (d TunnelDSCP) String() string {
+	if d.Inherit {
+		return "inherit"
+	}
+	return fmt.Sprint(d.Value)
+}
+
+// RPFModeOverride overrides the reverse path filtering mode that Felix enforces on the interfaces
+// that match InterfacePattern.  The pattern is an interface name, optionally ending in "+" to match
+// any interface with that prefix.  Mode is one of "Strict", "Loose" or "Disabled".
+type RPFModeOverride struct {
+	InterfacePattern string
+	Mode             string
+}
+
+// HookChain is an entry in IptablesHookChains.  Felix creates Chain, if it doesn't exist, and
+// jumps to it at Point, one of the HookPoint* constants.  Felix never modifies the chain's rules so
+// they survive resyncs and restarts.  Packets that return from the chain carry on through
+// Felix's chains as if it wasn't there.
+type HookChain struct {
+	Point string
+	Chain string
+}
+
+const (
+	// HookPointBeforePolicy is at the start of Felix's filter INPUT, FORWARD and OUTPUT chains.
+	HookPointBeforePolicy = "before-policy"
+	// HookPointAfterPolicy is at the end of Felix's filter INPUT, FORWARD and OUTPUT chains.
+	// Packets that policy accepts or drops outright don't reach it.
+	HookPointAfterPolicy = "after-policy"
+	// HookPointBeforeNAT is at the start of Felix's nat PREROUTING, OUTPUT and POSTROUTING chains.
+	HookPointBeforeNAT = "before-nat"
+)
+
+// BlockedCIDR is an entry in CIDRBlocklist.  Action is "Drop" or "Reject".
+type BlockedCIDR struct {
+	CIDR   string
+	Action string
+}
+
+func (b BlockedCIDR) IPVersion() uint8 {
+	if strings.Contains(b.CIDR, ":") {
+		return 6
+	}
+	return 4
+}
+
+// ProxyNeighborRule makes the host answer ARP/NDP requests for IPs on the interfaces of the
+// workloads that match Selector.
+type ProxyNeighborRule struct {
+	Selector string
+	IPs      []string
+}
+
+// ExtraRouteRule routes CIDRs to the workloads that match Selector.
+type ExtraRouteRule struct {
+	Selector string
+	CIDRs    []string
+}
+
+// BandwidthLimitRule limits the bandwidth of the workloads that match Selector, in bits per
+// second; zero means unlimited.
+type BandwidthLimitRule struct {
+	Selector string
+	Ingress  int64
+	Egress   int64
+}
+
+// ConnectionLimitRule limits the connections from the workloads that match Selector: the number
+// of concurrent connections and the number of new connections per second; zero means unlimited.
+type ConnectionLimitRule struct {
+	Selector                string
+	MaxConnections          int
+	NewConnectionsPerSecond int
+}
+
+// ConntrackHelperRule attaches the given conntrack helpers to the connections of the workloads that
+// match Selector.
+type ConntrackHelperRule struct {
+	Selector string
+	Helpers  []ConntrackHelper
+}
+
+// ConntrackHelper is a kernel conntrack helper and the port (of Protocol) that it applies to.
+type ConntrackHelper struct {
+	Name     string
 	Protocol string
-	Port     uint16
+	Port     int
+}
+
+// conntrackHelperDefaults maps the supported conntrack helpers to their default protocol and port.
+var conntrackHelperDefaults = map[string]ConntrackHelper{
+	"ftp":  {Name: "ftp", Protocol: "tcp", Port: 21},
+	"tftp": {Name: "tftp", Protocol: "udp", Port: 69},
+	"sip":  {Name: "sip", Protocol: "udp", Port: 5060},
+}
+
+// FabricPlane is one plane of a multi-plane fabric: the local uplink Interface and the CIDR of the
+// node addresses on the plane.
+type FabricPlane struct {
+	Interface string
+	CIDR      string
+}
+
+// EgressGatewayRule steers the egress traffic of the workloads that match ClientSelector to the
+// gateway workloads that match GatewaySelector.
+type EgressGatewayRule struct {
+	ClientSelector  string
+	GatewaySelector string
+}
+
+// EgressInterfaceRule sends the traffic from (Direction "from") or to (Direction "to") CIDR
+// out of Interface, via Gateway if it is set.
+type EgressInterfaceRule struct {
+	Direction string
+	CIDR      string
+	Interface string
+	Gateway   string
+}
+
+// PortRangePartition selects partition Index, counting from 0, of Count equal partitions of a port
+// range.  The zero value means that the range isn't partitioned.
+type PortRangePartition struct {
+	Index int
+	Count int
+}
+
+// defaultNATPortRangeForPartitions is the range that NATPortRangePartition partitions if
+// NATPortRange isn't set.  It excludes the privileged ports.
+var defaultNATPortRangeForPartitions = numorstring.Port{MinPort: 1024, MaxPort: 65535}
+
+// SNATSourcePool maps the workloads that match Selector to the SNAT source addresses in ToSource,
+// which is a single IP, an "<ip>-<ip>" range or a CIDR.  Addresses in a CIDR are allocated with
+// NETMAP so that each workload IP maps to a fixed address in the pool.
+type SNATSourcePool struct {
+	Selector string
+	ToSource string
+}
+
+// IsCIDR returns true if the pool is a CIDR, as opposed to a single IP or an IP range.
+func (p SNATSourcePool) IsCIDR() bool {
+	return strings.Contains(p.ToSource, "/")
+}
+
+// IPVersion returns the IP version of the pool's addresses.
+func (p SNATSourcePool) IPVersion() uint8 {
+	if strings.Contains(p.ToSource, ":") {
+		return 6
+	}
+	return 4
+}
+
+const (
+	// AutoHostEndpointID is the endpoint ID of the implicit host endpoint that Felix creates for
+	// AutoHostEndpointInterfaces.
+	AutoHostEndpointID = "felix-auto-host-endpoint"
+	// AutoHostEndpointLabel is set to "true" on the implicit host endpoint.
+	AutoHostEndpointLabel = "projectcalico.org/auto-host-endpoint"
+)
+
+const (
+	ShutdownModeLeaveDataplane    = "leave-dataplane"
+	ShutdownModeFlushCalicoChains = "flush-calico-chains"
+	ShutdownModeFullCleanup       = "full-cleanup"
+)
+
+const (
+	RPFModeStrict   = "Strict"
+	RPFModeLoose    = "Loose"
+	RPFModeDisabled = "Disabled"
+)
+
+// Matches returns true if the interface pattern matches the named interface.
+func (o RPFModeOverride) Matches(ifaceName string) bool {
+	if strings.HasSuffix(o.InterfacePattern, "+") {
+		return strings.HasPrefix(ifaceName, strings.TrimSuffix(o.InterfacePattern, "+"))
+	}
+	return ifaceName == o.InterfacePattern
+}
+
+// RPFModeForInterface returns the mode of the first override that matches the named interface.
+func RPFModeForInterface(overrides []RPFModeOverride, ifaceName string) (mode string, ok bool) {
+	for _, o := range overrides {
+		if o.Matches(ifaceName) {
+			return o.Mode, true
+		}
+	}
+	return "", false
 }
 
 // Load parses and merges the rawData from one particular source into this config object.
@@ -412,6 +1184,30 @@ func (config *Config) UpdateFrom(rawData map[string]string, source Source) (chan
 	return
 }
 
+// EffectiveNATPortRange returns the port range that NAT outgoing should use once
+// NATPortRangePartition has been applied to NATPortRange.  It returns the zero value if no port
+// range applies.
+func (config *Config) EffectiveNATPortRange() numorstring.Port {
+	partition := config.NATPortRangePartition
+	if partition.Count == 0 {
+		return config.NATPortRange
+	}
+	base := config.NATPortRange
+	if base.MaxPort == 0 {
+		base = defaultNATPortRangeForPartitions
+	}
+	size := (int(base.MaxPort) - int(base.MinPort) + 1) / partition.Count
+	if size == 0 {
+		// Validate() rejects this but be defensive.
+		return config.NATPortRange
+	}
+	minPort := int(base.MinPort) + partition.Index*size
+	return numorstring.Port{
+		MinPort: uint16(minPort),
+		MaxPort: uint16(minPort + size - 1),
+	}
+}
+
 func (config *Config) IsLeader() bool {
 	return config.Variant == "Calico"
 }
@@ -660,6 +1456,35 @@ func (config *Config) Validate() (err error) {
 		}
 	}
 
+	// The metrics server's certificate and key must be given together; client certificate
+	// authentication only makes sense with TLS enabled.
+	if (config.PrometheusMetricsCertFile == "") != (config.PrometheusMetricsKeyFile == "") {
+		err = errors.New("PrometheusMetricsCertFile and PrometheusMetricsKeyFile must both be specified to enable TLS")
+	} else if config.PrometheusMetricsCAFile != "" && config.PrometheusMetricsCertFile == "" {
+		err = errors.New("PrometheusMetricsCAFile requires PrometheusMetricsCertFile and PrometheusMetricsKeyFile")
+	}
+
+	// Each NAT port range partition must contain at least one port.
+	if config.NATPortRangePartition.Count > 0 {
+		base := config.NATPortRange
+		if base.MaxPort == 0 {
+			base = defaultNATPortRangeForPartitions
+		}
+		if int(base.MaxPort)-int(base.MinPort)+1 < config.NATPortRangePartition.Count {
+			err = errors.New("NATPortRangePartition has more partitions than there are ports in NATPortRange")
+		}
+	}
+
+	// Multicast routes and the VXLAN group must be multicast addresses.
+	for _, cidr := range config.MulticastGroupRoutes {
+		if _, ipNet, parseErr := net.ParseCIDR(cidr); parseErr != nil || ipNet.IP.To4() == nil || !ipNet.IP.IsMulticast() {
+			err = errors.New("MulticastGroupRoutes may only contain IPv4 multicast CIDRs")
+		}
+	}
+	if config.VXLANMulticastGroup != nil && !config.VXLANMulticastGroup.IsMulticast() {
+		err = errors.New("VXLANMulticastGroup must be a multicast address")
+	}
+
 	if err != nil {
 		config.Err = err
 	}
@@ -749,6 +1574,8 @@ func loadParams() {
 			param = &EndpointListParam{}
 		case "port-list":
 			param = &PortListParam{}
+		case "tunnel-dscp":
+			param = &TunnelDSCPParam{}
 		case "portrange":
 			param = &PortRangeParam{}
 		case "portrange-list":
@@ -778,6 +1605,40 @@ func loadParams() {
 			param = &RouteTableRangeParam{}
 		case "keyvaluelist":
 			param = &KeyValueListParam{}
+		case "sysctl-list":
+			param = &SysctlListParam{}
+		case "service-account-list":
+			param = &ServiceAccountListParam{}
+		case "rpf-mode-list":
+			param = &RPFModeListParam{}
+		case "hook-chain-list":
+			param = &HookChainListParam{}
+		case "selector":
+			param = &SelectorParam{}
+		case "snat-source-pool-list":
+			param = &SNATSourcePoolListParam{}
+		case "portrange-partition":
+			param = &PortRangePartitionParam{}
+		case "egress-gateway-list":
+			param = &EgressGatewayListParam{}
+		case "cidr-blocklist":
+			param = &CIDRBlocklistParam{}
+		case "fabric-plane-list":
+			param = &FabricPlaneListParam{}
+		case "proxy-neighbor-list":
+			param = &ProxyNeighborListParam{}
+		case "extra-route-list":
+			param = &ExtraRouteListParam{}
+		case "bandwidth-limit-list":
+			param = &BandwidthLimitListParam{}
+		case "connection-limit-list":
+			param = &ConnectionLimitListParam{}
+		case "conntrack-helper-list":
+			param = &ConntrackHelperListParam{}
+		case "nat64-prefix-list":
+			param = &NAT64PrefixListParam{}
+		case "egress-interface-list":
+			param = &EgressInterfaceListParam{}
 		default:
 			log.Panicf("Unknown type of parameter: %v", kind)
 		}
@@ -61,6 +61,137 @@ var _ = Describe("FelixConfig vs ConfigParams parity", func() {
 		"loadClientConfigFromEnvironment",
 		"useNodeResourceUpdates",
 		"internalOverrides",
+
+		// Not yet exposed via the FelixConfiguration API.
+		"TracingOTLPEndpoint",
+		"TracingSampleRatio",
+		"FlowLogsEnabled",
+		"FlowLogsFlushInterval",
+		"FlowLogsConntrackPollInterval",
+		"FlowLogsFilePath",
+		"FlowLogsSyslogEnabled",
+		"FlowLogsOTLPEndpoint",
+		"FlowLogsIPFIXCollector",
+		"FlowLogsIPFIXProtocol",
+		"FlowLogsDenyEventsWebhookURL",
+		"FlowLogsDenyEventsRateLimit",
+		"PrometheusWorkloadMetricsEnabled",
+		"PrometheusWorkloadTCPStatsEnabled",
+		"DNSVisibilityEnabled",
+		"PacketCaptureEnabled",
+		"PacketCaptureDir",
+		"AlertsWebhookURL",
+		"AlertsSNMPTrapTarget",
+		"AlertsSNMPCommunity",
+		"AlertsSNMPTrapOID",
+		"AlertsConntrackThresholdPercent",
+		"AlertsRepeatInterval",
+		"WindowsPolicyRuleCompactionEnabled",
+		"WindowsVXLANRouteCheckInterval",
+		"WindowsVFPMetricsEnabled",
+		"WindowsDNSExceptions",
+		"WindowsMetadataExceptions",
+		"InterfaceFlapDampingWindow",
+		"SysctlRefreshInterval",
+		"SysctlOverrides",
+		"InterfaceRPFModes",
+		"DataplaneDriverGRPCAddress",
+		"DataplaneDriverHealthCheckInterval",
+		"SimulatedDataplaneEnabled",
+		"SimulatedDataplaneStateFile",
+		"PolicySyncAllowedServiceAccounts",
+		"DatastoreResyncBaseDelay",
+		"DatastoreResyncMaxDelay",
+		"DatastoreRecordFile",
+		"DatastoreReplayFile",
+		"BackgroundResyncMaxCPUPressure",
+		"KubernetesPodInformerEnabled",
+		"KubernetesPodInformerResyncPeriod",
+		"KubernetesPodInformerLocalNodeOnly",
+		"KubernetesPodInformerLabelSelector",
+		"ClusterServiceAllowNodeLocalDNS",
+		"ClusterServiceNodeLocalDNSAddrs",
+		"ClusterServiceAllowKubeletProbes",
+		"ClusterServiceAllowKubeletAPI",
+		"ClusterServiceKubeletAPIPort",
+		"FailsafeAuditEnabled",
+		"NATOutgoingExclusionSelector",
+		"NATOutgoingSourcePools",
+		"NATPortRangePartition",
+		"NATOutgoingPreservePorts",
+		"EgressGatewaySteering",
+		"EgressGatewayRoutingRulePriority",
+		"EgressInterfaces",
+		"EgressInterfaceRoutingRulePriority",
+		"CIDRBlocklist",
+		"CIDRBlocklistMetricsEnabled",
+		"NAT64Prefixes",
+		"AutoHostEndpointInterfaces",
+		"AutoHostEndpointProfile",
+		"HostEndpointPolicyCountersEnabled",
+		"NodeSelectorIPSetsEnabled",
+		"ShutdownMode",
+		"DataplaneCheckpointFile",
+		"HandoffSocketPath",
+		"HandoffTimeout",
+		"DataplaneApplyThrottleInterval",
+		"DataplaneApplyThrottleBurst",
+		"DataplaneMaxBatchSize",
+		"DataplaneApplyDebounceInterval",
+		"RouteBorrowedIPsFromWorkloads",
+		"VXLANFabricPlanes",
+		"VXLANFabricPlaneProbePort",
+		"VXLANFabricPlaneProbeInterval",
+		"VXLANFabricPlaneProbeTimeout",
+		"WorkloadProxyNeighbors",
+		"WorkloadExtraRoutes",
+		"WorkloadBandwidthLimitsEnabled",
+		"WorkloadBandwidthLimits",
+		"WorkloadConnectionLimits",
+		"FlowOffloadEnabled",
+		"FlowOffloadHardware",
+		"FlowOffloadHostInterfaces",
+		"FlowOffloadExcludeSelector",
+		"TCPolicyOffloadInterfaces",
+		"VRFSupportEnabled",
+		"HostEndpointsFollowExpectedIPs",
+		"HostEndpointsCoverChildInterfaces",
+		"ControlPlanePriorityIfacePattern",
+		"ControlPlanePriorityPorts",
+		"IPIPDSCP",
+		"VXLANDSCP",
+		"WireguardDSCP",
+		"BPFNATBackendSelection",
+		"BPFHostPortsEnabled",
+		"KubeNodeConditionsEnabled",
+		"ServiceCIDRCheckEnabled",
+		"IptablesOtherBackendCleanupEnabled",
+		"IptablesIPSetInlineMaxMembers",
+		"KubePodConditionsEnabled",
+		"StartupResyncSlots",
+		"StartupResyncNamespace",
+		"StartupResyncMaxWait",
+		"StartupResyncMaxHold",
+		"XDPChainingEnabled",
+		"DNSPolicyEnabled",
+		"DNSPolicyRefreshInterval",
+		"DNSPolicyTrustedServers",
+		"PolicySyncAppProtocolHintsEnabled",
+		"KubernetesProfilelessModeEnabled",
+		"IptablesHookChains",
+		"SCTPMultihomingEnabled",
+		"GTPUNotrackPort",
+		"VXLANMulticastGroup",
+		"MulticastEnabled",
+		"MulticastIGMPVersion",
+		"MulticastMLDVersion",
+		"MulticastGroupRoutes",
+		"ConntrackHelpers",
+		"DataplaneCommandCaptureDir",
+		"DataplaneCommandCaptureMaxFiles",
+		"KubeNodeDataplaneSummaryInterval",
+		"WorkloadStatsHost",
+		"WorkloadStatsPort",
 	}
 	cpFieldNameToFC := map[string]string{
 		"IpInIpEnabled":                      "IPIPEnabled",
@@ -226,6 +357,22 @@ var _ = DescribeTable("Config parsing",
 	Entry("TyphaK8sNamespace empty", "TyphaK8sNamespace", "", "kube-system"),
 	Entry("TyphaK8sNamespace set", "TyphaK8sNamespace", "default", "default"),
 	Entry("TyphaK8sNamespace none", "TyphaK8sNamespace", "none", "kube-system", true),
+	Entry("TyphaTLSReloadInterval", "TyphaTLSReloadInterval", "60", 60*time.Second),
+	Entry("DatastoreResyncBaseDelay", "DatastoreResyncBaseDelay", "0.5", 500*time.Millisecond),
+	Entry("DatastoreResyncMaxDelay", "DatastoreResyncMaxDelay", "0", time.Duration(0)),
+	Entry("DatastoreRecordFile", "DatastoreRecordFile", "/tmp/updates.json", "/tmp/updates.json"),
+	Entry("DatastoreReplayFile", "DatastoreReplayFile", "/tmp/updates.json", "/tmp/updates.json"),
+	Entry("BackgroundResyncMaxCPUPressure", "BackgroundResyncMaxCPUPressure", "25.5", 25.5),
+	Entry("KubernetesPodInformerEnabled", "KubernetesPodInformerEnabled", "true", true),
+	Entry("KubernetesPodInformerResyncPeriod", "KubernetesPodInformerResyncPeriod", "300", 300*time.Second),
+	Entry("KubernetesPodInformerLabelSelector", "KubernetesPodInformerLabelSelector", "app=web", "app=web"),
+	Entry("ClusterServiceAllowNodeLocalDNS", "ClusterServiceAllowNodeLocalDNS", "true", true),
+	Entry("ClusterServiceAllowNodeLocalDNS default", "ClusterServiceAllowNodeLocalDNS", "", false),
+	Entry("ClusterServiceAllowKubeletProbes default", "ClusterServiceAllowKubeletProbes", "", false),
+	Entry("ClusterServiceNodeLocalDNSAddrs default", "ClusterServiceNodeLocalDNSAddrs", "", []string{"169.254.20.10"}),
+	Entry("ClusterServiceNodeLocalDNSAddrs", "ClusterServiceNodeLocalDNSAddrs", "169.254.25.10,fd00::10", []string{"169.254.25.10", "fd00::10"}),
+	Entry("ClusterServiceAllowKubeletAPI", "ClusterServiceAllowKubeletAPI", "true", true),
+	Entry("ClusterServiceKubeletAPIPort", "ClusterServiceKubeletAPIPort", "10255", 10255),
 
 	Entry("InterfacePrefix", "InterfacePrefix", "tap", "tap"),
 	Entry("InterfacePrefix list", "InterfacePrefix", "tap,cali", "tap,cali"),
@@ -259,6 +406,150 @@ var _ = DescribeTable("Config parsing",
 		regexp.MustCompile("^kube-ipvs0$"),
 	}),
 
+	Entry("AutoHostEndpointInterfaces", "AutoHostEndpointInterfaces", "eth0,/^ens.*/", []*regexp.Regexp{
+		regexp.MustCompile("^eth0$"),
+		regexp.MustCompile("^ens.*"),
+	}),
+	Entry("AutoHostEndpointProfile", "AutoHostEndpointProfile", "allow-all", "allow-all"),
+	Entry("NodeSelectorIPSetsEnabled", "NodeSelectorIPSetsEnabled", "true", true),
+	Entry("ShutdownMode", "ShutdownMode", "Full-Cleanup", "full-cleanup"),
+	Entry("ShutdownMode default", "ShutdownMode", "", "leave-dataplane"),
+	Entry("ShutdownMode bad mode -> defaulted", "ShutdownMode", "remove-everything", "leave-dataplane"),
+	Entry("DataplaneCheckpointFile", "DataplaneCheckpointFile",
+		"/var/run/calico/felix-checkpoint.json", "/var/run/calico/felix-checkpoint.json"),
+	Entry("HandoffSocketPath", "HandoffSocketPath",
+		"/var/run/calico/felix-handoff.sock", "/var/run/calico/felix-handoff.sock"),
+	Entry("HandoffTimeout", "HandoffTimeout", "10", 10*time.Second),
+	Entry("DataplaneApplyThrottleInterval", "DataplaneApplyThrottleInterval", "250", 250*time.Millisecond),
+	Entry("DataplaneApplyThrottleInterval default", "DataplaneApplyThrottleInterval", "", 100*time.Millisecond),
+	Entry("DataplaneApplyThrottleBurst", "DataplaneApplyThrottleBurst", "3", 3),
+	Entry("DataplaneApplyThrottleBurst zero -> defaulted", "DataplaneApplyThrottleBurst", "0", 10),
+	Entry("DataplaneMaxBatchSize", "DataplaneMaxBatchSize", "1000", 1000),
+	Entry("DataplaneMaxBatchSize default", "DataplaneMaxBatchSize", "", 100),
+	Entry("DataplaneApplyDebounceInterval", "DataplaneApplyDebounceInterval", "50", 50*time.Millisecond),
+	Entry("DataplaneApplyDebounceInterval default", "DataplaneApplyDebounceInterval", "", time.Duration(0)),
+	Entry("RouteBorrowedIPsFromWorkloads", "RouteBorrowedIPsFromWorkloads", "true", true),
+	Entry("VXLANFabricPlanes", "VXLANFabricPlanes", "eth0=10.1.0.0/16, eth1=10.2.0.1/16",
+		[]config.FabricPlane{
+			{Interface: "eth0", CIDR: "10.1.0.0/16"},
+			{Interface: "eth1", CIDR: "10.2.0.0/16"},
+		}),
+	Entry("VXLANFabricPlanes missing CIDR", "VXLANFabricPlanes", "eth0", []config.FabricPlane(nil)),
+	Entry("VXLANFabricPlanes IPv6", "VXLANFabricPlanes", "eth0=fd00::/64", []config.FabricPlane(nil)),
+	Entry("VXLANFabricPlaneProbePort", "VXLANFabricPlaneProbePort", "9000", 9000),
+	Entry("VXLANFabricPlaneProbePort default", "VXLANFabricPlaneProbePort", "", 9097),
+	Entry("VXLANFabricPlaneProbeInterval", "VXLANFabricPlaneProbeInterval", "2", 2*time.Second),
+	Entry("VXLANFabricPlaneProbeTimeout default", "VXLANFabricPlaneProbeTimeout", "", 3*time.Second),
+	Entry("WorkloadProxyNeighbors", "WorkloadProxyNeighbors",
+		"kubevirt.io == 'virt-launcher'=169.254.1.1, fe80::1; has(vm)=10.0.0.1",
+		[]config.ProxyNeighborRule{
+			{Selector: "kubevirt.io == 'virt-launcher'", IPs: []string{"169.254.1.1", "fe80::1"}},
+			{Selector: "has(vm)", IPs: []string{"10.0.0.1"}},
+		}),
+	Entry("WorkloadProxyNeighbors bad IP", "WorkloadProxyNeighbors", "has(vm)=10.0.0.300",
+		[]config.ProxyNeighborRule(nil)),
+	Entry("WorkloadProxyNeighbors bad selector", "WorkloadProxyNeighbors", "has(=10.0.0.1",
+		[]config.ProxyNeighborRule(nil)),
+	Entry("WorkloadExtraRoutes", "WorkloadExtraRoutes",
+		"projectcalico.org/namespace == 'vms'=10.65.0.1/24, fd00:65::/64; has(vm)=10.66.0.0/16",
+		[]config.ExtraRouteRule{
+			{Selector: "projectcalico.org/namespace == 'vms'", CIDRs: []string{"10.65.0.0/24", "fd00:65::/64"}},
+			{Selector: "has(vm)", CIDRs: []string{"10.66.0.0/16"}},
+		}),
+	Entry("WorkloadExtraRoutes bare IP", "WorkloadExtraRoutes", "has(vm)=10.65.0.1",
+		[]config.ExtraRouteRule(nil)),
+	Entry("WorkloadExtraRoutes bad selector", "WorkloadExtraRoutes", "has(=10.65.0.0/24",
+		[]config.ExtraRouteRule(nil)),
+	Entry("ConntrackHelpers", "ConntrackHelpers",
+		"projectcalico.org/namespace == 'legacy'=ftp, sip:5080; has(tftp)=tftp",
+		[]config.ConntrackHelperRule{
+			{Selector: "projectcalico.org/namespace == 'legacy'", Helpers: []config.ConntrackHelper{
+				{Name: "ftp", Protocol: "tcp", Port: 21},
+				{Name: "sip", Protocol: "udp", Port: 5080},
+			}},
+			{Selector: "has(tftp)", Helpers: []config.ConntrackHelper{
+				{Name: "tftp", Protocol: "udp", Port: 69},
+			}},
+		}),
+	Entry("ConntrackHelpers unknown helper", "ConntrackHelpers", "has(a)=irc",
+		[]config.ConntrackHelperRule(nil)),
+	Entry("ConntrackHelpers bad port", "ConntrackHelpers", "has(a)=ftp:70000",
+		[]config.ConntrackHelperRule(nil)),
+	Entry("DataplaneCommandCaptureDir", "DataplaneCommandCaptureDir",
+		"/var/log/calico/commands", "/var/log/calico/commands"),
+	Entry("DataplaneCommandCaptureMaxFiles", "DataplaneCommandCaptureMaxFiles", "50", 50),
+	Entry("DataplaneCommandCaptureMaxFiles default", "DataplaneCommandCaptureMaxFiles", "", 20),
+	Entry("DataplaneCommandCaptureMaxFiles zero -> defaulted", "DataplaneCommandCaptureMaxFiles", "0", 20),
+	Entry("WorkloadBandwidthLimitsEnabled", "WorkloadBandwidthLimitsEnabled", "true", true),
+	Entry("WorkloadBandwidthLimits", "WorkloadBandwidthLimits",
+		"projectcalico.org/namespace == 'batch'=100M, 1.5G; has(unlimited)=0,0",
+		[]config.BandwidthLimitRule{
+			{Selector: "projectcalico.org/namespace == 'batch'", Ingress: 100000000, Egress: 1500000000},
+			{Selector: "has(unlimited)", Ingress: 0, Egress: 0},
+		}),
+	Entry("WorkloadBandwidthLimits missing egress", "WorkloadBandwidthLimits", "has(a)=10M",
+		[]config.BandwidthLimitRule(nil)),
+	Entry("WorkloadBandwidthLimits bad quantity", "WorkloadBandwidthLimits", "has(a)=10M,fast",
+		[]config.BandwidthLimitRule(nil)),
+	Entry("WorkloadConnectionLimits", "WorkloadConnectionLimits",
+		"projectcalico.org/namespace == 'batch'=1000,50; has(a)=0,10",
+		[]config.ConnectionLimitRule{
+			{Selector: "projectcalico.org/namespace == 'batch'", MaxConnections: 1000, NewConnectionsPerSecond: 50},
+			{Selector: "has(a)", MaxConnections: 0, NewConnectionsPerSecond: 10},
+		}),
+	Entry("WorkloadConnectionLimits negative", "WorkloadConnectionLimits", "has(a)=-1,10",
+		[]config.ConnectionLimitRule(nil)),
+	Entry("FlowOffloadEnabled", "FlowOffloadEnabled", "true", true),
+	Entry("FlowOffloadHardware", "FlowOffloadHardware", "true", true),
+	Entry("FlowOffloadExcludeSelector", "FlowOffloadExcludeSelector", "offload == 'false'", "offload == 'false'"),
+	Entry("VRFSupportEnabled", "VRFSupportEnabled", "true", true),
+	Entry("HostEndpointsFollowExpectedIPs", "HostEndpointsFollowExpectedIPs", "true", true),
+	Entry("HostEndpointsCoverChildInterfaces", "HostEndpointsCoverChildInterfaces", "true", true),
+	Entry("IPIPDSCP inherit", "IPIPDSCP", "inherit", config.TunnelDSCP{Inherit: true}),
+	Entry("VXLANDSCP fixed", "VXLANDSCP", "46", config.TunnelDSCP{Value: 46}),
+	Entry("VXLANDSCP out of range", "VXLANDSCP", "64", config.TunnelDSCP{}),
+	Entry("WireguardDSCP", "WireguardDSCP", "10", 10),
+	Entry("ControlPlanePriorityIfacePattern", "ControlPlanePriorityIfacePattern", "^eth0$",
+		regexp.MustCompile("^eth0$")),
+	Entry("ControlPlanePriorityPorts", "ControlPlanePriorityPorts", "tcp:6443,udp:10.0.0.0/8:53",
+		[]config.ProtoPort{{Protocol: "tcp", Port: 6443}, {Protocol: "udp", Net: "10.0.0.0/8", Port: 53}}),
+	Entry("BPFNATBackendSelection", "BPFNATBackendSelection", "maglev", "Maglev"),
+	Entry("BPFNATBackendSelection invalid", "BPFNATBackendSelection", "hash", "Random"),
+	Entry("BPFHostPortsEnabled", "BPFHostPortsEnabled", "true", true),
+	Entry("KubeNodeConditionsEnabled", "KubeNodeConditionsEnabled", "true", true),
+	Entry("KubeNodeDataplaneSummaryInterval", "KubeNodeDataplaneSummaryInterval", "60", 60*time.Second),
+	Entry("KubeNodeDataplaneSummaryInterval default", "KubeNodeDataplaneSummaryInterval", "", time.Duration(0)),
+	Entry("WorkloadStatsHost", "WorkloadStatsHost", "10.0.0.1", "10.0.0.1"),
+	Entry("WorkloadStatsHost default", "WorkloadStatsHost", "", "localhost"),
+	Entry("WorkloadStatsPort", "WorkloadStatsPort", "9094", 9094),
+	Entry("ServiceCIDRCheckEnabled", "ServiceCIDRCheckEnabled", "true", true),
+	Entry("IptablesOtherBackendCleanupEnabled", "IptablesOtherBackendCleanupEnabled", "false", false),
+	Entry("IptablesIPSetInlineMaxMembers", "IptablesIPSetInlineMaxMembers", "100", 100),
+	Entry("KubePodConditionsEnabled", "KubePodConditionsEnabled", "true", true),
+	Entry("KubernetesProfilelessModeEnabled", "KubernetesProfilelessModeEnabled", "true", true),
+	Entry("SCTPMultihomingEnabled", "SCTPMultihomingEnabled", "true", true),
+	Entry("GTPUNotrackPort", "GTPUNotrackPort", "2152", 2152),
+	Entry("GTPUNotrackPort bad value -> defaulted", "GTPUNotrackPort", "70000", 0),
+	Entry("VXLANMulticastGroup", "VXLANMulticastGroup", "239.1.1.1", net.ParseIP("239.1.1.1")),
+	Entry("MulticastEnabled", "MulticastEnabled", "true", true),
+	Entry("MulticastIGMPVersion", "MulticastIGMPVersion", "2", 2),
+	Entry("MulticastIGMPVersion bad value -> defaulted", "MulticastIGMPVersion", "4", 0),
+	Entry("MulticastMLDVersion", "MulticastMLDVersion", "1", 1),
+	Entry("MulticastGroupRoutes", "MulticastGroupRoutes", "239.0.0.0/8, 224.1.1.1",
+		[]string{"239.0.0.0/8", "224.1.1.1/32"}),
+	Entry("SimulatedDataplaneEnabled", "SimulatedDataplaneEnabled", "true", true),
+	Entry("SimulatedDataplaneStateFile", "SimulatedDataplaneStateFile", "/tmp/state.json", "/tmp/state.json"),
+	Entry("StartupResyncSlots", "StartupResyncSlots", "10", 10),
+	Entry("StartupResyncSlots out of range", "StartupResyncSlots", "-1", 0),
+	Entry("StartupResyncMaxHold", "StartupResyncMaxHold", "60", 60*time.Second),
+	Entry("XDPChainingEnabled", "XDPChainingEnabled", "true", true),
+	Entry("DNSPolicyEnabled", "DNSPolicyEnabled", "true", true),
+	Entry("DNSPolicyRefreshInterval", "DNSPolicyRefreshInterval", "60", 60*time.Second),
+	Entry("DNSPolicyTrustedServers", "DNSPolicyTrustedServers", "10.96.0.10, 169.254.20.10",
+		[]string{"10.96.0.10/32", "169.254.20.10/32"}),
+	Entry("VXLANFabricPlanes duplicate CIDR", "VXLANFabricPlanes", "eth0=10.1.0.0/16,eth1=10.1.0.0/16",
+		[]config.FabricPlane(nil)),
+
 	Entry("ChainInsertMode append", "ChainInsertMode", "append", "append"),
 	Entry("ChainInsertMode append", "ChainInsertMode", "Append", "append"),
 
@@ -375,6 +666,15 @@ var _ = DescribeTable("Config parsing",
 			{Net: "::/0", Protocol: "tcp", Port: 1},
 			{Net: "::/0", Protocol: "udp", Port: 2},
 		}),
+	Entry("FailsafeInboundHostPorts interface syntax", "FailsafeInboundHostPorts", "tcp:10.0.0.0/8:22@eth0,udp:68@bond+",
+		[]config.ProtoPort{
+			{Net: "10.0.0.0/8", Protocol: "tcp", Port: 22, Interface: "eth0"},
+			{Protocol: "udp", Port: 68, Interface: "bond+"},
+		}),
+	Entry("FailsafeOutboundHostPorts interface syntax IPv6", "FailsafeOutboundHostPorts", "tcp:[fd00::/64]:179@eth1",
+		[]config.ProtoPort{
+			{Net: "fd00::/64", Protocol: "tcp", Port: 179, Interface: "eth1"},
+		}),
 	Entry("FailsafeInboundHostPorts mixed syntax", "FailsafeInboundHostPorts", "1,udp:2",
 		[]config.ProtoPort{
 			{Protocol: "tcp", Port: 1},
@@ -409,6 +709,20 @@ var _ = DescribeTable("Config parsing",
 		},
 		true,
 	),
+	Entry("FailsafeInboundHostPorts bad interface -> defaulted", "FailsafeInboundHostPorts", "tcp:22@eth 0",
+		[]config.ProtoPort{
+			{Protocol: "tcp", Port: 22},
+			{Protocol: "udp", Port: 68},
+			{Protocol: "tcp", Port: 179},
+			{Protocol: "tcp", Port: 2379},
+			{Protocol: "tcp", Port: 2380},
+			{Protocol: "tcp", Port: 5473},
+			{Protocol: "tcp", Port: 6443},
+			{Protocol: "tcp", Port: 6666},
+			{Protocol: "tcp", Port: 6667},
+		},
+		true,
+	),
 	Entry("FailsafeInboundHostPorts too many parts -> defaulted", "FailsafeInboundHostPorts", "tcp:0.0.0.0/0:1:bar",
 		[]config.ProtoPort{
 			{Protocol: "tcp", Port: 22},
@@ -424,6 +738,116 @@ var _ = DescribeTable("Config parsing",
 		true,
 	),
 
+	Entry("SysctlOverrides", "SysctlOverrides", "net.ipv4.conf.all.rp_filter=2, net.ipv4.conf.*.proxy_arp=0",
+		map[string]string{
+			"net.ipv4.conf.all.rp_filter": "2",
+			"net.ipv4.conf.*.proxy_arp":   "0",
+		},
+	),
+	Entry("SysctlOverrides bad syntax -> defaulted", "SysctlOverrides", "net.ipv4.ip_forward",
+		map[string]string(nil),
+	),
+	Entry("SysctlOverrides absolute path -> defaulted", "SysctlOverrides", "/etc/passwd=x",
+		map[string]string(nil),
+	),
+	Entry("SysctlOverrides path traversal -> defaulted", "SysctlOverrides", "net.//.//.//etc.shadow=x",
+		map[string]string(nil),
+	),
+
+	Entry("InterfaceRPFModes", "InterfaceRPFModes", "eth1:loose, bond+:Disabled,cali+:STRICT",
+		[]config.RPFModeOverride{
+			{InterfacePattern: "eth1", Mode: "Loose"},
+			{InterfacePattern: "bond+", Mode: "Disabled"},
+			{InterfacePattern: "cali+", Mode: "Strict"},
+		},
+	),
+	Entry("InterfaceRPFModes bad mode -> defaulted", "InterfaceRPFModes", "eth1:sloppy",
+		[]config.RPFModeOverride(nil),
+	),
+	Entry("InterfaceRPFModes bad pattern -> defaulted", "InterfaceRPFModes", "eth*:Loose",
+		[]config.RPFModeOverride(nil),
+	),
+
+	Entry("IptablesHookChains", "IptablesHookChains", "before-policy:my-pre, Before-NAT:my_nat,after-policy:my-pre",
+		[]config.HookChain{
+			{Point: "before-policy", Chain: "my-pre"},
+			{Point: "before-nat", Chain: "my_nat"},
+			{Point: "after-policy", Chain: "my-pre"},
+		},
+	),
+	Entry("IptablesHookChains bad point -> defaulted", "IptablesHookChains", "during-policy:my-pre",
+		[]config.HookChain(nil),
+	),
+	Entry("IptablesHookChains Felix chain -> defaulted", "IptablesHookChains", "before-policy:cali-mine",
+		[]config.HookChain(nil),
+	),
+
+	Entry("PolicySyncAllowedServiceAccounts", "PolicySyncAllowedServiceAccounts",
+		"istio-system/*, */dikastes,default/my-app.sa",
+		[]string{"istio-system/*", "*/dikastes", "default/my-app.sa"},
+	),
+	Entry("PolicySyncAllowedServiceAccounts missing namespace -> defaulted", "PolicySyncAllowedServiceAccounts",
+		"dikastes",
+		[]string(nil),
+	),
+	Entry("PolicySyncAppProtocolHintsEnabled", "PolicySyncAppProtocolHintsEnabled", "true", true),
+
+	Entry("FailsafeAuditEnabled", "FailsafeAuditEnabled", "true", true),
+	Entry("NATOutgoingExclusionSelector", "NATOutgoingExclusionSelector", "has(on-prem)", "has(on-prem)"),
+	Entry("NATOutgoingExclusionSelector bad selector", "NATOutgoingExclusionSelector", "has(", ""),
+	Entry("NATOutgoingSourcePools", "NATOutgoingSourcePools",
+		"projectcalico.org/namespace == 'a'=10.0.0.1-10.0.0.9; has(b)=10.1.0.0/28;has(c) = 10.2.0.1",
+		[]config.SNATSourcePool{
+			{Selector: "projectcalico.org/namespace == 'a'", ToSource: "10.0.0.1-10.0.0.9"},
+			{Selector: "has(b)", ToSource: "10.1.0.0/28"},
+			{Selector: "has(c)", ToSource: "10.2.0.1"},
+		}),
+	Entry("NATOutgoingSourcePools bad source", "NATOutgoingSourcePools",
+		"has(a)=10.0.0.1-dead::beef", []config.SNATSourcePool(nil)),
+	Entry("NATPortRangePartition", "NATPortRangePartition", "2/8",
+		config.PortRangePartition{Index: 2, Count: 8}),
+	Entry("NATPortRangePartition index out of range", "NATPortRangePartition", "8/8",
+		config.PortRangePartition{}),
+	Entry("NATPortRangePartition bad format", "NATPortRangePartition", "2-8",
+		config.PortRangePartition{}),
+	Entry("NATOutgoingPreservePorts", "NATOutgoingPreservePorts", "true", true),
+	Entry("EgressGatewaySteering", "EgressGatewaySteering",
+		"projectcalico.org/namespace == 'a'=>egw == 'a'; has(b) => egw == 'b'",
+		[]config.EgressGatewayRule{
+			{ClientSelector: "projectcalico.org/namespace == 'a'", GatewaySelector: "egw == 'a'"},
+			{ClientSelector: "has(b)", GatewaySelector: "egw == 'b'"},
+		}),
+	Entry("EgressGatewaySteering missing gateway", "EgressGatewaySteering",
+		"has(a)", []config.EgressGatewayRule(nil)),
+	Entry("EgressGatewaySteering bad selector", "EgressGatewaySteering",
+		"has(a)=>has(", []config.EgressGatewayRule(nil)),
+	Entry("EgressGatewayRoutingRulePriority", "EgressGatewayRoutingRulePriority", "200", 200),
+	Entry("EgressInterfaces", "EgressInterfaces",
+		"from:10.65.0.0/16=eth1@192.168.1.1, to:10.200.0.0/16=eth2",
+		[]config.EgressInterfaceRule{
+			{Direction: "from", CIDR: "10.65.0.0/16", Interface: "eth1", Gateway: "192.168.1.1"},
+			{Direction: "to", CIDR: "10.200.0.0/16", Interface: "eth2"},
+		}),
+	Entry("EgressInterfaces bad direction", "EgressInterfaces",
+		"via:10.65.0.0/16=eth1", []config.EgressInterfaceRule(nil)),
+	Entry("EgressInterfaces IPv6", "EgressInterfaces",
+		"to:fd00::/64=eth1", []config.EgressInterfaceRule(nil)),
+	Entry("EgressInterfaceRoutingRulePriority", "EgressInterfaceRoutingRulePriority", "90", 90),
+	Entry("CIDRBlocklist", "CIDRBlocklist", "10.99.0.1/16, fd00:99::/64=reject,192.0.2.0/24=Drop",
+		[]config.BlockedCIDR{
+			{CIDR: "10.99.0.0/16", Action: "Drop"},
+			{CIDR: "fd00:99::/64", Action: "Reject"},
+			{CIDR: "192.0.2.0/24", Action: "Drop"},
+		}),
+	Entry("CIDRBlocklist bad action", "CIDRBlocklist", "10.99.0.0/16=Accept", []config.BlockedCIDR(nil)),
+	Entry("CIDRBlocklist bad CIDR", "CIDRBlocklist", "10.99.0.0/33", []config.BlockedCIDR(nil)),
+	Entry("CIDRBlocklistMetricsEnabled", "CIDRBlocklistMetricsEnabled", "true", true),
+	Entry("NAT64Prefixes", "NAT64Prefixes", "64:ff9b::/96,fd00:64::/96",
+		[]string{"64:ff9b::/96", "fd00:64::/96"}),
+	Entry("NAT64Prefixes not /96", "NAT64Prefixes", "64:ff9b::/64", []string(nil)),
+	Entry("NAT64Prefixes IPv4", "NAT64Prefixes", "10.0.0.0/8", []string(nil)),
+	Entry("NATOutgoingSourcePools bad selector", "NATOutgoingSourcePools",
+		"has(=10.0.0.1", []config.SNATSourcePool(nil)),
 	Entry("FailsafeInboundHostPorts none", "FailsafeInboundHostPorts", "none", []config.ProtoPort(nil)),
 	Entry("FailsafeOutboundHostPorts none", "FailsafeOutboundHostPorts", "none", []config.ProtoPort(nil)),
 
@@ -682,6 +1106,21 @@ var _ = DescribeTable("Config validation",
 		"TyphaCN":       "typha-peer",
 		"TyphaURISAN":   "spiffe://k8s.example.com/typha-peer",
 	}, true),
+	Entry("metrics TLS cert without key", map[string]string{
+		"PrometheusMetricsCertFile": "/usr",
+	}, false),
+	Entry("metrics TLS CA without cert and key", map[string]string{
+		"PrometheusMetricsCAFile": "/usr",
+	}, false),
+	Entry("metrics TLS cert and key", map[string]string{
+		"PrometheusMetricsCertFile": "/usr",
+		"PrometheusMetricsKeyFile":  "/usr",
+	}, true),
+	Entry("metrics TLS cert, key and CA", map[string]string{
+		"PrometheusMetricsCertFile": "/usr",
+		"PrometheusMetricsKeyFile":  "/usr",
+		"PrometheusMetricsCAFile":   "/usr",
+	}, true),
 	Entry("valid OpenstackRegion", map[string]string{
 		"OpenstackRegion": "region1",
 	}, true),
@@ -706,6 +1145,52 @@ var _ = DescribeTable("Config validation",
 	Entry("invalid RouteTableRange", map[string]string{
 		"RouteTableRange": "abcde",
 	}, false),
+	Entry("NATPortRangePartition within NATPortRange", map[string]string{
+		"NATPortRange":          "1000:1003",
+		"NATPortRangePartition": "3/4",
+	}, true),
+	Entry("multicast group routes", map[string]string{
+		"MulticastGroupRoutes": "239.0.0.0/8",
+		"VXLANMulticastGroup":  "239.1.1.1",
+	}, true),
+	Entry("unicast group route", map[string]string{
+		"MulticastGroupRoutes": "10.0.0.0/8",
+	}, false),
+	Entry("IPv6 group route", map[string]string{
+		"MulticastGroupRoutes": "ff05::/16",
+	}, false),
+	Entry("unicast VXLAN group", map[string]string{
+		"VXLANMulticastGroup": "10.0.0.1",
+	}, false),
+	Entry("NATPortRangePartition with more partitions than ports", map[string]string{
+		"NATPortRange":          "1000:1003",
+		"NATPortRangePartition": "0/5",
+	}, false),
+)
+
+var _ = DescribeTable("Config EffectiveNATPortRange",
+	func(settings map[string]string, expected numorstring.Port) {
+		cfg := config.New()
+		_, err := cfg.UpdateFrom(settings, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.EffectiveNATPortRange()).To(Equal(expected))
+	},
+
+	Entry("no settings", map[string]string{}, numorstring.Port{}),
+	Entry("no partition", map[string]string{
+		"NATPortRange": "1000:2000",
+	}, numorstring.Port{MinPort: 1000, MaxPort: 2000}),
+	Entry("first partition", map[string]string{
+		"NATPortRange":          "1000:1999",
+		"NATPortRangePartition": "0/4",
+	}, numorstring.Port{MinPort: 1000, MaxPort: 1249}),
+	Entry("last partition drops the remainder", map[string]string{
+		"NATPortRange":          "1000:2000",
+		"NATPortRangePartition": "3/4",
+	}, numorstring.Port{MinPort: 1750, MaxPort: 1999}),
+	Entry("partition of the default range", map[string]string{
+		"NATPortRangePartition": "1/2",
+	}, numorstring.Port{MinPort: 33280, MaxPort: 65535}),
 )
 
 var _ = DescribeTable("Config InterfaceExclude",
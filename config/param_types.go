@@ -28,6 +28,7 @@ import (
 
 	"time"
 
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/util/validation"
 
 	"github.com/kardianos/osext"
@@ -37,6 +38,7 @@ import (
 	"github.com/projectcalico/felix/idalloc"
 	"github.com/projectcalico/felix/stringutils"
 	cnet "github.com/projectcalico/libcalico-go/lib/net"
+	"github.com/projectcalico/libcalico-go/lib/selector"
 )
 
 const (
@@ -299,6 +301,21 @@ func (p *Ipv4Param) Parse(raw string) (result interface{}, err error) {
 	return
 }
 
+type TunnelDSCPParam struct {
+	Metadata
+}
+
+func (p *TunnelDSCPParam) Parse(raw string) (interface{}, error) {
+	if strings.ToLower(raw) == "inherit" {
+		return TunnelDSCP{Inherit: true}, nil
+	}
+	value, err := strconv.ParseUint(raw, 10, 8)
+	if err != nil || value > 63 {
+		return nil, p.parseFailed(raw, "must be \"inherit\" or a DSCP value from 0 to 63")
+	}
+	return TunnelDSCP{Value: uint8(value)}, nil
+}
+
 type PortListParam struct {
 	Metadata
 }
@@ -313,6 +330,17 @@ func (p *PortListParam) Parse(raw string) (interface{}, error) {
 
 		protocolStr := "tcp"
 		netStr := ""
+		ifaceStr := ""
+
+		// Check if the entry is limited to an interface, "<entry>@<interface>".  Interface names
+		// may themselves contain "@" so split on the first one.
+		if i := strings.Index(portStr, "@"); i >= 0 {
+			ifaceStr = portStr[i+1:]
+			portStr = portStr[:i]
+			if !rpfIfacePatternRegexp.MatchString(ifaceStr) {
+				return nil, p.parseFailed(raw, "invalid interface pattern "+ifaceStr)
+			}
+		}
 
 		// Check if IPv6 network is set
 		if strings.Contains(portStr, "[") && strings.Contains(portStr, "]") {
@@ -331,7 +359,8 @@ func (p *PortListParam) Parse(raw string) (interface{}, error) {
 		parts := strings.Split(portStr, ":")
 		if len(parts) > 3 {
 			return nil, p.parseFailed(raw,
-				"ports should be <protocol>:<net>:<number> or <protocol>:<number> or <number>")
+				"ports should be <protocol>:<net>:<number> or <protocol>:<number> or <number>, "+
+					"optionally followed by @<interface>")
 		}
 
 		if len(parts) > 2 {
@@ -360,8 +389,9 @@ func (p *PortListParam) Parse(raw string) (interface{}, error) {
 		}
 
 		protoPort := ProtoPort{
-			Protocol: protocolStr,
-			Port:     uint16(port),
+			Protocol:  protocolStr,
+			Port:      uint16(port),
+			Interface: ifaceStr,
 		}
 
 		if netStr != "" {
@@ -393,6 +423,27 @@ func (p *PortRangeParam) Parse(raw string) (interface{}, error) {
 	return portRange, nil
 }
 
+// PortRangePartitionParam parses an "<index>/<count>" port range partition, such as "2/8".
+type PortRangePartitionParam struct {
+	Metadata
+}
+
+var portRangePartitionRegexp = regexp.MustCompile(`^(\d+)/(\d+)$`)
+
+func (p *PortRangePartitionParam) Parse(raw string) (result interface{}, err error) {
+	err = p.parseFailed(raw, "must be <index>/<count> where the index is less than the count")
+	m := portRangePartitionRegexp.FindStringSubmatch(strings.TrimSpace(raw))
+	if m == nil {
+		return
+	}
+	index, _ := strconv.Atoi(m[1])
+	count, _ := strconv.Atoi(m[2])
+	if count < 1 || count > 65535 || index >= count {
+		return
+	}
+	return PortRangePartition{Index: index, Count: count}, nil
+}
+
 type PortRangeListParam struct {
 	Metadata
 }
@@ -546,6 +597,460 @@ func (r *RegionParam) Parse(raw string) (result interface{}, err error) {
 	return raw, nil
 }
 
+// SelectorParam parses a Calico selector, such as "has(on-prem)", keeping it in its original form.
+type SelectorParam struct {
+	Metadata
+}
+
+func (p *SelectorParam) Parse(raw string) (result interface{}, err error) {
+	if _, err = selector.Parse(raw); err != nil {
+		err = p.parseFailed(raw, "invalid selector: "+err.Error())
+		return
+	}
+	return raw, nil
+}
+
+// SNATSourcePoolListParam parses a semicolon-separated list of "<selector>=<source>" items.  Since
+// selectors may contain "=", the source is taken from after the last "=".
+type SNATSourcePoolListParam struct {
+	Metadata
+}
+
+func (p *SNATSourcePoolListParam) Parse(raw string) (result interface{}, err error) {
+	var pools []SNATSourcePool
+	for _, item := range strings.Split(raw, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		i := strings.LastIndex(item, "=")
+		if i < 0 {
+			err = p.parseFailed(raw, "invalid <selector>=<source> item "+item)
+			return
+		}
+		sel := strings.TrimSpace(item[:i])
+		toSource := strings.TrimSpace(item[i+1:])
+		if _, err = selector.Parse(sel); err != nil {
+			err = p.parseFailed(raw, "invalid selector: "+err.Error())
+			return
+		}
+		if !validSNATSource(toSource) {
+			err = p.parseFailed(raw, "invalid SNAT source "+toSource)
+			return
+		}
+		pools = append(pools, SNATSourcePool{Selector: sel, ToSource: toSource})
+	}
+	result = pools
+	return
+}
+
+// EgressGatewayListParam parses a semicolon-separated list of "<workload selector>=><gateway
+// selector>" items.  The selectors are split at the first "=>".
+type EgressGatewayListParam struct {
+	Metadata
+}
+
+func (p *EgressGatewayListParam) Parse(raw string) (result interface{}, err error) {
+	var egwRules []EgressGatewayRule
+	for _, item := range strings.Split(raw, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=>", 2)
+		if len(parts) != 2 {
+			err = p.parseFailed(raw, "invalid <workload selector>=><gateway selector> item "+item)
+			return
+		}
+		rule := EgressGatewayRule{
+			ClientSelector:  strings.TrimSpace(parts[0]),
+			GatewaySelector: strings.TrimSpace(parts[1]),
+		}
+		for _, sel := range []string{rule.ClientSelector, rule.GatewaySelector} {
+			if _, err = selector.Parse(sel); err != nil {
+				err = p.parseFailed(raw, "invalid selector: "+err.Error())
+				return
+			}
+		}
+		egwRules = append(egwRules, rule)
+	}
+	result = egwRules
+	return
+}
+
+// EgressInterfaceListParam parses a comma-separated list of
+// "<from|to>:<cidr>=<interface>[@<gateway>]" items, where the CIDR and the gateway are IPv4.
+type EgressInterfaceListParam struct {
+	Metadata
+}
+
+func (p *EgressInterfaceListParam) Parse(raw string) (result interface{}, err error) {
+	var egressIfaces []EgressInterfaceRule
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		dirAndRest := strings.SplitN(item, ":", 2)
+		parts := []string{}
+		if len(dirAndRest) == 2 {
+			parts = strings.SplitN(dirAndRest[1], "=", 2)
+		}
+		if len(parts) != 2 || (dirAndRest[0] != "from" && dirAndRest[0] != "to") {
+			err = p.parseFailed(raw, "invalid <from|to>:<cidr>=<interface>[@<gateway>] item "+item)
+			return
+		}
+		_, ipNet, cerr := net.ParseCIDR(strings.TrimSpace(parts[0]))
+		if cerr != nil || ipNet.IP.To4() == nil {
+			err = p.parseFailed(raw, "invalid IPv4 CIDR in item "+item)
+			return
+		}
+		rule := EgressInterfaceRule{Direction: dirAndRest[0], CIDR: ipNet.String()}
+		ifaceAndGW := strings.SplitN(strings.TrimSpace(parts[1]), "@", 2)
+		rule.Interface = ifaceAndGW[0]
+		if !NonRegexpIfaceElemRegexp.MatchString(rule.Interface) {
+			err = p.parseFailed(raw, "invalid interface name in item "+item)
+			return
+		}
+		if len(ifaceAndGW) == 2 {
+			gw := net.ParseIP(ifaceAndGW[1])
+			if gw == nil || gw.To4() == nil {
+				err = p.parseFailed(raw, "invalid IPv4 gateway in item "+item)
+				return
+			}
+			rule.Gateway = gw.String()
+		}
+		egressIfaces = append(egressIfaces, rule)
+	}
+	result = egressIfaces
+	return
+}
+
+// CIDRBlocklistParam parses a comma-separated list of "<cidr>[=<action>]" items, where the action
+// is Drop or Reject (case insensitive) and defaults to Drop.
+type CIDRBlocklistParam struct {
+	Metadata
+}
+
+func (p *CIDRBlocklistParam) Parse(raw string) (result interface{}, err error) {
+	var blocked []BlockedCIDR
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		b := BlockedCIDR{CIDR: strings.TrimSpace(parts[0]), Action: "Drop"}
+		if len(parts) == 2 {
+			switch strings.ToLower(strings.TrimSpace(parts[1])) {
+			case "drop":
+				b.Action = "Drop"
+			case "reject":
+				b.Action = "Reject"
+			default:
+				err = p.parseFailed(raw, "invalid action in item "+item+", must be Drop or Reject")
+				return
+			}
+		}
+		_, ipNet, cerr := net.ParseCIDR(b.CIDR)
+		if cerr != nil {
+			err = p.parseFailed(raw, "invalid CIDR "+b.CIDR)
+			return
+		}
+		b.CIDR = ipNet.String()
+		blocked = append(blocked, b)
+	}
+	result = blocked
+	return
+}
+
+// FabricPlaneListParam parses a comma-separated list of "<interface>=<cidr>" items.  Each plane
+// must have a distinct IPv4 CIDR.
+type FabricPlaneListParam struct {
+	Metadata
+}
+
+func (p *FabricPlaneListParam) Parse(raw string) (result interface{}, err error) {
+	var planes []FabricPlane
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			err = p.parseFailed(raw, "invalid <interface>=<cidr> item "+item)
+			return
+		}
+		_, ipNet, cerr := net.ParseCIDR(strings.TrimSpace(parts[1]))
+		if cerr != nil || ipNet.IP.To4() == nil {
+			err = p.parseFailed(raw, "invalid IPv4 CIDR in item "+item)
+			return
+		}
+		for _, other := range planes {
+			if other.CIDR == ipNet.String() {
+				err = p.parseFailed(raw, "duplicate plane CIDR "+other.CIDR)
+				return
+			}
+		}
+		planes = append(planes, FabricPlane{Interface: strings.TrimSpace(parts[0]), CIDR: ipNet.String()})
+	}
+	result = planes
+	return
+}
+
+// NAT64PrefixListParam parses a comma-separated list of IPv6 /96 prefixes.  NAT64 address
+// mapping (RFC 6052) places the whole IPv4 address in the last 32 bits, so other prefix lengths
+// aren't supported.
+type NAT64PrefixListParam struct {
+	Metadata
+}
+
+func (p *NAT64PrefixListParam) Parse(raw string) (result interface{}, err error) {
+	var prefixes []string
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		ip, ipNet, cerr := net.ParseCIDR(item)
+		if cerr != nil || ip.To4() != nil {
+			err = p.parseFailed(raw, "invalid IPv6 CIDR "+item)
+			return
+		}
+		if ones, _ := ipNet.Mask.Size(); ones != 96 {
+			err = p.parseFailed(raw, "NAT64 prefix "+item+" is not a /96")
+			return
+		}
+		prefixes = append(prefixes, ipNet.String())
+	}
+	result = prefixes
+	return
+}
+
+// ProxyNeighborListParam parses a semicolon-separated list of "<selector>=<ip>[,<ip>...]" items.
+// The selector is split at the last "=" since the IPs can't contain one.
+type ProxyNeighborListParam struct {
+	Metadata
+}
+
+func (p *ProxyNeighborListParam) Parse(raw string) (result interface{}, err error) {
+	var proxyRules []ProxyNeighborRule
+	for _, item := range strings.Split(raw, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		i := strings.LastIndex(item, "=")
+		if i < 0 {
+			err = p.parseFailed(raw, "invalid <selector>=<ip>[,<ip>...] item "+item)
+			return
+		}
+		rule := ProxyNeighborRule{Selector: strings.TrimSpace(item[:i])}
+		if _, err = selector.Parse(rule.Selector); err != nil {
+			err = p.parseFailed(raw, "invalid selector: "+err.Error())
+			return
+		}
+		for _, s := range strings.Split(item[i+1:], ",") {
+			addr := net.ParseIP(strings.TrimSpace(s))
+			if addr == nil {
+				err = p.parseFailed(raw, "invalid IP "+s)
+				return
+			}
+			rule.IPs = append(rule.IPs, addr.String())
+		}
+		proxyRules = append(proxyRules, rule)
+	}
+	result = proxyRules
+	return
+}
+
+// ExtraRouteListParam parses a semicolon-separated list of "<selector>=<cidr>[,<cidr>...]" items.
+// As for ProxyNeighborListParam, the selector is split at the last "=".
+type ExtraRouteListParam struct {
+	Metadata
+}
+
+func (p *ExtraRouteListParam) Parse(raw string) (result interface{}, err error) {
+	var routeRules []ExtraRouteRule
+	for _, item := range strings.Split(raw, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		i := strings.LastIndex(item, "=")
+		if i < 0 {
+			err = p.parseFailed(raw, "invalid <selector>=<cidr>[,<cidr>...] item "+item)
+			return
+		}
+		rule := ExtraRouteRule{Selector: strings.TrimSpace(item[:i])}
+		if _, err = selector.Parse(rule.Selector); err != nil {
+			err = p.parseFailed(raw, "invalid selector: "+err.Error())
+			return
+		}
+		for _, s := range strings.Split(item[i+1:], ",") {
+			_, ipNet, cerr := net.ParseCIDR(strings.TrimSpace(s))
+			if cerr != nil {
+				err = p.parseFailed(raw, "invalid CIDR "+s)
+				return
+			}
+			rule.CIDRs = append(rule.CIDRs, ipNet.String())
+		}
+		routeRules = append(routeRules, rule)
+	}
+	result = routeRules
+	return
+}
+
+// BandwidthLimitListParam parses a semicolon-separated list of "<selector>=<ingress>,<egress>"
+// items.  As for ProxyNeighborListParam, the selector is split at the last "=".
+type BandwidthLimitListParam struct {
+	Metadata
+}
+
+func (p *BandwidthLimitListParam) Parse(raw string) (result interface{}, err error) {
+	var limitRules []BandwidthLimitRule
+	for _, item := range strings.Split(raw, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		i := strings.LastIndex(item, "=")
+		if i < 0 {
+			err = p.parseFailed(raw, "invalid <selector>=<ingress>,<egress> item "+item)
+			return
+		}
+		rule := BandwidthLimitRule{Selector: strings.TrimSpace(item[:i])}
+		if _, err = selector.Parse(rule.Selector); err != nil {
+			err = p.parseFailed(raw, "invalid selector: "+err.Error())
+			return
+		}
+		limits := strings.Split(item[i+1:], ",")
+		if len(limits) != 2 {
+			err = p.parseFailed(raw, "invalid <selector>=<ingress>,<egress> item "+item)
+			return
+		}
+		var values [2]int64
+		for j, s := range limits {
+			q, qerr := resource.ParseQuantity(strings.TrimSpace(s))
+			if qerr != nil || q.Sign() < 0 {
+				err = p.parseFailed(raw, "invalid bandwidth "+s)
+				return
+			}
+			values[j] = q.Value()
+		}
+		rule.Ingress, rule.Egress = values[0], values[1]
+		limitRules = append(limitRules, rule)
+	}
+	result = limitRules
+	return
+}
+
+// ConnectionLimitListParam parses a semicolon-separated list of
+// "<selector>=<max-connections>,<new-connections-per-second>" items.  As for
+// ProxyNeighborListParam, the selector is split at the last "=".
+type ConnectionLimitListParam struct {
+	Metadata
+}
+
+func (p *ConnectionLimitListParam) Parse(raw string) (result interface{}, err error) {
+	var limitRules []ConnectionLimitRule
+	for _, item := range strings.Split(raw, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		i := strings.LastIndex(item, "=")
+		if i < 0 {
+			err = p.parseFailed(raw, "invalid <selector>=<max-connections>,<rate> item "+item)
+			return
+		}
+		rule := ConnectionLimitRule{Selector: strings.TrimSpace(item[:i])}
+		if _, err = selector.Parse(rule.Selector); err != nil {
+			err = p.parseFailed(raw, "invalid selector: "+err.Error())
+			return
+		}
+		limits := strings.Split(item[i+1:], ",")
+		if len(limits) != 2 {
+			err = p.parseFailed(raw, "invalid <selector>=<max-connections>,<rate> item "+item)
+			return
+		}
+		var values [2]int
+		for j, s := range limits {
+			v, convErr := strconv.Atoi(strings.TrimSpace(s))
+			if convErr != nil || v < 0 {
+				err = p.parseFailed(raw, "invalid connection limit "+s)
+				return
+			}
+			values[j] = v
+		}
+		rule.MaxConnections, rule.NewConnectionsPerSecond = values[0], values[1]
+		limitRules = append(limitRules, rule)
+	}
+	result = limitRules
+	return
+}
+
+// ConntrackHelperListParam parses a semicolon-separated list of
+// "<selector>=<helper>[:<port>][,<helper>[:<port>]...]" items.  As for ProxyNeighborListParam, the
+// selector is split at the last "=".
+type ConntrackHelperListParam struct {
+	Metadata
+}
+
+func (p *ConntrackHelperListParam) Parse(raw string) (result interface{}, err error) {
+	var helperRules []ConntrackHelperRule
+	for _, item := range strings.Split(raw, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		i := strings.LastIndex(item, "=")
+		if i < 0 {
+			err = p.parseFailed(raw, "invalid <selector>=<helper>[:<port>][,...] item "+item)
+			return
+		}
+		rule := ConntrackHelperRule{Selector: strings.TrimSpace(item[:i])}
+		if _, err = selector.Parse(rule.Selector); err != nil {
+			err = p.parseFailed(raw, "invalid selector: "+err.Error())
+			return
+		}
+		for _, s := range strings.Split(item[i+1:], ",") {
+			parts := strings.SplitN(strings.TrimSpace(s), ":", 2)
+			helper, ok := conntrackHelperDefaults[parts[0]]
+			if !ok {
+				err = p.parseFailed(raw, "unknown conntrack helper "+parts[0])
+				return
+			}
+			if len(parts) == 2 {
+				port, convErr := strconv.Atoi(parts[1])
+				if convErr != nil || port < 1 || port > 65535 {
+					err = p.parseFailed(raw, "invalid port "+parts[1])
+					return
+				}
+				helper.Port = port
+			}
+			rule.Helpers = append(rule.Helpers, helper)
+		}
+		helperRules = append(helperRules, rule)
+	}
+	result = helperRules
+	return
+}
+
+// validSNATSource returns true if s is an IP, an "<ip>-<ip>" range of the same IP version, or a CIDR.
+func validSNATSource(s string) bool {
+	if _, _, err := net.ParseCIDR(s); err == nil {
+		return true
+	}
+	parts := strings.Split(s, "-")
+	if len(parts) > 2 {
+		return false
+	}
+	var ips []net.IP
+	for _, part := range parts {
+		ip := net.ParseIP(part)
+		if ip == nil {
+			return false
+		}
+		ips = append(ips, ip)
+	}
+	return len(ips) == 1 || (ips[0].To4() == nil) == (ips[1].To4() == nil)
+}
+
 type RouteTableRangeParam struct {
 	Metadata
 }
@@ -571,6 +1076,159 @@ func (p *RouteTableRangeParam) Parse(raw string) (result interface{}, err error)
 	return
 }
 
+// SysctlListParam parses a comma-separated list of sysctl=value pairs, where the sysctl is given
+// by its name, as used by the sysctl tool (e.g. "net.ipv4.conf.all.rp_filter"); "*" may be used
+// in place of an interface name.
+type SysctlListParam struct {
+	Metadata
+}
+
+// sysctlNameRegexp matches the sysctl names that we accept.  Felix writes overrides to /proc/sys
+// as root, so absolute names and ".." components, which could refer to files outside /proc/sys,
+// are rejected too.
+var sysctlNameRegexp = regexp.MustCompile(`^[a-z0-9_.\-/*]+$`)
+
+func (p *SysctlListParam) Parse(raw string) (result interface{}, err error) {
+	sysctls := map[string]string{}
+	for _, item := range strings.Split(raw, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || !validSysctlName(name) {
+			err = p.parseFailed(raw, "invalid sysctl=value item "+item)
+			return
+		}
+		sysctls[name] = strings.TrimSpace(parts[1])
+	}
+	result = sysctls
+	return
+}
+
+func validSysctlName(name string) bool {
+	if !sysctlNameRegexp.MatchString(name) || strings.HasPrefix(name, "/") {
+		return false
+	}
+	// As in the sysctl tool, "." separates the components of the name and "/" stands for a "."
+	// within a component, so "//" would become "..".
+	for _, part := range strings.Split(name, ".") {
+		if part == "" || strings.Trim(part, "/") == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// RPFModeListParam parses a comma-separated list of <interface pattern>:<mode> items, for example
+// "eth1:Loose,bond+:Disabled".  The first matching item applies so more specific patterns should
+// come first.
+type RPFModeListParam struct {
+	Metadata
+}
+
+var rpfIfacePatternRegexp = regexp.MustCompile(`^[a-zA-Z0-9_\-.@]+\+?$`)
+
+func (p *RPFModeListParam) Parse(raw string) (result interface{}, err error) {
+	var overrides []RPFModeOverride
+	for _, item := range strings.Split(raw, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		parts := strings.Split(item, ":")
+		if len(parts) != 2 {
+			err = p.parseFailed(raw, "invalid <interface>:<mode> item "+item)
+			return
+		}
+		pattern := strings.TrimSpace(parts[0])
+		if !rpfIfacePatternRegexp.MatchString(pattern) {
+			err = p.parseFailed(raw, "invalid interface pattern "+pattern)
+			return
+		}
+		var mode string
+		switch strings.ToLower(strings.TrimSpace(parts[1])) {
+		case "strict":
+			mode = RPFModeStrict
+		case "loose":
+			mode = RPFModeLoose
+		case "disabled":
+			mode = RPFModeDisabled
+		default:
+			err = p.parseFailed(raw, "invalid RPF mode "+parts[1])
+			return
+		}
+		overrides = append(overrides, RPFModeOverride{InterfacePattern: pattern, Mode: mode})
+	}
+	result = overrides
+	return
+}
+
+// HookChainListParam parses a comma-separated list of <point>:<chain> items, for example
+// "before-policy:my-pre,before-nat:my-nat".  The chains are jumped to in the order that they're
+// listed.  Chain names that Felix uses for its own chains are rejected, since Felix would
+// otherwise clean them up.
+type HookChainListParam struct {
+	Metadata
+}
+
+var hookChainNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_\-]{1,28}$`)
+
+func (p *HookChainListParam) Parse(raw string) (result interface{}, err error) {
+	var hooks []HookChain
+	for _, item := range strings.Split(raw, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		parts := strings.Split(item, ":")
+		if len(parts) != 2 {
+			err = p.parseFailed(raw, "invalid <point>:<chain> item "+item)
+			return
+		}
+		point := strings.ToLower(strings.TrimSpace(parts[0]))
+		switch point {
+		case HookPointBeforePolicy, HookPointAfterPolicy, HookPointBeforeNAT:
+		default:
+			err = p.parseFailed(raw, "invalid hook point "+parts[0])
+			return
+		}
+		chain := strings.TrimSpace(parts[1])
+		if !hookChainNameRegexp.MatchString(chain) ||
+			strings.HasPrefix(chain, "cali") || strings.HasPrefix(chain, "felix-") {
+			err = p.parseFailed(raw, "invalid hook chain name "+chain)
+			return
+		}
+		hooks = append(hooks, HookChain{Point: point, Chain: chain})
+	}
+	result = hooks
+	return
+}
+
+// ServiceAccountListParam parses a comma-separated list of "<namespace>/<name>" service account
+// patterns, where "*" can be used in place of the namespace or the name to match any value.
+type ServiceAccountListParam struct {
+	Metadata
+}
+
+var serviceAccountPatternRegexp = regexp.MustCompile(
+	`^(\*|[a-z0-9]([-a-z0-9]*[a-z0-9])?)/(\*|[a-z0-9]([-.a-z0-9]*[a-z0-9])?)$`)
+
+func (p *ServiceAccountListParam) Parse(raw string) (result interface{}, err error) {
+	var patterns []string
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !serviceAccountPatternRegexp.MatchString(item) {
+			err = p.parseFailed(raw, "invalid <namespace>/<name> service account "+item)
+			return
+		}
+		patterns = append(patterns, item)
+	}
+	result = patterns
+	return
+}
+
 type KeyValueListParam struct {
 	Metadata
 }
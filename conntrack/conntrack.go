@@ -21,6 +21,8 @@ import (
 	"os/exec"
 
 	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/logutils"
 )
 
 // For TCP/UDP, each conntrack entry holds two copies of the tuple
@@ -92,10 +94,8 @@ func (c Conntrack) RemoveConntrackFlows(ipVersion uint8, ipAddr net.IP) {
 		logCxt := log.WithFields(log.Fields{"ip": ipAddr, "direction": direction})
 		// Retry a few times because the conntrack command seems to fail at random.
 		for retry := 0; retry <= numRetries; retry += 1 {
-			cmd := c.newCmd("conntrack",
-				"--family", family,
-				"--delete", direction,
-				ipAddr.String())
+			args := []string{"--family", family, "--delete", direction, ipAddr.String()}
+			cmd := c.newCmd("conntrack", args...)
 
 			// The conntrack tool generates quite a lot of output on stdout (one line per flow) so we
 			// only capture stderr (which is where it logs its errors).
@@ -114,6 +114,7 @@ func (c Conntrack) RemoveConntrackFlows(ipVersion uint8, ipAddr net.IP) {
 			}
 			if retry == numRetries {
 				logCxt.WithError(err).WithField("output", stderrBuf.String()).Error("Failed to remove conntrack flows after retries.")
+				logutils.CaptureFailedCommand("conntrack", args, "", "", stderrBuf.String(), err)
 			} else {
 				logCxt.WithError(err).WithField("output", stderrBuf.String()).Debug("Failed to remove conntrack flows, will retry...")
 			}
@@ -0,0 +1,150 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/proto"
+)
+
+// capabilitiesTimeout is how long we wait for the dataplane driver to advertise its capabilities
+// before assuming that it predates capability negotiation (and so supports everything).
+const capabilitiesTimeout = 10 * time.Second
+
+const (
+	featureIPv6      = "IPv6"
+	featureVXLAN     = "VXLAN"
+	featureWireguard = "WireGuard"
+	featureALP       = "application layer policy"
+)
+
+// checkCapabilities returns an error if the config enables features that the dataplane driver
+// doesn't support.  IPv6 is enabled by default so, if the driver doesn't support it, we only
+// return a warning.
+func checkCapabilities(configParams *config.Config, caps *proto.DataplaneCapabilities) (warning string, err error) {
+	var unsupported []string
+	if configParams.VXLANEnabled && !caps.Vxlan {
+		unsupported = append(unsupported, featureVXLAN+" (VXLANEnabled)")
+	}
+	if configParams.WireguardEnabled && !caps.Wireguard {
+		unsupported = append(unsupported, featureWireguard+" (WireguardEnabled)")
+	}
+	if configParams.PolicySyncPathPrefix != "" && !caps.ApplicationLayerPolicy {
+		unsupported = append(unsupported, featureALP+" (PolicySyncPathPrefix)")
+	}
+	if len(unsupported) > 0 {
+		err = fmt.Errorf("dataplane driver doesn't support features that are enabled in the config: %s",
+			strings.Join(unsupported, ", "))
+	}
+	if configParams.Ipv6Support && !caps.Ipv6 {
+		warning = "Dataplane driver doesn't support IPv6 but Ipv6Support is enabled; IPv6 will not be programmed."
+	}
+	return
+}
+
+// requiredFeature returns the optional dataplane feature that the driver needs in order to handle
+// the given message, or "" if the message doesn't need one.
+func requiredFeature(msg interface{}) string {
+	switch msg.(type) {
+	case *proto.VXLANTunnelEndpointUpdate, *proto.VXLANTunnelEndpointRemove:
+		return featureVXLAN
+	case *proto.WireguardEndpointUpdate, *proto.WireguardEndpointRemove:
+		return featureWireguard
+	}
+	return ""
+}
+
+// stripIPv6 removes the IPv6 content from a message that's bound for a driver that doesn't
+// support IPv6.  It returns nil if nothing is left to send.  Messages are copied rather than
+// modified in place.
+func stripIPv6(msg interface{}) interface{} {
+	switch msg := msg.(type) {
+	case *proto.RouteUpdate:
+		if isIPv6(msg.Dst) {
+			return nil
+		}
+	case *proto.RouteRemove:
+		if isIPv6(msg.Dst) {
+			return nil
+		}
+	case *proto.IPSetUpdate:
+		msgCopy := *msg
+		msgCopy.Members = filterIPv6Members(msg.Members)
+		return &msgCopy
+	case *proto.IPSetDeltaUpdate:
+		msgCopy := *msg
+		msgCopy.AddedMembers = filterIPv6Members(msg.AddedMembers)
+		msgCopy.RemovedMembers = filterIPv6Members(msg.RemovedMembers)
+		if len(msgCopy.AddedMembers) == 0 && len(msgCopy.RemovedMembers) == 0 {
+			return nil
+		}
+		return &msgCopy
+	case *proto.WorkloadEndpointUpdate:
+		if msg.Endpoint == nil || (len(msg.Endpoint.Ipv6Nets) == 0 && len(msg.Endpoint.Ipv6Nat) == 0) {
+			return msg
+		}
+		epCopy := *msg.Endpoint
+		epCopy.Ipv6Nets = nil
+		epCopy.Ipv6Nat = nil
+		return &proto.WorkloadEndpointUpdate{Id: msg.Id, Endpoint: &epCopy}
+	case *proto.HostEndpointUpdate:
+		if msg.Endpoint == nil || len(msg.Endpoint.ExpectedIpv6Addrs) == 0 {
+			return msg
+		}
+		epCopy := *msg.Endpoint
+		epCopy.ExpectedIpv6Addrs = nil
+		return &proto.HostEndpointUpdate{Id: msg.Id, Endpoint: &epCopy}
+	}
+	return msg
+}
+
+// filterIPv6Members returns the IP set members that aren't IPv6.  Members may be IPs, CIDRs or
+// named port members of the form "<IP>,<protocol>:<port>".
+func filterIPv6Members(members []string) []string {
+	var filtered []string
+	for _, m := range members {
+		if isIPv6(strings.SplitN(m, ",", 2)[0]) {
+			continue
+		}
+		filtered = append(filtered, m)
+	}
+	return filtered
+}
+
+func isIPv6(addrOrCIDR string) bool {
+	return strings.Contains(addrOrCIDR, ":")
+}
+
+func hasFeature(caps *proto.DataplaneCapabilities, feature string) bool {
+	if caps == nil {
+		// Driver didn't advertise its capabilities.
+		return true
+	}
+	switch feature {
+	case featureIPv6:
+		return caps.Ipv6
+	case featureVXLAN:
+		return caps.Vxlan
+	case featureWireguard:
+		return caps.Wireguard
+	case featureALP:
+		return caps.ApplicationLayerPolicy
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/proto"
+)
+
+var _ = Describe("Dataplane capabilities", func() {
+	var configParams *config.Config
+
+	BeforeEach(func() {
+		configParams = config.New()
+		configParams.Ipv6Support = false
+	})
+
+	It("should accept a driver that supports everything that's enabled", func() {
+		configParams.VXLANEnabled = true
+		warning, err := checkCapabilities(configParams, &proto.DataplaneCapabilities{Vxlan: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(warning).To(BeEmpty())
+	})
+
+	It("should reject a driver that doesn't support enabled features", func() {
+		configParams.VXLANEnabled = true
+		configParams.WireguardEnabled = true
+		configParams.PolicySyncPathPrefix = "/var/run/nodeagent"
+		_, err := checkCapabilities(configParams, &proto.DataplaneCapabilities{Vxlan: true})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("WireguardEnabled"))
+		Expect(err.Error()).To(ContainSubstring("PolicySyncPathPrefix"))
+		Expect(err.Error()).NotTo(ContainSubstring("VXLANEnabled"))
+	})
+
+	It("should only warn if IPv6 isn't supported", func() {
+		configParams.Ipv6Support = true
+		warning, err := checkCapabilities(configParams, &proto.DataplaneCapabilities{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(warning).To(ContainSubstring("IPv6"))
+	})
+
+	It("should map messages to the features they need", func() {
+		Expect(requiredFeature(&proto.VXLANTunnelEndpointUpdate{})).To(Equal(featureVXLAN))
+		Expect(requiredFeature(&proto.WireguardEndpointRemove{})).To(Equal(featureWireguard))
+		Expect(requiredFeature(&proto.IPSetUpdate{})).To(BeEmpty())
+	})
+
+	It("should assume that a driver that didn't advertise supports everything", func() {
+		Expect(hasFeature(nil, featureWireguard)).To(BeTrue())
+		Expect(hasFeature(&proto.DataplaneCapabilities{}, featureWireguard)).To(BeFalse())
+		Expect(hasFeature(&proto.DataplaneCapabilities{Wireguard: true}, featureWireguard)).To(BeTrue())
+	})
+
+	It("should strip IPv6 content from messages", func() {
+		Expect(stripIPv6(&proto.RouteUpdate{Dst: "dead:beef::/64"})).To(BeNil())
+		Expect(stripIPv6(&proto.RouteRemove{Dst: "dead:beef::/64"})).To(BeNil())
+		Expect(stripIPv6(&proto.RouteUpdate{Dst: "10.0.0.0/24"})).To(Equal(&proto.RouteUpdate{Dst: "10.0.0.0/24"}))
+		Expect(stripIPv6(&proto.IPSetUpdate{
+			Id:      "s",
+			Members: []string{"10.0.0.1", "dead::1", "10.0.0.2,tcp:80", "dead::2,tcp:80"},
+		})).To(Equal(&proto.IPSetUpdate{Id: "s", Members: []string{"10.0.0.1", "10.0.0.2,tcp:80"}}))
+		Expect(stripIPv6(&proto.IPSetDeltaUpdate{Id: "s", AddedMembers: []string{"dead::1"}})).To(BeNil())
+
+		wep := &proto.WorkloadEndpointUpdate{
+			Id: &proto.WorkloadEndpointID{WorkloadId: "w"},
+			Endpoint: &proto.WorkloadEndpoint{
+				Ipv4Nets: []string{"10.0.0.1/32"},
+				Ipv6Nets: []string{"dead::1/128"},
+			},
+		}
+		Expect(stripIPv6(wep)).To(Equal(&proto.WorkloadEndpointUpdate{
+			Id:       &proto.WorkloadEndpointID{WorkloadId: "w"},
+			Endpoint: &proto.WorkloadEndpoint{Ipv4Nets: []string{"10.0.0.1/32"}},
+		}))
+		Expect(wep.Endpoint.Ipv6Nets).To(HaveLen(1), "original message should be left alone")
+	})
+})
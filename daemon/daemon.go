@@ -58,12 +58,20 @@ import (
 	"github.com/projectcalico/felix/config"
 	_ "github.com/projectcalico/felix/config"
 	dp "github.com/projectcalico/felix/dataplane"
+	"github.com/projectcalico/felix/debugserver"
+	"github.com/projectcalico/felix/informercache"
 	"github.com/projectcalico/felix/jitter"
 	"github.com/projectcalico/felix/logutils"
+	"github.com/projectcalico/felix/podconditions"
+	"github.com/projectcalico/felix/podstats"
 	"github.com/projectcalico/felix/policysync"
 	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/replay"
+	"github.com/projectcalico/felix/resynclease"
 	"github.com/projectcalico/felix/statusrep"
+	"github.com/projectcalico/felix/tracing"
 	"github.com/projectcalico/felix/usagerep"
+	"github.com/projectcalico/felix/watchmonitor"
 )
 
 const (
@@ -353,6 +361,7 @@ configRetry:
 	// If we get here, we've loaded the configuration successfully.
 	// Update log levels before we do anything else.
 	logutils.ConfigureLogging(configParams)
+	logutils.ConfigureCommandCapture(configParams.DataplaneCommandCaptureDir, configParams.DataplaneCommandCaptureMaxFiles)
 	// Since we may have enabled more logging, log with the build context
 	// again.
 	buildInfoLogCxt.WithField("config", configParams).Info(
@@ -368,6 +377,29 @@ configRetry:
 		simulateDataRace()
 	}
 
+	// Enable tracing (if configured) before we start the calculation graph and dataplane so that
+	// both are covered.
+	tracing.Start(tracing.Config{
+		Endpoint:    configParams.TracingOTLPEndpoint,
+		SampleRatio: configParams.TracingSampleRatio,
+		ServiceName: "calico-felix",
+		Hostname:    configParams.FelixHostname,
+	})
+
+	// shutdownRequests carries shutdowns requested via the debug server or the handoff socket.
+	shutdownRequests := make(chan shutdownRequest, 1)
+	if configParams.DebugServerEnabled {
+		log.Warn("DebugServerEnabled is set, starting debug server.")
+		debugserver.RegisterHandler("shutdown", shutdownHandler{requests: shutdownRequests})
+		logutils.RegisterProfileDumpHandler(configParams)
+		go debugserver.Serve(debugserver.Config{
+			Host:         configParams.DebugServerHost,
+			Port:         configParams.DebugServerPort,
+			SocketPath:   configParams.DebugServerSocketPath,
+			AllowedCIDRs: configParams.DebugServerAllowedCIDRs,
+		})
+	}
+
 	// Start up the dataplane driver.  This may be the internal go-based driver or an external
 	// one.
 	var dpDriver dp.DataplaneDriver
@@ -386,6 +418,24 @@ configRetry:
 		log.Panic("Graceful shutdown took too long")
 	}
 
+	var tookOverAt time.Time
+	if configParams.HandoffSocketPath != "" {
+		// If there's an old Felix still running, ask it to stop programming the dataplane and
+		// to write its checkpoint before we start our dataplane driver, which loads it.
+		switch requestHandoff(configParams.HandoffSocketPath, configParams.HandoffTimeout) {
+		case handoffSucceeded:
+			tookOverAt = time.Now()
+		case handoffIncomplete:
+			if configParams.DataplaneCheckpointFile != "" {
+				discardCheckpoint(configParams.DataplaneCheckpointFile)
+			}
+		case handoffDeclined:
+			// The running Felix is still programming the dataplane; exit and let our
+			// supervisor retry after it has settled.
+			log.Fatal("Running Felix refused to hand off the dataplane, exiting.")
+		}
+	}
+
 	dpDriver, dpDriverCmd = dp.StartDataplaneDriver(
 		configParams.Copy(), // Copy to avoid concurrent access.
 		healthAggregator,
@@ -415,20 +465,23 @@ configRetry:
 	var policySyncServer *policysync.Server
 	var policySyncProcessor *policysync.Processor
 	var policySyncAPIBinder binder.Binder
+	var toPolicySync chan interface{}
 	calcGraphClientChannels := []chan<- interface{}{dpConnector.ToDataplane}
 	if configParams.IsLeader() && configParams.PolicySyncPathPrefix != "" {
 		log.WithField("policySyncPathPrefix", configParams.PolicySyncPathPrefix).Info(
 			"Policy sync API enabled.  Creating the policy sync server.")
-		toPolicySync := make(chan interface{})
+		toPolicySync = make(chan interface{})
 		policySyncUIDAllocator := policysync.NewUIDAllocator()
 		policySyncProcessor = policysync.NewProcessor(toPolicySync)
 		policySyncServer = policysync.NewServer(
 			policySyncProcessor.JoinUpdates,
 			policySyncUIDAllocator.NextUID,
 		)
+		policySyncServer.SetAllowedServiceAccounts(configParams.PolicySyncAllowedServiceAccounts)
 		policySyncAPIBinder = binder.NewBinder(configParams.PolicySyncPathPrefix)
 		policySyncServer.RegisterGrpc(policySyncAPIBinder.Server())
 		calcGraphClientChannels = append(calcGraphClientChannels, toPolicySync)
+		dpConnector.policySyncUpdates = toPolicySync
 	}
 
 	// Now create the calculation graph, which receives updates from the
@@ -447,50 +500,143 @@ configRetry:
 	// which will feed the calculation graph with updates, bringing Felix into sync.
 	var syncer Startable
 	var typhaConnection *syncclient.SyncerClient
+	var typhaRebalancer *typhaRebalancer
+	var typhaRebalance, typhaTLSReload bool
 	syncerToValidator := calc.NewSyncerCallbacksDecoupler()
-	if typhaAddr != "" {
+	var syncerCallbacks bapi.SyncerCallbacks = syncerToValidator
+	if configParams.DatastoreRecordFile != "" {
+		recorder, err := replay.NewRecorder(configParams.DatastoreRecordFile, syncerToValidator)
+		if err != nil {
+			log.WithError(err).Error("Failed to create datastore recording, not recording.")
+		} else {
+			log.WithField("file", configParams.DatastoreRecordFile).Info("Recording datastore updates.")
+			syncerCallbacks = recorder
+		}
+	}
+	if configParams.DatastoreReplayFile != "" {
+		// Play back a recording instead of syncing.
+		log.WithField("file", configParams.DatastoreReplayFile).Warn(
+			"Replaying datastore recording instead of syncing with the datastore.")
+		syncer = replay.NewPlayer(configParams.DatastoreReplayFile, syncerCallbacks)
+		configParams.SetUseNodeResourceUpdates(true)
+	} else if typhaAddr != "" {
 		// Use a remote Syncer, via the Typha server.
+		newTyphaConnection := func(addr string, callbacks bapi.SyncerCallbacks) *syncclient.SyncerClient {
+			return syncclient.New(
+				addr,
+				buildinfo.GitVersion,
+				configParams.FelixHostname,
+				fmt.Sprintf("Revision: %s; Build date: %s",
+					buildinfo.GitRevision, buildinfo.BuildDate),
+				callbacks,
+				&syncclient.Options{
+					ReadTimeout:  configParams.TyphaReadTimeout,
+					WriteTimeout: configParams.TyphaWriteTimeout,
+					KeyFile:      configParams.TyphaKeyFile,
+					CertFile:     configParams.TyphaCertFile,
+					CAFile:       configParams.TyphaCAFile,
+					ServerCN:     configParams.TyphaCN,
+					ServerURISAN: configParams.TyphaURISAN,
+				},
+			)
+		}
+		var typhaCallbacks bapi.SyncerCallbacks = syncerCallbacks
+		typhaRebalance = configParams.TyphaRebalanceInterval > 0
+		if typhaRebalance && (configParams.TyphaAddr != "" || k8sClientSet == nil) {
+			log.Warn("Typha rebalancing requires Typha to be discovered via its Kubernetes " +
+				"service; disabling rebalancing.")
+			typhaRebalance = false
+		}
+		typhaTLSReload = configParams.TyphaTLSReloadInterval > 0 && configParams.TyphaCertFile != ""
+		if typhaRebalance || typhaTLSReload {
+			handover := newTyphaHandover(syncerCallbacks)
+			typhaCallbacks = handover.InitialCallbacks()
+			typhaRebalancer = newTyphaRebalancer(configParams, k8sClientSet, handover,
+				newTyphaConnection, failureReportChan)
+		}
 		log.WithField("addr", typhaAddr).Info("Connecting to Typha.")
-		typhaConnection = syncclient.New(
-			typhaAddr,
-			buildinfo.GitVersion,
-			configParams.FelixHostname,
-			fmt.Sprintf("Revision: %s; Build date: %s",
-				buildinfo.GitRevision, buildinfo.BuildDate),
-			syncerToValidator,
-			&syncclient.Options{
-				ReadTimeout:  configParams.TyphaReadTimeout,
-				WriteTimeout: configParams.TyphaWriteTimeout,
-				KeyFile:      configParams.TyphaKeyFile,
-				CertFile:     configParams.TyphaCertFile,
-				CAFile:       configParams.TyphaCAFile,
-				ServerCN:     configParams.TyphaCN,
-				ServerURISAN: configParams.TyphaURISAN,
-			},
-		)
+		typhaConnection = newTyphaConnection(typhaAddr, typhaCallbacks)
 	} else {
 		// Use the syncer locally.
-		syncer = felixsyncer.New(backendClient, datastoreConfig.Spec, syncerToValidator, configParams.IsLeader())
+		// Wrap the client to monitor the syncer's watches and spread out the re-lists that
+		// follow a watch failure.
+		var syncerBackend bapi.Client = backendClient
+		if configParams.KubernetesPodInformerEnabled {
+			if k8sClientSet == nil || configParams.DatastoreType != string(apiconfig.Kubernetes) {
+				log.Warn("The pod informer is only supported with the Kubernetes datastore; ignoring " +
+					"KubernetesPodInformerEnabled.")
+			} else {
+				informerConfig := informercache.Config{
+					ResyncPeriod:  configParams.KubernetesPodInformerResyncPeriod,
+					LabelSelector: configParams.KubernetesPodInformerLabelSelector,
+				}
+				if configParams.KubernetesPodInformerLocalNodeOnly {
+					log.Warn("Only watching pods on this node; policy selectors won't match pods " +
+						"on other nodes.")
+					informerConfig.NodeName = configParams.FelixHostname
+				}
+				podCache, err := informercache.NewClient(backendClient, k8sClientSet, informerConfig)
+				if err != nil {
+					log.WithError(err).Fatal("Failed to create pod informer.")
+				}
+				podCache.Start(context.Background())
+				syncerBackend = podCache
+			}
+		}
+		syncerClient := watchmonitor.NewClient(syncerBackend, watchmonitor.Config{
+			BaseResyncDelay: configParams.DatastoreResyncBaseDelay,
+			MaxResyncDelay:  configParams.DatastoreResyncMaxDelay,
+		})
+		syncer = felixsyncer.New(syncerClient, datastoreConfig.Spec, syncerCallbacks, configParams.IsLeader())
 
 		log.Info("using resource updates where applicable")
 		configParams.SetUseNodeResourceUpdates(true)
 	}
 	log.WithField("syncer", syncer).Info("Created Syncer")
 
+	// If configured, wait for our turn to resync so that we don't all load the API server at
+	// once after an upgrade.
+	var resyncSlot *resynclease.Slot
+	if configParams.StartupResyncSlots > 0 {
+		if k8sClientSet == nil {
+			log.Warn("Startup resync coordination requires the Kubernetes API; ignoring StartupResyncSlots.")
+		} else {
+			log.Info("Waiting for a startup resync slot.")
+			healthAggregator.Report(healthName, &health.HealthReport{Live: true, Ready: false})
+			coordinator := resynclease.New(k8sClientSet, resynclease.Config{
+				Namespace: configParams.StartupResyncNamespace,
+				Slots:     configParams.StartupResyncSlots,
+				Identity:  configParams.FelixHostname,
+			})
+			ctx, cancel := context.WithTimeout(context.Background(), configParams.StartupResyncMaxWait)
+			var err error
+			resyncSlot, err = coordinator.Acquire(ctx)
+			cancel()
+			if err != nil {
+				log.WithError(err).Warn("Gave up waiting for a startup resync slot; resyncing anyway.")
+			}
+			healthAggregator.Report(healthName, &health.HealthReport{Live: true, Ready: true})
+		}
+	}
+
 	// Start the background processing threads.
 	if syncer != nil {
 		log.Infof("Starting the datastore Syncer")
 		syncer.Start()
 	} else {
 		log.Infof("Starting the Typha connection")
-		err := typhaConnection.Start(context.Background())
+		typhaCtx := context.Background()
+		if typhaRebalancer != nil {
+			typhaCtx = typhaRebalancer.InitialContext()
+		}
+		err := typhaConnection.Start(typhaCtx)
 		if err != nil {
 			log.WithError(err).Error("Failed to connect to Typha. Retrying...")
 			startTime := time.Now()
 			for err != nil && time.Since(startTime) < 30*time.Second {
 				// Set Ready to false and Live to true when unable to connect to typha
 				healthAggregator.Report(healthName, &health.HealthReport{Live: true, Ready: false})
-				err = typhaConnection.Start(context.Background())
+				err = typhaConnection.Start(typhaCtx)
 				if err == nil {
 					break
 				}
@@ -513,8 +659,24 @@ configRetry:
 		log.Debugf("Typha supports node resource updates: %v", supportsNodeResourceUpdates)
 		configParams.SetUseNodeResourceUpdates(supportsNodeResourceUpdates)
 
+		if typhaRebalancer != nil {
+			typhaRebalancer.SetActiveConnection(typhaAddr, typhaConnection)
+			if typhaRebalance {
+				typhaRebalancer.StartRebalancing()
+			}
+			if typhaTLSReload {
+				tlsWatcher := newTyphaTLSWatcher(configParams.TyphaCertFile, configParams.TyphaKeyFile,
+					configParams.TyphaCAFile, typhaRebalancer.Reconnect)
+				go tlsWatcher.watch(configParams.TyphaTLSReloadInterval)
+			}
+		}
+
 		go func() {
 			typhaConnection.Finished.Wait()
+			if typhaRebalancer != nil && !typhaRebalancer.IsActive(typhaConnection) {
+				// We moved to a new Typha connection, which the rebalancer monitors.
+				return
+			}
 			failureReportChan <- "Connection to Typha failed"
 		}()
 	}
@@ -603,14 +765,43 @@ configRetry:
 		)
 		dpConnector.statusReporter.Start()
 	}
+	if configParams.KubePodConditionsEnabled {
+		if k8sClientSet == nil {
+			log.Warn("No Kubernetes client available, ignoring KubePodConditionsEnabled.")
+		} else {
+			log.Info("Pod conditions enabled, starting pod conditions reporter")
+			dpConnector.podConditions = podconditions.NewReporter(k8sClientSet)
+			dpConnector.podConditions.Start()
+		}
+	}
+	if configParams.WorkloadStatsPort != 0 {
+		log.WithField("port", configParams.WorkloadStatsPort).Info("Starting pod statistics server")
+		dpConnector.podStats = podstats.NewServer(configParams.FelixHostname)
+		go dpConnector.podStats.Serve(podstats.Config{
+			Host: configParams.WorkloadStatsHost,
+			Port: configParams.WorkloadStatsPort,
+		})
+	}
 
 	// Start communicating with the dataplane driver.
 	dpConnector.Start()
 
+	if resyncSlot != nil {
+		go releaseResyncSlotWhenReady(resyncSlot, healthAggregator, configParams.StartupResyncMaxHold)
+	}
+
 	if policySyncProcessor != nil {
 		log.WithField("policySyncPathPrefix", configParams.PolicySyncPathPrefix).Info(
 			"Policy sync API enabled.  Starting the policy sync server.")
 		policySyncProcessor.Start()
+		if configParams.PolicySyncAppProtocolHintsEnabled {
+			if k8sClientSet == nil {
+				log.Warn("No Kubernetes client available, ignoring PolicySyncAppProtocolHintsEnabled.")
+			} else {
+				log.Info("Sending application protocol hints to policy sync clients.")
+				policysync.NewAppProtocolWatcher(k8sClientSet, configParams.FelixHostname, toPolicySync).Start(context.Background())
+			}
+		}
 		sc := make(chan *sync.WaitGroup)
 		stopSignalChans = append(stopSignalChans, sc)
 		go policySyncAPIBinder.SearchAndBind(sc)
@@ -634,15 +825,33 @@ configRetry:
 		go dp.ServePrometheusMetrics(configParams)
 	}
 
+	if configParams.HandoffSocketPath != "" {
+		// Now that we're up, let the next Felix take over from us.
+		l, err := listenForHandoff(configParams.HandoffSocketPath)
+		if err != nil {
+			log.WithError(err).Error("Failed to listen on handoff socket, the next Felix won't be able to take over from us.")
+		} else {
+			go serveHandoff(l, shutdownRequests, tookOverAt)
+		}
+	}
+
 	// Register signal handlers to dump memory/CPU profiles.
 	logutils.RegisterProfilingSignalHandlers(configParams)
 
 	// Now monitor the worker process and our worker threads and shut
 	// down the process gracefully if they fail.
-	monitorAndManageShutdown(failureReportChan, dpDriverCmd, stopSignalChans)
+	monitorAndManageShutdown(failureReportChan, shutdownRequests, configParams.ShutdownMode,
+		dpDriver, dpDriverCmd, stopSignalChans)
 }
 
-func monitorAndManageShutdown(failureReportChan <-chan string, driverCmd *exec.Cmd, stopSignalChans []chan<- *sync.WaitGroup) {
+func monitorAndManageShutdown(
+	failureReportChan <-chan string,
+	shutdownRequests <-chan shutdownRequest,
+	shutdownMode string,
+	driver dp.DataplaneDriver,
+	driverCmd *exec.Cmd,
+	stopSignalChans []chan<- *sync.WaitGroup,
+) {
 	// Ask the runtime to tell us if we get a term/int signal.
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, syscall.SIGTERM)
@@ -668,6 +877,7 @@ func monitorAndManageShutdown(failureReportChan <-chan string, driverCmd *exec.C
 	driverAlreadyStopped := driverCmd == nil
 	receivedFatalSignal := false
 	var reason string
+	var shutdownReq shutdownRequest
 	select {
 	case <-driverStoppedC:
 		reason = "Driver stopped"
@@ -680,11 +890,26 @@ func monitorAndManageShutdown(failureReportChan <-chan string, driverCmd *exec.C
 			reason = fmt.Sprintf("Received OS signal %v", sig)
 			receivedFatalSignal = true
 		}
+	case shutdownReq = <-shutdownRequests:
+		reason = shutdownReq.reason
+		shutdownMode = shutdownReq.mode
+		// Exit straight away, as for a signal.
+		receivedFatalSignal = true
 	case reason = <-failureReportChan:
 	}
 	logCxt := log.WithField("reason", reason)
 	logCxt.Warn("Felix is shutting down")
 
+	if !receivedFatalSignal {
+		// We're restarting after a failure or a config change, rather than being asked to
+		// stop, so always leave the dataplane for the next Felix.
+		shutdownMode = config.ShutdownModeLeaveDataplane
+	}
+	cleanUpDataplane(driver, shutdownMode)
+	if shutdownReq.done != nil {
+		close(shutdownReq.done)
+	}
+
 	// Notify other components to stop.  Each notified component must call Done() on the wait
 	// group when it has completed its shutdown.
 	var stopWG sync.WaitGroup
@@ -905,6 +1130,25 @@ type DataplaneConnector struct {
 	firstStatusReportSent bool
 
 	wireguardStatUpdateFromDataplane chan *proto.WireguardStatusUpdate
+
+	// policySyncUpdates, if non-nil, receives the workload endpoint statuses reported by the
+	// dataplane so that they can be passed on to policy sync clients.
+	policySyncUpdates chan<- interface{}
+
+	// podConditions, if non-nil, writes the workload endpoint statuses reported by the dataplane
+	// to the Kubernetes pods.
+	podConditions *podconditions.Reporter
+	// podStats, if non-nil, learns the local pods' interfaces from the workload endpoint updates
+	// that we send to the dataplane, and serves their statistics.
+	podStats *podstats.Server
+
+	// capabilitiesFromDataplane carries the capabilities that the dataplane driver advertises
+	// from the read loop to the send loop.
+	capabilitiesFromDataplane chan *proto.DataplaneCapabilities
+	capabilitiesKnown         bool
+	// capabilities is nil if the driver didn't advertise its capabilities in time.
+	capabilities   *proto.DataplaneCapabilities
+	warnedFeatures map[string]bool
 }
 
 type Startable interface {
@@ -929,6 +1173,8 @@ func newConnector(configParams *config.Config,
 		failureReportChan:                failureReportChan,
 		dataplane:                        dataplane,
 		wireguardStatUpdateFromDataplane: make(chan *proto.WireguardStatusUpdate, 1),
+		capabilitiesFromDataplane:        make(chan *proto.DataplaneCapabilities, 1),
+		warnedFeatures:                   map[string]bool{},
 	}
 	return felixConn
 }
@@ -953,10 +1199,22 @@ func (fc *DataplaneConnector) readMessagesFromDataplane() {
 			if fc.statusReporter != nil {
 				fc.StatusUpdatesFromDataplane <- msg
 			}
+			if fc.policySyncUpdates != nil {
+				fc.policySyncUpdates <- msg
+			}
+			if fc.podConditions != nil {
+				fc.podConditions.OnUpdate(msg)
+			}
 		case *proto.WorkloadEndpointStatusRemove:
 			if fc.statusReporter != nil {
 				fc.StatusUpdatesFromDataplane <- msg
 			}
+			if fc.policySyncUpdates != nil {
+				fc.policySyncUpdates <- msg
+			}
+			if fc.podConditions != nil {
+				fc.podConditions.OnUpdate(msg)
+			}
 		case *proto.HostEndpointStatusUpdate:
 			if fc.statusReporter != nil {
 				fc.StatusUpdatesFromDataplane <- msg
@@ -967,6 +1225,12 @@ func (fc *DataplaneConnector) readMessagesFromDataplane() {
 			}
 		case *proto.WireguardStatusUpdate:
 			fc.wireguardStatUpdateFromDataplane <- msg
+		case *proto.DataplaneCapabilities:
+			select {
+			case fc.capabilitiesFromDataplane <- msg:
+			default:
+				log.WithField("msg", msg).Warning("Ignoring repeated capabilities from dataplane")
+			}
 		default:
 			log.WithField("msg", msg).Warning("Unknown message from dataplane")
 		}
@@ -1102,6 +1366,11 @@ func (fc *DataplaneConnector) sendMessagesToDataplaneDriver() {
 	var config map[string]string
 	for {
 		msg := <-fc.ToDataplane
+		if _, ok := msg.(*proto.ConfigUpdate); !ok && !fc.capabilitiesKnown {
+			// The driver may wait for its config before advertising its capabilities so
+			// only wait for them once we've got something else to send.
+			fc.waitForCapabilities()
+		}
 		switch msg := msg.(type) {
 		case *proto.InSync:
 			log.Info("Datastore now in sync.")
@@ -1165,6 +1434,26 @@ func (fc *DataplaneConnector) sendMessagesToDataplaneDriver() {
 		case *calc.DatastoreNotReady:
 			log.Warn("Datastore became unready, need to restart.")
 			fc.shutDownProcess("datastore became unready")
+		case *proto.WorkloadEndpointUpdate, *proto.WorkloadEndpointRemove:
+			if fc.podStats != nil {
+				fc.podStats.OnUpdate(msg)
+			}
+		}
+		if feature := requiredFeature(msg); feature != "" && !hasFeature(fc.capabilities, feature) {
+			if !fc.warnedFeatures[feature] {
+				log.WithField("feature", feature).Warning(
+					"Dataplane driver doesn't support feature, not sending it updates for that feature.")
+				fc.warnedFeatures[feature] = true
+			}
+			continue
+		}
+		if !hasFeature(fc.capabilities, featureIPv6) {
+			// Driver can't program IPv6 so don't send it IPv6 routes, IP set members or
+			// endpoint addresses.
+			msg = stripIPv6(msg)
+			if msg == nil {
+				continue
+			}
 		}
 		if err := fc.dataplane.SendMessage(msg); err != nil {
 			fc.shutDownProcess("Failed to write to dataplane driver")
@@ -1172,6 +1461,30 @@ func (fc *DataplaneConnector) sendMessagesToDataplaneDriver() {
 	}
 }
 
+// waitForCapabilities waits for the dataplane driver to advertise its capabilities and checks
+// them against the config, shutting down if the driver can't support the config.  Drivers that
+// predate capability negotiation never advertise; after a timeout, we assume that they support
+// everything.
+func (fc *DataplaneConnector) waitForCapabilities() {
+	fc.capabilitiesKnown = true
+	select {
+	case caps := <-fc.capabilitiesFromDataplane:
+		log.WithField("capabilities", caps).Info("Received capabilities from dataplane driver.")
+		fc.capabilities = caps
+	case <-time.After(capabilitiesTimeout):
+		log.Warning("Dataplane driver didn't advertise its capabilities, assuming it supports all features.")
+		return
+	}
+	warning, err := checkCapabilities(fc.config, fc.capabilities)
+	if warning != "" {
+		log.Warning(warning)
+	}
+	if err != nil {
+		log.WithError(err).Error("Dataplane driver can't support the configuration.")
+		fc.shutDownProcess("dataplane driver doesn't support configured features")
+	}
+}
+
 func (fc *DataplaneConnector) shutDownProcess(reason string) {
 	// Send a failure report to the managed shutdown thread then give it
 	// a few seconds to do the shutdown.
@@ -1192,6 +1505,24 @@ func (fc *DataplaneConnector) Start() {
 	go fc.handleWireguardStatUpdateFromDataplane()
 }
 
+// releaseResyncSlotWhenReady releases our startup resync slot once Felix reports ready, which
+// means that it has finished its initial resync, or after maxHold, whichever comes first.
+func releaseResyncSlotWhenReady(slot *resynclease.Slot, healthAgg *health.HealthAggregator, maxHold time.Duration) {
+	defer slot.Release()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	deadline := time.Now().Add(maxHold)
+	for time.Now().Before(deadline) {
+		if healthAgg.Summary().Ready {
+			log.Info("Finished startup resync.")
+			return
+		}
+		<-ticker.C
+	}
+	log.Warn("Startup resync is taking longer than StartupResyncMaxHold; releasing slot.")
+}
+
 func discoverTyphaAddr(configParams *config.Config, k8sClientSet kubernetes.Interface) (string, error) {
 	typhaDiscoveryOpts := configParams.TyphaDiscoveryOpts()
 	typhaDiscoveryOpts = append(typhaDiscoveryOpts, discovery.WithKubeClient(k8sClientSet))
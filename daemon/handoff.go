@@ -0,0 +1,168 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/config"
+)
+
+// The handoff protocol is deliberately simple: the new Felix connects to the old Felix's handoff
+// socket and sends handoffRequest.  The old Felix stops programming the dataplane, writes its
+// checkpoint, replies with handoffDone and then closes the connection.  The connection also
+// closes if the old Felix exits, so, either way, once the connection closes the new Felix can take
+// over.  It only trusts the checkpoint if it got handoffDone.  A Felix that took over less than
+// minHandoffInterval ago replies with handoffRefused instead.
+const (
+	handoffRequest = "handoff\n"
+	handoffDone    = "done\n"
+	handoffRefused = "refused\n"
+)
+
+// minHandoffInterval is how long a Felix that took over from another Felix refuses to hand off in
+// its turn.  Without it, two Felixes that are each restarted after handing off (for example, the
+// pods of two overlapping DaemonSets) would hand the dataplane back and forth indefinitely.
+const minHandoffInterval = time.Minute
+
+// handoffResult is the outcome of requestHandoff.
+type handoffResult int
+
+const (
+	// handoffNoFelix means that there was no Felix running to take over from.
+	handoffNoFelix handoffResult = iota
+	// handoffSucceeded means that the running Felix handed off the dataplane to us.
+	handoffSucceeded
+	// handoffIncomplete means that the running Felix didn't finish handing off: it may still be
+	// programming the dataplane or writing its checkpoint, so the checkpoint can't be trusted.
+	handoffIncomplete
+	// handoffDeclined means that the running Felix took over recently and refused to hand off.
+	handoffDeclined
+)
+
+// requestHandoff asks the Felix that's listening on the handoff socket, if any, to hand off the
+// dataplane to us, and waits for it to do so, or for the timeout.
+func requestHandoff(socketPath string, timeout time.Duration) handoffResult {
+	logCxt := log.WithField("socket", socketPath)
+	conn, err := net.DialTimeout("unix", socketPath, timeout)
+	if err != nil {
+		logCxt.WithError(err).Info("No running Felix to take over from.")
+		return handoffNoFelix
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	logCxt.Info("Asking the running Felix to hand off the dataplane.")
+	if _, err := io.WriteString(conn, handoffRequest); err != nil {
+		logCxt.WithError(err).Warn("Failed to send handoff request, continuing anyway.")
+		return handoffIncomplete
+	}
+	reply, err := ioutil.ReadAll(conn)
+	if err != nil {
+		logCxt.WithError(err).Warn("Running Felix didn't hand off the dataplane in time, continuing anyway.")
+		return handoffIncomplete
+	}
+	switch string(reply) {
+	case handoffDone:
+		logCxt.Info("Running Felix handed off the dataplane.")
+		return handoffSucceeded
+	case handoffRefused:
+		logCxt.Warn("Running Felix took over recently and refused to hand off.")
+		return handoffDeclined
+	}
+	logCxt.WithField("reply", string(reply)).Warn(
+		"Running Felix didn't confirm the handoff, continuing anyway.")
+	return handoffIncomplete
+}
+
+// discardCheckpoint removes a checkpoint that we can't trust, if there is one.
+func discardCheckpoint(path string) {
+	logCxt := log.WithField("file", path)
+	if err := os.Remove(path); err == nil {
+		logCxt.Warn("Discarded dataplane checkpoint from a Felix that didn't finish handing off.")
+	} else if !os.IsNotExist(err) {
+		logCxt.WithError(err).Error("Failed to discard dataplane checkpoint.")
+	}
+}
+
+// listenForHandoff listens on the handoff socket, replacing the socket of the Felix that we took
+// over from.
+func listenForHandoff(socketPath string) (net.Listener, error) {
+	_ = os.Remove(socketPath)
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// serveHandoff handles handoff requests from a new Felix by passing them on to
+// monitorAndManageShutdown() as leave-dataplane shutdowns.  tookOverAt is when we took over from
+// another Felix, or zero if we didn't.
+func serveHandoff(l net.Listener, requests chan<- shutdownRequest, tookOverAt time.Time) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			log.WithError(err).Error("Failed to accept on handoff socket, stopping handoff server.")
+			return
+		}
+		go handleHandoff(conn, requests, tookOverAt)
+	}
+}
+
+func handleHandoff(conn net.Conn, requests chan<- shutdownRequest, tookOverAt time.Time) {
+	defer conn.Close()
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != handoffRequest {
+		log.WithError(err).WithField("request", line).Warn("Ignoring bad handoff request.")
+		return
+	}
+	if !tookOverAt.IsZero() && time.Since(tookOverAt) < minHandoffInterval {
+		log.WithField("tookOverAt", tookOverAt).Warn(
+			"Refusing handoff request, we only just took over from another Felix.")
+		if _, err := io.WriteString(conn, handoffRefused); err != nil {
+			log.WithError(err).Warn("Failed to refuse handoff to the new Felix.")
+		}
+		return
+	}
+	req := shutdownRequest{
+		mode:   config.ShutdownModeLeaveDataplane,
+		reason: "Handing off to a new Felix",
+		done:   make(chan struct{}),
+	}
+	select {
+	case requests <- req:
+	default:
+		// Already shutting down; closing the connection lets the new Felix carry on.
+		log.Info("Handoff requested while already shutting down.")
+		return
+	}
+	<-req.done
+	if _, err := io.WriteString(conn, handoffDone); err != nil {
+		log.WithError(err).Warn("Failed to confirm handoff to the new Felix.")
+		return
+	}
+	log.Info("Handed off the dataplane to the new Felix.")
+}
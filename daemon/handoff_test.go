@@ -0,0 +1,109 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Handoff", func() {
+	var (
+		dir        string
+		socketPath string
+		requests   chan shutdownRequest
+		l          net.Listener
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "felix-handoff")
+		Expect(err).NotTo(HaveOccurred())
+		socketPath = filepath.Join(dir, "handoff.sock")
+		requests = make(chan shutdownRequest, 1)
+		l, err = listenForHandoff(socketPath)
+		Expect(err).NotTo(HaveOccurred())
+		go serveHandoff(l, requests, time.Time{})
+	})
+
+	AfterEach(func() {
+		l.Close()
+		os.RemoveAll(dir)
+	})
+
+	// requestHandoffAsync returns a channel that receives requestHandoff's result.
+	requestHandoffAsync := func(timeout time.Duration) chan handoffResult {
+		done := make(chan handoffResult, 1)
+		go func() {
+			done <- requestHandoff(socketPath, timeout)
+		}()
+		return done
+	}
+
+	It("should wait for the old Felix to leave the dataplane", func() {
+		done := requestHandoffAsync(10 * time.Second)
+		var req shutdownRequest
+		Eventually(requests).Should(Receive(&req))
+		Expect(req.mode).To(Equal("leave-dataplane"))
+		Consistently(done, "100ms").ShouldNot(Receive())
+		close(req.done)
+		Eventually(done).Should(Receive(Equal(handoffSucceeded)))
+	})
+
+	It("should give up after the timeout and not trust the checkpoint", func() {
+		done := requestHandoffAsync(100 * time.Millisecond)
+		Eventually(requests).Should(Receive())
+		Eventually(done).Should(Receive(Equal(handoffIncomplete)))
+	})
+
+	It("should not trust the checkpoint if the old Felix is already shutting down", func() {
+		requests <- shutdownRequest{mode: "full-cleanup"}
+		done := requestHandoffAsync(10 * time.Second)
+		Eventually(done).Should(Receive(Equal(handoffIncomplete)))
+	})
+
+	It("should carry on if there's no old Felix", func() {
+		l.Close()
+		os.Remove(socketPath)
+		done := requestHandoffAsync(10 * time.Second)
+		Eventually(done).Should(Receive(Equal(handoffNoFelix)))
+	})
+
+	It("should refuse to hand off if it only just took over", func() {
+		l.Close()
+		var err error
+		l, err = listenForHandoff(socketPath)
+		Expect(err).NotTo(HaveOccurred())
+		go serveHandoff(l, requests, time.Now())
+
+		done := requestHandoffAsync(10 * time.Second)
+		Eventually(done).Should(Receive(Equal(handoffDeclined)))
+		Expect(requests).NotTo(Receive())
+	})
+
+	It("should discard an untrusted checkpoint", func() {
+		cpFile := filepath.Join(dir, "checkpoint.json")
+		Expect(ioutil.WriteFile(cpFile, []byte("{}"), 0600)).To(Succeed())
+		discardCheckpoint(cpFile)
+		_, err := os.Stat(cpFile)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+})
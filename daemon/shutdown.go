@@ -0,0 +1,77 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/config"
+	dp "github.com/projectcalico/felix/dataplane"
+)
+
+// shutdownRequest asks monitorAndManageShutdown() to shut Felix down with the given shutdown mode.
+// If done is non-nil, it is closed once the dataplane has been prepared for the shutdown.
+type shutdownRequest struct {
+	mode   string
+	reason string
+	done   chan struct{}
+}
+
+// shutdownHandler serves /debug/shutdown?mode=<mode>, which asks Felix to shut down with the
+// given shutdown mode.
+type shutdownHandler struct {
+	requests chan<- shutdownRequest
+}
+
+func (h shutdownHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	mode := strings.ToLower(req.URL.Query().Get("mode"))
+	switch mode {
+	case config.ShutdownModeLeaveDataplane, config.ShutdownModeFlushCalicoChains, config.ShutdownModeFullCleanup:
+	default:
+		http.Error(w, "mode must be one of "+config.ShutdownModeLeaveDataplane+", "+
+			config.ShutdownModeFlushCalicoChains+" or "+config.ShutdownModeFullCleanup, http.StatusBadRequest)
+		return
+	}
+	select {
+	case h.requests <- shutdownRequest{mode: mode, reason: "Shutdown requested via the debug server"}:
+		log.WithField("mode", mode).Warn("Shutdown requested via the debug server.")
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		http.Error(w, "Already shutting down", http.StatusConflict)
+	}
+}
+
+// cleanUpDataplane prepares the dataplane for Felix to shut down according to the shutdown mode,
+// if the dataplane driver supports it.
+func cleanUpDataplane(driver dp.DataplaneDriver, mode string) {
+	logCxt := log.WithField("mode", mode)
+	cleaner, ok := driver.(dp.DataplaneCleaner)
+	if !ok {
+		if mode != config.ShutdownModeLeaveDataplane {
+			logCxt.Warn("Dataplane driver can't clean up the dataplane; leaving it in place.")
+		}
+		return
+	}
+	if err := cleaner.CleanUpDataplane(mode); err != nil {
+		logCxt.WithError(err).Error("Failed to clean up the dataplane.")
+	}
+}
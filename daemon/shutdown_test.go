@@ -0,0 +1,101 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+type mockCleaner struct {
+	modes []string
+}
+
+func (m *mockCleaner) SendMessage(msg interface{}) error {
+	return nil
+}
+
+func (m *mockCleaner) RecvMessage() (interface{}, error) {
+	return nil, nil
+}
+
+func (m *mockCleaner) CleanUpDataplane(mode string) error {
+	m.modes = append(m.modes, mode)
+	return nil
+}
+
+var _ = Describe("Shutdown handler", func() {
+	var (
+		requests chan shutdownRequest
+		handler  shutdownHandler
+	)
+
+	BeforeEach(func() {
+		requests = make(chan shutdownRequest, 1)
+		handler = shutdownHandler{requests: requests}
+	})
+
+	serve := func(method, url string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, url, nil))
+		return rec.Code
+	}
+
+	DescribeTable("should pass on valid modes",
+		func(url, expectedMode string) {
+			Expect(serve(http.MethodPost, url)).To(Equal(http.StatusAccepted))
+			var req shutdownRequest
+			Expect(requests).To(Receive(&req))
+			Expect(req.mode).To(Equal(expectedMode))
+		},
+		Entry("leave-dataplane", "/debug/shutdown?mode=leave-dataplane", "leave-dataplane"),
+		Entry("flush-calico-chains", "/debug/shutdown?mode=flush-calico-chains", "flush-calico-chains"),
+		Entry("full-cleanup mixed case", "/debug/shutdown?mode=Full-Cleanup", "full-cleanup"),
+	)
+
+	It("should reject a bad mode", func() {
+		Expect(serve(http.MethodPost, "/debug/shutdown?mode=everything")).To(Equal(http.StatusBadRequest))
+		Expect(requests).NotTo(Receive())
+	})
+
+	It("should reject a GET", func() {
+		Expect(serve(http.MethodGet, "/debug/shutdown?mode=full-cleanup")).To(Equal(http.StatusMethodNotAllowed))
+		Expect(requests).NotTo(Receive())
+	})
+
+	It("should reject a second request", func() {
+		Expect(serve(http.MethodPost, "/debug/shutdown?mode=full-cleanup")).To(Equal(http.StatusAccepted))
+		Expect(serve(http.MethodPost, "/debug/shutdown?mode=full-cleanup")).To(Equal(http.StatusConflict))
+	})
+})
+
+var _ = Describe("cleanUpDataplane", func() {
+	It("should clean up with the cleanup modes", func() {
+		cleaner := &mockCleaner{}
+		cleanUpDataplane(cleaner, "flush-calico-chains")
+		cleanUpDataplane(cleaner, "full-cleanup")
+		Expect(cleaner.modes).To(Equal([]string{"flush-calico-chains", "full-cleanup"}))
+	})
+
+	It("should pass leave-dataplane to the driver so it can write its checkpoint", func() {
+		cleaner := &mockCleaner{}
+		cleanUpDataplane(cleaner, "leave-dataplane")
+		Expect(cleaner.modes).To(Equal([]string{"leave-dataplane"}))
+	})
+})
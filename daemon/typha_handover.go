@@ -0,0 +1,195 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/libcalico-go/lib/backend/api"
+	"github.com/projectcalico/libcalico-go/lib/backend/model"
+)
+
+// typhaHandover sits between the Typha connection(s) and the rest of Felix, allowing Felix to
+// switch to a different Typha without restarting.  It records the keys (and their revisions) that
+// it has passed downstream.  While a handover is in progress, the new connection's updates are
+// buffered until it reports that it's in sync; then the new connection's snapshot is compared
+// with what we already have so that only the differences (including deletions) are passed on.
+type typhaHandover struct {
+	lock       sync.Mutex
+	downstream api.SyncerCallbacks
+
+	nextConnID uint64
+	activeConn uint64
+
+	// known maps from key.String() to the key and revision of each KV that we've passed
+	// downstream.
+	known map[string]knownKV
+
+	pendingConn    uint64
+	pendingUpdates map[string]api.Update
+	pendingDone    chan struct{}
+}
+
+type knownKV struct {
+	key      model.Key
+	revision string
+}
+
+func newTyphaHandover(downstream api.SyncerCallbacks) *typhaHandover {
+	return &typhaHandover{
+		downstream: downstream,
+		known:      map[string]knownKV{},
+	}
+}
+
+// InitialCallbacks returns the callbacks for the first connection, which passes its updates
+// straight through.
+func (h *typhaHandover) InitialCallbacks() api.SyncerCallbacks {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.nextConnID++
+	h.activeConn = h.nextConnID
+	return &handoverConnCallbacks{handover: h, connID: h.activeConn}
+}
+
+// StartHandover returns the callbacks for a new connection and a channel that is closed once
+// that connection has taken over.  Any previous, incomplete handover is abandoned.
+func (h *typhaHandover) StartHandover() (api.SyncerCallbacks, <-chan struct{}) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.nextConnID++
+	h.pendingConn = h.nextConnID
+	h.pendingUpdates = map[string]api.Update{}
+	h.pendingDone = make(chan struct{})
+	return &handoverConnCallbacks{handover: h, connID: h.pendingConn}, h.pendingDone
+}
+
+// AbortHandover abandons the handover in progress, if any.  The current connection stays
+// active.
+func (h *typhaHandover) AbortHandover() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.pendingConn = 0
+	h.pendingUpdates = nil
+	h.pendingDone = nil
+}
+
+func (h *typhaHandover) onStatusUpdated(connID uint64, status api.SyncStatus) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	switch connID {
+	case h.activeConn:
+		h.downstream.OnStatusUpdated(status)
+	case h.pendingConn:
+		if status == api.InSync {
+			h.completeHandover()
+		}
+	}
+}
+
+func (h *typhaHandover) onUpdates(connID uint64, updates []api.Update) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	switch connID {
+	case h.activeConn:
+		h.recordUpdates(updates)
+		h.downstream.OnUpdates(updates)
+	case h.pendingConn:
+		for _, u := range updates {
+			h.pendingUpdates[u.Key.String()] = u
+		}
+	default:
+		log.WithField("connID", connID).Debug("Ignoring updates from old Typha connection.")
+	}
+}
+
+// completeHandover makes the pending connection active and sends the differences between its
+// snapshot and the KVs that we already have downstream.  Must be called with the lock held.
+func (h *typhaHandover) completeHandover() {
+	var diff []api.Update
+	for k, u := range h.pendingUpdates {
+		old, exists := h.known[k]
+		if u.Value == nil {
+			if exists {
+				diff = append(diff, deletionOf(old.key))
+			}
+			continue
+		}
+		if exists && old.revision != "" && old.revision == u.Revision {
+			// Unchanged.
+			continue
+		}
+		if exists {
+			u.UpdateType = api.UpdateTypeKVUpdated
+		} else {
+			u.UpdateType = api.UpdateTypeKVNew
+		}
+		diff = append(diff, u)
+	}
+	for k, old := range h.known {
+		if _, ok := h.pendingUpdates[k]; !ok {
+			diff = append(diff, deletionOf(old.key))
+		}
+	}
+	log.WithFields(log.Fields{
+		"snapshotSize": len(h.pendingUpdates),
+		"numChanges":   len(diff),
+	}).Info("New Typha connection in sync, handing over.")
+
+	h.activeConn = h.pendingConn
+	close(h.pendingDone)
+	h.pendingConn = 0
+	h.pendingUpdates = nil
+	h.pendingDone = nil
+
+	if len(diff) > 0 {
+		h.recordUpdates(diff)
+		h.downstream.OnUpdates(diff)
+	}
+}
+
+func (h *typhaHandover) recordUpdates(updates []api.Update) {
+	for _, u := range updates {
+		k := u.Key.String()
+		if u.Value == nil {
+			delete(h.known, k)
+		} else {
+			h.known[k] = knownKV{key: u.Key, revision: u.Revision}
+		}
+	}
+}
+
+func deletionOf(key model.Key) api.Update {
+	return api.Update{
+		KVPair:     model.KVPair{Key: key},
+		UpdateType: api.UpdateTypeKVDeleted,
+	}
+}
+
+// handoverConnCallbacks tags the callbacks from one Typha connection with its ID.
+type handoverConnCallbacks struct {
+	handover *typhaHandover
+	connID   uint64
+}
+
+func (c *handoverConnCallbacks) OnStatusUpdated(status api.SyncStatus) {
+	c.handover.onStatusUpdated(c.connID, status)
+}
+
+func (c *handoverConnCallbacks) OnUpdates(updates []api.Update) {
+	c.handover.onUpdates(c.connID, updates)
+}
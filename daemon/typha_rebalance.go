@@ -0,0 +1,343 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/jitter"
+	"github.com/projectcalico/libcalico-go/lib/backend/api"
+	"github.com/projectcalico/typha/pkg/syncclient"
+)
+
+const (
+	typhaPortName        = "calico-typha"
+	typhaLoadMetric      = "typha_connections_active"
+	typhaLoadHintTimeout = 5 * time.Second
+	typhaHandoverTimeout = 5 * time.Minute
+	typhaHelloTimeout    = 10 * time.Second
+	typhaRebalanceJitter = 0.5 // Fraction of the interval to add as jitter.
+)
+
+var (
+	countTyphaRebalances = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "felix_typha_rebalances",
+		Help: "Number of times Felix tried to move to a less-loaded Typha, by result.",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(countTyphaRebalances)
+}
+
+// typhaRebalancer manages Felix's connection to Typha, allowing it to move to a new connection
+// without restarting.  If rebalancing is enabled, it periodically compares the load on the Typha
+// that we're connected to with the load on the other Typha instances and, if ours is overloaded,
+// moves to the least-loaded one.
+// Typha reports its load as the number of active connections in its Prometheus metrics.
+//
+// To avoid every client of an overloaded Typha moving at once, each client moves with a
+// probability proportional to the excess load, so that, on average, just enough clients move to
+// bring the Typha down to the average load.
+type typhaRebalancer struct {
+	config            *config.Config
+	handover          *typhaHandover
+	newConnection     func(addr string, callbacks api.SyncerCallbacks) *syncclient.SyncerClient
+	failureReportChan chan<- string
+
+	discoverAddrs func() ([]string, error)
+	getLoad       func(addr string) (float64, error)
+	randFloat     func() float64
+
+	// moveLock serialises moves, which may be triggered by rebalancing or by a TLS reload.
+	moveLock sync.Mutex
+
+	lock         sync.Mutex
+	activeAddr   string
+	activeConn   *syncclient.SyncerClient
+	activeCancel context.CancelFunc
+}
+
+func newTyphaRebalancer(
+	configParams *config.Config,
+	k8sClient kubernetes.Interface,
+	handover *typhaHandover,
+	newConnection func(addr string, callbacks api.SyncerCallbacks) *syncclient.SyncerClient,
+	failureReportChan chan<- string,
+) *typhaRebalancer {
+	return &typhaRebalancer{
+		config:            configParams,
+		handover:          handover,
+		newConnection:     newConnection,
+		failureReportChan: failureReportChan,
+		discoverAddrs: func() ([]string, error) {
+			return discoverAllTyphaAddrs(k8sClient, configParams.TyphaK8sNamespace, configParams.TyphaK8sServiceName)
+		},
+		getLoad: func(addr string) (float64, error) {
+			return fetchTyphaLoad(addr, configParams.TyphaLoadHintsPort)
+		},
+		randFloat: rand.Float64,
+	}
+}
+
+// InitialContext returns the context to start the first connection with, which the rebalancer
+// cancels when it moves to a different Typha.
+func (r *typhaRebalancer) InitialContext() context.Context {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	ctx, cancel := context.WithCancel(context.Background())
+	r.activeCancel = cancel
+	return ctx
+}
+
+// SetActiveConnection records the connection that the rebalancer starts from.
+func (r *typhaRebalancer) SetActiveConnection(addr string, conn *syncclient.SyncerClient) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.activeAddr = addr
+	r.activeConn = conn
+}
+
+// IsActive returns true if the given connection is the one that Felix is currently using.
+func (r *typhaRebalancer) IsActive(conn *syncclient.SyncerClient) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.activeConn == conn
+}
+
+// StartRebalancing starts the background loop that checks the Typha loads.
+func (r *typhaRebalancer) StartRebalancing() {
+	go r.loop()
+}
+
+// Reconnect replaces the current Typha connection with a new connection to the same Typha.
+// This is used to pick up new TLS credentials.
+func (r *typhaRebalancer) Reconnect() error {
+	r.lock.Lock()
+	addr := r.activeAddr
+	r.lock.Unlock()
+	return r.moveTo(addr)
+}
+
+func (r *typhaRebalancer) loop() {
+	interval := r.config.TyphaRebalanceInterval
+	ticker := jitter.NewTicker(interval, time.Duration(float64(interval)*typhaRebalanceJitter))
+	for range ticker.C {
+		r.maybeRebalance()
+	}
+}
+
+func (r *typhaRebalancer) maybeRebalance() {
+	r.lock.Lock()
+	currentAddr := r.activeAddr
+	r.lock.Unlock()
+
+	addrs, err := r.discoverAddrs()
+	if err != nil {
+		log.WithError(err).Warn("Failed to list Typha instances, skipping rebalance check.")
+		return
+	}
+	loads := map[string]float64{}
+	for _, addr := range addrs {
+		load, err := r.getLoad(addr)
+		if err != nil {
+			log.WithError(err).WithField("addr", addr).Debug("Failed to get Typha load hint.")
+			continue
+		}
+		loads[addr] = load
+	}
+	target, ok := chooseTyphaRebalanceTarget(currentAddr, loads, r.config.TyphaRebalanceThreshold, r.randFloat())
+	if !ok {
+		return
+	}
+	log.WithFields(log.Fields{
+		"current": currentAddr,
+		"target":  target,
+		"loads":   loads,
+	}).Info("Typha is overloaded, moving to a less-loaded instance.")
+	if err := r.moveTo(target); err != nil {
+		log.WithError(err).WithField("target", target).Warn("Failed to move to new Typha, staying put.")
+		countTyphaRebalances.WithLabelValues("failed").Inc()
+		return
+	}
+	countTyphaRebalances.WithLabelValues("success").Inc()
+}
+
+// moveTo connects to the given Typha and, once the new connection is in sync, switches over
+// to it and closes the old connection.
+func (r *typhaRebalancer) moveTo(addr string) error {
+	r.moveLock.Lock()
+	defer r.moveLock.Unlock()
+
+	callbacks, handedOver := r.handover.StartHandover()
+	conn := r.newConnection(addr, callbacks)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := conn.Start(ctx); err != nil {
+		cancel()
+		r.handover.AbortHandover()
+		return err
+	}
+	supportsNodeResourceUpdates, err := conn.SupportsNodeResourceUpdates(typhaHelloTimeout)
+	if err == nil && supportsNodeResourceUpdates != r.config.UseNodeResourceUpdates() {
+		err = fmt.Errorf("new Typha's support for node resource updates doesn't match the old one's")
+	}
+	if err != nil {
+		cancel()
+		r.handover.AbortHandover()
+		return err
+	}
+	connFinished := make(chan struct{})
+	go func() {
+		conn.Finished.Wait()
+		close(connFinished)
+	}()
+	select {
+	case <-handedOver:
+	case <-connFinished:
+		cancel()
+		r.handover.AbortHandover()
+		return fmt.Errorf("new Typha connection failed before it was in sync")
+	case <-time.After(typhaHandoverTimeout):
+		cancel()
+		r.handover.AbortHandover()
+		return fmt.Errorf("timed out waiting for new Typha connection to sync")
+	}
+
+	r.lock.Lock()
+	oldCancel := r.activeCancel
+	r.activeAddr = addr
+	r.activeConn = conn
+	r.activeCancel = cancel
+	r.lock.Unlock()
+
+	log.WithField("addr", addr).Info("Moved to new Typha connection, closing old connection.")
+	oldCancel()
+	go func() {
+		<-connFinished
+		if r.IsActive(conn) {
+			r.failureReportChan <- "Connection to Typha failed"
+		}
+	}()
+	return nil
+}
+
+// chooseTyphaRebalanceTarget decides whether to move from the current Typha, returning the
+// least-loaded Typha if so.  We only consider moving if the current Typha's load exceeds the
+// average by more than the threshold (a fraction of the average).  Then we move with probability
+// (current - average) / current; randValue is a random number in [0, 1).
+func chooseTyphaRebalanceTarget(
+	current string,
+	loads map[string]float64,
+	threshold float64,
+	randValue float64,
+) (string, bool) {
+	currentLoad, ok := loads[current]
+	if !ok || len(loads) < 2 || currentLoad <= 0 {
+		return "", false
+	}
+	var addrs []string
+	var total float64
+	for addr, load := range loads {
+		addrs = append(addrs, addr)
+		total += load
+	}
+	avg := total / float64(len(loads))
+	if currentLoad <= avg*(1+threshold) {
+		return "", false
+	}
+	if randValue >= (currentLoad-avg)/currentLoad {
+		return "", false
+	}
+	// Sort for determinism when there are ties.
+	sort.Strings(addrs)
+	target := ""
+	for _, addr := range addrs {
+		if target == "" || loads[addr] < loads[target] {
+			target = addr
+		}
+	}
+	if target == current {
+		return "", false
+	}
+	return target, true
+}
+
+// discoverAllTyphaAddrs returns the addresses of all the ready Typha instances behind the
+// Typha service.
+func discoverAllTyphaAddrs(k8sClient kubernetes.Interface, namespace, serviceName string) ([]string, error) {
+	eps, err := k8sClient.CoreV1().Endpoints(namespace).Get(context.Background(), serviceName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var addrs []string
+	for _, subset := range eps.Subsets {
+		var port int32
+		for _, p := range subset.Ports {
+			if p.Name == typhaPortName {
+				port = p.Port
+				break
+			}
+		}
+		if port == 0 {
+			continue
+		}
+		for _, a := range subset.Addresses {
+			addrs = append(addrs, net.JoinHostPort(a.IP, fmt.Sprint(port)))
+		}
+	}
+	return addrs, nil
+}
+
+// fetchTyphaLoad reads the number of active connections from the given Typha's Prometheus
+// metrics endpoint.
+func fetchTyphaLoad(typhaAddr string, metricsPort int) (float64, error) {
+	host, _, err := net.SplitHostPort(typhaAddr)
+	if err != nil {
+		return 0, err
+	}
+	client := http.Client{Timeout: typhaLoadHintTimeout}
+	resp, err := client.Get(fmt.Sprintf("http://%s/metrics", net.JoinHostPort(host, fmt.Sprint(metricsPort))))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status from Typha metrics endpoint: %s", resp.Status)
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	family, ok := families[typhaLoadMetric]
+	if !ok || len(family.Metric) == 0 {
+		return 0, fmt.Errorf("Typha didn't report %s", typhaLoadMetric)
+	}
+	return family.Metric[0].GetGauge().GetValue(), nil
+}
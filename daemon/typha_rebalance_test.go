@@ -0,0 +1,177 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/libcalico-go/lib/backend/api"
+	"github.com/projectcalico/libcalico-go/lib/backend/model"
+)
+
+type recordingCallbacks struct {
+	statuses []api.SyncStatus
+	updates  []api.Update
+}
+
+func (r *recordingCallbacks) OnStatusUpdated(status api.SyncStatus) {
+	r.statuses = append(r.statuses, status)
+}
+
+func (r *recordingCallbacks) OnUpdates(updates []api.Update) {
+	r.updates = append(r.updates, updates...)
+}
+
+func kvUpdate(name, value, revision string) api.Update {
+	return api.Update{
+		KVPair: model.KVPair{
+			Key:      model.GlobalConfigKey{Name: name},
+			Value:    value,
+			Revision: revision,
+		},
+		UpdateType: api.UpdateTypeKVNew,
+	}
+}
+
+var _ = Describe("Typha handover", func() {
+	var (
+		downstream *recordingCallbacks
+		handover   *typhaHandover
+		oldConn    api.SyncerCallbacks
+	)
+
+	BeforeEach(func() {
+		downstream = &recordingCallbacks{}
+		handover = newTyphaHandover(downstream)
+		oldConn = handover.InitialCallbacks()
+		oldConn.OnUpdates([]api.Update{
+			kvUpdate("unchanged", "a", "1"),
+			kvUpdate("changed", "b", "2"),
+			kvUpdate("deleted", "c", "3"),
+		})
+		oldConn.OnStatusUpdated(api.InSync)
+		downstream.updates = nil
+	})
+
+	It("should pass through updates from the initial connection", func() {
+		oldConn.OnUpdates([]api.Update{kvUpdate("new", "d", "4")})
+		Expect(downstream.updates).To(Equal([]api.Update{kvUpdate("new", "d", "4")}))
+		Expect(downstream.statuses).To(Equal([]api.SyncStatus{api.InSync}))
+	})
+
+	It("should only send the differences when the new connection takes over", func() {
+		newConn, done := handover.StartHandover()
+		newConn.OnUpdates([]api.Update{
+			kvUpdate("unchanged", "a", "1"),
+			kvUpdate("changed", "b2", "5"),
+			kvUpdate("added", "e", "6"),
+		})
+		// The old connection stays active until the new one is in sync.
+		oldConn.OnUpdates([]api.Update{kvUpdate("old", "f", "7")})
+		Expect(downstream.updates).To(HaveLen(1))
+		downstream.updates = nil
+
+		newConn.OnStatusUpdated(api.InSync)
+		Expect(done).To(BeClosed())
+		changed := kvUpdate("changed", "b2", "5")
+		changed.UpdateType = api.UpdateTypeKVUpdated
+		Expect(downstream.updates).To(ConsistOf(
+			changed,
+			kvUpdate("added", "e", "6"),
+			deletionOf(model.GlobalConfigKey{Name: "deleted"}),
+			deletionOf(model.GlobalConfigKey{Name: "old"}),
+		))
+		// The handover doesn't resend the in-sync status.
+		Expect(downstream.statuses).To(Equal([]api.SyncStatus{api.InSync}))
+
+		// Now the old connection is ignored and the new one passes through.
+		downstream.updates = nil
+		oldConn.OnUpdates([]api.Update{kvUpdate("stale", "g", "8")})
+		newConn.OnUpdates([]api.Update{kvUpdate("fresh", "h", "9")})
+		Expect(downstream.updates).To(Equal([]api.Update{kvUpdate("fresh", "h", "9")}))
+	})
+
+	It("should keep the old connection after an aborted handover", func() {
+		newConn, done := handover.StartHandover()
+		handover.AbortHandover()
+		newConn.OnUpdates([]api.Update{kvUpdate("ignored", "x", "10")})
+		newConn.OnStatusUpdated(api.InSync)
+		Expect(done).NotTo(BeClosed())
+		oldConn.OnUpdates([]api.Update{kvUpdate("new", "d", "4")})
+		Expect(downstream.updates).To(Equal([]api.Update{kvUpdate("new", "d", "4")}))
+	})
+})
+
+var _ = DescribeTable("Typha rebalance target",
+	func(loads map[string]float64, randValue float64, expectedTarget string) {
+		target, ok := chooseTyphaRebalanceTarget("a", loads, 0.2, randValue)
+		Expect(ok).To(Equal(expectedTarget != ""))
+		Expect(target).To(Equal(expectedTarget))
+	},
+	Entry("balanced", map[string]float64{"a": 10, "b": 10, "c": 10}, 0.0, ""),
+	Entry("within threshold", map[string]float64{"a": 11, "b": 10, "c": 9}, 0.0, ""),
+	Entry("overloaded", map[string]float64{"a": 20, "b": 5, "c": 5}, 0.0, "b"),
+	Entry("overloaded but unlucky", map[string]float64{"a": 20, "b": 5, "c": 5}, 0.5, ""),
+	Entry("overloaded and lucky", map[string]float64{"a": 20, "b": 6, "c": 4}, 0.49, "c"),
+	Entry("only one Typha", map[string]float64{"a": 20}, 0.0, ""),
+	Entry("unknown load", map[string]float64{"b": 20, "c": 5}, 0.0, ""),
+)
+
+var _ = Describe("Typha load hints", func() {
+	var server *httptest.Server
+	var metrics string
+
+	BeforeEach(func() {
+		metrics = "# HELP typha_connections_active Number of open client connections.\n" +
+			"# TYPE typha_connections_active gauge\n" +
+			"typha_connections_active 42\n"
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = fmt.Fprint(w, metrics)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	fetch := func() (float64, error) {
+		u, err := url.Parse(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		host, port, err := net.SplitHostPort(u.Host)
+		Expect(err).NotTo(HaveOccurred())
+		var portNum int
+		_, err = fmt.Sscan(port, &portNum)
+		Expect(err).NotTo(HaveOccurred())
+		return fetchTyphaLoad(net.JoinHostPort(host, "5473"), portNum)
+	}
+
+	It("should read the number of active connections", func() {
+		Expect(fetch()).To(BeNumerically("==", 42))
+	})
+
+	It("should fail if the metric is missing", func() {
+		metrics = "typha_connections_accepted 12\n"
+		_, err := fetch()
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,142 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	countTyphaTLSReloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "felix_typha_tls_reloads",
+		Help: "Number of times Felix reconnected to Typha to pick up new TLS files, by result.",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(countTyphaTLSReloads)
+}
+
+// typhaTLSWatcher watches the TLS files that Felix uses to connect to Typha and calls reconnect
+// once they have all been updated.  Since the Typha client loads the files each time it
+// connects, the new connection picks up the new certificate.  As for the metrics server, we poll
+// the files' modification times because Kubernetes secrets are updated by swapping symlinks.
+type typhaTLSWatcher struct {
+	certFile, keyFile, caFile string
+	reconnect                 func() error
+
+	modTimes  []time.Time
+	lastError error
+}
+
+func newTyphaTLSWatcher(certFile, keyFile, caFile string, reconnect func() error) *typhaTLSWatcher {
+	w := &typhaTLSWatcher{
+		certFile:  certFile,
+		keyFile:   keyFile,
+		caFile:    caFile,
+		reconnect: reconnect,
+	}
+	// Record the files that the current connection was made with.
+	w.modTimes, _ = w.statFiles()
+	return w
+}
+
+func (w *typhaTLSWatcher) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		w.maybeReconnect()
+	}
+}
+
+func (w *typhaTLSWatcher) statFiles() ([]time.Time, error) {
+	var modTimes []time.Time
+	for _, name := range []string{w.certFile, w.keyFile, w.caFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+	return modTimes, nil
+}
+
+// maybeReconnect reconnects to Typha if any of the files have changed.  We check that the new
+// files are valid first so that, if we catch them part way through an update, we keep the
+// existing connection and try again on the next tick.
+func (w *typhaTLSWatcher) maybeReconnect() {
+	modTimes, err := w.statFiles()
+	if err != nil {
+		w.recordError(err)
+		return
+	}
+	if timesEqual(modTimes, w.modTimes) {
+		return
+	}
+	if err := validateTyphaTLSFiles(w.certFile, w.keyFile, w.caFile); err != nil {
+		w.recordError(err)
+		return
+	}
+	log.WithField("certFile", w.certFile).Info("Typha TLS files changed, reconnecting to Typha.")
+	if err := w.reconnect(); err != nil {
+		countTyphaTLSReloads.WithLabelValues("failed").Inc()
+		w.recordError(err)
+		return
+	}
+	countTyphaTLSReloads.WithLabelValues("success").Inc()
+	w.modTimes = modTimes
+	w.lastError = nil
+}
+
+func (w *typhaTLSWatcher) recordError(err error) {
+	if w.lastError == nil || w.lastError.Error() != err.Error() {
+		log.WithError(err).Error("Failed to reload Typha TLS files, keeping existing connection.")
+	}
+	w.lastError = err
+}
+
+func validateTyphaTLSFiles(certFile, keyFile, caFile string) error {
+	if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+		return err
+	}
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return err
+	}
+	if !x509.NewCertPool().AppendCertsFromPEM(pem) {
+		return errors.New("no certificates found in Typha CA file " + caFile)
+	}
+	return nil
+}
+
+func timesEqual(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// makeSelfSignedCert returns the PEM-encoded certificate and key for a new self-signed cert.
+func makeSelfSignedCert(cn string) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+var _ = Describe("Typha TLS watcher", func() {
+	var (
+		dir                       string
+		certFile, keyFile, caFile string
+		modTime                   time.Time
+		watcher                   *typhaTLSWatcher
+		numReconnects             int
+		reconnectErr              error
+	)
+
+	writeFile := func(name string, data []byte) {
+		Expect(ioutil.WriteFile(name, data, 0600)).To(Succeed())
+		// Make sure that the modification time changes, even on filesystems with coarse
+		// timestamps.
+		modTime = modTime.Add(time.Second)
+		Expect(os.Chtimes(name, modTime, modTime)).To(Succeed())
+	}
+
+	writeCert := func(cn string) {
+		certPEM, keyPEM := makeSelfSignedCert(cn)
+		writeFile(certFile, certPEM)
+		writeFile(keyFile, keyPEM)
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "typha-tls")
+		Expect(err).NotTo(HaveOccurred())
+		certFile = filepath.Join(dir, "tls.crt")
+		keyFile = filepath.Join(dir, "tls.key")
+		caFile = filepath.Join(dir, "ca.crt")
+		modTime = time.Now().Add(-time.Hour).Truncate(time.Second)
+
+		writeCert("felix")
+		caPEM, _ := makeSelfSignedCert("ca")
+		writeFile(caFile, caPEM)
+
+		numReconnects = 0
+		reconnectErr = nil
+		watcher = newTyphaTLSWatcher(certFile, keyFile, caFile, func() error {
+			numReconnects++
+			return reconnectErr
+		})
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("should not reconnect if the files haven't changed", func() {
+		watcher.maybeReconnect()
+		Expect(numReconnects).To(Equal(0))
+	})
+
+	It("should reconnect once when the certificate changes", func() {
+		writeCert("felix-2")
+		watcher.maybeReconnect()
+		Expect(numReconnects).To(Equal(1))
+		watcher.maybeReconnect()
+		Expect(numReconnects).To(Equal(1))
+	})
+
+	It("should wait until the certificate and key match", func() {
+		certPEM, keyPEM := makeSelfSignedCert("felix-2")
+		writeFile(certFile, certPEM)
+		watcher.maybeReconnect()
+		Expect(numReconnects).To(Equal(0))
+
+		writeFile(keyFile, keyPEM)
+		watcher.maybeReconnect()
+		Expect(numReconnects).To(Equal(1))
+	})
+
+	It("should ignore an invalid CA file", func() {
+		writeFile(caFile, []byte("not a cert"))
+		watcher.maybeReconnect()
+		Expect(numReconnects).To(Equal(0))
+	})
+
+	It("should retry if reconnecting fails", func() {
+		writeCert("felix-2")
+		reconnectErr = errors.New("dummy error")
+		watcher.maybeReconnect()
+		Expect(numReconnects).To(Equal(1))
+
+		reconnectErr = nil
+		watcher.maybeReconnect()
+		Expect(numReconnects).To(Equal(2))
+		watcher.maybeReconnect()
+		Expect(numReconnects).To(Equal(2))
+	})
+})
@@ -19,10 +19,8 @@ package dataplane
 import (
 	"math/bits"
 	"net"
-	"net/http"
 	"os/exec"
 	"runtime/debug"
-	"strconv"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -30,23 +28,31 @@ import (
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	apiv3 "github.com/projectcalico/api/pkg/apis/projectcalico/v3"
+	"github.com/projectcalico/felix/alerts"
 	"github.com/projectcalico/felix/aws"
 	"github.com/projectcalico/felix/bpf"
 	"github.com/projectcalico/felix/bpf/conntrack"
 	"github.com/projectcalico/felix/bpf/tc"
+	"github.com/projectcalico/felix/capture"
 	"github.com/projectcalico/felix/config"
 	extdataplane "github.com/projectcalico/felix/dataplane/external"
 	"github.com/projectcalico/felix/dataplane/inactive"
 	intdataplane "github.com/projectcalico/felix/dataplane/linux"
+	"github.com/projectcalico/felix/dataplane/sim"
+	"github.com/projectcalico/felix/debugserver"
+	"github.com/projectcalico/felix/dnscache"
+	"github.com/projectcalico/felix/flowlogs"
 	"github.com/projectcalico/felix/idalloc"
 	"github.com/projectcalico/felix/ifacemonitor"
 	"github.com/projectcalico/felix/ipsets"
 	"github.com/projectcalico/felix/logutils"
 	"github.com/projectcalico/felix/markbits"
+	"github.com/projectcalico/felix/metricsserver"
+	"github.com/projectcalico/felix/nodeconditions"
 	"github.com/projectcalico/felix/rules"
+	"github.com/projectcalico/felix/servicecidrs"
 	"github.com/projectcalico/felix/wireguard"
 	"github.com/projectcalico/libcalico-go/lib/health"
 )
@@ -63,6 +69,15 @@ func StartDataplaneDriver(configParams *config.Config,
 		return &inactive.InactiveDataplane{}, nil
 	}
 
+	if configParams.SimulatedDataplaneEnabled {
+		log.WithField("stateFile", configParams.SimulatedDataplaneStateFile).Info(
+			"Using simulated dataplane driver.")
+		simDP := sim.New(configParams.SimulatedDataplaneStateFile)
+		simDP.Start()
+		debugserver.RegisterStateDumper("sim-dataplane", simDP.DumpState)
+		return simDP, nil
+	}
+
 	if configParams.UseInternalDataplaneDriver {
 		log.Info("Using internal (linux) dataplane driver.")
 		// If kube ipvs interface is present, enable ipvs support.  In BPF mode, we bypass kube-proxy so IPVS
@@ -102,7 +117,7 @@ func StartDataplaneDriver(configParams *config.Config,
 		// avoid allocating the others to minimize the number of bits in use.
 
 		// The accept bit is a long-lived bit used to communicate between chains.
-		var markAccept, markPass, markScratch0, markScratch1, markWireguard, markEndpointNonCaliEndpoint uint32
+		var markAccept, markPass, markScratch0, markScratch1, markWireguard, markFailsafeAudit, markEndpointNonCaliEndpoint uint32
 		markAccept, _ = markBitsManager.NextSingleBitMark()
 		if !configParams.BPFEnabled {
 			// The pass bit is used to communicate from a policy chain up to the endpoint chain.
@@ -125,6 +140,22 @@ func StartDataplaneDriver(configParams *config.Config,
 			}
 		}
 
+		failsafeAuditEnabled := configParams.FailsafeAuditEnabled
+		if failsafeAuditEnabled && configParams.BPFEnabled {
+			log.Warn("Failsafe audit mode is not supported in BPF mode, ignoring FailsafeAuditEnabled.")
+			failsafeAuditEnabled = false
+		}
+		if failsafeAuditEnabled {
+			log.Info("Failsafe audit mode enabled, allocating a mark bit")
+			markFailsafeAudit, _ = markBitsManager.NextSingleBitMark()
+			if markFailsafeAudit == 0 {
+				log.WithFields(log.Fields{
+					"Name":     "felix-iptables",
+					"MarkMask": allowedMarkBits,
+				}).Panic("Failed to allocate a mark bit for failsafe audit mode, not enough mark bits available.")
+			}
+		}
+
 		// markPass and the scratch-1 bits are only used in iptables mode.
 		if markAccept == 0 || markScratch0 == 0 || !configParams.BPFEnabled && (markPass == 0 || markScratch1 == 0) {
 			log.WithFields(log.Fields{
@@ -135,13 +166,16 @@ func StartDataplaneDriver(configParams *config.Config,
 
 		// Mark bits for endpoint mark. Currently Felix takes the rest bits from mask available for use.
 		markEndpointMark, allocated := markBitsManager.NextBlockBitsMark(markBitsManager.AvailableMarkBitCount())
+		// In iptables mode, VRF support dispatches packets from workloads in a VRF on their
+		// endpoint mark.
+		vrfIptablesEnabled := configParams.VRFSupportEnabled && !configParams.BPFEnabled
+		if (kubeIPVSSupportEnabled || vrfIptablesEnabled) && allocated == 0 {
+			log.WithFields(log.Fields{
+				"Name":     "felix-iptables",
+				"MarkMask": allowedMarkBits,
+			}).Panic("Not enough mark bits available for endpoint mark.")
+		}
 		if kubeIPVSSupportEnabled {
-			if allocated == 0 {
-				log.WithFields(log.Fields{
-					"Name":     "felix-iptables",
-					"MarkMask": allowedMarkBits,
-				}).Panic("Not enough mark bits available for endpoint mark.")
-			}
 			// Take lowest bit position (position 1) from endpoint mark mask reserved for non-calico endpoint.
 			markEndpointNonCaliEndpoint = uint32(1) << uint(bits.TrailingZeros32(markEndpointMark))
 		}
@@ -150,6 +184,7 @@ func StartDataplaneDriver(configParams *config.Config,
 			"passMark":            markPass,
 			"scratch0Mark":        markScratch0,
 			"scratch1Mark":        markScratch1,
+			"failsafeAuditMark":   markFailsafeAudit,
 			"endpointMark":        markEndpointMark,
 			"endpointMarkNonCali": markEndpointNonCaliEndpoint,
 		}).Info("Calculated iptables mark bits")
@@ -173,6 +208,111 @@ func StartDataplaneDriver(configParams *config.Config,
 		// If wireguard is enabled, update the failsafe ports to include the wireguard port.
 		failsafeInboundHostPorts := configParams.FailsafeInboundHostPorts
 		failsafeOutboundHostPorts := configParams.FailsafeOutboundHostPorts
+		var nodeLocalDNSAddrs []string
+		if configParams.ClusterServiceAllowNodeLocalDNS {
+			nodeLocalDNSAddrs = configParams.ClusterServiceNodeLocalDNSAddrs
+		}
+		// The calculation graph only populates the NAT outgoing exclusion IP set in iptables mode.
+		natOutgoingExclusionsEnabled := configParams.NATOutgoingExclusionSelector != ""
+		if natOutgoingExclusionsEnabled && configParams.BPFEnabled {
+			log.Warn("NAT outgoing exclusions are not supported in BPF mode, ignoring NATOutgoingExclusionSelector.")
+			natOutgoingExclusionsEnabled = false
+		}
+		natOutgoingSourcePools := configParams.NATOutgoingSourcePools
+		if len(natOutgoingSourcePools) > 0 && configParams.BPFEnabled {
+			log.Warn("NAT outgoing source pools are not supported in BPF mode, ignoring NATOutgoingSourcePools.")
+			natOutgoingSourcePools = nil
+		}
+		// Egress gateway steering relies on IPIP and iptables; each steering rule gets its own
+		// routing table.
+		egressGatewaySteering := configParams.EgressGatewaySteering
+		if len(egressGatewaySteering) > 0 && (configParams.BPFEnabled || !configParams.IpInIpEnabled) {
+			log.Warn("Egress gateway steering requires IPIP and is not supported in BPF mode, ignoring EgressGatewaySteering.")
+			egressGatewaySteering = nil
+		}
+		var egressGatewayTableIndices []int
+		for range egressGatewaySteering {
+			idx, err := routeTableIndexAllocator.GrabIndex()
+			if err != nil {
+				log.WithError(err).Panic("Unable to assign table indices for egress gateway steering.")
+			}
+			egressGatewayTableIndices = append(egressGatewayTableIndices, idx)
+		}
+		// Each EgressInterfaces item gets its own routing table.
+		egressInterfaces := configParams.EgressInterfaces
+		if len(egressInterfaces) > 0 && configParams.BPFEnabled {
+			log.Warn("Egress interfaces are not supported in BPF mode, ignoring EgressInterfaces.")
+			egressInterfaces = nil
+		}
+		var egressInterfaceTableIndices []int
+		for range egressInterfaces {
+			idx, err := routeTableIndexAllocator.GrabIndex()
+			if err != nil {
+				log.WithError(err).Panic("Unable to assign table indices for egress interfaces.")
+			}
+			egressInterfaceTableIndices = append(egressInterfaceTableIndices, idx)
+		}
+		// Proxy neighbor entries rely on IP sets that the calculation graph only maintains when
+		// BPF mode is off.
+		workloadProxyNeighbors := configParams.WorkloadProxyNeighbors
+		if len(workloadProxyNeighbors) > 0 && configParams.BPFEnabled {
+			log.Warn("Workload proxy neighbors are not supported in BPF mode, ignoring WorkloadProxyNeighbors.")
+			workloadProxyNeighbors = nil
+		}
+		// Likewise for conntrack helpers.
+		conntrackHelpers := configParams.ConntrackHelpers
+		if len(conntrackHelpers) > 0 && configParams.BPFEnabled {
+			log.Warn("Conntrack helpers are not supported in BPF mode, ignoring ConntrackHelpers.")
+			conntrackHelpers = nil
+		}
+		// The BPF programs own the workload interfaces' ingress hook.
+		workloadBandwidthLimitsEnabled := configParams.WorkloadBandwidthLimitsEnabled
+		if workloadBandwidthLimitsEnabled && configParams.BPFEnabled {
+			log.Warn("Workload bandwidth limits are not supported in BPF mode, ignoring WorkloadBandwidthLimitsEnabled.")
+			workloadBandwidthLimitsEnabled = false
+		}
+		flowOffloadEnabled := configParams.FlowOffloadEnabled
+		if flowOffloadEnabled && configParams.BPFEnabled {
+			log.Warn("Flow offload is not supported in BPF mode, ignoring FlowOffloadEnabled.")
+			flowOffloadEnabled = false
+		}
+		tcPolicyOffloadInterfaces := configParams.TCPolicyOffloadInterfaces
+		if len(tcPolicyOffloadInterfaces) > 0 && configParams.BPFEnabled {
+			log.Warn("tc policy offload is not supported in BPF mode, ignoring TCPolicyOffloadInterfaces.")
+			tcPolicyOffloadInterfaces = nil
+		}
+		hostEndpointsCoverChildIfaces := configParams.HostEndpointsCoverChildInterfaces
+		if hostEndpointsCoverChildIfaces && configParams.BPFEnabled {
+			log.Warn("Host endpoint child interfaces are not supported in BPF mode, ignoring HostEndpointsCoverChildInterfaces.")
+			hostEndpointsCoverChildIfaces = false
+		}
+		var nodeConditions *nodeconditions.Reporter
+		if configParams.KubeNodeConditionsEnabled {
+			if k8sClientSet != nil {
+				nodeConditions = nodeconditions.NewReporter(k8sClientSet, configParams.FelixHostname)
+				nodeConditions.Start()
+				nodeConditions.ReportNotReady()
+			} else {
+				log.Warn("No Kubernetes client available, ignoring KubeNodeConditionsEnabled.")
+			}
+		}
+		var dataplaneSummaryCallback func(nodeconditions.DataplaneSummary)
+		if nodeConditions != nil {
+			dataplaneSummaryCallback = nodeConditions.ReportDataplaneSummary
+		}
+		var serviceCIDRChecker *servicecidrs.Checker
+		if configParams.ServiceCIDRCheckEnabled {
+			if k8sClientSet != nil {
+				serviceCIDRChecker = servicecidrs.NewChecker(k8sClientSet)
+				serviceCIDRChecker.Start()
+			} else {
+				log.Warn("No Kubernetes client available, ignoring ServiceCIDRCheckEnabled.")
+			}
+		}
+		var kubeletAPIPort int
+		if configParams.ClusterServiceAllowKubeletAPI {
+			kubeletAPIPort = configParams.ClusterServiceKubeletAPIPort
+		}
 		if configParams.WireguardEnabled {
 			var found = false
 			for _, i := range failsafeInboundHostPorts {
@@ -225,6 +365,9 @@ func StartDataplaneDriver(configParams *config.Config,
 				InterfaceExcludes: configParams.InterfaceExclude,
 				ResyncInterval:    configParams.InterfaceRefreshInterval,
 			},
+			IfaceFlapDampingWindow: configParams.InterfaceFlapDampingWindow,
+			SysctlRefreshInterval:  configParams.SysctlRefreshInterval,
+			SysctlOverrides:        configParams.SysctlOverrides,
 			RulesConfig: rules.Config{
 				WorkloadIfacePrefixes: configParams.InterfacePrefixes(),
 
@@ -241,8 +384,10 @@ func StartDataplaneDriver(configParams *config.Config,
 					nil,
 				),
 
-				KubeNodePortRanges:     configParams.KubeNodePortRanges,
-				KubeIPVSSupportEnabled: kubeIPVSSupportEnabled,
+				KubeNodePortRanges:        configParams.KubeNodePortRanges,
+				KubeIPVSSupportEnabled:    kubeIPVSSupportEnabled,
+				VRFSupportEnabled:         vrfIptablesEnabled,
+				KubernetesProfilelessMode: configParams.KubernetesProfilelessModeEnabled,
 
 				OpenStackSpecialCasesEnabled: configParams.OpenstackActive(),
 				OpenStackMetadataIP:          net.ParseIP(configParams.MetadataAddr),
@@ -270,6 +415,7 @@ func StartDataplaneDriver(configParams *config.Config,
 				WireguardInterfaceName: configParams.WireguardInterfaceName,
 				WireguardIptablesMark:  markWireguard,
 				WireguardListeningPort: configParams.WireguardListeningPort,
+				WireguardDSCP:          uint8(configParams.WireguardDSCP),
 				RouteSource:            configParams.RouteSource,
 
 				IptablesLogPrefix:         configParams.LogPrefix,
@@ -279,14 +425,34 @@ func StartDataplaneDriver(configParams *config.Config,
 
 				FailsafeInboundHostPorts:  failsafeInboundHostPorts,
 				FailsafeOutboundHostPorts: failsafeOutboundHostPorts,
+				FailsafeAuditEnabled:      failsafeAuditEnabled,
+				FailsafeAuditIptablesMark: markFailsafeAudit,
+				RPFModeOverrides:          configParams.InterfaceRPFModes,
+				IptablesHookChains:        configParams.IptablesHookChains,
+				MulticastEnabled:          configParams.MulticastEnabled,
+
+				ClusterServiceNodeLocalDNSAddrs:  nodeLocalDNSAddrs,
+				ClusterServiceAllowKubeletProbes: configParams.ClusterServiceAllowKubeletProbes,
+				ClusterServiceKubeletAPIPort:     kubeletAPIPort,
 
 				DisableConntrackInvalid: configParams.DisableConntrackInvalidCheck,
+				SCTPMultihomingEnabled:  configParams.SCTPMultihomingEnabled,
+				GTPUNotrackPort:         uint16(configParams.GTPUNotrackPort),
 
-				NATPortRange:                       configParams.NATPortRange,
+				NATPortRange:                       configParams.EffectiveNATPortRange(),
 				IptablesNATOutgoingInterfaceFilter: configParams.IptablesNATOutgoingInterfaceFilter,
 				NATOutgoingAddress:                 configParams.NATOutgoingAddress,
+				NATOutgoingExclusionsEnabled:       natOutgoingExclusionsEnabled,
+				NATOutgoingSourcePools:             natOutgoingSourcePools,
+				NATOutgoingPreservePorts:           configParams.NATOutgoingPreservePorts,
+				EgressGatewaySteering:              egressGatewaySteering,
+				ConntrackHelpers:                   conntrackHelpers,
 				BPFEnabled:                         configParams.BPFEnabled,
 				ServiceLoopPrevention:              configParams.ServiceLoopPrevention,
+				BlockedCIDRs:                       configParams.CIDRBlocklist,
+				CIDRBlocklistMetricsEnabled:        configParams.CIDRBlocklistMetricsEnabled,
+				NAT64Prefixes:                      configParams.NAT64Prefixes,
+				FlowLogsEnabled:                    configParams.FlowLogsEnabled,
 			},
 			Wireguard: wireguard.Config{
 				Enabled:             wireguardEnabled,
@@ -301,6 +467,8 @@ func StartDataplaneDriver(configParams *config.Config,
 			IPIPMTU:                        configParams.IpInIpMtu,
 			VXLANMTU:                       configParams.VXLANMTU,
 			VXLANPort:                      configParams.VXLANPort,
+			IPIPDSCP:                       configParams.IPIPDSCP,
+			VXLANDSCP:                      configParams.VXLANDSCP,
 			IptablesBackend:                configParams.IptablesBackend,
 			IptablesRefreshInterval:        configParams.IptablesRefreshInterval,
 			RouteRefreshInterval:           configParams.RouteRefreshInterval,
@@ -317,6 +485,7 @@ func StartDataplaneDriver(configParams *config.Config,
 			IPv6Enabled:                    configParams.Ipv6Support,
 			StatusReportingInterval:        configParams.ReportingIntervalSecs,
 			XDPRefreshInterval:             configParams.XDPRefreshInterval,
+			BackgroundResyncMaxCPUPressure: configParams.BackgroundResyncMaxCPUPressure,
 
 			NetlinkTimeout: configParams.NetlinkTimeoutSecs,
 
@@ -328,14 +497,59 @@ func StartDataplaneDriver(configParams *config.Config,
 				// a good time to force a GC and return any RAM that we can.
 				debug.FreeOSMemory()
 
+				if nodeConditions != nil {
+					nodeConditions.ReportReady()
+				}
+
 				if configParams.DebugMemoryProfilePath == "" {
 					return
 				}
 				logutils.DumpHeapMemoryProfile(configParams.DebugMemoryProfilePath)
 			},
+			DegradedRulesCallback: func(issues []string) {
+				if nodeConditions != nil {
+					nodeConditions.ReportDegraded(issues)
+				}
+			},
+			DataplaneSummaryCallback:           dataplaneSummaryCallback,
+			DataplaneSummaryInterval:           configParams.KubeNodeDataplaneSummaryInterval,
 			HealthAggregator:                   healthAggregator,
 			DebugSimulateDataplaneHangAfter:    configParams.DebugSimulateDataplaneHangAfter,
 			ExternalNodesCidrs:                 configParams.ExternalNodesCIDRList,
+			VXLANFabricPlanes:                  configParams.VXLANFabricPlanes,
+			VXLANFabricPlaneProbePort:          configParams.VXLANFabricPlaneProbePort,
+			VXLANFabricPlaneProbeInterval:      configParams.VXLANFabricPlaneProbeInterval,
+			VXLANFabricPlaneProbeTimeout:       configParams.VXLANFabricPlaneProbeTimeout,
+			VXLANMulticastGroup:                configParams.VXLANMulticastGroup,
+			MulticastIGMPVersion:               configParams.MulticastIGMPVersion,
+			MulticastMLDVersion:                configParams.MulticastMLDVersion,
+			MulticastGroupRoutes:               configParams.MulticastGroupRoutes,
+			WorkloadProxyNeighbors:             workloadProxyNeighbors,
+			WorkloadExtraRoutes:                configParams.WorkloadExtraRoutes,
+			WorkloadConnectionLimits:           configParams.WorkloadConnectionLimits,
+			WorkloadBandwidthLimitsEnabled:     workloadBandwidthLimitsEnabled,
+			WorkloadBandwidthLimits:            configParams.WorkloadBandwidthLimits,
+			FlowOffloadEnabled:                 flowOffloadEnabled,
+			FlowOffloadHardware:                configParams.FlowOffloadHardware,
+			FlowOffloadHostInterfaces:          configParams.FlowOffloadHostInterfaces,
+			FlowOffloadExcludeSelector:         configParams.FlowOffloadExcludeSelector,
+			TCPolicyOffloadInterfaces:          tcPolicyOffloadInterfaces,
+			VRFSupportEnabled:                  configParams.VRFSupportEnabled,
+			HostEndpointsCoverChildInterfaces:  hostEndpointsCoverChildIfaces,
+			ServiceCIDRChecker:                 serviceCIDRChecker,
+			IptablesOtherBackendCleanupEnabled: configParams.IptablesOtherBackendCleanupEnabled,
+			IptablesIPSetInlineMaxMembers:      configParams.IptablesIPSetInlineMaxMembers,
+			DNSPolicyEnabled:                   configParams.DNSPolicyEnabled,
+			DNSPolicyRefreshInterval:           configParams.DNSPolicyRefreshInterval,
+			ControlPlanePriorityIfacePattern:   configParams.ControlPlanePriorityIfacePattern,
+			ControlPlanePriorityPorts:          configParams.ControlPlanePriorityPorts,
+			AutoHostEndpointInterfaces:         configParams.AutoHostEndpointInterfaces,
+			HostEndpointsFollowExpectedIPs:     configParams.HostEndpointsFollowExpectedIPs,
+			EgressGatewayRouteTableIndices:     egressGatewayTableIndices,
+			EgressGatewayRoutingRulePriority:   configParams.EgressGatewayRoutingRulePriority,
+			EgressInterfaces:                   egressInterfaces,
+			EgressInterfaceRouteTableIndices:   egressInterfaceTableIndices,
+			EgressInterfaceRoutingRulePriority: configParams.EgressInterfaceRoutingRulePriority,
 			SidecarAccelerationEnabled:         configParams.SidecarAccelerationEnabled,
 			BPFEnabled:                         configParams.BPFEnabled,
 			BPFDisableUnprivileged:             configParams.BPFDisableUnprivileged,
@@ -343,6 +557,8 @@ func StartDataplaneDriver(configParams *config.Config,
 			BPFKubeProxyIptablesCleanupEnabled: configParams.BPFKubeProxyIptablesCleanupEnabled,
 			BPFLogLevel:                        configParams.BPFLogLevel,
 			BPFExtToServiceConnmark:            configParams.BPFExtToServiceConnmark,
+			BPFNATBackendSelection:             configParams.BPFNATBackendSelection,
+			BPFHostPortsEnabled:                configParams.BPFHostPortsEnabled,
 			BPFDataIfacePattern:                configParams.BPFDataIfacePattern,
 			BPFCgroupV2:                        configParams.DebugBPFCgroupV2,
 			BPFMapRepin:                        configParams.DebugBPFMapRepinEnabled,
@@ -350,6 +566,7 @@ func StartDataplaneDriver(configParams *config.Config,
 			KubeProxyEndpointSlicesEnabled:     configParams.BPFKubeProxyEndpointSlicesEnabled,
 			XDPEnabled:                         configParams.XDPEnabled,
 			XDPAllowGeneric:                    configParams.GenericXDPEnabled,
+			XDPChainingEnabled:                 configParams.XDPChainingEnabled,
 			BPFConntrackTimeouts:               conntrack.DefaultTimeouts(), // FIXME make timeouts configurable
 			RouteTableManager:                  routeTableIndexAllocator,
 			MTUIfacePattern:                    configParams.MTUIfacePattern,
@@ -361,15 +578,94 @@ func StartDataplaneDriver(configParams *config.Config,
 			RouteSource: configParams.RouteSource,
 
 			KubernetesProvider: configParams.KubernetesProvider(),
+
+			WorkloadMetricsEnabled:  configParams.PrometheusMetricsEnabled && configParams.PrometheusWorkloadMetricsEnabled,
+			WorkloadTCPStatsEnabled: configParams.PrometheusMetricsEnabled && configParams.PrometheusWorkloadTCPStatsEnabled,
+
+			HostEndpointPolicyCountersEnabled: configParams.HostEndpointPolicyCountersEnabled,
+
+			CheckpointFile: configParams.DataplaneCheckpointFile,
+
+			ApplyThrottleInterval: configParams.DataplaneApplyThrottleInterval,
+			ApplyThrottleBurst:    configParams.DataplaneApplyThrottleBurst,
+			MaxBatchSize:          configParams.DataplaneMaxBatchSize,
+			ApplyDebounceInterval: configParams.DataplaneApplyDebounceInterval,
 		}
 
 		if configParams.BPFExternalServiceMode == "dsr" {
 			dpConfig.BPFNodePortDSREnabled = true
 		}
 
+		startAlerts(configParams)
+
 		intDP := intdataplane.NewIntDataplaneDriver(dpConfig)
+		var flowLogsWorkloads *flowlogs.WorkloadIndex
+		if configParams.FlowLogsEnabled && !configParams.BPFEnabled {
+			flowLogsWorkloads = flowlogs.NewWorkloadIndex()
+			intDP.RegisterManager(flowLogsWorkloads)
+		}
+		if configParams.PacketCaptureEnabled {
+			if !configParams.DebugServerEnabled {
+				log.Warn("PacketCaptureEnabled is set but captures can only be controlled " +
+					"through the debug server, which is disabled.")
+			}
+			captures := capture.NewManager(configParams.PacketCaptureDir)
+			intDP.RegisterManager(captures)
+			debugserver.RegisterHandler("captures", captures)
+		}
 		intDP.Start()
 
+		var domainLookup func(ip string) []string
+		if configParams.DNSVisibilityEnabled {
+			dnsCache := dnscache.New()
+			dnscache.Start(dnsCache)
+			debugserver.RegisterStateDumper("dns-cache", dnsCache.Dump)
+			domainLookup = dnsCache.Lookup
+		}
+		if configParams.DNSPolicyEnabled && !configParams.BPFEnabled {
+			// The IP sets behind destination domain rules are only fed from the responses to
+			// workloads' queries to the trusted servers; the DNS cache above, which is only used
+			// for visibility, takes any response.
+			var trustedServers []net.IPNet
+			for _, s := range configParams.DNSPolicyTrustedServers {
+				_, cidr, err := net.ParseCIDR(s)
+				if err != nil {
+					log.WithError(err).Panic("Failed to parse DNSPolicyTrustedServers")
+				}
+				trustedServers = append(trustedServers, *cidr)
+			}
+			dnscache.StartPolicyCapture(dnscache.PolicyCaptureConfig{
+				TrustedServers:        trustedServers,
+				WorkloadIfacePrefixes: configParams.InterfacePrefixes(),
+			}, intDP.OnDNSResponse)
+		}
+
+		if configParams.FlowLogsEnabled && !configParams.BPFEnabled {
+			flowlogs.Start(flowlogs.Config{
+				FlushInterval:         configParams.FlowLogsFlushInterval,
+				ConntrackPollInterval: configParams.FlowLogsConntrackPollInterval,
+				FilePath:              configParams.FlowLogsFilePath,
+				SyslogEnabled:         configParams.FlowLogsSyslogEnabled,
+				OTLPEndpoint:          configParams.FlowLogsOTLPEndpoint,
+				IPFIXCollector:        configParams.FlowLogsIPFIXCollector,
+				IPFIXProtocol:         configParams.FlowLogsIPFIXProtocol,
+				DenyEventsWebhookURL:  configParams.FlowLogsDenyEventsWebhookURL,
+				DenyEventsRateLimit:   configParams.FlowLogsDenyEventsRateLimit,
+				Workloads:             flowLogsWorkloads,
+				DomainLookup:          domainLookup,
+				Hostname:              configParams.FelixHostname,
+			})
+		} else if configParams.FlowLogsEnabled {
+			log.Warn("Flow logs are not supported in BPF mode, ignoring FlowLogsEnabled.")
+		}
+
+		if failsafeAuditEnabled {
+			flowlogs.StartFailsafeAuditor(failsafeInboundHostPorts, failsafeOutboundHostPorts)
+		}
+		if configParams.CIDRBlocklistMetricsEnabled && len(configParams.CIDRBlocklist) > 0 {
+			flowlogs.StartCIDRBlocklistCounter(configParams.CIDRBlocklist)
+		}
+
 		// Set source-destination-check on AWS EC2 instance.
 		if configParams.AWSSrcDstCheck != string(apiv3.AWSSrcDstCheckOptionDoNothing) {
 			c := &clock.RealClock{}
@@ -378,6 +674,19 @@ func StartDataplaneDriver(configParams *config.Config,
 		}
 
 		return intDP, nil
+	} else if configParams.DataplaneDriverGRPCAddress != "" {
+		log.WithField("address", configParams.DataplaneDriverGRPCAddress).Info(
+			"Using external gRPC dataplane driver.")
+
+		conn, err := extdataplane.ConnectGRPCDataplaneDriver(
+			configParams.DataplaneDriverGRPCAddress,
+			healthAggregator,
+			configParams.DataplaneDriverHealthCheckInterval,
+		)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to connect to gRPC dataplane driver")
+		}
+		return conn, nil
 	} else {
 		log.WithField("driver", configParams.DataplaneDriver).Info(
 			"Using external dataplane driver.")
@@ -394,6 +703,7 @@ func ServePrometheusMetrics(configParams *config.Config) {
 	log.WithFields(log.Fields{
 		"host": configParams.PrometheusMetricsHost,
 		"port": configParams.PrometheusMetricsPort,
+		"tls":  configParams.PrometheusMetricsCertFile != "",
 	}).Info("Starting prometheus metrics endpoint")
 	if configParams.PrometheusGoMetricsEnabled && configParams.PrometheusProcessMetricsEnabled && configParams.PrometheusWireGuardMetricsEnabled {
 		log.Info("Including Golang, Process and WireGuard metrics")
@@ -411,12 +721,34 @@ func ServePrometheusMetrics(configParams *config.Config) {
 			prometheus.Unregister(wireguard.MustNewWireguardMetrics())
 		}
 	}
-	http.Handle("/metrics", promhttp.Handler())
-	addr := net.JoinHostPort(configParams.PrometheusMetricsHost, strconv.Itoa(configParams.PrometheusMetricsPort))
-	for {
-		err := http.ListenAndServe(addr, nil)
-		log.WithError(err).Error(
-			"Prometheus metrics endpoint failed, trying to restart it...")
-		time.Sleep(1 * time.Second)
+	metricsserver.Serve(metricsserver.Config{
+		Host:     configParams.PrometheusMetricsHost,
+		Port:     configParams.PrometheusMetricsPort,
+		CertFile: configParams.PrometheusMetricsCertFile,
+		KeyFile:  configParams.PrometheusMetricsKeyFile,
+		CAFile:   configParams.PrometheusMetricsCAFile,
+	})
+}
+
+// startAlerts configures the alert sinks and, if there are any, starts watching the conntrack
+// table.
+func startAlerts(configParams *config.Config) {
+	var sinks []alerts.Sink
+	if configParams.AlertsWebhookURL != "" {
+		sinks = append(sinks, alerts.NewWebhookSink(configParams.AlertsWebhookURL))
+	}
+	if configParams.AlertsSNMPTrapTarget != "" {
+		sink, err := alerts.NewSNMPTrapSink(configParams.AlertsSNMPTrapTarget,
+			configParams.AlertsSNMPCommunity, configParams.AlertsSNMPTrapOID)
+		if err != nil {
+			log.WithError(err).Error("Invalid SNMP trap configuration, SNMP alerts disabled.")
+		} else {
+			sinks = append(sinks, sink)
+		}
+	}
+	if len(sinks) == 0 {
+		return
 	}
+	alerts.Configure(configParams.FelixHostname, configParams.AlertsRepeatInterval, sinks...)
+	alerts.NewConntrackWatcher(configParams.AlertsConntrackThresholdPercent, 30*time.Second).Start()
 }
@@ -18,3 +18,9 @@ type DataplaneDriver interface {
 	SendMessage(msg interface{}) error
 	RecvMessage() (msg interface{}, err error)
 }
+
+// DataplaneCleaner is implemented by dataplane drivers that can prepare the dataplane for Felix to
+// shut down, for example by removing Felix's state from it; see config.ShutdownMode for the modes.
+type DataplaneCleaner interface {
+	CleanUpDataplane(mode string) error
+}
@@ -16,22 +16,18 @@ package dataplane
 
 import (
 	"fmt"
-	"net"
-	"net/http"
 	"os/exec"
-	"strconv"
-	"time"
 
 	log "github.com/sirupsen/logrus"
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
-
 
 	"github.com/projectcalico/felix/config"
 	windataplane "github.com/projectcalico/felix/dataplane/windows"
 	"github.com/projectcalico/felix/dataplane/windows/hns"
+	"github.com/projectcalico/felix/metricsserver"
+	"github.com/projectcalico/felix/nodeconditions"
 	"github.com/projectcalico/libcalico-go/lib/health"
 )
 
@@ -50,6 +46,29 @@ func StartDataplaneDriver(configParams *config.Config,
 		VXLANEnabled: configParams.VXLANEnabled,
 		VXLANID:      configParams.VXLANVNI,
 		VXLANPort:    configParams.VXLANPort,
+
+		VXLANRouteCheckInterval: configParams.WindowsVXLANRouteCheckInterval,
+
+		RuleCompactionEnabled: configParams.WindowsPolicyRuleCompactionEnabled,
+		VFPMetricsEnabled:     configParams.WindowsVFPMetricsEnabled,
+
+		DNSExceptions:      configParams.WindowsDNSExceptions,
+		MetadataExceptions: configParams.WindowsMetadataExceptions,
+
+		FailsafeInboundHostPorts:  configParams.FailsafeInboundHostPorts,
+		FailsafeOutboundHostPorts: configParams.FailsafeOutboundHostPorts,
+	}
+
+	if configParams.KubeNodeConditionsEnabled {
+		if k8sClientSet != nil {
+			nodeConditions := nodeconditions.NewReporter(k8sClientSet, configParams.FelixHostname)
+			nodeConditions.Start()
+			nodeConditions.ReportNotReady()
+			dpConfig.PostInSyncCallback = nodeConditions.ReportReady
+			dpConfig.DegradedRulesCallback = nodeConditions.ReportDegraded
+		} else {
+			log.Warn("No Kubernetes client available, ignoring KubeNodeConditionsEnabled.")
+		}
 	}
 
 	winDP := windataplane.NewWinDataplaneDriver(hns.API{}, dpConfig)
@@ -66,6 +85,7 @@ func ServePrometheusMetrics(configParams *config.Config) {
 	log.WithFields(log.Fields{
 		"host": configParams.PrometheusMetricsHost,
 		"port": configParams.PrometheusMetricsPort,
+		"tls":  configParams.PrometheusMetricsCertFile != "",
 	}).Info("Starting prometheus metrics endpoint")
 	if configParams.PrometheusGoMetricsEnabled && configParams.PrometheusProcessMetricsEnabled {
 		log.Info("Including Golang, and Process metrics")
@@ -79,12 +99,11 @@ func ServePrometheusMetrics(configParams *config.Config) {
 			prometheus.Unregister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
 		}
 	}
-	http.Handle("/metrics", promhttp.Handler())
-	addr := net.JoinHostPort(configParams.PrometheusMetricsHost, strconv.Itoa(configParams.PrometheusMetricsPort))
-	for {
-		err := http.ListenAndServe(addr, nil)
-		log.WithError(err).Error(
-			"Prometheus metrics endpoint failed, trying to restart it...")
-		time.Sleep(1 * time.Second)
-	}
-}
\ No newline at end of file
+	metricsserver.Serve(metricsserver.Config{
+		Host:     configParams.PrometheusMetricsHost,
+		Port:     configParams.PrometheusMetricsPort,
+		CertFile: configParams.PrometheusMetricsCertFile,
+		KeyFile:  configParams.PrometheusMetricsKeyFile,
+		CAFile:   configParams.PrometheusMetricsCAFile,
+	})
+}
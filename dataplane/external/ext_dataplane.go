@@ -12,8 +12,8 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// extdataplane implements the connection to an external dataplane driver, connected via
-// a pair of pipes.
+// extdataplane implements the connection to an external dataplane driver, connected either via
+// a pair of pipes or via gRPC.
 package extdataplane
 
 import (
@@ -28,6 +28,7 @@ import (
 
 	_ "github.com/projectcalico/felix/config"
 	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/tracing"
 )
 
 // StartExtDataplaneDriver starts the given driver as a child process and returns a
@@ -110,7 +111,13 @@ func (c *extDataplaneConn) RecvMessage() (msg interface{}, err error) {
 		return
 	}
 	log.WithField("envelope", envelope).Debug("Received message from dataplane.")
+	msg = unwrapFromDataplane(&envelope)
+	return
+}
 
+// unwrapFromDataplane extracts the payload from a message that was received from the dataplane
+// driver.  It returns nil if the payload isn't one that we know about.
+func unwrapFromDataplane(envelope *proto.FromDataplane) (msg interface{}) {
 	switch payload := envelope.Payload.(type) {
 	case *proto.FromDataplane_ProcessStatusUpdate:
 		msg = payload.ProcessStatusUpdate
@@ -124,6 +131,8 @@ func (c *extDataplaneConn) RecvMessage() (msg interface{}, err error) {
 		msg = payload.HostEndpointStatusRemove
 	case *proto.FromDataplane_WireguardStatusUpdate:
 		msg = payload.WireguardStatusUpdate
+	case *proto.FromDataplane_DataplaneCapabilities:
+		msg = payload.DataplaneCapabilities
 
 	default:
 		log.WithField("payload", payload).Warn("Ignoring unknown message from dataplane")
@@ -133,13 +142,46 @@ func (c *extDataplaneConn) RecvMessage() (msg interface{}, err error) {
 }
 
 func (fc *extDataplaneConn) SendMessage(msg interface{}) error {
+	if _, ok := msg.(*tracing.BatchStart); ok {
+		// The tracing context can't be passed to another process.
+		return nil
+	}
 	log.Debugf("Writing msg (%v) to felix: %#v", fc.nextSeqNumber, msg)
-	// Wrap the payload message in an envelope so that protobuf takes care of deserialising
-	// it as the correct type.
+	envelope := wrapToDataplane(fc.nextSeqNumber, msg)
+	fc.nextSeqNumber += 1
+	data, err := pb.Marshal(envelope)
+
+	if err != nil {
+		log.WithError(err).WithField("msg", msg).Panic(
+			"Failed to marshal data to front end")
+	}
+
+	lengthBytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(lengthBytes, uint64(len(data)))
+	var messageBuf bytes.Buffer
+	messageBuf.Write(lengthBytes)
+	messageBuf.Write(data)
+	for {
+		_, err := messageBuf.WriteTo(fc.toDataplane)
+		if err == io.ErrShortWrite {
+			log.Warn("Short write to dataplane driver; buffer full?")
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		log.Debug("Wrote message to dataplane driver")
+		break
+	}
+	return nil
+}
+
+// wrapToDataplane wraps the payload message in an envelope so that protobuf takes care of
+// deserialising it as the correct type.
+func wrapToDataplane(seqNo uint64, msg interface{}) *proto.ToDataplane {
 	envelope := &proto.ToDataplane{
-		SequenceNumber: fc.nextSeqNumber,
+		SequenceNumber: seqNo,
 	}
-	fc.nextSeqNumber += 1
 	switch msg := msg.(type) {
 	case *proto.ConfigUpdate:
 		envelope.Payload = &proto.ToDataplane_ConfigUpdate{ConfigUpdate: msg}
@@ -201,29 +243,5 @@ func (fc *extDataplaneConn) SendMessage(msg interface{}) error {
 	default:
 		log.WithField("msg", msg).Panic("Unknown message type")
 	}
-	data, err := pb.Marshal(envelope)
-
-	if err != nil {
-		log.WithError(err).WithField("msg", msg).Panic(
-			"Failed to marshal data to front end")
-	}
-
-	lengthBytes := make([]byte, 8)
-	binary.LittleEndian.PutUint64(lengthBytes, uint64(len(data)))
-	var messageBuf bytes.Buffer
-	messageBuf.Write(lengthBytes)
-	messageBuf.Write(data)
-	for {
-		_, err := messageBuf.WriteTo(fc.toDataplane)
-		if err == io.ErrShortWrite {
-			log.Warn("Short write to dataplane driver; buffer full?")
-			continue
-		}
-		if err != nil {
-			return err
-		}
-		log.Debug("Wrote message to dataplane driver")
-		break
-	}
-	return nil
+	return envelope
 }
@@ -0,0 +1,132 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extdataplane
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/tracing"
+	"github.com/projectcalico/libcalico-go/lib/health"
+)
+
+const (
+	// GRPCServiceName is the name of the versioned dataplane driver service, which is also the
+	// service name that Felix uses in gRPC health checks.
+	GRPCServiceName = "felix.DataplaneDriverV1"
+
+	healthName = "ExternalDataplaneDriver"
+)
+
+// ConnectGRPCDataplaneDriver connects to an external dataplane driver that implements the
+// DataplaneDriverV1 gRPC service at the given address, which is either the path of a unix socket
+// or a host:port.  If healthInterval is non-zero, the driver's health is checked at that interval
+// and reported to the health aggregator.
+func ConnectGRPCDataplaneDriver(
+	address string,
+	healthAggregator *health.HealthAggregator,
+	healthInterval time.Duration,
+) (*grpcDataplaneConn, error) {
+	log.WithField("address", address).Info("Connecting to gRPC dataplane driver.")
+	conn, err := grpc.Dial(address, grpc.WithInsecure(), grpc.WithContextDialer(dialDataplaneDriver))
+	if err != nil {
+		return nil, err
+	}
+	stream, err := proto.NewDataplaneDriverV1Client(conn).Sync(context.Background())
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	c := &grpcDataplaneConn{
+		conn:   conn,
+		stream: stream,
+	}
+	if healthAggregator != nil && healthInterval > 0 {
+		healthAggregator.RegisterReporter(healthName, &health.HealthReport{Live: true, Ready: true},
+			healthInterval*3)
+		go c.loopCheckingHealth(healthpb.NewHealthClient(conn), healthAggregator, healthInterval)
+	}
+	return c, nil
+}
+
+// dialDataplaneDriver dials a unix socket if the address is a path and TCP otherwise.
+func dialDataplaneDriver(ctx context.Context, address string) (net.Conn, error) {
+	network := "tcp"
+	if strings.HasPrefix(address, "/") {
+		network = "unix"
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, network, address)
+}
+
+type grpcDataplaneConn struct {
+	conn          *grpc.ClientConn
+	stream        proto.DataplaneDriverV1_SyncClient
+	nextSeqNumber uint64
+}
+
+func (c *grpcDataplaneConn) RecvMessage() (msg interface{}, err error) {
+	envelope, err := c.stream.Recv()
+	if err != nil {
+		return
+	}
+	log.WithField("envelope", envelope).Debug("Received message from dataplane.")
+	msg = unwrapFromDataplane(envelope)
+	return
+}
+
+func (c *grpcDataplaneConn) SendMessage(msg interface{}) error {
+	if _, ok := msg.(*tracing.BatchStart); ok {
+		// The tracing context can't be passed to another process.
+		return nil
+	}
+	log.Debugf("Sending msg (%v) to gRPC dataplane driver: %#v", c.nextSeqNumber, msg)
+	envelope := wrapToDataplane(c.nextSeqNumber, msg)
+	c.nextSeqNumber += 1
+	return c.stream.Send(envelope)
+}
+
+func (c *grpcDataplaneConn) loopCheckingHealth(
+	client healthpb.HealthClient,
+	healthAggregator *health.HealthAggregator,
+	interval time.Duration,
+) {
+	for {
+		healthAggregator.Report(healthName, c.checkHealth(client, interval))
+		time.Sleep(interval)
+	}
+}
+
+func (c *grpcDataplaneConn) checkHealth(client healthpb.HealthClient, timeout time.Duration) *health.HealthReport {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: GRPCServiceName})
+	if err != nil {
+		log.WithError(err).Warn("Failed to check health of gRPC dataplane driver.")
+		return &health.HealthReport{Live: false, Ready: false}
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		log.WithField("status", resp.Status).Warn("gRPC dataplane driver is not serving.")
+		return &health.HealthReport{Live: true, Ready: false}
+	}
+	return &health.HealthReport{Live: true, Ready: true}
+}
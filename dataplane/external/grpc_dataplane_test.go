@@ -0,0 +1,120 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extdataplane
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/libcalico-go/lib/health"
+)
+
+// fakeDriver echoes a status update back for every InSync message that it receives.
+type fakeDriver struct {
+	received chan *proto.ToDataplane
+}
+
+func (d *fakeDriver) Sync(stream proto.DataplaneDriverV1_SyncServer) error {
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		d.received <- msg
+		if msg.GetInSync() != nil {
+			err = stream.Send(&proto.FromDataplane{Payload: &proto.FromDataplane_ProcessStatusUpdate{
+				ProcessStatusUpdate: &proto.ProcessStatusUpdate{IsoTimestamp: "now"},
+			}})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+var _ = Describe("gRPC dataplane driver connection", func() {
+	var (
+		dir          string
+		server       *grpc.Server
+		healthServer *grpchealth.Server
+		driver       *fakeDriver
+		aggregator   *health.HealthAggregator
+		conn         *grpcDataplaneConn
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "felixgrpcdp")
+		Expect(err).NotTo(HaveOccurred())
+		sock := path.Join(dir, "driver.sock")
+		lis, err := net.Listen("unix", sock)
+		Expect(err).NotTo(HaveOccurred())
+
+		server = grpc.NewServer()
+		driver = &fakeDriver{received: make(chan *proto.ToDataplane, 10)}
+		proto.RegisterDataplaneDriverV1Server(server, driver)
+		healthServer = grpchealth.NewServer()
+		healthServer.SetServingStatus(GRPCServiceName, healthpb.HealthCheckResponse_SERVING)
+		healthpb.RegisterHealthServer(server, healthServer)
+		go func() {
+			_ = server.Serve(lis)
+		}()
+
+		aggregator = health.NewHealthAggregator()
+		conn, err = ConnectGRPCDataplaneDriver(sock, aggregator, 50*time.Millisecond)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		_ = conn.conn.Close()
+		server.Stop()
+		_ = os.RemoveAll(dir)
+	})
+
+	It("should exchange messages with the driver", func() {
+		Expect(conn.SendMessage(&proto.ConfigUpdate{Config: map[string]string{"a": "b"}})).To(Succeed())
+		Expect(conn.SendMessage(&proto.InSync{})).To(Succeed())
+
+		var msg *proto.ToDataplane
+		Eventually(driver.received).Should(Receive(&msg))
+		Expect(msg.SequenceNumber).To(BeNumerically("==", 0))
+		Expect(msg.GetConfigUpdate().Config).To(Equal(map[string]string{"a": "b"}))
+		Eventually(driver.received).Should(Receive(&msg))
+		Expect(msg.SequenceNumber).To(BeNumerically("==", 1))
+		Expect(msg.GetInSync()).NotTo(BeNil())
+
+		status, err := conn.RecvMessage()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status).To(Equal(&proto.ProcessStatusUpdate{IsoTimestamp: "now"}))
+	})
+
+	It("should report the driver's health", func() {
+		Eventually(aggregator.Summary).Should(Equal(&health.HealthReport{Live: true, Ready: true}))
+		healthServer.SetServingStatus(GRPCServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+		Eventually(aggregator.Summary).Should(Equal(&health.HealthReport{Live: true, Ready: false}))
+		server.Stop()
+		Eventually(aggregator.Summary).Should(Equal(&health.HealthReport{Live: false, Ready: false}))
+	})
+})
@@ -0,0 +1,68 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/projectcalico/felix/proto"
+
+	cprometheus "github.com/projectcalico/libcalico-go/lib/prometheus"
+)
+
+var summaryPriorityApplyLatency = cprometheus.NewSummary(prometheus.SummaryOpts{
+	Name: "felix_int_dataplane_priority_apply_latency_seconds",
+	Help: "Time in seconds from receiving a security-critical update, such as an endpoint " +
+		"removal or a policy with deny rules, to it being applied to the dataplane.",
+})
+
+func init() {
+	prometheus.MustRegister(summaryPriorityApplyLatency)
+}
+
+// minPriorityApplyInterval is the minimum interval between the applies that priority updates
+// force through the apply throttle.
+const minPriorityApplyInterval = 100 * time.Millisecond
+
+// isPriorityUpdate returns true for the security-critical updates that go in the priority lane:
+// they cut short the current batch and skip the debounce interval and (subject to
+// minPriorityApplyInterval) the apply throttle, so that traffic that should now be blocked isn't
+// allowed for longer than necessary.  Everything
+// else, such as the re-renders that follow label churn, goes in the bulk lane.
+func isPriorityUpdate(msg interface{}) bool {
+	switch msg := msg.(type) {
+	case *proto.WorkloadEndpointRemove, *proto.HostEndpointRemove:
+		return true
+	case *proto.ActivePolicyUpdate:
+		return policyHasDenyRule(msg.Policy)
+	}
+	return false
+}
+
+func policyHasDenyRule(policy *proto.Policy) bool {
+	if policy == nil {
+		return false
+	}
+	for _, rules := range [][]*proto.Rule{policy.InboundRules, policy.OutboundRules} {
+		for _, r := range rules {
+			if r.Action == "deny" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/proto"
+)
+
+var _ = DescribeTable("isPriorityUpdate",
+	func(msg interface{}, expected bool) {
+		Expect(isPriorityUpdate(msg)).To(Equal(expected))
+	},
+	Entry("workload endpoint removal", &proto.WorkloadEndpointRemove{}, true),
+	Entry("host endpoint removal", &proto.HostEndpointRemove{}, true),
+	Entry("workload endpoint update", &proto.WorkloadEndpointUpdate{}, false),
+	Entry("policy with inbound deny", &proto.ActivePolicyUpdate{
+		Policy: &proto.Policy{InboundRules: []*proto.Rule{{Action: "allow"}, {Action: "deny"}}},
+	}, true),
+	Entry("policy with outbound deny", &proto.ActivePolicyUpdate{
+		Policy: &proto.Policy{OutboundRules: []*proto.Rule{{Action: "deny"}}},
+	}, true),
+	Entry("policy without deny", &proto.ActivePolicyUpdate{
+		Policy: &proto.Policy{InboundRules: []*proto.Rule{{Action: "allow"}, {Action: "log"}}},
+	}, false),
+	Entry("policy removal", &proto.ActivePolicyRemove{}, false),
+	Entry("IP set delta", &proto.IPSetDeltaUpdate{}, false),
+)
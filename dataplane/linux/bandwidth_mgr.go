@@ -0,0 +1,268 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/ifacemonitor"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/libcalico-go/lib/selector"
+	"github.com/projectcalico/libcalico-go/lib/set"
+)
+
+const (
+	// minBandwidthBurst is the smallest burst, in bytes, that we configure; it must comfortably
+	// exceed the MTU.  Above that, the burst allows for 100ms of traffic at the limit.
+	minBandwidthBurst = 32 * 1024
+	// bandwidthLatency bounds the queueing delay of the ingress shaper.
+	bandwidthLatency = "25ms"
+	// bandwidthRootHandle is the handle of our root qdisc.  We only remove a root qdisc that has it
+	// (the kernel checks the handle) so that we don't remove qdiscs that something else added.
+	bandwidthRootHandle = "ca1:"
+	// bandwidthFilterPrio is the priority of our filter on the ingress qdisc, which we only remove
+	// by priority so that we leave other filters, and the qdisc itself, alone.
+	bandwidthFilterPrio = "3233"
+
+	// orchestratorKubernetes is the OrchestratorId of Kubernetes pods' workload endpoints.
+	orchestratorKubernetes = "k8s"
+)
+
+type bandwidthLimits struct {
+	// ingress and egress are in bits per second, from the workload's point of view; zero means
+	// unlimited.
+	ingress, egress int64
+}
+
+type workloadBandwidth struct {
+	ifaceName string
+	labels    map[string]string
+}
+
+type bandwidthLimitRule struct {
+	selector selector.Selector
+	limits   bandwidthLimits
+}
+
+// bandwidthManager rate limits workloads that have bandwidth limits, using tc on their
+// interfaces.  Traffic to the workload leaves the host through the workload's interface, so we
+// shape it with a tbf root qdisc.  Traffic from the workload arrives on the interface, where we
+// can only police it, so we drop traffic over the limit with a u32 filter on the ingress qdisc.
+//
+// A workload's limits come from the first WorkloadBandwidthLimits rule that selects it or, if
+// none does, from its pod's bandwidth annotations; see podBandwidthWatcher.
+type bandwidthManager struct {
+	wlIfacesRegexp *regexp.Regexp
+	limitRules     []bandwidthLimitRule
+	newCmd         cmdFactory
+
+	workloads      map[proto.WorkloadEndpointID]workloadBandwidth
+	podAnnotations map[string]bandwidthLimits
+	upIfaces       set.Set
+
+	// appliedLimits holds the limits that we've programmed on each interface.  An interface that
+	// isn't in the map may have leftovers from a previous run, so we remove those (if they have
+	// our handle or priority) when we first sync it.
+	appliedLimits map[string]bandwidthLimits
+	dirtyIfaces   set.Set
+}
+
+func newBandwidthManager(
+	wlInterfacePrefixes []string,
+	limitRules []config.BandwidthLimitRule,
+	newCmd cmdFactory,
+) *bandwidthManager {
+	var rules []bandwidthLimitRule
+	for _, rule := range limitRules {
+		sel, err := selector.Parse(rule.Selector)
+		if err != nil {
+			// The selector is validated when the config is loaded.
+			log.WithError(err).Panic("Failed to parse WorkloadBandwidthLimits selector")
+		}
+		rules = append(rules, bandwidthLimitRule{
+			selector: sel,
+			limits:   bandwidthLimits{ingress: rule.Ingress, egress: rule.Egress},
+		})
+	}
+	return &bandwidthManager{
+		wlIfacesRegexp: regexp.MustCompile("^(" + strings.Join(wlInterfacePrefixes, "|") + ").*"),
+		limitRules:     rules,
+		newCmd:         newCmd,
+		workloads:      map[proto.WorkloadEndpointID]workloadBandwidth{},
+		podAnnotations: map[string]bandwidthLimits{},
+		upIfaces:       set.New(),
+		appliedLimits:  map[string]bandwidthLimits{},
+		dirtyIfaces:    set.New(),
+	}
+}
+
+func (m *bandwidthManager) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *proto.WorkloadEndpointUpdate:
+		if old, ok := m.workloads[*msg.Id]; ok && old.ifaceName != msg.Endpoint.Name {
+			m.dirtyIfaces.Add(old.ifaceName)
+		}
+		m.workloads[*msg.Id] = workloadBandwidth{
+			ifaceName: msg.Endpoint.Name,
+			labels:    msg.Endpoint.Labels,
+		}
+		m.dirtyIfaces.Add(msg.Endpoint.Name)
+	case *proto.WorkloadEndpointRemove:
+		if old, ok := m.workloads[*msg.Id]; ok {
+			m.dirtyIfaces.Add(old.ifaceName)
+			delete(m.workloads, *msg.Id)
+		}
+	case *podBandwidthUpdate:
+		if msg.Limits == (bandwidthLimits{}) {
+			delete(m.podAnnotations, msg.WorkloadID)
+		} else {
+			m.podAnnotations[msg.WorkloadID] = msg.Limits
+		}
+		for id, wl := range m.workloads {
+			if id.OrchestratorId == orchestratorKubernetes && id.WorkloadId == msg.WorkloadID {
+				m.dirtyIfaces.Add(wl.ifaceName)
+			}
+		}
+	case *ifaceUpdate:
+		if !m.wlIfacesRegexp.MatchString(msg.Name) {
+			// We only ever touch workload interfaces.
+			return
+		}
+		// The interface may have been recreated without our qdiscs, so forget what we applied
+		// and reconcile it in full when it's up.
+		delete(m.appliedLimits, msg.Name)
+		if msg.State == ifacemonitor.StateUp {
+			m.upIfaces.Add(msg.Name)
+			m.dirtyIfaces.Add(msg.Name)
+		} else {
+			m.upIfaces.Discard(msg.Name)
+			m.dirtyIfaces.Discard(msg.Name)
+		}
+	}
+}
+
+func (m *bandwidthManager) CompleteDeferredWork() error {
+	if m.dirtyIfaces.Len() == 0 {
+		return nil
+	}
+
+	desired := map[string]bandwidthLimits{}
+	for id, wl := range m.workloads {
+		desired[wl.ifaceName] = m.limitsFor(id, wl)
+	}
+
+	var lastErr error
+	m.dirtyIfaces.Iter(func(item interface{}) error {
+		ifaceName := item.(string)
+		if !m.upIfaces.Contains(ifaceName) {
+			// We'll get an interface update when it comes up.
+			return set.RemoveItem
+		}
+		if err := m.syncIface(ifaceName, desired[ifaceName]); err != nil {
+			log.WithError(err).WithField("iface", ifaceName).Warn(
+				"Failed to apply bandwidth limits, will retry")
+			lastErr = err
+			return nil
+		}
+		return set.RemoveItem
+	})
+	return lastErr
+}
+
+// limitsFor returns the workload's limits from the first rule that selects it or, failing that,
+// from its pod's annotations.
+func (m *bandwidthManager) limitsFor(id proto.WorkloadEndpointID, wl workloadBandwidth) bandwidthLimits {
+	for _, rule := range m.limitRules {
+		if rule.selector.Evaluate(wl.labels) {
+			return rule.limits
+		}
+	}
+	if id.OrchestratorId != orchestratorKubernetes {
+		return bandwidthLimits{}
+	}
+	return m.podAnnotations[id.WorkloadId]
+}
+
+func (m *bandwidthManager) syncIface(ifaceName string, limits bandwidthLimits) error {
+	applied, known := m.appliedLimits[ifaceName]
+	if known && applied == limits {
+		return nil
+	}
+	logCxt := log.WithFields(log.Fields{
+		"iface":   ifaceName,
+		"ingress": limits.ingress,
+		"egress":  limits.egress,
+	})
+	logCxt.Info("Updating workload bandwidth limits")
+
+	if !known || applied.ingress != limits.ingress {
+		if limits.ingress > 0 {
+			if err := m.tc("qdisc", "replace", "dev", ifaceName, "root", "handle", bandwidthRootHandle,
+				"tbf", "rate", bitsPerSecond(limits.ingress),
+				"burst", fmt.Sprint(bandwidthBurst(limits.ingress)),
+				"latency", bandwidthLatency); err != nil {
+				return err
+			}
+		} else if !known || applied.ingress > 0 {
+			m.tcIgnoreErr("qdisc", "del", "dev", ifaceName, "root", "handle", bandwidthRootHandle)
+		}
+	}
+
+	if !known || applied.egress != limits.egress {
+		// Replace our filter, if any.
+		if !known || applied.egress > 0 {
+			m.tcIgnoreErr("filter", "del", "dev", ifaceName, "parent", "ffff:", "prio", bandwidthFilterPrio)
+		}
+		if limits.egress > 0 {
+			// The ingress qdisc may already exist, in which case we share it.
+			m.tcIgnoreErr("qdisc", "add", "dev", ifaceName, "ingress")
+			if err := m.tc("filter", "add", "dev", ifaceName, "parent", "ffff:",
+				"protocol", "all", "prio", bandwidthFilterPrio, "u32", "match", "u32", "0", "0",
+				"police", "rate", bitsPerSecond(limits.egress),
+				"burst", fmt.Sprint(bandwidthBurst(limits.egress)),
+				"drop", "flowid", ":1"); err != nil {
+				return err
+			}
+		}
+	}
+
+	m.appliedLimits[ifaceName] = limits
+	return nil
+}
+
+func (m *bandwidthManager) tc(args ...string) error {
+	return runTC(m.newCmd, args...)
+}
+
+func (m *bandwidthManager) tcIgnoreErr(args ...string) {
+	runTCIgnoreErr(m.newCmd, args...)
+}
+
+func bitsPerSecond(rate int64) string {
+	return fmt.Sprintf("%dbit", rate)
+}
+
+func bandwidthBurst(rate int64) int64 {
+	burst := rate / 8 / 10
+	if burst < minBandwidthBurst {
+		burst = minBandwidthBurst
+	}
+	return burst
+}
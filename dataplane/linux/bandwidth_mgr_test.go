@@ -0,0 +1,208 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/ifacemonitor"
+	"github.com/projectcalico/felix/proto"
+)
+
+// tcRecorder records the command lines that it is asked to run.  Commands that start with one of
+// the failPrefixes fail.
+type tcRecorder struct {
+	cmds         []string
+	failPrefixes []string
+	// failSubstrings makes the commands that contain any of the strings fail.
+	failSubstrings []string
+	// outputs holds the output of commands, by command line.
+	outputs map[string]string
+}
+
+type tcRecorderCmd struct {
+	output string
+	err    error
+}
+
+func (c *tcRecorderCmd) Output() ([]byte, error) {
+	return []byte(c.output), c.err
+}
+
+func (r *tcRecorder) factory(name string, args ...string) cmdIface {
+	line := name + " " + strings.Join(args, " ")
+	r.cmds = append(r.cmds, line)
+	for _, p := range r.failPrefixes {
+		if strings.HasPrefix(line, p) {
+			return &tcRecorderCmd{err: errors.New("failed")}
+		}
+	}
+	for _, s := range r.failSubstrings {
+		if strings.Contains(line, s) {
+			return &tcRecorderCmd{err: errors.New("failed")}
+		}
+	}
+	return &tcRecorderCmd{output: r.outputs[line]}
+}
+
+func (r *tcRecorder) takeCmds() []string {
+	cmds := r.cmds
+	r.cmds = nil
+	return cmds
+}
+
+var _ = Describe("Bandwidth manager", func() {
+	var (
+		mgr *bandwidthManager
+		tc  *tcRecorder
+	)
+
+	wlID := &proto.WorkloadEndpointID{OrchestratorId: "k8s", WorkloadId: "ns/pod1", EndpointId: "eth0"}
+
+	sendWorkload := func(labels map[string]string) {
+		mgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id: wlID,
+			Endpoint: &proto.WorkloadEndpoint{
+				Name:   "cali1",
+				Labels: labels,
+			},
+		})
+	}
+	sendAnnotations := func(ingress, egress int64) {
+		mgr.OnUpdate(&podBandwidthUpdate{
+			WorkloadID: "ns/pod1",
+			Limits:     bandwidthLimits{ingress: ingress, egress: egress},
+		})
+	}
+
+	BeforeEach(func() {
+		tc = &tcRecorder{}
+		mgr = newBandwidthManager([]string{"cali"}, []config.BandwidthLimitRule{
+			{Selector: "vm == 'big'", Ingress: 50000000, Egress: 0},
+		}, tc.factory)
+	})
+
+	It("should wait for the interface to come up", func() {
+		sendAnnotations(10000000, 0)
+		sendWorkload(nil)
+		Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+		Expect(tc.takeCmds()).To(BeEmpty())
+
+		mgr.OnUpdate(&ifaceUpdate{Name: "cali1", State: ifacemonitor.StateUp})
+		Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+		Expect(tc.takeCmds()).To(Equal([]string{
+			"tc qdisc replace dev cali1 root handle ca1: tbf rate 10000000bit burst 125000 latency 25ms",
+			"tc filter del dev cali1 parent ffff: prio 3233",
+		}))
+	})
+
+	It("should only clean up workload interfaces", func() {
+		mgr.OnUpdate(&ifaceUpdate{Name: "eth0", State: ifacemonitor.StateUp})
+		Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+		Expect(tc.takeCmds()).To(BeEmpty())
+
+		mgr.OnUpdate(&ifaceUpdate{Name: "cali2", State: ifacemonitor.StateUp})
+		Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+		Expect(tc.takeCmds()).To(Equal([]string{
+			"tc qdisc del dev cali2 root handle ca1:",
+			"tc filter del dev cali2 parent ffff: prio 3233",
+		}))
+	})
+
+	Describe("with the interface up", func() {
+		BeforeEach(func() {
+			mgr.OnUpdate(&ifaceUpdate{Name: "cali1", State: ifacemonitor.StateUp})
+			sendAnnotations(10000000, 1000000)
+			sendWorkload(nil)
+			Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+		})
+
+		It("should shape ingress and police egress", func() {
+			Expect(tc.takeCmds()).To(Equal([]string{
+				"tc qdisc replace dev cali1 root handle ca1: tbf rate 10000000bit burst 125000 latency 25ms",
+				"tc filter del dev cali1 parent ffff: prio 3233",
+				"tc qdisc add dev cali1 ingress",
+				"tc filter add dev cali1 parent ffff: protocol all prio 3233 u32 match u32 0 0 " +
+					"police rate 1000000bit burst 32768 drop flowid :1",
+			}))
+		})
+
+		It("should do nothing if the limits don't change", func() {
+			tc.takeCmds()
+			sendWorkload(map[string]string{"app": "web"})
+			Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+			Expect(tc.takeCmds()).To(BeEmpty())
+		})
+
+		It("should only touch the root qdisc when the ingress limit changes", func() {
+			tc.takeCmds()
+			sendAnnotations(20000000, 1000000)
+			Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+			Expect(tc.takeCmds()).To(Equal([]string{
+				"tc qdisc replace dev cali1 root handle ca1: tbf rate 20000000bit burst 250000 latency 25ms",
+			}))
+		})
+
+		It("should let the config override the annotations", func() {
+			tc.takeCmds()
+			sendWorkload(map[string]string{"vm": "big"})
+			Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+			Expect(tc.takeCmds()).To(Equal([]string{
+				"tc qdisc replace dev cali1 root handle ca1: tbf rate 50000000bit burst 625000 latency 25ms",
+				"tc filter del dev cali1 parent ffff: prio 3233",
+			}))
+		})
+
+		It("should remove only its own qdisc and filter when the workload is removed", func() {
+			tc.takeCmds()
+			mgr.OnUpdate(&proto.WorkloadEndpointRemove{Id: wlID})
+			Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+			Expect(tc.takeCmds()).To(Equal([]string{
+				"tc qdisc del dev cali1 root handle ca1:",
+				"tc filter del dev cali1 parent ffff: prio 3233",
+			}))
+		})
+
+		It("should reapply the limits when the interface is recreated", func() {
+			tc.takeCmds()
+			mgr.OnUpdate(&ifaceUpdate{Name: "cali1", State: ifacemonitor.StateDown})
+			mgr.OnUpdate(&ifaceUpdate{Name: "cali1", State: ifacemonitor.StateUp})
+			Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+			Expect(tc.takeCmds()).To(HaveLen(4))
+		})
+
+		It("should retry after a failure", func() {
+			tc.takeCmds()
+			tc.failPrefixes = []string{"tc filter add"}
+			sendAnnotations(10000000, 2000000)
+			Expect(mgr.CompleteDeferredWork()).To(HaveOccurred())
+			Expect(tc.takeCmds()).To(HaveLen(3))
+
+			tc.failPrefixes = nil
+			Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+			Expect(tc.takeCmds()).To(Equal([]string{
+				"tc filter del dev cali1 parent ffff: prio 3233",
+				"tc qdisc add dev cali1 ingress",
+				"tc filter add dev cali1 parent ffff: protocol all prio 3233 u32 match u32 0 0 " +
+					"police rate 2000000bit burst 32768 drop flowid :1",
+			}))
+		})
+	})
+})
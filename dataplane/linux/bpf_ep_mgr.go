@@ -129,6 +129,7 @@ type bpfEndpointManager struct {
 	workloadIfaceRegex      *regexp.Regexp
 	ipSetIDAlloc            *idalloc.IDAllocator
 	epToHostAction          string
+	multicastEnabled        bool
 	vxlanMTU                int
 	vxlanPort               uint16
 	dsrEnabled              bool
@@ -167,6 +168,7 @@ func newBPFEndpointManager(
 	hostname string,
 	fibLookupEnabled bool,
 	epToHostAction string,
+	multicastEnabled bool,
 	dataIfaceRegex *regexp.Regexp,
 	workloadIfaceRegex *regexp.Regexp,
 	ipSetIDAlloc *idalloc.IDAllocator,
@@ -201,6 +203,7 @@ func newBPFEndpointManager(
 		workloadIfaceRegex:      workloadIfaceRegex,
 		ipSetIDAlloc:            ipSetIDAlloc,
 		epToHostAction:          epToHostAction,
+		multicastEnabled:        multicastEnabled,
 		vxlanMTU:                vxlanMTU,
 		vxlanPort:               vxlanPort,
 		dsrEnabled:              dsrEnabled,
@@ -805,6 +808,12 @@ func (m *bpfEndpointManager) attachWorkloadProgram(ifaceName string, endpoint *p
 		rules.SuppressNormalHostPolicy = true
 	}
 
+	// As in iptables mode, workloads can send IGMP, whatever their policy, if multicast is
+	// enabled.
+	if polDirection == PolDirnEgress && m.multicastEnabled {
+		rules.AllowIGMP = true
+	}
+
 	// If host -> workload, always suppress the normal host-* endpoint policy.
 	if polDirection == PolDirnIngress {
 		rules.SuppressNormalHostPolicy = true
@@ -171,6 +171,7 @@ var _ = Describe("BPF Endpoint Manager", func() {
 			"uthost",
 			fibLookupEnabled,
 			endpointToHostAction,
+			false,
 			regexp.MustCompile(dataIfacePattern),
 			regexp.MustCompile(workloadIfaceRegex),
 			ipSetIDAllocator,
@@ -73,6 +73,9 @@ type bpfRouteManager struct {
 	// desiredRoutes contains the complete, desired state of the dataplane map.
 	desiredRoutes map[routes.Key]routes.Value
 	dirtyRoutes   set.Set
+	// numInconsistencies counts the incorrect, missing and unexpected routes that we've found when
+	// resyncing.
+	numInconsistencies int
 
 	// Callbacks used to tell kube-proxy about the relevant routes.
 	cbLck           sync.RWMutex
@@ -369,7 +372,9 @@ func (m *bpfRouteManager) resyncWithDataplane() {
 	debug := log.GetLevel() >= log.DebugLevel
 	log.Info("Doing full resync of BPF routes map")
 
-	// Mark all desired routes as dirty.
+	// Mark all desired routes as dirty.  Remember the routes that were already dirty so that we only count
+	// the routes that the resync finds as inconsistencies.
+	alreadyDirty := m.dirtyRoutes.Copy()
 	m.dirtyRoutes.Clear()
 	for k := range m.desiredRoutes {
 		m.dirtyRoutes.Add(k)
@@ -405,6 +410,18 @@ func (m *bpfRouteManager) resyncWithDataplane() {
 	if err != nil {
 		log.WithError(err).Panic("Failed to scan BPF map.")
 	}
+	m.dirtyRoutes.Iter(func(item interface{}) error {
+		if !alreadyDirty.Contains(item) {
+			m.numInconsistencies++
+		}
+		return nil
+	})
+}
+
+// NumInconsistencies returns the number of incorrect, missing and unexpected routes that we've
+// found (and queued for repair) since we were created.
+func (m *bpfRouteManager) NumInconsistencies() int {
+	return m.numInconsistencies
 }
 
 func (m *bpfRouteManager) onIfaceUpdate(msg *ifaceUpdate) {
@@ -0,0 +1,108 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/projectcalico/felix/iptables"
+)
+
+// chainOrigins records the policy, profile or endpoint that each of the policy and endpoint
+// managers' chains came from, so that an iptables-restore failure can be traced back to it.  It
+// also records the iptables-restore failures in those chains that the tables have given up on, so
+// that the endpoint managers can report them in the status of the affected endpoints.  The
+// iptables tables look chains up from their own goroutines so it has a lock.  A nil *chainOrigins
+// records nothing.
+type chainOrigins struct {
+	lock    sync.Mutex
+	origins map[string]string
+	// failures maps from table name to the failure that the table is stuck on.
+	failures map[string]*iptables.RestoreError
+}
+
+func newChainOrigins() *chainOrigins {
+	return &chainOrigins{
+		origins:  map[string]string{},
+		failures: map[string]*iptables.RestoreError{},
+	}
+}
+
+func (c *chainOrigins) set(origin string, chainNames ...string) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, name := range chainNames {
+		c.origins[name] = origin
+	}
+}
+
+func (c *chainOrigins) remove(chainNames ...string) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, name := range chainNames {
+		delete(c.origins, name)
+	}
+}
+
+// describe is used as the iptables tables' DescribeChain function.
+func (c *chainOrigins) describe(chainName string) string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.origins[chainName]
+}
+
+// onRestoreFailure is used as the iptables tables' OnRestoreFailure function.  It takes on
+// failures in the chains that it knows the origin of; a failure in any other chain is left to the
+// table.
+func (c *chainOrigins) onRestoreFailure(table string, rErr *iptables.RestoreError) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if rErr == nil {
+		delete(c.failures, table)
+		return true
+	}
+	if _, ok := c.origins[rErr.Chain]; !ok {
+		return false
+	}
+	c.failures[table] = rErr
+	return true
+}
+
+// failedChains returns the names of the chains that iptables-restore is failing on, in order, and
+// the failure for each one.
+func (c *chainOrigins) failedChains() ([]string, map[string]string) {
+	if c == nil {
+		return nil, nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var names []string
+	failures := map[string]string{}
+	for _, rErr := range c.failures {
+		if _, ok := failures[rErr.Chain]; !ok {
+			names = append(names, rErr.Chain)
+		}
+		failures[rErr.Chain] = rErr.Error()
+	}
+	sort.Strings(names)
+	return names, failures
+}
@@ -0,0 +1,220 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/iptables"
+)
+
+// dataplaneCheckpoint is the state that we save when Felix shuts down so that the next Felix can
+// start from it instead of reloading and rewriting the whole dataplane: the IP sets are updated
+// with deltas from the checkpoint; the iptables tables use it in place of their first
+// iptables-save; the route tables skip the full resync of the interfaces whose routes match it.
+// Each then checks the dataplane as usual.  See their LoadCheckpoint() methods.
+type dataplaneCheckpoint struct {
+	// Version is the checkpointVersion of the Felix that wrote the checkpoint.
+	Version int `json:"version"`
+	// Hostname is the name of the node that the checkpoint was taken on.
+	Hostname string `json:"hostname"`
+
+	IPSets map[string]ipsets.IPSetCheckpoint `json:"ipSets"`
+	// IptablesChains maps from the table's checkpoint key to its chains.
+	IptablesChains map[string]iptables.TableCheckpoint `json:"iptablesChains"`
+	// Routes maps from the route table's checkpoint key to its routes by interface.
+	Routes map[string]map[string][]string `json:"routes"`
+}
+
+// checkpointVersion is the version of the checkpoint format.  It must be bumped whenever the
+// format changes in a way that another version of Felix would misread, since, after a handoff
+// during an upgrade, the checkpoint comes from the previous version of Felix.
+const checkpointVersion = 2
+
+// ipSetsCheckpointer is implemented by the IP sets that support checkpoints.
+type ipSetsCheckpointer interface {
+	Checkpoint() map[string]ipsets.IPSetCheckpoint
+	LoadCheckpoint(cp map[string]ipsets.IPSetCheckpoint)
+}
+
+// keyedCheckpointer is implemented by the route tables, each of which has its own part of the
+// checkpoint.
+type keyedCheckpointer interface {
+	CheckpointKey() string
+	Checkpoint() map[string][]string
+	LoadCheckpoint(cp map[string][]string)
+}
+
+func (d *InternalDataplane) routeTableCheckpointers() (cps []keyedCheckpointer) {
+	for _, r := range d.routeTableSyncers() {
+		if c, ok := r.(keyedCheckpointer); ok {
+			cps = append(cps, c)
+		}
+	}
+	return
+}
+
+// writeCheckpoint writes the checkpoint file, if configured.  It must be called from the main loop.
+func (d *InternalDataplane) writeCheckpoint() {
+	if d.config.CheckpointFile == "" {
+		return
+	}
+	logCxt := log.WithField("file", d.config.CheckpointFile)
+	if !d.doneFirstApply {
+		logCxt.Info("Dataplane not programmed yet, not writing checkpoint.")
+		return
+	}
+	cp := dataplaneCheckpoint{
+		Version:        checkpointVersion,
+		Hostname:       d.config.Hostname,
+		IPSets:         map[string]ipsets.IPSetCheckpoint{},
+		IptablesChains: map[string]iptables.TableCheckpoint{},
+		Routes:         map[string]map[string][]string{},
+	}
+	for _, s := range d.ipSets {
+		if c, ok := s.(ipSetsCheckpointer); ok {
+			for name, ipSet := range c.Checkpoint() {
+				cp.IPSets[name] = ipSet
+			}
+		}
+	}
+	for _, t := range d.allIptablesTables {
+		cp.IptablesChains[t.CheckpointKey()] = t.Checkpoint()
+	}
+	for _, r := range d.routeTableCheckpointers() {
+		cp.Routes[r.CheckpointKey()] = r.Checkpoint()
+	}
+	data, err := json.Marshal(cp)
+	if err != nil {
+		logCxt.WithError(err).Error("Failed to marshal checkpoint.")
+		return
+	}
+	// Write to a temporary file and rename it so that we never leave a partial checkpoint.
+	tmpFile := d.config.CheckpointFile + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(tmpFile), 0700); err != nil {
+		logCxt.WithError(err).Error("Failed to create checkpoint directory.")
+		return
+	}
+	if err := ioutil.WriteFile(tmpFile, data, 0600); err != nil {
+		logCxt.WithError(err).Error("Failed to write checkpoint.")
+		return
+	}
+	if err := os.Rename(tmpFile, d.config.CheckpointFile); err != nil {
+		logCxt.WithError(err).Error("Failed to rename checkpoint.")
+		return
+	}
+	logCxt.WithFields(log.Fields{
+		"numIPSets":         len(cp.IPSets),
+		"numIptablesTables": len(cp.IptablesChains),
+		"numRouteTables":    len(cp.Routes),
+	}).Info("Wrote dataplane checkpoint.")
+}
+
+// loadCheckpoint loads the checkpoint file, if there is one, and then removes it so that a stale
+// checkpoint can't be loaded again.  The checkpoint is only a hint; the components that use it
+// still check it against the dataplane.
+func (d *InternalDataplane) loadCheckpoint() {
+	if d.config.CheckpointFile == "" {
+		return
+	}
+	logCxt := log.WithField("file", d.config.CheckpointFile)
+	data, err := readCheckpointFile(d.config.CheckpointFile)
+	if os.IsNotExist(err) {
+		logCxt.Info("No dataplane checkpoint.")
+		return
+	}
+	if err := os.Remove(d.config.CheckpointFile); err != nil {
+		logCxt.WithError(err).Warn("Failed to remove dataplane checkpoint.")
+	}
+	if err != nil {
+		logCxt.WithError(err).Warn("Failed to read dataplane checkpoint, ignoring it.")
+		return
+	}
+	cp, err := parseCheckpoint(data, d.config.Hostname)
+	if err != nil {
+		logCxt.WithError(err).Warn("Not using dataplane checkpoint.")
+		return
+	}
+	for _, s := range d.ipSets {
+		if c, ok := s.(ipSetsCheckpointer); ok {
+			c.LoadCheckpoint(cp.IPSets)
+		}
+	}
+	for _, t := range d.allIptablesTables {
+		if chains, ok := cp.IptablesChains[t.CheckpointKey()]; ok {
+			t.LoadCheckpoint(chains)
+		}
+	}
+	for _, r := range d.routeTableCheckpointers() {
+		if routes, ok := cp.Routes[r.CheckpointKey()]; ok {
+			r.LoadCheckpoint(routes)
+		}
+	}
+	logCxt.WithFields(log.Fields{
+		"numIPSets":         len(cp.IPSets),
+		"numIptablesTables": len(cp.IptablesChains),
+		"numRouteTables":    len(cp.Routes),
+	}).Info("Loaded dataplane checkpoint.")
+}
+
+// readCheckpointFile reads the checkpoint file, after checking that we can trust it: the
+// checkpoint may be on a volume that's shared with another Felix, so it must be a regular file
+// that belongs to our user and that no one else can write to.
+func readCheckpointFile(path string) ([]byte, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, errors.New("checkpoint isn't a regular file")
+	}
+	if info.Mode().Perm()&0022 != 0 {
+		return nil, fmt.Errorf("checkpoint is writable by other users (mode %v)", info.Mode().Perm())
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Geteuid() {
+		return nil, fmt.Errorf("checkpoint belongs to user %d, not to us", st.Uid)
+	}
+	return ioutil.ReadAll(f)
+}
+
+// parseCheckpoint parses the checkpoint and checks that it was written by a Felix that uses the
+// same checkpoint format on this node.
+func parseCheckpoint(data []byte, hostname string) (*dataplaneCheckpoint, error) {
+	var cp dataplaneCheckpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+	if cp.Version != checkpointVersion {
+		return nil, fmt.Errorf("checkpoint has version %d, expected %d", cp.Version, checkpointVersion)
+	}
+	if cp.Hostname != hostname {
+		return nil, fmt.Errorf("checkpoint was taken on node %q, not on this node (%q)", cp.Hostname, hostname)
+	}
+	return &cp, nil
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Dataplane checkpoint", func() {
+	var dir, path string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "felix-checkpoint")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(dir, "checkpoint.json")
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(dir)
+	})
+
+	Describe("readCheckpointFile", func() {
+		It("should read a file that only we can write", func() {
+			Expect(ioutil.WriteFile(path, []byte("{}"), 0600)).To(Succeed())
+			Expect(readCheckpointFile(path)).To(Equal([]byte("{}")))
+		})
+
+		It("should reject a file that other users can write", func() {
+			Expect(ioutil.WriteFile(path, []byte("{}"), 0600)).To(Succeed())
+			Expect(os.Chmod(path, 0666)).To(Succeed())
+			_, err := readCheckpointFile(path)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should reject a symlink", func() {
+			target := filepath.Join(dir, "target.json")
+			Expect(ioutil.WriteFile(target, []byte("{}"), 0600)).To(Succeed())
+			Expect(os.Symlink(target, path)).To(Succeed())
+			_, err := readCheckpointFile(path)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("parseCheckpoint", func() {
+		It("should accept a checkpoint from this node with our version", func() {
+			cp, err := parseCheckpoint([]byte(`{"version": 2, "hostname": "node1"}`), "node1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cp.Hostname).To(Equal("node1"))
+		})
+
+		It("should reject a checkpoint without a version", func() {
+			_, err := parseCheckpoint([]byte(`{"hostname": "node1"}`), "node1")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should reject a checkpoint with another version", func() {
+			_, err := parseCheckpoint([]byte(`{"version": 3, "hostname": "node1"}`), "node1")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should reject a checkpoint from another node", func() {
+			_, err := parseCheckpoint([]byte(`{"version": 2, "hostname": "node2"}`), "node1")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,170 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"errors"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/bpf/nat"
+	"github.com/projectcalico/felix/bpf/tc"
+	"github.com/projectcalico/felix/config"
+)
+
+const cleanupTimeout = 20 * time.Second
+
+var errCleanupTimeout = errors.New("timed out waiting for the dataplane to be cleaned up")
+
+type cleanupRequest struct {
+	mode string
+	done chan struct{}
+}
+
+// ipSetsRemover is implemented by the IP sets that can remove all of Felix's IP sets.
+type ipSetsRemover interface {
+	RemoveAllIPSets()
+}
+
+// routeRemover is implemented by the route table syncers that can remove all the routes that
+// they've programmed (and, for wireguard, its routing rule and device).
+type routeRemover interface {
+	RemoveAllRoutes()
+}
+
+// ruleRemover is implemented by the routing rules that can remove all the rules that they've
+// programmed.
+type ruleRemover interface {
+	RemoveAllRules()
+}
+
+// CleanUpDataplane prepares the dataplane for Felix to shut down according to the given shutdown
+// mode; see config.ShutdownMode.  In leave-dataplane mode, it writes the checkpoint, if
+// configured; otherwise it removes Felix's state from the dataplane.  It runs on the main loop
+// and, once it is done, the loop stops updating the dataplane so that it doesn't put back what
+// was removed, or make the checkpoint stale.
+func (d *InternalDataplane) CleanUpDataplane(mode string) error {
+	if mode == config.ShutdownModeLeaveDataplane && d.config.CheckpointFile == "" {
+		// Nothing to do.
+		return nil
+	}
+	req := cleanupRequest{
+		mode: mode,
+		done: make(chan struct{}),
+	}
+	timeout := time.NewTimer(cleanupTimeout)
+	defer timeout.Stop()
+	select {
+	case d.cleanupRequests <- req:
+	case <-timeout.C:
+		return errCleanupTimeout
+	}
+	select {
+	case <-req.done:
+		return nil
+	case <-timeout.C:
+		return errCleanupTimeout
+	}
+}
+
+func (d *InternalDataplane) cleanUp(mode string) {
+	logCxt := log.WithField("mode", mode)
+	d.shutDown = true
+	if mode == config.ShutdownModeLeaveDataplane {
+		d.writeCheckpoint()
+		return
+	}
+	if d.config.BPFEnabled && mode != config.ShutdownModeFullCleanup {
+		logCxt.Warn("Only full cleanup of the dataplane is supported in BPF mode; leaving it.")
+		return
+	}
+	logCxt.Info("Cleaning up the dataplane.")
+
+	// Remove our chains first since they may refer to our IP sets.
+	for _, t := range d.allIptablesTables {
+		t.RemoveAllChainsAndRules()
+	}
+	for _, t := range d.allIptablesTables {
+		t.Apply()
+	}
+	if mode == config.ShutdownModeFullCleanup {
+		for _, s := range d.ipSets {
+			if r, ok := s.(ipSetsRemover); ok {
+				r.RemoveAllIPSets()
+				s.ApplyUpdates()
+				s.ApplyDeletions()
+			}
+		}
+		d.removeRoutesAndDevices()
+		d.removeBPFState()
+	}
+	logCxt.Info("Finished cleaning up the dataplane.")
+}
+
+// removeRoutesAndDevices removes our routes, routing rules and tunnel devices.  It's best effort:
+// failures are logged and the rest carries on.
+func (d *InternalDataplane) removeRoutesAndDevices() {
+	// Stop the device threads first so that they don't put the devices back.
+	if d.vxlanManager != nil {
+		d.vxlanManager.StopDeviceSync()
+	}
+	if d.ipipManager != nil {
+		d.ipipManager.StopDeviceSync()
+	}
+
+	for _, rt := range d.routeTableSyncers() {
+		if r, ok := rt.(routeRemover); ok {
+			r.RemoveAllRoutes()
+		} else if s, ok := rt.(routeRulesSyncer); ok {
+			if r, ok := s.routeRules.(ruleRemover); ok {
+				r.RemoveAllRules()
+			}
+		} else {
+			log.WithField("syncer", rt).Warn("Don't know how to remove routes, leaving them.")
+			continue
+		}
+		if err := rt.Apply(); err != nil {
+			log.WithError(err).Warn("Failed to remove routes or routing rules.")
+		}
+	}
+
+	if d.config.RulesConfig.VXLANEnabled {
+		cleanUpVXLANDevice()
+	}
+	if d.ipipManager != nil {
+		if err := d.ipipManager.RemoveDeviceAddresses(); err != nil {
+			log.WithError(err).Warn("Failed to remove the IPIP tunnel device's address.")
+		}
+	}
+	if d.config.FlowOffloadEnabled {
+		removeFlowOffloadTable(newRealCmd)
+	}
+}
+
+// removeBPFState removes our XDP programs, TC programs, connect-time load balancer and pinned
+// maps.
+func (d *InternalDataplane) removeBPFState() {
+	if err := d.shutdownXDPCompletely(); err != nil {
+		log.WithError(err).Warn("Failed to remove XDP programs.")
+	}
+	if !d.config.BPFEnabled {
+		return
+	}
+	if err := nat.RemoveConnectTimeLoadBalancer(""); err != nil {
+		log.WithError(err).Warn("Failed to remove BPF connect-time load balancer.")
+	}
+	tc.CleanUpProgramsAndPins()
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/ifacemonitor"
+	"github.com/projectcalico/libcalico-go/lib/set"
+)
+
+// prioPriomap sends all traffic to the low priority band (1:2) unless a filter classifies it.
+var prioPriomap = []string{"1", "1", "1", "1", "1", "1", "1", "1", "1", "1", "1", "1", "1", "1", "1", "1"}
+
+// controlPlanePriorityManager prioritises host control plane traffic over workload traffic on the
+// host's uplinks.  It replaces the root qdisc of each matching interface with a two-band prio
+// qdisc: u32 filters put traffic to or from the control plane ports in the high priority band and
+// everything else goes through fq_codel in the low priority band.
+type controlPlanePriorityManager struct {
+	ifacePattern *regexp.Regexp
+	ports        []config.ProtoPort
+	ipv6Enabled  bool
+	newCmd       cmdFactory
+
+	dirtyIfaces set.Set
+}
+
+func newControlPlanePriorityManager(
+	ifacePattern *regexp.Regexp,
+	ports []config.ProtoPort,
+	ipv6Enabled bool,
+	newCmd cmdFactory,
+) *controlPlanePriorityManager {
+	return &controlPlanePriorityManager{
+		ifacePattern: ifacePattern,
+		ports:        ports,
+		ipv6Enabled:  ipv6Enabled,
+		newCmd:       newCmd,
+		dirtyIfaces:  set.New(),
+	}
+}
+
+func (m *controlPlanePriorityManager) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *ifaceUpdate:
+		if !m.ifacePattern.MatchString(msg.Name) {
+			return
+		}
+		// We (re)program the qdisc whenever the interface comes up in case it was recreated.
+		if msg.State == ifacemonitor.StateUp {
+			m.dirtyIfaces.Add(msg.Name)
+		} else {
+			m.dirtyIfaces.Discard(msg.Name)
+		}
+	}
+}
+
+func (m *controlPlanePriorityManager) CompleteDeferredWork() error {
+	var lastErr error
+	m.dirtyIfaces.Iter(func(item interface{}) error {
+		ifaceName := item.(string)
+		if err := m.syncIface(ifaceName); err != nil {
+			log.WithError(err).WithField("iface", ifaceName).Warn(
+				"Failed to configure control plane priority, will retry")
+			lastErr = err
+			return nil
+		}
+		return set.RemoveItem
+	})
+	return lastErr
+}
+
+func (m *controlPlanePriorityManager) syncIface(ifaceName string) error {
+	log.WithField("iface", ifaceName).Info("Configuring control plane priority qdisc")
+
+	// Removing the root qdisc also removes its filters, so we always start from a clean slate.
+	runTCIgnoreErr(m.newCmd, "qdisc", "del", "dev", ifaceName, "root")
+	args := append([]string{"qdisc", "add", "dev", ifaceName, "root", "handle", "1:",
+		"prio", "bands", "2", "priomap"}, prioPriomap...)
+	if err := runTC(m.newCmd, args...); err != nil {
+		return err
+	}
+	if err := runTC(m.newCmd, "qdisc", "add", "dev", ifaceName, "parent", "1:2",
+		"handle", "20:", "fq_codel"); err != nil {
+		return err
+	}
+
+	for _, p := range m.ports {
+		for _, version := range []uint8{4, 6} {
+			if version == 6 && !m.ipv6Enabled {
+				continue
+			}
+			for _, toPort := range []bool{true, false} {
+				match, ok := controlPlaneMatch(p, version, toPort)
+				if !ok {
+					continue
+				}
+				args := append([]string{"filter", "add", "dev", ifaceName, "parent", "1:"}, match...)
+				args = append(args, "flowid", "1:1")
+				if err := runTC(m.newCmd, args...); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// controlPlaneMatch returns the protocol, priority and u32 match arguments of a filter that
+// matches outgoing traffic to (or from) the given port.  If the port is limited to a CIDR, that is
+// the remote end, which is always the destination of outgoing traffic.  It returns false if the
+// port doesn't apply to the IP version.
+func controlPlaneMatch(p config.ProtoPort, version uint8, toPort bool) ([]string, bool) {
+	family, tcProto, prio := "ip", "ip", "1"
+	if version == 6 {
+		family, tcProto, prio = "ip6", "ipv6", "2"
+	}
+
+	protoNum := "6"
+	if p.Protocol == "udp" {
+		protoNum = "17"
+	}
+	portField := "dport"
+	if !toPort {
+		portField = "sport"
+	}
+
+	args := []string{"protocol", tcProto, "prio", prio, "u32",
+		"match", family, "protocol", protoNum, "0xff",
+		"match", family, portField, fmt.Sprint(p.Port), "0xffff"}
+
+	if p.Net != "" {
+		_, cidr, err := net.ParseCIDR(p.Net)
+		if err != nil {
+			log.WithError(err).WithField("net", p.Net).Warn("Ignoring control plane port with invalid CIDR")
+			return nil, false
+		}
+		if (cidr.IP.To4() != nil) != (version == 4) {
+			return nil, false
+		}
+		args = append(args, "match", family, "dst", cidr.String())
+	}
+	return args, true
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"regexp"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/ifacemonitor"
+)
+
+var _ = Describe("Control plane priority manager", func() {
+	var (
+		mgr *controlPlanePriorityManager
+		tc  *tcRecorder
+	)
+
+	BeforeEach(func() {
+		tc = &tcRecorder{}
+		mgr = newControlPlanePriorityManager(
+			regexp.MustCompile("^eth0$"),
+			[]config.ProtoPort{
+				{Protocol: "tcp", Port: 6443},
+				{Protocol: "udp", Net: "10.0.0.0/8", Port: 53},
+			},
+			true,
+			tc.factory,
+		)
+	})
+
+	It("should ignore non-matching interfaces", func() {
+		mgr.OnUpdate(&ifaceUpdate{Name: "cali1", State: ifacemonitor.StateUp})
+		Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+		Expect(tc.takeCmds()).To(BeEmpty())
+	})
+
+	It("should program the prio qdisc and filters when the uplink comes up", func() {
+		mgr.OnUpdate(&ifaceUpdate{Name: "eth0", State: ifacemonitor.StateUp})
+		Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+		Expect(tc.takeCmds()).To(Equal([]string{
+			"tc qdisc del dev eth0 root",
+			"tc qdisc add dev eth0 root handle 1: prio bands 2 priomap 1 1 1 1 1 1 1 1 1 1 1 1 1 1 1 1",
+			"tc qdisc add dev eth0 parent 1:2 handle 20: fq_codel",
+			"tc filter add dev eth0 parent 1: protocol ip prio 1 u32 " +
+				"match ip protocol 6 0xff match ip dport 6443 0xffff flowid 1:1",
+			"tc filter add dev eth0 parent 1: protocol ip prio 1 u32 " +
+				"match ip protocol 6 0xff match ip sport 6443 0xffff flowid 1:1",
+			"tc filter add dev eth0 parent 1: protocol ipv6 prio 2 u32 " +
+				"match ip6 protocol 6 0xff match ip6 dport 6443 0xffff flowid 1:1",
+			"tc filter add dev eth0 parent 1: protocol ipv6 prio 2 u32 " +
+				"match ip6 protocol 6 0xff match ip6 sport 6443 0xffff flowid 1:1",
+			"tc filter add dev eth0 parent 1: protocol ip prio 1 u32 " +
+				"match ip protocol 17 0xff match ip dport 53 0xffff match ip dst 10.0.0.0/8 flowid 1:1",
+			"tc filter add dev eth0 parent 1: protocol ip prio 1 u32 " +
+				"match ip protocol 17 0xff match ip sport 53 0xffff match ip dst 10.0.0.0/8 flowid 1:1",
+		}))
+
+		Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+		Expect(tc.takeCmds()).To(BeEmpty())
+	})
+
+	It("should retry after a failure", func() {
+		tc.failPrefixes = []string{"tc qdisc add dev eth0 parent"}
+		mgr.OnUpdate(&ifaceUpdate{Name: "eth0", State: ifacemonitor.StateUp})
+		Expect(mgr.CompleteDeferredWork()).To(HaveOccurred())
+		Expect(tc.takeCmds()).To(HaveLen(3))
+
+		tc.failPrefixes = nil
+		Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+		Expect(tc.takeCmds()).To(HaveLen(9))
+	})
+
+	It("should give up on an interface that goes down", func() {
+		tc.failPrefixes = []string{"tc qdisc add"}
+		mgr.OnUpdate(&ifaceUpdate{Name: "eth0", State: ifacemonitor.StateUp})
+		Expect(mgr.CompleteDeferredWork()).To(HaveOccurred())
+		tc.takeCmds()
+
+		mgr.OnUpdate(&ifaceUpdate{Name: "eth0", State: ifacemonitor.StateDown})
+		Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+		Expect(tc.takeCmds()).To(BeEmpty())
+	})
+})
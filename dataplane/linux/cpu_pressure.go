@@ -0,0 +1,173 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	systemCPUPressureFile = "/proc/pressure/cpu"
+	cgroupV2Root          = "/sys/fs/cgroup"
+
+	// cpuPressureCacheTime limits how often we read the pressure file.
+	cpuPressureCacheTime = 1 * time.Second
+)
+
+// cpuPressureLimiter reports whether the CPU is too busy for background work, using the
+// kernel's pressure stall information (PSI).  It uses the pressure of Felix's own cgroup (so that
+// it notices Felix being throttled by its CPU limit) if the host uses cgroup v2, and the
+// system-wide pressure otherwise.  A nil *cpuPressureLimiter never reports busy.
+type cpuPressureLimiter struct {
+	// maxPressure is the percentage of time, averaged over 10s, that some task may be stalled
+	// waiting for CPU before we consider the CPU busy.
+	maxPressure  float64
+	pressureFile string
+
+	readFile func(string) ([]byte, error)
+	now      func() time.Time
+
+	lock     sync.Mutex
+	lastRead time.Time
+	lastBusy bool
+}
+
+// newCPUPressureLimiter returns a limiter, or nil if PSI isn't available.
+func newCPUPressureLimiter(maxPressure float64) *cpuPressureLimiter {
+	pressureFile := findCPUPressureFile(ioutil.ReadFile)
+	if pressureFile == "" {
+		log.Warn("CPU pressure information not available (needs kernel 4.20+ with PSI enabled); " +
+			"background resyncs won't be throttled.")
+		return nil
+	}
+	log.WithFields(log.Fields{
+		"file":        pressureFile,
+		"maxPressure": maxPressure,
+	}).Info("Will defer background resyncs while the CPU is busy.")
+	return &cpuPressureLimiter{
+		maxPressure:  maxPressure,
+		pressureFile: pressureFile,
+		readFile:     ioutil.ReadFile,
+		now:          time.Now,
+	}
+}
+
+func findCPUPressureFile(readFile func(string) ([]byte, error)) string {
+	candidates := []string{systemCPUPressureFile}
+	if data, err := readFile("/proc/self/cgroup"); err == nil {
+		// On cgroup v2, the file has a single line of the form "0::<path>".
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.HasPrefix(line, "0::") {
+				cgroupFile := filepath.Join(cgroupV2Root, strings.TrimPrefix(line, "0::"), "cpu.pressure")
+				candidates = append([]string{cgroupFile}, candidates...)
+				break
+			}
+		}
+	}
+	for _, f := range candidates {
+		if _, err := readFile(f); err == nil {
+			return f
+		}
+	}
+	return ""
+}
+
+// Busy returns true if the CPU pressure is above the limit.
+func (l *cpuPressureLimiter) Busy() bool {
+	if l == nil {
+		return false
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastRead) < cpuPressureCacheTime {
+		return l.lastBusy
+	}
+	l.lastRead = now
+
+	data, err := l.readFile(l.pressureFile)
+	if err != nil {
+		log.WithError(err).Debug("Failed to read CPU pressure, assuming not busy")
+		l.lastBusy = false
+		return false
+	}
+	pressure, err := parseCPUPressure(data)
+	if err != nil {
+		log.WithError(err).Debug("Failed to parse CPU pressure, assuming not busy")
+		l.lastBusy = false
+		return false
+	}
+	l.lastBusy = pressure > l.maxPressure
+	if l.lastBusy {
+		log.WithField("pressure", pressure).Debug("CPU is busy")
+	}
+	return l.lastBusy
+}
+
+// parseCPUPressure returns the "some avg10" value from a PSI file, which looks like this:
+//
+//	some avg10=1.23 avg60=0.50 avg300=0.10 total=123456
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func parseCPUPressure(data []byte) (float64, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "some" {
+			continue
+		}
+		for _, f := range fields[1:] {
+			if strings.HasPrefix(f, "avg10=") {
+				return strconv.ParseFloat(strings.TrimPrefix(f, "avg10="), 64)
+			}
+		}
+	}
+	return 0, fmt.Errorf("no 'some avg10' value in %q", data)
+}
+
+// deferrableRefresh tracks a periodic refresh that may be put off while the CPU is busy, by at
+// most maxDelay.
+type deferrableRefresh struct {
+	name         string
+	maxDelay     time.Duration
+	pendingSince time.Time
+}
+
+// Due records a request for the refresh, if requested is true, and returns true if a pending
+// refresh should be done now.
+func (r *deferrableRefresh) Due(limiter *cpuPressureLimiter, requested bool, now time.Time) bool {
+	if requested && r.pendingSince.IsZero() {
+		r.pendingSince = now
+	}
+	if r.pendingSince.IsZero() {
+		return false
+	}
+	if limiter.Busy() && now.Sub(r.pendingSince) < r.maxDelay {
+		log.WithField("refresh", r.name).Debug("CPU busy, deferring refresh")
+		return false
+	}
+	r.pendingSince = time.Time{}
+	return true
+}
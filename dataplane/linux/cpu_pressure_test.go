@@ -0,0 +1,96 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CPU pressure limiter", func() {
+	var (
+		files   map[string]string
+		now     time.Time
+		limiter *cpuPressureLimiter
+	)
+
+	readFile := func(name string) ([]byte, error) {
+		if data, ok := files[name]; ok {
+			return []byte(data), nil
+		}
+		return nil, errors.New("no such file")
+	}
+
+	setPressure := func(avg10 string) {
+		files["/sys/fs/cgroup/felix/cpu.pressure"] = "some avg10=" + avg10 + " avg60=0.00 avg300=0.00 total=1\n" +
+			"full avg10=0.00 avg60=0.00 avg300=0.00 total=0\n"
+	}
+
+	BeforeEach(func() {
+		files = map[string]string{
+			"/proc/self/cgroup":  "0::/felix\n",
+			"/proc/pressure/cpu": "some avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+		}
+		setPressure("0.00")
+		now = time.Now()
+		limiter = &cpuPressureLimiter{
+			maxPressure:  20,
+			pressureFile: findCPUPressureFile(readFile),
+			readFile:     readFile,
+			now:          func() time.Time { return now },
+		}
+	})
+
+	It("should prefer the cgroup's pressure file", func() {
+		Expect(limiter.pressureFile).To(Equal("/sys/fs/cgroup/felix/cpu.pressure"))
+	})
+
+	It("should fall back to the system pressure file", func() {
+		files["/proc/self/cgroup"] = "12:cpu,cpuacct:/felix\n"
+		Expect(findCPUPressureFile(readFile)).To(Equal("/proc/pressure/cpu"))
+	})
+
+	It("should report busy above the limit", func() {
+		Expect(limiter.Busy()).To(BeFalse())
+		setPressure("25.10")
+		// Cached for a second.
+		Expect(limiter.Busy()).To(BeFalse())
+		now = now.Add(cpuPressureCacheTime)
+		Expect(limiter.Busy()).To(BeTrue())
+	})
+
+	It("should not report busy if the file can't be parsed", func() {
+		files["/sys/fs/cgroup/felix/cpu.pressure"] = "garbage"
+		Expect(limiter.Busy()).To(BeFalse())
+	})
+
+	It("should defer a refresh by at most maxDelay", func() {
+		setPressure("50.00")
+		r := deferrableRefresh{name: "test", maxDelay: time.Minute}
+		Expect(r.Due(limiter, false, now)).To(BeFalse())
+		Expect(r.Due(limiter, true, now)).To(BeFalse())
+		Expect(r.Due(limiter, false, now.Add(30*time.Second))).To(BeFalse())
+		Expect(r.Due(limiter, false, now.Add(time.Minute))).To(BeTrue())
+		Expect(r.Due(limiter, false, now.Add(time.Minute))).To(BeFalse())
+	})
+
+	It("should not defer a refresh without a limiter", func() {
+		r := deferrableRefresh{name: "test", maxDelay: time.Minute}
+		Expect(r.Due(nil, true, now)).To(BeTrue())
+	})
+})
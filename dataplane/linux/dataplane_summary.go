@@ -0,0 +1,112 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"time"
+
+	"github.com/projectcalico/felix/nodeconditions"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/libcalico-go/lib/set"
+)
+
+// dataplaneSummaryManager counts the endpoints, policies, IP sets and routes that the dataplane
+// is programming, and records the outcome of each apply, so that the main loop can periodically
+// report a summary of the node's dataplane (see Config.DataplaneSummaryCallback).  It only
+// watches the updates; it doesn't program anything.
+type dataplaneSummaryManager struct {
+	workloadEndpoints set.Set
+	hostEndpoints     set.Set
+	policyRules       map[proto.PolicyID]int
+	profileRules      map[proto.ProfileID]int
+	ipSets            set.Set
+	routes            set.Set
+
+	lastApplyTime time.Duration
+	applyErrors   int
+}
+
+func newDataplaneSummaryManager() *dataplaneSummaryManager {
+	return &dataplaneSummaryManager{
+		workloadEndpoints: set.New(),
+		hostEndpoints:     set.New(),
+		policyRules:       map[proto.PolicyID]int{},
+		profileRules:      map[proto.ProfileID]int{},
+		ipSets:            set.New(),
+		routes:            set.New(),
+	}
+}
+
+func (m *dataplaneSummaryManager) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *proto.WorkloadEndpointUpdate:
+		m.workloadEndpoints.Add(*msg.Id)
+	case *proto.WorkloadEndpointRemove:
+		m.workloadEndpoints.Discard(*msg.Id)
+	case *proto.HostEndpointUpdate:
+		m.hostEndpoints.Add(*msg.Id)
+	case *proto.HostEndpointRemove:
+		m.hostEndpoints.Discard(*msg.Id)
+	case *proto.ActivePolicyUpdate:
+		m.policyRules[*msg.Id] = len(msg.Policy.InboundRules) + len(msg.Policy.OutboundRules)
+	case *proto.ActivePolicyRemove:
+		delete(m.policyRules, *msg.Id)
+	case *proto.ActiveProfileUpdate:
+		m.profileRules[*msg.Id] = len(msg.Profile.InboundRules) + len(msg.Profile.OutboundRules)
+	case *proto.ActiveProfileRemove:
+		delete(m.profileRules, *msg.Id)
+	case *proto.IPSetUpdate:
+		m.ipSets.Add(msg.Id)
+	case *proto.IPSetRemove:
+		m.ipSets.Discard(msg.Id)
+	case *proto.RouteUpdate:
+		m.routes.Add(msg.Dst)
+	case *proto.RouteRemove:
+		m.routes.Discard(msg.Dst)
+	}
+}
+
+func (m *dataplaneSummaryManager) CompleteDeferredWork() error {
+	return nil
+}
+
+// recordApply records the duration of an apply and whether it left the dataplane out of sync.
+func (m *dataplaneSummaryManager) recordApply(applyTime time.Duration, failed bool) {
+	m.lastApplyTime = applyTime
+	if failed {
+		m.applyErrors++
+	}
+}
+
+func (m *dataplaneSummaryManager) summary(now time.Time) nodeconditions.DataplaneSummary {
+	s := nodeconditions.DataplaneSummary{
+		Timestamp:               now.UTC().Format(time.RFC3339),
+		WorkloadEndpoints:       m.workloadEndpoints.Len(),
+		HostEndpoints:           m.hostEndpoints.Len(),
+		Policies:                len(m.policyRules),
+		Profiles:                len(m.profileRules),
+		IPSets:                  m.ipSets.Len(),
+		Routes:                  m.routes.Len(),
+		LastApplyLatencySeconds: m.lastApplyTime.Seconds(),
+		ApplyErrors:             m.applyErrors,
+	}
+	for _, n := range m.policyRules {
+		s.Rules += n
+	}
+	for _, n := range m.profileRules {
+		s.Rules += n
+	}
+	return s
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/nodeconditions"
+	"github.com/projectcalico/felix/proto"
+)
+
+var _ = Describe("Dataplane summary manager", func() {
+	var mgr *dataplaneSummaryManager
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	BeforeEach(func() {
+		mgr = newDataplaneSummaryManager()
+	})
+
+	It("should count what the dataplane is programming", func() {
+		wlID := proto.WorkloadEndpointID{OrchestratorId: "k8s", WorkloadId: "ns/pod", EndpointId: "eth0"}
+		polID := proto.PolicyID{Tier: "default", Name: "pol1"}
+		profID := proto.ProfileID{Name: "prof1"}
+		mgr.OnUpdate(&proto.WorkloadEndpointUpdate{Id: &wlID, Endpoint: &proto.WorkloadEndpoint{}})
+		mgr.OnUpdate(&proto.HostEndpointUpdate{Id: &proto.HostEndpointID{EndpointId: "hep1"}, Endpoint: &proto.HostEndpoint{}})
+		mgr.OnUpdate(&proto.ActivePolicyUpdate{Id: &polID, Policy: &proto.Policy{
+			InboundRules:  []*proto.Rule{{Action: "allow"}, {Action: "deny"}},
+			OutboundRules: []*proto.Rule{{Action: "allow"}},
+		}})
+		mgr.OnUpdate(&proto.ActiveProfileUpdate{Id: &profID, Profile: &proto.Profile{
+			InboundRules: []*proto.Rule{{Action: "allow"}},
+		}})
+		mgr.OnUpdate(&proto.IPSetUpdate{Id: "s:abc"})
+		mgr.OnUpdate(&proto.RouteUpdate{Dst: "10.0.1.0/26"})
+		mgr.OnUpdate(&proto.RouteUpdate{Dst: "10.0.1.0/26"})
+		mgr.recordApply(250*time.Millisecond, false)
+
+		Expect(mgr.summary(now)).To(Equal(nodeconditions.DataplaneSummary{
+			Timestamp:               "2021-06-01T12:00:00Z",
+			WorkloadEndpoints:       1,
+			HostEndpoints:           1,
+			Policies:                1,
+			Profiles:                1,
+			Rules:                   4,
+			IPSets:                  1,
+			Routes:                  1,
+			LastApplyLatencySeconds: 0.25,
+		}))
+
+		mgr.OnUpdate(&proto.WorkloadEndpointRemove{Id: &wlID})
+		mgr.OnUpdate(&proto.ActivePolicyRemove{Id: &polID})
+		mgr.OnUpdate(&proto.RouteRemove{Dst: "10.0.1.0/26"})
+		mgr.recordApply(time.Second, true)
+		mgr.recordApply(time.Second, true)
+
+		s := mgr.summary(now)
+		Expect(s.WorkloadEndpoints).To(Equal(0))
+		Expect(s.Policies).To(Equal(0))
+		Expect(s.Rules).To(Equal(1))
+		Expect(s.Routes).To(Equal(0))
+		Expect(s.ApplyErrors).To(Equal(2))
+	})
+})
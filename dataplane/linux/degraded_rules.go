@@ -0,0 +1,194 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+)
+
+// degradedRules collects the policies, profiles and features that we can't render as written on
+// this node, for example because iptables lacks a match that they need, so that policy authors
+// can find out that they are enforced in a weaker or stricter form.  Issues are keyed by their
+// subject (for example "policy default/foo (IPv4)"); report passes the full list to the callback
+// when it has changed.  It is only used from the main dataplane goroutine.  A nil *degradedRules
+// records nothing.
+type degradedRules struct {
+	issues   map[string]string
+	callback func(issues []string)
+
+	reported     []string
+	reportedOnce bool
+}
+
+func newDegradedRules(callback func(issues []string)) *degradedRules {
+	return &degradedRules{
+		issues:   map[string]string{},
+		callback: callback,
+	}
+}
+
+// set records the issue with the given subject, replacing any previous one.  An empty issue
+// clears it.
+func (d *degradedRules) set(subject, issue string) {
+	if d == nil || d.issues[subject] == issue {
+		return
+	}
+	if issue == "" {
+		log.WithField("subject", subject).Info("Rules can now be rendered as written.")
+		delete(d.issues, subject)
+		return
+	}
+	log.WithFields(log.Fields{"subject": subject, "issue": issue}).Warn(
+		"Can't render rules as written on this node.")
+	d.issues[subject] = issue
+}
+
+func (d *degradedRules) clear(subject string) {
+	d.set(subject, "")
+}
+
+// report calls the callback with the sorted issues if they have changed since the last call, or
+// if this is the first call.
+func (d *degradedRules) report() {
+	if d == nil {
+		return
+	}
+	issues := []string{}
+	for subject, issue := range d.issues {
+		issues = append(issues, subject+": "+issue)
+	}
+	sort.Strings(issues)
+	if d.reportedOnce && reflect.DeepEqual(issues, d.reported) {
+		return
+	}
+	d.reported = issues
+	d.reportedOnce = true
+	if d.callback != nil {
+		d.callback(issues)
+	}
+}
+
+// degradedRulesManager records, in degradedRules, the policies and profiles with rules that the
+// dataplane renders in a weaker or stricter form because of its mode or configuration.  Policies
+// and profiles whose IP sets have to be inlined are recorded by the ipSetInliningPolicyManager.
+type degradedRulesManager struct {
+	degradedRules *degradedRules
+	// bpfEnabled is set in BPF mode, which doesn't support destination domains.
+	bpfEnabled bool
+	// nat64Enabled is set if IPv4 CIDRs in rules are also rendered with their NAT64 equivalents.
+	nat64Enabled bool
+	// sctpUnsupported is set if the kernel's conntrack doesn't support SCTP, in which case all
+	// SCTP packets are INVALID and our rules drop them.  It's set by configureKernel(), once it
+	// has tried to load the SCTP conntrack module, before the main loop starts.
+	sctpUnsupported bool
+}
+
+// sctpConntrackSysctl only exists if the kernel's conntrack supports SCTP.
+const sctpConntrackSysctl = "/proc/sys/net/netfilter/nf_conntrack_sctp_timeout_established"
+
+func sctpConntrackSupported() bool {
+	_, err := os.Stat(sctpConntrackSysctl)
+	return err == nil
+}
+
+func newDegradedRulesManager(degradedRules *degradedRules, bpfEnabled, nat64Enabled bool) *degradedRulesManager {
+	return &degradedRulesManager{
+		degradedRules: degradedRules,
+		bpfEnabled:    bpfEnabled,
+		nat64Enabled:  nat64Enabled,
+	}
+}
+
+func (m *degradedRulesManager) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *proto.ActivePolicyUpdate:
+		m.degradedRules.set(degradedPolicySubject(*msg.Id),
+			m.issue(msg.Policy.InboundRules, msg.Policy.OutboundRules))
+	case *proto.ActivePolicyRemove:
+		m.degradedRules.clear(degradedPolicySubject(*msg.Id))
+	case *proto.ActiveProfileUpdate:
+		m.degradedRules.set(degradedProfileSubject(*msg.Id),
+			m.issue(msg.Profile.InboundRules, msg.Profile.OutboundRules))
+	case *proto.ActiveProfileRemove:
+		m.degradedRules.clear(degradedProfileSubject(*msg.Id))
+	}
+}
+
+func (m *degradedRulesManager) CompleteDeferredWork() error {
+	m.degradedRules.report()
+	return nil
+}
+
+// issue describes the ways in which the given rules are rendered differently to how they're
+// written, or returns "" if they aren't.
+func (m *degradedRulesManager) issue(ruleLists ...[]*proto.Rule) string {
+	var domains, nat64ICMP, sctp bool
+	for _, ruleList := range ruleLists {
+		for _, rule := range ruleList {
+			if m.bpfEnabled && len(rule.DstDomains) > 0 {
+				domains = true
+			}
+			if m.nat64Enabled && rules.NAT64SkipsRule(rule) {
+				nat64ICMP = true
+			}
+			if m.sctpUnsupported && isSCTP(rule.Protocol) {
+				sctp = true
+			}
+		}
+	}
+	var issues []string
+	if domains {
+		issues = append(issues, "destination domains aren't supported in BPF mode; allow rules "+
+			"that use them are left out and other rules that use them deny all destinations")
+	}
+	if nat64ICMP {
+		issues = append(issues, "ICMP rules with IPv4 CIDRs don't match the CIDRs' NAT64 addresses")
+	}
+	if sctp {
+		issues = append(issues, "the kernel's conntrack doesn't support SCTP so SCTP packets are "+
+			"dropped as INVALID whatever the rules say")
+	}
+	return strings.Join(issues, "; ")
+}
+
+func isSCTP(protocol *proto.Protocol) bool {
+	if protocol == nil {
+		return false
+	}
+	switch p := protocol.NumberOrName.(type) {
+	case *proto.Protocol_Name:
+		return strings.ToLower(p.Name) == "sctp"
+	case *proto.Protocol_Number:
+		return p.Number == 132
+	}
+	return false
+}
+
+func degradedPolicySubject(id proto.PolicyID) string {
+	return fmt.Sprintf("policy %s/%s", id.Tier, id.Name)
+}
+
+func degradedProfileSubject(id proto.ProfileID) string {
+	return fmt.Sprintf("profile %s", id.Name)
+}
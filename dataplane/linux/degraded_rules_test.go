@@ -0,0 +1,97 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/proto"
+)
+
+var _ = Describe("Degraded rules manager", func() {
+	var (
+		reported []string
+		polID    = proto.PolicyID{Tier: "default", Name: "pol1"}
+		profID   = proto.ProfileID{Name: "prof1"}
+	)
+
+	newManager := func(bpfEnabled, nat64Enabled bool) *degradedRulesManager {
+		reported = nil
+		return newDegradedRulesManager(newDegradedRules(func(issues []string) {
+			reported = issues
+		}), bpfEnabled, nat64Enabled)
+	}
+
+	domainRule := &proto.Rule{Action: "allow", DstDomains: []string{"example.com"}}
+	icmpRule := &proto.Rule{
+		Action:    "allow",
+		IpVersion: proto.IPVersion_IPV4,
+		Protocol:  &proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "icmp"}},
+		DstNet:    []string{"10.0.0.0/8"},
+	}
+
+	It("should report domain rules in BPF mode", func() {
+		m := newManager(true, false)
+		m.OnUpdate(&proto.ActivePolicyUpdate{Id: &polID, Policy: &proto.Policy{
+			OutboundRules: []*proto.Rule{domainRule, icmpRule},
+		}})
+		Expect(m.CompleteDeferredWork()).To(Succeed())
+		Expect(reported).To(HaveLen(1))
+		Expect(reported[0]).To(HavePrefix("policy default/pol1: destination domains"))
+
+		m.OnUpdate(&proto.ActivePolicyRemove{Id: &polID})
+		Expect(m.CompleteDeferredWork()).To(Succeed())
+		Expect(reported).To(BeEmpty())
+	})
+
+	It("should report ICMP rules that aren't mapped to NAT64 addresses", func() {
+		m := newManager(false, true)
+		m.OnUpdate(&proto.ActiveProfileUpdate{Id: &profID, Profile: &proto.Profile{
+			InboundRules: []*proto.Rule{domainRule, icmpRule},
+		}})
+		Expect(m.CompleteDeferredWork()).To(Succeed())
+		Expect(reported).To(Equal([]string{
+			"profile prof1: ICMP rules with IPv4 CIDRs don't match the CIDRs' NAT64 addresses",
+		}))
+
+		m.OnUpdate(&proto.ActiveProfileUpdate{Id: &profID, Profile: &proto.Profile{}})
+		Expect(m.CompleteDeferredWork()).To(Succeed())
+		Expect(reported).To(BeEmpty())
+	})
+
+	It("should report SCTP rules when the kernel doesn't support SCTP conntrack", func() {
+		m := newManager(false, false)
+		m.sctpUnsupported = true
+		m.OnUpdate(&proto.ActivePolicyUpdate{Id: &polID, Policy: &proto.Policy{
+			InboundRules: []*proto.Rule{
+				icmpRule,
+				{Action: "allow", Protocol: &proto.Protocol{NumberOrName: &proto.Protocol_Number{Number: 132}}},
+			},
+		}})
+		Expect(m.CompleteDeferredWork()).To(Succeed())
+		Expect(reported).To(HaveLen(1))
+		Expect(reported[0]).To(HavePrefix("policy default/pol1: the kernel's conntrack doesn't support SCTP"))
+	})
+
+	It("should report nothing when the rules are rendered as written", func() {
+		m := newManager(false, false)
+		m.OnUpdate(&proto.ActivePolicyUpdate{Id: &polID, Policy: &proto.Policy{
+			OutboundRules: []*proto.Rule{domainRule, icmpRule},
+		}})
+		Expect(m.CompleteDeferredWork()).To(Succeed())
+		Expect(reported).To(BeEmpty())
+	})
+})
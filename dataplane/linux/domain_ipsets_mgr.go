@@ -0,0 +1,349 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+	"github.com/projectcalico/libcalico-go/lib/set"
+)
+
+const (
+	// domainIPsMinRetention is the minimum time that we keep an address in a domain set for,
+	// even if its DNS TTL is shorter.  Clients often keep using an address after its TTL has
+	// expired and we'd rather not cut off their connections.
+	domainIPsMinRetention = 5 * time.Minute
+	// domainIPsExpiryInterval is how often the main loop gives us a chance to expire addresses.
+	domainIPsExpiryInterval = 30 * time.Second
+)
+
+// domainIPsUpdate is sent to the managers, via the dataplane's main loop, when a DNS response
+// (captured, or to one of our own lookups) resolves a policy domain to an address.
+type domainIPsUpdate struct {
+	Domain string
+	IP     net.IP
+	TTL    time.Duration
+}
+
+// domainIPSetsManager maintains the IP sets behind rules that match on destination domains.
+// Each distinct list of domains has a set (see rules.DomainIPSetID), which holds the addresses
+// that the domains have been seen to resolve to, until their TTLs (or domainIPsMinRetention)
+// run out.  A rule's set starts off empty so, until a domain has been resolved, the rule
+// matches nothing.
+//
+// The manager is used by the main loop but wantsDomain is also called from the DNS capture and
+// resolver goroutines, so the set of domains that we're interested in has its own lock.
+type domainIPSetsManager struct {
+	ipsetsDataplanes []ipsetsDataplane
+	maxIPSetSize     int
+	onDomainsChanged func(domains []string)
+
+	// Domain lists of the rules in each policy and profile.
+	policyDomains  map[proto.PolicyID][][]string
+	profileDomains map[proto.ProfileID][][]string
+
+	// sets holds the active domain sets, by IP set ID.
+	sets map[string]*domainIPSet
+	// addrs maps each domain name that we've seen resolved to its addresses' expiry times.
+	addrs map[string]map[string]time.Time
+
+	dirtySets    set.Set
+	domainsDirty bool
+
+	wantedLock     sync.RWMutex
+	wantedExact    map[string]bool
+	wantedSuffixes []string
+
+	// Shim for testing.
+	now func() time.Time
+}
+
+type domainIPSet struct {
+	domains  []string
+	refCount int
+}
+
+func newDomainIPSetsManager(
+	ipsetsDataplanes []ipsetsDataplane,
+	maxIPSetSize int,
+	onDomainsChanged func(domains []string),
+) *domainIPSetsManager {
+	return &domainIPSetsManager{
+		ipsetsDataplanes: ipsetsDataplanes,
+		maxIPSetSize:     maxIPSetSize,
+		onDomainsChanged: onDomainsChanged,
+
+		policyDomains:  map[proto.PolicyID][][]string{},
+		profileDomains: map[proto.ProfileID][][]string{},
+		sets:           map[string]*domainIPSet{},
+		addrs:          map[string]map[string]time.Time{},
+		dirtySets:      set.New(),
+		wantedExact:    map[string]bool{},
+
+		now: time.Now,
+	}
+}
+
+func (m *domainIPSetsManager) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *proto.ActivePolicyUpdate:
+		domains := domainsOfRules(msg.Policy.InboundRules, msg.Policy.OutboundRules)
+		m.setRuleDomains(m.policyDomains[*msg.Id], domains)
+		if len(domains) > 0 {
+			m.policyDomains[*msg.Id] = domains
+		} else {
+			delete(m.policyDomains, *msg.Id)
+		}
+	case *proto.ActivePolicyRemove:
+		m.setRuleDomains(m.policyDomains[*msg.Id], nil)
+		delete(m.policyDomains, *msg.Id)
+	case *proto.ActiveProfileUpdate:
+		domains := domainsOfRules(msg.Profile.InboundRules, msg.Profile.OutboundRules)
+		m.setRuleDomains(m.profileDomains[*msg.Id], domains)
+		if len(domains) > 0 {
+			m.profileDomains[*msg.Id] = domains
+		} else {
+			delete(m.profileDomains, *msg.Id)
+		}
+	case *proto.ActiveProfileRemove:
+		m.setRuleDomains(m.profileDomains[*msg.Id], nil)
+		delete(m.profileDomains, *msg.Id)
+	case *domainIPsUpdate:
+		m.onDomainIP(msg)
+	}
+}
+
+// setRuleDomains updates the sets' reference counts when a policy or profile's domain lists
+// change from old to new.
+func (m *domainIPSetsManager) setRuleDomains(old, new [][]string) {
+	for _, domains := range new {
+		id := rules.DomainIPSetID(domains)
+		s := m.sets[id]
+		if s == nil {
+			s = &domainIPSet{domains: domains}
+			m.sets[id] = s
+			m.dirtySets.Add(id)
+			m.domainsDirty = true
+		}
+		s.refCount++
+	}
+	for _, domains := range old {
+		id := rules.DomainIPSetID(domains)
+		s := m.sets[id]
+		s.refCount--
+		if s.refCount == 0 {
+			delete(m.sets, id)
+			m.dirtySets.Add(id)
+			m.domainsDirty = true
+		}
+	}
+}
+
+func (m *domainIPSetsManager) onDomainIP(upd *domainIPsUpdate) {
+	domain := normaliseDomain(upd.Domain)
+	ttl := upd.TTL
+	if ttl < domainIPsMinRetention {
+		ttl = domainIPsMinRetention
+	}
+	addr := upd.IP.String()
+	expiry := m.now().Add(ttl)
+
+	addrs := m.addrs[domain]
+	if addrs == nil {
+		addrs = map[string]time.Time{}
+		m.addrs[domain] = addrs
+	}
+	oldExpiry, known := addrs[addr]
+	if expiry.After(oldExpiry) {
+		addrs[addr] = expiry
+	}
+	if !known {
+		m.markSetsDirty(domain)
+	}
+}
+
+// markSetsDirty marks the sets whose domains match the given name as needing an update.
+func (m *domainIPSetsManager) markSetsDirty(name string) {
+	for id, s := range m.sets {
+		for _, d := range s.domains {
+			if domainMatches(d, name) {
+				m.dirtySets.Add(id)
+				break
+			}
+		}
+	}
+}
+
+func (m *domainIPSetsManager) CompleteDeferredWork() error {
+	now := m.now()
+	for domain, addrs := range m.addrs {
+		for addr, expiry := range addrs {
+			if !now.Before(expiry) {
+				delete(addrs, addr)
+				m.markSetsDirty(domain)
+			}
+		}
+		if len(addrs) == 0 {
+			delete(m.addrs, domain)
+		}
+	}
+
+	if m.domainsDirty {
+		m.updateWantedDomains()
+		m.domainsDirty = false
+	}
+
+	m.dirtySets.Iter(func(item interface{}) error {
+		id := item.(string)
+		s := m.sets[id]
+		for _, dp := range m.ipsetsDataplanes {
+			if s == nil {
+				dp.RemoveIPSet(id)
+				continue
+			}
+			dp.AddOrReplaceIPSet(ipsets.IPSetMetadata{
+				MaxSize: m.maxIPSetSize,
+				SetID:   id,
+				Type:    ipsets.IPSetTypeHashIP,
+			}, m.members(s, dp.GetIPFamily().Version()))
+		}
+		return set.RemoveItem
+	})
+	return nil
+}
+
+// members returns the unexpired addresses, of the given IP version, of the names that match the
+// set's domains.
+func (m *domainIPSetsManager) members(s *domainIPSet, ipVersion int) []string {
+	members := set.New()
+	for name, addrs := range m.addrs {
+		matched := false
+		for _, d := range s.domains {
+			if domainMatches(d, name) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		for addr := range addrs {
+			if (net.ParseIP(addr).To4() != nil) == (ipVersion == 4) {
+				members.Add(addr)
+			}
+		}
+	}
+	var out []string
+	members.Iter(func(item interface{}) error {
+		out = append(out, item.(string))
+		return nil
+	})
+	sort.Strings(out)
+	if len(out) > m.maxIPSetSize {
+		log.WithFields(log.Fields{
+			"domains":    s.domains,
+			"numMembers": len(out),
+			"maxSize":    m.maxIPSetSize,
+		}).Warn("Domains resolve to more addresses than fit in an IP set, leaving some out.")
+		out = out[:m.maxIPSetSize]
+	}
+	return out
+}
+
+// updateWantedDomains recalculates the domains that wantsDomain accepts and passes them to the
+// onDomainsChanged callback.
+func (m *domainIPSetsManager) updateWantedDomains() {
+	all := set.New()
+	for _, s := range m.sets {
+		for _, d := range s.domains {
+			all.Add(d)
+		}
+	}
+	exact := map[string]bool{}
+	var suffixes, domains []string
+	all.Iter(func(item interface{}) error {
+		d := item.(string)
+		domains = append(domains, d)
+		if strings.HasPrefix(d, "*.") {
+			suffixes = append(suffixes, d[1:])
+		} else {
+			exact[d] = true
+		}
+		return nil
+	})
+	sort.Strings(domains)
+
+	m.wantedLock.Lock()
+	m.wantedExact = exact
+	m.wantedSuffixes = suffixes
+	m.wantedLock.Unlock()
+
+	log.WithField("domains", domains).Info("Policy domains changed.")
+	if m.onDomainsChanged != nil {
+		m.onDomainsChanged(domains)
+	}
+}
+
+// wantsDomain returns true if the given name matches a domain that is used in policy.  It may be
+// called from any goroutine.
+func (m *domainIPSetsManager) wantsDomain(name string) bool {
+	name = normaliseDomain(name)
+	m.wantedLock.RLock()
+	defer m.wantedLock.RUnlock()
+	if m.wantedExact[name] {
+		return true
+	}
+	for _, suffix := range m.wantedSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// domainsOfRules returns the domain lists of the rules that match on domains.
+func domainsOfRules(ruleLists ...[]*proto.Rule) [][]string {
+	var domains [][]string
+	for _, rs := range ruleLists {
+		for _, r := range rs {
+			if len(r.DstDomains) > 0 {
+				domains = append(domains, r.DstDomains)
+			}
+		}
+	}
+	return domains
+}
+
+// domainMatches returns true if the name matches the (normalised) domain from a rule; a domain
+// starting with "*." matches any subdomain of the rest of it.
+func domainMatches(domain, name string) bool {
+	if strings.HasPrefix(domain, "*.") {
+		return strings.HasSuffix(name, domain[1:])
+	}
+	return domain == name
+}
+
+func normaliseDomain(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
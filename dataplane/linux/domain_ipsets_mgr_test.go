@@ -0,0 +1,117 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+	"github.com/projectcalico/libcalico-go/lib/set"
+)
+
+var _ = Describe("Domain IP sets manager", func() {
+	var (
+		mgr     *domainIPSetsManager
+		ipSets  *mockIPSets
+		now     time.Time
+		domains []string
+		polID   = proto.PolicyID{Tier: "default", Name: "pol1"}
+		setID   = rules.DomainIPSetID([]string{"*.example.com", "example.org"})
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+		domains = nil
+		ipSets = newMockIPSets()
+		mgr = newDomainIPSetsManager([]ipsetsDataplane{ipSets}, 1024, func(d []string) {
+			domains = d
+		})
+		mgr.now = func() time.Time { return now }
+
+		mgr.OnUpdate(&proto.ActivePolicyUpdate{
+			Id: &polID,
+			Policy: &proto.Policy{OutboundRules: []*proto.Rule{
+				{Action: "allow", DstDomains: []string{"*.example.com", "example.org"}},
+			}},
+		})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+	})
+
+	observe := func(domain, addr string, ttl time.Duration) {
+		mgr.OnUpdate(&domainIPsUpdate{Domain: domain, IP: net.ParseIP(addr), TTL: ttl})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+	}
+
+	It("should create an empty set and report the domains", func() {
+		Expect(ipSets.Members).To(Equal(map[string]set.Set{setID: set.New()}))
+		Expect(domains).To(Equal([]string{"*.example.com", "example.org"}))
+		Expect(mgr.wantsDomain("Example.org.")).To(BeTrue())
+		Expect(mgr.wantsDomain("www.example.com")).To(BeTrue())
+		Expect(mgr.wantsDomain("example.com")).To(BeFalse())
+		Expect(mgr.wantsDomain("example.net")).To(BeFalse())
+	})
+
+	It("should add the addresses that the domains resolve to", func() {
+		observe("example.org.", "192.0.2.1", time.Hour)
+		observe("www.example.com", "192.0.2.2", time.Hour)
+		observe("www.example.com", "2001:db8::1", time.Hour)
+		observe("example.net", "192.0.2.3", time.Hour)
+		Expect(ipSets.Members[setID]).To(Equal(set.From("192.0.2.1", "192.0.2.2")))
+	})
+
+	It("should expire addresses after their TTL, but not too soon", func() {
+		observe("example.org", "192.0.2.1", time.Second)
+		observe("example.org", "192.0.2.2", time.Hour)
+		now = now.Add(domainIPsMinRetention - time.Second)
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(ipSets.Members[setID]).To(Equal(set.From("192.0.2.1", "192.0.2.2")))
+		now = now.Add(time.Second)
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(ipSets.Members[setID]).To(Equal(set.From("192.0.2.2")))
+	})
+
+	It("should remove the set when no rules use it", func() {
+		mgr.OnUpdate(&proto.ActivePolicyUpdate{
+			Id:     &polID,
+			Policy: &proto.Policy{OutboundRules: []*proto.Rule{{Action: "allow"}}},
+		})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(ipSets.Members).To(BeEmpty())
+		Expect(domains).To(BeEmpty())
+		Expect(mgr.wantsDomain("example.org")).To(BeFalse())
+	})
+
+	It("should share a set between rules with the same domains", func() {
+		profID := proto.ProfileID{Name: "prof1"}
+		mgr.OnUpdate(&proto.ActiveProfileUpdate{
+			Id: &profID,
+			Profile: &proto.Profile{OutboundRules: []*proto.Rule{
+				{Action: "allow", DstDomains: []string{"*.example.com", "example.org"}},
+			}},
+		})
+		mgr.OnUpdate(&proto.ActivePolicyRemove{Id: &polID})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(ipSets.Members).To(HaveKey(setID))
+
+		mgr.OnUpdate(&proto.ActiveProfileRemove{Id: &profID})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(ipSets.Members).To(BeEmpty())
+	})
+})
@@ -0,0 +1,445 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/ifacemonitor"
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/logutils"
+	"github.com/projectcalico/felix/netlinkshim"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/routerule"
+	"github.com/projectcalico/felix/routetable"
+	"github.com/projectcalico/felix/rules"
+	"github.com/projectcalico/libcalico-go/lib/set"
+)
+
+// routeRules is the interface provided by the standard routerule module used to program routing
+// rules.
+type routeRules interface {
+	SetRule(rule *routerule.Rule)
+	RemoveRule(rule *routerule.Rule)
+	QueueResync()
+	Apply() error
+}
+
+// routeRulesSyncer adapts routeRules to the routeTableSyncer interface so that the main loop
+// applies (and periodically resyncs) the routing rules along with the routing tables.
+type routeRulesSyncer struct {
+	routeRules
+}
+
+func (s routeRulesSyncer) OnIfaceStateChanged(string, ifacemonitor.State) {}
+
+var defaultV4CIDR = ip.MustParseCIDROrIP("0.0.0.0/0")
+
+// egressGatewayManager steers the egress traffic of local workloads to the egress gateways that
+// are configured by EgressGatewaySteering.  Each steering rule has its own routing table, whose
+// default route sends traffic to one of the rule's gateways.  Each node picks the gateway by
+// hashing its hostname so that nodes are spread across a rule's gateways.  The table also has
+// throw routes for the IP pools and the hosts so that traffic within the cluster isn't steered.
+// A routing rule for each local workload IP then sends the workload's traffic to the table of the
+// first steering rule that selects it.
+//
+// Since the traffic isn't addressed to the gateway, the default route can't simply point at the
+// gateway's IP: an IPIP route's gateway is the outer destination, which has to be a node.  So a
+// node without a local gateway sends the traffic over IPIP to the node that hosts the chosen
+// gateway, which it learns from the workload routes.  A node that hosts one of a rule's gateways
+// routes the rule's traffic straight to a local gateway's interface instead, and has routing rules
+// for all of the rule's clients so that the traffic that arrives from other nodes takes the same
+// route.
+//
+// The workloads and gateways that each steering rule selects come from IP sets that the
+// calculation graph maintains; see rules.EgressGatewayClientIPSetID and rules.EgressGatewayIPSetID.
+type egressGatewayManager struct {
+	hostname     string
+	rulePriority int
+
+	// Our dependencies.  routeTables holds the routing table for each steering rule and
+	// tableIndices the corresponding table indices.
+	routeTables  []routeTable
+	tableIndices []int
+	routeRules   routeRules
+
+	// Internal state.  ipSetIDToClients and ipSetIDToGateways map the IDs of our IP sets to
+	// the index of the steering rule that they belong to.
+	ipSetIDToClients  map[string]int
+	ipSetIDToGateways map[string]int
+	clientCIDRs       []set.Set /* ip.CIDR */
+	gatewayAddrs      []set.Set /* ip.Addr */
+	localWorkloadIPs  map[proto.WorkloadEndpointID][]ip.CIDR
+	poolCIDRs         map[string]ip.CIDR
+	hostAddrs         map[string]ip.Addr
+	// activeRules maps each workload CIDR that we've programmed a routing rule for to the index
+	// of its steering rule.
+	activeRules map[ip.CIDR]int
+	dirty       bool
+
+	// localWorkloadIfaces holds the interface of each local workload.  remoteWorkloadRoutes holds
+	// the routes to remote workloads, indexed by destination, for finding the nodes that host
+	// remote gateways.
+	localWorkloadIfaces  map[proto.WorkloadEndpointID]string
+	remoteWorkloadRoutes map[ip.CIDR]*proto.RouteUpdate
+	// gatewayIfaces holds, for each steering rule, the interface of the local gateway that its
+	// table routes to, if any.
+	gatewayIfaces []string
+}
+
+func newEgressGatewayManagerFromConfig(config Config, opRecorder logutils.OpRecorder) *egressGatewayManager {
+	tableIndexSet := set.New()
+	wlIfacesPattern := "^(" + strings.Join(config.RulesConfig.WorkloadIfacePrefixes, "|") + ").*"
+	var routeTables []routeTable
+	for _, idx := range config.EgressGatewayRouteTableIndices {
+		tableIndexSet.Add(idx)
+		routeTables = append(routeTables, routetable.New(
+			[]string{"^tunl0$", wlIfacesPattern, routetable.InterfaceNone},
+			4,
+			false, // vxlan
+			config.NetlinkTimeout,
+			nil, // deviceRouteSourceAddress
+			config.DeviceRouteProtocol,
+			true, // removeExternalRoutes
+			idx,
+			opRecorder,
+		))
+	}
+	rr, err := routerule.New(
+		4,
+		config.EgressGatewayRoutingRulePriority,
+		tableIndexSet,
+		routerule.RulesMatchSrcFWMarkTable,
+		routerule.RulesMatchSrcFWMarkTable,
+		config.NetlinkTimeout,
+		func() (routerule.HandleIface, error) {
+			return netlinkshim.NewRealNetlink()
+		},
+		opRecorder,
+	)
+	if err != nil {
+		log.WithError(err).Panic("Unexpected error creating egress gateway rule manager")
+	}
+	return newEgressGatewayManager(
+		config.Hostname,
+		routeTables,
+		config.EgressGatewayRouteTableIndices,
+		rr,
+		config.EgressGatewayRoutingRulePriority,
+	)
+}
+
+func newEgressGatewayManager(
+	hostname string,
+	routeTables []routeTable,
+	tableIndices []int,
+	routeRules routeRules,
+	rulePriority int,
+) *egressGatewayManager {
+	m := &egressGatewayManager{
+		hostname:     hostname,
+		rulePriority: rulePriority,
+		routeTables:  routeTables,
+		tableIndices: tableIndices,
+		routeRules:   routeRules,
+
+		ipSetIDToClients:  map[string]int{},
+		ipSetIDToGateways: map[string]int{},
+		localWorkloadIPs:  map[proto.WorkloadEndpointID][]ip.CIDR{},
+		poolCIDRs:         map[string]ip.CIDR{},
+		hostAddrs:         map[string]ip.Addr{},
+		activeRules:       map[ip.CIDR]int{},
+		dirty:             true,
+
+		localWorkloadIfaces:  map[proto.WorkloadEndpointID]string{},
+		remoteWorkloadRoutes: map[ip.CIDR]*proto.RouteUpdate{},
+	}
+	for i := range routeTables {
+		m.ipSetIDToClients[rules.EgressGatewayClientIPSetID(i)] = i
+		m.ipSetIDToGateways[rules.EgressGatewayIPSetID(i)] = i
+		m.clientCIDRs = append(m.clientCIDRs, set.New())
+		m.gatewayAddrs = append(m.gatewayAddrs, set.New())
+		m.gatewayIfaces = append(m.gatewayIfaces, "")
+	}
+	return m
+}
+
+func (m *egressGatewayManager) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *proto.IPSetUpdate:
+		m.onIPSetMembers(msg.Id, nil, true)
+		m.onIPSetMembers(msg.Id, msg.Members, true)
+	case *proto.IPSetDeltaUpdate:
+		m.onIPSetMembers(msg.Id, msg.RemovedMembers, false)
+		m.onIPSetMembers(msg.Id, msg.AddedMembers, true)
+	case *proto.IPSetRemove:
+		m.onIPSetMembers(msg.Id, nil, true)
+	case *proto.WorkloadEndpointUpdate:
+		var cidrs []ip.CIDR
+		for _, s := range msg.Endpoint.Ipv4Nets {
+			cidrs = append(cidrs, ip.MustParseCIDROrIP(s))
+		}
+		m.localWorkloadIPs[*msg.Id] = cidrs
+		m.localWorkloadIfaces[*msg.Id] = msg.Endpoint.Name
+		m.dirty = true
+	case *proto.WorkloadEndpointRemove:
+		delete(m.localWorkloadIPs, *msg.Id)
+		delete(m.localWorkloadIfaces, *msg.Id)
+		m.dirty = true
+	case *proto.RouteUpdate:
+		dst, err := ip.ParseCIDROrIP(msg.Dst)
+		if err != nil || dst.Version() != 4 {
+			return
+		}
+		if msg.Type == proto.RouteType_REMOTE_WORKLOAD && msg.DstNodeIp != "" {
+			m.remoteWorkloadRoutes[dst] = msg
+		} else {
+			delete(m.remoteWorkloadRoutes, dst)
+		}
+		m.dirty = true
+	case *proto.RouteRemove:
+		if dst, err := ip.ParseCIDROrIP(msg.Dst); err == nil {
+			delete(m.remoteWorkloadRoutes, dst)
+			m.dirty = true
+		}
+	case *proto.IPAMPoolUpdate:
+		m.poolCIDRs[msg.Id] = ip.MustParseCIDROrIP(msg.Pool.Cidr)
+		m.dirty = true
+	case *proto.IPAMPoolRemove:
+		delete(m.poolCIDRs, msg.Id)
+		m.dirty = true
+	case *proto.HostMetadataUpdate:
+		m.hostAddrs[msg.Hostname] = ip.FromString(msg.Ipv4Addr)
+		m.dirty = true
+	case *proto.HostMetadataRemove:
+		delete(m.hostAddrs, msg.Hostname)
+		m.dirty = true
+	}
+}
+
+// onIPSetMembers adds or removes members of one of our IP sets.  Passing nil members with add
+// set clears the IP set.
+func (m *egressGatewayManager) onIPSetMembers(ipSetID string, members []string, add bool) {
+	var s set.Set
+	isClients := false
+	if i, ok := m.ipSetIDToClients[ipSetID]; ok {
+		s = m.clientCIDRs[i]
+		isClients = true
+	} else if i, ok := m.ipSetIDToGateways[ipSetID]; ok {
+		s = m.gatewayAddrs[i]
+	} else {
+		return
+	}
+	m.dirty = true
+	if members == nil && add {
+		s.Clear()
+		return
+	}
+	for _, member := range members {
+		cidr, err := ip.ParseCIDROrIP(member)
+		if err != nil || cidr.Version() != 4 {
+			continue
+		}
+		var item interface{} = cidr.Addr()
+		if isClients {
+			item = cidr
+		}
+		if add {
+			s.Add(item)
+		} else {
+			s.Discard(item)
+		}
+	}
+}
+
+func (m *egressGatewayManager) CompleteDeferredWork() error {
+	if !m.dirty {
+		return nil
+	}
+	m.updateRouteTables()
+	m.updateRouteRules()
+	m.dirty = false
+	return nil
+}
+
+func (m *egressGatewayManager) updateRouteTables() {
+	// Traffic to the IP pools and the hosts stays within the cluster so it isn't steered.
+	var throwCIDRs []ip.CIDR
+	for _, cidr := range m.poolCIDRs {
+		if cidr.Version() == 4 {
+			throwCIDRs = append(throwCIDRs, cidr)
+		}
+	}
+	for _, addr := range m.hostAddrs {
+		if addr != nil && addr.Version() == 4 {
+			throwCIDRs = append(throwCIDRs, addr.AsCIDR())
+		}
+	}
+	sort.Slice(throwCIDRs, func(i, j int) bool {
+		return throwCIDRs[i].String() < throwCIDRs[j].String()
+	})
+	var throwRoutes []routetable.Target
+	for _, cidr := range throwCIDRs {
+		throwRoutes = append(throwRoutes, routetable.Target{
+			Type: routetable.TargetTypeThrow,
+			CIDR: cidr,
+		})
+	}
+
+	localIfaces := m.localWorkloadIfacesByAddr()
+	for i, rt := range m.routeTables {
+		rt.SetRoutes(routetable.InterfaceNone, throwRoutes)
+		var localGateways, remoteGateways []ip.Addr
+		m.gatewayAddrs[i].Iter(func(item interface{}) error {
+			addr := item.(ip.Addr)
+			if _, ok := localIfaces[addr]; ok {
+				localGateways = append(localGateways, addr)
+			} else if m.gatewayNodeIP(addr) != nil {
+				remoteGateways = append(remoteGateways, addr)
+			}
+			return nil
+		})
+
+		var tunnelRoutes []routetable.Target
+		gatewayIface := ""
+		if gw := m.chooseGateway(localGateways); gw != nil {
+			gatewayIface = localIfaces[gw]
+		} else if gw := m.chooseGateway(remoteGateways); gw != nil {
+			tunnelRoutes = []routetable.Target{{
+				Type: routetable.TargetTypeNoEncap,
+				CIDR: defaultV4CIDR,
+				GW:   m.gatewayNodeIP(gw),
+			}}
+		} else {
+			// Without a route, lookups fall through to the main table so the traffic leaves
+			// the node as if it wasn't steered.
+			log.WithField("rule", i).Debug("No reachable egress gateways for rule.")
+		}
+		rt.SetRoutes("tunl0", tunnelRoutes)
+		if m.gatewayIfaces[i] != "" && m.gatewayIfaces[i] != gatewayIface {
+			rt.SetRoutes(m.gatewayIfaces[i], nil)
+		}
+		if gatewayIface != "" {
+			rt.SetRoutes(gatewayIface, []routetable.Target{{CIDR: defaultV4CIDR}})
+		}
+		m.gatewayIfaces[i] = gatewayIface
+	}
+}
+
+// localWorkloadIfacesByAddr maps the IPs of the local workloads to their interfaces.
+func (m *egressGatewayManager) localWorkloadIfacesByAddr() map[ip.Addr]string {
+	ifaces := map[ip.Addr]string{}
+	for id, cidrs := range m.localWorkloadIPs {
+		for _, cidr := range cidrs {
+			ifaces[cidr.Addr()] = m.localWorkloadIfaces[id]
+		}
+	}
+	return ifaces
+}
+
+// gatewayNodeIP returns the IP of the node that hosts the given remote gateway, from the most
+// specific workload route that covers it, or nil if we don't know it.
+func (m *egressGatewayManager) gatewayNodeIP(gw ip.Addr) ip.Addr {
+	var best ip.CIDR
+	for dst := range m.remoteWorkloadRoutes {
+		if !ip.CIDRContains(dst, gw.AsCIDR()) {
+			continue
+		}
+		if best == nil || dst.Prefix() > best.Prefix() {
+			best = dst
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return ip.FromString(m.remoteWorkloadRoutes[best].DstNodeIp)
+}
+
+// chooseGateway returns this node's choice from the given gateways, or nil if there are none.
+func (m *egressGatewayManager) chooseGateway(gateways []ip.Addr) ip.Addr {
+	if len(gateways) == 0 {
+		return nil
+	}
+	sort.Slice(gateways, func(i, j int) bool {
+		return gateways[i].String() < gateways[j].String()
+	})
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(m.hostname))
+	return gateways[h.Sum32()%uint32(len(gateways))]
+}
+
+func (m *egressGatewayManager) updateRouteRules() {
+	wanted := map[ip.CIDR]int{}
+	for _, cidrs := range m.localWorkloadIPs {
+		for _, cidr := range cidrs {
+			for i, clients := range m.clientCIDRs {
+				if clients.Contains(cidr) {
+					wanted[cidr] = i
+					break
+				}
+			}
+		}
+	}
+	// If we route a rule to a local gateway, the rule's remote clients send their traffic to us
+	// too, so they need routing rules here.  A client uses the first rule that selects it.
+	for i, iface := range m.gatewayIfaces {
+		if iface == "" {
+			continue
+		}
+		m.clientCIDRs[i].Iter(func(item interface{}) error {
+			cidr := item.(ip.CIDR)
+			if _, ok := wanted[cidr]; ok || m.gatewayAddrs[i].Contains(cidr.Addr()) {
+				return nil
+			}
+			for j := 0; j < i; j++ {
+				if m.clientCIDRs[j].Contains(cidr) {
+					return nil
+				}
+			}
+			wanted[cidr] = i
+			return nil
+		})
+	}
+	for cidr, i := range m.activeRules {
+		if wantedIdx, ok := wanted[cidr]; !ok || wantedIdx != i {
+			m.routeRules.RemoveRule(m.makeRule(cidr, i))
+			delete(m.activeRules, cidr)
+		}
+	}
+	for cidr, i := range wanted {
+		if _, ok := m.activeRules[cidr]; !ok {
+			m.routeRules.SetRule(m.makeRule(cidr, i))
+			m.activeRules[cidr] = i
+		}
+	}
+}
+
+func (m *egressGatewayManager) makeRule(cidr ip.CIDR, ruleIdx int) *routerule.Rule {
+	return routerule.NewRule(4, m.rulePriority).
+		MatchSrcAddress(cidr.ToIPNet()).
+		GoToTable(m.tableIndices[ruleIdx])
+}
+
+func (m *egressGatewayManager) GetRouteTableSyncers() []routeTableSyncer {
+	syncers := []routeTableSyncer{routeRulesSyncer{m.routeRules}}
+	for _, rt := range m.routeTables {
+		syncers = append(syncers, rt)
+	}
+	return syncers
+}
@@ -0,0 +1,213 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/routerule"
+	"github.com/projectcalico/felix/routetable"
+)
+
+type mockRouteRules struct {
+	rules map[string]int
+}
+
+func (r *mockRouteRules) SetRule(rule *routerule.Rule) {
+	nlRule := rule.NetLinkRule()
+	r.rules[nlRule.Src.String()] = nlRule.Table
+}
+
+func (r *mockRouteRules) RemoveRule(rule *routerule.Rule) {
+	delete(r.rules, rule.NetLinkRule().Src.String())
+}
+
+func (r *mockRouteRules) QueueResync() {}
+
+func (r *mockRouteRules) Apply() error {
+	return nil
+}
+
+var _ = Describe("Egress gateway manager", func() {
+	var (
+		egwMgr     *egressGatewayManager
+		rt0, rt1   *mockRouteTable
+		routeRules *mockRouteRules
+	)
+
+	newRT := func() *mockRouteTable {
+		return &mockRouteTable{
+			currentRoutes:   map[string][]routetable.Target{},
+			currentL2Routes: map[string][]routetable.L2Target{},
+		}
+	}
+
+	BeforeEach(func() {
+		rt0 = newRT()
+		rt1 = newRT()
+		routeRules = &mockRouteRules{rules: map[string]int{}}
+		egwMgr = newEgressGatewayManager("host1", []routeTable{rt0, rt1}, []int{10, 11}, routeRules, 105)
+
+		egwMgr.OnUpdate(&proto.IPAMPoolUpdate{
+			Id:   "pool1",
+			Pool: &proto.IPAMPool{Cidr: "10.0.0.0/16", Masquerade: true},
+		})
+		egwMgr.OnUpdate(&proto.HostMetadataUpdate{Hostname: "host1", Ipv4Addr: "192.168.0.1"})
+		egwMgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id:       &proto.WorkloadEndpointID{OrchestratorId: "k8s", WorkloadId: "ns/pod1", EndpointId: "eth0"},
+			Endpoint: &proto.WorkloadEndpoint{Ipv4Nets: []string{"10.0.0.1/32"}},
+		})
+		egwMgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id:       &proto.WorkloadEndpointID{OrchestratorId: "k8s", WorkloadId: "ns/pod2", EndpointId: "eth0"},
+			Endpoint: &proto.WorkloadEndpoint{Ipv4Nets: []string{"10.0.0.2/32"}},
+		})
+	})
+
+	throwRoutes := []routetable.Target{
+		{Type: routetable.TargetTypeThrow, CIDR: ip.MustParseCIDROrIP("10.0.0.0/16")},
+		{Type: routetable.TargetTypeThrow, CIDR: ip.MustParseCIDROrIP("192.168.0.1/32")},
+	}
+
+	It("should program throw routes but no default routes without gateways", func() {
+		Expect(egwMgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+		rt0.checkRoutes(routetable.InterfaceNone, throwRoutes)
+		rt0.checkRoutes("tunl0", nil)
+		rt1.checkRoutes(routetable.InterfaceNone, throwRoutes)
+		Expect(routeRules.rules).To(BeEmpty())
+	})
+
+	Describe("with clients and gateways", func() {
+		BeforeEach(func() {
+			egwMgr.OnUpdate(&proto.IPSetUpdate{
+				Id:      "egw-client-0",
+				Members: []string{"10.0.0.1/32", "10.0.5.5/32"},
+				Type:    proto.IPSetUpdate_NET,
+			})
+			egwMgr.OnUpdate(&proto.IPSetUpdate{
+				Id:      "egw-client-1",
+				Members: []string{"10.0.0.1/32", "10.0.0.2/32"},
+				Type:    proto.IPSetUpdate_NET,
+			})
+			egwMgr.OnUpdate(&proto.IPSetUpdate{
+				Id:      "egw-gw-0",
+				Members: []string{"10.0.9.9/32"},
+				Type:    proto.IPSetUpdate_NET,
+			})
+			egwMgr.OnUpdate(&proto.RouteUpdate{
+				Type:        proto.RouteType_REMOTE_WORKLOAD,
+				Dst:         "10.0.9.0/26",
+				DstNodeName: "host2",
+				DstNodeIp:   "192.168.0.2",
+			})
+			Expect(egwMgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+		})
+
+		It("should route to the gateway's node over IPIP", func() {
+			rt0.checkRoutes("tunl0", []routetable.Target{{
+				Type: routetable.TargetTypeNoEncap,
+				CIDR: ip.MustParseCIDROrIP("0.0.0.0/0"),
+				GW:   ip.FromString("192.168.0.2"),
+			}})
+			rt1.checkRoutes("tunl0", nil)
+		})
+
+		It("should use the most specific route to the gateway", func() {
+			egwMgr.OnUpdate(&proto.RouteUpdate{
+				Type:        proto.RouteType_REMOTE_WORKLOAD,
+				Dst:         "10.0.9.9/32",
+				DstNodeName: "host3",
+				DstNodeIp:   "192.168.0.3",
+			})
+			Expect(egwMgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+			rt0.checkRoutes("tunl0", []routetable.Target{{
+				Type: routetable.TargetTypeNoEncap,
+				CIDR: ip.MustParseCIDROrIP("0.0.0.0/0"),
+				GW:   ip.FromString("192.168.0.3"),
+			}})
+		})
+
+		It("should remove the default route when the gateway's node is unknown", func() {
+			egwMgr.OnUpdate(&proto.RouteRemove{Dst: "10.0.9.0/26"})
+			Expect(egwMgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+			rt0.checkRoutes("tunl0", nil)
+		})
+
+		It("should route to a local gateway's interface and steer all the rule's clients", func() {
+			egwMgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+				Id:       &proto.WorkloadEndpointID{OrchestratorId: "k8s", WorkloadId: "ns/gw", EndpointId: "eth0"},
+				Endpoint: &proto.WorkloadEndpoint{Name: "cali1234", Ipv4Nets: []string{"10.0.9.10/32"}},
+			})
+			egwMgr.OnUpdate(&proto.IPSetDeltaUpdate{
+				Id:           "egw-gw-0",
+				AddedMembers: []string{"10.0.9.10/32"},
+			})
+			Expect(egwMgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+			rt0.checkRoutes("tunl0", nil)
+			rt0.checkRoutes("cali1234", []routetable.Target{{
+				CIDR: ip.MustParseCIDROrIP("0.0.0.0/0"),
+			}})
+			Expect(routeRules.rules).To(Equal(map[string]int{
+				"10.0.0.1/32": 10,
+				"10.0.0.2/32": 11,
+				"10.0.5.5/32": 10,
+			}))
+
+			egwMgr.OnUpdate(&proto.WorkloadEndpointRemove{
+				Id: &proto.WorkloadEndpointID{OrchestratorId: "k8s", WorkloadId: "ns/gw", EndpointId: "eth0"},
+			})
+			Expect(egwMgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+			rt0.checkRoutes("cali1234", nil)
+			Expect(routeRules.rules).NotTo(HaveKey("10.0.5.5/32"))
+		})
+
+		It("should steer local clients to the table of their first rule", func() {
+			Expect(routeRules.rules).To(Equal(map[string]int{
+				"10.0.0.1/32": 10,
+				"10.0.0.2/32": 11,
+			}))
+		})
+
+		It("should move a client when it leaves a rule", func() {
+			egwMgr.OnUpdate(&proto.IPSetDeltaUpdate{
+				Id:             "egw-client-0",
+				RemovedMembers: []string{"10.0.0.1/32"},
+			})
+			Expect(egwMgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+			Expect(routeRules.rules).To(Equal(map[string]int{
+				"10.0.0.1/32": 11,
+				"10.0.0.2/32": 11,
+			}))
+		})
+
+		It("should remove the rule when the workload is removed", func() {
+			egwMgr.OnUpdate(&proto.WorkloadEndpointRemove{
+				Id: &proto.WorkloadEndpointID{OrchestratorId: "k8s", WorkloadId: "ns/pod2", EndpointId: "eth0"},
+			})
+			Expect(egwMgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+			Expect(routeRules.rules).To(Equal(map[string]int{
+				"10.0.0.1/32": 10,
+			}))
+		})
+
+		It("should remove the default route when the gateways go away", func() {
+			egwMgr.OnUpdate(&proto.IPSetRemove{Id: "egw-gw-0"})
+			Expect(egwMgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+			rt0.checkRoutes("tunl0", nil)
+		})
+	})
+})
@@ -0,0 +1,198 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"regexp"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/logutils"
+	"github.com/projectcalico/felix/netlinkshim"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/routerule"
+	"github.com/projectcalico/felix/routetable"
+	"github.com/projectcalico/libcalico-go/lib/set"
+)
+
+// egressInterfaceManager sends traffic out of the host interfaces that EgressInterfaces selects,
+// for nodes that have separate networks for, say, storage and management.  Each item has its own
+// routing table and a routing rule that matches the item's source or destination CIDR and looks
+// up the table.  The table of a "to" item has a route for its CIDR via the item's interface; the
+// table of a "from" item has a default route via the interface plus throw routes for the IP pools
+// and hosts, so that traffic within the cluster isn't diverted.
+type egressInterfaceManager struct {
+	items        []config.EgressInterfaceRule
+	rulePriority int
+
+	// Our dependencies.  routeTables holds the routing table for each item and tableIndices the
+	// corresponding table indices.
+	routeTables  []routeTable
+	tableIndices []int
+	routeRules   routeRules
+
+	// Internal state.
+	poolCIDRs map[string]ip.CIDR
+	hostAddrs map[string]ip.Addr
+	dirty     bool
+}
+
+func newEgressInterfaceManagerFromConfig(config Config, opRecorder logutils.OpRecorder) *egressInterfaceManager {
+	tableIndexSet := set.New()
+	var routeTables []routeTable
+	for i, idx := range config.EgressInterfaceRouteTableIndices {
+		tableIndexSet.Add(idx)
+		routeTables = append(routeTables, routetable.New(
+			[]string{"^" + regexp.QuoteMeta(config.EgressInterfaces[i].Interface) + "$", routetable.InterfaceNone},
+			4,
+			false, // vxlan
+			config.NetlinkTimeout,
+			nil, // deviceRouteSourceAddress
+			config.DeviceRouteProtocol,
+			true, // removeExternalRoutes
+			idx,
+			opRecorder,
+		))
+	}
+	rr, err := routerule.New(
+		4,
+		config.EgressInterfaceRoutingRulePriority,
+		tableIndexSet,
+		routerule.RulesMatchSrcDstFWMarkTable,
+		routerule.RulesMatchSrcDstFWMarkTable,
+		config.NetlinkTimeout,
+		func() (routerule.HandleIface, error) {
+			return netlinkshim.NewRealNetlink()
+		},
+		opRecorder,
+	)
+	if err != nil {
+		log.WithError(err).Panic("Unexpected error creating egress interface rule manager")
+	}
+	return newEgressInterfaceManager(
+		config.EgressInterfaces,
+		routeTables,
+		config.EgressInterfaceRouteTableIndices,
+		rr,
+		config.EgressInterfaceRoutingRulePriority,
+	)
+}
+
+func newEgressInterfaceManager(
+	items []config.EgressInterfaceRule,
+	routeTables []routeTable,
+	tableIndices []int,
+	routeRules routeRules,
+	rulePriority int,
+) *egressInterfaceManager {
+	m := &egressInterfaceManager{
+		items:        items,
+		rulePriority: rulePriority,
+		routeTables:  routeTables,
+		tableIndices: tableIndices,
+		routeRules:   routeRules,
+
+		poolCIDRs: map[string]ip.CIDR{},
+		hostAddrs: map[string]ip.Addr{},
+		dirty:     true,
+	}
+	// The routing rules only depend on the configuration.
+	for i, item := range items {
+		cidr := ip.MustParseCIDROrIP(item.CIDR).ToIPNet()
+		if item.Direction == "to" {
+			m.routeRules.SetRule(routerule.NewRule(4, rulePriority).
+				MatchDstAddress(cidr).
+				GoToTable(tableIndices[i]))
+		} else {
+			m.routeRules.SetRule(routerule.NewRule(4, rulePriority+1).
+				MatchSrcAddress(cidr).
+				GoToTable(tableIndices[i]))
+		}
+	}
+	return m
+}
+
+func (m *egressInterfaceManager) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *proto.IPAMPoolUpdate:
+		m.poolCIDRs[msg.Id] = ip.MustParseCIDROrIP(msg.Pool.Cidr)
+		m.dirty = true
+	case *proto.IPAMPoolRemove:
+		delete(m.poolCIDRs, msg.Id)
+		m.dirty = true
+	case *proto.HostMetadataUpdate:
+		m.hostAddrs[msg.Hostname] = ip.FromString(msg.Ipv4Addr)
+		m.dirty = true
+	case *proto.HostMetadataRemove:
+		delete(m.hostAddrs, msg.Hostname)
+		m.dirty = true
+	}
+}
+
+func (m *egressInterfaceManager) CompleteDeferredWork() error {
+	if !m.dirty {
+		return nil
+	}
+
+	var throwCIDRs []ip.CIDR
+	for _, cidr := range m.poolCIDRs {
+		if cidr.Version() == 4 {
+			throwCIDRs = append(throwCIDRs, cidr)
+		}
+	}
+	for _, addr := range m.hostAddrs {
+		if addr != nil && addr.Version() == 4 {
+			throwCIDRs = append(throwCIDRs, addr.AsCIDR())
+		}
+	}
+	sort.Slice(throwCIDRs, func(i, j int) bool {
+		return throwCIDRs[i].String() < throwCIDRs[j].String()
+	})
+	var throwRoutes []routetable.Target
+	for _, cidr := range throwCIDRs {
+		throwRoutes = append(throwRoutes, routetable.Target{
+			Type: routetable.TargetTypeThrow,
+			CIDR: cidr,
+		})
+	}
+
+	for i, item := range m.items {
+		route := routetable.Target{CIDR: defaultV4CIDR}
+		if item.Direction == "to" {
+			route.CIDR = ip.MustParseCIDROrIP(item.CIDR)
+			m.routeTables[i].SetRoutes(routetable.InterfaceNone, nil)
+		} else {
+			m.routeTables[i].SetRoutes(routetable.InterfaceNone, throwRoutes)
+		}
+		if item.Gateway != "" {
+			route.Type = routetable.TargetTypeNoEncap
+			route.GW = ip.FromString(item.Gateway)
+		}
+		m.routeTables[i].SetRoutes(item.Interface, []routetable.Target{route})
+	}
+	m.dirty = false
+	return nil
+}
+
+func (m *egressInterfaceManager) GetRouteTableSyncers() []routeTableSyncer {
+	syncers := []routeTableSyncer{routeRulesSyncer{m.routeRules}}
+	for _, rt := range m.routeTables {
+		syncers = append(syncers, rt)
+	}
+	return syncers
+}
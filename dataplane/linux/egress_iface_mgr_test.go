@@ -0,0 +1,124 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/routerule"
+	"github.com/projectcalico/felix/routetable"
+)
+
+// mockSrcDstRouteRules records the rules that are set as "<priority> from <src> to <dst>" strings.
+type mockSrcDstRouteRules struct {
+	rules map[string]int
+}
+
+func (r *mockSrcDstRouteRules) key(rule *routerule.Rule) string {
+	nlRule := rule.NetLinkRule()
+	return fmt.Sprintf("%d from %v to %v", nlRule.Priority, nlRule.Src, nlRule.Dst)
+}
+
+func (r *mockSrcDstRouteRules) SetRule(rule *routerule.Rule) {
+	r.rules[r.key(rule)] = rule.NetLinkRule().Table
+}
+
+func (r *mockSrcDstRouteRules) RemoveRule(rule *routerule.Rule) {
+	delete(r.rules, r.key(rule))
+}
+
+func (r *mockSrcDstRouteRules) QueueResync() {}
+
+func (r *mockSrcDstRouteRules) Apply() error {
+	return nil
+}
+
+var _ = Describe("Egress interface manager", func() {
+	var (
+		mgr        *egressInterfaceManager
+		rt0, rt1   *mockRouteTable
+		routeRules *mockSrcDstRouteRules
+	)
+
+	newRT := func() *mockRouteTable {
+		return &mockRouteTable{
+			currentRoutes:   map[string][]routetable.Target{},
+			currentL2Routes: map[string][]routetable.L2Target{},
+		}
+	}
+
+	BeforeEach(func() {
+		rt0 = newRT()
+		rt1 = newRT()
+		routeRules = &mockSrcDstRouteRules{rules: map[string]int{}}
+		mgr = newEgressInterfaceManager(
+			[]config.EgressInterfaceRule{
+				{Direction: "from", CIDR: "10.65.0.0/16", Interface: "eth1", Gateway: "192.168.1.1"},
+				{Direction: "to", CIDR: "10.200.0.0/16", Interface: "eth2"},
+			},
+			[]routeTable{rt0, rt1},
+			[]int{20, 21},
+			routeRules,
+			102,
+		)
+
+		mgr.OnUpdate(&proto.IPAMPoolUpdate{
+			Id:   "pool1",
+			Pool: &proto.IPAMPool{Cidr: "10.65.0.0/16"},
+		})
+		mgr.OnUpdate(&proto.HostMetadataUpdate{Hostname: "host1", Ipv4Addr: "192.168.0.1"})
+		Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+	})
+
+	It("should program a routing rule for each item", func() {
+		Expect(routeRules.rules).To(Equal(map[string]int{
+			"103 from 10.65.0.0/16 to <nil>":  20,
+			"102 from <nil> to 10.200.0.0/16": 21,
+		}))
+	})
+
+	It("should send pool traffic out of its interface, except within the cluster", func() {
+		rt0.checkRoutes("eth1", []routetable.Target{{
+			Type: routetable.TargetTypeNoEncap,
+			CIDR: ip.MustParseCIDROrIP("0.0.0.0/0"),
+			GW:   ip.FromString("192.168.1.1"),
+		}})
+		rt0.checkRoutes(routetable.InterfaceNone, []routetable.Target{
+			{Type: routetable.TargetTypeThrow, CIDR: ip.MustParseCIDROrIP("10.65.0.0/16")},
+			{Type: routetable.TargetTypeThrow, CIDR: ip.MustParseCIDROrIP("192.168.0.1/32")},
+		})
+	})
+
+	It("should send destination traffic out of its interface", func() {
+		rt1.checkRoutes("eth2", []routetable.Target{{
+			CIDR: ip.MustParseCIDROrIP("10.200.0.0/16"),
+		}})
+		rt1.checkRoutes(routetable.InterfaceNone, nil)
+	})
+
+	It("should remove the throw route when a pool is removed", func() {
+		mgr.OnUpdate(&proto.IPAMPoolRemove{Id: "pool1"})
+		Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+		rt0.checkRoutes(routetable.InterfaceNone, []routetable.Target{
+			{Type: routetable.TargetTypeThrow, CIDR: ip.MustParseCIDROrIP("192.168.0.1/32")},
+		})
+	})
+})
@@ -22,15 +22,19 @@ import (
 	"reflect"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/projectcalico/felix/config"
 	"github.com/projectcalico/felix/ifacemonitor"
 	"github.com/projectcalico/felix/ip"
 	"github.com/projectcalico/felix/iptables"
 	"github.com/projectcalico/felix/proto"
 	"github.com/projectcalico/felix/routetable"
 	"github.com/projectcalico/felix/rules"
+	"github.com/projectcalico/libcalico-go/lib/selector"
 	"github.com/projectcalico/libcalico-go/lib/set"
 )
 
@@ -47,8 +51,13 @@ type routeTable interface {
 	routeTableSyncer
 	SetRoutes(ifaceName string, targets []routetable.Target)
 	SetL2Routes(ifaceName string, targets []routetable.L2Target)
+	IfaceSyncErrors() map[string]error
 }
 
+// maxIfaceParentDepth limits how far we follow the chain of parent interfaces when looking for
+// a host endpoint to inherit.
+const maxIfaceParentDepth = 8
+
 type hepListener interface {
 	OnHEPUpdate(hostIfaceToEpMap map[string]proto.HostEndpoint)
 }
@@ -135,6 +144,9 @@ type endpointManager struct {
 	ipVersion              uint8
 	wlIfacesRegexp         *regexp.Regexp
 	kubeIPVSSupportEnabled bool
+	// autoHostEpIfaceRegexps matches the host interfaces that the implicit host endpoint (see
+	// config.AutoHostEndpointID) applies to.
+	autoHostEpIfaceRegexps []*regexp.Regexp
 
 	// Our dependencies.
 	rawTable     iptablesTable
@@ -158,6 +170,13 @@ type endpointManager struct {
 	activeWlIDToChains         map[proto.WorkloadEndpointID][]*iptables.Chain
 	activeWlDispatchChains     map[string]*iptables.Chain
 	activeEPMarkDispatchChains map[string]*iptables.Chain
+	// activeVRFSetMarkChains (mangle) and activeVRFDispatchChains (filter) are the chains that
+	// we've programmed for workloads whose interfaces are in a VRF.
+	activeVRFSetMarkChains  map[string]*iptables.Chain
+	activeVRFDispatchChains map[string]*iptables.Chain
+	// wlChainRefCounts counts the workloads that use each of the chains in activeWlIDToChains;
+	// workloads with the same policies share policy group chains.
+	wlChainRefCounts map[string]int
 
 	// Workload endpoints that would be locally active but are 'shadowed' by other endpoints
 	// with the same interface name.
@@ -166,16 +185,48 @@ type endpointManager struct {
 	// wlIfaceNamesToReconfigure contains names of workload interfaces that need to have
 	// their configuration (sysctls etc.) refreshed.
 	wlIfaceNamesToReconfigure set.Set
+	// wlIfaceNameToProgrammingErr records the most recent error that we hit while programming
+	// each workload interface.  Passed up to the status reporter as the reason for the
+	// "error" status.
+	wlIfaceNameToProgrammingErr map[string]string
+	// wlIfaceNameToRouteErr records the workload interfaces whose routes the route table failed
+	// to program on its last Apply(), with the reason.
+	wlIfaceNameToRouteErr map[string]string
+	// wlIfaceNameToRestoreErr records the workload interfaces that use a chain that
+	// iptables-restore is failing on, with the reason.
+	wlIfaceNameToRestoreErr map[string]string
 
 	// epIDsToUpdateStatus contains IDs of endpoints that we need to report status for.
 	// Mix of host and workload endpoint IDs.
 	epIDsToUpdateStatus set.Set
 
+	// wlPolicyGenerations counts, for each active workload endpoint, the changes to the
+	// endpoint and to the policies and profiles that apply to it that we've programmed.  It is
+	// reported with the endpoint's status.  Each count starts from the time, in microseconds,
+	// that the endpoint became active, so that it doesn't go backwards when Felix restarts or
+	// the endpoint is re-created.
+	wlPolicyGenerations map[proto.WorkloadEndpointID]uint64
+	timeNow             func() time.Time
+	// pendingPolicyChanges contains the proto.PolicyID and proto.ProfileID of the policies and
+	// profiles that have changed since the last CompleteDeferredWork.
+	pendingPolicyChanges set.Set
+
 	// hostIfaceToAddrs maps host interface name to the set of IPs on that interface (reported
 	// fro the dataplane).
 	hostIfaceToAddrs map[string]set.Set
+	// ifaceVRFs maps the interfaces that are enslaved to a VRF to the VRF device's name.  Only
+	// populated when VRF support is enabled.
+	ifaceVRFs map[string]string
+	// ifaceParents maps VLAN interfaces to their parent interfaces and bond slaves to their
+	// bonds.  Only populated when host endpoints cover child interfaces.
+	ifaceParents map[string]string
 	// rawHostEndpoints contains the raw (i.e. not resolved to interface) host endpoints.
 	rawHostEndpoints map[proto.HostEndpointID]*proto.HostEndpoint
+	// hepPolicyJumps records the jumps from the active host endpoints' filter chains to their
+	// policies, so that the policies' counters can be read from another goroutine; it is
+	// protected by hepPolicyJumpsLock.
+	hepPolicyJumpsLock sync.Mutex
+	hepPolicyJumps     []hepPolicyJump
 	// hostEndpointsDirty is set to true when host endpoints are updated.
 	hostEndpointsDirty bool
 	// activeHostIfaceToChains maps host interface name to the chains that we've programmed.
@@ -201,9 +252,47 @@ type endpointManager struct {
 	callbacks              endpointManagerCallbacks
 	bpfEnabled             bool
 	bpfEndpointManager     hepListener
+
+	// chainOrigins, if non-nil, records the workload endpoint that each workload chain came from.
+	chainOrigins *chainOrigins
+	// hostEpsFollowExpectedIPs, if set, makes an all-interfaces host endpoint that lists
+	// expected IPs active only while one of those IPs is present on the host.
+	hostEpsFollowExpectedIPs bool
+
+	// extraRouteRules holds the WorkloadExtraRoutes rules, with the CIDRs of our IP version, and
+	// disabledPools the CIDRs of the disabled IP pools of our IP version, indexed by pool ID.  We
+	// only route extra CIDRs that lie inside a disabled pool.  extraRoutesDirty is set when the
+	// disabled pools change.
+	extraRouteRules  []extraRouteRule
+	disabledPools    map[string]ip.CIDR
+	extraRoutesDirty bool
+
+	// connLimitRules holds the WorkloadConnectionLimits rules.
+	connLimitRules []connLimitRule
+}
+
+// extraRouteRule is a WorkloadExtraRoutes rule, parsed for use by the endpointManager.
+type extraRouteRule struct {
+	selector selector.Selector
+	cidrs    []ip.CIDR
+}
+
+// connLimitRule is a WorkloadConnectionLimits rule, parsed for use by the endpointManager.
+type connLimitRule struct {
+	selector selector.Selector
+	limits   rules.ConnectionLimits
 }
 
-type EndpointStatusUpdateCallback func(ipVersion uint8, id interface{}, status string)
+// EndpointStatusUpdateCallback is called with the calculated status of an endpoint.  The reason
+// is only set when the status is "error"; it gives a human-readable explanation of the failure.
+// The policy generation is only set for workload endpoints.
+type EndpointStatusUpdateCallback func(
+	ipVersion uint8,
+	id interface{},
+	status string,
+	reason string,
+	policyGeneration uint64,
+)
 
 type procSysWriter func(path, value string) error
 
@@ -217,7 +306,11 @@ func newEndpointManager(
 	epMarkMapper rules.EndpointMarkMapper,
 	kubeIPVSSupportEnabled bool,
 	wlInterfacePrefixes []string,
+	autoHostEpIfaceRegexps []*regexp.Regexp,
+	workloadExtraRoutes []config.ExtraRouteRule,
+	workloadConnectionLimits []config.ConnectionLimitRule,
 	onWorkloadEndpointStatusUpdate EndpointStatusUpdateCallback,
+	procSysWriter procSysWriter,
 	bpfEnabled bool,
 	bpfEndpointManager hepListener,
 	callbacks *callbacks,
@@ -232,8 +325,11 @@ func newEndpointManager(
 		epMarkMapper,
 		kubeIPVSSupportEnabled,
 		wlInterfacePrefixes,
+		autoHostEpIfaceRegexps,
+		workloadExtraRoutes,
+		workloadConnectionLimits,
 		onWorkloadEndpointStatusUpdate,
-		writeProcSys,
+		procSysWriter,
 		os.Stat,
 		bpfEnabled,
 		bpfEndpointManager,
@@ -251,6 +347,9 @@ func newEndpointManagerWithShims(
 	epMarkMapper rules.EndpointMarkMapper,
 	kubeIPVSSupportEnabled bool,
 	wlInterfacePrefixes []string,
+	autoHostEpIfaceRegexps []*regexp.Regexp,
+	workloadExtraRoutes []config.ExtraRouteRule,
+	workloadConnectionLimits []config.ConnectionLimitRule,
 	onWorkloadEndpointStatusUpdate EndpointStatusUpdateCallback,
 	procSysWriter procSysWriter,
 	osStat func(name string) (os.FileInfo, error),
@@ -261,10 +360,44 @@ func newEndpointManagerWithShims(
 	wlIfacesPattern := "^(" + strings.Join(wlInterfacePrefixes, "|") + ").*"
 	wlIfacesRegexp := regexp.MustCompile(wlIfacesPattern)
 
+	var extraRouteRules []extraRouteRule
+	for _, rule := range workloadExtraRoutes {
+		sel, err := selector.Parse(rule.Selector)
+		if err != nil {
+			// The selector is validated when the config is loaded.
+			log.WithError(err).Panic("Failed to parse WorkloadExtraRoutes selector")
+		}
+		var cidrs []ip.CIDR
+		for _, s := range rule.CIDRs {
+			cidr := ip.MustParseCIDROrIP(s)
+			if cidr.Version() == ipVersion {
+				cidrs = append(cidrs, cidr)
+			}
+		}
+		extraRouteRules = append(extraRouteRules, extraRouteRule{selector: sel, cidrs: cidrs})
+	}
+
+	var connLimitRules []connLimitRule
+	for _, rule := range workloadConnectionLimits {
+		sel, err := selector.Parse(rule.Selector)
+		if err != nil {
+			// The selector is validated when the config is loaded.
+			log.WithError(err).Panic("Failed to parse WorkloadConnectionLimits selector")
+		}
+		connLimitRules = append(connLimitRules, connLimitRule{
+			selector: sel,
+			limits: rules.ConnectionLimits{
+				MaxConnections:          rule.MaxConnections,
+				NewConnectionsPerSecond: rule.NewConnectionsPerSecond,
+			},
+		})
+	}
+
 	return &endpointManager{
 		ipVersion:              ipVersion,
 		wlIfacesRegexp:         wlIfacesRegexp,
 		kubeIPVSSupportEnabled: kubeIPVSSupportEnabled,
+		autoHostEpIfaceRegexps: autoHostEpIfaceRegexps,
 		bpfEnabled:             bpfEnabled,
 		bpfEndpointManager:     bpfEndpointManager,
 
@@ -287,14 +420,24 @@ func newEndpointManagerWithShims(
 		activeWlEndpoints:     map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint{},
 		activeWlIfaceNameToID: map[string]proto.WorkloadEndpointID{},
 		activeWlIDToChains:    map[proto.WorkloadEndpointID][]*iptables.Chain{},
+		wlChainRefCounts:      map[string]int{},
 
 		shadowedWlEndpoints: map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint{},
 
-		wlIfaceNamesToReconfigure: set.New(),
+		wlIfaceNamesToReconfigure:   set.New(),
+		wlIfaceNameToProgrammingErr: map[string]string{},
+		wlIfaceNameToRouteErr:       map[string]string{},
+		wlIfaceNameToRestoreErr:     map[string]string{},
 
 		epIDsToUpdateStatus: set.New(),
 
+		wlPolicyGenerations:  map[proto.WorkloadEndpointID]uint64{},
+		timeNow:              time.Now,
+		pendingPolicyChanges: set.New(),
+
 		hostIfaceToAddrs:   map[string]set.Set{},
+		ifaceVRFs:          map[string]string{},
+		ifaceParents:       map[string]string{},
 		rawHostEndpoints:   map[proto.HostEndpointID]*proto.HostEndpoint{},
 		hostEndpointsDirty: true,
 
@@ -310,11 +453,17 @@ func newEndpointManagerWithShims(
 		activeHostMangleDispatchChains: map[string]*iptables.Chain{},
 		activeHostRawDispatchChains:    map[string]*iptables.Chain{},
 		activeEPMarkDispatchChains:     map[string]*iptables.Chain{},
+		activeVRFSetMarkChains:         map[string]*iptables.Chain{},
+		activeVRFDispatchChains:        map[string]*iptables.Chain{},
 		needToCheckDispatchChains:      true, // Need to do start-of-day update.
 		needToCheckEndpointMarkChains:  true, // Need to do start-of-day update.
 
 		OnEndpointStatusUpdate: onWorkloadEndpointStatusUpdate,
 		callbacks:              newEndpointManagerCallbacks(callbacks, ipVersion),
+
+		extraRouteRules: extraRouteRules,
+		connLimitRules:  connLimitRules,
+		disabledPools:   map[string]ip.CIDR{},
 	}
 }
 
@@ -325,6 +474,14 @@ func (m *endpointManager) OnUpdate(protoBufMsg interface{}) {
 		m.pendingWlEpUpdates[*msg.Id] = msg.Endpoint
 	case *proto.WorkloadEndpointRemove:
 		m.pendingWlEpUpdates[*msg.Id] = nil
+	case *proto.ActivePolicyUpdate:
+		m.pendingPolicyChanges.Add(*msg.Id)
+	case *proto.ActivePolicyRemove:
+		m.pendingPolicyChanges.Add(*msg.Id)
+	case *proto.ActiveProfileUpdate:
+		m.pendingPolicyChanges.Add(*msg.Id)
+	case *proto.ActiveProfileRemove:
+		m.pendingPolicyChanges.Add(*msg.Id)
 	case *proto.HostEndpointUpdate:
 		log.WithField("msg", msg).Debug("Host endpoint update")
 		m.callbacks.InvokeUpdateHostEndpoint(*msg.Id)
@@ -340,6 +497,15 @@ func (m *endpointManager) OnUpdate(protoBufMsg interface{}) {
 	case *ifaceUpdate:
 		log.WithField("update", msg).Debug("Interface state changed.")
 		m.pendingIfaceUpdates[msg.Name] = msg.State
+		vrfChanged := updateIfaceMaster(m.ifaceVRFs, msg.Name, msg.VRF)
+		parentChanged := updateIfaceMaster(m.ifaceParents, msg.Name, msg.Parent)
+		if vrfChanged || parentChanged {
+			m.hostEndpointsDirty = true
+		}
+		if vrfChanged {
+			// The VRF dispatch chains depend on the workload interfaces' VRFs.
+			m.needToCheckDispatchChains = true
+		}
 	case *ifaceAddrsUpdate:
 		log.WithField("update", msg).Debug("Interface addrs changed.")
 		if m.wlIfacesRegexp.MatchString(msg.Name) {
@@ -352,9 +518,51 @@ func (m *endpointManager) OnUpdate(protoBufMsg interface{}) {
 			delete(m.hostIfaceToAddrs, msg.Name)
 		}
 		m.hostEndpointsDirty = true
+	case *proto.IPAMPoolUpdate:
+		m.onPoolUpdate(msg.Id, msg.Pool)
+	case *proto.IPAMPoolRemove:
+		m.onPoolUpdate(msg.Id, nil)
 	}
 }
 
+// onPoolUpdate records whether the given IP pool is a disabled pool of our IP version and, if
+// that changed, marks the workloads' extra routes for recalculation.
+func (m *endpointManager) onPoolUpdate(id string, pool *proto.IPAMPool) {
+	if len(m.extraRouteRules) == 0 {
+		return
+	}
+	var cidr ip.CIDR
+	if pool != nil && pool.Disabled {
+		cidr = ip.MustParseCIDROrIP(pool.Cidr)
+		if cidr.Version() != m.ipVersion {
+			cidr = nil
+		}
+	}
+	if cidr == m.disabledPools[id] {
+		return
+	}
+	if cidr == nil {
+		delete(m.disabledPools, id)
+	} else {
+		m.disabledPools[id] = cidr
+	}
+	m.extraRoutesDirty = true
+}
+
+// updateIfaceMaster records the interface's master (or removes it, if master is empty) and
+// returns true if it changed.
+func updateIfaceMaster(ifaceToMaster map[string]string, ifaceName, master string) bool {
+	if ifaceToMaster[ifaceName] == master {
+		return false
+	}
+	if master == "" {
+		delete(ifaceToMaster, ifaceName)
+	} else {
+		ifaceToMaster[ifaceName] = master
+	}
+	return true
+}
+
 func (m *endpointManager) ResolveUpdateBatch() error {
 	// Copy the pending interface state to the active set and mark any interfaces that have
 	// changed state for reconfiguration by resolveWorkload/HostEndpoints()
@@ -389,6 +597,12 @@ func (m *endpointManager) ResolveUpdateBatch() error {
 func (m *endpointManager) CompleteDeferredWork() error {
 
 	m.resolveWorkloadEndpoints()
+	m.updatePolicyGenerations()
+
+	if m.extraRoutesDirty {
+		m.updateExtraRoutes()
+		m.extraRoutesDirty = false
+	}
 
 	if m.hostEndpointsDirty {
 		log.Debug("Host endpoints updated, resolving them.")
@@ -401,6 +615,9 @@ func (m *endpointManager) CompleteDeferredWork() error {
 		m.needToCheckEndpointMarkChains = false
 	}
 
+	m.updateRouteErrs()
+	m.updateRestoreErrs()
+
 	// Now send any endpoint status updates.
 	m.updateEndpointStatuses()
 
@@ -432,18 +649,18 @@ func (m *endpointManager) updateEndpointStatuses() {
 	m.epIDsToUpdateStatus.Iter(func(item interface{}) error {
 		switch id := item.(type) {
 		case proto.WorkloadEndpointID:
-			status := m.calculateWorkloadEndpointStatus(id)
-			m.OnEndpointStatusUpdate(m.ipVersion, id, status)
+			status, reason := m.calculateWorkloadEndpointStatus(id)
+			m.OnEndpointStatusUpdate(m.ipVersion, id, status, reason, m.wlPolicyGenerations[id])
 		case proto.HostEndpointID:
-			status := m.calculateHostEndpointStatus(id)
-			m.OnEndpointStatusUpdate(m.ipVersion, id, status)
+			status, reason := m.calculateHostEndpointStatus(id)
+			m.OnEndpointStatusUpdate(m.ipVersion, id, status, reason, 0)
 		}
 
 		return set.RemoveItem
 	})
 }
 
-func (m *endpointManager) calculateWorkloadEndpointStatus(id proto.WorkloadEndpointID) string {
+func (m *endpointManager) calculateWorkloadEndpointStatus(id proto.WorkloadEndpointID) (status, reason string) {
 	logCxt := log.WithField("workloadEndpointID", id)
 	logCxt.Debug("Re-evaluating workload endpoint status")
 	var operUp, adminUp, failed bool
@@ -451,15 +668,26 @@ func (m *endpointManager) calculateWorkloadEndpointStatus(id proto.WorkloadEndpo
 	if known {
 		adminUp = workload.State == "active"
 		operUp = m.activeUpIfaces.Contains(workload.Name)
-		failed = m.wlIfaceNamesToReconfigure.Contains(workload.Name)
+		failed = m.wlIfaceNamesToReconfigure.Contains(workload.Name) ||
+			m.wlIfaceNameToRouteErr[workload.Name] != "" ||
+			m.wlIfaceNameToRestoreErr[workload.Name] != ""
 	}
 
 	// Note: if endpoint is not known (i.e. has been deleted), status will be "", which signals
 	// a deletion.
-	var status string
 	if known {
 		if failed {
 			status = "error"
+			reason = m.wlIfaceNameToProgrammingErr[workload.Name]
+			if reason == "" {
+				reason = m.wlIfaceNameToRouteErr[workload.Name]
+			}
+			if reason == "" {
+				reason = m.wlIfaceNameToRestoreErr[workload.Name]
+			}
+			if reason == "" {
+				reason = "interface configuration pending"
+			}
 		} else if operUp && adminUp {
 			status = "up"
 		} else {
@@ -472,12 +700,13 @@ func (m *endpointManager) calculateWorkloadEndpointStatus(id proto.WorkloadEndpo
 		"operUp":  operUp,
 		"adminUp": adminUp,
 		"status":  status,
+		"reason":  reason,
 	})
 	logCxt.Info("Re-evaluated workload endpoint status")
-	return status
+	return
 }
 
-func (m *endpointManager) calculateHostEndpointStatus(id proto.HostEndpointID) (status string) {
+func (m *endpointManager) calculateHostEndpointStatus(id proto.HostEndpointID) (status, reason string) {
 	logCxt := log.WithField("hostEndpointID", id)
 	logCxt.Debug("Re-evaluating host endpoint status")
 	var resolved, operUp bool
@@ -514,6 +743,7 @@ func (m *endpointManager) calculateHostEndpointStatus(id proto.HostEndpointID) (
 		} else {
 			// Known but failed to resolve, map that to error.
 			status = "error"
+			reason = "no matching interface found"
 		}
 	}
 
@@ -522,9 +752,36 @@ func (m *endpointManager) calculateHostEndpointStatus(id proto.HostEndpointID) (
 		"resolved": resolved,
 		"operUp":   operUp,
 		"status":   status,
+		"reason":   reason,
 	})
 	logCxt.Info("Re-evaluated host endpoint status")
-	return status
+	return
+}
+
+// setWorkloadChains programs the given chains for the workload and removes any of its previous
+// chains that no workload uses any more.
+func (m *endpointManager) setWorkloadChains(id proto.WorkloadEndpointID, chains []*iptables.Chain) {
+	// Incref first so that chains that the workload keeps using aren't removed.
+	for _, chain := range chains {
+		m.wlChainRefCounts[chain.Name]++
+		if m.wlChainRefCounts[chain.Name] == 1 {
+			m.chainOrigins.set(fmt.Sprintf("workload endpoint %s/%s", id.WorkloadId, id.EndpointId), chain.Name)
+		}
+	}
+	m.filterTable.UpdateChains(chains)
+	for _, chain := range m.activeWlIDToChains[id] {
+		m.wlChainRefCounts[chain.Name]--
+		if m.wlChainRefCounts[chain.Name] == 0 {
+			delete(m.wlChainRefCounts, chain.Name)
+			m.filterTable.RemoveChainByName(chain.Name)
+			m.chainOrigins.remove(chain.Name)
+		}
+	}
+	if chains == nil {
+		delete(m.activeWlIDToChains, id)
+	} else {
+		m.activeWlIDToChains[id] = chains
+	}
 }
 
 func (m *endpointManager) resolveWorkloadEndpoints() {
@@ -535,8 +792,7 @@ func (m *endpointManager) resolveWorkloadEndpoints() {
 
 	removeActiveWorkload := func(logCxt *log.Entry, oldWorkload *proto.WorkloadEndpoint, id proto.WorkloadEndpointID) {
 		m.callbacks.InvokeRemoveWorkload(oldWorkload)
-		m.filterTable.RemoveChains(m.activeWlIDToChains[id])
-		delete(m.activeWlIDToChains, id)
+		m.setWorkloadChains(id, nil)
 		if oldWorkload != nil {
 			m.epMarkMapper.ReleaseEndpointMark(oldWorkload.Name)
 			// Remove any routes from the routing table.  The RouteTable will remove any
@@ -544,9 +800,13 @@ func (m *endpointManager) resolveWorkloadEndpoints() {
 			logCxt.Info("Workload removed, deleting old state.")
 			m.routeTable.SetRoutes(oldWorkload.Name, nil)
 			m.wlIfaceNamesToReconfigure.Discard(oldWorkload.Name)
+			delete(m.wlIfaceNameToProgrammingErr, oldWorkload.Name)
+			delete(m.wlIfaceNameToRouteErr, oldWorkload.Name)
+			delete(m.wlIfaceNameToRestoreErr, oldWorkload.Name)
 			delete(m.activeWlIfaceNameToID, oldWorkload.Name)
 		}
 		delete(m.activeWlEndpoints, id)
+		delete(m.wlPolicyGenerations, id)
 	}
 
 	// Repeat the following loop until the pending update map is empty.  Note that it's possible
@@ -585,10 +845,13 @@ func (m *endpointManager) resolveWorkloadEndpoints() {
 					logCxt.Debug("Interface name changed, cleaning up old state")
 					m.epMarkMapper.ReleaseEndpointMark(oldWorkload.Name)
 					if !m.bpfEnabled {
-						m.filterTable.RemoveChains(m.activeWlIDToChains[id])
+						m.setWorkloadChains(id, nil)
 					}
 					m.routeTable.SetRoutes(oldWorkload.Name, nil)
 					m.wlIfaceNamesToReconfigure.Discard(oldWorkload.Name)
+					delete(m.wlIfaceNameToProgrammingErr, oldWorkload.Name)
+					delete(m.wlIfaceNameToRouteErr, oldWorkload.Name)
+					delete(m.wlIfaceNameToRestoreErr, oldWorkload.Name)
 					delete(m.activeWlIfaceNameToID, oldWorkload.Name)
 				}
 				var ingressPolicyNames, egressPolicyNames []string
@@ -605,61 +868,20 @@ func (m *endpointManager) resolveWorkloadEndpoints() {
 						ingressPolicyNames,
 						egressPolicyNames,
 						workload.ProfileIds,
+						m.workloadConnectionLimits(workload),
 					)
-					m.filterTable.UpdateChains(chains)
-					m.activeWlIDToChains[id] = chains
+					m.setWorkloadChains(id, chains)
 				}
 
-				// Collect the IP prefixes that we want to route locally to this endpoint:
 				logCxt.Info("Updating endpoint routes.")
-				var (
-					ipStrings  []string
-					natInfos   []*proto.NatInfo
-					addrSuffix string
-				)
-				if m.ipVersion == 4 {
-					ipStrings = workload.Ipv4Nets
-					natInfos = workload.Ipv4Nat
-					addrSuffix = "/32"
-				} else {
-					ipStrings = workload.Ipv6Nets
-					natInfos = workload.Ipv6Nat
-					addrSuffix = "/128"
-				}
-				if len(natInfos) != 0 {
-					old := ipStrings
-					ipStrings = make([]string, len(old)+len(natInfos))
-					copy(ipStrings, old)
-					for ii, natInfo := range natInfos {
-						ipStrings[len(old)+ii] = natInfo.ExtIp + addrSuffix
-					}
-				}
-
-				var mac net.HardwareAddr
-				if workload.Mac != "" {
-					var err error
-					mac, err = net.ParseMAC(workload.Mac)
-					if err != nil {
-						logCxt.WithError(err).Error(
-							"Failed to parse endpoint's MAC address")
-					}
-				}
-				var routeTargets []routetable.Target
-				if adminUp {
-					logCxt.Debug("Endpoint up, adding routes")
-					for _, s := range ipStrings {
-						routeTargets = append(routeTargets, routetable.Target{
-							CIDR:    ip.MustParseCIDROrIP(s),
-							DestMAC: mac,
-						})
-					}
-				} else {
-					logCxt.Debug("Endpoint down, removing routes")
-				}
-				m.routeTable.SetRoutes(workload.Name, routeTargets)
+				m.routeTable.SetRoutes(workload.Name, m.workloadRouteTargets(logCxt, workload))
 				m.wlIfaceNamesToReconfigure.Add(workload.Name)
 				m.activeWlEndpoints[id] = workload
 				m.activeWlIfaceNameToID[workload.Name] = id
+				if _, ok := m.wlPolicyGenerations[id]; !ok {
+					m.wlPolicyGenerations[id] = uint64(m.timeNow().UnixNano() / int64(time.Microsecond))
+				}
+				m.wlPolicyGenerations[id]++
 				delete(m.pendingWlEpUpdates, id)
 
 				m.callbacks.InvokeUpdateWorkload(oldWorkload, workload)
@@ -698,6 +920,7 @@ func (m *endpointManager) resolveWorkloadEndpoints() {
 		// Rewrite the dispatch chains if they've changed.
 		newDispatchChains := m.ruleRenderer.WorkloadDispatchChains(m.activeWlEndpoints)
 		m.updateDispatchChains(m.activeWlDispatchChains, newDispatchChains, m.filterTable)
+		m.updateVRFChains()
 		m.needToCheckDispatchChains = false
 
 		// Set flag to update endpoint mark chains.
@@ -706,20 +929,290 @@ func (m *endpointManager) resolveWorkloadEndpoints() {
 
 	m.wlIfaceNamesToReconfigure.Iter(func(item interface{}) error {
 		ifaceName := item.(string)
-		err := m.configureInterface(ifaceName)
-		if err != nil {
+		configErr := m.configureInterface(ifaceName)
+		if configErr != nil {
 			if exists, err := m.interfaceExistsInProcSys(ifaceName); err == nil && !exists {
 				// Suppress log spam if interface has been removed.
-				log.WithError(err).Debug("Failed to configure interface and it seems to be gone")
+				log.WithError(configErr).Debug("Failed to configure interface and it seems to be gone")
 			} else {
-				log.WithError(err).Warn("Failed to configure interface, will retry")
+				log.WithError(configErr).Warn("Failed to configure interface, will retry")
+				m.recordWorkloadProgrammingErr(ifaceName, "failed to configure interface: "+configErr.Error())
 			}
 			return nil
 		}
+		if _, ok := m.wlIfaceNameToProgrammingErr[ifaceName]; ok {
+			// We previously reported an error for this interface; make sure that we
+			// report its recovery.
+			delete(m.wlIfaceNameToProgrammingErr, ifaceName)
+			m.markEndpointStatusDirtyByIface(ifaceName)
+		}
 		return set.RemoveItem
 	})
 }
 
+// updatePolicyGenerations bumps the policy generation of each active workload endpoint that
+// references a policy or profile that has changed, and queues a status update for it.
+func (m *endpointManager) updatePolicyGenerations() {
+	if m.pendingPolicyChanges.Len() == 0 {
+		return
+	}
+	for id, workload := range m.activeWlEndpoints {
+		if !m.workloadUsesChangedPolicy(workload) {
+			continue
+		}
+		m.wlPolicyGenerations[id]++
+		m.epIDsToUpdateStatus.Add(id)
+	}
+	m.pendingPolicyChanges = set.New()
+}
+
+func (m *endpointManager) workloadUsesChangedPolicy(workload *proto.WorkloadEndpoint) bool {
+	for _, tier := range workload.Tiers {
+		for _, names := range [][]string{tier.IngressPolicies, tier.EgressPolicies} {
+			for _, name := range names {
+				if m.pendingPolicyChanges.Contains(proto.PolicyID{Tier: tier.Name, Name: name}) {
+					return true
+				}
+			}
+		}
+	}
+	for _, name := range workload.ProfileIds {
+		if m.pendingPolicyChanges.Contains(proto.ProfileID{Name: name}) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordWorkloadProgrammingErr stores the reason that programming of the given workload
+// interface failed and, if it has changed, queues a status update for the endpoint.
+func (m *endpointManager) recordWorkloadProgrammingErr(ifaceName, reason string) {
+	if m.wlIfaceNameToProgrammingErr[ifaceName] == reason {
+		return
+	}
+	m.wlIfaceNameToProgrammingErr[ifaceName] = reason
+	m.markEndpointStatusDirtyByIface(ifaceName)
+}
+
+// updateRouteErrs picks up the workload interfaces that the route table failed to program on its
+// last Apply() and queues a status update for each endpoint whose route error has changed.
+func (m *endpointManager) updateRouteErrs() {
+	syncErrs := m.routeTable.IfaceSyncErrors()
+	for ifaceName := range m.activeWlIfaceNameToID {
+		reason := ""
+		if err, ok := syncErrs[ifaceName]; ok {
+			reason = "failed to program routes: " + err.Error()
+		}
+		if m.wlIfaceNameToRouteErr[ifaceName] == reason {
+			continue
+		}
+		if reason == "" {
+			delete(m.wlIfaceNameToRouteErr, ifaceName)
+		} else {
+			m.wlIfaceNameToRouteErr[ifaceName] = reason
+		}
+		m.markEndpointStatusDirtyByIface(ifaceName)
+	}
+}
+
+// updateRestoreErrs picks up the chains that the iptables tables have given up programming and
+// queues a status update for each workload endpoint whose restore error has changed.  A workload
+// is affected if the failed chain is one of its own chains or one of its policies or profiles.
+func (m *endpointManager) updateRestoreErrs() {
+	chainNames, failures := m.chainOrigins.failedChains()
+	for id, workload := range m.activeWlEndpoints {
+		reason := ""
+		for _, chainName := range chainNames {
+			if m.workloadUsesChain(id, workload, chainName) {
+				reason = "failed to program policy: " + failures[chainName]
+				break
+			}
+		}
+		if m.wlIfaceNameToRestoreErr[workload.Name] == reason {
+			continue
+		}
+		if reason == "" {
+			delete(m.wlIfaceNameToRestoreErr, workload.Name)
+		} else {
+			m.wlIfaceNameToRestoreErr[workload.Name] = reason
+		}
+		m.markEndpointStatusDirtyByIface(workload.Name)
+	}
+}
+
+func (m *endpointManager) workloadUsesChain(
+	id proto.WorkloadEndpointID,
+	workload *proto.WorkloadEndpoint,
+	chainName string,
+) bool {
+	for _, chain := range m.activeWlIDToChains[id] {
+		if chain.Name == chainName {
+			return true
+		}
+	}
+	for _, tier := range workload.Tiers {
+		for _, name := range tier.IngressPolicies {
+			polID := proto.PolicyID{Tier: tier.Name, Name: name}
+			if rules.PolicyChainName(rules.PolicyInboundPfx, &polID) == chainName {
+				return true
+			}
+		}
+		for _, name := range tier.EgressPolicies {
+			polID := proto.PolicyID{Tier: tier.Name, Name: name}
+			if rules.PolicyChainName(rules.PolicyOutboundPfx, &polID) == chainName {
+				return true
+			}
+		}
+	}
+	for _, name := range workload.ProfileIds {
+		profID := proto.ProfileID{Name: name}
+		if rules.ProfileChainName(rules.ProfileInboundPfx, &profID) == chainName ||
+			rules.ProfileChainName(rules.ProfileOutboundPfx, &profID) == chainName {
+			return true
+		}
+	}
+	return false
+}
+
+// workloadRouteTargets returns the routes of our IP version to the given workload: its IPs and
+// NAT IPs and any extra routes.  It returns no routes if the workload is down.
+func (m *endpointManager) workloadRouteTargets(logCxt *log.Entry, workload *proto.WorkloadEndpoint) []routetable.Target {
+	if workload.State != "active" {
+		logCxt.Debug("Endpoint down, removing routes")
+		return nil
+	}
+
+	// Collect the IP prefixes that we want to route locally to this endpoint:
+	var (
+		ipStrings  []string
+		natInfos   []*proto.NatInfo
+		addrSuffix string
+	)
+	if m.ipVersion == 4 {
+		ipStrings = workload.Ipv4Nets
+		natInfos = workload.Ipv4Nat
+		addrSuffix = "/32"
+	} else {
+		ipStrings = workload.Ipv6Nets
+		natInfos = workload.Ipv6Nat
+		addrSuffix = "/128"
+	}
+	if len(natInfos) != 0 {
+		old := ipStrings
+		ipStrings = make([]string, len(old)+len(natInfos))
+		copy(ipStrings, old)
+		for ii, natInfo := range natInfos {
+			ipStrings[len(old)+ii] = natInfo.ExtIp + addrSuffix
+		}
+	}
+
+	var mac net.HardwareAddr
+	if workload.Mac != "" {
+		var err error
+		mac, err = net.ParseMAC(workload.Mac)
+		if err != nil {
+			logCxt.WithError(err).Error(
+				"Failed to parse endpoint's MAC address")
+		}
+	}
+	logCxt.Debug("Endpoint up, adding routes")
+	var routeTargets []routetable.Target
+	for _, s := range ipStrings {
+		routeTargets = append(routeTargets, routetable.Target{
+			CIDR:    ip.MustParseCIDROrIP(s),
+			DestMAC: mac,
+		})
+	}
+	return append(routeTargets, m.extraRouteTargets(logCxt, workload)...)
+}
+
+// extraRouteTargets returns the routes for the CIDRs that the WorkloadExtraRoutes rules that
+// select the workload give it.  The routes go via the workload's first IP so that the workload can
+// forward to the networks behind it; if the workload has no IP of our version, they are plain
+// device routes.  CIDRs that aren't inside a disabled IP pool are skipped, since they could
+// overlap the IPs of other workloads or hosts.
+func (m *endpointManager) extraRouteTargets(logCxt *log.Entry, workload *proto.WorkloadEndpoint) []routetable.Target {
+	var gw ip.Addr
+	nets := workload.Ipv4Nets
+	if m.ipVersion == 6 {
+		nets = workload.Ipv6Nets
+	}
+	if len(nets) > 0 {
+		// Not the CIDR's Addr(), which is masked.
+		gw = ip.FromString(strings.Split(nets[0], "/")[0])
+	}
+	var targets []routetable.Target
+	seen := set.New()
+	for _, rule := range m.extraRouteRules {
+		if !rule.selector.Evaluate(workload.Labels) {
+			continue
+		}
+		for _, cidr := range rule.cidrs {
+			if seen.Contains(cidr) {
+				continue
+			}
+			seen.Add(cidr)
+			if !m.inDisabledPool(cidr) {
+				logCxt.WithField("cidr", cidr).Warn(
+					"Extra route isn't inside a disabled IP pool, not routing it to the workload.")
+				continue
+			}
+			if gw == nil {
+				targets = append(targets, routetable.Target{CIDR: cidr})
+				continue
+			}
+			targets = append(targets, routetable.Target{
+				Type: routetable.TargetTypeNoEncap,
+				CIDR: cidr,
+				GW:   gw,
+			})
+		}
+	}
+	return targets
+}
+
+// workloadConnectionLimits returns the limits of the first WorkloadConnectionLimits rule that
+// selects the workload, or no limits if none does.
+func (m *endpointManager) workloadConnectionLimits(workload *proto.WorkloadEndpoint) rules.ConnectionLimits {
+	for _, rule := range m.connLimitRules {
+		if rule.selector.Evaluate(workload.Labels) {
+			return rule.limits
+		}
+	}
+	return rules.ConnectionLimits{}
+}
+
+// inDisabledPool returns true if the given CIDR lies inside one of the disabled IP pools.
+func (m *endpointManager) inDisabledPool(cidr ip.CIDR) bool {
+	for _, pool := range m.disabledPools {
+		poolNet := pool.ToIPNet()
+		if pool.Prefix() <= cidr.Prefix() && poolNet.Contains(cidr.Addr().AsNetIP()) {
+			return true
+		}
+	}
+	return false
+}
+
+// updateExtraRoutes recalculates the routes of the active workloads that WorkloadExtraRoutes rules
+// select, after the disabled IP pools have changed.
+func (m *endpointManager) updateExtraRoutes() {
+	for id, workload := range m.activeWlEndpoints {
+		selected := false
+		for _, rule := range m.extraRouteRules {
+			if rule.selector.Evaluate(workload.Labels) {
+				selected = true
+				break
+			}
+		}
+		if !selected {
+			continue
+		}
+		logCxt := log.WithField("id", id)
+		logCxt.Info("IP pools changed, updating endpoint routes.")
+		m.routeTable.SetRoutes(workload.Name, m.workloadRouteTargets(logCxt, workload))
+	}
+}
+
 func wlIdsAscending(id1, id2 *proto.WorkloadEndpointID) bool {
 	if id1.OrchestratorId == id2.OrchestratorId {
 		// Need to compare WorkloadId.
@@ -732,6 +1225,21 @@ func wlIdsAscending(id1, id2 *proto.WorkloadEndpointID) bool {
 	return id1.OrchestratorId < id2.OrchestratorId
 }
 
+// updateVRFChains rewrites the chains that dispatch packets from workloads whose interfaces are in
+// a VRF.  The renderer returns no chains if VRF support is disabled.
+func (m *endpointManager) updateVRFChains() {
+	wlIfaceVRFs := map[string]string{}
+	for _, workload := range m.activeWlEndpoints {
+		if vrf := m.ifaceVRFs[workload.Name]; vrf != "" {
+			wlIfaceVRFs[workload.Name] = vrf
+		}
+	}
+	m.updateDispatchChains(m.activeVRFSetMarkChains,
+		m.ruleRenderer.VRFSetEndpointMarkChains(m.epMarkMapper, wlIfaceVRFs), m.mangleTable)
+	m.updateDispatchChains(m.activeVRFDispatchChains,
+		m.ruleRenderer.VRFWorkloadDispatchChains(m.epMarkMapper, wlIfaceVRFs), m.filterTable)
+}
+
 func (m *endpointManager) resolveEndpointMarks() {
 	if m.bpfEnabled {
 		return
@@ -742,6 +1250,30 @@ func (m *endpointManager) resolveEndpointMarks() {
 	m.updateDispatchChains(m.activeEPMarkDispatchChains, newEndpointMarkDispatchChains, m.filterTable)
 }
 
+var autoHostEpID = proto.HostEndpointID{EndpointId: config.AutoHostEndpointID}
+
+// autoHostEndpointApplies returns true if the calculation graph has sent us the implicit host
+// endpoint and the named interface is one that it should apply to.
+func (m *endpointManager) autoHostEndpointApplies(ifaceName string) bool {
+	if _, ok := m.rawHostEndpoints[autoHostEpID]; !ok {
+		return false
+	}
+	for _, re := range m.autoHostEpIfaceRegexps {
+		if re.MatchString(ifaceName) {
+			return true
+		}
+	}
+	return false
+}
+
+// HostEndpointPolicyJumps returns the jumps from the active host endpoints' filter chains to their
+// policies.  It may be called from any goroutine.
+func (m *endpointManager) HostEndpointPolicyJumps() []hepPolicyJump {
+	m.hepPolicyJumpsLock.Lock()
+	defer m.hepPolicyJumpsLock.Unlock()
+	return m.hepPolicyJumps
+}
+
 func (m *endpointManager) resolveHostEndpoints() map[string]proto.HostEndpointID {
 
 	// Host endpoint resolution
@@ -817,6 +1349,11 @@ func (m *endpointManager) resolveHostEndpoints() map[string]proto.HostEndpointID
 				}
 			}
 		}
+		if bestHostEpId.EndpointId == "" && m.autoHostEndpointApplies(ifaceName) {
+			// No explicit host endpoint applies, fall back to the implicit one.
+			ifaceCxt.Debug("Using implicit host endpoint for interface")
+			bestHostEpId = autoHostEpID
+		}
 		if bestHostEpId.EndpointId != "" {
 			logCxt := log.WithFields(log.Fields{
 				"ifaceName":    ifaceName,
@@ -827,7 +1364,18 @@ func (m *endpointManager) resolveHostEndpoints() map[string]proto.HostEndpointID
 		}
 	}
 
-	// Similar loop to find the best all-interfaces host endpoint.
+	m.addChildHostEndpoints(newIfaceNameToHostEpID)
+	if !m.bpfEnabled {
+		// In BPF mode, the programs on the enslaved interfaces see packets before the VRF
+		// device does.
+		m.addVRFHostEndpoints(newIfaceNameToHostEpID)
+	}
+
+	// Similar loop to find the best all-interfaces host endpoint.  If configured, an
+	// all-interfaces host endpoint that lists expected IPs is only active while at least one of
+	// those IPs is present on the host.  This allows, for example, a host endpoint for a VIP to
+	// follow the VIP when it fails over between hosts; since we're called whenever an
+	// interface's addresses change, the dispatch chains are updated as soon as the VIP moves.
 	bestHostEpId := proto.HostEndpointID{}
 	for id, hostEp := range m.rawHostEndpoints {
 		logCxt := log.WithField("id", id)
@@ -841,6 +1389,10 @@ func (m *endpointManager) resolveHostEndpoints() map[string]proto.HostEndpointID
 			logCxt.Debug("No better than existing match")
 			continue
 		}
+		if m.hostEpsFollowExpectedIPs && !m.hostHasExpectedAddr(hostEp) {
+			logCxt.Debug("None of the all-interfaces host endpoint's expected IPs are present")
+			continue
+		}
 		logCxt.Debug("New best all-interfaces host endpoint")
 		bestHostEpId = id
 	}
@@ -865,6 +1417,76 @@ func (m *endpointManager) resolveHostEndpoints() map[string]proto.HostEndpointID
 	return newIfaceNameToHostEpID
 }
 
+// addChildHostEndpoints extends host endpoints to the child interfaces of their interfaces: the
+// VLAN sub-interfaces of an interface and the slaves of a bond.  Otherwise, traffic on a VLAN
+// would bypass the policy of the host endpoint of the underlying interface.  A child interface
+// with a host endpoint of its own keeps it.
+func (m *endpointManager) addChildHostEndpoints(ifaceNameToHostEpID map[string]proto.HostEndpointID) {
+	inherited := map[string]proto.HostEndpointID{}
+	for ifaceName := range m.ifaceParents {
+		if _, ok := ifaceNameToHostEpID[ifaceName]; ok {
+			continue
+		}
+		// Follow the chain of parents, for example from a VLAN on a bond to the bond.  The depth
+		// limit guards against loops.
+		parent := m.ifaceParents[ifaceName]
+		for i := 0; parent != "" && i < maxIfaceParentDepth; i++ {
+			if id, ok := ifaceNameToHostEpID[parent]; ok {
+				log.WithFields(log.Fields{
+					"ifaceName": ifaceName,
+					"parent":    parent,
+					"hostEp":    id,
+				}).Debug("Child interface inherits host endpoint")
+				inherited[ifaceName] = id
+				break
+			}
+			parent = m.ifaceParents[parent]
+		}
+	}
+	for ifaceName, id := range inherited {
+		ifaceNameToHostEpID[ifaceName] = id
+	}
+}
+
+// addVRFHostEndpoints extends the host endpoints of interfaces that are enslaved to a VRF to the
+// VRF device.  Once the kernel has received a packet on an enslaved interface, it presents it on
+// the VRF device, so the filter INPUT and FORWARD chains only see the VRF device.  We can only do
+// that if all the interfaces in the VRF have the same host endpoint; otherwise, the VRF device
+// needs a host endpoint of its own.
+func (m *endpointManager) addVRFHostEndpoints(ifaceNameToHostEpID map[string]proto.HostEndpointID) {
+	vrfToHostEpIDs := map[string]set.Set{}
+	for ifaceName, vrf := range m.ifaceVRFs {
+		if _, ok := ifaceNameToHostEpID[vrf]; ok {
+			// The VRF device has its own host endpoint.
+			continue
+		}
+		ids := vrfToHostEpIDs[vrf]
+		if ids == nil {
+			ids = set.New()
+			vrfToHostEpIDs[vrf] = ids
+		}
+		// An interface without a host endpoint, a workload interface for example, adds the
+		// empty ID.
+		ids.Add(ifaceNameToHostEpID[ifaceName])
+	}
+	for vrf, ids := range vrfToHostEpIDs {
+		if ids.Len() != 1 {
+			log.WithFields(log.Fields{
+				"vrf":     vrf,
+				"hostEps": ids,
+			}).Info("Interfaces in VRF have different host endpoints, not policing the VRF device")
+			continue
+		}
+		ids.Iter(func(item interface{}) error {
+			if id := item.(proto.HostEndpointID); id.EndpointId != "" {
+				log.WithFields(log.Fields{"vrf": vrf, "hostEp": id}).Debug("VRF device inherits host endpoint")
+				ifaceNameToHostEpID[vrf] = id
+			}
+			return nil
+		})
+	}
+}
+
 func (m *endpointManager) updateHostEndpoints() {
 
 	// Calculate filtered name/id maps for untracked and pre-DNAT policy, and a reverse map from
@@ -912,11 +1534,21 @@ func (m *endpointManager) updateHostEndpoints() {
 			}
 		}
 	}
+	// The loop above only spots endpoints that were displaced from an interface that is still
+	// protected.  Also review endpoints that are no longer in use at all, such as an
+	// all-interfaces host endpoint whose expected IPs have left the host.
+	for id := range m.activeHostEpIDToIfaceNames {
+		if _, ok := newHostEpIDToIfaceNames[id]; !ok {
+			log.WithField("id", id).Debug("Host endpoint no longer in use, updating its status")
+			m.epIDsToUpdateStatus.Add(id)
+		}
+	}
 
 	if !m.bpfEnabled {
 		// Set up programming for the host endpoints that are now to be used.
 		newHostIfaceFiltChains := map[string][]*iptables.Chain{}
 		newHostIfaceMangleEgressChains := map[string][]*iptables.Chain{}
+		var newHEPPolicyJumps []hepPolicyJump
 		for ifaceName, id := range newIfaceNameToHostEpID {
 			log.WithField("id", id).Info("Updating host endpoint normal policy chains.")
 			hostEp := m.rawHostEndpoints[id]
@@ -948,6 +1580,13 @@ func (m *endpointManager) updateHostEndpoints() {
 			}
 			newHostIfaceFiltChains[ifaceName] = filtChains
 			delete(m.activeHostIfaceToFiltChains, ifaceName)
+			newHEPPolicyJumps = append(newHEPPolicyJumps, hostEndpointPolicyJumps(
+				ifaceName,
+				ingressPolicyNames,
+				egressPolicyNames,
+				ingressForwardPolicyNames,
+				egressForwardPolicyNames,
+			)...)
 
 			mangleChains := m.ruleRenderer.HostEndpointToMangleEgressChains(
 				ifaceName,
@@ -1031,6 +1670,10 @@ func (m *endpointManager) updateHostEndpoints() {
 		m.activeHostIfaceToMangleEgressChains = newHostIfaceMangleEgressChains
 		m.activeHostIfaceToMangleIngressChains = newHostIfaceMangleIngressChains
 		m.activeHostIfaceToRawChains = newHostIfaceRawChains
+
+		m.hepPolicyJumpsLock.Lock()
+		m.hepPolicyJumps = newHEPPolicyJumps
+		m.hepPolicyJumpsLock.Unlock()
 	}
 
 	// Remember the host endpoints that are now in use.
@@ -1220,6 +1863,24 @@ func writeProcSys(path, value string) error {
 // IFNAMSIZ (16) characters, so that it can't possibly match a real interface name.
 var allInterfaces = "any-interface-at-all"
 
+// hostHasExpectedAddr returns true if the host endpoint doesn't list any expected IPs, or if at
+// least one of them is present on one of the host's interfaces.
+func (m *endpointManager) hostHasExpectedAddr(hep *proto.HostEndpoint) bool {
+	if len(hep.ExpectedIpv4Addrs) == 0 && len(hep.ExpectedIpv6Addrs) == 0 {
+		return true
+	}
+	for _, ifaceAddrs := range m.hostIfaceToAddrs {
+		for _, wantedList := range [][]string{hep.ExpectedIpv4Addrs, hep.ExpectedIpv6Addrs} {
+			for _, wanted := range wantedList {
+				if ifaceAddrs.Contains(wanted) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
 // True if the given host endpoint is for all interfaces, as opposed to for a specific interface.
 func forAllInterfaces(hep *proto.HostEndpoint) bool {
 	return hep.Name == "*"
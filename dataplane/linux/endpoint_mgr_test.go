@@ -18,7 +18,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/projectcalico/felix/ifacemonitor"
 
@@ -26,6 +28,7 @@ import (
 	. "github.com/onsi/gomega"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/projectcalico/felix/config"
 	"github.com/projectcalico/felix/ip"
 	"github.com/projectcalico/felix/ipsets"
 	"github.com/projectcalico/felix/iptables"
@@ -575,6 +578,7 @@ func chainsForIfaces(ifaceMetadata []string,
 type mockRouteTable struct {
 	currentRoutes   map[string][]routetable.Target
 	currentL2Routes map[string][]routetable.L2Target
+	syncErrs        map[string]error
 }
 
 func (t *mockRouteTable) SetRoutes(ifaceName string, targets []routetable.Target) {
@@ -599,25 +603,45 @@ func (t *mockRouteTable) Apply() error {
 	return nil
 }
 
+func (t *mockRouteTable) IfaceSyncErrors() map[string]error {
+	return t.syncErrs
+}
+
 func (t *mockRouteTable) checkRoutes(ifaceName string, expected []routetable.Target) {
 	Expect(t.currentRoutes[ifaceName]).To(Equal(expected))
 }
 
 type statusReportRecorder struct {
-	currentState map[interface{}]string
+	currentState       map[interface{}]string
+	currentReasons     map[interface{}]string
+	currentGenerations map[interface{}]uint64
 }
 
-func (r *statusReportRecorder) endpointStatusUpdateCallback(ipVersion uint8, id interface{}, status string) {
+func (r *statusReportRecorder) endpointStatusUpdateCallback(
+	ipVersion uint8,
+	id interface{},
+	status string,
+	reason string,
+	policyGeneration uint64,
+) {
 	log.WithFields(log.Fields{
-		"ipVersion": ipVersion,
-		"id":        id,
-		"status":    status,
+		"ipVersion":        ipVersion,
+		"id":               id,
+		"status":           status,
+		"reason":           reason,
+		"policyGeneration": policyGeneration,
 	}).Debug("endpointStatusUpdateCallback")
+	r.currentGenerations[id] = policyGeneration
 	if status == "" {
 		delete(r.currentState, id)
 	} else {
 		r.currentState[id] = status
 	}
+	if reason == "" {
+		delete(r.currentReasons, id)
+	} else {
+		r.currentReasons[id] = reason
+	}
 }
 
 type hostEpSpec struct {
@@ -648,6 +672,8 @@ func endpointManagerTests(ipVersion uint8) func() {
 			mockProcSys     *testProcSys
 			statusReportRec *statusReportRecorder
 			hepListener     *testHEPListener
+			extraRoutes     []config.ExtraRouteRule
+			connLimits      []config.ConnectionLimitRule
 		)
 
 		BeforeEach(func() {
@@ -675,6 +701,8 @@ func endpointManagerTests(ipVersion uint8) func() {
 			loAddrs.Add("::1")
 			eth1Addrs = set.New()
 			eth1Addrs.Add(ipv4Eth1)
+			extraRoutes = nil
+			connLimits = nil
 		})
 
 		JustBeforeEach(func() {
@@ -686,7 +714,11 @@ func endpointManagerTests(ipVersion uint8) func() {
 				currentRoutes: map[string][]routetable.Target{},
 			}
 			mockProcSys = &testProcSys{state: map[string]string{}, pathsThatExist: map[string]bool{}}
-			statusReportRec = &statusReportRecorder{currentState: map[interface{}]string{}}
+			statusReportRec = &statusReportRecorder{
+				currentState:       map[interface{}]string{},
+				currentReasons:     map[interface{}]string{},
+				currentGenerations: map[interface{}]uint64{},
+			}
 			hepListener = &testHEPListener{}
 			epMgr = newEndpointManagerWithShims(
 				rawTable,
@@ -698,6 +730,9 @@ func endpointManagerTests(ipVersion uint8) func() {
 				rules.NewEndpointMarkMapper(rrConfigNormal.IptablesMarkEndpoint, rrConfigNormal.IptablesMarkNonCaliEndpoint),
 				rrConfigNormal.KubeIPVSSupportEnabled,
 				[]string{"cali"},
+				[]*regexp.Regexp{regexp.MustCompile("^eth0$")},
+				extraRoutes,
+				connLimits,
 				statusReportRec.endpointStatusUpdateCallback,
 				mockProcSys.write,
 				mockProcSys.stat,
@@ -705,6 +740,10 @@ func endpointManagerTests(ipVersion uint8) func() {
 				hepListener,
 				newCallbacks(),
 			)
+			epMgr.timeNow = func() time.Time {
+				return time.Unix(1, 0)
+			}
+			epMgr.chainOrigins = newChainOrigins()
 		})
 
 		It("should be constructable", func() {
@@ -874,6 +913,118 @@ func endpointManagerTests(ipVersion uint8) func() {
 				})
 			})
 
+			Describe("with the implicit host endpoint", func() {
+				JustBeforeEach(configureHostEp(&hostEpSpec{
+					id:      "felix-auto-host-endpoint",
+					polName: "polA",
+				}))
+
+				It("should apply it to the matching interface only", func() {
+					Expect(hepListener.state).To(Equal(map[string]string{
+						"eth0": "profiles=,normal=I=polA,E=polA,untracked=,preDNAT=,AoF=",
+					}))
+					Expect(statusReportRec.currentState).To(Equal(map[interface{}]string{
+						proto.HostEndpointID{EndpointId: "felix-auto-host-endpoint"}: "up",
+					}))
+				})
+
+				Context("with an explicit host endpoint for eth0", func() {
+					JustBeforeEach(configureHostEp(&hostEpSpec{
+						id:      "id1",
+						name:    "eth0",
+						polName: "polB",
+					}))
+
+					It("should prefer the explicit host endpoint", func() {
+						Expect(hepListener.state).To(Equal(map[string]string{
+							"eth0": "profiles=,normal=I=polB,E=polB,untracked=,preDNAT=,AoF=",
+						}))
+					})
+				})
+			})
+
+			Describe("with * host endpoints for a VIP and for the host", func() {
+				const vip = "10.0.240.99"
+
+				setEth0Addrs := func(addrs ...string) func() {
+					return func() {
+						newAddrs := eth0Addrs.Copy()
+						for _, a := range addrs {
+							newAddrs.Add(a)
+						}
+						epMgr.OnUpdate(&ifaceAddrsUpdate{
+							Name:  "eth0",
+							Addrs: newAddrs,
+						})
+						err := epMgr.ResolveUpdateBatch()
+						Expect(err).ToNot(HaveOccurred())
+						err = epMgr.CompleteDeferredWork()
+						Expect(err).ToNot(HaveOccurred())
+					}
+				}
+
+				JustBeforeEach(func() {
+					configureHostEp(&hostEpSpec{
+						id:        "id0",
+						name:      "*",
+						ipv4Addrs: []string{vip},
+						ipv6Addrs: []string{vip},
+						polName:   "polV",
+					})()
+					configureHostEp(&hostEpSpec{
+						id:      "id1",
+						name:    "*",
+						polName: "polA",
+					})()
+				})
+
+				It("should use the VIP's endpoint by default, wherever the VIP is", func() {
+					Expect(hepListener.state).To(Equal(map[string]string{
+						"any-interface-at-all": "profiles=,normal=I=polV,E=polV,untracked=,preDNAT=,AoF=",
+					}))
+				})
+
+				Context("with HostEndpointsFollowExpectedIPs", func() {
+					BeforeEach(func() {
+						epMgr.hostEpsFollowExpectedIPs = true
+					})
+
+					It("should use the host's endpoint while the VIP is elsewhere", func() {
+						Expect(hepListener.state).To(Equal(map[string]string{
+							"any-interface-at-all": "profiles=,normal=I=polA,E=polA,untracked=,preDNAT=,AoF=",
+						}))
+						Expect(statusReportRec.currentState).To(Equal(map[interface{}]string{
+							proto.HostEndpointID{EndpointId: "id0"}: "error",
+							proto.HostEndpointID{EndpointId: "id1"}: "up",
+						}))
+					})
+
+					Context("after the VIP moves to this host", func() {
+						JustBeforeEach(setEth0Addrs(vip))
+
+						It("should switch to the VIP's endpoint", func() {
+							Expect(hepListener.state).To(Equal(map[string]string{
+								"any-interface-at-all": "profiles=,normal=I=polV,E=polV,untracked=,preDNAT=,AoF=",
+							}))
+							Expect(statusReportRec.currentState).To(Equal(map[interface{}]string{
+								proto.HostEndpointID{EndpointId: "id0"}: "up",
+								proto.HostEndpointID{EndpointId: "id1"}: "error",
+							}))
+						})
+
+						Context("after the VIP moves away again", func() {
+							JustBeforeEach(setEth0Addrs())
+
+							It("should switch back to the host's endpoint", func() {
+								Expect(hepListener.state).To(Equal(map[string]string{
+									"any-interface-at-all": "profiles=,normal=I=polA,E=polA,untracked=,preDNAT=,AoF=",
+								}))
+							})
+						})
+					})
+				})
+			})
+
 			// Configure host endpoints with tier names here, so we can check which of
 			// the host endpoints gets used in the programming for a particular host
 			// interface.  When more than one host endpoint matches a given interface,
@@ -1294,6 +1445,109 @@ func endpointManagerTests(ipVersion uint8) func() {
 						})
 					})
 				})
+
+				Context("with a VLAN on eth0", func() {
+					JustBeforeEach(func() {
+						epMgr.OnUpdate(&ifaceUpdate{
+							Name:   "eth0.100",
+							State:  "up",
+							Parent: "eth0",
+						})
+						err := epMgr.ResolveUpdateBatch()
+						Expect(err).ToNot(HaveOccurred())
+						err = epMgr.CompleteDeferredWork()
+						Expect(err).ToNot(HaveOccurred())
+					})
+
+					It("should police the VLAN with eth0's host endpoint", expectChainsFor("eth0", "eth0.100"))
+					It("should report id1 up", func() {
+						Expect(statusReportRec.currentState).To(Equal(map[interface{}]string{
+							proto.HostEndpointID{EndpointId: "id1"}: "up",
+						}))
+					})
+
+					Context("with a VLAN on the VLAN", func() {
+						JustBeforeEach(func() {
+							epMgr.OnUpdate(&ifaceUpdate{
+								Name:   "eth0.100.200",
+								State:  "up",
+								Parent: "eth0.100",
+							})
+							err := epMgr.ResolveUpdateBatch()
+							Expect(err).ToNot(HaveOccurred())
+							err = epMgr.CompleteDeferredWork()
+							Expect(err).ToNot(HaveOccurred())
+						})
+
+						It("should police both VLANs", expectChainsFor("eth0", "eth0.100", "eth0.100.200"))
+					})
+
+					Context("after the VLAN goes down", func() {
+						JustBeforeEach(func() {
+							epMgr.OnUpdate(&ifaceUpdate{
+								Name:  "eth0.100",
+								State: "down",
+							})
+							err := epMgr.ResolveUpdateBatch()
+							Expect(err).ToNot(HaveOccurred())
+							err = epMgr.CompleteDeferredWork()
+							Expect(err).ToNot(HaveOccurred())
+						})
+
+						It("should stop policing the VLAN", expectChainsFor("eth0"))
+					})
+				})
+
+				Context("with eth0 enslaved to a VRF", func() {
+					JustBeforeEach(func() {
+						epMgr.OnUpdate(&ifaceUpdate{
+							Name:  "eth0",
+							State: "up",
+							VRF:   "vrf-data",
+						})
+						epMgr.OnUpdate(&ifaceUpdate{
+							Name:  "vrf-data",
+							State: "up",
+						})
+						err := epMgr.ResolveUpdateBatch()
+						Expect(err).ToNot(HaveOccurred())
+						err = epMgr.CompleteDeferredWork()
+						Expect(err).ToNot(HaveOccurred())
+					})
+
+					It("should police the VRF device with eth0's host endpoint", expectChainsFor("eth0", "vrf-data"))
+
+					Context("with a workload interface in the same VRF", func() {
+						JustBeforeEach(func() {
+							epMgr.OnUpdate(&ifaceUpdate{
+								Name:  "cali12345",
+								State: "up",
+								VRF:   "vrf-data",
+							})
+							err := epMgr.ResolveUpdateBatch()
+							Expect(err).ToNot(HaveOccurred())
+							err = epMgr.CompleteDeferredWork()
+							Expect(err).ToNot(HaveOccurred())
+						})
+
+						It("should not police the VRF device", expectChainsFor("eth0"))
+					})
+
+					Context("after eth0 leaves the VRF", func() {
+						JustBeforeEach(func() {
+							epMgr.OnUpdate(&ifaceUpdate{
+								Name:  "eth0",
+								State: "up",
+							})
+							err := epMgr.ResolveUpdateBatch()
+							Expect(err).ToNot(HaveOccurred())
+							err = epMgr.CompleteDeferredWork()
+							Expect(err).ToNot(HaveOccurred())
+						})
+
+						It("should stop policing the VRF device", expectChainsFor("eth0"))
+					})
+				})
 			})
 
 			Describe("with host endpoint matching non-existent interface", func() {
@@ -1513,6 +1767,31 @@ func endpointManagerTests(ipVersion uint8) func() {
 
 					It("should have expected chains", expectWlChainsFor("cali12345-ab_policy1"))
 
+					It("should report the first policy generation, starting from the time", func() {
+						Expect(statusReportRec.currentGenerations[wlEPID1]).To(BeNumerically("==", 1000001))
+					})
+
+					It("should bump the policy generation when the policy changes", func() {
+						epMgr.OnUpdate(&proto.ActivePolicyUpdate{
+							Id: &proto.PolicyID{Tier: "default", Name: "policy1"},
+						})
+						Expect(epMgr.ResolveUpdateBatch()).To(Succeed())
+						Expect(epMgr.CompleteDeferredWork()).To(Succeed())
+						Expect(statusReportRec.currentGenerations[wlEPID1]).To(BeNumerically("==", 1000002))
+					})
+
+					It("should not bump the policy generation for unrelated policies", func() {
+						epMgr.OnUpdate(&proto.ActivePolicyUpdate{
+							Id: &proto.PolicyID{Tier: "default", Name: "policy2"},
+						})
+						epMgr.OnUpdate(&proto.ActiveProfileUpdate{
+							Id: &proto.ProfileID{Name: "prof1"},
+						})
+						Expect(epMgr.ResolveUpdateBatch()).To(Succeed())
+						Expect(epMgr.CompleteDeferredWork()).To(Succeed())
+						Expect(statusReportRec.currentGenerations[wlEPID1]).To(BeNumerically("==", 1000001))
+					})
+
 					Context("with another endpoint with the same interface name and earlier workload ID, and no policy", func() {
 
 						JustBeforeEach(func() {
@@ -1702,6 +1981,41 @@ func endpointManagerTests(ipVersion uint8) func() {
 							wlEPID1: "error",
 						}))
 					})
+
+					Context("with the interface present in /proc/sys", func() {
+						JustBeforeEach(func() {
+							// The interface is still queued for reconfiguration so this
+							// retries the failed configuration.
+							mockProcSys.pathsThatExist[fmt.Sprintf("/proc/sys/net/ipv%d/conf/cali12345-ab", ipVersion)] = true
+							err := epMgr.ResolveUpdateBatch()
+							Expect(err).ToNot(HaveOccurred())
+							err = epMgr.CompleteDeferredWork()
+							Expect(err).ToNot(HaveOccurred())
+						})
+
+						It("should report the reason for the error", func() {
+							Expect(statusReportRec.currentReasons).To(Equal(map[interface{}]string{
+								wlEPID1: "failed to configure interface: mock proc sys failure",
+							}))
+						})
+
+						Context("after the failure is resolved", func() {
+							JustBeforeEach(func() {
+								mockProcSys.Fail = false
+								err := epMgr.ResolveUpdateBatch()
+								Expect(err).ToNot(HaveOccurred())
+								err = epMgr.CompleteDeferredWork()
+								Expect(err).ToNot(HaveOccurred())
+							})
+
+							It("should report the endpoint up with no reason", func() {
+								Expect(statusReportRec.currentState).To(Equal(map[interface{}]string{
+									wlEPID1: "up",
+								}))
+								Expect(statusReportRec.currentReasons).To(BeEmpty())
+							})
+						})
+					})
 				})
 
 				Context("with updates for the workload's iface", func() {
@@ -1727,6 +2041,69 @@ func endpointManagerTests(ipVersion uint8) func() {
 						}))
 					})
 
+					Context("when the route table fails to program the iface's routes", func() {
+						JustBeforeEach(func() {
+							routeTable.syncErrs = map[string]error{"cali12345-ab": routetable.UpdateFailed}
+							err := epMgr.CompleteDeferredWork()
+							Expect(err).ToNot(HaveOccurred())
+						})
+
+						It("should report the endpoint in error with the reason", func() {
+							Expect(statusReportRec.currentState).To(Equal(map[interface{}]string{
+								wlEPID1: "error",
+							}))
+							Expect(statusReportRec.currentReasons).To(Equal(map[interface{}]string{
+								wlEPID1: "failed to program routes: " + routetable.UpdateFailed.Error(),
+							}))
+						})
+
+						It("should report the endpoint up once the routes are programmed", func() {
+							routeTable.syncErrs = nil
+							err := epMgr.CompleteDeferredWork()
+							Expect(err).ToNot(HaveOccurred())
+							Expect(statusReportRec.currentState).To(Equal(map[interface{}]string{
+								wlEPID1: "up",
+							}))
+							Expect(statusReportRec.currentReasons).To(BeEmpty())
+						})
+					})
+
+					Context("when iptables-restore fails on one of the iface's chains", func() {
+						var rErr *iptables.RestoreError
+
+						JustBeforeEach(func() {
+							rErr = &iptables.RestoreError{
+								Err:       errors.New("exit status 1"),
+								Line:      7,
+								Chain:     "cali-tw-cali12345-ab",
+								RuleIndex: 0,
+								Origin:    "workload endpoint pod-11/endpoint-id-11",
+							}
+							Expect(epMgr.chainOrigins.onRestoreFailure("filter", rErr)).To(BeTrue())
+							err := epMgr.CompleteDeferredWork()
+							Expect(err).ToNot(HaveOccurred())
+						})
+
+						It("should report the endpoint in error with the failure", func() {
+							Expect(statusReportRec.currentState).To(Equal(map[interface{}]string{
+								wlEPID1: "error",
+							}))
+							Expect(statusReportRec.currentReasons).To(Equal(map[interface{}]string{
+								wlEPID1: "failed to program policy: " + rErr.Error(),
+							}))
+						})
+
+						It("should report the endpoint up once the table is programmed", func() {
+							Expect(epMgr.chainOrigins.onRestoreFailure("filter", nil)).To(BeTrue())
+							err := epMgr.CompleteDeferredWork()
+							Expect(err).ToNot(HaveOccurred())
+							Expect(statusReportRec.currentState).To(Equal(map[interface{}]string{
+								wlEPID1: "up",
+							}))
+							Expect(statusReportRec.currentReasons).To(BeEmpty())
+						})
+					})
+
 					It("should write /proc/sys entries", func() {
 						if ipVersion == 6 {
 							mockProcSys.checkState(map[string]string{
@@ -1810,6 +2187,111 @@ func endpointManagerTests(ipVersion uint8) func() {
 						})
 					})
 
+					Context("with extra routes configured for the endpoint", func() {
+						var labels map[string]string
+
+						BeforeEach(func() {
+							extraRoutes = []config.ExtraRouteRule{{
+								Selector: "vm == 'router'",
+								CIDRs:    []string{"10.65.0.0/24", "10.66.0.0/24", "2001:db8:65::/64"},
+							}}
+							labels = map[string]string{"vm": "router"}
+						})
+
+						JustBeforeEach(func() {
+							for _, pool := range []*proto.IPAMPool{
+								{Cidr: "10.65.0.0/16", Disabled: true},
+								{Cidr: "2001:db8:65::/48", Disabled: true},
+								// Not disabled, so IPAM may give its IPs to other workloads.
+								{Cidr: "10.66.0.0/16"},
+							} {
+								epMgr.OnUpdate(&proto.IPAMPoolUpdate{
+									Id:   strings.Replace(pool.Cidr, "/", "-", 1),
+									Pool: pool,
+								})
+							}
+							epMgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+								Id: &wlEPID1,
+								Endpoint: &proto.WorkloadEndpoint{
+									State:      "active",
+									Mac:        "01:02:03:04:05:06",
+									Name:       "cali12345-ab",
+									ProfileIds: []string{},
+									Tiers:      []*proto.TierInfo{},
+									Ipv4Nets:   []string{"10.0.240.2/24"},
+									Ipv6Nets:   []string{"2001:db8:2::2/128"},
+									Labels:     labels,
+								},
+							})
+							err := epMgr.ResolveUpdateBatch()
+							Expect(err).ToNot(HaveOccurred())
+							err = epMgr.CompleteDeferredWork()
+							Expect(err).ToNot(HaveOccurred())
+						})
+
+						workloadRoutes := func() []routetable.Target {
+							if ipVersion == 6 {
+								return []routetable.Target{
+									{
+										CIDR:    ip.MustParseCIDROrIP("2001:db8:2::2/128"),
+										DestMAC: testutils.MustParseMAC("01:02:03:04:05:06"),
+									},
+								}
+							}
+							return []routetable.Target{
+								{
+									CIDR:    ip.MustParseCIDROrIP("10.0.240.0/24"),
+									DestMAC: testutils.MustParseMAC("01:02:03:04:05:06"),
+								},
+							}
+						}
+
+						It("should route the extra CIDRs in disabled pools via the workload", func() {
+							if ipVersion == 6 {
+								routeTable.checkRoutes("cali12345-ab", append(workloadRoutes(), routetable.Target{
+									Type: routetable.TargetTypeNoEncap,
+									CIDR: ip.MustParseCIDROrIP("2001:db8:65::/64"),
+									GW:   ip.FromString("2001:db8:2::2"),
+								}))
+							} else {
+								routeTable.checkRoutes("cali12345-ab", append(workloadRoutes(), routetable.Target{
+									Type: routetable.TargetTypeNoEncap,
+									CIDR: ip.MustParseCIDROrIP("10.65.0.0/24"),
+									GW:   ip.FromString("10.0.240.2"),
+								}))
+							}
+						})
+
+						Context("with the disabled pools enabled", func() {
+							JustBeforeEach(func() {
+								for _, cidr := range []string{"10.65.0.0/16", "2001:db8:65::/48"} {
+									epMgr.OnUpdate(&proto.IPAMPoolUpdate{
+										Id:   strings.Replace(cidr, "/", "-", 1),
+										Pool: &proto.IPAMPool{Cidr: cidr},
+									})
+								}
+								err := epMgr.ResolveUpdateBatch()
+								Expect(err).ToNot(HaveOccurred())
+								err = epMgr.CompleteDeferredWork()
+								Expect(err).ToNot(HaveOccurred())
+							})
+
+							It("should remove the extra routes", func() {
+								routeTable.checkRoutes("cali12345-ab", workloadRoutes())
+							})
+						})
+
+						Context("with a workload that the rule doesn't select", func() {
+							BeforeEach(func() {
+								labels = map[string]string{"vm": "other"}
+							})
+
+							It("should only route the workload's own IPs", func() {
+								routeTable.checkRoutes("cali12345-ab", workloadRoutes())
+							})
+						})
+					})
+
 					Context("with the endpoint removed", func() {
 						JustBeforeEach(func() {
 							epMgr.OnUpdate(&proto.WorkloadEndpointRemove{
@@ -1887,6 +2369,53 @@ func endpointManagerTests(ipVersion uint8) func() {
 				})
 			})
 
+			Context("with connection limits configured", func() {
+				wlEPID1 := proto.WorkloadEndpointID{
+					OrchestratorId: "k8s",
+					WorkloadId:     "pod-11",
+					EndpointId:     "endpoint-id-11",
+				}
+				addWorkload := func(labels map[string]string) {
+					epMgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+						Id: &wlEPID1,
+						Endpoint: &proto.WorkloadEndpoint{
+							State:      "active",
+							Mac:        "01:02:03:04:05:06",
+							Name:       "cali12345-ab",
+							ProfileIds: []string{},
+							Tiers:      []*proto.TierInfo{},
+							Ipv4Nets:   []string{"10.0.240.2/24"},
+							Ipv6Nets:   []string{"2001:db8:2::2/128"},
+							Labels:     labels,
+						},
+					})
+					Expect(epMgr.ResolveUpdateBatch()).To(Succeed())
+					Expect(epMgr.CompleteDeferredWork()).To(Succeed())
+				}
+				limitRule := iptables.Rule{
+					Match:   iptables.Match().ConntrackState("NEW").ConnLimitAbove(100),
+					Action:  iptables.DropAction{},
+					Comment: []string{"Drop connections over the workload's connection limit"},
+				}
+
+				BeforeEach(func() {
+					connLimits = []config.ConnectionLimitRule{{
+						Selector:       "projectcalico.org/namespace == 'batch'",
+						MaxConnections: 100,
+					}}
+				})
+
+				It("should limit the workloads that the config selects", func() {
+					addWorkload(map[string]string{"projectcalico.org/namespace": "batch"})
+					Expect(filterTable.currentChains["cali-fw-cali12345-ab"].Rules).To(ContainElement(limitRule))
+				})
+
+				It("should not limit other workloads", func() {
+					addWorkload(map[string]string{"projectcalico.org/namespace": "web"})
+					Expect(filterTable.currentChains["cali-fw-cali12345-ab"].Rules).NotTo(ContainElement(limitRule))
+				})
+			})
+
 			Context("with an inactive workload endpoint", func() {
 				wlEPID1 := proto.WorkloadEndpointID{
 					OrchestratorId: "k8s",
@@ -1941,6 +2470,120 @@ func endpointManagerTests(ipVersion uint8) func() {
 					routeTable.checkRoutes("cali12345-ab", nil)
 				})
 			})
+
+			Context("with VRF support and a workload endpoint in a VRF", func() {
+				wlEPID1 := proto.WorkloadEndpointID{
+					OrchestratorId: "k8s",
+					WorkloadId:     "pod-11",
+					EndpointId:     "endpoint-id-11",
+				}
+				BeforeEach(func() {
+					rrConfigNormal.VRFSupportEnabled = true
+				})
+				JustBeforeEach(func() {
+					epMgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+						Id: &wlEPID1,
+						Endpoint: &proto.WorkloadEndpoint{
+							State: "active",
+							Name:  "cali12345-ab",
+						},
+					})
+					epMgr.OnUpdate(&ifaceUpdate{
+						Name:  "cali12345-ab",
+						State: "up",
+						VRF:   "vrf-data",
+					})
+					err := epMgr.ResolveUpdateBatch()
+					Expect(err).ToNot(HaveOccurred())
+					err = epMgr.CompleteDeferredWork()
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("should mark and dispatch the workload's packets on its endpoint mark", func() {
+					mark, err := epMgr.epMarkMapper.GetEndpointMark("cali12345-ab")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(mangleTable.currentChains[rules.ChainSetVRFEndpointMark].Rules).To(ContainElement(iptables.Rule{
+						Match:  iptables.Match().InInterface("cali12345-ab"),
+						Action: iptables.SetMaskedMarkAction{Mark: mark, Mask: 0xff00},
+					}))
+					Expect(filterTable.currentChains[rules.ChainFromVRFWorkloadDispatch].Rules).To(ContainElement(iptables.Rule{
+						Match:  iptables.Match().InInterface("vrf-data").MarkMatchesWithMask(mark, 0xff00),
+						Action: iptables.GotoAction{Target: "cali-fw-cali12345-ab"},
+					}))
+				})
+
+				Context("after the interface leaves the VRF", func() {
+					JustBeforeEach(func() {
+						epMgr.OnUpdate(&ifaceUpdate{
+							Name:  "cali12345-ab",
+							State: "up",
+						})
+						err := epMgr.ResolveUpdateBatch()
+						Expect(err).ToNot(HaveOccurred())
+						err = epMgr.CompleteDeferredWork()
+						Expect(err).ToNot(HaveOccurred())
+					})
+
+					It("should stop marking the workload's packets", func() {
+						Expect(mangleTable.currentChains[rules.ChainSetVRFEndpointMark].Rules).To(Equal([]iptables.Rule{
+							{Action: iptables.ClearMarkAction{Mark: 0xff00}},
+						}))
+					})
+				})
+			})
+
+			Context("with two workload endpoints with the same policies", func() {
+				wlID := func(n int) proto.WorkloadEndpointID {
+					return proto.WorkloadEndpointID{
+						OrchestratorId: "k8s",
+						WorkloadId:     fmt.Sprintf("pod-%d", n),
+						EndpointId:     "eth0",
+					}
+				}
+				groupName := rules.PolicyGroupChainName(rules.PolicyGroupInboundPfx, []string{"policy1", "policy2"})
+				update := func(n int, policies ...string) {
+					id := wlID(n)
+					epMgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+						Id: &id,
+						Endpoint: &proto.WorkloadEndpoint{
+							State: "active",
+							Name:  fmt.Sprintf("cali12345-%d", n),
+							Tiers: []*proto.TierInfo{{
+								Name:            "default",
+								IngressPolicies: policies,
+							}},
+						},
+					})
+					Expect(epMgr.ResolveUpdateBatch()).To(Succeed())
+					Expect(epMgr.CompleteDeferredWork()).To(Succeed())
+				}
+				remove := func(n int) {
+					id := wlID(n)
+					epMgr.OnUpdate(&proto.WorkloadEndpointRemove{Id: &id})
+					Expect(epMgr.ResolveUpdateBatch()).To(Succeed())
+					Expect(epMgr.CompleteDeferredWork()).To(Succeed())
+				}
+
+				JustBeforeEach(func() {
+					update(1, "policy1", "policy2")
+					update(2, "policy1", "policy2")
+				})
+
+				It("should share the policy group chain", func() {
+					Expect(filterTable.currentChains).To(HaveKey(groupName))
+					Expect(filterTable.currentChains["cali-tw-cali12345-1"].Rules).To(ContainElement(
+						iptables.Rule{Action: iptables.JumpAction{Target: groupName}}))
+					Expect(filterTable.currentChains["cali-tw-cali12345-2"].Rules).To(ContainElement(
+						iptables.Rule{Action: iptables.JumpAction{Target: groupName}}))
+				})
+
+				It("should keep the group chain until neither workload uses it", func() {
+					remove(1)
+					Expect(filterTable.currentChains).To(HaveKey(groupName))
+					update(2, "policy1")
+					Expect(filterTable.currentChains).NotTo(HaveKey(groupName))
+				})
+			})
 		})
 
 		It("should check the correct path", func() {
@@ -0,0 +1,209 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Probe packets are "<type> <target address>".  Replies carry the address that was probed because
+// the remote node may answer from the address of another plane.
+const (
+	fabricPlaneProbeRequest = "calico-plane-probe"
+	fabricPlaneProbeReply   = "calico-plane-reply"
+)
+
+// fabricPlaneReachabilityUpdate tells the vxlanManager whether a remote node answers probes on one
+// of its fabric plane addresses.
+type fabricPlaneReachabilityUpdate struct {
+	Addr      string
+	Reachable bool
+}
+
+// planeProber is the interface that the vxlanManager uses to tell the fabricPlaneProber which
+// addresses to probe.
+type planeProber interface {
+	SetTargets(addrs []string)
+}
+
+// fabricPlaneProber checks that remote nodes are reachable over each fabric plane, so that a node
+// only fails over to a plane that the remote node can also use.  It answers the probes of other
+// nodes, probes each target every interval and sends a fabricPlaneReachabilityUpdate when a target
+// starts or stops answering.  A target counts as reachable until it has failed to answer for the
+// timeout; that avoids failing over when Felix starts or a node first appears.
+type fabricPlaneProber struct {
+	port     int
+	interval time.Duration
+	timeout  time.Duration
+	updates  chan<- *fabricPlaneReachabilityUpdate
+
+	conn net.PacketConn
+
+	lock    sync.Mutex
+	targets map[string]*fabricPlaneTarget
+}
+
+type fabricPlaneTarget struct {
+	// lastReply is the time of the last reply, or of when we started probing if there has been
+	// no reply yet.
+	lastReply time.Time
+	reachable bool
+}
+
+func newFabricPlaneProber(
+	port int,
+	interval time.Duration,
+	timeout time.Duration,
+	updates chan<- *fabricPlaneReachabilityUpdate,
+) *fabricPlaneProber {
+	return &fabricPlaneProber{
+		port:     port,
+		interval: interval,
+		timeout:  timeout,
+		updates:  updates,
+		targets:  map[string]*fabricPlaneTarget{},
+	}
+}
+
+// Start opens the probe socket and starts the goroutines that answer and send probes.
+func (p *fabricPlaneProber) Start(ctx context.Context) error {
+	conn, err := net.ListenPacket("udp4", net.JoinHostPort("", strconv.Itoa(p.port)))
+	if err != nil {
+		return err
+	}
+	p.conn = conn
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+	go p.loopReceiving()
+	go p.loopProbing(ctx)
+	return nil
+}
+
+// SetTargets sets the remote plane addresses to probe.  It's called from the main dataplane
+// goroutine.
+func (p *fabricPlaneProber) SetTargets(addrs []string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	wanted := map[string]bool{}
+	for _, addr := range addrs {
+		wanted[addr] = true
+		if _, ok := p.targets[addr]; !ok {
+			p.targets[addr] = &fabricPlaneTarget{lastReply: time.Now(), reachable: true}
+		}
+	}
+	for addr := range p.targets {
+		if !wanted[addr] {
+			delete(p.targets, addr)
+		}
+	}
+}
+
+func (p *fabricPlaneProber) loopReceiving() {
+	buf := make([]byte, 256)
+	for {
+		n, from, err := p.conn.ReadFrom(buf)
+		if err != nil {
+			log.WithError(err).Info("Fabric plane probe socket closed, no longer answering probes.")
+			return
+		}
+		fields := strings.Fields(string(buf[:n]))
+		if len(fields) != 2 {
+			log.WithField("from", from).Debug("Ignoring malformed fabric plane probe packet.")
+			continue
+		}
+		switch fields[0] {
+		case fabricPlaneProbeRequest:
+			reply := []byte(fabricPlaneProbeReply + " " + fields[1])
+			if _, err := p.conn.WriteTo(reply, from); err != nil {
+				log.WithError(err).WithField("to", from).Debug("Failed to answer fabric plane probe.")
+			}
+		case fabricPlaneProbeReply:
+			p.onReply(fields[1], time.Now())
+		}
+	}
+}
+
+func (p *fabricPlaneProber) loopProbing(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, upd := range p.sendProbes() {
+			p.updates <- upd
+		}
+	}
+}
+
+// sendProbes sends a probe to each target and returns the updates for the targets whose state has
+// changed.
+func (p *fabricPlaneProber) sendProbes() []*fabricPlaneReachabilityUpdate {
+	p.lock.Lock()
+	addrs := make([]string, 0, len(p.targets))
+	for addr := range p.targets {
+		addrs = append(addrs, addr)
+	}
+	p.lock.Unlock()
+
+	for _, addr := range addrs {
+		to := &net.UDPAddr{IP: net.ParseIP(addr), Port: p.port}
+		if _, err := p.conn.WriteTo([]byte(fabricPlaneProbeRequest+" "+addr), to); err != nil {
+			log.WithError(err).WithField("addr", addr).Debug("Failed to send fabric plane probe.")
+		}
+	}
+	return p.checkTargets(time.Now())
+}
+
+func (p *fabricPlaneProber) onReply(addr string, now time.Time) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if t, ok := p.targets[addr]; ok {
+		t.lastReply = now
+	}
+}
+
+// checkTargets updates the reachability of each target and returns the updates for the targets
+// whose state has changed.  The caller sends them without holding the lock, so that the main
+// dataplane goroutine can't block in SetTargets while we wait for it to read the channel.
+func (p *fabricPlaneProber) checkTargets(now time.Time) []*fabricPlaneReachabilityUpdate {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var updates []*fabricPlaneReachabilityUpdate
+	for addr, t := range p.targets {
+		reachable := now.Sub(t.lastReply) < p.timeout
+		if reachable == t.reachable {
+			continue
+		}
+		log.WithFields(log.Fields{"addr": addr, "reachable": reachable}).Info(
+			"Fabric plane address changed reachability")
+		t.reachable = reachable
+		updates = append(updates, &fabricPlaneReachabilityUpdate{Addr: addr, Reachable: reachable})
+	}
+	return updates
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"context"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("fabricPlaneProber", func() {
+	var (
+		prober  *fabricPlaneProber
+		updates chan *fabricPlaneReachabilityUpdate
+	)
+
+	BeforeEach(func() {
+		updates = make(chan *fabricPlaneReachabilityUpdate, 10)
+		prober = newFabricPlaneProber(0, time.Second, 3*time.Second, updates)
+		prober.SetTargets([]string{"10.1.0.2", "10.2.0.2"})
+	})
+
+	It("should treat new targets as reachable until the timeout", func() {
+		Expect(prober.checkTargets(time.Now().Add(time.Second))).To(BeEmpty())
+	})
+
+	It("should report targets that stop and start answering", func() {
+		now := time.Now()
+		prober.onReply("10.1.0.2", now.Add(2*time.Second))
+		Expect(prober.checkTargets(now.Add(4 * time.Second))).To(ConsistOf(
+			&fabricPlaneReachabilityUpdate{Addr: "10.2.0.2", Reachable: false},
+		))
+		Expect(prober.checkTargets(now.Add(4 * time.Second))).To(BeEmpty())
+
+		prober.onReply("10.2.0.2", now.Add(5*time.Second))
+		Expect(prober.checkTargets(now.Add(6 * time.Second))).To(ConsistOf(
+			&fabricPlaneReachabilityUpdate{Addr: "10.1.0.2", Reachable: false},
+			&fabricPlaneReachabilityUpdate{Addr: "10.2.0.2", Reachable: true},
+		))
+	})
+
+	It("should ignore replies from addresses that it isn't probing", func() {
+		prober.SetTargets([]string{"10.1.0.2"})
+		prober.onReply("10.2.0.2", time.Now())
+		Expect(prober.targets).To(HaveLen(1))
+	})
+
+	It("should answer its own probes over loopback", func() {
+		// Find a free port to probe.
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		port := conn.LocalAddr().(*net.UDPAddr).Port
+		Expect(conn.Close()).To(Succeed())
+
+		prober = newFabricPlaneProber(port, 10*time.Millisecond, 100*time.Millisecond, updates)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		Expect(prober.Start(ctx)).To(Succeed())
+		prober.SetTargets([]string{"127.0.0.1"})
+
+		// Without replies, the target would become unreachable after 100ms.
+		Consistently(updates, "300ms").ShouldNot(Receive())
+	})
+})
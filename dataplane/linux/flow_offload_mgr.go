@@ -0,0 +1,330 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/ifacemonitor"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/libcalico-go/lib/selector"
+	"github.com/projectcalico/libcalico-go/lib/set"
+)
+
+const (
+	cmdNft = "nft"
+
+	flowOffloadTable = "inet calico-flowoffload"
+)
+
+var (
+	descFlowOffloadFlows = prometheus.NewDesc(
+		"felix_flow_offload_flows_added",
+		"Number of packets that added an established flow to the flow offload table; roughly one per offloaded flow.",
+		nil, nil,
+	)
+	descFlowOffloadIfaces = prometheus.NewDesc(
+		"felix_flow_offload_interfaces",
+		"Number of interfaces whose forwarded flows can be offloaded.",
+		nil, nil,
+	)
+
+	nftCounterRegexp = regexp.MustCompile(`counter packets (\d+)`)
+)
+
+// flowOffloadManager offloads forwarded flows, once they're established, to an nftables
+// flowtable, so that their packets skip the netfilter hooks (and so our iptables rules) from then
+// on.  Our iptables rules only accept the first packets of a flow if policy allows it, and the
+// nftables forward chain that adds flows to the flowtable runs after iptables' filter table, so
+// only flows that have passed policy get offloaded.  A flow is only offloaded if both its input
+// and output interfaces are in the flowtable, which holds the local workload interfaces (apart
+// from those of excluded workloads) and the matching host interfaces.
+//
+// A hardware flowtable can only hold devices that support hardware offload, which veths don't, so
+// if we fail to program the hardware flowtable we fall back to a software one.
+type flowOffloadManager struct {
+	hardware bool
+	// hardwareFailed is set when we've fallen back to software offload.
+	hardwareFailed bool
+
+	hostIfaces   []*regexp.Regexp
+	excludedSel  selector.Selector
+	newCmd       cmdFactory
+	workloadIfcs map[proto.WorkloadEndpointID]string
+	excludedIfcs set.Set
+	upIfaces     set.Set
+
+	// programmedDevices holds the devices that we've added to the flowtable and that may still
+	// be in it; the kernel removes a device from the flowtable when the device is deleted.  It's
+	// nil if we don't know what's in the flowtable, in which case we replace the whole table.
+	programmedDevices set.Set
+	dirty             bool
+
+	numDevices int32
+}
+
+func newFlowOffloadManager(
+	hardware bool,
+	hostIfaces []*regexp.Regexp,
+	excludeSelector string,
+	newCmd cmdFactory,
+) *flowOffloadManager {
+	var excludedSel selector.Selector
+	if excludeSelector != "" {
+		var err error
+		excludedSel, err = selector.Parse(excludeSelector)
+		if err != nil {
+			// The selector is validated when the config is loaded.
+			log.WithError(err).Panic("Failed to parse flow offload exclude selector")
+		}
+	}
+	return &flowOffloadManager{
+		hardware:     hardware,
+		hostIfaces:   hostIfaces,
+		excludedSel:  excludedSel,
+		newCmd:       newCmd,
+		workloadIfcs: map[proto.WorkloadEndpointID]string{},
+		excludedIfcs: set.New(),
+		upIfaces:     set.New(),
+		// Always sync on the first apply, to remove any leftover table.
+		dirty: true,
+	}
+}
+
+func (m *flowOffloadManager) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *proto.WorkloadEndpointUpdate:
+		if old, ok := m.workloadIfcs[*msg.Id]; ok {
+			m.excludedIfcs.Discard(old)
+		}
+		m.workloadIfcs[*msg.Id] = msg.Endpoint.Name
+		if m.excludedSel != nil && m.excludedSel.Evaluate(msg.Endpoint.Labels) {
+			m.excludedIfcs.Add(msg.Endpoint.Name)
+		}
+		m.dirty = true
+	case *proto.WorkloadEndpointRemove:
+		if old, ok := m.workloadIfcs[*msg.Id]; ok {
+			m.excludedIfcs.Discard(old)
+			delete(m.workloadIfcs, *msg.Id)
+			m.dirty = true
+		}
+	case *ifaceUpdate:
+		if msg.State == ifacemonitor.StateUp {
+			m.upIfaces.Add(msg.Name)
+		} else {
+			m.upIfaces.Discard(msg.Name)
+			// The interface may have been deleted, taking it out of the flowtable, in
+			// which case we mustn't try to remove it.  If it was only set down, leaving it
+			// in the flowtable does no harm.
+			if m.programmedDevices != nil {
+				m.programmedDevices.Discard(msg.Name)
+			}
+		}
+		m.dirty = true
+	}
+}
+
+func (m *flowOffloadManager) CompleteDeferredWork() error {
+	if !m.dirty {
+		return nil
+	}
+
+	devices := m.desiredDevices()
+	var script string
+	if m.programmedDevices == nil || m.programmedDevices.Len() == 0 || len(devices) == 0 {
+		// We don't know what's in the table, or we need to create or delete it.  There are
+		// no offloaded flows that we need to keep in any of those cases.
+		script = m.nftScript(devices)
+	} else {
+		// Add and remove devices without touching the rest of the table, so that the flows
+		// between the other devices stay offloaded.
+		var added, removed []string
+		for _, d := range devices {
+			if !m.programmedDevices.Contains(d) {
+				added = append(added, d)
+			}
+		}
+		desired := set.FromArray(devices)
+		m.programmedDevices.Iter(func(item interface{}) error {
+			if !desired.Contains(item) {
+				removed = append(removed, item.(string))
+			}
+			return nil
+		})
+		if len(added) == 0 && len(removed) == 0 {
+			m.dirty = false
+			return nil
+		}
+		sort.Strings(removed)
+		script = m.nftDeltaScript(added, removed)
+	}
+
+	logCxt := log.WithField("devices", devices)
+	logCxt.Info("Updating flow offload table")
+	err := m.runNft(script)
+	if err != nil && m.useHardware() && len(devices) > 0 {
+		// Probably a device that doesn't support hardware offload.  Replace the table with a
+		// software one; if that works, stay with software.
+		logCxt.WithError(err).Warn(
+			"Failed to program hardware flow offload table, falling back to software offload")
+		m.hardwareFailed = true
+		err = m.runNft(m.nftScript(devices))
+		if err != nil {
+			// Not a hardware problem after all.
+			m.hardwareFailed = false
+		}
+	}
+	if err != nil {
+		logCxt.WithError(err).Warn("Failed to update flow offload table, will retry")
+		// Replace the whole table next time.
+		m.programmedDevices = nil
+		return err
+	}
+	m.programmedDevices = set.FromArray(devices)
+	atomic.StoreInt32(&m.numDevices, int32(len(devices)))
+	m.dirty = false
+	return nil
+}
+
+func (m *flowOffloadManager) runNft(script string) error {
+	_, err := m.newCmd(cmdNft, script).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		err = fmt.Errorf("%s", strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
+
+func (m *flowOffloadManager) useHardware() bool {
+	return m.hardware && !m.hardwareFailed
+}
+
+func (m *flowOffloadManager) desiredDevices() []string {
+	devices := []string{}
+	isWorkloadIface := map[string]bool{}
+	for _, name := range m.workloadIfcs {
+		isWorkloadIface[name] = true
+	}
+	m.upIfaces.Iter(func(item interface{}) error {
+		name := item.(string)
+		if isWorkloadIface[name] {
+			if !m.excludedIfcs.Contains(name) {
+				devices = append(devices, name)
+			}
+			return nil
+		}
+		for _, re := range m.hostIfaces {
+			if re.MatchString(name) {
+				devices = append(devices, name)
+				break
+			}
+		}
+		return nil
+	})
+	sort.Strings(devices)
+	return devices
+}
+
+// nftScript returns an nft script that atomically replaces our table, which flushes all of the
+// offloaded flows.  Adding the table before deleting it means that the delete succeeds whether or
+// not the table already exists.
+func (m *flowOffloadManager) nftScript(devices []string) string {
+	cmds := []string{
+		"add table " + flowOffloadTable,
+		"delete table " + flowOffloadTable,
+	}
+	if len(devices) == 0 {
+		// A flowtable needs at least one device.
+		return strings.Join(cmds, "; ")
+	}
+	cmds = append(cmds,
+		"add table "+flowOffloadTable,
+		m.addDevicesCmd(devices),
+		// Priority 10 puts the chain after iptables' filter table so that we only see packets
+		// that our rules have accepted.
+		"add chain "+flowOffloadTable+" forward { type filter hook forward priority 10; policy accept; }",
+		"add rule "+flowOffloadTable+" forward ct state established meta l4proto { tcp, udp } counter flow add @ft",
+	)
+	return strings.Join(cmds, "; ")
+}
+
+// nftDeltaScript returns an nft script that atomically adds devices to and removes devices from
+// our existing flowtable.
+func (m *flowOffloadManager) nftDeltaScript(added, removed []string) string {
+	var cmds []string
+	if len(added) > 0 {
+		cmds = append(cmds, m.addDevicesCmd(added))
+	}
+	if len(removed) > 0 {
+		cmds = append(cmds,
+			"delete flowtable "+flowOffloadTable+" ft { devices = { "+strings.Join(removed, ", ")+" }; }")
+	}
+	return strings.Join(cmds, "; ")
+}
+
+// addDevicesCmd returns the nft command that creates our flowtable with the given devices or, if
+// it already exists, adds the devices to it.
+func (m *flowOffloadManager) addDevicesCmd(devices []string) string {
+	flags := ""
+	if m.useHardware() {
+		flags = " flags offload;"
+	}
+	return "add flowtable " + flowOffloadTable + " ft { hook ingress priority 0; devices = { " +
+		strings.Join(devices, ", ") + " };" + flags + " }"
+}
+
+// removeFlowOffloadTable removes our table, if it exists, so that flows stop being offloaded
+// after the feature is disabled.
+func removeFlowOffloadTable(newCmd cmdFactory) {
+	// This fails if nft isn't installed or the table doesn't exist, which is the usual case.
+	if _, err := newCmd(cmdNft, "delete table "+flowOffloadTable).Output(); err == nil {
+		log.Info("Removed flow offload table")
+	}
+}
+
+func (m *flowOffloadManager) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descFlowOffloadFlows
+	ch <- descFlowOffloadIfaces
+}
+
+func (m *flowOffloadManager) Collect(ch chan<- prometheus.Metric) {
+	numDevices := atomic.LoadInt32(&m.numDevices)
+	ch <- prometheus.MustNewConstMetric(descFlowOffloadIfaces, prometheus.GaugeValue, float64(numDevices))
+	if numDevices == 0 {
+		return
+	}
+	out, err := m.newCmd(cmdNft, "list chain "+flowOffloadTable+" forward").Output()
+	if err != nil {
+		log.WithError(err).Debug("Failed to read flow offload counter")
+		return
+	}
+	match := nftCounterRegexp.FindSubmatch(out)
+	if match == nil {
+		return
+	}
+	flows, err := strconv.ParseFloat(string(match[1]), 64)
+	if err != nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(descFlowOffloadFlows, prometheus.CounterValue, flows)
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"regexp"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/ifacemonitor"
+	"github.com/projectcalico/felix/proto"
+)
+
+var _ = Describe("Flow offload manager", func() {
+	var (
+		mgr *flowOffloadManager
+		nft *tcRecorder
+	)
+
+	wlID := func(name string) *proto.WorkloadEndpointID {
+		return &proto.WorkloadEndpointID{OrchestratorId: "k8s", WorkloadId: "ns/" + name, EndpointId: "eth0"}
+	}
+
+	BeforeEach(func() {
+		nft = &tcRecorder{}
+		mgr = newFlowOffloadManager(false, []*regexp.Regexp{regexp.MustCompile("^eth")}, "offload == 'false'",
+			nft.factory)
+	})
+
+	It("should remove any leftover table on the first apply", func() {
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(nft.takeCmds()).To(Equal([]string{
+			"nft add table inet calico-flowoffload; delete table inet calico-flowoffload",
+		}))
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(nft.takeCmds()).To(BeEmpty())
+	})
+
+	It("should offload between up workload and host interfaces", func() {
+		mgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id:       wlID("pod1"),
+			Endpoint: &proto.WorkloadEndpoint{Name: "cali1"},
+		})
+		mgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id:       wlID("pod2"),
+			Endpoint: &proto.WorkloadEndpoint{Name: "cali2", Labels: map[string]string{"offload": "false"}},
+		})
+		for _, name := range []string{"cali1", "cali2", "eth0", "docker0"} {
+			mgr.OnUpdate(&ifaceUpdate{Name: name, State: ifacemonitor.StateUp})
+		}
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(nft.takeCmds()).To(Equal([]string{
+			"nft add table inet calico-flowoffload; delete table inet calico-flowoffload; " +
+				"add table inet calico-flowoffload; " +
+				"add flowtable inet calico-flowoffload ft { hook ingress priority 0; devices = { cali1, eth0 }; }; " +
+				"add chain inet calico-flowoffload forward { type filter hook forward priority 10; policy accept; }; " +
+				"add rule inet calico-flowoffload forward ct state established meta l4proto { tcp, udp } counter flow add @ft",
+		}))
+
+		// No change, no update.
+		mgr.OnUpdate(&ifaceUpdate{Name: "docker0", State: ifacemonitor.StateDown})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(nft.takeCmds()).To(BeEmpty())
+
+		// Devices are added and removed without replacing the table, which would flush the
+		// offloaded flows.
+		mgr.OnUpdate(&proto.WorkloadEndpointRemove{Id: wlID("pod1")})
+		mgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id:       wlID("pod3"),
+			Endpoint: &proto.WorkloadEndpoint{Name: "cali3"},
+		})
+		mgr.OnUpdate(&ifaceUpdate{Name: "cali3", State: ifacemonitor.StateUp})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(nft.takeCmds()).To(Equal([]string{
+			"nft add flowtable inet calico-flowoffload ft { hook ingress priority 0; devices = { cali3 }; }; " +
+				"delete flowtable inet calico-flowoffload ft { devices = { cali1 }; }",
+		}))
+
+		// An interface that goes down may have been deleted, which removes it from the
+		// flowtable, so we don't try to remove it.
+		mgr.OnUpdate(&ifaceUpdate{Name: "cali3", State: ifacemonitor.StateDown})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(nft.takeCmds()).To(BeEmpty())
+
+		// The table is deleted with the last device.
+		mgr.OnUpdate(&ifaceUpdate{Name: "eth0", State: ifacemonitor.StateDown})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(nft.takeCmds()).To(Equal([]string{
+			"nft add table inet calico-flowoffload; delete table inet calico-flowoffload",
+		}))
+	})
+
+	It("should fall back to software offload if hardware offload fails", func() {
+		mgr = newFlowOffloadManager(true, []*regexp.Regexp{regexp.MustCompile("^eth")}, "", nft.factory)
+		nft.failSubstrings = []string{"flags offload"}
+		mgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id:       wlID("pod1"),
+			Endpoint: &proto.WorkloadEndpoint{Name: "cali1"},
+		})
+		mgr.OnUpdate(&ifaceUpdate{Name: "cali1", State: ifacemonitor.StateUp})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		cmds := nft.takeCmds()
+		Expect(cmds).To(HaveLen(2))
+		Expect(cmds[0]).To(ContainSubstring("devices = { cali1 }; flags offload; }"))
+		Expect(cmds[1]).To(ContainSubstring("devices = { cali1 }; }"))
+
+		// Later devices are added in software too.
+		mgr.OnUpdate(&ifaceUpdate{Name: "eth0", State: ifacemonitor.StateUp})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(nft.takeCmds()).To(Equal([]string{
+			"nft add flowtable inet calico-flowoffload ft { hook ingress priority 0; devices = { eth0 }; }",
+		}))
+	})
+
+	It("should retry after a failure", func() {
+		nft.failPrefixes = []string{"nft"}
+		Expect(mgr.CompleteDeferredWork()).NotTo(Succeed())
+		nft.failPrefixes = nil
+		nft.takeCmds()
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(nft.takeCmds()).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,223 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/iptables"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+)
+
+const (
+	hepPathLocal   = "local"
+	hepPathForward = "forward"
+)
+
+var (
+	hepPolicyMetricLabels = []string{"ip_version", "iface", "policy", "direction", "path"}
+
+	descHEPPolicyPackets = prometheus.NewDesc(
+		"felix_host_endpoint_policy_packets",
+		"Number of packets that a host endpoint's policy was applied to, split by whether the "+
+			"packets were forwarded or originated/terminated locally.",
+		hepPolicyMetricLabels, nil,
+	)
+	descHEPPolicyBytes = prometheus.NewDesc(
+		"felix_host_endpoint_policy_bytes",
+		"Number of bytes that a host endpoint's policy was applied to, split by whether the "+
+			"packets were forwarded or originated/terminated locally.",
+		hepPolicyMetricLabels, nil,
+	)
+)
+
+// hepPolicyJump is a jump from one of a host endpoint's filter chains to one of its policies.  The
+// path is hepPathLocal for the chains that police traffic to and from the host itself and
+// hepPathForward for the chains that police forwarded traffic, which only applyOnForward policies
+// are rendered into.
+type hepPolicyJump struct {
+	Iface     string
+	Policy    string
+	Direction string
+	Path      string
+	iptables.ChainJump
+}
+
+// hostEndpointPolicyJumps returns the policy jumps in the filter chains that
+// HostEndpointToFilterChains renders for the given interface and policies.
+func hostEndpointPolicyJumps(
+	ifaceName string,
+	ingressPolicyNames []string,
+	egressPolicyNames []string,
+	ingressForwardPolicyNames []string,
+	egressForwardPolicyNames []string,
+) []hepPolicyJump {
+	var jumps []hepPolicyJump
+	add := func(names []string, chainPrefix string, polPrefix rules.PolicyChainNamePrefix, dir, path string) {
+		chain := rules.EndpointChainName(chainPrefix, ifaceName)
+		for _, name := range names {
+			jumps = append(jumps, hepPolicyJump{
+				Iface:     ifaceName,
+				Policy:    name,
+				Direction: dir,
+				Path:      path,
+				ChainJump: iptables.ChainJump{
+					Chain:  chain,
+					Target: rules.PolicyChainName(polPrefix, &proto.PolicyID{Name: name}),
+				},
+			})
+		}
+	}
+	add(ingressPolicyNames, rules.HostFromEndpointPfx, rules.PolicyInboundPfx, "inbound", hepPathLocal)
+	add(egressPolicyNames, rules.HostToEndpointPfx, rules.PolicyOutboundPfx, "outbound", hepPathLocal)
+	add(ingressForwardPolicyNames, rules.HostFromEndpointForwardPfx, rules.PolicyInboundPfx, "inbound", hepPathForward)
+	add(egressForwardPolicyNames, rules.HostToEndpointForwardPfx, rules.PolicyOutboundPfx, "outbound", hepPathForward)
+	return jumps
+}
+
+type hepPolicyJumpSource interface {
+	HostEndpointPolicyJumps() []hepPolicyJump
+}
+
+type jumpCounterReader interface {
+	ReadJumpCounters() (map[iptables.ChainJump]iptables.Counters, error)
+}
+
+// hepPolicyCounterSource pairs the endpoint manager and filter table of one IP version.
+type hepPolicyCounterSource struct {
+	ipVersion uint8
+	jumps     hepPolicyJumpSource
+	counters  jumpCounterReader
+}
+
+// hepPolicyCounters reports, for each host endpoint policy, how many packets it was applied to on
+// the local and forward paths, both as Prometheus metrics and as a debug report.  Since
+// applyOnForward is easy to get wrong, this shows which policies actually see forwarded traffic.
+// The counts come from the counters of the iptables rules that jump to the policies, which are
+// read, with iptables-save, when the metrics are scraped or the report is requested.  A count is
+// reset if its rule is reprogrammed.
+type hepPolicyCounters struct {
+	sources []hepPolicyCounterSource
+}
+
+func newHEPPolicyCounters(sources []hepPolicyCounterSource) *hepPolicyCounters {
+	return &hepPolicyCounters{sources: sources}
+}
+
+// hepPolicyCount is the count for one jump.
+type hepPolicyCount struct {
+	ipVersion uint8
+	hepPolicyJump
+	iptables.Counters
+}
+
+func (c *hepPolicyCounters) read() []hepPolicyCount {
+	var counts []hepPolicyCount
+	for _, src := range c.sources {
+		jumps := src.jumps.HostEndpointPolicyJumps()
+		if len(jumps) == 0 {
+			continue
+		}
+		counters, err := src.counters.ReadJumpCounters()
+		if err != nil {
+			log.WithError(err).WithField("ipVersion", src.ipVersion).Warn(
+				"Failed to read iptables counters for host endpoint policies.")
+			continue
+		}
+		for _, jump := range jumps {
+			counts = append(counts, hepPolicyCount{
+				ipVersion:     src.ipVersion,
+				hepPolicyJump: jump,
+				Counters:      counters[jump.ChainJump],
+			})
+		}
+	}
+	return counts
+}
+
+func (c *hepPolicyCounters) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descHEPPolicyPackets
+	ch <- descHEPPolicyBytes
+}
+
+func (c *hepPolicyCounters) Collect(ch chan<- prometheus.Metric) {
+	for _, count := range c.read() {
+		labels := []string{
+			strconv.Itoa(int(count.ipVersion)), count.Iface, count.Policy, count.Direction, count.Path,
+		}
+		ch <- prometheus.MustNewConstMetric(
+			descHEPPolicyPackets, prometheus.CounterValue, float64(count.Packets), labels...)
+		ch <- prometheus.MustNewConstMetric(
+			descHEPPolicyBytes, prometheus.CounterValue, float64(count.Bytes), labels...)
+	}
+}
+
+// Dump writes a table with a row for each host endpoint policy, showing the packets that it was
+// applied to on the local and forward paths.  A policy without applyOnForward shows "-" for the
+// forward path since it isn't applied there at all.
+func (c *hepPolicyCounters) Dump(w io.Writer) error {
+	type rowKey struct {
+		ipVersion                uint8
+		iface, direction, policy string
+	}
+	rows := map[rowKey]map[string]iptables.Counters{}
+	for _, count := range c.read() {
+		k := rowKey{count.ipVersion, count.Iface, count.Direction, count.Policy}
+		if rows[k] == nil {
+			rows[k] = map[string]iptables.Counters{}
+		}
+		rows[k][count.Path] = count.Counters
+	}
+	keys := make([]rowKey, 0, len(rows))
+	for k := range rows {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.ipVersion != b.ipVersion {
+			return a.ipVersion < b.ipVersion
+		}
+		if a.iface != b.iface {
+			return a.iface < b.iface
+		}
+		if a.direction != b.direction {
+			return a.direction < b.direction
+		}
+		return a.policy < b.policy
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "IP\tIFACE\tDIRECTION\tPOLICY\tLOCAL PACKETS\tFORWARD PACKETS")
+	for _, k := range keys {
+		cells := []string{}
+		for _, path := range []string{hepPathLocal, hepPathForward} {
+			if counters, ok := rows[k][path]; ok {
+				cells = append(cells, strconv.FormatUint(counters.Packets, 10))
+			} else {
+				cells = append(cells, "-")
+			}
+		}
+		fmt.Fprintf(tw, "IPv%d\t%s\t%s\t%s\t%s\t%s\n", k.ipVersion, k.iface, k.direction, k.policy, cells[0], cells[1])
+	}
+	return tw.Flush()
+}
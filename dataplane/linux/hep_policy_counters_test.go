@@ -0,0 +1,75 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/iptables"
+)
+
+type mockHEPPolicyJumps []hepPolicyJump
+
+func (j mockHEPPolicyJumps) HostEndpointPolicyJumps() []hepPolicyJump {
+	return j
+}
+
+type mockJumpCounters map[iptables.ChainJump]iptables.Counters
+
+func (c mockJumpCounters) ReadJumpCounters() (map[iptables.ChainJump]iptables.Counters, error) {
+	return c, nil
+}
+
+var _ = Describe("Host endpoint policy counters", func() {
+	It("should render jumps to the policies in each host endpoint chain", func() {
+		jumps := hostEndpointPolicyJumps("eth0", []string{"default.in"}, nil, []string{"default.in"}, []string{"default.out"})
+		Expect(jumps).To(ConsistOf(
+			hepPolicyJump{
+				Iface: "eth0", Policy: "default.in", Direction: "inbound", Path: "local",
+				ChainJump: iptables.ChainJump{Chain: "cali-fh-eth0", Target: "cali-pi-default.in"},
+			},
+			hepPolicyJump{
+				Iface: "eth0", Policy: "default.in", Direction: "inbound", Path: "forward",
+				ChainJump: iptables.ChainJump{Chain: "cali-fhfw-eth0", Target: "cali-pi-default.in"},
+			},
+			hepPolicyJump{
+				Iface: "eth0", Policy: "default.out", Direction: "outbound", Path: "forward",
+				ChainJump: iptables.ChainJump{Chain: "cali-thfw-eth0", Target: "cali-po-default.out"},
+			},
+		))
+	})
+
+	It("should report local and forward packets per policy", func() {
+		jumps := hostEndpointPolicyJumps("eth0", []string{"default.aof", "default.local"}, nil, []string{"default.aof"}, nil)
+		counters := newHEPPolicyCounters([]hepPolicyCounterSource{{
+			ipVersion: 4,
+			jumps:     mockHEPPolicyJumps(jumps),
+			counters: mockJumpCounters{
+				{Chain: "cali-fh-eth0", Target: "cali-pi-default.aof"}:   {Packets: 10, Bytes: 1000},
+				{Chain: "cali-fhfw-eth0", Target: "cali-pi-default.aof"}: {Packets: 3, Bytes: 300},
+				{Chain: "cali-fh-eth0", Target: "cali-pi-default.local"}: {Packets: 7, Bytes: 700},
+			},
+		}})
+		var buf bytes.Buffer
+		Expect(counters.Dump(&buf)).To(Succeed())
+		Expect(buf.String()).To(Equal(
+			"IP    IFACE  DIRECTION  POLICY         LOCAL PACKETS  FORWARD PACKETS\n" +
+				"IPv4  eth0   inbound    default.aof    10             3\n" +
+				"IPv4  eth0   inbound    default.local  7              -\n"))
+	})
+})
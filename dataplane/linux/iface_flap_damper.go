@@ -0,0 +1,148 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	countIfaceUpdatesDamped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "felix_int_dataplane_iface_updates_damped",
+		Help: "Number of workload interface state updates that were delayed or discarded because " +
+			"the interface was flapping.",
+	})
+	gaugeFlappingIfaces = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "felix_int_dataplane_flapping_ifaces",
+		Help: "Number of workload interfaces whose state updates are currently being damped.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(countIfaceUpdatesDamped)
+	prometheus.MustRegister(gaugeFlappingIfaces)
+}
+
+type dampedIface struct {
+	lastEmitted  ifaceUpdate
+	lastEmitTime time.Time
+	// pending is the latest update that we've held back, or nil if there isn't one.
+	pending *ifaceUpdate
+}
+
+// ifaceFlapDamper damps state changes of workload interfaces.  Some CNI plugins delete and
+// re-add a workload's interface several times in quick succession; without damping, each of those
+// changes would trigger reprogramming of the endpoint's chains and routes.
+//
+// The first change to an interface is passed through immediately.  Further changes within the
+// damping window are held back and, when the window expires, only the interface's latest state is
+// passed on (and only if it differs from the state that we last passed on).  It is only used from
+// the main dataplane goroutine.
+type ifaceFlapDamper struct {
+	window           time.Duration
+	workloadPrefixes []string
+
+	ifaces map[string]*dampedIface
+}
+
+func newIfaceFlapDamper(window time.Duration, workloadPrefixes []string) *ifaceFlapDamper {
+	return &ifaceFlapDamper{
+		window:           window,
+		workloadPrefixes: workloadPrefixes,
+		ifaces:           map[string]*dampedIface{},
+	}
+}
+
+func (d *ifaceFlapDamper) isWorkloadIface(name string) bool {
+	for _, prefix := range d.workloadPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// OnIfaceUpdate returns the update if it should be processed now, or nil if it has been held back.
+func (d *ifaceFlapDamper) OnIfaceUpdate(upd *ifaceUpdate, now time.Time) *ifaceUpdate {
+	if d.window <= 0 || !d.isWorkloadIface(upd.Name) {
+		return upd
+	}
+	iface := d.ifaces[upd.Name]
+	if iface == nil {
+		d.ifaces[upd.Name] = &dampedIface{lastEmitted: *upd, lastEmitTime: now}
+		return upd
+	}
+	if iface.pending == nil && now.Sub(iface.lastEmitTime) >= d.window {
+		iface.lastEmitted = *upd
+		iface.lastEmitTime = now
+		return upd
+	}
+	if iface.pending == nil {
+		log.WithField("ifaceName", upd.Name).Info("Interface is flapping, damping its state updates.")
+		gaugeFlappingIfaces.Inc()
+	}
+	log.WithField("update", upd).Debug("Holding back interface update.")
+	countIfaceUpdatesDamped.Inc()
+	iface.pending = upd
+	return nil
+}
+
+// NextFlushTime returns the time at which Flush next has work to do.
+func (d *ifaceFlapDamper) NextFlushTime() (next time.Time, ok bool) {
+	for _, iface := range d.ifaces {
+		t := iface.lastEmitTime.Add(d.window)
+		if !ok || t.Before(next) {
+			next = t
+			ok = true
+		}
+	}
+	return
+}
+
+// Flush returns the held back updates whose damping window has expired, in name order.  It also
+// forgets about interfaces that have been quiet for the whole window.
+func (d *ifaceFlapDamper) Flush(now time.Time) (updates []*ifaceUpdate) {
+	for name, iface := range d.ifaces {
+		if now.Sub(iface.lastEmitTime) < d.window {
+			continue
+		}
+		if iface.pending == nil {
+			// No changes in the last window, no need to track this interface any more.
+			delete(d.ifaces, name)
+			continue
+		}
+		gaugeFlappingIfaces.Dec()
+		upd := iface.pending
+		iface.pending = nil
+		if *upd == iface.lastEmitted {
+			log.WithField("ifaceName", name).Info(
+				"Interface flapped but ended in its previous state, discarding update.")
+			continue
+		}
+		log.WithField("update", upd).Info("Releasing damped interface update.")
+		iface.lastEmitted = *upd
+		iface.lastEmitTime = now
+		updates = append(updates, upd)
+	}
+	sort.Slice(updates, func(i, j int) bool {
+		return updates[i].Name < updates[j].Name
+	})
+	return
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/ifacemonitor"
+)
+
+var _ = Describe("Interface flap damper", func() {
+	var (
+		d   *ifaceFlapDamper
+		now time.Time
+	)
+
+	up := func(name string, idx int) *ifaceUpdate {
+		return &ifaceUpdate{Name: name, State: ifacemonitor.StateUp, Index: idx}
+	}
+	down := func(name string, idx int) *ifaceUpdate {
+		return &ifaceUpdate{Name: name, State: ifacemonitor.StateDown, Index: idx}
+	}
+
+	BeforeEach(func() {
+		d = newIfaceFlapDamper(time.Second, []string{"cali"})
+		now = time.Now()
+	})
+
+	It("should pass through non-workload interfaces", func() {
+		Expect(d.OnIfaceUpdate(up("eth0", 1), now)).To(Equal(up("eth0", 1)))
+		Expect(d.OnIfaceUpdate(down("eth0", 1), now)).To(Equal(down("eth0", 1)))
+		_, ok := d.NextFlushTime()
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should pass through everything when disabled", func() {
+		d = newIfaceFlapDamper(0, []string{"cali"})
+		Expect(d.OnIfaceUpdate(up("cali1", 1), now)).NotTo(BeNil())
+		Expect(d.OnIfaceUpdate(down("cali1", 1), now)).NotTo(BeNil())
+	})
+
+	It("should pass through the first update and updates after a quiet window", func() {
+		Expect(d.OnIfaceUpdate(up("cali1", 1), now)).To(Equal(up("cali1", 1)))
+		now = now.Add(2 * time.Second)
+		Expect(d.OnIfaceUpdate(down("cali1", 1), now)).To(Equal(down("cali1", 1)))
+	})
+
+	It("should discard a flap that ends in the original state", func() {
+		Expect(d.OnIfaceUpdate(up("cali1", 1), now)).NotTo(BeNil())
+		Expect(d.OnIfaceUpdate(down("cali1", 1), now.Add(10*time.Millisecond))).To(BeNil())
+		Expect(d.OnIfaceUpdate(up("cali1", 1), now.Add(20*time.Millisecond))).To(BeNil())
+
+		next, ok := d.NextFlushTime()
+		Expect(ok).To(BeTrue())
+		Expect(next).To(Equal(now.Add(time.Second)))
+		Expect(d.Flush(now.Add(500 * time.Millisecond))).To(BeEmpty())
+		Expect(d.Flush(next)).To(BeEmpty())
+
+		// The interface is then forgotten once it has been quiet for a window.
+		Expect(d.Flush(next.Add(time.Second))).To(BeEmpty())
+		Expect(d.ifaces).To(BeEmpty())
+	})
+
+	It("should release only the latest state of a flapping interface", func() {
+		Expect(d.OnIfaceUpdate(up("cali1", 1), now)).NotTo(BeNil())
+		Expect(d.OnIfaceUpdate(up("cali2", 2), now)).NotTo(BeNil())
+		Expect(d.OnIfaceUpdate(down("cali2", 2), now)).To(BeNil())
+		Expect(d.OnIfaceUpdate(down("cali1", 1), now)).To(BeNil())
+		Expect(d.OnIfaceUpdate(up("cali1", 3), now)).To(BeNil())
+
+		now = now.Add(time.Second)
+		Expect(d.Flush(now)).To(Equal([]*ifaceUpdate{up("cali1", 3), down("cali2", 2)}))
+
+		// Still damped for another window after the release.
+		Expect(d.OnIfaceUpdate(down("cali1", 3), now)).To(BeNil())
+	})
+})
@@ -15,6 +15,7 @@
 package intdataplane
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -32,6 +33,7 @@ import (
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 	"k8s.io/client-go/kubernetes"
 
+	"github.com/projectcalico/felix/alerts"
 	"github.com/projectcalico/felix/bpf"
 	"github.com/projectcalico/felix/bpf/arp"
 	"github.com/projectcalico/felix/bpf/conntrack"
@@ -43,6 +45,7 @@ import (
 	"github.com/projectcalico/felix/bpf/state"
 	"github.com/projectcalico/felix/bpf/tc"
 	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/dnscache"
 	"github.com/projectcalico/felix/idalloc"
 	"github.com/projectcalico/felix/ifacemonitor"
 	"github.com/projectcalico/felix/ipsets"
@@ -50,10 +53,13 @@ import (
 	"github.com/projectcalico/felix/jitter"
 	"github.com/projectcalico/felix/labelindex"
 	"github.com/projectcalico/felix/logutils"
+	"github.com/projectcalico/felix/nodeconditions"
 	"github.com/projectcalico/felix/proto"
 	"github.com/projectcalico/felix/routetable"
 	"github.com/projectcalico/felix/rules"
+	"github.com/projectcalico/felix/servicecidrs"
 	"github.com/projectcalico/felix/throttle"
+	"github.com/projectcalico/felix/tracing"
 	"github.com/projectcalico/felix/wireguard"
 	"github.com/projectcalico/libcalico-go/lib/health"
 	lclogutils "github.com/projectcalico/libcalico-go/lib/logutils"
@@ -122,6 +128,8 @@ type Config struct {
 	IPIPMTU              int
 	VXLANMTU             int
 	VXLANPort            int
+	IPIPDSCP             config.TunnelDSCP
+	VXLANDSCP            config.TunnelDSCP
 
 	MaxIPSetSize int
 
@@ -138,6 +146,10 @@ type Config struct {
 	IptablesLockTimeout            time.Duration
 	IptablesLockProbeInterval      time.Duration
 	XDPRefreshInterval             time.Duration
+	// BackgroundResyncMaxCPUPressure, if non-zero, is the CPU pressure (the percentage of time
+	// that tasks are stalled waiting for CPU) above which the periodic iptables, IP set and
+	// route refreshes are deferred.
+	BackgroundResyncMaxCPUPressure float64
 
 	Wireguard wireguard.Config
 
@@ -146,6 +158,15 @@ type Config struct {
 	RulesConfig rules.Config
 
 	IfaceMonitorConfig ifacemonitor.Config
+	// IfaceFlapDampingWindow is the window over which we coalesce state changes of a flapping
+	// workload interface.  Zero disables damping.
+	IfaceFlapDampingWindow time.Duration
+
+	// SysctlRefreshInterval is the interval at which we check for, and repair, changes to the
+	// sysctls that we manage.  Zero disables the check.
+	SysctlRefreshInterval time.Duration
+	// SysctlOverrides maps from sysctl name to the value to use in place of Felix's default.
+	SysctlOverrides map[string]string
 
 	StatusReportingInterval time.Duration
 
@@ -156,10 +177,111 @@ type Config struct {
 	HealthAggregator   *health.HealthAggregator
 	RouteTableManager  *idalloc.IndexAllocator
 
+	// DegradedRulesCallback, if set, is called with the policy rules and features that can't be
+	// rendered as written on this node, whenever that list changes.
+	DegradedRulesCallback func(issues []string)
+	// DataplaneSummaryCallback, if set, is called every DataplaneSummaryInterval with a summary
+	// of what we're programming.
+	DataplaneSummaryCallback func(summary nodeconditions.DataplaneSummary)
+	DataplaneSummaryInterval time.Duration
+
 	DebugSimulateDataplaneHangAfter time.Duration
 
 	ExternalNodesCidrs []string
 
+	// VXLANFabricPlanes lists the fabric planes that VXLAN traffic can use, in order of preference.
+	VXLANFabricPlanes []config.FabricPlane
+	// VXLANFabricPlaneProbePort, if non-zero, is the port used to probe the remote nodes'
+	// plane addresses.
+	VXLANFabricPlaneProbePort     int
+	VXLANFabricPlaneProbeInterval time.Duration
+	VXLANFabricPlaneProbeTimeout  time.Duration
+	// VXLANMulticastGroup, if set, is the underlay multicast group of the VXLAN device.
+	VXLANMulticastGroup net.IP
+
+	// MulticastIGMPVersion and MulticastMLDVersion, if non-zero, force the IGMP and MLD
+	// versions of workload interfaces; they only apply if RulesConfig.MulticastEnabled is set.
+	MulticastIGMPVersion int
+	MulticastMLDVersion  int
+	// MulticastGroupRoutes lists multicast group CIDRs that we route over the VXLAN device.
+	MulticastGroupRoutes []string
+
+	// WorkloadProxyNeighbors configures proxy ARP/NDP entries on selected workloads' interfaces.
+	WorkloadProxyNeighbors []config.ProxyNeighborRule
+	// WorkloadExtraRoutes routes additional CIDRs to selected workloads.
+	WorkloadExtraRoutes []config.ExtraRouteRule
+	// WorkloadConnectionLimits limits the connections from selected workloads.
+	WorkloadConnectionLimits []config.ConnectionLimitRule
+
+	// WorkloadBandwidthLimitsEnabled enables tc rate limiting of workloads, using the pods'
+	// bandwidth annotations (if KubeClientSet is set) and WorkloadBandwidthLimits.
+	WorkloadBandwidthLimitsEnabled bool
+	WorkloadBandwidthLimits        []config.BandwidthLimitRule
+
+	// FlowOffloadEnabled enables offloading established forwarded flows to an nftables
+	// flowtable; FlowOffloadHardware asks for hardware offload.  The flowtable holds the local
+	// workload interfaces, apart from those of workloads matching FlowOffloadExcludeSelector, and
+	// the host interfaces matching FlowOffloadHostInterfaces.
+	FlowOffloadEnabled         bool
+	FlowOffloadHardware        bool
+	FlowOffloadHostInterfaces  []*regexp.Regexp
+	FlowOffloadExcludeSelector string
+
+	// TCPolicyOffloadInterfaces matches the host endpoint interfaces on which we offload simple
+	// untracked policy rules to tc flower filters; nil disables the feature.
+	TCPolicyOffloadInterfaces []*regexp.Regexp
+
+	// VRFSupportEnabled makes us program the routes of workload interfaces that are enslaved
+	// to a VRF into the VRF's table and, in iptables mode, police a VRF device with the host
+	// endpoint of its enslaved interfaces.  RulesConfig.VRFSupportEnabled enables the iptables
+	// rules for workloads in a VRF.
+	VRFSupportEnabled bool
+
+	// HostEndpointsCoverChildInterfaces extends host endpoints to the VLAN sub-interfaces and
+	// bond slaves of their interfaces, where those don't have host endpoints of their own.
+	HostEndpointsCoverChildInterfaces bool
+
+	// IptablesOtherBackendCleanupEnabled makes us remove our chains and rules from the iptables
+	// backend that we aren't using.
+	IptablesOtherBackendCleanupEnabled bool
+
+	// IptablesIPSetInlineMaxMembers is the largest IP set that we expand into CIDR matches in
+	// policy and static rules when iptables can't match on IP sets.
+	IptablesIPSetInlineMaxMembers int
+
+	// DNSPolicyEnabled makes us fill the IP sets behind rules that match on destination domains,
+	// from the DNS responses passed to OnDNSResponse and from looking the domains up every
+	// DNSPolicyRefreshInterval.  It is ignored in BPF mode.
+	DNSPolicyEnabled         bool
+	DNSPolicyRefreshInterval time.Duration
+
+	// ServiceCIDRChecker, if non-nil, is told the configured service cluster IPs so that it can
+	// check them against the cluster's service CIDRs.
+	ServiceCIDRChecker *servicecidrs.Checker
+
+	// ControlPlanePriorityIfacePattern matches the uplinks on which we prioritise traffic to and
+	// from ControlPlanePriorityPorts; nil disables the feature.
+	ControlPlanePriorityIfacePattern *regexp.Regexp
+	ControlPlanePriorityPorts        []config.ProtoPort
+
+	// AutoHostEndpointInterfaces matches the host interfaces that the implicit host endpoint
+	// applies to.
+	AutoHostEndpointInterfaces []*regexp.Regexp
+	// HostEndpointsFollowExpectedIPs makes an all-interfaces host endpoint that lists expected
+	// IPs active only while one of them is present on the host.
+	HostEndpointsFollowExpectedIPs bool
+
+	// EgressGatewayRouteTableIndices holds the routing table index for each of the
+	// RulesConfig.EgressGatewaySteering rules.
+	EgressGatewayRouteTableIndices   []int
+	EgressGatewayRoutingRulePriority int
+
+	// EgressInterfaceRouteTableIndices holds the routing table index for each of the
+	// EgressInterfaces items; the items are ignored in BPF mode.
+	EgressInterfaces                   []config.EgressInterfaceRule
+	EgressInterfaceRouteTableIndices   []int
+	EgressInterfaceRoutingRulePriority int
+
 	BPFEnabled                         bool
 	BPFDisableUnprivileged             bool
 	BPFKubeProxyIptablesCleanupEnabled bool
@@ -168,16 +290,39 @@ type Config struct {
 	BPFDataIfacePattern                *regexp.Regexp
 	XDPEnabled                         bool
 	XDPAllowGeneric                    bool
+	XDPChainingEnabled                 bool
 	BPFConntrackTimeouts               conntrack.Timeouts
 	BPFCgroupV2                        string
 	BPFConnTimeLBEnabled               bool
 	BPFMapRepin                        bool
 	BPFNodePortDSREnabled              bool
+	BPFNATBackendSelection             string
+	BPFHostPortsEnabled                bool
 	KubeProxyMinSyncPeriod             time.Duration
 	KubeProxyEndpointSlicesEnabled     bool
 
 	SidecarAccelerationEnabled bool
 
+	// WorkloadMetricsEnabled enables per-workload traffic counters in the Prometheus metrics.
+	WorkloadMetricsEnabled bool
+	// HostEndpointPolicyCountersEnabled enables the per-host endpoint policy counters in the
+	// Prometheus metrics and the debug state.
+	HostEndpointPolicyCountersEnabled bool
+	// WorkloadTCPStatsEnabled enables per-namespace TCP socket statistics in the Prometheus
+	// metrics.
+	WorkloadTCPStatsEnabled bool
+	// CheckpointFile, if non-empty, is where the dataplane checkpoint is written when Felix shuts
+	// down and loaded from when it starts.
+	CheckpointFile string
+
+	// ApplyThrottleInterval and ApplyThrottleBurst control the throttle on applying updates to
+	// the dataplane; MaxBatchSize limits the number of calculation graph updates in each batch
+	// and ApplyDebounceInterval, if non-zero, delays each apply to gather more updates.
+	ApplyThrottleInterval time.Duration
+	ApplyThrottleBurst    int
+	MaxBatchSize          int
+	ApplyDebounceInterval time.Duration
+
 	LookPathOverride func(file string) (string, error)
 
 	KubeClientSet *kubernetes.Clientset
@@ -240,11 +385,18 @@ type InternalDataplane struct {
 	iptablesFilterTables []*iptables.Table
 	ipSets               []ipsetsDataplane
 
-	ipipManager *ipipManager
+	// ipSetInliningTables holds the tables that stand in front of the iptables tables when
+	// iptables can't match on IP sets; see staticChainTable.
+	ipSetInliningTables map[*iptables.Table]*ipSetInliningTable
+
+	ipipManager  *ipipManager
+	vxlanManager *vxlanManager
 
 	wireguardManager *wireguardManager
 
 	ifaceMonitor     *ifacemonitor.InterfaceMonitor
+	ifaceFlapDamper  *ifaceFlapDamper
+	sysctlMgr        *sysctlManager
 	ifaceUpdates     chan *ifaceUpdate
 	ifaceAddrUpdates chan *ifaceAddrsUpdate
 
@@ -266,6 +418,25 @@ type InternalDataplane struct {
 	// forceXDPRefresh is set by the XDP refresh timer to indicate that we should
 	// check the XDP state in the dataplane.
 	forceXDPRefresh bool
+	// cpuLimiter, if non-nil, defers the periodic refreshes while the CPU is busy.
+	cpuLimiter *cpuPressureLimiter
+	// backendCleaner, if non-nil, removes our rules from the iptables backend that we aren't
+	// using at start of day.
+	backendCleaner *backendCleaner
+	// domainIPSetsMgr maintains the IP sets behind destination domain rules; nil in BPF mode.
+	// domainResolver, if non-nil, looks up the domains periodically.
+	domainIPSetsMgr *domainIPSetsManager
+	domainResolver  *dnscache.Resolver
+	domainUpdates   chan *domainIPsUpdate
+	// podBandwidthWatcher, if non-nil, sends the local pods' bandwidth annotations to
+	// podBandwidthUpdates, which is nil otherwise.
+	podBandwidthWatcher *podBandwidthWatcher
+	podBandwidthUpdates chan *podBandwidthUpdate
+
+	// fabricPlaneProber, if non-nil, sends the reachability of the remote nodes' fabric plane
+	// addresses to fabricPlaneUpdates, which is nil otherwise.
+	fabricPlaneProber  *fabricPlaneProber
+	fabricPlaneUpdates chan *fabricPlaneReachabilityUpdate
 	// doneFirstApply is set after we finish the first update to the dataplane. It indicates
 	// that the dataplane should now be in sync.
 	doneFirstApply bool
@@ -273,12 +444,39 @@ type InternalDataplane struct {
 	reschedTimer *time.Timer
 	reschedC     <-chan time.Time
 
+	// routesBackingOff is set while a route table is backing off because the kernel ran out of
+	// memory for routes; we report that we're not ready until it recovers.
+	routesBackingOff bool
+
 	applyThrottle *throttle.Throttle
 
 	config Config
 
 	debugHangC <-chan time.Time
 
+	// stateDumpRequests carries requests from the debug server to dump state that is owned by
+	// the main loop.
+	stateDumpRequests chan stateDumpRequest
+	// resyncRequests carries requests from the debug server to force a full resync of the
+	// dataplane.  Requests wait in pendingResyncRequests until the next apply().
+	resyncRequests        chan resyncRequest
+	pendingResyncRequests []resyncRequest
+	resyncBaseline        map[string]int
+	// cleanupRequests carries the request to clean up the dataplane when Felix shuts down.  Once
+	// we've handled it, shutDown is set and we stop updating the dataplane.
+	cleanupRequests chan cleanupRequest
+	shutDown        bool
+	// bpfMaps holds the BPF maps that can be dumped via the debug server.
+	bpfMaps []bpf.Map
+	// hepPolicyCounters, if non-nil, reports the host endpoint policy counters via the debug
+	// server.
+	hepPolicyCounters *hepPolicyCounters
+	// tcPolicyOffload, if non-nil, reports the tc offload status of untracked policy rules via
+	// the debug server.
+	tcPolicyOffload *tcPolicyOffloadManager
+	// degradedRulesMgr reports rules that the dataplane can't render faithfully.
+	degradedRulesMgr *degradedRulesManager
+
 	xdpState          *xdpState
 	sockmapState      *sockmapState
 	endpointsSourceV4 endpointsSource
@@ -286,6 +484,9 @@ type InternalDataplane struct {
 	callbacks         *callbacks
 
 	loopSummarizer *logutils.Summarizer
+	// summaryMgr, if non-nil, collects the summary that we pass to the
+	// DataplaneSummaryCallback.
+	summaryMgr *dataplaneSummaryManager
 }
 
 const (
@@ -320,23 +521,50 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 		log.WithError(err).Error("Failed to write MTU file, pod MTU may not be properly set")
 	}
 
+	if config.ApplyThrottleInterval == 0 {
+		config.ApplyThrottleInterval = 100 * time.Millisecond
+	}
+	if config.ApplyThrottleBurst == 0 {
+		config.ApplyThrottleBurst = 10
+	}
+	if config.MaxBatchSize == 0 {
+		config.MaxBatchSize = msgPeekLimit
+	}
+
 	dp := &InternalDataplane{
 		toDataplane:      make(chan interface{}, msgPeekLimit),
 		fromDataplane:    make(chan interface{}, 100),
 		ruleRenderer:     ruleRenderer,
 		ifaceMonitor:     ifacemonitor.New(config.IfaceMonitorConfig, config.FatalErrorRestartCallback),
+		ifaceFlapDamper:  newIfaceFlapDamper(config.IfaceFlapDampingWindow, config.RulesConfig.WorkloadIfacePrefixes),
+		sysctlMgr:        newSysctlManager(config.SysctlOverrides),
 		ifaceUpdates:     make(chan *ifaceUpdate, 100),
 		ifaceAddrUpdates: make(chan *ifaceAddrsUpdate, 100),
+		domainUpdates:    make(chan *domainIPsUpdate, 1000),
 		config:           config,
-		applyThrottle:    throttle.New(10),
+		applyThrottle:    throttle.New(config.ApplyThrottleBurst),
 		loopSummarizer:   logutils.NewSummarizer("dataplane reconciliation loops"),
+
+		stateDumpRequests: make(chan stateDumpRequest),
+		resyncRequests:    make(chan resyncRequest),
+		cleanupRequests:   make(chan cleanupRequest),
 	}
 	dp.applyThrottle.Refill() // Allow the first apply() immediately.
+	dp.fromDataplane <- &proto.DataplaneCapabilities{
+		Ipv6:                   true,
+		Vxlan:                  true,
+		Wireguard:              true,
+		ApplicationLayerPolicy: true,
+	}
 	dp.ifaceMonitor.StateCallback = dp.onIfaceStateChange
 	dp.ifaceMonitor.AddrCallback = dp.onIfaceAddrsChange
 
 	backendMode := iptables.DetectBackend(config.LookPathOverride, iptables.NewRealCmd, config.IptablesBackend)
 
+	if config.BackgroundResyncMaxCPUPressure > 0 {
+		dp.cpuLimiter = newCPUPressureLimiter(config.BackgroundResyncMaxCPUPressure)
+	}
+
 	// Most iptables tables need the same options.
 	iptablesOptions := iptables.TableOptions{
 		HistoricChainPrefixes: rules.AllHistoricChainNamePrefixes,
@@ -350,6 +578,13 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 		OnStillAlive:          dp.reportHealth,
 		OpRecorder:            dp.loopSummarizer,
 	}
+	if dp.cpuLimiter != nil {
+		iptablesOptions.DeferRefresh = dp.cpuLimiter.Busy
+	}
+	for _, hook := range config.RulesConfig.IptablesHookChains {
+		// The hook chains belong to the user, the tables must not clean them up.
+		iptablesOptions.ExternalChains = append(iptablesOptions.ExternalChains, hook.Chain)
+	}
 
 	if config.BPFEnabled && config.BPFKubeProxyIptablesCleanupEnabled {
 		// If BPF-mode is enabled, clean up kube-proxy's rules too.
@@ -366,9 +601,52 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 		iptablesNATOptions.ExtraCleanupRegexPattern += "|" + rules.HistoricInsertedNATRuleRegex
 	}
 
+	// The policy and endpoint managers record where their chains came from so that an
+	// iptables-restore failure can be traced back to the policy or endpoint, and reported in the
+	// status of the endpoints that use the chain.  Chain names are the same for IPv4 and IPv6 so
+	// each IP version has its own record.
+	chainOriginsV4 := newChainOrigins()
+	chainOriginsV6 := newChainOrigins()
+	iptablesOptionsV6 := iptablesOptions
+	iptablesOptions.DescribeChain = chainOriginsV4.describe
+	iptablesOptions.OnRestoreFailure = chainOriginsV4.onRestoreFailure
+	iptablesOptionsV6.DescribeChain = chainOriginsV6.describe
+	iptablesOptionsV6.OnRestoreFailure = chainOriginsV6.onRestoreFailure
+
 	featureDetector := iptables.NewFeatureDetector(config.FeatureDetectOverrides)
 	iptablesFeatures := featureDetector.GetFeatures()
 
+	// In iptables mode, policy normally matches on IP sets.  If iptables can't do that, fall back
+	// to expanding the IP sets into CIDR matches.
+	ipSetMatchSupported := config.BPFEnabled || featureDetector.IPSetMatchSupported(config.LookPathOverride, backendMode)
+	if !ipSetMatchSupported {
+		msg := "iptables can't match on IP sets (is the xt_set kernel module missing?); expanding " +
+			"IP sets in policy into CIDR matches, which only works for small IP sets"
+		log.Warn(msg)
+		alerts.Raise(alerts.ConditionIPSetMatchUnavailable, alerts.SeverityWarning, "", msg)
+	}
+	degradedRules := newDegradedRules(config.DegradedRulesCallback)
+	if !config.BPFEnabled && !iptablesFeatures.MASQFullyRandom && !config.RulesConfig.NATOutgoingPreservePorts {
+		degradedRules.set("natOutgoing", "iptables or the kernel doesn't support --random-fully so "+
+			"NAT outgoing doesn't randomise source ports")
+	}
+	if config.BPFEnabled && failsafesUseInterfaces(config.RulesConfig.FailsafeInboundHostPorts,
+		config.RulesConfig.FailsafeOutboundHostPorts) {
+		degradedRules.set("failsafePorts", "interface-qualified failsafe ports aren't supported in "+
+			"BPF mode so they're open on all interfaces")
+	}
+	degradedRules.report()
+	newPolicyMgr := func(rawTable, mangleTable, filterTable iptablesTable, ipVersion uint8, origins *chainOrigins) Manager {
+		policyMgr := newPolicyManager(rawTable, mangleTable, filterTable, ruleRenderer, ipVersion)
+		policyMgr.chainOrigins = origins
+		if ipSetMatchSupported {
+			return policyMgr
+		}
+		inliningMgr := newIPSetInliningPolicyManager(policyMgr, ipVersion, config.IptablesIPSetInlineMaxMembers)
+		inliningMgr.degradedRules = degradedRules
+		return inliningMgr
+	}
+
 	var iptablesLock sync.Locker
 	if iptablesFeatures.RestoreSupportsLock {
 		log.Debug("Calico implementation of iptables lock disabled (because detected version of " +
@@ -419,6 +697,15 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 		iptablesLock,
 		featureDetector,
 		iptablesOptions)
+	if config.IptablesOtherBackendCleanupEnabled {
+		ipVersions := []uint8{4}
+		if config.IPv6Enabled {
+			ipVersions = append(ipVersions, 6)
+		}
+		dp.backendCleaner = newBackendCleaner(backendMode, ipVersions, iptablesLock, featureDetector,
+			iptablesOptions)
+	}
+
 	ipSetsConfigV4 := config.RulesConfig.IPSetConfigV4
 	ipSetsV4 := ipsets.NewIPSets(ipSetsConfigV4, dp.loopSummarizer)
 	dp.iptablesNATTables = append(dp.iptablesNATTables, natTableV4)
@@ -426,21 +713,46 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 	dp.iptablesMangleTables = append(dp.iptablesMangleTables, mangleTableV4)
 	dp.iptablesFilterTables = append(dp.iptablesFilterTables, filterTableV4)
 	dp.ipSets = append(dp.ipSets, ipSetsV4)
+	newIPSetInliningTables := func(ipSets ipsetsDataplane, ipSetsConfig *ipsets.IPVersionConfig, ipVersion uint8, tables ...*iptables.Table) {
+		if ipSetMatchSupported {
+			return
+		}
+		if dp.ipSetInliningTables == nil {
+			dp.ipSetInliningTables = map[*iptables.Table]*ipSetInliningTable{}
+		}
+		for _, t := range tables {
+			dp.ipSetInliningTables[t] = newIPSetInliningTable(t, ruleRenderer, ipSets, ipSetsConfig,
+				ipVersion, config.IptablesIPSetInlineMaxMembers, degradedRules)
+		}
+	}
+	newIPSetInliningTables(ipSetsV4, ipSetsConfigV4, 4, rawTableV4, mangleTableV4, natTableV4, filterTableV4)
 
 	if config.RulesConfig.VXLANEnabled {
 		routeTableVXLAN := routetable.New([]string{"^vxlan.calico$"}, 4, true, config.NetlinkTimeout,
 			config.DeviceRouteSourceAddress, config.DeviceRouteProtocol, true, 0,
 			dp.loopSummarizer)
 
-		vxlanManager := newVXLANManager(
+		var prober planeProber
+		if len(config.VXLANFabricPlanes) > 0 && config.VXLANFabricPlaneProbePort != 0 {
+			dp.fabricPlaneUpdates = make(chan *fabricPlaneReachabilityUpdate, 100)
+			dp.fabricPlaneProber = newFabricPlaneProber(
+				config.VXLANFabricPlaneProbePort,
+				config.VXLANFabricPlaneProbeInterval,
+				config.VXLANFabricPlaneProbeTimeout,
+				dp.fabricPlaneUpdates,
+			)
+			prober = dp.fabricPlaneProber
+		}
+		dp.vxlanManager = newVXLANManager(
 			ipSetsV4,
 			routeTableVXLAN,
 			"vxlan.calico",
 			config,
 			dp.loopSummarizer,
+			prober,
 		)
-		go vxlanManager.KeepVXLANDeviceInSync(config.VXLANMTU, iptablesFeatures.ChecksumOffloadBroken, 10*time.Second)
-		dp.RegisterManager(vxlanManager)
+		go dp.vxlanManager.KeepVXLANDeviceInSync(config.VXLANMTU, iptablesFeatures.ChecksumOffloadBroken, 10*time.Second)
+		dp.RegisterManager(dp.vxlanManager)
 	} else {
 		cleanUpVXLANDevice()
 	}
@@ -453,7 +765,7 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 		if err := bpf.SupportsXDP(); err != nil {
 			log.WithError(err).Warn("Can't enable XDP acceleration.")
 		} else {
-			st, err := NewXDPState(config.XDPAllowGeneric)
+			st, err := NewXDPState(config.XDPAllowGeneric, config.XDPChainingEnabled)
 			if err != nil {
 				log.WithError(err).Warn("Can't enable XDP acceleration.")
 			} else {
@@ -469,7 +781,7 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 
 	// TODO Integrate XDP and BPF infra.
 	if !config.BPFEnabled && dp.xdpState == nil {
-		xdpState, err := NewXDPState(config.XDPAllowGeneric)
+		xdpState, err := NewXDPState(config.XDPAllowGeneric, config.XDPChainingEnabled)
 		if err == nil {
 			if err := xdpState.WipeXDP(); err != nil {
 				log.WithError(err).Warn("Failed to cleanup preexisting XDP state")
@@ -520,7 +832,7 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 			rules.IPSetIDThisHostIPs,
 			ipSetsV4,
 			config.MaxIPSetSize))
-		dp.RegisterManager(newPolicyManager(rawTableV4, mangleTableV4, filterTableV4, ruleRenderer, 4))
+		dp.RegisterManager(newPolicyMgr(rawTableV4, mangleTableV4, filterTableV4, 4, chainOriginsV4))
 
 		// Clean up any leftover BPF state.
 		err := nat.RemoveConnectTimeLoadBalancer("")
@@ -600,6 +912,7 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 			config.Hostname,
 			fibLookupEnabled,
 			config.RulesConfig.EndpointToHostAction,
+			config.RulesConfig.MulticastEnabled,
 			config.BPFDataIfacePattern,
 			workloadIfaceRegex,
 			ipSetIDAllocator,
@@ -632,6 +945,11 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 		if err != nil {
 			log.WithError(err).Panic("Failed to create NAT backend affinity BPF map.")
 		}
+		backendSelectionMap := nat.SelectionMap(bpfMapContext)
+		err = backendSelectionMap.EnsureExists()
+		if err != nil {
+			log.WithError(err).Panic("Failed to create NAT backend selection BPF map.")
+		}
 
 		routeMap := routes.Map(bpfMapContext)
 		err = routeMap.EnsureExists()
@@ -645,6 +963,9 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 			log.WithError(err).Panic("Failed to create conntrack BPF map.")
 		}
 
+		dp.bpfMaps = append(dp.bpfMaps, ipSetsMap, arpMap, failsafesMap, frontendMap, backendMap,
+			backendAffinityMap, backendSelectionMap, routeMap, ctMap)
+
 		conntrackScanner := conntrack.NewScanner(ctMap,
 			conntrack.NewLivenessScanner(config.BPFConntrackTimeouts, config.BPFNodePortDSREnabled))
 
@@ -665,6 +986,13 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 			bpfproxyOpts = append(bpfproxyOpts, bpfproxy.WithDSREnabled())
 		}
 
+		bpfproxyOpts = append(bpfproxyOpts,
+			bpfproxy.WithBackendSelection(config.BPFNATBackendSelection, backendSelectionMap))
+
+		if config.BPFHostPortsEnabled {
+			bpfproxyOpts = append(bpfproxyOpts, bpfproxy.WithHostPorts())
+		}
+
 		if config.KubeClientSet != nil {
 			// We have a Kubernetes connection, start watching services and populating the NAT maps.
 			kp, err := bpfproxy.StartKubeProxy(
@@ -689,7 +1017,8 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 
 		if config.BPFConnTimeLBEnabled {
 			// Activate the connect-time load balancer.
-			err = nat.InstallConnectTimeLoadBalancer(frontendMap, backendMap, routeMap, config.BPFCgroupV2, config.BPFLogLevel)
+			err = nat.InstallConnectTimeLoadBalancer(frontendMap, backendMap, backendAffinityMap,
+				backendSelectionMap, routeMap, config.BPFCgroupV2, config.BPFLogLevel)
 			if err != nil {
 				log.WithError(err).Panic("BPFConnTimeLBEnabled but failed to attach connect-time load balancer, bailing out.")
 			}
@@ -705,6 +1034,9 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 	routeTableV4 := routetable.New(interfaceRegexes, 4, false, config.NetlinkTimeout,
 		config.DeviceRouteSourceAddress, config.DeviceRouteProtocol, config.RemoveExternalRoutes, 0,
 		dp.loopSummarizer)
+	if config.VRFSupportEnabled {
+		routeTableV4.EnableVRFTables()
+	}
 
 	epManager := newEndpointManager(
 		rawTableV4,
@@ -716,20 +1048,116 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 		epMarkMapper,
 		config.RulesConfig.KubeIPVSSupportEnabled,
 		config.RulesConfig.WorkloadIfacePrefixes,
+		config.AutoHostEndpointInterfaces,
+		config.WorkloadExtraRoutes,
+		config.WorkloadConnectionLimits,
 		dp.endpointStatusCombiner.OnEndpointStatusUpdate,
+		dp.sysctlMgr.SetSysctl,
 		config.BPFEnabled,
 		bpfEndpointManager,
 		callbacks)
+	epManager.chainOrigins = chainOriginsV4
+	epManager.hostEpsFollowExpectedIPs = config.HostEndpointsFollowExpectedIPs
 	dp.RegisterManager(epManager)
+	dp.RegisterManager(dp.sysctlMgr)
+	if len(config.RulesConfig.RPFModeOverrides) > 0 {
+		dp.RegisterManager(newRPFManager(
+			config.RulesConfig.RPFModeOverrides,
+			config.RulesConfig.WorkloadIfacePrefixes,
+			dp.sysctlMgr.SetSysctl,
+		))
+	}
+	if config.RulesConfig.MulticastEnabled && (config.MulticastIGMPVersion > 0 || config.MulticastMLDVersion > 0) {
+		mldVersion := 0
+		if config.IPv6Enabled {
+			mldVersion = config.MulticastMLDVersion
+		}
+		dp.RegisterManager(newMulticastManager(
+			config.RulesConfig.WorkloadIfacePrefixes,
+			config.MulticastIGMPVersion,
+			mldVersion,
+			dp.sysctlMgr.SetSysctl,
+		))
+	}
 	dp.endpointsSourceV4 = epManager
+	hepCounterSources := []hepPolicyCounterSource{{ipVersion: 4, jumps: epManager, counters: filterTableV4}}
+	if config.WorkloadMetricsEnabled {
+		workloadMetrics := newWorkloadMetricsManager()
+		dp.RegisterManager(workloadMetrics)
+		prometheus.MustRegister(workloadMetrics)
+	}
+	if config.WorkloadTCPStatsEnabled {
+		tcpStats := newTCPStatsManager()
+		dp.RegisterManager(tcpStats)
+		prometheus.MustRegister(tcpStats)
+	}
 	dp.RegisterManager(newFloatingIPManager(natTableV4, ruleRenderer, 4))
-	dp.RegisterManager(newMasqManager(ipSetsV4, natTableV4, ruleRenderer, config.MaxIPSetSize, 4))
+	dp.RegisterManager(newMasqManager(ipSetsV4, dp.staticChainTable(natTableV4), ruleRenderer, config.MaxIPSetSize, 4))
 	if config.RulesConfig.IPIPEnabled {
 		// Add a manger to keep the all-hosts IP set up to date.
 		dp.ipipManager = newIPIPManager(ipSetsV4, config.MaxIPSetSize, config.ExternalNodesCidrs)
 		dp.RegisterManager(dp.ipipManager) // IPv4-only
 	}
 
+	if len(config.EgressGatewayRouteTableIndices) > 0 {
+		dp.RegisterManager(newEgressGatewayManagerFromConfig(config, dp.loopSummarizer)) // IPv4-only
+	}
+
+	if len(config.EgressInterfaceRouteTableIndices) > 0 {
+		dp.RegisterManager(newEgressInterfaceManagerFromConfig(config, dp.loopSummarizer)) // IPv4-only
+	}
+
+	// Registered even if there are no rules so that it removes the entries of old rules.  Handles
+	// both IP versions.
+	dp.RegisterManager(newProxyNeighManager(config.WorkloadProxyNeighbors, config.IPv6Enabled))
+
+	if config.WorkloadBandwidthLimitsEnabled {
+		// Handles both IP versions.
+		dp.RegisterManager(newBandwidthManager(
+			config.RulesConfig.WorkloadIfacePrefixes,
+			config.WorkloadBandwidthLimits,
+			newRealCmd,
+		))
+		if config.KubeClientSet != nil {
+			dp.podBandwidthUpdates = make(chan *podBandwidthUpdate, 100)
+			dp.podBandwidthWatcher = newPodBandwidthWatcher(config.KubeClientSet, config.Hostname,
+				dp.podBandwidthUpdates)
+		} else {
+			log.Info("No Kubernetes client available, bandwidth limits will only come from " +
+				"WorkloadBandwidthLimits.")
+		}
+	}
+
+	if config.FlowOffloadEnabled {
+		// Handles both IP versions.
+		flowOffloadMgr := newFlowOffloadManager(config.FlowOffloadHardware, config.FlowOffloadHostInterfaces,
+			config.FlowOffloadExcludeSelector, newRealCmd)
+		dp.RegisterManager(flowOffloadMgr)
+		if err := prometheus.Register(flowOffloadMgr); err != nil {
+			log.WithError(err).Warn("Failed to register flow offload metrics")
+		}
+	} else {
+		removeFlowOffloadTable(newRealCmd)
+	}
+
+	if len(config.TCPolicyOffloadInterfaces) > 0 {
+		// Handles both IP versions.
+		dp.tcPolicyOffload = newTCPolicyOffloadManager(config.TCPolicyOffloadInterfaces,
+			config.RulesConfig.FailsafeInboundHostPorts, newRealCmd)
+		dp.RegisterManager(dp.tcPolicyOffload)
+	}
+
+	if config.ServiceCIDRChecker != nil {
+		// Handles both IP versions.
+		dp.RegisterManager(newServiceCIDRCheckManager(config.ServiceCIDRChecker))
+	}
+
+	if config.ControlPlanePriorityIfacePattern != nil {
+		// Handles both IP versions.
+		dp.RegisterManager(newControlPlanePriorityManager(config.ControlPlanePriorityIfacePattern,
+			config.ControlPlanePriorityPorts, config.IPv6Enabled, newRealCmd))
+	}
+
 	// Add a manager for wireguard configuration. This is added irrespective of whether wireguard is actually enabled
 	// because it may need to tidy up some of the routing rules when disabled.
 	cryptoRouteTableWireguard := wireguard.New(config.Hostname, &config.Wireguard, config.NetlinkTimeout,
@@ -754,7 +1182,7 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 			rules.RuleHashPrefix,
 			iptablesLock,
 			featureDetector,
-			iptablesOptions,
+			iptablesOptionsV6,
 		)
 		natTableV6 := iptables.NewTable(
 			"nat",
@@ -770,7 +1198,7 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 			rules.RuleHashPrefix,
 			iptablesLock,
 			featureDetector,
-			iptablesOptions,
+			iptablesOptionsV6,
 		)
 		filterTableV6 := iptables.NewTable(
 			"filter",
@@ -778,7 +1206,7 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 			rules.RuleHashPrefix,
 			iptablesLock,
 			featureDetector,
-			iptablesOptions,
+			iptablesOptionsV6,
 		)
 
 		ipSetsConfigV6 := config.RulesConfig.IPSetConfigV6
@@ -788,11 +1216,15 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 		dp.iptablesRawTables = append(dp.iptablesRawTables, rawTableV6)
 		dp.iptablesMangleTables = append(dp.iptablesMangleTables, mangleTableV6)
 		dp.iptablesFilterTables = append(dp.iptablesFilterTables, filterTableV6)
+		newIPSetInliningTables(ipSetsV6, ipSetsConfigV6, 6, rawTableV6, mangleTableV6, natTableV6, filterTableV6)
 
 		routeTableV6 := routetable.New(
 			interfaceRegexes, 6, false, config.NetlinkTimeout,
 			config.DeviceRouteSourceAddress, config.DeviceRouteProtocol, config.RemoveExternalRoutes, 0,
 			dp.loopSummarizer)
+		if config.VRFSupportEnabled {
+			routeTableV6.EnableVRFTables()
+		}
 
 		if !config.BPFEnabled {
 			dp.RegisterManager(newIPSetsManager(ipSetsV6, config.MaxIPSetSize))
@@ -801,9 +1233,9 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 				rules.IPSetIDThisHostIPs,
 				ipSetsV6,
 				config.MaxIPSetSize))
-			dp.RegisterManager(newPolicyManager(rawTableV6, mangleTableV6, filterTableV6, ruleRenderer, 6))
+			dp.RegisterManager(newPolicyMgr(rawTableV6, mangleTableV6, filterTableV6, 6, chainOriginsV6))
 		}
-		dp.RegisterManager(newEndpointManager(
+		epManagerV6 := newEndpointManager(
 			rawTableV6,
 			mangleTableV6,
 			filterTableV6,
@@ -813,20 +1245,65 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 			epMarkMapper,
 			config.RulesConfig.KubeIPVSSupportEnabled,
 			config.RulesConfig.WorkloadIfacePrefixes,
+			config.AutoHostEndpointInterfaces,
+			config.WorkloadExtraRoutes,
+			config.WorkloadConnectionLimits,
 			dp.endpointStatusCombiner.OnEndpointStatusUpdate,
+			dp.sysctlMgr.SetSysctl,
 			config.BPFEnabled,
 			nil,
-			callbacks))
+			callbacks)
+		epManagerV6.chainOrigins = chainOriginsV6
+		epManagerV6.hostEpsFollowExpectedIPs = config.HostEndpointsFollowExpectedIPs
+		dp.RegisterManager(epManagerV6)
+		hepCounterSources = append(hepCounterSources,
+			hepPolicyCounterSource{ipVersion: 6, jumps: epManagerV6, counters: filterTableV6})
 		dp.RegisterManager(newFloatingIPManager(natTableV6, ruleRenderer, 6))
-		dp.RegisterManager(newMasqManager(ipSetsV6, natTableV6, ruleRenderer, config.MaxIPSetSize, 6))
+		dp.RegisterManager(newMasqManager(ipSetsV6, dp.staticChainTable(natTableV6), ruleRenderer, config.MaxIPSetSize, 6))
 		dp.RegisterManager(newServiceLoopManager(filterTableV6, ruleRenderer, 6))
 	}
 
+	if !config.BPFEnabled {
+		// Always maintain the domain IP sets, so that rules that match on domains have a
+		// (possibly empty) set to refer to even if the feature is disabled.
+		var onDomainsChanged func(domains []string)
+		if config.DNSPolicyEnabled {
+			dp.domainResolver = dnscache.NewResolver(config.DNSPolicyRefreshInterval, dp.OnDNSResponse)
+			onDomainsChanged = dp.domainResolver.SetDomains
+		}
+		dp.domainIPSetsMgr = newDomainIPSetsManager(dp.ipSets, config.MaxIPSetSize, onDomainsChanged)
+		dp.RegisterManager(dp.domainIPSetsMgr)
+	} else if config.DNSPolicyEnabled {
+		log.Warn("DNS policy is not supported in BPF mode, ignoring DNSPolicyEnabled.")
+	}
+
+	dp.degradedRulesMgr = newDegradedRulesManager(degradedRules, config.BPFEnabled,
+		!config.BPFEnabled && config.IPv6Enabled && len(config.RulesConfig.NAT64Prefixes) > 0)
+	dp.RegisterManager(dp.degradedRulesMgr)
+
+	if config.DataplaneSummaryCallback != nil && config.DataplaneSummaryInterval > 0 {
+		dp.summaryMgr = newDataplaneSummaryManager()
+		dp.RegisterManager(dp.summaryMgr)
+	}
+
+	if config.HostEndpointPolicyCountersEnabled && !config.BPFEnabled {
+		dp.hepPolicyCounters = newHEPPolicyCounters(hepCounterSources)
+		prometheus.MustRegister(dp.hepPolicyCounters)
+	}
+
 	dp.allIptablesTables = append(dp.allIptablesTables, dp.iptablesMangleTables...)
 	dp.allIptablesTables = append(dp.allIptablesTables, dp.iptablesNATTables...)
 	dp.allIptablesTables = append(dp.allIptablesTables, dp.iptablesFilterTables...)
 	dp.allIptablesTables = append(dp.allIptablesTables, dp.iptablesRawTables...)
 
+	// The IP set inlining tables go last so that they see the IP set updates that the other
+	// managers make.
+	for _, t := range dp.allIptablesTables {
+		if inliningTable := dp.ipSetInliningTables[t]; inliningTable != nil {
+			dp.RegisterManager(inliningTable)
+		}
+	}
+
 	// Register that we will report liveness and readiness.
 	if config.HealthAggregator != nil {
 		log.Info("Registering to report health.")
@@ -986,6 +1463,22 @@ type Manager interface {
 	CompleteDeferredWork() error
 }
 
+// routeTableWithBackoff is implemented by route table syncers that back off after the kernel runs
+// out of memory for routes.
+type routeTableWithBackoff interface {
+	BackoffRemaining() time.Duration
+}
+
+// routeTableWithContext is implemented by route table syncers that trace their netlink calls.
+type routeTableWithContext interface {
+	ApplyWithContext(ctx context.Context) error
+}
+
+// ipsetsDataplaneWithContext is implemented by IP set dataplanes that trace their ipset calls.
+type ipsetsDataplaneWithContext interface {
+	ApplyUpdatesWithContext(ctx context.Context)
+}
+
 type ManagerWithRouteTables interface {
 	Manager
 	GetRouteTableSyncers() []routeTableSyncer
@@ -1014,12 +1507,30 @@ func (d *InternalDataplane) RegisterManager(mgr Manager) {
 func (d *InternalDataplane) Start() {
 	// Do our start-of-day configuration.
 	d.doStaticDataplaneConfig()
+	// Load the checkpoint before the loop starts to process updates.
+	d.loadCheckpoint()
 
 	// Then, start the worker threads.
 	go d.loopUpdatingDataplane()
 	go d.loopReportingStatus()
 	go d.ifaceMonitor.MonitorInterfaces()
 	go d.monitorHostMTU()
+	if d.domainResolver != nil {
+		d.domainResolver.Start()
+	}
+	if d.podBandwidthWatcher != nil {
+		d.podBandwidthWatcher.Start(context.Background())
+	}
+	if d.fabricPlaneProber != nil {
+		if err := d.fabricPlaneProber.Start(context.Background()); err != nil {
+			// The VXLAN manager still fails over when a local uplink goes down.
+			log.WithError(err).Error("Failed to start fabric plane prober, remote plane " +
+				"reachability won't be checked.")
+		}
+	}
+
+	d.registerStateDumpers()
+	d.registerResyncHandler()
 }
 
 // onIfaceStateChange is our interface monitor callback.  It gets called from the monitor's thread.
@@ -1029,17 +1540,64 @@ func (d *InternalDataplane) onIfaceStateChange(ifaceName string, state ifacemoni
 		"ifIndex":   ifIndex,
 		"state":     state,
 	}).Info("Linux interface state changed.")
-	d.ifaceUpdates <- &ifaceUpdate{
+	update := &ifaceUpdate{
 		Name:  ifaceName,
 		State: state,
 		Index: ifIndex,
 	}
+	if state == ifacemonitor.StateUp && (d.config.VRFSupportEnabled || d.config.HostEndpointsCoverChildInterfaces) {
+		// Enslaving an interface cycles it down and up, so we see the change here.
+		if err := d.lookUpIfaceMasters(update); err != nil {
+			log.WithError(err).WithField("ifaceName", ifaceName).Warn("Failed to look up interface's master devices")
+		}
+	}
+	d.ifaceUpdates <- update
 }
 
 type ifaceUpdate struct {
 	Name  string
 	State ifacemonitor.State
 	Index int
+	// VRF is the name of the VRF device that the interface is enslaved to, if VRF support is
+	// enabled.
+	VRF string
+	// Parent is the name of the VLAN interface's parent interface or of the bond that the
+	// interface is enslaved to, if host endpoints cover child interfaces.
+	Parent string
+}
+
+// lookUpIfaceMasters fills in the VRF and parent of the interface, as enabled.
+func (d *InternalDataplane) lookUpIfaceMasters(update *ifaceUpdate) error {
+	link, err := netlink.LinkByName(update.Name)
+	if err != nil {
+		return err
+	}
+	attrs := link.Attrs()
+	if _, ok := link.(*netlink.Vlan); ok && d.config.HostEndpointsCoverChildInterfaces && attrs.ParentIndex != 0 {
+		parent, err := netlink.LinkByIndex(attrs.ParentIndex)
+		if err != nil {
+			return err
+		}
+		update.Parent = parent.Attrs().Name
+	}
+	if attrs.MasterIndex == 0 {
+		return nil
+	}
+	master, err := netlink.LinkByIndex(attrs.MasterIndex)
+	if err != nil {
+		return err
+	}
+	switch master.(type) {
+	case *netlink.Vrf:
+		if d.config.VRFSupportEnabled {
+			update.VRF = master.Attrs().Name
+		}
+	case *netlink.Bond:
+		if d.config.HostEndpointsCoverChildInterfaces {
+			update.Parent = master.Attrs().Name
+		}
+	}
+	return nil
 }
 
 // Check if current felix ipvs config is correct when felix gets an kube-ipvs0 interface update.
@@ -1075,6 +1633,20 @@ type ifaceAddrsUpdate struct {
 	Addrs set.Set
 }
 
+// OnDNSResponse is called, from any goroutine, with each address that a DNS response resolves a
+// domain name to.  It passes the ones for domains that are used in policy on to the main loop.
+func (d *InternalDataplane) OnDNSResponse(domain string, ip net.IP, ttl time.Duration) {
+	if d.domainIPSetsMgr == nil || !d.domainIPSetsMgr.wantsDomain(domain) {
+		return
+	}
+	select {
+	case d.domainUpdates <- &domainIPsUpdate{Domain: domain, IP: ip, TTL: ttl}:
+	default:
+		// We'll pick the address up again from the next response or lookup.
+		log.WithField("domain", domain).Debug("Dataplane busy, dropping DNS response for policy domain.")
+	}
+}
+
 func (d *InternalDataplane) SendMessage(msg interface{}) error {
 	d.toDataplane <- msg
 	return nil
@@ -1106,6 +1678,10 @@ func (d *InternalDataplane) doStaticDataplaneConfig() {
 	// Check/configure global kernel parameters.
 	d.configureKernel()
 
+	if d.backendCleaner != nil {
+		d.backendCleaner.CleanUp()
+	}
+
 	if d.config.BPFEnabled {
 		d.setUpIptablesBPF()
 	} else {
@@ -1116,6 +1692,7 @@ func (d *InternalDataplane) doStaticDataplaneConfig() {
 		log.Info("IPIP enabled, starting thread to keep tunnel configuration in sync.")
 		go d.ipipManager.KeepIPIPDeviceInSync(
 			d.config.IPIPMTU,
+			d.config.IPIPDSCP.TOS(),
 			d.config.RulesConfig.IPIPTunnelAddress,
 		)
 	} else {
@@ -1324,10 +1901,20 @@ func (d *InternalDataplane) setUpIptablesBPF() {
 	}
 }
 
+// staticChainTable returns the table to program the static chains into.  That's normally the
+// table itself but, if iptables can't match on IP sets, it's the ipSetInliningTable in front of
+// it.
+func (d *InternalDataplane) staticChainTable(t *iptables.Table) iptablesTable {
+	if inliningTable := d.ipSetInliningTables[t]; inliningTable != nil {
+		return inliningTable
+	}
+	return t
+}
+
 func (d *InternalDataplane) setUpIptablesNormal() {
 	for _, t := range d.iptablesRawTables {
 		rawChains := d.ruleRenderer.StaticRawTableChains(t.IPVersion)
-		t.UpdateChains(rawChains)
+		d.staticChainTable(t).UpdateChains(rawChains)
 		t.InsertOrAppendRules("PREROUTING", []iptables.Rule{{
 			Action: iptables.JumpAction{Target: rules.ChainRawPrerouting},
 		}})
@@ -1337,7 +1924,7 @@ func (d *InternalDataplane) setUpIptablesNormal() {
 	}
 	for _, t := range d.iptablesFilterTables {
 		filterChains := d.ruleRenderer.StaticFilterTableChains(t.IPVersion)
-		t.UpdateChains(filterChains)
+		d.staticChainTable(t).UpdateChains(filterChains)
 		t.InsertOrAppendRules("FORWARD", []iptables.Rule{{
 			Action: iptables.JumpAction{Target: rules.ChainFilterForward},
 		}})
@@ -1352,7 +1939,7 @@ func (d *InternalDataplane) setUpIptablesNormal() {
 		t.AppendRules("FORWARD", d.ruleRenderer.StaticFilterForwardAppendRules())
 	}
 	for _, t := range d.iptablesNATTables {
-		t.UpdateChains(d.ruleRenderer.StaticNATTableChains(t.IPVersion))
+		d.staticChainTable(t).UpdateChains(d.ruleRenderer.StaticNATTableChains(t.IPVersion))
 		t.InsertOrAppendRules("PREROUTING", []iptables.Rule{{
 			Action: iptables.JumpAction{Target: rules.ChainNATPrerouting},
 		}})
@@ -1364,7 +1951,7 @@ func (d *InternalDataplane) setUpIptablesNormal() {
 		}})
 	}
 	for _, t := range d.iptablesMangleTables {
-		t.UpdateChains(d.ruleRenderer.StaticMangleTableChains(t.IPVersion))
+		d.staticChainTable(t).UpdateChains(d.ruleRenderer.StaticMangleTableChains(t.IPVersion))
 		t.InsertOrAppendRules("PREROUTING", []iptables.Rule{{
 			Action: iptables.JumpAction{Target: rules.ChainManglePrerouting},
 		}})
@@ -1382,6 +1969,18 @@ func (d *InternalDataplane) setUpIptablesNormal() {
 	}
 }
 
+// failsafesUseInterfaces returns true if any of the failsafe ports is limited to an interface.
+func failsafesUseInterfaces(portLists ...[]config.ProtoPort) bool {
+	for _, ports := range portLists {
+		for _, p := range ports {
+			if p.Interface != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func stringToProtocol(protocol string) (labelindex.IPSetPortProtocol, error) {
 	switch protocol {
 	case "tcp":
@@ -1448,6 +2047,11 @@ func (d *InternalDataplane) loopUpdatingDataplane() {
 	// Retry any failed operations every 10s.
 	retryTicker := time.NewTicker(10 * time.Second)
 
+	var summaryTicks <-chan time.Time
+	if d.summaryMgr != nil {
+		summaryTicks = time.NewTicker(d.config.DataplaneSummaryInterval).C
+	}
+
 	// If configured, start tickers to refresh the IP sets and routing table entries.
 	var ipSetsRefreshC <-chan time.Time
 	if d.config.IPSetsRefreshInterval > 0 {
@@ -1469,6 +2073,20 @@ func (d *InternalDataplane) loopUpdatingDataplane() {
 		)
 		routeRefreshC = refreshTicker.C
 	}
+	var sysctlRefreshC <-chan time.Time
+	if d.config.SysctlRefreshInterval > 0 {
+		log.WithField("interval", d.config.SysctlRefreshInterval).Info(
+			"Will check sysctls on timer")
+		refreshTicker := jitter.NewTicker(
+			d.config.SysctlRefreshInterval,
+			d.config.SysctlRefreshInterval/10,
+		)
+		sysctlRefreshC = refreshTicker.C
+	}
+	var domainExpiryC <-chan time.Time
+	if d.domainIPSetsMgr != nil && d.config.DNSPolicyEnabled {
+		domainExpiryC = time.NewTicker(domainIPsExpiryInterval).C
+	}
 	var xdpRefreshC <-chan time.Time
 	if d.config.XDPRefreshInterval > 0 && d.xdpState != nil {
 		log.WithField("interval", d.config.XDPRefreshInterval).Info(
@@ -1480,19 +2098,69 @@ func (d *InternalDataplane) loopUpdatingDataplane() {
 		xdpRefreshC = refreshTicker.C
 	}
 
+	// The IP set and route refreshes may be deferred while the CPU is busy.
+	ipSetsRefresh := deferrableRefresh{name: "ipsets", maxDelay: d.config.IPSetsRefreshInterval}
+	routeRefresh := deferrableRefresh{name: "routes", maxDelay: d.config.RouteRefreshInterval}
+	doDueRefreshes := func(ipSetsRequested, routesRequested bool) {
+		now := time.Now()
+		if ipSetsRefresh.Due(d.cpuLimiter, ipSetsRequested, now) {
+			log.Debug("Refreshing IP sets state")
+			d.forceIPSetsRefresh = true
+			d.dataplaneNeedsSync = true
+		}
+		if routeRefresh.Due(d.cpuLimiter, routesRequested, now) {
+			log.Debug("Refreshing routes")
+			d.forceRouteRefresh = true
+			d.dataplaneNeedsSync = true
+		}
+	}
+
 	// Fill the apply throttle leaky bucket.
-	throttleC := jitter.NewTicker(100*time.Millisecond, 10*time.Millisecond).C
+	throttleC := jitter.NewTicker(d.config.ApplyThrottleInterval, d.config.ApplyThrottleInterval/10).C
 	beingThrottled := false
 
+	// When debouncing, we hold off applying until ApplyDebounceInterval after the first pending
+	// update, unless there's a priority update pending; see isPriorityUpdate().
+	var pendingSince time.Time
+	var debounceC <-chan time.Time
+	var prioritySince time.Time
+	// lastPriorityBypass is when a priority update last bypassed the apply throttle;
+	// priorityBypassC pops when the next one is allowed to.
+	var lastPriorityBypass time.Time
+	var priorityBypassC <-chan time.Time
+	priorityUpdatePending := func() bool {
+		return !prioritySince.IsZero()
+	}
+	debounceWait := func() time.Duration {
+		if d.config.ApplyDebounceInterval <= 0 || priorityUpdatePending() {
+			return 0
+		}
+		if pendingSince.IsZero() {
+			pendingSince = time.Now()
+		}
+		return d.config.ApplyDebounceInterval - time.Since(pendingSince)
+	}
+
 	datastoreInSync := false
+	// traceCtx is the tracing context of the calculation graph flush that we're processing, if
+	// that flush is being traced.
+	traceCtx := context.Background()
 
 	processMsgFromCalcGraph := func(msg interface{}) {
+		if batchStart, ok := msg.(*tracing.BatchStart); ok {
+			traceCtx = batchStart.Ctx
+			return
+		}
 		log.WithField("msg", proto.MsgStringer{Msg: msg}).Infof(
 			"Received %T update from calculation graph", msg)
 		d.recordMsgStat(msg)
 		for _, mgr := range d.allManagers {
 			mgr.OnUpdate(msg)
 		}
+		if datastoreInSync && isPriorityUpdate(msg) && !priorityUpdatePending() {
+			// Before we're in sync, there's no point hurrying since we can't apply anything.
+			prioritySince = time.Now()
+		}
 		switch msg.(type) {
 		case *proto.InSync:
 			log.WithField("timeSinceStart", time.Since(processStartTime)).Info(
@@ -1501,8 +2169,7 @@ func (d *InternalDataplane) loopUpdatingDataplane() {
 		}
 	}
 
-	processIfaceUpdate := func(ifaceUpdate *ifaceUpdate) {
-		log.WithField("msg", ifaceUpdate).Info("Received interface update")
+	applyIfaceUpdate := func(ifaceUpdate *ifaceUpdate) {
 		if ifaceUpdate.Name == KubeIPVSInterface {
 			d.checkIPVSConfigOnStateUpdate(ifaceUpdate.State)
 			return
@@ -1519,6 +2186,35 @@ func (d *InternalDataplane) loopUpdatingDataplane() {
 		}
 	}
 
+	processIfaceUpdate := func(ifaceUpdate *ifaceUpdate) {
+		log.WithField("msg", ifaceUpdate).Info("Received interface update")
+		if ifaceUpdate = d.ifaceFlapDamper.OnIfaceUpdate(ifaceUpdate, time.Now()); ifaceUpdate == nil {
+			// Interface is flapping, we'll apply its latest state once it settles.
+			return
+		}
+		applyIfaceUpdate(ifaceUpdate)
+	}
+
+	// Timer channel for releasing damped interface updates; nil when there's nothing to release.
+	var ifaceFlushC <-chan time.Time
+	scheduleIfaceFlush := func() {
+		ifaceFlushC = nil
+		if next, ok := d.ifaceFlapDamper.NextFlushTime(); ok {
+			ifaceFlushC = time.After(time.Until(next))
+		}
+	}
+
+	processDomainUpdate := func(upd *domainIPsUpdate) {
+		log.WithFields(log.Fields{
+			"domain": upd.Domain,
+			"ip":     upd.IP,
+			"ttl":    upd.TTL,
+		}).Debug("Received policy domain address")
+		for _, mgr := range d.allManagers {
+			mgr.OnUpdate(upd)
+		}
+	}
+
 	processAddrsUpdate := func(ifaceAddrsUpdate *ifaceAddrsUpdate) {
 		log.WithField("msg", ifaceAddrsUpdate).Info("Received interface addresses update")
 		for _, mgr := range d.allManagers {
@@ -1531,10 +2227,17 @@ func (d *InternalDataplane) loopUpdatingDataplane() {
 		case msg := <-d.toDataplane:
 			// Process the message we received, then opportunistically process any other
 			// pending messages.
+			if batchStart, ok := msg.(*tracing.BatchStart); ok {
+				// Start of a traced batch, record our processing of it in the same trace.
+				traceCtx = batchStart.Ctx
+			}
+			_, span := tracing.StartSpan(traceCtx, "dataplane.ProcessUpdates")
 			batchSize := 1
 			processMsgFromCalcGraph(msg)
+			// Stop early if we get a priority update, so that we apply it now rather than after
+			// the rest of the backlog.
 		msgLoop1:
-			for i := 0; i < msgPeekLimit; i++ {
+			for i := 0; i < d.config.MaxBatchSize && !priorityUpdatePending(); i++ {
 				select {
 				case msg := <-d.toDataplane:
 					processMsgFromCalcGraph(msg)
@@ -1546,6 +2249,8 @@ func (d *InternalDataplane) loopUpdatingDataplane() {
 			}
 			d.dataplaneNeedsSync = true
 			summaryBatchSize.Observe(float64(batchSize))
+			span.SetAttribute("batchSize", batchSize)
+			span.End()
 		case ifaceUpdate := <-d.ifaceUpdates:
 			// Process the message we received, then opportunistically process any other
 			// pending messages.
@@ -1564,6 +2269,13 @@ func (d *InternalDataplane) loopUpdatingDataplane() {
 			}
 			d.dataplaneNeedsSync = true
 			summaryIfaceBatchSize.Observe(float64(batchSize))
+			scheduleIfaceFlush()
+		case <-ifaceFlushC:
+			for _, ifaceUpdate := range d.ifaceFlapDamper.Flush(time.Now()) {
+				applyIfaceUpdate(ifaceUpdate)
+				d.dataplaneNeedsSync = true
+			}
+			scheduleIfaceFlush()
 		case ifaceAddrsUpdate := <-d.ifaceAddrUpdates:
 			batchSize := 1
 			processAddrsUpdate(ifaceAddrsUpdate)
@@ -1580,13 +2292,42 @@ func (d *InternalDataplane) loopUpdatingDataplane() {
 			}
 			summaryAddrBatchSize.Observe(float64(batchSize))
 			d.dataplaneNeedsSync = true
-		case <-ipSetsRefreshC:
-			log.Debug("Refreshing IP sets state")
-			d.forceIPSetsRefresh = true
+		case upd := <-d.domainUpdates:
+			processDomainUpdate(upd)
+		msgLoop4:
+			for i := 0; i < msgPeekLimit; i++ {
+				select {
+				case upd := <-d.domainUpdates:
+					processDomainUpdate(upd)
+				default:
+					// Channel blocked so we must be caught up.
+					break msgLoop4
+				}
+			}
+			d.dataplaneNeedsSync = true
+		case upd := <-d.podBandwidthUpdates:
+			log.WithField("msg", upd).Info("Received pod bandwidth update")
+			for _, mgr := range d.allManagers {
+				mgr.OnUpdate(upd)
+			}
+			d.dataplaneNeedsSync = true
+		case upd := <-d.fabricPlaneUpdates:
+			log.WithField("msg", upd).Info("Received fabric plane reachability update")
+			for _, mgr := range d.allManagers {
+				mgr.OnUpdate(upd)
+			}
 			d.dataplaneNeedsSync = true
+		case <-domainExpiryC:
+			// The domain IP sets manager expires stale addresses when it's next asked to
+			// complete its work.
+			d.dataplaneNeedsSync = true
+		case <-ipSetsRefreshC:
+			doDueRefreshes(true, false)
 		case <-routeRefreshC:
-			log.Debug("Refreshing routes")
-			d.forceRouteRefresh = true
+			doDueRefreshes(false, true)
+		case <-sysctlRefreshC:
+			log.Debug("Checking sysctls")
+			d.sysctlMgr.QueueResync()
 			d.dataplaneNeedsSync = true
 		case <-xdpRefreshC:
 			log.Debug("Refreshing XDP")
@@ -1599,18 +2340,53 @@ func (d *InternalDataplane) loopUpdatingDataplane() {
 			d.reschedC = nil
 		case <-throttleC:
 			d.applyThrottle.Refill()
+		case <-debounceC:
+			debounceC = nil
+		case <-priorityBypassC:
+			priorityBypassC = nil
 		case <-healthTicks:
 			d.reportHealth()
+		case <-summaryTicks:
+			d.config.DataplaneSummaryCallback(d.summaryMgr.summary(time.Now()))
 		case <-retryTicker.C:
+			// Retry any refreshes that we deferred.
+			doDueRefreshes(false, false)
+		case req := <-d.stateDumpRequests:
+			req.result <- req.dump()
+		case req := <-d.resyncRequests:
+			d.queueFullResync(req)
+		case req := <-d.cleanupRequests:
+			d.cleanUp(req.mode)
+			close(req.done)
 		case <-d.debugHangC:
 			log.Warning("Debug hang simulation timer popped, hanging the dataplane!!")
 			time.Sleep(1 * time.Hour)
 			log.Panic("Woke up after 1 hour, something's probably wrong with the test.")
 		}
 
-		if datastoreInSync && d.dataplaneNeedsSync {
-			// Dataplane is out-of-sync, check if we're throttled.
-			if d.applyThrottle.Admit() {
+		if datastoreInSync && d.dataplaneNeedsSync && !d.shutDown {
+			if wait := debounceWait(); wait > 0 {
+				// Still gathering updates into this batch.
+				if debounceC == nil {
+					debounceC = time.After(wait)
+				}
+				continue
+			}
+			// Dataplane is out-of-sync, check if we're throttled.  Priority updates bypass the
+			// throttle, but only once per minPriorityApplyInterval so that a stream of them
+			// can't make us apply continuously.
+			admitted := d.applyThrottle.Admit()
+			if !admitted && priorityUpdatePending() {
+				if wait := minPriorityApplyInterval - time.Since(lastPriorityBypass); wait > 0 {
+					if priorityBypassC == nil {
+						priorityBypassC = time.After(wait)
+					}
+				} else {
+					admitted = true
+					lastPriorityBypass = time.Now()
+				}
+			}
+			if admitted {
 				if beingThrottled && d.applyThrottle.WouldAdmit() {
 					log.Info("Dataplane updates no longer throttled")
 					beingThrottled = false
@@ -1619,7 +2395,13 @@ func (d *InternalDataplane) loopUpdatingDataplane() {
 				applyStart := time.Now()
 
 				// Actually apply the changes to the dataplane.
-				d.apply()
+				d.apply(traceCtx)
+				traceCtx = context.Background()
+				pendingSince = time.Time{}
+				if priorityUpdatePending() {
+					summaryPriorityApplyLatency.Observe(time.Since(prioritySince).Seconds())
+					prioritySince = time.Time{}
+				}
 
 				// Record stats.
 				applyTime := time.Since(applyStart)
@@ -1629,9 +2411,16 @@ func (d *InternalDataplane) loopUpdatingDataplane() {
 					// Dataplane is still dirty, record an error.
 					countDataplaneSyncErrors.Inc()
 				}
+				if d.summaryMgr != nil {
+					d.summaryMgr.recordApply(applyTime, d.dataplaneNeedsSync)
+				}
 
 				d.loopSummarizer.EndOfIteration(applyTime)
 
+				if len(d.pendingResyncRequests) > 0 {
+					d.completeResyncRequests()
+				}
+
 				if !d.doneFirstApply {
 					log.WithField(
 						"secsSinceStart", time.Since(processStartTime).Seconds(),
@@ -1664,16 +2453,20 @@ func (d *InternalDataplane) configureKernel() {
 	mp := newModProbe(moduleConntrackSCTP, newRealCmd)
 	out, err := mp.Exec()
 	log.WithError(err).WithField("output", out).Infof("attempted to modprobe %s", moduleConntrackSCTP)
+	if !d.config.BPFEnabled && !sctpConntrackSupported() {
+		log.Warn("The kernel's conntrack doesn't support SCTP; SCTP traffic will be dropped.")
+		d.degradedRulesMgr.sctpUnsupported = true
+	}
 
 	log.Info("Making sure IPv4 forwarding is enabled.")
-	err = writeProcSys("/proc/sys/net/ipv4/ip_forward", "1")
+	err = d.sysctlMgr.SetSysctl("/proc/sys/net/ipv4/ip_forward", "1")
 	if err != nil {
 		log.WithError(err).Error("Failed to set IPv4 forwarding sysctl")
 	}
 
 	if d.config.IPv6Enabled {
 		log.Info("Making sure IPv6 forwarding is enabled.")
-		err = writeProcSys("/proc/sys/net/ipv6/conf/all/forwarding", "1")
+		err = d.sysctlMgr.SetSysctl("/proc/sys/net/ipv6/conf/all/forwarding", "1")
 		if err != nil {
 			log.WithError(err).Error("Failed to set IPv6 forwarding sysctl")
 		}
@@ -1681,7 +2474,7 @@ func (d *InternalDataplane) configureKernel() {
 
 	if d.config.BPFEnabled && d.config.BPFDisableUnprivileged {
 		log.Info("BPF enabled, disabling unprivileged BPF usage.")
-		err := writeProcSys("/proc/sys/kernel/unprivileged_bpf_disabled", "1")
+		err := d.sysctlMgr.SetSysctl("/proc/sys/kernel/unprivileged_bpf_disabled", "1")
 		if err != nil {
 			log.WithError(err).Error("Failed to set unprivileged_bpf_disabled sysctl")
 		}
@@ -1699,11 +2492,14 @@ func (d *InternalDataplane) recordMsgStat(msg interface{}) {
 	countMessages.WithLabelValues(typeName).Inc()
 }
 
-func (d *InternalDataplane) apply() {
+func (d *InternalDataplane) apply(traceCtx context.Context) {
 	// Update sequencing is important here because iptables rules have dependencies on ipsets.
 	// Creating a rule that references an unknown IP set fails, as does deleting an IP set that
 	// is in use.
 
+	ctx, span := tracing.StartSpan(traceCtx, "dataplane.Apply")
+	defer span.End()
+
 	// Unset the needs-sync flag, we'll set it again if something fails.
 	d.dataplaneNeedsSync = false
 
@@ -1713,7 +2509,11 @@ func (d *InternalDataplane) apply() {
 	// begins its dataplane programming updates.
 	for _, mgr := range d.allManagers {
 		if handler, ok := mgr.(UpdateBatchResolver); ok {
+			_, mgrSpan := tracing.StartSpan(ctx, "dataplane.ResolveUpdateBatch")
+			mgrSpan.SetAttribute("manager", reflect.TypeOf(mgr).String())
 			err := handler.ResolveUpdateBatch()
+			mgrSpan.RecordError(err)
+			mgrSpan.End()
 			if err != nil {
 				log.WithField("manager", reflect.TypeOf(mgr).Name()).WithError(err).Debug(
 					"couldn't resolve update batch for manager, will try again later")
@@ -1725,7 +2525,11 @@ func (d *InternalDataplane) apply() {
 
 	// Now allow managers to complete the dataplane programming updates that they need.
 	for _, mgr := range d.allManagers {
+		_, mgrSpan := tracing.StartSpan(ctx, "dataplane.CompleteDeferredWork")
+		mgrSpan.SetAttribute("manager", reflect.TypeOf(mgr).String())
 		err := mgr.CompleteDeferredWork()
+		mgrSpan.RecordError(err)
+		mgrSpan.End()
 		if err != nil {
 			log.WithField("manager", reflect.TypeOf(mgr).Name()).WithError(err).Debug(
 				"couldn't complete deferred work for manager, will try again later")
@@ -1789,7 +2593,13 @@ func (d *InternalDataplane) apply() {
 	for _, ipSets := range d.ipSets {
 		ipSetsWG.Add(1)
 		go func(ipSets ipsetsDataplane) {
-			ipSets.ApplyUpdates()
+			ipSetsCtx, ipSetsSpan := tracing.StartSpan(ctx, "ipsets.ApplyUpdates")
+			if tracedIPSets, ok := ipSets.(ipsetsDataplaneWithContext); ok {
+				tracedIPSets.ApplyUpdatesWithContext(ipSetsCtx)
+			} else {
+				ipSets.ApplyUpdates()
+			}
+			ipSetsSpan.End()
 			d.reportHealth()
 			ipSetsWG.Done()
 		}(ipSets)
@@ -1798,11 +2608,35 @@ func (d *InternalDataplane) apply() {
 	// Update the routing table in parallel with the other updates.  We'll wait for it to finish
 	// before we return.
 	var routesWG sync.WaitGroup
+	var routesBackoffMutex sync.Mutex
+	var routesBackoff time.Duration
 	for _, r := range d.routeTableSyncers() {
 		routesWG.Add(1)
 		go func(r routeTableSyncer) {
-			err := r.Apply()
-			if err != nil {
+			routesCtx, routesSpan := tracing.StartSpan(ctx, "routetable.Apply")
+			routesSpan.SetAttribute("syncer", reflect.TypeOf(r).String())
+			var err error
+			if tracedRoutes, ok := r.(routeTableWithContext); ok {
+				err = tracedRoutes.ApplyWithContext(routesCtx)
+			} else {
+				err = r.Apply()
+			}
+			routesSpan.RecordError(err)
+			routesSpan.End()
+			var backoff time.Duration
+			if rb, ok := r.(routeTableWithBackoff); ok && err != nil {
+				backoff = rb.BackoffRemaining()
+			}
+			if backoff > 0 {
+				// The kernel is out of memory for routes; rather than retrying straight
+				// away, reschedule when the route table's backoff expires.
+				log.WithError(err).Warn("Failed to synchronize routing table, backing off...")
+				routesBackoffMutex.Lock()
+				if routesBackoff == 0 || backoff < routesBackoff {
+					routesBackoff = backoff
+				}
+				routesBackoffMutex.Unlock()
+			} else if err != nil {
 				log.Warn("Failed to synchronize routing table, will retry...")
 				d.dataplaneNeedsSync = true
 			}
@@ -1817,17 +2651,25 @@ func (d *InternalDataplane) apply() {
 	// Update iptables, this should sever any references to now-unused IP sets.
 	var reschedDelayMutex sync.Mutex
 	var reschedDelay time.Duration
+	var iptablesFailed bool
 	var iptablesWG sync.WaitGroup
 	for _, t := range d.allIptablesTables {
 		iptablesWG.Add(1)
 		go func(t *iptables.Table) {
-			tableReschedAfter := t.Apply()
+			tableCtx, tableSpan := tracing.StartSpan(ctx, "iptables.Apply")
+			tableSpan.SetAttribute("table", t.Name)
+			tableSpan.SetAttribute("ipVersion", t.IPVersion)
+			tableReschedAfter := t.ApplyWithContext(tableCtx)
+			tableSpan.End()
 
 			reschedDelayMutex.Lock()
 			defer reschedDelayMutex.Unlock()
 			if tableReschedAfter != 0 && (reschedDelay == 0 || tableReschedAfter < reschedDelay) {
 				reschedDelay = tableReschedAfter
 			}
+			if t.HasHandledRestoreFailure() {
+				iptablesFailed = true
+			}
 			d.reportHealth()
 			iptablesWG.Done()
 		}(t)
@@ -1838,7 +2680,9 @@ func (d *InternalDataplane) apply() {
 	for _, ipSets := range d.ipSets {
 		ipSetsWG.Add(1)
 		go func(s ipsetsDataplane) {
+			_, ipSetsSpan := tracing.StartSpan(ctx, "ipsets.ApplyDeletions")
 			s.ApplyDeletions()
+			ipSetsSpan.End()
 			d.reportHealth()
 			ipSetsWG.Done()
 		}(ipSets)
@@ -1847,9 +2691,14 @@ func (d *InternalDataplane) apply() {
 
 	// Wait for the route updates to finish.
 	routesWG.Wait()
+	d.routesBackingOff = routesBackoff != 0
+	if d.routesBackingOff && (reschedDelay == 0 || routesBackoff < reschedDelay) {
+		reschedDelay = routesBackoff
+	}
 
-	// And publish and status updates.
-	d.endpointStatusCombiner.Apply()
+	// And publish and status updates.  The endpoints' latest policy generations only count as
+	// programmed if everything succeeded.
+	d.endpointStatusCombiner.Apply(!d.dataplaneNeedsSync && !d.routesBackingOff && !iptablesFailed)
 
 	// Set up any needed rescheduling kick.
 	if d.reschedC != nil {
@@ -1923,7 +2772,7 @@ func (d *InternalDataplane) reportHealth() {
 	if d.config.HealthAggregator != nil {
 		d.config.HealthAggregator.Report(
 			healthName,
-			&health.HealthReport{Live: true, Ready: d.doneFirstApply},
+			&health.HealthReport{Live: true, Ready: d.doneFirstApply && !d.routesBackingOff},
 		)
 	}
 }
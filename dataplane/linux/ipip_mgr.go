@@ -15,7 +15,9 @@
 package intdataplane
 
 import (
+	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -49,6 +51,11 @@ type ipipManager struct {
 
 	// Configured list of external node ip cidr's to be added to the ipset.
 	externalNodeCIDRs []string
+
+	// deviceSyncLock is held by the IPIP thread while it configures the device;
+	// deviceSyncStopped stops the thread.  See StopDeviceSync.
+	deviceSyncLock    sync.Mutex
+	deviceSyncStopped bool
 }
 
 func newIPIPManager(
@@ -81,10 +88,17 @@ func newIPIPManagerWithShim(
 
 // KeepIPIPDeviceInSync is a goroutine that configures the IPIP tunnel device, then periodically
 // checks that it is still correctly configured.
-func (d *ipipManager) KeepIPIPDeviceInSync(mtu int, address net.IP) {
+func (d *ipipManager) KeepIPIPDeviceInSync(mtu int, tos uint8, address net.IP) {
 	log.Info("IPIP thread started.")
 	for {
-		err := d.configureIPIPDevice(mtu, address)
+		d.deviceSyncLock.Lock()
+		if d.deviceSyncStopped {
+			d.deviceSyncLock.Unlock()
+			log.Info("IPIP thread stopped.")
+			return
+		}
+		err := d.configureIPIPDevice(mtu, tos, address)
+		d.deviceSyncLock.Unlock()
 		if err != nil {
 			log.WithError(err).Warn("Failed configure IPIP tunnel device, retrying...")
 			time.Sleep(1 * time.Second)
@@ -94,10 +108,25 @@ func (d *ipipManager) KeepIPIPDeviceInSync(mtu int, address net.IP) {
 	}
 }
 
+// StopDeviceSync stops the IPIP thread, waiting for any update that it's making to finish, so that
+// it doesn't put back what the caller is about to remove.
+func (d *ipipManager) StopDeviceSync() {
+	d.deviceSyncLock.Lock()
+	defer d.deviceSyncLock.Unlock()
+	d.deviceSyncStopped = true
+}
+
+// RemoveDeviceAddresses removes the addresses of the IPIP tunnel device.  The device itself can't
+// be removed while the ipip kernel module is loaded.
+func (d *ipipManager) RemoveDeviceAddresses() error {
+	return d.setLinkAddressV4("tunl0", nil)
+}
+
 // configureIPIPDevice ensures the IPIP tunnel device is up and configures correctly.
-func (d *ipipManager) configureIPIPDevice(mtu int, address net.IP) error {
+func (d *ipipManager) configureIPIPDevice(mtu int, tos uint8, address net.IP) error {
 	logCxt := log.WithFields(log.Fields{
 		"mtu":        mtu,
+		"tos":        tos,
 		"tunnelAddr": address,
 	})
 	logCxt.Debug("Configuring IPIP tunnel")
@@ -137,6 +166,18 @@ func (d *ipipManager) configureIPIPDevice(mtu int, address net.IP) error {
 		}
 		logCxt.Info("Set tunnel admin up")
 	}
+	if iptun, ok := link.(*netlink.Iptun); ok && iptun.Tos != tos {
+		logCxt.WithField("oldTOS", iptun.Tos).Info("Tunnel device TOS needs to be updated")
+		tosArg := fmt.Sprintf("0x%02x", tos)
+		if tos == 1 {
+			tosArg = "inherit"
+		}
+		if err := d.dataplane.RunCmd("ip", "tunnel", "change", "tunl0", "mode", "ipip", "tos", tosArg); err != nil {
+			log.WithError(err).Warn("Failed to set tunnel device TOS")
+			return err
+		}
+		logCxt.Info("Updated tunnel TOS")
+	}
 
 	if err := d.setLinkAddressV4("tunl0", address); err != nil {
 		log.WithError(err).Warn("Failed to set tunnel device IP")
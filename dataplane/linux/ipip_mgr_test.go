@@ -63,7 +63,7 @@ var _ = Describe("IpipMgr (tunnel configuration)", func() {
 		}
 
 		BeforeEach(func() {
-			err = ipipMgr.configureIPIPDevice(1400, ip)
+			err = ipipMgr.configureIPIPDevice(1400, 0, ip)
 			Expect(err).ToNot(HaveOccurred())
 		})
 
@@ -84,7 +84,7 @@ var _ = Describe("IpipMgr (tunnel configuration)", func() {
 		Describe("after second call with same params", func() {
 			BeforeEach(func() {
 				dataplane.ResetCalls()
-				err := ipipMgr.configureIPIPDevice(1400, ip)
+				err := ipipMgr.configureIPIPDevice(1400, 0, ip)
 				Expect(err).ToNot(HaveOccurred())
 			})
 			It("should avoid creating the interface", func() {
@@ -104,7 +104,7 @@ var _ = Describe("IpipMgr (tunnel configuration)", func() {
 		Describe("after second call with different params", func() {
 			BeforeEach(func() {
 				dataplane.ResetCalls()
-				err = ipipMgr.configureIPIPDevice(1500, ip2)
+				err = ipipMgr.configureIPIPDevice(1500, 0, ip2)
 				Expect(err).ToNot(HaveOccurred())
 
 			})
@@ -126,7 +126,7 @@ var _ = Describe("IpipMgr (tunnel configuration)", func() {
 		Describe("after second call with nil IP", func() {
 			BeforeEach(func() {
 				dataplane.ResetCalls()
-				err := ipipMgr.configureIPIPDevice(1500, nil)
+				err := ipipMgr.configureIPIPDevice(1500, 0, nil)
 				Expect(err).ToNot(HaveOccurred())
 			})
 			It("should avoid creating the interface", func() {
@@ -146,7 +146,7 @@ var _ = Describe("IpipMgr (tunnel configuration)", func() {
 
 	Describe("after calling configureIPIPDevice with no IP", func() {
 		BeforeEach(func() {
-			err := ipipMgr.configureIPIPDevice(1400, nil)
+			err := ipipMgr.configureIPIPDevice(1400, 0, nil)
 			Expect(err).ToNot(HaveOccurred())
 		})
 
@@ -164,12 +164,34 @@ var _ = Describe("IpipMgr (tunnel configuration)", func() {
 		})
 	})
 
+	Describe("with an existing tunnel device", func() {
+		BeforeEach(func() {
+			dataplane.iptunLink = &netlink.Iptun{LinkAttrs: netlink.LinkAttrs{Name: "tunl0", MTU: 1400}}
+			dataplane.tunnelLinkAttrs = &dataplane.iptunLink.LinkAttrs
+		})
+
+		It("should set a fixed TOS", func() {
+			Expect(ipipMgr.configureIPIPDevice(1400, 46<<2, nil)).To(Succeed())
+			Expect(dataplane.iptunLink.Tos).To(Equal(uint8(46 << 2)))
+		})
+
+		It("should set the TOS to inherit", func() {
+			Expect(ipipMgr.configureIPIPDevice(1400, 1, nil)).To(Succeed())
+			Expect(dataplane.iptunLink.Tos).To(Equal(uint8(1)))
+		})
+
+		It("should leave a correct TOS alone", func() {
+			Expect(ipipMgr.configureIPIPDevice(1400, 0, nil)).To(Succeed())
+			Expect(dataplane.RunCmdCalled).To(BeFalse())
+		})
+	})
+
 	// Cover the error cases.  We pass the error back up the stack, check that that happens
 	// for all calls.
 	const expNumCalls = 8
 	It("a successful call should only call into dataplane expected number of times", func() {
 		// This spec is a sanity-check that we've got the expNumCalls constant correct.
-		err := ipipMgr.configureIPIPDevice(1400, ip)
+		err := ipipMgr.configureIPIPDevice(1400, 0, ip)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(dataplane.NumCalls).To(BeNumerically("==", expNumCalls))
 	})
@@ -184,7 +206,7 @@ var _ = Describe("IpipMgr (tunnel configuration)", func() {
 			})
 
 			It("should return the error", func() {
-				Expect(ipipMgr.configureIPIPDevice(1400, ip)).To(Equal(mockFailure))
+				Expect(ipipMgr.configureIPIPDevice(1400, 0, ip)).To(Equal(mockFailure))
 			})
 
 			Describe("with an IP to remove", func() {
@@ -195,7 +217,7 @@ var _ = Describe("IpipMgr (tunnel configuration)", func() {
 						})
 				})
 				It("should return the error", func() {
-					Expect(ipipMgr.configureIPIPDevice(1400, ip)).To(Equal(mockFailure))
+					Expect(ipipMgr.configureIPIPDevice(1400, 0, ip)).To(Equal(mockFailure))
 				})
 			})
 		})
@@ -347,7 +369,9 @@ var _ = Describe("ipipManager IP set updates", func() {
 type mockIPIPDataplane struct {
 	tunnelLink      *mockLink
 	tunnelLinkAttrs *netlink.LinkAttrs
-	addrs           []netlink.Addr
+	// iptunLink, if set, is returned in place of tunnelLink so that the tunnel's TOS is visible.
+	iptunLink *netlink.Iptun
+	addrs     []netlink.Addr
 
 	RunCmdCalled     bool
 	LinkSetMTUCalled bool
@@ -382,6 +406,9 @@ func (d *mockIPIPDataplane) LinkByName(name string) (netlink.Link, error) {
 	}
 
 	Expect(name).To(Equal("tunl0"))
+	if d.iptunLink != nil {
+		return d.iptunLink, nil
+	}
 	if d.tunnelLink == nil {
 		return nil, notFound
 	}
@@ -444,6 +471,17 @@ func (d *mockIPIPDataplane) RunCmd(name string, args ...string) error {
 	}
 	log.WithFields(log.Fields{"name": name, "args": args}).Info("RunCmd called")
 	Expect(name).To(Equal("ip"))
+	if d.iptunLink != nil {
+		Expect(args[:6]).To(Equal([]string{"tunnel", "change", "tunl0", "mode", "ipip", "tos"}))
+		switch args[6] {
+		case "inherit":
+			d.iptunLink.Tos = 1
+		default:
+			_, err := fmt.Sscanf(args[6], "0x%x", &d.iptunLink.Tos)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		return nil
+	}
 	Expect(args).To(Equal([]string{"tunnel", "add", "tunl0", "mode", "ipip"}))
 
 	if d.tunnelLink == nil {
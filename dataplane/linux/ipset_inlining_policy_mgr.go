@@ -0,0 +1,399 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/alerts"
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+	"github.com/projectcalico/libcalico-go/lib/set"
+)
+
+// ipSetInliningPolicyManager stands in front of the policyManager when iptables can't match on
+// IP sets (see iptables.FeatureDetector.IPSetMatchSupported).  It tracks the members of the IP
+// sets and passes the policies and profiles on with their IP set matches replaced by the
+// equivalent CIDR matches, re-rendering them when the members change.
+//
+// That only makes sense for small sets so named port sets, and sets with more than maxMembers
+// members of our IP version, aren't expanded; nor are the domain sets behind destination domain
+// matches, whose members only the dataplane knows.  A rule that uses such a set fails closed: deny
+// and pass rules are rendered as deny rules without the set match, so that they deny more traffic,
+// and other rules are left out.  Such policies and profiles are recorded in degradedRules.
+type ipSetInliningPolicyManager struct {
+	policyMgr  Manager
+	ipVersion  uint8
+	maxMembers int
+
+	ipSets   map[string]*inlinedIPSet
+	policies map[proto.PolicyID]*proto.Policy
+	profiles map[proto.ProfileID]*proto.Profile
+
+	dirtyIPSets   set.Set
+	dirtyPolicies set.Set
+	dirtyProfiles set.Set
+
+	degradedRules *degradedRules
+}
+
+type inlinedIPSet struct {
+	members   set.Set
+	namedPort bool
+}
+
+func newIPSetInliningPolicyManager(policyMgr Manager, ipVersion uint8, maxMembers int) *ipSetInliningPolicyManager {
+	return &ipSetInliningPolicyManager{
+		policyMgr:  policyMgr,
+		ipVersion:  ipVersion,
+		maxMembers: maxMembers,
+
+		ipSets:   map[string]*inlinedIPSet{},
+		policies: map[proto.PolicyID]*proto.Policy{},
+		profiles: map[proto.ProfileID]*proto.Profile{},
+
+		dirtyIPSets:   set.New(),
+		dirtyPolicies: set.New(),
+		dirtyProfiles: set.New(),
+	}
+}
+
+func (m *ipSetInliningPolicyManager) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *proto.IPSetUpdate:
+		s := &inlinedIPSet{
+			members:   set.New(),
+			namedPort: msg.Type == proto.IPSetUpdate_IP_AND_PORT,
+		}
+		for _, member := range msg.Members {
+			s.members.Add(member)
+		}
+		m.ipSets[msg.Id] = s
+		m.dirtyIPSets.Add(msg.Id)
+	case *proto.IPSetDeltaUpdate:
+		s := m.ipSets[msg.Id]
+		if s == nil {
+			log.WithField("setID", msg.Id).Warn("Delta update for unknown IP set, ignoring")
+			return
+		}
+		for _, member := range msg.RemovedMembers {
+			s.members.Discard(member)
+		}
+		for _, member := range msg.AddedMembers {
+			s.members.Add(member)
+		}
+		m.dirtyIPSets.Add(msg.Id)
+	case *proto.IPSetRemove:
+		delete(m.ipSets, msg.Id)
+		m.dirtyIPSets.Add(msg.Id)
+	case *proto.ActivePolicyUpdate:
+		m.policies[*msg.Id] = msg.Policy
+		m.dirtyPolicies.Add(*msg.Id)
+	case *proto.ActivePolicyRemove:
+		delete(m.policies, *msg.Id)
+		m.dirtyPolicies.Discard(*msg.Id)
+		m.degradedRules.clear(m.policyDegradedSubject(*msg.Id))
+		m.policyMgr.OnUpdate(msg)
+	case *proto.ActiveProfileUpdate:
+		m.profiles[*msg.Id] = msg.Profile
+		m.dirtyProfiles.Add(*msg.Id)
+	case *proto.ActiveProfileRemove:
+		delete(m.profiles, *msg.Id)
+		m.dirtyProfiles.Discard(*msg.Id)
+		m.degradedRules.clear(m.profileDegradedSubject(*msg.Id))
+		m.policyMgr.OnUpdate(msg)
+	}
+}
+
+func (m *ipSetInliningPolicyManager) CompleteDeferredWork() error {
+	if m.dirtyIPSets.Len() > 0 {
+		for id, policy := range m.policies {
+			if rulesUseIPSets(policy.InboundRules, m.dirtyIPSets) || rulesUseIPSets(policy.OutboundRules, m.dirtyIPSets) {
+				m.dirtyPolicies.Add(id)
+			}
+		}
+		for id, profile := range m.profiles {
+			if rulesUseIPSets(profile.InboundRules, m.dirtyIPSets) || rulesUseIPSets(profile.OutboundRules, m.dirtyIPSets) {
+				m.dirtyProfiles.Add(id)
+			}
+		}
+		m.dirtyIPSets = set.New()
+	}
+
+	m.dirtyPolicies.Iter(func(item interface{}) error {
+		id := item.(proto.PolicyID)
+		policy := *m.policies[id]
+		leftOver := set.New()
+		policy.InboundRules = m.inlineRules(policy.InboundRules, leftOver)
+		policy.OutboundRules = m.inlineRules(policy.OutboundRules, leftOver)
+		m.degradedRules.set(m.policyDegradedSubject(id), degradedIPSetsIssue(leftOver))
+		m.policyMgr.OnUpdate(&proto.ActivePolicyUpdate{Id: &id, Policy: &policy})
+		return set.RemoveItem
+	})
+	m.dirtyProfiles.Iter(func(item interface{}) error {
+		id := item.(proto.ProfileID)
+		profile := *m.profiles[id]
+		leftOver := set.New()
+		profile.InboundRules = m.inlineRules(profile.InboundRules, leftOver)
+		profile.OutboundRules = m.inlineRules(profile.OutboundRules, leftOver)
+		m.degradedRules.set(m.profileDegradedSubject(id), degradedIPSetsIssue(leftOver))
+		m.policyMgr.OnUpdate(&proto.ActiveProfileUpdate{Id: &id, Profile: &profile})
+		return set.RemoveItem
+	})
+	m.degradedRules.report()
+
+	return m.policyMgr.CompleteDeferredWork()
+}
+
+func (m *ipSetInliningPolicyManager) policyDegradedSubject(id proto.PolicyID) string {
+	return fmt.Sprintf("policy %s/%s (IPv%d)", id.Tier, id.Name, m.ipVersion)
+}
+
+func (m *ipSetInliningPolicyManager) profileDegradedSubject(id proto.ProfileID) string {
+	return fmt.Sprintf("profile %s (IPv%d)", id.Name, m.ipVersion)
+}
+
+// degradedIPSetsIssue describes the IP sets that rules couldn't be rendered with, or returns ""
+// if there are none.
+func degradedIPSetsIssue(leftOver set.Set) string {
+	if leftOver.Len() == 0 {
+		return ""
+	}
+	var setIDs []string
+	leftOver.Iter(func(item interface{}) error {
+		setIDs = append(setIDs, item.(string))
+		return nil
+	})
+	sort.Strings(setIDs)
+	return fmt.Sprintf("iptables can't match on IP sets and IP sets %s can't be expanded inline; "+
+		"deny and pass rules that use them deny more traffic and other rules that use them are left out",
+		strings.Join(setIDs, ", "))
+}
+
+// inlineRules returns the inlined rules, adding the IDs of the IP sets that couldn't be expanded
+// to leftOver.
+func (m *ipSetInliningPolicyManager) inlineRules(rules []*proto.Rule, leftOver set.Set) []*proto.Rule {
+	var out []*proto.Rule
+	for _, rule := range rules {
+		if rule = m.inlineRule(rule, leftOver); rule != nil {
+			out = append(out, rule)
+		}
+	}
+	return out
+}
+
+// inlineRule returns a copy of the rule with its IP set matches replaced by CIDR matches, or nil
+// if the rule can never match or has to be left out.  The IDs of the IP sets that couldn't be
+// expanded are added to allLeftOver.
+func (m *ipSetInliningPolicyManager) inlineRule(pRule *proto.Rule, allLeftOver set.Set) *proto.Rule {
+	if !ruleUsesIPSets(pRule) {
+		return pRule
+	}
+	ruleCopy := *pRule
+	var leftOver []string
+	var matchesNothing bool
+
+	ruleCopy.SrcNet, leftOver, matchesNothing = m.inlinePositiveIPSets(pRule.SrcNet, pRule.SrcIpSetIds, leftOver)
+	if matchesNothing {
+		return nil
+	}
+	ruleCopy.DstNet, leftOver, matchesNothing = m.inlinePositiveIPSets(pRule.DstNet, pRule.DstIpSetIds, leftOver)
+	if matchesNothing {
+		return nil
+	}
+	ruleCopy.NotSrcNet, leftOver = m.inlineNegatedIPSets(pRule.NotSrcNet, pRule.NotSrcIpSetIds, leftOver)
+	ruleCopy.NotDstNet, leftOver = m.inlineNegatedIPSets(pRule.NotDstNet, pRule.NotDstIpSetIds, leftOver)
+	leftOver = append(leftOver, pRule.SrcNamedPortIpSetIds...)
+	leftOver = append(leftOver, pRule.DstNamedPortIpSetIds...)
+	leftOver = append(leftOver, pRule.NotSrcNamedPortIpSetIds...)
+	leftOver = append(leftOver, pRule.NotDstNamedPortIpSetIds...)
+	if len(pRule.DstDomains) > 0 {
+		// The domain sets are filled in by the dataplane so we never see their members.
+		leftOver = append(leftOver, rules.DomainIPSetID(pRule.DstDomains))
+	}
+
+	ruleCopy.SrcIpSetIds = nil
+	ruleCopy.DstIpSetIds = nil
+	ruleCopy.NotSrcIpSetIds = nil
+	ruleCopy.NotDstIpSetIds = nil
+	ruleCopy.SrcNamedPortIpSetIds = nil
+	ruleCopy.DstNamedPortIpSetIds = nil
+	ruleCopy.NotSrcNamedPortIpSetIds = nil
+	ruleCopy.NotDstNamedPortIpSetIds = nil
+	ruleCopy.DstDomains = nil
+
+	if len(leftOver) == 0 {
+		return &ruleCopy
+	}
+	for _, setID := range leftOver {
+		allLeftOver.Add(setID)
+		msg := fmt.Sprintf("IP set %s can't be expanded inline because it is too big, is a named port "+
+			"set or holds domain addresses, and iptables can't match on IP sets; rules that use it "+
+			"aren't enforced correctly", setID)
+		log.WithFields(log.Fields{
+			"setID":      setID,
+			"ruleID":     pRule.RuleId,
+			"action":     pRule.Action,
+			"maxMembers": m.maxMembers,
+		}).Warn(msg)
+		alerts.Raise(alerts.ConditionIPSetMatchUnavailable, alerts.SeverityWarning, setID, msg)
+	}
+	switch pRule.Action {
+	case "deny", "pass":
+		// Leaving out a pass rule would let the traffic that it matches fall through to the
+		// later rules in the tier, so deny it instead.
+		ruleCopy.Action = "deny"
+		return &ruleCopy
+	}
+	return nil
+}
+
+// inlinePositiveIPSets returns the CIDRs that are in all of the given IP sets, and the given CIDRs,
+// if there are any.  The IDs of the sets that can't be expanded are appended to leftOver.
+func (m *ipSetInliningPolicyManager) inlinePositiveIPSets(
+	nets []string,
+	setIDs []string,
+	leftOver []string,
+) (outNets []string, outLeftOver []string, matchesNothing bool) {
+	if len(setIDs) == 0 {
+		return nets, leftOver, false
+	}
+	var cidrs []ip.CIDR
+	constrained := false
+	if len(nets) > 0 {
+		cidrs = m.parseCIDRs(nets)
+		constrained = true
+	}
+	for _, setID := range setIDs {
+		members, ok := m.members(setID)
+		if !ok {
+			leftOver = append(leftOver, setID)
+			continue
+		}
+		if !constrained {
+			cidrs = members
+			constrained = true
+		} else {
+			cidrs = ip.IntersectCIDRs(cidrs, members)
+		}
+	}
+	if !constrained {
+		return nil, leftOver, false
+	}
+	if len(cidrs) == 0 {
+		return nil, leftOver, true
+	}
+	return cidrStrings(cidrs), leftOver, false
+}
+
+// inlineNegatedIPSets adds the members of the given IP sets to the negated CIDRs.  The IDs of the
+// sets that can't be expanded are appended to leftOver.
+func (m *ipSetInliningPolicyManager) inlineNegatedIPSets(
+	nets []string,
+	setIDs []string,
+	leftOver []string,
+) ([]string, []string) {
+	var cidrs []ip.CIDR
+	for _, setID := range setIDs {
+		members, ok := m.members(setID)
+		if !ok {
+			leftOver = append(leftOver, setID)
+			continue
+		}
+		cidrs = append(cidrs, members...)
+	}
+	if len(cidrs) == 0 {
+		return nets, leftOver
+	}
+	return append(append([]string(nil), nets...), cidrStrings(cidrs)...), leftOver
+}
+
+// members returns the members of the IP set that are of our IP version, or false if the set
+// can't be expanded.
+func (m *ipSetInliningPolicyManager) members(setID string) ([]ip.CIDR, bool) {
+	s := m.ipSets[setID]
+	if s == nil || s.namedPort {
+		return nil, false
+	}
+	var members []string
+	s.members.Iter(func(item interface{}) error {
+		members = append(members, item.(string))
+		return nil
+	})
+	cidrs := m.parseCIDRs(members)
+	if len(cidrs) > m.maxMembers {
+		return nil, false
+	}
+	return cidrs, true
+}
+
+func (m *ipSetInliningPolicyManager) parseCIDRs(nets []string) []ip.CIDR {
+	var cidrs []ip.CIDR
+	for _, n := range nets {
+		cidr, err := ip.ParseCIDROrIP(n)
+		if err != nil || cidr.Version() != m.ipVersion {
+			continue
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs
+}
+
+// cidrStrings returns the sorted, de-duplicated string forms of the CIDRs so that the rendered
+// rules, and hence their hashes, are stable.
+func cidrStrings(cidrs []ip.CIDR) []string {
+	seen := set.New()
+	var out []string
+	for _, cidr := range cidrs {
+		s := cidr.String()
+		if seen.Contains(s) {
+			continue
+		}
+		seen.Add(s)
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func ruleUsesIPSets(rule *proto.Rule) bool {
+	return len(rule.SrcIpSetIds) > 0 || len(rule.DstIpSetIds) > 0 ||
+		len(rule.NotSrcIpSetIds) > 0 || len(rule.NotDstIpSetIds) > 0 ||
+		len(rule.SrcNamedPortIpSetIds) > 0 || len(rule.DstNamedPortIpSetIds) > 0 ||
+		len(rule.NotSrcNamedPortIpSetIds) > 0 || len(rule.NotDstNamedPortIpSetIds) > 0 ||
+		len(rule.DstDomains) > 0
+}
+
+func rulesUseIPSets(rules []*proto.Rule, setIDs set.Set) bool {
+	for _, rule := range rules {
+		for _, ids := range [][]string{
+			rule.SrcIpSetIds, rule.DstIpSetIds, rule.NotSrcIpSetIds, rule.NotDstIpSetIds,
+			rule.SrcNamedPortIpSetIds, rule.DstNamedPortIpSetIds,
+			rule.NotSrcNamedPortIpSetIds, rule.NotDstNamedPortIpSetIds,
+		} {
+			for _, id := range ids {
+				if setIDs.Contains(id) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
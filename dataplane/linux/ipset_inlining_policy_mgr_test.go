@@ -0,0 +1,196 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/proto"
+)
+
+var _ = Describe("IP set inlining policy manager", func() {
+	var (
+		mgr       *ipSetInliningPolicyManager
+		policyMgr *recordingManager
+		polID     = proto.PolicyID{Tier: "default", Name: "pol1"}
+	)
+
+	BeforeEach(func() {
+		policyMgr = &recordingManager{}
+		mgr = newIPSetInliningPolicyManager(policyMgr, 4, 3)
+		mgr.OnUpdate(&proto.IPSetUpdate{
+			Id:      "s:small",
+			Members: []string{"10.0.0.2", "10.0.0.1", "fd00::1"},
+			Type:    proto.IPSetUpdate_IP,
+		})
+		mgr.OnUpdate(&proto.IPSetUpdate{
+			Id:      "s:nets",
+			Members: []string{"10.0.0.0/24"},
+			Type:    proto.IPSetUpdate_NET,
+		})
+		mgr.OnUpdate(&proto.IPSetUpdate{
+			Id:      "s:big",
+			Members: []string{"10.0.1.1", "10.0.1.2", "10.0.1.3", "10.0.1.4"},
+			Type:    proto.IPSetUpdate_IP,
+		})
+		mgr.OnUpdate(&proto.IPSetUpdate{
+			Id:      "n:port",
+			Members: []string{"10.0.0.1,tcp:80"},
+			Type:    proto.IPSetUpdate_IP_AND_PORT,
+		})
+	})
+
+	sendPolicy := func(rules ...*proto.Rule) {
+		mgr.OnUpdate(&proto.ActivePolicyUpdate{
+			Id:     &polID,
+			Policy: &proto.Policy{InboundRules: rules},
+		})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+	}
+
+	lastRules := func() []*proto.Rule {
+		Expect(policyMgr.updates).NotTo(BeEmpty())
+		upd := policyMgr.updates[len(policyMgr.updates)-1].(*proto.ActivePolicyUpdate)
+		Expect(*upd.Id).To(Equal(polID))
+		return upd.Policy.InboundRules
+	}
+
+	It("should replace a source IP set with its members of the right IP version", func() {
+		sendPolicy(&proto.Rule{Action: "allow", SrcIpSetIds: []string{"s:small"}})
+		Expect(lastRules()).To(Equal([]*proto.Rule{
+			{Action: "allow", SrcNet: []string{"10.0.0.1/32", "10.0.0.2/32"}},
+		}))
+	})
+
+	It("should intersect IP sets with each other and with the CIDRs", func() {
+		sendPolicy(
+			&proto.Rule{Action: "allow", DstIpSetIds: []string{"s:nets", "s:small"}},
+			&proto.Rule{Action: "allow", DstNet: []string{"10.0.0.1"}, DstIpSetIds: []string{"s:nets"}},
+		)
+		Expect(lastRules()).To(Equal([]*proto.Rule{
+			{Action: "allow", DstNet: []string{"10.0.0.1/32", "10.0.0.2/32"}},
+			{Action: "allow", DstNet: []string{"10.0.0.1/32"}},
+		}))
+	})
+
+	It("should drop a rule whose IP sets don't overlap", func() {
+		sendPolicy(&proto.Rule{Action: "allow", SrcNet: []string{"192.168.0.0/16"}, SrcIpSetIds: []string{"s:small"}})
+		Expect(lastRules()).To(BeEmpty())
+	})
+
+	It("should add negated IP sets to the negated CIDRs", func() {
+		sendPolicy(&proto.Rule{Action: "allow", NotSrcNet: []string{"10.1.0.0/16"}, NotSrcIpSetIds: []string{"s:nets"}})
+		Expect(lastRules()).To(Equal([]*proto.Rule{
+			{Action: "allow", NotSrcNet: []string{"10.1.0.0/16", "10.0.0.0/24"}},
+		}))
+	})
+
+	It("should leave out allow rules that use sets that are too big", func() {
+		sendPolicy(&proto.Rule{Action: "allow", SrcIpSetIds: []string{"s:big"}})
+		Expect(lastRules()).To(BeEmpty())
+	})
+
+	It("should render deny rules without the sets that can't be expanded", func() {
+		sendPolicy(&proto.Rule{
+			Action:               "deny",
+			SrcIpSetIds:          []string{"s:big"},
+			DstIpSetIds:          []string{"s:nets"},
+			DstNamedPortIpSetIds: []string{"n:port"},
+		})
+		Expect(lastRules()).To(Equal([]*proto.Rule{
+			{Action: "deny", DstNet: []string{"10.0.0.0/24"}},
+		}))
+	})
+
+	It("should render pass rules that use sets that can't be expanded as deny rules", func() {
+		sendPolicy(&proto.Rule{
+			Action:      "pass",
+			SrcIpSetIds: []string{"s:big"},
+			DstNet:      []string{"10.0.2.0/24"},
+		})
+		Expect(lastRules()).To(Equal([]*proto.Rule{
+			{Action: "deny", DstNet: []string{"10.0.2.0/24"}},
+		}))
+	})
+
+	It("should leave out allow rules that match on destination domains", func() {
+		sendPolicy(&proto.Rule{Action: "allow", DstDomains: []string{"example.com"}})
+		Expect(lastRules()).To(BeEmpty())
+	})
+
+	It("should re-render policy when an IP set changes", func() {
+		sendPolicy(&proto.Rule{Action: "allow", SrcIpSetIds: []string{"s:small"}})
+		policyMgr.updates = nil
+
+		mgr.OnUpdate(&proto.IPSetDeltaUpdate{
+			Id:             "s:small",
+			AddedMembers:   []string{"10.0.0.3"},
+			RemovedMembers: []string{"10.0.0.1"},
+		})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(lastRules()).To(Equal([]*proto.Rule{
+			{Action: "allow", SrcNet: []string{"10.0.0.2/32", "10.0.0.3/32"}},
+		}))
+
+		// Unrelated sets shouldn't trigger a re-render.
+		policyMgr.updates = nil
+		mgr.OnUpdate(&proto.IPSetDeltaUpdate{Id: "s:nets", AddedMembers: []string{"10.0.5.0/24"}})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(policyMgr.updates).To(BeEmpty())
+	})
+
+	It("should report the policies that it can't render as written", func() {
+		var reported []string
+		mgr.degradedRules = newDegradedRules(func(issues []string) {
+			reported = issues
+		})
+		sendPolicy(
+			&proto.Rule{Action: "deny", SrcIpSetIds: []string{"s:big"}},
+			&proto.Rule{Action: "allow", SrcIpSetIds: []string{"s:small"}},
+		)
+		Expect(reported).To(HaveLen(1))
+		Expect(reported[0]).To(HavePrefix("policy default/pol1 (IPv4): "))
+		Expect(reported[0]).To(ContainSubstring("s:big"))
+		Expect(reported[0]).NotTo(ContainSubstring("s:small"))
+
+		mgr.OnUpdate(&proto.ActivePolicyRemove{Id: &polID})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(reported).To(BeEmpty())
+	})
+
+	It("should pass removes straight through", func() {
+		sendPolicy(&proto.Rule{Action: "allow", SrcIpSetIds: []string{"s:small"}})
+		mgr.OnUpdate(&proto.ActivePolicyRemove{Id: &polID})
+		Expect(policyMgr.updates[len(policyMgr.updates)-1]).To(Equal(&proto.ActivePolicyRemove{Id: &polID}))
+
+		policyMgr.updates = nil
+		mgr.OnUpdate(&proto.IPSetRemove{Id: "s:small"})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(policyMgr.updates).To(BeEmpty())
+	})
+})
+
+type recordingManager struct {
+	updates []interface{}
+}
+
+func (m *recordingManager) OnUpdate(msg interface{}) {
+	m.updates = append(m.updates, msg)
+}
+
+func (m *recordingManager) CompleteDeferredWork() error {
+	return nil
+}
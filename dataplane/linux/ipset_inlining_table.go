@@ -0,0 +1,207 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/iptables"
+	"github.com/projectcalico/felix/rules"
+)
+
+// ipSetInliningTable stands in front of an iptables table when iptables can't match on IP sets.
+// ipSetInliningPolicyManager deals with policy; this deals with the static chains and the NAT
+// outgoing chain, which match on the IP sets that the dataplane maintains itself (host IPs, IP
+// pools and so on).  It passes chains on with their IP set matches replaced by matches on the
+// sets' members (see rules.RuleRenderer.InlineIPSetMatches) and, as a Manager, re-renders them
+// when the members change.  It should be registered after the managers that update the IP sets.
+//
+// Sets that don't exist yet or that have more than maxMembers members can't be inlined; the
+// chains that use them are recorded in degradedRules.  The host IP sets are exempt from the
+// limit: the IPIP and VXLAN allow rules in the INPUT chain use them and they are followed by rules
+// that drop all other tunnel traffic, so leaving them out would cut the node off from the others
+// once the cluster grew past maxMembers nodes.
+type ipSetInliningTable struct {
+	iptablesTable
+	ruleRenderer rules.RuleRenderer
+	ipSets       ipsetsDataplane
+	ipSetConfig  *ipsets.IPVersionConfig
+	ipVersion    uint8
+	maxMembers   int
+
+	// chains holds the chains that use IP sets, as they were passed to us.
+	chains map[string]*iptables.Chain
+	// chainIPSets holds the names of the IP sets that each of those chains uses.
+	chainIPSets map[string][]string
+	// renderedMembers holds, for each IP set that we've rendered, a summary of its members at the
+	// time.
+	renderedMembers map[string]string
+
+	degradedRules *degradedRules
+}
+
+// uncappedIPSetIDs holds the IDs of the IP sets that are inlined however big they are.
+var uncappedIPSetIDs = map[string]bool{
+	rules.IPSetIDAllHostNets:        true,
+	rules.IPSetIDAllVXLANSourceNets: true,
+}
+
+func newIPSetInliningTable(
+	table iptablesTable,
+	ruleRenderer rules.RuleRenderer,
+	ipSets ipsetsDataplane,
+	ipSetConfig *ipsets.IPVersionConfig,
+	ipVersion uint8,
+	maxMembers int,
+	degradedRules *degradedRules,
+) *ipSetInliningTable {
+	return &ipSetInliningTable{
+		iptablesTable: table,
+		ruleRenderer:  ruleRenderer,
+		ipSets:        ipSets,
+		ipSetConfig:   ipSetConfig,
+		ipVersion:     ipVersion,
+		maxMembers:    maxMembers,
+
+		chains:          map[string]*iptables.Chain{},
+		chainIPSets:     map[string][]string{},
+		renderedMembers: map[string]string{},
+
+		degradedRules: degradedRules,
+	}
+}
+
+func (t *ipSetInliningTable) UpdateChain(chain *iptables.Chain) {
+	t.iptablesTable.UpdateChain(t.inlineChain(chain))
+}
+
+func (t *ipSetInliningTable) UpdateChains(chains []*iptables.Chain) {
+	var inlined []*iptables.Chain
+	for _, chain := range chains {
+		inlined = append(inlined, t.inlineChain(chain))
+	}
+	t.iptablesTable.UpdateChains(inlined)
+}
+
+func (t *ipSetInliningTable) RemoveChains(chains []*iptables.Chain) {
+	for _, chain := range chains {
+		t.forgetChain(chain.Name)
+	}
+	t.iptablesTable.RemoveChains(chains)
+}
+
+func (t *ipSetInliningTable) RemoveChainByName(name string) {
+	t.forgetChain(name)
+	t.iptablesTable.RemoveChainByName(name)
+}
+
+func (t *ipSetInliningTable) OnUpdate(msg interface{}) {
+}
+
+// CompleteDeferredWork re-renders the chains that use IP sets whose members have changed.
+func (t *ipSetInliningTable) CompleteDeferredWork() error {
+	changedIPSets := map[string]bool{}
+	for setName, summary := range t.renderedMembers {
+		if _, newSummary := t.members(setName); newSummary != summary {
+			changedIPSets[setName] = true
+		}
+	}
+	if len(changedIPSets) > 0 {
+		var chains []*iptables.Chain
+		for name, setNames := range t.chainIPSets {
+			for _, setName := range setNames {
+				if changedIPSets[setName] {
+					chains = append(chains, t.chains[name])
+					break
+				}
+			}
+		}
+		sort.Slice(chains, func(i, j int) bool {
+			return chains[i].Name < chains[j].Name
+		})
+		t.UpdateChains(chains)
+	}
+	t.degradedRules.report()
+	return nil
+}
+
+// inlineChain returns the chain with its IP set matches inlined, recording the IP sets that it
+// uses so that it can be re-rendered when they change.
+func (t *ipSetInliningTable) inlineChain(chain *iptables.Chain) *iptables.Chain {
+	var setNames []string
+	inlinedRules, leftOver := t.ruleRenderer.InlineIPSetMatches(chain.Rules, t.ipVersion,
+		func(setName string) ([]string, bool) {
+			setNames = append(setNames, setName)
+			members, summary := t.members(setName)
+			t.renderedMembers[setName] = summary
+			return members, members != nil
+		})
+	if setNames == nil {
+		t.forgetChain(chain.Name)
+		return chain
+	}
+	t.chains[chain.Name] = chain
+	t.chainIPSets[chain.Name] = setNames
+
+	subject := fmt.Sprintf("chain %s (IPv%d)", chain.Name, t.ipVersion)
+	issue := ""
+	if len(leftOver) > 0 {
+		issue = fmt.Sprintf("iptables can't match on IP sets and IP sets %s can't be expanded inline; "+
+			"drop rules that use them match more traffic and other rules that use them are left out",
+			strings.Join(leftOver, ", "))
+	}
+	t.degradedRules.set(subject, issue)
+
+	return &iptables.Chain{
+		Name:  chain.Name,
+		Rules: inlinedRules,
+	}
+}
+
+func (t *ipSetInliningTable) forgetChain(name string) {
+	if _, ok := t.chains[name]; !ok {
+		return
+	}
+	delete(t.chains, name)
+	delete(t.chainIPSets, name)
+	t.degradedRules.clear(fmt.Sprintf("chain %s (IPv%d)", name, t.ipVersion))
+}
+
+// members returns the members of the IP set with the given name, or nil if it can't be inlined,
+// along with a summary that changes when the result does.
+func (t *ipSetInliningTable) members(setName string) ([]string, string) {
+	setID, ok := t.ipSetConfig.SetIDForMainIPSet(setName)
+	if !ok {
+		return nil, "unknown"
+	}
+	memberSet, err := t.ipSets.GetMembers(setID)
+	if err != nil || memberSet == nil {
+		// Not created yet.
+		return nil, "missing"
+	}
+	if memberSet.Len() > t.maxMembers && !uncappedIPSetIDs[setID] {
+		return nil, "too big"
+	}
+	members := []string{}
+	memberSet.Iter(func(item interface{}) error {
+		members = append(members, item.(string))
+		return nil
+	})
+	sort.Strings(members)
+	return members, strings.Join(members, ",")
+}
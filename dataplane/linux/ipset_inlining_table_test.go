@@ -0,0 +1,140 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/iptables"
+	"github.com/projectcalico/felix/rules"
+)
+
+var _ = Describe("IP set inlining table", func() {
+	var (
+		table    *mockTable
+		ipSets   *mockIPSets
+		inlining *ipSetInliningTable
+		reported []string
+	)
+	ipSetConfig := ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil)
+	hostsChain := &iptables.Chain{
+		Name: "cali-INPUT",
+		Rules: []iptables.Rule{
+			{
+				Match:  iptables.Match().ProtocolNum(4).SourceIPSet("cali40all-hosts-net"),
+				Action: iptables.AcceptAction{},
+			},
+			{
+				Match:  iptables.Match().ProtocolNum(4),
+				Action: iptables.DropAction{},
+			},
+		},
+	}
+
+	thisHostChain := &iptables.Chain{
+		Name: "cali-OUTPUT",
+		Rules: []iptables.Rule{{
+			Match:  iptables.Match().DestIPSet("cali40this-host"),
+			Action: iptables.AcceptAction{},
+		}},
+	}
+
+	BeforeEach(func() {
+		table = newMockTable("filter")
+		ipSets = newMockIPSets()
+		reported = nil
+		renderer := rules.NewRenderer(rules.Config{
+			IPSetConfigV4:        ipSetConfig,
+			IptablesMarkAccept:   0x8,
+			IptablesMarkPass:     0x10,
+			IptablesMarkScratch0: 0x20,
+			IptablesMarkScratch1: 0x40,
+			IptablesMarkEndpoint: 0xff00,
+		})
+		inlining = newIPSetInliningTable(table, renderer, ipSets, ipSetConfig, 4, 2,
+			newDegradedRules(func(issues []string) {
+				reported = issues
+			}))
+	})
+
+	setMembers := func(members ...string) {
+		ipSets.AddOrReplaceIPSet(ipsets.IPSetMetadata{
+			SetID: "all-hosts-net",
+			Type:  ipsets.IPSetTypeHashNet,
+		}, members)
+	}
+
+	It("should pass through chains that don't use IP sets", func() {
+		chain := &iptables.Chain{
+			Name:  "cali-foo",
+			Rules: []iptables.Rule{{Action: iptables.AcceptAction{}}},
+		}
+		inlining.UpdateChain(chain)
+		Expect(table.currentChains["cali-foo"]).To(Equal(chain))
+		Expect(inlining.chains).To(BeEmpty())
+	})
+
+	It("should inline the IP set and re-render the chain when it changes", func() {
+		setMembers("10.0.0.1")
+		inlining.UpdateChains([]*iptables.Chain{hostsChain})
+		Expect(inlining.CompleteDeferredWork()).To(Succeed())
+		Expect(table.currentChains["cali-INPUT"].Rules).To(Equal([]iptables.Rule{
+			{Match: iptables.Match().ProtocolNum(4).SourceNet("10.0.0.1/32"), Action: iptables.AcceptAction{}},
+			{Match: iptables.Match().ProtocolNum(4), Action: iptables.DropAction{}},
+		}))
+		Expect(reported).To(BeEmpty())
+
+		ipSets.AddMembers("all-hosts-net", []string{"10.0.0.2"})
+		Expect(inlining.CompleteDeferredWork()).To(Succeed())
+		Expect(table.currentChains["cali-INPUT"].Rules).To(Equal([]iptables.Rule{
+			{Match: iptables.Match().ProtocolNum(4).SourceNet("10.0.0.1/32"), Action: iptables.AcceptAction{}},
+			{Match: iptables.Match().ProtocolNum(4).SourceNet("10.0.0.2/32"), Action: iptables.AcceptAction{}},
+			{Match: iptables.Match().ProtocolNum(4), Action: iptables.DropAction{}},
+		}))
+	})
+
+	It("should leave out allow rules that use IP sets that can't be inlined and report them", func() {
+		inlining.UpdateChains([]*iptables.Chain{hostsChain})
+		Expect(inlining.CompleteDeferredWork()).To(Succeed())
+		Expect(table.currentChains["cali-INPUT"].Rules).To(Equal([]iptables.Rule{
+			{Match: iptables.Match().ProtocolNum(4), Action: iptables.DropAction{}},
+		}))
+		Expect(reported).To(HaveLen(1))
+		Expect(reported[0]).To(HavePrefix("chain cali-INPUT (IPv4): "))
+
+		// The host IP sets are inlined even if they're bigger than the limit.
+		setMembers("10.0.0.1", "10.0.0.2", "10.0.0.3")
+		Expect(inlining.CompleteDeferredWork()).To(Succeed())
+		Expect(table.currentChains["cali-INPUT"].Rules).To(HaveLen(4))
+		Expect(reported).To(BeEmpty())
+
+		// Too big.
+		ipSets.AddOrReplaceIPSet(ipsets.IPSetMetadata{
+			SetID: "this-host",
+			Type:  ipsets.IPSetTypeHashNet,
+		}, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"})
+		inlining.UpdateChains([]*iptables.Chain{thisHostChain})
+		Expect(inlining.CompleteDeferredWork()).To(Succeed())
+		Expect(table.currentChains["cali-OUTPUT"].Rules).To(BeEmpty())
+		Expect(reported).To(HaveLen(1))
+		inlining.RemoveChains([]*iptables.Chain{thisHostChain})
+
+		inlining.RemoveChains([]*iptables.Chain{hostsChain})
+		Expect(table.currentChains).To(BeEmpty())
+		Expect(inlining.chains).To(BeEmpty())
+	})
+})
@@ -0,0 +1,179 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/iptables"
+	"github.com/projectcalico/felix/rules"
+)
+
+// calicoIptablesLineRegexp matches the lines of iptables-save output that declare one of our
+// chains or that hold one of our rules.
+var calicoIptablesLineRegexp = regexp.MustCompile(`^:cali-|--comment "?` + rules.RuleHashPrefix)
+
+// countCalicoIptablesLines counts the lines of iptables-save output that belong to us.
+func countCalicoIptablesLines(out []byte) int {
+	count := 0
+	for _, line := range bytes.Split(out, []byte("\n")) {
+		if calicoIptablesLineRegexp.Match(line) {
+			count++
+		}
+	}
+	return count
+}
+
+// otherIptablesBackend returns the iptables backend that we're not using.
+func otherIptablesBackend(backendMode string) string {
+	if backendMode == "nft" {
+		return "legacy"
+	}
+	return "nft"
+}
+
+// backendCleaner removes our chains and rules from the iptables backend that we aren't using.
+// After an OS upgrade switches the backend, the old rules would otherwise stay in the kernel,
+// alongside the ones that we program, and go on applying to traffic.  Only chains with our
+// prefixes and rules with our hash comments (or that jump to our chains) are removed; the other
+// backend's tables are otherwise left alone.
+type backendCleaner struct {
+	backendMode string
+	ipVersions  []uint8
+	lock        sync.Locker
+	detector    *iptables.FeatureDetector
+	options     iptables.TableOptions
+
+	lookPath func(file string) (string, error)
+	newCmd   func(name string, arg ...string) ([]byte, error)
+}
+
+func newBackendCleaner(
+	backendMode string,
+	ipVersions []uint8,
+	lock sync.Locker,
+	detector *iptables.FeatureDetector,
+	options iptables.TableOptions,
+) *backendCleaner {
+	c := &backendCleaner{
+		backendMode: otherIptablesBackend(backendMode),
+		ipVersions:  ipVersions,
+		lock:        lock,
+		detector:    detector,
+		options:     options,
+		lookPath:    exec.LookPath,
+		newCmd: func(name string, arg ...string) ([]byte, error) {
+			return exec.Command(name, arg...).Output()
+		},
+	}
+	// Start from scratch rather than with the options of our own tables, which may clean up
+	// (for example) kube-proxy's rules as well as ours.
+	c.options.BackendMode = c.backendMode
+	c.options.HistoricChainPrefixes = rules.AllHistoricChainNamePrefixes
+	c.options.ExtraCleanupRegexPattern = ""
+	c.options.RefreshInterval = 0
+	c.options.DeferRefresh = nil
+	if options.LookPathOverride != nil {
+		c.lookPath = options.LookPathOverride
+	}
+	return c
+}
+
+// CleanUp removes our state from each IP version's tables in the other backend, if there is any,
+// and then checks that it has gone.
+func (c *backendCleaner) CleanUp() {
+	for _, ipVersion := range c.ipVersions {
+		c.cleanUpIPVersion(ipVersion)
+	}
+}
+
+func (c *backendCleaner) cleanUpIPVersion(ipVersion uint8) {
+	logCxt := log.WithFields(log.Fields{"backend": c.backendMode, "ipVersion": ipVersion})
+
+	// findBestBinary falls back to the unqualified binaries, which belong to whichever backend
+	// the host uses; that might be ours, so we need the backend-specific binaries to be sure
+	// that we're looking at the other backend.
+	verInfix := ""
+	if ipVersion == 6 {
+		verInfix = "6"
+	}
+	saveCmd := fmt.Sprintf("ip%stables-%s-save", verInfix, c.backendMode)
+	restoreCmd := fmt.Sprintf("ip%stables-%s-restore", verInfix, c.backendMode)
+	for _, cmd := range []string{saveCmd, restoreCmd} {
+		if _, err := c.lookPath(cmd); err != nil {
+			logCxt.WithField("command", cmd).Debug(
+				"No command for the other iptables backend, skipping its cleanup.")
+			return
+		}
+	}
+
+	out, err := c.newCmd(saveCmd)
+	if err != nil {
+		logCxt.WithError(err).Warn("Failed to read the other iptables backend, skipping its cleanup.")
+		return
+	}
+	if countCalicoIptablesLines(out) == 0 {
+		logCxt.Debug("No Calico rules in the other iptables backend.")
+		return
+	}
+	logCxt.Warn("Found Calico rules in the iptables backend that we're not using, removing them.")
+
+	for _, name := range []string{"raw", "mangle", "nat", "filter"} {
+		options := c.options
+		if name == "nat" {
+			options.ExtraCleanupRegexPattern = rules.HistoricInsertedNATRuleRegex
+		}
+		if err := c.cleanUpTable(name, ipVersion, options); err != nil {
+			logCxt.WithError(err).WithField("table", name).Error(
+				"Failed to remove Calico rules from the other iptables backend.")
+		}
+	}
+
+	out, err = c.newCmd(saveCmd)
+	if err != nil {
+		logCxt.WithError(err).Warn("Failed to re-read the other iptables backend.")
+		return
+	}
+	if n := countCalicoIptablesLines(out); n > 0 {
+		logCxt.WithField("numLines", n).Error(
+			"Calico rules remain in the iptables backend that we're not using; " +
+				"they may still apply to traffic.")
+		return
+	}
+	logCxt.Info("Removed Calico rules from the iptables backend that we're not using.")
+}
+
+// cleanUpTable removes our chains and rules from one table.  A fresh Table doesn't want any
+// chains and starts off wanting to remove its inserts from the kernel chains, so applying it
+// once cleans up.
+func (c *backendCleaner) cleanUpTable(name string, ipVersion uint8, options iptables.TableOptions) (err error) {
+	// The table panics if it can't program iptables; that mustn't take out the dataplane
+	// since this backend is only being tidied up.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while cleaning up: %v", r)
+		}
+	}()
+	t := iptables.NewTable(name, ipVersion, rules.RuleHashPrefix, c.lock, c.detector, options)
+	t.RemoveAllChainsAndRules()
+	t.Apply()
+	return nil
+}
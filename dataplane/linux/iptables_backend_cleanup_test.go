@@ -0,0 +1,84 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/iptables"
+)
+
+var _ = Describe("iptables backend cleanup", func() {
+	var (
+		cleaner   *backendCleaner
+		available map[string]bool
+		saveOut   string
+		cmds      []string
+	)
+
+	BeforeEach(func() {
+		available = map[string]bool{}
+		saveOut = ""
+		cmds = nil
+		cleaner = newBackendCleaner("nft", []uint8{4}, dummyLock{}, iptables.NewFeatureDetector(nil),
+			iptables.TableOptions{InsertMode: "insert"})
+		cleaner.lookPath = func(file string) (string, error) {
+			if available[file] {
+				return "/sbin/" + file, nil
+			}
+			return "", errors.New("not found")
+		}
+		cleaner.newCmd = func(name string, arg ...string) ([]byte, error) {
+			cmds = append(cmds, strings.Join(append([]string{name}, arg...), " "))
+			return []byte(saveOut), nil
+		}
+	})
+
+	It("should target the other backend", func() {
+		Expect(cleaner.backendMode).To(Equal("legacy"))
+		Expect(cleaner.options.BackendMode).To(Equal("legacy"))
+		Expect(otherIptablesBackend("legacy")).To(Equal("nft"))
+	})
+
+	It("should do nothing without the other backend's own commands", func() {
+		available["iptables-legacy-save"] = true
+		cleaner.CleanUp()
+		Expect(cmds).To(BeEmpty())
+	})
+
+	It("should do nothing if the other backend has no Calico rules", func() {
+		available["iptables-legacy-save"] = true
+		available["iptables-legacy-restore"] = true
+		saveOut = "*filter\n:INPUT ACCEPT [0:0]\n:KUBE-FIREWALL - [0:0]\n-A INPUT -j KUBE-FIREWALL\nCOMMIT\n"
+		cleaner.CleanUp()
+		Expect(cmds).To(Equal([]string{"iptables-legacy-save"}))
+	})
+
+	It("should count only Calico's chains and rules", func() {
+		Expect(countCalicoIptablesLines([]byte(
+			"*filter\n" +
+				":INPUT ACCEPT [0:0]\n" +
+				":cali-INPUT - [0:0]\n" +
+				":calico-dhcp - [0:0]\n" +
+				"-A INPUT -m comment --comment \"cali:Cz_u1IQiXIMmKD4c\" -j cali-INPUT\n" +
+				"-A INPUT -m comment --comment \"dhcp\" -j ACCEPT\n" +
+				"COMMIT\n",
+		))).To(Equal(2))
+	})
+})
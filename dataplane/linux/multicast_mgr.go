@@ -0,0 +1,102 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/ifacemonitor"
+)
+
+// multicastManager forces the IGMP and MLD versions of workload interfaces when they come up, so
+// that the host queries the workloads, and they report their group memberships, in a version
+// that the multicast routing daemon understands.  A zero version leaves the kernel's default.
+type multicastManager struct {
+	workloadPrefixes []string
+	igmpVersion      int
+	mldVersion       int
+
+	// pending contains the names of workload interfaces that have come up and need their
+	// sysctls to be set.
+	pending map[string]bool
+
+	writeProcSys procSysWriter
+}
+
+func newMulticastManager(
+	workloadPrefixes []string,
+	igmpVersion int,
+	mldVersion int,
+	procSysWriter procSysWriter,
+) *multicastManager {
+	return &multicastManager{
+		workloadPrefixes: workloadPrefixes,
+		igmpVersion:      igmpVersion,
+		mldVersion:       mldVersion,
+		pending:          map[string]bool{},
+		writeProcSys:     procSysWriter,
+	}
+}
+
+func (m *multicastManager) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *ifaceUpdate:
+		if msg.State != ifacemonitor.StateUp {
+			delete(m.pending, msg.Name)
+			return
+		}
+		for _, prefix := range m.workloadPrefixes {
+			if strings.HasPrefix(msg.Name, prefix) {
+				m.pending[msg.Name] = true
+				return
+			}
+		}
+	}
+}
+
+func (m *multicastManager) CompleteDeferredWork() error {
+	var lastErr error
+	for name := range m.pending {
+		var sysctls [][2]string
+		if m.igmpVersion > 0 {
+			sysctls = append(sysctls, [2]string{
+				fmt.Sprintf("/proc/sys/net/ipv4/conf/%s/force_igmp_version", name),
+				fmt.Sprint(m.igmpVersion),
+			})
+		}
+		if m.mldVersion > 0 {
+			sysctls = append(sysctls, [2]string{
+				fmt.Sprintf("/proc/sys/net/ipv6/conf/%s/force_mld_version", name),
+				fmt.Sprint(m.mldVersion),
+			})
+		}
+		failed := false
+		for _, s := range sysctls {
+			if err := m.writeProcSys(s[0], s[1]); err != nil {
+				log.WithError(err).WithField("path", s[0]).Warn(
+					"Failed to set multicast sysctl of workload interface, will retry.")
+				lastErr = err
+				failed = true
+			}
+		}
+		if !failed {
+			delete(m.pending, name)
+		}
+	}
+	return lastErr
+}
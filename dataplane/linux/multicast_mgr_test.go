@@ -0,0 +1,72 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/ifacemonitor"
+)
+
+var _ = Describe("Multicast manager", func() {
+	var (
+		sysctls *mockSysctls
+		mgr     *multicastManager
+	)
+
+	BeforeEach(func() {
+		sysctls = &mockSysctls{values: map[string]string{}, writes: map[string]string{}}
+		mgr = newMulticastManager([]string{"cali"}, 2, 1, sysctls.write)
+	})
+
+	up := func(name string) *ifaceUpdate {
+		return &ifaceUpdate{Name: name, State: ifacemonitor.StateUp}
+	}
+
+	It("should force the IGMP and MLD versions of workload interfaces", func() {
+		mgr.OnUpdate(up("eth0"))
+		mgr.OnUpdate(up("cali1234"))
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(sysctls.writes).To(Equal(map[string]string{
+			"/proc/sys/net/ipv4/conf/cali1234/force_igmp_version": "2",
+			"/proc/sys/net/ipv6/conf/cali1234/force_mld_version":  "1",
+		}))
+
+		sysctls.writes = map[string]string{}
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(sysctls.writes).To(BeEmpty())
+	})
+
+	It("should leave the kernel's default version if it isn't configured", func() {
+		mgr = newMulticastManager([]string{"cali"}, 3, 0, sysctls.write)
+		mgr.OnUpdate(up("cali1234"))
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(sysctls.writes).To(Equal(map[string]string{
+			"/proc/sys/net/ipv4/conf/cali1234/force_igmp_version": "3",
+		}))
+	})
+
+	It("should retry failed writes", func() {
+		mgr.OnUpdate(up("cali1234"))
+		sysctls.failErr = errors.New("failed")
+		Expect(mgr.CompleteDeferredWork()).To(HaveOccurred())
+		sysctls.failErr = nil
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(sysctls.writes).To(HaveKeyWithValue("/proc/sys/net/ipv4/conf/cali1234/force_igmp_version", "2"))
+	})
+})
@@ -0,0 +1,146 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	kapiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// The pod annotations that limit the pod's bandwidth, in bits per second, using the usual quantity
+// suffixes; for example "10M".  They are the annotations that the bandwidth CNI plugin reads.
+const (
+	IngressBandwidthAnnotation = "kubernetes.io/ingress-bandwidth"
+	EgressBandwidthAnnotation  = "kubernetes.io/egress-bandwidth"
+)
+
+const podBandwidthResyncPeriod = 10 * time.Minute
+
+// podBandwidthUpdate carries the bandwidth annotations of a local pod to the bandwidthManager.  The
+// Calico data model doesn't include pod annotations so we watch them ourselves.
+type podBandwidthUpdate struct {
+	// WorkloadID is the pod's "<namespace>/<name>", as in its WorkloadEndpointID.
+	WorkloadID string
+	Limits     bandwidthLimits
+}
+
+// podBandwidthWatcher watches the pods on this node and sends a podBandwidthUpdate when a pod's
+// bandwidth annotations change.  The informer calls our handlers from a single goroutine so the
+// watcher needs no locking.
+type podBandwidthWatcher struct {
+	informer cache.SharedIndexInformer
+	updates  chan<- *podBandwidthUpdate
+
+	// sent holds the limits that we last sent for each pod that has any.
+	sent map[string]bandwidthLimits
+}
+
+func newPodBandwidthWatcher(
+	k8sClient kubernetes.Interface,
+	nodeName string,
+	updates chan<- *podBandwidthUpdate,
+) *podBandwidthWatcher {
+	informer := coreinformers.NewFilteredPodInformer(
+		k8sClient,
+		metav1.NamespaceAll,
+		podBandwidthResyncPeriod,
+		cache.Indexers{},
+		func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("spec.nodeName", nodeName).String()
+		},
+	)
+	w := &podBandwidthWatcher{
+		informer: informer,
+		updates:  updates,
+		sent:     map[string]bandwidthLimits{},
+	}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			w.onPodUpdate(obj, false)
+		},
+		UpdateFunc: func(_, newObj interface{}) {
+			w.onPodUpdate(newObj, false)
+		},
+		DeleteFunc: func(obj interface{}) {
+			w.onPodUpdate(obj, true)
+		},
+	})
+	return w
+}
+
+// Start runs the informer until the context is cancelled.
+func (w *podBandwidthWatcher) Start(ctx context.Context) {
+	go w.informer.Run(ctx.Done())
+}
+
+func (w *podBandwidthWatcher) onPodUpdate(obj interface{}, deleted bool) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		log.WithError(err).Warn("Failed to get key of pod, ignoring.")
+		return
+	}
+	var limits bandwidthLimits
+	if !deleted {
+		if pod, ok := obj.(*kapiv1.Pod); ok {
+			limits = bandwidthLimits{
+				ingress: podBandwidth(pod, IngressBandwidthAnnotation),
+				egress:  podBandwidth(pod, EgressBandwidthAnnotation),
+			}
+		}
+	}
+
+	if limits == w.sent[key] {
+		return
+	}
+	if limits == (bandwidthLimits{}) {
+		delete(w.sent, key)
+	} else {
+		w.sent[key] = limits
+	}
+	log.WithFields(log.Fields{
+		"pod":     key,
+		"ingress": limits.ingress,
+		"egress":  limits.egress,
+	}).Debug("Pod bandwidth annotations changed")
+	w.updates <- &podBandwidthUpdate{WorkloadID: key, Limits: limits}
+}
+
+// podBandwidth returns the bandwidth limit from the given annotation, or 0 (unlimited) if the pod
+// doesn't have it or the value is invalid.
+func podBandwidth(pod *kapiv1.Pod, annotation string) int64 {
+	value, ok := pod.Annotations[annotation]
+	if !ok {
+		return 0
+	}
+	q, err := resource.ParseQuantity(value)
+	if err != nil || q.Sign() <= 0 {
+		log.WithError(err).WithFields(log.Fields{
+			"pod":        pod.Namespace + "/" + pod.Name,
+			"annotation": annotation,
+			"value":      value,
+		}).Warn("Ignoring invalid bandwidth limit.")
+		return 0
+	}
+	return q.Value()
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	kapiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Pod bandwidth watcher", func() {
+	var (
+		w       *podBandwidthWatcher
+		updates chan *podBandwidthUpdate
+	)
+
+	pod := func(annotations map[string]string) *kapiv1.Pod {
+		return &kapiv1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "pod1",
+				Namespace:   "ns",
+				Annotations: annotations,
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		updates = make(chan *podBandwidthUpdate, 10)
+		w = &podBandwidthWatcher{
+			updates: updates,
+			sent:    map[string]bandwidthLimits{},
+		}
+	})
+
+	It("should send the limits from the annotations", func() {
+		w.onPodUpdate(pod(map[string]string{
+			IngressBandwidthAnnotation: "10M",
+			EgressBandwidthAnnotation:  "1.5G",
+		}), false)
+		Expect(updates).To(Receive(Equal(&podBandwidthUpdate{
+			WorkloadID: "ns/pod1",
+			Limits:     bandwidthLimits{ingress: 10000000, egress: 1500000000},
+		})))
+	})
+
+	It("should ignore invalid annotations", func() {
+		w.onPodUpdate(pod(map[string]string{
+			IngressBandwidthAnnotation: "fast",
+			EgressBandwidthAnnotation:  "-1M",
+		}), false)
+		Expect(updates).NotTo(Receive())
+	})
+
+	It("should only send changes", func() {
+		p := pod(map[string]string{IngressBandwidthAnnotation: "10M"})
+		w.onPodUpdate(p, false)
+		Expect(updates).To(Receive())
+		p.Labels = map[string]string{"app": "web"}
+		w.onPodUpdate(p, false)
+		Expect(updates).NotTo(Receive())
+
+		w.onPodUpdate(p, true)
+		Expect(updates).To(Receive(Equal(&podBandwidthUpdate{WorkloadID: "ns/pod1"})))
+		Expect(w.sent).To(BeEmpty())
+	})
+})
@@ -15,6 +15,8 @@
 package intdataplane
 
 import (
+	"fmt"
+
 	log "github.com/sirupsen/logrus"
 
 	"github.com/projectcalico/felix/iptables"
@@ -30,6 +32,8 @@ type policyManager struct {
 	filterTable  iptablesTable
 	ruleRenderer policyRenderer
 	ipVersion    uint8
+	// chainOrigins, if non-nil, records the policy or profile that each chain came from.
+	chainOrigins *chainOrigins
 }
 
 type policyRenderer interface {
@@ -52,6 +56,9 @@ func (m *policyManager) OnUpdate(msg interface{}) {
 	case *proto.ActivePolicyUpdate:
 		log.WithField("id", msg.Id).Debug("Updating policy chains")
 		chains := m.ruleRenderer.PolicyToIptablesChains(msg.Id, msg.Policy, m.ipVersion)
+		for _, chain := range chains {
+			m.chainOrigins.set(fmt.Sprintf("policy %s/%s", msg.Id.Tier, msg.Id.Name), chain.Name)
+		}
 		// We can't easily tell whether the policy is in use in a particular table, and, if the policy
 		// type gets changed it may move between tables.  Hence, we put the policy into all tables.
 		// The iptables layer will avoid programming it if it is not actually used.
@@ -69,9 +76,11 @@ func (m *policyManager) OnUpdate(msg interface{}) {
 		m.mangleTable.RemoveChainByName(outName)
 		m.rawTable.RemoveChainByName(inName)
 		m.rawTable.RemoveChainByName(outName)
+		m.chainOrigins.remove(inName, outName)
 	case *proto.ActiveProfileUpdate:
 		log.WithField("id", msg.Id).Debug("Updating profile chains")
 		inbound, outbound := m.ruleRenderer.ProfileToIptablesChains(msg.Id, msg.Profile, m.ipVersion)
+		m.chainOrigins.set("profile "+msg.Id.Name, inbound.Name, outbound.Name)
 		m.filterTable.UpdateChains([]*iptables.Chain{inbound, outbound})
 		m.mangleTable.UpdateChains([]*iptables.Chain{outbound})
 	case *proto.ActiveProfileRemove:
@@ -81,6 +90,7 @@ func (m *policyManager) OnUpdate(msg interface{}) {
 		m.filterTable.RemoveChainByName(inName)
 		m.filterTable.RemoveChainByName(outName)
 		m.mangleTable.RemoveChainByName(outName)
+		m.chainOrigins.remove(inName, outName)
 	}
 }
 
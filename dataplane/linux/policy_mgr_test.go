@@ -38,6 +38,7 @@ var _ = Describe("Policy manager", func() {
 		filterTable = newMockTable("filter")
 		ruleRenderer = newMockPolRenderer()
 		policyMgr = newPolicyManager(rawTable, mangleTable, filterTable, ruleRenderer, 4)
+		policyMgr.chainOrigins = newChainOrigins()
 	})
 
 	It("shouldn't touch iptables", func() {
@@ -84,6 +85,15 @@ var _ = Describe("Policy manager", func() {
 				filterTable.checkChains([][]*iptables.Chain{})
 				mangleTable.checkChains([][]*iptables.Chain{})
 			})
+
+			It("should forget the origin of the chains", func() {
+				Expect(policyMgr.chainOrigins.describe("cali-pi-pol1")).To(BeEmpty())
+			})
+		})
+
+		It("should record the origin of the chains", func() {
+			Expect(policyMgr.chainOrigins.describe("cali-pi-pol1")).To(Equal("policy default/pol1"))
+			Expect(policyMgr.chainOrigins.describe("cali-po-pol1")).To(Equal("policy default/pol1"))
 		})
 	})
 
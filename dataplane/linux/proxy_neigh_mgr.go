@@ -0,0 +1,305 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/ifacemonitor"
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/netlinkshim"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+	"github.com/projectcalico/libcalico-go/lib/set"
+)
+
+// proxyNeighNetlink is the subset of the netlink API that the proxyNeighManager uses, so that we
+// can shim it in the tests.
+type proxyNeighNetlink interface {
+	LinkByName(name string) (netlink.Link, error)
+	NeighProxyList(linkIndex, family int) ([]netlink.Neigh, error)
+	NeighSet(neigh *netlink.Neigh) error
+	NeighDel(neigh *netlink.Neigh) error
+}
+
+// proxyNeighManager programs the proxy ARP/NDP entries that WorkloadProxyNeighbors configures on
+// the interfaces of local workloads.  The workloads that each rule selects come from an IP set
+// that the calculation graph maintains; see rules.ProxyNeighborIPSetID.
+//
+// The entries are managed as desired state: when an interface's desired entries change, or the
+// interface comes up, we list its proxy entries and add the missing ones and remove any others.
+// We also sync each workload interface when we first hear about it so that the entries of rules
+// that have since been removed from the config are cleaned up; that's why the manager is always
+// registered, even if there are no rules.
+type proxyNeighManager struct {
+	ipv6Enabled bool
+	nl          proxyNeighNetlink
+
+	// ruleIPs holds the proxy IPs of each rule and ipSetIDToRule maps the IDs of our IP sets to
+	// the index of their rule.  selectedCIDRs holds the members of each rule's IP set.
+	ruleIPs       [][]ip.Addr
+	ipSetIDToRule map[string]int
+	selectedCIDRs []set.Set /* ip.CIDR */
+
+	workloadIfaces map[proto.WorkloadEndpointID]string
+	workloadCIDRs  map[proto.WorkloadEndpointID][]ip.CIDR
+
+	// desiredIPs holds the proxy IPs that we last calculated for each interface and dirtyIfaces
+	// the interfaces that need to be reconciled.
+	desiredIPs  map[string]set.Set /* ip.Addr */
+	dirtyIfaces set.Set
+	dirty       bool
+}
+
+func newProxyNeighManager(proxyRules []config.ProxyNeighborRule, ipv6Enabled bool) *proxyNeighManager {
+	nlHandle, err := netlink.NewHandle()
+	if err != nil {
+		log.WithError(err).Panic("Failed to create netlink handle for proxy neighbor manager")
+	}
+	return newProxyNeighManagerWithShim(proxyRules, ipv6Enabled, nlHandle)
+}
+
+func newProxyNeighManagerWithShim(
+	proxyRules []config.ProxyNeighborRule,
+	ipv6Enabled bool,
+	nl proxyNeighNetlink,
+) *proxyNeighManager {
+	m := &proxyNeighManager{
+		ipv6Enabled:    ipv6Enabled,
+		nl:             nl,
+		ipSetIDToRule:  map[string]int{},
+		workloadIfaces: map[proto.WorkloadEndpointID]string{},
+		workloadCIDRs:  map[proto.WorkloadEndpointID][]ip.CIDR{},
+		desiredIPs:     map[string]set.Set{},
+		dirtyIfaces:    set.New(),
+	}
+	for i, rule := range proxyRules {
+		var addrs []ip.Addr
+		for _, s := range rule.IPs {
+			addr := ip.FromString(s)
+			if addr.Version() == 6 && !ipv6Enabled {
+				continue
+			}
+			addrs = append(addrs, addr)
+		}
+		m.ruleIPs = append(m.ruleIPs, addrs)
+		m.ipSetIDToRule[rules.ProxyNeighborIPSetID(i)] = i
+		m.selectedCIDRs = append(m.selectedCIDRs, set.New())
+	}
+	return m
+}
+
+func (m *proxyNeighManager) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *proto.IPSetUpdate:
+		m.clearIPSet(msg.Id)
+		m.onIPSetMembers(msg.Id, msg.Members, true)
+	case *proto.IPSetDeltaUpdate:
+		m.onIPSetMembers(msg.Id, msg.RemovedMembers, false)
+		m.onIPSetMembers(msg.Id, msg.AddedMembers, true)
+	case *proto.IPSetRemove:
+		m.clearIPSet(msg.Id)
+	case *proto.WorkloadEndpointUpdate:
+		var cidrs []ip.CIDR
+		for _, nets := range [][]string{msg.Endpoint.Ipv4Nets, msg.Endpoint.Ipv6Nets} {
+			for _, s := range nets {
+				cidrs = append(cidrs, ip.MustParseCIDROrIP(s))
+			}
+		}
+		oldIface, ok := m.workloadIfaces[*msg.Id]
+		if ok && oldIface != msg.Endpoint.Name {
+			m.dirtyIfaces.Add(oldIface)
+		}
+		if !ok || oldIface != msg.Endpoint.Name {
+			m.dirtyIfaces.Add(msg.Endpoint.Name)
+		}
+		m.workloadIfaces[*msg.Id] = msg.Endpoint.Name
+		m.workloadCIDRs[*msg.Id] = cidrs
+		m.dirty = true
+	case *proto.WorkloadEndpointRemove:
+		delete(m.workloadIfaces, *msg.Id)
+		delete(m.workloadCIDRs, *msg.Id)
+		m.dirty = true
+	case *ifaceUpdate:
+		if msg.State != ifacemonitor.StateUp {
+			return
+		}
+		if _, ok := m.desiredIPs[msg.Name]; ok {
+			// The interface may have been recreated, which flushes its proxy entries.
+			m.dirtyIfaces.Add(msg.Name)
+		}
+	}
+}
+
+func (m *proxyNeighManager) clearIPSet(ipSetID string) {
+	i, ok := m.ipSetIDToRule[ipSetID]
+	if !ok {
+		return
+	}
+	m.dirty = true
+	m.selectedCIDRs[i].Clear()
+}
+
+// onIPSetMembers adds or removes members of one of our IP sets.
+func (m *proxyNeighManager) onIPSetMembers(ipSetID string, members []string, add bool) {
+	i, ok := m.ipSetIDToRule[ipSetID]
+	if !ok || len(members) == 0 {
+		return
+	}
+	m.dirty = true
+	s := m.selectedCIDRs[i]
+	for _, member := range members {
+		cidr, err := ip.ParseCIDROrIP(member)
+		if err != nil {
+			continue
+		}
+		if add {
+			s.Add(cidr)
+		} else {
+			s.Discard(cidr)
+		}
+	}
+}
+
+func (m *proxyNeighManager) CompleteDeferredWork() error {
+	if m.dirty {
+		m.updateDesiredIPs()
+		m.dirty = false
+	}
+
+	var lastErr error
+	m.dirtyIfaces.Iter(func(item interface{}) error {
+		ifaceName := item.(string)
+		if err := m.syncIface(ifaceName); err != nil {
+			log.WithError(err).WithField("iface", ifaceName).Warn(
+				"Failed to sync proxy neighbor entries, will retry")
+			lastErr = err
+			return nil
+		}
+		return set.RemoveItem
+	})
+	return lastErr
+}
+
+// updateDesiredIPs recalculates the proxy IPs of each workload interface and marks the interfaces
+// whose IPs changed as dirty.
+func (m *proxyNeighManager) updateDesiredIPs() {
+	desiredIPs := map[string]set.Set{}
+	for id, ifaceName := range m.workloadIfaces {
+		for i, selected := range m.selectedCIDRs {
+			if !m.anySelected(selected, m.workloadCIDRs[id]) {
+				continue
+			}
+			if desiredIPs[ifaceName] == nil {
+				desiredIPs[ifaceName] = set.New()
+			}
+			for _, addr := range m.ruleIPs[i] {
+				desiredIPs[ifaceName].Add(addr)
+			}
+		}
+	}
+	for ifaceName, addrs := range m.desiredIPs {
+		if newAddrs, ok := desiredIPs[ifaceName]; !ok || !newAddrs.Equals(addrs) {
+			m.dirtyIfaces.Add(ifaceName)
+		}
+	}
+	for ifaceName := range desiredIPs {
+		if _, ok := m.desiredIPs[ifaceName]; !ok {
+			m.dirtyIfaces.Add(ifaceName)
+		}
+	}
+	m.desiredIPs = desiredIPs
+}
+
+func (m *proxyNeighManager) anySelected(selected set.Set, cidrs []ip.CIDR) bool {
+	for _, cidr := range cidrs {
+		if selected.Contains(cidr) {
+			return true
+		}
+	}
+	return false
+}
+
+// syncIface makes the proxy entries on the given interface match its desired IPs.
+func (m *proxyNeighManager) syncIface(ifaceName string) error {
+	link, err := m.nl.LinkByName(ifaceName)
+	if err != nil {
+		if netlinkshim.IsNotExist(err) {
+			// The interface is gone, along with its entries.  If it comes back, we'll get an
+			// interface update.
+			log.WithField("iface", ifaceName).Debug("Interface gone, no proxy entries to sync")
+			return nil
+		}
+		return err
+	}
+	linkIndex := link.Attrs().Index
+
+	desired := map[string]ip.Addr{}
+	if addrs, ok := m.desiredIPs[ifaceName]; ok {
+		addrs.Iter(func(item interface{}) error {
+			addr := item.(ip.Addr)
+			desired[addr.String()] = addr
+			return nil
+		})
+	}
+
+	families := []int{netlink.FAMILY_V4}
+	if m.ipv6Enabled {
+		families = append(families, netlink.FAMILY_V6)
+	}
+	for _, family := range families {
+		existing, err := m.nl.NeighProxyList(linkIndex, family)
+		if err != nil {
+			return err
+		}
+		for _, n := range existing {
+			if n.IP == nil {
+				continue
+			}
+			if _, ok := desired[n.IP.String()]; ok {
+				delete(desired, n.IP.String())
+				continue
+			}
+			log.WithFields(log.Fields{"iface": ifaceName, "ip": n.IP}).Info("Removing proxy neighbor entry")
+			if err := m.nl.NeighDel(&netlink.Neigh{
+				LinkIndex: linkIndex,
+				Family:    family,
+				Flags:     netlink.NTF_PROXY,
+				IP:        n.IP,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, addr := range desired {
+		family := netlink.FAMILY_V4
+		if addr.Version() == 6 {
+			family = netlink.FAMILY_V6
+		}
+		log.WithFields(log.Fields{"iface": ifaceName, "ip": addr}).Info("Adding proxy neighbor entry")
+		if err := m.nl.NeighSet(&netlink.Neigh{
+			LinkIndex: linkIndex,
+			Family:    family,
+			Flags:     netlink.NTF_PROXY,
+			IP:        addr.AsNetIP(),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"net"
+	"sort"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/ifacemonitor"
+	"github.com/projectcalico/felix/proto"
+)
+
+// mockProxyNeighNetlink holds the proxy entries of each link, keyed on IP.
+type mockProxyNeighNetlink struct {
+	links   map[string]int
+	proxies map[int]map[string]netlink.Neigh
+	numSets int
+}
+
+func (n *mockProxyNeighNetlink) LinkByName(name string) (netlink.Link, error) {
+	idx, ok := n.links[name]
+	if !ok {
+		return nil, netlink.LinkNotFoundError{}
+	}
+	return &mockLink{attrs: netlink.LinkAttrs{Name: name, Index: idx}}, nil
+}
+
+func (n *mockProxyNeighNetlink) NeighProxyList(linkIndex, family int) ([]netlink.Neigh, error) {
+	var neighs []netlink.Neigh
+	for _, neigh := range n.proxies[linkIndex] {
+		if neigh.Family == family {
+			neighs = append(neighs, neigh)
+		}
+	}
+	return neighs, nil
+}
+
+func (n *mockProxyNeighNetlink) NeighSet(neigh *netlink.Neigh) error {
+	Expect(neigh.Flags).To(Equal(netlink.NTF_PROXY))
+	if n.proxies[neigh.LinkIndex] == nil {
+		n.proxies[neigh.LinkIndex] = map[string]netlink.Neigh{}
+	}
+	n.proxies[neigh.LinkIndex][neigh.IP.String()] = *neigh
+	n.numSets++
+	return nil
+}
+
+func (n *mockProxyNeighNetlink) NeighDel(neigh *netlink.Neigh) error {
+	delete(n.proxies[neigh.LinkIndex], neigh.IP.String())
+	return nil
+}
+
+func (n *mockProxyNeighNetlink) proxyIPs(linkIndex int) []string {
+	ips := []string{}
+	for s := range n.proxies[linkIndex] {
+		ips = append(ips, s)
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+var _ = Describe("Proxy neighbor manager", func() {
+	var (
+		mgr *proxyNeighManager
+		nl  *mockProxyNeighNetlink
+	)
+
+	wep1ID := &proto.WorkloadEndpointID{OrchestratorId: "k8s", WorkloadId: "ns/vm1", EndpointId: "eth0"}
+	wep2ID := &proto.WorkloadEndpointID{OrchestratorId: "k8s", WorkloadId: "ns/vm2", EndpointId: "eth0"}
+
+	BeforeEach(func() {
+		nl = &mockProxyNeighNetlink{
+			links:   map[string]int{"cali1": 10, "cali2": 11},
+			proxies: map[int]map[string]netlink.Neigh{},
+		}
+		mgr = newProxyNeighManagerWithShim([]config.ProxyNeighborRule{
+			{Selector: "vm == 'true'", IPs: []string{"169.254.1.1", "fd00::1"}},
+			{Selector: "router == 'true'", IPs: []string{"10.0.0.254"}},
+		}, true, nl)
+
+		mgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id: wep1ID,
+			Endpoint: &proto.WorkloadEndpoint{
+				Name:     "cali1",
+				Ipv4Nets: []string{"10.0.0.1/32"},
+				Ipv6Nets: []string{"fd00::a/128"},
+			},
+		})
+		mgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id:       wep2ID,
+			Endpoint: &proto.WorkloadEndpoint{Name: "cali2", Ipv4Nets: []string{"10.0.0.2/32"}},
+		})
+	})
+
+	It("should remove old entries from workload interfaces if there are no rules", func() {
+		nl.proxies[10] = map[string]netlink.Neigh{
+			"169.254.1.1": {LinkIndex: 10, Family: netlink.FAMILY_V4, IP: net.ParseIP("169.254.1.1")},
+		}
+		mgr = newProxyNeighManagerWithShim(nil, true, nl)
+		mgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id:       wep1ID,
+			Endpoint: &proto.WorkloadEndpoint{Name: "cali1", Ipv4Nets: []string{"10.0.0.1/32"}},
+		})
+		Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+		Expect(nl.proxyIPs(10)).To(BeEmpty())
+	})
+
+	It("should not add entries until a rule selects the workload", func() {
+		Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+		Expect(nl.proxyIPs(10)).To(BeEmpty())
+		Expect(nl.proxyIPs(11)).To(BeEmpty())
+	})
+
+	Describe("with selected workloads", func() {
+		BeforeEach(func() {
+			mgr.OnUpdate(&proto.IPSetUpdate{
+				Id:      "proxy-neigh-0",
+				Members: []string{"10.0.0.1/32", "fd00::a/128"},
+				Type:    proto.IPSetUpdate_NET,
+			})
+			mgr.OnUpdate(&proto.IPSetUpdate{
+				Id:      "proxy-neigh-1",
+				Members: []string{"10.0.0.1/32", "10.0.0.2/32"},
+				Type:    proto.IPSetUpdate_NET,
+			})
+			Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+		})
+
+		It("should add the rules' entries to each interface", func() {
+			Expect(nl.proxyIPs(10)).To(Equal([]string{"10.0.0.254", "169.254.1.1", "fd00::1"}))
+			Expect(nl.proxyIPs(11)).To(Equal([]string{"10.0.0.254"}))
+			Expect(nl.proxies[10]["fd00::1"].Family).To(Equal(netlink.FAMILY_V6))
+		})
+
+		It("should not reprogram unchanged interfaces", func() {
+			numSets := nl.numSets
+			Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+			Expect(nl.numSets).To(Equal(numSets))
+		})
+
+		It("should remove entries when a workload leaves the IP set", func() {
+			mgr.OnUpdate(&proto.IPSetDeltaUpdate{
+				Id:             "proxy-neigh-1",
+				RemovedMembers: []string{"10.0.0.2/32"},
+			})
+			Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+			Expect(nl.proxyIPs(10)).To(Equal([]string{"10.0.0.254", "169.254.1.1", "fd00::1"}))
+			Expect(nl.proxyIPs(11)).To(BeEmpty())
+		})
+
+		It("should remove stale entries that it didn't add", func() {
+			nl.proxies[11]["10.9.9.9"] = netlink.Neigh{
+				LinkIndex: 11,
+				Family:    netlink.FAMILY_V4,
+				IP:        []byte{10, 9, 9, 9},
+			}
+			mgr.OnUpdate(&ifaceUpdate{Name: "cali2", State: ifacemonitor.StateUp, Index: 11})
+			Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+			Expect(nl.proxyIPs(11)).To(Equal([]string{"10.0.0.254"}))
+		})
+
+		It("should clear entries when the workload is removed", func() {
+			mgr.OnUpdate(&proto.WorkloadEndpointRemove{Id: wep1ID})
+			Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+			Expect(nl.proxyIPs(10)).To(BeEmpty())
+			Expect(nl.proxyIPs(11)).To(Equal([]string{"10.0.0.254"}))
+		})
+
+		It("should reprogram an interface that is recreated", func() {
+			delete(nl.proxies, 10)
+			nl.links["cali1"] = 20
+			mgr.OnUpdate(&ifaceUpdate{Name: "cali1", State: ifacemonitor.StateUp, Index: 20})
+			Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+			Expect(nl.proxyIPs(20)).To(Equal([]string{"10.0.0.254", "169.254.1.1", "fd00::1"}))
+		})
+
+		It("should tolerate an interface that has gone", func() {
+			delete(nl.links, "cali2")
+			mgr.OnUpdate(&proto.IPSetRemove{Id: "proxy-neigh-1"})
+			Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+			Expect(nl.proxyIPs(10)).To(Equal([]string{"169.254.1.1", "fd00::1"}))
+		})
+	})
+})
@@ -0,0 +1,148 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/debugserver"
+)
+
+// resyncTimeout bounds how long a resync request waits for the dataplane loop to do the resync,
+// which only happens once we're in sync with the datastore.
+const resyncTimeout = 2 * time.Minute
+
+// inconsistencyCounter is implemented by the dataplane components (iptables tables, IP sets, route
+// tables, the BPF route manager and the XDP state) that count the inconsistencies that they find,
+// and then repair, when they resync with the dataplane.
+type inconsistencyCounter interface {
+	NumInconsistencies() int
+}
+
+type resyncRequest struct {
+	result chan resyncSummary
+}
+
+type resyncSummary struct {
+	// InSync is false if some of the repairs failed; they'll be retried as usual.
+	InSync bool `json:"inSync"`
+	// Repairs holds the number of inconsistencies found in each part of the dataplane.
+	Repairs map[string]int `json:"repairs"`
+}
+
+// resyncHandler serves POST /debug/resync, which makes us check all of the dataplane against
+// the desired state, repair any differences and report how many we found.
+type resyncHandler struct {
+	requests chan<- resyncRequest
+}
+
+func (h resyncHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	log.Warn("Full dataplane resync requested via the debug server.")
+	r := resyncRequest{result: make(chan resyncSummary, 1)}
+	timeout := time.NewTimer(resyncTimeout)
+	defer timeout.Stop()
+	select {
+	case h.requests <- r:
+	case <-timeout.C:
+		http.Error(w, "Timed out waiting for the dataplane loop", http.StatusServiceUnavailable)
+		return
+	}
+	var summary resyncSummary
+	select {
+	case summary = <-r.result:
+	case <-timeout.C:
+		http.Error(w, "Timed out waiting for the resync to finish", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(summary); err != nil {
+		log.WithError(err).Warn("Failed to write resync summary")
+	}
+}
+
+func (d *InternalDataplane) registerResyncHandler() {
+	debugserver.RegisterHandler("resync", resyncHandler{requests: d.resyncRequests})
+}
+
+// queueFullResync makes the next apply() check all of the dataplane against the desired state.
+func (d *InternalDataplane) queueFullResync(req resyncRequest) {
+	if len(d.pendingResyncRequests) == 0 {
+		d.resyncBaseline = d.countInconsistencies()
+	}
+	d.pendingResyncRequests = append(d.pendingResyncRequests, req)
+
+	for _, t := range d.allIptablesTables {
+		t.InvalidateDataplaneCache("forced resync")
+	}
+	d.forceIPSetsRefresh = true
+	d.forceRouteRefresh = true
+	d.forceXDPRefresh = true
+	d.sysctlMgr.QueueResync()
+	d.dataplaneNeedsSync = true
+}
+
+// completeResyncRequests reports the inconsistencies found since the resync was requested.
+func (d *InternalDataplane) completeResyncRequests() {
+	summary := resyncSummary{
+		InSync:  !d.dataplaneNeedsSync,
+		Repairs: map[string]int{},
+	}
+	for component, n := range d.countInconsistencies() {
+		summary.Repairs[component] = n - d.resyncBaseline[component]
+	}
+	log.WithFields(log.Fields{
+		"inSync":  summary.InSync,
+		"repairs": summary.Repairs,
+	}).Info("Completed full dataplane resync.")
+	for _, req := range d.pendingResyncRequests {
+		req.result <- summary
+	}
+	d.pendingResyncRequests = nil
+	d.resyncBaseline = nil
+}
+
+func (d *InternalDataplane) countInconsistencies() map[string]int {
+	counts := map[string]int{}
+	for _, t := range d.allIptablesTables {
+		counts["iptables"] += t.NumInconsistencies()
+	}
+	for _, s := range d.ipSets {
+		if c, ok := s.(inconsistencyCounter); ok {
+			counts["ipsets"] += c.NumInconsistencies()
+		}
+	}
+	for _, rt := range d.routeTableSyncers() {
+		if c, ok := rt.(inconsistencyCounter); ok {
+			counts["routes"] += c.NumInconsistencies()
+		}
+	}
+	for _, mgr := range d.allManagers {
+		if c, ok := mgr.(inconsistencyCounter); ok {
+			counts["routes"] += c.NumInconsistencies()
+		}
+	}
+	if d.xdpState != nil {
+		counts["xdp"] += d.xdpState.NumInconsistencies()
+	}
+	return counts
+}
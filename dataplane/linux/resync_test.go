@@ -0,0 +1,59 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("resync handler", func() {
+	var (
+		requests chan resyncRequest
+		handler  resyncHandler
+	)
+
+	BeforeEach(func() {
+		requests = make(chan resyncRequest, 1)
+		handler = resyncHandler{requests: requests}
+	})
+
+	It("should reject GETs", func() {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/resync/", nil))
+		Expect(w.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(requests).NotTo(Receive())
+	})
+
+	It("should return the summary from the dataplane loop", func() {
+		go func() {
+			defer GinkgoRecover()
+			req := <-requests
+			req.result <- resyncSummary{InSync: true, Repairs: map[string]int{"iptables": 2}}
+		}()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/debug/resync/", nil))
+		Expect(w.Code).To(Equal(http.StatusOK))
+
+		var summary resyncSummary
+		Expect(json.Unmarshal(w.Body.Bytes(), &summary)).To(Succeed())
+		Expect(summary.InSync).To(BeTrue())
+		Expect(summary.Repairs).To(Equal(map[string]int{"iptables": 2}))
+	})
+})
@@ -0,0 +1,102 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/ifacemonitor"
+)
+
+var rpFilterValues = map[string]string{
+	config.RPFModeDisabled: "0",
+	config.RPFModeStrict:   "1",
+	config.RPFModeLoose:    "2",
+}
+
+// rpfManager applies the user's per-interface RPF mode overrides to host interfaces, by setting
+// their rp_filter sysctl when they come up.  (Workload interfaces are handled by the iptables rules
+// in the raw PREROUTING chain.)  Note that the kernel uses the maximum of the "all" and
+// per-interface rp_filter values so loose or disabled modes only take effect if
+// net.ipv4.conf.all.rp_filter is set to a value no stricter than the override.
+type rpfManager struct {
+	overrides        []config.RPFModeOverride
+	workloadPrefixes []string
+
+	// pending contains the names of interfaces that have come up and need their rp_filter
+	// sysctl to be set.
+	pending map[string]bool
+
+	writeProcSys procSysWriter
+}
+
+func newRPFManager(
+	overrides []config.RPFModeOverride,
+	workloadPrefixes []string,
+	procSysWriter procSysWriter,
+) *rpfManager {
+	return &rpfManager{
+		overrides:        overrides,
+		workloadPrefixes: workloadPrefixes,
+		pending:          map[string]bool{},
+		writeProcSys:     procSysWriter,
+	}
+}
+
+func (m *rpfManager) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *ifaceUpdate:
+		if msg.State != ifacemonitor.StateUp {
+			delete(m.pending, msg.Name)
+			return
+		}
+		if m.isWorkloadIface(msg.Name) {
+			return
+		}
+		if _, ok := config.RPFModeForInterface(m.overrides, msg.Name); ok {
+			m.pending[msg.Name] = true
+		}
+	}
+}
+
+func (m *rpfManager) isWorkloadIface(name string) bool {
+	for _, prefix := range m.workloadPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *rpfManager) CompleteDeferredWork() error {
+	var lastErr error
+	for name := range m.pending {
+		mode, _ := config.RPFModeForInterface(m.overrides, name)
+		p := fmt.Sprintf("/proc/sys/net/ipv4/conf/%s/rp_filter", name)
+		log.WithFields(log.Fields{"ifaceName": name, "mode": mode}).Info(
+			"Setting RPF mode of host interface.")
+		if err := m.writeProcSys(p, rpFilterValues[mode]); err != nil {
+			log.WithError(err).WithField("ifaceName", name).Warn("Failed to set rp_filter, will retry.")
+			lastErr = err
+			continue
+		}
+		delete(m.pending, name)
+	}
+	return lastErr
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/ifacemonitor"
+)
+
+var _ = Describe("RPF manager", func() {
+	var (
+		sysctls *mockSysctls
+		mgr     *rpfManager
+	)
+
+	BeforeEach(func() {
+		sysctls = &mockSysctls{values: map[string]string{}, writes: map[string]string{}}
+		mgr = newRPFManager([]config.RPFModeOverride{
+			{InterfacePattern: "eth1", Mode: "Disabled"},
+			{InterfacePattern: "eth+", Mode: "Loose"},
+			{InterfacePattern: "cali+", Mode: "Loose"},
+		}, []string{"cali"}, sysctls.write)
+	})
+
+	up := func(name string) *ifaceUpdate {
+		return &ifaceUpdate{Name: name, State: ifacemonitor.StateUp}
+	}
+
+	It("should set rp_filter on matching host interfaces", func() {
+		mgr.OnUpdate(up("eth0"))
+		mgr.OnUpdate(up("eth1"))
+		mgr.OnUpdate(up("bond0"))
+		mgr.OnUpdate(up("cali1234"))
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(sysctls.writes).To(Equal(map[string]string{
+			"/proc/sys/net/ipv4/conf/eth0/rp_filter": "2",
+			"/proc/sys/net/ipv4/conf/eth1/rp_filter": "0",
+		}))
+
+		sysctls.writes = map[string]string{}
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(sysctls.writes).To(BeEmpty())
+	})
+
+	It("should retry failed writes", func() {
+		mgr.OnUpdate(up("eth0"))
+		sysctls.failErr = errors.New("failed")
+		Expect(mgr.CompleteDeferredWork()).To(HaveOccurred())
+		sysctls.failErr = nil
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(sysctls.writes).To(HaveKeyWithValue("/proc/sys/net/ipv4/conf/eth0/rp_filter", "2"))
+	})
+
+	It("should not retry interfaces that have gone down", func() {
+		mgr.OnUpdate(up("eth0"))
+		mgr.OnUpdate(&ifaceUpdate{Name: "eth0", State: ifacemonitor.StateDown})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(sysctls.writes).To(BeEmpty())
+	})
+})
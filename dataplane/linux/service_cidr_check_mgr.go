@@ -0,0 +1,53 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"github.com/projectcalico/felix/proto"
+)
+
+type serviceCIDRChecker interface {
+	OnConfiguredCIDRs(cidrs []string)
+}
+
+// serviceCIDRCheckManager passes the service cluster IPs from the BGP configuration, which drive
+// service loop prevention, to a checker that compares them with the cluster's actual service
+// CIDRs.
+type serviceCIDRCheckManager struct {
+	checker serviceCIDRChecker
+
+	pendingGlobalBGPConfig *proto.GlobalBGPConfigUpdate
+}
+
+func newServiceCIDRCheckManager(checker serviceCIDRChecker) *serviceCIDRCheckManager {
+	return &serviceCIDRCheckManager{
+		checker: checker,
+	}
+}
+
+func (m *serviceCIDRCheckManager) OnUpdate(protoBufMsg interface{}) {
+	switch msg := protoBufMsg.(type) {
+	case *proto.GlobalBGPConfigUpdate:
+		m.pendingGlobalBGPConfig = msg
+	}
+}
+
+func (m *serviceCIDRCheckManager) CompleteDeferredWork() error {
+	if m.pendingGlobalBGPConfig != nil {
+		m.checker.OnConfiguredCIDRs(m.pendingGlobalBGPConfig.GetServiceClusterCidrs())
+		m.pendingGlobalBGPConfig = nil
+	}
+	return nil
+}
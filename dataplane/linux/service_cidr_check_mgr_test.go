@@ -0,0 +1,52 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/proto"
+)
+
+type mockServiceCIDRChecker struct {
+	calls [][]string
+}
+
+func (c *mockServiceCIDRChecker) OnConfiguredCIDRs(cidrs []string) {
+	c.calls = append(c.calls, cidrs)
+}
+
+var _ = Describe("Service CIDR check manager", func() {
+	var (
+		checker *mockServiceCIDRChecker
+		mgr     *serviceCIDRCheckManager
+	)
+
+	BeforeEach(func() {
+		checker = &mockServiceCIDRChecker{}
+		mgr = newServiceCIDRCheckManager(checker)
+	})
+
+	It("should pass on the service cluster IPs once per update", func() {
+		mgr.OnUpdate(&proto.GlobalBGPConfigUpdate{
+			ServiceClusterCidrs:  []string{"10.96.0.0/12"},
+			ServiceExternalCidrs: []string{"192.0.2.0/24"},
+		})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(checker.calls).To(Equal([][]string{{"10.96.0.0/12"}}))
+	})
+})
@@ -0,0 +1,71 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/iptables"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+)
+
+var _ = Describe("Service loop manager", func() {
+	var (
+		mgr         *serviceLoopManager
+		filterTable *mockTable
+	)
+
+	BeforeEach(func() {
+		renderer := rules.NewRenderer(rules.Config{
+			IPSetConfigV4:         ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+			IPSetConfigV6:         ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+			IptablesMarkAccept:    0x8,
+			IptablesMarkPass:      0x10,
+			IptablesMarkScratch0:  0x20,
+			IptablesMarkScratch1:  0x40,
+			IptablesMarkEndpoint:  0xff00,
+			ServiceLoopPrevention: "Drop",
+		})
+		filterTable = newMockTable("filter")
+		mgr = newServiceLoopManager(filterTable, renderer, 4)
+	})
+
+	It("should program the chain without a BGP configuration", func() {
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		filterTable.checkChains([][]*iptables.Chain{{{
+			Name:  rules.ChainCIDRBlock,
+			Rules: []iptables.Rule{},
+		}}})
+	})
+
+	It("should block the service CIDRs from the BGP configuration", func() {
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		mgr.OnUpdate(&proto.GlobalBGPConfigUpdate{
+			ServiceClusterCidrs:  []string{"10.96.0.0/12"},
+			ServiceExternalCidrs: []string{"fd00:96::/112"},
+		})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		filterTable.checkChains([][]*iptables.Chain{{{
+			Name: rules.ChainCIDRBlock,
+			Rules: []iptables.Rule{{
+				Match:  iptables.Match().DestNet("10.96.0.0/12"),
+				Action: iptables.DropAction{},
+			}},
+		}}})
+	})
+})
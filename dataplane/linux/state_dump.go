@@ -0,0 +1,146 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/projectcalico/felix/bpf"
+	"github.com/projectcalico/felix/debugserver"
+)
+
+const stateDumpTimeout = 10 * time.Second
+
+var errStateDumpTimeout = errors.New("timed out waiting for the dataplane loop")
+
+// stateDumper is implemented by the dataplane components (iptables tables, IP sets, route
+// tables) that can write out their state for debugging.
+type stateDumper interface {
+	DumpState(w io.Writer) error
+}
+
+type stateDumpRequest struct {
+	dump   func() error
+	result chan error
+}
+
+func (d *InternalDataplane) registerStateDumpers() {
+	debugserver.RegisterStateDumper("iptables", d.dumpOnLoop(d.dumpIptables))
+	debugserver.RegisterStateDumper("ipsets", d.dumpOnLoop(d.dumpIPSets))
+	debugserver.RegisterStateDumper("routes", d.dumpOnLoop(d.dumpRoutes))
+	if len(d.bpfMaps) > 0 {
+		debugserver.RegisterStateDumper("bpf-maps", d.dumpBPFMaps)
+	}
+	if d.hepPolicyCounters != nil {
+		// The counters are read from the kernel and the jumps are locked so this doesn't need
+		// to run on the loop.
+		debugserver.RegisterStateDumper("hep-policy-counters", d.hepPolicyCounters.Dump)
+	}
+	if d.tcPolicyOffload != nil {
+		debugserver.RegisterStateDumper("tc-policy-offload", d.dumpOnLoop(d.tcPolicyOffload.DumpState))
+	}
+}
+
+// dumpOnLoop wraps a dump function so that it runs on the main dataplane goroutine, which owns
+// the state being dumped.  The output is buffered so that a slow client can't block the loop.
+func (d *InternalDataplane) dumpOnLoop(dump func(w io.Writer) error) debugserver.StateDumper {
+	return func(w io.Writer) error {
+		var buf bytes.Buffer
+		req := stateDumpRequest{
+			dump:   func() error { return dump(&buf) },
+			result: make(chan error, 1),
+		}
+		timeout := time.NewTimer(stateDumpTimeout)
+		defer timeout.Stop()
+		select {
+		case d.stateDumpRequests <- req:
+		case <-timeout.C:
+			return errStateDumpTimeout
+		}
+		var err error
+		select {
+		case err = <-req.result:
+		case <-timeout.C:
+			// The loop still owns buf so we mustn't touch it.
+			return errStateDumpTimeout
+		}
+		if _, werr := w.Write(buf.Bytes()); werr != nil {
+			return werr
+		}
+		return err
+	}
+}
+
+func (d *InternalDataplane) dumpIptables(w io.Writer) error {
+	for _, t := range d.allIptablesTables {
+		if err := t.DumpState(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *InternalDataplane) dumpIPSets(w io.Writer) error {
+	for _, s := range d.ipSets {
+		if sd, ok := s.(stateDumper); ok {
+			if err := sd.DumpState(w); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *InternalDataplane) dumpRoutes(w io.Writer) error {
+	for _, rt := range d.routeTableSyncers() {
+		if sd, ok := rt.(stateDumper); ok {
+			if err := sd.DumpState(w); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dumpBPFMaps writes the raw contents of the BPF maps.  The kernel handles concurrent access to
+// the maps so this doesn't need to run on the dataplane loop (which could be held up for a long
+// time by a large conntrack table).
+func (d *InternalDataplane) dumpBPFMaps(w io.Writer) error {
+	for _, m := range d.bpfMaps {
+		if _, err := fmt.Fprintf(w, "# %s (%s)\n", m.GetName(), m.Path()); err != nil {
+			return err
+		}
+		var writeErr error
+		err := m.Iter(func(k, v []byte) bpf.IteratorAction {
+			// There's no way to abort the iteration so, after a write failure, skip the rest.
+			if writeErr == nil {
+				_, writeErr = fmt.Fprintf(w, "%s: %s\n", hex.EncodeToString(k), hex.EncodeToString(v))
+			}
+			return bpf.IterNone
+		})
+		if writeErr != nil {
+			return writeErr
+		}
+		if err != nil {
+			fmt.Fprintf(w, "# failed to iterate map: %v\n", err)
+		}
+	}
+	return nil
+}
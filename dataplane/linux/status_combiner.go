@@ -15,33 +15,61 @@
 package intdataplane
 
 import (
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/projectcalico/felix/proto"
 	"github.com/projectcalico/libcalico-go/lib/set"
 )
 
+var (
+	gaugeEndpointsInError = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "felix_int_dataplane_endpoints_in_error",
+		Help: "Number of local endpoints that Felix failed to program and is reporting as in error.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(gaugeEndpointsInError)
+}
+
 // endpointStatusCombiner combines the status reports of endpoints from the IPv4 and IPv6
 // endpoint managers.  Where conflicts occur, it reports the "worse" status.
 type endpointStatusCombiner struct {
-	ipVersionToStatuses map[uint8]map[interface{}]string
+	ipVersionToStatuses map[uint8]map[interface{}]endpointStatus
 	dirtyIDs            set.Set
-	fromDataplane       chan interface{}
+	idsInError          set.Set
+	// idsWithNewGenerations contains the IDs of endpoints whose latest policy generation hasn't
+	// been programmed yet.
+	idsWithNewGenerations set.Set
+	fromDataplane         chan interface{}
+}
+
+type endpointStatus struct {
+	status string
+	reason string
+	// policyGeneration is the latest generation that the endpoint manager has rendered and
+	// programmedGeneration the latest one that the dataplane has programmed, which is the one
+	// that we report.
+	policyGeneration     uint64
+	programmedGeneration uint64
 }
 
 func newEndpointStatusCombiner(fromDataplane chan interface{}, ipv6Enabled bool) *endpointStatusCombiner {
 	e := &endpointStatusCombiner{
-		ipVersionToStatuses: map[uint8]map[interface{}]string{},
-		dirtyIDs:            set.New(),
-		fromDataplane:       fromDataplane,
+		ipVersionToStatuses:   map[uint8]map[interface{}]endpointStatus{},
+		dirtyIDs:              set.New(),
+		idsInError:            set.New(),
+		idsWithNewGenerations: set.New(),
+		fromDataplane:         fromDataplane,
 	}
 
 	// IPv4 is always enabled.
-	e.ipVersionToStatuses[4] = map[interface{}]string{}
+	e.ipVersionToStatuses[4] = map[interface{}]endpointStatus{}
 	if ipv6Enabled {
 		// If IPv6 is enabled, track the IPv6 state too.  We use the presence of this
 		// extra map to trigger merging.
-		e.ipVersionToStatuses[6] = map[interface{}]string{}
+		e.ipVersionToStatuses[6] = map[interface{}]endpointStatus{}
 	}
 	return e
 }
@@ -50,29 +78,73 @@ func (e *endpointStatusCombiner) OnEndpointStatusUpdate(
 	ipVersion uint8,
 	id interface{}, // proto.HostEndpointID or proto.WorkloadEndpointID
 	status string,
+	reason string,
+	policyGeneration uint64,
 ) {
 	log.WithFields(log.Fields{
-		"ipVersion": ipVersion,
-		"workload":  id,
-		"status":    status,
+		"ipVersion":        ipVersion,
+		"workload":         id,
+		"status":           status,
+		"reason":           reason,
+		"policyGeneration": policyGeneration,
 	}).Info("Storing endpoint status update")
 	e.dirtyIDs.Add(id)
 	if status == "" {
 		delete(e.ipVersionToStatuses[ipVersion], id)
 	} else {
-		e.ipVersionToStatuses[ipVersion][id] = status
+		old := e.ipVersionToStatuses[ipVersion][id]
+		e.ipVersionToStatuses[ipVersion][id] = endpointStatus{
+			status:               status,
+			reason:               reason,
+			policyGeneration:     policyGeneration,
+			programmedGeneration: old.programmedGeneration,
+		}
+		if policyGeneration != old.programmedGeneration {
+			e.idsWithNewGenerations.Add(id)
+		}
 	}
 }
 
-func (e *endpointStatusCombiner) Apply() {
+// Apply reports the statuses that have changed.  dataplaneInSync should be true if the dataplane
+// has programmed everything that the managers have rendered, in which case the endpoints' latest
+// policy generations have been programmed; otherwise we keep reporting their previous generations.
+func (e *endpointStatusCombiner) Apply(dataplaneInSync bool) {
+	if dataplaneInSync {
+		e.idsWithNewGenerations.Iter(func(id interface{}) error {
+			for _, statuses := range e.ipVersionToStatuses {
+				if s, ok := statuses[id]; ok {
+					s.programmedGeneration = s.policyGeneration
+					statuses[id] = s
+				}
+			}
+			e.dirtyIDs.Add(id)
+			return set.RemoveItem
+		})
+	}
 	e.dirtyIDs.Iter(func(id interface{}) error {
 		statusToReport := ""
+		reasonToReport := ""
+		// Report the oldest generation that has been programmed for all IP versions.
+		var generationToReport uint64
+		generationKnown := false
 		logCxt := log.WithField("id", id)
-		for ipVer, statuses := range e.ipVersionToStatuses {
-			status := statuses[id]
+		for _, ipVer := range []uint8{4, 6} {
+			statuses, ok := e.ipVersionToStatuses[ipVer]
+			if !ok {
+				continue
+			}
+			status := statuses[id].status
+			if status != "" && (!generationKnown || statuses[id].programmedGeneration < generationToReport) {
+				generationToReport = statuses[id].programmedGeneration
+				generationKnown = true
+			}
 			logCxt := logCxt.WithField("ipVersion", ipVer).WithField("status", status)
 			if status == "error" {
 				logCxt.Info("Endpoint is in error, will report error")
+				if statusToReport != "error" {
+					// Report the reason from the first IP version that failed.
+					reasonToReport = statuses[id].reason
+				}
 				statusToReport = "error"
 			} else if status == "down" && statusToReport != "error" {
 				logCxt.Info("Endpoint down for at least one IP version")
@@ -82,6 +154,11 @@ func (e *endpointStatusCombiner) Apply() {
 				statusToReport = "up"
 			}
 		}
+		if statusToReport == "error" {
+			e.idsInError.Add(id)
+		} else {
+			e.idsInError.Discard(id)
+		}
 		if statusToReport == "" {
 			logCxt.Info("Reporting endpoint removed.")
 			switch id := id.(type) {
@@ -95,13 +172,20 @@ func (e *endpointStatusCombiner) Apply() {
 				}
 			}
 		} else {
-			logCxt.WithField("status", statusToReport).Info("Reporting combined status.")
+			logCxt = logCxt.WithField("status", statusToReport)
+			if reasonToReport != "" {
+				logCxt.WithField("reason", reasonToReport).Warn("Reporting endpoint programming failure.")
+			} else {
+				logCxt.Info("Reporting combined status.")
+			}
 			switch id := id.(type) {
 			case proto.WorkloadEndpointID:
 				e.fromDataplane <- &proto.WorkloadEndpointStatusUpdate{
 					Id: &id,
 					Status: &proto.EndpointStatus{
-						Status: statusToReport,
+						Status:           statusToReport,
+						Reason:           reasonToReport,
+						PolicyGeneration: generationToReport,
 					},
 				}
 			case proto.HostEndpointID:
@@ -109,10 +193,12 @@ func (e *endpointStatusCombiner) Apply() {
 					Id: &id,
 					Status: &proto.EndpointStatus{
 						Status: statusToReport,
+						Reason: reasonToReport,
 					},
 				}
 			}
 		}
 		return set.RemoveItem
 	})
+	gaugeEndpointsInError.Set(float64(e.idsInError.Len()))
 }
@@ -48,12 +48,12 @@ var _ = Describe("StatusCombiner", func() {
 				done := make(chan bool)
 				go func() {
 					statusCombiner.OnEndpointStatusUpdate(
-						4, epID, v4Status,
+						4, epID, v4Status, "", 0,
 					)
 					statusCombiner.OnEndpointStatusUpdate(
-						6, epID, v6Status,
+						6, epID, v6Status, "", 0,
 					)
-					statusCombiner.Apply()
+					statusCombiner.Apply(true)
 					done <- true
 				}()
 				Eventually(fromDataplane).Should(Receive(Equal(
@@ -71,12 +71,12 @@ var _ = Describe("StatusCombiner", func() {
 				// Then remove the status, should get cleaned up.
 				go func() {
 					statusCombiner.OnEndpointStatusUpdate(
-						4, epID, "",
+						4, epID, "", "", 0,
 					)
 					statusCombiner.OnEndpointStatusUpdate(
-						6, epID, "",
+						6, epID, "", "", 0,
 					)
-					statusCombiner.Apply()
+					statusCombiner.Apply(true)
 				}()
 				Eventually(fromDataplane).Should(Receive(Equal(
 					&proto.WorkloadEndpointStatusRemove{
@@ -97,6 +97,67 @@ var _ = Describe("StatusCombiner", func() {
 			Entry("error, up == error", "error", "up", "error"),
 			Entry("error, down == error", "error", "down", "error"),
 		)
+
+		It("should report the reason from the IP version that is in error", func() {
+			go func() {
+				statusCombiner.OnEndpointStatusUpdate(4, epID, "up", "", 0)
+				statusCombiner.OnEndpointStatusUpdate(6, epID, "error", "failed to configure interface", 0)
+				statusCombiner.Apply(true)
+			}()
+			Eventually(fromDataplane).Should(Receive(Equal(
+				&proto.WorkloadEndpointStatusUpdate{
+					Id: &epID,
+					Status: &proto.EndpointStatus{
+						Status: "error",
+						Reason: "failed to configure interface",
+					},
+				},
+			)))
+		})
+
+		It("should report the oldest policy generation across IP versions", func() {
+			go func() {
+				statusCombiner.OnEndpointStatusUpdate(4, epID, "up", "", 3)
+				statusCombiner.OnEndpointStatusUpdate(6, epID, "up", "", 2)
+				statusCombiner.Apply(true)
+			}()
+			Eventually(fromDataplane).Should(Receive(Equal(
+				&proto.WorkloadEndpointStatusUpdate{
+					Id: &epID,
+					Status: &proto.EndpointStatus{
+						Status:           "up",
+						PolicyGeneration: 2,
+					},
+				},
+			)))
+		})
+	})
+
+	Describe("with a dataplane that is out of sync", func() {
+		BeforeEach(func() {
+			statusCombiner = newEndpointStatusCombiner(fromDataplane, false)
+		})
+
+		It("should only report a policy generation once it is programmed", func() {
+			go func() {
+				statusCombiner.OnEndpointStatusUpdate(4, epID, "up", "", 3)
+				statusCombiner.Apply(true)
+				statusCombiner.OnEndpointStatusUpdate(4, epID, "up", "", 4)
+				statusCombiner.Apply(false)
+				statusCombiner.Apply(true)
+			}()
+			for _, generation := range []uint64{3, 3, 4} {
+				Eventually(fromDataplane).Should(Receive(Equal(
+					&proto.WorkloadEndpointStatusUpdate{
+						Id: &epID,
+						Status: &proto.EndpointStatus{
+							Status:           "up",
+							PolicyGeneration: generation,
+						},
+					},
+				)))
+			}
+		})
 	})
 
 	Describe("with IPv6 disabled", func() {
@@ -111,9 +172,9 @@ var _ = Describe("StatusCombiner", func() {
 				done := make(chan bool)
 				go func() {
 					statusCombiner.OnEndpointStatusUpdate(
-						4, epID, v4Status,
+						4, epID, v4Status, "", 0,
 					)
-					statusCombiner.Apply()
+					statusCombiner.Apply(true)
 					done <- true
 				}()
 				Eventually(fromDataplane).Should(Receive(Equal(
@@ -131,9 +192,9 @@ var _ = Describe("StatusCombiner", func() {
 				// Then remove the status, should get cleaned up.
 				go func() {
 					statusCombiner.OnEndpointStatusUpdate(
-						4, epID, "",
+						4, epID, "", "", 0,
 					)
-					statusCombiner.Apply()
+					statusCombiner.Apply(true)
 				}()
 				Eventually(fromDataplane).Should(Receive(Equal(
 					&proto.WorkloadEndpointStatusRemove{
@@ -0,0 +1,278 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/ifacemonitor"
+)
+
+const procSysPrefix = "/proc/sys/"
+
+// sysctlNameRegexp matches the sysctl names that we accept; "*" stands for any interface.
+var sysctlNameRegexp = regexp.MustCompile(`^[a-z0-9_.\-/*]+$`)
+
+var (
+	countSysctlDriftRepairs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "felix_sysctl_drift_repairs",
+		Help: "Number of times that Felix found a sysctl that it manages set to an unexpected " +
+			"value (usually because another agent changed it) and set it back.",
+	}, []string{"sysctl"})
+	countSysctlWriteFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "felix_sysctl_write_failures",
+		Help: "Number of failed attempts to set a sysctl.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(countSysctlDriftRepairs)
+	prometheus.MustRegister(countSysctlWriteFailures)
+}
+
+// sysctlManager owns the sysctls that Felix sets.  Other components declare the values that they
+// need via SetSysctl, which writes the value straight away (so that callers can handle errors as
+// before) and records it as desired state.  After QueueResync is called, CompleteDeferredWork
+// re-reads all the desired sysctls and repairs any that have been changed behind our back.
+//
+// The user can override the value of any sysctl that Felix sets, or ask Felix to manage extra
+// sysctls, using sysctl names (as used by the sysctl tool), for example
+// "net.ipv4.conf.all.rp_filter".  In an override, "*" can be used in place of an interface name
+// to match all the interfaces that Felix configures.
+type sysctlManager struct {
+	// desired maps from /proc/sys path to the value that Felix wants for that sysctl, before
+	// overrides are applied.
+	desired map[string]string
+	// overrides maps from /proc/sys path (which may contain a "*" wildcard) to the value
+	// configured by the user.
+	overrides map[string]string
+
+	resyncPending bool
+
+	readProcSys  func(path string) (string, error)
+	writeProcSys procSysWriter
+}
+
+func newSysctlManager(overrides map[string]string) *sysctlManager {
+	return newSysctlManagerWithShims(overrides, readProcSys, writeProcSys)
+}
+
+func newSysctlManagerWithShims(
+	overrides map[string]string,
+	procSysReader func(path string) (string, error),
+	procSysWriter procSysWriter,
+) *sysctlManager {
+	m := &sysctlManager{
+		desired:      map[string]string{},
+		overrides:    map[string]string{},
+		readProcSys:  procSysReader,
+		writeProcSys: procSysWriter,
+	}
+	for name, value := range overrides {
+		p, err := sysctlNameToPath(name)
+		if err != nil {
+			log.WithError(err).WithField("sysctl", name).Error("Ignoring invalid sysctl override.")
+			continue
+		}
+		log.WithFields(log.Fields{"sysctl": name, "path": p, "value": value}).Info(
+			"Configured sysctl override.")
+		m.overrides[p] = value
+	}
+	// Make sure that we apply the non-wildcard overrides on the first pass, even if nothing
+	// else asks for those sysctls.
+	m.resyncPending = true
+	return m
+}
+
+// SetSysctl records that Felix wants the sysctl at the given /proc/sys path to have the given
+// value and writes it (or the user's override for it).  It has the same signature as
+// writeProcSys so that it can be used in its place.
+func (m *sysctlManager) SetSysctl(path, value string) error {
+	m.desired[path] = value
+	value = m.effectiveValue(path, value)
+	err := m.writeProcSys(path, value)
+	if err != nil {
+		countSysctlWriteFailures.Inc()
+	}
+	return err
+}
+
+func (m *sysctlManager) QueueResync() {
+	m.resyncPending = true
+}
+
+func (m *sysctlManager) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *ifaceUpdate:
+		if msg.State == ifacemonitor.StateUp {
+			return
+		}
+		// The interface is down or gone, forget about its sysctls.  If it comes back up,
+		// whoever configured it before will configure it again.
+		for p := range m.desired {
+			if sysctlPathIface(p) == msg.Name {
+				log.WithFields(log.Fields{"ifaceName": msg.Name, "path": p}).Debug(
+					"Interface down, forgetting its sysctl.")
+				delete(m.desired, p)
+			}
+		}
+	}
+}
+
+func (m *sysctlManager) CompleteDeferredWork() error {
+	if !m.resyncPending {
+		return nil
+	}
+	var lastErr error
+	for p, value := range m.allDesired() {
+		current, err := m.readProcSys(p)
+		if os.IsNotExist(err) {
+			// Usually an interface that has gone away and we haven't heard yet.
+			log.WithField("path", p).Debug("Sysctl doesn't exist, ignoring.")
+			continue
+		} else if err != nil {
+			log.WithError(err).WithField("path", p).Warn("Failed to read sysctl.")
+			lastErr = err
+			continue
+		}
+		if normaliseSysctlValue(current) == normaliseSysctlValue(value) {
+			continue
+		}
+		log.WithFields(log.Fields{
+			"path":     p,
+			"current":  current,
+			"expected": value,
+		}).Warn("Sysctl has unexpected value, another agent may have changed it. Setting it back.")
+		countSysctlDriftRepairs.WithLabelValues(sysctlMetricName(p)).Inc()
+		if err := m.writeProcSys(p, value); err != nil {
+			log.WithError(err).WithField("path", p).Warn("Failed to set sysctl.")
+			countSysctlWriteFailures.Inc()
+			lastErr = err
+		}
+	}
+	if lastErr != nil {
+		return lastErr
+	}
+	m.resyncPending = false
+	return nil
+}
+
+// allDesired returns the effective value of every sysctl that we manage, including the
+// non-wildcard overrides.
+func (m *sysctlManager) allDesired() map[string]string {
+	all := map[string]string{}
+	for p, value := range m.desired {
+		all[p] = m.effectiveValue(p, value)
+	}
+	for p, value := range m.overrides {
+		if !strings.Contains(p, "*") {
+			all[p] = value
+		}
+	}
+	return all
+}
+
+func (m *sysctlManager) effectiveValue(p, value string) string {
+	if override, ok := m.overrides[p]; ok {
+		return override
+	}
+	// Check the wildcard overrides in a deterministic order, in case more than one matches.
+	var patterns []string
+	for pattern := range m.overrides {
+		if strings.Contains(pattern, "*") {
+			patterns = append(patterns, pattern)
+		}
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, p); matched {
+			return m.overrides[pattern]
+		}
+	}
+	return value
+}
+
+// sysctlNameToPath converts a sysctl name, such as "net.ipv4.conf.eth0/100.rp_filter", to its
+// /proc/sys path.  As in the sysctl tool, "." and "/" are swapped so that the name can refer to
+// interfaces that have dots in their names.  Since we write to the path as root, names that
+// aren't sysctl names, or that would escape /proc/sys, are rejected.
+func sysctlNameToPath(name string) (string, error) {
+	if !sysctlNameRegexp.MatchString(name) {
+		return "", fmt.Errorf("invalid sysctl name %q", name)
+	}
+	p := strings.Map(func(r rune) rune {
+		switch r {
+		case '.':
+			return '/'
+		case '/':
+			return '.'
+		}
+		return r
+	}, name)
+	for _, part := range strings.Split(p, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", fmt.Errorf("invalid sysctl name %q", name)
+		}
+	}
+	p = path.Clean(procSysPrefix + p)
+	if !strings.HasPrefix(p, procSysPrefix) {
+		return "", fmt.Errorf("sysctl name %q is outside %s", name, procSysPrefix)
+	}
+	return p, nil
+}
+
+// sysctlPathIface returns the interface that a per-interface sysctl applies to, for example
+// "eth0" for "/proc/sys/net/ipv4/conf/eth0/rp_filter", or "" if the sysctl isn't per-interface.
+func sysctlPathIface(p string) string {
+	parts := strings.Split(strings.TrimPrefix(p, procSysPrefix), "/")
+	// net/<family>/<conf|neigh>/<iface>/<sysctl>
+	if len(parts) != 5 || parts[0] != "net" || (parts[2] != "conf" && parts[2] != "neigh") {
+		return ""
+	}
+	return parts[3]
+}
+
+// sysctlMetricName returns the sysctl's name for use as a metric label.  To keep the cardinality
+// down, interface names are replaced by "*", apart from the special "all" and "default" entries.
+func sysctlMetricName(p string) string {
+	iface := sysctlPathIface(p)
+	parts := strings.Split(strings.TrimPrefix(p, procSysPrefix), "/")
+	if iface != "" && iface != "all" && iface != "default" {
+		parts[3] = "*"
+	}
+	return strings.Join(parts, ".")
+}
+
+func normaliseSysctlValue(value string) string {
+	// Multi-value sysctls, such as ip_local_port_range, are tab-separated when read back.
+	return strings.Join(strings.Fields(value), " ")
+}
+
+func readProcSys(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
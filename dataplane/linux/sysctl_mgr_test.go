@@ -0,0 +1,195 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"errors"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/ifacemonitor"
+)
+
+type mockSysctls struct {
+	values  map[string]string
+	writes  map[string]string
+	failErr error
+}
+
+func (s *mockSysctls) read(path string) (string, error) {
+	v, ok := s.values[path]
+	if !ok {
+		return "", os.ErrNotExist
+	}
+	return v + "\n", nil
+}
+
+func (s *mockSysctls) write(path, value string) error {
+	if s.failErr != nil {
+		return s.failErr
+	}
+	s.values[path] = value
+	s.writes[path] = value
+	return nil
+}
+
+var _ = Describe("Sysctl manager", func() {
+	var (
+		sysctls *mockSysctls
+		mgr     *sysctlManager
+	)
+
+	const (
+		rpFilter    = "/proc/sys/net/ipv4/conf/all/rp_filter"
+		caliProxy   = "/proc/sys/net/ipv4/conf/cali1234/proxy_arp"
+		ethForward  = "/proc/sys/net/ipv4/conf/eth0.100/forwarding"
+		ipForward   = "/proc/sys/net/ipv4/ip_forward"
+		portRange   = "/proc/sys/net/ipv4/ip_local_port_range"
+		unspecified = "/proc/sys/net/ipv4/conf/default/rp_filter"
+	)
+
+	BeforeEach(func() {
+		sysctls = &mockSysctls{
+			values: map[string]string{
+				rpFilter:    "1",
+				unspecified: "1",
+				portRange:   "32768\t60999",
+			},
+			writes: map[string]string{},
+		}
+		mgr = newSysctlManagerWithShims(map[string]string{
+			"net.ipv4.conf.all.rp_filter":       "2",
+			"net.ipv4.conf.*.proxy_arp":         "0",
+			"net.ipv4.conf.eth0/100.forwarding": "0",
+		}, sysctls.read, sysctls.write)
+	})
+
+	It("should apply non-wildcard overrides on the first pass", func() {
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(sysctls.writes).To(Equal(map[string]string{rpFilter: "2"}))
+	})
+
+	It("should write values, applying overrides", func() {
+		Expect(mgr.SetSysctl(ipForward, "1")).To(Succeed())
+		Expect(mgr.SetSysctl(caliProxy, "1")).To(Succeed())
+		Expect(mgr.SetSysctl(ethForward, "1")).To(Succeed())
+		Expect(sysctls.writes).To(Equal(map[string]string{
+			ipForward:  "1",
+			caliProxy:  "0",
+			ethForward: "0",
+		}))
+	})
+
+	It("should return write errors", func() {
+		sysctls.failErr = errors.New("failed")
+		Expect(mgr.SetSysctl(ipForward, "1")).To(HaveOccurred())
+	})
+
+	Describe("after the first pass", func() {
+		BeforeEach(func() {
+			Expect(mgr.SetSysctl(ipForward, "1")).To(Succeed())
+			Expect(mgr.SetSysctl(caliProxy, "1")).To(Succeed())
+			Expect(mgr.SetSysctl(portRange, "32768 60999")).To(Succeed())
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			sysctls.writes = map[string]string{}
+		})
+
+		It("should only check for drift after a resync is queued", func() {
+			sysctls.values[ipForward] = "0"
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(sysctls.writes).To(BeEmpty())
+		})
+
+		It("should do nothing if there's no drift", func() {
+			mgr.QueueResync()
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(sysctls.writes).To(BeEmpty())
+		})
+
+		It("should repair drift", func() {
+			sysctls.values[ipForward] = "0"
+			sysctls.values[caliProxy] = "1"
+			sysctls.values[rpFilter] = "1"
+			mgr.QueueResync()
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(sysctls.writes).To(Equal(map[string]string{
+				ipForward: "1",
+				caliProxy: "0",
+				rpFilter:  "2",
+			}))
+		})
+
+		It("should retry failed repairs", func() {
+			sysctls.values[ipForward] = "0"
+			sysctls.failErr = errors.New("failed")
+			mgr.QueueResync()
+			Expect(mgr.CompleteDeferredWork()).To(HaveOccurred())
+			sysctls.failErr = nil
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(sysctls.writes).To(Equal(map[string]string{ipForward: "1"}))
+		})
+
+		It("should ignore sysctls that have disappeared", func() {
+			delete(sysctls.values, caliProxy)
+			mgr.QueueResync()
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(sysctls.writes).To(BeEmpty())
+		})
+
+		It("should forget an interface's sysctls when it goes down", func() {
+			mgr.OnUpdate(&ifaceUpdate{Name: "cali1234", State: ifacemonitor.StateDown})
+			sysctls.values[caliProxy] = "1"
+			mgr.QueueResync()
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(sysctls.writes).To(BeEmpty())
+		})
+	})
+
+	It("should convert sysctl names to paths", func() {
+		Expect(sysctlNameToPath("net.ipv4.conf.eth0/100.rp_filter")).To(Equal(
+			"/proc/sys/net/ipv4/conf/eth0.100/rp_filter"))
+		Expect(sysctlNameToPath("net.ipv4.ip_forward")).To(Equal(ipForward))
+	})
+
+	It("should reject sysctl names that escape /proc/sys", func() {
+		for _, name := range []string{
+			"/etc/passwd",
+			"/proc/sys/net/ipv4/ip_forward",
+			"net.//.//.//etc.shadow",
+			"net..ipv4",
+			"net.ipv4.conf.all.rp_filter ",
+			"",
+		} {
+			_, err := sysctlNameToPath(name)
+			Expect(err).To(HaveOccurred(), name)
+		}
+	})
+
+	It("should ignore invalid overrides", func() {
+		m := newSysctlManagerWithShims(map[string]string{
+			"net.//.//.//etc.shadow":      "x",
+			"net.ipv4.conf.all.rp_filter": "2",
+		}, nil, nil)
+		Expect(m.overrides).To(Equal(map[string]string{rpFilter: "2"}))
+	})
+
+	It("should calculate metric names", func() {
+		Expect(sysctlMetricName(caliProxy)).To(Equal("net.ipv4.conf.*.proxy_arp"))
+		Expect(sysctlMetricName(rpFilter)).To(Equal("net.ipv4.conf.all.rp_filter"))
+		Expect(sysctlMetricName(ipForward)).To(Equal("net.ipv4.ip_forward"))
+	})
+})
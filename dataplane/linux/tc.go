@@ -0,0 +1,44 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const cmdTC = "tc"
+
+// runTC runs tc with the given arguments, including tc's error output in the returned error.
+func runTC(newCmd cmdFactory, args ...string) error {
+	_, err := newCmd(cmdTC, args...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return fmt.Errorf("tc %s failed: %s", strings.Join(args, " "), strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return fmt.Errorf("tc %s failed: %w", strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+// runTCIgnoreErr runs a tc command that is expected to fail if there's nothing to remove.
+func runTCIgnoreErr(newCmd cmdFactory, args ...string) {
+	if err := runTC(newCmd, args...); err != nil {
+		log.WithError(err).Debug("Ignoring tc error")
+	}
+}
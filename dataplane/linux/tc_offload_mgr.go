@@ -0,0 +1,602 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/ifacemonitor"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/libcalico-go/lib/set"
+)
+
+// maxTCOffloadFilters limits the number of flower filters that we install on one interface; a
+// rule whose CIDRs and ports would take us over the limit, and the rules after it, are left to
+// iptables.
+const maxTCOffloadFilters = 1000
+
+// Our filters have priorities tcOffloadFirstPrio to tcOffloadFirstPrio+maxTCOffloadFilters-1, in
+// order.  We share the ingress qdisc with anything else that uses it and only remove filters in
+// that range, so that others' filters are left alone.
+const tcOffloadFirstPrio = 4096
+
+// tcFilterPrioRegexp extracts the priorities from the output of "tc filter show".
+var tcFilterPrioRegexp = regexp.MustCompile(`\bpref (\d+)\b`)
+
+type tcOffloadMode string
+
+const (
+	tcOffloadHardware    tcOffloadMode = "hardware"
+	tcOffloadSoftware    tcOffloadMode = "software"
+	tcOffloadUnsupported tcOffloadMode = "unsupported"
+)
+
+var gaugeTCOffloadRules = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "felix_tc_offload_rules",
+	Help: "Number of untracked host endpoint policy rules by tc offload mode (hardware, software or unsupported).",
+}, []string{"mode"})
+
+func init() {
+	prometheus.MustRegister(gaugeTCOffloadRules)
+}
+
+// tcFlowerFilter is one flower filter; its empty fields match anything.
+type tcFlowerFilter struct {
+	ipVersion uint8
+	protocol  string
+	srcNet    string
+	dstNet    string
+	dstPort   string
+	action    string
+}
+
+func (f tcFlowerFilter) args(ifaceName string, idx int, skipSW bool) []string {
+	protocol := "ip"
+	if f.ipVersion == 6 {
+		protocol = "ipv6"
+	}
+	args := []string{"filter", "add", "dev", ifaceName, "parent", "ffff:",
+		"protocol", protocol, "prio", fmt.Sprint(tcOffloadFirstPrio + idx), "flower"}
+	if skipSW {
+		args = append(args, "skip_sw")
+	}
+	if f.protocol != "" {
+		args = append(args, "ip_proto", f.protocol)
+	}
+	if f.srcNet != "" {
+		args = append(args, "src_ip", f.srcNet)
+	}
+	if f.dstNet != "" {
+		args = append(args, "dst_ip", f.dstNet)
+	}
+	if f.dstPort != "" {
+		args = append(args, "dst_port", f.dstPort)
+	}
+	return append(args, "action", f.action)
+}
+
+// tcOffloadRuleStatus records how one rule of an untracked policy is enforced on an interface.
+// Its filters are filters[firstFilter:firstFilter+numFilters] of the interface's filters.
+type tcOffloadRuleStatus struct {
+	policy      string
+	rule        int
+	firstFilter int
+	numFilters  int
+	mode        tcOffloadMode
+}
+
+type tcOffloadIfaceState struct {
+	filters []tcFlowerFilter
+	// inHardware records, for each filter, whether the NIC accepted it with skip_sw.
+	inHardware []bool
+	rules      []tcOffloadRuleStatus
+}
+
+type hostEndpointUntrackedPolicies struct {
+	ifaceName string
+	policies  []proto.PolicyID
+}
+
+// tcPolicyOffloadManager copies the simple rules of untracked host endpoint policies to tc
+// flower filters on the host endpoint's interface, asking the NIC to run them in hardware
+// (skip_sw).  Where the NIC refuses a filter, that filter and the ones after it run in software,
+// which keeps the filters in order.  The filters reproduce the start of the raw chain: the
+// failsafe ports pass, then each policy's ingress rules in order, with allow and pass rules
+// passing the packet on to the kernel and deny rules dropping it.  At the first rule that flower
+// can't express (for example one that uses a selector) we stop; iptables still evaluates all the
+// policies for the packets that get through, so the filters only make drops cheaper.
+type tcPolicyOffloadManager struct {
+	ifacePatterns []*regexp.Regexp
+	failsafePorts []config.ProtoPort
+	newCmd        cmdFactory
+
+	policies      map[proto.PolicyID][]*proto.Rule
+	hostEndpoints map[proto.HostEndpointID]hostEndpointUntrackedPolicies
+	upIfaces      set.Set
+
+	// applied holds what we've programmed on each interface.  An interface that isn't in the map
+	// may have leftover filters, so we look for filters in our priority range when we first sync
+	// it.
+	applied     map[string]*tcOffloadIfaceState
+	dirtyIfaces set.Set
+}
+
+func newTCPolicyOffloadManager(
+	ifacePatterns []*regexp.Regexp,
+	failsafePorts []config.ProtoPort,
+	newCmd cmdFactory,
+) *tcPolicyOffloadManager {
+	return &tcPolicyOffloadManager{
+		ifacePatterns: ifacePatterns,
+		failsafePorts: failsafePorts,
+		newCmd:        newCmd,
+		policies:      map[proto.PolicyID][]*proto.Rule{},
+		hostEndpoints: map[proto.HostEndpointID]hostEndpointUntrackedPolicies{},
+		upIfaces:      set.New(),
+		applied:       map[string]*tcOffloadIfaceState{},
+		dirtyIfaces:   set.New(),
+	}
+}
+
+func (m *tcPolicyOffloadManager) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *proto.ActivePolicyUpdate:
+		if !msg.Policy.Untracked {
+			if _, ok := m.policies[*msg.Id]; !ok {
+				return
+			}
+			delete(m.policies, *msg.Id)
+		} else {
+			m.policies[*msg.Id] = msg.Policy.InboundRules
+		}
+		m.markPolicyDirty(*msg.Id)
+	case *proto.ActivePolicyRemove:
+		if _, ok := m.policies[*msg.Id]; ok {
+			delete(m.policies, *msg.Id)
+			m.markPolicyDirty(*msg.Id)
+		}
+	case *proto.HostEndpointUpdate:
+		if old, ok := m.hostEndpoints[*msg.Id]; ok {
+			m.dirtyIfaces.Add(old.ifaceName)
+		}
+		hep := hostEndpointUntrackedPolicies{ifaceName: msg.Endpoint.Name}
+		if len(msg.Endpoint.UntrackedTiers) > 0 {
+			tier := msg.Endpoint.UntrackedTiers[0]
+			for _, name := range tier.IngressPolicies {
+				hep.policies = append(hep.policies, proto.PolicyID{Tier: tier.Name, Name: name})
+			}
+		}
+		m.hostEndpoints[*msg.Id] = hep
+		m.dirtyIfaces.Add(hep.ifaceName)
+	case *proto.HostEndpointRemove:
+		if old, ok := m.hostEndpoints[*msg.Id]; ok {
+			m.dirtyIfaces.Add(old.ifaceName)
+			delete(m.hostEndpoints, *msg.Id)
+		}
+	case *ifaceUpdate:
+		if !m.matchesIface(msg.Name) {
+			return
+		}
+		// The interface may have been recreated without our filters.
+		delete(m.applied, msg.Name)
+		if msg.State == ifacemonitor.StateUp {
+			m.upIfaces.Add(msg.Name)
+			m.dirtyIfaces.Add(msg.Name)
+		} else {
+			m.upIfaces.Discard(msg.Name)
+			m.dirtyIfaces.Discard(msg.Name)
+		}
+	}
+}
+
+func (m *tcPolicyOffloadManager) markPolicyDirty(id proto.PolicyID) {
+	for _, hep := range m.hostEndpoints {
+		for _, p := range hep.policies {
+			if p == id {
+				m.dirtyIfaces.Add(hep.ifaceName)
+				break
+			}
+		}
+	}
+}
+
+func (m *tcPolicyOffloadManager) matchesIface(name string) bool {
+	for _, re := range m.ifacePatterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *tcPolicyOffloadManager) CompleteDeferredWork() error {
+	if m.dirtyIfaces.Len() == 0 {
+		return nil
+	}
+
+	var lastErr error
+	m.dirtyIfaces.Iter(func(item interface{}) error {
+		ifaceName := item.(string)
+		if !m.upIfaces.Contains(ifaceName) {
+			// Either not an interface that we offload to or it's down; for the latter, we'll
+			// get an interface update when it comes up.
+			return set.RemoveItem
+		}
+		if err := m.syncIface(ifaceName); err != nil {
+			log.WithError(err).WithField("iface", ifaceName).Warn(
+				"Failed to offload untracked policy to tc, will retry")
+			lastErr = err
+			return nil
+		}
+		return set.RemoveItem
+	})
+	m.updateGauges()
+	return lastErr
+}
+
+func (m *tcPolicyOffloadManager) syncIface(ifaceName string) error {
+	filters, rules := m.desiredFilters(ifaceName)
+	applied, known := m.applied[ifaceName]
+	if known && reflect.DeepEqual(applied.filters, filters) {
+		// The policy names or rule numbers may have changed even though the filters haven't.
+		applied.rules = rules
+		applied.updateRuleModes()
+		return nil
+	}
+
+	logCxt := log.WithFields(log.Fields{"iface": ifaceName, "numFilters": len(filters)})
+	logCxt.Info("Updating tc offload of untracked policy")
+	delete(m.applied, ifaceName)
+
+	// Keep the filters that haven't changed at the start of the list and remove the rest.
+	state := &tcOffloadIfaceState{rules: rules}
+	if known {
+		for len(state.filters) < len(applied.filters) && len(state.filters) < len(filters) &&
+			applied.filters[len(state.filters)] == filters[len(state.filters)] {
+			state.filters = append(state.filters, filters[len(state.filters)])
+			state.inHardware = append(state.inHardware, applied.inHardware[len(state.inHardware)])
+		}
+		for i := len(state.filters); i < len(applied.filters); i++ {
+			m.tcIgnoreErr("filter", "del", "dev", ifaceName, "parent", "ffff:",
+				"prio", fmt.Sprint(tcOffloadFirstPrio+i))
+		}
+	} else {
+		for _, prio := range m.leftoverPrios(ifaceName) {
+			m.tcIgnoreErr("filter", "del", "dev", ifaceName, "parent", "ffff:", "prio", fmt.Sprint(prio))
+		}
+	}
+
+	if len(filters) > len(state.filters) {
+		// The ingress qdisc may already exist, in which case we share it.
+		m.tcIgnoreErr("qdisc", "add", "dev", ifaceName, "ingress")
+	}
+	// Filters in hardware run before those in software so, once the NIC refuses one, we put
+	// the rest in software too.
+	hardware := len(state.inHardware) == 0 || state.inHardware[len(state.inHardware)-1]
+	for i := len(state.filters); i < len(filters); i++ {
+		f := filters[i]
+		if hardware {
+			err := m.tc(f.args(ifaceName, i, true)...)
+			if err == nil {
+				state.filters = append(state.filters, f)
+				state.inHardware = append(state.inHardware, true)
+				continue
+			}
+			logCxt.WithError(err).Info("NIC can't offload filter, falling back to software")
+			hardware = false
+		}
+		// If this fails then the filters that we've added are a prefix of the policy, which
+		// is still correct.
+		if err := m.tc(f.args(ifaceName, i, false)...); err != nil {
+			return err
+		}
+		state.filters = append(state.filters, f)
+		state.inHardware = append(state.inHardware, false)
+	}
+	state.updateRuleModes()
+	m.applied[ifaceName] = state
+	return nil
+}
+
+// leftoverPrios returns the priorities in our range of the filters on the interface's ingress
+// qdisc.
+func (m *tcPolicyOffloadManager) leftoverPrios(ifaceName string) []int {
+	out, err := m.newCmd(cmdTC, "filter", "show", "dev", ifaceName, "ingress").Output()
+	if err != nil {
+		// Most likely there's no ingress qdisc.
+		log.WithError(err).WithField("iface", ifaceName).Debug("Failed to list tc filters")
+		return nil
+	}
+	seen := map[int]bool{}
+	var prios []int
+	for _, match := range tcFilterPrioRegexp.FindAllStringSubmatch(string(out), -1) {
+		prio, err := strconv.Atoi(match[1])
+		if err != nil || prio < tcOffloadFirstPrio || prio >= tcOffloadFirstPrio+maxTCOffloadFilters {
+			continue
+		}
+		if !seen[prio] {
+			seen[prio] = true
+			prios = append(prios, prio)
+		}
+	}
+	return prios
+}
+
+// updateRuleModes sets the mode of each offloaded rule from the modes of its filters.
+func (s *tcOffloadIfaceState) updateRuleModes() {
+	for i := range s.rules {
+		r := &s.rules[i]
+		if r.mode == tcOffloadUnsupported {
+			continue
+		}
+		r.mode = tcOffloadHardware
+		for _, hw := range s.inHardware[r.firstFilter : r.firstFilter+r.numFilters] {
+			if !hw {
+				r.mode = tcOffloadSoftware
+				break
+			}
+		}
+	}
+}
+
+// desiredFilters calculates the filters for the interface and the status of each rule of its
+// untracked policies.  Offloaded rules get their mode once the filters are programmed.
+func (m *tcPolicyOffloadManager) desiredFilters(ifaceName string) ([]tcFlowerFilter, []tcOffloadRuleStatus) {
+	var policies []proto.PolicyID
+	for _, hep := range m.hostEndpoints {
+		if hep.ifaceName == ifaceName {
+			policies = hep.policies
+			break
+		}
+	}
+	if len(policies) == 0 {
+		return nil, nil
+	}
+
+	filters := m.failsafeFilters()
+	var rules []tcOffloadRuleStatus
+	supported, offloaded := true, false
+	for _, id := range policies {
+		inboundRules, ok := m.policies[id]
+		if !ok {
+			// We haven't heard about the policy yet.
+			supported = false
+		}
+		for i, rule := range inboundRules {
+			status := tcOffloadRuleStatus{policy: id.Name, rule: i, mode: tcOffloadUnsupported}
+			if supported {
+				ruleFilters, ok := tcFiltersForRule(rule)
+				if ok && len(filters)+len(ruleFilters) <= maxTCOffloadFilters {
+					status.firstFilter = len(filters)
+					status.numFilters = len(ruleFilters)
+					status.mode = ""
+					filters = append(filters, ruleFilters...)
+					offloaded = true
+				} else {
+					supported = false
+				}
+			}
+			rules = append(rules, status)
+		}
+	}
+	if !offloaded {
+		// Only the failsafe filters, which wouldn't drop anything.
+		filters = nil
+	}
+	return filters, rules
+}
+
+func (m *tcPolicyOffloadManager) failsafeFilters() []tcFlowerFilter {
+	var filters []tcFlowerFilter
+	for _, p := range m.failsafePorts {
+		for _, v := range []uint8{4, 6} {
+			if p.Net != "" && cidrIPVersion(p.Net) != v {
+				continue
+			}
+			filters = append(filters, tcFlowerFilter{
+				ipVersion: v,
+				protocol:  strings.ToLower(p.Protocol),
+				srcNet:    p.Net,
+				dstPort:   fmt.Sprint(p.Port),
+				action:    "pass",
+			})
+		}
+	}
+	return filters
+}
+
+// tcFiltersForRule returns the flower filters that implement the rule, or false if flower can't
+// express the rule.
+func tcFiltersForRule(rule *proto.Rule) ([]tcFlowerFilter, bool) {
+	if !isValidRuleForTCOffload(rule) {
+		return nil, false
+	}
+	var action string
+	switch rule.Action {
+	case "deny":
+		action = "drop"
+	case "", "allow", "next-tier", "pass":
+		action = "pass"
+	default:
+		return nil, false
+	}
+	protocol := ""
+	if rule.Protocol != nil {
+		var ok bool
+		if protocol, ok = tcFlowerProtocol(rule.Protocol); !ok {
+			return nil, false
+		}
+	} else if len(rule.DstPorts) > 0 {
+		return nil, false
+	}
+
+	srcNets := rule.SrcNet
+	if len(srcNets) == 0 {
+		srcNets = []string{""}
+	}
+	dstNets := rule.DstNet
+	if len(dstNets) == 0 {
+		dstNets = []string{""}
+	}
+	dstPorts := []string{""}
+	if len(rule.DstPorts) > 0 {
+		dstPorts = nil
+		for _, pr := range rule.DstPorts {
+			if pr.First == pr.Last {
+				dstPorts = append(dstPorts, fmt.Sprint(pr.First))
+			} else {
+				dstPorts = append(dstPorts, fmt.Sprintf("%d-%d", pr.First, pr.Last))
+			}
+		}
+	}
+
+	var filters []tcFlowerFilter
+	for _, v := range []uint8{4, 6} {
+		if (v == 4 && rule.IpVersion == proto.IPVersion_IPV6) || (v == 6 && rule.IpVersion == proto.IPVersion_IPV4) {
+			continue
+		}
+		for _, src := range srcNets {
+			if src != "" && cidrIPVersion(src) != v {
+				continue
+			}
+			for _, dst := range dstNets {
+				if dst != "" && cidrIPVersion(dst) != v {
+					continue
+				}
+				for _, port := range dstPorts {
+					filters = append(filters, tcFlowerFilter{
+						ipVersion: v,
+						protocol:  protocol,
+						srcNet:    src,
+						dstNet:    dst,
+						dstPort:   port,
+						action:    action,
+					})
+					if len(filters) > maxTCOffloadFilters {
+						return nil, false
+					}
+				}
+			}
+		}
+	}
+	return filters, true
+}
+
+// isValidRuleForTCOffload returns true if the rule only matches on IP version, protocol, CIDRs
+// and destination ports.
+func isValidRuleForTCOffload(rule *proto.Rule) bool {
+	return rule != nil &&
+		len(rule.SrcPorts) == 0 &&
+		len(rule.SrcNamedPortIpSetIds) == 0 &&
+		len(rule.SrcIpSetIds) == 0 &&
+		len(rule.DstNamedPortIpSetIds) == 0 &&
+		len(rule.DstIpSetIds) == 0 &&
+		rule.NotProtocol == nil &&
+		len(rule.NotSrcNet) == 0 &&
+		len(rule.NotSrcPorts) == 0 &&
+		len(rule.NotSrcIpSetIds) == 0 &&
+		len(rule.NotSrcNamedPortIpSetIds) == 0 &&
+		len(rule.NotDstNet) == 0 &&
+		len(rule.NotDstPorts) == 0 &&
+		len(rule.NotDstIpSetIds) == 0 &&
+		len(rule.NotDstNamedPortIpSetIds) == 0 &&
+		len(rule.DstDomains) == 0 &&
+		rule.Icmp == nil &&
+		rule.NotIcmp == nil &&
+		rule.HttpMatch == nil &&
+		rule.SrcServiceAccountMatch == nil &&
+		rule.DstServiceAccountMatch == nil
+}
+
+// tcFlowerProtocol returns flower's name for the protocols that it can match ports on.
+func tcFlowerProtocol(p *proto.Protocol) (string, bool) {
+	switch p.GetNumberOrName().(type) {
+	case *proto.Protocol_Number:
+		switch p.GetNumber() {
+		case 6:
+			return "tcp", true
+		case 17:
+			return "udp", true
+		case 132:
+			return "sctp", true
+		}
+	case *proto.Protocol_Name:
+		switch name := strings.ToLower(p.GetName()); name {
+		case "tcp", "udp", "sctp":
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func cidrIPVersion(cidr string) uint8 {
+	ip := net.ParseIP(strings.SplitN(cidr, "/", 2)[0])
+	if ip != nil && ip.To4() == nil {
+		return 6
+	}
+	return 4
+}
+
+func (m *tcPolicyOffloadManager) updateGauges() {
+	counts := map[tcOffloadMode]int{}
+	for _, s := range m.applied {
+		for _, r := range s.rules {
+			counts[r.mode]++
+		}
+	}
+	for _, mode := range []tcOffloadMode{tcOffloadHardware, tcOffloadSoftware, tcOffloadUnsupported} {
+		gaugeTCOffloadRules.WithLabelValues(string(mode)).Set(float64(counts[mode]))
+	}
+}
+
+// DumpState writes the offload mode of each rule, by interface.
+func (m *tcPolicyOffloadManager) DumpState(w io.Writer) error {
+	var ifaceNames []string
+	for name := range m.applied {
+		ifaceNames = append(ifaceNames, name)
+	}
+	sort.Strings(ifaceNames)
+	for _, name := range ifaceNames {
+		s := m.applied[name]
+		if _, err := fmt.Fprintf(w, "# %s (%d filters)\n", name, len(s.filters)); err != nil {
+			return err
+		}
+		for _, r := range s.rules {
+			if _, err := fmt.Fprintf(w, "%s rule %d: %s\n", r.policy, r.rule, r.mode); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *tcPolicyOffloadManager) tc(args ...string) error {
+	return runTC(m.newCmd, args...)
+}
+
+func (m *tcPolicyOffloadManager) tcIgnoreErr(args ...string) {
+	runTCIgnoreErr(m.newCmd, args...)
+}
@@ -0,0 +1,240 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"bytes"
+	"regexp"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/ifacemonitor"
+	"github.com/projectcalico/felix/proto"
+)
+
+var _ = Describe("tc policy offload manager", func() {
+	var (
+		mgr *tcPolicyOffloadManager
+		tc  *tcRecorder
+	)
+
+	hepID := &proto.HostEndpointID{EndpointId: "eth0-hep"}
+	polID := &proto.PolicyID{Tier: "default", Name: "untracked"}
+
+	sendPolicy := func(rules ...*proto.Rule) {
+		mgr.OnUpdate(&proto.ActivePolicyUpdate{
+			Id:     polID,
+			Policy: &proto.Policy{InboundRules: rules, Untracked: true},
+		})
+	}
+
+	dump := func() string {
+		var buf bytes.Buffer
+		Expect(mgr.DumpState(&buf)).To(Succeed())
+		return buf.String()
+	}
+
+	BeforeEach(func() {
+		tc = &tcRecorder{}
+		mgr = newTCPolicyOffloadManager(
+			[]*regexp.Regexp{regexp.MustCompile("^eth")},
+			[]config.ProtoPort{{Protocol: "tcp", Port: 22, Net: "10.0.0.0/8"}},
+			tc.factory,
+		)
+		mgr.OnUpdate(&proto.HostEndpointUpdate{
+			Id: hepID,
+			Endpoint: &proto.HostEndpoint{
+				Name: "eth0",
+				UntrackedTiers: []*proto.TierInfo{
+					{Name: "default", IngressPolicies: []string{"untracked"}},
+				},
+			},
+		})
+	})
+
+	It("should ignore interfaces that don't match", func() {
+		mgr.OnUpdate(&ifaceUpdate{Name: "cali1", State: ifacemonitor.StateUp})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(tc.takeCmds()).To(BeEmpty())
+	})
+
+	It("should remove only its own leftover filters from an interface without policy", func() {
+		tc.outputs = map[string]string{
+			"tc filter show dev eth1 ingress": "filter protocol ip pref 1 flower chain 0 \n" +
+				"filter protocol ip pref 1 flower chain 0 handle 0x1 \n" +
+				"filter protocol ip pref 4096 flower chain 0 \n" +
+				"filter protocol ip pref 4096 flower chain 0 handle 0x1 \n" +
+				"filter protocol ipv6 pref 4097 flower chain 0 \n" +
+				"filter protocol ipv6 pref 4097 flower chain 0 handle 0x1 \n",
+		}
+		mgr.OnUpdate(&ifaceUpdate{Name: "eth1", State: ifacemonitor.StateUp})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(tc.takeCmds()).To(Equal([]string{
+			"tc filter show dev eth1 ingress",
+			"tc filter del dev eth1 parent ffff: prio 4096",
+			"tc filter del dev eth1 parent ffff: prio 4097",
+		}))
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(tc.takeCmds()).To(BeEmpty())
+	})
+
+	Describe("with the interface up", func() {
+		BeforeEach(func() {
+			mgr.OnUpdate(&ifaceUpdate{Name: "eth0", State: ifacemonitor.StateUp})
+		})
+
+		It("should offload simple rules in order, after the failsafe ports", func() {
+			sendPolicy(
+				&proto.Rule{
+					Action:   "allow",
+					Protocol: &proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "tcp"}},
+					SrcNet:   []string{"192.168.0.1/32"},
+					DstPorts: []*proto.PortRange{{First: 80, Last: 80}, {First: 8000, Last: 8080}},
+				},
+				&proto.Rule{
+					Action: "deny",
+					SrcNet: []string{"192.168.0.0/16", "fd00::/8"},
+				},
+			)
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(tc.takeCmds()).To(Equal([]string{
+				"tc filter show dev eth0 ingress",
+				"tc qdisc add dev eth0 ingress",
+				"tc filter add dev eth0 parent ffff: protocol ip prio 4096 flower skip_sw ip_proto tcp src_ip 10.0.0.0/8 dst_port 22 action pass",
+				"tc filter add dev eth0 parent ffff: protocol ip prio 4097 flower skip_sw ip_proto tcp src_ip 192.168.0.1/32 dst_port 80 action pass",
+				"tc filter add dev eth0 parent ffff: protocol ip prio 4098 flower skip_sw ip_proto tcp src_ip 192.168.0.1/32 dst_port 8000-8080 action pass",
+				"tc filter add dev eth0 parent ffff: protocol ip prio 4099 flower skip_sw src_ip 192.168.0.0/16 action drop",
+				"tc filter add dev eth0 parent ffff: protocol ipv6 prio 4100 flower skip_sw src_ip fd00::/8 action drop",
+			}))
+			Expect(dump()).To(Equal("# eth0 (5 filters)\n" +
+				"untracked rule 0: hardware\n" +
+				"untracked rule 1: hardware\n"))
+
+			By("not reprogramming if nothing changed")
+			sendPolicy(
+				&proto.Rule{
+					Action:   "allow",
+					Protocol: &proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "tcp"}},
+					SrcNet:   []string{"192.168.0.1/32"},
+					DstPorts: []*proto.PortRange{{First: 80, Last: 80}, {First: 8000, Last: 8080}},
+				},
+				&proto.Rule{
+					Action: "deny",
+					SrcNet: []string{"192.168.0.0/16", "fd00::/8"},
+				},
+			)
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(tc.takeCmds()).To(BeEmpty())
+
+			By("only replacing the filters after the first change")
+			sendPolicy(
+				&proto.Rule{
+					Action:   "allow",
+					Protocol: &proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "tcp"}},
+					SrcNet:   []string{"192.168.0.1/32"},
+					DstPorts: []*proto.PortRange{{First: 80, Last: 80}},
+				},
+				&proto.Rule{
+					Action: "deny",
+					SrcNet: []string{"192.168.0.0/16", "fd00::/8"},
+				},
+			)
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(tc.takeCmds()).To(Equal([]string{
+				"tc filter del dev eth0 parent ffff: prio 4098",
+				"tc filter del dev eth0 parent ffff: prio 4099",
+				"tc filter del dev eth0 parent ffff: prio 4100",
+				"tc qdisc add dev eth0 ingress",
+				"tc filter add dev eth0 parent ffff: protocol ip prio 4098 flower skip_sw src_ip 192.168.0.0/16 action drop",
+				"tc filter add dev eth0 parent ffff: protocol ipv6 prio 4099 flower skip_sw src_ip fd00::/8 action drop",
+			}))
+		})
+
+		It("should fall back to software when the NIC refuses a filter", func() {
+			tc.failPrefixes = []string{"tc filter add dev eth0 parent ffff: protocol ip prio 4098 flower skip_sw"}
+			sendPolicy(
+				&proto.Rule{Action: "deny", SrcNet: []string{"192.168.0.0/16"}},
+				&proto.Rule{Action: "deny", SrcNet: []string{"172.16.0.0/12"}},
+			)
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(tc.takeCmds()).To(Equal([]string{
+				"tc filter show dev eth0 ingress",
+				"tc qdisc add dev eth0 ingress",
+				"tc filter add dev eth0 parent ffff: protocol ip prio 4096 flower skip_sw ip_proto tcp src_ip 10.0.0.0/8 dst_port 22 action pass",
+				"tc filter add dev eth0 parent ffff: protocol ip prio 4097 flower skip_sw src_ip 192.168.0.0/16 action drop",
+				"tc filter add dev eth0 parent ffff: protocol ip prio 4098 flower skip_sw src_ip 172.16.0.0/12 action drop",
+				"tc filter add dev eth0 parent ffff: protocol ip prio 4098 flower src_ip 172.16.0.0/12 action drop",
+			}))
+			Expect(dump()).To(Equal("# eth0 (3 filters)\n" +
+				"untracked rule 0: hardware\n" +
+				"untracked rule 1: software\n"))
+		})
+
+		It("should stop at the first rule that can't be offloaded", func() {
+			sendPolicy(
+				&proto.Rule{Action: "deny", SrcNet: []string{"192.168.0.0/16"}},
+				&proto.Rule{Action: "allow", SrcIpSetIds: []string{"s:abcd"}},
+				&proto.Rule{Action: "deny", SrcNet: []string{"172.16.0.0/12"}},
+			)
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(tc.takeCmds()).To(Equal([]string{
+				"tc filter show dev eth0 ingress",
+				"tc qdisc add dev eth0 ingress",
+				"tc filter add dev eth0 parent ffff: protocol ip prio 4096 flower skip_sw ip_proto tcp src_ip 10.0.0.0/8 dst_port 22 action pass",
+				"tc filter add dev eth0 parent ffff: protocol ip prio 4097 flower skip_sw src_ip 192.168.0.0/16 action drop",
+			}))
+			Expect(dump()).To(Equal("# eth0 (2 filters)\n" +
+				"untracked rule 0: hardware\n" +
+				"untracked rule 1: unsupported\n" +
+				"untracked rule 2: unsupported\n"))
+		})
+
+		It("should remove the filters when the host endpoint goes", func() {
+			sendPolicy(&proto.Rule{Action: "deny", SrcNet: []string{"192.168.0.0/16"}})
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			tc.takeCmds()
+
+			mgr.OnUpdate(&proto.HostEndpointRemove{Id: hepID})
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(tc.takeCmds()).To(Equal([]string{
+				"tc filter del dev eth0 parent ffff: prio 4096",
+				"tc filter del dev eth0 parent ffff: prio 4097",
+			}))
+			Expect(dump()).To(Equal("# eth0 (0 filters)\n"))
+		})
+
+		It("should retry after a failure", func() {
+			tc.failPrefixes = []string{"tc filter add dev eth0 parent ffff: protocol ip prio 4097"}
+			sendPolicy(&proto.Rule{Action: "deny", SrcNet: []string{"192.168.0.0/16"}})
+			Expect(mgr.CompleteDeferredWork()).To(HaveOccurred())
+			tc.takeCmds()
+
+			tc.failPrefixes = nil
+			tc.outputs = map[string]string{
+				"tc filter show dev eth0 ingress": "filter protocol ip pref 4096 flower chain 0 \n",
+			}
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(tc.takeCmds()).To(Equal([]string{
+				"tc filter show dev eth0 ingress",
+				"tc filter del dev eth0 parent ffff: prio 4096",
+				"tc qdisc add dev eth0 ingress",
+				"tc filter add dev eth0 parent ffff: protocol ip prio 4096 flower skip_sw ip_proto tcp src_ip 10.0.0.0/8 dst_port 22 action pass",
+				"tc filter add dev eth0 parent ffff: protocol ip prio 4097 flower skip_sw src_ip 192.168.0.0/16 action drop",
+			}))
+		})
+	})
+})
@@ -0,0 +1,383 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+
+	"github.com/projectcalico/felix/proto"
+)
+
+const (
+	// Constants from linux/inet_diag.h and linux/sock_diag.h.
+	inetDiagInfo        = 2
+	inetDiagSKMemInfo   = 4
+	skMemInfoDrops      = 8
+	sizeofInetDiagReq   = 56
+	sizeofInetDiagMsg   = 72
+	inetDiagReadTimeout = 5 * time.Second
+
+	// TCP states whose sockets carry a tcp_info: everything except LISTEN, TIME_WAIT and
+	// CLOSE.
+	tcpStatesWithInfo = (1<<12 - 1) &^ (1<<10 | 1<<6 | 1<<7)
+)
+
+var (
+	tcpStatsLabels = []string{"namespace"}
+
+	descWorkloadTCPSockets = prometheus.NewDesc(
+		"felix_workload_tcp_sockets",
+		"Number of open TCP sockets in local workloads.",
+		tcpStatsLabels, nil,
+	)
+	descWorkloadTCPRTT = prometheus.NewDesc(
+		"felix_workload_tcp_rtt_seconds",
+		"Smoothed round-trip time of the open TCP sockets in local workloads.",
+		tcpStatsLabels, nil,
+	)
+	descWorkloadTCPRetransmits = prometheus.NewDesc(
+		"felix_workload_tcp_retransmits",
+		"Number of segments retransmitted by the open TCP sockets in local workloads.",
+		tcpStatsLabels, nil,
+	)
+	descWorkloadTCPLostPackets = prometheus.NewDesc(
+		"felix_workload_tcp_lost_packets",
+		"Number of packets currently considered lost by the open TCP sockets in local workloads.",
+		tcpStatsLabels, nil,
+	)
+	descWorkloadTCPSocketDrops = prometheus.NewDesc(
+		"felix_workload_tcp_socket_drops",
+		"Number of packets dropped by the open TCP sockets in local workloads before they were "+
+			"read, for example because the socket's receive buffer was full.",
+		tcpStatsLabels, nil,
+	)
+
+	tcpRTTBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+)
+
+// tcpSocketStats holds the statistics of one TCP socket that we export.
+type tcpSocketStats struct {
+	rtt          time.Duration
+	totalRetrans uint32
+	lost         uint32
+	drops        uint32
+}
+
+// tcpStatsManager exports TCP socket statistics for the sockets owned by local workloads,
+// aggregated per namespace.  High retransmit counts and RTTs, with few policy drops, point at
+// congestion rather than network policy.
+//
+// At scrape time, it finds each workload's network namespace from the host side of the
+// workload's veth and dumps the namespace's TCP sockets over INET_DIAG.  Since the statistics
+// only cover the sockets that are open at the time, the values are reported as gauges.
+type tcpStatsManager struct {
+	lock             sync.Mutex
+	ifaceToNamespace map[string]string
+	endpointToIface  map[proto.WorkloadEndpointID]string
+
+	// collectLock serialises scrapes, which dump sockets without holding lock, so as not to
+	// block the dataplane.  It protects netnsIDToProcPath.
+	collectLock       sync.Mutex
+	netnsIDToProcPath map[int]string
+
+	// Shims for testing.
+	listLinks      func() ([]netlink.Link, error)
+	dumpTCPSockets func(netnsID int) ([]tcpSocketStats, error)
+}
+
+func newTCPStatsManager() *tcpStatsManager {
+	m := &tcpStatsManager{
+		ifaceToNamespace:  map[string]string{},
+		endpointToIface:   map[proto.WorkloadEndpointID]string{},
+		netnsIDToProcPath: map[int]string{},
+		listLinks:         netlink.LinkList,
+	}
+	m.dumpTCPSockets = m.dumpTCPSocketsInNetns
+	return m
+}
+
+func (m *tcpStatsManager) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *proto.WorkloadEndpointUpdate:
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		m.removeEndpoint(*msg.Id)
+		namespace := ""
+		if parts := strings.SplitN(msg.Id.WorkloadId, "/", 2); len(parts) == 2 {
+			namespace = parts[0]
+		}
+		m.endpointToIface[*msg.Id] = msg.Endpoint.Name
+		m.ifaceToNamespace[msg.Endpoint.Name] = namespace
+	case *proto.WorkloadEndpointRemove:
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		m.removeEndpoint(*msg.Id)
+	}
+}
+
+func (m *tcpStatsManager) removeEndpoint(id proto.WorkloadEndpointID) {
+	if iface, ok := m.endpointToIface[id]; ok {
+		delete(m.ifaceToNamespace, iface)
+		delete(m.endpointToIface, id)
+	}
+}
+
+func (m *tcpStatsManager) CompleteDeferredWork() error {
+	return nil
+}
+
+func (m *tcpStatsManager) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descWorkloadTCPSockets
+	ch <- descWorkloadTCPRTT
+	ch <- descWorkloadTCPRetransmits
+	ch <- descWorkloadTCPLostPackets
+	ch <- descWorkloadTCPSocketDrops
+}
+
+func (m *tcpStatsManager) Collect(ch chan<- prometheus.Metric) {
+	links, err := m.listLinks()
+	if err != nil {
+		log.WithError(err).Warn("Failed to list interfaces for workload TCP stats.")
+		return
+	}
+
+	// A workload with several interfaces has one network namespace; only dump it once.
+	netnsIDToNamespace := map[int]string{}
+	m.lock.Lock()
+	for _, link := range links {
+		attrs := link.Attrs()
+		namespace, ok := m.ifaceToNamespace[attrs.Name]
+		if !ok {
+			continue
+		}
+		netnsIDToNamespace[attrs.NetNsID] = namespace
+	}
+	m.lock.Unlock()
+
+	m.collectLock.Lock()
+	defer m.collectLock.Unlock()
+
+	type aggregate struct {
+		sockets      uint64
+		rttSum       float64
+		rttBuckets   map[float64]uint64
+		totalRetrans uint64
+		lost         uint64
+		drops        uint64
+	}
+	aggregates := map[string]*aggregate{}
+	for netnsID, namespace := range netnsIDToNamespace {
+		sockets, err := m.dumpTCPSockets(netnsID)
+		if err != nil {
+			log.WithError(err).WithField("netnsID", netnsID).Debug(
+				"Failed to dump TCP sockets in workload network namespace.")
+			continue
+		}
+		agg := aggregates[namespace]
+		if agg == nil {
+			agg = &aggregate{rttBuckets: map[float64]uint64{}}
+			aggregates[namespace] = agg
+		}
+		for _, s := range sockets {
+			rtt := s.rtt.Seconds()
+			agg.sockets++
+			agg.rttSum += rtt
+			for _, b := range tcpRTTBuckets {
+				if rtt <= b {
+					agg.rttBuckets[b]++
+				}
+			}
+			agg.totalRetrans += uint64(s.totalRetrans)
+			agg.lost += uint64(s.lost)
+			agg.drops += uint64(s.drops)
+		}
+	}
+
+	for namespace, agg := range aggregates {
+		ch <- prometheus.MustNewConstMetric(descWorkloadTCPSockets, prometheus.GaugeValue, float64(agg.sockets), namespace)
+		ch <- prometheus.MustNewConstHistogram(descWorkloadTCPRTT, agg.sockets, agg.rttSum, agg.rttBuckets, namespace)
+		ch <- prometheus.MustNewConstMetric(descWorkloadTCPRetransmits, prometheus.GaugeValue, float64(agg.totalRetrans), namespace)
+		ch <- prometheus.MustNewConstMetric(descWorkloadTCPLostPackets, prometheus.GaugeValue, float64(agg.lost), namespace)
+		ch <- prometheus.MustNewConstMetric(descWorkloadTCPSocketDrops, prometheus.GaugeValue, float64(agg.drops), namespace)
+	}
+}
+
+// dumpTCPSocketsInNetns dumps the TCP sockets in the network namespace that the host knows by
+// the given ID.
+func (m *tcpStatsManager) dumpTCPSocketsInNetns(netnsID int) ([]tcpSocketStats, error) {
+	ns, err := m.openNetns(netnsID)
+	if err != nil {
+		return nil, err
+	}
+	defer ns.Close()
+
+	sock, err := nl.GetNetlinkSocketAt(ns, netns.None(), unix.NETLINK_INET_DIAG)
+	if err != nil {
+		return nil, err
+	}
+	defer sock.Close()
+	tv := unix.NsecToTimeval(inetDiagReadTimeout.Nanoseconds())
+	if err := sock.SetReceiveTimeout(&tv); err != nil {
+		return nil, err
+	}
+
+	var stats []tcpSocketStats
+	for _, family := range []uint8{unix.AF_INET, unix.AF_INET6} {
+		req := nl.NewNetlinkRequest(nl.SOCK_DIAG_BY_FAMILY, unix.NLM_F_DUMP)
+		req.AddData(&inetDiagReq{family: family})
+		if err := sock.Send(req); err != nil {
+			return nil, err
+		}
+	recvLoop:
+		for {
+			msgs, _, err := sock.Receive()
+			if err != nil {
+				return nil, err
+			}
+			for _, msg := range msgs {
+				switch msg.Header.Type {
+				case unix.NLMSG_DONE:
+					break recvLoop
+				case unix.NLMSG_ERROR:
+					if len(msg.Data) >= 4 {
+						return nil, syscall.Errno(-int32(nl.NativeEndian().Uint32(msg.Data[0:4])))
+					}
+					return nil, errors.New("INET_DIAG dump failed")
+				}
+				if s, ok := parseInetDiagMsg(msg.Data); ok {
+					stats = append(stats, s)
+				}
+			}
+		}
+	}
+	return stats, nil
+}
+
+// openNetns opens the network namespace with the given ID.  Namespace IDs can only be resolved
+// from a handle on the namespace, so we find a process in each one by scanning /proc, and cache
+// the result until the process goes away or the ID is reused.
+func (m *tcpStatsManager) openNetns(netnsID int) (netns.NsHandle, error) {
+	if path, ok := m.netnsIDToProcPath[netnsID]; ok {
+		if ns, err := openNetnsWithID(path, netnsID); err == nil {
+			return ns, nil
+		}
+	}
+	m.netnsIDToProcPath = map[int]string{}
+	paths, _ := filepath.Glob("/proc/[0-9]*/ns/net")
+	for _, path := range paths {
+		ns, err := netns.GetFromPath(path)
+		if err != nil {
+			continue
+		}
+		id, err := netlink.GetNetNsIdByFd(int(ns))
+		ns.Close()
+		if err != nil || id < 0 {
+			continue
+		}
+		if _, ok := m.netnsIDToProcPath[id]; !ok {
+			m.netnsIDToProcPath[id] = path
+		}
+	}
+	path, ok := m.netnsIDToProcPath[netnsID]
+	if !ok {
+		return netns.None(), fmt.Errorf("no process found in network namespace %d", netnsID)
+	}
+	return openNetnsWithID(path, netnsID)
+}
+
+func openNetnsWithID(path string, netnsID int) (netns.NsHandle, error) {
+	ns, err := netns.GetFromPath(path)
+	if err != nil {
+		return netns.None(), err
+	}
+	if id, err := netlink.GetNetNsIdByFd(int(ns)); err != nil || id != netnsID {
+		ns.Close()
+		return netns.None(), fmt.Errorf("%s is no longer in network namespace %d", path, netnsID)
+	}
+	return ns, nil
+}
+
+// inetDiagReq is an inet_diag_req_v2 that dumps all the TCP sockets of a family that have a
+// tcp_info, requesting their tcp_info and socket memory info.
+type inetDiagReq struct {
+	family uint8
+}
+
+func (r *inetDiagReq) Serialize() []byte {
+	b := make([]byte, sizeofInetDiagReq)
+	b[0] = r.family
+	b[1] = unix.IPPROTO_TCP
+	b[2] = 1<<(inetDiagInfo-1) | 1<<(inetDiagSKMemInfo-1)
+	nl.NativeEndian().PutUint32(b[4:8], tcpStatesWithInfo)
+	// The socket ID, which is ignored for dumps apart from its cookie.
+	binary.BigEndian.PutUint32(b[48:52], nl.TCPDIAG_NOCOOKIE)
+	binary.BigEndian.PutUint32(b[52:56], nl.TCPDIAG_NOCOOKIE)
+	return b
+}
+
+func (r *inetDiagReq) Len() int {
+	return sizeofInetDiagReq
+}
+
+// parseInetDiagMsg extracts the stats from an inet_diag_msg and its attributes.  It returns
+// false if the message doesn't include a tcp_info.
+func parseInetDiagMsg(b []byte) (tcpSocketStats, bool) {
+	var stats tcpSocketStats
+	if len(b) < sizeofInetDiagMsg {
+		return stats, false
+	}
+	attrs, err := nl.ParseRouteAttr(b[sizeofInetDiagMsg:])
+	if err != nil {
+		return stats, false
+	}
+	haveInfo := false
+	for _, attr := range attrs {
+		switch attr.Attr.Type {
+		case inetDiagInfo:
+			// Older kernels return a shorter tcp_info, newer ones a longer one; we only need
+			// the fields that have been there for ever.
+			var info unix.TCPInfo
+			n := len(attr.Value)
+			if n > int(unsafe.Sizeof(info)) {
+				n = int(unsafe.Sizeof(info))
+			}
+			copy((*[unsafe.Sizeof(info)]byte)(unsafe.Pointer(&info))[:n], attr.Value)
+			stats.rtt = time.Duration(info.Rtt) * time.Microsecond
+			stats.totalRetrans = info.Total_retrans
+			stats.lost = info.Lost
+			haveInfo = true
+		case inetDiagSKMemInfo:
+			if len(attr.Value) >= (skMemInfoDrops+1)*4 {
+				stats.drops = nl.NativeEndian().Uint32(attr.Value[skMemInfoDrops*4:])
+			}
+		}
+	}
+	return stats, haveInfo
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+
+	"github.com/projectcalico/felix/proto"
+)
+
+var _ = Describe("TCP stats manager", func() {
+	var (
+		mgr        *tcpStatsManager
+		links      []netlink.Link
+		netnsSocks map[int][]tcpSocketStats
+	)
+
+	collect := func() map[string]*dto.Metric {
+		ch := make(chan prometheus.Metric, 100)
+		mgr.Collect(ch)
+		close(ch)
+		metrics := map[string]*dto.Metric{}
+		for m := range ch {
+			var pb dto.Metric
+			Expect(m.Write(&pb)).To(Succeed())
+			metrics[m.Desc().String()+pb.GetLabel()[0].GetValue()] = &pb
+		}
+		return metrics
+	}
+
+	addEndpoint := func(workload, iface string) {
+		mgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id:       &proto.WorkloadEndpointID{OrchestratorId: "k8s", WorkloadId: workload, EndpointId: iface},
+			Endpoint: &proto.WorkloadEndpoint{Name: iface},
+		})
+	}
+
+	BeforeEach(func() {
+		links = []netlink.Link{
+			&netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "cali1", NetNsID: 1}},
+			&netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "cali2", NetNsID: 2}},
+			&netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "cali2b", NetNsID: 2}},
+			&netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "cali3", NetNsID: 3}},
+		}
+		netnsSocks = map[int][]tcpSocketStats{
+			1: {{rtt: 200 * time.Microsecond, totalRetrans: 1, lost: 0, drops: 2}},
+			2: {
+				{rtt: 20 * time.Millisecond, totalRetrans: 5, lost: 1},
+				{rtt: 2 * time.Millisecond, totalRetrans: 0, lost: 0},
+			},
+		}
+		mgr = newTCPStatsManager()
+		mgr.listLinks = func() ([]netlink.Link, error) { return links, nil }
+		mgr.dumpTCPSockets = func(netnsID int) ([]tcpSocketStats, error) {
+			socks, ok := netnsSocks[netnsID]
+			if !ok {
+				return nil, errors.New("no such namespace")
+			}
+			return socks, nil
+		}
+	})
+
+	It("should report nothing with no endpoints", func() {
+		Expect(collect()).To(BeEmpty())
+	})
+
+	It("should aggregate socket stats per namespace", func() {
+		addEndpoint("ns1/pod1", "cali1")
+		addEndpoint("ns1/pod2", "cali2")
+		addEndpoint("ns1/pod2", "cali2b")
+		addEndpoint("ns2/pod3", "cali3")
+
+		metrics := collect()
+		Expect(metrics[descWorkloadTCPSockets.String()+"ns1"].GetGauge().GetValue()).To(Equal(3.0))
+		Expect(metrics[descWorkloadTCPRetransmits.String()+"ns1"].GetGauge().GetValue()).To(Equal(6.0))
+		Expect(metrics[descWorkloadTCPLostPackets.String()+"ns1"].GetGauge().GetValue()).To(Equal(1.0))
+		Expect(metrics[descWorkloadTCPSocketDrops.String()+"ns1"].GetGauge().GetValue()).To(Equal(2.0))
+
+		rtt := metrics[descWorkloadTCPRTT.String()+"ns1"].GetHistogram()
+		Expect(rtt.GetSampleCount()).To(Equal(uint64(3)))
+		Expect(rtt.GetSampleSum()).To(BeNumerically("~", 0.0222, 1e-9))
+		buckets := map[float64]uint64{}
+		for _, b := range rtt.GetBucket() {
+			buckets[b.GetUpperBound()] = b.GetCumulativeCount()
+		}
+		Expect(buckets[0.0001]).To(Equal(uint64(0)))
+		Expect(buckets[0.0005]).To(Equal(uint64(1)))
+		Expect(buckets[0.005]).To(Equal(uint64(2)))
+		Expect(buckets[0.05]).To(Equal(uint64(3)))
+
+		// ns2's only workload couldn't be dumped so it isn't reported.
+		Expect(metrics).To(HaveLen(5))
+	})
+
+	It("should stop reporting removed endpoints", func() {
+		addEndpoint("ns1/pod1", "cali1")
+		mgr.OnUpdate(&proto.WorkloadEndpointRemove{
+			Id: &proto.WorkloadEndpointID{OrchestratorId: "k8s", WorkloadId: "ns1/pod1", EndpointId: "cali1"},
+		})
+		Expect(collect()).To(BeEmpty())
+	})
+
+	It("should parse an inet_diag_msg", func() {
+		msg := make([]byte, sizeofInetDiagMsg)
+		info := make([]byte, 104)
+		nl.NativeEndian().PutUint32(info[32:], 3)    // tcpi_lost
+		nl.NativeEndian().PutUint32(info[68:], 1500) // tcpi_rtt
+		nl.NativeEndian().PutUint32(info[100:], 7)   // tcpi_total_retrans
+		msg = append(msg, nl.NewRtAttr(inetDiagInfo, info).Serialize()...)
+		memInfo := make([]byte, 9*4)
+		nl.NativeEndian().PutUint32(memInfo[skMemInfoDrops*4:], 4)
+		msg = append(msg, nl.NewRtAttr(inetDiagSKMemInfo, memInfo).Serialize()...)
+
+		stats, ok := parseInetDiagMsg(msg)
+		Expect(ok).To(BeTrue())
+		Expect(stats).To(Equal(tcpSocketStats{rtt: 1500 * time.Microsecond, totalRetrans: 7, lost: 3, drops: 4}))
+	})
+
+	It("should ignore an inet_diag_msg without a tcp_info", func() {
+		_, ok := parseInetDiagMsg(make([]byte, sizeofInetDiagMsg))
+		Expect(ok).To(BeFalse())
+		_, ok = parseInetDiagMsg(make([]byte, 10))
+		Expect(ok).To(BeFalse())
+	})
+})
@@ -24,7 +24,9 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/projectcalico/felix/config"
 	"github.com/projectcalico/felix/ethtool"
+	"github.com/projectcalico/felix/ifacemonitor"
 	"github.com/projectcalico/felix/ipsets"
 	"github.com/projectcalico/felix/logutils"
 	"github.com/projectcalico/felix/rules"
@@ -59,6 +61,17 @@ type vxlanManager struct {
 	routeTable          routeTable
 	blackholeRouteTable routeTable
 	noEncapRouteTable   routeTable
+	// noEncapParent is the parent interface that noEncapRouteTable manages.  If the node's IP
+	// moves to another interface, the old table is kept, with no routes, so that it cleans up the
+	// old interface; staleNoEncapRouteTables holds such tables by interface.
+	noEncapParent           string
+	staleNoEncapRouteTables map[string]routeTable
+	// localVTEPChanged wakes the device thread when the address of our VTEP's parent changes.
+	localVTEPChanged chan struct{}
+	// deviceSyncLock is held by the device thread while it configures the device;
+	// deviceSyncStopped stops the thread.  See StopDeviceSync.
+	deviceSyncLock    sync.Mutex
+	deviceSyncStopped bool
 
 	// Hold pending updates.
 	routesByDest    map[string]*proto.RouteUpdate
@@ -72,6 +85,15 @@ type vxlanManager struct {
 	vxlanDevice string
 	vxlanID     int
 	vxlanPort   int
+	vxlanTOS    uint8
+
+	// Fabric planes, in order of preference, and whether each plane's local uplink is up.
+	fabricPlanes  []config.FabricPlane
+	planeIfacesUp map[string]bool
+	// planeProber, if non-nil, probes the remote nodes' plane addresses; the addresses that
+	// have stopped answering are in unreachablePlaneIPs.
+	planeProber         planeProber
+	unreachablePlaneIPs map[string]bool
 
 	// Indicates if configuration has changed since the last apply.
 	routesDirty       bool
@@ -97,6 +119,7 @@ func newVXLANManager(
 	deviceName string,
 	dpConfig Config,
 	opRecorder logutils.OpRecorder,
+	prober planeProber,
 ) *vxlanManager {
 	nlHandle, _ := netlink.NewHandle()
 
@@ -123,6 +146,7 @@ func newVXLANManager(
 		deviceName,
 		dpConfig,
 		nlHandle,
+		prober,
 		func(interfaceRegexes []string, ipVersion uint8, vxlan bool, netlinkTimeout time.Duration,
 			deviceRouteSourceAddress net.IP, deviceRouteProtocol int, removeExternalRoutes bool) routeTable {
 			return routetable.New(interfaceRegexes, ipVersion, vxlan, netlinkTimeout,
@@ -138,6 +162,7 @@ func newVXLANManagerWithShims(
 	deviceName string,
 	dpConfig Config,
 	nlHandle netlinkHandle,
+	prober planeProber,
 	noEncapRTConstruct func(interfacePrefixes []string, ipVersion uint8, vxlan bool, netlinkTimeout time.Duration,
 		deviceRouteSourceAddress net.IP, deviceRouteProtocol int, removeExternalRoutes bool) routeTable,
 ) *vxlanManager {
@@ -161,6 +186,11 @@ func newVXLANManagerWithShims(
 		vxlanDevice:         deviceName,
 		vxlanID:             dpConfig.RulesConfig.VXLANVNI,
 		vxlanPort:           dpConfig.RulesConfig.VXLANPort,
+		vxlanTOS:            dpConfig.VXLANDSCP.TOS(),
+		fabricPlanes:        dpConfig.VXLANFabricPlanes,
+		planeIfacesUp:       map[string]bool{},
+		planeProber:         prober,
+		unreachablePlaneIPs: map[string]bool{},
 		externalNodeCIDRs:   dpConfig.ExternalNodesCidrs,
 		routesDirty:         true,
 		vtepsDirty:          true,
@@ -168,6 +198,9 @@ func newVXLANManagerWithShims(
 		nlHandle:            nlHandle,
 		noEncapProtocol:     noEncapProtocol,
 		noEncapRTConstruct:  noEncapRTConstruct,
+
+		staleNoEncapRouteTables: map[string]routeTable{},
+		localVTEPChanged:        make(chan struct{}, 1),
 	}
 }
 
@@ -214,7 +247,81 @@ func (m *vxlanManager) OnUpdate(protoBufMsg interface{}) {
 		}
 		m.routesDirty = true
 		m.vtepsDirty = true
+	case *ifaceUpdate:
+		if !m.isPlaneIface(msg.Name) {
+			return
+		}
+		up := msg.State == ifacemonitor.StateUp
+		if m.planeIfacesUp[msg.Name] != up {
+			logrus.WithFields(logrus.Fields{"iface": msg.Name, "up": up}).Info(
+				"Fabric plane uplink changed state, updating VTEPs")
+			m.planeIfacesUp[msg.Name] = up
+			m.routesDirty = true
+			m.vtepsDirty = true
+		}
+	case *fabricPlaneReachabilityUpdate:
+		if m.unreachablePlaneIPs[msg.Addr] != !msg.Reachable {
+			logrus.WithFields(logrus.Fields{"addr": msg.Addr, "reachable": msg.Reachable}).Info(
+				"Remote fabric plane address changed reachability, updating VTEPs")
+			if msg.Reachable {
+				delete(m.unreachablePlaneIPs, msg.Addr)
+			} else {
+				m.unreachablePlaneIPs[msg.Addr] = true
+			}
+			m.routesDirty = true
+			m.vtepsDirty = true
+		}
+	}
+}
+
+func (m *vxlanManager) isPlaneIface(ifaceName string) bool {
+	for _, plane := range m.fabricPlanes {
+		if plane.Interface == ifaceName {
+			return true
+		}
+	}
+	return false
+}
+
+// vtepTunnelIP returns the address to tunnel to for the given remote VTEP: its address on the most
+// preferred fabric plane whose local uplink is up and on which the remote node answers probes, or
+// its parent device IP if there is no such plane.  Checking the remote end as well as our uplink
+// means that both nodes move off a plane that is broken between them, rather than each using a
+// different plane.
+func (m *vxlanManager) vtepTunnelIP(vtep *proto.VXLANTunnelEndpointUpdate) string {
+	for i, plane := range m.fabricPlanes {
+		if !m.planeIfacesUp[plane.Interface] || i >= len(vtep.FabricPlaneIps) {
+			continue
+		}
+		if planeIP := vtep.FabricPlaneIps[i]; planeIP != "" && !m.unreachablePlaneIPs[planeIP] {
+			return planeIP
+		}
 	}
+	return vtep.ParentDeviceIp
+}
+
+// updateProbeTargets tells the prober to probe the plane addresses of the current VTEPs and forgets
+// the reachability of any other addresses.
+func (m *vxlanManager) updateProbeTargets() {
+	if m.planeProber == nil {
+		return
+	}
+	var targets []string
+	wanted := map[string]bool{}
+	for _, u := range m.vtepsByNode {
+		for _, planeIP := range u.FabricPlaneIps {
+			if planeIP != "" && !wanted[planeIP] {
+				wanted[planeIP] = true
+				targets = append(targets, planeIP)
+			}
+		}
+	}
+	for addr := range m.unreachablePlaneIPs {
+		if !wanted[addr] {
+			delete(m.unreachablePlaneIPs, addr)
+		}
+	}
+	m.planeProber.SetTargets(targets)
 }
 
 func routeIsLocalVXLANBlock(msg *proto.RouteUpdate) bool {
@@ -260,6 +367,18 @@ func (m *vxlanManager) deleteRoute(dst string) {
 func (m *vxlanManager) setLocalVTEP(vtep *proto.VXLANTunnelEndpointUpdate) {
 	m.Lock()
 	defer m.Unlock()
+	if m.myVTEP != nil && vtep != nil && m.myVTEP.ParentDeviceIp != vtep.ParentDeviceIp {
+		// The node's IP has changed (for example, after a DHCP renewal or a failover); reconfigure
+		// the device now rather than at its next periodic check.
+		logrus.WithFields(logrus.Fields{
+			"old": m.myVTEP.ParentDeviceIp,
+			"new": vtep.ParentDeviceIp,
+		}).Info("Node IP changed, updating VXLAN tunnel device")
+		select {
+		case m.localVTEPChanged <- struct{}{}:
+		default:
+		}
+	}
 	m.myVTEP = vtep
 }
 
@@ -280,19 +399,57 @@ func (m *vxlanManager) getNoEncapRouteTable() routeTable {
 	return m.noEncapRouteTable
 }
 
-func (m *vxlanManager) setNoEncapRouteTable(rt routeTable) {
+func (m *vxlanManager) setNoEncapRouteTable(rt routeTable, parentName string) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.noEncapRouteTable = rt
+	m.noEncapParent = parentName
+}
+
+// noEncapRouteTableForParent returns the no encap route table for the given parent interface.  If
+// the parent has changed, the routes are removed from the old parent and a table for the new one
+// takes over.
+func (m *vxlanManager) noEncapRouteTableForParent(parentName string) routeTable {
 	m.Lock()
 	defer m.Unlock()
 
+	if m.noEncapParent == "" {
+		m.noEncapParent = parentName
+	}
+	if m.noEncapParent == parentName {
+		return m.noEncapRouteTable
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"old": m.noEncapParent,
+		"new": parentName,
+	}).Info("VXLAN parent interface changed, moving unencapsulated routes")
+	m.noEncapRouteTable.SetRoutes(m.noEncapParent, nil)
+	m.staleNoEncapRouteTables[m.noEncapParent] = m.noEncapRouteTable
+
+	rt := m.staleNoEncapRouteTables[parentName]
+	if rt != nil {
+		delete(m.staleNoEncapRouteTables, parentName)
+	} else {
+		rt = m.noEncapRTConstruct([]string{"^" + parentName + "$"}, 4, false, m.dpConfig.NetlinkTimeout,
+			m.dpConfig.DeviceRouteSourceAddress, m.noEncapProtocol, false)
+	}
 	m.noEncapRouteTable = rt
+	m.noEncapParent = parentName
+	return rt
 }
 
 func (m *vxlanManager) GetRouteTableSyncers() []routeTableSyncer {
 	rts := []routeTableSyncer{m.routeTable, m.blackholeRouteTable}
 
-	noEncapRouteTable := m.getNoEncapRouteTable()
-	if noEncapRouteTable != nil {
-		rts = append(rts, noEncapRouteTable)
+	m.Lock()
+	defer m.Unlock()
+	if m.noEncapRouteTable != nil {
+		rts = append(rts, m.noEncapRouteTable)
+	}
+	for _, rt := range m.staleNoEncapRouteTables {
+		rts = append(rts, rt)
 	}
 
 	return rts
@@ -332,6 +489,9 @@ func (m *vxlanManager) CompleteDeferredWork() error {
 
 		// The route table accepts the desired state. Start by setting the desired L2 "routes" by iterating
 		// known VTEPs.
+		// Without an underlay multicast group, the device copies broadcast and multicast frames
+		// to each remote VTEP that has a flood entry.
+		flood := m.dpConfig.RulesConfig.MulticastEnabled && m.dpConfig.VXLANMulticastGroup == nil
 		var l2routes []routetable.L2Target
 		for _, u := range m.vtepsByNode {
 			mac, err := net.ParseMAC(u.Mac)
@@ -343,12 +503,20 @@ func (m *vxlanManager) CompleteDeferredWork() error {
 			l2routes = append(l2routes, routetable.L2Target{
 				VTEPMAC: mac,
 				GW:      ip.FromString(u.Ipv4Addr),
-				IP:      ip.FromString(u.ParentDeviceIp),
+				IP:      ip.FromString(m.vtepTunnelIP(u)),
+				Flood:   flood,
 			})
 			allowedVXLANSources = append(allowedVXLANSources, u.ParentDeviceIp)
+			// The remote node may send from any of its planes.
+			for _, planeIP := range u.FabricPlaneIps {
+				if planeIP != "" && planeIP != u.ParentDeviceIp {
+					allowedVXLANSources = append(allowedVXLANSources, planeIP)
+				}
+			}
 		}
 		logrus.WithField("l2routes", l2routes).Debug("VXLAN manager sending L2 updates")
 		m.routeTable.SetL2Routes(m.vxlanDevice, l2routes)
+		m.updateProbeTargets()
 		m.ipsetsDataplane.AddOrReplaceIPSet(m.ipSetMetadata, allowedVXLANSources)
 		m.vtepsDirty = false
 	}
@@ -400,6 +568,20 @@ func (m *vxlanManager) CompleteDeferredWork() error {
 			}
 		}
 
+		if m.dpConfig.RulesConfig.MulticastEnabled {
+			// Multicast groups that should reach other nodes are routed over the device, which
+			// sends them to the underlay group, if one is configured, or copies them to each
+			// remote VTEP using the flood entries above.
+			for _, group := range m.dpConfig.MulticastGroupRoutes {
+				cidr, err := ip.CIDRFromString(group)
+				if err != nil {
+					logrus.WithError(err).WithField("group", group).Warn("Failed to parse multicast group route")
+					continue
+				}
+				vxlanRoutes = append(vxlanRoutes, routetable.Target{CIDR: cidr})
+			}
+		}
+
 		logrus.WithField("vxlanroutes", vxlanRoutes).Debug("VXLAN manager sending VXLAN L3 updates")
 		m.routeTable.SetRoutes(m.vxlanDevice, vxlanRoutes)
 
@@ -411,6 +593,7 @@ func (m *vxlanManager) CompleteDeferredWork() error {
 		if noEncapRouteTable != nil {
 			if parentDevice, err := m.getLocalVTEPParent(); err == nil {
 				ifName := parentDevice.Attrs().Name
+				noEncapRouteTable = m.noEncapRouteTableForParent(ifName)
 				log.WithField("link", parentDevice).WithField("routes", noEncapRoutes).Debug("VXLAN manager sending unencapsulated L3 updates")
 				noEncapRouteTable.SetRoutes(ifName, noEncapRoutes)
 			} else {
@@ -453,11 +636,18 @@ func (m *vxlanManager) KeepVXLANDeviceInSync(mtu int, xsumBroken bool, wait time
 			if m.getNoEncapRouteTable() == nil {
 				noEncapRouteTable := m.noEncapRTConstruct([]string{"^" + parent.Attrs().Name + "$"}, 4, false, m.dpConfig.NetlinkTimeout, m.dpConfig.DeviceRouteSourceAddress,
 					m.noEncapProtocol, false)
-				m.setNoEncapRouteTable(noEncapRouteTable)
+				m.setNoEncapRouteTable(noEncapRouteTable, parent.Attrs().Name)
 			}
 		}
 
+		m.deviceSyncLock.Lock()
+		if m.deviceSyncStopped {
+			m.deviceSyncLock.Unlock()
+			logrus.Info("VXLAN tunnel device thread stopped.")
+			return
+		}
 		err := m.configureVXLANDevice(mtu, localVTEP, xsumBroken)
+		m.deviceSyncLock.Unlock()
 		if err != nil {
 			logrus.WithError(err).Warn("Failed configure VXLAN tunnel device, retrying...")
 			logNextSuccess = true
@@ -469,10 +659,22 @@ func (m *vxlanManager) KeepVXLANDeviceInSync(mtu int, xsumBroken bool, wait time
 			logrus.Info("VXLAN tunnel device configured")
 			logNextSuccess = false
 		}
-		time.Sleep(wait)
+		select {
+		case <-m.localVTEPChanged:
+			logrus.Info("Local VTEP changed, reconfiguring VXLAN tunnel device")
+		case <-time.After(wait):
+		}
 	}
 }
 
+// StopDeviceSync stops the VXLAN tunnel device thread, waiting for any update that it's making to
+// finish, so that it doesn't put back what the caller is about to remove.
+func (m *vxlanManager) StopDeviceSync() {
+	m.deviceSyncLock.Lock()
+	defer m.deviceSyncLock.Unlock()
+	m.deviceSyncStopped = true
+}
+
 // getParentInterface returns the parent interface for the given local VTEP based on IP address. This link returned is nil
 // if, and only if, an error occurred
 func (m *vxlanManager) getParentInterface(localVTEP *proto.VXLANTunnelEndpointUpdate) (netlink.Link, error) {
@@ -516,6 +718,14 @@ func (m *vxlanManager) configureVXLANDevice(mtu int, localVTEP *proto.VXLANTunne
 		Port:         m.vxlanPort,
 		VtepDevIndex: parent.Attrs().Index,
 		SrcAddr:      ip.FromString(localVTEP.ParentDeviceIp).AsNetIP(),
+		TOS:          int(m.vxlanTOS),
+		Group:        m.dpConfig.VXLANMulticastGroup,
+	}
+	if len(m.fabricPlanes) > 0 {
+		// With multiple planes, the tunnel traffic has to leave through whichever uplink leads
+		// to the chosen plane, with that uplink's address, so don't tie the device to the parent.
+		vxlan.VtepDevIndex = 0
+		vxlan.SrcAddr = nil
 	}
 
 	// Try to get the device.
@@ -539,7 +749,20 @@ func (m *vxlanManager) configureVXLANDevice(mtu int, localVTEP *proto.VXLANTunne
 
 	// At this point, we have successfully queried the existing device, or made sure it exists if it didn't
 	// already. Check for mismatched configuration. If they don't match, recreate the device.
-	if incompat := vxlanLinksIncompat(vxlan, link); incompat != "" {
+	incompat := vxlanLinksIncompat(vxlan, link)
+	if incompat == "" && len(m.fabricPlanes) > 0 {
+		if v, ok := link.(*netlink.Vxlan); ok && (v.VtepDevIndex > 0 || len(v.SrcAddr) > 0) {
+			incompat = "device is tied to a parent interface but fabric planes are enabled"
+		}
+	}
+	if incompat == "" {
+		// vxlanLinksIncompat ignores a group that is only set on one side; we need to add or
+		// remove it.
+		if v, ok := link.(*netlink.Vxlan); ok && !v.Group.Equal(vxlan.Group) {
+			incompat = fmt.Sprintf("group address: %v vs %v", vxlan.Group, v.Group)
+		}
+	}
+	if incompat != "" {
 		// Existing device doesn't match desired configuration - delete it and recreate.
 		logrus.Warningf("%q exists with incompatible configuration: %v; recreating device", vxlan.Name, incompat)
 		if err = m.nlHandle.LinkDel(link); err != nil {
@@ -663,5 +886,9 @@ func vxlanLinksIncompat(l1, l2 netlink.Link) string {
 		return fmt.Sprintf("gbp: %v vs %v", v1.GBP, v2.GBP)
 	}
 
+	if v1.TOS != v2.TOS {
+		return fmt.Sprintf("tos: %v vs %v", v1.TOS, v2.TOS)
+	}
+
 	return ""
 }
@@ -18,6 +18,8 @@ import (
 	"net"
 	"time"
 
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/ifacemonitor"
 	"github.com/projectcalico/felix/rules"
 
 	"github.com/projectcalico/felix/ip"
@@ -31,8 +33,18 @@ import (
 	"github.com/projectcalico/felix/proto"
 )
 
+type mockPlaneProber struct {
+	targets []string
+}
+
+func (p *mockPlaneProber) SetTargets(addrs []string) {
+	p.targets = addrs
+}
+
 type mockVXLANDataplane struct {
 	links []netlink.Link
+	// addrs, if set, holds the addresses of each link by name.
+	addrs map[string][]netlink.Addr
 }
 
 func (m *mockVXLANDataplane) LinkByName(name string) (netlink.Link, error) {
@@ -55,6 +67,9 @@ func (m *mockVXLANDataplane) LinkSetUp(link netlink.Link) error {
 }
 
 func (m *mockVXLANDataplane) AddrList(link netlink.Link, family int) ([]netlink.Addr, error) {
+	if m.addrs != nil {
+		return m.addrs[link.Attrs().Name], nil
+	}
 	l := []netlink.Addr{{
 		IPNet: &net.IPNet{
 			IP: net.IPv4(172, 0, 0, 2),
@@ -117,6 +132,7 @@ var _ = Describe("VXLANManager", func() {
 			&mockVXLANDataplane{
 				links: []netlink.Link{&mockLink{attrs: netlink.LinkAttrs{Name: "eth0"}}},
 			},
+			nil,
 			func(interfacePrefixes []string, ipVersion uint8, vxlan bool, netlinkTimeout time.Duration,
 				deviceRouteSourceAddress net.IP, deviceRouteProtocol int, removeExternalRoutes bool) routeTable {
 				return prt
@@ -124,6 +140,13 @@ var _ = Describe("VXLANManager", func() {
 		)
 	})
 
+	It("should recreate the VXLAN device if its TOS is wrong", func() {
+		Expect(vxlanLinksIncompat(
+			&netlink.Vxlan{VxlanId: 1, TOS: 1},
+			&netlink.Vxlan{VxlanId: 1},
+		)).To(Equal("tos: 1 vs 0"))
+	})
+
 	It("successfully adds a route to the parent interface", func() {
 		manager.OnUpdate(&proto.VXLANTunnelEndpointUpdate{
 			Node:           "node1",
@@ -247,4 +270,202 @@ var _ = Describe("VXLANManager", func() {
 		Expect(manager.routesDirty).To(BeFalse())
 		Expect(prt.currentRoutes["eth0"]).To(HaveLen(1))
 	})
+
+	It("tunnels over the most preferred fabric plane whose uplink is up", func() {
+		manager.fabricPlanes = []config.FabricPlane{
+			{Interface: "eth0", CIDR: "10.1.0.0/16"},
+			{Interface: "eth1", CIDR: "10.2.0.0/16"},
+		}
+		manager.noEncapRouteTable = prt
+		manager.OnUpdate(&proto.VXLANTunnelEndpointUpdate{
+			Node:           "node1",
+			Mac:            "00:0a:74:9d:68:16",
+			Ipv4Addr:       "10.0.0.0",
+			ParentDeviceIp: "172.0.0.2",
+		})
+		manager.OnUpdate(&proto.VXLANTunnelEndpointUpdate{
+			Node:           "node2",
+			Mac:            "00:0a:95:9d:68:16",
+			Ipv4Addr:       "10.0.80.0",
+			ParentDeviceIp: "172.0.12.1",
+			FabricPlaneIps: []string{"10.1.0.2", "10.2.0.2"},
+		})
+		tunnelIP := func() ip.Addr {
+			Expect(manager.CompleteDeferredWork()).To(Succeed())
+			Expect(rt.currentL2Routes["vxlan.calico"]).To(HaveLen(1))
+			return rt.currentL2Routes["vxlan.calico"][0].IP
+		}
+
+		By("using the parent device IP until a plane's uplink is up")
+		Expect(tunnelIP()).To(Equal(ip.FromString("172.0.12.1")))
+
+		By("using the first plane when both uplinks are up")
+		manager.OnUpdate(&ifaceUpdate{Name: "eth0", State: ifacemonitor.StateUp})
+		manager.OnUpdate(&ifaceUpdate{Name: "eth1", State: ifacemonitor.StateUp})
+		Expect(tunnelIP()).To(Equal(ip.FromString("10.1.0.2")))
+
+		By("failing over to the second plane when the first uplink goes down")
+		manager.OnUpdate(&ifaceUpdate{Name: "eth0", State: ifacemonitor.StateDown})
+		Expect(tunnelIP()).To(Equal(ip.FromString("10.2.0.2")))
+
+		By("failing back when the first uplink comes back up")
+		manager.OnUpdate(&ifaceUpdate{Name: "eth0", State: ifacemonitor.StateUp})
+		Expect(tunnelIP()).To(Equal(ip.FromString("10.1.0.2")))
+	})
+
+	It("only tunnels over a fabric plane on which the remote node is reachable", func() {
+		prober := &mockPlaneProber{}
+		manager.planeProber = prober
+		manager.fabricPlanes = []config.FabricPlane{
+			{Interface: "eth0", CIDR: "10.1.0.0/16"},
+			{Interface: "eth1", CIDR: "10.2.0.0/16"},
+		}
+		manager.noEncapRouteTable = prt
+		manager.OnUpdate(&proto.VXLANTunnelEndpointUpdate{
+			Node:           "node2",
+			Mac:            "00:0a:95:9d:68:16",
+			Ipv4Addr:       "10.0.80.0",
+			ParentDeviceIp: "172.0.12.1",
+			FabricPlaneIps: []string{"10.1.0.2", "10.2.0.2"},
+		})
+		manager.OnUpdate(&ifaceUpdate{Name: "eth0", State: ifacemonitor.StateUp})
+		manager.OnUpdate(&ifaceUpdate{Name: "eth1", State: ifacemonitor.StateUp})
+		tunnelIP := func() ip.Addr {
+			Expect(manager.CompleteDeferredWork()).To(Succeed())
+			Expect(rt.currentL2Routes["vxlan.calico"]).To(HaveLen(1))
+			return rt.currentL2Routes["vxlan.calico"][0].IP
+		}
+
+		By("probing the remote node's plane addresses")
+		Expect(tunnelIP()).To(Equal(ip.FromString("10.1.0.2")))
+		Expect(prober.targets).To(ConsistOf("10.1.0.2", "10.2.0.2"))
+
+		By("failing over when the remote node stops answering on the first plane")
+		manager.OnUpdate(&fabricPlaneReachabilityUpdate{Addr: "10.1.0.2", Reachable: false})
+		Expect(tunnelIP()).To(Equal(ip.FromString("10.2.0.2")))
+
+		By("falling back to the parent device IP when neither plane answers")
+		manager.OnUpdate(&fabricPlaneReachabilityUpdate{Addr: "10.2.0.2", Reachable: false})
+		Expect(tunnelIP()).To(Equal(ip.FromString("172.0.12.1")))
+
+		By("failing back when the remote node answers on the first plane again")
+		manager.OnUpdate(&fabricPlaneReachabilityUpdate{Addr: "10.1.0.2", Reachable: true})
+		Expect(tunnelIP()).To(Equal(ip.FromString("10.1.0.2")))
+
+		By("forgetting the plane addresses of a removed node")
+		manager.OnUpdate(&proto.VXLANTunnelEndpointRemove{Node: "node2"})
+		Expect(manager.CompleteDeferredWork()).To(Succeed())
+		Expect(prober.targets).To(BeEmpty())
+		Expect(manager.unreachablePlaneIPs).To(BeEmpty())
+	})
+
+	It("routes the configured multicast groups over the VXLAN device", func() {
+		manager.dpConfig.RulesConfig.MulticastEnabled = true
+		manager.dpConfig.MulticastGroupRoutes = []string{"239.1.0.0/16"}
+		manager.noEncapRouteTable = prt
+		manager.OnUpdate(&proto.VXLANTunnelEndpointUpdate{
+			Node:           "node1",
+			Mac:            "00:0a:74:9d:68:16",
+			Ipv4Addr:       "10.0.0.0",
+			ParentDeviceIp: "172.0.0.2",
+		})
+		manager.OnUpdate(&proto.VXLANTunnelEndpointUpdate{
+			Node:           "node2",
+			Mac:            "00:0a:95:9d:68:16",
+			Ipv4Addr:       "10.0.80.0",
+			ParentDeviceIp: "172.0.12.1",
+		})
+
+		Expect(manager.CompleteDeferredWork()).To(Succeed())
+		Expect(rt.currentRoutes["vxlan.calico"]).To(ConsistOf(routetable.Target{
+			CIDR: ip.MustParseCIDROrIP("239.1.0.0/16"),
+		}))
+		Expect(rt.currentL2Routes["vxlan.calico"]).To(HaveLen(1))
+		Expect(rt.currentL2Routes["vxlan.calico"][0].Flood).To(BeTrue())
+	})
+
+	It("leaves flooding to the underlay multicast group if there is one", func() {
+		manager.dpConfig.RulesConfig.MulticastEnabled = true
+		manager.dpConfig.VXLANMulticastGroup = net.ParseIP("239.0.0.1")
+		manager.noEncapRouteTable = prt
+		manager.OnUpdate(&proto.VXLANTunnelEndpointUpdate{
+			Node:           "node1",
+			Mac:            "00:0a:74:9d:68:16",
+			Ipv4Addr:       "10.0.0.0",
+			ParentDeviceIp: "172.0.0.2",
+		})
+		manager.OnUpdate(&proto.VXLANTunnelEndpointUpdate{
+			Node:           "node2",
+			Mac:            "00:0a:95:9d:68:16",
+			Ipv4Addr:       "10.0.80.0",
+			ParentDeviceIp: "172.0.12.1",
+		})
+
+		Expect(manager.CompleteDeferredWork()).To(Succeed())
+		Expect(rt.currentL2Routes["vxlan.calico"]).To(HaveLen(1))
+		Expect(rt.currentL2Routes["vxlan.calico"][0].Flood).To(BeFalse())
+	})
+
+	It("moves the unencapsulated routes when the node IP moves to another interface", func() {
+		nlHandle := &mockVXLANDataplane{
+			links: []netlink.Link{
+				&mockLink{attrs: netlink.LinkAttrs{Name: "eth0"}},
+				&mockLink{attrs: netlink.LinkAttrs{Name: "eth1"}},
+			},
+			addrs: map[string][]netlink.Addr{
+				"eth0": {{IPNet: &net.IPNet{IP: net.IPv4(172, 0, 0, 2)}}},
+				"eth1": {{IPNet: &net.IPNet{IP: net.IPv4(172, 0, 0, 3)}}},
+			},
+		}
+		manager.nlHandle = nlHandle
+		manager.noEncapRouteTable = prt
+		prt2 := &mockRouteTable{
+			currentRoutes:   map[string][]routetable.Target{},
+			currentL2Routes: map[string][]routetable.L2Target{},
+		}
+		var constructedFor []string
+		manager.noEncapRTConstruct = func(interfacePrefixes []string, ipVersion uint8, vxlan bool, netlinkTimeout time.Duration,
+			deviceRouteSourceAddress net.IP, deviceRouteProtocol int, removeExternalRoutes bool) routeTable {
+			constructedFor = append(constructedFor, interfacePrefixes...)
+			return prt2
+		}
+
+		manager.OnUpdate(&proto.VXLANTunnelEndpointUpdate{
+			Node:           "node1",
+			Mac:            "00:0a:74:9d:68:16",
+			Ipv4Addr:       "10.0.0.0",
+			ParentDeviceIp: "172.0.0.2",
+		})
+		manager.OnUpdate(&proto.VXLANTunnelEndpointUpdate{
+			Node:           "node2",
+			Mac:            "00:0a:95:9d:68:16",
+			Ipv4Addr:       "10.0.80.0",
+			ParentDeviceIp: "172.0.12.1",
+		})
+		manager.OnUpdate(&proto.RouteUpdate{
+			Type:        proto.RouteType_REMOTE_WORKLOAD,
+			IpPoolType:  proto.IPPoolType_VXLAN,
+			Dst:         "172.0.0.1/26",
+			DstNodeName: "node2",
+			DstNodeIp:   "172.8.8.8",
+			SameSubnet:  true,
+		})
+		Expect(manager.CompleteDeferredWork()).To(Succeed())
+		Expect(prt.currentRoutes["eth0"]).To(HaveLen(1))
+
+		manager.OnUpdate(&proto.VXLANTunnelEndpointUpdate{
+			Node:           "node1",
+			Mac:            "00:0a:74:9d:68:16",
+			Ipv4Addr:       "10.0.0.0",
+			ParentDeviceIp: "172.0.0.3",
+		})
+		Expect(manager.localVTEPChanged).To(Receive())
+		Expect(manager.CompleteDeferredWork()).To(Succeed())
+
+		Expect(constructedFor).To(Equal([]string{"^eth1$"}))
+		Expect(prt.currentRoutes["eth0"]).To(HaveLen(0))
+		Expect(prt2.currentRoutes["eth1"]).To(HaveLen(1))
+		Expect(manager.GetRouteTableSyncers()).To(ContainElement(prt))
+		Expect(manager.GetRouteTableSyncers()).To(ContainElement(prt2))
+	})
 })
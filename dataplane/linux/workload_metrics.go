@@ -0,0 +1,135 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+
+	"github.com/projectcalico/felix/proto"
+)
+
+var (
+	workloadMetricLabels = []string{"namespace", "workload", "endpoint", "iface"}
+
+	descWorkloadBytesSent = prometheus.NewDesc(
+		"felix_workload_bytes_sent",
+		"Number of bytes sent by the local workload endpoint.",
+		workloadMetricLabels, nil,
+	)
+	descWorkloadBytesReceived = prometheus.NewDesc(
+		"felix_workload_bytes_received",
+		"Number of bytes received by the local workload endpoint.",
+		workloadMetricLabels, nil,
+	)
+	descWorkloadPacketsSent = prometheus.NewDesc(
+		"felix_workload_packets_sent",
+		"Number of packets sent by the local workload endpoint.",
+		workloadMetricLabels, nil,
+	)
+	descWorkloadPacketsReceived = prometheus.NewDesc(
+		"felix_workload_packets_received",
+		"Number of packets received by the local workload endpoint.",
+		workloadMetricLabels, nil,
+	)
+)
+
+// workloadMetricsManager exports per-workload traffic counters to Prometheus.  The counters are
+// read, at scrape time, from the statistics of the host side of each workload's interface, so
+// they count all traffic to and from the workload whichever dataplane is in use.  Note that the
+// host side receives what the workload sends, and vice versa.
+//
+// The counters are reset if the workload's interface is recreated.
+type workloadMetricsManager struct {
+	lock            sync.Mutex
+	ifaceToLabels   map[string][]string
+	endpointToIface map[proto.WorkloadEndpointID]string
+
+	// Shim for testing.
+	listLinks func() ([]netlink.Link, error)
+}
+
+func newWorkloadMetricsManager() *workloadMetricsManager {
+	return &workloadMetricsManager{
+		ifaceToLabels:   map[string][]string{},
+		endpointToIface: map[proto.WorkloadEndpointID]string{},
+		listLinks:       netlink.LinkList,
+	}
+}
+
+func (m *workloadMetricsManager) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *proto.WorkloadEndpointUpdate:
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		m.removeEndpoint(*msg.Id)
+		iface := msg.Endpoint.Name
+		namespace, workload := "", msg.Id.WorkloadId
+		if parts := strings.SplitN(msg.Id.WorkloadId, "/", 2); len(parts) == 2 {
+			namespace, workload = parts[0], parts[1]
+		}
+		m.endpointToIface[*msg.Id] = iface
+		m.ifaceToLabels[iface] = []string{namespace, workload, msg.Id.EndpointId, iface}
+	case *proto.WorkloadEndpointRemove:
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		m.removeEndpoint(*msg.Id)
+	}
+}
+
+func (m *workloadMetricsManager) removeEndpoint(id proto.WorkloadEndpointID) {
+	if iface, ok := m.endpointToIface[id]; ok {
+		delete(m.ifaceToLabels, iface)
+		delete(m.endpointToIface, id)
+	}
+}
+
+func (m *workloadMetricsManager) CompleteDeferredWork() error {
+	return nil
+}
+
+func (m *workloadMetricsManager) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descWorkloadBytesSent
+	ch <- descWorkloadBytesReceived
+	ch <- descWorkloadPacketsSent
+	ch <- descWorkloadPacketsReceived
+}
+
+func (m *workloadMetricsManager) Collect(ch chan<- prometheus.Metric) {
+	links, err := m.listLinks()
+	if err != nil {
+		log.WithError(err).Warn("Failed to list interfaces for workload metrics.")
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, link := range links {
+		attrs := link.Attrs()
+		labels, ok := m.ifaceToLabels[attrs.Name]
+		if !ok || attrs.Statistics == nil {
+			continue
+		}
+		stats := attrs.Statistics
+		ch <- prometheus.MustNewConstMetric(descWorkloadBytesSent, prometheus.CounterValue, float64(stats.RxBytes), labels...)
+		ch <- prometheus.MustNewConstMetric(descWorkloadBytesReceived, prometheus.CounterValue, float64(stats.TxBytes), labels...)
+		ch <- prometheus.MustNewConstMetric(descWorkloadPacketsSent, prometheus.CounterValue, float64(stats.RxPackets), labels...)
+		ch <- prometheus.MustNewConstMetric(descWorkloadPacketsReceived, prometheus.CounterValue, float64(stats.TxPackets), labels...)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/vishvananda/netlink"
+
+	"github.com/projectcalico/felix/proto"
+)
+
+var _ = Describe("Workload metrics manager", func() {
+	var (
+		mgr   *workloadMetricsManager
+		links []netlink.Link
+	)
+
+	wepID := proto.WorkloadEndpointID{OrchestratorId: "k8s", WorkloadId: "ns1/pod1", EndpointId: "eth0"}
+
+	collect := func() map[string]*dto.Metric {
+		ch := make(chan prometheus.Metric, 100)
+		mgr.Collect(ch)
+		close(ch)
+		metrics := map[string]*dto.Metric{}
+		for m := range ch {
+			var pb dto.Metric
+			Expect(m.Write(&pb)).To(Succeed())
+			metrics[m.Desc().String()] = &pb
+		}
+		return metrics
+	}
+
+	BeforeEach(func() {
+		links = []netlink.Link{
+			&netlink.Veth{LinkAttrs: netlink.LinkAttrs{
+				Name:       "cali1234",
+				Statistics: &netlink.LinkStatistics{RxBytes: 1000, TxBytes: 2000, RxPackets: 10, TxPackets: 20},
+			}},
+			&netlink.Device{LinkAttrs: netlink.LinkAttrs{
+				Name:       "eth0",
+				Statistics: &netlink.LinkStatistics{RxBytes: 5, TxBytes: 5},
+			}},
+		}
+		mgr = newWorkloadMetricsManager()
+		mgr.listLinks = func() ([]netlink.Link, error) { return links, nil }
+	})
+
+	It("should report nothing with no endpoints", func() {
+		Expect(collect()).To(BeEmpty())
+	})
+
+	Describe("with a workload endpoint", func() {
+		BeforeEach(func() {
+			mgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+				Id:       &wepID,
+				Endpoint: &proto.WorkloadEndpoint{Name: "cali1234"},
+			})
+		})
+
+		It("should report the workload's counters from the workload's point of view", func() {
+			metrics := collect()
+			Expect(metrics).To(HaveLen(4))
+			sent := metrics[descWorkloadBytesSent.String()]
+			Expect(sent.GetCounter().GetValue()).To(Equal(1000.0))
+			Expect(metrics[descWorkloadBytesReceived.String()].GetCounter().GetValue()).To(Equal(2000.0))
+			Expect(metrics[descWorkloadPacketsSent.String()].GetCounter().GetValue()).To(Equal(10.0))
+			Expect(metrics[descWorkloadPacketsReceived.String()].GetCounter().GetValue()).To(Equal(20.0))
+
+			labels := map[string]string{}
+			for _, l := range sent.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			Expect(labels).To(Equal(map[string]string{
+				"namespace": "ns1",
+				"workload":  "pod1",
+				"endpoint":  "eth0",
+				"iface":     "cali1234",
+			}))
+		})
+
+		It("should stop reporting the endpoint after it is removed", func() {
+			mgr.OnUpdate(&proto.WorkloadEndpointRemove{Id: &wepID})
+			Expect(collect()).To(BeEmpty())
+		})
+
+		It("should follow an interface rename", func() {
+			mgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+				Id:       &wepID,
+				Endpoint: &proto.WorkloadEndpoint{Name: "eth0"},
+			})
+			Expect(collect()[descWorkloadBytesSent.String()].GetCounter().GetValue()).To(Equal(5.0))
+		})
+	})
+})
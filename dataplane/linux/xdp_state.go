@@ -17,6 +17,7 @@ package intdataplane
 import (
 	"fmt"
 	"net"
+	"reflect"
 	"strings"
 	"time"
 
@@ -99,15 +100,18 @@ type xdpState struct {
 	common    xdpStateCommon
 }
 
-func NewXDPState(allowGenericXDP bool) (*xdpState, error) {
+// NewXDPState creates the XDP state.  If chainXDP is true, then, rather than replacing another
+// XDP program on an interface that needs ours, we attach ours in its place and pass the packets
+// that we allow on to it.
+func NewXDPState(allowGenericXDP, chainXDP bool) (*xdpState, error) {
 	lib, err := bpf.NewBPFLib("/usr/lib/calico/bpf/")
 	if err != nil {
 		return nil, err
 	}
-	return NewXDPStateWithBPFLibrary(lib, allowGenericXDP), nil
+	return NewXDPStateWithBPFLibrary(lib, allowGenericXDP, chainXDP), nil
 }
 
-func NewXDPStateWithBPFLibrary(library bpf.BPFDataplane, allowGenericXDP bool) *xdpState {
+func NewXDPStateWithBPFLibrary(library bpf.BPFDataplane, allowGenericXDP, chainXDP bool) *xdpState {
 	log.Debug("Created new xdpState.")
 	return &xdpState{
 		ipV4State: newXDPIPState(4),
@@ -116,6 +120,7 @@ func NewXDPStateWithBPFLibrary(library bpf.BPFDataplane, allowGenericXDP bool) *
 			needResync: true,
 			bpfLib:     library,
 			xdpModes:   getXDPModes(allowGenericXDP),
+			chainXDP:   chainXDP,
 		},
 	}
 }
@@ -168,6 +173,15 @@ func (x *xdpState) DepopulateCallbacks(cbs *callbacks) {
 	}
 }
 
+// NumInconsistencies returns the number of problems with the XDP programs, maps and map contents
+// that resyncs have found (and queued for repair) since we were created.
+func (x *xdpState) NumInconsistencies() int {
+	if x.ipV4State == nil {
+		return 0
+	}
+	return x.ipV4State.numInconsistencies
+}
+
 func (x *xdpState) QueueResync() {
 	x.common.needResync = true
 }
@@ -206,7 +220,7 @@ func (x *xdpState) ResyncIfNeeded(ipsSourceV4 ipsetsSource) error {
 func (x *xdpState) ApplyBPFActions(ipsSource ipsetsSource) error {
 	if x.ipV4State != nil {
 		memberCacheV4 := newXDPMemberCache(x.ipV4State.getBpfIPFamily(), x.common.bpfLib)
-		err := x.ipV4State.bpfActions.apply(memberCacheV4, x.ipV4State.ipsetIDsToMembers, newConvertingIPSetsSource(ipsSource), x.common.xdpModes)
+		err := x.ipV4State.bpfActions.apply(memberCacheV4, x.ipV4State.ipsetIDsToMembers, newConvertingIPSetsSource(ipsSource), x.common.xdpModes, x.common.chainXDP)
 		x.ipV4State.bpfActions = newXDPBPFActions()
 		if err != nil {
 			log.WithError(err).Info("Applying BPF actions did not succeed. Queueing XDP resync.")
@@ -292,6 +306,9 @@ type xdpIPState struct {
 	bpfActions        *xdpBPFActions
 	cbIDs             []*CbID
 	logCxt            *log.Entry
+	// numInconsistencies counts the differences between the dataplane and currentState that
+	// resyncs have found.
+	numInconsistencies int
 }
 
 type ipsetIDsToMembers struct {
@@ -314,6 +331,11 @@ func (i *ipsetIDsToMembers) Clear() {
 	i.pendingDeletions = make(map[string]set.Set)
 }
 
+// HasPending returns true if there are member updates that haven't been applied to the cache yet.
+func (i *ipsetIDsToMembers) HasPending() bool {
+	return len(i.pendingReplaces) > 0 || len(i.pendingAdds) > 0 || len(i.pendingDeletions) > 0
+}
+
 func (i *ipsetIDsToMembers) GetCached(setID string) (s set.Set, ok bool) {
 	s, ok = i.cache[setID]
 	return
@@ -448,7 +470,7 @@ func (s *xdpIPState) getBpfIPFamily() bpf.IPFamily {
 }
 
 // newXDPResyncState creates the xdpResyncState object, returning an error on failure.
-func (s *xdpIPState) newXDPResyncState(bpfLib bpf.BPFDataplane, ipsSource ipsetsSource, programTag string, xpdModes []bpf.XDPMode) (*xdpResyncState, error) {
+func (s *xdpIPState) newXDPResyncState(bpfLib bpf.BPFDataplane, ipsSource ipsetsSource, programTag string, xpdModes []bpf.XDPMode, chainXDP bool) (*xdpResyncState, error) {
 	xdpIfaces, err := bpfLib.GetXDPIfaces()
 	if err != nil {
 		return nil, err
@@ -461,7 +483,8 @@ func (s *xdpIPState) newXDPResyncState(bpfLib bpf.BPFDataplane, ipsSource ipsets
 		// error can happen when the program was not pinned in the bpf filesystem, so we say it's bogus anyway
 		bogus := tagErr != nil || tag != programTag || modeErr != nil || !isValidMode(mode, xpdModes)
 		ifacesWithProgs[iface] = progInfo{
-			bogus: bogus,
+			bogus:   bogus,
+			foreign: chainXDP && isForeignXDP(bpfLib, iface),
 		}
 	}
 	ifacesWithPinnedMaps, err := bpfLib.ListCIDRMaps(s.getBpfIPFamily())
@@ -580,16 +603,49 @@ func (s *xdpIPState) tryResync(common *xdpStateCommon, ipsSource ipsetsSource) e
 	defer func() {
 		s.logCxt.WithField("resyncDuration", time.Since(resyncStart)).Debug("Finished XDP resync.")
 	}()
+	// Map contents only tell us about inconsistencies if there are no member updates pending;
+	// otherwise we can't tell a pending update from a repair.
+	membersPending := s.ipsetIDsToMembers.HasPending()
 	s.ipsetIDsToMembers.Clear()
-	resyncState, err := s.newXDPResyncState(common.bpfLib, ipsSource, common.programTag, common.xdpModes)
+	resyncState, err := s.newXDPResyncState(common.bpfLib, ipsSource, common.programTag, common.xdpModes, common.chainXDP)
 	if err != nil {
 		return err
 	}
+	s.countProgramAndMapInconsistencies(resyncState)
 	s.fixupXDPProgramAndMapConsistency(resyncState)
-	s.fixupBlacklistContents(resyncState)
+	s.fixupBlacklistContents(resyncState, !membersPending)
 	return nil
 }
 
+// countProgramAndMapInconsistencies counts the interfaces whose XDP program or map doesn't match
+// what we think we've programmed.
+func (s *xdpIPState) countProgramAndMapInconsistencies(resyncState *xdpResyncState) {
+	ifaces := s.getIfaces(resyncState, giNS|giWX|giIX|giUX|giWM|giCM|giRM)
+	ifaces.Iter(func(item interface{}) error {
+		iface := item.(string)
+		programmed := false
+		if data, ok := s.currentState.IfaceNameToData[iface]; ok {
+			programmed = data.NeedsXDP()
+		}
+		progInfo, hasProg := resyncState.ifacesWithProgs[iface]
+		if hasProg && progInfo.foreign {
+			hasProg = false
+		}
+		mapInfo, hasMap := resyncState.ifacesWithMaps[iface]
+		var consistent bool
+		if programmed {
+			consistent = hasProg && !progInfo.bogus && hasMap && !mapInfo.bogus && !mapInfo.mismatched
+		} else {
+			consistent = !hasProg && !hasMap
+		}
+		if !consistent {
+			s.logCxt.WithField("iface", iface).Info("Resync - XDP program or map is out of sync.")
+			s.numInconsistencies++
+		}
+		return nil
+	})
+}
+
 // fixupXDPProgramAndMapConsistency ensures that XDP programs are
 // installed on the proper network interfaces, are valid, and
 // reference the correct maps.
@@ -628,6 +684,11 @@ func (s *xdpIPState) fixupXDPProgramAndMapConsistency(resyncState *xdpResyncStat
 		}()
 		hasXDP, hasBogusXDP := func() (bool, bool) {
 			if progInfo, ok := resyncState.ifacesWithProgs[iface]; ok {
+				if progInfo.foreign {
+					// Another program that we'll leave alone, or chain after ours if
+					// we need XDP here.
+					return false, false
+				}
 				return true, progInfo.bogus
 			}
 			return false, false
@@ -776,7 +837,10 @@ func (s *xdpIPState) fixupXDPProgramAndMapConsistency(resyncState *xdpResyncStat
 // desired contents of the map, figure out the missing or superfluous
 // members and update the BPF actions that are about modifying the BPF
 // maps on a member level.
-func (s *xdpIPState) fixupBlacklistContents(resyncState *xdpResyncState) {
+//
+// If countInconsistencies is true, members of existing maps that don't match currentState are
+// counted as inconsistencies.
+func (s *xdpIPState) fixupBlacklistContents(resyncState *xdpResyncState, countInconsistencies bool) {
 	ifaces := s.getIfaces(resyncState, giNS)
 	ifaces.Iter(func(item interface{}) error {
 		iface := item.(string)
@@ -791,7 +855,7 @@ func (s *xdpIPState) fixupBlacklistContents(resyncState *xdpResyncState) {
 			if _, ok := resyncState.ifacesWithMaps[iface]; !ok {
 				s.logCxt.WithField("iface", iface).Panic("Resync - iface missing from ifaces with maps in resync state!")
 			}
-			s.fixupBlacklistContentsExistingMap(resyncState, iface)
+			s.fixupBlacklistContentsExistingMap(resyncState, iface, countInconsistencies)
 		}
 		s.logCxt.WithFields(log.Fields{
 			"iface":         iface,
@@ -817,9 +881,16 @@ func (s *xdpIPState) fixupBlacklistContentsFreshMap(iface string) {
 	delete(s.bpfActions.RemoveFromMap, iface)
 }
 
-func (s *xdpIPState) fixupBlacklistContentsExistingMap(resyncState *xdpResyncState, iface string) {
+func (s *xdpIPState) fixupBlacklistContentsExistingMap(
+	resyncState *xdpResyncState,
+	iface string,
+	countInconsistencies bool,
+) {
 	membersInBpfMap := resyncState.ifacesWithMaps[iface].contents
 	setIDsInNS := s.getSetIDToRefCountFromNewState(iface)
+	// If the iface's IP sets are changing, the changes to the contents are updates, not repairs.
+	countInconsistencies = countInconsistencies &&
+		reflect.DeepEqual(setIDsInNS, getSetIDToRefCount(s.currentState, iface))
 	membersInNS := make(map[string]uint32)
 	for setID, refCount := range setIDsInNS {
 		if _, ok := resyncState.ipsetMembers[setID]; !ok {
@@ -844,6 +915,9 @@ func (s *xdpIPState) fixupBlacklistContentsExistingMap(resyncState *xdpResyncSta
 			"actualRefCount":   actualRefCount,
 			"expectedRefCount": expectedRefCount,
 		}).Debug("Resync - syncing member.")
+		if expectedRefCount != actualRefCount && countInconsistencies {
+			s.numInconsistencies++
+		}
 		if expectedRefCount > actualRefCount {
 			s.updateMembersToChange(s.bpfActions.MembersToAdd, iface, member, expectedRefCount-actualRefCount)
 		} else if expectedRefCount < actualRefCount {
@@ -857,6 +931,9 @@ func (s *xdpIPState) fixupBlacklistContentsExistingMap(resyncState *xdpResyncSta
 			"member":           member,
 			"expectedRefCount": expectedRefCount,
 		}).Debug("Resync - missing member.")
+		if countInconsistencies {
+			s.numInconsistencies++
+		}
 		s.updateMembersToChange(s.bpfActions.MembersToAdd, iface, member, expectedRefCount)
 	}
 	delete(s.bpfActions.AddToMap, iface)
@@ -876,8 +953,12 @@ func (s *xdpIPState) updateMembersToChange(membersToChangeMap map[string]map[str
 }
 
 func (s *xdpIPState) getSetIDToRefCountFromNewState(iface string) map[string]uint32 {
+	return getSetIDToRefCount(s.newCurrentState, iface)
+}
+
+func getSetIDToRefCount(state *xdpSystemState, iface string) map[string]uint32 {
 	setIDToRefCount := make(map[string]uint32)
-	if data, ok := s.newCurrentState.IfaceNameToData[iface]; ok {
+	if data, ok := state.IfaceNameToData[iface]; ok {
 		for _, setIDs := range data.PoliciesToSetIDs {
 			setIDs.Iter(func(item interface{}) error {
 				setID := item.(string)
@@ -1474,6 +1555,7 @@ func isValidRuleForXDP(rule *proto.Rule) bool {
 		len(rule.NotDstPorts) == 0 &&
 		len(rule.NotDstIpSetIds) == 0 &&
 		len(rule.NotDstNamedPortIpSetIds) == 0 &&
+		len(rule.DstDomains) == 0 &&
 		// have no application layer policy stuff
 		rule.HttpMatch == nil &&
 		rule.SrcServiceAccountMatch == nil &&
@@ -1674,6 +1756,7 @@ type xdpStateCommon struct {
 	needResync bool
 	bpfLib     bpf.BPFDataplane
 	xdpModes   []bpf.XDPMode
+	chainXDP   bool
 }
 
 type xdpSystemState struct {
@@ -1778,7 +1861,7 @@ func newXDPBPFActions() *xdpBPFActions {
 // installs XDP programs, creates and removes BPF maps, adds and
 // removes whole ipsets into/from the BPF maps, adds and removes
 // certain members to/from BPF maps.
-func (a *xdpBPFActions) apply(memberCache *xdpMemberCache, ipsetIDsToMembers *ipsetIDsToMembers, ipsSource ipsetsSource, xdpModes []bpf.XDPMode) error {
+func (a *xdpBPFActions) apply(memberCache *xdpMemberCache, ipsetIDsToMembers *ipsetIDsToMembers, ipsSource ipsetsSource, xdpModes []bpf.XDPMode, chainXDP bool) error {
 	var opErr error
 	logCxt := log.WithField("family", memberCache.GetFamily().String())
 
@@ -1903,6 +1986,20 @@ func (a *xdpBPFActions) apply(memberCache *xdpMemberCache, ipsetIDsToMembers *ip
 	a.InstallXDP.Iter(func(item interface{}) error {
 		iface := item.(string)
 		logCxt.WithField("iface", iface).Debug("Loading XDP program.")
+		if chainXDP && isForeignXDP(memberCache.bpfLib, iface) {
+			id, err := memberCache.bpfLib.GetXDPID(iface)
+			if err == nil {
+				logCxt.WithFields(log.Fields{
+					"iface": iface,
+					"id":    id,
+				}).Info("Chaining existing XDP program after ours.")
+				err = memberCache.bpfLib.ChainXDP(iface, id)
+			}
+			if err != nil {
+				opErr = fmt.Errorf("failed to chain existing XDP program on %s: %v", iface, err)
+				return set.StopIteration
+			}
+		}
 		var loadErrs []error
 		for _, mode := range xdpModes {
 			if err := memberCache.bpfLib.LoadXDPAuto(iface, mode); err != nil {
@@ -1930,6 +2027,17 @@ func (a *xdpBPFActions) apply(memberCache *xdpMemberCache, ipsetIDsToMembers *ip
 	return nil
 }
 
+// isForeignXDP returns true if the interface has an XDP program attached that isn't ours.
+func isForeignXDP(bpfLib bpf.BPFDataplane, iface string) bool {
+	id, err := bpfLib.GetXDPID(iface)
+	if err != nil {
+		// No program attached.
+		return false
+	}
+	pinnedID, err := bpfLib.GetPinnedXDPID(iface)
+	return err != nil || pinnedID != id
+}
+
 func getXDPModes(allowGenericXDP bool) []bpf.XDPMode {
 	modes := []bpf.XDPMode{
 		bpf.XDPOffload,
@@ -2240,6 +2348,8 @@ type xdpResyncState struct {
 
 type progInfo struct {
 	bogus bool
+	// foreign is set if the program isn't ours and we're chaining other programs after ours.
+	foreign bool
 }
 
 type mapInfo struct {
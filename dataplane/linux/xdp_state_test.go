@@ -436,7 +436,7 @@ var _ = Describe("XDP state", func() {
 
 			DescribeTable("",
 				func(s testStruct) {
-					state := NewXDPStateWithBPFLibrary(bpf.NewMockBPFLib("../../bpf-apache/bin"), true)
+					state := NewXDPStateWithBPFLibrary(bpf.NewMockBPFLib("../../bpf-apache/bin"), true, false)
 					ipState := state.ipV4State
 					cs := ipState.currentState
 					expectedNcs := newXDPSystemState()
@@ -1721,10 +1721,25 @@ var _ = Describe("XDP state", func() {
 				actions         *xdpBPFActions
 			}
 
+			It("should count the XDP programs and maps that don't match the current state", func() {
+				lib, programTag := bpfStateToBpfLib(map[string]bpfIfaceData{
+					"ifMap":     {mapExists: true},
+					"ifProg":    {hasXDP: true},
+					"ifProgMap": {hasXDP: true, mapExists: true},
+				})
+				state := NewXDPStateWithBPFLibrary(lib, false, false)
+				state.common.programTag = programTag
+				ipState := state.ipV4State
+				ipState.newCurrentState = newXDPSystemState()
+				err := ipState.tryResync(&state.common, newConvertingIPSetsSource(&nilIPSetsSource{}))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(state.NumInconsistencies()).To(Equal(3))
+			})
+
 			DescribeTable("resync",
 				func(s testStruct) {
 					lib, programTag := bpfStateToBpfLib(s.bpfState)
-					state := NewXDPStateWithBPFLibrary(lib, false)
+					state := NewXDPStateWithBPFLibrary(lib, false, false)
 					state.common.programTag = programTag
 					ipState := state.ipV4State
 					ipState.newCurrentState = newXDPSystemState()
@@ -2185,7 +2200,7 @@ var _ = Describe("XDP state", func() {
 					family := bpf.IPFamilyV4
 					lib := stateToBPFDataplane(bpfState, family)
 					memberCache := newXDPMemberCache(family, lib)
-					state := NewXDPStateWithBPFLibrary(lib, true)
+					state := NewXDPStateWithBPFLibrary(lib, true, false)
 					ipState := state.ipV4State
 					ipState.newCurrentState = newXDPSystemState()
 					testStateToRealState(s.newCurrentState, nil, ipState.newCurrentState)
@@ -2400,7 +2415,7 @@ var _ = Describe("XDP state", func() {
 					},
 				},
 			}
-			state := NewXDPStateWithBPFLibrary(bpf.NewMockBPFLib("../../bpf-apache/bin"), true)
+			state := NewXDPStateWithBPFLibrary(bpf.NewMockBPFLib("../../bpf-apache/bin"), true, false)
 			ipState := state.ipV4State
 			testStateToRealState(testState, nil, ipState.currentState)
 			cache := ipState.ipsetIDsToMembers
@@ -2455,7 +2470,7 @@ var _ = Describe("XDP state", func() {
 
 			DescribeTable("",
 				func(s testStruct) {
-					state := NewXDPStateWithBPFLibrary(bpf.NewMockBPFLib("../../bpf-apache/bin"), false)
+					state := NewXDPStateWithBPFLibrary(bpf.NewMockBPFLib("../../bpf-apache/bin"), false, false)
 					state.ipV4State.bpfActions.InstallXDP.AddAll(s.install)
 					state.ipV4State.bpfActions.UninstallXDP.AddAll(s.uninstall)
 					state.ipV4State.bpfActions.CreateMap.AddAll(s.create)
@@ -2472,7 +2487,7 @@ var _ = Describe("XDP state", func() {
 					_, err := memberCache.bpfLib.NewFailsafeMap()
 					Expect(err).NotTo(HaveOccurred())
 
-					err = state.ipV4State.bpfActions.apply(memberCache, s.ipsetIDsToMembers, newConvertingIPSetsSource(s.ipsetsSrc), state.common.xdpModes)
+					err = state.ipV4State.bpfActions.apply(memberCache, s.ipsetIDsToMembers, newConvertingIPSetsSource(s.ipsetsSrc), state.common.xdpModes, state.common.chainXDP)
 					Expect(err).NotTo(HaveOccurred())
 
 					actual := bpfDataplaneDump(st, bpf.IPFamilyV4)
@@ -2700,10 +2715,10 @@ var _ = Describe("XDP state", func() {
 
 			DescribeTable("",
 				func(s testStruct) {
-					state := NewXDPStateWithBPFLibrary(bpf.NewMockBPFLib("../../bpf-apache/bin"), true)
+					state := NewXDPStateWithBPFLibrary(bpf.NewMockBPFLib("../../bpf-apache/bin"), true, false)
 					state.ipV4State.newCurrentState = newXDPSystemState()
 					ipsetsSrc := &nilIPSetsSource{}
-					resyncState, err := state.ipV4State.newXDPResyncState(state.common.bpfLib, ipsetsSrc, state.common.programTag, state.common.xdpModes)
+					resyncState, err := state.ipV4State.newXDPResyncState(state.common.bpfLib, ipsetsSrc, state.common.programTag, state.common.xdpModes, state.common.chainXDP)
 					Expect(err).NotTo(HaveOccurred())
 					state.ipV4State.bpfActions.InstallXDP.AddAll(s.install)
 					state.ipV4State.bpfActions.UninstallXDP.AddAll(s.uninstall)
@@ -2787,5 +2802,55 @@ var _ = Describe("XDP state", func() {
 				}),
 			)
 		})
+
+		Describe("chaining", func() {
+			var lib *bpf.MockBPFLib
+
+			BeforeEach(func() {
+				lib = bpf.NewMockBPFLib("../../bpf-apache/bin")
+				_, err := lib.NewFailsafeMap()
+				Expect(err).NotTo(HaveOccurred())
+				lib.XDPProgs["eth0"] = bpf.XDPInfo{
+					Id:      1000,
+					Bytes:   []byte("other XDP program"),
+					Mode:    bpf.XDPDriver,
+					Foreign: true,
+				}
+			})
+
+			applyActions := func(state *xdpState, install, uninstall []string) error {
+				actions := state.ipV4State.bpfActions
+				actions.InstallXDP.AddAll(install)
+				actions.CreateMap.AddAll(install)
+				actions.UninstallXDP.AddAll(uninstall)
+				memberCache := newXDPMemberCache(bpf.IPFamilyV4, lib)
+				return actions.apply(memberCache, newIPSetIDsToMembers(), &nilIPSetsSource{},
+					state.common.xdpModes, state.common.chainXDP)
+			}
+
+			It("should chain the other program after ours and restore it when we're done", func() {
+				state := NewXDPStateWithBPFLibrary(lib, false, true)
+				Expect(applyActions(state, []string{"eth0"}, nil)).To(Succeed())
+				Expect(lib.XDPProgs["eth0"].Foreign).To(BeFalse())
+				Expect(lib.GetChainedXDPID("eth0")).To(Equal(1000))
+
+				state.ipV4State.bpfActions = newXDPBPFActions()
+				Expect(applyActions(state, nil, []string{"eth0"})).To(Succeed())
+				Expect(lib.XDPProgs["eth0"].Id).To(Equal(1000))
+				Expect(lib.ChainedXDPProgs).To(BeEmpty())
+			})
+
+			It("should leave the other program alone when wiping XDP", func() {
+				state := NewXDPStateWithBPFLibrary(lib, false, true)
+				Expect(state.WipeXDP()).To(Succeed())
+				Expect(lib.XDPProgs).To(HaveKey("eth0"))
+			})
+
+			It("should remove the other program when wiping XDP without chaining", func() {
+				state := NewXDPStateWithBPFLibrary(lib, false, false)
+				Expect(state.WipeXDP()).To(Succeed())
+				Expect(lib.XDPProgs).NotTo(HaveKey("eth0"))
+			})
+		})
 	})
 })
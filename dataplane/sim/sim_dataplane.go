@@ -0,0 +1,412 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sim implements a simulated dataplane driver.  Instead of programming the kernel, it
+// keeps an in-memory model of the IP sets, policy chains, endpoints and routes that Felix asks
+// for, and writes that model to a JSON file.  The model includes the kernel's view: the iptables
+// policy chains, rendered by the same rule renderer as the Linux dataplane, and the IP sets
+// under their kernel names.  That lets Felix run, unprivileged, against a test
+// datastore in a CI pipeline, which can then assert that a policy change has the intended effect.
+//
+// The model stops at the policy and profile chains.  It doesn't render the static chains
+// (conntrack, failsafes, host endpoint and NAT handling) or the per-endpoint dispatch chains, so
+// it can't say which packets reach a policy chain; it checks what the policies match, not the
+// end-to-end verdict for a packet.
+package sim
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/iptables"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+	"github.com/projectcalico/libcalico-go/lib/set"
+)
+
+const writeInterval = 1 * time.Second
+
+// State is the simulated dataplane state, as written to the state file.
+type State struct {
+	// InSync is true once Felix has sent the complete datastore snapshot.
+	InSync bool `json:"inSync"`
+	// IPSets maps IP set ID to its sorted members.
+	IPSets map[string][]string `json:"ipSets"`
+	// Chains maps "policy/<tier>/<name>" and "profile/<name>" to the chains' rules.
+	Chains map[string]Chains `json:"chains"`
+	// Endpoints maps "workload/<orchestrator>/<workload>/<endpoint>" and "host/<endpoint>" to
+	// the endpoints' policy and profile order.
+	Endpoints map[string]Endpoint `json:"endpoints"`
+	// Routes maps destination CIDR to route.
+	Routes map[string]Route `json:"routes"`
+
+	// IPv4 and IPv6 hold the kernel model for each IP version.
+	IPv4 Kernel `json:"ipv4"`
+	IPv6 Kernel `json:"ipv6"`
+}
+
+// Kernel models what the Linux dataplane would program into the kernel for one IP version.
+type Kernel struct {
+	// IPSets maps kernel IP set name to its sorted members.
+	IPSets map[string][]string `json:"ipSets"`
+	// FilterChains maps the name of a policy or profile chain in the filter table to its
+	// rules, in iptables-save format.  Other chains aren't modelled.
+	FilterChains map[string][]string `json:"filterChains"`
+}
+
+type Chains struct {
+	Inbound  []*proto.Rule `json:"inbound"`
+	Outbound []*proto.Rule `json:"outbound"`
+}
+
+type Endpoint struct {
+	Interface      string            `json:"interface"`
+	Addresses      []string          `json:"addresses,omitempty"`
+	Tiers          []*proto.TierInfo `json:"tiers,omitempty"`
+	UntrackedTiers []*proto.TierInfo `json:"untrackedTiers,omitempty"`
+	PreDNATTiers   []*proto.TierInfo `json:"preDNATTiers,omitempty"`
+	ForwardTiers   []*proto.TierInfo `json:"forwardTiers,omitempty"`
+	Profiles       []string          `json:"profiles,omitempty"`
+}
+
+type Route struct {
+	Type          string `json:"type"`
+	DstNodeName   string `json:"dstNodeName,omitempty"`
+	DstNodeIP     string `json:"dstNodeIP,omitempty"`
+	LocalWorkload bool   `json:"localWorkload,omitempty"`
+	NATOutgoing   bool   `json:"natOutgoing,omitempty"`
+}
+
+// Dataplane is a DataplaneDriver that simulates the dataplane in memory.
+type Dataplane struct {
+	stateFile string
+
+	lock         sync.Mutex
+	ipSets       map[string]set.Set
+	state        State
+	dirty        bool
+	ruleRenderer rules.RuleRenderer
+
+	toFelix chan interface{}
+}
+
+// ruleConfig is the rule renderer config used to render the modelled iptables chains.  The mark
+// bits only need to be distinct; they don't change which packets a chain accepts.
+var ruleConfig = rules.Config{
+	IPSetConfigV4:        ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, rules.IPSetNamePrefix, nil, nil),
+	IPSetConfigV6:        ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, rules.IPSetNamePrefix, nil, nil),
+	IptablesMarkAccept:   0x10000,
+	IptablesMarkPass:     0x20000,
+	IptablesMarkScratch0: 0x40000,
+	IptablesMarkScratch1: 0x80000,
+	IptablesMarkEndpoint: 0xfff00000,
+	IptablesLogPrefix:    "calico-packet",
+}
+
+func newKernel() Kernel {
+	return Kernel{
+		IPSets:       map[string][]string{},
+		FilterChains: map[string][]string{},
+	}
+}
+
+// New creates a simulated dataplane that writes its state to stateFile (if non-empty).
+func New(stateFile string) *Dataplane {
+	d := &Dataplane{
+		stateFile: stateFile,
+		ipSets:    map[string]set.Set{},
+		state: State{
+			IPSets:    map[string][]string{},
+			Chains:    map[string]Chains{},
+			Endpoints: map[string]Endpoint{},
+			Routes:    map[string]Route{},
+			IPv4:      newKernel(),
+			IPv6:      newKernel(),
+		},
+		ruleRenderer: rules.NewRenderer(ruleConfig),
+		toFelix:      make(chan interface{}, 100),
+	}
+	d.toFelix <- &proto.DataplaneCapabilities{
+		Ipv6:                   true,
+		Vxlan:                  true,
+		Wireguard:              true,
+		ApplicationLayerPolicy: true,
+	}
+	return d
+}
+
+// Start starts the background goroutine that writes the state file.
+func (d *Dataplane) Start() {
+	if d.stateFile == "" {
+		return
+	}
+	go d.loopWritingStateFile()
+}
+
+func (d *Dataplane) SendMessage(msg interface{}) error {
+	d.lock.Lock()
+	replies := d.onUpdateLocked(msg)
+	d.lock.Unlock()
+
+	// Send the replies after releasing the lock; the channel may be full and Felix may be
+	// blocked trying to send us another message.
+	for _, reply := range replies {
+		d.toFelix <- reply
+	}
+	return nil
+}
+
+// onUpdateLocked applies msg to the model and returns the messages to send back to Felix.
+func (d *Dataplane) onUpdateLocked(msg interface{}) (replies []interface{}) {
+	d.dirty = true
+	switch msg := msg.(type) {
+	case *proto.InSync:
+		d.state.InSync = true
+	case *proto.IPSetUpdate:
+		d.ipSets[msg.Id] = set.FromArray(msg.Members)
+		d.updateIPSet(msg.Id)
+	case *proto.IPSetDeltaUpdate:
+		s, ok := d.ipSets[msg.Id]
+		if !ok {
+			log.WithField("id", msg.Id).Warn("Delta update for unknown IP set")
+			s = set.New()
+			d.ipSets[msg.Id] = s
+		}
+		for _, m := range msg.AddedMembers {
+			s.Add(m)
+		}
+		for _, m := range msg.RemovedMembers {
+			s.Discard(m)
+		}
+		d.updateIPSet(msg.Id)
+	case *proto.IPSetRemove:
+		delete(d.ipSets, msg.Id)
+		delete(d.state.IPSets, msg.Id)
+		delete(d.state.IPv4.IPSets, ruleConfig.IPSetConfigV4.NameForMainIPSet(msg.Id))
+		delete(d.state.IPv6.IPSets, ruleConfig.IPSetConfigV6.NameForMainIPSet(msg.Id))
+	case *proto.ActivePolicyUpdate:
+		d.state.Chains[policyKey(msg.Id)] = Chains{
+			Inbound:  msg.Policy.InboundRules,
+			Outbound: msg.Policy.OutboundRules,
+		}
+		d.updateFilterChains(4, d.ruleRenderer.PolicyToIptablesChains(msg.Id, msg.Policy, 4))
+		d.updateFilterChains(6, d.ruleRenderer.PolicyToIptablesChains(msg.Id, msg.Policy, 6))
+	case *proto.ActivePolicyRemove:
+		delete(d.state.Chains, policyKey(msg.Id))
+		d.removeFilterChains(
+			rules.PolicyChainName(rules.PolicyInboundPfx, msg.Id),
+			rules.PolicyChainName(rules.PolicyOutboundPfx, msg.Id),
+		)
+	case *proto.ActiveProfileUpdate:
+		d.state.Chains[profileKey(msg.Id)] = Chains{
+			Inbound:  msg.Profile.InboundRules,
+			Outbound: msg.Profile.OutboundRules,
+		}
+		for _, ipVersion := range []uint8{4, 6} {
+			inbound, outbound := d.ruleRenderer.ProfileToIptablesChains(msg.Id, msg.Profile, ipVersion)
+			d.updateFilterChains(ipVersion, []*iptables.Chain{inbound, outbound})
+		}
+	case *proto.ActiveProfileRemove:
+		delete(d.state.Chains, profileKey(msg.Id))
+		d.removeFilterChains(
+			rules.ProfileChainName(rules.ProfileInboundPfx, msg.Id),
+			rules.ProfileChainName(rules.ProfileOutboundPfx, msg.Id),
+		)
+	case *proto.WorkloadEndpointUpdate:
+		ep := msg.Endpoint
+		d.state.Endpoints[workloadKey(msg.Id)] = Endpoint{
+			Interface: ep.Name,
+			Addresses: append(append([]string(nil), ep.Ipv4Nets...), ep.Ipv6Nets...),
+			Tiers:     ep.Tiers,
+			Profiles:  ep.ProfileIds,
+		}
+		// There's nothing to program, so the endpoint is up straight away.
+		replies = append(replies, &proto.WorkloadEndpointStatusUpdate{
+			Id:     msg.Id,
+			Status: &proto.EndpointStatus{Status: "up"},
+		})
+	case *proto.WorkloadEndpointRemove:
+		delete(d.state.Endpoints, workloadKey(msg.Id))
+		replies = append(replies, &proto.WorkloadEndpointStatusRemove{Id: msg.Id})
+	case *proto.HostEndpointUpdate:
+		ep := msg.Endpoint
+		d.state.Endpoints[hostKey(msg.Id)] = Endpoint{
+			Interface:      ep.Name,
+			Addresses:      append(append([]string(nil), ep.ExpectedIpv4Addrs...), ep.ExpectedIpv6Addrs...),
+			Tiers:          ep.Tiers,
+			UntrackedTiers: ep.UntrackedTiers,
+			PreDNATTiers:   ep.PreDnatTiers,
+			ForwardTiers:   ep.ForwardTiers,
+			Profiles:       ep.ProfileIds,
+		}
+		replies = append(replies, &proto.HostEndpointStatusUpdate{
+			Id:     msg.Id,
+			Status: &proto.EndpointStatus{Status: "up"},
+		})
+	case *proto.HostEndpointRemove:
+		delete(d.state.Endpoints, hostKey(msg.Id))
+		replies = append(replies, &proto.HostEndpointStatusRemove{Id: msg.Id})
+	case *proto.RouteUpdate:
+		d.state.Routes[msg.Dst] = Route{
+			Type:          msg.Type.String(),
+			DstNodeName:   msg.DstNodeName,
+			DstNodeIP:     msg.DstNodeIp,
+			LocalWorkload: msg.LocalWorkload,
+			NATOutgoing:   msg.NatOutgoing,
+		}
+	case *proto.RouteRemove:
+		delete(d.state.Routes, msg.Dst)
+	default:
+		// Config updates, host metadata and so on don't change the modelled state.
+		log.WithField("msg", msg).Debug("Ignoring message in simulated dataplane")
+	}
+	return
+}
+
+func (d *Dataplane) RecvMessage() (interface{}, error) {
+	return <-d.toFelix, nil
+}
+
+func (d *Dataplane) updateIPSet(id string) {
+	var members []string
+	d.ipSets[id].Iter(func(item interface{}) error {
+		members = append(members, item.(string))
+		return nil
+	})
+	sort.Strings(members)
+	d.state.IPSets[id] = members
+
+	// The Linux dataplane splits each IP set into an IPv4 and an IPv6 kernel IP set.
+	var v4Members, v6Members []string
+	for _, m := range members {
+		if strings.Contains(strings.SplitN(m, ",", 2)[0], ":") {
+			v6Members = append(v6Members, m)
+		} else {
+			v4Members = append(v4Members, m)
+		}
+	}
+	d.state.IPv4.IPSets[ruleConfig.IPSetConfigV4.NameForMainIPSet(id)] = v4Members
+	d.state.IPv6.IPSets[ruleConfig.IPSetConfigV6.NameForMainIPSet(id)] = v6Members
+}
+
+func (d *Dataplane) kernel(ipVersion uint8) *Kernel {
+	if ipVersion == 6 {
+		return &d.state.IPv6
+	}
+	return &d.state.IPv4
+}
+
+func (d *Dataplane) updateFilterChains(ipVersion uint8, chains []*iptables.Chain) {
+	k := d.kernel(ipVersion)
+	features := &iptables.Features{}
+	for _, chain := range chains {
+		rendered := []string{}
+		for _, r := range chain.Rules {
+			rendered = append(rendered, r.RenderAppend(chain.Name, "", features))
+		}
+		log.WithFields(log.Fields{
+			"ipVersion": ipVersion,
+			"chain":     chain.Name,
+		}).Debug("Updating simulated iptables chain")
+		k.FilterChains[chain.Name] = rendered
+	}
+}
+
+func (d *Dataplane) removeFilterChains(names ...string) {
+	for _, name := range names {
+		delete(d.state.IPv4.FilterChains, name)
+		delete(d.state.IPv6.FilterChains, name)
+	}
+}
+
+func policyKey(id *proto.PolicyID) string {
+	return "policy/" + id.Tier + "/" + id.Name
+}
+
+func profileKey(id *proto.ProfileID) string {
+	return "profile/" + id.Name
+}
+
+func workloadKey(id *proto.WorkloadEndpointID) string {
+	return "workload/" + id.OrchestratorId + "/" + id.WorkloadId + "/" + id.EndpointId
+}
+
+func hostKey(id *proto.HostEndpointID) string {
+	return "host/" + id.EndpointId
+}
+
+// DumpState writes the current state to w as JSON.  It has the signature of a
+// debugserver.StateDumper.
+func (d *Dataplane) DumpState(w io.Writer) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.writeStateLocked(w)
+}
+
+func (d *Dataplane) writeStateLocked(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d.state)
+}
+
+// loopWritingStateFile writes the state file, at most once per writeInterval, whenever the state
+// has changed since Felix was last in sync.
+func (d *Dataplane) loopWritingStateFile() {
+	ticker := time.NewTicker(writeInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := d.maybeWriteStateFile(); err != nil {
+			log.WithError(err).WithField("file", d.stateFile).Warn(
+				"Failed to write simulated dataplane state, will retry")
+		}
+	}
+}
+
+func (d *Dataplane) maybeWriteStateFile() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if !d.dirty || !d.state.InSync {
+		return nil
+	}
+
+	// Write to a temporary file and rename it into place so that readers never see a partial
+	// file.
+	f, err := ioutil.TempFile(filepath.Dir(d.stateFile), filepath.Base(d.stateFile)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := d.writeStateLocked(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), d.stateFile); err != nil {
+		return err
+	}
+	d.dirty = false
+	return nil
+}
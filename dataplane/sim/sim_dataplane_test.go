@@ -0,0 +1,187 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+)
+
+var _ = Describe("Simulated dataplane", func() {
+	var (
+		dir string
+		dp  *Dataplane
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "sim-dataplane")
+		Expect(err).NotTo(HaveOccurred())
+		dp = New(filepath.Join(dir, "state.json"))
+
+		msg, err := dp.RecvMessage()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(msg).To(BeAssignableToTypeOf(&proto.DataplaneCapabilities{}))
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(dir)
+	})
+
+	readState := func() State {
+		data, err := ioutil.ReadFile(filepath.Join(dir, "state.json"))
+		Expect(err).NotTo(HaveOccurred())
+		var state State
+		Expect(json.Unmarshal(data, &state)).To(Succeed())
+		return state
+	}
+
+	It("should model IP sets", func() {
+		Expect(dp.SendMessage(&proto.IPSetUpdate{Id: "s1", Members: []string{"10.0.0.2", "10.0.0.1"}})).To(Succeed())
+		Expect(dp.SendMessage(&proto.IPSetDeltaUpdate{
+			Id:             "s1",
+			AddedMembers:   []string{"10.0.0.3"},
+			RemovedMembers: []string{"10.0.0.1"},
+		})).To(Succeed())
+		Expect(dp.SendMessage(&proto.IPSetUpdate{Id: "s2", Members: []string{"10.0.1.1"}})).To(Succeed())
+		Expect(dp.SendMessage(&proto.IPSetRemove{Id: "s2"})).To(Succeed())
+		Expect(dp.SendMessage(&proto.InSync{})).To(Succeed())
+
+		Expect(dp.maybeWriteStateFile()).To(Succeed())
+		Expect(readState().IPSets).To(Equal(map[string][]string{
+			"s1": {"10.0.0.2", "10.0.0.3"},
+		}))
+	})
+
+	It("should model policies, endpoints and routes", func() {
+		Expect(dp.SendMessage(&proto.ActivePolicyUpdate{
+			Id: &proto.PolicyID{Tier: "default", Name: "allow-web"},
+			Policy: &proto.Policy{
+				InboundRules: []*proto.Rule{{Action: "allow"}},
+			},
+		})).To(Succeed())
+		id := &proto.WorkloadEndpointID{OrchestratorId: "k8s", WorkloadId: "ns1/pod1", EndpointId: "eth0"}
+		Expect(dp.SendMessage(&proto.WorkloadEndpointUpdate{
+			Id: id,
+			Endpoint: &proto.WorkloadEndpoint{
+				Name:     "cali1234",
+				Ipv4Nets: []string{"10.0.0.1/32"},
+				Tiers: []*proto.TierInfo{
+					{Name: "default", IngressPolicies: []string{"allow-web"}},
+				},
+			},
+		})).To(Succeed())
+		Expect(dp.SendMessage(&proto.RouteUpdate{
+			Type:        proto.RouteType_REMOTE_WORKLOAD,
+			Dst:         "10.0.1.0/26",
+			DstNodeName: "node2",
+		})).To(Succeed())
+
+		msg, err := dp.RecvMessage()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(msg).To(Equal(&proto.WorkloadEndpointStatusUpdate{
+			Id:     id,
+			Status: &proto.EndpointStatus{Status: "up"},
+		}))
+
+		// The state file is only written once we're in sync.
+		Expect(dp.maybeWriteStateFile()).To(Succeed())
+		_, err = os.Stat(filepath.Join(dir, "state.json"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+
+		Expect(dp.SendMessage(&proto.InSync{})).To(Succeed())
+		Expect(dp.maybeWriteStateFile()).To(Succeed())
+		state := readState()
+		Expect(state.InSync).To(BeTrue())
+		Expect(state.Chains).To(HaveKey("policy/default/allow-web"))
+		Expect(state.Chains["policy/default/allow-web"].Inbound).To(HaveLen(1))
+		Expect(state.Endpoints).To(HaveKeyWithValue("workload/k8s/ns1/pod1/eth0", Endpoint{
+			Interface: "cali1234",
+			Addresses: []string{"10.0.0.1/32"},
+			Tiers: []*proto.TierInfo{
+				{Name: "default", IngressPolicies: []string{"allow-web"}},
+			},
+		}))
+		Expect(state.Routes).To(Equal(map[string]Route{
+			"10.0.1.0/26": {Type: "REMOTE_WORKLOAD", DstNodeName: "node2"},
+		}))
+	})
+
+	It("should model the kernel IP sets and iptables chains", func() {
+		Expect(dp.SendMessage(&proto.IPSetUpdate{Id: "s1", Members: []string{"10.0.0.1", "dead::1"}})).To(Succeed())
+		polID := &proto.PolicyID{Tier: "default", Name: "allow-s1"}
+		Expect(dp.SendMessage(&proto.ActivePolicyUpdate{
+			Id: polID,
+			Policy: &proto.Policy{
+				InboundRules: []*proto.Rule{{Action: "allow", SrcIpSetIds: []string{"s1"}}},
+			},
+		})).To(Succeed())
+		Expect(dp.SendMessage(&proto.InSync{})).To(Succeed())
+
+		Expect(dp.maybeWriteStateFile()).To(Succeed())
+		state := readState()
+		v4Name := ruleConfig.IPSetConfigV4.NameForMainIPSet("s1")
+		v6Name := ruleConfig.IPSetConfigV6.NameForMainIPSet("s1")
+		Expect(state.IPv4.IPSets).To(Equal(map[string][]string{v4Name: {"10.0.0.1"}}))
+		Expect(state.IPv6.IPSets).To(Equal(map[string][]string{v6Name: {"dead::1"}}))
+		inboundChain := rules.PolicyChainName(rules.PolicyInboundPfx, polID)
+		Expect(state.IPv4.FilterChains[inboundChain]).To(ContainElement(ContainSubstring("--match-set " + v4Name + " src")))
+		Expect(state.IPv6.FilterChains[inboundChain]).To(ContainElement(ContainSubstring("--match-set " + v6Name + " src")))
+
+		Expect(dp.SendMessage(&proto.ActivePolicyRemove{Id: polID})).To(Succeed())
+		Expect(dp.SendMessage(&proto.IPSetRemove{Id: "s1"})).To(Succeed())
+		Expect(dp.maybeWriteStateFile()).To(Succeed())
+		state = readState()
+		Expect(state.IPv4.FilterChains).To(BeEmpty())
+		Expect(state.IPv4.IPSets).To(BeEmpty())
+		Expect(state.IPv6.IPSets).To(BeEmpty())
+	})
+
+	It("should not hold its lock while waiting for Felix to read its replies", func() {
+		const numUpdates = 200
+		sent := make(chan struct{})
+		go func() {
+			defer close(sent)
+			for i := 0; i < numUpdates; i++ {
+				_ = dp.SendMessage(&proto.WorkloadEndpointUpdate{
+					Id:       &proto.WorkloadEndpointID{OrchestratorId: "k8s", WorkloadId: fmt.Sprint(i), EndpointId: "eth0"},
+					Endpoint: &proto.WorkloadEndpoint{},
+				})
+			}
+		}()
+		Eventually(func() int { return len(dp.toFelix) }).Should(Equal(cap(dp.toFelix)))
+
+		dumped := make(chan error, 1)
+		go func() {
+			dumped <- dp.DumpState(ioutil.Discard)
+		}()
+		Eventually(dumped).Should(Receive(BeNil()))
+
+		for i := 0; i < numUpdates; i++ {
+			_, err := dp.RecvMessage()
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Eventually(sent).Should(BeClosed())
+	})
+})
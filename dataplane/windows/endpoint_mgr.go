@@ -16,6 +16,7 @@ package windataplane
 
 import (
 	"errors"
+	"fmt"
 	"net"
 	"os"
 	"reflect"
@@ -75,6 +76,15 @@ type endpointManager struct {
 	pendingHostAddrs []string
 	// hostAddrs contains the list of IPs detected on the host.
 	hostAddrs []string
+
+	// policySetRulesCache caches the rules calculated for each list of policy set IDs during a
+	// single CompleteDeferredWork() pass so that endpoints with the same effective policy share
+	// the same ACL policies rather than recalculating (and recompacting) them per endpoint.
+	policySetRulesCache map[string][]*hns.ACLPolicy
+
+	// vfpMetrics, if non-nil, is told about the rules applied to each endpoint so that it can
+	// export their counters.
+	vfpMetrics *vfpMetricsCollector
 }
 
 type hnsInterface interface {
@@ -318,6 +328,13 @@ func (m *endpointManager) CompleteDeferredWork() error {
 		_ = m.RefreshHnsEndpointCache(true)
 	}
 
+	// Policy sets can't change during this pass so the rules calculated for one endpoint can be
+	// reused for any other endpoint with the same policies.
+	m.policySetRulesCache = map[string][]*hns.ACLPolicy{}
+	defer func() {
+		m.policySetRulesCache = nil
+	}()
+
 	// Loop through each pending update
 	var missingEndpoints bool
 	for id, workload := range m.pendingWlEpUpdates {
@@ -376,6 +393,9 @@ func (m *endpointManager) CompleteDeferredWork() error {
 			// For now, we don't need to do anything. As the endpoint is being removed, HNS will automatically
 			// handle the removal of any associated policies from the dataplane for us
 			logCxt.Info("Processing endpoint removal")
+			if m.vfpMetrics != nil {
+				m.vfpMetrics.OnEndpointRemoved(id)
+			}
 			delete(m.activeWlEndpoints, id)
 			delete(m.pendingWlEpUpdates, id)
 		}
@@ -443,8 +463,8 @@ func (m *endpointManager) applyRules(workloadId proto.WorkloadEndpointID, endpoi
 		log.WithField("hostAddrs", m.hostAddrs).Debug("Adding node->endpoint allow rule")
 		rules = append(rules, nodeToEp)
 	}
-	rules = append(rules, m.policysetsDataplane.GetPolicySetRules(inboundPolicyIds, true)...)
-	rules = append(rules, m.policysetsDataplane.GetPolicySetRules(outboundPolicyIds, false)...)
+	rules = append(rules, m.getPolicySetRules(inboundPolicyIds, true)...)
+	rules = append(rules, m.getPolicySetRules(outboundPolicyIds, false)...)
 
 	if len(rules) > 0 {
 		if log.GetLevel() >= log.DebugLevel {
@@ -465,10 +485,29 @@ func (m *endpointManager) applyRules(workloadId proto.WorkloadEndpointID, endpoi
 		logCxt.WithError(err).Warning("Failed to apply rules. This operation will be retried.")
 		return ErrorUpdateFailed
 	}
+	if m.vfpMetrics != nil {
+		m.vfpMetrics.OnEndpointRulesApplied(workloadId, endpointId, rules)
+	}
 
 	return nil
 }
 
+// getPolicySetRules returns the rules for the given policy sets, using the rules that were
+// already calculated for another endpoint during this pass if possible.
+func (m *endpointManager) getPolicySetRules(setIds []string, isInbound bool) []*hns.ACLPolicy {
+	if m.policySetRulesCache == nil {
+		return m.policysetsDataplane.GetPolicySetRules(setIds, isInbound)
+	}
+	key := fmt.Sprintf("%v/%s", isInbound, strings.Join(setIds, ","))
+	if rules, ok := m.policySetRulesCache[key]; ok {
+		log.WithField("setIds", setIds).Debug("Reusing rules calculated for another endpoint")
+		return rules
+	}
+	rules := m.policysetsDataplane.GetPolicySetRules(setIds, isInbound)
+	m.policySetRulesCache[key] = rules
+	return rules
+}
+
 // nodeToEndpointRule creates a HNS rule that allows traffic from the node IP to the endpoint.
 func (m *endpointManager) nodeToEndpointRule() *hns.ACLPolicy {
 	if len(m.hostAddrs) == 0 {
@@ -0,0 +1,262 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package windataplane
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/dataplane/windows/hns"
+	"github.com/projectcalico/felix/dataplane/windows/policysets"
+	"github.com/projectcalico/felix/proto"
+)
+
+// hostEndpointManager applies host endpoint policy to the host's own HNS endpoint (the host vNIC
+// that is attached to the Calico HNS network).  Host network traffic, including traffic to node
+// ports and from HostProcess containers, which share the host's network namespace, passes through
+// that endpoint's vSwitch port so HNS ACLs on it protect the host in the same way that ACLs on a
+// workload's endpoint protect the workload.
+//
+// A host endpoint is matched to HNS endpoints by name (unless it is the "*" all-interfaces
+// endpoint) or by one of its expected IPs.  HNS endpoints with attached containers belong to
+// workloads and are never matched.
+type hostEndpointManager struct {
+	hns                 hnsInterface
+	hnsNetworkRegexp    *regexp.Regexp
+	policysetsDataplane policysets.PolicySetsDataplane
+
+	failsafeInboundHostPorts  []config.ProtoPort
+	failsafeOutboundHostPorts []config.ProtoPort
+
+	// hostEndpoints contains the current host endpoints for this host.
+	hostEndpoints map[proto.HostEndpointID]*proto.HostEndpoint
+	// activeRules maps from HNS endpoint ID to the rules that we last applied to that endpoint.
+	activeRules map[string][]*hns.ACLPolicy
+	// dirty is set when a host endpoint or any policy, profile or IP set changes.  Since there
+	// are only a handful of host endpoints, we recalculate them all and only send the ones that
+	// have actually changed to HNS.
+	dirty bool
+
+	// Shim for testing.
+	applyACLPolicy func(endpointID string, rules ...*hns.ACLPolicy) error
+}
+
+func newHostEndpointManager(
+	hnsAPI hnsInterface,
+	hnsNetworkRegexp *regexp.Regexp,
+	policysets policysets.PolicySetsDataplane,
+	failsafeInboundHostPorts []config.ProtoPort,
+	failsafeOutboundHostPorts []config.ProtoPort,
+) *hostEndpointManager {
+	return &hostEndpointManager{
+		hns:                       hnsAPI,
+		hnsNetworkRegexp:          hnsNetworkRegexp,
+		policysetsDataplane:       policysets,
+		failsafeInboundHostPorts:  failsafeInboundHostPorts,
+		failsafeOutboundHostPorts: failsafeOutboundHostPorts,
+		hostEndpoints:             map[proto.HostEndpointID]*proto.HostEndpoint{},
+		activeRules:               map[string][]*hns.ACLPolicy{},
+		applyACLPolicy:            applyACLPolicy,
+	}
+}
+
+func applyACLPolicy(endpointID string, rules ...*hns.ACLPolicy) error {
+	endpoint := &hns.HNSEndpoint{}
+	endpoint.Id = endpointID
+	return endpoint.ApplyACLPolicy(rules...)
+}
+
+func (m *hostEndpointManager) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *proto.HostEndpointUpdate:
+		log.WithField("hostEndpointId", msg.Id).Info("Processing HostEndpointUpdate")
+		if len(msg.Endpoint.UntrackedTiers) > 0 || len(msg.Endpoint.PreDnatTiers) > 0 ||
+			len(msg.Endpoint.ForwardTiers) > 0 {
+			log.WithField("hostEndpointId", msg.Id).Warn(
+				"Untracked, pre-DNAT and apply-on-forward policies are not supported on Windows; ignoring them.")
+		}
+		m.hostEndpoints[*msg.Id] = msg.Endpoint
+		m.dirty = true
+	case *proto.HostEndpointRemove:
+		log.WithField("hostEndpointId", msg.Id).Info("Processing HostEndpointRemove")
+		delete(m.hostEndpoints, *msg.Id)
+		m.dirty = true
+	case *proto.ActivePolicyUpdate, *proto.ActivePolicyRemove,
+		*proto.ActiveProfileUpdate, *proto.ActiveProfileRemove,
+		*proto.IPSetUpdate, *proto.IPSetDeltaUpdate, *proto.IPSetRemove:
+		if len(m.hostEndpoints) > 0 || len(m.activeRules) > 0 {
+			m.dirty = true
+		}
+	}
+}
+
+func (m *hostEndpointManager) CompleteDeferredWork() error {
+	if !m.dirty {
+		return nil
+	}
+
+	endpoints, err := m.hns.HNSListEndpointRequest()
+	if err != nil {
+		log.WithError(err).Warn("Failed to list HNS endpoints; will retry.")
+		return err
+	}
+
+	// Iterate over the host endpoints in a deterministic order so that, if more than one host
+	// endpoint matches the same HNS endpoint, we consistently pick the same one.
+	var hepIDs []proto.HostEndpointID
+	for id := range m.hostEndpoints {
+		hepIDs = append(hepIDs, id)
+	}
+	sort.Slice(hepIDs, func(i, j int) bool {
+		return hepIDs[i].EndpointId < hepIDs[j].EndpointId
+	})
+
+	desiredRules := map[string][]*hns.ACLPolicy{}
+	existingEndpoints := map[string]bool{}
+	for i := range endpoints {
+		endpoint := &endpoints[i]
+		if endpoint.IsRemoteEndpoint || !m.hnsNetworkRegexp.MatchString(endpoint.VirtualNetworkName) {
+			continue
+		}
+		existingEndpoints[endpoint.Id] = true
+		for _, hepID := range hepIDs {
+			hep := m.hostEndpoints[hepID]
+			if !hostEndpointMatches(hep, endpoint) {
+				continue
+			}
+			containers, err := m.hns.GetAttachedContainerIDs(endpoint)
+			if err != nil || len(containers) > 0 {
+				log.WithFields(log.Fields{
+					"hostEndpointId": hepID,
+					"hnsEndpointId":  endpoint.Id,
+				}).Debug("Skipping HNS endpoint that may belong to a workload")
+				break
+			}
+			log.WithFields(log.Fields{
+				"hostEndpointId": hepID,
+				"hnsEndpointId":  endpoint.Id,
+			}).Debug("Host endpoint matches HNS endpoint")
+			desiredRules[endpoint.Id] = m.rulesForHostEndpoint(hep)
+			break
+		}
+	}
+
+	var lastErr error
+	for id, rules := range desiredRules {
+		if reflect.DeepEqual(m.activeRules[id], rules) {
+			continue
+		}
+		log.WithFields(log.Fields{"hnsEndpointId": id, "numRules": len(rules)}).Info(
+			"Applying host endpoint rules")
+		if err := m.applyACLPolicy(id, rules...); err != nil {
+			log.WithError(err).WithField("hnsEndpointId", id).Warn(
+				"Failed to apply host endpoint rules. This operation will be retried.")
+			lastErr = ErrorUpdateFailed
+			continue
+		}
+		m.activeRules[id] = rules
+	}
+	for id := range m.activeRules {
+		if _, ok := desiredRules[id]; ok {
+			continue
+		}
+		if !existingEndpoints[id] {
+			log.WithField("hnsEndpointId", id).Info("HNS endpoint for host endpoint has gone away")
+			delete(m.activeRules, id)
+			continue
+		}
+		log.WithField("hnsEndpointId", id).Info("Removing host endpoint rules")
+		if err := m.applyACLPolicy(id); err != nil {
+			log.WithError(err).WithField("hnsEndpointId", id).Warn(
+				"Failed to remove host endpoint rules. This operation will be retried.")
+			lastErr = ErrorUpdateFailed
+			continue
+		}
+		delete(m.activeRules, id)
+	}
+
+	if lastErr != nil {
+		return lastErr
+	}
+	m.dirty = false
+	return nil
+}
+
+// rulesForHostEndpoint calculates the complete list of rules for a host endpoint: the failsafe
+// rules, followed by the rules for its normal policies (or its profiles, if it has no policies).
+func (m *hostEndpointManager) rulesForHostEndpoint(hep *proto.HostEndpoint) []*hns.ACLPolicy {
+	var inboundPolicyIds, outboundPolicyIds []string
+	if len(hep.Tiers) > 0 && len(hep.Tiers[0].IngressPolicies) > 0 {
+		inboundPolicyIds = prependAll(policysets.PolicyNamePrefix, hep.Tiers[0].IngressPolicies)
+	} else {
+		inboundPolicyIds = prependAll(policysets.ProfileNamePrefix, hep.ProfileIds)
+	}
+	if len(hep.Tiers) > 0 && len(hep.Tiers[0].EgressPolicies) > 0 {
+		outboundPolicyIds = prependAll(policysets.PolicyNamePrefix, hep.Tiers[0].EgressPolicies)
+	} else {
+		outboundPolicyIds = prependAll(policysets.ProfileNamePrefix, hep.ProfileIds)
+	}
+
+	var rules []*hns.ACLPolicy
+	for _, p := range m.failsafeInboundHostPorts {
+		if !failsafeAppliesToHostEndpoint(p, hep) {
+			continue
+		}
+		rules = append(rules, m.policysetsDataplane.NewFailsafeRule(true, p.Protocol, p.Port, p.Net))
+	}
+	for _, p := range m.failsafeOutboundHostPorts {
+		if !failsafeAppliesToHostEndpoint(p, hep) {
+			continue
+		}
+		rules = append(rules, m.policysetsDataplane.NewFailsafeRule(false, p.Protocol, p.Port, p.Net))
+	}
+	rules = append(rules, m.policysetsDataplane.GetPolicySetRules(inboundPolicyIds, true)...)
+	rules = append(rules, m.policysetsDataplane.GetPolicySetRules(outboundPolicyIds, false)...)
+	return rules
+}
+
+// failsafeAppliesToHostEndpoint returns false if the failsafe port is limited to an interface
+// that isn't the host endpoint's.  A trailing "+" in the interface matches any suffix.  Host
+// endpoints without a specific interface name get all failsafe ports.
+func failsafeAppliesToHostEndpoint(p config.ProtoPort, hep *proto.HostEndpoint) bool {
+	if p.Interface == "" || hep.Name == "" || hep.Name == "*" {
+		return true
+	}
+	if strings.HasSuffix(p.Interface, "+") {
+		return strings.HasPrefix(hep.Name, strings.TrimSuffix(p.Interface, "+"))
+	}
+	return hep.Name == p.Interface
+}
+
+func hostEndpointMatches(hep *proto.HostEndpoint, endpoint *hns.HNSEndpoint) bool {
+	if hep.Name != "" && hep.Name != "*" && hep.Name == endpoint.Name {
+		return true
+	}
+	if endpoint.IPAddress == nil {
+		return false
+	}
+	ip := endpoint.IPAddress.String()
+	for _, addr := range hep.ExpectedIpv4Addrs {
+		if addr == ip || addr == ip+ipv4AddrSuffix {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package windataplane
+
+import (
+	"errors"
+	"net"
+	"regexp"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/dataplane/windows/hns"
+	"github.com/projectcalico/felix/dataplane/windows/policysets"
+	"github.com/projectcalico/felix/proto"
+)
+
+type mockHNSEndpoints struct {
+	mockHNS
+	endpoints  []hns.HNSEndpoint
+	containers map[string][]string
+}
+
+func (h *mockHNSEndpoints) HNSListEndpointRequest() ([]hns.HNSEndpoint, error) {
+	return h.endpoints, nil
+}
+
+func (h *mockHNSEndpoints) GetAttachedContainerIDs(endpoint *hns.HNSEndpoint) ([]string, error) {
+	return h.containers[endpoint.Id], nil
+}
+
+var _ = Describe("Host endpoint manager tests", func() {
+	var (
+		mgr       *hostEndpointManager
+		h         *mockHNSEndpoints
+		ps        *policysets.PolicySets
+		applied   map[string][]*hns.ACLPolicy
+		failApply bool
+	)
+
+	failsafeRules := []*hns.ACLPolicy{
+		{Type: hns.ACL, Protocol: 6, Action: hns.Allow, Direction: hns.In, RuleType: hns.Switch, Priority: 100,
+			LocalPorts: "22"},
+		{Type: hns.ACL, Protocol: 17, Action: hns.Allow, Direction: hns.Out, RuleType: hns.Switch, Priority: 100,
+			RemotePorts: "53", RemoteAddresses: "10.96.0.10/32"},
+	}
+
+	BeforeEach(func() {
+		h = &mockHNSEndpoints{
+			endpoints: []hns.HNSEndpoint{
+				{Id: "host-ep", Name: "Calico_ep", VirtualNetworkName: "Calico", IPAddress: net.ParseIP("10.0.0.1")},
+				{Id: "pod-ep", Name: "pod", VirtualNetworkName: "Calico", IPAddress: net.ParseIP("10.0.0.2")},
+				{Id: "other-ep", Name: "other", VirtualNetworkName: "nat", IPAddress: net.ParseIP("10.0.0.3")},
+			},
+			containers: map[string][]string{"pod-ep": {"container"}},
+		}
+		ps = policysets.NewPolicySets(h, []policysets.IPSetCache{&mockIPSetCache{}}, mockReader(""))
+		mgr = newHostEndpointManager(h, regexp.MustCompile("Calico"), ps,
+			[]config.ProtoPort{{Protocol: "tcp", Port: 22}},
+			[]config.ProtoPort{{Protocol: "udp", Port: 53, Net: "10.96.0.10/32"}},
+		)
+		applied = map[string][]*hns.ACLPolicy{}
+		failApply = false
+		mgr.applyACLPolicy = func(id string, rules ...*hns.ACLPolicy) error {
+			if failApply {
+				return errors.New("failed")
+			}
+			applied[id] = rules
+			return nil
+		}
+
+		ps.AddOrReplacePolicySet("policy-pol1", &proto.Policy{
+			InboundRules: []*proto.Rule{{Action: "allow"}},
+		})
+	})
+
+	It("should do nothing with no host endpoints", func() {
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(applied).To(BeEmpty())
+	})
+
+	Describe("with a host endpoint matching by IP", func() {
+		BeforeEach(func() {
+			mgr.OnUpdate(&proto.HostEndpointUpdate{
+				Id: &proto.HostEndpointID{EndpointId: "hep1"},
+				Endpoint: &proto.HostEndpoint{
+					Name:              "*",
+					ExpectedIpv4Addrs: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+					Tiers: []*proto.TierInfo{{
+						Name:            "default",
+						IngressPolicies: []string{"pol1"},
+					}},
+				},
+			})
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		})
+
+		It("should only program the host's HNS endpoint", func() {
+			Expect(applied).To(HaveLen(1))
+			Expect(applied["host-ep"]).To(Equal(append(append([]*hns.ACLPolicy{}, failsafeRules...),
+				// Ingress policy.
+				&hns.ACLPolicy{Type: hns.ACL, Protocol: 256, Action: hns.Allow, Direction: hns.In,
+					RuleType: hns.Switch, Priority: 1000},
+				&hns.ACLPolicy{Type: hns.ACL, Protocol: 256, Action: hns.Block, Direction: hns.In,
+					RuleType: hns.Switch, Priority: 1001},
+				&hns.ACLPolicy{Type: hns.ACL, Protocol: 256, Action: hns.Allow, Direction: hns.In,
+					RuleType: hns.Host, Priority: 100},
+				// No egress policy, default deny.
+				&hns.ACLPolicy{Type: hns.ACL, Protocol: 256, Action: hns.Block, Direction: hns.Out,
+					RuleType: hns.Switch, Priority: 1001},
+				&hns.ACLPolicy{Type: hns.ACL, Protocol: 256, Action: hns.Allow, Direction: hns.Out,
+					RuleType: hns.Host, Priority: 100},
+			)))
+		})
+
+		It("should not reapply unchanged rules", func() {
+			delete(applied, "host-ep")
+			mgr.OnUpdate(&proto.IPSetUpdate{Id: "s:abcd"})
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(applied).To(BeEmpty())
+		})
+
+		It("should reapply rules when the policy changes", func() {
+			ps.AddOrReplacePolicySet("policy-pol1", &proto.Policy{
+				InboundRules: []*proto.Rule{{Action: "deny"}},
+			})
+			mgr.OnUpdate(&proto.ActivePolicyUpdate{Id: &proto.PolicyID{Name: "pol1"}})
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(applied["host-ep"][2].Action).To(Equal(hns.Block))
+		})
+
+		It("should remove the rules when the host endpoint is removed", func() {
+			mgr.OnUpdate(&proto.HostEndpointRemove{Id: &proto.HostEndpointID{EndpointId: "hep1"}})
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(applied).To(HaveKey("host-ep"))
+			Expect(applied["host-ep"]).To(BeEmpty())
+		})
+
+		It("should retry failed updates", func() {
+			mgr.OnUpdate(&proto.HostEndpointRemove{Id: &proto.HostEndpointID{EndpointId: "hep1"}})
+			failApply = true
+			Expect(mgr.CompleteDeferredWork()).To(Equal(ErrorUpdateFailed))
+			failApply = false
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(applied["host-ep"]).To(BeEmpty())
+		})
+	})
+
+	It("should match a host endpoint by name", func() {
+		mgr.OnUpdate(&proto.HostEndpointUpdate{
+			Id:       &proto.HostEndpointID{EndpointId: "hep1"},
+			Endpoint: &proto.HostEndpoint{Name: "Calico_ep", ProfileIds: []string{"prof1"}},
+		})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(applied).To(HaveKey("host-ep"))
+		Expect(applied["host-ep"][:2]).To(Equal(failsafeRules))
+	})
+
+	It("should only apply interface-qualified failsafe ports to matching host endpoints", func() {
+		mgr.failsafeInboundHostPorts = []config.ProtoPort{
+			{Protocol: "tcp", Port: 22},
+			{Protocol: "tcp", Port: 3389, Interface: "Calico+"},
+			{Protocol: "tcp", Port: 5985, Interface: "Ethernet"},
+		}
+		mgr.OnUpdate(&proto.HostEndpointUpdate{
+			Id:       &proto.HostEndpointID{EndpointId: "hep1"},
+			Endpoint: &proto.HostEndpoint{Name: "Calico_ep", ProfileIds: []string{"prof1"}},
+		})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(applied["host-ep"][1].LocalPorts).To(Equal("3389"))
+		Expect(applied["host-ep"][2]).To(Equal(failsafeRules[1]))
+	})
+})
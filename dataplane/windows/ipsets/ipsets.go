@@ -20,6 +20,8 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/projectcalico/libcalico-go/lib/set"
+
+	"github.com/projectcalico/felix/stringutils"
 )
 
 // IPSets manages a whole plane of IP sets, i.e. all the IPv4 sets, or all the IPv6 IP sets.
@@ -27,6 +29,10 @@ type IPSets struct {
 	IPVersionConfig *IPVersionConfig
 	ipSetIDToIPSet  map[string]*ipSet
 	logCxt          *log.Entry
+
+	// interner deduplicates the members of our IP sets.  The same IP is typically a member of
+	// many IP sets and each update carries its own copy of it.
+	interner *stringutils.Interner
 }
 
 func NewIPSets(ipVersionConfig *IPVersionConfig) *IPSets {
@@ -36,6 +42,7 @@ func NewIPSets(ipVersionConfig *IPVersionConfig) *IPSets {
 		logCxt: log.WithFields(log.Fields{
 			"family": ipVersionConfig.Family,
 		}),
+		interner: stringutils.NewInterner(),
 	}
 }
 
@@ -49,10 +56,17 @@ func (s *IPSets) AddOrReplaceIPSet(setMetadata IPSetMetadata, members []string)
 		"setID":   setMetadata.SetID,
 		"setType": setMetadata.Type,
 	}).Info("Creating IP set")
-	filteredMembers := s.filterMembers(members)
+	filteredMembers := set.New()
+	s.filterMembers(members).Iter(func(m interface{}) error {
+		filteredMembers.Add(s.interner.Intern(m.(string)))
+		return nil
+	})
 
 	// Create the IP set struct and stores it by id
 	setID := setMetadata.SetID
+	if oldIPSet := s.ipSetIDToIPSet[setID]; oldIPSet != nil {
+		s.releaseMembers(oldIPSet)
+	}
 	ipSet := &ipSet{
 		IPSetMetadata: setMetadata,
 		Members:       filteredMembers,
@@ -63,9 +77,19 @@ func (s *IPSets) AddOrReplaceIPSet(setMetadata IPSetMetadata, members []string)
 // RemoveIPSet is responsible for the removal of an IP set from the store
 func (s *IPSets) RemoveIPSet(setID string) {
 	s.logCxt.WithField("setID", setID).Info("Removing IP set")
+	if ipSet := s.ipSetIDToIPSet[setID]; ipSet != nil {
+		s.releaseMembers(ipSet)
+	}
 	delete(s.ipSetIDToIPSet, setID)
 }
 
+func (s *IPSets) releaseMembers(ipSet *ipSet) {
+	ipSet.Members.Iter(func(m interface{}) error {
+		s.interner.Release(m.(string))
+		return nil
+	})
+}
+
 // AddMembers adds a range of new members to an existing IP set in the store
 func (s *IPSets) AddMembers(setID string, newMembers []string) {
 	if len(newMembers) == 0 {
@@ -82,7 +106,9 @@ func (s *IPSets) AddMembers(setID string, newMembers []string) {
 		"filteredMembers": filteredMembers,
 	}).Debug("Adding new members to IP set")
 	filteredMembers.Iter(func(m interface{}) error {
-		ipSet.Members.Add(m)
+		if !ipSet.Members.Contains(m) {
+			ipSet.Members.Add(s.interner.Intern(m.(string)))
+		}
 		return nil
 	})
 }
@@ -104,7 +130,10 @@ func (s *IPSets) RemoveMembers(setID string, removedMembers []string) {
 	}).Debug("Removing members from IP set")
 
 	filteredMembers.Iter(func(m interface{}) error {
-		ipSet.Members.Discard(m)
+		if ipSet.Members.Contains(m) {
+			ipSet.Members.Discard(m)
+			s.interner.Release(m.(string))
+		}
 		return nil
 	})
 }
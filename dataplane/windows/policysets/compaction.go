@@ -0,0 +1,100 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policysets
+
+import (
+	"strings"
+
+	"github.com/projectcalico/felix/dataplane/windows/hns"
+)
+
+// compactRules reduces the number of HNS rules by merging rules that only differ in their remote
+// (or local) addresses into a single rule with the union of the addresses.  It only merges rules
+// that have the same priority: GetPolicySetRules only gives adjacent rules the same priority
+// when it is safe to reorder them, so merging them can't change which rule wins.
+//
+// A rule with no addresses matches any address so, when merging, "any" absorbs the other rule's
+// addresses.  Merged address lists are limited to maxEntries entries.
+func compactRules(rules []*hns.ACLPolicy, maxEntries int) []*hns.ACLPolicy {
+	var compacted []*hns.ACLPolicy
+	start := 0
+	for i := 1; i <= len(rules); i++ {
+		if i < len(rules) && rules[i].Priority == rules[start].Priority {
+			continue
+		}
+		run := mergeRules(rules[start:i], remoteAddrs, maxEntries)
+		run = mergeRules(run, localAddrs, maxEntries)
+		compacted = append(compacted, run...)
+		start = i
+	}
+	return compacted
+}
+
+func remoteAddrs(r *hns.ACLPolicy) *string {
+	return &r.RemoteAddresses
+}
+
+func localAddrs(r *hns.ACLPolicy) *string {
+	return &r.LocalAddresses
+}
+
+// mergeRules merges rules that are identical apart from the addresses returned by mergeField,
+// preserving the order in which the merged rules first appear.
+func mergeRules(rules []*hns.ACLPolicy, mergeField func(*hns.ACLPolicy) *string, maxEntries int) []*hns.ACLPolicy {
+	if len(rules) < 2 {
+		return rules
+	}
+	var merged []*hns.ACLPolicy
+	byKey := map[hns.ACLPolicy]*hns.ACLPolicy{}
+	for _, r := range rules {
+		key := *r
+		key.Id = ""
+		*mergeField(&key) = ""
+
+		existing := byKey[key]
+		if existing != nil {
+			if addrs, ok := unionAddrs(*mergeField(existing), *mergeField(r), maxEntries); ok {
+				*mergeField(existing) = addrs
+				continue
+			}
+		}
+		// Take a copy so that we don't modify the caller's rules (which may be cached).
+		rCopy := *r
+		byKey[key] = &rCopy
+		merged = append(merged, &rCopy)
+	}
+	return merged
+}
+
+// unionAddrs returns the union of two comma-separated address lists, where an empty list means
+// "any".  It returns false if the union would have more than maxEntries entries.
+func unionAddrs(a, b string, maxEntries int) (string, bool) {
+	if a == "" || b == "" {
+		return "", true
+	}
+	seen := map[string]bool{}
+	var union []string
+	for _, addr := range append(strings.Split(a, ","), strings.Split(b, ",")...) {
+		if seen[addr] {
+			continue
+		}
+		seen[addr] = true
+		union = append(union, addr)
+	}
+	if len(union) > maxEntries {
+		return "", false
+	}
+	return strings.Join(union, ","), true
+}
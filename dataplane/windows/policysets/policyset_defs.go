@@ -25,6 +25,10 @@ const (
 	// the ip family of this policy set, currently set to V4.
 	// V6 will be added once dataplane support is available.
 	ipVersion uint8 = 4
+	// Priority used for host endpoint failsafe rules, which must take precedence over policy.
+	HostEndpointFailsafeRulePriority uint16 = 100
+	// Priority used for platform exception rules, see PlatformException.
+	PlatformExceptionRulePriority uint16 = 500
 	// Priority used for rule that allows host to endpoint traffic.
 	HostToEndpointRulePriority uint16 = 900
 	// Start of range of priorities used for policy set rules.
@@ -64,6 +68,7 @@ type PolicySetsDataplane interface {
 	AddOrReplacePolicySet(setId string, policy interface{})
 	RemovePolicySet(setId string)
 	NewRule(isInbound bool, priority uint16) *hns.ACLPolicy
+	NewFailsafeRule(isInbound bool, protocol string, port uint16, cidr string) *hns.ACLPolicy
 	GetPolicySetRules(setIds []string, isInbound bool) (rules []*hns.ACLPolicy)
 	ProcessIpSetUpdate(ipSetId string) []string
 }
@@ -83,4 +88,7 @@ type policySet struct {
 	// maintain this to make it easier to look up which Policy sets are
 	// impacted (in need of recomputation) after a IP set update occurs.
 	IpSetIds set.Set
+	// NumUnsupportedRules is the number of rules that use features that HNS doesn't support and
+	// so are left out.
+	NumUnsupportedRules int
 }
@@ -16,6 +16,8 @@ package policysets
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -37,6 +39,11 @@ type HNSAPI interface {
 	GetHNSSupportedFeatures() hns.HNSSupportedFeatures
 }
 
+// ipPortsPerRule is the maximum number of addresses or ports that we put in a single HNS rule.
+// Windows RS4+ supports multiple CIDRs and port ranges in a rule but Microsoft recommended
+// limiting the number of entries per rule to a few thousand.
+const ipPortsPerRule = 4000
+
 // PolicySets manages a whole plane of policies/profiles
 type PolicySets struct {
 	IpSets []IPSetCache
@@ -44,6 +51,10 @@ type PolicySets struct {
 	supportedFeatures      hns.HNSSupportedFeatures
 	policySetIdToPolicySet map[string]*policySet
 
+	// ruleCompactionEnabled enables merging of rules that differ only in their addresses, see
+	// compactRules.
+	ruleCompactionEnabled bool
+
 	// staticACLRules contains the list of static endpoint ACL rules.
 	staticACLRules []*hns.ACLPolicy
 }
@@ -59,6 +70,33 @@ func NewPolicySets(hns HNSAPI, ipsets []IPSetCache, reader StaticRulesReader) *P
 	}
 }
 
+// EnableRuleCompaction enables merging of policy rules that differ only in their addresses, which
+// reduces the number of ACLs that are programmed per endpoint.  It requires HNS support for
+// address lists.
+func (s *PolicySets) EnableRuleCompaction() {
+	if !s.supportedFeatures.Acl.AclAddressLists {
+		log.Warn("HNS doesn't support ACL address lists, not enabling rule compaction.")
+		return
+	}
+	s.ruleCompactionEnabled = true
+}
+
+// AddPlatformExceptions validates the given platform exceptions and adds them to the static rules
+// that are applied to every endpoint.
+func (s *PolicySets) AddPlatformExceptions(exceptions []PlatformException) error {
+	var rules []*hns.ACLPolicy
+	for _, e := range exceptions {
+		rule, err := e.ToHnsACLPolicy()
+		if err != nil {
+			return err
+		}
+		log.WithField("rule", rule).Info("Adding platform exception rule")
+		rules = append(rules, rule)
+	}
+	s.staticACLRules = append(s.staticACLRules, rules...)
+	return nil
+}
+
 // AddOrReplacePolicySet is responsible for the creation (or replacement) of a Policy set
 // and it is capable of processing either Profiles or Policies from the datastore.
 func (s *PolicySets) AddOrReplacePolicySet(setId string, policy interface{}) {
@@ -71,6 +109,7 @@ func (s *PolicySets) AddOrReplacePolicySet(setId string, policy interface{}) {
 	// keep track of any IP sets which were referenced by the policy/profile so that
 	// we can easily tell which Policy sets are impacted when a IP set is modified.
 	var rules []*hns.ACLPolicy
+	var numUnsupported int
 	var policyIpSetIds set.Set
 
 	setMetadata := PolicySetMetadata{
@@ -81,13 +120,13 @@ func (s *PolicySets) AddOrReplacePolicySet(setId string, policy interface{}) {
 	case *proto.Policy:
 		// Incoming datastore object is a Policy
 		log.Debug("Policy set represents a Policy")
-		rules = s.convertPolicyToRules(setId, p.InboundRules, p.OutboundRules)
+		rules, numUnsupported = s.convertPolicyToRules(setId, p.InboundRules, p.OutboundRules)
 		policyIpSetIds = getReferencedIpSetIds(p.InboundRules, p.OutboundRules)
 		setMetadata.Type = PolicySetTypePolicy
 	case *proto.Profile:
 		// Incoming datastore object is a Profile
 		log.Debug("Policy set represents a Profile")
-		rules = s.convertPolicyToRules(setId, p.InboundRules, p.OutboundRules)
+		rules, numUnsupported = s.convertPolicyToRules(setId, p.InboundRules, p.OutboundRules)
 		policyIpSetIds = getReferencedIpSetIds(p.InboundRules, p.OutboundRules)
 		setMetadata.Type = PolicySetTypeProfile
 	default:
@@ -101,10 +140,27 @@ func (s *PolicySets) AddOrReplacePolicySet(setId string, policy interface{}) {
 		Policy:            policy,
 		Members:           rules,
 		IpSetIds:          policyIpSetIds,
+
+		NumUnsupportedRules: numUnsupported,
 	}
 	s.policySetIdToPolicySet[setMetadata.SetId] = policySet
 }
 
+// DegradedPolicySets returns a sorted description of each policy set that has rules that aren't
+// supported on Windows and so are left out.
+func (s *PolicySets) DegradedPolicySets() []string {
+	issues := []string{}
+	for setId, policySet := range s.policySetIdToPolicySet {
+		if policySet.NumUnsupportedRules == 0 {
+			continue
+		}
+		issues = append(issues, fmt.Sprintf("%s: %d rule(s) use features that aren't supported on "+
+			"Windows and are left out", setId, policySet.NumUnsupportedRules))
+	}
+	sort.Strings(issues)
+	return issues
+}
+
 // RemovePolicySet is responsible for the removal of a Policy set
 func (s *PolicySets) RemovePolicySet(setId string) {
 	log.WithField("setId", setId).Info("Processing removal of Policy set")
@@ -132,6 +188,7 @@ func (s *PolicySets) GetPolicySetRules(setIds []string, isInbound bool) (rules [
 		}
 	}
 
+	numStaticRules := len(rules)
 	var lastRule *hns.ACLPolicy
 	for _, setId := range setIds {
 		if debug {
@@ -171,6 +228,17 @@ func (s *PolicySets) GetPolicySetRules(setIds []string, isInbound bool) (rules [
 		}
 	}
 
+	if s.ruleCompactionEnabled {
+		policyRules := compactRules(rules[numStaticRules:], ipPortsPerRule)
+		if debug {
+			log.WithFields(log.Fields{
+				"before": len(rules) - numStaticRules,
+				"after":  len(policyRules),
+			}).Debug("Compacted policy rules")
+		}
+		rules = append(rules[:numStaticRules], policyRules...)
+	}
+
 	// Apply a default block rule for this direction at the end of the policy
 	currentPriority++
 	rules = append(rules, s.NewRule(isInbound, currentPriority))
@@ -237,15 +305,17 @@ func getReferencedIpSetIds(inboundRules []*proto.Rule, outboundRules []*proto.Ru
 	return ipSetIds
 }
 
-// convertPolicyToRules converts the provided inbound and outbound proto rules into hns rules.
-func (s *PolicySets) convertPolicyToRules(policyId string, inboundRules []*proto.Rule, outboundRules []*proto.Rule) (hnsRules []*hns.ACLPolicy) {
+// convertPolicyToRules converts the provided inbound and outbound proto rules into hns rules.  It
+// also returns the number of rules that were left out because HNS doesn't support them.
+func (s *PolicySets) convertPolicyToRules(policyId string, inboundRules []*proto.Rule, outboundRules []*proto.Rule) (hnsRules []*hns.ACLPolicy, numUnsupported int) {
 	log.WithField("policyId", policyId).Debug("Converting policy to HNS rules.")
 
-	inbound := s.protoRulesToHnsRules(policyId, inboundRules, true)
+	inbound, numUnsupportedIn := s.protoRulesToHnsRules(policyId, inboundRules, true)
 	hnsRules = append(hnsRules, inbound...)
 
-	outbound := s.protoRulesToHnsRules(policyId, outboundRules, false)
+	outbound, numUnsupportedOut := s.protoRulesToHnsRules(policyId, outboundRules, false)
 	hnsRules = append(hnsRules, outbound...)
+	numUnsupported = numUnsupportedIn + numUnsupportedOut
 
 	if log.GetLevel() >= log.DebugLevel {
 		for _, rule := range hnsRules {
@@ -256,16 +326,17 @@ func (s *PolicySets) convertPolicyToRules(policyId string, inboundRules []*proto
 	return
 }
 
-// protoRulesToHnsRules converts a set of proto rules into HNS rules.
-func (s *PolicySets) protoRulesToHnsRules(policyId string, protoRules []*proto.Rule, isInbound bool) (rules []*hns.ACLPolicy) {
+// protoRulesToHnsRules converts a set of proto rules into HNS rules, returning the number of rules
+// that were skipped because they aren't supported.
+func (s *PolicySets) protoRulesToHnsRules(policyId string, protoRules []*proto.Rule, isInbound bool) (rules []*hns.ACLPolicy, numUnsupported int) {
 	log.WithField("policyId", policyId).Debug("protoRulesToHnsRules")
-	const ipPortsPerRule = 4000
 	for _, protoRule := range protoRules {
 		hnsRules, err := s.protoRuleToHnsRules(policyId, protoRule, isInbound, ipPortsPerRule)
 		if err != nil {
 			switch err {
 			case ErrNotSupported:
 				log.WithField("rule", protoRule).Warn("Skipped rule because it's not supported on Windows.")
+				numUnsupported++
 			case ErrRuleIsNoOp:
 				// For example, an IPv6 rule on IPv4.
 				log.WithField("rule", protoRule).Debug("Skipping no-op rule.")
@@ -314,6 +385,12 @@ func (s *PolicySets) protoRuleToHnsRules(policyId string, pRule *proto.Rule, isI
 		return nil, ErrNotSupported
 	}
 
+	// Skip rules that match on destination domains, these are not supported
+	if len(pRule.DstDomains) > 0 {
+		log.WithField("rule", pRule).Info("Skipping rule because it contains destination domains (currently unsupported).")
+		return nil, ErrNotSupported
+	}
+
 	// Filter the Src and Dst CIDRs to only the IP version that we're rendering
 	var filteredAll bool
 	ruleCopy := *pRule
@@ -649,6 +726,29 @@ func (s *PolicySets) NewRule(isInbound bool, priority uint16) *hns.ACLPolicy {
 	}
 }
 
+// NewFailsafeRule returns a new hns rule that allows traffic to (inbound) or from (outbound) the
+// given local port.  If cidr is non-empty, the rule only matches that remote CIDR.
+func (s *PolicySets) NewFailsafeRule(isInbound bool, protocol string, port uint16, cidr string) *hns.ACLPolicy {
+	rule := s.NewRule(isInbound, HostEndpointFailsafeRulePriority)
+	rule.Action = hns.Allow
+	rule.Protocol = protocolNameToNumber(protocol)
+	if isInbound {
+		rule.LocalPorts = strconv.Itoa(int(port))
+	} else {
+		rule.RemotePorts = strconv.Itoa(int(port))
+	}
+	if cidr != "" && cidr != "0.0.0.0/0" {
+		rule.RemoteAddresses = cidr
+	}
+	if s.supportedFeatures.Acl.AclRuleId {
+		rule.Id = fmt.Sprintf("failsafe-%s-%s-%d", rule.Direction, strings.ToLower(protocol), port)
+		if rule.RemoteAddresses != "" {
+			rule.Id += "-" + strings.NewReplacer(".", "-", "/", "-").Replace(rule.RemoteAddresses)
+		}
+	}
+	return rule
+}
+
 // NewHostRule returns a new hns rule object scoped to the host.
 func (s *PolicySets) NewHostRule(isInbound bool) *hns.ACLPolicy {
 	direction := hns.Out
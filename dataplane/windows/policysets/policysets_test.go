@@ -588,6 +588,37 @@ func TestNegativeTestCases(t *testing.T) {
 
 }
 
+func TestDegradedPolicySets(t *testing.T) {
+	RegisterTestingT(t)
+
+	h := mockHNS{}
+	h.SupportedFeatures.Acl.AclRuleId = true
+	h.SupportedFeatures.Acl.AclNoHostRulePriority = true
+	ipsc := mockIPSetCache{
+		IPSets: map[string][]string{},
+	}
+	ps := NewPolicySets(&h, []IPSetCache{&ipsc}, mockReader(""))
+
+	ps.AddOrReplacePolicySet("policy-pol1", &proto.Policy{
+		InboundRules: []*proto.Rule{
+			{Action: "Allow", Icmp: &proto.Rule_IcmpType{IcmpType: 8}},
+			{Action: "Allow", RuleId: "rule-2"},
+		},
+		OutboundRules: []*proto.Rule{
+			{Action: "Allow", DstDomains: []string{"example.com"}},
+		},
+	})
+	ps.AddOrReplacePolicySet("profile-prof1", &proto.Profile{
+		InboundRules: []*proto.Rule{{Action: "Allow"}},
+	})
+	Expect(ps.DegradedPolicySets()).To(Equal([]string{
+		"policy-pol1: 2 rule(s) use features that aren't supported on Windows and are left out",
+	}))
+
+	ps.RemovePolicySet("policy-pol1")
+	Expect(ps.DegradedPolicySets()).To(BeEmpty())
+}
+
 func TestMultiIpPortChunks(t *testing.T) {
 	RegisterTestingT(t)
 
@@ -1225,3 +1256,75 @@ type mockIPSetCache struct {
 func (c *mockIPSetCache) GetIPSetMembers(ipsetID string) []string {
 	return c.IPSets[ipsetID]
 }
+
+func TestRuleCompaction(t *testing.T) {
+	RegisterTestingT(t)
+
+	h := mockHNS{}
+	// Windows 1803/RS4
+	h.SupportedFeatures.Acl.AclRuleId = true
+	h.SupportedFeatures.Acl.AclNoHostRulePriority = true
+	h.SupportedFeatures.Acl.AclAddressLists = true
+
+	ipsc := mockIPSetCache{
+		IPSets: map[string][]string{},
+	}
+
+	ps := NewPolicySets(&h, []IPSetCache{&ipsc}, mockReader(""))
+	ps.EnableRuleCompaction()
+
+	tcp := &proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "tcp"}}
+	ps.AddOrReplacePolicySet("a", &proto.Policy{
+		InboundRules: []*proto.Rule{
+			{Action: "Allow", Protocol: tcp, SrcNet: []string{"10.0.0.0/24"}, DstPorts: []*proto.PortRange{{First: 80, Last: 80}}, RuleId: "r1"},
+			{Action: "Allow", Protocol: tcp, SrcNet: []string{"10.0.1.0/24"}, DstPorts: []*proto.PortRange{{First: 443, Last: 443}}, RuleId: "r2"},
+			{Action: "Allow", Protocol: tcp, SrcNet: []string{"10.0.2.0/24", "10.0.0.0/24"}, DstPorts: []*proto.PortRange{{First: 80, Last: 80}}, RuleId: "r3"},
+			{Action: "Deny", SrcNet: []string{"10.0.3.0/24"}, RuleId: "r4"},
+			{Action: "Allow", Protocol: tcp, SrcNet: []string{"10.0.4.0/24"}, DstPorts: []*proto.PortRange{{First: 80, Last: 80}}, RuleId: "r5"},
+		},
+	})
+	ps.AddOrReplacePolicySet("b", &proto.Policy{
+		InboundRules: []*proto.Rule{
+			{Action: "Allow", Protocol: tcp, DstPorts: []*proto.PortRange{{First: 80, Last: 80}}, RuleId: "r1"},
+			{Action: "Allow", Protocol: tcp, SrcNet: []string{"10.0.0.0/24"}, DstPorts: []*proto.PortRange{{First: 80, Last: 80}}, RuleId: "r2"},
+		},
+	})
+
+	Expect(ps.GetPolicySetRules([]string{"a"}, true)).To(Equal([]*hns.ACLPolicy{
+		{Type: hns.ACL, Protocol: 6, Action: hns.Allow, Direction: hns.In, RuleType: hns.Switch, Priority: 1000,
+			Id: "a-r1-0", RemoteAddresses: "10.0.0.0/24,10.0.2.0/24", LocalPorts: "80"},
+		{Type: hns.ACL, Protocol: 6, Action: hns.Allow, Direction: hns.In, RuleType: hns.Switch, Priority: 1000,
+			Id: "a-r2-0", RemoteAddresses: "10.0.1.0/24", LocalPorts: "443"},
+		// Rules aren't merged across a change of action.
+		{Type: hns.ACL, Protocol: 256, Action: hns.Block, Direction: hns.In, RuleType: hns.Switch, Priority: 1001,
+			Id: "a-r4-0", RemoteAddresses: "10.0.3.0/24"},
+		{Type: hns.ACL, Protocol: 6, Action: hns.Allow, Direction: hns.In, RuleType: hns.Switch, Priority: 1002,
+			Id: "a-r5-0", RemoteAddresses: "10.0.4.0/24", LocalPorts: "80"},
+		// Default deny rule.
+		{Type: hns.ACL, Protocol: 256, Action: hns.Block, Direction: hns.In, RuleType: hns.Switch, Priority: 1003},
+		// Default host/pod rule.
+		{Type: hns.ACL, Protocol: 256, Action: hns.Allow, Direction: hns.In, RuleType: hns.Host},
+	}), "incorrect compacted rules for a")
+
+	// A rule that matches any address absorbs the other rule.
+	Expect(ps.GetPolicySetRules([]string{"b"}, true)).To(Equal([]*hns.ACLPolicy{
+		{Type: hns.ACL, Protocol: 6, Action: hns.Allow, Direction: hns.In, RuleType: hns.Switch, Priority: 1000,
+			Id: "b-r1-0", LocalPorts: "80"},
+		// Default deny rule.
+		{Type: hns.ACL, Protocol: 256, Action: hns.Block, Direction: hns.In, RuleType: hns.Switch, Priority: 1001},
+		// Default host/pod rule.
+		{Type: hns.ACL, Protocol: 256, Action: hns.Allow, Direction: hns.In, RuleType: hns.Host},
+	}), "incorrect compacted rules for b")
+
+	// Merged address lists are limited in size.
+	rules := []*hns.ACLPolicy{
+		{Priority: 1000, RemoteAddresses: "10.0.0.1/32,10.0.0.2/32"},
+		{Priority: 1000, RemoteAddresses: "10.0.0.3/32"},
+		{Priority: 1000, RemoteAddresses: "10.0.0.4/32"},
+	}
+	Expect(compactRules(rules, 3)).To(Equal([]*hns.ACLPolicy{
+		{Priority: 1000, RemoteAddresses: "10.0.0.1/32,10.0.0.2/32,10.0.0.3/32"},
+		{Priority: 1000, RemoteAddresses: "10.0.0.4/32"},
+	}))
+	Expect(rules[0].RemoteAddresses).To(Equal("10.0.0.1/32,10.0.0.2/32"), "input rules should not be modified")
+}
@@ -19,8 +19,11 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 
@@ -96,6 +99,50 @@ type staticEndpointPolicies struct {
 	Rules    []staticEndpointPolicy `json:"Rules"`
 }
 
+// PlatformException allows workloads to reach a platform service, such as the cloud provider's DNS
+// or metadata server, regardless of policy.  It is rendered as an outbound static rule.
+type PlatformException struct {
+	// Name identifies the kind of exception, for example "dns", and is used in the rule ID.
+	Name     string
+	Protocol string
+	CIDR     string
+	// Port is the destination port, or 0 for any port.
+	Port uint16
+}
+
+func (e PlatformException) ToHnsACLPolicy() (*hns.ACLPolicy, error) {
+	protocol := strings.ToLower(e.Protocol)
+	if protocol != "tcp" && protocol != "udp" {
+		return nil, fmt.Errorf("platform exception %s: protocol %q is not tcp or udp", e.Name, e.Protocol)
+	}
+	if e.CIDR == "" {
+		return nil, fmt.Errorf("platform exception %s: destination CIDR is required", e.Name)
+	}
+	ip, ipNet, err := net.ParseCIDR(e.CIDR)
+	if err != nil {
+		return nil, fmt.Errorf("platform exception %s: %w", e.Name, err)
+	}
+	if ip.To4() == nil {
+		return nil, fmt.Errorf("platform exception %s: %s is not an IPv4 CIDR", e.Name, e.CIDR)
+	}
+
+	rule := &hns.ACLPolicy{
+		Type:            hns.ACL,
+		Id:              fmt.Sprintf("platform-%s-%s-%s", e.Name, protocol, strings.NewReplacer(".", "-", "/", "-").Replace(ipNet.String())),
+		Protocol:        protocolNameToNumber(protocol),
+		Action:          hns.Allow,
+		Direction:       hns.Out,
+		RemoteAddresses: ipNet.String(),
+		RuleType:        hns.Switch,
+		Priority:        PlatformExceptionRulePriority,
+	}
+	if e.Port != 0 {
+		rule.RemotePorts = strconv.Itoa(int(e.Port))
+		rule.Id += "-" + rule.RemotePorts
+	}
+	return rule, nil
+}
+
 // staticRulesReader is a wrapper to read a file.
 // So we can have a mock reader for UT.
 type StaticRulesReader interface {
@@ -117,6 +117,39 @@ func TestFailedRendering(t *testing.T) {
 	Expect(func() { readStaticRules(r) }).To(Panic())
 }
 
+func TestPlatformExceptions(t *testing.T) {
+	RegisterTestingT(t)
+
+	h := mockHNS{}
+	ps := NewPolicySets(&h, []IPSetCache{&mockIPSetCache{}}, mockReader(staticRules))
+	Expect(ps.AddPlatformExceptions([]PlatformException{
+		{Name: "dns", Protocol: "udp", CIDR: "169.254.169.253/32", Port: 53},
+		{Name: "metadata", Protocol: "TCP", CIDR: "169.254.169.254/32"},
+	})).To(Succeed())
+
+	Expect(ps.GetPolicySetRules(nil, false)[:3]).To(Equal([]*hns.ACLPolicy{
+		// Static rule from the file.
+		{Type: hns.ACL, Id: "MyPlatform-block-server", Protocol: 6, Action: hns.Block, Direction: hns.Out,
+			RuleType: hns.Switch, Priority: 200, RemoteAddresses: "10.0.0.1/32", RemotePorts: "80"},
+		{Type: hns.ACL, Id: "platform-dns-udp-169-254-169-253-32-53", Protocol: 17, Action: hns.Allow,
+			Direction: hns.Out, RuleType: hns.Switch, Priority: 500, RemoteAddresses: "169.254.169.253/32",
+			RemotePorts: "53"},
+		{Type: hns.ACL, Id: "platform-metadata-tcp-169-254-169-254-32", Protocol: 6, Action: hns.Allow,
+			Direction: hns.Out, RuleType: hns.Switch, Priority: 500, RemoteAddresses: "169.254.169.254/32"},
+	}))
+
+	for _, e := range []PlatformException{
+		{Name: "dns", Protocol: "icmp", CIDR: "10.0.0.1/32"},
+		{Name: "dns", Protocol: "udp", Port: 53},
+		{Name: "dns", Protocol: "udp", CIDR: "10.0.0.1", Port: 53},
+		{Name: "dns", Protocol: "udp", CIDR: "fd00::1/128", Port: 53},
+	} {
+		_, err := e.ToHnsACLPolicy()
+		Expect(err).To(HaveOccurred(), "expected error for %+v", e)
+	}
+	Expect(ps.AddPlatformExceptions([]PlatformException{{Name: "bad"}})).NotTo(Succeed())
+}
+
 type mockReader string
 
 func (m mockReader) ReadData() ([]byte, error) {
@@ -0,0 +1,295 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package windataplane
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/dataplane/windows/hns"
+	"github.com/projectcalico/felix/proto"
+)
+
+var (
+	// The per-workload metrics have the same names and labels as those exported by the Linux
+	// dataplane.  Windows workloads don't have a host-side interface, so the "iface" label holds
+	// the ID of the HNS endpoint, which is also the name of its VFP port.
+	workloadMetricLabels = []string{"namespace", "workload", "endpoint", "iface"}
+
+	descWorkloadBytesSent = prometheus.NewDesc(
+		"felix_workload_bytes_sent",
+		"Number of bytes sent by the local workload endpoint.",
+		workloadMetricLabels, nil,
+	)
+	descWorkloadBytesReceived = prometheus.NewDesc(
+		"felix_workload_bytes_received",
+		"Number of bytes received by the local workload endpoint.",
+		workloadMetricLabels, nil,
+	)
+	descWorkloadPacketsSent = prometheus.NewDesc(
+		"felix_workload_packets_sent",
+		"Number of packets sent by the local workload endpoint.",
+		workloadMetricLabels, nil,
+	)
+	descWorkloadPacketsReceived = prometheus.NewDesc(
+		"felix_workload_packets_received",
+		"Number of packets received by the local workload endpoint.",
+		workloadMetricLabels, nil,
+	)
+	// The Linux dataplane has no per-rule equivalent of this one.
+	descPolicyRulePackets = prometheus.NewDesc(
+		"felix_policy_rule_packets",
+		"Number of packets that matched a policy rule on the local workload endpoint.",
+		append(append([]string{}, workloadMetricLabels...), "rule", "direction", "action"), nil,
+	)
+)
+
+// vfpPortCounters holds the traffic counters of a VFP port.  The directions are from the point of
+// view of the switch port so "in" is traffic sent by the workload.
+type vfpPortCounters struct {
+	BytesIn, BytesOut     uint64
+	PacketsIn, PacketsOut uint64
+}
+
+type vfpInterface interface {
+	PortCounters(portID string) (vfpPortCounters, error)
+	// RuleCounters returns the number of packets that matched each ACL rule, by rule ID.
+	RuleCounters(portID string) (map[string]uint64, error)
+}
+
+// vfpCtrl reads counters from VFP using the vfpctrl tool.  The HNS endpoint ID is used as the VFP
+// port name.
+type vfpCtrl struct {
+	// Shim for testing.
+	run func(args ...string) ([]byte, error)
+}
+
+func newVFPCtrl() *vfpCtrl {
+	return &vfpCtrl{
+		run: func(args ...string) ([]byte, error) {
+			return exec.Command("vfpctrl.exe", args...).Output()
+		},
+	}
+}
+
+func (v *vfpCtrl) PortCounters(portID string) (vfpPortCounters, error) {
+	out, err := v.run("/port", portID, "/get-port-counter")
+	if err != nil {
+		return vfpPortCounters{}, err
+	}
+	return parseVFPPortCounters(out), nil
+}
+
+func (v *vfpCtrl) RuleCounters(portID string) (map[string]uint64, error) {
+	out, err := v.run("/port", portID, "/get-rule-counter")
+	if err != nil {
+		return nil, err
+	}
+	return parseVFPRuleCounters(out), nil
+}
+
+// parseVFPPortCounters parses the output of "vfpctrl /get-port-counter", which has a section for
+// each direction:
+//
+//   Direction - OUT
+//     ...
+//     Bytes: 1234
+//     Packets: 12
+//   Direction - IN
+//     ...
+func parseVFPPortCounters(out []byte) (c vfpPortCounters) {
+	var bytesCtr, pktsCtr *uint64
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "Direction - ") {
+			switch strings.TrimSpace(strings.TrimPrefix(line, "Direction - ")) {
+			case "IN":
+				bytesCtr, pktsCtr = &c.BytesIn, &c.PacketsIn
+			case "OUT":
+				bytesCtr, pktsCtr = &c.BytesOut, &c.PacketsOut
+			default:
+				bytesCtr, pktsCtr = nil, nil
+			}
+			continue
+		}
+		key, value, ok := splitVFPLine(line)
+		if !ok || bytesCtr == nil {
+			continue
+		}
+		switch key {
+		case "Bytes":
+			*bytesCtr = value
+		case "Packets":
+			*pktsCtr = value
+		}
+	}
+	return
+}
+
+// parseVFPRuleCounters parses the output of "vfpctrl /get-rule-counter", which lists each rule's
+// ID followed by its counters:
+//
+//   RULE : policy-pol1-rule1-0
+//     ...
+//     Matched packets : 12
+func parseVFPRuleCounters(out []byte) map[string]uint64 {
+	counters := map[string]uint64{}
+	var rule string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.ToUpper(strings.TrimSpace(parts[0]))
+		if key == "RULE" || key == "ID" {
+			rule = strings.TrimSpace(parts[1])
+			continue
+		}
+		if rule == "" || key != "MATCHED PACKETS" {
+			continue
+		}
+		if n, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 10, 64); err == nil {
+			counters[rule] += n
+		}
+	}
+	return counters
+}
+
+func splitVFPLine(line string) (string, uint64, bool) {
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 {
+		return "", 0, false
+	}
+	n, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return strings.TrimSpace(parts[0]), n, true
+}
+
+type vfpRuleInfo struct {
+	direction string
+	action    string
+}
+
+type vfpEndpoint struct {
+	hnsEndpointID string
+	labels        []string
+	rules         map[string]vfpRuleInfo
+}
+
+// vfpMetricsCollector exports per-workload traffic counters and per-ACL rule hit counters, read
+// from VFP at scrape time.  The endpoint manager tells it which HNS endpoint belongs to each
+// workload and which rules it programmed there.
+type vfpMetricsCollector struct {
+	lock      sync.Mutex
+	endpoints map[proto.WorkloadEndpointID]*vfpEndpoint
+	vfp       vfpInterface
+}
+
+func newVFPMetricsCollector(vfp vfpInterface) *vfpMetricsCollector {
+	return &vfpMetricsCollector{
+		endpoints: map[proto.WorkloadEndpointID]*vfpEndpoint{},
+		vfp:       vfp,
+	}
+}
+
+// OnEndpointRulesApplied records the rules that were applied to a workload's HNS endpoint.
+func (c *vfpMetricsCollector) OnEndpointRulesApplied(id proto.WorkloadEndpointID, hnsEndpointID string, rules []*hns.ACLPolicy) {
+	namespace, workload := "", id.WorkloadId
+	if parts := strings.SplitN(id.WorkloadId, "/", 2); len(parts) == 2 {
+		namespace, workload = parts[0], parts[1]
+	}
+	ep := &vfpEndpoint{
+		hnsEndpointID: hnsEndpointID,
+		labels:        []string{namespace, workload, id.EndpointId, hnsEndpointID},
+		rules:         map[string]vfpRuleInfo{},
+	}
+	for _, r := range rules {
+		if r.Id == "" {
+			continue
+		}
+		direction := "ingress"
+		if r.Direction == hns.Out {
+			direction = "egress"
+		}
+		ep.rules[r.Id] = vfpRuleInfo{direction: direction, action: strings.ToLower(string(r.Action))}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.endpoints[id] = ep
+}
+
+func (c *vfpMetricsCollector) OnEndpointRemoved(id proto.WorkloadEndpointID) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.endpoints, id)
+}
+
+func (c *vfpMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descWorkloadBytesSent
+	ch <- descWorkloadBytesReceived
+	ch <- descWorkloadPacketsSent
+	ch <- descWorkloadPacketsReceived
+	ch <- descPolicyRulePackets
+}
+
+func (c *vfpMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	// Take a snapshot so that we don't hold the lock, and block the dataplane, while we run
+	// vfpctrl.
+	c.lock.Lock()
+	var endpoints []*vfpEndpoint
+	for _, ep := range c.endpoints {
+		endpoints = append(endpoints, ep)
+	}
+	c.lock.Unlock()
+
+	for _, ep := range endpoints {
+		logCxt := log.WithField("hnsEndpointId", ep.hnsEndpointID)
+		if ctrs, err := c.vfp.PortCounters(ep.hnsEndpointID); err != nil {
+			logCxt.WithError(err).Debug("Failed to read VFP port counters.")
+		} else {
+			ch <- prometheus.MustNewConstMetric(descWorkloadBytesSent, prometheus.CounterValue, float64(ctrs.BytesIn), ep.labels...)
+			ch <- prometheus.MustNewConstMetric(descWorkloadBytesReceived, prometheus.CounterValue, float64(ctrs.BytesOut), ep.labels...)
+			ch <- prometheus.MustNewConstMetric(descWorkloadPacketsSent, prometheus.CounterValue, float64(ctrs.PacketsIn), ep.labels...)
+			ch <- prometheus.MustNewConstMetric(descWorkloadPacketsReceived, prometheus.CounterValue, float64(ctrs.PacketsOut), ep.labels...)
+		}
+
+		ruleCtrs, err := c.vfp.RuleCounters(ep.hnsEndpointID)
+		if err != nil {
+			logCxt.WithError(err).Debug("Failed to read VFP rule counters.")
+			continue
+		}
+		for id, info := range ep.rules {
+			n, ok := ruleCtrs[id]
+			if !ok {
+				continue
+			}
+			labels := append(append([]string{}, ep.labels...), id, info.direction, info.action)
+			ch <- prometheus.MustNewConstMetric(descPolicyRulePackets, prometheus.CounterValue, float64(n), labels...)
+		}
+	}
+}
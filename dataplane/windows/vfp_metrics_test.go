@@ -0,0 +1,167 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package windataplane
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/projectcalico/felix/dataplane/windows/hns"
+	"github.com/projectcalico/felix/proto"
+)
+
+const samplePortCounters = `
+ITEM LIST
+===========
+Direction - OUT
+  SYN packets: 3
+  Bytes: 1000
+  Packets: 10
+  Dropped ACL packets: 1
+Direction - IN
+  Bytes: 2000
+  Packets: 20
+Command get-port-counter succeeded!
+`
+
+const sampleRuleCounters = `
+ITEM LIST
+===========
+  Layer : ACL_ENDPOINT_LAYER
+    Group : ACL_ENDPOINT_GROUP_IPV4_IN
+      RULE : policy-pol1-rule1-0
+        Matched packets : 5
+      RULE : policy-pol1-rule2-0
+        Matched packets : 7
+    Group : ACL_ENDPOINT_GROUP_IPV4_OUT
+      RULE : policy-pol1-rule2-0
+        Matched packets : 1
+Command get-rule-counter succeeded!
+`
+
+type mockVFP struct {
+	portCtrs vfpPortCounters
+	ruleCtrs map[string]uint64
+	err      error
+}
+
+func (v *mockVFP) PortCounters(portID string) (vfpPortCounters, error) {
+	return v.portCtrs, v.err
+}
+
+func (v *mockVFP) RuleCounters(portID string) (map[string]uint64, error) {
+	return v.ruleCtrs, v.err
+}
+
+var _ = Describe("VFP metrics", func() {
+	It("should parse port counters", func() {
+		Expect(parseVFPPortCounters([]byte(samplePortCounters))).To(Equal(vfpPortCounters{
+			BytesOut:   1000,
+			PacketsOut: 10,
+			BytesIn:    2000,
+			PacketsIn:  20,
+		}))
+	})
+
+	It("should parse rule counters", func() {
+		Expect(parseVFPRuleCounters([]byte(sampleRuleCounters))).To(Equal(map[string]uint64{
+			"policy-pol1-rule1-0": 5,
+			"policy-pol1-rule2-0": 8,
+		}))
+	})
+
+	It("should run vfpctrl for the endpoint's port", func() {
+		v := newVFPCtrl()
+		var args []string
+		v.run = func(a ...string) ([]byte, error) {
+			args = a
+			return []byte(samplePortCounters), nil
+		}
+		_, err := v.PortCounters("abcd")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(args).To(Equal([]string{"/port", "abcd", "/get-port-counter"}))
+	})
+
+	Describe("collector", func() {
+		var (
+			vfp *mockVFP
+			c   *vfpMetricsCollector
+			id  = proto.WorkloadEndpointID{WorkloadId: "ns1/pod1", EndpointId: "eth0"}
+		)
+
+		collect := func() map[string][]*dto.Metric {
+			ch := make(chan prometheus.Metric, 100)
+			c.Collect(ch)
+			close(ch)
+			metrics := map[string][]*dto.Metric{}
+			for m := range ch {
+				var d dto.Metric
+				Expect(m.Write(&d)).To(Succeed())
+				name := m.Desc().String()
+				metrics[name] = append(metrics[name], &d)
+			}
+			return metrics
+		}
+
+		BeforeEach(func() {
+			vfp = &mockVFP{
+				portCtrs: vfpPortCounters{BytesIn: 1, BytesOut: 2, PacketsIn: 3, PacketsOut: 4},
+				ruleCtrs: map[string]uint64{"policy-pol1-rule1-0": 5, "unknown": 6},
+			}
+			c = newVFPMetricsCollector(vfp)
+			c.OnEndpointRulesApplied(id, "hns-ep", []*hns.ACLPolicy{
+				{Id: "policy-pol1-rule1-0", Direction: hns.In, Action: hns.Block},
+				{Direction: hns.In, Action: hns.Block},
+			})
+		})
+
+		It("should export workload and rule counters", func() {
+			metrics := collect()
+			Expect(metrics[descWorkloadBytesSent.String()]).To(HaveLen(1))
+			Expect(metrics[descWorkloadBytesSent.String()][0].GetCounter().GetValue()).To(Equal(1.0))
+			Expect(metrics[descWorkloadPacketsReceived.String()][0].GetCounter().GetValue()).To(Equal(4.0))
+			rules := metrics[descPolicyRulePackets.String()]
+			Expect(rules).To(HaveLen(1))
+			Expect(rules[0].GetCounter().GetValue()).To(Equal(5.0))
+			labels := map[string]string{}
+			for _, l := range rules[0].GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			Expect(labels).To(Equal(map[string]string{
+				"namespace": "ns1",
+				"workload":  "pod1",
+				"endpoint":  "eth0",
+				"iface":     "hns-ep",
+				"rule":      "policy-pol1-rule1-0",
+				"direction": "ingress",
+				"action":    "block",
+			}))
+		})
+
+		It("should skip endpoints whose counters can't be read", func() {
+			vfp.err = errors.New("failed")
+			Expect(collect()).To(BeEmpty())
+		})
+
+		It("should stop exporting removed endpoints", func() {
+			c.OnEndpointRemoved(id)
+			Expect(collect()).To(BeEmpty())
+		})
+	})
+})
@@ -21,6 +21,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 
 	"github.com/projectcalico/felix/dataplane/windows/hcn"
@@ -30,8 +31,27 @@ import (
 
 var (
 	ErrUpdatesFailed = errors.New("some VXLAN route updates failed")
+
+	countVXLANRouteChecks = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "felix_windows_vxlan_route_checks",
+		Help: "Number of periodic checks of the VXLAN routes in HNS.",
+	})
+	countVXLANRouteDrift = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "felix_windows_vxlan_route_drift",
+		Help: "Number of VXLAN routes found to be missing, duplicated or unexpected in HNS, by type.",
+	}, []string{"type"})
+	countVXLANRouteRepairFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "felix_windows_vxlan_route_update_failures",
+		Help: "Number of times that updating the VXLAN routes in HNS failed.",
+	})
 )
 
+func init() {
+	prometheus.MustRegister(countVXLANRouteChecks)
+	prometheus.MustRegister(countVXLANRouteDrift)
+	prometheus.MustRegister(countVXLANRouteRepairFailures)
+}
+
 type vxlanManager struct {
 	// Shim for the Windows HNS API.
 	hcn hcnInterface
@@ -50,6 +70,11 @@ type vxlanManager struct {
 
 	// Indicates if configuration has changed since the last apply.
 	dirty bool
+
+	// programmedNetPols contains the routes that we successfully programmed in the last apply.
+	// Used to tell drift caused by out-of-band HNS operations apart from our own updates.
+	programmedNetPols set.Set
+	doneFirstApply    bool
 }
 
 type hcnInterface interface {
@@ -66,9 +91,19 @@ func newVXLANManager(hcn hcnInterface, hostname string, networkName *regexp.Rege
 		vxlanID:      vxlanID,
 		vxlanPort:    port,
 		dirty:        true,
+
+		programmedNetPols: set.New(),
 	}
 }
 
+// QueueRouteCheck triggers a check of the routes in HNS against the desired routes at the next
+// apply.  Any drift is repaired.
+func (m *vxlanManager) QueueRouteCheck() {
+	logrus.Debug("Queueing VXLAN route check")
+	countVXLANRouteChecks.Inc()
+	m.dirty = true
+}
+
 func (m *vxlanManager) OnUpdate(protoBufMsg interface{}) {
 	switch msg := protoBufMsg.(type) {
 	case *proto.RouteUpdate:
@@ -138,7 +173,7 @@ func (m *vxlanManager) CompleteDeferredWork() error {
 	}
 
 	// Calculate what should be there as a whole, then, below, we'll remove items that are already there from this set.
-	netPolsToAdd := set.New()
+	desiredNetPols := set.New()
 	for dest, route := range m.routesByDest {
 		logrus.WithFields(logrus.Fields{
 			"node":  dest,
@@ -159,11 +194,15 @@ func (m *vxlanManager) CompleteDeferredWork() error {
 			DestinationPrefix:           route.Dst,
 		}
 
-		netPolsToAdd.Add(networkPolicySettings)
+		desiredNetPols.Add(networkPolicySettings)
 	}
+	netPolsToAdd := desiredNetPols.Copy()
 
-	// Load what's actually there.
-	netPolsToRemove := set.New()
+	// Load what's actually there.  Out-of-band HNS operations sometimes duplicate routes so we
+	// track every copy of each route.
+	var netPolsToRemove []hcn.RemoteSubnetRoutePolicySetting
+	existingNetPols := map[hcn.RemoteSubnetRoutePolicySetting][]hcn.RemoteSubnetRoutePolicySetting{}
+	var existingOrder []hcn.RemoteSubnetRoutePolicySetting
 	for _, policy := range network.Policies {
 		if policy.Type == hcn.RemoteSubnetRoute {
 			existingPolSettings := hcn.RemoteSubnetRoutePolicySetting{}
@@ -180,17 +219,54 @@ func (m *vxlanManager) CompleteDeferredWork() error {
 				ProviderAddress:             existingPolSettings.ProviderAddress,
 				DestinationPrefix:           existingPolSettings.DestinationPrefix,
 			}
-			logCxt := logrus.WithField("route", existingPolSettings)
-			if netPolsToAdd.Contains(filteredPolSettings) {
-				logCxt.Debug("Found route that we still want")
-				netPolsToAdd.Discard(filteredPolSettings)
-			} else {
-				logCxt.Debug("Found route that we no longer want")
-				netPolsToRemove.Add(existingPolSettings)
+			if _, ok := existingNetPols[filteredPolSettings]; !ok {
+				existingOrder = append(existingOrder, filteredPolSettings)
 			}
+			existingNetPols[filteredPolSettings] = append(existingNetPols[filteredPolSettings], existingPolSettings)
+		}
+	}
+
+	// Only count drift once we've programmed the routes at least once; before that, any
+	// difference is just left over from a previous run.
+	countDrift := func(driftType string, route hcn.RemoteSubnetRoutePolicySetting) {
+		if !m.doneFirstApply {
+			return
 		}
+		logrus.WithFields(logrus.Fields{"type": driftType, "route": route}).Warn(
+			"Detected VXLAN route drift in HNS, repairing")
+		countVXLANRouteDrift.WithLabelValues(driftType).Inc()
 	}
 
+	for _, filteredPolSettings := range existingOrder {
+		existing := existingNetPols[filteredPolSettings]
+		logCxt := logrus.WithField("route", filteredPolSettings)
+		if !netPolsToAdd.Contains(filteredPolSettings) {
+			logCxt.Debug("Found route that we no longer want")
+			if !m.programmedNetPols.Contains(filteredPolSettings) {
+				countDrift("unexpected", filteredPolSettings)
+			}
+			netPolsToRemove = append(netPolsToRemove, existing...)
+			continue
+		}
+		if len(existing) == 1 {
+			logCxt.Debug("Found route that we still want")
+			netPolsToAdd.Discard(filteredPolSettings)
+			continue
+		}
+		// Duplicated route.  Depending on the version of HNS, removing a route may remove one
+		// copy or all of them so we remove one copy per duplicate and then add it back.
+		logCxt.WithField("copies", len(existing)).Debug("Found duplicated route")
+		countDrift("duplicate", filteredPolSettings)
+		netPolsToRemove = append(netPolsToRemove, existing...)
+	}
+	netPolsToAdd.Iter(func(item interface{}) error {
+		route := item.(hcn.RemoteSubnetRoutePolicySetting)
+		if m.programmedNetPols.Contains(route) && existingNetPols[route] == nil {
+			countDrift("missing", route)
+		}
+		return nil
+	})
+
 	wrapPolSettings := func(polSettings hcn.RemoteSubnetRoutePolicySetting) *hcn.PolicyNetworkRequest {
 		polJSON, err := json.Marshal(polSettings)
 		if err != nil {
@@ -208,19 +284,19 @@ func (m *vxlanManager) CompleteDeferredWork() error {
 	}
 
 	// Remove routes that are no longer needed.
-	netPolsToRemove.Iter(func(item interface{}) error {
-		polSetting := item.(hcn.RemoteSubnetRoutePolicySetting)
+	var numFailedRemoves int
+	for _, polSetting := range netPolsToRemove {
 		polReq := wrapPolSettings(polSetting)
 		if polReq == nil {
-			return nil
+			numFailedRemoves++
+			continue
 		}
 		err = network.RemovePolicy(*polReq)
 		if err != nil {
 			logrus.WithError(err).WithField("request", polSetting).Error("Failed to remove unwanted VXLAN route policy")
-			return nil
+			numFailedRemoves++
 		}
-		return set.RemoveItem
-	})
+	}
 
 	// Add new routes.
 	netPolsToAdd.Iter(func(item interface{}) error {
@@ -237,14 +313,17 @@ func (m *vxlanManager) CompleteDeferredWork() error {
 	})
 
 	// Wrap up and check for errors.
-	if netPolsToAdd.Len() == 0 && netPolsToRemove.Len() == 0 {
+	if netPolsToAdd.Len() == 0 && numFailedRemoves == 0 {
 		logrus.Info("All VXLAN route updates succeeded.")
+		m.programmedNetPols = desiredNetPols
+		m.doneFirstApply = true
 		m.dirty = false
 	} else {
 		logrus.WithFields(logrus.Fields{
 			"numFailedAdds":    netPolsToAdd.Len(),
-			"numFailedRemoves": netPolsToRemove.Len(),
+			"numFailedRemoves": numFailedRemoves,
 		}).Error("Not all VXLAN route updates succeeded.")
+		countVXLANRouteRepairFailures.Inc()
 		return ErrUpdatesFailed
 	}
 
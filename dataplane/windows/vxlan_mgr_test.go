@@ -195,6 +195,44 @@ var _ = Describe("VXLAN manager tests", func() {
 							Expect(mgr.dirty).To(BeFalse())
 						})
 
+						Describe("after the route is removed out-of-band and a route check", func() {
+							BeforeEach(func() {
+								dataplane.networks[0].Policies = dataplane.networks[0].Policies[:2]
+								mgr.QueueRouteCheck()
+								Expect(mgr.dirty).To(BeTrue())
+								Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+							})
+
+							itShouldApplyTheRoute()
+						})
+
+						Describe("after the route is duplicated out-of-band and a route check", func() {
+							BeforeEach(func() {
+								dataplane.networks[0].Policies = append(dataplane.networks[0].Policies,
+									dataplane.networks[0].Policies[2])
+								mgr.QueueRouteCheck()
+								Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+							})
+
+							itShouldApplyTheRoute()
+						})
+
+						Describe("after an unexpected route is added out-of-band and a route check", func() {
+							BeforeEach(func() {
+								polJSON, err := json.Marshal(hcn.RemoteSubnetRoutePolicySetting{
+									IsolationId:       4096,
+									DestinationPrefix: "10.0.1.0/26",
+								})
+								Expect(err).NotTo(HaveOccurred())
+								dataplane.networks[0].Policies = append(dataplane.networks[0].Policies,
+									hcn.NetworkPolicy{Type: hcn.RemoteSubnetRoute, Settings: polJSON})
+								mgr.QueueRouteCheck()
+								Expect(mgr.CompleteDeferredWork()).NotTo(HaveOccurred())
+							})
+
+							itShouldApplyTheRoute()
+						})
+
 						Describe("after removing the route and calling CompleteDeferredWork", func() {
 							BeforeEach(func() {
 								mgr.OnUpdate(&proto.RouteRemove{
@@ -15,11 +15,14 @@
 package windataplane
 
 import (
+	"reflect"
 	"regexp"
 	"time"
 
+	"github.com/projectcalico/felix/config"
 	"github.com/projectcalico/felix/dataplane/windows/hcn"
 
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/projectcalico/felix/dataplane/windows/hns"
@@ -59,6 +62,26 @@ type Config struct {
 	VXLANEnabled bool
 	VXLANID      int
 	VXLANPort    int
+	// VXLANRouteCheckInterval is the interval at which the VXLAN routes in HNS are checked and,
+	// if they have drifted, repaired.  Zero disables the periodic check.
+	VXLANRouteCheckInterval time.Duration
+
+	RuleCompactionEnabled bool
+	// VFPMetricsEnabled enables export of per-workload and per-rule counters from VFP.
+	VFPMetricsEnabled bool
+
+	// DNSExceptions and MetadataExceptions are destinations that workloads may always reach.
+	DNSExceptions      []config.ProtoPort
+	MetadataExceptions []config.ProtoPort
+
+	FailsafeInboundHostPorts  []config.ProtoPort
+	FailsafeOutboundHostPorts []config.ProtoPort
+
+	// PostInSyncCallback, if set, is called after the first update to the dataplane.
+	PostInSyncCallback func()
+	// DegradedRulesCallback, if set, is called with the policies and profiles that have rules
+	// that aren't supported on Windows, whenever they change.
+	DegradedRulesCallback func(issues []string)
 }
 
 // winDataplane implements an in-process Felix dataplane driver capable of applying network policy
@@ -99,6 +122,7 @@ type WindowsDataplane struct {
 	// stores all of the managers which will be processing  the various updates from felix.
 	allManagers []Manager
 	endpointMgr *endpointManager
+	vxlanMgr    *vxlanManager
 	// each IPSets manages a whole "plane" of IP sets, i.e. all the IPv4 sets, or all the IPv6
 	// IP sets.
 	ipSets []*ipsets.IPSets
@@ -119,6 +143,8 @@ type WindowsDataplane struct {
 	// a simple throttle to control how frequently the driver is allowed to apply updates
 	// to the dataplane.
 	applyThrottle *throttle.Throttle
+	// reportedDegraded holds the issues that we last passed to the DegradedRulesCallback.
+	reportedDegraded []string
 	// config provides a way for felix to provide some additional configuration options
 	// to the dataplane driver. This isn't really used currently, but will be in the future.
 	config Config
@@ -166,6 +192,8 @@ func NewWinDataplaneDriver(hns hns.API, config Config) *WindowsDataplane {
 	}
 
 	dp.applyThrottle.Refill() // Allow the first apply() immediately.
+	// The Windows dataplane only supports IPv4 and has no WireGuard or policy sync support.
+	dp.fromDataplane <- &proto.DataplaneCapabilities{Vxlan: true}
 
 	dp.ipSets = append(dp.ipSets, ipSetsV4)
 
@@ -174,20 +202,47 @@ func NewWinDataplaneDriver(hns hns.API, config Config) *WindowsDataplane {
 		ipsc = append(ipsc, i)
 	}
 	dp.policySets = policysets.NewPolicySets(hns, ipsc, policysets.FileReader(policysets.StaticFileName))
+	if config.RuleCompactionEnabled {
+		dp.policySets.EnableRuleCompaction()
+	}
+	var exceptions []policysets.PlatformException
+	for _, e := range config.DNSExceptions {
+		exceptions = append(exceptions, policysets.PlatformException{Name: "dns", Protocol: e.Protocol, CIDR: e.Net, Port: e.Port})
+	}
+	for _, e := range config.MetadataExceptions {
+		exceptions = append(exceptions, policysets.PlatformException{Name: "metadata", Protocol: e.Protocol, CIDR: e.Net, Port: e.Port})
+	}
+	if err := dp.policySets.AddPlatformExceptions(exceptions); err != nil {
+		// Same as for an invalid static rules file.
+		log.WithError(err).Panic("Invalid platform exception.")
+	}
 
 	dp.RegisterManager(newIPSetsManager(ipSetsV4))
 	dp.RegisterManager(newPolicyManager(dp.policySets))
 	dp.endpointMgr = newEndpointManager(hns, dp.policySets)
+	if config.VFPMetricsEnabled {
+		log.Info("VFP metrics enabled, exporting workload and policy rule counters")
+		dp.endpointMgr.vfpMetrics = newVFPMetricsCollector(newVFPCtrl())
+		prometheus.MustRegister(dp.endpointMgr.vfpMetrics)
+	}
 	dp.RegisterManager(dp.endpointMgr)
+	dp.RegisterManager(newHostEndpointManager(
+		hns,
+		dp.endpointMgr.hnsNetworkRegexp,
+		dp.policySets,
+		config.FailsafeInboundHostPorts,
+		config.FailsafeOutboundHostPorts,
+	))
 	if config.VXLANEnabled {
 		log.Info("VXLAN enabled, starting the VXLAN manager")
-		dp.RegisterManager(newVXLANManager(
+		dp.vxlanMgr = newVXLANManager(
 			hcn.API{},
 			config.Hostname,
 			regexp.MustCompile(defaultNetworkName), // FIXME Hard-coded regex
 			config.VXLANID,
 			config.VXLANPort,
-		))
+		)
+		dp.RegisterManager(dp.vxlanMgr)
 	} else {
 		log.Info("VXLAN disabled, not starting the VXLAN manager")
 	}
@@ -243,6 +298,12 @@ func (d *WindowsDataplane) loopUpdatingDataplane() {
 
 	datastoreInSync := false
 
+	var vxlanRouteCheckC <-chan time.Time
+	if d.vxlanMgr != nil && d.config.VXLANRouteCheckInterval > 0 {
+		vxlanRouteCheckC = jitter.NewTicker(
+			d.config.VXLANRouteCheckInterval, d.config.VXLANRouteCheckInterval/10).Channel()
+	}
+
 	// function to pass messages to the managers for processing
 	processMsgFromCalcGraph := func(msg interface{}) {
 		log.WithField("msg", proto.MsgStringer{Msg: msg}).Infof(
@@ -283,6 +344,9 @@ func (d *WindowsDataplane) loopUpdatingDataplane() {
 			d.applyThrottle.Refill()
 		case <-healthTicks:
 			d.reportHealth()
+		case <-vxlanRouteCheckC:
+			d.vxlanMgr.QueueRouteCheck()
+			d.dataplaneNeedsSync = true
 		case <-d.reschedC:
 			log.Debug("Reschedule kick received")
 			d.dataplaneNeedsSync = true
@@ -301,6 +365,7 @@ func (d *WindowsDataplane) loopUpdatingDataplane() {
 
 				// Actually apply the changes to the dataplane.
 				d.apply()
+				d.reportDegradedRules()
 
 				applyTime := time.Since(applyStart)
 				log.WithField("msecToApply", applyTime.Seconds()*1000.0).Info(
@@ -311,6 +376,9 @@ func (d *WindowsDataplane) loopUpdatingDataplane() {
 						"secsSinceStart", time.Since(processStartTime).Seconds(),
 					).Info("Completed first update to dataplane.")
 					d.doneFirstApply = true
+					if d.config.PostInSyncCallback != nil {
+						d.config.PostInSyncCallback()
+					}
 				}
 
 				d.reportHealth()
@@ -324,6 +392,20 @@ func (d *WindowsDataplane) loopUpdatingDataplane() {
 	}
 }
 
+// reportDegradedRules passes the policy sets that have unsupported rules to the
+// DegradedRulesCallback if they have changed since the last call.
+func (d *WindowsDataplane) reportDegradedRules() {
+	if d.config.DegradedRulesCallback == nil {
+		return
+	}
+	issues := d.policySets.DegradedPolicySets()
+	if d.reportedDegraded != nil && reflect.DeepEqual(issues, d.reportedDegraded) {
+		return
+	}
+	d.reportedDegraded = issues
+	d.config.DegradedRulesCallback(issues)
+}
+
 // Applies any pending changes to the dataplane by giving each of the managers a chance to
 // complete their deffered work. If the operation fails, then this will also set up a
 // rescheduling kick so that the apply can be reattempted.
@@ -0,0 +1,284 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package debugserver implements Felix's optional debug HTTP server.  It serves:
+//
+//   /debug/pprof/...      the standard Go pprof handlers, for on-demand CPU/heap/etc. profiles.
+//   /debug/goroutines     a full dump of all goroutine stacks.
+//   /debug/runtime        Go runtime statistics, as JSON.
+//   /debug/state/         the list of registered dataplane state dumps.
+//   /debug/state/<name>   a dump of the named piece of dataplane state (iptables chains, IP sets,
+//                         routes, BPF maps, ...).
+//   /debug/<other>        handlers registered by other components with RegisterHandler.
+//
+// The server is disabled by default.  It can listen on a TCP port (optionally restricted to an
+// allowlist of source CIDRs) or on a unix socket, which is protected by filesystem permissions.
+// Felix's other debug triggers, such as the heap and CPU profile dumps, register handlers here
+// rather than opening ports of their own.
+package debugserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	rtpprof "runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/buildinfo"
+)
+
+const restartDelay = 1 * time.Second
+
+type Config struct {
+	// Host and Port are used to listen on TCP if SocketPath is empty.
+	Host string
+	Port int
+	// SocketPath, if set, makes the server listen on a unix socket instead of TCP.
+	SocketPath string
+	// AllowedCIDRs, if non-empty, restricts TCP clients to those with a source IP in one of the
+	// CIDRs.
+	AllowedCIDRs []string
+}
+
+// StateDumper writes a human-readable dump of some piece of state to w.
+type StateDumper func(w io.Writer) error
+
+var (
+	dumpersLock sync.Mutex
+	dumpers     = map[string]StateDumper{}
+)
+
+// RegisterStateDumper makes a state dump available at /debug/state/<name>.  Registering a
+// second dumper with the same name replaces the first.
+func RegisterStateDumper(name string, dumper StateDumper) {
+	dumpersLock.Lock()
+	defer dumpersLock.Unlock()
+	dumpers[name] = dumper
+}
+
+var (
+	handlersLock sync.Mutex
+	handlers     = map[string]http.Handler{}
+)
+
+// RegisterHandler serves requests for paths under /debug/<prefix>/ with the given handler.  It
+// may be called before or after the server is started.
+func RegisterHandler(prefix string, handler http.Handler) {
+	handlersLock.Lock()
+	defer handlersLock.Unlock()
+	handlers[prefix] = handler
+}
+
+// serveRegistered dispatches requests that don't match one of the built-in handlers to the
+// handlers registered with RegisterHandler.
+func serveRegistered(w http.ResponseWriter, req *http.Request) {
+	prefix := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/debug/"), "/", 2)[0]
+	handlersLock.Lock()
+	handler := handlers[prefix]
+	handlersLock.Unlock()
+	if handler == nil {
+		http.NotFound(w, req)
+		return
+	}
+	handler.ServeHTTP(w, req)
+}
+
+func lookUpStateDumper(name string) StateDumper {
+	dumpersLock.Lock()
+	defer dumpersLock.Unlock()
+	return dumpers[name]
+}
+
+func stateDumperNames() []string {
+	dumpersLock.Lock()
+	defer dumpersLock.Unlock()
+	var names []string
+	for name := range dumpers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Serve runs the debug server.  It never returns; if the server fails, it is restarted after a
+// short delay.
+func Serve(config Config) {
+	handler, err := newHandler(config)
+	if err != nil {
+		log.WithError(err).Error("Invalid debug server configuration, not starting debug server.")
+		return
+	}
+	for {
+		err := listenAndServe(config, handler)
+		log.WithError(err).Error("Debug server failed, trying to restart it...")
+		time.Sleep(restartDelay)
+	}
+}
+
+func listenAndServe(config Config, handler http.Handler) error {
+	var l net.Listener
+	var err error
+	if config.SocketPath != "" {
+		// Clean up any socket left behind by a previous instance.
+		_ = os.Remove(config.SocketPath)
+		l, err = net.Listen("unix", config.SocketPath)
+		if err != nil {
+			return err
+		}
+		if err := os.Chmod(config.SocketPath, 0600); err != nil {
+			l.Close()
+			return err
+		}
+	} else {
+		l, err = net.Listen("tcp", net.JoinHostPort(config.Host, strconv.Itoa(config.Port)))
+		if err != nil {
+			return err
+		}
+	}
+	log.WithField("addr", l.Addr()).Warn("Debug server listening.")
+	return http.Serve(l, handler)
+}
+
+func newHandler(config Config) (http.Handler, error) {
+	var allowedNets []*net.IPNet
+	for _, cidr := range config.AllowedCIDRs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		allowedNets = append(allowedNets, ipNet)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/goroutines", serveGoroutines)
+	mux.HandleFunc("/debug/runtime", serveRuntimeStats)
+	mux.HandleFunc("/debug/state/", serveState)
+	mux.HandleFunc("/debug/", serveRegistered)
+
+	if config.SocketPath != "" || len(allowedNets) == 0 {
+		return mux, nil
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !remoteAddrAllowed(req.RemoteAddr, allowedNets) {
+			log.WithField("remoteAddr", req.RemoteAddr).Warn("Rejecting debug request from disallowed address.")
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, req)
+	}), nil
+}
+
+func remoteAddrAllowed(remoteAddr string, allowedNets []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range allowedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func serveGoroutines(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err := rtpprof.Lookup("goroutine").WriteTo(w, 2); err != nil {
+		log.WithError(err).Warn("Failed to write goroutine dump.")
+	}
+}
+
+type runtimeStats struct {
+	Version      string    `json:"version"`
+	GitRevision  string    `json:"gitRevision"`
+	GoVersion    string    `json:"goVersion"`
+	NumCPU       int       `json:"numCPU"`
+	GOMAXPROCS   int       `json:"gomaxprocs"`
+	NumGoroutine int       `json:"numGoroutine"`
+	HeapAlloc    uint64    `json:"heapAllocBytes"`
+	HeapInuse    uint64    `json:"heapInuseBytes"`
+	HeapObjects  uint64    `json:"heapObjects"`
+	Sys          uint64    `json:"sysBytes"`
+	NumGC        uint32    `json:"numGC"`
+	PauseTotal   string    `json:"gcPauseTotal"`
+	LastGC       time.Time `json:"lastGC"`
+}
+
+func serveRuntimeStats(w http.ResponseWriter, req *http.Request) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	stats := runtimeStats{
+		Version:      buildinfo.GitVersion,
+		GitRevision:  buildinfo.GitRevision,
+		GoVersion:    runtime.Version(),
+		NumCPU:       runtime.NumCPU(),
+		GOMAXPROCS:   runtime.GOMAXPROCS(0),
+		NumGoroutine: runtime.NumGoroutine(),
+		HeapAlloc:    m.HeapAlloc,
+		HeapInuse:    m.HeapInuse,
+		HeapObjects:  m.HeapObjects,
+		Sys:          m.Sys,
+		NumGC:        m.NumGC,
+		PauseTotal:   time.Duration(m.PauseTotalNs).String(),
+		LastGC:       time.Unix(0, int64(m.LastGC)),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(stats); err != nil {
+		log.WithError(err).Warn("Failed to write runtime stats.")
+	}
+}
+
+func serveState(w http.ResponseWriter, req *http.Request) {
+	name := strings.TrimPrefix(req.URL.Path, "/debug/state/")
+	if name == "" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, n := range stateDumperNames() {
+			fmt.Fprintln(w, n)
+		}
+		return
+	}
+	dumper := lookUpStateDumper(name)
+	if dumper == nil {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err := dumper(w); err != nil {
+		log.WithError(err).WithField("name", name).Warn("Failed to dump state.")
+		// We may already have written part of the response so we can't change the status code.
+		fmt.Fprintf(w, "\nERROR: %v\n", err)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debugserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Debug server", func() {
+	var handler http.Handler
+
+	get := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	BeforeEach(func() {
+		dumpersLock.Lock()
+		dumpers = map[string]StateDumper{}
+		dumpersLock.Unlock()
+
+		RegisterStateDumper("iptables", func(w io.Writer) error {
+			_, err := fmt.Fprintln(w, "*filter")
+			return err
+		})
+		RegisterStateDumper("broken", func(w io.Writer) error {
+			_, _ = fmt.Fprintln(w, "partial")
+			return errors.New("dump failed")
+		})
+
+		var err error
+		handler, err = newHandler(Config{})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should list the state dumps", func() {
+		rec := get("/debug/state/", "127.0.0.1:1234")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(Equal("broken\niptables\n"))
+	})
+
+	It("should serve a state dump", func() {
+		rec := get("/debug/state/iptables", "127.0.0.1:1234")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(Equal("*filter\n"))
+	})
+
+	It("should report a failed state dump", func() {
+		rec := get("/debug/state/broken", "127.0.0.1:1234")
+		Expect(rec.Body.String()).To(Equal("partial\n\nERROR: dump failed\n"))
+	})
+
+	It("should 404 an unknown state dump", func() {
+		rec := get("/debug/state/unknown", "127.0.0.1:1234")
+		Expect(rec.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("should serve runtime stats", func() {
+		rec := get("/debug/runtime", "127.0.0.1:1234")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		var stats map[string]interface{}
+		Expect(json.Unmarshal(rec.Body.Bytes(), &stats)).To(Succeed())
+		Expect(stats["numGoroutine"]).To(BeNumerically(">", 0))
+		Expect(stats).To(HaveKey("heapAllocBytes"))
+	})
+
+	It("should serve goroutine dumps", func() {
+		rec := get("/debug/goroutines", "127.0.0.1:1234")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(ContainSubstring("goroutine "))
+	})
+
+	It("should dispatch to registered handlers", func() {
+		RegisterHandler("widgets", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			_, _ = fmt.Fprint(w, "widget "+req.URL.Path)
+		}))
+		rec := get("/debug/widgets/foo", "127.0.0.1:1234")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(Equal("widget /debug/widgets/foo"))
+
+		rec = get("/debug/gadgets/foo", "127.0.0.1:1234")
+		Expect(rec.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("should serve the pprof index", func() {
+		rec := get("/debug/pprof/", "127.0.0.1:1234")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(ContainSubstring("heap"))
+	})
+
+	Describe("with an allowlist", func() {
+		BeforeEach(func() {
+			var err error
+			handler, err = newHandler(Config{AllowedCIDRs: []string{"10.0.0.0/8", "fd00::/8"}})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should allow requests from allowed IPv4 addresses", func() {
+			Expect(get("/debug/state/", "10.1.2.3:1234").Code).To(Equal(http.StatusOK))
+		})
+
+		It("should allow requests from allowed IPv6 addresses", func() {
+			Expect(get("/debug/state/", "[fd00::1]:1234").Code).To(Equal(http.StatusOK))
+		})
+
+		It("should reject other requests", func() {
+			Expect(get("/debug/state/", "127.0.0.1:1234").Code).To(Equal(http.StatusForbidden))
+			Expect(get("/debug/state/", "garbage").Code).To(Equal(http.StatusForbidden))
+		})
+	})
+
+	It("should reject an invalid allowlist", func() {
+		_, err := newHandler(Config{AllowedCIDRs: []string{"10.0.0.0/33"}})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,257 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnscache
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+	"unsafe"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/bpf"
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	dnsPort = 53
+	// snapLen is the maximum number of bytes of each DNS response that we capture.  UDP DNS
+	// responses are limited to 4096 bytes by EDNS0 in practice.
+	snapLen = 4096
+
+	captureRestartDelay = 5 * time.Second
+	expiryInterval      = time.Minute
+)
+
+var countResponsesParsed = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "felix_dns_responses",
+	Help: "Number of captured DNS responses, by result of parsing them.",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(countResponsesParsed)
+}
+
+// dnsResponseFilter is a classic BPF filter that accepts UDP packets from port 53.  It is
+// attached to an AF_PACKET/SOCK_DGRAM socket, so the packet data starts at the IP header.
+// Fragments and IPv6 packets with extension headers are not matched.
+var dnsResponseFilter = []bpf.Instruction{
+	/* 0 */ bpf.LoadAbsolute{Off: 0, Size: 1},
+	/* 1 */ bpf.ALUOpConstant{Op: bpf.ALUOpShiftRight, Val: 4},
+	/* 2 */ bpf.JumpIf{Cond: bpf.JumpEqual, Val: 4, SkipFalse: 7},
+	// IPv4.
+	/* 3 */ bpf.LoadAbsolute{Off: 9, Size: 1},
+	/* 4 */ bpf.JumpIf{Cond: bpf.JumpEqual, Val: unix.IPPROTO_UDP, SkipFalse: 11},
+	/* 5 */ bpf.LoadAbsolute{Off: 6, Size: 2},
+	/* 6 */ bpf.JumpIf{Cond: bpf.JumpBitsSet, Val: 0x1fff, SkipTrue: 9},
+	/* 7 */ bpf.LoadMemShift{Off: 0},
+	/* 8 */ bpf.LoadIndirect{Off: 0, Size: 2},
+	/* 9 */ bpf.JumpIf{Cond: bpf.JumpEqual, Val: dnsPort, SkipTrue: 5, SkipFalse: 6},
+	// IPv6.
+	/* 10 */ bpf.JumpIf{Cond: bpf.JumpEqual, Val: 6, SkipFalse: 5},
+	/* 11 */ bpf.LoadAbsolute{Off: 6, Size: 1},
+	/* 12 */ bpf.JumpIf{Cond: bpf.JumpEqual, Val: unix.IPPROTO_UDP, SkipFalse: 3},
+	/* 13 */ bpf.LoadAbsolute{Off: 40, Size: 2},
+	/* 14 */ bpf.JumpIf{Cond: bpf.JumpEqual, Val: dnsPort, SkipFalse: 1},
+	/* 15 */ bpf.RetConstant{Val: snapLen},
+	/* 16 */ bpf.RetConstant{Val: 0},
+}
+
+// ObserveFunc is called with each address that a DNS response resolves a domain name to.
+type ObserveFunc func(domain string, ip net.IP, ttl time.Duration)
+
+// Start starts capturing DNS responses on all interfaces and recording them in the cache.
+func Start(cache *Cache) {
+	go cache.Run(expiryInterval)
+	go func() {
+		for {
+			err := capture(dnsResponseFilter, func(pkt []byte, from *unix.SockaddrLinklayer) {
+				if from.Pkttype == unix.PACKET_OUTGOING {
+					// We'll see the same response as it arrives on another interface; only
+					// look at incoming packets to avoid double the work.
+					return
+				}
+				payload, err := udpPayload(pkt)
+				if err != nil {
+					countResponsesParsed.WithLabelValues("bad-packet").Inc()
+					return
+				}
+				observeResponse(payload, cache.Observe)
+			})
+			log.WithError(err).Error("DNS response capture failed, will retry.")
+			time.Sleep(captureRestartDelay)
+		}
+	}()
+}
+
+// capture captures the packets that the given filter accepts on all interfaces and passes them,
+// starting at the IP header, to handle.
+func capture(filterInsns []bpf.Instruction, handle func(pkt []byte, from *unix.SockaddrLinklayer)) error {
+	raw, err := bpf.Assemble(filterInsns)
+	if err != nil {
+		return err
+	}
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM, int(htons(unix.ETH_P_ALL)))
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	filter := make([]unix.SockFilter, len(raw))
+	for i, ins := range raw {
+		filter[i] = unix.SockFilter{Code: ins.Op, Jt: ins.Jt, Jf: ins.Jf, K: ins.K}
+	}
+	prog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if err := unix.SetsockoptSockFprog(fd, unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, &prog); err != nil {
+		return err
+	}
+	log.Info("Capturing DNS packets.")
+
+	buf := make([]byte, snapLen)
+	for {
+		n, from, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return err
+		}
+		ll, ok := from.(*unix.SockaddrLinklayer)
+		if !ok {
+			continue
+		}
+		handle(buf[:n], ll)
+	}
+}
+
+// observeResponse parses the DNS response, passing its addresses to observe, and counts the
+// result.
+func observeResponse(payload []byte, observe ObserveFunc) {
+	if err := parseResponse(payload, observe); err != nil {
+		log.WithError(err).Debug("Failed to parse DNS response.")
+		countResponsesParsed.WithLabelValues("bad-dns").Inc()
+		return
+	}
+	countResponsesParsed.WithLabelValues("ok").Inc()
+}
+
+// udpPacket is a captured UDP packet.
+type udpPacket struct {
+	src, dst         net.IP
+	srcPort, dstPort uint16
+	payload          []byte
+}
+
+// parseUDP parses the given IP packet, which must be UDP.
+func parseUDP(pkt []byte) (*udpPacket, error) {
+	if len(pkt) < 1 {
+		return nil, errors.New("empty packet")
+	}
+	var u udpPacket
+	var l4 []byte
+	switch pkt[0] >> 4 {
+	case 4:
+		ihl := int(pkt[0]&0xf) * 4
+		if ihl < 20 || len(pkt) < ihl {
+			return nil, errors.New("bad IPv4 header")
+		}
+		u.src = net.IP(pkt[12:16])
+		u.dst = net.IP(pkt[16:20])
+		l4 = pkt[ihl:]
+	case 6:
+		if len(pkt) < 40 {
+			return nil, errors.New("bad IPv6 header")
+		}
+		u.src = net.IP(pkt[8:24])
+		u.dst = net.IP(pkt[24:40])
+		l4 = pkt[40:]
+	default:
+		return nil, errors.New("unknown IP version")
+	}
+	if len(l4) < 8 {
+		return nil, errors.New("truncated UDP header")
+	}
+	u.srcPort = binary.BigEndian.Uint16(l4[0:2])
+	u.dstPort = binary.BigEndian.Uint16(l4[2:4])
+	u.payload = l4[8:]
+	return &u, nil
+}
+
+// udpPayload returns the UDP payload of the given IP packet.
+func udpPayload(pkt []byte) ([]byte, error) {
+	u, err := parseUDP(pkt)
+	if err != nil {
+		return nil, err
+	}
+	return u.payload, nil
+}
+
+// parseResponse parses a DNS response and calls observe for each A and AAAA record.  Each
+// address is attributed to the name that the record is for and to the name that was queried,
+// which differ if the response includes a CNAME chain.
+func parseResponse(msg []byte, observe ObserveFunc) error {
+	var p dnsmessage.Parser
+	hdr, err := p.Start(msg)
+	if err != nil {
+		return err
+	}
+	if !hdr.Response || hdr.RCode != dnsmessage.RCodeSuccess {
+		return nil
+	}
+	questions, err := p.AllQuestions()
+	if err != nil {
+		return err
+	}
+	for {
+		rh, err := p.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var ip net.IP
+		switch rh.Type {
+		case dnsmessage.TypeA:
+			r, err := p.AResource()
+			if err != nil {
+				return err
+			}
+			ip = net.IP(r.A[:])
+		case dnsmessage.TypeAAAA:
+			r, err := p.AAAAResource()
+			if err != nil {
+				return err
+			}
+			ip = net.IP(r.AAAA[:])
+		default:
+			if err := p.SkipAnswer(); err != nil {
+				return err
+			}
+			continue
+		}
+		ttl := time.Duration(rh.TTL) * time.Second
+		observe(rh.Name.String(), ip, ttl)
+		for _, q := range questions {
+			if q.Name != rh.Name {
+				observe(q.Name.String(), ip, ttl)
+			}
+		}
+	}
+}
+
+func htons(v uint16) uint16 {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	return *(*uint16)(unsafe.Pointer(&b[0]))
+}
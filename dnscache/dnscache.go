@@ -0,0 +1,174 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dnscache watches DNS responses that traverse the node and maintains a cache mapping
+// IP addresses to the domain names that recently resolved to them.  The cache is used to
+// annotate denied-flow reports with domain names, and can be dumped via the debug server, to
+// help with debugging policy that blocks traffic to external services.
+//
+// The same capture, along with a Resolver that looks domains up itself, feeds the IP sets behind
+// policy rules that match on destination domains.
+package dnscache
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// minRetention is the minimum time that we remember a mapping for, even if the DNS TTL is
+	// shorter.  Clients often keep using an IP after its TTL has expired.
+	minRetention = 5 * time.Minute
+	// maxRetention caps the time that we remember a mapping for.
+	maxRetention = 24 * time.Hour
+	// maxEntries bounds the size of the cache; once it is full, new IPs are ignored until
+	// existing entries expire.
+	maxEntries = 100000
+)
+
+var gaugeCacheEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "felix_dns_cache_entries",
+	Help: "Number of IPs in the DNS cache.",
+})
+
+func init() {
+	prometheus.MustRegister(gaugeCacheEntries)
+}
+
+// Cache maps IPs to the domain names that have recently resolved to them.  It is safe for
+// concurrent use.
+type Cache struct {
+	lock    sync.Mutex
+	entries map[string]map[string]time.Time
+
+	// Shim for testing.
+	now func() time.Time
+}
+
+func New() *Cache {
+	return &Cache{
+		entries: map[string]map[string]time.Time{},
+		now:     time.Now,
+	}
+}
+
+// Observe records that the domain resolved to the IP with the given TTL.
+func (c *Cache) Observe(domain string, ip net.IP, ttl time.Duration) {
+	if ttl < minRetention {
+		ttl = minRetention
+	} else if ttl > maxRetention {
+		ttl = maxRetention
+	}
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	ipStr := ip.String()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := c.now()
+	domains := c.entries[ipStr]
+	if domains == nil {
+		if len(c.entries) >= maxEntries {
+			c.expireLocked(now)
+			if len(c.entries) >= maxEntries {
+				return
+			}
+		}
+		domains = map[string]time.Time{}
+		c.entries[ipStr] = domains
+	}
+	if expiry := now.Add(ttl); expiry.After(domains[domain]) {
+		domains[domain] = expiry
+	}
+	gaugeCacheEntries.Set(float64(len(c.entries)))
+}
+
+// Lookup returns the domain names that have recently resolved to the IP, in sorted order.
+func (c *Cache) Lookup(ip string) []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := c.now()
+	var domains []string
+	for d, expiry := range c.entries[ip] {
+		if now.Before(expiry) {
+			domains = append(domains, d)
+		}
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// Expire removes expired mappings.
+func (c *Cache) Expire() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.expireLocked(c.now())
+}
+
+func (c *Cache) expireLocked(now time.Time) {
+	for ip, domains := range c.entries {
+		for d, expiry := range domains {
+			if !now.Before(expiry) {
+				delete(domains, d)
+			}
+		}
+		if len(domains) == 0 {
+			delete(c.entries, ip)
+		}
+	}
+	gaugeCacheEntries.Set(float64(len(c.entries)))
+}
+
+// Dump writes the contents of the cache to w, one IP per line.  It has the signature of a
+// debugserver.StateDumper.
+func (c *Cache) Dump(w io.Writer) error {
+	c.lock.Lock()
+	now := c.now()
+	var lines []string
+	for ip, domains := range c.entries {
+		var parts []string
+		for d, expiry := range domains {
+			if now.Before(expiry) {
+				parts = append(parts, fmt.Sprintf("%s (%v)", d, expiry.Sub(now).Round(time.Second)))
+			}
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		sort.Strings(parts)
+		lines = append(lines, ip+" "+strings.Join(parts, ", "))
+	}
+	c.lock.Unlock()
+
+	sort.Strings(lines)
+	for _, l := range lines {
+		if _, err := fmt.Fprintln(w, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run periodically expires old entries.  It never returns.
+func (c *Cache) Run(expiryInterval time.Duration) {
+	for range time.NewTicker(expiryInterval).C {
+		c.Expire()
+	}
+}
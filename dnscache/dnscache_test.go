@@ -0,0 +1,264 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnscache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/bpf"
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/sys/unix"
+)
+
+var _ = Describe("Cache", func() {
+	var (
+		cache *Cache
+		now   time.Time
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+		cache = New()
+		cache.now = func() time.Time { return now }
+	})
+
+	It("should map IPs to domains", func() {
+		cache.Observe("www.example.com.", net.ParseIP("10.0.0.1"), time.Hour)
+		cache.Observe("Example.com", net.ParseIP("10.0.0.1"), time.Hour)
+		Expect(cache.Lookup("10.0.0.1")).To(Equal([]string{"example.com", "www.example.com"}))
+		Expect(cache.Lookup("10.0.0.2")).To(BeEmpty())
+	})
+
+	It("should keep short-TTL entries for the minimum retention time", func() {
+		cache.Observe("example.com", net.ParseIP("10.0.0.1"), time.Second)
+		now = now.Add(minRetention - time.Second)
+		Expect(cache.Lookup("10.0.0.1")).To(Equal([]string{"example.com"}))
+		now = now.Add(time.Second)
+		Expect(cache.Lookup("10.0.0.1")).To(BeEmpty())
+		cache.Expire()
+		Expect(cache.entries).To(BeEmpty())
+	})
+
+	It("should dump its contents", func() {
+		cache.Observe("example.com", net.ParseIP("10.0.0.1"), time.Hour)
+		cache.Observe("example.org", net.ParseIP("fd00::1"), 10*time.Minute)
+		var buf bytes.Buffer
+		Expect(cache.Dump(&buf)).To(Succeed())
+		Expect(buf.String()).To(Equal("10.0.0.1 example.com (1h0m0s)\nfd00::1 example.org (10m0s)\n"))
+	})
+})
+
+var _ = Describe("DNS response capture", func() {
+	buildResponse := func() []byte {
+		b := dnsmessage.NewBuilder(nil, dnsmessage.Header{Response: true, RCode: dnsmessage.RCodeSuccess})
+		Expect(b.StartQuestions()).To(Succeed())
+		Expect(b.Question(dnsmessage.Question{
+			Name:  dnsmessage.MustNewName("www.example.com."),
+			Type:  dnsmessage.TypeA,
+			Class: dnsmessage.ClassINET,
+		})).To(Succeed())
+		Expect(b.StartAnswers()).To(Succeed())
+		Expect(b.CNAMEResource(dnsmessage.ResourceHeader{
+			Name:  dnsmessage.MustNewName("www.example.com."),
+			Class: dnsmessage.ClassINET,
+			TTL:   300,
+		}, dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName("cdn.example.net.")})).To(Succeed())
+		Expect(b.AResource(dnsmessage.ResourceHeader{
+			Name:  dnsmessage.MustNewName("cdn.example.net."),
+			Class: dnsmessage.ClassINET,
+			TTL:   60,
+		}, dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}})).To(Succeed())
+		msg, err := b.Finish()
+		Expect(err).NotTo(HaveOccurred())
+		return msg
+	}
+
+	ipv4UDP := func(srcPort uint16, payload []byte) []byte {
+		pkt := make([]byte, 28)
+		pkt[0] = 0x45
+		pkt[9] = 17
+		binary.BigEndian.PutUint16(pkt[20:22], srcPort)
+		binary.BigEndian.PutUint16(pkt[22:24], 40000)
+		return append(pkt, payload...)
+	}
+
+	ipv6UDP := func(srcPort uint16, payload []byte) []byte {
+		pkt := make([]byte, 48)
+		pkt[0] = 0x60
+		pkt[6] = 17
+		binary.BigEndian.PutUint16(pkt[40:42], srcPort)
+		return append(pkt, payload...)
+	}
+
+	It("should attribute addresses to the queried name and the CNAME target", func() {
+		type obs struct {
+			domain string
+			ip     string
+			ttl    time.Duration
+		}
+		var observed []obs
+		err := parseResponse(buildResponse(), func(domain string, ip net.IP, ttl time.Duration) {
+			observed = append(observed, obs{domain, ip.String(), ttl})
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(observed).To(Equal([]obs{
+			{"cdn.example.net.", "192.0.2.1", time.Minute},
+			{"www.example.com.", "192.0.2.1", time.Minute},
+		}))
+	})
+
+	It("should extract the UDP payload", func() {
+		payload := []byte{1, 2, 3}
+		Expect(udpPayload(ipv4UDP(53, payload))).To(Equal(payload))
+		Expect(udpPayload(ipv6UDP(53, payload))).To(Equal(payload))
+		_, err := udpPayload([]byte{0x45, 0})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should filter for DNS responses", func() {
+		vm, err := bpf.NewVM(dnsResponseFilter)
+		Expect(err).NotTo(HaveOccurred())
+		accepted := func(pkt []byte) bool {
+			n, err := vm.Run(pkt)
+			Expect(err).NotTo(HaveOccurred())
+			return n > 0
+		}
+		Expect(accepted(ipv4UDP(53, nil))).To(BeTrue())
+		Expect(accepted(ipv4UDP(5353, nil))).To(BeFalse())
+		Expect(accepted(ipv6UDP(53, nil))).To(BeTrue())
+		Expect(accepted(ipv6UDP(123, nil))).To(BeFalse())
+
+		tcp := ipv4UDP(53, nil)
+		tcp[9] = 6
+		Expect(accepted(tcp)).To(BeFalse())
+
+		frag := ipv4UDP(53, nil)
+		binary.BigEndian.PutUint16(frag[6:8], 10)
+		Expect(accepted(frag)).To(BeFalse())
+	})
+
+	It("should filter for DNS queries and responses", func() {
+		vm, err := bpf.NewVM(dnsQueryOrResponseFilter)
+		Expect(err).NotTo(HaveOccurred())
+		accepted := func(pkt []byte) bool {
+			n, err := vm.Run(pkt)
+			Expect(err).NotTo(HaveOccurred())
+			return n > 0
+		}
+		query := ipv4UDP(40000, nil)
+		binary.BigEndian.PutUint16(query[22:24], 53)
+		Expect(accepted(query)).To(BeTrue())
+		Expect(accepted(ipv4UDP(53, nil))).To(BeTrue())
+		Expect(accepted(ipv4UDP(5353, nil))).To(BeFalse())
+
+		query6 := ipv6UDP(40000, nil)
+		binary.BigEndian.PutUint16(query6[42:44], 53)
+		Expect(accepted(query6)).To(BeTrue())
+		Expect(accepted(ipv6UDP(53, nil))).To(BeTrue())
+		Expect(accepted(ipv6UDP(123, nil))).To(BeFalse())
+
+		tcp := ipv4UDP(53, nil)
+		tcp[9] = 6
+		Expect(accepted(tcp)).To(BeFalse())
+	})
+})
+
+var _ = Describe("DNS policy query tracking", func() {
+	const (
+		podIP    = "10.65.0.2"
+		serverIP = "10.96.0.10"
+		podIface = 10
+		ethIface = 2
+	)
+	var (
+		tracker *queryTracker
+		now     time.Time
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+		_, trusted, _ := net.ParseCIDR(serverIP + "/32")
+		tracker = newQueryTracker(PolicyCaptureConfig{
+			TrustedServers:        []net.IPNet{*trusted},
+			WorkloadIfacePrefixes: []string{"cali"},
+		}, func(ifindex int) string {
+			return map[int]string{podIface: "cali1234", ethIface: "eth0"}[ifindex]
+		})
+		tracker.now = func() time.Time { return now }
+	})
+
+	packet := func(src, dst string, srcPort, dstPort, id uint16) []byte {
+		pkt := make([]byte, 30)
+		pkt[0] = 0x45
+		pkt[9] = 17
+		copy(pkt[12:16], net.ParseIP(src).To4())
+		copy(pkt[16:20], net.ParseIP(dst).To4())
+		binary.BigEndian.PutUint16(pkt[20:22], srcPort)
+		binary.BigEndian.PutUint16(pkt[22:24], dstPort)
+		binary.BigEndian.PutUint16(pkt[28:30], id)
+		return pkt
+	}
+	query := func(id uint16) []byte {
+		return packet(podIP, serverIP, 40000, 53, id)
+	}
+	response := func(id uint16) []byte {
+		return packet(serverIP, podIP, 53, 40000, id)
+	}
+
+	It("should accept the response to a workload's query as it is delivered", func() {
+		Expect(tracker.onPacket(query(1), unix.PACKET_HOST, podIface)).To(BeNil())
+		// Not as it arrives from the server.
+		Expect(tracker.onPacket(response(1), unix.PACKET_HOST, ethIface)).To(BeNil())
+		Expect(tracker.onPacket(response(1), unix.PACKET_OUTGOING, podIface)).NotTo(BeNil())
+		// Only once.
+		Expect(tracker.onPacket(response(1), unix.PACKET_OUTGOING, podIface)).To(BeNil())
+	})
+
+	It("should ignore responses without a query", func() {
+		Expect(tracker.onPacket(response(1), unix.PACKET_OUTGOING, podIface)).To(BeNil())
+	})
+
+	It("should ignore responses with the wrong ID", func() {
+		tracker.onPacket(query(1), unix.PACKET_HOST, podIface)
+		Expect(tracker.onPacket(response(2), unix.PACKET_OUTGOING, podIface)).To(BeNil())
+	})
+
+	It("should ignore responses that a workload sends", func() {
+		tracker.onPacket(query(1), unix.PACKET_HOST, podIface)
+		Expect(tracker.onPacket(response(1), unix.PACKET_HOST, podIface)).To(BeNil())
+	})
+
+	It("should ignore responses to other interfaces", func() {
+		tracker.onPacket(query(1), unix.PACKET_HOST, podIface)
+		Expect(tracker.onPacket(response(1), unix.PACKET_OUTGOING, ethIface)).To(BeNil())
+	})
+
+	It("should ignore queries to untrusted servers", func() {
+		tracker.onPacket(packet(podIP, "192.0.2.53", 40000, 53, 1), unix.PACKET_HOST, podIface)
+		Expect(tracker.onPacket(packet("192.0.2.53", podIP, 53, 40000, 1), unix.PACKET_OUTGOING, podIface)).To(BeNil())
+	})
+
+	It("should ignore responses after the query times out", func() {
+		tracker.onPacket(query(1), unix.PACKET_HOST, podIface)
+		now = now.Add(queryTimeout + time.Second)
+		Expect(tracker.onPacket(response(1), unix.PACKET_OUTGOING, podIface)).To(BeNil())
+		Expect(tracker.queries).To(BeEmpty())
+	})
+})
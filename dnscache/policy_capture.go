@@ -0,0 +1,238 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnscache
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// queryTimeout is how long we wait for the response to a DNS query.
+	queryTimeout = 10 * time.Second
+	// maxOutstandingQueries bounds the memory that queries without a response can use; once it
+	// is reached, new queries are ignored until existing ones time out.
+	maxOutstandingQueries = 10000
+)
+
+// dnsQueryOrResponseFilter is a classic BPF filter that accepts UDP packets to or from port 53.
+// As for dnsResponseFilter, the packet data starts at the IP header and fragments and IPv6
+// packets with extension headers are not matched.
+var dnsQueryOrResponseFilter = []bpf.Instruction{
+	/* 0 */ bpf.LoadAbsolute{Off: 0, Size: 1},
+	/* 1 */ bpf.ALUOpConstant{Op: bpf.ALUOpShiftRight, Val: 4},
+	/* 2 */ bpf.JumpIf{Cond: bpf.JumpEqual, Val: 4, SkipFalse: 9},
+	// IPv4.
+	/* 3 */ bpf.LoadAbsolute{Off: 9, Size: 1},
+	/* 4 */ bpf.JumpIf{Cond: bpf.JumpEqual, Val: unix.IPPROTO_UDP, SkipFalse: 15},
+	/* 5 */ bpf.LoadAbsolute{Off: 6, Size: 2},
+	/* 6 */ bpf.JumpIf{Cond: bpf.JumpBitsSet, Val: 0x1fff, SkipTrue: 13},
+	/* 7 */ bpf.LoadMemShift{Off: 0},
+	/* 8 */ bpf.LoadIndirect{Off: 0, Size: 2},
+	/* 9 */ bpf.JumpIf{Cond: bpf.JumpEqual, Val: dnsPort, SkipTrue: 9},
+	/* 10 */ bpf.LoadIndirect{Off: 2, Size: 2},
+	/* 11 */ bpf.JumpIf{Cond: bpf.JumpEqual, Val: dnsPort, SkipTrue: 7, SkipFalse: 8},
+	// IPv6.
+	/* 12 */ bpf.JumpIf{Cond: bpf.JumpEqual, Val: 6, SkipFalse: 7},
+	/* 13 */ bpf.LoadAbsolute{Off: 6, Size: 1},
+	/* 14 */ bpf.JumpIf{Cond: bpf.JumpEqual, Val: unix.IPPROTO_UDP, SkipFalse: 5},
+	/* 15 */ bpf.LoadAbsolute{Off: 40, Size: 2},
+	/* 16 */ bpf.JumpIf{Cond: bpf.JumpEqual, Val: dnsPort, SkipTrue: 2},
+	/* 17 */ bpf.LoadAbsolute{Off: 42, Size: 2},
+	/* 18 */ bpf.JumpIf{Cond: bpf.JumpEqual, Val: dnsPort, SkipFalse: 1},
+	/* 19 */ bpf.RetConstant{Val: snapLen},
+	/* 20 */ bpf.RetConstant{Val: 0},
+}
+
+// PolicyCaptureConfig configures the capture of the DNS responses that feed domain policy.
+type PolicyCaptureConfig struct {
+	// TrustedServers are the CIDRs of the DNS servers whose responses we trust.
+	TrustedServers []net.IPNet
+	// WorkloadIfacePrefixes are the name prefixes of the workload interfaces.
+	WorkloadIfacePrefixes []string
+}
+
+// StartPolicyCapture starts capturing the DNS responses that workloads receive from the trusted
+// servers and passing their addresses to observe.  Since these addresses are allowed by policy,
+// responses are only trusted if they answer a query that a workload made; see queryTracker.
+func StartPolicyCapture(config PolicyCaptureConfig, observe ObserveFunc) {
+	if len(config.TrustedServers) == 0 {
+		log.Warn("No trusted DNS servers configured, destination domain rules will only match " +
+			"the addresses that Felix looks up itself.")
+		return
+	}
+	tracker := newQueryTracker(config, interfaceNameByIndex)
+	go func() {
+		for {
+			err := capture(dnsQueryOrResponseFilter, func(pkt []byte, from *unix.SockaddrLinklayer) {
+				payload := tracker.onPacket(pkt, from.Pkttype, from.Ifindex)
+				if payload != nil {
+					observeResponse(payload, observe)
+				}
+			})
+			log.WithError(err).Error("DNS policy capture failed, will retry.")
+			time.Sleep(captureRestartDelay)
+		}
+	}()
+}
+
+// queryKey identifies a DNS query by the client's address and port, the server's address and
+// the query ID.
+type queryKey struct {
+	client     [16]byte
+	clientPort uint16
+	server     [16]byte
+	id         uint16
+}
+
+func newQueryKey(client net.IP, clientPort uint16, server net.IP, id uint16) queryKey {
+	k := queryKey{clientPort: clientPort, id: id}
+	copy(k.client[:], client.To16())
+	copy(k.server[:], server.To16())
+	return k
+}
+
+// queryTracker decides which captured DNS responses can be trusted for policy.  It records the
+// queries that workloads send to the trusted servers, as they arrive from the workload, and only
+// passes on a response if:
+//
+// - it comes from a trusted server,
+// - it answers an outstanding query, with the same ID, ports and addresses, and
+// - the host is sending it to a workload interface.
+//
+// Since we see packets before the kernel's checks, the last condition makes sure that we only
+// trust responses that the host has routed (and, when the server is a pod, policed), rather
+// than, say, a response that a workload forges with the server's address.  Each query is
+// answered at most once.  It is only used from the capture goroutine.
+type queryTracker struct {
+	trustedServers        []net.IPNet
+	workloadIfacePrefixes []string
+	ifaceName             func(ifindex int) string
+
+	queries    map[queryKey]time.Time
+	ifaceNames map[int]string
+	nextExpiry time.Time
+
+	// Shim for testing.
+	now func() time.Time
+}
+
+func newQueryTracker(config PolicyCaptureConfig, ifaceName func(ifindex int) string) *queryTracker {
+	return &queryTracker{
+		trustedServers:        config.TrustedServers,
+		workloadIfacePrefixes: config.WorkloadIfacePrefixes,
+		ifaceName:             ifaceName,
+		queries:               map[queryKey]time.Time{},
+		ifaceNames:            map[int]string{},
+		now:                   time.Now,
+	}
+}
+
+// onPacket handles a captured packet and returns the DNS payload if it is a trusted response.
+func (t *queryTracker) onPacket(pkt []byte, pktType uint8, ifindex int) []byte {
+	now := t.now()
+	if now.After(t.nextExpiry) {
+		t.expire(now)
+	}
+	u, err := parseUDP(pkt)
+	if err != nil || len(u.payload) < 2 {
+		countResponsesParsed.WithLabelValues("bad-packet").Inc()
+		return nil
+	}
+	id := binary.BigEndian.Uint16(u.payload[0:2])
+
+	if u.dstPort == dnsPort {
+		// A query.  Queries also show up as the host forwards them; we only need one copy.
+		if pktType == unix.PACKET_OUTGOING || !t.isTrustedServer(u.dst) {
+			return nil
+		}
+		if len(t.queries) >= maxOutstandingQueries {
+			log.Debug("Too many outstanding DNS queries, ignoring query.")
+			return nil
+		}
+		t.queries[newQueryKey(u.src, u.srcPort, u.dst, id)] = now.Add(queryTimeout)
+		return nil
+	}
+
+	if pktType != unix.PACKET_OUTGOING || !t.isWorkloadIface(ifindex) {
+		return nil
+	}
+	key := newQueryKey(u.dst, u.dstPort, u.src, id)
+	deadline, ok := t.queries[key]
+	if !ok || !t.isTrustedServer(u.src) || now.After(deadline) {
+		log.WithFields(log.Fields{
+			"src": u.src,
+			"dst": u.dst,
+			"id":  id,
+		}).Debug("Ignoring DNS response that doesn't answer a query to a trusted server.")
+		countResponsesParsed.WithLabelValues("untrusted").Inc()
+		return nil
+	}
+	delete(t.queries, key)
+	return u.payload
+}
+
+func (t *queryTracker) isTrustedServer(addr net.IP) bool {
+	for _, cidr := range t.trustedServers {
+		if cidr.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWorkloadIface returns true if the interface with the given index is a workload interface.
+// Interface names are cached until the next expiry, in case the index is reused.
+func (t *queryTracker) isWorkloadIface(ifindex int) bool {
+	name, ok := t.ifaceNames[ifindex]
+	if !ok {
+		name = t.ifaceName(ifindex)
+		t.ifaceNames[ifindex] = name
+	}
+	if name == "" {
+		return false
+	}
+	for _, prefix := range t.workloadIfacePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// expire removes the queries that have timed out and clears the interface name cache.
+func (t *queryTracker) expire(now time.Time) {
+	for key, deadline := range t.queries {
+		if now.After(deadline) {
+			delete(t.queries, key)
+		}
+	}
+	t.ifaceNames = map[int]string{}
+	t.nextExpiry = now.Add(queryTimeout)
+}
+
+func interfaceNameByIndex(ifindex int) string {
+	iface, err := net.InterfaceByIndex(ifindex)
+	if err != nil {
+		return ""
+	}
+	return iface.Name
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnscache
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	resolvConfPath = "/etc/resolv.conf"
+	queryTimeout   = 2 * time.Second
+)
+
+// Resolver periodically looks up a set of domain names and passes the addresses that they resolve
+// to to its observer.  Capturing responses only tells us about the domains that clients have
+// looked up recently; resolving them ourselves means that we know their addresses before the
+// first connection, and that we keep up as they change.  Wildcard names can't be looked up so
+// they are skipped.
+type Resolver struct {
+	interval time.Duration
+	observe  ObserveFunc
+
+	lock    sync.Mutex
+	domains []string
+
+	// Shims for testing.
+	readResolvConf func() ([]byte, error)
+	exchange       func(server string, query []byte) ([]byte, error)
+}
+
+func NewResolver(interval time.Duration, observe ObserveFunc) *Resolver {
+	return &Resolver{
+		interval: interval,
+		observe:  observe,
+		readResolvConf: func() ([]byte, error) {
+			return ioutil.ReadFile(resolvConfPath)
+		},
+		exchange: exchangeUDP,
+	}
+}
+
+// SetDomains replaces the set of domain names to look up.  It is safe to call concurrently with
+// the resolver running.
+func (r *Resolver) SetDomains(domains []string) {
+	var exact []string
+	for _, d := range domains {
+		if !strings.HasPrefix(d, "*.") {
+			exact = append(exact, d)
+		}
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.domains = exact
+}
+
+// Start starts looking up the domains every interval.
+func (r *Resolver) Start() {
+	go func() {
+		for range time.NewTicker(r.interval).C {
+			r.ResolveAll()
+		}
+	}()
+}
+
+// ResolveAll looks up each of the domains once.
+func (r *Resolver) ResolveAll() {
+	r.lock.Lock()
+	domains := r.domains
+	r.lock.Unlock()
+	if len(domains) == 0 {
+		return
+	}
+
+	conf, err := r.readResolvConf()
+	if err != nil {
+		log.WithError(err).Warn("Failed to read resolv.conf, unable to look up policy domains.")
+		return
+	}
+	servers := parseNameservers(conf)
+	if len(servers) == 0 {
+		log.Warn("No nameservers in resolv.conf, unable to look up policy domains.")
+		return
+	}
+	for _, domain := range domains {
+		for _, qType := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+			if err := r.resolve(servers, domain, qType); err != nil {
+				log.WithError(err).WithFields(log.Fields{
+					"domain": domain,
+					"type":   qType,
+				}).Debug("Failed to look up domain.")
+			}
+		}
+	}
+}
+
+// resolve sends the query to each server in turn until one of them answers.
+func (r *Resolver) resolve(servers []string, domain string, qType dnsmessage.Type) error {
+	name, err := dnsmessage.NewName(domain + ".")
+	if err != nil {
+		return err
+	}
+	id := uint16(rand.Uint32())
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	if err := b.StartQuestions(); err != nil {
+		return err
+	}
+	if err := b.Question(dnsmessage.Question{Name: name, Type: qType, Class: dnsmessage.ClassINET}); err != nil {
+		return err
+	}
+	query, err := b.Finish()
+	if err != nil {
+		return err
+	}
+
+	for _, server := range servers {
+		var resp []byte
+		resp, err = r.exchange(server, query)
+		if err != nil {
+			continue
+		}
+		var p dnsmessage.Parser
+		var hdr dnsmessage.Header
+		hdr, err = p.Start(resp)
+		if err != nil {
+			continue
+		}
+		if hdr.ID != id {
+			err = errors.New("response ID doesn't match query")
+			continue
+		}
+		return parseResponse(resp, r.observe)
+	}
+	return err
+}
+
+func exchangeUDP(server string, query []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(server, "53"), queryTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(queryTimeout)); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, snapLen)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// parseNameservers returns the addresses of the nameservers in the given resolv.conf contents.
+func parseNameservers(conf []byte) []string {
+	var servers []string
+	scanner := bufio.NewScanner(bytes.NewReader(conf))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+		if net.ParseIP(fields[1]) == nil {
+			continue
+		}
+		servers = append(servers, fields[1])
+	}
+	return servers
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnscache
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var _ = Describe("Resolver", func() {
+	type obs struct {
+		domain string
+		ip     string
+	}
+	var (
+		resolver *Resolver
+		observed []obs
+		servers  []string
+	)
+
+	// answer plays the part of a nameserver that resolves every name to 192.0.2.1 and
+	// 2001:db8::1.
+	answer := func(query []byte) []byte {
+		var p dnsmessage.Parser
+		hdr, err := p.Start(query)
+		Expect(err).NotTo(HaveOccurred())
+		q, err := p.Question()
+		Expect(err).NotTo(HaveOccurred())
+
+		b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: hdr.ID, Response: true})
+		Expect(b.StartQuestions()).To(Succeed())
+		Expect(b.Question(q)).To(Succeed())
+		Expect(b.StartAnswers()).To(Succeed())
+		rh := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: 30}
+		if q.Type == dnsmessage.TypeA {
+			Expect(b.AResource(rh, dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}})).To(Succeed())
+		} else {
+			aaaa := dnsmessage.AAAAResource{}
+			copy(aaaa.AAAA[:], net.ParseIP("2001:db8::1"))
+			Expect(b.AAAAResource(rh, aaaa)).To(Succeed())
+		}
+		msg, err := b.Finish()
+		Expect(err).NotTo(HaveOccurred())
+		return msg
+	}
+
+	BeforeEach(func() {
+		observed = nil
+		servers = nil
+		resolver = NewResolver(time.Minute, func(domain string, ip net.IP, ttl time.Duration) {
+			Expect(ttl).To(Equal(30 * time.Second))
+			observed = append(observed, obs{domain, ip.String()})
+		})
+		resolver.readResolvConf = func() ([]byte, error) {
+			return []byte("# comment\nsearch svc.cluster.local\nnameserver 10.96.0.10\nnameserver 10.96.0.11\n"), nil
+		}
+		resolver.exchange = func(server string, query []byte) ([]byte, error) {
+			servers = append(servers, server)
+			if server == "10.96.0.10" {
+				return nil, errors.New("timeout")
+			}
+			return answer(query), nil
+		}
+	})
+
+	It("should look up exact domains and skip wildcards", func() {
+		resolver.SetDomains([]string{"*.example.com", "example.org"})
+		resolver.ResolveAll()
+		Expect(observed).To(Equal([]obs{
+			{"example.org.", "192.0.2.1"},
+			{"example.org.", "2001:db8::1"},
+		}))
+		Expect(servers).To(Equal([]string{"10.96.0.10", "10.96.0.11", "10.96.0.10", "10.96.0.11"}))
+	})
+
+	It("should do nothing without domains", func() {
+		resolver.SetDomains([]string{"*.example.com"})
+		resolver.ResolveAll()
+		Expect(servers).To(BeEmpty())
+	})
+
+	It("should parse nameservers", func() {
+		Expect(parseNameservers([]byte("nameserver 10.0.0.1\nnameserver bad\nnameserver fd00::1 # v6\n"))).To(
+			Equal([]string{"10.0.0.1", "fd00::1"}))
+	})
+})
@@ -0,0 +1,83 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flowlogs
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/rules"
+)
+
+var countCIDRBlocklistPackets = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "felix_cidr_blocklist_packets",
+	Help: "Number of packets blocked by each entry in the CIDR blocklist.",
+}, []string{"cidr", "action"})
+
+func init() {
+	prometheus.MustRegister(countCIDRBlocklistPackets)
+}
+
+// CIDRBlocklistCounter counts the packets that the iptables rules for the CIDR blocklist send to
+// NFLOG group rules.NFLOGCIDRBlocklistGroup before blocking them.
+type CIDRBlocklistCounter struct {
+	blocked []config.BlockedCIDR
+}
+
+func NewCIDRBlocklistCounter(blocked []config.BlockedCIDR) *CIDRBlocklistCounter {
+	return &CIDRBlocklistCounter{blocked: blocked}
+}
+
+// StartCIDRBlocklistCounter starts a CIDRBlocklistCounter for the given blocklist in the background.
+func StartCIDRBlocklistCounter(blocked []config.BlockedCIDR) {
+	go NewCIDRBlocklistCounter(blocked).Run()
+}
+
+func (c *CIDRBlocklistCounter) Run() {
+	for {
+		log.WithField("group", rules.NFLOGCIDRBlocklistGroup).Info(
+			"Listening for blocked packets on NFLOG group.")
+		err := readNFLOG(rules.NFLOGCIDRBlocklistGroup, func(data []byte) {
+			prefix, _, err := parseNFLOGAttrs(data)
+			if err != nil {
+				log.WithError(err).Debug("Ignoring unparseable NFLOG packet.")
+				return
+			}
+			c.OnPrefix(prefix)
+		})
+		log.WithError(err).Error("Failed to read CIDR blocklist NFLOG messages, will retry.")
+		time.Sleep(nflogRestartDelay)
+	}
+}
+
+// OnPrefix records a packet with the given NFLOG prefix, which has the form "B|<index>".
+func (c *CIDRBlocklistCounter) OnPrefix(prefix string) {
+	if !strings.HasPrefix(prefix, rules.NFLOGCIDRBlocklistPrefix) {
+		log.WithField("prefix", prefix).Debug("Ignoring packet with unexpected NFLOG prefix.")
+		return
+	}
+	idx, err := strconv.Atoi(strings.TrimPrefix(prefix, rules.NFLOGCIDRBlocklistPrefix))
+	if err != nil || idx < 0 || idx >= len(c.blocked) {
+		log.WithField("prefix", prefix).Debug("Ignoring packet with bad blocklist index.")
+		return
+	}
+	blocked := c.blocked[idx]
+	countCIDRBlocklistPackets.WithLabelValues(blocked.CIDR, blocked.Action).Inc()
+}
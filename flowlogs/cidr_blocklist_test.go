@@ -0,0 +1,51 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flowlogs
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/projectcalico/felix/config"
+)
+
+var _ = Describe("CIDRBlocklistCounter", func() {
+	var counter *CIDRBlocklistCounter
+
+	BeforeEach(func() {
+		countCIDRBlocklistPackets.Reset()
+		counter = NewCIDRBlocklistCounter([]config.BlockedCIDR{
+			{CIDR: "10.99.0.0/16", Action: "Drop"},
+			{CIDR: "fd00:99::/64", Action: "Reject"},
+		})
+	})
+
+	It("should count packets per blocklist entry", func() {
+		counter.OnPrefix("B|1")
+		counter.OnPrefix("B|1")
+		Expect(testutil.ToFloat64(countCIDRBlocklistPackets.WithLabelValues(
+			"fd00:99::/64", "Reject"))).To(Equal(2.0))
+		Expect(testutil.ToFloat64(countCIDRBlocklistPackets.WithLabelValues(
+			"10.99.0.0/16", "Drop"))).To(Equal(0.0))
+	})
+
+	It("should ignore bad prefixes", func() {
+		for _, prefix := range []string{"A|I|0", "B|", "B|2", "B|-1", "B|foo"} {
+			counter.OnPrefix(prefix)
+		}
+		Expect(testutil.CollectAndCount(countCIDRBlocklistPackets)).To(Equal(0))
+	})
+})
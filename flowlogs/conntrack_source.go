@@ -0,0 +1,105 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flowlogs
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+)
+
+type ctTuple struct {
+	family           uint8
+	proto            uint8
+	srcIP, dstIP     string
+	srcPort, dstPort uint16
+}
+
+type ctListFunc func(table netlink.ConntrackTableType, family netlink.InetFamily) ([]*netlink.ConntrackFlow, error)
+
+// ConntrackSource reports allowed flows by polling the conntrack table.  Conntrack entries
+// persist across polls so it tracks the counters that it saw last time and only reports the
+// increase.
+type ConntrackSource struct {
+	agg        *Aggregator
+	listFlows  ctListFunc
+	lastCounts map[ctTuple]counts
+}
+
+func NewConntrackSource(agg *Aggregator) *ConntrackSource {
+	return &ConntrackSource{
+		agg:        agg,
+		listFlows:  netlink.ConntrackTableList,
+		lastCounts: map[ctTuple]counts{},
+	}
+}
+
+func (s *ConntrackSource) Run(pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.poll()
+	}
+}
+
+func (s *ConntrackSource) poll() {
+	seen := map[ctTuple]counts{}
+	for _, family := range []netlink.InetFamily{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		flows, err := s.listFlows(netlink.ConntrackTable, family)
+		if err != nil {
+			log.WithError(err).WithField("family", family).Warn("Failed to list conntrack entries.")
+			// Keep the previous counts so that we don't double count next time.
+			for t, c := range s.lastCounts {
+				if t.family == uint8(family) {
+					seen[t] = c
+				}
+			}
+			continue
+		}
+		for _, f := range flows {
+			t := ctTuple{
+				family:  f.FamilyType,
+				proto:   f.Forward.Protocol,
+				srcIP:   f.Forward.SrcIP.String(),
+				dstIP:   f.Forward.DstIP.String(),
+				srcPort: f.Forward.SrcPort,
+				dstPort: f.Forward.DstPort,
+			}
+			c := counts{
+				packets: f.Forward.Packets + f.Reverse.Packets,
+				bytes:   f.Forward.Bytes + f.Reverse.Bytes,
+			}
+			seen[t] = c
+
+			delta := c
+			if last, ok := s.lastCounts[t]; ok && last.packets <= c.packets && last.bytes <= c.bytes {
+				delta.packets -= last.packets
+				delta.bytes -= last.bytes
+				if delta.packets == 0 {
+					continue
+				}
+			}
+			s.agg.Record(FlowKey{
+				SrcIP:   t.srcIP,
+				DstIP:   t.dstIP,
+				Proto:   t.proto,
+				DstPort: t.dstPort,
+				Verdict: VerdictAllow,
+			}, delta.packets, delta.bytes)
+		}
+	}
+	s.lastCounts = seen
+}
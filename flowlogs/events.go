@@ -0,0 +1,209 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flowlogs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/rules"
+)
+
+const (
+	maxDenyEventBatch   = 100
+	denyEventQueueDepth = 1000
+)
+
+var (
+	countDenyEventsSent = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "felix_flowlogs_deny_events_sent",
+		Help: "Number of denied-packet events sent.",
+	})
+	countDenyEventsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "felix_flowlogs_deny_events_dropped",
+		Help: "Number of denied-packet events that were not sent, by reason.",
+	}, []string{"reason"})
+)
+
+func init() {
+	prometheus.MustRegister(countDenyEventsSent)
+	prometheus.MustRegister(countDenyEventsDropped)
+}
+
+// DenyEvent describes a single denied packet.  The local side is the endpoint that the policy
+// was applied to; the remote side is the other end of the connection.
+type DenyEvent struct {
+	Time time.Time `json:"time"`
+	// Direction is "ingress" or "egress", relative to the local endpoint.
+	Direction string `json:"direction"`
+	// Workload and Endpoint identify the local workload endpoint, if the local IP belongs to one.
+	Workload string `json:"workload,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+	LocalIP  string `json:"localIP"`
+	RemoteIP string `json:"remoteIP"`
+	// RemoteDomains are the domain names that recently resolved to RemoteIP, if DNS visibility
+	// is enabled.
+	RemoteDomains []string `json:"remoteDomains,omitempty"`
+	Proto         uint8    `json:"proto"`
+	DstPort       uint16   `json:"dstPort"`
+	// Policy is the name of the denying policy or profile, or "(no-policy-matched)" or
+	// "(no-profile-matched)" for packets that were denied by default.
+	Policy string `json:"policy"`
+	// RuleIndex is the index of the denying rule within the policy or profile.  It is omitted
+	// for packets that were denied by default.
+	RuleIndex *int `json:"ruleIndex,omitempty"`
+}
+
+// DenyEventStream turns denied packets into DenyEvents and sends them, in batches, to a webhook.
+// Events are rate limited with a token bucket; events over the limit, or that arrive while the
+// webhook is backed up, are dropped and counted.
+type DenyEventStream struct {
+	url       string
+	client    *http.Client
+	workloads *WorkloadIndex
+	// domainLookup, if non-nil, returns the domain names for a remote IP.
+	domainLookup func(ip string) []string
+
+	// Token bucket.  Only accessed from the NFLOG reader goroutine.
+	rate       float64
+	burst      float64
+	tokens     float64
+	lastRefill time.Time
+
+	queue chan DenyEvent
+
+	// Shim for testing.
+	now func() time.Time
+}
+
+// NewDenyEventStream creates a stream that POSTs JSON arrays of DenyEvents to the given URL,
+// sending at most ratePerSec events per second on average.  workloads may be nil, in which case
+// events are not attributed to workloads.
+func NewDenyEventStream(url string, ratePerSec int, workloads *WorkloadIndex) *DenyEventStream {
+	return &DenyEventStream{
+		url:        url,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		workloads:  workloads,
+		rate:       float64(ratePerSec),
+		burst:      float64(ratePerSec),
+		tokens:     float64(ratePerSec),
+		lastRefill: time.Now(),
+		queue:      make(chan DenyEvent, denyEventQueueDepth),
+		now:        time.Now,
+	}
+}
+
+// OnDeniedPacket converts the packet to an event and queues it for sending, subject to the rate
+// limit.  It never blocks.
+func (s *DenyEventStream) OnDeniedPacket(pkt deniedPacket) {
+	if !s.takeToken() {
+		countDenyEventsDropped.WithLabelValues("rate-limited").Inc()
+		return
+	}
+	select {
+	case s.queue <- s.eventForPacket(pkt):
+	default:
+		countDenyEventsDropped.WithLabelValues("queue-full").Inc()
+	}
+}
+
+func (s *DenyEventStream) takeToken() bool {
+	now := s.now()
+	s.tokens += now.Sub(s.lastRefill).Seconds() * s.rate
+	if s.tokens > s.burst {
+		s.tokens = s.burst
+	}
+	s.lastRefill = now
+	if s.tokens < 1 {
+		return false
+	}
+	s.tokens--
+	return true
+}
+
+func (s *DenyEventStream) eventForPacket(pkt deniedPacket) DenyEvent {
+	e := DenyEvent{
+		Time:    s.now(),
+		Proto:   pkt.Key.Proto,
+		DstPort: pkt.Key.DstPort,
+		Policy:  pkt.Key.Policy,
+	}
+	if pkt.Direction == rules.NFLOGDirInbound {
+		e.Direction = "ingress"
+		e.LocalIP, e.RemoteIP = pkt.Key.DstIP, pkt.Key.SrcIP
+	} else {
+		e.Direction = "egress"
+		e.LocalIP, e.RemoteIP = pkt.Key.SrcIP, pkt.Key.DstIP
+	}
+	if pkt.RuleIndex >= 0 {
+		idx := pkt.RuleIndex
+		e.RuleIndex = &idx
+	}
+	if s.workloads != nil {
+		if id, ok := s.workloads.Lookup(e.LocalIP); ok {
+			e.Workload = id.WorkloadId
+			e.Endpoint = id.EndpointId
+		}
+	}
+	if s.domainLookup != nil {
+		e.RemoteDomains = s.domainLookup(e.RemoteIP)
+	}
+	return e
+}
+
+// Run sends queued events to the webhook.  Events that are already queued when a send starts
+// are batched together.
+func (s *DenyEventStream) Run() {
+	for e := range s.queue {
+		batch := []DenyEvent{e}
+	drain:
+		for len(batch) < maxDenyEventBatch {
+			select {
+			case e := <-s.queue:
+				batch = append(batch, e)
+			default:
+				break drain
+			}
+		}
+		if err := s.send(batch); err != nil {
+			log.WithError(err).Warn("Failed to send denied-packet events.")
+			countDenyEventsDropped.WithLabelValues("send-failed").Add(float64(len(batch)))
+			continue
+		}
+		countDenyEventsSent.Add(float64(len(batch)))
+	}
+}
+
+func (s *DenyEventStream) send(batch []DenyEvent) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected response from webhook: %s", resp.Status)
+	}
+	return nil
+}
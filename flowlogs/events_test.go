@@ -0,0 +1,170 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flowlogs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/proto"
+)
+
+var _ = Describe("WorkloadIndex", func() {
+	var idx *WorkloadIndex
+	id := proto.WorkloadEndpointID{OrchestratorId: "k8s", WorkloadId: "ns1/pod1", EndpointId: "eth0"}
+
+	lookup := func(ip string) *proto.WorkloadEndpointID {
+		id, ok := idx.Lookup(ip)
+		if !ok {
+			return nil
+		}
+		return &id
+	}
+
+	BeforeEach(func() {
+		idx = NewWorkloadIndex()
+		idx.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id: &id,
+			Endpoint: &proto.WorkloadEndpoint{
+				Ipv4Nets: []string{"10.0.0.2/32"},
+				Ipv6Nets: []string{"fd00::2/128"},
+			},
+		})
+	})
+
+	It("should look up endpoints by IP", func() {
+		Expect(lookup("10.0.0.2")).To(Equal(&id))
+		Expect(lookup("fd00::2")).To(Equal(&id))
+		Expect(lookup("10.0.0.3")).To(BeNil())
+	})
+
+	It("should handle IP changes and removal", func() {
+		idx.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id:       &id,
+			Endpoint: &proto.WorkloadEndpoint{Ipv4Nets: []string{"10.0.0.3/32"}},
+		})
+		Expect(lookup("10.0.0.2")).To(BeNil())
+		Expect(lookup("10.0.0.3")).To(Equal(&id))
+
+		idx.OnUpdate(&proto.WorkloadEndpointRemove{Id: &id})
+		Expect(lookup("10.0.0.3")).To(BeNil())
+	})
+})
+
+var _ = Describe("DenyEventStream", func() {
+	var (
+		server   *httptest.Server
+		lock     sync.Mutex
+		received []DenyEvent
+		stream   *DenyEventStream
+		now      time.Time
+	)
+
+	ingressPkt := deniedPacket{Key: denyKey, Length: 60, Direction: "I", RuleIndex: 3}
+
+	BeforeEach(func() {
+		received = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			Expect(err).NotTo(HaveOccurred())
+			var events []DenyEvent
+			Expect(json.Unmarshal(body, &events)).To(Succeed())
+			lock.Lock()
+			defer lock.Unlock()
+			received = append(received, events...)
+		}))
+
+		idx := NewWorkloadIndex()
+		idx.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id:       &proto.WorkloadEndpointID{WorkloadId: "ns1/pod1", EndpointId: "eth0"},
+			Endpoint: &proto.WorkloadEndpoint{Ipv4Nets: []string{"10.0.0.2/32"}},
+		})
+		now = t0
+		stream = NewDenyEventStream(server.URL, 2, idx)
+		stream.now = func() time.Time { return now }
+		stream.lastRefill = t0
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	receivedEvents := func() []DenyEvent {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]DenyEvent(nil), received...)
+	}
+
+	It("should attribute events to the workload and rule", func() {
+		go stream.Run()
+		stream.OnDeniedPacket(ingressPkt)
+		Eventually(receivedEvents).Should(HaveLen(1))
+		idx := 3
+		Expect(receivedEvents()[0]).To(Equal(DenyEvent{
+			Time:      t0,
+			Direction: "ingress",
+			Workload:  "ns1/pod1",
+			Endpoint:  "eth0",
+			LocalIP:   "10.0.0.2",
+			RemoteIP:  "10.0.0.1",
+			Proto:     6,
+			DstPort:   80,
+			Policy:    "default.deny-web",
+			RuleIndex: &idx,
+		}))
+	})
+
+	It("should omit the rule index for default denies", func() {
+		e := stream.eventForPacket(deniedPacket{Key: allowKey, Direction: "O", RuleIndex: -1})
+		Expect(e.Direction).To(Equal("egress"))
+		Expect(e.LocalIP).To(Equal("10.0.0.1"))
+		Expect(e.Workload).To(BeEmpty())
+		Expect(e.RuleIndex).To(BeNil())
+	})
+
+	It("should annotate the remote IP with domain names", func() {
+		stream.domainLookup = func(ip string) []string {
+			if ip == "10.0.0.1" {
+				return []string{"example.com"}
+			}
+			return nil
+		}
+		e := stream.eventForPacket(ingressPkt)
+		Expect(e.RemoteDomains).To(Equal([]string{"example.com"}))
+	})
+
+	It("should rate limit events", func() {
+		for i := 0; i < 5; i++ {
+			stream.OnDeniedPacket(ingressPkt)
+		}
+		Expect(stream.queue).To(HaveLen(2))
+
+		now = now.Add(500 * time.Millisecond)
+		for i := 0; i < 5; i++ {
+			stream.OnDeniedPacket(ingressPkt)
+		}
+		Expect(stream.queue).To(HaveLen(3))
+
+		go stream.Run()
+		Eventually(receivedEvents).Should(HaveLen(3))
+	})
+})
@@ -0,0 +1,136 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flowlogs
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/rules"
+)
+
+var countFailsafeAuditPackets = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "felix_failsafe_audit_packets",
+	Help: "Number of packets that were accepted only because they matched a failsafe port.",
+}, []string{"direction", "protocol", "port", "net", "iface"})
+
+func init() {
+	prometheus.MustRegister(countFailsafeAuditPackets)
+}
+
+// FailsafeAuditor counts the packets that, in failsafe audit mode, the iptables rules send to
+// NFLOG group rules.NFLOGFailsafeAuditGroup because policy would have denied them if they hadn't
+// matched a failsafe port.  Ports that never show up in the counts are candidates for removal
+// from the failsafe lists.
+type FailsafeAuditor struct {
+	inbound, outbound []config.ProtoPort
+
+	lock sync.Mutex
+	// logged records the ports that we've already logged, so that we log each port once.
+	logged map[string]bool
+}
+
+func NewFailsafeAuditor(inbound, outbound []config.ProtoPort) *FailsafeAuditor {
+	return &FailsafeAuditor{
+		inbound:  inbound,
+		outbound: outbound,
+		logged:   map[string]bool{},
+	}
+}
+
+// StartFailsafeAuditor starts a FailsafeAuditor for the given failsafe ports in the background.
+func StartFailsafeAuditor(inbound, outbound []config.ProtoPort) {
+	go NewFailsafeAuditor(inbound, outbound).Run()
+}
+
+func (a *FailsafeAuditor) Run() {
+	for {
+		log.WithField("group", rules.NFLOGFailsafeAuditGroup).Info(
+			"Listening for failsafe audit packets on NFLOG group.")
+		err := readNFLOG(rules.NFLOGFailsafeAuditGroup, func(data []byte) {
+			prefix, _, err := parseNFLOGAttrs(data)
+			if err != nil {
+				log.WithError(err).Debug("Ignoring unparseable NFLOG packet.")
+				return
+			}
+			a.OnPrefix(prefix)
+		})
+		log.WithError(err).Error("Failed to read failsafe audit NFLOG messages, will retry.")
+		time.Sleep(nflogRestartDelay)
+	}
+}
+
+// OnPrefix records a packet with the given NFLOG prefix.
+func (a *FailsafeAuditor) OnPrefix(prefix string) {
+	dir, protoPort, err := a.parseAuditPrefix(prefix)
+	if err != nil {
+		log.WithError(err).Debug("Ignoring failsafe audit packet.")
+		return
+	}
+	direction := "inbound"
+	if dir == rules.NFLOGDirOutbound {
+		direction = "outbound"
+	}
+	port := strconv.Itoa(int(protoPort.Port))
+	countFailsafeAuditPackets.WithLabelValues(
+		direction, protoPort.Protocol, port, protoPort.Net, protoPort.Interface).Inc()
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if !a.logged[prefix] {
+		a.logged[prefix] = true
+		log.WithFields(log.Fields{
+			"direction": direction,
+			"protocol":  protoPort.Protocol,
+			"port":      port,
+			"net":       protoPort.Net,
+			"iface":     protoPort.Interface,
+		}).Info("Failsafe port accepted traffic that policy would have denied; further packets are only counted.")
+	}
+}
+
+// parseAuditPrefix parses an NFLOG prefix of the form "A|<direction>|<index>" and returns the
+// failsafe port that it refers to.
+func (a *FailsafeAuditor) parseAuditPrefix(prefix string) (dir string, protoPort config.ProtoPort, err error) {
+	if !strings.HasPrefix(prefix, rules.NFLOGFailsafeAuditPrefix) {
+		return "", protoPort, errors.New("unexpected NFLOG prefix: " + prefix)
+	}
+	parts := strings.Split(strings.TrimPrefix(prefix, rules.NFLOGFailsafeAuditPrefix), "|")
+	if len(parts) != 2 {
+		return "", protoPort, errors.New("malformed NFLOG prefix: " + prefix)
+	}
+	dir = parts[0]
+	var protoPorts []config.ProtoPort
+	switch dir {
+	case rules.NFLOGDirInbound:
+		protoPorts = a.inbound
+	case rules.NFLOGDirOutbound:
+		protoPorts = a.outbound
+	default:
+		return "", protoPort, errors.New("bad direction in NFLOG prefix: " + prefix)
+	}
+	idx, err := strconv.Atoi(parts[1])
+	if err != nil || idx < 0 || idx >= len(protoPorts) {
+		return "", protoPort, errors.New("bad failsafe port index in NFLOG prefix: " + prefix)
+	}
+	return dir, protoPorts[idx], nil
+}
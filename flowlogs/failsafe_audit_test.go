@@ -0,0 +1,59 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flowlogs
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/projectcalico/felix/config"
+)
+
+var _ = Describe("FailsafeAuditor", func() {
+	var auditor *FailsafeAuditor
+
+	BeforeEach(func() {
+		countFailsafeAuditPackets.Reset()
+		auditor = NewFailsafeAuditor(
+			[]config.ProtoPort{
+				{Protocol: "tcp", Port: 22},
+				{Net: "10.0.0.0/8", Protocol: "tcp", Port: 6443, Interface: "eth0"},
+			},
+			[]config.ProtoPort{
+				{Protocol: "udp", Port: 53},
+			},
+		)
+	})
+
+	It("should count packets per failsafe port", func() {
+		auditor.OnPrefix("A|I|1")
+		auditor.OnPrefix("A|I|1")
+		auditor.OnPrefix("A|O|0")
+		Expect(testutil.ToFloat64(countFailsafeAuditPackets.WithLabelValues(
+			"inbound", "tcp", "6443", "10.0.0.0/8", "eth0"))).To(Equal(2.0))
+		Expect(testutil.ToFloat64(countFailsafeAuditPackets.WithLabelValues(
+			"outbound", "udp", "53", "", ""))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(countFailsafeAuditPackets.WithLabelValues(
+			"inbound", "tcp", "22", "", ""))).To(Equal(0.0))
+	})
+
+	It("should ignore bad prefixes", func() {
+		for _, prefix := range []string{"D|I0|default.foo", "A|I", "A|X|0", "A|I|2", "A|O|-1", "A|I|foo"} {
+			auditor.OnPrefix(prefix)
+		}
+		Expect(auditor.logged).To(BeEmpty())
+	})
+})
@@ -0,0 +1,276 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package flowlogs collects basic network flow records and emits them, aggregated over a
+// configurable window, to one or more sinks.
+//
+// Flow records come from two sources:
+//
+// - Denied packets are sent to an NFLOG group by the iptables rules (see rules.NFLOGDenyGroup);
+//   the NFLOG prefix identifies the policy or profile that denied the packet.
+// - Allowed flows are found by periodically polling the conntrack table; packet and byte counts
+//   require conntrack accounting (net.netfilter.nf_conntrack_acct) to be enabled.
+//
+// Records are aggregated by source IP, destination IP, protocol, destination port, verdict and
+// policy.
+package flowlogs
+
+import (
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+type Verdict string
+
+const (
+	VerdictAllow Verdict = "allow"
+	VerdictDeny  Verdict = "deny"
+)
+
+var (
+	countFlowLogsEmitted = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "felix_flowlogs_emitted",
+		Help: "Number of aggregated flow logs emitted.",
+	})
+	countSinkErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "felix_flowlogs_sink_errors",
+		Help: "Number of failures to write flow logs to a sink.",
+	}, []string{"sink"})
+)
+
+func init() {
+	prometheus.MustRegister(countFlowLogsEmitted)
+	prometheus.MustRegister(countSinkErrors)
+}
+
+type Config struct {
+	// FlushInterval is the aggregation window; flow logs are emitted at the end of each window.
+	FlushInterval time.Duration
+	// ConntrackPollInterval is how often the conntrack table is scanned for allowed flows.
+	ConntrackPollInterval time.Duration
+
+	// FilePath, if set, enables the file sink, which appends one JSON object per line.
+	FilePath string
+	// SyslogEnabled enables the syslog sink.
+	SyslogEnabled bool
+	// OTLPEndpoint, if set, enables the OTLP sink, which sends flow logs as OTLP/HTTP log
+	// records to <OTLPEndpoint>/v1/logs.
+	OTLPEndpoint string
+	// IPFIXCollector, if set, enables export of flow records to the given host:port over UDP,
+	// using IPFIXProtocol (ExportProtocolIPFIX or ExportProtocolNetFlowV9).
+	IPFIXCollector string
+	IPFIXProtocol  string
+
+	// DenyEventsWebhookURL, if set, enables the denied-packet event stream, which POSTs an
+	// event for each denied packet, up to DenyEventsRateLimit events per second.
+	DenyEventsWebhookURL string
+	DenyEventsRateLimit  int
+	// Workloads, if non-nil, is used to attribute denied-packet events to local workloads.
+	Workloads *WorkloadIndex
+	// DomainLookup, if non-nil, is used to annotate denied-packet events with the domain names
+	// that recently resolved to the remote IP.
+	DomainLookup func(ip string) []string
+
+	Hostname string
+}
+
+// Start starts collecting flow logs.
+func Start(config Config) {
+	var sinks []Sink
+	if config.FilePath != "" {
+		sinks = append(sinks, NewFileSink(config.FilePath))
+	}
+	if config.SyslogEnabled {
+		s, err := NewSyslogSink()
+		if err != nil {
+			log.WithError(err).Error("Failed to connect to syslog, flow logs will not be sent to syslog.")
+		} else {
+			sinks = append(sinks, s)
+		}
+	}
+	if config.OTLPEndpoint != "" {
+		sinks = append(sinks, NewOTLPSink(config.OTLPEndpoint, config.Hostname))
+	}
+	if config.IPFIXCollector != "" {
+		s, err := NewIPFIXSink(config.IPFIXProtocol, config.IPFIXCollector, 0)
+		if err != nil {
+			log.WithError(err).Error("Failed to create flow exporter, flow records will not be exported.")
+		} else {
+			sinks = append(sinks, s)
+		}
+	}
+	var events *DenyEventStream
+	if config.DenyEventsWebhookURL != "" {
+		events = NewDenyEventStream(config.DenyEventsWebhookURL, config.DenyEventsRateLimit, config.Workloads)
+		events.domainLookup = config.DomainLookup
+		go events.Run()
+	}
+	if len(sinks) == 0 && events == nil {
+		log.Warn("Flow logs enabled but no sinks configured, not collecting flow logs.")
+		return
+	}
+	log.WithField("config", config).Info("Starting flow logs collector.")
+
+	var agg *Aggregator
+	if len(sinks) > 0 {
+		agg = NewAggregator(sinks...)
+		go agg.Run(config.FlushInterval)
+		go NewConntrackSource(agg).Run(config.ConntrackPollInterval)
+	}
+	go NewNFLOGSource(agg, events).Run()
+}
+
+// FlowKey is the aggregation key for flow logs.
+type FlowKey struct {
+	SrcIP   string  `json:"srcIP"`
+	DstIP   string  `json:"dstIP"`
+	Proto   uint8   `json:"proto"`
+	DstPort uint16  `json:"dstPort"`
+	Verdict Verdict `json:"verdict"`
+	// Policy is the name of the policy or profile that denied the flow.  It is empty for
+	// allowed flows.
+	Policy string `json:"policy,omitempty"`
+}
+
+// FlowLog is an aggregated flow record for one aggregation window.
+type FlowLog struct {
+	FlowKey
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	Packets   uint64    `json:"packets"`
+	Bytes     uint64    `json:"bytes"`
+}
+
+// Sink is a destination for flow logs.
+type Sink interface {
+	Name() string
+	Emit(logs []*FlowLog) error
+}
+
+type counts struct {
+	packets, bytes uint64
+}
+
+// Aggregator accumulates flow records and periodically emits them to its sinks.
+type Aggregator struct {
+	sinks []Sink
+
+	lock        sync.Mutex
+	flows       map[FlowKey]*counts
+	windowStart time.Time
+
+	// Shim for testing.
+	now func() time.Time
+}
+
+func NewAggregator(sinks ...Sink) *Aggregator {
+	return &Aggregator{
+		sinks:       sinks,
+		flows:       map[FlowKey]*counts{},
+		windowStart: time.Now(),
+		now:         time.Now,
+	}
+}
+
+// Record adds the given packet and byte counts to the flow with the given key.
+func (a *Aggregator) Record(key FlowKey, packets, bytes uint64) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	c := a.flows[key]
+	if c == nil {
+		c = &counts{}
+		a.flows[key] = c
+	}
+	c.packets += packets
+	c.bytes += bytes
+}
+
+func (a *Aggregator) Run(flushInterval time.Duration) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		a.Flush()
+	}
+}
+
+// Flush ends the current aggregation window and emits its flow logs to the sinks.
+func (a *Aggregator) Flush() {
+	a.lock.Lock()
+	flows := a.flows
+	start := a.windowStart
+	end := a.now()
+	a.flows = map[FlowKey]*counts{}
+	a.windowStart = end
+	a.lock.Unlock()
+
+	if len(flows) == 0 {
+		return
+	}
+	logs := make([]*FlowLog, 0, len(flows))
+	for k, c := range flows {
+		logs = append(logs, &FlowLog{
+			FlowKey:   k,
+			StartTime: start,
+			EndTime:   end,
+			Packets:   c.packets,
+			Bytes:     c.bytes,
+		})
+	}
+	sort.Slice(logs, func(i, j int) bool {
+		return lessFlowKey(logs[i].FlowKey, logs[j].FlowKey)
+	})
+
+	emitted := false
+	for _, s := range a.sinks {
+		if err := s.Emit(logs); err != nil {
+			log.WithError(err).WithField("sink", s.Name()).Warn("Failed to emit flow logs.")
+			countSinkErrors.WithLabelValues(s.Name()).Inc()
+			continue
+		}
+		emitted = true
+	}
+	if emitted {
+		// Only count the logs if at least one sink accepted them.
+		countFlowLogsEmitted.Add(float64(len(logs)))
+	}
+}
+
+func lessFlowKey(a, b FlowKey) bool {
+	if a.SrcIP != b.SrcIP {
+		return a.SrcIP < b.SrcIP
+	}
+	if a.DstIP != b.DstIP {
+		return a.DstIP < b.DstIP
+	}
+	if a.Proto != b.Proto {
+		return a.Proto < b.Proto
+	}
+	if a.DstPort != b.DstPort {
+		return a.DstPort < b.DstPort
+	}
+	if a.Verdict != b.Verdict {
+		return a.Verdict < b.Verdict
+	}
+	return a.Policy < b.Policy
+}
+
+func hostname() string {
+	h, _ := os.Hostname()
+	return h
+}
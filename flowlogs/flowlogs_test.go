@@ -0,0 +1,352 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flowlogs
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+type recordingSink struct {
+	batches [][]*FlowLog
+	err     error
+}
+
+func (s *recordingSink) Name() string {
+	return "recorder"
+}
+
+func (s *recordingSink) Emit(logs []*FlowLog) error {
+	s.batches = append(s.batches, logs)
+	return s.err
+}
+
+var (
+	t0 = time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	t1 = t0.Add(5 * time.Minute)
+
+	denyKey = FlowKey{
+		SrcIP:   "10.0.0.1",
+		DstIP:   "10.0.0.2",
+		Proto:   6,
+		DstPort: 80,
+		Verdict: VerdictDeny,
+		Policy:  "default.deny-web",
+	}
+	allowKey = FlowKey{
+		SrcIP:   "10.0.0.1",
+		DstIP:   "10.0.0.3",
+		Proto:   17,
+		DstPort: 53,
+		Verdict: VerdictAllow,
+	}
+)
+
+var _ = Describe("Aggregator", func() {
+	var (
+		sink *recordingSink
+		agg  *Aggregator
+	)
+
+	BeforeEach(func() {
+		sink = &recordingSink{}
+		agg = NewAggregator(sink)
+		agg.windowStart = t0
+		agg.now = func() time.Time { return t1 }
+	})
+
+	It("should not emit anything for an empty window", func() {
+		agg.Flush()
+		Expect(sink.batches).To(BeEmpty())
+	})
+
+	It("should aggregate records with the same key", func() {
+		agg.Record(denyKey, 1, 60)
+		agg.Record(allowKey, 2, 200)
+		agg.Record(denyKey, 1, 40)
+		agg.Flush()
+
+		Expect(sink.batches).To(Equal([][]*FlowLog{{
+			{FlowKey: denyKey, StartTime: t0, EndTime: t1, Packets: 2, Bytes: 100},
+			{FlowKey: allowKey, StartTime: t0, EndTime: t1, Packets: 2, Bytes: 200},
+		}}))
+	})
+
+	It("should start a new window after a flush", func() {
+		agg.Record(denyKey, 1, 60)
+		agg.Flush()
+		t2 := t1.Add(5 * time.Minute)
+		agg.now = func() time.Time { return t2 }
+		agg.Record(denyKey, 1, 60)
+		agg.Flush()
+
+		Expect(sink.batches).To(HaveLen(2))
+		Expect(sink.batches[1]).To(Equal([]*FlowLog{
+			{FlowKey: denyKey, StartTime: t1, EndTime: t2, Packets: 1, Bytes: 60},
+		}))
+	})
+
+	It("should carry on after a sink failure", func() {
+		sink.err = errors.New("sink failed")
+		agg.Record(denyKey, 1, 60)
+		agg.Flush()
+		agg.Record(denyKey, 1, 60)
+		agg.Flush()
+		Expect(sink.batches).To(HaveLen(2))
+	})
+
+	It("should only count flow logs that a sink accepted", func() {
+		before := testutil.ToFloat64(countFlowLogsEmitted)
+		sink.err = errors.New("sink failed")
+		agg.Record(denyKey, 1, 60)
+		agg.Flush()
+		Expect(testutil.ToFloat64(countFlowLogsEmitted)).To(Equal(before))
+
+		sink.err = nil
+		agg.Record(denyKey, 1, 60)
+		agg.Flush()
+		Expect(testutil.ToFloat64(countFlowLogsEmitted)).To(Equal(before + 1))
+	})
+})
+
+var _ = Describe("Sinks", func() {
+	logs := []*FlowLog{
+		{FlowKey: denyKey, StartTime: t0, EndTime: t1, Packets: 2, Bytes: 100},
+		{FlowKey: allowKey, StartTime: t0, EndTime: t1, Packets: 3, Bytes: 300},
+	}
+
+	It("file sink should append JSON lines", func() {
+		dir, err := ioutil.TempDir("", "flowlogs")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "sub", "flows.log")
+
+		s := NewFileSink(path)
+		Expect(s.Emit(logs[:1])).To(Succeed())
+		Expect(s.Emit(logs[1:])).To(Succeed())
+
+		data, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		Expect(lines).To(HaveLen(2))
+		Expect(lines[0]).To(Equal(`{"srcIP":"10.0.0.1","dstIP":"10.0.0.2","proto":6,"dstPort":80,` +
+			`"verdict":"deny","policy":"default.deny-web","startTime":"2021-06-01T12:00:00Z",` +
+			`"endTime":"2021-06-01T12:05:00Z","packets":2,"bytes":100}`))
+		var decoded FlowLog
+		Expect(json.Unmarshal([]byte(lines[1]), &decoded)).To(Succeed())
+		Expect(decoded).To(Equal(*logs[1]))
+	})
+
+	It("OTLP sink should send log records", func() {
+		var paths []string
+		var reqs []map[string]interface{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			Expect(err).NotTo(HaveOccurred())
+			var req map[string]interface{}
+			Expect(json.Unmarshal(body, &req)).To(Succeed())
+			paths = append(paths, r.URL.Path)
+			reqs = append(reqs, req)
+		}))
+		defer server.Close()
+
+		s := NewOTLPSink(server.URL+"/", "node1")
+		Expect(s.Emit(logs)).To(Succeed())
+		Expect(paths).To(Equal([]string{"/v1/logs"}))
+		rl := reqs[0]["resourceLogs"].([]interface{})[0].(map[string]interface{})
+		records := rl["scopeLogs"].([]interface{})[0].(map[string]interface{})["logRecords"].([]interface{})
+		Expect(records).To(HaveLen(2))
+		attrs := records[0].(map[string]interface{})["attributes"].([]interface{})
+		Expect(attrs).To(ContainElement(map[string]interface{}{
+			"key": "calico.policy", "value": map[string]interface{}{"stringValue": "default.deny-web"},
+		}))
+		Expect(attrs).To(ContainElement(map[string]interface{}{
+			"key": "destination.port", "value": map[string]interface{}{"intValue": "80"},
+		}))
+	})
+
+	It("OTLP sink should report collector errors", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+		Expect(NewOTLPSink(server.URL, "node1").Emit(logs)).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("ConntrackSource", func() {
+	var (
+		sink  *recordingSink
+		agg   *Aggregator
+		src   *ConntrackSource
+		flows []*netlink.ConntrackFlow
+	)
+
+	flow := func(packets, bytes uint64) *netlink.ConntrackFlow {
+		f := &netlink.ConntrackFlow{FamilyType: uint8(netlink.FAMILY_V4)}
+		f.Forward.Protocol = 17
+		f.Forward.SrcIP = net.ParseIP("10.0.0.1")
+		f.Forward.DstIP = net.ParseIP("10.0.0.3")
+		f.Forward.SrcPort = 40000
+		f.Forward.DstPort = 53
+		f.Forward.Packets = packets
+		f.Forward.Bytes = bytes
+		return f
+	}
+
+	BeforeEach(func() {
+		sink = &recordingSink{}
+		agg = NewAggregator(sink)
+		src = NewConntrackSource(agg)
+		src.listFlows = func(table netlink.ConntrackTableType, family netlink.InetFamily) ([]*netlink.ConntrackFlow, error) {
+			if family == netlink.FAMILY_V4 {
+				return flows, nil
+			}
+			return nil, nil
+		}
+	})
+
+	flushedCounts := func() (packets, bytes uint64) {
+		agg.Flush()
+		if len(sink.batches) == 0 {
+			return 0, 0
+		}
+		l := sink.batches[len(sink.batches)-1]
+		sink.batches = nil
+		Expect(l).To(HaveLen(1))
+		Expect(l[0].FlowKey).To(Equal(allowKey))
+		return l[0].Packets, l[0].Bytes
+	}
+
+	It("should only report the increase in the counters", func() {
+		flows = []*netlink.ConntrackFlow{flow(2, 200)}
+		src.poll()
+		p, b := flushedCounts()
+		Expect(p).To(BeEquivalentTo(2))
+		Expect(b).To(BeEquivalentTo(200))
+
+		flows = []*netlink.ConntrackFlow{flow(5, 500)}
+		src.poll()
+		p, b = flushedCounts()
+		Expect(p).To(BeEquivalentTo(3))
+		Expect(b).To(BeEquivalentTo(300))
+
+		// No change: nothing to report.
+		src.poll()
+		agg.Flush()
+		Expect(sink.batches).To(BeEmpty())
+	})
+
+	It("should treat a reused tuple with lower counters as a new flow", func() {
+		flows = []*netlink.ConntrackFlow{flow(5, 500)}
+		src.poll()
+		flushedCounts()
+		flows = []*netlink.ConntrackFlow{flow(1, 100)}
+		src.poll()
+		p, _ := flushedCounts()
+		Expect(p).To(BeEquivalentTo(1))
+	})
+})
+
+var _ = Describe("NFLOG parsing", func() {
+	nflogMsg := func(family uint8, prefix string, payload []byte) []byte {
+		msg := []byte{family, 0, 0, 20}
+		msg = append(msg, nflogAttr(nfulaPrefix, append([]byte(prefix), 0))...)
+		msg = append(msg, nflogAttr(nfulaPayload, payload)...)
+		return msg
+	}
+
+	It("should parse a denied IPv4 TCP packet", func() {
+		pkt := make([]byte, 24)
+		pkt[0] = 0x45
+		binary.BigEndian.PutUint16(pkt[2:4], 60)
+		pkt[9] = unix.IPPROTO_TCP
+		copy(pkt[12:16], net.ParseIP("10.0.0.1").To4())
+		copy(pkt[16:20], net.ParseIP("10.0.0.2").To4())
+		binary.BigEndian.PutUint16(pkt[20:22], 40000)
+		binary.BigEndian.PutUint16(pkt[22:24], 80)
+
+		p, err := parseNFLOGPacket(nflogMsg(unix.AF_INET, "D|I3|default.deny-web", pkt))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p.Key).To(Equal(denyKey))
+		Expect(p.Length).To(Equal(60))
+		Expect(p.Direction).To(Equal("I"))
+		Expect(p.RuleIndex).To(Equal(3))
+	})
+
+	It("should parse a denied IPv6 UDP packet", func() {
+		pkt := make([]byte, 44)
+		pkt[0] = 0x60
+		binary.BigEndian.PutUint16(pkt[4:6], 20)
+		pkt[6] = unix.IPPROTO_UDP
+		copy(pkt[8:24], net.ParseIP("fd00::1"))
+		copy(pkt[24:40], net.ParseIP("fd00::2"))
+		binary.BigEndian.PutUint16(pkt[42:44], 53)
+
+		p, err := parseNFLOGPacket(nflogMsg(unix.AF_INET6, "D|O-|(no-profile-matched)", pkt))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p.Direction).To(Equal("O"))
+		Expect(p.RuleIndex).To(Equal(-1))
+		Expect(p.Key).To(Equal(FlowKey{
+			SrcIP:   "fd00::1",
+			DstIP:   "fd00::2",
+			Proto:   unix.IPPROTO_UDP,
+			DstPort: 53,
+			Verdict: VerdictDeny,
+			Policy:  "(no-profile-matched)",
+		}))
+		Expect(p.Length).To(Equal(60))
+	})
+
+	It("should reject packets with a foreign prefix", func() {
+		_, err := parseNFLOGPacket(nflogMsg(unix.AF_INET, "something else", make([]byte, 20)))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject malformed prefixes", func() {
+		for _, prefix := range []string{"D|foo", "D|X1|foo", "D|Ix|foo"} {
+			_, err := parseNFLOGPacket(nflogMsg(unix.AF_INET, prefix, make([]byte, 20)))
+			Expect(err).To(HaveOccurred(), prefix)
+		}
+	})
+
+	It("should reject truncated packets", func() {
+		_, err := parseNFLOGPacket(nflogMsg(unix.AF_INET, "D|I0|foo", make([]byte, 10)))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should build a valid bind message", func() {
+		msg := nflogBindMsg(20)
+		Expect(nl.NativeEndian().Uint32(msg[0:4])).To(BeEquivalentTo(len(msg)))
+		Expect(nl.NativeEndian().Uint16(msg[4:6])).To(BeEquivalentTo(nfnlSubsysULOG<<8 | nfulnlMsgConfig))
+		Expect(binary.BigEndian.Uint16(msg[18:20])).To(BeEquivalentTo(20))
+	})
+})
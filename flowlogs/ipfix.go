@@ -0,0 +1,322 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flowlogs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	ExportProtocolIPFIX     = "IPFIX"
+	ExportProtocolNetFlowV9 = "NetFlowV9"
+
+	ipfixVersion     = 10
+	netflowV9Version = 9
+
+	ipfixTemplateSetID     = 2
+	netflowV9TemplateSetID = 0
+
+	templateIDv4 = 256
+	templateIDv6 = 257
+
+	// maxExportMsgLen keeps each UDP datagram within a typical path MTU.
+	maxExportMsgLen = 1400
+
+	// Values of the forwardingStatus information element (RFC 7270), "unknown" reason code.
+	forwardingStatusForwarded = 64
+	forwardingStatusDropped   = 128
+)
+
+// Information element IDs.  NetFlow v9 and IPFIX share IDs for the fields that we export,
+// except for the flow timestamps.
+const (
+	ieOctetDeltaCount          = 1
+	iePacketDeltaCount         = 2
+	ieProtocolIdentifier       = 4
+	ieSourceIPv4Address        = 8
+	ieDestinationTransportPort = 11
+	ieDestinationIPv4Address   = 12
+	ieNFv9LastSwitched         = 21
+	ieNFv9FirstSwitched        = 22
+	ieSourceIPv6Address        = 27
+	ieDestinationIPv6Address   = 28
+	ieForwardingStatus         = 89
+	ieFlowStartMilliseconds    = 152
+	ieFlowEndMilliseconds      = 153
+)
+
+type templateField struct {
+	id, length uint16
+}
+
+// IPFIXSink exports flow logs to an IPFIX (RFC 7011) or NetFlow v9 (RFC 3954) collector over
+// UDP.  Templates are sent at the start of every batch so that a collector that restarts picks
+// them up again by the next flush.  The name of the denying policy is not exported; denied flows
+// are marked with the "dropped" forwardingStatus.
+type IPFIXSink struct {
+	protocol string
+	conn     net.Conn
+	domainID uint32
+
+	// seq is the IPFIX sequence number (count of data records sent) or the NetFlow v9 sequence
+	// number (count of export packets sent).
+	seq       uint32
+	startTime time.Time
+
+	// Shim for testing.
+	now func() time.Time
+}
+
+func NewIPFIXSink(protocol, collector string, domainID uint32) (*IPFIXSink, error) {
+	if protocol != ExportProtocolIPFIX && protocol != ExportProtocolNetFlowV9 {
+		return nil, fmt.Errorf("unknown flow export protocol %q", protocol)
+	}
+	conn, err := net.Dial("udp", collector)
+	if err != nil {
+		return nil, err
+	}
+	return &IPFIXSink{
+		protocol:  protocol,
+		conn:      conn,
+		domainID:  domainID,
+		startTime: time.Now(),
+		now:       time.Now,
+	}, nil
+}
+
+func (s *IPFIXSink) Name() string {
+	if s.protocol == ExportProtocolNetFlowV9 {
+		return "netflow9"
+	}
+	return "ipfix"
+}
+
+func (s *IPFIXSink) Emit(logs []*FlowLog) error {
+	for _, msg := range s.buildMessages(logs) {
+		if _, err := s.conn.Write(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *IPFIXSink) templateFields(v6 bool) []templateField {
+	srcIE, dstIE, addrLen := uint16(ieSourceIPv4Address), uint16(ieDestinationIPv4Address), uint16(4)
+	if v6 {
+		srcIE, dstIE, addrLen = ieSourceIPv6Address, ieDestinationIPv6Address, 16
+	}
+	fields := []templateField{
+		{srcIE, addrLen},
+		{dstIE, addrLen},
+		{ieProtocolIdentifier, 1},
+		{ieDestinationTransportPort, 2},
+		{ieForwardingStatus, 1},
+		{iePacketDeltaCount, 8},
+		{ieOctetDeltaCount, 8},
+	}
+	if s.protocol == ExportProtocolNetFlowV9 {
+		return append(fields, templateField{ieNFv9FirstSwitched, 4}, templateField{ieNFv9LastSwitched, 4})
+	}
+	return append(fields, templateField{ieFlowStartMilliseconds, 8}, templateField{ieFlowEndMilliseconds, 8})
+}
+
+func (s *IPFIXSink) templateSet() []byte {
+	setID := uint16(ipfixTemplateSetID)
+	if s.protocol == ExportProtocolNetFlowV9 {
+		setID = netflowV9TemplateSetID
+	}
+	var b bytes.Buffer
+	for _, v6 := range []bool{false, true} {
+		templateID := uint16(templateIDv4)
+		if v6 {
+			templateID = templateIDv6
+		}
+		fields := s.templateFields(v6)
+		writeUint16(&b, templateID)
+		writeUint16(&b, uint16(len(fields)))
+		for _, f := range fields {
+			writeUint16(&b, f.id)
+			writeUint16(&b, f.length)
+		}
+	}
+	return wrapSet(setID, b.Bytes())
+}
+
+// encodeRecord encodes the data record for the given flow log, returning the ID of the template
+// that it uses.  Flow logs that don't have valid IP addresses are skipped.
+func (s *IPFIXSink) encodeRecord(l *FlowLog) (templateID uint16, record []byte, ok bool) {
+	src, dst := net.ParseIP(l.SrcIP), net.ParseIP(l.DstIP)
+	if src == nil || dst == nil {
+		return 0, nil, false
+	}
+	var b bytes.Buffer
+	if src4, dst4 := src.To4(), dst.To4(); src4 != nil && dst4 != nil {
+		templateID = templateIDv4
+		b.Write(src4)
+		b.Write(dst4)
+	} else {
+		templateID = templateIDv6
+		b.Write(src.To16())
+		b.Write(dst.To16())
+	}
+	b.WriteByte(l.Proto)
+	writeUint16(&b, l.DstPort)
+	if l.Verdict == VerdictDeny {
+		b.WriteByte(forwardingStatusDropped)
+	} else {
+		b.WriteByte(forwardingStatusForwarded)
+	}
+	writeUint64(&b, l.Packets)
+	writeUint64(&b, l.Bytes)
+	if s.protocol == ExportProtocolNetFlowV9 {
+		writeUint32(&b, s.uptimeMillis(l.StartTime))
+		writeUint32(&b, s.uptimeMillis(l.EndTime))
+	} else {
+		writeUint64(&b, uint64(l.StartTime.UnixNano()/int64(time.Millisecond)))
+		writeUint64(&b, uint64(l.EndTime.UnixNano()/int64(time.Millisecond)))
+	}
+	return templateID, b.Bytes(), true
+}
+
+// uptimeMillis converts a timestamp to the NetFlow v9 representation: milliseconds since the
+// exporter started.
+func (s *IPFIXSink) uptimeMillis(t time.Time) uint32 {
+	return uint32(t.Sub(s.startTime) / time.Millisecond)
+}
+
+// buildMessages encodes the flow logs as a series of export messages, each of which fits in a
+// single datagram.  The first message carries the templates.
+func (s *IPFIXSink) buildMessages(logs []*FlowLog) [][]byte {
+	type pendingSet struct {
+		templateID uint16
+		records    [][]byte
+	}
+	var msgs [][]byte
+	var sets []pendingSet
+	templates := s.templateSet()
+	msgLen := s.headerLen() + len(templates)
+	numRecords := 0
+
+	flush := func() {
+		if len(sets) == 0 && templates == nil {
+			return
+		}
+		var body bytes.Buffer
+		count := 0
+		if templates != nil {
+			body.Write(templates)
+			count += 2
+			templates = nil
+		}
+		for _, set := range sets {
+			body.Write(wrapSet(set.templateID, bytes.Join(set.records, nil)))
+			count += len(set.records)
+		}
+		msgs = append(msgs, s.wrapMessage(body.Bytes(), count, numRecords))
+		sets = nil
+		msgLen = s.headerLen()
+		numRecords = 0
+	}
+
+	for _, l := range logs {
+		templateID, record, ok := s.encodeRecord(l)
+		if !ok {
+			continue
+		}
+		extra := len(record)
+		if len(sets) == 0 || sets[len(sets)-1].templateID != templateID {
+			// Allow for the set header and worst-case padding.
+			extra += 4 + 3
+		}
+		if msgLen+extra > maxExportMsgLen {
+			flush()
+			extra = len(record) + 4 + 3
+		}
+		if len(sets) == 0 || sets[len(sets)-1].templateID != templateID {
+			sets = append(sets, pendingSet{templateID: templateID})
+		}
+		sets[len(sets)-1].records = append(sets[len(sets)-1].records, record)
+		msgLen += extra
+		numRecords++
+	}
+	flush()
+	return msgs
+}
+
+func (s *IPFIXSink) headerLen() int {
+	if s.protocol == ExportProtocolNetFlowV9 {
+		return 20
+	}
+	return 16
+}
+
+// wrapMessage prepends the message header.  count is the total number of template and data
+// records in the message (used by NetFlow v9); numDataRecords is used to advance the IPFIX
+// sequence number.
+func (s *IPFIXSink) wrapMessage(body []byte, count, numDataRecords int) []byte {
+	var b bytes.Buffer
+	now := s.now()
+	if s.protocol == ExportProtocolNetFlowV9 {
+		writeUint16(&b, netflowV9Version)
+		writeUint16(&b, uint16(count))
+		writeUint32(&b, s.uptimeMillis(now))
+		writeUint32(&b, uint32(now.Unix()))
+		writeUint32(&b, s.seq)
+		writeUint32(&b, s.domainID)
+		s.seq++
+	} else {
+		writeUint16(&b, ipfixVersion)
+		writeUint16(&b, uint16(16+len(body)))
+		writeUint32(&b, uint32(now.Unix()))
+		writeUint32(&b, s.seq)
+		writeUint32(&b, s.domainID)
+		s.seq += uint32(numDataRecords)
+	}
+	b.Write(body)
+	return b.Bytes()
+}
+
+// wrapSet prepends a set (FlowSet in NetFlow v9) header and pads the set to a multiple of 4
+// bytes.
+func wrapSet(setID uint16, contents []byte) []byte {
+	length := nlAlign(4 + len(contents))
+	b := make([]byte, length)
+	binary.BigEndian.PutUint16(b[0:2], setID)
+	binary.BigEndian.PutUint16(b[2:4], uint16(length))
+	copy(b[4:], contents)
+	return b
+}
+
+func writeUint16(b *bytes.Buffer, v uint16) {
+	var buf [2]byte
+	binary.BigEndian.PutUint16(buf[:], v)
+	b.Write(buf[:])
+}
+
+func writeUint32(b *bytes.Buffer, v uint32) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	b.Write(buf[:])
+}
+
+func writeUint64(b *bytes.Buffer, v uint64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	b.Write(buf[:])
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flowlogs
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// parsedSet is a set (FlowSet) from an export message.
+type parsedSet struct {
+	id   uint16
+	data []byte
+}
+
+func parseSets(b []byte) []parsedSet {
+	var sets []parsedSet
+	for len(b) > 0 {
+		Expect(len(b)).To(BeNumerically(">=", 4))
+		l := int(binary.BigEndian.Uint16(b[2:4]))
+		Expect(l%4).To(BeZero(), "set not padded")
+		Expect(l).To(BeNumerically("<=", len(b)))
+		sets = append(sets, parsedSet{id: binary.BigEndian.Uint16(b[0:2]), data: b[4:l]})
+		b = b[l:]
+	}
+	return sets
+}
+
+var _ = Describe("IPFIX sink", func() {
+	var (
+		collector *net.UDPConn
+		sink      *IPFIXSink
+	)
+
+	logs := []*FlowLog{
+		{FlowKey: denyKey, StartTime: t0, EndTime: t1, Packets: 2, Bytes: 100},
+		{FlowKey: allowKey, StartTime: t0, EndTime: t1, Packets: 3, Bytes: 300},
+		{FlowKey: FlowKey{SrcIP: "fd00::1", DstIP: "fd00::2", Proto: 17, DstPort: 53, Verdict: VerdictAllow},
+			StartTime: t0, EndTime: t1, Packets: 1, Bytes: 80},
+	}
+
+	newSink := func(protocol string) {
+		var err error
+		sink, err = NewIPFIXSink(protocol, collector.LocalAddr().String(), 7)
+		Expect(err).NotTo(HaveOccurred())
+		sink.startTime = t0.Add(-time.Minute)
+		sink.now = func() time.Time { return t1 }
+	}
+
+	receive := func() []byte {
+		buf := make([]byte, 65536)
+		Expect(collector.SetReadDeadline(time.Now().Add(5 * time.Second))).To(Succeed())
+		n, err := collector.Read(buf)
+		Expect(err).NotTo(HaveOccurred())
+		return buf[:n]
+	}
+
+	BeforeEach(func() {
+		var err error
+		collector, err = net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		collector.Close()
+	})
+
+	It("should reject an unknown protocol", func() {
+		_, err := NewIPFIXSink("sFlow", collector.LocalAddr().String(), 0)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should export IPFIX templates and records", func() {
+		newSink(ExportProtocolIPFIX)
+		Expect(sink.Name()).To(Equal("ipfix"))
+		Expect(sink.Emit(logs)).To(Succeed())
+
+		msg := receive()
+		Expect(binary.BigEndian.Uint16(msg[0:2])).To(Equal(uint16(10)))
+		Expect(int(binary.BigEndian.Uint16(msg[2:4]))).To(Equal(len(msg)))
+		Expect(binary.BigEndian.Uint32(msg[4:8])).To(Equal(uint32(t1.Unix())))
+		Expect(binary.BigEndian.Uint32(msg[8:12])).To(BeZero())
+		Expect(binary.BigEndian.Uint32(msg[12:16])).To(Equal(uint32(7)))
+
+		sets := parseSets(msg[16:])
+		Expect(sets).To(HaveLen(3))
+		Expect(sets[0].id).To(Equal(uint16(ipfixTemplateSetID)))
+		// First template: ID 256 with 9 fields, starting with sourceIPv4Address.
+		Expect(sets[0].data[0:8]).To(Equal([]byte{1, 0, 0, 9, 0, 8, 0, 4}))
+
+		Expect(sets[1].id).To(Equal(uint16(templateIDv4)))
+		rec := sets[1].data
+		Expect(rec[0:4]).To(Equal([]byte{10, 0, 0, 1}))
+		Expect(rec[4:8]).To(Equal([]byte{10, 0, 0, 2}))
+		Expect(rec[8]).To(Equal(uint8(6)))
+		Expect(binary.BigEndian.Uint16(rec[9:11])).To(Equal(uint16(80)))
+		Expect(rec[11]).To(Equal(uint8(forwardingStatusDropped)))
+		Expect(binary.BigEndian.Uint64(rec[12:20])).To(Equal(uint64(2)))
+		Expect(binary.BigEndian.Uint64(rec[20:28])).To(Equal(uint64(100)))
+		Expect(binary.BigEndian.Uint64(rec[28:36])).To(Equal(uint64(t0.UnixNano() / 1e6)))
+		Expect(binary.BigEndian.Uint64(rec[36:44])).To(Equal(uint64(t1.UnixNano() / 1e6)))
+		// Second IPv4 record follows in the same set.
+		Expect(rec[44+11]).To(Equal(uint8(forwardingStatusForwarded)))
+
+		Expect(sets[2].id).To(Equal(uint16(templateIDv6)))
+		Expect(net.IP(sets[2].data[0:16]).String()).To(Equal("fd00::1"))
+
+		By("advancing the sequence number by the number of data records")
+		Expect(sink.Emit(logs[:1])).To(Succeed())
+		msg = receive()
+		Expect(binary.BigEndian.Uint32(msg[8:12])).To(Equal(uint32(3)))
+	})
+
+	It("should export NetFlow v9 templates and records", func() {
+		newSink(ExportProtocolNetFlowV9)
+		Expect(sink.Name()).To(Equal("netflow9"))
+		Expect(sink.Emit(logs[:1])).To(Succeed())
+
+		msg := receive()
+		Expect(binary.BigEndian.Uint16(msg[0:2])).To(Equal(uint16(9)))
+		// Two templates plus one data record.
+		Expect(binary.BigEndian.Uint16(msg[2:4])).To(Equal(uint16(3)))
+		Expect(binary.BigEndian.Uint32(msg[4:8])).To(Equal(uint32(6 * 60 * 1000)))
+		Expect(binary.BigEndian.Uint32(msg[12:16])).To(BeZero())
+		Expect(binary.BigEndian.Uint32(msg[16:20])).To(Equal(uint32(7)))
+
+		sets := parseSets(msg[20:])
+		Expect(sets).To(HaveLen(2))
+		Expect(sets[0].id).To(Equal(uint16(netflowV9TemplateSetID)))
+		rec := sets[1].data
+		Expect(binary.BigEndian.Uint32(rec[28:32])).To(Equal(uint32(60 * 1000)))
+		Expect(binary.BigEndian.Uint32(rec[32:36])).To(Equal(uint32(6 * 60 * 1000)))
+	})
+
+	It("should split large batches across datagrams", func() {
+		newSink(ExportProtocolIPFIX)
+		var many []*FlowLog
+		for i := 0; i < 100; i++ {
+			many = append(many, &FlowLog{
+				FlowKey:   FlowKey{SrcIP: fmt.Sprintf("10.0.1.%d", i), DstIP: "10.0.0.2", Proto: 6, DstPort: 80},
+				StartTime: t0, EndTime: t1, Packets: 1, Bytes: 60,
+			})
+		}
+		msgs := sink.buildMessages(many)
+		Expect(len(msgs)).To(BeNumerically(">", 1))
+		total := 0
+		for _, msg := range msgs {
+			Expect(len(msg)).To(BeNumerically("<=", maxExportMsgLen))
+			for _, set := range parseSets(msg[16:]) {
+				if set.id == templateIDv4 {
+					total += len(set.data) / 44
+				}
+			}
+		}
+		Expect(total).To(Equal(100))
+	})
+})
@@ -0,0 +1,303 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flowlogs
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+
+	"github.com/projectcalico/felix/rules"
+)
+
+// Constants from linux/netfilter/nfnetlink.h and linux/netfilter/nfnetlink_log.h.
+const (
+	nfnlSubsysULOG = 4
+
+	nfulnlMsgPacket = 0
+	nfulnlMsgConfig = 1
+
+	nfulaCfgCmd  = 1
+	nfulaCfgMode = 2
+
+	nfulnlCfgCmdBind = 1
+	nfulnlCopyPacket = 2
+
+	nfulaPayload = 9
+	nfulaPrefix  = 10
+
+	nlaTypeMask = 0x3fff
+
+	// nflogCopyRange is the number of bytes of each packet that we ask the kernel for; enough for
+	// the IP and L4 headers.
+	nflogCopyRange = 80
+
+	nflogRestartDelay = 5 * time.Second
+)
+
+// NFLOGSource reports denied packets, which the iptables rules send to NFLOG group
+// rules.NFLOGDenyGroup with a prefix that identifies the denying policy.
+type NFLOGSource struct {
+	agg    *Aggregator
+	events *DenyEventStream
+	group  uint16
+}
+
+// NewNFLOGSource creates an NFLOGSource that records denied packets in the given Aggregator and,
+// if it is non-nil, the given DenyEventStream.
+func NewNFLOGSource(agg *Aggregator, events *DenyEventStream) *NFLOGSource {
+	return &NFLOGSource{
+		agg:    agg,
+		events: events,
+		group:  rules.NFLOGDenyGroup,
+	}
+}
+
+func (s *NFLOGSource) Run() {
+	for {
+		err := s.readLoop()
+		log.WithError(err).Error("Failed to read NFLOG messages, will retry.")
+		time.Sleep(nflogRestartDelay)
+	}
+}
+
+func (s *NFLOGSource) readLoop() error {
+	log.WithField("group", s.group).Info("Listening for denied packets on NFLOG group.")
+	return readNFLOG(s.group, func(data []byte) {
+		pkt, err := parseNFLOGPacket(data)
+		if err != nil {
+			log.WithError(err).Debug("Ignoring unparseable NFLOG packet.")
+			return
+		}
+		if s.agg != nil {
+			s.agg.Record(pkt.Key, 1, uint64(pkt.Length))
+		}
+		if s.events != nil {
+			s.events.OnDeniedPacket(pkt)
+		}
+	})
+}
+
+// readNFLOG binds to the given NFLOG group and calls onPacket with the body of each
+// NFULNL_MSG_PACKET message.  It only returns on error.
+func readNFLOG(group uint16, onPacket func(data []byte)) error {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW, unix.NETLINK_NETFILTER)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return err
+	}
+	if err := unix.Sendto(fd, nflogBindMsg(group), 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err == unix.ENOBUFS {
+			// The kernel dropped some messages because we didn't keep up; we'll under-count but
+			// there's nothing else to do.
+			log.WithField("group", group).Debug("NFLOG socket buffer overflowed, some packets were not recorded.")
+			continue
+		} else if err != nil {
+			return err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			log.WithError(err).Warn("Failed to parse NFLOG netlink message.")
+			continue
+		}
+		for _, msg := range msgs {
+			switch msg.Header.Type {
+			case unix.NLMSG_ERROR:
+				if len(msg.Data) >= 4 {
+					if errno := int32(nl.NativeEndian().Uint32(msg.Data[:4])); errno != 0 {
+						return syscall.Errno(-errno)
+					}
+				}
+			case nfnlSubsysULOG<<8 | nfulnlMsgPacket:
+				onPacket(msg.Data)
+			}
+		}
+	}
+}
+
+// nflogBindMsg builds the NFULNL_MSG_CONFIG message that binds our socket to the given group and
+// asks for the start of each packet to be copied to us.
+func nflogBindMsg(group uint16) []byte {
+	cmd := nflogAttr(nfulaCfgCmd, []byte{nfulnlCfgCmdBind})
+	mode := make([]byte, 6)
+	binary.BigEndian.PutUint32(mode[0:4], nflogCopyRange)
+	mode[4] = nfulnlCopyPacket
+	modeAttr := nflogAttr(nfulaCfgMode, mode)
+
+	body := make([]byte, 4)
+	body[0] = unix.AF_UNSPEC
+	body[1] = 0 // NFNETLINK_V0
+	binary.BigEndian.PutUint16(body[2:4], group)
+	body = append(body, cmd...)
+	body = append(body, modeAttr...)
+
+	hdr := make([]byte, unix.NLMSG_HDRLEN)
+	ne := nl.NativeEndian()
+	ne.PutUint32(hdr[0:4], uint32(len(hdr)+len(body)))
+	ne.PutUint16(hdr[4:6], nfnlSubsysULOG<<8|nfulnlMsgConfig)
+	ne.PutUint16(hdr[6:8], unix.NLM_F_REQUEST|unix.NLM_F_ACK)
+	return append(hdr, body...)
+}
+
+func nflogAttr(attrType uint16, value []byte) []byte {
+	length := 4 + len(value)
+	b := make([]byte, nlAlign(length))
+	ne := nl.NativeEndian()
+	ne.PutUint16(b[0:2], uint16(length))
+	ne.PutUint16(b[2:4], attrType)
+	copy(b[4:], value)
+	return b
+}
+
+func nlAlign(l int) int {
+	return (l + 3) &^ 3
+}
+
+// deniedPacket is a packet received from the NFLOG group.
+type deniedPacket struct {
+	Key    FlowKey
+	Length int
+	// Direction is rules.NFLOGDirInbound or rules.NFLOGDirOutbound.
+	Direction string
+	// RuleIndex is the index of the denying rule within the policy or profile; -1 if the packet
+	// was denied because no policy or profile matched.
+	RuleIndex int
+}
+
+// parseNFLOGPacket parses the body of an NFULNL_MSG_PACKET message (starting with the nfgenmsg
+// header) and returns the denied packet.
+func parseNFLOGPacket(data []byte) (pkt deniedPacket, err error) {
+	if len(data) < 4 {
+		return pkt, errors.New("message too short")
+	}
+	family := data[0]
+	prefix, payload, err := parseNFLOGAttrs(data)
+	if err != nil {
+		return pkt, err
+	}
+	dir, ruleIdx, name, err := parseDenyPrefix(prefix)
+	if err != nil {
+		return pkt, err
+	}
+	pkt.Key, pkt.Length, err = parseIPHeader(family, payload)
+	pkt.Key.Verdict = VerdictDeny
+	pkt.Key.Policy = name
+	pkt.Direction = dir
+	pkt.RuleIndex = ruleIdx
+	return
+}
+
+// parseNFLOGAttrs extracts the prefix and payload from the body of an NFULNL_MSG_PACKET message.
+func parseNFLOGAttrs(data []byte) (prefix string, payload []byte, err error) {
+	if len(data) < 4 {
+		return "", nil, errors.New("message too short")
+	}
+	ne := nl.NativeEndian()
+	for attrs := data[4:]; len(attrs) >= 4; {
+		l := int(ne.Uint16(attrs[0:2]))
+		if l < 4 || l > len(attrs) {
+			return "", nil, errors.New("bad attribute length")
+		}
+		value := attrs[4:l]
+		switch ne.Uint16(attrs[2:4]) & nlaTypeMask {
+		case nfulaPrefix:
+			prefix = strings.TrimRight(string(value), "\x00")
+		case nfulaPayload:
+			payload = value
+		}
+		if nlAlign(l) >= len(attrs) {
+			break
+		}
+		attrs = attrs[nlAlign(l):]
+	}
+	return prefix, payload, nil
+}
+
+// parseDenyPrefix parses an NFLOG prefix of the form "D|<direction><rule index>|<name>".
+func parseDenyPrefix(prefix string) (dir string, ruleIdx int, name string, err error) {
+	if !strings.HasPrefix(prefix, rules.NFLOGDenyPrefix) {
+		return "", 0, "", errors.New("unexpected NFLOG prefix: " + prefix)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(prefix, rules.NFLOGDenyPrefix), "|", 2)
+	if len(parts) != 2 || len(parts[0]) < 2 {
+		return "", 0, "", errors.New("malformed NFLOG prefix: " + prefix)
+	}
+	dir = parts[0][:1]
+	if dir != rules.NFLOGDirInbound && dir != rules.NFLOGDirOutbound {
+		return "", 0, "", errors.New("bad direction in NFLOG prefix: " + prefix)
+	}
+	ruleIdx = -1
+	if idx := parts[0][1:]; idx != rules.NFLOGNoRuleIndex {
+		ruleIdx, err = strconv.Atoi(idx)
+		if err != nil {
+			return "", 0, "", errors.New("bad rule index in NFLOG prefix: " + prefix)
+		}
+	}
+	return dir, ruleIdx, parts[1], nil
+}
+
+func parseIPHeader(family uint8, b []byte) (key FlowKey, length int, err error) {
+	var l4 []byte
+	switch family {
+	case unix.AF_INET:
+		if len(b) < 20 {
+			return key, 0, errors.New("IPv4 header too short")
+		}
+		ihl := int(b[0]&0xf) * 4
+		key.Proto = b[9]
+		key.SrcIP = net.IP(b[12:16]).String()
+		key.DstIP = net.IP(b[16:20]).String()
+		length = int(binary.BigEndian.Uint16(b[2:4]))
+		if ihl <= len(b) {
+			l4 = b[ihl:]
+		}
+	case unix.AF_INET6:
+		if len(b) < 40 {
+			return key, 0, errors.New("IPv6 header too short")
+		}
+		// Note: we don't walk IPv6 extension headers.
+		key.Proto = b[6]
+		key.SrcIP = net.IP(b[8:24]).String()
+		key.DstIP = net.IP(b[24:40]).String()
+		length = 40 + int(binary.BigEndian.Uint16(b[4:6]))
+		l4 = b[40:]
+	default:
+		return key, 0, errors.New("unknown address family")
+	}
+	switch key.Proto {
+	case unix.IPPROTO_TCP, unix.IPPROTO_UDP, unix.IPPROTO_SCTP, unix.IPPROTO_UDPLITE:
+		if len(l4) >= 4 {
+			key.DstPort = binary.BigEndian.Uint16(l4[2:4])
+		}
+	}
+	return key, length, nil
+}
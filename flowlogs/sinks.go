@@ -0,0 +1,182 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flowlogs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileSink appends flow logs to a file as JSON, one flow log per line.  The file is reopened for
+// each batch so that it can be rotated by an external tool such as logrotate.
+type FileSink struct {
+	path string
+}
+
+func NewFileSink(path string) *FileSink {
+	return &FileSink{path: path}
+}
+
+func (s *FileSink) Name() string {
+	return "file"
+}
+
+func (s *FileSink) Emit(logs []*FlowLog) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, l := range logs {
+		if err := enc.Encode(l); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// SyslogSink sends each flow log to the local syslog daemon as a JSON message.
+type SyslogSink struct {
+	writer *syslog.Writer
+}
+
+func NewSyslogSink() (*SyslogSink, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_LOCAL0, "calico-felix-flowlogs")
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogSink{writer: w}, nil
+}
+
+func (s *SyslogSink) Name() string {
+	return "syslog"
+}
+
+func (s *SyslogSink) Emit(logs []*FlowLog) error {
+	for _, l := range logs {
+		msg, err := json.Marshal(l)
+		if err != nil {
+			return err
+		}
+		if err := s.writer.Info(string(msg)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OTLPSink sends flow logs to an OpenTelemetry collector as OTLP/HTTP log records (JSON
+// encoding).  The flow log fields are sent as log record attributes.
+type OTLPSink struct {
+	url      string
+	hostname string
+	client   *http.Client
+}
+
+func NewOTLPSink(endpoint, host string) *OTLPSink {
+	if host == "" {
+		host = hostname()
+	}
+	return &OTLPSink{
+		url:      strings.TrimSuffix(endpoint, "/") + "/v1/logs",
+		hostname: host,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *OTLPSink) Name() string {
+	return "otlp"
+}
+
+func (s *OTLPSink) Emit(logs []*FlowLog) error {
+	body, err := json.Marshal(s.buildRequest(logs))
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected response from collector: %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *OTLPSink) buildRequest(logs []*FlowLog) map[string]interface{} {
+	records := make([]interface{}, 0, len(logs))
+	for _, l := range logs {
+		attrs := []interface{}{
+			otlpString("source.address", l.SrcIP),
+			otlpString("destination.address", l.DstIP),
+			otlpInt("network.protocol", uint64(l.Proto)),
+			otlpInt("destination.port", uint64(l.DstPort)),
+			otlpString("calico.verdict", string(l.Verdict)),
+			otlpInt("calico.packets", l.Packets),
+			otlpInt("calico.bytes", l.Bytes),
+			otlpString("calico.start_time", l.StartTime.UTC().Format(time.RFC3339)),
+		}
+		if l.Policy != "" {
+			attrs = append(attrs, otlpString("calico.policy", l.Policy))
+		}
+		records = append(records, map[string]interface{}{
+			"timeUnixNano": strconv.FormatInt(l.EndTime.UnixNano(), 10),
+			"body":         map[string]interface{}{"stringValue": "flow"},
+			"attributes":   attrs,
+		})
+	}
+	return map[string]interface{}{
+		"resourceLogs": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": []interface{}{
+					otlpString("service.name", "calico-felix"),
+					otlpString("host.name", s.hostname),
+				},
+			},
+			"scopeLogs": []interface{}{map[string]interface{}{
+				"scope":      map[string]interface{}{"name": "github.com/projectcalico/felix/flowlogs"},
+				"logRecords": records,
+			}},
+		}},
+	}
+}
+
+func otlpString(key, value string) map[string]interface{} {
+	return map[string]interface{}{"key": key, "value": map[string]interface{}{"stringValue": value}}
+}
+
+func otlpInt(key string, value uint64) map[string]interface{} {
+	// OTLP JSON encodes 64-bit integers as strings.
+	return map[string]interface{}{"key": key, "value": map[string]interface{}{"intValue": strconv.FormatUint(value, 10)}}
+}
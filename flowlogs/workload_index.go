@@ -0,0 +1,87 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flowlogs
+
+import (
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/projectcalico/felix/proto"
+)
+
+// WorkloadIndex maps the IPs of local workload endpoints to the endpoints' IDs.  It implements
+// the dataplane's Manager interface so that it can be registered with the dataplane driver to
+// receive endpoint updates.
+type WorkloadIndex struct {
+	lock        sync.RWMutex
+	ipToID      map[string]proto.WorkloadEndpointID
+	endpointIPs map[proto.WorkloadEndpointID][]string
+}
+
+func NewWorkloadIndex() *WorkloadIndex {
+	return &WorkloadIndex{
+		ipToID:      map[string]proto.WorkloadEndpointID{},
+		endpointIPs: map[proto.WorkloadEndpointID][]string{},
+	}
+}
+
+func (idx *WorkloadIndex) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *proto.WorkloadEndpointUpdate:
+		var ips []string
+		for _, nets := range [][]string{msg.Endpoint.Ipv4Nets, msg.Endpoint.Ipv6Nets} {
+			for _, n := range nets {
+				ip := net.ParseIP(strings.Split(n, "/")[0])
+				if ip != nil {
+					ips = append(ips, ip.String())
+				}
+			}
+		}
+		idx.setEndpointIPs(*msg.Id, ips)
+	case *proto.WorkloadEndpointRemove:
+		idx.setEndpointIPs(*msg.Id, nil)
+	}
+}
+
+func (idx *WorkloadIndex) CompleteDeferredWork() error {
+	return nil
+}
+
+func (idx *WorkloadIndex) setEndpointIPs(id proto.WorkloadEndpointID, ips []string) {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	for _, ip := range idx.endpointIPs[id] {
+		if idx.ipToID[ip] == id {
+			delete(idx.ipToID, ip)
+		}
+	}
+	if len(ips) == 0 {
+		delete(idx.endpointIPs, id)
+		return
+	}
+	idx.endpointIPs[id] = ips
+	for _, ip := range ips {
+		idx.ipToID[ip] = id
+	}
+}
+
+// Lookup returns the ID of the local workload endpoint that has the given IP.
+func (idx *WorkloadIndex) Lookup(ip string) (proto.WorkloadEndpointID, bool) {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+	id, ok := idx.ipToID[ip]
+	return id, ok
+}
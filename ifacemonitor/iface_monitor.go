@@ -21,6 +21,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 	"github.com/vishvananda/netlink"
 	"golang.org/x/sys/unix"
@@ -34,9 +35,26 @@ type netlinkStub interface {
 		routeUpdates chan netlink.RouteUpdate,
 	) (cancel chan struct{}, err error)
 	LinkList() ([]netlink.Link, error)
+	// ListLocalRoutes lists the local routes of the given link or, if link is nil, of all links.
 	ListLocalRoutes(link netlink.Link, family int) ([]netlink.Route, error)
 }
 
+var (
+	countNetlinkGaps = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "felix_iface_monitor_netlink_gaps",
+		Help: "Number of times that netlink updates were lost because the subscription socket overflowed.",
+	})
+	countResyncs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "felix_iface_monitor_resyncs",
+		Help: "Number of full resyncs of interface state, by reason.",
+	}, []string{"reason"})
+)
+
+func init() {
+	prometheus.MustRegister(countNetlinkGaps)
+	prometheus.MustRegister(countResyncs)
+}
+
 type State string
 
 const (
@@ -51,7 +69,9 @@ type AddrStateCallback func(ifaceName string, addrs set.Set)
 type Config struct {
 	// InterfaceExcludes is a list of interface names that we don't want callbacks for.
 	InterfaceExcludes []*regexp.Regexp
-	// ResyncInterval is the interval at which we rescan all the interfaces.  If <0 rescan is disabled.
+	// ResyncInterval is the interval at which we rescan all the interfaces.  If <=0 rescan is
+	// disabled and we rely on the netlink subscriptions, only rescanning after a netlink failure
+	// (such as a socket overflow) may have caused us to miss updates.
 	ResyncInterval time.Duration
 }
 type InterfaceMonitor struct {
@@ -100,6 +120,7 @@ func (m *InterfaceMonitor) MonitorInterfaces() {
 	log.Info("Interface monitoring thread started.")
 
 	// Reconnection loop.
+	resyncReason := "initial"
 	for {
 		var nlCancelC chan struct{}
 		filterUpdatesCtx, filterUpdatesCancel := context.WithCancel(context.Background())
@@ -117,10 +138,11 @@ func (m *InterfaceMonitor) MonitorInterfaces() {
 		}
 		log.Info("Subscribed to netlink updates.")
 
-		// Do a resync to notify all our existing interfaces.  We also do periodic
-		// resyncs because it's not clear what the ordering guarantees are for our netlink
-		// subscription vs a list operation as used by resync().
-		err := m.resync()
+		// Do a resync to notify all our existing interfaces.  On the first pass, this is our
+		// initial snapshot; on later passes, we're reconnecting after a failure that may
+		// have caused us to miss updates so we need to fill in the gap.  After that, the
+		// subscriptions tell us about every change.
+		err := m.resync(resyncReason)
 		if err != nil {
 			m.fatalErrCallback(fmt.Errorf("failed to read from netlink (initial resync): %w", err))
 		}
@@ -149,7 +171,7 @@ func (m *InterfaceMonitor) MonitorInterfaces() {
 				m.handleNetlinkRouteUpdate(routeUpdate)
 			case <-m.resyncC:
 				log.Debug("Resync trigger")
-				err := m.resync()
+				err := m.resync("periodic")
 				if err != nil {
 					m.fatalErrCallback(fmt.Errorf("failed to read from netlink (resync): %w", err))
 				}
@@ -158,6 +180,7 @@ func (m *InterfaceMonitor) MonitorInterfaces() {
 		close(nlCancelC)
 		filterUpdatesCancel()
 		log.Warn("Reconnecting to netlink after a failure...")
+		resyncReason = "reconnect"
 	}
 }
 
@@ -246,6 +269,12 @@ func (m *InterfaceMonitor) notifyIfaceAddrs(ifIndex int) {
 }
 
 func (m *InterfaceMonitor) storeAndNotifyLink(ifaceExists bool, link netlink.Link) {
+	m.storeAndNotifyLinkWithAddrs(ifaceExists, link, nil)
+}
+
+// storeAndNotifyLinkWithAddrs is as storeAndNotifyLink but, if addrs is non-nil, it is used as the
+// link's current set of addresses instead of querying netlink for them.
+func (m *InterfaceMonitor) storeAndNotifyLinkWithAddrs(ifaceExists bool, link netlink.Link, addrs set.Set) {
 	attrs := link.Attrs()
 	ifIndex := attrs.Index
 	newName := attrs.Name
@@ -260,10 +289,10 @@ func (m *InterfaceMonitor) storeAndNotifyLink(ifaceExists bool, link netlink.Lin
 			"oldName": oldName,
 			"newName": newName,
 		}).Info("Interface renamed, simulating deletion of old copy.")
-		m.storeAndNotifyLinkInner(false, oldName, link)
+		m.storeAndNotifyLinkInner(false, oldName, link, nil)
 	}
 
-	m.storeAndNotifyLinkInner(ifaceExists, newName, link)
+	m.storeAndNotifyLinkInner(ifaceExists, newName, link, addrs)
 }
 
 func linkIsOperUp(link netlink.Link) bool {
@@ -279,7 +308,7 @@ func linkIsOperUp(link netlink.Link) bool {
 	return ifaceIsUp
 }
 
-func (m *InterfaceMonitor) storeAndNotifyLinkInner(ifaceExists bool, ifaceName string, link netlink.Link) {
+func (m *InterfaceMonitor) storeAndNotifyLinkInner(ifaceExists bool, ifaceName string, link netlink.Link, addrs set.Set) {
 	log.WithFields(log.Fields{
 		"ifaceExists": ifaceExists,
 		"ifaceName":   ifaceName,
@@ -326,18 +355,21 @@ func (m *InterfaceMonitor) storeAndNotifyLinkInner(ifaceExists bool, ifaceName s
 	// a small window of insecurity.
 	if ifaceExists && !m.isExcludedInterface(ifaceName) {
 		// Notify address changes for non excluded interfaces.
-		newAddrs := set.New()
-		for _, family := range [2]int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
-			routes, err := m.netlinkStub.ListLocalRoutes(link, family)
-			if err != nil {
-				log.WithError(err).Warn("Netlink route list operation failed.")
-			}
-			for _, route := range routes {
-				if !routeIsLocalUnicast(route) {
-					log.WithField("route", route).Debug("Ignoring non-local route.")
-					continue
+		newAddrs := addrs
+		if newAddrs == nil {
+			newAddrs = set.New()
+			for _, family := range [2]int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+				routes, err := m.netlinkStub.ListLocalRoutes(link, family)
+				if err != nil {
+					log.WithError(err).Warn("Netlink route list operation failed.")
+				}
+				for _, route := range routes {
+					if !routeIsLocalUnicast(route) {
+						log.WithField("route", route).Debug("Ignoring non-local route.")
+						continue
+					}
+					newAddrs.Add(route.Dst.IP.String())
 				}
-				newAddrs.Add(route.Dst.IP.String())
 			}
 		}
 		if (m.ifaceAddrs[ifIndex] == nil) || !m.ifaceAddrs[ifIndex].Equals(newAddrs) {
@@ -352,13 +384,23 @@ func (m *InterfaceMonitor) storeAndNotifyLinkInner(ifaceExists bool, ifaceName s
 	}
 }
 
-func (m *InterfaceMonitor) resync() error {
-	log.Debug("Resyncing interface state.")
+// resync lists all interfaces and their addresses and notifies any differences from our cached
+// state.  To keep the cost down on hosts with many interfaces, it does a single dump of the local
+// routes for each IP family rather than listing the routes of each interface in turn.
+func (m *InterfaceMonitor) resync(reason string) error {
+	log.WithField("reason", reason).Debug("Resyncing interface state.")
+	countResyncs.WithLabelValues(reason).Inc()
 	links, err := m.netlinkStub.LinkList()
 	if err != nil {
 		log.WithError(err).Warn("Netlink list operation failed.")
 		return err
 	}
+	addrsByIfIndex, err := m.listAllLocalAddrs()
+	if err != nil {
+		// Fall back to listing the addresses of each interface.
+		log.WithError(err).Warn("Netlink route dump failed, listing addresses per-interface.")
+		addrsByIfIndex = nil
+	}
 	currentIfaces := set.New()
 	for _, link := range links {
 		attrs := link.Attrs()
@@ -369,7 +411,14 @@ func (m *InterfaceMonitor) resync() error {
 			continue
 		}
 		currentIfaces.Add(attrs.Name)
-		m.storeAndNotifyLink(true, link)
+		var addrs set.Set
+		if addrsByIfIndex != nil {
+			addrs = addrsByIfIndex[attrs.Index]
+			if addrs == nil {
+				addrs = set.New()
+			}
+		}
+		m.storeAndNotifyLinkWithAddrs(true, link, addrs)
 	}
 	for name, ifIndex := range m.upIfaces {
 		if currentIfaces.Contains(name) {
@@ -385,3 +434,25 @@ func (m *InterfaceMonitor) resync() error {
 	log.Debug("Resync complete")
 	return nil
 }
+
+// listAllLocalAddrs dumps the local routes of all interfaces and returns the local unicast
+// addresses, grouped by interface index.
+func (m *InterfaceMonitor) listAllLocalAddrs() (map[int]set.Set, error) {
+	addrsByIfIndex := map[int]set.Set{}
+	for _, family := range [2]int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		routes, err := m.netlinkStub.ListLocalRoutes(nil, family)
+		if err != nil {
+			return nil, err
+		}
+		for _, route := range routes {
+			if !routeIsLocalUnicast(route) {
+				continue
+			}
+			if addrsByIfIndex[route.LinkIndex] == nil {
+				addrsByIfIndex[route.LinkIndex] = set.New()
+			}
+			addrsByIfIndex[route.LinkIndex].Add(route.Dst.IP.String())
+		}
+	}
+	return addrsByIfIndex, nil
+}
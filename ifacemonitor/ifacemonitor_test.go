@@ -57,6 +57,10 @@ type netlinkTest struct {
 	// in the same function).
 	linksMutex  sync.Mutex
 	LinkListErr error
+
+	// Counts of calls to ListLocalRoutes for a single link and for all links.
+	numLinkRouteLists int
+	numAllRouteLists  int
 }
 
 type addrState struct {
@@ -258,37 +262,52 @@ func (nl *netlinkTest) LinkList() ([]netlink.Link, error) {
 }
 
 func (nl *netlinkTest) ListLocalRoutes(link netlink.Link, family int) ([]netlink.Route, error) {
-	name := link.Attrs().Name
 	nl.linksMutex.Lock()
 	defer nl.linksMutex.Unlock()
-	model, prs := nl.links[name]
+	if link == nil {
+		nl.numAllRouteLists++
+		var routes []netlink.Route
+		for _, model := range nl.links {
+			routes = append(routes, nl.localRoutes(model, family)...)
+		}
+		return routes, nil
+	}
+	nl.numLinkRouteLists++
+	model, prs := nl.links[link.Attrs().Name]
+	if !prs {
+		return nil, nil
+	}
+	return nl.localRoutes(model, family), nil
+}
+
+func (nl *netlinkTest) localRoutes(model linkModel, family int) []netlink.Route {
 	var routes []netlink.Route
-	if prs {
-		model.addrs.Iter(func(item interface{}) error {
-			addr := item.(string)
-			net, err := netlink.ParseIPNet(addr)
-			if err != nil {
-				panic("Address parsing failed")
+	model.addrs.Iter(func(item interface{}) error {
+		addr := item.(string)
+		net, err := netlink.ParseIPNet(addr)
+		if err != nil {
+			panic("Address parsing failed")
+		}
+		if strings.ContainsRune(addr, ':') {
+			if family == netlink.FAMILY_V6 {
+				routes = append(routes, netlink.Route{
+					LinkIndex: model.index,
+					Type:      unix.RTN_LOCAL,
+					Dst:       net,
+				})
 			}
-			if strings.ContainsRune(addr, ':') {
-				if family == netlink.FAMILY_V6 {
-					routes = append(routes, netlink.Route{
-						Type: unix.RTN_LOCAL,
-						Dst:  net,
-					})
-				}
-			} else {
-				if family == netlink.FAMILY_V4 {
-					routes = append(routes, netlink.Route{
-						Type: unix.RTN_LOCAL,
-						Dst:  net,
-					})
-				}
+		} else {
+			if family == netlink.FAMILY_V4 {
+				routes = append(routes, netlink.Route{
+					LinkIndex: model.index,
+					Type:      unix.RTN_LOCAL,
+					Dst:       net,
+				})
 			}
-			return nil
-		})
-	}
-	return routes, nil
+		}
+		return nil
+	})
+	return routes
 }
 
 func (nl *netlinkTest) AddrList(link netlink.Link, family int) ([]netlink.Addr, error) {
@@ -662,6 +681,54 @@ var _ = Describe("ifacemonitor", func() {
 		Expect(fatalErrC).ToNot(BeClosed())
 	})
 
+	It("should list all addresses with a single dump per family on resync", func() {
+		// Make sure that the initial resync has finished before we add the links.
+		resyncC <- time.Time{}
+
+		names := set.New()
+		for _, name := range []string{"eth0", "eth1", "eth2"} {
+			nl.addLinkNoSignal(name)
+			nl.linksMutex.Lock()
+			nl.links[name].addrs.Add("10.0.0." + name[3:] + "/32")
+			nl.linksMutex.Unlock()
+			names.Add(name)
+		}
+		nl.linksMutex.Lock()
+		nl.numLinkRouteLists = 0
+		nl.numAllRouteLists = 0
+		nl.linksMutex.Unlock()
+
+		resyncC <- time.Time{}
+		for i := 0; i < 3; i++ {
+			var upd addrState
+			Eventually(dp.addrC).Should(Receive(&upd))
+			Expect(names.Contains(upd.ifaceName)).To(BeTrue())
+			names.Discard(upd.ifaceName)
+			Expect(upd.addrs.Contains("10.0.0." + upd.ifaceName[3:])).To(BeTrue())
+		}
+
+		nl.linksMutex.Lock()
+		defer nl.linksMutex.Unlock()
+		Expect(nl.numAllRouteLists).To(Equal(2))
+		Expect(nl.numLinkRouteLists).To(Equal(0))
+	})
+
+	It("should resync after reconnecting to netlink", func() {
+		idx := nl.nextIndex
+		nl.addLink("eth0")
+		dp.expectAddrStateCb("eth0", "", true)
+		nl.changeLinkState("eth0", "up")
+		dp.expectLinkStateCb("eth0", ifacemonitor.StateUp, idx)
+
+		// Simulate the link being removed while we're missing updates.
+		nl.delLinkNoSignal("eth0")
+		close(nl.linkUpdates)
+		Eventually(nl.userSubscribed).Should(Receive())
+		dp.expectLinkStateCb("eth0", ifacemonitor.StateDown, idx)
+		dp.expectAddrStateCb("eth0", "", false)
+		Expect(fatalErrC).ToNot(BeClosed())
+	})
+
 	It("should report a fatal error if routes channel goes down", func() {
 		oldCancel := nl.cancel
 		close(nl.routeUpdates)
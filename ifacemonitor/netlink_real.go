@@ -15,6 +15,8 @@
 package ifacemonitor
 
 import (
+	"errors"
+
 	log "github.com/sirupsen/logrus"
 	"github.com/vishvananda/netlink"
 	"golang.org/x/sys/unix"
@@ -30,20 +32,14 @@ func (nl *netlinkReal) Subscribe(
 	cancel := make(chan struct{})
 
 	if err := netlink.LinkSubscribeWithOptions(linkUpdates, cancel, netlink.LinkSubscribeOptions{
-		ErrorCallback: func(err error) {
-			// Not necessarily fatal (can be an unexpected message, which the library will drop).
-			log.WithError(err).Warn("Netlink reported an error.")
-		},
+		ErrorCallback: subscribeErrorCallback,
 	}); err != nil {
 		log.WithError(err).Error("Failed to subscribe to link updates")
 		close(cancel)
 		return nil, err
 	}
 	if err := netlink.RouteSubscribeWithOptions(routeUpdates, cancel, netlink.RouteSubscribeOptions{
-		ErrorCallback: func(err error) {
-			// Not necessarily fatal (can be an unexpected message, which the library will drop).
-			log.WithError(err).Warn("Netlink reported an error.")
-		},
+		ErrorCallback: subscribeErrorCallback,
 	}); err != nil {
 		log.WithError(err).Error("Failed to subscribe to route updates")
 		close(cancel)
@@ -53,15 +49,31 @@ func (nl *netlinkReal) Subscribe(
 	return cancel, nil
 }
 
+// subscribeErrorCallback is called by the netlink library when it fails to read from one of our
+// subscription sockets.  ENOBUFS means that the kernel overflowed the socket's receive buffer and
+// dropped some messages, leaving a gap in the update stream; the library then closes the update
+// channel, which triggers a resubscribe and resync in MonitorInterfaces.
+func subscribeErrorCallback(err error) {
+	if errors.Is(err, unix.ENOBUFS) {
+		log.WithError(err).Warn("Netlink socket overflowed, some updates were lost; will resync.")
+		countNetlinkGaps.Inc()
+		return
+	}
+	// Not necessarily fatal (can be an unexpected message, which the library will drop).
+	log.WithError(err).Warn("Netlink reported an error.")
+}
+
 func (nl *netlinkReal) LinkList() ([]netlink.Link, error) {
 	return netlink.LinkList()
 }
 
 func (nl *netlinkReal) ListLocalRoutes(link netlink.Link, family int) ([]netlink.Route, error) {
 	routeFilter := &netlink.Route{}
+	filterMask := netlink.RT_FILTER_TABLE
 	if link != nil {
 		routeFilter.LinkIndex = link.Attrs().Index
+		filterMask |= netlink.RT_FILTER_OIF
 	}
 	routeFilter.Table = unix.RT_TABLE_LOCAL
-	return netlink.RouteListFiltered(family, routeFilter, netlink.RT_FILTER_TABLE|netlink.RT_FILTER_OIF)
+	return netlink.RouteListFiltered(family, routeFilter, filterMask)
 }
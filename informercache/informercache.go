@@ -0,0 +1,274 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package informercache serves the syncer's workload endpoint lists and watches from a shared
+// pod informer rather than from direct API server lists and watches.  The informer can be
+// restricted to the pods on the local node, or to pods matching a label selector, and it can be
+// shared with other components in the process that need to watch pods.
+package informercache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	kapiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	apiv3 "github.com/projectcalico/libcalico-go/lib/apis/v3"
+	"github.com/projectcalico/libcalico-go/lib/backend/api"
+	"github.com/projectcalico/libcalico-go/lib/backend/k8s/conversion"
+	"github.com/projectcalico/libcalico-go/lib/backend/model"
+)
+
+// historySize is the number of pod events that we keep so that a watch can start from the
+// revision of an earlier list.  If the requested revision is older than that, the watch fails
+// and the syncer does a fresh list.
+const historySize = 1000
+
+type Config struct {
+	// ResyncPeriod is the informer's resync period; zero disables resyncs.
+	ResyncPeriod time.Duration
+	// NodeName, if non-empty, restricts the informer to pods that are scheduled to that node.
+	NodeName string
+	// LabelSelector, if non-empty, restricts the informer to pods matching the selector.
+	LabelSelector string
+}
+
+// Client wraps a backend client, serving lists and watches of all workload endpoints from the
+// pod informer.  All other calls are passed through to the wrapped client.
+type Client struct {
+	api.Client
+
+	informer  cache.SharedIndexInformer
+	converter conversion.Converter
+
+	lock     sync.Mutex
+	pods     map[string]*kapiv1.Pod
+	seq      uint64
+	history  []podEvent
+	watchers map[*watcher]bool
+}
+
+type podEvent struct {
+	seq      uint64
+	old, new *kapiv1.Pod
+}
+
+func NewClient(client api.Client, k8sClient kubernetes.Interface, config Config) (*Client, error) {
+	var fieldSelector string
+	if config.NodeName != "" {
+		fieldSelector = fields.OneTermEqualSelector("spec.nodeName", config.NodeName).String()
+	}
+	if config.LabelSelector != "" {
+		if _, err := labels.Parse(config.LabelSelector); err != nil {
+			return nil, fmt.Errorf("invalid pod label selector %q: %w", config.LabelSelector, err)
+		}
+	}
+	informer := coreinformers.NewFilteredPodInformer(
+		k8sClient,
+		metav1.NamespaceAll,
+		config.ResyncPeriod,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+		func(options *metav1.ListOptions) {
+			options.FieldSelector = fieldSelector
+			options.LabelSelector = config.LabelSelector
+		},
+	)
+	return newClient(client, informer), nil
+}
+
+func newClient(client api.Client, informer cache.SharedIndexInformer) *Client {
+	c := &Client{
+		Client:    client,
+		informer:  informer,
+		converter: conversion.NewConverter(),
+		pods:      map[string]*kapiv1.Pod{},
+		watchers:  map[*watcher]bool{},
+	}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			c.onPodUpdate(nil, obj)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.onPodUpdate(oldObj, newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			c.onPodUpdate(obj, nil)
+		},
+	})
+	return c
+}
+
+// Informer returns the shared pod informer so that other components can add their own event
+// handlers rather than starting their own watch.
+func (c *Client) Informer() cache.SharedIndexInformer {
+	return c.informer
+}
+
+// Start runs the informer until the context is cancelled.
+func (c *Client) Start(ctx context.Context) {
+	go c.informer.Run(ctx.Done())
+}
+
+func (c *Client) onPodUpdate(oldObj, newObj interface{}) {
+	oldPod, _ := oldObj.(*kapiv1.Pod)
+	newPod, _ := newObj.(*kapiv1.Pod)
+	if oldPod == nil && newPod == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.seq++
+	event := podEvent{seq: c.seq, old: oldPod, new: newPod}
+	if newPod != nil {
+		c.pods[podKey(newPod)] = newPod
+	} else {
+		delete(c.pods, podKey(oldPod))
+	}
+	c.history = append(c.history, event)
+	if len(c.history) > historySize {
+		c.history = c.history[len(c.history)-historySize:]
+	}
+	for w := range c.watchers {
+		w.queue(event)
+	}
+}
+
+func (c *Client) handles(list model.ListInterface) bool {
+	rlo, ok := list.(model.ResourceListOptions)
+	return ok && rlo.Kind == apiv3.KindWorkloadEndpoint && rlo.Name == "" && rlo.Namespace == ""
+}
+
+func (c *Client) List(ctx context.Context, list model.ListInterface, revision string) (*model.KVPairList, error) {
+	if !c.handles(list) {
+		return c.Client.List(ctx, list, revision)
+	}
+	if !cache.WaitForCacheSync(ctx.Done(), c.informer.HasSynced) {
+		return nil, ctx.Err()
+	}
+
+	c.lock.Lock()
+	pods := make([]*kapiv1.Pod, 0, len(c.pods))
+	for _, pod := range c.pods {
+		pods = append(pods, pod)
+	}
+	seq := c.seq
+	c.lock.Unlock()
+
+	var kvps []*model.KVPair
+	for _, pod := range pods {
+		kvps = append(kvps, c.podToKVPs(pod)...)
+	}
+	return &model.KVPairList{
+		KVPairs:  kvps,
+		Revision: strconv.FormatUint(seq, 10),
+	}, nil
+}
+
+func (c *Client) Watch(ctx context.Context, list model.ListInterface, revision string) (api.WatchInterface, error) {
+	if !c.handles(list) {
+		return c.Client.Watch(ctx, list, revision)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	from := c.seq
+	if revision != "" {
+		var err error
+		from, err = strconv.ParseUint(revision, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid workload endpoint revision %q: %w", revision, err)
+		}
+	}
+	w := newWatcher(ctx, c)
+	if from < c.seq && (len(c.history) == 0 || c.history[0].seq > from+1) {
+		// We no longer have all the events since the requested revision.
+		log.WithField("revision", revision).Info("Pod watch revision too old, forcing a resync.")
+		w.fail(fmt.Errorf("revision %s is too old", revision))
+		return w, nil
+	}
+	for _, event := range c.history {
+		if event.seq > from {
+			w.queue(event)
+		}
+	}
+	c.watchers[w] = true
+	return w, nil
+}
+
+func (c *Client) removeWatcher(w *watcher) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.watchers, w)
+}
+
+// podToKVPs converts a pod to its workload endpoints, returning nil if the pod isn't a valid
+// Calico workload.
+func (c *Client) podToKVPs(pod *kapiv1.Pod) []*model.KVPair {
+	if pod == nil || !c.converter.IsValidCalicoWorkloadEndpoint(pod) {
+		return nil
+	}
+	// The conversion modifies the pod's labels and the pod belongs to the informer's cache, so
+	// convert a copy.
+	kvps, err := c.converter.PodToWorkloadEndpoints(pod.DeepCopy())
+	if err != nil {
+		log.WithError(err).WithField("pod", podKey(pod)).Warn("Failed to convert pod to workload endpoint.")
+		return nil
+	}
+	return kvps
+}
+
+// toWatchEvents converts a pod event into the corresponding workload endpoint events.
+func (c *Client) toWatchEvents(event podEvent) []api.WatchEvent {
+	oldKVPs := map[string]*model.KVPair{}
+	for _, kvp := range c.podToKVPs(event.old) {
+		oldKVPs[kvp.Key.String()] = kvp
+	}
+	var events []api.WatchEvent
+	newKeys := map[string]bool{}
+	for _, kvp := range c.podToKVPs(event.new) {
+		k := kvp.Key.String()
+		newKeys[k] = true
+		if old, ok := oldKVPs[k]; ok {
+			events = append(events, api.WatchEvent{Type: api.WatchModified, Old: old, New: kvp})
+		} else {
+			events = append(events, api.WatchEvent{Type: api.WatchAdded, New: kvp})
+		}
+	}
+	for k, old := range oldKVPs {
+		if !newKeys[k] {
+			events = append(events, api.WatchEvent{Type: api.WatchDeleted, Old: old})
+		}
+	}
+	return events
+}
+
+func podKey(pod *kapiv1.Pod) string {
+	return pod.Namespace + "/" + pod.Name
+}
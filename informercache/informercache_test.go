@@ -0,0 +1,189 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package informercache
+
+import (
+	"context"
+	"strconv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	kapiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	apiv3 "github.com/projectcalico/libcalico-go/lib/apis/v3"
+	"github.com/projectcalico/libcalico-go/lib/backend/api"
+	"github.com/projectcalico/libcalico-go/lib/backend/model"
+)
+
+type passThroughBackend struct {
+	api.Client
+
+	numLists int
+}
+
+func (b *passThroughBackend) List(ctx context.Context, list model.ListInterface, revision string) (*model.KVPairList, error) {
+	b.numLists++
+	return &model.KVPairList{}, nil
+}
+
+func makePod(name, node, ip string) *kapiv1.Pod {
+	return &kapiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "default",
+			ResourceVersion: "1",
+			Labels:          map[string]string{"app": name},
+		},
+		Spec: kapiv1.PodSpec{NodeName: node},
+		Status: kapiv1.PodStatus{
+			PodIP:  ip,
+			PodIPs: []kapiv1.PodIP{{IP: ip}},
+		},
+	}
+}
+
+var _ = Describe("Informer cache", func() {
+	var (
+		k8sClient *fake.Clientset
+		backend   *passThroughBackend
+		client    *Client
+		ctx       context.Context
+		cancel    context.CancelFunc
+		wepList   = model.ResourceListOptions{Kind: apiv3.KindWorkloadEndpoint}
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		k8sClient = fake.NewSimpleClientset(makePod("pod-1", "node-1", "10.0.0.1"))
+		backend = &passThroughBackend{}
+		var err error
+		client, err = NewClient(backend, k8sClient, Config{})
+		Expect(err).NotTo(HaveOccurred())
+		client.Start(ctx)
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	createPod := func(pod *kapiv1.Pod) {
+		_, err := k8sClient.CoreV1().Pods(pod.Namespace).Create(ctx, pod, metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	deletePod := func(name string) {
+		err := k8sClient.CoreV1().Pods("default").Delete(ctx, name, metav1.DeleteOptions{})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	It("should pass through lists of other resources", func() {
+		_, err := client.List(ctx, model.ResourceListOptions{Kind: "NetworkPolicy"}, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(backend.numLists).To(Equal(1))
+	})
+
+	It("should list workload endpoints from the cache", func() {
+		l, err := client.List(ctx, wepList, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(l.KVPairs).To(HaveLen(1))
+		Expect(l.KVPairs[0].Key.(model.ResourceKey).Name).To(ContainSubstring("pod--1"))
+		Expect(backend.numLists).To(Equal(0))
+	})
+
+	It("should skip host-networked pods", func() {
+		pod := makePod("pod-2", "node-1", "10.0.0.2")
+		pod.Spec.HostNetwork = true
+		createPod(pod)
+		Eventually(func() int {
+			client.lock.Lock()
+			defer client.lock.Unlock()
+			return len(client.pods)
+		}).Should(Equal(2))
+		l, err := client.List(ctx, wepList, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(l.KVPairs).To(HaveLen(1))
+	})
+
+	It("should watch from the list's revision", func() {
+		l, err := client.List(ctx, wepList, "")
+		Expect(err).NotTo(HaveOccurred())
+
+		// Changes made between the list and the watch must still be seen.
+		createPod(makePod("pod-2", "node-1", "10.0.0.2"))
+		deletePod("pod-1")
+		Eventually(func() int {
+			client.lock.Lock()
+			defer client.lock.Unlock()
+			return len(client.history)
+		}).Should(Equal(3))
+
+		w, err := client.Watch(ctx, wepList, l.Revision)
+		Expect(err).NotTo(HaveOccurred())
+		defer w.Stop()
+
+		var event api.WatchEvent
+		Eventually(w.ResultChan()).Should(Receive(&event))
+		Expect(event.Type).To(Equal(api.WatchAdded))
+		Expect(event.New.Key.(model.ResourceKey).Name).To(ContainSubstring("pod--2"))
+		Eventually(w.ResultChan()).Should(Receive(&event))
+		Expect(event.Type).To(Equal(api.WatchDeleted))
+		Expect(event.Old.Key.(model.ResourceKey).Name).To(ContainSubstring("pod--1"))
+
+		createPod(makePod("pod-3", "node-1", "10.0.0.3"))
+		Eventually(w.ResultChan()).Should(Receive(&event))
+		Expect(event.Type).To(Equal(api.WatchAdded))
+		Expect(event.New.Key.(model.ResourceKey).Name).To(ContainSubstring("pod--3"))
+	})
+
+	It("should fail a watch from a revision that is too old", func() {
+		_, err := client.List(ctx, wepList, "")
+		Expect(err).NotTo(HaveOccurred())
+		client.lock.Lock()
+		client.history = client.history[:0]
+		client.seq += historySize
+		current := client.seq
+		client.lock.Unlock()
+
+		w, err := client.Watch(ctx, wepList, strconv.FormatUint(current-historySize, 10))
+		Expect(err).NotTo(HaveOccurred())
+		var event api.WatchEvent
+		Eventually(w.ResultChan()).Should(Receive(&event))
+		Expect(event.Type).To(Equal(api.WatchError))
+		Eventually(w.ResultChan()).Should(BeClosed())
+	})
+
+	It("should stop delivering events once stopped", func() {
+		l, err := client.List(ctx, wepList, "")
+		Expect(err).NotTo(HaveOccurred())
+		w, err := client.Watch(ctx, wepList, l.Revision)
+		Expect(err).NotTo(HaveOccurred())
+		w.Stop()
+		Eventually(w.HasTerminated).Should(BeTrue())
+		Eventually(func() int {
+			client.lock.Lock()
+			defer client.lock.Unlock()
+			return len(client.watchers)
+		}).Should(Equal(0))
+	})
+})
+
+var _ = Describe("Informer cache config", func() {
+	It("should reject an invalid label selector", func() {
+		_, err := NewClient(nil, fake.NewSimpleClientset(), Config{LabelSelector: "a in ("})
+		Expect(err).To(HaveOccurred())
+	})
+})
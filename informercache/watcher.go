@@ -0,0 +1,138 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package informercache
+
+import (
+	"context"
+	"sync"
+
+	"github.com/projectcalico/libcalico-go/lib/backend/api"
+)
+
+// watcher delivers the pod events for one watch.  Events are queued without blocking, so that a
+// slow consumer doesn't hold up the informer, and converted to workload endpoint events as they
+// are delivered.
+type watcher struct {
+	client  *Client
+	results chan api.WatchEvent
+
+	lock    sync.Mutex
+	pending []podEvent
+	err     error
+	wakeup  chan struct{}
+
+	ctx      context.Context
+	stopOnce sync.Once
+	stopped  chan struct{}
+	done     chan struct{}
+}
+
+func newWatcher(ctx context.Context, client *Client) *watcher {
+	w := &watcher{
+		client:  client,
+		results: make(chan api.WatchEvent),
+		wakeup:  make(chan struct{}, 1),
+		ctx:     ctx,
+		stopped: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go w.loop()
+	return w
+}
+
+func (w *watcher) queue(event podEvent) {
+	w.lock.Lock()
+	w.pending = append(w.pending, event)
+	w.lock.Unlock()
+	w.wake()
+}
+
+// fail sends an error event to the consumer, after any events that are already queued.
+func (w *watcher) fail(err error) {
+	w.lock.Lock()
+	w.err = err
+	w.lock.Unlock()
+	w.wake()
+}
+
+func (w *watcher) wake() {
+	select {
+	case w.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (w *watcher) loop() {
+	defer close(w.done)
+	defer close(w.results)
+	defer w.client.removeWatcher(w)
+	for {
+		select {
+		case <-w.wakeup:
+		case <-w.stopped:
+			return
+		case <-w.ctx.Done():
+			return
+		}
+
+		w.lock.Lock()
+		pending := w.pending
+		w.pending = nil
+		err := w.err
+		w.lock.Unlock()
+
+		for _, podEvent := range pending {
+			for _, event := range w.client.toWatchEvents(podEvent) {
+				if !w.send(event) {
+					return
+				}
+			}
+		}
+		if err != nil {
+			w.send(api.WatchEvent{Type: api.WatchError, Error: err})
+			return
+		}
+	}
+}
+
+func (w *watcher) send(event api.WatchEvent) bool {
+	select {
+	case w.results <- event:
+		return true
+	case <-w.stopped:
+		return false
+	case <-w.ctx.Done():
+		return false
+	}
+}
+
+func (w *watcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopped)
+	})
+}
+
+func (w *watcher) ResultChan() <-chan api.WatchEvent {
+	return w.results
+}
+
+func (w *watcher) HasTerminated() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
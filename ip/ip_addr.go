@@ -273,6 +273,28 @@ func ParseCIDROrIP(s string) (CIDR, error) {
 	return CIDRFromIPNet(netCIDR), nil
 }
 
+// CIDRContains returns true if the inner CIDR is inside the outer one.
+func CIDRContains(outer, inner CIDR) bool {
+	outerNet := outer.ToIPNet()
+	return outer.Prefix() <= inner.Prefix() && outerNet.Contains(inner.Addr().AsNetIP())
+}
+
+// IntersectCIDRs returns the CIDRs that are covered by both lists.  Two CIDRs either don't
+// overlap or one contains the other, in which case their intersection is the smaller one.
+func IntersectCIDRs(a, b []CIDR) []CIDR {
+	var out []CIDR
+	for _, x := range a {
+		for _, y := range b {
+			if CIDRContains(x, y) {
+				out = append(out, y)
+			} else if CIDRContains(y, x) {
+				out = append(out, x)
+			}
+		}
+	}
+	return out
+}
+
 func IPNetsEqual(net1, net2 *net.IPNet) bool {
 	if net1 == nil && net2 == nil {
 		// Both are nil, therefore equal.
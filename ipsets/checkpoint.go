@@ -0,0 +1,85 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipsets
+
+import "sort"
+
+// IPSetCheckpoint records one of our IP sets as we programmed it.  A checkpoint lets a restarted
+// Felix update its IP sets with deltas instead of rewriting every one of them.
+type IPSetCheckpoint struct {
+	Type    IPSetType `json:"type"`
+	MaxSize int       `json:"maxSize"`
+	Members []string  `json:"members"`
+}
+
+// Checkpoint returns the IP sets that we've programmed, keyed by their names in the dataplane.
+// IP sets that we haven't programmed yet are left out.
+func (s *IPSets) Checkpoint() map[string]IPSetCheckpoint {
+	cp := map[string]IPSetCheckpoint{}
+	for _, ipSet := range s.ipSetIDToIPSet {
+		if ipSet.members == nil {
+			continue
+		}
+		members := make([]string, 0, ipSet.members.Len())
+		ipSet.members.Iter(func(item interface{}) error {
+			members = append(members, item.(ipSetMember).String())
+			return nil
+		})
+		sort.Strings(members)
+		cp[ipSet.MainIPSetName] = IPSetCheckpoint{
+			Type:    ipSet.Type,
+			MaxSize: ipSet.MaxSize,
+			Members: members,
+		}
+	}
+	return cp
+}
+
+// LoadCheckpoint loads a checkpoint that was taken by a previous instance of Felix.  Until the
+// first ApplyUpdates(), AddOrReplaceIPSet() assumes that an IP set in the checkpoint with the same
+// metadata already has the checkpointed members, so it only queues the deltas.  The first
+// ApplyUpdates() does a resync, which corrects any members that don't match the dataplane, and
+// rewrites any IP set that is missing.
+func (s *IPSets) LoadCheckpoint(cp map[string]IPSetCheckpoint) {
+	s.checkpoint = cp
+}
+
+// seedFromCheckpoint sets the IP set's members to those in the checkpoint and turns its pending
+// replace into deltas, if the checkpoint has a matching IP set.
+func (s *IPSets) seedFromCheckpoint(ipSet *ipSet) {
+	cp, ok := s.checkpoint[ipSet.MainIPSetName]
+	if !ok {
+		return
+	}
+	delete(s.checkpoint, ipSet.MainIPSetName)
+	if cp.Type != ipSet.Type || cp.MaxSize != ipSet.MaxSize {
+		s.logCxt.WithField("setID", ipSet.SetID).Info("IP set metadata changed since checkpoint, will rewrite it.")
+		return
+	}
+	ipSet.members = s.filterAndCanonicaliseMembers(ipSet.Type, cp.Members)
+	ipSet.pendingReplace.Iter(func(m interface{}) error {
+		if !ipSet.members.Contains(m) {
+			ipSet.pendingAdds.Add(m)
+		}
+		return nil
+	})
+	ipSet.members.Iter(func(m interface{}) error {
+		if !ipSet.pendingReplace.Contains(m) {
+			ipSet.pendingDeletions.Add(m)
+		}
+		return nil
+	})
+	ipSet.pendingReplace = nil
+}
@@ -246,6 +246,34 @@ type ipSet struct {
 	pendingDeletions set.Set /*<ipSetMember>*/
 }
 
+// numDesiredMembers returns the number of members that the IP set will have once the pending
+// updates have been applied.
+func (s *ipSet) numDesiredMembers() int {
+	if s.pendingReplace != nil {
+		return s.pendingReplace.Len()
+	}
+	return s.members.Len() + s.pendingAdds.Len() - s.pendingDeletions.Len()
+}
+
+// queueFullRewrite turns the IP set's pending deltas into a full rewrite.  It's used when the IP
+// set turns out to be missing from the dataplane.
+func (s *ipSet) queueFullRewrite() {
+	desired := set.New()
+	s.members.Iter(func(m interface{}) error {
+		if !s.pendingDeletions.Contains(m) {
+			desired.Add(m)
+		}
+		return nil
+	})
+	s.pendingAdds.Iter(func(m interface{}) error {
+		desired.Add(m)
+		return set.RemoveItem
+	})
+	s.pendingDeletions = set.New()
+	s.members = nil
+	s.pendingReplace = desired
+}
+
 // IPVersionConfig wraps up the metadata for a particular IP version.  It can be used by
 // this and other components to calculate IP set names from IP set IDs, for example.
 type IPVersionConfig struct {
@@ -321,6 +349,16 @@ func (c IPVersionConfig) NameForMainIPSet(setID string) string {
 	return combineAndTrunc(c.mainSetNamePrefix, setID, MaxIPSetNameLength)
 }
 
+// SetIDForMainIPSet is the inverse of NameForMainIPSet.  It returns false if the name isn't one
+// of our main IP set names or if the ID may have been truncated to fit, since it can't be
+// recovered in that case.
+func (c IPVersionConfig) SetIDForMainIPSet(setName string) (string, bool) {
+	if !strings.HasPrefix(setName, c.mainSetNamePrefix) || len(setName) >= MaxIPSetNameLength {
+		return "", false
+	}
+	return setName[len(c.mainSetNamePrefix):], true
+}
+
 // OwnsIPSet returns true if the given IP set name appears to belong to Felix.  i.e. whether it
 // starts with an expected prefix.
 func (c IPVersionConfig) OwnsIPSet(setName string) bool {
@@ -17,8 +17,10 @@ package ipsets
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 	"time"
 
@@ -27,9 +29,14 @@ import (
 
 	"github.com/projectcalico/libcalico-go/lib/set"
 
+	"github.com/projectcalico/felix/alerts"
 	"github.com/projectcalico/felix/logutils"
+	"github.com/projectcalico/felix/tracing"
 )
 
+// alertFlushTimeout is how long we wait for an alert to be sent before panicking.
+const alertFlushTimeout = 5 * time.Second
+
 // IPSets manages a whole "plane" of IP sets, i.e. all the IPv4 sets, or all the IPv6 IP sets.
 type IPSets struct {
 	IPVersionConfig *IPVersionConfig
@@ -43,6 +50,8 @@ type IPSets struct {
 	// dirtyIPSetIDs contains IDs of IP sets that need updating.
 	dirtyIPSetIDs  set.Set // <string>
 	resyncRequired bool
+	// numInconsistencies counts the problems that we've found when resyncing.
+	numInconsistencies int
 
 	// pendingTempIPSetDeletions contains names of temporary IP sets that need to be deleted.  We use it to
 	// attempt an early deletion of temporary IP sets, if possible.
@@ -71,6 +80,10 @@ type IPSets struct {
 	stderrCopy bytes.Buffer
 
 	opReporter logutils.OpRecorder
+
+	// checkpoint holds the IP sets from the checkpoint that was loaded by LoadCheckpoint(), until
+	// the first successful ApplyUpdates().
+	checkpoint map[string]IPSetCheckpoint
 }
 
 func NewIPSets(ipVersionConfig *IPVersionConfig, recorder logutils.OpRecorder) *IPSets {
@@ -135,6 +148,7 @@ func (s *IPSets) AddOrReplaceIPSet(setMetadata IPSetMetadata, members []string)
 		pendingAdds:      set.New(),
 		pendingDeletions: set.New(),
 	}
+	s.seedFromCheckpoint(ipSet)
 	s.ipSetIDToIPSet[setID] = ipSet
 	s.mainIPSetNameToIPSet[ipSet.MainIPSetName] = ipSet
 
@@ -156,6 +170,16 @@ func (s *IPSets) RemoveIPSet(setID string) {
 	s.pendingIPSetDeletions.Add(mainIPSetName)
 }
 
+// RemoveAllIPSets queues up the removal of all our IP sets, including any that were left behind by
+// a previous run.  The IP sets will be removed on the next call to ApplyDeletions(), which may fail
+// for IP sets that are still referenced by iptables rules.
+func (s *IPSets) RemoveAllIPSets() {
+	for setID := range s.ipSetIDToIPSet {
+		s.RemoveIPSet(setID)
+	}
+	s.QueueResync()
+}
+
 // AddMembers adds the given members to the IP set.  Filters out members that are of the incorrect
 // IP version.
 func (s *IPSets) AddMembers(setID string, newMembers []string) {
@@ -225,6 +249,12 @@ func (s *IPSets) RemoveMembers(setID string, removedMembers []string) {
 	s.dirtyIPSetIDs.Add(setID)
 }
 
+// NumInconsistencies returns the number of problems with the IP sets in the dataplane that we've
+// found (and queued for repair) since we were created.
+func (s *IPSets) NumInconsistencies() int {
+	return s.numInconsistencies
+}
+
 // QueueResync forces a resync with the dataplane on the next ApplyUpdates() call.
 func (s *IPSets) QueueResync() {
 	s.logCxt.Debug("Asked to resync with the dataplane on next update.")
@@ -297,6 +327,12 @@ func (s *IPSets) GetMembers(setID string) (set.Set, error) {
 }
 
 func (s *IPSets) ApplyUpdates() {
+	s.ApplyUpdatesWithContext(context.Background())
+}
+
+// ApplyUpdatesWithContext is ApplyUpdates, recording the "ipset list" and "ipset restore" calls
+// that it makes as child spans of the span in ctx.
+func (s *IPSets) ApplyUpdatesWithContext(ctx context.Context) {
 	success := false
 	retryDelay := 1 * time.Millisecond
 	backOff := func() {
@@ -313,7 +349,10 @@ func (s *IPSets) ApplyUpdates() {
 			s.logCxt.Debug("Resyncing ipsets with dataplane.")
 			s.opReporter.RecordOperation(fmt.Sprint("resync-ipsets-v", s.IPVersionConfig.Family.Version()))
 
+			_, listSpan := tracing.StartSpan(ctx, "ipsets.List")
 			numProblems, err := s.tryResync()
+			listSpan.RecordError(err)
+			listSpan.End()
 			if err != nil {
 				s.logCxt.WithError(err).Warning("Failed to resync with dataplane")
 				backOff()
@@ -322,6 +361,7 @@ func (s *IPSets) ApplyUpdates() {
 			if numProblems > 0 {
 				s.logCxt.WithField("numProblems", numProblems).Warn(
 					"Found inconsistencies in IP sets in dataplane")
+				s.numInconsistencies += numProblems
 			}
 			s.resyncRequired = false
 		}
@@ -333,7 +373,11 @@ func (s *IPSets) ApplyUpdates() {
 			s.tryTempIPSetDeletions()
 		}
 
-		if err := s.tryUpdates(); err != nil {
+		_, restoreSpan := tracing.StartSpan(ctx, "ipsets.Restore")
+		err := s.tryUpdates()
+		restoreSpan.RecordError(err)
+		restoreSpan.End()
+		if err != nil {
 			// While failed deletions don't cause immediate problems, update failures may mean that our iptables
 			// updates fail.  We need to do an immediate resync.
 			s.logCxt.WithError(err).Warning("Failed to update IP sets. Marking dataplane for resync.")
@@ -346,8 +390,13 @@ func (s *IPSets) ApplyUpdates() {
 		success = true
 		break
 	}
+	// The checkpoint is only valid for the IP sets that we're given before the first update.
+	s.checkpoint = nil
 	if !success {
 		s.dumpIPSetsToLog()
+		alerts.Raise(alerts.ConditionDataplaneProgrammingFailed, alerts.SeverityCritical,
+			"ipsets/"+string(s.IPVersionConfig.Family), "Failed to update IP sets after multiple retries.")
+		alerts.Flush(alertFlushTimeout)
 		s.logCxt.Panic("Failed to update IP sets after multiple retries.")
 	}
 	gaugeNumTotalIpsets.Set(float64(s.existingIPSetNames.Len()))
@@ -576,6 +625,19 @@ func (s *IPSets) tryResync() (numProblems int, err error) {
 		return
 	}
 
+	// Any IP sets that we think we've programmed but that are missing from the dataplane need to be
+	// rewritten.  This happens after we seed an IP set from a checkpoint, for example.
+	for _, ipSet := range s.ipSetIDToIPSet {
+		if ipSet.members == nil || s.existingIPSetNames.Contains(ipSet.MainIPSetName) {
+			continue
+		}
+		s.logCxt.WithField("setID", ipSet.SetID).Warning(
+			"Resync found IP set missing from dataplane. Queueing a rewrite.")
+		numProblems++
+		ipSet.queueFullRewrite()
+		s.dirtyIPSetIDs.Add(ipSet.SetID)
+	}
+
 	// Scan for IP sets that need to be cleaned up.  Create a whitelist containing the IP sets
 	// that we expect to be there.
 	expectedIPSets := set.New()
@@ -695,6 +757,8 @@ func (s *IPSets) tryUpdates() error {
 			"stderr":     s.stderrCopy.String(),
 			"input":      s.restoreInCopy.String(),
 		}).Warning("Failed to complete ipset restore, IP sets may be out-of-sync.")
+		logutils.CaptureFailedCommand("ipset", []string{"restore"}, s.restoreInCopy.String(),
+			s.stdoutCopy.String(), s.stderrCopy.String(), err)
 		return err
 	}
 
@@ -739,6 +803,12 @@ func (s *IPSets) writeUpdates(ipSet *ipSet, w io.Writer) error {
 		})
 	}
 
+	if numMembers := ipSet.numDesiredMembers(); ipSet.MaxSize > 0 && numMembers > ipSet.MaxSize {
+		logCxt.WithField("maxSize", ipSet.MaxSize).Warn("IP set has more members than its maximum size.")
+		alerts.Raise(alerts.ConditionIPSetOverflow, alerts.SeverityCritical, ipSet.SetID, fmt.Sprintf(
+			"IP set %s needs %d members but its maximum size is %d.", ipSet.SetID, numMembers, ipSet.MaxSize))
+	}
+
 	if ipSet.pendingReplace == nil {
 		// In delta-writing mode:
 		// - pendingReplace is nil
@@ -920,6 +990,43 @@ func (s *IPSets) deleteIPSet(setName string) error {
 	return nil
 }
 
+// DumpState writes the desired state of the IP sets to w, in a format similar to "ipset save".
+// It must be called from the thread that owns the IPSets.
+func (s *IPSets) DumpState(w io.Writer) error {
+	setIDs := make([]string, 0, len(s.ipSetIDToIPSet))
+	for setID := range s.ipSetIDToIPSet {
+		setIDs = append(setIDs, setID)
+	}
+	sort.Strings(setIDs)
+	for _, setID := range setIDs {
+		ipSet := s.ipSetIDToIPSet[setID]
+		if _, err := fmt.Fprintf(w, "create %s %s maxelem %d\n",
+			ipSet.MainIPSetName, ipSet.Type, ipSet.MaxSize); err != nil {
+			return err
+		}
+		if ipSet.members == nil && ipSet.pendingReplace == nil {
+			fmt.Fprintf(w, "# %s members unknown, waiting for resync\n", ipSet.MainIPSetName)
+			continue
+		}
+		members, err := s.GetMembers(setID)
+		if err != nil {
+			return err
+		}
+		var sorted []string
+		members.Iter(func(item interface{}) error {
+			sorted = append(sorted, item.(string))
+			return nil
+		})
+		sort.Strings(sorted)
+		for _, m := range sorted {
+			if _, err := fmt.Fprintf(w, "add %s %s\n", ipSet.MainIPSetName, m); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (s *IPSets) dumpIPSetsToLog() {
 	cmd := s.newCmd("ipset", "list")
 	output, err := cmd.Output()
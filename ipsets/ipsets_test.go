@@ -275,6 +275,76 @@ var _ = Describe("IP sets dataplane", func() {
 		Expect(dataplane.CmdNames).To(BeNil(), "updates should have been no-ops")
 	})
 
+	It("should remove all IP sets", func() {
+		ipsets.AddOrReplaceIPSet(meta, []string{"10.0.0.1"})
+		ipsets.AddOrReplaceIPSet(meta2, []string{"10.0.0.2"})
+		apply()
+		dataplane.IPSetMembers[v4TempIPSetName1] = set.From("10.0.0.3")
+		ipsets.RemoveAllIPSets()
+		apply()
+		Expect(dataplane.IPSetMembers).To(BeEmpty())
+	})
+
+	Describe("after restarting with a checkpoint", func() {
+		var cp map[string]IPSetCheckpoint
+
+		BeforeEach(func() {
+			ipsets.AddOrReplaceIPSet(meta, []string{"10.0.0.1", "10.0.0.2"})
+			ipsets.AddOrReplaceIPSet(meta2, []string{"10.0.0.3"})
+			apply()
+			cp = ipsets.Checkpoint()
+			dataplane.NumSwaps = 0
+
+			ipsets = NewIPSetsWithShims(
+				v4VersionConf,
+				logutils.NewSummarizer("test loop"),
+				dataplane.newCmd,
+				dataplane.sleep,
+			)
+			ipsets.LoadCheckpoint(cp)
+		})
+
+		It("should checkpoint the programmed members", func() {
+			Expect(cp).To(Equal(map[string]IPSetCheckpoint{
+				v4MainIPSetName:  {Type: IPSetTypeHashIP, MaxSize: 1234, Members: []string{"10.0.0.1", "10.0.0.2"}},
+				v4MainIPSetName2: {Type: IPSetTypeHashIP, MaxSize: 1234, Members: []string{"10.0.0.3"}},
+			}))
+		})
+
+		It("should update the IP sets with deltas", func() {
+			ipsets.AddOrReplaceIPSet(meta, []string{"10.0.0.2", "10.0.0.4"})
+			ipsets.AddOrReplaceIPSet(meta2, []string{"10.0.0.3"})
+			apply()
+			dataplane.ExpectMembers(map[string][]string{
+				v4MainIPSetName:  {"10.0.0.2", "10.0.0.4"},
+				v4MainIPSetName2: {"10.0.0.3"},
+			})
+			Expect(dataplane.NumSwaps).To(BeZero())
+		})
+
+		It("should correct members that changed since the checkpoint", func() {
+			dataplane.IPSetMembers[v4MainIPSetName] = set.From("10.0.0.1", "10.0.0.5")
+			ipsets.AddOrReplaceIPSet(meta, []string{"10.0.0.1", "10.0.0.2"})
+			apply()
+			Expect(dataplane.IPSetMembers[v4MainIPSetName]).To(Equal(set.From("10.0.0.1", "10.0.0.2")))
+		})
+
+		It("should rewrite an IP set that's missing from the dataplane", func() {
+			delete(dataplane.IPSetMembers, v4MainIPSetName)
+			delete(dataplane.IPSetMetadata, v4MainIPSetName)
+			ipsets.AddOrReplaceIPSet(meta, []string{"10.0.0.1", "10.0.0.2"})
+			apply()
+			Expect(dataplane.IPSetMembers[v4MainIPSetName]).To(Equal(set.From("10.0.0.1", "10.0.0.2")))
+		})
+
+		It("should rewrite an IP set whose metadata changed", func() {
+			ipsets.AddOrReplaceIPSet(metaCIDRs, []string{"10.0.0.0/24"})
+			apply()
+			Expect(dataplane.IPSetMembers[v4MainIPSetName]).To(Equal(set.From("10.0.0.0/24")))
+			Expect(dataplane.NumSwaps).To(Equal(1))
+		})
+	})
+
 	Describe("with left-over IP sets in place", func() {
 		BeforeEach(func() {
 			dataplane.IPSetMembers = map[string]set.Set{
@@ -818,4 +888,13 @@ var _ = Describe("Standard IPv4 IPVersionConfig", func() {
 		Expect(v4VersionConf.OwnsIPSet("foobar")).To(BeFalse())
 		Expect(v4VersionConf.OwnsIPSet("noncali")).To(BeFalse())
 	})
+	It("should recover the IDs of main IP sets", func() {
+		id, ok := v4VersionConf.SetIDForMainIPSet(v4VersionConf.NameForMainIPSet("this-host"))
+		Expect(ok).To(BeTrue())
+		Expect(id).To(Equal("this-host"))
+		_, ok = v4VersionConf.SetIDForMainIPSet(v4VersionConf.NameForMainIPSet("s:qMt7iLlGDhvLnCjM0l9nzxbabcd"))
+		Expect(ok).To(BeFalse(), "truncated ID")
+		_, ok = v4VersionConf.SetIDForMainIPSet("cali4ts:abcdef12345_-")
+		Expect(ok).To(BeFalse(), "temporary IP set")
+	})
 })
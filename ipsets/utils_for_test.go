@@ -69,6 +69,7 @@ type mockDataplane struct {
 	TriedToAddExistent       bool
 
 	AttemptedDestroys []string
+	NumSwaps          int
 
 	CumulativeSleep time.Duration
 }
@@ -370,6 +371,7 @@ func (c *restoreCmd) main() {
 			}
 		case "swap":
 			Expect(len(parts)).To(Equal(3))
+			c.Dataplane.NumSwaps++
 			name1 := parts[1]
 			name2 := parts[2]
 
@@ -111,6 +111,25 @@ func (g LogAction) String() string {
 	return "Log"
 }
 
+type NflogAction struct {
+	Group     uint16
+	Prefix    string
+	Size      int
+	TypeNflog struct{}
+}
+
+func (n NflogAction) ToFragment(features *Features) string {
+	size := 80
+	if n.Size != 0 {
+		size = n.Size
+	}
+	return fmt.Sprintf(`--jump NFLOG --nflog-group %d --nflog-prefix "%s" --nflog-range %d`, n.Group, n.Prefix, size)
+}
+
+func (n NflogAction) String() string {
+	return fmt.Sprintf("Nflog:g=%d,p=%s", n.Group, n.Prefix)
+}
+
 type AcceptAction struct {
 	TypeAccept struct{}
 }
@@ -142,13 +161,16 @@ func (g DNATAction) String() string {
 }
 
 type SNATAction struct {
-	ToAddr   string
-	TypeSNAT struct{}
+	ToAddr string
+	// DisableRandomFully suppresses --random-fully even if it's supported so that the kernel
+	// keeps the source port where it can.
+	DisableRandomFully bool
+	TypeSNAT           struct{}
 }
 
 func (g SNATAction) ToFragment(features *Features) string {
 	fullyRand := ""
-	if features.SNATFullyRandom {
+	if features.SNATFullyRandom && !g.DisableRandomFully {
 		fullyRand = " --random-fully"
 	}
 	return fmt.Sprintf("--jump SNAT --to-source %s%s", g.ToAddr, fullyRand)
@@ -158,14 +180,31 @@ func (g SNATAction) String() string {
 	return fmt.Sprintf("SNAT->%s", g.ToAddr)
 }
 
+// NetmapAction statically maps the source address of a packet to the same host part within ToNet.
+type NetmapAction struct {
+	ToNet      string
+	TypeNetmap struct{}
+}
+
+func (g NetmapAction) ToFragment(features *Features) string {
+	return fmt.Sprintf("--jump NETMAP --to %s", g.ToNet)
+}
+
+func (g NetmapAction) String() string {
+	return fmt.Sprintf("NETMAP->%s", g.ToNet)
+}
+
 type MasqAction struct {
-	ToPorts  string
-	TypeMasq struct{}
+	ToPorts string
+	// DisableRandomFully suppresses --random-fully even if it's supported so that the kernel
+	// keeps the source port where it can.
+	DisableRandomFully bool
+	TypeMasq           struct{}
 }
 
 func (g MasqAction) ToFragment(features *Features) string {
 	fullyRand := ""
-	if features.MASQFullyRandom {
+	if features.MASQFullyRandom && !g.DisableRandomFully {
 		fullyRand = " --random-fully"
 	}
 	if g.ToPorts != "" {
@@ -204,6 +243,19 @@ func (c SetMarkAction) String() string {
 	return fmt.Sprintf("Set:%#x", c.Mark)
 }
 
+type SetDSCPAction struct {
+	Value       uint8
+	TypeSetDSCP struct{}
+}
+
+func (c SetDSCPAction) ToFragment(features *Features) string {
+	return fmt.Sprintf("--jump DSCP --set-dscp %d", c.Value)
+}
+
+func (c SetDSCPAction) String() string {
+	return fmt.Sprintf("SetDSCP:%d", c.Value)
+}
+
 type SetMaskedMarkAction struct {
 	Mark              uint32
 	Mask              uint32
@@ -230,6 +282,20 @@ func (g NoTrackAction) String() string {
 	return "NOTRACK"
 }
 
+// CTHelperAction attaches the named conntrack helper to the connection.  It is only valid in the
+// raw table, on the first packet of the connection.
+type CTHelperAction struct {
+	Helper string
+}
+
+func (c CTHelperAction) ToFragment(features *Features) string {
+	return fmt.Sprintf("--jump CT --helper %s", c.Helper)
+}
+
+func (c CTHelperAction) String() string {
+	return fmt.Sprintf("CTHelper:%s", c.Helper)
+}
+
 type SaveConnMarkAction struct {
 	SaveMask     uint32
 	TypeConnMark struct{}
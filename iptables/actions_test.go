@@ -31,13 +31,19 @@ var _ = DescribeTable("Actions",
 	Entry("DropAction", Features{}, DropAction{}, "--jump DROP"),
 	Entry("AcceptAction", Features{}, AcceptAction{}, "--jump ACCEPT"),
 	Entry("LogAction", Features{}, LogAction{Prefix: "prefix"}, `--jump LOG --log-prefix "prefix: " --log-level 5`),
+	Entry("NflogAction", Features{}, NflogAction{Group: 20, Prefix: "D|default.foo"}, `--jump NFLOG --nflog-group 20 --nflog-prefix "D|default.foo" --nflog-range 80`),
+	Entry("NflogAction with size", Features{}, NflogAction{Group: 20, Prefix: "D|default.foo", Size: 40}, `--jump NFLOG --nflog-group 20 --nflog-prefix "D|default.foo" --nflog-range 40`),
 	Entry("DNATAction", Features{}, DNATAction{DestAddr: "10.0.0.1", DestPort: 8081}, "--jump DNAT --to-destination 10.0.0.1:8081"),
 	Entry("SNATAction", Features{}, SNATAction{ToAddr: "10.0.0.1"}, "--jump SNAT --to-source 10.0.0.1"),
 	Entry("SNATAction fully random", Features{SNATFullyRandom: true}, SNATAction{ToAddr: "10.0.0.1"}, "--jump SNAT --to-source 10.0.0.1 --random-fully"),
+	Entry("SNATAction random fully disabled", Features{SNATFullyRandom: true}, SNATAction{ToAddr: "10.0.0.1", DisableRandomFully: true}, "--jump SNAT --to-source 10.0.0.1"),
+	Entry("NetmapAction", Features{}, NetmapAction{ToNet: "10.0.0.0/28"}, "--jump NETMAP --to 10.0.0.0/28"),
 	Entry("MasqAction", Features{}, MasqAction{}, "--jump MASQUERADE"),
 	Entry("MasqAction", Features{MASQFullyRandom: true}, MasqAction{}, "--jump MASQUERADE --random-fully"),
+	Entry("MasqAction random fully disabled", Features{MASQFullyRandom: true}, MasqAction{ToPorts: "99-100", DisableRandomFully: true}, "--jump MASQUERADE --to-ports 99-100"),
 	Entry("ClearMarkAction", Features{}, ClearMarkAction{Mark: 0x1000}, "--jump MARK --set-mark 0/0x1000"),
 	Entry("SetMarkAction", Features{}, SetMarkAction{Mark: 0x1000}, "--jump MARK --set-mark 0x1000/0x1000"),
+	Entry("SetDSCPAction", Features{}, SetDSCPAction{Value: 46}, "--jump DSCP --set-dscp 46"),
 	Entry("SetMaskedMarkAction", Features{}, SetMaskedMarkAction{
 		Mark: 0x1000,
 		Mask: 0xf000,
@@ -46,4 +52,5 @@ var _ = DescribeTable("Actions",
 	Entry("RestoreConnMarkAction", Features{}, RestoreConnMarkAction{RestoreMask: 0x100}, "--jump CONNMARK --restore-mark --mark 0x100"),
 	Entry("SaveConnMarkAction", Features{}, SaveConnMarkAction{}, "--jump CONNMARK --save-mark --mark 0xffffffff"),
 	Entry("RestoreConnMarkAction", Features{}, RestoreConnMarkAction{}, "--jump CONNMARK --restore-mark --mark 0xffffffff"),
+	Entry("CTHelperAction", Features{}, CTHelperAction{Helper: "ftp"}, "--jump CT --helper ftp"),
 )
@@ -0,0 +1,69 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import "fmt"
+
+// CheckpointKey identifies the table in a checkpoint.
+func (t *Table) CheckpointKey() string {
+	return fmt.Sprintf("%s-v%d", t.Name, t.IPVersion)
+}
+
+// TableCheckpoint records the chains that we've programmed as we last programmed or read them.
+type TableCheckpoint struct {
+	// Hashes maps from chain name to the chain's rule hashes, for our chains and for the chains
+	// that we've inserted rules into.  Rules that aren't ours have an empty hash.
+	Hashes map[string][]string `json:"hashes"`
+	// Rules maps from the name of each chain that we've inserted rules into to its full rules,
+	// which we need to remove our rules from it.
+	Rules map[string][]string `json:"rules"`
+}
+
+// Checkpoint returns our chains, and the chains that we've inserted rules into, as we last
+// programmed or read them.
+func (t *Table) Checkpoint() TableCheckpoint {
+	cp := TableCheckpoint{
+		Hashes: map[string][]string{},
+		Rules:  map[string][]string{},
+	}
+	for chainName, hashes := range t.chainToDataplaneHashes {
+		if t.ourChainsRegexp.MatchString(chainName) {
+			cp.Hashes[chainName] = append([]string{}, hashes...)
+			continue
+		}
+		if numEmptyStrings(hashes) == len(hashes) {
+			// None of our rules in this chain.
+			continue
+		}
+		cp.Hashes[chainName] = append([]string{}, hashes...)
+		cp.Rules[chainName] = append([]string{}, t.chainToFullRules[chainName]...)
+	}
+	return cp
+}
+
+// LoadCheckpoint loads a checkpoint that was taken by a previous instance of Felix.  When the
+// first Apply() would load the dataplane state, it uses the checkpoint instead of running
+// iptables-save, so that it goes straight to writing the rules that have changed since the
+// checkpoint.  The post-write check then reads back the dataplane as it would after any write
+// and corrects the chains that someone else changed while Felix wasn't running.
+func (t *Table) LoadCheckpoint(cp TableCheckpoint) {
+	if cp.Hashes == nil {
+		cp.Hashes = map[string][]string{}
+	}
+	if cp.Rules == nil {
+		cp.Rules = map[string][]string{}
+	}
+	t.checkpoint = &cp
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+)
+
+// restoreChunkSize is the size of the chunks that make up a chunkedBuffer.  It's big enough that
+// typical updates fit in one chunk but small enough that the chunks are cheap to pool.
+const restoreChunkSize = 64 * 1024
+
+// restoreChunkPool is shared by all the tables so that, between them, they only hold on to
+// enough chunks for the largest concurrent updates, rather than each table keeping a buffer
+// sized for its largest ever update.
+var restoreChunkPool = sync.Pool{
+	New: func() interface{} {
+		chunk := make([]byte, 0, restoreChunkSize)
+		return &chunk
+	},
+}
+
+// chunkedBuffer is an append-only buffer made up of fixed-size chunks from restoreChunkPool.
+// Unlike a bytes.Buffer, it never has to reallocate and copy its contents as it grows, so
+// rendering a full resync of a rule-heavy table doesn't allocate and discard a series of
+// ever-larger buffers.  Its contents are read by streaming them, chunk by chunk, to
+// iptables-restore.
+type chunkedBuffer struct {
+	chunks []*[]byte
+	len    int
+}
+
+func (c *chunkedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		chunk := c.chunkWithSpace()
+		written := copy((*chunk)[len(*chunk):cap(*chunk)], p)
+		*chunk = (*chunk)[:len(*chunk)+written]
+		p = p[written:]
+	}
+	c.len += n
+	return n, nil
+}
+
+func (c *chunkedBuffer) WriteString(s string) (int, error) {
+	n := len(s)
+	for len(s) > 0 {
+		chunk := c.chunkWithSpace()
+		written := copy((*chunk)[len(*chunk):cap(*chunk)], s)
+		*chunk = (*chunk)[:len(*chunk)+written]
+		s = s[written:]
+	}
+	c.len += n
+	return n, nil
+}
+
+func (c *chunkedBuffer) WriteByte(b byte) error {
+	chunk := c.chunkWithSpace()
+	*chunk = append(*chunk, b)
+	c.len++
+	return nil
+}
+
+// chunkWithSpace returns the last chunk, adding a new one if the last chunk is full.
+func (c *chunkedBuffer) chunkWithSpace() *[]byte {
+	if len(c.chunks) > 0 {
+		last := c.chunks[len(c.chunks)-1]
+		if len(*last) < cap(*last) {
+			return last
+		}
+	}
+	chunk := restoreChunkPool.Get().(*[]byte)
+	c.chunks = append(c.chunks, chunk)
+	return chunk
+}
+
+func (c *chunkedBuffer) Len() int {
+	return c.len
+}
+
+// Reset empties the buffer and returns its chunks to the pool.  Readers returned by NewReader()
+// must not be used after Reset().
+func (c *chunkedBuffer) Reset() {
+	for i, chunk := range c.chunks {
+		*chunk = (*chunk)[:0]
+		restoreChunkPool.Put(chunk)
+		c.chunks[i] = nil
+	}
+	c.chunks = c.chunks[:0]
+	c.len = 0
+}
+
+// NewReader returns a reader that streams the current contents of the buffer without copying
+// them.
+func (c *chunkedBuffer) NewReader() io.Reader {
+	readers := make([]io.Reader, len(c.chunks))
+	for i, chunk := range c.chunks {
+		readers[i] = bytes.NewReader(*chunk)
+	}
+	return io.MultiReader(readers...)
+}
+
+// String returns a copy of the contents of the buffer.
+func (c *chunkedBuffer) String() string {
+	var sb strings.Builder
+	sb.Grow(c.len)
+	for _, chunk := range c.chunks {
+		sb.Write(*chunk)
+	}
+	return sb.String()
+}
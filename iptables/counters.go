@@ -0,0 +1,82 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// ChainJump identifies the rules in chain Chain that jump to chain Target.
+type ChainJump struct {
+	Chain  string
+	Target string
+}
+
+// Counters holds the packet and byte counters of one or more iptables rules.
+type Counters struct {
+	Packets uint64
+	Bytes   uint64
+}
+
+// counterRuleRegexp matches an "iptables-save -c" rule line and captures its packet and byte
+// counters, its chain and, if it has one, its jump target.
+var counterRuleRegexp = regexp.MustCompile(`^\[(\d+):(\d+)\] -A (\S+)(?:.* (?:-j|--jump|-g|--goto) (\S+))?`)
+
+// ReadJumpCounters reads the counters of the rules in the table that jump to another chain from
+// the dataplane.  Where a chain has more than one rule that jumps to the same target, their
+// counters are added together.  Unlike the Table's other methods, it doesn't touch the Table's
+// state so it may be called from any goroutine.
+func (t *Table) ReadJumpCounters() (map[ChainJump]Counters, error) {
+	cmd := t.newCmd(t.iptablesSaveCmd, "-c", "-t", t.Name)
+	countNumSaveCalls.Inc()
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseJumpCounters(bytes.NewReader(output))
+}
+
+// parseJumpCounters extracts the jump rule counters from "iptables-save -c" output.
+func parseJumpCounters(r io.Reader) (map[ChainJump]Counters, error) {
+	counters := map[ChainJump]Counters{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		m := counterRuleRegexp.FindStringSubmatch(scanner.Text())
+		if m == nil || m[4] == "" {
+			continue
+		}
+		packets, err := strconv.ParseUint(m[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		bytes, err := strconv.ParseUint(m[2], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		jump := ChainJump{Chain: m[3], Target: m[4]}
+		c := counters[jump]
+		c.Packets += packets
+		c.Bytes += bytes
+		counters[jump] = c
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return counters, nil
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("parseJumpCounters", func() {
+	It("should sum the counters of jump rules", func() {
+		counters, err := parseJumpCounters(strings.NewReader(`# Generated by iptables-save
+*filter
+:INPUT ACCEPT [10:1000]
+:cali-th-eth0 - [0:0]
+[5:500] -A cali-th-eth0 -m comment --comment "cali:abcd" -m mark --mark 0x0/0x10 -j cali-po-default.polA
+[1:100] -A cali-th-eth0 -m comment --comment "cali:efgh" -m mark --mark 0x10/0x10 -j RETURN
+[2:200] -A cali-th-eth0 -m comment --comment "cali:ijkl" -g cali-po-default.polA
+[7:700] -A cali-th-eth0 -m comment --comment "cali:mnop" -m mark --mark 0x10/0x10
+COMMIT
+`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(counters).To(Equal(map[ChainJump]Counters{
+			{Chain: "cali-th-eth0", Target: "cali-po-default.polA"}: {Packets: 7, Bytes: 700},
+			{Chain: "cali-th-eth0", Target: "RETURN"}:               {Packets: 1, Bytes: 100},
+		}))
+	})
+})
@@ -49,6 +49,9 @@ var (
 	v5Dot7Dot0 = versionparse.MustParseVersion("5.7.0")
 )
 
+// ipSetMatchOverride is the feature detection override for IPSetMatchSupported.
+const ipSetMatchOverride = "IPSetMatch"
+
 type Features struct {
 	// SNATFullyRandom is true if --random-fully is supported by the SNAT action.
 	SNATFullyRandom bool
@@ -68,6 +71,7 @@ type FeatureDetector struct {
 	featureCache    *Features
 	featureOverride map[string]string
 	loggedOverrides bool
+	ipSetMatchCache *bool
 
 	// Path to file with kernel version
 	GetKernelVersionReader func() (io.Reader, error)
@@ -117,6 +121,10 @@ func (d *FeatureDetector) refreshFeaturesLockHeld() {
 	}
 
 	for k, v := range d.featureOverride {
+		if k == ipSetMatchOverride {
+			// Handled by IPSetMatchSupported.
+			continue
+		}
 		ovr, err := strconv.ParseBool(v)
 		logCxt := log.WithFields(log.Fields{
 			"flag":  k,
@@ -155,6 +163,50 @@ func (d *FeatureDetector) refreshFeaturesLockHeld() {
 	}
 }
 
+// IPSetMatchSupported returns true if iptables can match on IP sets with --match-set.  That needs
+// the kernel's xt_set module, which some minimal kernels leave out, and revision 1 or later of
+// the set match; revision 0 only has the old --set option.  Without it, any rule that references
+// an IP set fails to program.  The check runs the iptables binary of the given backend ("legacy"
+// or "nft"), which is the one that programs our rules, rather than whichever one "iptables" is.
+// Unlike the other features, this is only detected once.
+func (d *FeatureDetector) IPSetMatchSupported(lookPath func(file string) (string, error), backendMode string) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.ipSetMatchCache == nil {
+		supported := d.detectIPSetMatchLockHeld(lookPath, backendMode)
+		d.ipSetMatchCache = &supported
+	}
+	return *d.ipSetMatchCache
+}
+
+func (d *FeatureDetector) detectIPSetMatchLockHeld(lookPath func(file string) (string, error), backendMode string) bool {
+	logCxt := log.WithField("flag", ipSetMatchOverride)
+	if v, ok := d.featureOverride[ipSetMatchOverride]; ok {
+		ovr, err := strconv.ParseBool(v)
+		if err == nil {
+			logCxt.WithField("value", v).Info("Overriding feature detection flag")
+			return ovr
+		}
+		logCxt.WithField("value", v).Warn("Failed to parse value for feature detection override; ignoring")
+	}
+
+	// iptables only offers the help for a match revision that the kernel supports so, if xt_set
+	// is missing, it fails to load the match.
+	cmd := d.NewCmd(findBestBinary(lookPath, 4, backendMode, ""), "-m", "set", "--help")
+	out, err := cmd.Output()
+	if err != nil {
+		logCxt.WithError(err).Warn("Failed to load the iptables set match, is the xt_set kernel module missing?")
+		return false
+	}
+	if !bytes.Contains(out, []byte("--match-set")) {
+		logCxt.WithField("help", string(out)).Warn("iptables set match doesn't support --match-set")
+		return false
+	}
+	logCxt.Debug("iptables supports the set match")
+	return true
+}
+
 func (d *FeatureDetector) getIptablesVersion() *versionparse.Version {
 	cmd := d.NewCmd("iptables", "--version")
 	out, err := cmd.Output()
@@ -247,8 +299,8 @@ func DetectBackend(lookPath func(file string) (string, error), newCmd cmdFactory
 }
 
 // findBestBinary tries to find an iptables binary for the specific variant (legacy/nftables mode) and returns the name
-// of the binary.  Falls back on iptables-restore/iptables-save if the specific variant isn't available.
-// Panics if no binary can be found.
+// of the binary.  Falls back on iptables-restore/iptables-save if the specific variant isn't available.  If
+// saveOrRestore is empty, it looks for the main iptables binary instead.  Panics if no binary can be found.
 func findBestBinary(lookPath func(file string) (string, error), ipVersion uint8, backendMode, saveOrRestore string) string {
 	if lookPath == nil {
 		lookPath = exec.LookPath
@@ -257,9 +309,13 @@ func findBestBinary(lookPath func(file string) (string, error), ipVersion uint8,
 	if ipVersion == 6 {
 		verInfix = "6"
 	}
+	suffix := ""
+	if saveOrRestore != "" {
+		suffix = "-" + saveOrRestore
+	}
 	candidates := []string{
-		"ip" + verInfix + "tables-" + backendMode + "-" + saveOrRestore,
-		"ip" + verInfix + "tables-" + saveOrRestore,
+		"ip" + verInfix + "tables-" + backendMode + suffix,
+		"ip" + verInfix + "tables" + suffix,
 	}
 
 	logCxt := log.WithFields(log.Fields{
@@ -225,6 +225,94 @@ func TestFeatureDetectionOverride(t *testing.T) {
 	}
 }
 
+func TestIPSetMatchDetection(t *testing.T) {
+	RegisterTestingT(t)
+
+	type test struct {
+		name     string
+		backend  string
+		help     string
+		override map[string]string
+		expected bool
+	}
+	for _, tst := range []test{
+		{
+			"set match with --match-set",
+			"legacy",
+			"set match options:\n [!] --match-set name flags [--return-nomatch]\n",
+			nil,
+			true,
+		},
+		{
+			"set match with --match-set, nft backend",
+			"nft",
+			"set match options:\n [!] --match-set name flags [--return-nomatch]\n",
+			nil,
+			true,
+		},
+		{
+			"set match revision 0",
+			"legacy",
+			"set match options:\n [!] --set name flags\n",
+			nil,
+			false,
+		},
+		{
+			"set match fails to load",
+			"legacy",
+			"error",
+			nil,
+			false,
+		},
+		{
+			"set match fails to load, nft backend",
+			"nft",
+			"error",
+			nil,
+			false,
+		},
+		{
+			"override with set match missing",
+			"legacy",
+			"error",
+			map[string]string{"IPSetMatch": "true"},
+			true,
+		},
+		{
+			"override with set match present",
+			"legacy",
+			"set match options:\n [!] --match-set name flags\n",
+			map[string]string{"IPSetMatch": "false"},
+			false,
+		},
+	} {
+		tst := tst
+		t.Run(tst.name, func(t *testing.T) {
+			RegisterTestingT(t)
+			dataplane := newMockDataplane("filter", map[string][]string{}, tst.backend)
+			featureDetector := NewFeatureDetector(tst.override)
+			featureDetector.NewCmd = dataplane.newCmd
+			featureDetector.GetKernelVersionReader = dataplane.getKernelVersionReader
+
+			if tst.help == "error" {
+				dataplane.FailNextSetMatchHelp = true
+			} else {
+				dataplane.SetMatchHelp = tst.help
+			}
+
+			Expect(featureDetector.IPSetMatchSupported(lookPathAll, tst.backend)).To(Equal(tst.expected))
+			if tst.override == nil {
+				// The check should use the backend's own binary.
+				Expect(dataplane.CmdNames).To(ConsistOf("iptables-" + tst.backend))
+			}
+			// The result should be cached.
+			numCmds := len(dataplane.CmdNames)
+			Expect(featureDetector.IPSetMatchSupported(lookPathAll, tst.backend)).To(Equal(tst.expected))
+			Expect(dataplane.CmdNames).To(HaveLen(numCmds))
+		})
+	}
+}
+
 func TestIptablesBackendDetection(t *testing.T) {
 	RegisterTestingT(t)
 
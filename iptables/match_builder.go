@@ -114,6 +114,11 @@ func (m MatchCriteria) RPFCheckFailed(acceptLocal bool) MatchCriteria {
 	return ret
 }
 
+// RPFCheckFailedLoose matches packets whose source isn't reachable via any interface.
+func (m MatchCriteria) RPFCheckFailedLoose() MatchCriteria {
+	return append(m, "-m rpfilter --invert --validmark --loose")
+}
+
 func (m MatchCriteria) IPVSConnection() MatchCriteria {
 	return append(m, "-m ipvs --ipvs")
 }
@@ -156,6 +161,20 @@ func (m MatchCriteria) NotConntrackState(stateNames string) MatchCriteria {
 	return append(m, fmt.Sprintf("-m conntrack ! --ctstate %s", stateNames))
 }
 
+// ConnLimitAbove matches if more than limit connections match the rule, counting all the
+// connections together rather than per source.
+func (m MatchCriteria) ConnLimitAbove(limit int) MatchCriteria {
+	return append(m, fmt.Sprintf("-m connlimit --connlimit-above %d --connlimit-mask 0", limit))
+}
+
+// HashLimitAbove matches packets above the given rate, using a single bucket for all the
+// packets that match the rule.  The name identifies the rule's bucket, so it must be unique, and
+// it is limited to 15 characters.
+func (m MatchCriteria) HashLimitAbove(name string, perSecond, burst int) MatchCriteria {
+	return append(m, fmt.Sprintf("-m hashlimit --hashlimit-name %s --hashlimit-above %d/sec --hashlimit-burst %d",
+		name, perSecond, burst))
+}
+
 func (m MatchCriteria) Protocol(name string) MatchCriteria {
 	return append(m, fmt.Sprintf("-p %s", name))
 }
@@ -164,6 +183,12 @@ func (m MatchCriteria) NotProtocol(name string) MatchCriteria {
 	return append(m, fmt.Sprintf("! -p %s", name))
 }
 
+// NotSCTPChunkTypesOnly matches SCTP packets that contain a chunk of a type that isn't in the
+// comma-separated list of chunk types.  It must follow a match on the SCTP protocol.
+func (m MatchCriteria) NotSCTPChunkTypesOnly(chunkTypes string) MatchCriteria {
+	return append(m, fmt.Sprintf("-m sctp ! --chunk-types only %s", chunkTypes))
+}
+
 func (m MatchCriteria) ProtocolNum(num uint8) MatchCriteria {
 	return append(m, fmt.Sprintf("-p %d", num))
 }
@@ -59,6 +59,10 @@ var _ = DescribeTable("MatchBuilder",
 	Entry("NotMarkMatchesWithMask", Match().NotMarkMatchesWithMask(0x400a, 0xf00f), "-m mark ! --mark 0x400a/0xf00f"),
 	// Conntrack.
 	Entry("ConntrackState", Match().ConntrackState("INVALID"), "-m conntrack --ctstate INVALID"),
+	// Limits.
+	Entry("ConnLimitAbove", Match().ConnLimitAbove(100), "-m connlimit --connlimit-above 100 --connlimit-mask 0"),
+	Entry("HashLimitAbove", Match().HashLimitAbove("cali1234", 10, 20),
+		"-m hashlimit --hashlimit-name cali1234 --hashlimit-above 10/sec --hashlimit-burst 20"),
 	// Interfaces.
 	Entry("InInterface", Match().InInterface("tap1234abcd"), "--in-interface tap1234abcd"),
 	Entry("OutInterface", Match().OutInterface("tap1234abcd"), "--out-interface tap1234abcd"),
@@ -71,6 +75,7 @@ var _ = DescribeTable("MatchBuilder",
 	// Protocol.
 	Entry("Protocol", Match().Protocol("tcp"), "-p tcp"),
 	Entry("NotProtocol", Match().NotProtocol("tcp"), "! -p tcp"),
+	Entry("NotSCTPChunkTypesOnly", Match().NotSCTPChunkTypesOnly("HEARTBEAT"), "-m sctp ! --chunk-types only HEARTBEAT"),
 	Entry("ProtocolNum", Match().ProtocolNum(123), "-p 123"),
 	Entry("NotProtocolNum", Match().NotProtocolNum(123), "! -p 123"),
 	// CIDRs.
@@ -15,13 +15,13 @@
 package iptables
 
 import (
-	"bytes"
 	"fmt"
+	"io"
 
 	log "github.com/sirupsen/logrus"
 )
 
-// RestoreInputBuilder builds a byte slice for use as input to iptables-restore.
+// RestoreInputBuilder builds the input to iptables-restore.
 //
 // Operations must be done inside a per-table transaction.
 //
@@ -32,13 +32,13 @@ import (
 //     buf.WriteForwardReference("cali-chain-name")
 //     buf.WriteLine("-A cali-chain-name ...")
 //     buf.EndTransaction()
-//     bytes = buf.GetBytesAndReset()
-//     <write bytes to iptables-restore stdin>
+//     <stream buf.NewReader() to iptables-restore stdin>
+//     buf.Reset()
 //
 // Transactions are ignored completely if there are no writes between the StartTransaction()
 // and EndTransaction() calls.
 type RestoreInputBuilder struct {
-	buf              bytes.Buffer
+	buf              chunkedBuffer
 	currentTableName string
 	txnOpenerWritten bool
 	NumLinesWritten  counter
@@ -84,7 +84,18 @@ func (b *RestoreInputBuilder) writeFormattedLine(format string, args ...interfac
 	if err != nil {
 		log.WithError(err).Panic("Failed to write to in-memory buffer")
 	}
-	b.buf.WriteString("\n")
+	b.endLine()
+}
+
+// writeLine writes a line to the internal buffer, appending a new line.  Unlike
+// writeFormattedLine, it doesn't need to box its argument or parse a format string.
+func (b *RestoreInputBuilder) writeLine(line string) {
+	_, _ = b.buf.WriteString(line)
+	b.endLine()
+}
+
+func (b *RestoreInputBuilder) endLine() {
+	_ = b.buf.WriteByte('\n')
 	if b.NumLinesWritten != nil {
 		b.NumLinesWritten.Inc()
 	}
@@ -114,19 +125,22 @@ func (b *RestoreInputBuilder) WriteForwardReference(chainName string) {
 // Panics if there is no open transaction.
 func (b *RestoreInputBuilder) WriteLine(line string) {
 	b.maybeWriteTransactionOpener()
-	b.writeFormattedLine(line)
+	b.writeLine(line)
 }
 
-// GetBytesAndReset returns the contents of the buffer and, as a side effect, resets the buffer.  For performance,
-// this is a direct reference to the data rather than a copy.  The returned slice is only valid until the next
-// write operation on the builder.  Should be called after EndTransaction; panics if there is a still-open transaction.
-func (b *RestoreInputBuilder) GetBytesAndReset() []byte {
+// NewReader returns a reader that streams the contents of the buffer, without copying it.  The
+// reader is only valid until the next write operation on the builder or Reset().  Should be called
+// after EndTransaction; panics if there is a still-open transaction.
+func (b *RestoreInputBuilder) NewReader() io.Reader {
 	if b.currentTableName != "" {
-		log.Panic("GetBytesAndReset() called inside transaction.")
+		log.Panic("NewReader() called inside transaction.")
 	}
-	buf := b.buf.Next(b.buf.Len())
-	b.Reset()
-	return buf
+	return b.buf.NewReader()
+}
+
+// String returns a copy of the contents of the buffer, for logging.
+func (b *RestoreInputBuilder) String() string {
+	return b.buf.String()
 }
 
 type counter interface {
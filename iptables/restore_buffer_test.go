@@ -0,0 +1,86 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RestoreInputBuilder", func() {
+	var buf RestoreInputBuilder
+
+	BeforeEach(func() {
+		buf = RestoreInputBuilder{}
+	})
+
+	AfterEach(func() {
+		buf.Reset()
+	})
+
+	readAll := func() string {
+		b, err := ioutil.ReadAll(buf.NewReader())
+		Expect(err).NotTo(HaveOccurred())
+		return string(b)
+	}
+
+	It("should render a transaction", func() {
+		buf.StartTransaction("filter")
+		buf.WriteForwardReference("cali-foo")
+		buf.WriteLine("-A cali-foo -m comment --comment \"100%\" -j ACCEPT")
+		buf.EndTransaction()
+		expected := "*filter\n:cali-foo - -\n-A cali-foo -m comment --comment \"100%\" -j ACCEPT\nCOMMIT\n"
+		Expect(readAll()).To(Equal(expected))
+		Expect(buf.String()).To(Equal(expected))
+	})
+
+	It("should skip empty transactions", func() {
+		buf.StartTransaction("filter")
+		buf.EndTransaction()
+		Expect(buf.Empty()).To(BeTrue())
+		Expect(readAll()).To(Equal(""))
+	})
+
+	It("should stream input that spans many chunks", func() {
+		var expected strings.Builder
+		expected.WriteString("*filter\n")
+		buf.StartTransaction("filter")
+		for i := 0; expected.Len() < 3*restoreChunkSize; i++ {
+			line := fmt.Sprintf("-A cali-foo -m comment --comment \"rule %d\" -j ACCEPT", i)
+			buf.WriteLine(line)
+			expected.WriteString(line + "\n")
+		}
+		buf.EndTransaction()
+		expected.WriteString("COMMIT\n")
+		Expect(len(buf.buf.chunks)).To(BeNumerically(">", 3))
+		Expect(readAll()).To(Equal(expected.String()))
+	})
+
+	It("should be reusable after a reset", func() {
+		buf.StartTransaction("filter")
+		buf.WriteLine("-A cali-foo -j ACCEPT")
+		buf.EndTransaction()
+		buf.Reset()
+		Expect(buf.Empty()).To(BeTrue())
+		buf.StartTransaction("nat")
+		buf.WriteLine("-A cali-bar -j ACCEPT")
+		buf.EndTransaction()
+		Expect(readAll()).To(Equal("*nat\n-A cali-bar -j ACCEPT\nCOMMIT\n"))
+	})
+})
@@ -0,0 +1,116 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// restoreFailedLineRegexp matches the line number in iptables-restore's error output; depending
+// on the version it reports "line N failed" or "Error occurred at line: N".
+var restoreFailedLineRegexp = regexp.MustCompile(`(?m)line:? (\d+)(?: failed|\s*$)`)
+
+// RestoreError is returned when ip(6)tables-restore fails.  If iptables-restore reported the line
+// of its input that failed, the error records the line and the chain that it updated.  For a rule
+// in one of our chains, it also records the index of the rule in the chain and, if the Table was
+// given a DescribeChain function, where the chain came from (for example, a policy).
+type RestoreError struct {
+	Err error
+	// Line is the (1-indexed) line number of the input that failed, or 0 if iptables-restore
+	// didn't say.
+	Line      int
+	InputLine string
+	Chain     string
+	// RuleIndex is the (0-indexed) index of the rule within Chain, or -1 if the line isn't a rule
+	// of one of our chains.
+	RuleIndex int
+	Origin    string
+}
+
+func (e *RestoreError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("iptables-restore failed: %v", e.Err)
+	}
+	var details []string
+	if e.Chain != "" {
+		details = append(details, "chain "+e.Chain)
+	}
+	if e.RuleIndex >= 0 {
+		details = append(details, fmt.Sprintf("rule %d", e.RuleIndex))
+	}
+	if e.Origin != "" {
+		details = append(details, "from "+e.Origin)
+	}
+	if len(details) == 0 {
+		return fmt.Sprintf("iptables-restore failed at line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("iptables-restore failed at line %d (%s): %v",
+		e.Line, strings.Join(details, ", "), e.Err)
+}
+
+func (e *RestoreError) Unwrap() error {
+	return e.Err
+}
+
+// newRestoreError maps the line that iptables-restore reported as failing back to the chain, rule
+// and, via DescribeChain, the policy or endpoint that generated it.
+func (t *Table) newRestoreError(err error, input, errOutput string, features *Features) *RestoreError {
+	rErr := &RestoreError{Err: err, RuleIndex: -1}
+	m := restoreFailedLineRegexp.FindStringSubmatch(errOutput)
+	if m == nil {
+		return rErr
+	}
+	lineNum, _ := strconv.Atoi(m[1])
+	lines := strings.Split(input, "\n")
+	if lineNum < 1 || lineNum > len(lines) {
+		return rErr
+	}
+	rErr.Line = lineNum
+	rErr.InputLine = lines[lineNum-1]
+
+	fields := strings.Fields(rErr.InputLine)
+	if len(fields) < 2 {
+		return rErr
+	}
+	switch fields[0] {
+	case "-A", "-I", "-R", "-D", "--new-chain", "--delete-chain":
+		rErr.Chain = fields[1]
+	default:
+		if strings.HasPrefix(fields[0], ":") {
+			// Forward reference.
+			rErr.Chain = fields[0][1:]
+		}
+	}
+	if rErr.Chain == "" {
+		return rErr
+	}
+
+	// Our rules carry a hash comment; find the rule with that hash.
+	if chain, ok := t.desiredStateOfChain(rErr.Chain); ok {
+		for i, hash := range chain.RuleHashes(features) {
+			if strings.Contains(rErr.InputLine, t.commentFrag(hash)) {
+				rErr.RuleIndex = i
+				break
+			}
+		}
+	}
+	if t.describeChain != nil {
+		rErr.Origin = t.describeChain(rErr.Chain)
+	}
+	return rErr
+}
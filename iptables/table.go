@@ -17,12 +17,14 @@ package iptables
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os/exec"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -32,12 +34,16 @@ import (
 
 	"github.com/projectcalico/libcalico-go/lib/set"
 
+	"github.com/projectcalico/felix/alerts"
 	"github.com/projectcalico/felix/logutils"
+	"github.com/projectcalico/felix/tracing"
 )
 
 const (
 	MaxChainNameLength   = 28
 	minPostWriteInterval = 50 * time.Millisecond
+	// alertFlushTimeout is how long we wait for an alert to be sent before panicking.
+	alertFlushTimeout = 5 * time.Second
 )
 
 var (
@@ -211,6 +217,10 @@ type Table struct {
 	// this table).
 	chainRefCounts map[string]int
 	dirtyChains    set.Set
+	// externalChains holds the names of chains that our chains may jump to but whose contents
+	// belong to someone else.  We create them, if needed, while they're referenced but we never
+	// flush, program or delete them.
+	externalChains set.Set
 
 	inSyncWithDataPlane bool
 
@@ -218,6 +228,9 @@ type Table struct {
 	// it is updated when we write to the dataplane but it can also be read back and compared
 	// to what we calculate from chainToContents.
 	chainToDataplaneHashes map[string][]string
+	// checkpoint holds the checkpoint that was loaded by LoadCheckpoint(), until we first load
+	// the dataplane state.
+	checkpoint *TableCheckpoint
 
 	// chainToFullRules contains the full rules for any chains that we may be hooking into, mapped from chain name
 	// to slices of rules in that chain.
@@ -276,8 +289,18 @@ type Table struct {
 	// lookPath is a shim for exec.LookPath.
 	lookPath func(file string) (string, error)
 
-	onStillAlive func()
-	opReporter   logutils.OpRecorder
+	onStillAlive  func()
+	opReporter    logutils.OpRecorder
+	deferRefresh  func() bool
+	describeChain func(chainName string) string
+
+	// onRestoreFailure and restoreFailureHandled: see TableOptions.OnRestoreFailure.
+	onRestoreFailure      func(table string, rErr *RestoreError) bool
+	restoreFailureHandled bool
+
+	// numInconsistencies counts the out-of-sync chains that we've found when reloading the
+	// dataplane state.
+	numInconsistencies int
 }
 
 type TableOptions struct {
@@ -305,8 +328,30 @@ type TableOptions struct {
 	OnStillAlive func()
 	// OpRecorder to tell when we do resyncs etc.
 	OpRecorder logutils.OpRecorder
+	// ExternalChains are chains that our chains may jump to but that we don't own.  The Table
+	// creates them if they don't exist but it leaves their contents alone.
+	ExternalChains []string
+	// DeferRefresh, if non-nil, is called when the refresh timer pops.  If it returns true, the
+	// refresh is put off, by at most one more RefreshInterval, so that it doesn't compete with
+	// more urgent work.
+	DeferRefresh func() bool
+	// DescribeChain, if non-nil, is called when iptables-restore fails on a line that updates one
+	// of our chains, to say where the chain came from; for example, "policy default/foo".  It's
+	// called from the goroutine that calls Apply().
+	DescribeChain func(chainName string) string
+	// OnRestoreFailure, if non-nil, is called when the Table gives up retrying a failed
+	// iptables-restore.  If it returns true, its caller takes on reporting the failure (for
+	// example, in the status of the endpoints that use the failed chain) so the Table doesn't
+	// panic; it asks to be rescheduled after HandledRestoreFailureRetryInterval.  Once a later
+	// Apply() succeeds, it's called again with a nil error.  It's called from the goroutine that
+	// calls Apply().
+	OnRestoreFailure func(table string, rErr *RestoreError) bool
 }
 
+// HandledRestoreFailureRetryInterval is how long the Table waits before retrying after a failure
+// that its OnRestoreFailure function has taken on.
+const HandledRestoreFailureRetryInterval = 10 * time.Second
+
 func NewTable(
 	name string,
 	ipVersion uint8,
@@ -394,6 +439,7 @@ func NewTable(
 		chainNameToChain:       map[string]*Chain{},
 		chainRefCounts:         refcounts,
 		dirtyChains:            set.New(),
+		externalChains:         set.FromArray(options.ExternalChains),
 		chainToDataplaneHashes: map[string][]string{},
 		chainToFullRules:       map[string][]string{},
 		logCxt: log.WithFields(log.Fields{
@@ -430,6 +476,9 @@ func NewTable(
 		gaugeNumRules:         gaugeNumRules.WithLabelValues(fmt.Sprintf("%d", ipVersion), name),
 		countNumLinesExecuted: countNumLinesExecuted.WithLabelValues(fmt.Sprintf("%d", ipVersion), name),
 		opReporter:            options.OpRecorder,
+		deferRefresh:          options.DeferRefresh,
+		describeChain:         options.DescribeChain,
+		onRestoreFailure:      options.OnRestoreFailure,
 	}
 	table.restoreInputBuffer.NumLinesWritten = table.countNumLinesExecuted
 
@@ -551,6 +600,20 @@ func (t *Table) RemoveChainByName(name string) {
 	t.InvalidateDataplaneCache("chain removal")
 }
 
+// RemoveAllChainsAndRules queues the removal of all of our chains and of the rules that we insert
+// into or append to other chains, so that the next Apply() leaves no trace of Felix in the table.
+func (t *Table) RemoveAllChainsAndRules() {
+	for chainName := range t.chainToInsertedRules {
+		t.InsertOrAppendRules(chainName, nil)
+	}
+	for chainName := range t.chainToAppendedRules {
+		t.AppendRules(chainName, nil)
+	}
+	for chainName := range t.chainNameToChain {
+		t.RemoveChainByName(chainName)
+	}
+}
+
 // increfReferredChains finds all the chains that the given rules refer to  (i.e. have jumps/gotos to) and
 // increments their refcount.
 func (t *Table) increfReferredChains(rules []Rule) {
@@ -603,7 +666,14 @@ func (t *Table) loadDataplaneState() {
 	t.opReporter.RecordOperation(fmt.Sprintf("resync-%v-v%d", t.Name, t.IPVersion))
 
 	t.lastReadTime = t.timeNow()
-	dataplaneHashes, dataplaneRules := t.getHashesAndRulesFromDataplane()
+	var dataplaneHashes, dataplaneRules map[string][]string
+	if t.checkpoint != nil {
+		t.logCxt.Info("Using checkpoint in place of the first load of the dataplane state.")
+		dataplaneHashes, dataplaneRules = t.checkpoint.Hashes, t.checkpoint.Rules
+		t.checkpoint = nil
+	} else {
+		dataplaneHashes, dataplaneRules = t.getHashesAndRulesFromDataplane()
+	}
 
 	// Check that the rules we think we've programmed are still there and mark any inconsistent
 	// chains for refresh.
@@ -634,6 +704,7 @@ func (t *Table) loadDataplaneState() {
 					logCxt.WithField("actualRuleIDs", dpHashes).Warn(
 						"Chain had unexpected inserts, marking for resync")
 					t.dirtyInsertAppend.Add(chainName)
+					t.numInconsistencies++
 				}
 				continue
 			}
@@ -651,12 +722,14 @@ func (t *Table) loadDataplaneState() {
 					"actualRuleIDs":   dpHashes,
 				}).Warn("Detected out-of-sync inserts, marking for resync")
 				t.dirtyInsertAppend.Add(chainName)
+				t.numInconsistencies++
 			}
 		} else {
 			// One of our chains, should match exactly.
 			if !reflect.DeepEqual(dpHashes, expectedHashes) {
 				logCxt.Warn("Detected out-of-sync Calico chain, marking for resync")
 				t.dirtyChains.Add(chainName)
+				t.numInconsistencies++
 			}
 		}
 	}
@@ -684,6 +757,7 @@ func (t *Table) loadDataplaneState() {
 				if hash != "" {
 					logCxt.Info("Found unexpected insert, marking for cleanup")
 					t.dirtyInsertAppend.Add(chainName)
+					t.numInconsistencies++
 					break
 				}
 			}
@@ -692,6 +766,7 @@ func (t *Table) loadDataplaneState() {
 		// Chain exists in dataplane but not in memory, mark as dirty so we'll clean it up.
 		logCxt.Info("Found unexpected chain, marking for cleanup")
 		t.dirtyChains.Add(chainName)
+		t.numInconsistencies++
 	}
 
 	t.logCxt.Debug("Finished loading iptables state")
@@ -933,6 +1008,58 @@ func (t *Table) readHashesAndRulesFrom(r io.ReadCloser) (hashes map[string][]str
 	return hashes, rules, nil
 }
 
+// DumpState writes the desired state of the table to w, in a format similar to iptables-save.
+// Only chains and rules that Felix manages are included.  Like the Table's other methods, it must
+// be called from the thread that owns the Table.
+func (t *Table) DumpState(w io.Writer) error {
+	features := t.featureDetector.GetFeatures()
+	if _, err := fmt.Fprintf(w, "# IPv%d\n*%s\n", t.IPVersion, t.Name); err != nil {
+		return err
+	}
+	var lines []string
+	chainNames := make([]string, 0, len(t.chainNameToChain))
+	for name := range t.chainNameToChain {
+		chainNames = append(chainNames, name)
+	}
+	sort.Strings(chainNames)
+	for _, name := range chainNames {
+		lines = append(lines, fmt.Sprintf(":%s - -", name))
+	}
+	for _, name := range chainNames {
+		for _, r := range t.chainNameToChain[name].Rules {
+			lines = append(lines, r.RenderAppend(name, "", features))
+		}
+	}
+	for _, name := range sortedKeys(t.chainToInsertedRules) {
+		for _, r := range t.chainToInsertedRules[name] {
+			lines = append(lines, r.RenderInsert(name, "", features))
+		}
+	}
+	for _, name := range sortedKeys(t.chainToAppendedRules) {
+		for _, r := range t.chainToAppendedRules[name] {
+			lines = append(lines, r.RenderAppend(name, "", features))
+		}
+	}
+	lines = append(lines, "COMMIT")
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+func sortedKeys(m map[string][]Rule) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// NumInconsistencies returns the number of out-of-sync chains that the table has found (and
+// queued for repair) since it was created.
+func (t *Table) NumInconsistencies() int {
+	return t.numInconsistencies
+}
+
 func (t *Table) InvalidateDataplaneCache(reason string) {
 	logCxt := t.logCxt.WithField("reason", reason)
 	if !t.inSyncWithDataPlane {
@@ -944,15 +1071,27 @@ func (t *Table) InvalidateDataplaneCache(reason string) {
 }
 
 func (t *Table) Apply() (rescheduleAfter time.Duration) {
+	return t.ApplyWithContext(context.Background())
+}
+
+// ApplyWithContext is Apply, recording the iptables-save and iptables-restore calls that it makes
+// as child spans of the span in ctx.
+func (t *Table) ApplyWithContext(ctx context.Context) (rescheduleAfter time.Duration) {
 	now := t.timeNow()
 	// We _think_ we're in sync, check if there are any reasons to think we might
 	// not be in sync.
 	lastReadToNow := now.Sub(t.lastReadTime)
 	invalidated := false
+	refreshDeferred := false
 	if t.refreshInterval > 0 && lastReadToNow > t.refreshInterval {
-		// Too long since we've forced a refresh.
-		t.InvalidateDataplaneCache("refresh timer")
-		invalidated = true
+		if t.deferRefresh != nil && lastReadToNow < 2*t.refreshInterval && t.deferRefresh() {
+			t.logCxt.Debug("Deferring refresh")
+			refreshDeferred = true
+		} else {
+			// Too long since we've forced a refresh.
+			t.InvalidateDataplaneCache("refresh timer")
+			invalidated = true
+		}
 	}
 	// To workaround the possibility of another process clobbering our updates, we refresh the
 	// dataplane after we do a write at exponentially increasing intervals.  We do a refresh
@@ -987,11 +1126,13 @@ func (t *Table) Apply() (rescheduleAfter time.Duration) {
 		if !t.inSyncWithDataPlane {
 			// We have reason to believe that our picture of the dataplane is out of
 			// sync.  Refresh it.  This may mark more chains as dirty.
+			_, saveSpan := tracing.StartSpan(ctx, "iptables.LoadDataplaneState")
 			t.loadDataplaneState()
+			saveSpan.End()
 		}
 		t.onStillAlive()
 
-		if err := t.applyUpdates(); err != nil {
+		if err := t.applyUpdates(ctx); err != nil {
 			if retries > 0 {
 				retries--
 				t.logCxt.WithError(err).Warn("Failed to program iptables, will retry")
@@ -1009,12 +1150,25 @@ func (t *Table) Apply() (rescheduleAfter time.Duration) {
 				} else {
 					t.logCxt.WithField("iptablesState", string(output)).Error("Current state of iptables")
 				}
+				alerts.Raise(alerts.ConditionDataplaneProgrammingFailed, alerts.SeverityCritical,
+					"iptables/"+t.Name, fmt.Sprintf("Failed to program iptables table %s: %v", t.Name, err))
+				rErr, ok := err.(*RestoreError)
+				if ok && t.onRestoreFailure != nil && t.onRestoreFailure(t.Name, rErr) {
+					t.logCxt.WithError(err).Error("Failed to program iptables, will retry later.")
+					t.restoreFailureHandled = true
+					return HandledRestoreFailureRetryInterval
+				}
+				alerts.Flush(alertFlushTimeout)
 				t.logCxt.WithError(err).Panic("Failed to program iptables, giving up after retries")
 			}
 		}
 		if failedAtLeastOnce {
 			t.logCxt.Warn("Succeeded after retry.")
 		}
+		if t.restoreFailureHandled {
+			t.onRestoreFailure(t.Name, nil)
+			t.restoreFailureHandled = false
+		}
 		break
 	}
 
@@ -1025,6 +1179,10 @@ func (t *Table) Apply() (rescheduleAfter time.Duration) {
 		// Refresh interval is set, start with that.
 		lastReadToNow = now.Sub(t.lastReadTime)
 		rescheduleAfter = t.refreshInterval - lastReadToNow
+		if refreshDeferred {
+			// Check again soon, without overshooting the deadline by much.
+			rescheduleAfter = t.refreshInterval / 10
+		}
 	}
 	if t.postWriteInterval < time.Hour {
 		postWriteReched := t.lastWriteTime.Add(t.postWriteInterval).Sub(now)
@@ -1038,7 +1196,7 @@ func (t *Table) Apply() (rescheduleAfter time.Duration) {
 	return
 }
 
-func (t *Table) applyUpdates() error {
+func (t *Table) applyUpdates(ctx context.Context) error {
 	// If needed, detect the dataplane features.
 	features := t.featureDetector.GetFeatures()
 
@@ -1054,6 +1212,10 @@ func (t *Table) applyUpdates() error {
 	// Writing a forward reference ensures that the chain exists and that it is empty.
 	t.dirtyChains.Iter(func(item interface{}) error {
 		chainName := item.(string)
+		if t.externalChains.Contains(chainName) {
+			// Never flush an external chain, we handle those below.
+			return nil
+		}
 		chainNeedsToBeFlushed := false
 		if t.nftablesMode {
 			// iptables-nft-restore <v1.8.3 has a bug (https://bugzilla.netfilter.org/show_bug.cgi?id=1348)
@@ -1085,8 +1247,23 @@ func (t *Table) applyUpdates() error {
 		return nil
 	})
 
-	// Make a second pass over the dirty chains.  This time, we write out the rule changes.
+	// Create any external chains that we're about to refer to but that don't exist yet.  We do
+	// this with an explicit command, rather than a forward reference, so that we can't flush the
+	// rules that someone else has put in the chain.
 	newHashes := map[string][]string{}
+	t.dirtyChains.Iter(func(item interface{}) error {
+		chainName := item.(string)
+		if !t.externalChains.Contains(chainName) || t.chainRefCounts[chainName] == 0 {
+			return nil
+		}
+		if _, ok := t.chainToDataplaneHashes[chainName]; !ok {
+			buf.WriteLine(fmt.Sprintf("--new-chain %s", chainName))
+			newHashes[chainName] = []string{}
+		}
+		return nil
+	})
+
+	// Make a second pass over the dirty chains.  This time, we write out the rule changes.
 	t.dirtyChains.Iter(func(item interface{}) error {
 		chainName := item.(string)
 		if chain, ok := t.desiredStateOfChain(chainName); ok {
@@ -1237,6 +1414,9 @@ func (t *Table) applyUpdates() error {
 
 		t.dirtyChains.Iter(func(item interface{}) error {
 			chainName := item.(string)
+			if t.externalChains.Contains(chainName) {
+				return nil
+			}
 			if _, ok := t.desiredStateOfChain(chainName); !ok {
 				// Chain deletion
 				buf.WriteForwardReference(chainName)
@@ -1252,6 +1432,10 @@ func (t *Table) applyUpdates() error {
 	// references.
 	t.dirtyChains.Iter(func(item interface{}) error {
 		chainName := item.(string)
+		if t.externalChains.Contains(chainName) {
+			// Not ours to delete, even if we no longer refer to it.
+			return nil
+		}
 		if _, ok := t.desiredStateOfChain(chainName); !ok {
 			// Chain deletion
 			buf.WriteLine(fmt.Sprintf("--delete-chain %s", chainName))
@@ -1265,16 +1449,14 @@ func (t *Table) applyUpdates() error {
 	if buf.Empty() {
 		t.logCxt.Debug("Update ended up being no-op, skipping call to ip(6)tables-restore.")
 	} else {
-		// Get the contents of the buffer ready to send to iptables-restore.  Warning: for perf, this is directly
-		// accessing the buffer's internal array; don't touch the buffer after this point.
+		// Stream the contents of the buffer to iptables-restore.  Warning: for perf, the reader directly
+		// accesses the buffer's chunks; don't touch the buffer until we reset it below.
 		t.opReporter.RecordOperation(fmt.Sprintf("update-%v-v%d", t.Name, t.IPVersion))
-
-		inputBytes := buf.GetBytesAndReset()
+		defer buf.Reset()
 
 		if log.GetLevel() >= log.DebugLevel {
-			// Only convert (potentially very large slice) to string at debug level.
-			inputStr := string(inputBytes)
-			t.logCxt.WithField("iptablesInput", inputStr).Debug("Writing to iptables")
+			// Only convert (potentially very large buffer) to string at debug level.
+			t.logCxt.WithField("iptablesInput", buf.String()).Debug("Writing to iptables")
 		}
 
 		var outputBuf, errBuf bytes.Buffer
@@ -1303,28 +1485,38 @@ func (t *Table) applyUpdates() error {
 			}).Debug("Using native iptables-restore xtables lock.")
 		}
 		cmd := t.newCmd(t.iptablesRestoreCmd, args...)
-		cmd.SetStdin(bytes.NewReader(inputBytes))
+		cmd.SetStdin(buf.NewReader())
 		cmd.SetStdout(&outputBuf)
 		cmd.SetStderr(&errBuf)
 		countNumRestoreCalls.Inc()
+		_, restoreSpan := tracing.StartSpan(ctx, "iptables.Restore")
+		restoreSpan.SetAttribute("command", t.iptablesRestoreCmd)
 		// Note: calicoXtablesLock will be a dummy lock if our xtables lock is disabled (i.e. if iptables-restore
 		// supports the xtables lock itself, or if our implementation is disabled by config.
 		t.calicoXtablesLock.Lock()
 		err := cmd.Run()
 		t.calicoXtablesLock.Unlock()
+		restoreSpan.RecordError(err)
+		restoreSpan.End()
 		if err != nil {
 			// To log out the input, we must convert to string here since, after we return, the buffer can be re-used
 			// (and the logger may convert to string on a background thread).
-			inputStr := string(inputBytes)
+			inputStr := buf.String()
+			rErr := t.newRestoreError(err, inputStr, errBuf.String(), features)
 			t.logCxt.WithFields(log.Fields{
 				"output":      outputBuf.String(),
 				"errorOutput": errBuf.String(),
 				"error":       err,
 				"input":       inputStr,
+				"failedLine":  rErr.InputLine,
+				"chain":       rErr.Chain,
+				"ruleIndex":   rErr.RuleIndex,
+				"origin":      rErr.Origin,
 			}).Warn("Failed to execute ip(6)tables-restore command")
+			logutils.CaptureFailedCommand(t.iptablesRestoreCmd, args, inputStr, outputBuf.String(), errBuf.String(), err)
 			t.inSyncWithDataPlane = false
 			countNumRestoreErrors.Inc()
-			return err
+			return rErr
 		}
 		t.lastWriteTime = t.timeNow()
 		t.postWriteInterval = t.initialPostWriteInterval
@@ -1349,6 +1541,12 @@ func (t *Table) applyUpdates() error {
 	return nil
 }
 
+// HasHandledRestoreFailure returns true if the last Apply() gave up on an iptables-restore failure
+// that the OnRestoreFailure function took on, so the table isn't in sync.
+func (t *Table) HasHandledRestoreFailure() bool {
+	return t.restoreFailureHandled
+}
+
 // desiredStateOfChain returns the given chain, if and only if it exists in the cache and it is referenced by some
 // other chain.  If the chain doesn't exist or it is not referenced, returns nil and false.
 func (t *Table) desiredStateOfChain(chainName string) (chain *Chain, present bool) {
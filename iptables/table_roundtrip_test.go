@@ -0,0 +1,230 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables_test
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+
+	. "github.com/projectcalico/felix/iptables"
+	"github.com/projectcalico/felix/logutils"
+	"github.com/projectcalico/felix/rules"
+)
+
+// The round-trip test renders randomly generated chains, feeds them through the (simulated)
+// iptables-restore and iptables-save, and checks that the table reads back exactly what it
+// wrote.  Set FELIX_RANDOM_TEST_SEED to reproduce a failure and FELIX_RANDOM_TEST_ITERATIONS to
+// run it for longer.
+
+func randomTestSeed() int64 {
+	if s, err := strconv.ParseInt(os.Getenv("FELIX_RANDOM_TEST_SEED"), 10, 64); err == nil {
+		return s
+	}
+	return time.Now().UnixNano()
+}
+
+func randomTestIterations(def int) int {
+	if n, err := strconv.Atoi(os.Getenv("FELIX_RANDOM_TEST_ITERATIONS")); err == nil {
+		return n
+	}
+	return def
+}
+
+// randomCommentRunes are the awkward characters that comments can contain.
+var randomCommentRunes = []rune(`abcXYZ019 -_"'%\$*;:#!` + "\té☃")
+
+func randomComment(r *rand.Rand) string {
+	runes := make([]rune, r.Intn(20))
+	for i := range runes {
+		runes[i] = randomCommentRunes[r.Intn(len(randomCommentRunes))]
+	}
+	return string(runes)
+}
+
+func randomMatch(r *rand.Rand) MatchCriteria {
+	m := Match()
+	for n := r.Intn(5); n > 0; n-- {
+		switch r.Intn(10) {
+		case 0:
+			m = m.Protocol([]string{"tcp", "udp", "sctp"}[r.Intn(3)])
+		case 1:
+			m = m.SourceNet(fmt.Sprintf("10.%d.%d.0/24", r.Intn(256), r.Intn(256)))
+		case 2:
+			m = m.NotDestNet(fmt.Sprintf("192.168.%d.%d/32", r.Intn(256), r.Intn(256)))
+		case 3:
+			m = m.MarkSingleBitSet(1 << uint(r.Intn(32)))
+		case 4:
+			m = m.MarkMatchesWithMask(r.Uint32()&0xff00, 0xff00)
+		case 5:
+			m = m.DestPorts(uint16(r.Intn(65536)), uint16(r.Intn(65536)))
+		case 6:
+			m = m.SourceIPSet(fmt.Sprintf("cali40s:%x", r.Int63()))
+		case 7:
+			m = m.InInterface(fmt.Sprintf("cali%x", r.Intn(1<<24)))
+		case 8:
+			m = m.ConntrackState("RELATED,ESTABLISHED")
+		case 9:
+			m = m.ICMPTypeAndCode(uint8(r.Intn(256)), uint8(r.Intn(256)))
+		}
+	}
+	return m
+}
+
+func randomAction(r *rand.Rand, chainNames []string) Action {
+	switch r.Intn(6) {
+	case 0:
+		return AcceptAction{}
+	case 1:
+		return DropAction{}
+	case 2:
+		return ReturnAction{}
+	case 3:
+		return SetMarkAction{Mark: 1 << uint(r.Intn(32))}
+	case 4:
+		return LogAction{Prefix: "calico-packet"}
+	default:
+		return JumpAction{Target: chainNames[r.Intn(len(chainNames))]}
+	}
+}
+
+// randomChains returns up to numChains chains that only jump to chains later in the list, so
+// that there are no loops.
+func randomChains(r *rand.Rand, numChains, maxRules int) []*Chain {
+	chainNames := make([]string, numChains)
+	for i := range chainNames {
+		chainNames[i] = fmt.Sprintf("cali-rt-%d-%x", i, r.Intn(1<<16))
+	}
+	chains := make([]*Chain, numChains)
+	for i := range chains {
+		chain := &Chain{Name: chainNames[i]}
+		for n := r.Intn(maxRules + 1); n > 0; n-- {
+			var action Action = DropAction{}
+			if i < numChains-1 {
+				action = randomAction(r, chainNames[i+1:])
+			}
+			rule := Rule{Match: randomMatch(r), Action: action}
+			for c := r.Intn(3); c > 0; c-- {
+				rule.Comment = append(rule.Comment, randomComment(r))
+			}
+			chain.Rules = append(chain.Rules, rule)
+		}
+		chains[i] = chain
+	}
+	return chains
+}
+
+func newRoundTripTable(dataplane *mockDataplane) *Table {
+	featureDetector := NewFeatureDetector(nil)
+	featureDetector.NewCmd = dataplane.newCmd
+	featureDetector.GetKernelVersionReader = dataplane.getKernelVersionReader
+	return NewTable(
+		"filter",
+		4,
+		rules.RuleHashPrefix,
+		&mockMutex{},
+		featureDetector,
+		TableOptions{
+			HistoricChainPrefixes: rules.AllHistoricChainNamePrefixes,
+			NewCmdOverride:        dataplane.newCmd,
+			SleepOverride:         dataplane.sleep,
+			NowOverride:           dataplane.now,
+			BackendMode:           "legacy",
+			LookPathOverride:      lookPathNoLegacy,
+			OpRecorder:            logutils.NewSummarizer("test loop"),
+		},
+	)
+}
+
+var _ = Describe("Table round trip with random chains", func() {
+	var logLevel log.Level
+
+	BeforeEach(func() {
+		logLevel = log.GetLevel()
+		log.SetLevel(log.WarnLevel)
+	})
+
+	AfterEach(func() {
+		log.SetLevel(logLevel)
+	})
+
+	It("should read back what it wrote", func() {
+		seed := randomTestSeed()
+		r := rand.New(rand.NewSource(seed))
+		for i := 0; i < randomTestIterations(20); i++ {
+			dataplane := newMockDataplane("filter", map[string][]string{
+				"FORWARD": {},
+				"INPUT":   {},
+				"OUTPUT":  {},
+			}, "legacy")
+			table := newRoundTripTable(dataplane)
+			chains := randomChains(r, 1+r.Intn(10), 10)
+			table.UpdateChains(chains)
+			table.InsertOrAppendRules("FORWARD", []Rule{{Action: JumpAction{Target: chains[0].Name}}})
+			table.Apply()
+			Expect(dataplane.Chains).To(HaveKey(chains[0].Name), "seed %d", seed)
+
+			// A fresh table with the same chains should find the dataplane in sync.
+			dataplane.ResetCmds()
+			table = newRoundTripTable(dataplane)
+			table.UpdateChains(chains)
+			table.InsertOrAppendRules("FORWARD", []Rule{{Action: JumpAction{Target: chains[0].Name}}})
+			table.Apply()
+			Expect(dataplane.CmdNames).NotTo(ContainElement("iptables-restore"),
+				"Rewrote the dataplane after reading it back, seed %d", seed)
+		}
+	})
+})
+
+func BenchmarkTableApply100Chains10Rules(b *testing.B) {
+	benchmarkTableApply(b, 100, 10)
+}
+
+func BenchmarkTableApply1000Chains10Rules(b *testing.B) {
+	benchmarkTableApply(b, 1000, 10)
+}
+
+// benchmarkTableApply measures a full resync of a table with the given number of chains:
+// rendering the chains and writing them to the (simulated) iptables-restore.
+func benchmarkTableApply(b *testing.B, numChains, numRules int) {
+	RegisterTestingT(b)
+	logLevel := log.GetLevel()
+	log.SetLevel(log.WarnLevel)
+	defer log.SetLevel(logLevel)
+
+	r := rand.New(rand.NewSource(1))
+	chains := randomChains(r, numChains, numRules)
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		dataplane := newMockDataplane("filter", map[string][]string{
+			"FORWARD": {},
+			"INPUT":   {},
+			"OUTPUT":  {},
+		}, "legacy")
+		table := newRoundTripTable(dataplane)
+		table.UpdateChains(chains)
+		table.InsertOrAppendRules("FORWARD", []Rule{{Action: JumpAction{Target: chains[0].Name}}})
+		b.StartTimer()
+
+		table.Apply()
+	}
+}
@@ -17,8 +17,10 @@ package iptables_test
 import (
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/projectcalico/felix/alerts"
 	. "github.com/projectcalico/felix/iptables"
 	"github.com/projectcalico/felix/logutils"
 
@@ -76,17 +78,8 @@ func describeEmptyDataplaneTests(dataplaneMode string) {
 	var table *Table
 	var iptLock *mockMutex
 	var featureDetector *FeatureDetector
-	BeforeEach(func() {
-		dataplane = newMockDataplane("filter", map[string][]string{
-			"FORWARD": {},
-			"INPUT":   {},
-			"OUTPUT":  {},
-		}, dataplaneMode)
-		iptLock = &mockMutex{}
-		featureDetector = NewFeatureDetector(nil)
-		featureDetector.NewCmd = dataplane.newCmd
-		featureDetector.GetKernelVersionReader = dataplane.getKernelVersionReader
-		table = NewTable(
+	newTable := func() *Table {
+		return NewTable(
 			"filter",
 			4,
 			rules.RuleHashPrefix,
@@ -102,6 +95,18 @@ func describeEmptyDataplaneTests(dataplaneMode string) {
 				OpRecorder:            logutils.NewSummarizer("test loop"),
 			},
 		)
+	}
+	BeforeEach(func() {
+		dataplane = newMockDataplane("filter", map[string][]string{
+			"FORWARD": {},
+			"INPUT":   {},
+			"OUTPUT":  {},
+		}, dataplaneMode)
+		iptLock = &mockMutex{}
+		featureDetector = NewFeatureDetector(nil)
+		featureDetector.NewCmd = dataplane.newCmd
+		featureDetector.GetKernelVersionReader = dataplane.getKernelVersionReader
+		table = newTable()
 	})
 
 	Describe("with iptables returning an nft error", func() {
@@ -381,6 +386,36 @@ func describeEmptyDataplaneTests(dataplaneMode string) {
 					},
 				}))
 			})
+			It("should dump its desired state", func() {
+				var buf strings.Builder
+				Expect(table.DumpState(&buf)).To(Succeed())
+				Expect(buf.String()).To(Equal(strings.Join([]string{
+					"# IPv4",
+					"*filter",
+					":cali-FORWARD - -",
+					":cali-foobar - -",
+					"-A cali-FORWARD --jump cali-foobar",
+					"-A cali-foobar --jump ACCEPT",
+					"-A cali-foobar --jump DROP",
+					"-I FORWARD --jump cali-FORWARD",
+					"COMMIT",
+					"",
+				}, "\n")))
+			})
+
+			Describe("after removing all chains and rules", func() {
+				BeforeEach(func() {
+					table.RemoveAllChainsAndRules()
+					table.Apply()
+				})
+				It("should remove everything", func() {
+					Expect(dataplane.Chains).To(Equal(map[string][]string{
+						"FORWARD": {},
+						"INPUT":   {},
+						"OUTPUT":  {},
+					}))
+				})
+			})
 
 			Describe("after adding a reference from an insert", func() {
 				BeforeEach(func() {
@@ -660,6 +695,71 @@ func describeEmptyDataplaneTests(dataplaneMode string) {
 				},
 			}))
 		})
+		Describe("after restarting with a checkpoint", func() {
+			var cp TableCheckpoint
+			var expectedChains map[string][]string
+
+			BeforeEach(func() {
+				cp = table.Checkpoint()
+				expectedChains = map[string][]string{}
+				for name, chainRules := range dataplane.Chains {
+					expectedChains[name] = append([]string{}, chainRules...)
+				}
+
+				table = newTable()
+				table.LoadCheckpoint(cp)
+				table.InsertOrAppendRules("FORWARD", []Rule{
+					{Action: AcceptAction{}},
+					{Action: DropAction{}},
+					{Action: JumpAction{Target: "cali-foobar"}},
+				})
+				table.UpdateChains([]*Chain{
+					{Name: "cali-foobar", Rules: []Rule{
+						{Action: AcceptAction{}},
+						{Action: DropAction{}},
+					}},
+				})
+				dataplane.ResetCmds()
+			})
+
+			It("should checkpoint our chains and the chains that we insert into", func() {
+				Expect(cp.Hashes).To(Equal(map[string][]string{
+					"FORWARD":     {"3gUkOfVeYRgMeHF4", "8MgbRleZ5Rc5cBEf", "Ox1x6pjEMCqtMxFb"},
+					"cali-foobar": {"42h7Q64_2XDzpwKe", "0sUFHicPNNqNyNx8"},
+				}))
+				Expect(cp.Rules).To(HaveKey("FORWARD"))
+				Expect(cp.Rules["FORWARD"]).To(HaveLen(3))
+			})
+
+			It("should not load the dataplane state or write anything if nothing changed", func() {
+				table.Apply()
+				Expect(dataplane.CmdNames).NotTo(ContainElement(ContainSubstring("save")))
+				Expect(dataplane.CmdNames).NotTo(ContainElement(ContainSubstring("restore")))
+				Expect(dataplane.Chains).To(Equal(expectedChains))
+			})
+
+			It("should only write the chains that changed since the checkpoint", func() {
+				table.UpdateChains([]*Chain{
+					{Name: "cali-foobar", Rules: []Rule{
+						{Action: AcceptAction{}},
+					}},
+				})
+				table.Apply()
+				Expect(dataplane.CmdNames).NotTo(ContainElement(ContainSubstring("save")))
+				Expect(dataplane.Chains["cali-foobar"]).To(Equal(expectedChains["cali-foobar"][:1]))
+			})
+
+			It("should count and correct a chain that changed while stopped on the post-write check", func() {
+				dataplane.Chains["cali-foobar"] = dataplane.Chains["cali-foobar"][:1]
+				table.Apply()
+				Expect(table.NumInconsistencies()).To(BeZero())
+
+				dataplane.AdvanceTimeBy(time.Second)
+				table.Apply()
+				Expect(table.NumInconsistencies()).To(Equal(1))
+				Expect(dataplane.Chains).To(Equal(expectedChains))
+			})
+		})
 		Describe("then truncating the chain, with the iptables changed before iptables-restore", func() {
 			BeforeEach(func() {
 				dataplane.OnPreRestore = func() {
@@ -930,6 +1030,80 @@ func describeEmptyDataplaneTests(dataplaneMode string) {
 	})
 }
 
+var _ = Describe("Table with external chains", func() {
+	var dataplane *mockDataplane
+	var table *Table
+	BeforeEach(func() {
+		dataplane = newMockDataplane("filter", map[string][]string{
+			"FORWARD": {},
+			"INPUT":   {},
+			"OUTPUT":  {},
+			"my-hook": {"--jump LOG"},
+		}, "legacy")
+		featureDetector := NewFeatureDetector(nil)
+		featureDetector.NewCmd = dataplane.newCmd
+		featureDetector.GetKernelVersionReader = dataplane.getKernelVersionReader
+		table = NewTable(
+			"filter",
+			4,
+			rules.RuleHashPrefix,
+			&mockMutex{},
+			featureDetector,
+			TableOptions{
+				HistoricChainPrefixes: rules.AllHistoricChainNamePrefixes,
+				ExternalChains:        []string{"my-hook", "other-hook"},
+				NewCmdOverride:        dataplane.newCmd,
+				SleepOverride:         dataplane.sleep,
+				NowOverride:           dataplane.now,
+				BackendMode:           "legacy",
+				LookPathOverride:      lookPathNoLegacy,
+				OpRecorder:            logutils.NewSummarizer("test loop"),
+			},
+		)
+		table.InsertOrAppendRules("FORWARD", []Rule{
+			{Action: JumpAction{Target: "cali-FORWARD"}},
+		})
+		table.UpdateChain(&Chain{
+			Name: "cali-FORWARD",
+			Rules: []Rule{
+				{Action: JumpAction{Target: "my-hook"}},
+				{Action: JumpAction{Target: "other-hook"}},
+			}})
+		table.Apply()
+	})
+
+	It("should create the missing chain and leave the existing one alone", func() {
+		Expect(dataplane.Chains["cali-FORWARD"]).To(HaveLen(2))
+		Expect(dataplane.Chains["my-hook"]).To(Equal([]string{"--jump LOG"}))
+		Expect(dataplane.Chains["other-hook"]).To(Equal([]string{}))
+		Expect(dataplane.FlushedChains.Contains("my-hook")).To(BeFalse())
+		Expect(dataplane.FlushedChains.Contains("other-hook")).To(BeFalse())
+	})
+
+	Describe("after removing the references", func() {
+		BeforeEach(func() {
+			dataplane.Chains["other-hook"] = []string{"--jump DROP"}
+			table.UpdateChain(&Chain{Name: "cali-FORWARD"})
+			table.Apply()
+		})
+
+		It("should not delete or flush the chains", func() {
+			Expect(dataplane.Chains["cali-FORWARD"]).To(BeEmpty())
+			Expect(dataplane.Chains["my-hook"]).To(Equal([]string{"--jump LOG"}))
+			Expect(dataplane.Chains["other-hook"]).To(Equal([]string{"--jump DROP"}))
+			Expect(dataplane.DeletedChains.Len()).To(BeZero())
+			Expect(dataplane.FlushedChains.Contains("my-hook")).To(BeFalse())
+		})
+
+		It("should leave the chains alone after a resync", func() {
+			table.InvalidateDataplaneCache("test")
+			table.Apply()
+			Expect(dataplane.Chains["my-hook"]).To(Equal([]string{"--jump LOG"}))
+			Expect(dataplane.Chains["other-hook"]).To(Equal([]string{"--jump DROP"}))
+		})
+	})
+})
+
 var _ = Describe("Tests of post-update recheck behaviour with refresh timer (nft)", func() {
 	describePostUpdateCheckTests(true, "nft")
 })
@@ -1676,6 +1850,115 @@ func lookPathNoLegacy(p string) (string, error) {
 	return p, nil
 }
 
+type mockAlertSink struct {
+	lock   sync.Mutex
+	alerts []alerts.Alert
+}
+
+func (s *mockAlertSink) Name() string {
+	return "mock"
+}
+
+func (s *mockAlertSink) Send(a alerts.Alert) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.alerts = append(s.alerts, a)
+	return nil
+}
+
+var _ = Describe("Table with a failing iptables-restore", func() {
+	var dataplane *mockDataplane
+	var table *Table
+	var sink *mockAlertSink
+	var handleFailures bool
+	var reportedFailures []*RestoreError
+
+	BeforeEach(func() {
+		handleFailures = false
+		reportedFailures = nil
+		dataplane = newMockDataplane("filter", map[string][]string{
+			"FORWARD": {},
+			"INPUT":   {},
+			"OUTPUT":  {},
+		}, "legacy")
+		featureDetector := NewFeatureDetector(nil)
+		featureDetector.NewCmd = dataplane.newCmd
+		featureDetector.GetKernelVersionReader = dataplane.getKernelVersionReader
+		table = NewTable(
+			"filter",
+			4,
+			rules.RuleHashPrefix,
+			&mockMutex{},
+			featureDetector,
+			TableOptions{
+				HistoricChainPrefixes: rules.AllHistoricChainNamePrefixes,
+				NewCmdOverride:        dataplane.newCmd,
+				SleepOverride:         dataplane.sleep,
+				NowOverride:           dataplane.now,
+				BackendMode:           "legacy",
+				LookPathOverride:      lookPathAll,
+				OpRecorder:            logutils.NewSummarizer("test loop"),
+				DescribeChain: func(chainName string) string {
+					if chainName == "cali-pi-foo" {
+						return "policy default/foo"
+					}
+					return ""
+				},
+				OnRestoreFailure: func(table string, rErr *RestoreError) bool {
+					Expect(table).To(Equal("filter"))
+					reportedFailures = append(reportedFailures, rErr)
+					return handleFailures
+				},
+			},
+		)
+		table.InsertOrAppendRules("FORWARD", []Rule{{Action: JumpAction{Target: "cali-pi-foo"}}})
+		table.UpdateChain(&Chain{Name: "cali-pi-foo", Rules: []Rule{
+			{Action: AcceptAction{}},
+			{Match: Match().Protocol("sctp"), Action: DropAction{}},
+		}})
+
+		sink = &mockAlertSink{}
+		alerts.Configure("host", time.Hour, sink)
+		dataplane.FailAllRestores = true
+		dataplane.FailRestoreOnLine = func(line string) bool {
+			return strings.HasPrefix(line, "-A cali-pi-foo") && strings.Contains(line, "sctp")
+		}
+	})
+
+	AfterEach(func() {
+		alerts.Configure("", 0)
+	})
+
+	It("should trace the failed line back to its chain, rule and origin", func() {
+		Expect(func() {
+			table.Apply()
+		}).To(Panic())
+		alerts.Flush(time.Second)
+
+		sink.lock.Lock()
+		defer sink.lock.Unlock()
+		Expect(sink.alerts).To(HaveLen(1))
+		Expect(sink.alerts[0].Message).To(MatchRegexp(
+			`iptables-restore failed at line \d+ \(chain cali-pi-foo, rule 1, from policy default/foo\)`))
+	})
+
+	It("should retry later instead of panicking if the failure is taken on", func() {
+		handleFailures = true
+		Expect(table.Apply()).To(Equal(HandledRestoreFailureRetryInterval))
+		Expect(reportedFailures).To(HaveLen(1))
+		Expect(reportedFailures[0].Chain).To(Equal("cali-pi-foo"))
+
+		dataplane.FailAllRestores = false
+		table.Apply()
+		Expect(reportedFailures).To(HaveLen(2))
+		Expect(reportedFailures[1]).To(BeNil())
+		Expect(dataplane.Chains["cali-pi-foo"]).To(HaveLen(2))
+
+		table.Apply()
+		Expect(reportedFailures).To(HaveLen(2))
+	})
+})
+
 func lookPathAll(p string) (string, error) {
 	return p, nil
 }
@@ -64,6 +64,7 @@ type mockDataplane struct {
 	CmdNames                       []string
 	FailNextRestore                bool
 	FailAllRestores                bool
+	FailRestoreOnLine              func(line string) bool
 	OnPreRestore                   func()
 	FailNextSaveRead               bool
 	FailNextSaveStdoutPipe         bool
@@ -77,6 +78,8 @@ type mockDataplane struct {
 	Time                           time.Time
 	FailNextVersion                bool
 	Version                        string
+	FailNextSetMatchHelp           bool
+	SetMatchHelp                   string
 	KernelVersion                  string
 	NftablesMode                   bool
 }
@@ -122,6 +125,11 @@ func (d *mockDataplane) newCmd(name string, arg ...string) CmdIface {
 		cmd = &saveCmd{
 			Dataplane: d,
 		}
+	case "iptables-legacy", "iptables-nft":
+		Expect(arg).To(Equal([]string{"-m", "set", "--help"}))
+		cmd = &setMatchHelpCmd{versionCmd{
+			Dataplane: d,
+		}}
 	case "iptables":
 		Expect(arg).To(Equal([]string{"--version"}))
 		cmd = &versionCmd{
@@ -245,6 +253,14 @@ func (d *restoreCmd) Run() error {
 	}
 	if d.Dataplane.FailAllRestores {
 		log.Warn("Simulating an iptables-restore failure")
+		if d.Dataplane.FailRestoreOnLine != nil {
+			for i, line := range strings.Split(input, "\n") {
+				if d.Dataplane.FailRestoreOnLine(line) {
+					_, _ = fmt.Fprintf(d.Stderr, "iptables-restore: line %d failed\n", i+1)
+					break
+				}
+			}
+		}
 		return errors.New("Simulated failure")
 	}
 
@@ -381,6 +397,11 @@ func (d *restoreCmd) Run() error {
 				d.Dataplane.ChainMods.Add(chainMod{name: chainName, ruleNum: i})
 
 			}
+		case "-N", "--new-chain":
+			chainName = parts[1]
+			Expect(parts).To(HaveLen(2), "--new-chain only has one argument")
+			Expect(chains).NotTo(HaveKey(chainName), "Chain already exists")
+			chains[chainName] = []string{}
 		case "-X", "--delete-chain":
 			chainName = parts[1]
 			Expect(parts).To(HaveLen(2), "--delete-chain only has one argument")
@@ -573,6 +594,19 @@ func (d *versionCmd) Run() error {
 	return errors.New("Not implemented")
 }
 
+type setMatchHelpCmd struct {
+	versionCmd
+}
+
+func (d *setMatchHelpCmd) Output() ([]byte, error) {
+	if d.Dataplane.FailNextSetMatchHelp {
+		d.Dataplane.FailNextSetMatchHelp = false
+		return nil, errors.New("Simulated failure")
+	}
+
+	return []byte(d.Dataplane.SetMatchHelp), nil
+}
+
 type closableBuffer struct {
 	b                 *bytes.Buffer
 	Closed            bool
@@ -0,0 +1,42 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package labelindex
+
+import (
+	"github.com/projectcalico/libcalico-go/lib/backend/model"
+)
+
+// LabelEndpointID is the label that we implicitly add to each workload endpoint, set to its
+// endpoint ID.  A workload with several interfaces (for example, a pod with Multus secondary
+// interfaces) has a workload endpoint per interface, which share the workload's labels; the
+// endpoint ID, which in Kubernetes is the name of the interface inside the pod, tells them apart.
+// Selectors, including the dataplane's, can therefore pick out one interface, for example
+// "projectcalico.org/endpoint == 'net1'".
+const LabelEndpointID = "projectcalico.org/endpoint"
+
+// WorkloadEndpointLabels returns the labels that we index for the given workload endpoint: its own
+// labels plus LabelEndpointID.  LabelEndpointID always comes from the key, replacing any label of
+// that name on the endpoint, so that a workload can't claim to be another of its interfaces.
+func WorkloadEndpointLabels(key model.WorkloadEndpointKey, endpoint *model.WorkloadEndpoint) map[string]string {
+	if key.EndpointID == "" {
+		return endpoint.Labels
+	}
+	labels := make(map[string]string, len(endpoint.Labels)+1)
+	for k, v := range endpoint.Labels {
+		labels[k] = v
+	}
+	labels[LabelEndpointID] = key.EndpointID
+	return labels
+}
@@ -20,6 +20,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/projectcalico/libcalico-go/lib/backend/api"
 	"github.com/projectcalico/libcalico-go/lib/backend/model"
 	"github.com/projectcalico/libcalico-go/lib/selector"
 )
@@ -90,6 +91,44 @@ var _ = Describe("Index", func() {
 		})
 	})
 
+	Context("with a workload endpoint per interface", func() {
+		eth0Key := model.WorkloadEndpointKey{
+			Hostname: "host", OrchestratorID: "k8s", WorkloadID: "ns/pod", EndpointID: "eth0",
+		}
+		net1Key := model.WorkloadEndpointKey{
+			Hostname: "host", OrchestratorID: "k8s", WorkloadID: "ns/pod", EndpointID: "net1",
+		}
+
+		BeforeEach(func() {
+			for _, key := range []model.WorkloadEndpointKey{eth0Key, net1Key} {
+				idx.OnUpdate(api.Update{KVPair: model.KVPair{
+					Key:   key,
+					Value: &model.WorkloadEndpoint{Labels: map[string]string{"a": "b"}},
+				}})
+			}
+		})
+
+		It("should let selectors pick out one interface", func() {
+			sel, err := selector.Parse(`a == "b" && projectcalico.org/endpoint == "net1"`)
+			Expect(err).NotTo(HaveOccurred())
+			idx.UpdateSelector("e1", sel)
+			Expect(updates).To(Equal([]update{{"start", net1Key, "e1"}}))
+		})
+
+		It("should override an explicit label", func() {
+			idx.OnUpdate(api.Update{KVPair: model.KVPair{
+				Key: eth0Key,
+				Value: &model.WorkloadEndpoint{Labels: map[string]string{
+					"a": "b", "projectcalico.org/endpoint": "net1",
+				}},
+			}})
+			sel, err := selector.Parse(`projectcalico.org/endpoint == "net1"`)
+			Expect(err).NotTo(HaveOccurred())
+			idx.UpdateSelector("e1", sel)
+			Expect(updates).To(Equal([]update{{"start", net1Key, "e1"}}))
+		})
+	})
+
 	Context("with one set of labels added", func() {
 		BeforeEach(func() {
 			idx.UpdateLabels("l1", map[string]string{"a": "b", "c": "d"}, nil)
@@ -143,7 +143,7 @@ func (l *InheritIndex) OnUpdate(update api.Update) (_ bool) {
 			log.Debugf("Updating InheritIndex with endpoint %v", key)
 			endpoint := update.Value.(*model.WorkloadEndpoint)
 			profileIDs := endpoint.ProfileIDs
-			l.UpdateLabels(key, endpoint.Labels, profileIDs)
+			l.UpdateLabels(key, WorkloadEndpointLabels(key, endpoint), profileIDs)
 		} else {
 			log.Debugf("Deleting endpoint %v from InheritIndex", key)
 			l.DeleteLabels(key)
@@ -29,15 +29,26 @@ import (
 	"github.com/projectcalico/libcalico-go/lib/backend/api"
 	"github.com/projectcalico/libcalico-go/lib/backend/model"
 	"github.com/projectcalico/libcalico-go/lib/selector"
+	"github.com/projectcalico/libcalico-go/lib/selector/parser"
 	"github.com/projectcalico/libcalico-go/lib/set"
 )
 
+// ExplicitlySelectedID is implemented by the IDs of endpoints that should only match selectors
+// that refer to a particular label.  That stops selectors such as all(), which were written before
+// those endpoints existed, from matching them.
+type ExplicitlySelectedID interface {
+	RequiredSelectorLabel() string
+}
+
 // endpointData holds the data that we need to know about a particular endpoint.
 type endpointData struct {
 	labels  map[string]string
 	nets    []ip.CIDR
 	ports   []model.EndpointPort
 	parents []*npParentData
+	// requiredSelectorLabel, if non-empty, is a label that a selector must refer to in order to
+	// match this endpoint.
+	requiredSelectorLabel string
 
 	cachedMatchingIPSetIDs set.Set /* or, as an optimization, nil if there are none */
 }
@@ -59,6 +70,33 @@ func (d *endpointData) RemoveMatchingIPSetID(id string) {
 	}
 }
 
+// MatchesSelector returns true if the selector matches the endpoint's labels and, if the endpoint
+// must be selected explicitly, the selector depends on the required label: it wouldn't match the
+// endpoint without it.  That rules out selectors such as all(), and selectors that only mention
+// the label's name inside a value.
+func (d *endpointData) MatchesSelector(sel selector.Selector) bool {
+	if !sel.EvaluateLabels(d) {
+		return false
+	}
+	if d.requiredSelectorLabel == "" {
+		return true
+	}
+	return !sel.EvaluateLabels(labelsWithout{labels: d, label: d.requiredSelectorLabel})
+}
+
+// labelsWithout hides one label of the wrapped labels.
+type labelsWithout struct {
+	labels parser.Labels
+	label  string
+}
+
+func (l labelsWithout) Get(labelName string) (value string, present bool) {
+	if labelName == l.label {
+		return "", false
+	}
+	return l.labels.Get(labelName)
+}
+
 func (d *endpointData) HasParent(parent *npParentData) bool {
 	for _, p := range d.parents {
 		if p == parent {
@@ -216,7 +254,7 @@ func (idx *SelectorAndNamedPortIndex) OnUpdate(update api.Update) (_ bool) {
 			profileIDs := endpoint.ProfileIDs
 			idx.UpdateEndpointOrSet(
 				key,
-				endpoint.Labels,
+				WorkloadEndpointLabels(key, endpoint),
 				extractCIDRsFromWorkloadEndpoint(endpoint),
 				endpoint.Ports,
 				profileIDs)
@@ -382,7 +420,7 @@ func (idx *SelectorAndNamedPortIndex) UpdateIPSet(ipSetID string, sel selector.S
 
 	// Then scan all endpoints.
 	for epID, epData := range idx.endpointDataByID {
-		if !sel.EvaluateLabels(epData) {
+		if !epData.MatchesSelector(sel) {
 			// Endpoint doesn't match.
 			continue
 		}
@@ -455,6 +493,9 @@ func (idx *SelectorAndNamedPortIndex) UpdateEndpointOrSet(
 
 	// Calculate the new endpoint data.
 	newEndpointData := &endpointData{}
+	if explicitID, ok := id.(ExplicitlySelectedID); ok {
+		newEndpointData.requiredSelectorLabel = explicitID.RequiredSelectorLabel()
+	}
 	if len(labels) > 0 {
 		newEndpointData.labels = labels
 	}
@@ -519,7 +560,7 @@ func (idx *SelectorAndNamedPortIndex) scanEndpointAgainstAllIPSets(
 		// creates a new endpointData struct.)
 		epData.RemoveMatchingIPSetID(ipSetID)
 
-		if ipSetData.selector.EvaluateLabels(epData) {
+		if epData.MatchesSelector(ipSetData.selector) {
 			newIPSetContribution := idx.CalculateEndpointContribution(epData, ipSetData)
 			if len(newIPSetContribution) > 0 {
 				// Record the match in the index.  This allows us to quickly recalculate the
@@ -0,0 +1,133 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logutils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const commandCaptureSuffix = ".capture"
+
+var (
+	// maxCapturedStreamBytes limits the size of each stream in a capture; iptables-restore input
+	// can be many megabytes.  Variable so that the tests can lower it.
+	maxCapturedStreamBytes = 1024 * 1024
+
+	commandCaptureLock     sync.Mutex
+	commandCaptureDir      string
+	commandCaptureMaxFiles int
+	commandCaptureSeqNo    uint64
+)
+
+// ConfigureCommandCapture enables the capture of failed external commands to files in dir, of
+// which only the most recent maxFiles are kept.  An empty dir disables capture.
+func ConfigureCommandCapture(dir string, maxFiles int) {
+	commandCaptureLock.Lock()
+	defer commandCaptureLock.Unlock()
+
+	commandCaptureDir = ""
+	if dir == "" {
+		return
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		log.WithError(err).WithField("dir", dir).Error(
+			"Failed to create command capture directory, failed commands won't be captured.")
+		return
+	}
+	log.WithFields(log.Fields{"dir": dir, "maxFiles": maxFiles}).Info(
+		"Capturing the input and output of failed dataplane commands.")
+	commandCaptureDir = dir
+	commandCaptureMaxFiles = maxFiles
+}
+
+// CaptureFailedCommand writes the command line, input, output and error of an external command
+// that failed to a new file in the capture directory, then removes the oldest captures.  It is a
+// no-op if capture is disabled.
+func CaptureFailedCommand(name string, args []string, stdin, stdout, stderr string, cmdErr error) {
+	commandCaptureLock.Lock()
+	defer commandCaptureLock.Unlock()
+
+	if commandCaptureDir == "" {
+		return
+	}
+
+	commandCaptureSeqNo++
+	now := time.Now()
+	fileName := filepath.Join(commandCaptureDir, fmt.Sprintf("%s-%06d-%s%s",
+		now.UTC().Format("20060102T150405.000000"), commandCaptureSeqNo%1000000,
+		filepath.Base(name), commandCaptureSuffix))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "time: %s\ncommand: %s\nerror: %v\n", now.Format(time.RFC3339Nano),
+		strings.Join(append([]string{name}, args...), " "), cmdErr)
+	writeCapturedStream(&b, "stdin", stdin)
+	writeCapturedStream(&b, "stdout", stdout)
+	writeCapturedStream(&b, "stderr", stderr)
+
+	logCxt := log.WithField("file", fileName)
+	if err := ioutil.WriteFile(fileName, []byte(b.String()), 0600); err != nil {
+		logCxt.WithError(err).Warn("Failed to write command capture.")
+		return
+	}
+	logCxt.WithField("command", name).Info("Captured failed command.")
+
+	pruneCommandCaptures(commandCaptureDir, commandCaptureMaxFiles)
+}
+
+func writeCapturedStream(b *strings.Builder, streamName, data string) {
+	truncated := ""
+	if len(data) > maxCapturedStreamBytes {
+		truncated = fmt.Sprintf(" (truncated from %d bytes)", len(data))
+		data = data[:maxCapturedStreamBytes]
+	}
+	fmt.Fprintf(b, "\n----- %s%s -----\n%s", streamName, truncated, data)
+	if data != "" && !strings.HasSuffix(data, "\n") {
+		b.WriteString("\n")
+	}
+}
+
+// pruneCommandCaptures removes the oldest captures in dir so that at most maxFiles remain.  The
+// file names start with a timestamp so they sort oldest first.
+func pruneCommandCaptures(dir string, maxFiles int) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		log.WithError(err).WithField("dir", dir).Warn("Failed to list command captures.")
+		return
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), commandCaptureSuffix) {
+			names = append(names, e.Name())
+		}
+	}
+	if len(names) <= maxFiles {
+		return
+	}
+	sort.Strings(names)
+	for _, name := range names[:len(names)-maxFiles] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+			log.WithError(err).WithField("file", name).Warn("Failed to remove old command capture.")
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logutils
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Failed command capture", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "cmd-capture-test")
+		Expect(err).NotTo(HaveOccurred())
+		maxCapturedStreamBytes = 10
+	})
+
+	AfterEach(func() {
+		ConfigureCommandCapture("", 0)
+		maxCapturedStreamBytes = 1024 * 1024
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	captures := func() []string {
+		entries, err := ioutil.ReadDir(dir)
+		Expect(err).NotTo(HaveOccurred())
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	readCapture := func(name string) string {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		Expect(err).NotTo(HaveOccurred())
+		return string(data)
+	}
+
+	It("should do nothing if capture is disabled", func() {
+		CaptureFailedCommand("iptables-restore", nil, "input", "", "", errors.New("failed"))
+		Expect(captures()).To(BeEmpty())
+	})
+
+	It("should capture the command, its error and its streams", func() {
+		ConfigureCommandCapture(dir, 5)
+		CaptureFailedCommand("/sbin/iptables-restore", []string{"--noflush"}, "*filter\n", "",
+			"line 1 failed", errors.New("exit status 1"))
+
+		names := captures()
+		Expect(names).To(HaveLen(1))
+		Expect(names[0]).To(HaveSuffix("-iptables-restore.capture"))
+		capture := readCapture(names[0])
+		Expect(capture).To(ContainSubstring("command: /sbin/iptables-restore --noflush\n"))
+		Expect(capture).To(ContainSubstring("error: exit status 1\n"))
+		Expect(capture).To(ContainSubstring("\n----- stdin -----\n*filter\n"))
+		Expect(capture).To(ContainSubstring("\n----- stdout -----\n"))
+		Expect(capture).To(HaveSuffix("\n----- stderr -----\nline 1 failed\n"))
+	})
+
+	It("should truncate long streams", func() {
+		ConfigureCommandCapture(dir, 5)
+		CaptureFailedCommand("ipset", nil, "0123456789abcdef", "", "", errors.New("failed"))
+
+		names := captures()
+		Expect(names).To(HaveLen(1))
+		Expect(readCapture(names[0])).To(ContainSubstring(
+			"\n----- stdin (truncated from 16 bytes) -----\n0123456789\n"))
+	})
+
+	It("should keep only the most recent captures", func() {
+		ConfigureCommandCapture(dir, 2)
+		for _, input := range []string{"first", "second", "third"} {
+			CaptureFailedCommand("ipset", nil, input, "", "", errors.New("failed"))
+		}
+
+		names := captures()
+		Expect(names).To(HaveLen(2))
+		Expect(readCapture(names[0])).To(ContainSubstring("\nsecond\n"))
+		Expect(readCapture(names[1])).To(ContainSubstring("\nthird\n"))
+	})
+
+	It("should leave other files in the directory alone when pruning", func() {
+		Expect(ioutil.WriteFile(filepath.Join(dir, "other"), []byte("x"), 0600)).To(Succeed())
+		ConfigureCommandCapture(dir, 1)
+		for _, input := range []string{"first", "second"} {
+			CaptureFailedCommand("ipset", nil, input, "", "", errors.New("failed"))
+		}
+
+		names := captures()
+		Expect(names).To(HaveLen(2))
+		Expect(names).To(ContainElement("other"))
+	})
+})
@@ -17,6 +17,7 @@
 package logutils
 
 import (
+	"net/http"
 	"os"
 	"os/signal"
 	"runtime/pprof"
@@ -27,6 +28,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/debugserver"
 )
 
 func DumpHeapMemoryProfile(fileName string) {
@@ -109,3 +111,44 @@ func RegisterProfilingSignalHandlers(configParams *config.Config) {
 		}()
 	}
 }
+
+// RegisterProfileDumpHandler makes the profiles that SIGUSR1 and SIGUSR2 write available from the
+// debug server too, as POST /debug/profile-dump/heap and /debug/profile-dump/cpu, so that all of
+// Felix's debug triggers are reachable from one place.  The profiles are written to the same files
+// as the signal handlers write.
+func RegisterProfileDumpHandler(configParams *config.Config) {
+	debugserver.RegisterHandler("profile-dump", profileDumpHandler{
+		memoryProfilePath: configParams.DebugMemoryProfilePath,
+		cpuProfilePath:    configParams.DebugCPUProfilePath,
+	})
+}
+
+type profileDumpHandler struct {
+	memoryProfilePath string
+	cpuProfilePath    string
+}
+
+func (h profileDumpHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var path string
+	var dump func(string)
+	switch strings.TrimPrefix(req.URL.Path, "/debug/profile-dump/") {
+	case "heap":
+		path, dump = h.memoryProfilePath, DumpHeapMemoryProfile
+	case "cpu":
+		path, dump = h.cpuProfilePath, DumpCPUProfile
+	default:
+		http.NotFound(w, req)
+		return
+	}
+	if path == "" {
+		http.Error(w, "No profile path configured", http.StatusConflict)
+		return
+	}
+	// A CPU profile takes several seconds to collect, so write the profile in the background.
+	go dump(path)
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,72 @@
+// +build !windows
+
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logutils
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Profile dump handler", func() {
+	var (
+		dir     string
+		handler profileDumpHandler
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "profile-dump-test")
+		Expect(err).NotTo(HaveOccurred())
+		handler = profileDumpHandler{memoryProfilePath: filepath.Join(dir, "heap.pprof")}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	serve := func(method, path string) int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w.Code
+	}
+
+	It("should write a heap profile", func() {
+		Expect(serve(http.MethodPost, "/debug/profile-dump/heap")).To(Equal(http.StatusAccepted))
+		Eventually(func() error {
+			_, err := os.Stat(filepath.Join(dir, "heap.pprof"))
+			return err
+		}).Should(Succeed())
+	})
+
+	It("should only accept POST", func() {
+		Expect(serve(http.MethodGet, "/debug/profile-dump/heap")).To(Equal(http.StatusMethodNotAllowed))
+	})
+
+	It("should reject profiles with no configured path", func() {
+		Expect(serve(http.MethodPost, "/debug/profile-dump/cpu")).To(Equal(http.StatusConflict))
+	})
+
+	It("should reject unknown profiles", func() {
+		Expect(serve(http.MethodPost, "/debug/profile-dump/block")).To(Equal(http.StatusNotFound))
+	})
+})
@@ -52,6 +52,10 @@ func RegisterProfilingSignalHandlers(configParams *config.Config) {
 	return
 }
 
+func RegisterProfileDumpHandler(configParams *config.Config) {
+	return
+}
+
 // A simple io.Writer for logging to file
 type FileWriter struct {
 	file *os.File
@@ -0,0 +1,85 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metricsserver serves Felix's Prometheus metrics endpoint, optionally over TLS.  When
+// TLS is enabled, the certificate, key and CA bundle are re-read whenever the files change on
+// disk so that they can be rotated without restarting Felix.
+package metricsserver
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultReloadInterval = 10 * time.Second
+	restartDelay          = 1 * time.Second
+)
+
+type Config struct {
+	Host string
+	Port int
+
+	// CertFile and KeyFile enable TLS when set.
+	CertFile string
+	KeyFile  string
+	// CAFile, if set, enables client certificate authentication: only clients presenting a
+	// certificate signed by one of the CAs in the file are allowed to scrape the metrics.
+	CAFile string
+
+	// ReloadInterval is how often the TLS files are checked for changes.
+	ReloadInterval time.Duration
+}
+
+func (c Config) TLSEnabled() bool {
+	return c.CertFile != "" && c.KeyFile != ""
+}
+
+// Serve serves the Prometheus metrics endpoint.  It never returns; if the server fails, it is
+// restarted after a short delay.
+func Serve(config Config) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+
+	var files *tlsFiles
+	if config.TLSEnabled() {
+		reloadInterval := config.ReloadInterval
+		if reloadInterval <= 0 {
+			reloadInterval = defaultReloadInterval
+		}
+		files = newTLSFiles(config.CertFile, config.KeyFile, config.CAFile)
+		go files.watch(reloadInterval)
+	}
+
+	for {
+		var err error
+		server := &http.Server{Addr: addr, Handler: mux}
+		if files != nil {
+			server.TLSConfig = files.serverConfig()
+			// The certificate comes from the TLS config so no file names are needed here.
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		log.WithError(err).Error(
+			"Prometheus metrics endpoint failed, trying to restart it...")
+		time.Sleep(restartDelay)
+	}
+}
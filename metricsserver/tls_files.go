@@ -0,0 +1,177 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metricsserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// tlsFiles holds the most recently loaded TLS config for the metrics server.  We poll the files'
+// modification times rather than using inotify because certificates mounted from Kubernetes
+// secrets are updated by swapping symlinks, which inotify watches on the file don't see.
+type tlsFiles struct {
+	certFile, keyFile, caFile string
+
+	lock      sync.RWMutex
+	config    *tls.Config
+	modTimes  []time.Time
+	lastError error
+}
+
+func newTLSFiles(certFile, keyFile, caFile string) *tlsFiles {
+	f := &tlsFiles{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+	}
+	f.maybeReload()
+	return f
+}
+
+func (f *tlsFiles) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		f.maybeReload()
+	}
+}
+
+// serverConfig returns the TLS config for the server.  The certificate comes from the files, via
+// GetCertificate, so the server doesn't need the file names; http.Server.ServeTLS refuses to start
+// with empty file names unless GetCertificate or Certificates is set.
+func (f *tlsFiles) serverConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate:     f.getCertificate,
+		GetConfigForClient: f.getConfigForClient,
+	}
+}
+
+func (f *tlsFiles) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	if f.config == nil {
+		return nil, fmt.Errorf("metrics TLS configuration not loaded: %v", f.lastError)
+	}
+	return &f.config.Certificates[0], nil
+}
+
+func (f *tlsFiles) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	if f.config == nil {
+		return nil, fmt.Errorf("metrics TLS configuration not loaded: %v", f.lastError)
+	}
+	return f.config, nil
+}
+
+func (f *tlsFiles) files() []string {
+	files := []string{f.certFile, f.keyFile}
+	if f.caFile != "" {
+		files = append(files, f.caFile)
+	}
+	return files
+}
+
+// maybeReload reloads the TLS config if any of the files have changed since they were last
+// loaded.  If loading fails, the previous config (if any) is kept.
+func (f *tlsFiles) maybeReload() {
+	var modTimes []time.Time
+	for _, name := range f.files() {
+		info, err := os.Stat(name)
+		if err != nil {
+			f.recordError(err)
+			return
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+
+	f.lock.RLock()
+	unchanged := f.config != nil && timesEqual(modTimes, f.modTimes)
+	f.lock.RUnlock()
+	if unchanged {
+		return
+	}
+
+	config, err := f.load()
+	if err != nil {
+		f.recordError(err)
+		return
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.config == nil {
+		log.WithField("certFile", f.certFile).Info("Loaded metrics TLS configuration.")
+	} else {
+		log.WithField("certFile", f.certFile).Info("Metrics TLS files changed, reloaded TLS configuration.")
+	}
+	f.config = config
+	f.modTimes = modTimes
+	f.lastError = nil
+}
+
+func (f *tlsFiles) recordError(err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.lastError == nil || f.lastError.Error() != err.Error() {
+		log.WithError(err).Error("Failed to load metrics TLS configuration, keeping previous configuration (if any).")
+	}
+	f.lastError = err
+}
+
+func (f *tlsFiles) load() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(f.certFile, f.keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if f.caFile != "" {
+		pem, err := ioutil.ReadFile(f.caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in metrics CA file " + f.caFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+func timesEqual(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,219 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metricsserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func makeCert(cn string, serial int64, parent *testCert, isCA bool) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	parentCert, parentKey := tmpl, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parentCert, &key.PublicKey, parentKey)
+	Expect(err).NotTo(HaveOccurred())
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).NotTo(HaveOccurred())
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+var _ = Describe("Metrics TLS", func() {
+	var (
+		dir                       string
+		certFile, keyFile, caFile string
+		ca, serverCert            *testCert
+		listener                  net.Listener
+		files                     *tlsFiles
+		modTime                   time.Time
+	)
+
+	writeFile := func(name string, data []byte) {
+		Expect(ioutil.WriteFile(name, data, 0600)).To(Succeed())
+		// Make sure that the modification time changes, even on filesystems with coarse
+		// timestamps.
+		modTime = modTime.Add(time.Second)
+		Expect(os.Chtimes(name, modTime, modTime)).To(Succeed())
+	}
+
+	writeServerCert := func(c *testCert) {
+		writeFile(certFile, c.certPEM)
+		writeFile(keyFile, c.keyPEM)
+	}
+
+	serve := func() {
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		// As in Serve, the server gets no certificate file names.
+		server := &http.Server{
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("metrics"))
+			}),
+			TLSConfig: files.serverConfig(),
+		}
+		go func() {
+			_ = server.ServeTLS(listener, "", "")
+		}()
+	}
+
+	get := func(clientCert *testCert) (*http.Response, error) {
+		roots := x509.NewCertPool()
+		roots.AddCert(ca.cert)
+		tlsConfig := &tls.Config{RootCAs: roots}
+		if clientCert != nil {
+			pair, err := tls.X509KeyPair(clientCert.certPEM, clientCert.keyPEM)
+			Expect(err).NotTo(HaveOccurred())
+			tlsConfig.Certificates = []tls.Certificate{pair}
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		resp, err := client.Get("https://" + listener.Addr().String() + "/metrics")
+		if err == nil {
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "metrics-tls")
+		Expect(err).NotTo(HaveOccurred())
+		certFile = filepath.Join(dir, "tls.crt")
+		keyFile = filepath.Join(dir, "tls.key")
+		caFile = filepath.Join(dir, "ca.crt")
+		modTime = time.Now().Add(-time.Hour)
+
+		ca = makeCert("ca", 1, nil, true)
+		serverCert = makeCert("felix", 2, ca, false)
+		writeServerCert(serverCert)
+		writeFile(caFile, ca.certPEM)
+	})
+
+	AfterEach(func() {
+		if listener != nil {
+			listener.Close()
+			listener = nil
+		}
+		os.RemoveAll(dir)
+	})
+
+	Context("without a CA file", func() {
+		BeforeEach(func() {
+			files = newTLSFiles(certFile, keyFile, "")
+			serve()
+		})
+
+		It("should serve over TLS without a client certificate", func() {
+			resp, err := get(nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("should pick up a rotated certificate", func() {
+			newCert := makeCert("felix", 3, ca, false)
+			writeServerCert(newCert)
+			files.maybeReload()
+
+			resp, err := get(nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.TLS.PeerCertificates[0].SerialNumber.Int64()).To(BeEquivalentTo(3))
+		})
+
+		It("should keep the previous certificate if the new one is invalid", func() {
+			writeFile(certFile, []byte("not a cert"))
+			files.maybeReload()
+			Expect(files.lastError).To(HaveOccurred())
+
+			resp, err := get(nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.TLS.PeerCertificates[0].SerialNumber.Int64()).To(BeEquivalentTo(2))
+		})
+	})
+
+	Context("with a CA file", func() {
+		BeforeEach(func() {
+			files = newTLSFiles(certFile, keyFile, caFile)
+			serve()
+		})
+
+		It("should reject clients without a certificate", func() {
+			_, err := get(nil)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should reject clients with a certificate from another CA", func() {
+			otherCA := makeCert("other-ca", 10, nil, true)
+			_, err := get(makeCert("prometheus", 11, otherCA, false))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should accept clients with a certificate signed by the CA", func() {
+			resp, err := get(makeCert("prometheus", 4, ca, false))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+	})
+
+	It("should fail handshakes if the files never loaded", func() {
+		Expect(os.Remove(keyFile)).To(Succeed())
+		files = newTLSFiles(certFile, keyFile, "")
+		serve()
+		_, err := get(nil)
+		Expect(err).To(HaveOccurred())
+	})
+})
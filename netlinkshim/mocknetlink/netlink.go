@@ -98,6 +98,7 @@ const (
 	FailNextWireguardClose
 	FailNextWireguardDeviceByName
 	FailNextWireguardConfigureDevice
+	FailNextRouteAddNoBufs
 	FailNone FailFlags = 0
 )
 
@@ -191,6 +192,9 @@ func (f FailFlags) String() string {
 	if f&FailNextWireguardConfigureDevice != 0 {
 		parts = append(parts, "FailNextWireguardConfigureDevice")
 	}
+	if f&FailNextRouteAddNoBufs != 0 {
+		parts = append(parts, "FailNextRouteAddNoBufs")
+	}
 	if f == 0 {
 		parts = append(parts, "FailNone")
 	}
@@ -381,6 +385,25 @@ func (d *MockNetlinkDataplane) LinkByName(name string) (netlink.Link, error) {
 	return nil, NotFoundError
 }
 
+func (d *MockNetlinkDataplane) LinkByIndex(index int) (netlink.Link, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	defer GinkgoRecover()
+
+	Expect(d.NetlinkOpen).To(BeTrue())
+	for _, link := range d.NameToLink {
+		if link.LinkAttrs.Index != index {
+			continue
+		}
+		if link.LinkType == "vrf" {
+			// Callers type-assert to get the VRF's table.
+			return &netlink.Vrf{LinkAttrs: link.LinkAttrs, Table: link.VRFTable}, nil
+		}
+		return link, nil
+	}
+	return nil, NotFoundError
+}
+
 func (d *MockNetlinkDataplane) LinkAdd(link netlink.Link) error {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
@@ -662,6 +685,9 @@ func (d *MockNetlinkDataplane) RouteAdd(route *netlink.Route) error {
 	if d.shouldFail(FailNextRouteAdd) {
 		return SimulatedError
 	}
+	if d.shouldFail(FailNextRouteAddNoBufs) {
+		return unix.ENOBUFS
+	}
 	key := KeyForRoute(route)
 	log.WithField("routeKey", key).Info("Mock dataplane: RouteUpdate called")
 	d.AddedRouteKeys.Add(key)
@@ -762,6 +788,8 @@ type MockLink struct {
 	LinkAttrs netlink.LinkAttrs
 	Addrs     []netlink.Addr
 	LinkType  string
+	// VRFTable is the table of a link with LinkType "vrf".
+	VRFTable uint32
 
 	WireguardPrivateKey   wgtypes.Key
 	WireguardPublicKey    wgtypes.Key
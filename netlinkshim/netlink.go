@@ -25,6 +25,7 @@ type Interface interface {
 	SetSocketTimeout(to time.Duration) error
 	LinkList() ([]netlink.Link, error)
 	LinkByName(name string) (netlink.Link, error)
+	LinkByIndex(index int) (netlink.Link, error)
 	LinkAdd(link netlink.Link) error
 	LinkDel(link netlink.Link) error
 	LinkSetMTU(link netlink.Link, mtu int) error
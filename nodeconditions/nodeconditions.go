@@ -0,0 +1,355 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodeconditions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// PolicyReady is the node condition that Felix sets to True once it has finished its initial
+	// programming of the dataplane, and so can enforce policy for pods scheduled to the node.
+	PolicyReady v1.NodeConditionType = "PolicyReady"
+	// PolicyDegraded is the node condition that Felix sets to True when it can't render some
+	// policy rules, or some features, as written on this node (for example, because iptables
+	// lacks a match) and has to enforce them in a weaker or stricter form instead.
+	PolicyDegraded v1.NodeConditionType = "PolicyDegraded"
+
+	// DataplaneSummaryAnnotation holds the JSON-encoded DataplaneSummary that Felix last reported
+	// for the node.
+	DataplaneSummaryAnnotation = "projectcalico.org/dataplaneSummary"
+
+	reasonNotInSync        = "FelixNotInSync"
+	reasonInSync           = "FelixInSync"
+	reasonUp               = "CalicoIsUp"
+	reasonUnsupportedRules = "UnsupportedRules"
+	reasonRulesSupported   = "AllRulesSupported"
+
+	// maxDegradedIssues limits the number of issues listed in the PolicyDegraded message.
+	maxDegradedIssues = 10
+
+	timeout     = 20 * time.Second
+	initBackoff = 1 * time.Second
+	maxBackoff  = 1 * time.Minute
+)
+
+// DataplaneSummary summarises what Felix is programming on the node, so that dashboards can be
+// built from the Node resources rather than by scraping every node's metrics.
+type DataplaneSummary struct {
+	Timestamp               string  `json:"timestamp"`
+	WorkloadEndpoints       int     `json:"workloadEndpoints"`
+	HostEndpoints           int     `json:"hostEndpoints"`
+	Policies                int     `json:"policies"`
+	Profiles                int     `json:"profiles"`
+	Rules                   int     `json:"rules"`
+	IPSets                  int     `json:"ipSets"`
+	Routes                  int     `json:"routes"`
+	LastApplyLatencySeconds float64 `json:"lastApplyLatencySeconds"`
+	// ApplyErrors is the number of dataplane updates that have failed since Felix started.
+	ApplyErrors int `json:"applyErrors"`
+}
+
+// Reporter publishes Felix's readiness as conditions on our Kubernetes Node.  Before the initial
+// dataplane programming completes, it sets PolicyReady=False and NetworkUnavailable=True, so that
+// pods aren't scheduled to the node; afterwards it sets PolicyReady=True and
+// NetworkUnavailable=False.  It also writes the latest DataplaneSummary, if any, to the
+// DataplaneSummaryAnnotation.  Updates are made in the background and retried with backoff, so
+// the API server being unavailable never blocks the dataplane.
+//
+// The conditions need permission to patch the nodes/status subresource and the summary, which is
+// an annotation, permission to patch nodes.
+type Reporter struct {
+	client   kubernetes.Interface
+	nodeName string
+	clock    clock.Clock
+
+	lock    sync.Mutex
+	ready   bool
+	pending bool
+	kickC   chan struct{}
+	// degradedIssues is nil until ReportDegraded is first called, after which we also write the
+	// PolicyDegraded condition.
+	degradedIssues []string
+	summary        *DataplaneSummary
+	summaryPending bool
+
+	// lastConditions holds the conditions that we last wrote, so that we only move their
+	// LastTransitionTime when their status changes.  It's nil until we've read the node's
+	// existing conditions.  Only accessed from the loop goroutine.
+	lastConditions map[v1.NodeConditionType]v1.NodeCondition
+}
+
+func NewReporter(client kubernetes.Interface, nodeName string) *Reporter {
+	return newReporter(client, nodeName, clock.RealClock{})
+}
+
+func newReporter(client kubernetes.Interface, nodeName string, c clock.Clock) *Reporter {
+	return &Reporter{
+		client:   client,
+		nodeName: nodeName,
+		clock:    c,
+		kickC:    make(chan struct{}, 1),
+	}
+}
+
+// Start starts the background goroutine that writes the conditions.
+func (r *Reporter) Start() {
+	go r.loop()
+}
+
+// ReportNotReady sets PolicyReady=False and NetworkUnavailable=True on the node.
+func (r *Reporter) ReportNotReady() {
+	r.report(false)
+}
+
+// ReportReady sets PolicyReady=True and NetworkUnavailable=False on the node.
+func (r *Reporter) ReportReady() {
+	r.report(true)
+}
+
+// ReportDegraded sets PolicyDegraded on the node: True, listing the issues, if there are any and
+// False otherwise.
+func (r *Reporter) ReportDegraded(issues []string) {
+	r.lock.Lock()
+	r.degradedIssues = append([]string{}, issues...)
+	r.pending = true
+	r.lock.Unlock()
+	r.kick()
+}
+
+// ReportDataplaneSummary writes the summary to the node's DataplaneSummaryAnnotation.  To avoid
+// churning the Node, and every watcher of Nodes, the summary is only written if one of its counts
+// has changed; the timestamp and apply latency change with every update so, on their own, they
+// don't count.
+func (r *Reporter) ReportDataplaneSummary(summary DataplaneSummary) {
+	r.lock.Lock()
+	if r.summary != nil {
+		last := *r.summary
+		last.Timestamp = summary.Timestamp
+		last.LastApplyLatencySeconds = summary.LastApplyLatencySeconds
+		if last == summary {
+			r.lock.Unlock()
+			return
+		}
+	}
+	r.summary = &summary
+	r.summaryPending = true
+	r.lock.Unlock()
+	r.kick()
+}
+
+func (r *Reporter) report(ready bool) {
+	r.lock.Lock()
+	r.ready = ready
+	r.pending = true
+	r.lock.Unlock()
+	r.kick()
+}
+
+func (r *Reporter) kick() {
+
+	select {
+	case r.kickC <- struct{}{}:
+	default:
+		// Loop already has a kick pending; it'll pick up the latest state.
+	}
+}
+
+func (r *Reporter) loop() {
+	backoff := initBackoff
+	var retryC <-chan time.Time
+	for {
+		select {
+		case <-r.kickC:
+			backoff = initBackoff
+		case <-retryC:
+		}
+		retryC = nil
+
+		r.lock.Lock()
+		ready, pending, degradedIssues := r.ready, r.pending, r.degradedIssues
+		summary, summaryPending := r.summary, r.summaryPending
+		r.pending = false
+		r.summaryPending = false
+		r.lock.Unlock()
+
+		failed := false
+		if pending {
+			if err := r.patchConditions(ready, degradedIssues); err != nil {
+				log.WithError(err).WithField("ready", ready).Warn(
+					"Failed to update node conditions, will retry")
+				r.lock.Lock()
+				// Retry; we write whatever the state is then, which may be newer than this.
+				r.pending = true
+				r.lock.Unlock()
+				failed = true
+			} else {
+				log.WithField("ready", ready).Info("Updated node conditions")
+			}
+		}
+		if summaryPending {
+			if err := r.patchSummary(summary); err != nil {
+				log.WithError(err).Warn("Failed to update dataplane summary, will retry")
+				r.lock.Lock()
+				r.summaryPending = true
+				r.lock.Unlock()
+				failed = true
+			} else {
+				log.Debug("Updated dataplane summary")
+			}
+		}
+		if failed {
+			retryC = r.clock.After(backoff)
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+	}
+}
+
+func (r *Reporter) patchConditions(ready bool, degradedIssues []string) error {
+	now := metav1.NewTime(r.clock.Now())
+	policyReady := v1.NodeCondition{
+		Type:               PolicyReady,
+		Status:             v1.ConditionFalse,
+		Reason:             reasonNotInSync,
+		Message:            "Felix has not finished programming the dataplane",
+		LastHeartbeatTime:  now,
+		LastTransitionTime: now,
+	}
+	// The scheduler doesn't schedule pods to a node with NetworkUnavailable=True, so that's what
+	// keeps pods off the node until we can enforce their policy.
+	networkUnavailable := v1.NodeCondition{
+		Type:               v1.NodeNetworkUnavailable,
+		Status:             v1.ConditionTrue,
+		Reason:             reasonNotInSync,
+		Message:            "Felix has not finished programming the dataplane",
+		LastHeartbeatTime:  now,
+		LastTransitionTime: now,
+	}
+	if ready {
+		policyReady.Status = v1.ConditionTrue
+		policyReady.Reason = reasonInSync
+		policyReady.Message = "Felix has programmed the dataplane"
+		networkUnavailable.Status = v1.ConditionFalse
+		networkUnavailable.Reason = reasonUp
+		networkUnavailable.Message = "Calico is running on this node"
+	}
+	conditions := []v1.NodeCondition{policyReady, networkUnavailable}
+	if degradedIssues != nil {
+		conditions = append(conditions, degradedCondition(degradedIssues, now))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if r.lastConditions == nil {
+		// Pick up the conditions that a previous Felix, or someone else, wrote.
+		node, err := r.client.CoreV1().Nodes().Get(ctx, r.nodeName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		r.lastConditions = map[v1.NodeConditionType]v1.NodeCondition{}
+		for _, c := range node.Status.Conditions {
+			r.lastConditions[c.Type] = c
+		}
+	}
+	for i, c := range conditions {
+		if last, ok := r.lastConditions[c.Type]; ok && last.Status == c.Status {
+			conditions[i].LastTransitionTime = last.LastTransitionTime
+		}
+	}
+
+	// Node conditions are merged by type, so a strategic merge patch leaves the kubelet's
+	// conditions alone.
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": conditions,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.CoreV1().Nodes().Patch(ctx, r.nodeName, types.StrategicMergePatchType, patch,
+		metav1.PatchOptions{}, "status")
+	if err != nil {
+		return err
+	}
+	for _, c := range conditions {
+		r.lastConditions[c.Type] = c
+	}
+	return nil
+}
+
+func (r *Reporter) patchSummary(summary *DataplaneSummary) error {
+	summaryJSON, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				DataplaneSummaryAnnotation: string(summaryJSON),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err = r.client.CoreV1().Nodes().Patch(ctx, r.nodeName, types.MergePatchType, patch,
+		metav1.PatchOptions{})
+	return err
+}
+
+func degradedCondition(issues []string, now metav1.Time) v1.NodeCondition {
+	c := v1.NodeCondition{
+		Type:               PolicyDegraded,
+		Status:             v1.ConditionFalse,
+		Reason:             reasonRulesSupported,
+		Message:            "Felix can render all policy rules as written",
+		LastHeartbeatTime:  now,
+		LastTransitionTime: now,
+	}
+	if len(issues) == 0 {
+		return c
+	}
+	listed := issues
+	if len(listed) > maxDegradedIssues {
+		listed = listed[:maxDegradedIssues]
+	}
+	c.Status = v1.ConditionTrue
+	c.Reason = reasonUnsupportedRules
+	c.Message = "Felix can't render some policy rules as written on this node: " + strings.Join(listed, "; ")
+	if len(issues) > len(listed) {
+		c.Message += fmt.Sprintf("; and %d more", len(issues)-len(listed))
+	}
+	return c
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodeconditions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var _ = Describe("Node conditions reporter", func() {
+	var (
+		client    *fake.Clientset
+		fakeClock *clock.FakeClock
+		reporter  *Reporter
+	)
+
+	BeforeEach(func() {
+		client = fake.NewSimpleClientset(&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+			Status: v1.NodeStatus{
+				Conditions: []v1.NodeCondition{
+					{Type: v1.NodeReady, Status: v1.ConditionTrue, Reason: "KubeletReady"},
+					{Type: v1.NodeNetworkUnavailable, Status: v1.ConditionFalse, Reason: "RouteCreated"},
+				},
+			},
+		})
+		fakeClock = clock.NewFakeClock(time.Now())
+		reporter = newReporter(client, "node1", fakeClock)
+		reporter.Start()
+	})
+
+	conditions := func() map[v1.NodeConditionType]v1.ConditionStatus {
+		node, err := client.CoreV1().Nodes().Get(context.Background(), "node1", metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		conds := map[v1.NodeConditionType]v1.ConditionStatus{}
+		for _, c := range node.Status.Conditions {
+			conds[c.Type] = c.Status
+		}
+		return conds
+	}
+
+	It("should set PolicyReady=False and NetworkUnavailable=True before the dataplane is in sync", func() {
+		reporter.ReportNotReady()
+		Eventually(conditions).Should(Equal(map[v1.NodeConditionType]v1.ConditionStatus{
+			v1.NodeReady:              v1.ConditionTrue,
+			v1.NodeNetworkUnavailable: v1.ConditionTrue,
+			PolicyReady:               v1.ConditionFalse,
+		}))
+	})
+
+	It("should set PolicyReady=True and NetworkUnavailable=False once in sync", func() {
+		reporter.ReportNotReady()
+		reporter.ReportReady()
+		Eventually(conditions).Should(Equal(map[v1.NodeConditionType]v1.ConditionStatus{
+			v1.NodeReady:              v1.ConditionTrue,
+			v1.NodeNetworkUnavailable: v1.ConditionFalse,
+			PolicyReady:               v1.ConditionTrue,
+		}))
+	})
+
+	It("should only set PolicyDegraded once it has been reported", func() {
+		reporter.ReportReady()
+		Eventually(conditions).Should(HaveKeyWithValue(PolicyReady, v1.ConditionTrue))
+		Expect(conditions()).NotTo(HaveKey(PolicyDegraded))
+
+		reporter.ReportDegraded(nil)
+		Eventually(conditions).Should(HaveKeyWithValue(PolicyDegraded, v1.ConditionFalse))
+	})
+
+	It("should list the issues in PolicyDegraded", func() {
+		var issues []string
+		for i := 0; i < maxDegradedIssues+2; i++ {
+			issues = append(issues, fmt.Sprintf("issue %d", i))
+		}
+		reporter.ReportReady()
+		reporter.ReportDegraded(issues)
+		Eventually(conditions).Should(HaveKeyWithValue(PolicyDegraded, v1.ConditionTrue))
+
+		node, err := client.CoreV1().Nodes().Get(context.Background(), "node1", metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		for _, c := range node.Status.Conditions {
+			if c.Type != PolicyDegraded {
+				continue
+			}
+			Expect(c.Reason).To(Equal(reasonUnsupportedRules))
+			Expect(c.Message).To(ContainSubstring("issue 0; issue 1"))
+			Expect(c.Message).NotTo(ContainSubstring(fmt.Sprintf("issue %d", maxDegradedIssues)))
+			Expect(c.Message).To(HaveSuffix("; and 2 more"))
+		}
+
+		reporter.ReportDegraded(nil)
+		Eventually(conditions).Should(HaveKeyWithValue(PolicyDegraded, v1.ConditionFalse))
+		Expect(conditions()).To(HaveKeyWithValue(PolicyReady, v1.ConditionTrue))
+	})
+
+	It("should only move LastTransitionTime when a condition's status changes", func() {
+		transitionTimes := func() map[v1.NodeConditionType]int64 {
+			node, err := client.CoreV1().Nodes().Get(context.Background(), "node1", metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			times := map[v1.NodeConditionType]int64{}
+			for _, c := range node.Status.Conditions {
+				times[c.Type] = c.LastTransitionTime.Unix()
+			}
+			return times
+		}
+		start := fakeClock.Now().Unix()
+
+		reporter.ReportNotReady()
+		Eventually(conditions).Should(HaveKeyWithValue(PolicyReady, v1.ConditionFalse))
+		Expect(transitionTimes()).To(HaveKeyWithValue(PolicyReady, start))
+
+		fakeClock.Step(time.Minute)
+		reporter.ReportDegraded(nil)
+		Eventually(conditions).Should(HaveKey(PolicyDegraded))
+		Expect(transitionTimes()).To(HaveKeyWithValue(PolicyReady, start))
+		Expect(transitionTimes()).To(HaveKeyWithValue(PolicyDegraded, start+60))
+
+		fakeClock.Step(time.Minute)
+		reporter.ReportReady()
+		Eventually(conditions).Should(HaveKeyWithValue(PolicyReady, v1.ConditionTrue))
+		Expect(transitionTimes()).To(HaveKeyWithValue(PolicyReady, start+120))
+		Expect(transitionTimes()).To(HaveKeyWithValue(PolicyDegraded, start+60))
+	})
+
+	It("should write the dataplane summary to an annotation", func() {
+		reporter.ReportDataplaneSummary(DataplaneSummary{WorkloadEndpoints: 3, Rules: 12})
+		annotation := func() string {
+			node, err := client.CoreV1().Nodes().Get(context.Background(), "node1", metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			return node.Annotations[DataplaneSummaryAnnotation]
+		}
+		Eventually(annotation).Should(MatchJSON(`{
+			"timestamp": "",
+			"workloadEndpoints": 3,
+			"hostEndpoints": 0,
+			"policies": 0,
+			"profiles": 0,
+			"rules": 12,
+			"ipSets": 0,
+			"routes": 0,
+			"lastApplyLatencySeconds": 0,
+			"applyErrors": 0
+		}`))
+		// Only the summary was reported, so the conditions should be untouched.
+		Expect(conditions()).NotTo(HaveKey(PolicyReady))
+	})
+
+	It("should only write the dataplane summary when it changes", func() {
+		var patches int32
+		client.PrependReactor("patch", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			atomic.AddInt32(&patches, 1)
+			return false, nil, nil
+		})
+		numPatches := func() int32 {
+			return atomic.LoadInt32(&patches)
+		}
+		reporter.ReportDataplaneSummary(DataplaneSummary{Timestamp: "t1", Rules: 12})
+		Eventually(numPatches).Should(BeEquivalentTo(1))
+
+		reporter.ReportDataplaneSummary(DataplaneSummary{Timestamp: "t2", Rules: 12, LastApplyLatencySeconds: 0.1})
+		Consistently(numPatches, "100ms").Should(BeEquivalentTo(1))
+
+		reporter.ReportDataplaneSummary(DataplaneSummary{Timestamp: "t3", Rules: 13})
+		Eventually(numPatches).Should(BeEquivalentTo(2))
+	})
+
+	It("should retry after a failure", func() {
+		failures := 1
+		client.PrependReactor("patch", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if failures > 0 {
+				failures--
+				return true, nil, errors.New("dummy error")
+			}
+			return false, nil, nil
+		})
+
+		reporter.ReportReady()
+		Eventually(fakeClock.HasWaiters).Should(BeTrue())
+		Expect(conditions()).NotTo(HaveKey(PolicyReady))
+
+		fakeClock.Step(initBackoff)
+		Eventually(conditions).Should(HaveKeyWithValue(PolicyReady, v1.ConditionTrue))
+	})
+})
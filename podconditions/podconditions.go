@@ -0,0 +1,356 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package podconditions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/projectcalico/felix/proto"
+)
+
+const (
+	// PolicyProgrammed is the pod condition that Felix sets to True once the dataplane has
+	// programmed the pod's policy on all of its interfaces.  Pods that list it in their
+	// readinessGates don't become ready, and so don't receive Service traffic, until their policy
+	// is in force.
+	PolicyProgrammed v1.PodConditionType = "projectcalico.org/PolicyProgrammed"
+
+	// PolicyGenerationAnnotation holds the policy generation that was last reported for the pod.
+	// The generation increases each time the dataplane finishes programming a change to the
+	// pod's policy.  For a pod with several interfaces, it is the highest generation of its
+	// interfaces.
+	PolicyGenerationAnnotation = "projectcalico.org/policyGeneration"
+
+	orchestratorKubernetes = "k8s"
+
+	reasonProgrammed    = "PolicyProgrammed"
+	reasonNotProgrammed = "PolicyNotProgrammed"
+
+	timeout     = 20 * time.Second
+	initBackoff = 1 * time.Second
+	maxBackoff  = 1 * time.Minute
+)
+
+type podStatus struct {
+	programmed bool
+	generation uint64
+	reason     string
+}
+
+// Reporter writes the policy status of our local pods, as reported by the dataplane, to their
+// Kubernetes Pods.  Updates are made in the background and retried with backoff, so the API
+// server being unavailable never blocks the dataplane.
+type Reporter struct {
+	client kubernetes.Interface
+	clock  clock.Clock
+
+	lock sync.Mutex
+	// endpoints holds the status of each of the pods' workload endpoints, by endpoint ID.  A pod
+	// with Multus secondary interfaces has a workload endpoint per interface.
+	endpoints map[types.NamespacedName]map[string]podStatus
+	// desired holds the combined status of each pod's endpoints, which is what we write.
+	desired map[types.NamespacedName]podStatus
+	dirty   map[types.NamespacedName]bool
+	kickC   chan struct{}
+	// written holds the condition that we last wrote to each pod, so that we only move its
+	// LastTransitionTime when its status changes.  If a pod is missing, we read its condition
+	// before we write it.
+	written map[types.NamespacedName]v1.PodCondition
+}
+
+func NewReporter(client kubernetes.Interface) *Reporter {
+	return newReporter(client, clock.RealClock{})
+}
+
+func newReporter(client kubernetes.Interface, c clock.Clock) *Reporter {
+	return &Reporter{
+		client:    client,
+		clock:     c,
+		endpoints: map[types.NamespacedName]map[string]podStatus{},
+		desired:   map[types.NamespacedName]podStatus{},
+		dirty:     map[types.NamespacedName]bool{},
+		kickC:     make(chan struct{}, 1),
+		written:   map[types.NamespacedName]v1.PodCondition{},
+	}
+}
+
+// Start starts the background goroutine that writes the pod statuses.
+func (r *Reporter) Start() {
+	go r.loop()
+}
+
+// OnUpdate handles the WorkloadEndpointStatusUpdate and WorkloadEndpointStatusRemove messages
+// from the dataplane; other messages and non-Kubernetes workloads are ignored.
+func (r *Reporter) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *proto.WorkloadEndpointStatusUpdate:
+		pod, ok := podName(msg.Id)
+		if !ok {
+			return
+		}
+		status := podStatus{
+			programmed: msg.Status.Status == "up",
+			generation: msg.Status.PolicyGeneration,
+			reason:     msg.Status.Reason,
+		}
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		eps := r.endpoints[pod]
+		if eps == nil {
+			eps = map[string]podStatus{}
+			r.endpoints[pod] = eps
+		}
+		eps[msg.Id.EndpointId] = status
+		r.updateDesired(pod)
+	case *proto.WorkloadEndpointStatusRemove:
+		pod, ok := podName(msg.Id)
+		if !ok {
+			return
+		}
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		eps := r.endpoints[pod]
+		delete(eps, msg.Id.EndpointId)
+		if len(eps) == 0 {
+			// The pod is going away, so there's nothing to write.
+			delete(r.endpoints, pod)
+			delete(r.desired, pod)
+			delete(r.dirty, pod)
+			delete(r.written, pod)
+			return
+		}
+		// Only one of the pod's interfaces has gone; the others determine its status now.
+		r.updateDesired(pod)
+	}
+}
+
+// updateDesired recalculates the combined status of the pod's endpoints and queues a write if it
+// has changed.  Must be called with the lock held.
+func (r *Reporter) updateDesired(pod types.NamespacedName) {
+	status := combineStatuses(r.endpoints[pod])
+	if old, ok := r.desired[pod]; ok && old == status {
+		return
+	}
+	r.desired[pod] = status
+	r.dirty[pod] = true
+	r.kick()
+}
+
+// combineStatuses returns the status of a pod from the statuses of its endpoints: its policy is
+// programmed once it is programmed on all of them.
+func combineStatuses(eps map[string]podStatus) podStatus {
+	if len(eps) == 1 {
+		for _, status := range eps {
+			return status
+		}
+	}
+	ids := make([]string, 0, len(eps))
+	for id := range eps {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	combined := podStatus{programmed: true}
+	var reasons []string
+	for _, id := range ids {
+		status := eps[id]
+		if status.generation > combined.generation {
+			combined.generation = status.generation
+		}
+		if status.programmed {
+			continue
+		}
+		combined.programmed = false
+		if status.reason != "" {
+			reasons = append(reasons, fmt.Sprintf("interface %s: %s", id, status.reason))
+		} else {
+			reasons = append(reasons, fmt.Sprintf("interface %s not programmed", id))
+		}
+	}
+	combined.reason = strings.Join(reasons, "; ")
+	return combined
+}
+
+func podName(id *proto.WorkloadEndpointID) (types.NamespacedName, bool) {
+	if id == nil || id.OrchestratorId != orchestratorKubernetes {
+		return types.NamespacedName{}, false
+	}
+	// Kubernetes workload IDs are of the form <namespace>/<pod name>.
+	parts := strings.SplitN(id.WorkloadId, "/", 2)
+	if len(parts) != 2 {
+		log.WithField("id", id).Warn("Unexpected Kubernetes workload ID, ignoring")
+		return types.NamespacedName{}, false
+	}
+	return types.NamespacedName{Namespace: parts[0], Name: parts[1]}, true
+}
+
+func (r *Reporter) kick() {
+	select {
+	case r.kickC <- struct{}{}:
+	default:
+		// Loop already has a kick pending; it'll pick up the latest state.
+	}
+}
+
+func (r *Reporter) loop() {
+	backoff := initBackoff
+	var retryC <-chan time.Time
+	for {
+		select {
+		case <-r.kickC:
+		case <-retryC:
+			retryC = nil
+		}
+
+		r.lock.Lock()
+		toWrite := map[types.NamespacedName]podStatus{}
+		for pod := range r.dirty {
+			toWrite[pod] = r.desired[pod]
+		}
+		r.dirty = map[types.NamespacedName]bool{}
+		r.lock.Unlock()
+
+		var failed []types.NamespacedName
+		for pod, status := range toWrite {
+			logCxt := log.WithFields(log.Fields{
+				"pod":        pod,
+				"programmed": status.programmed,
+				"generation": status.generation,
+			})
+			if err := r.patchPod(pod, status); err != nil {
+				if k8serrors.IsNotFound(err) {
+					logCxt.Debug("Pod no longer exists, skipping status update")
+					continue
+				}
+				logCxt.WithError(err).Warn("Failed to update pod policy status, will retry")
+				failed = append(failed, pod)
+				continue
+			}
+			logCxt.Debug("Updated pod policy status")
+		}
+
+		if len(failed) == 0 {
+			backoff = initBackoff
+			continue
+		}
+		r.lock.Lock()
+		for _, pod := range failed {
+			if _, ok := r.desired[pod]; ok {
+				r.dirty[pod] = true
+			}
+		}
+		r.lock.Unlock()
+		if retryC == nil {
+			retryC = r.clock.After(backoff)
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+	}
+}
+
+func (r *Reporter) patchPod(pod types.NamespacedName, status podStatus) error {
+	condition := v1.PodCondition{
+		Type:               PolicyProgrammed,
+		Status:             v1.ConditionTrue,
+		Reason:             reasonProgrammed,
+		Message:            fmt.Sprintf("Policy generation %d is programmed", status.generation),
+		LastTransitionTime: metav1.NewTime(r.clock.Now()),
+	}
+	if !status.programmed {
+		condition.Status = v1.ConditionFalse
+		condition.Reason = reasonNotProgrammed
+		condition.Message = "Felix has not programmed the pod's policy"
+		if status.reason != "" {
+			condition.Message += ": " + status.reason
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	last, err := r.lastCondition(ctx, pod)
+	if err != nil {
+		return err
+	}
+	if last != nil && last.Status == condition.Status {
+		condition.LastTransitionTime = last.LastTransitionTime
+	}
+
+	// The annotation and the condition go in a single patch of the status subresource, which
+	// accepts metadata changes too.  Pod conditions are merged by type, so a strategic merge patch
+	// leaves the kubelet's conditions alone.
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				PolicyGenerationAnnotation: strconv.FormatUint(status.generation, 10),
+			},
+		},
+		"status": map[string]interface{}{
+			"conditions": []v1.PodCondition{condition},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.CoreV1().Pods(pod.Namespace).Patch(ctx, pod.Name, types.StrategicMergePatchType, patch,
+		metav1.PatchOptions{}, "status")
+	if err != nil {
+		return err
+	}
+	r.lock.Lock()
+	if _, ok := r.desired[pod]; ok {
+		r.written[pod] = condition
+	}
+	r.lock.Unlock()
+	return nil
+}
+
+// lastCondition returns the PolicyProgrammed condition that we last wrote to the pod, reading it
+// from the pod if we haven't written it since we started.  It returns nil if the pod doesn't have
+// the condition.
+func (r *Reporter) lastCondition(ctx context.Context, pod types.NamespacedName) (*v1.PodCondition, error) {
+	r.lock.Lock()
+	last, ok := r.written[pod]
+	r.lock.Unlock()
+	if ok {
+		return &last, nil
+	}
+	p, err := r.client.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i, c := range p.Status.Conditions {
+		if c.Type == PolicyProgrammed {
+			return &p.Status.Conditions[i], nil
+		}
+	}
+	return nil, nil
+}
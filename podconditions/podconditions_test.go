@@ -0,0 +1,256 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package podconditions
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/projectcalico/felix/proto"
+)
+
+var _ = Describe("Pod conditions reporter", func() {
+	var (
+		client    *fake.Clientset
+		fakeClock *clock.FakeClock
+		reporter  *Reporter
+	)
+
+	BeforeEach(func() {
+		client = fake.NewSimpleClientset(&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "ns1"},
+			Status: v1.PodStatus{
+				Conditions: []v1.PodCondition{
+					{Type: v1.PodScheduled, Status: v1.ConditionTrue},
+				},
+			},
+		})
+		fakeClock = clock.NewFakeClock(time.Now())
+		reporter = newReporter(client, fakeClock)
+		reporter.Start()
+	})
+
+	id := func(workloadID string) *proto.WorkloadEndpointID {
+		return &proto.WorkloadEndpointID{
+			OrchestratorId: "k8s",
+			WorkloadId:     workloadID,
+			EndpointId:     "eth0",
+		}
+	}
+
+	getPod := func() *v1.Pod {
+		pod, err := client.CoreV1().Pods("ns1").Get(context.Background(), "pod1", metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		return pod
+	}
+
+	conditions := func() map[v1.PodConditionType]v1.ConditionStatus {
+		conds := map[v1.PodConditionType]v1.ConditionStatus{}
+		for _, c := range getPod().Status.Conditions {
+			conds[c.Type] = c.Status
+		}
+		return conds
+	}
+
+	generation := func() string {
+		return getPod().Annotations[PolicyGenerationAnnotation]
+	}
+
+	It("should set PolicyProgrammed=True and the generation once the pod is up", func() {
+		reporter.OnUpdate(&proto.WorkloadEndpointStatusUpdate{
+			Id:     id("ns1/pod1"),
+			Status: &proto.EndpointStatus{Status: "up", PolicyGeneration: 3},
+		})
+		Eventually(conditions).Should(Equal(map[v1.PodConditionType]v1.ConditionStatus{
+			v1.PodScheduled:  v1.ConditionTrue,
+			PolicyProgrammed: v1.ConditionTrue,
+		}))
+		Expect(generation()).To(Equal("3"))
+	})
+
+	It("should write the condition and the generation in a single patch", func() {
+		actions := len(client.Actions())
+		reporter.OnUpdate(&proto.WorkloadEndpointStatusUpdate{
+			Id:     id("ns1/pod1"),
+			Status: &proto.EndpointStatus{Status: "up", PolicyGeneration: 4},
+		})
+		Eventually(generation).Should(Equal("4"))
+		Expect(conditions()).To(HaveKeyWithValue(PolicyProgrammed, v1.ConditionTrue))
+		var patches int
+		for _, a := range client.Actions()[actions:] {
+			if a.GetVerb() == "patch" {
+				patches++
+			}
+		}
+		Expect(patches).To(Equal(1))
+	})
+
+	It("should set PolicyProgrammed=False if the pod is in error", func() {
+		reporter.OnUpdate(&proto.WorkloadEndpointStatusUpdate{
+			Id:     id("ns1/pod1"),
+			Status: &proto.EndpointStatus{Status: "error", Reason: "oops"},
+		})
+		Eventually(conditions).Should(HaveKeyWithValue(PolicyProgrammed, v1.ConditionFalse))
+	})
+
+	It("should only move LastTransitionTime when the status changes", func() {
+		transitionTime := func() int64 {
+			for _, c := range getPod().Status.Conditions {
+				if c.Type == PolicyProgrammed {
+					return c.LastTransitionTime.Unix()
+				}
+			}
+			return 0
+		}
+		start := fakeClock.Now().Unix()
+
+		reporter.OnUpdate(&proto.WorkloadEndpointStatusUpdate{
+			Id:     id("ns1/pod1"),
+			Status: &proto.EndpointStatus{Status: "up", PolicyGeneration: 1},
+		})
+		Eventually(generation).Should(Equal("1"))
+		Expect(transitionTime()).To(Equal(start))
+
+		fakeClock.Step(time.Minute)
+		reporter.OnUpdate(&proto.WorkloadEndpointStatusUpdate{
+			Id:     id("ns1/pod1"),
+			Status: &proto.EndpointStatus{Status: "up", PolicyGeneration: 2},
+		})
+		Eventually(generation).Should(Equal("2"))
+		Expect(transitionTime()).To(Equal(start))
+
+		fakeClock.Step(time.Minute)
+		reporter.OnUpdate(&proto.WorkloadEndpointStatusUpdate{
+			Id:     id("ns1/pod1"),
+			Status: &proto.EndpointStatus{Status: "error", Reason: "oops", PolicyGeneration: 2},
+		})
+		Eventually(conditions).Should(HaveKeyWithValue(PolicyProgrammed, v1.ConditionFalse))
+		Expect(transitionTime()).To(Equal(start + 120))
+	})
+
+	It("should keep the LastTransitionTime that was written before Felix restarted", func() {
+		pod := getPod()
+		written := metav1.NewTime(fakeClock.Now().Add(-time.Hour))
+		pod.Status.Conditions = append(pod.Status.Conditions, v1.PodCondition{
+			Type:               PolicyProgrammed,
+			Status:             v1.ConditionTrue,
+			LastTransitionTime: written,
+		})
+		_, err := client.CoreV1().Pods("ns1").UpdateStatus(context.Background(), pod, metav1.UpdateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		reporter.OnUpdate(&proto.WorkloadEndpointStatusUpdate{
+			Id:     id("ns1/pod1"),
+			Status: &proto.EndpointStatus{Status: "up", PolicyGeneration: 5},
+		})
+		Eventually(generation).Should(Equal("5"))
+		for _, c := range getPod().Status.Conditions {
+			if c.Type == PolicyProgrammed {
+				Expect(c.LastTransitionTime.Unix()).To(Equal(written.Unix()))
+			}
+		}
+	})
+
+	Context("with a pod with two interfaces", func() {
+		net1ID := &proto.WorkloadEndpointID{
+			OrchestratorId: "k8s",
+			WorkloadId:     "ns1/pod1",
+			EndpointId:     "net1",
+		}
+
+		BeforeEach(func() {
+			reporter.OnUpdate(&proto.WorkloadEndpointStatusUpdate{
+				Id:     id("ns1/pod1"),
+				Status: &proto.EndpointStatus{Status: "up", PolicyGeneration: 3},
+			})
+			reporter.OnUpdate(&proto.WorkloadEndpointStatusUpdate{
+				Id:     net1ID,
+				Status: &proto.EndpointStatus{Status: "error", Reason: "oops", PolicyGeneration: 2},
+			})
+		})
+
+		message := func() string {
+			for _, c := range getPod().Status.Conditions {
+				if c.Type == PolicyProgrammed {
+					return c.Message
+				}
+			}
+			return ""
+		}
+
+		It("should only set PolicyProgrammed=True once both interfaces are programmed", func() {
+			Eventually(message).Should(Equal("Felix has not programmed the pod's policy: interface net1: oops"))
+			Expect(conditions()).To(HaveKeyWithValue(PolicyProgrammed, v1.ConditionFalse))
+			Expect(generation()).To(Equal("3"))
+
+			reporter.OnUpdate(&proto.WorkloadEndpointStatusUpdate{
+				Id:     net1ID,
+				Status: &proto.EndpointStatus{Status: "up", PolicyGeneration: 4},
+			})
+			Eventually(conditions).Should(HaveKeyWithValue(PolicyProgrammed, v1.ConditionTrue))
+			Expect(generation()).To(Equal("4"))
+		})
+
+		It("should report the remaining interface when one is removed", func() {
+			Eventually(conditions).Should(HaveKeyWithValue(PolicyProgrammed, v1.ConditionFalse))
+			reporter.OnUpdate(&proto.WorkloadEndpointStatusRemove{Id: net1ID})
+			Eventually(conditions).Should(HaveKeyWithValue(PolicyProgrammed, v1.ConditionTrue))
+		})
+	})
+
+	It("should ignore non-Kubernetes workloads", func() {
+		actions := len(client.Actions())
+		reporter.OnUpdate(&proto.WorkloadEndpointStatusUpdate{
+			Id: &proto.WorkloadEndpointID{
+				OrchestratorId: "openstack",
+				WorkloadId:     "ns1/pod1",
+			},
+			Status: &proto.EndpointStatus{Status: "up", PolicyGeneration: 1},
+		})
+		Consistently(func() int { return len(client.Actions()) }).Should(Equal(actions))
+	})
+
+	It("should retry after a failure", func() {
+		failures := 1
+		client.PrependReactor("patch", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if failures > 0 {
+				failures--
+				return true, nil, errors.New("dummy error")
+			}
+			return false, nil, nil
+		})
+
+		reporter.OnUpdate(&proto.WorkloadEndpointStatusUpdate{
+			Id:     id("ns1/pod1"),
+			Status: &proto.EndpointStatus{Status: "up", PolicyGeneration: 2},
+		})
+		Eventually(fakeClock.HasWaiters).Should(BeTrue())
+		Expect(conditions()).NotTo(HaveKey(PolicyProgrammed))
+
+		fakeClock.Step(initBackoff)
+		Eventually(conditions).Should(HaveKeyWithValue(PolicyProgrammed, v1.ConditionTrue))
+		Expect(generation()).To(Equal("2"))
+	})
+})
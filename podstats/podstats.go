@@ -0,0 +1,253 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package podstats serves the network statistics of the local pods' interfaces in the format of
+// the kubelet's /stats/summary API, so that pod network metrics don't depend on cAdvisor finding
+// the veths that Felix manages.  The statistics are read from the host ends of the veths, so
+// received and transmitted are swapped to give the pod's point of view.
+package podstats
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/projectcalico/felix/proto"
+)
+
+const (
+	// SummaryPath is the path that the statistics are served on, as for the kubelet.
+	SummaryPath = "/stats/summary"
+
+	// defaultInterface is the pod-side name of the interface whose statistics the kubelet
+	// reports as the pod's own.
+	defaultInterface = "eth0"
+
+	orchestratorKubernetes = "k8s"
+	sysClassNet            = "/sys/class/net"
+	restartDelay           = 1 * time.Second
+)
+
+type Config struct {
+	Host string
+	Port int
+}
+
+// Summary, and the types below, follow the kubelet's stats API (k8s.io/kubelet/pkg/apis/stats/
+// v1alpha1), trimmed to the network statistics.
+type Summary struct {
+	Node NodeStats  `json:"node"`
+	Pods []PodStats `json:"pods"`
+}
+
+type NodeStats struct {
+	NodeName string `json:"nodeName"`
+}
+
+type PodReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+type PodStats struct {
+	PodRef  PodReference  `json:"podRef"`
+	Network *NetworkStats `json:"network,omitempty"`
+}
+
+// NetworkStats holds the statistics of the pod's default interface and, in Interfaces, of all
+// its interfaces.
+type NetworkStats struct {
+	Time metav1.Time `json:"time"`
+	InterfaceStats
+	Interfaces []InterfaceStats `json:"interfaces,omitempty"`
+}
+
+type InterfaceStats struct {
+	Name     string  `json:"name"`
+	RxBytes  *uint64 `json:"rxBytes,omitempty"`
+	RxErrors *uint64 `json:"rxErrors,omitempty"`
+	TxBytes  *uint64 `json:"txBytes,omitempty"`
+	TxErrors *uint64 `json:"txErrors,omitempty"`
+}
+
+// podInterface is one of a pod's interfaces: the name of the endpoint in the pod and of the
+// host end of its veth.
+type podInterface struct {
+	name      string
+	hostIface string
+}
+
+// Server tracks the interfaces of the local pods from the WorkloadEndpointUpdate and
+// WorkloadEndpointRemove messages that are sent to the dataplane, and serves their statistics.
+type Server struct {
+	nodeName    string
+	sysClassNet string
+	now         func() time.Time
+
+	lock       sync.Mutex
+	interfaces map[proto.WorkloadEndpointID]string
+}
+
+func NewServer(nodeName string) *Server {
+	return newServer(nodeName, sysClassNet, time.Now)
+}
+
+func newServer(nodeName, sysClassNet string, now func() time.Time) *Server {
+	return &Server{
+		nodeName:    nodeName,
+		sysClassNet: sysClassNet,
+		now:         now,
+		interfaces:  map[proto.WorkloadEndpointID]string{},
+	}
+}
+
+// OnUpdate handles the WorkloadEndpointUpdate and WorkloadEndpointRemove messages; other
+// messages and non-Kubernetes workloads are ignored.
+func (s *Server) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *proto.WorkloadEndpointUpdate:
+		if msg.Id == nil || msg.Id.OrchestratorId != orchestratorKubernetes || msg.Endpoint == nil {
+			return
+		}
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.interfaces[*msg.Id] = msg.Endpoint.Name
+	case *proto.WorkloadEndpointRemove:
+		if msg.Id == nil {
+			return
+		}
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		delete(s.interfaces, *msg.Id)
+	}
+}
+
+// Serve serves the statistics on SummaryPath.  It never returns; if the server fails, it is
+// restarted after a short delay.
+func (s *Server) Serve(config Config) {
+	mux := http.NewServeMux()
+	mux.Handle(SummaryPath, s)
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+	for {
+		server := &http.Server{Addr: addr, Handler: mux}
+		err := server.ListenAndServe()
+		log.WithError(err).Error("Pod statistics endpoint failed, trying to restart it...")
+		time.Sleep(restartDelay)
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.Summary()); err != nil {
+		log.WithError(err).Warn("Failed to write pod statistics")
+	}
+}
+
+// Summary reads the current statistics of the local pods' interfaces.  Interfaces whose
+// statistics can't be read, for example because the pod is being torn down, are left out.
+func (s *Server) Summary() Summary {
+	pods := map[types.NamespacedName][]podInterface{}
+	s.lock.Lock()
+	for id, hostIface := range s.interfaces {
+		// Kubernetes workload IDs are of the form <namespace>/<pod name>.
+		parts := strings.SplitN(id.WorkloadId, "/", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		pod := types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+		pods[pod] = append(pods[pod], podInterface{name: id.EndpointId, hostIface: hostIface})
+	}
+	s.lock.Unlock()
+
+	summary := Summary{
+		Node: NodeStats{NodeName: s.nodeName},
+		Pods: []PodStats{},
+	}
+	now := metav1.NewTime(s.now())
+	for pod, ifaces := range pods {
+		podStats := PodStats{PodRef: PodReference{Name: pod.Name, Namespace: pod.Namespace}}
+		sort.Slice(ifaces, func(i, j int) bool {
+			return ifaces[i].name < ifaces[j].name
+		})
+		for _, iface := range ifaces {
+			stats, err := s.readInterfaceStats(iface)
+			if err != nil {
+				log.WithError(err).WithField("iface", iface.hostIface).Debug(
+					"Failed to read interface statistics, skipping")
+				continue
+			}
+			if podStats.Network == nil {
+				podStats.Network = &NetworkStats{Time: now}
+			}
+			podStats.Network.Interfaces = append(podStats.Network.Interfaces, stats)
+			if iface.name == defaultInterface {
+				podStats.Network.InterfaceStats = stats
+			}
+		}
+		if podStats.Network != nil && podStats.Network.Name == "" {
+			// No eth0, use the first interface as the default.
+			podStats.Network.InterfaceStats = podStats.Network.Interfaces[0]
+		}
+		summary.Pods = append(summary.Pods, podStats)
+	}
+	sort.Slice(summary.Pods, func(i, j int) bool {
+		a, b := summary.Pods[i].PodRef, summary.Pods[j].PodRef
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return summary
+}
+
+// readInterfaceStats reads the statistics of the host end of the veth and swaps them round so
+// that they're from the pod's point of view.
+func (s *Server) readInterfaceStats(iface podInterface) (InterfaceStats, error) {
+	stats := InterfaceStats{Name: iface.name}
+	for _, c := range []struct {
+		file string
+		dest **uint64
+	}{
+		{"tx_bytes", &stats.RxBytes},
+		{"tx_errors", &stats.RxErrors},
+		{"rx_bytes", &stats.TxBytes},
+		{"rx_errors", &stats.TxErrors},
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(s.sysClassNet, iface.hostIface, "statistics", c.file))
+		if err != nil {
+			return InterfaceStats{}, err
+		}
+		value, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return InterfaceStats{}, err
+		}
+		*c.dest = &value
+	}
+	return stats, nil
+}
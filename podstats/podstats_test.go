@@ -0,0 +1,155 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package podstats
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/projectcalico/felix/proto"
+)
+
+var _ = Describe("Pod statistics server", func() {
+	var (
+		dir    string
+		server *Server
+	)
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "podstats")
+		Expect(err).NotTo(HaveOccurred())
+		server = newServer("node1", dir, func() time.Time { return now })
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	writeStats := func(iface string, rxBytes, rxErrors, txBytes, txErrors string) {
+		statsDir := filepath.Join(dir, iface, "statistics")
+		Expect(os.MkdirAll(statsDir, 0755)).To(Succeed())
+		for file, value := range map[string]string{
+			"rx_bytes":  rxBytes,
+			"rx_errors": rxErrors,
+			"tx_bytes":  txBytes,
+			"tx_errors": txErrors,
+		} {
+			Expect(ioutil.WriteFile(filepath.Join(statsDir, file), []byte(value+"\n"), 0644)).To(Succeed())
+		}
+	}
+
+	sendEndpoint := func(workloadID, endpointID, iface string) {
+		server.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id: &proto.WorkloadEndpointID{
+				OrchestratorId: "k8s",
+				WorkloadId:     workloadID,
+				EndpointId:     endpointID,
+			},
+			Endpoint: &proto.WorkloadEndpoint{Name: iface},
+		})
+	}
+
+	u := func(v uint64) *uint64 {
+		return &v
+	}
+
+	It("should report the statistics from the pod's point of view", func() {
+		writeStats("cali1", "100", "1", "200", "2")
+		sendEndpoint("ns1/pod1", "eth0", "cali1")
+
+		Expect(server.Summary()).To(Equal(Summary{
+			Node: NodeStats{NodeName: "node1"},
+			Pods: []PodStats{{
+				PodRef: PodReference{Name: "pod1", Namespace: "ns1"},
+				Network: &NetworkStats{
+					Time: metav1.NewTime(now),
+					InterfaceStats: InterfaceStats{
+						Name: "eth0", RxBytes: u(200), RxErrors: u(2), TxBytes: u(100), TxErrors: u(1),
+					},
+					Interfaces: []InterfaceStats{{
+						Name: "eth0", RxBytes: u(200), RxErrors: u(2), TxBytes: u(100), TxErrors: u(1),
+					}},
+				},
+			}},
+		}))
+	})
+
+	It("should group a pod's interfaces and use eth0 as the default", func() {
+		writeStats("cali1", "1", "0", "2", "0")
+		writeStats("cali2", "3", "0", "4", "0")
+		sendEndpoint("ns1/pod1", "net1", "cali2")
+		sendEndpoint("ns1/pod1", "eth0", "cali1")
+
+		pods := server.Summary().Pods
+		Expect(pods).To(HaveLen(1))
+		Expect(pods[0].Network.Name).To(Equal("eth0"))
+		Expect(pods[0].Network.Interfaces).To(HaveLen(2))
+		Expect(pods[0].Network.Interfaces[1].Name).To(Equal("net1"))
+		Expect(*pods[0].Network.Interfaces[1].RxBytes).To(Equal(uint64(4)))
+	})
+
+	It("should leave out interfaces that can't be read and removed endpoints", func() {
+		writeStats("cali1", "1", "0", "2", "0")
+		sendEndpoint("ns1/pod1", "eth0", "cali1")
+		sendEndpoint("ns1/pod2", "eth0", "cali-missing")
+
+		pods := server.Summary().Pods
+		Expect(pods).To(HaveLen(2))
+		Expect(pods[0].Network).NotTo(BeNil())
+		Expect(pods[1].PodRef.Name).To(Equal("pod2"))
+		Expect(pods[1].Network).To(BeNil())
+
+		server.OnUpdate(&proto.WorkloadEndpointRemove{Id: &proto.WorkloadEndpointID{
+			OrchestratorId: "k8s",
+			WorkloadId:     "ns1/pod1",
+			EndpointId:     "eth0",
+		}})
+		pods = server.Summary().Pods
+		Expect(pods).To(HaveLen(1))
+		Expect(pods[0].PodRef.Name).To(Equal("pod2"))
+	})
+
+	It("should serve the summary as JSON", func() {
+		writeStats("cali1", "100", "1", "200", "2")
+		sendEndpoint("ns1/pod1", "eth0", "cali1")
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, SummaryPath, nil))
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(MatchJSON(`{
+			"node": {"nodeName": "node1"},
+			"pods": [{
+				"podRef": {"name": "pod1", "namespace": "ns1"},
+				"network": {
+					"time": "2021-06-01T12:00:00Z",
+					"name": "eth0", "rxBytes": 200, "rxErrors": 2, "txBytes": 100, "txErrors": 1,
+					"interfaces": [
+						{"name": "eth0", "rxBytes": 200, "rxErrors": 2, "txBytes": 100, "txErrors": 1}
+					]
+				}
+			}]
+		}`))
+	})
+})
@@ -0,0 +1,212 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policysync
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	kapiv1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	discoveryinformers "k8s.io/client-go/informers/discovery/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/projectcalico/felix/proto"
+)
+
+const appProtocolsResyncPeriod = 10 * time.Minute
+
+// AppProtocolWatcher watches EndpointSlices and sends the Processor the appProtocols of the
+// Service ports that each pod backs.  Policy sync clients (typically L7 sidecars) receive them as
+// hints on their WorkloadEndpoint, so they don't need to query the API server themselves.
+//
+// Only the slices of Services are watched.  EndpointSlices can't be selected by node on the server
+// side, since one slice may have endpoints on many nodes, so the endpoints on other nodes are
+// ignored here.
+//
+// The informer calls our handlers from a single goroutine so the watcher needs no locking.
+type AppProtocolWatcher struct {
+	informer cache.SharedIndexInformer
+	// nodeName, if non-empty, limits the hints to endpoints that are on that node.
+	nodeName string
+	updates  chan<- interface{}
+
+	// hintsByWorkload holds the hints from each slice that has an endpoint for the workload, by
+	// workload ID and then slice key.
+	hintsByWorkload map[string]map[string][]*proto.AppProtocolHint
+	// workloadsBySlice holds the IDs of the workloads that each slice has hints for.
+	workloadsBySlice map[string][]string
+	// sent holds the hints that we last sent for each workload.
+	sent map[string][]*proto.AppProtocolHint
+}
+
+func NewAppProtocolWatcher(k8sClient kubernetes.Interface, nodeName string, updates chan<- interface{}) *AppProtocolWatcher {
+	informer := discoveryinformers.NewFilteredEndpointSliceInformer(
+		k8sClient,
+		metav1.NamespaceAll,
+		appProtocolsResyncPeriod,
+		cache.Indexers{},
+		func(opts *metav1.ListOptions) {
+			// The hints are named after the Service, so ignore slices that don't belong to one.
+			opts.LabelSelector = discovery.LabelServiceName
+		},
+	)
+	return newAppProtocolWatcher(informer, nodeName, updates)
+}
+
+func newAppProtocolWatcher(informer cache.SharedIndexInformer, nodeName string, updates chan<- interface{}) *AppProtocolWatcher {
+	w := &AppProtocolWatcher{
+		informer:         informer,
+		nodeName:         nodeName,
+		updates:          updates,
+		hintsByWorkload:  map[string]map[string][]*proto.AppProtocolHint{},
+		workloadsBySlice: map[string][]string{},
+		sent:             map[string][]*proto.AppProtocolHint{},
+	}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			w.onSliceUpdate(obj, false)
+		},
+		UpdateFunc: func(_, newObj interface{}) {
+			w.onSliceUpdate(newObj, false)
+		},
+		DeleteFunc: func(obj interface{}) {
+			w.onSliceUpdate(obj, true)
+		},
+	})
+	return w
+}
+
+// Start runs the informer until the context is cancelled.
+func (w *AppProtocolWatcher) Start(ctx context.Context) {
+	go w.informer.Run(ctx.Done())
+}
+
+func (w *AppProtocolWatcher) onSliceUpdate(obj interface{}, deleted bool) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		log.WithError(err).Warn("Failed to get key of EndpointSlice, ignoring.")
+		return
+	}
+	var hints map[string][]*proto.AppProtocolHint
+	if !deleted {
+		if slice, ok := obj.(*discovery.EndpointSlice); ok {
+			hints = w.sliceHints(slice)
+		}
+	}
+
+	affected := map[string]bool{}
+	for _, workloadID := range w.workloadsBySlice[key] {
+		affected[workloadID] = true
+		delete(w.hintsByWorkload[workloadID], key)
+		if len(w.hintsByWorkload[workloadID]) == 0 {
+			delete(w.hintsByWorkload, workloadID)
+		}
+	}
+	delete(w.workloadsBySlice, key)
+	for workloadID, h := range hints {
+		affected[workloadID] = true
+		if w.hintsByWorkload[workloadID] == nil {
+			w.hintsByWorkload[workloadID] = map[string][]*proto.AppProtocolHint{}
+		}
+		w.hintsByWorkload[workloadID][key] = h
+		w.workloadsBySlice[key] = append(w.workloadsBySlice[key], workloadID)
+	}
+
+	for workloadID := range affected {
+		w.maybeSendHints(workloadID)
+	}
+}
+
+// sliceHints returns the hints that the slice gives for each of the pods that it targets.
+func (w *AppProtocolWatcher) sliceHints(slice *discovery.EndpointSlice) map[string][]*proto.AppProtocolHint {
+	var portHints []*proto.AppProtocolHint
+	for _, port := range slice.Ports {
+		if port.AppProtocol == nil || *port.AppProtocol == "" || port.Port == nil {
+			continue
+		}
+		hint := &proto.AppProtocolHint{
+			ServiceName: slice.Labels[discovery.LabelServiceName],
+			Protocol:    string(kapiv1.ProtocolTCP),
+			Port:        *port.Port,
+			AppProtocol: *port.AppProtocol,
+		}
+		if port.Protocol != nil {
+			hint.Protocol = string(*port.Protocol)
+		}
+		if port.Name != nil {
+			hint.PortName = *port.Name
+		}
+		portHints = append(portHints, hint)
+	}
+	if len(portHints) == 0 {
+		return nil
+	}
+
+	hints := map[string][]*proto.AppProtocolHint{}
+	for _, ep := range slice.Endpoints {
+		ref := ep.TargetRef
+		if ref == nil || ref.Kind != "Pod" {
+			continue
+		}
+		if w.nodeName != "" && ep.NodeName != nil && *ep.NodeName != w.nodeName {
+			continue
+		}
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = slice.Namespace
+		}
+		hints[namespace+"/"+ref.Name] = portHints
+	}
+	return hints
+}
+
+// maybeSendHints sends the workload's hints, from all slices, to the Processor if they have
+// changed.
+func (w *AppProtocolWatcher) maybeSendHints(workloadID string) {
+	var hints []*proto.AppProtocolHint
+	for _, h := range w.hintsByWorkload[workloadID] {
+		hints = append(hints, h...)
+	}
+	sort.Slice(hints, func(i, j int) bool {
+		a, b := hints[i], hints[j]
+		if a.ServiceName != b.ServiceName {
+			return a.ServiceName < b.ServiceName
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		return a.Port < b.Port
+	})
+
+	if reflect.DeepEqual(hints, w.sent[workloadID]) {
+		return
+	}
+	if len(hints) == 0 {
+		delete(w.sent, workloadID)
+	} else {
+		w.sent[workloadID] = hints
+	}
+	log.WithFields(log.Fields{
+		"workload": workloadID,
+		"hints":    hints,
+	}).Debug("Application protocol hints changed")
+	w.updates <- &AppProtocolsUpdate{WorkloadID: workloadID, Hints: hints}
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policysync_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	kapiv1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/projectcalico/felix/policysync"
+	"github.com/projectcalico/felix/proto"
+)
+
+var _ = Describe("AppProtocolWatcher", func() {
+	var (
+		client  *fake.Clientset
+		updates chan interface{}
+		cancel  context.CancelFunc
+	)
+
+	strPtr := func(s string) *string { return &s }
+	int32Ptr := func(i int32) *int32 { return &i }
+	udp := kapiv1.ProtocolUDP
+
+	slice := func(pods ...string) *discovery.EndpointSlice {
+		s := &discovery.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "web-abcde",
+				Namespace: "default",
+				Labels:    map[string]string{discovery.LabelServiceName: "web"},
+			},
+			Ports: []discovery.EndpointPort{
+				{Name: strPtr("http"), Port: int32Ptr(8080), AppProtocol: strPtr("http")},
+				{Name: strPtr("dns"), Port: int32Ptr(53), Protocol: &udp},
+			},
+		}
+		for _, pod := range pods {
+			s.Endpoints = append(s.Endpoints, discovery.Endpoint{
+				Addresses: []string{"10.0.0.1"},
+				TargetRef: &kapiv1.ObjectReference{Kind: "Pod", Namespace: "default", Name: pod},
+				NodeName:  strPtr("node1"),
+			})
+		}
+		return s
+	}
+	httpHint := &proto.AppProtocolHint{
+		ServiceName: "web",
+		Protocol:    "TCP",
+		Port:        8080,
+		PortName:    "http",
+		AppProtocol: "http",
+	}
+
+	BeforeEach(func() {
+		unowned := slice("pod3")
+		unowned.Name = "unowned"
+		unowned.Labels = nil
+		client = fake.NewSimpleClientset(slice("pod1"), unowned)
+		updates = make(chan interface{}, 10)
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		policysync.NewAppProtocolWatcher(client, "node1", updates).Start(ctx)
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("should send the hints of the Service ports that have an appProtocol", func() {
+		Eventually(updates).Should(Receive(Equal(&policysync.AppProtocolsUpdate{
+			WorkloadID: "default/pod1",
+			Hints:      []*proto.AppProtocolHint{httpHint},
+		})))
+	})
+
+	It("should ignore slices that don't belong to a Service", func() {
+		Eventually(updates).Should(Receive())
+		Consistently(updates).ShouldNot(Receive())
+	})
+
+	It("should send updates when the pods change", func() {
+		Eventually(updates).Should(Receive())
+		_, err := client.DiscoveryV1().EndpointSlices("default").Update(
+			context.Background(), slice("pod2"), metav1.UpdateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		var received []interface{}
+		for i := 0; i < 2; i++ {
+			var u interface{}
+			Eventually(updates).Should(Receive(&u))
+			received = append(received, u)
+		}
+		Expect(received).To(ConsistOf(
+			&policysync.AppProtocolsUpdate{WorkloadID: "default/pod1"},
+			&policysync.AppProtocolsUpdate{WorkloadID: "default/pod2", Hints: []*proto.AppProtocolHint{httpHint}},
+		))
+	})
+})
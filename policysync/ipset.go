@@ -51,11 +51,27 @@ func newIPSet(update *proto.IPSetUpdate) *ipSetInfo {
 	return s
 }
 
-func (s *ipSetInfo) replaceMembers(update *proto.IPSetUpdate) {
+// replaceMembers replaces the membership of the IP set and returns the equivalent delta update.
+func (s *ipSetInfo) replaceMembers(update *proto.IPSetUpdate) *proto.IPSetDeltaUpdate {
+	delta := &proto.IPSetDeltaUpdate{Id: s.SetID}
+	oldMembers := s.members
 	s.members = set.New()
 	for _, ms := range update.GetMembers() {
-		s.members.Add(s.Type.CanonicaliseMember(ms))
+		m := s.Type.CanonicaliseMember(ms)
+		s.members.Add(m)
+		if oldMembers != nil && !oldMembers.Contains(m) {
+			delta.AddedMembers = append(delta.AddedMembers, m.String())
+		}
+	}
+	if oldMembers != nil {
+		oldMembers.Iter(func(item interface{}) error {
+			if !s.members.Contains(item) {
+				delta.RemovedMembers = append(delta.RemovedMembers, item.(fmt.Stringer).String())
+			}
+			return nil
+		})
 	}
+	return delta
 }
 
 func (s *ipSetInfo) deltaUpdate(update *proto.IPSetDeltaUpdate) {
@@ -15,6 +15,10 @@
 package policysync
 
 import (
+	"fmt"
+
+	pb "github.com/gogo/protobuf/proto"
+
 	"github.com/projectcalico/felix/proto"
 )
 
@@ -37,3 +41,99 @@ func (pi *policyInfo) computeRefs() {
 	pi.refs = make(map[string]bool)
 	addIPSetsRuleList(pi.p, pi.refs)
 }
+
+// calculatePolicyDelta returns the delta update that turns the old version of a policy into the
+// new one, or nil if none of the old policy's rules can be reused, in which case the complete
+// policy might as well be sent.
+func calculatePolicyDelta(id proto.PolicyID, old, new *proto.Policy) *proto.ActivePolicyDeltaUpdate {
+	inbound := calculateRuleListDelta(old.GetInboundRules(), new.GetInboundRules())
+	outbound := calculateRuleListDelta(old.GetOutboundRules(), new.GetOutboundRules())
+	if numReused(inbound) == 0 && numReused(outbound) == 0 {
+		return nil
+	}
+	return &proto.ActivePolicyDeltaUpdate{
+		Id:            &id,
+		InboundRules:  inbound,
+		OutboundRules: outbound,
+		Namespace:     new.GetNamespace(),
+		Untracked:     new.GetUntracked(),
+		PreDnat:       new.GetPreDnat(),
+	}
+}
+
+func numReused(delta *proto.RuleListDelta) uint32 {
+	return delta.NumUnchanged + delta.NumUnchangedAtEnd
+}
+
+// calculateRuleListDelta returns the delta that replaces the range of the old rules that differs
+// from the new rules.  The rules at the start must be identical; the rules at the end only need
+// to match apart from their IDs, which change whenever an earlier rule does.  If the rules differ
+// in several places, the range covers all of them.
+func calculateRuleListDelta(old, new []*proto.Rule) *proto.RuleListDelta {
+	n := 0
+	for n < len(old) && n < len(new) && pb.Equal(old[n], new[n]) {
+		n++
+	}
+	m := 0
+	for m < len(old)-n && m < len(new)-n &&
+		rulesEqualApartFromID(old[len(old)-1-m], new[len(new)-1-m]) {
+		m++
+	}
+	var ids []string
+	for _, r := range new[len(new)-m:] {
+		ids = append(ids, r.RuleId)
+	}
+	return &proto.RuleListDelta{
+		NumUnchanged:          uint32(n),
+		NewRules:              new[n : len(new)-m],
+		NumUnchangedAtEnd:     uint32(m),
+		UnchangedAtEndRuleIds: ids,
+	}
+}
+
+func rulesEqualApartFromID(a, b *proto.Rule) bool {
+	aWithBsID := *a
+	aWithBsID.RuleId = b.RuleId
+	return pb.Equal(&aWithBsID, b)
+}
+
+// ApplyPolicyDelta returns the result of applying a delta update to the previous version of the
+// policy.  It is intended for use by policy sync clients.
+func ApplyPolicyDelta(old *proto.Policy, delta *proto.ActivePolicyDeltaUpdate) (*proto.Policy, error) {
+	inbound, err := applyRuleListDelta(old.GetInboundRules(), delta.GetInboundRules())
+	if err != nil {
+		return nil, err
+	}
+	outbound, err := applyRuleListDelta(old.GetOutboundRules(), delta.GetOutboundRules())
+	if err != nil {
+		return nil, err
+	}
+	return &proto.Policy{
+		Namespace:     delta.GetNamespace(),
+		InboundRules:  inbound,
+		OutboundRules: outbound,
+		Untracked:     delta.GetUntracked(),
+		PreDnat:       delta.GetPreDnat(),
+	}, nil
+}
+
+func applyRuleListDelta(old []*proto.Rule, delta *proto.RuleListDelta) ([]*proto.Rule, error) {
+	n := int(delta.GetNumUnchanged())
+	m := int(delta.GetNumUnchangedAtEnd())
+	if n+m > len(old) {
+		return nil, fmt.Errorf("delta keeps %d rules but previous policy only has %d", n+m, len(old))
+	}
+	if len(delta.GetUnchangedAtEndRuleIds()) != m {
+		return nil, fmt.Errorf("delta keeps %d rules at the end but has %d IDs for them",
+			m, len(delta.GetUnchangedAtEndRuleIds()))
+	}
+	rules := make([]*proto.Rule, 0, n+len(delta.GetNewRules())+m)
+	rules = append(rules, old[:n]...)
+	rules = append(rules, delta.GetNewRules()...)
+	for i, r := range old[len(old)-m:] {
+		renamed := *r
+		renamed.RuleId = delta.GetUnchangedAtEndRuleIds()[i]
+		rules = append(rules, &renamed)
+	}
+	return rules, nil
+}
@@ -20,6 +20,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/tracing"
 )
 
 // MaxMembersPerMessage sets the limit on how many IP Set members to include in an outgoing gRPC message, which has a
@@ -39,14 +40,20 @@ type Processor struct {
 	serviceAccountByID map[proto.ServiceAccountID]*proto.ServiceAccountUpdate
 	namespaceByID      map[proto.NamespaceID]*proto.NamespaceUpdate
 	ipSetsByID         map[string]*ipSetInfo
-	receivedInSync     bool
+	// appProtocolsByWorkload holds the application protocol hints for each Kubernetes workload,
+	// by workload ID.
+	appProtocolsByWorkload map[string][]*proto.AppProtocolHint
+	receivedInSync         bool
 }
 
 type EndpointInfo struct {
 	// The channel to send updates for this workload to.
 	output         chan<- proto.ToDataplane
 	currentJoinUID uint64
+	apiVersion     uint32
 	endpointUpd    *proto.WorkloadEndpointUpdate
+	// endpointStatus is the most recent status reported by the dataplane for this endpoint.
+	endpointStatus *proto.WorkloadEndpointStatusUpdate
 	syncedPolicies map[proto.PolicyID]bool
 	syncedProfiles map[proto.ProfileID]bool
 	syncedIPSets   map[string]bool
@@ -62,6 +69,8 @@ type JoinMetadata struct {
 // it provides the channel used to send sync messages back to the server goroutine.
 type JoinRequest struct {
 	JoinMetadata
+	// APIVersion is the version of the policy sync API negotiated with the client.
+	APIVersion uint32
 	// C is the channel to send updates to the policy sync client.  Processor closes the channel when the
 	// workload endpoint is removed, or when a new JoinRequest is received for the same endpoint.  If nil, indicates
 	// the client wants to stop receiving updates.
@@ -72,6 +81,15 @@ type LeaveRequest struct {
 	JoinMetadata
 }
 
+// AppProtocolsUpdate is sent to the Processor (on its Updates channel) when the application
+// protocol hints for a Kubernetes workload change.  Hints replaces any previous hints for the
+// workload; an empty list removes them.
+type AppProtocolsUpdate struct {
+	// WorkloadID is the "<namespace>/<pod name>" ID of the workload.
+	WorkloadID string
+	Hints      []*proto.AppProtocolHint
+}
+
 func NewProcessor(updates <-chan interface{}) *Processor {
 	return &Processor{
 		// Updates from the calculation graph.
@@ -84,6 +102,8 @@ func NewProcessor(updates <-chan interface{}) *Processor {
 		serviceAccountByID: make(map[proto.ServiceAccountID]*proto.ServiceAccountUpdate),
 		namespaceByID:      make(map[proto.NamespaceID]*proto.NamespaceUpdate),
 		ipSetsByID:         make(map[string]*ipSetInfo),
+
+		appProtocolsByWorkload: make(map[string][]*proto.AppProtocolHint),
 	}
 }
 
@@ -129,12 +149,16 @@ func (p *Processor) handleJoin(joinReq JoinRequest) {
 	}
 
 	ei.currentJoinUID = joinReq.JoinUID
+	ei.apiVersion = joinReq.APIVersion
 	ei.output = joinReq.C
 	ei.syncedPolicies = map[proto.PolicyID]bool{}
 	ei.syncedProfiles = map[proto.ProfileID]bool{}
 	ei.syncedIPSets = map[string]bool{}
 
 	p.maybeSyncEndpoint(ei)
+	if ei.endpointUpd != nil {
+		p.maybeSendEndpointStatus(ei)
+	}
 
 	// Any updates to service accounts will be synced, but the endpoint needs to know about any existing service
 	// accounts that were updated before it joined.
@@ -207,6 +231,14 @@ func (p *Processor) handleDataplane(update interface{}) {
 		p.handleIPSetDeltaUpdate(update)
 	case *proto.IPSetRemove:
 		p.handleIPSetRemove(update)
+	case *proto.WorkloadEndpointStatusUpdate:
+		p.handleWorkloadEndpointStatusUpdate(update)
+	case *proto.WorkloadEndpointStatusRemove:
+		p.handleWorkloadEndpointStatusRemove(update)
+	case *AppProtocolsUpdate:
+		p.handleAppProtocolsUpdate(update)
+	case *tracing.BatchStart:
+		// Policy sync clients aren't part of the trace.
 	default:
 		log.WithFields(log.Fields{
 			"type": reflect.TypeOf(update),
@@ -239,10 +271,17 @@ func (p *Processor) handleWorkloadEndpointUpdate(update *proto.WorkloadEndpointU
 			syncedProfiles: map[proto.ProfileID]bool{},
 		}
 		p.endpointsByID[epID] = ei
-	} else {
-		ei.endpointUpd = update
+		p.maybeSyncEndpoint(ei)
+		return
 	}
+	firstUpdate := ei.endpointUpd == nil
+	ei.endpointUpd = update
 	p.maybeSyncEndpoint(ei)
+	if firstUpdate {
+		// We may already have the endpoint's status from the dataplane, send it now that the
+		// client knows about the endpoint.
+		p.maybeSendEndpointStatus(ei)
+	}
 }
 
 func (p *Processor) maybeSyncEndpoint(ei *EndpointInfo) {
@@ -262,12 +301,48 @@ func (p *Processor) maybeSyncEndpoint(ei *EndpointInfo) {
 	p.syncAddedPolicies(ei)
 	p.syncAddedProfiles(ei)
 	ei.output <- proto.ToDataplane{
-		Payload: &proto.ToDataplane_WorkloadEndpointUpdate{WorkloadEndpointUpdate: ei.endpointUpd}}
+		Payload: &proto.ToDataplane_WorkloadEndpointUpdate{WorkloadEndpointUpdate: p.endpointUpdateWithHints(ei)}}
 	p.syncRemovedPolicies(ei)
 	p.syncRemovedProfiles(ei)
 	doDel()
 }
 
+// endpointUpdateWithHints returns the endpoint's update with its application protocol hints, if
+// it has any.  The update from the calculation graph is shared with the dataplane so we add the
+// hints to a copy.
+func (p *Processor) endpointUpdateWithHints(ei *EndpointInfo) *proto.WorkloadEndpointUpdate {
+	id := ei.endpointUpd.GetId()
+	if id.GetOrchestratorId() != OrchestratorId {
+		return ei.endpointUpd
+	}
+	hints := p.appProtocolsByWorkload[id.GetWorkloadId()]
+	if len(hints) == 0 || ei.endpointUpd.Endpoint == nil {
+		return ei.endpointUpd
+	}
+	ep := *ei.endpointUpd.Endpoint
+	ep.AppProtocols = hints
+	return &proto.WorkloadEndpointUpdate{Id: ei.endpointUpd.Id, Endpoint: &ep}
+}
+
+func (p *Processor) handleAppProtocolsUpdate(update *AppProtocolsUpdate) {
+	log.WithFields(log.Fields{
+		"workload": update.WorkloadID,
+		"hints":    update.Hints,
+	}).Debug("Processing AppProtocolsUpdate")
+	if len(update.Hints) == 0 {
+		delete(p.appProtocolsByWorkload, update.WorkloadID)
+	} else {
+		p.appProtocolsByWorkload[update.WorkloadID] = update.Hints
+	}
+
+	// Resend the endpoint to any clients of the workload's endpoints.
+	for epID, ei := range p.endpointsByID {
+		if epID.OrchestratorId == OrchestratorId && epID.WorkloadId == update.WorkloadID {
+			p.maybeSyncEndpoint(ei)
+		}
+	}
+}
+
 func (p *Processor) handleWorkloadEndpointRemove(update *proto.WorkloadEndpointRemove) {
 	// we trust the Calc graph never to send us a remove for an endpoint it didn't tell us about
 	ei := p.endpointsByID[*update.Id]
@@ -279,6 +354,45 @@ func (p *Processor) handleWorkloadEndpointRemove(update *proto.WorkloadEndpointR
 	delete(p.endpointsByID, *update.Id)
 }
 
+// handleWorkloadEndpointStatusUpdate handles a status report from the dataplane.  These arrive
+// independently of the updates from the calculation graph so the status may be for an endpoint
+// that we haven't heard about yet (or that has just been removed).
+func (p *Processor) handleWorkloadEndpointStatusUpdate(update *proto.WorkloadEndpointStatusUpdate) {
+	epID := *update.Id
+	log.WithFields(log.Fields{"epID": epID, "status": update.Status}).Debug("Endpoint status update")
+	ei, ok := p.endpointsByID[epID]
+	if !ok {
+		ei = &EndpointInfo{}
+		p.endpointsByID[epID] = ei
+	}
+	ei.endpointStatus = update
+	if ei.endpointUpd != nil {
+		p.maybeSendEndpointStatus(ei)
+	}
+}
+
+func (p *Processor) handleWorkloadEndpointStatusRemove(update *proto.WorkloadEndpointStatusRemove) {
+	epID := *update.Id
+	ei, ok := p.endpointsByID[epID]
+	if !ok {
+		return
+	}
+	ei.endpointStatus = nil
+	if ei.output == nil && ei.currentJoinUID == 0 && ei.endpointUpd == nil {
+		delete(p.endpointsByID, epID)
+	}
+}
+
+// maybeSendEndpointStatus sends the endpoint's dataplane status to its client, if it has one
+// that supports it.
+func (p *Processor) maybeSendEndpointStatus(ei *EndpointInfo) {
+	if ei.output == nil || ei.apiVersion < APIVersionV3 || ei.endpointStatus == nil {
+		return
+	}
+	ei.output <- proto.ToDataplane{Payload: &proto.ToDataplane_WorkloadEndpointStatusUpdate{
+		WorkloadEndpointStatusUpdate: ei.endpointStatus}}
+}
+
 func (p *Processor) handleActiveProfileUpdate(update *proto.ActiveProfileUpdate) {
 	pId := *update.Id
 	profile := update.GetProfile()
@@ -314,6 +428,13 @@ func (p *Processor) handleActivePolicyUpdate(update *proto.ActivePolicyUpdate) {
 	pId := *update.Id
 	log.WithFields(log.Fields{"PolicyID": pId}).Debug("Processing ActivePolicyUpdate")
 	policy := update.GetPolicy()
+	var deltaMsg *proto.ToDataplane
+	if old, ok := p.policyByID[pId]; ok {
+		if delta := calculatePolicyDelta(pId, old.p, policy); delta != nil {
+			deltaMsg = &proto.ToDataplane{Payload: &proto.ToDataplane_ActivePolicyDeltaUpdate{
+				ActivePolicyDeltaUpdate: delta}}
+		}
+	}
 	p.policyByID[pId] = newPolicyInfo(policy)
 
 	// Update any endpoints that reference this policy
@@ -323,7 +444,11 @@ func (p *Processor) handleActivePolicyUpdate(update *proto.ActivePolicyUpdate) {
 			if other == pId {
 				doAdd, doDel := p.getIPSetsSync(ei)
 				doAdd()
-				ei.output <- proto.ToDataplane{Payload: &proto.ToDataplane_ActivePolicyUpdate{ActivePolicyUpdate: update}}
+				if deltaMsg != nil && ei.apiVersion >= APIVersionV2 && ei.syncedPolicies[pId] {
+					ei.output <- *deltaMsg
+				} else {
+					ei.output <- proto.ToDataplane{Payload: &proto.ToDataplane_ActivePolicyUpdate{ActivePolicyUpdate: update}}
+				}
 				ei.syncedPolicies[pId] = true
 				doDel()
 				return true
@@ -399,14 +524,23 @@ func (p *Processor) handleIPSetUpdate(update *proto.IPSetUpdate) {
 		return
 	}
 	logCxt.Info("Updating existing IPSet")
-	s.replaceMembers(update)
+	delta := s.replaceMembers(update)
 
-	// gRPC has limits on message size, so break up large update if necessary.
+	// gRPC has limits on message size, so break up large update if necessary.  Clients that
+	// support deltas and already have the IP set only need to hear about the changes.
 	updates := splitIPSetUpdate(update)
+	var deltaUpdates []proto.ToDataplane
+	if len(delta.AddedMembers)+len(delta.RemovedMembers) > 0 {
+		deltaUpdates = splitIPSetDeltaUpdate(delta)
+	}
 	for _, ei := range p.updateableEndpoints() {
 		if p.referencesIPSet(ei, id) {
+			toSend := updates
+			if ei.apiVersion >= APIVersionV2 && ei.syncedIPSets[id] {
+				toSend = deltaUpdates
+			}
 			ei.syncedIPSets[id] = true
-			for _, u := range updates {
+			for _, u := range toSend {
 				ei.output <- u
 			}
 		}
@@ -1256,6 +1256,319 @@ var _ = Describe("Processor", func() {
 			})
 		})
 
+		Describe("API v2 delta updates", func() {
+			var v1Output, v2Output chan proto.ToDataplane
+			var polUpd *proto.ActivePolicyUpdate
+			var ipSetUpd *proto.IPSetUpdate
+
+			allowRule := func(port int32) *proto.Rule {
+				return &proto.Rule{
+					Action:      "allow",
+					SrcIpSetIds: []string{IPSetName},
+					DstPorts:    []*proto.PortRange{{First: port, Last: port}},
+					RuleId:      fmt.Sprintf("rule-%d", port),
+				}
+			}
+
+			BeforeEach(func(done Done) {
+				v1Output = make(chan proto.ToDataplane, 100)
+				v2Output = make(chan proto.ToDataplane, 100)
+				uut.JoinUpdates <- policysync.JoinRequest{
+					JoinMetadata: policysync.JoinMetadata{EndpointID: testId("v1"), JoinUID: 1},
+					APIVersion:   policysync.APIVersionV1,
+					C:            v1Output,
+				}
+				uut.JoinUpdates <- policysync.JoinRequest{
+					JoinMetadata: policysync.JoinMetadata{EndpointID: testId("v2"), JoinUID: 2},
+					APIVersion:   policysync.APIVersionV2,
+					C:            v2Output,
+				}
+
+				ipSetUpd = &proto.IPSetUpdate{
+					Id:      IPSetName,
+					Type:    proto.IPSetUpdate_IP,
+					Members: []string{"10.0.0.1", "10.0.0.2"},
+				}
+				updates <- ipSetUpd
+				polUpd = &proto.ActivePolicyUpdate{
+					Id: &proto.PolicyID{Tier: TierName, Name: PolicyName},
+					Policy: &proto.Policy{
+						InboundRules:  []*proto.Rule{allowRule(80), allowRule(443)},
+						OutboundRules: []*proto.Rule{allowRule(53)},
+					},
+				}
+				updates <- polUpd
+				for _, w := range []string{"v1", "v2"} {
+					id := testId(w)
+					updates <- &proto.WorkloadEndpointUpdate{
+						Id: &id,
+						Endpoint: &proto.WorkloadEndpoint{Tiers: []*proto.TierInfo{
+							{Name: TierName, IngressPolicies: []string{PolicyName}},
+						}},
+					}
+				}
+				for _, output := range []chan proto.ToDataplane{v1Output, v2Output} {
+					g := <-output
+					Expect(g.GetIpsetUpdate().GetMembers()).To(ConsistOf("10.0.0.1", "10.0.0.2"))
+					g = <-output
+					Expect(&g).To(HavePayload(polUpd))
+					g = <-output
+					Expect(g.GetWorkloadEndpointUpdate()).NotTo(BeNil())
+				}
+				close(done)
+			})
+
+			It("should send IP set replacements as deltas to v2 clients", func(done Done) {
+				msg := &proto.IPSetUpdate{
+					Id:      IPSetName,
+					Type:    proto.IPSetUpdate_IP,
+					Members: []string{"10.0.0.2", "10.0.0.3"},
+				}
+				updates <- msg
+
+				g := <-v1Output
+				Expect(&g).To(HavePayload(msg))
+				g = <-v2Output
+				Expect(&g).To(HavePayload(&proto.IPSetDeltaUpdate{
+					Id:             IPSetName,
+					AddedMembers:   []string{"10.0.0.3"},
+					RemovedMembers: []string{"10.0.0.1"},
+				}))
+				close(done)
+			})
+
+			It("should send policy updates as deltas to v2 clients", func(done Done) {
+				newPolUpd := &proto.ActivePolicyUpdate{
+					Id: polUpd.Id,
+					Policy: &proto.Policy{
+						InboundRules:  []*proto.Rule{allowRule(80), allowRule(8443)},
+						OutboundRules: []*proto.Rule{allowRule(53)},
+					},
+				}
+				updates <- newPolUpd
+
+				g := <-v1Output
+				Expect(&g).To(HavePayload(newPolUpd))
+				g = <-v2Output
+				delta := g.GetActivePolicyDeltaUpdate()
+				Expect(delta).To(Equal(&proto.ActivePolicyDeltaUpdate{
+					Id:            polUpd.Id,
+					InboundRules:  &proto.RuleListDelta{NumUnchanged: 1, NewRules: []*proto.Rule{allowRule(8443)}},
+					OutboundRules: &proto.RuleListDelta{NumUnchanged: 1, NewRules: []*proto.Rule{}},
+				}))
+				Expect(policysync.ApplyPolicyDelta(polUpd.Policy, delta)).To(Equal(newPolUpd.Policy))
+				close(done)
+			})
+
+			It("should send a delta for rules inserted before the last rule", func(done Done) {
+				renumbered := allowRule(443)
+				renumbered.RuleId = "rule-443-renumbered"
+				newPolUpd := &proto.ActivePolicyUpdate{
+					Id: polUpd.Id,
+					Policy: &proto.Policy{
+						InboundRules:  []*proto.Rule{allowRule(80), allowRule(8080), renumbered},
+						OutboundRules: []*proto.Rule{allowRule(53)},
+					},
+				}
+				updates <- newPolUpd
+
+				g := <-v2Output
+				delta := g.GetActivePolicyDeltaUpdate()
+				Expect(delta.InboundRules).To(Equal(&proto.RuleListDelta{
+					NumUnchanged:          1,
+					NewRules:              []*proto.Rule{allowRule(8080)},
+					NumUnchangedAtEnd:     1,
+					UnchangedAtEndRuleIds: []string{"rule-443-renumbered"},
+				}))
+				Expect(policysync.ApplyPolicyDelta(polUpd.Policy, delta)).To(Equal(newPolUpd.Policy))
+				close(done)
+			})
+
+			It("should send a delta for a removed first rule", func(done Done) {
+				renumbered := allowRule(443)
+				renumbered.RuleId = "rule-443-renumbered"
+				newPolUpd := &proto.ActivePolicyUpdate{
+					Id: polUpd.Id,
+					Policy: &proto.Policy{
+						InboundRules:  []*proto.Rule{renumbered},
+						OutboundRules: []*proto.Rule{allowRule(53)},
+					},
+				}
+				updates <- newPolUpd
+
+				g := <-v2Output
+				delta := g.GetActivePolicyDeltaUpdate()
+				Expect(delta.InboundRules).To(Equal(&proto.RuleListDelta{
+					NewRules:              []*proto.Rule{},
+					NumUnchangedAtEnd:     1,
+					UnchangedAtEndRuleIds: []string{"rule-443-renumbered"},
+				}))
+				Expect(policysync.ApplyPolicyDelta(polUpd.Policy, delta)).To(Equal(newPolUpd.Policy))
+				close(done)
+			})
+
+			It("should send the whole policy if no rules can be reused", func(done Done) {
+				newPolUpd := &proto.ActivePolicyUpdate{
+					Id:     polUpd.Id,
+					Policy: &proto.Policy{InboundRules: []*proto.Rule{allowRule(22)}},
+				}
+				updates <- newPolUpd
+
+				g := <-v2Output
+				Expect(&g).To(HavePayload(newPolUpd))
+				close(done)
+			})
+
+			It("should reject deltas that don't match the previous policy", func() {
+				_, err := policysync.ApplyPolicyDelta(&proto.Policy{}, &proto.ActivePolicyDeltaUpdate{
+					InboundRules: &proto.RuleListDelta{NumUnchanged: 1},
+				})
+				Expect(err).To(HaveOccurred())
+				_, err = policysync.ApplyPolicyDelta(polUpd.Policy, &proto.ActivePolicyDeltaUpdate{
+					InboundRules: &proto.RuleListDelta{NumUnchangedAtEnd: 1},
+				})
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		Describe("API v3 endpoint status", func() {
+			var v2Output, v3Output chan proto.ToDataplane
+
+			statusUpd := func(w string, generation uint64) *proto.WorkloadEndpointStatusUpdate {
+				id := testId(w)
+				return &proto.WorkloadEndpointStatusUpdate{
+					Id:     &id,
+					Status: &proto.EndpointStatus{Status: "up", PolicyGeneration: generation},
+				}
+			}
+
+			BeforeEach(func(done Done) {
+				v2Output = make(chan proto.ToDataplane, 100)
+				v3Output = make(chan proto.ToDataplane, 100)
+				uut.JoinUpdates <- policysync.JoinRequest{
+					JoinMetadata: policysync.JoinMetadata{EndpointID: testId("v2"), JoinUID: 1},
+					APIVersion:   policysync.APIVersionV2,
+					C:            v2Output,
+				}
+				uut.JoinUpdates <- policysync.JoinRequest{
+					JoinMetadata: policysync.JoinMetadata{EndpointID: testId("v3"), JoinUID: 2},
+					APIVersion:   policysync.APIVersionV3,
+					C:            v3Output,
+				}
+				for _, w := range []string{"v2", "v3"} {
+					id := testId(w)
+					updates <- &proto.WorkloadEndpointUpdate{Id: &id, Endpoint: &proto.WorkloadEndpoint{}}
+				}
+				for _, output := range []chan proto.ToDataplane{v2Output, v3Output} {
+					g := <-output
+					Expect(g.GetWorkloadEndpointUpdate()).NotTo(BeNil())
+				}
+				close(done)
+			})
+
+			It("should only send endpoint status to v3 clients", func(done Done) {
+				updates <- statusUpd("v2", 1)
+				updates <- statusUpd("v3", 1)
+
+				g := <-v3Output
+				Expect(&g).To(HavePayload(statusUpd("v3", 1)))
+				Consistently(v2Output).ShouldNot(Receive())
+				close(done)
+			})
+
+			It("should send the latest status on join", func(done Done) {
+				updates <- statusUpd("v3", 1)
+				<-v3Output
+				updates <- statusUpd("v3", 2)
+				<-v3Output
+
+				newOutput := make(chan proto.ToDataplane, 100)
+				uut.JoinUpdates <- policysync.JoinRequest{
+					JoinMetadata: policysync.JoinMetadata{EndpointID: testId("v3"), JoinUID: 3},
+					APIVersion:   policysync.APIVersionV3,
+					C:            newOutput,
+				}
+				g := <-newOutput
+				Expect(g.GetWorkloadEndpointUpdate()).NotTo(BeNil())
+				g = <-newOutput
+				Expect(&g).To(HavePayload(statusUpd("v3", 2)))
+				close(done)
+			})
+
+			It("should hold status for endpoints that it hasn't heard about yet", func(done Done) {
+				updates <- statusUpd("new", 1)
+
+				output := make(chan proto.ToDataplane, 100)
+				uut.JoinUpdates <- policysync.JoinRequest{
+					JoinMetadata: policysync.JoinMetadata{EndpointID: testId("new"), JoinUID: 4},
+					APIVersion:   policysync.APIVersionV3,
+					C:            output,
+				}
+				Consistently(output).ShouldNot(Receive())
+
+				id := testId("new")
+				updates <- &proto.WorkloadEndpointUpdate{Id: &id, Endpoint: &proto.WorkloadEndpoint{}}
+				g := <-output
+				Expect(g.GetWorkloadEndpointUpdate()).NotTo(BeNil())
+				g = <-output
+				Expect(&g).To(HavePayload(statusUpd("new", 1)))
+				updates <- statusUpd("new", 2)
+				g = <-output
+				Expect(&g).To(HavePayload(statusUpd("new", 2)))
+				close(done)
+			})
+		})
+
+		Describe("application protocol hints", func() {
+			var output chan proto.ToDataplane
+			var wepUpd *proto.WorkloadEndpointUpdate
+			hint := &proto.AppProtocolHint{ServiceName: "web", Protocol: "TCP", Port: 8080, AppProtocol: "http"}
+
+			BeforeEach(func(done Done) {
+				output = make(chan proto.ToDataplane, 100)
+				uut.JoinUpdates <- policysync.JoinRequest{
+					JoinMetadata: policysync.JoinMetadata{EndpointID: testId("default/web"), JoinUID: 1},
+					APIVersion:   policysync.APIVersionV3,
+					C:            output,
+				}
+				id := testId("default/web")
+				wepUpd = &proto.WorkloadEndpointUpdate{Id: &id, Endpoint: &proto.WorkloadEndpoint{Name: "eth0"}}
+				updates <- wepUpd
+				g := <-output
+				Expect(g.GetWorkloadEndpointUpdate().GetEndpoint().GetAppProtocols()).To(BeEmpty())
+				close(done)
+			})
+
+			It("should resend the endpoint with its hints", func(done Done) {
+				updates <- &policysync.AppProtocolsUpdate{WorkloadID: "default/web", Hints: []*proto.AppProtocolHint{hint}}
+				g := <-output
+				Expect(g.GetWorkloadEndpointUpdate().GetEndpoint().GetAppProtocols()).To(Equal([]*proto.AppProtocolHint{hint}))
+				Expect(g.GetWorkloadEndpointUpdate().GetEndpoint().GetName()).To(Equal("eth0"))
+				// The calculation graph's update is shared with the dataplane so it mustn't change.
+				Expect(wepUpd.Endpoint.AppProtocols).To(BeNil())
+
+				updates <- &policysync.AppProtocolsUpdate{WorkloadID: "default/web"}
+				g = <-output
+				Expect(g.GetWorkloadEndpointUpdate().GetEndpoint().GetAppProtocols()).To(BeEmpty())
+				close(done)
+			})
+
+			It("should include the hints in later endpoint updates", func(done Done) {
+				updates <- &policysync.AppProtocolsUpdate{WorkloadID: "default/web", Hints: []*proto.AppProtocolHint{hint}}
+				<-output
+				updates <- wepUpd
+				g := <-output
+				Expect(g.GetWorkloadEndpointUpdate().GetEndpoint().GetAppProtocols()).To(Equal([]*proto.AppProtocolHint{hint}))
+				close(done)
+			})
+
+			It("should ignore hints for other workloads", func(done Done) {
+				updates <- &policysync.AppProtocolsUpdate{WorkloadID: "default/other", Hints: []*proto.AppProtocolHint{hint}}
+				Consistently(output).ShouldNot(Receive())
+				close(done)
+			})
+		})
+
 		Describe("join / leave processing", func() {
 
 			Context("with WEP before any join", func() {
@@ -16,8 +16,14 @@ package policysync
 
 import (
 	"errors"
+	"strconv"
+	"strings"
 	"sync"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
 	log "github.com/sirupsen/logrus"
 
 	"github.com/projectcalico/felix/proto"
@@ -33,12 +39,31 @@ const (
 )
 const OutputQueueLen = 100
 
+const (
+	// APIVersionV1 clients are sent the complete IP set or policy whenever one changes.
+	APIVersionV1 = 1
+	// APIVersionV2 clients are sent deltas for the IP sets and policies that they already have.
+	APIVersionV2 = 2
+	// APIVersionV3 clients are also sent the dataplane status of their endpoint.
+	APIVersionV3 = 3
+
+	MaxAPIVersion = APIVersionV3
+
+	// APIVersionHeader is the gRPC response header that tells the client which API version the
+	// server chose.  Servers that predate versioning don't send it, which means version 1.
+	APIVersionHeader = "policysync-api-version"
+)
+
 // Server implements the API that each policy-sync agent connects to in order to get policy information.
 // There is a single instance of the Server, it disambiguates connections from different clients by the
 // credentials present in the gRPC request.
 type Server struct {
 	JoinUpdates chan<- interface{}
 	nextJoinUID func() uint64
+
+	// allowedServiceAccounts, if non-empty, restricts the API to workloads that run as one of the
+	// listed service accounts.
+	allowedServiceAccounts []string
 }
 
 func NewServer(joins chan<- interface{}, allocUID func() uint64) *Server {
@@ -48,12 +73,19 @@ func NewServer(joins chan<- interface{}, allocUID func() uint64) *Server {
 	}
 }
 
+// SetAllowedServiceAccounts restricts the API to workloads whose service account matches one of
+// the given "<namespace>/<name>" patterns, where "*" matches any namespace or name.  An empty list
+// allows all workloads.
+func (s *Server) SetAllowedServiceAccounts(allowed []string) {
+	s.allowedServiceAccounts = allowed
+}
+
 func (s *Server) RegisterGrpc(g *grpc.Server) {
 	log.Debug("Registering with grpc.Server")
 	proto.RegisterPolicySyncServer(g, s)
 }
 
-func (s *Server) Sync(_ *proto.SyncRequest, stream proto.PolicySync_SyncServer) error {
+func (s *Server) Sync(req *proto.SyncRequest, stream proto.PolicySync_SyncServer) error {
 	log.Info("New policy sync connection")
 
 	// Extract the workload ID from the request.
@@ -63,17 +95,33 @@ func (s *Server) Sync(_ *proto.SyncRequest, stream proto.PolicySync_SyncServer)
 		return errors.New("unable to authenticate client")
 	}
 	workloadID := creds.Namespace + "/" + creds.Workload
+	if !serviceAccountAllowed(s.allowedServiceAccounts, creds.Namespace, creds.ServiceAccount) {
+		log.WithFields(log.Fields{
+			"workload":       workloadID,
+			"serviceAccount": creds.ServiceAccount,
+		}).Warn("Rejecting policy sync connection from workload with unauthorized service account")
+		return status.Error(codes.PermissionDenied, "service account not authorized to use the policy sync API")
+	}
 
 	// Allocate a new unique join ID, this allows the processor to disambiguate if there are multiple connections
 	// for the same workload, which can happen transiently over client restart.  In particular, if our "leave"
 	// request races with the "join" request of the new connection.
 	myJoinUID := s.nextJoinUID()
+	apiVersion := negotiateAPIVersion(req.GetApiVersion())
 	logCxt := log.WithFields(log.Fields{
-		"workload": workloadID,
-		"joinID":   myJoinUID,
+		"workload":   workloadID,
+		"joinID":     myJoinUID,
+		"apiVersion": apiVersion,
 	})
 	logCxt.Info("New policy sync connection identified")
 
+	// Tell the client which version we chose so that it knows which messages to expect.
+	err := stream.SendHeader(metadata.Pairs(APIVersionHeader, strconv.FormatUint(uint64(apiVersion), 10)))
+	if err != nil {
+		logCxt.WithError(err).Warn("Failed to send API version to policy sync client")
+		return err
+	}
+
 	// Send a join request to the processor to ask it to start sending us updates.
 	updates := make(chan proto.ToDataplane, OutputQueueLen)
 	epID := proto.WorkloadEndpointID{
@@ -87,6 +135,7 @@ func (s *Server) Sync(_ *proto.SyncRequest, stream proto.PolicySync_SyncServer)
 	}
 	s.JoinUpdates <- JoinRequest{
 		JoinMetadata: joinMeta,
+		APIVersion:   apiVersion,
 		C:            updates,
 	}
 
@@ -127,6 +176,39 @@ func (s *Server) Sync(_ *proto.SyncRequest, stream proto.PolicySync_SyncServer)
 	return nil
 }
 
+// negotiateAPIVersion returns the API version to use with a client that supports up to the given
+// version.  Clients that predate versioning don't set the field at all.
+func negotiateAPIVersion(clientVersion uint32) uint32 {
+	if clientVersion < APIVersionV1 {
+		return APIVersionV1
+	}
+	if clientVersion > MaxAPIVersion {
+		return MaxAPIVersion
+	}
+	return clientVersion
+}
+
+// serviceAccountAllowed returns true if the given service account matches one of the
+// "<namespace>/<name>" patterns in the allowlist, or if the allowlist is empty.
+//
+// The workload's identity comes from the credentials that the flexvolume driver records when it
+// mounts the per-pod policy sync socket, so it can't be spoofed by a process in another pod.
+func serviceAccountAllowed(allowed []string, namespace, name string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, pattern := range allowed {
+		parts := strings.SplitN(pattern, "/", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if (parts[0] == "*" || parts[0] == namespace) && (parts[1] == "*" || parts[1] == name) {
+			return true
+		}
+	}
+	return false
+}
+
 type UIDAllocator struct {
 	l       sync.Mutex
 	nextUID uint64
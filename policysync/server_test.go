@@ -25,8 +25,10 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 var _ = Describe("Server", func() {
@@ -38,6 +40,75 @@ var _ = Describe("Server", func() {
 		uut = policysync.NewServer(joins, policysync.NewUIDAllocator().NextUID)
 	})
 
+	Describe("API version negotiation", func() {
+		var stream *testSyncStream
+
+		negotiate := func(clientVersion uint32) uint32 {
+			stream = &testSyncStream{output: make(chan *proto.ToDataplane)}
+			syncDone := make(chan bool)
+			go func() {
+				_ = uut.Sync(&proto.SyncRequest{ApiVersion: clientVersion}, stream)
+				syncDone <- true
+			}()
+			jr := (<-joins).(policysync.JoinRequest)
+			close(jr.C)
+			Expect((<-joins).(policysync.LeaveRequest).JoinUID).To(Equal(jr.JoinUID))
+			<-syncDone
+			return jr.APIVersion
+		}
+
+		It("should use v1 for clients that don't specify a version", func() {
+			Expect(negotiate(0)).To(BeNumerically("==", policysync.APIVersionV1))
+		})
+
+		It("should use the client's version if supported", func() {
+			Expect(negotiate(2)).To(BeNumerically("==", policysync.APIVersionV2))
+		})
+
+		It("should use the highest supported version for newer clients", func() {
+			Expect(negotiate(99)).To(BeNumerically("==", policysync.MaxAPIVersion))
+		})
+
+		It("should tell the client which version was chosen", func() {
+			negotiate(2)
+			Expect(stream.header.Get(policysync.APIVersionHeader)).To(Equal([]string{"2"}))
+		})
+	})
+
+	Describe("service account allowlist", func() {
+		It("should reject workloads with service accounts that aren't allowed", func() {
+			uut.SetAllowedServiceAccounts([]string{"istio-system/*", "*/dikastes"})
+			err := uut.Sync(&proto.SyncRequest{}, &testSyncStream{})
+			Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+			Consistently(joins).ShouldNot(Receive())
+		})
+
+		It("should accept workloads with allowed service accounts", func() {
+			uut.SetAllowedServiceAccounts([]string{"istio-system/*", "default/default"})
+			stream := &testSyncStream{output: make(chan *proto.ToDataplane)}
+			syncDone := make(chan error)
+			go func() {
+				syncDone <- uut.Sync(&proto.SyncRequest{}, stream)
+			}()
+			jr := (<-joins).(policysync.JoinRequest)
+			Expect(jr.EndpointID.GetWorkloadId()).To(Equal(WorkloadID))
+			close(jr.C)
+			Expect((<-joins).(policysync.LeaveRequest).JoinUID).To(Equal(jr.JoinUID))
+			Expect(<-syncDone).NotTo(HaveOccurred())
+		})
+
+		It("should match wildcard namespaces", func() {
+			uut.SetAllowedServiceAccounts([]string{"*/default"})
+			stream := &testSyncStream{output: make(chan *proto.ToDataplane)}
+			go func() {
+				_ = uut.Sync(&proto.SyncRequest{}, stream)
+			}()
+			jr := (<-joins).(policysync.JoinRequest)
+			close(jr.C)
+			Expect((<-joins).(policysync.LeaveRequest).JoinUID).To(Equal(jr.JoinUID))
+		})
+	})
+
 	Describe("Sync tests", func() {
 
 		Context("after calling Sync and joining", func() {
@@ -128,6 +199,7 @@ var _ = Describe("Server", func() {
 type testSyncStream struct {
 	output  chan<- *proto.ToDataplane
 	sendErr bool
+	header  metadata.MD
 }
 
 func (s *testSyncStream) Send(m *proto.ToDataplane) error {
@@ -142,8 +214,9 @@ func (*testSyncStream) SetHeader(metadata.MD) error {
 	panic("not implemented")
 }
 
-func (*testSyncStream) SendHeader(metadata.MD) error {
-	panic("not implemented")
+func (s *testSyncStream) SendHeader(md metadata.MD) error {
+	s.header = md
+	return nil
 }
 
 func (*testSyncStream) SetTrailer(metadata.MD) {
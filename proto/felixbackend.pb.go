@@ -11,6 +11,7 @@
 		SyncRequest
 		ToDataplane
 		FromDataplane
+		DataplaneCapabilities
 		ConfigUpdate
 		InSync
 		IPSetUpdate
@@ -22,6 +23,8 @@
 		Profile
 		ActivePolicyUpdate
 		ActivePolicyRemove
+		ActivePolicyDeltaUpdate
+		RuleListDelta
 		PolicyID
 		Policy
 		Rule
@@ -67,6 +70,7 @@
 		WireguardEndpointUpdate
 		WireguardEndpointRemove
 		GlobalBGPConfigUpdate
+		AppProtocolHint
 */
 package proto
 
@@ -209,10 +213,14 @@ func (x IPSetUpdate_IPSetType) String() string {
 	return proto1.EnumName(IPSetUpdate_IPSetType_name, int32(x))
 }
 func (IPSetUpdate_IPSetType) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptorFelixbackend, []int{5, 0}
+	return fileDescriptorFelixbackend, []int{6, 0}
 }
 
 type SyncRequest struct {
+	// The highest version of the policy sync API that the client supports.  Unset
+	// means version 1.  Older servers ignore this field and only send version 1
+	// messages so clients must handle those too.
+	ApiVersion uint32 `protobuf:"varint,1,opt,name=api_version,json=apiVersion,proto3" json:"api_version,omitempty"`
 }
 
 func (m *SyncRequest) Reset()                    { *m = SyncRequest{} }
@@ -220,6 +228,13 @@ func (m *SyncRequest) String() string            { return proto1.CompactTextStri
 func (*SyncRequest) ProtoMessage()               {}
 func (*SyncRequest) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{0} }
 
+func (m *SyncRequest) GetApiVersion() uint32 {
+	if m != nil {
+		return m.ApiVersion
+	}
+	return 0
+}
+
 type ToDataplane struct {
 	// Sequence number incremented with each message.  Useful for correlating
 	// messages in logs.
@@ -253,6 +268,8 @@ type ToDataplane struct {
 	//	*ToDataplane_WireguardEndpointUpdate
 	//	*ToDataplane_WireguardEndpointRemove
 	//	*ToDataplane_GlobalBgpConfigUpdate
+	//	*ToDataplane_ActivePolicyDeltaUpdate
+	//	*ToDataplane_WorkloadEndpointStatusUpdate
 	Payload isToDataplane_Payload `protobuf_oneof:"payload"`
 }
 
@@ -351,35 +368,43 @@ type ToDataplane_WireguardEndpointRemove struct {
 type ToDataplane_GlobalBgpConfigUpdate struct {
 	GlobalBgpConfigUpdate *GlobalBGPConfigUpdate `protobuf:"bytes,29,opt,name=global_bgp_config_update,json=globalBgpConfigUpdate,oneof"`
 }
-
-func (*ToDataplane_InSync) isToDataplane_Payload()                  {}
-func (*ToDataplane_IpsetUpdate) isToDataplane_Payload()             {}
-func (*ToDataplane_IpsetDeltaUpdate) isToDataplane_Payload()        {}
-func (*ToDataplane_IpsetRemove) isToDataplane_Payload()             {}
-func (*ToDataplane_ActiveProfileUpdate) isToDataplane_Payload()     {}
-func (*ToDataplane_ActiveProfileRemove) isToDataplane_Payload()     {}
-func (*ToDataplane_ActivePolicyUpdate) isToDataplane_Payload()      {}
-func (*ToDataplane_ActivePolicyRemove) isToDataplane_Payload()      {}
-func (*ToDataplane_HostEndpointUpdate) isToDataplane_Payload()      {}
-func (*ToDataplane_HostEndpointRemove) isToDataplane_Payload()      {}
-func (*ToDataplane_WorkloadEndpointUpdate) isToDataplane_Payload()  {}
-func (*ToDataplane_WorkloadEndpointRemove) isToDataplane_Payload()  {}
-func (*ToDataplane_ConfigUpdate) isToDataplane_Payload()            {}
-func (*ToDataplane_HostMetadataUpdate) isToDataplane_Payload()      {}
-func (*ToDataplane_HostMetadataRemove) isToDataplane_Payload()      {}
-func (*ToDataplane_IpamPoolUpdate) isToDataplane_Payload()          {}
-func (*ToDataplane_IpamPoolRemove) isToDataplane_Payload()          {}
-func (*ToDataplane_ServiceAccountUpdate) isToDataplane_Payload()    {}
-func (*ToDataplane_ServiceAccountRemove) isToDataplane_Payload()    {}
-func (*ToDataplane_NamespaceUpdate) isToDataplane_Payload()         {}
-func (*ToDataplane_NamespaceRemove) isToDataplane_Payload()         {}
-func (*ToDataplane_RouteUpdate) isToDataplane_Payload()             {}
-func (*ToDataplane_RouteRemove) isToDataplane_Payload()             {}
-func (*ToDataplane_VtepUpdate) isToDataplane_Payload()              {}
-func (*ToDataplane_VtepRemove) isToDataplane_Payload()              {}
-func (*ToDataplane_WireguardEndpointUpdate) isToDataplane_Payload() {}
-func (*ToDataplane_WireguardEndpointRemove) isToDataplane_Payload() {}
-func (*ToDataplane_GlobalBgpConfigUpdate) isToDataplane_Payload()   {}
+type ToDataplane_ActivePolicyDeltaUpdate struct {
+	ActivePolicyDeltaUpdate *ActivePolicyDeltaUpdate `protobuf:"bytes,30,opt,name=active_policy_delta_update,json=activePolicyDeltaUpdate,oneof"`
+}
+type ToDataplane_WorkloadEndpointStatusUpdate struct {
+	WorkloadEndpointStatusUpdate *WorkloadEndpointStatusUpdate `protobuf:"bytes,31,opt,name=workload_endpoint_status_update,json=workloadEndpointStatusUpdate,oneof"`
+}
+
+func (*ToDataplane_InSync) isToDataplane_Payload()                       {}
+func (*ToDataplane_IpsetUpdate) isToDataplane_Payload()                  {}
+func (*ToDataplane_IpsetDeltaUpdate) isToDataplane_Payload()             {}
+func (*ToDataplane_IpsetRemove) isToDataplane_Payload()                  {}
+func (*ToDataplane_ActiveProfileUpdate) isToDataplane_Payload()          {}
+func (*ToDataplane_ActiveProfileRemove) isToDataplane_Payload()          {}
+func (*ToDataplane_ActivePolicyUpdate) isToDataplane_Payload()           {}
+func (*ToDataplane_ActivePolicyRemove) isToDataplane_Payload()           {}
+func (*ToDataplane_HostEndpointUpdate) isToDataplane_Payload()           {}
+func (*ToDataplane_HostEndpointRemove) isToDataplane_Payload()           {}
+func (*ToDataplane_WorkloadEndpointUpdate) isToDataplane_Payload()       {}
+func (*ToDataplane_WorkloadEndpointRemove) isToDataplane_Payload()       {}
+func (*ToDataplane_ConfigUpdate) isToDataplane_Payload()                 {}
+func (*ToDataplane_HostMetadataUpdate) isToDataplane_Payload()           {}
+func (*ToDataplane_HostMetadataRemove) isToDataplane_Payload()           {}
+func (*ToDataplane_IpamPoolUpdate) isToDataplane_Payload()               {}
+func (*ToDataplane_IpamPoolRemove) isToDataplane_Payload()               {}
+func (*ToDataplane_ServiceAccountUpdate) isToDataplane_Payload()         {}
+func (*ToDataplane_ServiceAccountRemove) isToDataplane_Payload()         {}
+func (*ToDataplane_NamespaceUpdate) isToDataplane_Payload()              {}
+func (*ToDataplane_NamespaceRemove) isToDataplane_Payload()              {}
+func (*ToDataplane_RouteUpdate) isToDataplane_Payload()                  {}
+func (*ToDataplane_RouteRemove) isToDataplane_Payload()                  {}
+func (*ToDataplane_VtepUpdate) isToDataplane_Payload()                   {}
+func (*ToDataplane_VtepRemove) isToDataplane_Payload()                   {}
+func (*ToDataplane_WireguardEndpointUpdate) isToDataplane_Payload()      {}
+func (*ToDataplane_WireguardEndpointRemove) isToDataplane_Payload()      {}
+func (*ToDataplane_GlobalBgpConfigUpdate) isToDataplane_Payload()        {}
+func (*ToDataplane_ActivePolicyDeltaUpdate) isToDataplane_Payload()      {}
+func (*ToDataplane_WorkloadEndpointStatusUpdate) isToDataplane_Payload() {}
 
 func (m *ToDataplane) GetPayload() isToDataplane_Payload {
 	if m != nil {
@@ -591,6 +616,20 @@ func (m *ToDataplane) GetGlobalBgpConfigUpdate() *GlobalBGPConfigUpdate {
 	return nil
 }
 
+func (m *ToDataplane) GetActivePolicyDeltaUpdate() *ActivePolicyDeltaUpdate {
+	if x, ok := m.GetPayload().(*ToDataplane_ActivePolicyDeltaUpdate); ok {
+		return x.ActivePolicyDeltaUpdate
+	}
+	return nil
+}
+
+func (m *ToDataplane) GetWorkloadEndpointStatusUpdate() *WorkloadEndpointStatusUpdate {
+	if x, ok := m.GetPayload().(*ToDataplane_WorkloadEndpointStatusUpdate); ok {
+		return x.WorkloadEndpointStatusUpdate
+	}
+	return nil
+}
+
 // XXX_OneofFuncs is for the internal use of the proto package.
 func (*ToDataplane) XXX_OneofFuncs() (func(msg proto1.Message, b *proto1.Buffer) error, func(msg proto1.Message, tag, wire int, b *proto1.Buffer) (bool, error), func(msg proto1.Message) (n int), []interface{}) {
 	return _ToDataplane_OneofMarshaler, _ToDataplane_OneofUnmarshaler, _ToDataplane_OneofSizer, []interface{}{
@@ -622,6 +661,8 @@ func (*ToDataplane) XXX_OneofFuncs() (func(msg proto1.Message, b *proto1.Buffer)
 		(*ToDataplane_WireguardEndpointUpdate)(nil),
 		(*ToDataplane_WireguardEndpointRemove)(nil),
 		(*ToDataplane_GlobalBgpConfigUpdate)(nil),
+		(*ToDataplane_ActivePolicyDeltaUpdate)(nil),
+		(*ToDataplane_WorkloadEndpointStatusUpdate)(nil),
 	}
 }
 
@@ -769,6 +810,16 @@ func _ToDataplane_OneofMarshaler(msg proto1.Message, b *proto1.Buffer) error {
 		if err := b.EncodeMessage(x.GlobalBgpConfigUpdate); err != nil {
 			return err
 		}
+	case *ToDataplane_ActivePolicyDeltaUpdate:
+		_ = b.EncodeVarint(30<<3 | proto1.WireBytes)
+		if err := b.EncodeMessage(x.ActivePolicyDeltaUpdate); err != nil {
+			return err
+		}
+	case *ToDataplane_WorkloadEndpointStatusUpdate:
+		_ = b.EncodeVarint(31<<3 | proto1.WireBytes)
+		if err := b.EncodeMessage(x.WorkloadEndpointStatusUpdate); err != nil {
+			return err
+		}
 	case nil:
 	default:
 		return fmt.Errorf("ToDataplane.Payload has unexpected type %T", x)
@@ -1003,6 +1054,22 @@ func _ToDataplane_OneofUnmarshaler(msg proto1.Message, tag, wire int, b *proto1.
 		err := b.DecodeMessage(msg)
 		m.Payload = &ToDataplane_GlobalBgpConfigUpdate{msg}
 		return true, err
+	case 30: // payload.active_policy_delta_update
+		if wire != proto1.WireBytes {
+			return true, proto1.ErrInternalBadWireType
+		}
+		msg := new(ActivePolicyDeltaUpdate)
+		err := b.DecodeMessage(msg)
+		m.Payload = &ToDataplane_ActivePolicyDeltaUpdate{msg}
+		return true, err
+	case 31: // payload.workload_endpoint_status_update
+		if wire != proto1.WireBytes {
+			return true, proto1.ErrInternalBadWireType
+		}
+		msg := new(WorkloadEndpointStatusUpdate)
+		err := b.DecodeMessage(msg)
+		m.Payload = &ToDataplane_WorkloadEndpointStatusUpdate{msg}
+		return true, err
 	default:
 		return false, nil
 	}
@@ -1152,6 +1219,16 @@ func _ToDataplane_OneofSizer(msg proto1.Message) (n int) {
 		n += proto1.SizeVarint(29<<3 | proto1.WireBytes)
 		n += proto1.SizeVarint(uint64(s))
 		n += s
+	case *ToDataplane_ActivePolicyDeltaUpdate:
+		s := proto1.Size(x.ActivePolicyDeltaUpdate)
+		n += proto1.SizeVarint(30<<3 | proto1.WireBytes)
+		n += proto1.SizeVarint(uint64(s))
+		n += s
+	case *ToDataplane_WorkloadEndpointStatusUpdate:
+		s := proto1.Size(x.WorkloadEndpointStatusUpdate)
+		n += proto1.SizeVarint(31<<3 | proto1.WireBytes)
+		n += proto1.SizeVarint(uint64(s))
+		n += s
 	case nil:
 	default:
 		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
@@ -1168,6 +1245,7 @@ type FromDataplane struct {
 	//	*FromDataplane_WorkloadEndpointStatusUpdate
 	//	*FromDataplane_WorkloadEndpointStatusRemove
 	//	*FromDataplane_WireguardStatusUpdate
+	//	*FromDataplane_DataplaneCapabilities
 	Payload isFromDataplane_Payload `protobuf_oneof:"payload"`
 }
 
@@ -1200,6 +1278,9 @@ type FromDataplane_WorkloadEndpointStatusRemove struct {
 type FromDataplane_WireguardStatusUpdate struct {
 	WireguardStatusUpdate *WireguardStatusUpdate `protobuf:"bytes,9,opt,name=wireguard_status_update,json=wireguardStatusUpdate,oneof"`
 }
+type FromDataplane_DataplaneCapabilities struct {
+	DataplaneCapabilities *DataplaneCapabilities `protobuf:"bytes,10,opt,name=dataplane_capabilities,json=dataplaneCapabilities,oneof"`
+}
 
 func (*FromDataplane_ProcessStatusUpdate) isFromDataplane_Payload()          {}
 func (*FromDataplane_HostEndpointStatusUpdate) isFromDataplane_Payload()     {}
@@ -1207,6 +1288,7 @@ func (*FromDataplane_HostEndpointStatusRemove) isFromDataplane_Payload()     {}
 func (*FromDataplane_WorkloadEndpointStatusUpdate) isFromDataplane_Payload() {}
 func (*FromDataplane_WorkloadEndpointStatusRemove) isFromDataplane_Payload() {}
 func (*FromDataplane_WireguardStatusUpdate) isFromDataplane_Payload()        {}
+func (*FromDataplane_DataplaneCapabilities) isFromDataplane_Payload()        {}
 
 func (m *FromDataplane) GetPayload() isFromDataplane_Payload {
 	if m != nil {
@@ -1264,6 +1346,13 @@ func (m *FromDataplane) GetWireguardStatusUpdate() *WireguardStatusUpdate {
 	return nil
 }
 
+func (m *FromDataplane) GetDataplaneCapabilities() *DataplaneCapabilities {
+	if x, ok := m.GetPayload().(*FromDataplane_DataplaneCapabilities); ok {
+		return x.DataplaneCapabilities
+	}
+	return nil
+}
+
 // XXX_OneofFuncs is for the internal use of the proto package.
 func (*FromDataplane) XXX_OneofFuncs() (func(msg proto1.Message, b *proto1.Buffer) error, func(msg proto1.Message, tag, wire int, b *proto1.Buffer) (bool, error), func(msg proto1.Message) (n int), []interface{}) {
 	return _FromDataplane_OneofMarshaler, _FromDataplane_OneofUnmarshaler, _FromDataplane_OneofSizer, []interface{}{
@@ -1273,6 +1362,7 @@ func (*FromDataplane) XXX_OneofFuncs() (func(msg proto1.Message, b *proto1.Buffe
 		(*FromDataplane_WorkloadEndpointStatusUpdate)(nil),
 		(*FromDataplane_WorkloadEndpointStatusRemove)(nil),
 		(*FromDataplane_WireguardStatusUpdate)(nil),
+		(*FromDataplane_DataplaneCapabilities)(nil),
 	}
 }
 
@@ -1310,6 +1400,11 @@ func _FromDataplane_OneofMarshaler(msg proto1.Message, b *proto1.Buffer) error {
 		if err := b.EncodeMessage(x.WireguardStatusUpdate); err != nil {
 			return err
 		}
+	case *FromDataplane_DataplaneCapabilities:
+		_ = b.EncodeVarint(10<<3 | proto1.WireBytes)
+		if err := b.EncodeMessage(x.DataplaneCapabilities); err != nil {
+			return err
+		}
 	case nil:
 	default:
 		return fmt.Errorf("FromDataplane.Payload has unexpected type %T", x)
@@ -1368,6 +1463,14 @@ func _FromDataplane_OneofUnmarshaler(msg proto1.Message, tag, wire int, b *proto
 		err := b.DecodeMessage(msg)
 		m.Payload = &FromDataplane_WireguardStatusUpdate{msg}
 		return true, err
+	case 10: // payload.dataplane_capabilities
+		if wire != proto1.WireBytes {
+			return true, proto1.ErrInternalBadWireType
+		}
+		msg := new(DataplaneCapabilities)
+		err := b.DecodeMessage(msg)
+		m.Payload = &FromDataplane_DataplaneCapabilities{msg}
+		return true, err
 	default:
 		return false, nil
 	}
@@ -1407,6 +1510,11 @@ func _FromDataplane_OneofSizer(msg proto1.Message) (n int) {
 		n += proto1.SizeVarint(9<<3 | proto1.WireBytes)
 		n += proto1.SizeVarint(uint64(s))
 		n += s
+	case *FromDataplane_DataplaneCapabilities:
+		s := proto1.Size(x.DataplaneCapabilities)
+		n += proto1.SizeVarint(10<<3 | proto1.WireBytes)
+		n += proto1.SizeVarint(uint64(s))
+		n += s
 	case nil:
 	default:
 		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
@@ -1414,6 +1522,53 @@ func _FromDataplane_OneofSizer(msg proto1.Message) (n int) {
 	return n
 }
 
+// DataplaneCapabilities advertises the optional features that the dataplane
+// driver supports.  Felix refuses to start if a feature that is enabled in its
+// configuration isn't supported, and it doesn't send the driver messages that
+// relate to unsupported features.  Drivers that don't send this message are
+// assumed to support everything.
+type DataplaneCapabilities struct {
+	Ipv6                   bool `protobuf:"varint,1,opt,name=ipv6,proto3" json:"ipv6,omitempty"`
+	Vxlan                  bool `protobuf:"varint,2,opt,name=vxlan,proto3" json:"vxlan,omitempty"`
+	Wireguard              bool `protobuf:"varint,3,opt,name=wireguard,proto3" json:"wireguard,omitempty"`
+	ApplicationLayerPolicy bool `protobuf:"varint,4,opt,name=application_layer_policy,json=applicationLayerPolicy,proto3" json:"application_layer_policy,omitempty"`
+}
+
+func (m *DataplaneCapabilities) Reset()         { *m = DataplaneCapabilities{} }
+func (m *DataplaneCapabilities) String() string { return proto1.CompactTextString(m) }
+func (*DataplaneCapabilities) ProtoMessage()    {}
+func (*DataplaneCapabilities) Descriptor() ([]byte, []int) {
+	return fileDescriptorFelixbackend, []int{3}
+}
+
+func (m *DataplaneCapabilities) GetIpv6() bool {
+	if m != nil {
+		return m.Ipv6
+	}
+	return false
+}
+
+func (m *DataplaneCapabilities) GetVxlan() bool {
+	if m != nil {
+		return m.Vxlan
+	}
+	return false
+}
+
+func (m *DataplaneCapabilities) GetWireguard() bool {
+	if m != nil {
+		return m.Wireguard
+	}
+	return false
+}
+
+func (m *DataplaneCapabilities) GetApplicationLayerPolicy() bool {
+	if m != nil {
+		return m.ApplicationLayerPolicy
+	}
+	return false
+}
+
 type ConfigUpdate struct {
 	Config map[string]string `protobuf:"bytes,1,rep,name=config" json:"config,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
 }
@@ -1421,7 +1576,7 @@ type ConfigUpdate struct {
 func (m *ConfigUpdate) Reset()                    { *m = ConfigUpdate{} }
 func (m *ConfigUpdate) String() string            { return proto1.CompactTextString(m) }
 func (*ConfigUpdate) ProtoMessage()               {}
-func (*ConfigUpdate) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{3} }
+func (*ConfigUpdate) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{4} }
 
 func (m *ConfigUpdate) GetConfig() map[string]string {
 	if m != nil {
@@ -1436,7 +1591,7 @@ type InSync struct {
 func (m *InSync) Reset()                    { *m = InSync{} }
 func (m *InSync) String() string            { return proto1.CompactTextString(m) }
 func (*InSync) ProtoMessage()               {}
-func (*InSync) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{4} }
+func (*InSync) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{5} }
 
 type IPSetUpdate struct {
 	Id      string                `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
@@ -1447,7 +1602,7 @@ type IPSetUpdate struct {
 func (m *IPSetUpdate) Reset()                    { *m = IPSetUpdate{} }
 func (m *IPSetUpdate) String() string            { return proto1.CompactTextString(m) }
 func (*IPSetUpdate) ProtoMessage()               {}
-func (*IPSetUpdate) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{5} }
+func (*IPSetUpdate) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{6} }
 
 func (m *IPSetUpdate) GetId() string {
 	if m != nil {
@@ -1479,7 +1634,7 @@ type IPSetDeltaUpdate struct {
 func (m *IPSetDeltaUpdate) Reset()                    { *m = IPSetDeltaUpdate{} }
 func (m *IPSetDeltaUpdate) String() string            { return proto1.CompactTextString(m) }
 func (*IPSetDeltaUpdate) ProtoMessage()               {}
-func (*IPSetDeltaUpdate) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{6} }
+func (*IPSetDeltaUpdate) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{7} }
 
 func (m *IPSetDeltaUpdate) GetId() string {
 	if m != nil {
@@ -1509,7 +1664,7 @@ type IPSetRemove struct {
 func (m *IPSetRemove) Reset()                    { *m = IPSetRemove{} }
 func (m *IPSetRemove) String() string            { return proto1.CompactTextString(m) }
 func (*IPSetRemove) ProtoMessage()               {}
-func (*IPSetRemove) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{7} }
+func (*IPSetRemove) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{8} }
 
 func (m *IPSetRemove) GetId() string {
 	if m != nil {
@@ -1526,7 +1681,7 @@ type ActiveProfileUpdate struct {
 func (m *ActiveProfileUpdate) Reset()                    { *m = ActiveProfileUpdate{} }
 func (m *ActiveProfileUpdate) String() string            { return proto1.CompactTextString(m) }
 func (*ActiveProfileUpdate) ProtoMessage()               {}
-func (*ActiveProfileUpdate) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{8} }
+func (*ActiveProfileUpdate) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{9} }
 
 func (m *ActiveProfileUpdate) GetId() *ProfileID {
 	if m != nil {
@@ -1546,10 +1701,12 @@ type ActiveProfileRemove struct {
 	Id *ProfileID `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
 }
 
-func (m *ActiveProfileRemove) Reset()                    { *m = ActiveProfileRemove{} }
-func (m *ActiveProfileRemove) String() string            { return proto1.CompactTextString(m) }
-func (*ActiveProfileRemove) ProtoMessage()               {}
-func (*ActiveProfileRemove) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{9} }
+func (m *ActiveProfileRemove) Reset()         { *m = ActiveProfileRemove{} }
+func (m *ActiveProfileRemove) String() string { return proto1.CompactTextString(m) }
+func (*ActiveProfileRemove) ProtoMessage()    {}
+func (*ActiveProfileRemove) Descriptor() ([]byte, []int) {
+	return fileDescriptorFelixbackend, []int{10}
+}
 
 func (m *ActiveProfileRemove) GetId() *ProfileID {
 	if m != nil {
@@ -1565,7 +1722,7 @@ type ProfileID struct {
 func (m *ProfileID) Reset()                    { *m = ProfileID{} }
 func (m *ProfileID) String() string            { return proto1.CompactTextString(m) }
 func (*ProfileID) ProtoMessage()               {}
-func (*ProfileID) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{10} }
+func (*ProfileID) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{11} }
 
 func (m *ProfileID) GetName() string {
 	if m != nil {
@@ -1582,7 +1739,7 @@ type Profile struct {
 func (m *Profile) Reset()                    { *m = Profile{} }
 func (m *Profile) String() string            { return proto1.CompactTextString(m) }
 func (*Profile) ProtoMessage()               {}
-func (*Profile) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{11} }
+func (*Profile) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{12} }
 
 func (m *Profile) GetInboundRules() []*Rule {
 	if m != nil {
@@ -1606,7 +1763,7 @@ type ActivePolicyUpdate struct {
 func (m *ActivePolicyUpdate) Reset()                    { *m = ActivePolicyUpdate{} }
 func (m *ActivePolicyUpdate) String() string            { return proto1.CompactTextString(m) }
 func (*ActivePolicyUpdate) ProtoMessage()               {}
-func (*ActivePolicyUpdate) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{12} }
+func (*ActivePolicyUpdate) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{13} }
 
 func (m *ActivePolicyUpdate) GetId() *PolicyID {
 	if m != nil {
@@ -1629,7 +1786,7 @@ type ActivePolicyRemove struct {
 func (m *ActivePolicyRemove) Reset()                    { *m = ActivePolicyRemove{} }
 func (m *ActivePolicyRemove) String() string            { return proto1.CompactTextString(m) }
 func (*ActivePolicyRemove) ProtoMessage()               {}
-func (*ActivePolicyRemove) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{13} }
+func (*ActivePolicyRemove) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{14} }
 
 func (m *ActivePolicyRemove) GetId() *PolicyID {
 	if m != nil {
@@ -1638,6 +1795,115 @@ func (m *ActivePolicyRemove) GetId() *PolicyID {
 	return nil
 }
 
+type ActivePolicyDeltaUpdate struct {
+	Id            *PolicyID      `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	InboundRules  *RuleListDelta `protobuf:"bytes,2,opt,name=inbound_rules,json=inboundRules" json:"inbound_rules,omitempty"`
+	OutboundRules *RuleListDelta `protobuf:"bytes,3,opt,name=outbound_rules,json=outboundRules" json:"outbound_rules,omitempty"`
+	Namespace     string         `protobuf:"bytes,4,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Untracked     bool           `protobuf:"varint,5,opt,name=untracked,proto3" json:"untracked,omitempty"`
+	PreDnat       bool           `protobuf:"varint,6,opt,name=pre_dnat,json=preDnat,proto3" json:"pre_dnat,omitempty"`
+}
+
+func (m *ActivePolicyDeltaUpdate) Reset()         { *m = ActivePolicyDeltaUpdate{} }
+func (m *ActivePolicyDeltaUpdate) String() string { return proto1.CompactTextString(m) }
+func (*ActivePolicyDeltaUpdate) ProtoMessage()    {}
+func (*ActivePolicyDeltaUpdate) Descriptor() ([]byte, []int) {
+	return fileDescriptorFelixbackend, []int{15}
+}
+
+func (m *ActivePolicyDeltaUpdate) GetId() *PolicyID {
+	if m != nil {
+		return m.Id
+	}
+	return nil
+}
+
+func (m *ActivePolicyDeltaUpdate) GetInboundRules() *RuleListDelta {
+	if m != nil {
+		return m.InboundRules
+	}
+	return nil
+}
+
+func (m *ActivePolicyDeltaUpdate) GetOutboundRules() *RuleListDelta {
+	if m != nil {
+		return m.OutboundRules
+	}
+	return nil
+}
+
+func (m *ActivePolicyDeltaUpdate) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+func (m *ActivePolicyDeltaUpdate) GetUntracked() bool {
+	if m != nil {
+		return m.Untracked
+	}
+	return false
+}
+
+func (m *ActivePolicyDeltaUpdate) GetPreDnat() bool {
+	if m != nil {
+		return m.PreDnat
+	}
+	return false
+}
+
+// RuleListDelta describes an update to a list of rules relative to the previous
+// version of the list.  The delta replaces a single range of the previous list:
+// the new list is the first num_unchanged rules of the previous list, then
+// new_rules, then the last num_unchanged_at_end rules of the previous list.  If
+// rules were changed in more than one place, the range covers all of them.
+type RuleListDelta struct {
+	// The number of rules at the start of the previous list that are unchanged.
+	NumUnchanged uint32 `protobuf:"varint,1,opt,name=num_unchanged,json=numUnchanged,proto3" json:"num_unchanged,omitempty"`
+	// The rules that follow the unchanged rules in the new list.
+	NewRules []*Rule `protobuf:"bytes,2,rep,name=new_rules,json=newRules" json:"new_rules,omitempty"`
+	// The number of rules at the end of the previous list that are kept after
+	// new_rules.  Since each rule's ID depends on the rules before it, these rules
+	// may have new IDs.
+	NumUnchangedAtEnd uint32 `protobuf:"varint,3,opt,name=num_unchanged_at_end,json=numUnchangedAtEnd,proto3" json:"num_unchanged_at_end,omitempty"`
+	// The new IDs of the rules kept at the end, in order.
+	UnchangedAtEndRuleIds []string `protobuf:"bytes,4,rep,name=unchanged_at_end_rule_ids,json=unchangedAtEndRuleIds" json:"unchanged_at_end_rule_ids,omitempty"`
+}
+
+func (m *RuleListDelta) Reset()                    { *m = RuleListDelta{} }
+func (m *RuleListDelta) String() string            { return proto1.CompactTextString(m) }
+func (*RuleListDelta) ProtoMessage()               {}
+func (*RuleListDelta) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{16} }
+
+func (m *RuleListDelta) GetNumUnchanged() uint32 {
+	if m != nil {
+		return m.NumUnchanged
+	}
+	return 0
+}
+
+func (m *RuleListDelta) GetNewRules() []*Rule {
+	if m != nil {
+		return m.NewRules
+	}
+	return nil
+}
+
+func (m *RuleListDelta) GetNumUnchangedAtEnd() uint32 {
+	if m != nil {
+		return m.NumUnchangedAtEnd
+	}
+	return 0
+}
+
+func (m *RuleListDelta) GetUnchangedAtEndRuleIds() []string {
+	if m != nil {
+		return m.UnchangedAtEndRuleIds
+	}
+	return nil
+}
+
 type PolicyID struct {
 	Tier string `protobuf:"bytes,1,opt,name=tier,proto3" json:"tier,omitempty"`
 	Name string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
@@ -1646,7 +1912,7 @@ type PolicyID struct {
 func (m *PolicyID) Reset()                    { *m = PolicyID{} }
 func (m *PolicyID) String() string            { return proto1.CompactTextString(m) }
 func (*PolicyID) ProtoMessage()               {}
-func (*PolicyID) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{14} }
+func (*PolicyID) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{17} }
 
 func (m *PolicyID) GetTier() string {
 	if m != nil {
@@ -1675,7 +1941,7 @@ type Policy struct {
 func (m *Policy) Reset()                    { *m = Policy{} }
 func (m *Policy) String() string            { return proto1.CompactTextString(m) }
 func (*Policy) ProtoMessage()               {}
-func (*Policy) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{15} }
+func (*Policy) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{18} }
 
 func (m *Policy) GetNamespace() string {
 	if m != nil {
@@ -1758,6 +2024,9 @@ type Rule struct {
 	// Pass through of the v3 datamodel HTTP match criteria.
 	HttpMatch *HTTPMatch    `protobuf:"bytes,122,opt,name=http_match,json=httpMatch" json:"http_match,omitempty"`
 	Metadata  *RuleMetadata `protobuf:"bytes,123,opt,name=metadata" json:"metadata,omitempty"`
+	// Domain names that the destination must have resolved to; the dataplane matches on the
+	// addresses that it has seen them resolve to.
+	DstDomains []string `protobuf:"bytes,124,rep,name=dst_domains,json=dstDomains" json:"dst_domains,omitempty"`
 	// An opaque ID/hash for the rule.
 	RuleId string `protobuf:"bytes,201,opt,name=rule_id,json=ruleId,proto3" json:"rule_id,omitempty"`
 }
@@ -1765,7 +2034,7 @@ type Rule struct {
 func (m *Rule) Reset()                    { *m = Rule{} }
 func (m *Rule) String() string            { return proto1.CompactTextString(m) }
 func (*Rule) ProtoMessage()               {}
-func (*Rule) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{16} }
+func (*Rule) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{19} }
 
 type isRule_Icmp interface {
 	isRule_Icmp()
@@ -2047,6 +2316,13 @@ func (m *Rule) GetMetadata() *RuleMetadata {
 	return nil
 }
 
+func (m *Rule) GetDstDomains() []string {
+	if m != nil {
+		return m.DstDomains
+	}
+	return nil
+}
+
 func (m *Rule) GetRuleId() string {
 	if m != nil {
 		return m.RuleId
@@ -2177,7 +2453,7 @@ func (m *ServiceAccountMatch) Reset()         { *m = ServiceAccountMatch{} }
 func (m *ServiceAccountMatch) String() string { return proto1.CompactTextString(m) }
 func (*ServiceAccountMatch) ProtoMessage()    {}
 func (*ServiceAccountMatch) Descriptor() ([]byte, []int) {
-	return fileDescriptorFelixbackend, []int{17}
+	return fileDescriptorFelixbackend, []int{20}
 }
 
 func (m *ServiceAccountMatch) GetSelector() string {
@@ -2202,7 +2478,7 @@ type HTTPMatch struct {
 func (m *HTTPMatch) Reset()                    { *m = HTTPMatch{} }
 func (m *HTTPMatch) String() string            { return proto1.CompactTextString(m) }
 func (*HTTPMatch) ProtoMessage()               {}
-func (*HTTPMatch) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{18} }
+func (*HTTPMatch) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{21} }
 
 func (m *HTTPMatch) GetMethods() []string {
 	if m != nil {
@@ -2229,7 +2505,7 @@ func (m *HTTPMatch_PathMatch) Reset()         { *m = HTTPMatch_PathMatch{} }
 func (m *HTTPMatch_PathMatch) String() string { return proto1.CompactTextString(m) }
 func (*HTTPMatch_PathMatch) ProtoMessage()    {}
 func (*HTTPMatch_PathMatch) Descriptor() ([]byte, []int) {
-	return fileDescriptorFelixbackend, []int{18, 0}
+	return fileDescriptorFelixbackend, []int{21, 0}
 }
 
 type isHTTPMatch_PathMatch_PathMatch interface {
@@ -2342,7 +2618,7 @@ type RuleMetadata struct {
 func (m *RuleMetadata) Reset()                    { *m = RuleMetadata{} }
 func (m *RuleMetadata) String() string            { return proto1.CompactTextString(m) }
 func (*RuleMetadata) ProtoMessage()               {}
-func (*RuleMetadata) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{19} }
+func (*RuleMetadata) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{22} }
 
 func (m *RuleMetadata) GetAnnotations() map[string]string {
 	if m != nil {
@@ -2359,7 +2635,7 @@ type IcmpTypeAndCode struct {
 func (m *IcmpTypeAndCode) Reset()                    { *m = IcmpTypeAndCode{} }
 func (m *IcmpTypeAndCode) String() string            { return proto1.CompactTextString(m) }
 func (*IcmpTypeAndCode) ProtoMessage()               {}
-func (*IcmpTypeAndCode) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{20} }
+func (*IcmpTypeAndCode) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{23} }
 
 func (m *IcmpTypeAndCode) GetType() int32 {
 	if m != nil {
@@ -2385,7 +2661,7 @@ type Protocol struct {
 func (m *Protocol) Reset()                    { *m = Protocol{} }
 func (m *Protocol) String() string            { return proto1.CompactTextString(m) }
 func (*Protocol) ProtoMessage()               {}
-func (*Protocol) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{21} }
+func (*Protocol) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{24} }
 
 type isProtocol_NumberOrName interface {
 	isProtocol_NumberOrName()
@@ -2498,7 +2774,7 @@ type PortRange struct {
 func (m *PortRange) Reset()                    { *m = PortRange{} }
 func (m *PortRange) String() string            { return proto1.CompactTextString(m) }
 func (*PortRange) ProtoMessage()               {}
-func (*PortRange) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{22} }
+func (*PortRange) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{25} }
 
 func (m *PortRange) GetFirst() int32 {
 	if m != nil {
@@ -2523,7 +2799,7 @@ type WorkloadEndpointID struct {
 func (m *WorkloadEndpointID) Reset()                    { *m = WorkloadEndpointID{} }
 func (m *WorkloadEndpointID) String() string            { return proto1.CompactTextString(m) }
 func (*WorkloadEndpointID) ProtoMessage()               {}
-func (*WorkloadEndpointID) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{23} }
+func (*WorkloadEndpointID) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{26} }
 
 func (m *WorkloadEndpointID) GetOrchestratorId() string {
 	if m != nil {
@@ -2555,7 +2831,7 @@ func (m *WorkloadEndpointUpdate) Reset()         { *m = WorkloadEndpointUpdate{}
 func (m *WorkloadEndpointUpdate) String() string { return proto1.CompactTextString(m) }
 func (*WorkloadEndpointUpdate) ProtoMessage()    {}
 func (*WorkloadEndpointUpdate) Descriptor() ([]byte, []int) {
-	return fileDescriptorFelixbackend, []int{24}
+	return fileDescriptorFelixbackend, []int{27}
 }
 
 func (m *WorkloadEndpointUpdate) GetId() *WorkloadEndpointID {
@@ -2573,21 +2849,23 @@ func (m *WorkloadEndpointUpdate) GetEndpoint() *WorkloadEndpoint {
 }
 
 type WorkloadEndpoint struct {
-	State      string      `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
-	Name       string      `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
-	Mac        string      `protobuf:"bytes,3,opt,name=mac,proto3" json:"mac,omitempty"`
-	ProfileIds []string    `protobuf:"bytes,4,rep,name=profile_ids,json=profileIds" json:"profile_ids,omitempty"`
-	Ipv4Nets   []string    `protobuf:"bytes,5,rep,name=ipv4_nets,json=ipv4Nets" json:"ipv4_nets,omitempty"`
-	Ipv6Nets   []string    `protobuf:"bytes,6,rep,name=ipv6_nets,json=ipv6Nets" json:"ipv6_nets,omitempty"`
-	Tiers      []*TierInfo `protobuf:"bytes,7,rep,name=tiers" json:"tiers,omitempty"`
-	Ipv4Nat    []*NatInfo  `protobuf:"bytes,8,rep,name=ipv4_nat,json=ipv4Nat" json:"ipv4_nat,omitempty"`
-	Ipv6Nat    []*NatInfo  `protobuf:"bytes,9,rep,name=ipv6_nat,json=ipv6Nat" json:"ipv6_nat,omitempty"`
+	State        string             `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+	Name         string             `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Mac          string             `protobuf:"bytes,3,opt,name=mac,proto3" json:"mac,omitempty"`
+	ProfileIds   []string           `protobuf:"bytes,4,rep,name=profile_ids,json=profileIds" json:"profile_ids,omitempty"`
+	Ipv4Nets     []string           `protobuf:"bytes,5,rep,name=ipv4_nets,json=ipv4Nets" json:"ipv4_nets,omitempty"`
+	Ipv6Nets     []string           `protobuf:"bytes,6,rep,name=ipv6_nets,json=ipv6Nets" json:"ipv6_nets,omitempty"`
+	Tiers        []*TierInfo        `protobuf:"bytes,7,rep,name=tiers" json:"tiers,omitempty"`
+	Ipv4Nat      []*NatInfo         `protobuf:"bytes,8,rep,name=ipv4_nat,json=ipv4Nat" json:"ipv4_nat,omitempty"`
+	Ipv6Nat      []*NatInfo         `protobuf:"bytes,9,rep,name=ipv6_nat,json=ipv6Nat" json:"ipv6_nat,omitempty"`
+	Labels       map[string]string  `protobuf:"bytes,10,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	AppProtocols []*AppProtocolHint `protobuf:"bytes,16,rep,name=app_protocols,json=appProtocols" json:"app_protocols,omitempty"`
 }
 
 func (m *WorkloadEndpoint) Reset()                    { *m = WorkloadEndpoint{} }
 func (m *WorkloadEndpoint) String() string            { return proto1.CompactTextString(m) }
 func (*WorkloadEndpoint) ProtoMessage()               {}
-func (*WorkloadEndpoint) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{25} }
+func (*WorkloadEndpoint) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{28} }
 
 func (m *WorkloadEndpoint) GetState() string {
 	if m != nil {
@@ -2652,6 +2930,20 @@ func (m *WorkloadEndpoint) GetIpv6Nat() []*NatInfo {
 	return nil
 }
 
+func (m *WorkloadEndpoint) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *WorkloadEndpoint) GetAppProtocols() []*AppProtocolHint {
+	if m != nil {
+		return m.AppProtocols
+	}
+	return nil
+}
+
 type WorkloadEndpointRemove struct {
 	Id *WorkloadEndpointID `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
 }
@@ -2660,7 +2952,7 @@ func (m *WorkloadEndpointRemove) Reset()         { *m = WorkloadEndpointRemove{}
 func (m *WorkloadEndpointRemove) String() string { return proto1.CompactTextString(m) }
 func (*WorkloadEndpointRemove) ProtoMessage()    {}
 func (*WorkloadEndpointRemove) Descriptor() ([]byte, []int) {
-	return fileDescriptorFelixbackend, []int{26}
+	return fileDescriptorFelixbackend, []int{29}
 }
 
 func (m *WorkloadEndpointRemove) GetId() *WorkloadEndpointID {
@@ -2677,7 +2969,7 @@ type HostEndpointID struct {
 func (m *HostEndpointID) Reset()                    { *m = HostEndpointID{} }
 func (m *HostEndpointID) String() string            { return proto1.CompactTextString(m) }
 func (*HostEndpointID) ProtoMessage()               {}
-func (*HostEndpointID) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{27} }
+func (*HostEndpointID) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{30} }
 
 func (m *HostEndpointID) GetEndpointId() string {
 	if m != nil {
@@ -2694,7 +2986,7 @@ type HostEndpointUpdate struct {
 func (m *HostEndpointUpdate) Reset()                    { *m = HostEndpointUpdate{} }
 func (m *HostEndpointUpdate) String() string            { return proto1.CompactTextString(m) }
 func (*HostEndpointUpdate) ProtoMessage()               {}
-func (*HostEndpointUpdate) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{28} }
+func (*HostEndpointUpdate) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{31} }
 
 func (m *HostEndpointUpdate) GetId() *HostEndpointID {
 	if m != nil {
@@ -2724,7 +3016,7 @@ type HostEndpoint struct {
 func (m *HostEndpoint) Reset()                    { *m = HostEndpoint{} }
 func (m *HostEndpoint) String() string            { return proto1.CompactTextString(m) }
 func (*HostEndpoint) ProtoMessage()               {}
-func (*HostEndpoint) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{29} }
+func (*HostEndpoint) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{32} }
 
 func (m *HostEndpoint) GetName() string {
 	if m != nil {
@@ -2789,7 +3081,7 @@ type HostEndpointRemove struct {
 func (m *HostEndpointRemove) Reset()                    { *m = HostEndpointRemove{} }
 func (m *HostEndpointRemove) String() string            { return proto1.CompactTextString(m) }
 func (*HostEndpointRemove) ProtoMessage()               {}
-func (*HostEndpointRemove) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{30} }
+func (*HostEndpointRemove) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{33} }
 
 func (m *HostEndpointRemove) GetId() *HostEndpointID {
 	if m != nil {
@@ -2807,7 +3099,7 @@ type TierInfo struct {
 func (m *TierInfo) Reset()                    { *m = TierInfo{} }
 func (m *TierInfo) String() string            { return proto1.CompactTextString(m) }
 func (*TierInfo) ProtoMessage()               {}
-func (*TierInfo) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{31} }
+func (*TierInfo) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{34} }
 
 func (m *TierInfo) GetName() string {
 	if m != nil {
@@ -2838,7 +3130,7 @@ type NatInfo struct {
 func (m *NatInfo) Reset()                    { *m = NatInfo{} }
 func (m *NatInfo) String() string            { return proto1.CompactTextString(m) }
 func (*NatInfo) ProtoMessage()               {}
-func (*NatInfo) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{32} }
+func (*NatInfo) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{35} }
 
 func (m *NatInfo) GetExtIp() string {
 	if m != nil {
@@ -2863,7 +3155,7 @@ func (m *ProcessStatusUpdate) Reset()         { *m = ProcessStatusUpdate{} }
 func (m *ProcessStatusUpdate) String() string { return proto1.CompactTextString(m) }
 func (*ProcessStatusUpdate) ProtoMessage()    {}
 func (*ProcessStatusUpdate) Descriptor() ([]byte, []int) {
-	return fileDescriptorFelixbackend, []int{33}
+	return fileDescriptorFelixbackend, []int{36}
 }
 
 func (m *ProcessStatusUpdate) GetIsoTimestamp() string {
@@ -2889,7 +3181,7 @@ func (m *HostEndpointStatusUpdate) Reset()         { *m = HostEndpointStatusUpda
 func (m *HostEndpointStatusUpdate) String() string { return proto1.CompactTextString(m) }
 func (*HostEndpointStatusUpdate) ProtoMessage()    {}
 func (*HostEndpointStatusUpdate) Descriptor() ([]byte, []int) {
-	return fileDescriptorFelixbackend, []int{34}
+	return fileDescriptorFelixbackend, []int{37}
 }
 
 func (m *HostEndpointStatusUpdate) GetId() *HostEndpointID {
@@ -2908,12 +3200,21 @@ func (m *HostEndpointStatusUpdate) GetStatus() *EndpointStatus {
 
 type EndpointStatus struct {
 	Status string `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	// Human-readable explanation of why the endpoint is in "error" status.  Empty for
+	// other statuses.
+	Reason string `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	// Incremented each time the dataplane finishes programming a change to a
+	// workload endpoint or to the policies and profiles that apply to it.  It
+	// starts from the time, in microseconds, that the endpoint was first
+	// programmed, so it increases across Felix restarts.  Zero for host
+	// endpoints.
+	PolicyGeneration uint64 `protobuf:"varint,3,opt,name=policy_generation,json=policyGeneration,proto3" json:"policy_generation,omitempty"`
 }
 
 func (m *EndpointStatus) Reset()                    { *m = EndpointStatus{} }
 func (m *EndpointStatus) String() string            { return proto1.CompactTextString(m) }
 func (*EndpointStatus) ProtoMessage()               {}
-func (*EndpointStatus) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{35} }
+func (*EndpointStatus) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{38} }
 
 func (m *EndpointStatus) GetStatus() string {
 	if m != nil {
@@ -2922,6 +3223,20 @@ func (m *EndpointStatus) GetStatus() string {
 	return ""
 }
 
+func (m *EndpointStatus) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+func (m *EndpointStatus) GetPolicyGeneration() uint64 {
+	if m != nil {
+		return m.PolicyGeneration
+	}
+	return 0
+}
+
 type HostEndpointStatusRemove struct {
 	Id *HostEndpointID `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
 }
@@ -2930,7 +3245,7 @@ func (m *HostEndpointStatusRemove) Reset()         { *m = HostEndpointStatusRemo
 func (m *HostEndpointStatusRemove) String() string { return proto1.CompactTextString(m) }
 func (*HostEndpointStatusRemove) ProtoMessage()    {}
 func (*HostEndpointStatusRemove) Descriptor() ([]byte, []int) {
-	return fileDescriptorFelixbackend, []int{36}
+	return fileDescriptorFelixbackend, []int{39}
 }
 
 func (m *HostEndpointStatusRemove) GetId() *HostEndpointID {
@@ -2949,7 +3264,7 @@ func (m *WorkloadEndpointStatusUpdate) Reset()         { *m = WorkloadEndpointSt
 func (m *WorkloadEndpointStatusUpdate) String() string { return proto1.CompactTextString(m) }
 func (*WorkloadEndpointStatusUpdate) ProtoMessage()    {}
 func (*WorkloadEndpointStatusUpdate) Descriptor() ([]byte, []int) {
-	return fileDescriptorFelixbackend, []int{37}
+	return fileDescriptorFelixbackend, []int{40}
 }
 
 func (m *WorkloadEndpointStatusUpdate) GetId() *WorkloadEndpointID {
@@ -2974,7 +3289,7 @@ func (m *WorkloadEndpointStatusRemove) Reset()         { *m = WorkloadEndpointSt
 func (m *WorkloadEndpointStatusRemove) String() string { return proto1.CompactTextString(m) }
 func (*WorkloadEndpointStatusRemove) ProtoMessage()    {}
 func (*WorkloadEndpointStatusRemove) Descriptor() ([]byte, []int) {
-	return fileDescriptorFelixbackend, []int{38}
+	return fileDescriptorFelixbackend, []int{41}
 }
 
 func (m *WorkloadEndpointStatusRemove) GetId() *WorkloadEndpointID {
@@ -2993,7 +3308,7 @@ func (m *WireguardStatusUpdate) Reset()         { *m = WireguardStatusUpdate{} }
 func (m *WireguardStatusUpdate) String() string { return proto1.CompactTextString(m) }
 func (*WireguardStatusUpdate) ProtoMessage()    {}
 func (*WireguardStatusUpdate) Descriptor() ([]byte, []int) {
-	return fileDescriptorFelixbackend, []int{39}
+	return fileDescriptorFelixbackend, []int{42}
 }
 
 func (m *WireguardStatusUpdate) GetPublicKey() string {
@@ -3011,7 +3326,7 @@ type HostMetadataUpdate struct {
 func (m *HostMetadataUpdate) Reset()                    { *m = HostMetadataUpdate{} }
 func (m *HostMetadataUpdate) String() string            { return proto1.CompactTextString(m) }
 func (*HostMetadataUpdate) ProtoMessage()               {}
-func (*HostMetadataUpdate) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{40} }
+func (*HostMetadataUpdate) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{43} }
 
 func (m *HostMetadataUpdate) GetHostname() string {
 	if m != nil {
@@ -3035,7 +3350,7 @@ type HostMetadataRemove struct {
 func (m *HostMetadataRemove) Reset()                    { *m = HostMetadataRemove{} }
 func (m *HostMetadataRemove) String() string            { return proto1.CompactTextString(m) }
 func (*HostMetadataRemove) ProtoMessage()               {}
-func (*HostMetadataRemove) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{41} }
+func (*HostMetadataRemove) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{44} }
 
 func (m *HostMetadataRemove) GetHostname() string {
 	if m != nil {
@@ -3059,7 +3374,7 @@ type IPAMPoolUpdate struct {
 func (m *IPAMPoolUpdate) Reset()                    { *m = IPAMPoolUpdate{} }
 func (m *IPAMPoolUpdate) String() string            { return proto1.CompactTextString(m) }
 func (*IPAMPoolUpdate) ProtoMessage()               {}
-func (*IPAMPoolUpdate) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{42} }
+func (*IPAMPoolUpdate) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{45} }
 
 func (m *IPAMPoolUpdate) GetId() string {
 	if m != nil {
@@ -3082,7 +3397,7 @@ type IPAMPoolRemove struct {
 func (m *IPAMPoolRemove) Reset()                    { *m = IPAMPoolRemove{} }
 func (m *IPAMPoolRemove) String() string            { return proto1.CompactTextString(m) }
 func (*IPAMPoolRemove) ProtoMessage()               {}
-func (*IPAMPoolRemove) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{43} }
+func (*IPAMPoolRemove) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{46} }
 
 func (m *IPAMPoolRemove) GetId() string {
 	if m != nil {
@@ -3094,12 +3409,13 @@ func (m *IPAMPoolRemove) GetId() string {
 type IPAMPool struct {
 	Cidr       string `protobuf:"bytes,1,opt,name=cidr,proto3" json:"cidr,omitempty"`
 	Masquerade bool   `protobuf:"varint,2,opt,name=masquerade,proto3" json:"masquerade,omitempty"`
+	Disabled   bool   `protobuf:"varint,3,opt,name=disabled,proto3" json:"disabled,omitempty"`
 }
 
 func (m *IPAMPool) Reset()                    { *m = IPAMPool{} }
 func (m *IPAMPool) String() string            { return proto1.CompactTextString(m) }
 func (*IPAMPool) ProtoMessage()               {}
-func (*IPAMPool) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{44} }
+func (*IPAMPool) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{47} }
 
 func (m *IPAMPool) GetCidr() string {
 	if m != nil {
@@ -3115,6 +3431,13 @@ func (m *IPAMPool) GetMasquerade() bool {
 	return false
 }
 
+func (m *IPAMPool) GetDisabled() bool {
+	if m != nil {
+		return m.Disabled
+	}
+	return false
+}
+
 type ServiceAccountUpdate struct {
 	Id     *ServiceAccountID `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
 	Labels map[string]string `protobuf:"bytes,2,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
@@ -3124,7 +3447,7 @@ func (m *ServiceAccountUpdate) Reset()         { *m = ServiceAccountUpdate{} }
 func (m *ServiceAccountUpdate) String() string { return proto1.CompactTextString(m) }
 func (*ServiceAccountUpdate) ProtoMessage()    {}
 func (*ServiceAccountUpdate) Descriptor() ([]byte, []int) {
-	return fileDescriptorFelixbackend, []int{45}
+	return fileDescriptorFelixbackend, []int{48}
 }
 
 func (m *ServiceAccountUpdate) GetId() *ServiceAccountID {
@@ -3149,7 +3472,7 @@ func (m *ServiceAccountRemove) Reset()         { *m = ServiceAccountRemove{} }
 func (m *ServiceAccountRemove) String() string { return proto1.CompactTextString(m) }
 func (*ServiceAccountRemove) ProtoMessage()    {}
 func (*ServiceAccountRemove) Descriptor() ([]byte, []int) {
-	return fileDescriptorFelixbackend, []int{46}
+	return fileDescriptorFelixbackend, []int{49}
 }
 
 func (m *ServiceAccountRemove) GetId() *ServiceAccountID {
@@ -3167,7 +3490,7 @@ type ServiceAccountID struct {
 func (m *ServiceAccountID) Reset()                    { *m = ServiceAccountID{} }
 func (m *ServiceAccountID) String() string            { return proto1.CompactTextString(m) }
 func (*ServiceAccountID) ProtoMessage()               {}
-func (*ServiceAccountID) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{47} }
+func (*ServiceAccountID) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{50} }
 
 func (m *ServiceAccountID) GetNamespace() string {
 	if m != nil {
@@ -3191,7 +3514,7 @@ type NamespaceUpdate struct {
 func (m *NamespaceUpdate) Reset()                    { *m = NamespaceUpdate{} }
 func (m *NamespaceUpdate) String() string            { return proto1.CompactTextString(m) }
 func (*NamespaceUpdate) ProtoMessage()               {}
-func (*NamespaceUpdate) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{48} }
+func (*NamespaceUpdate) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{51} }
 
 func (m *NamespaceUpdate) GetId() *NamespaceID {
 	if m != nil {
@@ -3214,7 +3537,7 @@ type NamespaceRemove struct {
 func (m *NamespaceRemove) Reset()                    { *m = NamespaceRemove{} }
 func (m *NamespaceRemove) String() string            { return proto1.CompactTextString(m) }
 func (*NamespaceRemove) ProtoMessage()               {}
-func (*NamespaceRemove) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{49} }
+func (*NamespaceRemove) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{52} }
 
 func (m *NamespaceRemove) GetId() *NamespaceID {
 	if m != nil {
@@ -3230,7 +3553,7 @@ type NamespaceID struct {
 func (m *NamespaceID) Reset()                    { *m = NamespaceID{} }
 func (m *NamespaceID) String() string            { return proto1.CompactTextString(m) }
 func (*NamespaceID) ProtoMessage()               {}
-func (*NamespaceID) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{50} }
+func (*NamespaceID) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{53} }
 
 func (m *NamespaceID) GetName() string {
 	if m != nil {
@@ -3248,7 +3571,7 @@ type TunnelType struct {
 func (m *TunnelType) Reset()                    { *m = TunnelType{} }
 func (m *TunnelType) String() string            { return proto1.CompactTextString(m) }
 func (*TunnelType) ProtoMessage()               {}
-func (*TunnelType) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{51} }
+func (*TunnelType) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{54} }
 
 func (m *TunnelType) GetIpip() bool {
 	if m != nil {
@@ -3288,7 +3611,7 @@ type RouteUpdate struct {
 func (m *RouteUpdate) Reset()                    { *m = RouteUpdate{} }
 func (m *RouteUpdate) String() string            { return proto1.CompactTextString(m) }
 func (*RouteUpdate) ProtoMessage()               {}
-func (*RouteUpdate) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{52} }
+func (*RouteUpdate) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{55} }
 
 func (m *RouteUpdate) GetType() RouteType {
 	if m != nil {
@@ -3360,7 +3683,7 @@ type RouteRemove struct {
 func (m *RouteRemove) Reset()                    { *m = RouteRemove{} }
 func (m *RouteRemove) String() string            { return proto1.CompactTextString(m) }
 func (*RouteRemove) ProtoMessage()               {}
-func (*RouteRemove) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{53} }
+func (*RouteRemove) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{56} }
 
 func (m *RouteRemove) GetDst() string {
 	if m != nil {
@@ -3374,13 +3697,16 @@ type VXLANTunnelEndpointUpdate struct {
 	Mac            string `protobuf:"bytes,2,opt,name=mac,proto3" json:"mac,omitempty"`
 	Ipv4Addr       string `protobuf:"bytes,3,opt,name=ipv4_addr,json=ipv4Addr,proto3" json:"ipv4_addr,omitempty"`
 	ParentDeviceIp string `protobuf:"bytes,4,opt,name=parent_device_ip,json=parentDeviceIp,proto3" json:"parent_device_ip,omitempty"`
+	// The node's addresses on each of the configured fabric planes, in plane order.  An entry is
+	// empty if the node has no address on that plane.
+	FabricPlaneIps []string `protobuf:"bytes,5,rep,name=fabric_plane_ips,json=fabricPlaneIps" json:"fabric_plane_ips,omitempty"`
 }
 
 func (m *VXLANTunnelEndpointUpdate) Reset()         { *m = VXLANTunnelEndpointUpdate{} }
 func (m *VXLANTunnelEndpointUpdate) String() string { return proto1.CompactTextString(m) }
 func (*VXLANTunnelEndpointUpdate) ProtoMessage()    {}
 func (*VXLANTunnelEndpointUpdate) Descriptor() ([]byte, []int) {
-	return fileDescriptorFelixbackend, []int{54}
+	return fileDescriptorFelixbackend, []int{57}
 }
 
 func (m *VXLANTunnelEndpointUpdate) GetNode() string {
@@ -3411,6 +3737,13 @@ func (m *VXLANTunnelEndpointUpdate) GetParentDeviceIp() string {
 	return ""
 }
 
+func (m *VXLANTunnelEndpointUpdate) GetFabricPlaneIps() []string {
+	if m != nil {
+		return m.FabricPlaneIps
+	}
+	return nil
+}
+
 type VXLANTunnelEndpointRemove struct {
 	Node string `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
 }
@@ -3419,7 +3752,7 @@ func (m *VXLANTunnelEndpointRemove) Reset()         { *m = VXLANTunnelEndpointRe
 func (m *VXLANTunnelEndpointRemove) String() string { return proto1.CompactTextString(m) }
 func (*VXLANTunnelEndpointRemove) ProtoMessage()    {}
 func (*VXLANTunnelEndpointRemove) Descriptor() ([]byte, []int) {
-	return fileDescriptorFelixbackend, []int{55}
+	return fileDescriptorFelixbackend, []int{58}
 }
 
 func (m *VXLANTunnelEndpointRemove) GetNode() string {
@@ -3442,7 +3775,7 @@ func (m *WireguardEndpointUpdate) Reset()         { *m = WireguardEndpointUpdate
 func (m *WireguardEndpointUpdate) String() string { return proto1.CompactTextString(m) }
 func (*WireguardEndpointUpdate) ProtoMessage()    {}
 func (*WireguardEndpointUpdate) Descriptor() ([]byte, []int) {
-	return fileDescriptorFelixbackend, []int{56}
+	return fileDescriptorFelixbackend, []int{59}
 }
 
 func (m *WireguardEndpointUpdate) GetHostname() string {
@@ -3475,7 +3808,7 @@ func (m *WireguardEndpointRemove) Reset()         { *m = WireguardEndpointRemove
 func (m *WireguardEndpointRemove) String() string { return proto1.CompactTextString(m) }
 func (*WireguardEndpointRemove) ProtoMessage()    {}
 func (*WireguardEndpointRemove) Descriptor() ([]byte, []int) {
-	return fileDescriptorFelixbackend, []int{57}
+	return fileDescriptorFelixbackend, []int{60}
 }
 
 func (m *WireguardEndpointRemove) GetHostname() string {
@@ -3495,7 +3828,7 @@ func (m *GlobalBGPConfigUpdate) Reset()         { *m = GlobalBGPConfigUpdate{} }
 func (m *GlobalBGPConfigUpdate) String() string { return proto1.CompactTextString(m) }
 func (*GlobalBGPConfigUpdate) ProtoMessage()    {}
 func (*GlobalBGPConfigUpdate) Descriptor() ([]byte, []int) {
-	return fileDescriptorFelixbackend, []int{58}
+	return fileDescriptorFelixbackend, []int{61}
 }
 
 func (m *GlobalBGPConfigUpdate) GetServiceClusterCidrs() []string {
@@ -3519,10 +3852,63 @@ func (m *GlobalBGPConfigUpdate) GetServiceLoadbalancerCidrs() []string {
 	return nil
 }
 
+type AppProtocolHint struct {
+	// The Service that the hint comes from.
+	ServiceName string `protobuf:"bytes,1,opt,name=service_name,json=serviceName,proto3" json:"service_name,omitempty"`
+	// The port on the workload (i.e. the Service's target port), its protocol and the Service
+	// port's name.
+	Protocol string `protobuf:"bytes,2,opt,name=protocol,proto3" json:"protocol,omitempty"`
+	Port     int32  `protobuf:"varint,3,opt,name=port,proto3" json:"port,omitempty"`
+	PortName string `protobuf:"bytes,4,opt,name=port_name,json=portName,proto3" json:"port_name,omitempty"`
+	// The appProtocol of the Service port, for example "http" or "kubernetes.io/h2c".
+	AppProtocol string `protobuf:"bytes,5,opt,name=app_protocol,json=appProtocol,proto3" json:"app_protocol,omitempty"`
+}
+
+func (m *AppProtocolHint) Reset()                    { *m = AppProtocolHint{} }
+func (m *AppProtocolHint) String() string            { return proto1.CompactTextString(m) }
+func (*AppProtocolHint) ProtoMessage()               {}
+func (*AppProtocolHint) Descriptor() ([]byte, []int) { return fileDescriptorFelixbackend, []int{62} }
+
+func (m *AppProtocolHint) GetServiceName() string {
+	if m != nil {
+		return m.ServiceName
+	}
+	return ""
+}
+
+func (m *AppProtocolHint) GetProtocol() string {
+	if m != nil {
+		return m.Protocol
+	}
+	return ""
+}
+
+func (m *AppProtocolHint) GetPort() int32 {
+	if m != nil {
+		return m.Port
+	}
+	return 0
+}
+
+func (m *AppProtocolHint) GetPortName() string {
+	if m != nil {
+		return m.PortName
+	}
+	return ""
+}
+
+func (m *AppProtocolHint) GetAppProtocol() string {
+	if m != nil {
+		return m.AppProtocol
+	}
+	return ""
+}
+
 func init() {
 	proto1.RegisterType((*SyncRequest)(nil), "felix.SyncRequest")
 	proto1.RegisterType((*ToDataplane)(nil), "felix.ToDataplane")
 	proto1.RegisterType((*FromDataplane)(nil), "felix.FromDataplane")
+	proto1.RegisterType((*DataplaneCapabilities)(nil), "felix.DataplaneCapabilities")
 	proto1.RegisterType((*ConfigUpdate)(nil), "felix.ConfigUpdate")
 	proto1.RegisterType((*InSync)(nil), "felix.InSync")
 	proto1.RegisterType((*IPSetUpdate)(nil), "felix.IPSetUpdate")
@@ -3534,6 +3920,8 @@ func init() {
 	proto1.RegisterType((*Profile)(nil), "felix.Profile")
 	proto1.RegisterType((*ActivePolicyUpdate)(nil), "felix.ActivePolicyUpdate")
 	proto1.RegisterType((*ActivePolicyRemove)(nil), "felix.ActivePolicyRemove")
+	proto1.RegisterType((*ActivePolicyDeltaUpdate)(nil), "felix.ActivePolicyDeltaUpdate")
+	proto1.RegisterType((*RuleListDelta)(nil), "felix.RuleListDelta")
 	proto1.RegisterType((*PolicyID)(nil), "felix.PolicyID")
 	proto1.RegisterType((*Policy)(nil), "felix.Policy")
 	proto1.RegisterType((*Rule)(nil), "felix.Rule")
@@ -3580,6 +3968,7 @@ func init() {
 	proto1.RegisterType((*WireguardEndpointUpdate)(nil), "felix.WireguardEndpointUpdate")
 	proto1.RegisterType((*WireguardEndpointRemove)(nil), "felix.WireguardEndpointRemove")
 	proto1.RegisterType((*GlobalBGPConfigUpdate)(nil), "felix.GlobalBGPConfigUpdate")
+	proto1.RegisterType((*AppProtocolHint)(nil), "felix.AppProtocolHint")
 	proto1.RegisterEnum("felix.IPVersion", IPVersion_name, IPVersion_value)
 	proto1.RegisterEnum("felix.RouteType", RouteType_name, RouteType_value)
 	proto1.RegisterEnum("felix.IPPoolType", IPPoolType_name, IPPoolType_value)
@@ -3616,6 +4005,11 @@ type PolicySyncClient interface {
 	//  - RouteRemove
 	//  - VXLANTunnelEndpointUpdate
 	//  - VXLANTunnelEndpointRemove
+	//
+	// Clients that request version 2 of the API may also be sent:
+	//  - ActivePolicyDeltaUpdate
+	// and, when an IP set that they already have is replaced, they are sent an
+	// IPSetDeltaUpdate rather than the complete membership.
 	Sync(ctx context.Context, in *SyncRequest, opts ...grpc.CallOption) (PolicySync_SyncClient, error)
 }
 
@@ -3681,6 +4075,11 @@ type PolicySyncServer interface {
 	//  - RouteRemove
 	//  - VXLANTunnelEndpointUpdate
 	//  - VXLANTunnelEndpointRemove
+	//
+	// Clients that request version 2 of the API may also be sent:
+	//  - ActivePolicyDeltaUpdate
+	// and, when an IP set that they already have is replaced, they are sent an
+	// IPSetDeltaUpdate rather than the complete membership.
 	Sync(*SyncRequest, PolicySync_SyncServer) error
 }
 
@@ -3723,41 +4122,142 @@ var _PolicySync_serviceDesc = grpc.ServiceDesc{
 	Metadata: "felixbackend.proto",
 }
 
-func (m *SyncRequest) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
+// Client API for DataplaneDriverV1 service
+
+type DataplaneDriverV1Client interface {
+	Sync(ctx context.Context, opts ...grpc.CallOption) (DataplaneDriverV1_SyncClient, error)
+}
+
+type dataplaneDriverV1Client struct {
+	cc *grpc.ClientConn
+}
+
+func NewDataplaneDriverV1Client(cc *grpc.ClientConn) DataplaneDriverV1Client {
+	return &dataplaneDriverV1Client{cc}
+}
+
+func (c *dataplaneDriverV1Client) Sync(ctx context.Context, opts ...grpc.CallOption) (DataplaneDriverV1_SyncClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_DataplaneDriverV1_serviceDesc.Streams[0], c.cc, "/felix.DataplaneDriverV1/Sync", opts...)
 	if err != nil {
 		return nil, err
 	}
-	return dAtA[:n], nil
+	x := &dataplaneDriverV1SyncClient{stream}
+	return x, nil
 }
 
-func (m *SyncRequest) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	return i, nil
+type DataplaneDriverV1_SyncClient interface {
+	Send(*ToDataplane) error
+	Recv() (*FromDataplane, error)
+	grpc.ClientStream
 }
 
-func (m *ToDataplane) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
+type dataplaneDriverV1SyncClient struct {
+	grpc.ClientStream
+}
+
+func (x *dataplaneDriverV1SyncClient) Send(m *ToDataplane) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *dataplaneDriverV1SyncClient) Recv() (*FromDataplane, error) {
+	m := new(FromDataplane)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
-	return dAtA[:n], nil
+	return m, nil
 }
 
-func (m *ToDataplane) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if m.Payload != nil {
-		nn1, err := m.Payload.MarshalTo(dAtA[i:])
+// Server API for DataplaneDriverV1 service
+
+type DataplaneDriverV1Server interface {
+	Sync(DataplaneDriverV1_SyncServer) error
+}
+
+func RegisterDataplaneDriverV1Server(s *grpc.Server, srv DataplaneDriverV1Server) {
+	s.RegisterService(&_DataplaneDriverV1_serviceDesc, srv)
+}
+
+func _DataplaneDriverV1_Sync_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(DataplaneDriverV1Server).Sync(&dataplaneDriverV1SyncServer{stream})
+}
+
+type DataplaneDriverV1_SyncServer interface {
+	Send(*FromDataplane) error
+	Recv() (*ToDataplane, error)
+	grpc.ServerStream
+}
+
+type dataplaneDriverV1SyncServer struct {
+	grpc.ServerStream
+}
+
+func (x *dataplaneDriverV1SyncServer) Send(m *FromDataplane) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *dataplaneDriverV1SyncServer) Recv() (*ToDataplane, error) {
+	m := new(ToDataplane)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var _DataplaneDriverV1_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "felix.DataplaneDriverV1",
+	HandlerType: (*DataplaneDriverV1Server)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Sync",
+			Handler:       _DataplaneDriverV1_Sync_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "felixbackend.proto",
+}
+
+func (m *SyncRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *SyncRequest) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.ApiVersion != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(m.ApiVersion))
+	}
+	return i, nil
+}
+
+func (m *ToDataplane) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ToDataplane) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.Payload != nil {
+		nn1, err := m.Payload.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
@@ -4191,6 +4691,38 @@ func (m *ToDataplane_GlobalBgpConfigUpdate) MarshalTo(dAtA []byte) (int, error)
 	}
 	return i, nil
 }
+func (m *ToDataplane_ActivePolicyDeltaUpdate) MarshalTo(dAtA []byte) (int, error) {
+	i := 0
+	if m.ActivePolicyDeltaUpdate != nil {
+		dAtA[i] = 0xf2
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(m.ActivePolicyDeltaUpdate.Size()))
+		n30, err := m.ActivePolicyDeltaUpdate.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n30
+	}
+	return i, nil
+}
+func (m *ToDataplane_WorkloadEndpointStatusUpdate) MarshalTo(dAtA []byte) (int, error) {
+	i := 0
+	if m.WorkloadEndpointStatusUpdate != nil {
+		dAtA[i] = 0xfa
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(m.WorkloadEndpointStatusUpdate.Size()))
+		n31, err := m.WorkloadEndpointStatusUpdate.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n31
+	}
+	return i, nil
+}
 func (m *FromDataplane) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
@@ -4207,11 +4739,11 @@ func (m *FromDataplane) MarshalTo(dAtA []byte) (int, error) {
 	var l int
 	_ = l
 	if m.Payload != nil {
-		nn30, err := m.Payload.MarshalTo(dAtA[i:])
+		nn32, err := m.Payload.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += nn30
+		i += nn32
 	}
 	if m.SequenceNumber != 0 {
 		dAtA[i] = 0x40
@@ -4227,11 +4759,11 @@ func (m *FromDataplane_ProcessStatusUpdate) MarshalTo(dAtA []byte) (int, error)
 		dAtA[i] = 0x1a
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.ProcessStatusUpdate.Size()))
-		n31, err := m.ProcessStatusUpdate.MarshalTo(dAtA[i:])
+		n33, err := m.ProcessStatusUpdate.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n31
+		i += n33
 	}
 	return i, nil
 }
@@ -4241,11 +4773,11 @@ func (m *FromDataplane_HostEndpointStatusUpdate) MarshalTo(dAtA []byte) (int, er
 		dAtA[i] = 0x22
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.HostEndpointStatusUpdate.Size()))
-		n32, err := m.HostEndpointStatusUpdate.MarshalTo(dAtA[i:])
+		n34, err := m.HostEndpointStatusUpdate.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n32
+		i += n34
 	}
 	return i, nil
 }
@@ -4255,11 +4787,11 @@ func (m *FromDataplane_HostEndpointStatusRemove) MarshalTo(dAtA []byte) (int, er
 		dAtA[i] = 0x2a
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.HostEndpointStatusRemove.Size()))
-		n33, err := m.HostEndpointStatusRemove.MarshalTo(dAtA[i:])
+		n35, err := m.HostEndpointStatusRemove.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n33
+		i += n35
 	}
 	return i, nil
 }
@@ -4269,11 +4801,11 @@ func (m *FromDataplane_WorkloadEndpointStatusUpdate) MarshalTo(dAtA []byte) (int
 		dAtA[i] = 0x32
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.WorkloadEndpointStatusUpdate.Size()))
-		n34, err := m.WorkloadEndpointStatusUpdate.MarshalTo(dAtA[i:])
+		n36, err := m.WorkloadEndpointStatusUpdate.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n34
+		i += n36
 	}
 	return i, nil
 }
@@ -4283,11 +4815,11 @@ func (m *FromDataplane_WorkloadEndpointStatusRemove) MarshalTo(dAtA []byte) (int
 		dAtA[i] = 0x3a
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.WorkloadEndpointStatusRemove.Size()))
-		n35, err := m.WorkloadEndpointStatusRemove.MarshalTo(dAtA[i:])
+		n37, err := m.WorkloadEndpointStatusRemove.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n35
+		i += n37
 	}
 	return i, nil
 }
@@ -4297,14 +4829,86 @@ func (m *FromDataplane_WireguardStatusUpdate) MarshalTo(dAtA []byte) (int, error
 		dAtA[i] = 0x4a
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.WireguardStatusUpdate.Size()))
-		n36, err := m.WireguardStatusUpdate.MarshalTo(dAtA[i:])
+		n38, err := m.WireguardStatusUpdate.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n36
+		i += n38
+	}
+	return i, nil
+}
+func (m *FromDataplane_DataplaneCapabilities) MarshalTo(dAtA []byte) (int, error) {
+	i := 0
+	if m.DataplaneCapabilities != nil {
+		dAtA[i] = 0x52
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(m.DataplaneCapabilities.Size()))
+		n39, err := m.DataplaneCapabilities.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n39
+	}
+	return i, nil
+}
+func (m *DataplaneCapabilities) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *DataplaneCapabilities) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.Ipv6 {
+		dAtA[i] = 0x8
+		i++
+		if m.Ipv6 {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if m.Vxlan {
+		dAtA[i] = 0x10
+		i++
+		if m.Vxlan {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if m.Wireguard {
+		dAtA[i] = 0x18
+		i++
+		if m.Wireguard {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if m.ApplicationLayerPolicy {
+		dAtA[i] = 0x20
+		i++
+		if m.ApplicationLayerPolicy {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
 	}
 	return i, nil
 }
+
 func (m *ConfigUpdate) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
@@ -4499,21 +5103,21 @@ func (m *ActiveProfileUpdate) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0xa
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Id.Size()))
-		n37, err := m.Id.MarshalTo(dAtA[i:])
+		n40, err := m.Id.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n37
+		i += n40
 	}
 	if m.Profile != nil {
 		dAtA[i] = 0x12
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Profile.Size()))
-		n38, err := m.Profile.MarshalTo(dAtA[i:])
+		n41, err := m.Profile.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n38
+		i += n41
 	}
 	return i, nil
 }
@@ -4537,11 +5141,11 @@ func (m *ActiveProfileRemove) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0xa
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Id.Size()))
-		n39, err := m.Id.MarshalTo(dAtA[i:])
+		n42, err := m.Id.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n39
+		i += n42
 	}
 	return i, nil
 }
@@ -4631,21 +5235,21 @@ func (m *ActivePolicyUpdate) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0xa
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Id.Size()))
-		n40, err := m.Id.MarshalTo(dAtA[i:])
+		n43, err := m.Id.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n40
+		i += n43
 	}
 	if m.Policy != nil {
 		dAtA[i] = 0x12
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Policy.Size()))
-		n41, err := m.Policy.MarshalTo(dAtA[i:])
+		n44, err := m.Policy.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n41
+		i += n44
 	}
 	return i, nil
 }
@@ -4669,16 +5273,16 @@ func (m *ActivePolicyRemove) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0xa
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Id.Size()))
-		n42, err := m.Id.MarshalTo(dAtA[i:])
+		n45, err := m.Id.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n42
+		i += n45
 	}
 	return i, nil
 }
 
-func (m *PolicyID) Marshal() (dAtA []byte, err error) {
+func (m *ActivePolicyDeltaUpdate) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalTo(dAtA)
@@ -4688,27 +5292,71 @@ func (m *PolicyID) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *PolicyID) MarshalTo(dAtA []byte) (int, error) {
+func (m *ActivePolicyDeltaUpdate) MarshalTo(dAtA []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
-	if len(m.Tier) > 0 {
+	if m.Id != nil {
 		dAtA[i] = 0xa
 		i++
-		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.Tier)))
-		i += copy(dAtA[i:], m.Tier)
+		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Id.Size()))
+		n46, err := m.Id.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n46
 	}
-	if len(m.Name) > 0 {
+	if m.InboundRules != nil {
 		dAtA[i] = 0x12
 		i++
-		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.Name)))
-		i += copy(dAtA[i:], m.Name)
+		i = encodeVarintFelixbackend(dAtA, i, uint64(m.InboundRules.Size()))
+		n47, err := m.InboundRules.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n47
+	}
+	if m.OutboundRules != nil {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(m.OutboundRules.Size()))
+		n48, err := m.OutboundRules.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n48
+	}
+	if len(m.Namespace) > 0 {
+		dAtA[i] = 0x22
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.Namespace)))
+		i += copy(dAtA[i:], m.Namespace)
+	}
+	if m.Untracked {
+		dAtA[i] = 0x28
+		i++
+		if m.Untracked {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if m.PreDnat {
+		dAtA[i] = 0x30
+		i++
+		if m.PreDnat {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
 	}
 	return i, nil
 }
 
-func (m *Policy) Marshal() (dAtA []byte, err error) {
+func (m *RuleListDelta) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalTo(dAtA)
@@ -4718,25 +5366,18 @@ func (m *Policy) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *Policy) MarshalTo(dAtA []byte) (int, error) {
+func (m *RuleListDelta) MarshalTo(dAtA []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
-	if len(m.InboundRules) > 0 {
-		for _, msg := range m.InboundRules {
-			dAtA[i] = 0xa
-			i++
-			i = encodeVarintFelixbackend(dAtA, i, uint64(msg.Size()))
-			n, err := msg.MarshalTo(dAtA[i:])
-			if err != nil {
-				return 0, err
-			}
-			i += n
-		}
+	if m.NumUnchanged != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(m.NumUnchanged))
 	}
-	if len(m.OutboundRules) > 0 {
-		for _, msg := range m.OutboundRules {
+	if len(m.NewRules) > 0 {
+		for _, msg := range m.NewRules {
 			dAtA[i] = 0x12
 			i++
 			i = encodeVarintFelixbackend(dAtA, i, uint64(msg.Size()))
@@ -4747,18 +5388,110 @@ func (m *Policy) MarshalTo(dAtA []byte) (int, error) {
 			i += n
 		}
 	}
-	if m.Untracked {
+	if m.NumUnchangedAtEnd != 0 {
 		dAtA[i] = 0x18
 		i++
-		if m.Untracked {
-			dAtA[i] = 1
-		} else {
-			dAtA[i] = 0
-		}
-		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(m.NumUnchangedAtEnd))
 	}
-	if m.PreDnat {
-		dAtA[i] = 0x20
+	if len(m.UnchangedAtEndRuleIds) > 0 {
+		for _, s := range m.UnchangedAtEndRuleIds {
+			dAtA[i] = 0x22
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	return i, nil
+}
+
+func (m *PolicyID) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *PolicyID) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Tier) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.Tier)))
+		i += copy(dAtA[i:], m.Tier)
+	}
+	if len(m.Name) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.Name)))
+		i += copy(dAtA[i:], m.Name)
+	}
+	return i, nil
+}
+
+func (m *Policy) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Policy) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.InboundRules) > 0 {
+		for _, msg := range m.InboundRules {
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintFelixbackend(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	if len(m.OutboundRules) > 0 {
+		for _, msg := range m.OutboundRules {
+			dAtA[i] = 0x12
+			i++
+			i = encodeVarintFelixbackend(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	if m.Untracked {
+		dAtA[i] = 0x18
+		i++
+		if m.Untracked {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if m.PreDnat {
+		dAtA[i] = 0x20
 		i++
 		if m.PreDnat {
 			dAtA[i] = 1
@@ -4806,11 +5539,11 @@ func (m *Rule) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0x1a
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Protocol.Size()))
-		n43, err := m.Protocol.MarshalTo(dAtA[i:])
+		n49, err := m.Protocol.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n43
+		i += n49
 	}
 	if len(m.SrcNet) > 0 {
 		for _, s := range m.SrcNet {
@@ -4867,11 +5600,11 @@ func (m *Rule) MarshalTo(dAtA []byte) (int, error) {
 		}
 	}
 	if m.Icmp != nil {
-		nn44, err := m.Icmp.MarshalTo(dAtA[i:])
+		nn50, err := m.Icmp.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += nn44
+		i += nn50
 	}
 	if len(m.SrcIpSetIds) > 0 {
 		for _, s := range m.SrcIpSetIds {
@@ -4939,11 +5672,11 @@ func (m *Rule) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0x6
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.NotProtocol.Size()))
-		n45, err := m.NotProtocol.MarshalTo(dAtA[i:])
+		n51, err := m.NotProtocol.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n45
+		i += n51
 	}
 	if len(m.NotSrcNet) > 0 {
 		for _, s := range m.NotSrcNet {
@@ -5008,11 +5741,11 @@ func (m *Rule) MarshalTo(dAtA []byte) (int, error) {
 		}
 	}
 	if m.NotIcmp != nil {
-		nn46, err := m.NotIcmp.MarshalTo(dAtA[i:])
+		nn52, err := m.NotIcmp.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += nn46
+		i += nn52
 	}
 	if len(m.NotSrcIpSetIds) > 0 {
 		for _, s := range m.NotSrcIpSetIds {
@@ -5136,11 +5869,11 @@ func (m *Rule) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0x7
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.SrcServiceAccountMatch.Size()))
-		n47, err := m.SrcServiceAccountMatch.MarshalTo(dAtA[i:])
+		n53, err := m.SrcServiceAccountMatch.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n47
+		i += n53
 	}
 	if m.DstServiceAccountMatch != nil {
 		dAtA[i] = 0xca
@@ -5148,11 +5881,11 @@ func (m *Rule) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0x7
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.DstServiceAccountMatch.Size()))
-		n48, err := m.DstServiceAccountMatch.MarshalTo(dAtA[i:])
+		n54, err := m.DstServiceAccountMatch.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n48
+		i += n54
 	}
 	if m.HttpMatch != nil {
 		dAtA[i] = 0xd2
@@ -5160,11 +5893,11 @@ func (m *Rule) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0x7
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.HttpMatch.Size()))
-		n49, err := m.HttpMatch.MarshalTo(dAtA[i:])
+		n55, err := m.HttpMatch.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n49
+		i += n55
 	}
 	if m.Metadata != nil {
 		dAtA[i] = 0xda
@@ -5172,11 +5905,28 @@ func (m *Rule) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0x7
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Metadata.Size()))
-		n50, err := m.Metadata.MarshalTo(dAtA[i:])
+		n56, err := m.Metadata.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n50
+		i += n56
+	}
+	if len(m.DstDomains) > 0 {
+		for _, s := range m.DstDomains {
+			dAtA[i] = 0xe2
+			i++
+			dAtA[i] = 0x7
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
 	}
 	if len(m.RuleId) > 0 {
 		dAtA[i] = 0xca
@@ -5202,11 +5952,11 @@ func (m *Rule_IcmpTypeCode) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0x4a
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.IcmpTypeCode.Size()))
-		n51, err := m.IcmpTypeCode.MarshalTo(dAtA[i:])
+		n57, err := m.IcmpTypeCode.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n51
+		i += n57
 	}
 	return i, nil
 }
@@ -5227,11 +5977,11 @@ func (m *Rule_NotIcmpTypeCode) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0x6
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.NotIcmpTypeCode.Size()))
-		n52, err := m.NotIcmpTypeCode.MarshalTo(dAtA[i:])
+		n58, err := m.NotIcmpTypeCode.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n52
+		i += n58
 	}
 	return i, nil
 }
@@ -5335,11 +6085,11 @@ func (m *HTTPMatch_PathMatch) MarshalTo(dAtA []byte) (int, error) {
 	var l int
 	_ = l
 	if m.PathMatch != nil {
-		nn53, err := m.PathMatch.MarshalTo(dAtA[i:])
+		nn59, err := m.PathMatch.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += nn53
+		i += nn59
 	}
 	return i, nil
 }
@@ -5439,11 +6189,11 @@ func (m *Protocol) MarshalTo(dAtA []byte) (int, error) {
 	var l int
 	_ = l
 	if m.NumberOrName != nil {
-		nn54, err := m.NumberOrName.MarshalTo(dAtA[i:])
+		nn60, err := m.NumberOrName.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += nn54
+		i += nn60
 	}
 	return i, nil
 }
@@ -5546,21 +6296,21 @@ func (m *WorkloadEndpointUpdate) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0xa
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Id.Size()))
-		n55, err := m.Id.MarshalTo(dAtA[i:])
+		n61, err := m.Id.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n55
+		i += n61
 	}
 	if m.Endpoint != nil {
 		dAtA[i] = 0x2a
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Endpoint.Size()))
-		n56, err := m.Endpoint.MarshalTo(dAtA[i:])
+		n62, err := m.Endpoint.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n56
+		i += n62
 	}
 	return i, nil
 }
@@ -5679,6 +6429,37 @@ func (m *WorkloadEndpoint) MarshalTo(dAtA []byte) (int, error) {
 			i += n
 		}
 	}
+	if len(m.Labels) > 0 {
+		for k, _ := range m.Labels {
+			dAtA[i] = 0x52
+			i++
+			v := m.Labels[k]
+			mapSize := 1 + len(k) + sovFelixbackend(uint64(len(k))) + 1 + len(v) + sovFelixbackend(uint64(len(v)))
+			i = encodeVarintFelixbackend(dAtA, i, uint64(mapSize))
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintFelixbackend(dAtA, i, uint64(len(k)))
+			i += copy(dAtA[i:], k)
+			dAtA[i] = 0x12
+			i++
+			i = encodeVarintFelixbackend(dAtA, i, uint64(len(v)))
+			i += copy(dAtA[i:], v)
+		}
+	}
+	if len(m.AppProtocols) > 0 {
+		for _, msg := range m.AppProtocols {
+			dAtA[i] = 0x82
+			i++
+			dAtA[i] = 0x1
+			i++
+			i = encodeVarintFelixbackend(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
 	return i, nil
 }
 
@@ -5701,11 +6482,11 @@ func (m *WorkloadEndpointRemove) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0xa
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Id.Size()))
-		n57, err := m.Id.MarshalTo(dAtA[i:])
+		n63, err := m.Id.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n57
+		i += n63
 	}
 	return i, nil
 }
@@ -5753,21 +6534,21 @@ func (m *HostEndpointUpdate) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0xa
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Id.Size()))
-		n58, err := m.Id.MarshalTo(dAtA[i:])
+		n64, err := m.Id.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n58
+		i += n64
 	}
 	if m.Endpoint != nil {
 		dAtA[i] = 0x1a
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Endpoint.Size()))
-		n59, err := m.Endpoint.MarshalTo(dAtA[i:])
+		n65, err := m.Endpoint.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n59
+		i += n65
 	}
 	return i, nil
 }
@@ -5908,11 +6689,11 @@ func (m *HostEndpointRemove) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0xa
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Id.Size()))
-		n60, err := m.Id.MarshalTo(dAtA[i:])
+		n66, err := m.Id.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n60
+		i += n66
 	}
 	return i, nil
 }
@@ -6050,21 +6831,21 @@ func (m *HostEndpointStatusUpdate) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0xa
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Id.Size()))
-		n61, err := m.Id.MarshalTo(dAtA[i:])
+		n67, err := m.Id.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n61
+		i += n67
 	}
 	if m.Status != nil {
 		dAtA[i] = 0x12
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Status.Size()))
-		n62, err := m.Status.MarshalTo(dAtA[i:])
+		n68, err := m.Status.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n62
+		i += n68
 	}
 	return i, nil
 }
@@ -6090,6 +6871,17 @@ func (m *EndpointStatus) MarshalTo(dAtA []byte) (int, error) {
 		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.Status)))
 		i += copy(dAtA[i:], m.Status)
 	}
+	if len(m.Reason) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.Reason)))
+		i += copy(dAtA[i:], m.Reason)
+	}
+	if m.PolicyGeneration != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(m.PolicyGeneration))
+	}
 	return i, nil
 }
 
@@ -6112,11 +6904,11 @@ func (m *HostEndpointStatusRemove) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0xa
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Id.Size()))
-		n63, err := m.Id.MarshalTo(dAtA[i:])
+		n69, err := m.Id.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n63
+		i += n69
 	}
 	return i, nil
 }
@@ -6140,21 +6932,21 @@ func (m *WorkloadEndpointStatusUpdate) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0xa
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Id.Size()))
-		n64, err := m.Id.MarshalTo(dAtA[i:])
+		n70, err := m.Id.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n64
+		i += n70
 	}
 	if m.Status != nil {
 		dAtA[i] = 0x12
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Status.Size()))
-		n65, err := m.Status.MarshalTo(dAtA[i:])
+		n71, err := m.Status.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n65
+		i += n71
 	}
 	return i, nil
 }
@@ -6178,11 +6970,11 @@ func (m *WorkloadEndpointStatusRemove) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0xa
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Id.Size()))
-		n66, err := m.Id.MarshalTo(dAtA[i:])
+		n72, err := m.Id.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n66
+		i += n72
 	}
 	return i, nil
 }
@@ -6296,11 +7088,11 @@ func (m *IPAMPoolUpdate) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0x12
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Pool.Size()))
-		n67, err := m.Pool.MarshalTo(dAtA[i:])
+		n73, err := m.Pool.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n67
+		i += n73
 	}
 	return i, nil
 }
@@ -6360,6 +7152,16 @@ func (m *IPAMPool) MarshalTo(dAtA []byte) (int, error) {
 		}
 		i++
 	}
+	if m.Disabled {
+		dAtA[i] = 0x18
+		i++
+		if m.Disabled {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
 	return i, nil
 }
 
@@ -6382,11 +7184,11 @@ func (m *ServiceAccountUpdate) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0xa
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Id.Size()))
-		n68, err := m.Id.MarshalTo(dAtA[i:])
+		n74, err := m.Id.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n68
+		i += n74
 	}
 	if len(m.Labels) > 0 {
 		for k, _ := range m.Labels {
@@ -6427,11 +7229,11 @@ func (m *ServiceAccountRemove) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0xa
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Id.Size()))
-		n69, err := m.Id.MarshalTo(dAtA[i:])
+		n75, err := m.Id.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n69
+		i += n75
 	}
 	return i, nil
 }
@@ -6485,11 +7287,11 @@ func (m *NamespaceUpdate) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0xa
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Id.Size()))
-		n70, err := m.Id.MarshalTo(dAtA[i:])
+		n76, err := m.Id.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n70
+		i += n76
 	}
 	if len(m.Labels) > 0 {
 		for k, _ := range m.Labels {
@@ -6530,11 +7332,11 @@ func (m *NamespaceRemove) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0xa
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Id.Size()))
-		n71, err := m.Id.MarshalTo(dAtA[i:])
+		n77, err := m.Id.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n71
+		i += n77
 	}
 	return i, nil
 }
@@ -6688,11 +7490,11 @@ func (m *RouteUpdate) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0x52
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.TunnelType.Size()))
-		n72, err := m.TunnelType.MarshalTo(dAtA[i:])
+		n78, err := m.TunnelType.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n72
+		i += n78
 	}
 	return i, nil
 }
@@ -6760,6 +7562,21 @@ func (m *VXLANTunnelEndpointUpdate) MarshalTo(dAtA []byte) (int, error) {
 		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.ParentDeviceIp)))
 		i += copy(dAtA[i:], m.ParentDeviceIp)
 	}
+	if len(m.FabricPlaneIps) > 0 {
+		for _, s := range m.FabricPlaneIps {
+			dAtA[i] = 0x2a
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
 	return i, nil
 }
 
@@ -6910,6 +7727,53 @@ func (m *GlobalBGPConfigUpdate) MarshalTo(dAtA []byte) (int, error) {
 	return i, nil
 }
 
+func (m *AppProtocolHint) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *AppProtocolHint) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.ServiceName) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.ServiceName)))
+		i += copy(dAtA[i:], m.ServiceName)
+	}
+	if len(m.Protocol) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.Protocol)))
+		i += copy(dAtA[i:], m.Protocol)
+	}
+	if m.Port != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Port))
+	}
+	if len(m.PortName) > 0 {
+		dAtA[i] = 0x22
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.PortName)))
+		i += copy(dAtA[i:], m.PortName)
+	}
+	if len(m.AppProtocol) > 0 {
+		dAtA[i] = 0x2a
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.AppProtocol)))
+		i += copy(dAtA[i:], m.AppProtocol)
+	}
+	return i, nil
+}
+
 func encodeVarintFelixbackend(dAtA []byte, offset int, v uint64) int {
 	for v >= 1<<7 {
 		dAtA[offset] = uint8(v&0x7f | 0x80)
@@ -6922,6 +7786,9 @@ func encodeVarintFelixbackend(dAtA []byte, offset int, v uint64) int {
 func (m *SyncRequest) Size() (n int) {
 	var l int
 	_ = l
+	if m.ApiVersion != 0 {
+		n += 1 + sovFelixbackend(uint64(m.ApiVersion))
+	}
 	return n
 }
 
@@ -7189,14 +8056,32 @@ func (m *ToDataplane_GlobalBgpConfigUpdate) Size() (n int) {
 	}
 	return n
 }
-func (m *FromDataplane) Size() (n int) {
+func (m *ToDataplane_ActivePolicyDeltaUpdate) Size() (n int) {
 	var l int
 	_ = l
-	if m.Payload != nil {
-		n += m.Payload.Size()
+	if m.ActivePolicyDeltaUpdate != nil {
+		l = m.ActivePolicyDeltaUpdate.Size()
+		n += 2 + l + sovFelixbackend(uint64(l))
 	}
-	if m.SequenceNumber != 0 {
-		n += 1 + sovFelixbackend(uint64(m.SequenceNumber))
+	return n
+}
+func (m *ToDataplane_WorkloadEndpointStatusUpdate) Size() (n int) {
+	var l int
+	_ = l
+	if m.WorkloadEndpointStatusUpdate != nil {
+		l = m.WorkloadEndpointStatusUpdate.Size()
+		n += 2 + l + sovFelixbackend(uint64(l))
+	}
+	return n
+}
+func (m *FromDataplane) Size() (n int) {
+	var l int
+	_ = l
+	if m.Payload != nil {
+		n += m.Payload.Size()
+	}
+	if m.SequenceNumber != 0 {
+		n += 1 + sovFelixbackend(uint64(m.SequenceNumber))
 	}
 	return n
 }
@@ -7255,6 +8140,33 @@ func (m *FromDataplane_WireguardStatusUpdate) Size() (n int) {
 	}
 	return n
 }
+func (m *FromDataplane_DataplaneCapabilities) Size() (n int) {
+	var l int
+	_ = l
+	if m.DataplaneCapabilities != nil {
+		l = m.DataplaneCapabilities.Size()
+		n += 1 + l + sovFelixbackend(uint64(l))
+	}
+	return n
+}
+func (m *DataplaneCapabilities) Size() (n int) {
+	var l int
+	_ = l
+	if m.Ipv6 {
+		n += 2
+	}
+	if m.Vxlan {
+		n += 2
+	}
+	if m.Wireguard {
+		n += 2
+	}
+	if m.ApplicationLayerPolicy {
+		n += 2
+	}
+	return n
+}
+
 func (m *ConfigUpdate) Size() (n int) {
 	var l int
 	_ = l
@@ -7402,6 +8314,58 @@ func (m *ActivePolicyRemove) Size() (n int) {
 	return n
 }
 
+func (m *ActivePolicyDeltaUpdate) Size() (n int) {
+	var l int
+	_ = l
+	if m.Id != nil {
+		l = m.Id.Size()
+		n += 1 + l + sovFelixbackend(uint64(l))
+	}
+	if m.InboundRules != nil {
+		l = m.InboundRules.Size()
+		n += 1 + l + sovFelixbackend(uint64(l))
+	}
+	if m.OutboundRules != nil {
+		l = m.OutboundRules.Size()
+		n += 1 + l + sovFelixbackend(uint64(l))
+	}
+	l = len(m.Namespace)
+	if l > 0 {
+		n += 1 + l + sovFelixbackend(uint64(l))
+	}
+	if m.Untracked {
+		n += 2
+	}
+	if m.PreDnat {
+		n += 2
+	}
+	return n
+}
+
+func (m *RuleListDelta) Size() (n int) {
+	var l int
+	_ = l
+	if m.NumUnchanged != 0 {
+		n += 1 + sovFelixbackend(uint64(m.NumUnchanged))
+	}
+	if len(m.NewRules) > 0 {
+		for _, e := range m.NewRules {
+			l = e.Size()
+			n += 1 + l + sovFelixbackend(uint64(l))
+		}
+	}
+	if m.NumUnchangedAtEnd != 0 {
+		n += 1 + sovFelixbackend(uint64(m.NumUnchangedAtEnd))
+	}
+	if len(m.UnchangedAtEndRuleIds) > 0 {
+		for _, s := range m.UnchangedAtEndRuleIds {
+			l = len(s)
+			n += 1 + l + sovFelixbackend(uint64(l))
+		}
+	}
+	return n
+}
+
 func (m *PolicyID) Size() (n int) {
 	var l int
 	_ = l
@@ -7604,6 +8568,12 @@ func (m *Rule) Size() (n int) {
 		l = m.Metadata.Size()
 		n += 2 + l + sovFelixbackend(uint64(l))
 	}
+	if len(m.DstDomains) > 0 {
+		for _, s := range m.DstDomains {
+			l = len(s)
+			n += 2 + l + sovFelixbackend(uint64(l))
+		}
+	}
 	l = len(m.RuleId)
 	if l > 0 {
 		n += 2 + l + sovFelixbackend(uint64(l))
@@ -7841,6 +8811,20 @@ func (m *WorkloadEndpoint) Size() (n int) {
 			n += 1 + l + sovFelixbackend(uint64(l))
 		}
 	}
+	if len(m.Labels) > 0 {
+		for k, v := range m.Labels {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovFelixbackend(uint64(len(k))) + 1 + len(v) + sovFelixbackend(uint64(len(v)))
+			n += mapEntrySize + 1 + sovFelixbackend(uint64(mapEntrySize))
+		}
+	}
+	if len(m.AppProtocols) > 0 {
+		for _, e := range m.AppProtocols {
+			l = e.Size()
+			n += 2 + l + sovFelixbackend(uint64(l))
+		}
+	}
 	return n
 }
 
@@ -8010,6 +8994,13 @@ func (m *EndpointStatus) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovFelixbackend(uint64(l))
 	}
+	l = len(m.Reason)
+	if l > 0 {
+		n += 1 + l + sovFelixbackend(uint64(l))
+	}
+	if m.PolicyGeneration != 0 {
+		n += 1 + sovFelixbackend(uint64(m.PolicyGeneration))
+	}
 	return n
 }
 
@@ -8119,6 +9110,9 @@ func (m *IPAMPool) Size() (n int) {
 	if m.Masquerade {
 		n += 2
 	}
+	if m.Disabled {
+		n += 2
+	}
 	return n
 }
 
@@ -8283,6 +9277,12 @@ func (m *VXLANTunnelEndpointUpdate) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovFelixbackend(uint64(l))
 	}
+	if len(m.FabricPlaneIps) > 0 {
+		for _, s := range m.FabricPlaneIps {
+			l = len(s)
+			n += 1 + l + sovFelixbackend(uint64(l))
+		}
+	}
 	return n
 }
 
@@ -8348,6 +9348,31 @@ func (m *GlobalBGPConfigUpdate) Size() (n int) {
 	return n
 }
 
+func (m *AppProtocolHint) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.ServiceName)
+	if l > 0 {
+		n += 1 + l + sovFelixbackend(uint64(l))
+	}
+	l = len(m.Protocol)
+	if l > 0 {
+		n += 1 + l + sovFelixbackend(uint64(l))
+	}
+	if m.Port != 0 {
+		n += 1 + sovFelixbackend(uint64(m.Port))
+	}
+	l = len(m.PortName)
+	if l > 0 {
+		n += 1 + l + sovFelixbackend(uint64(l))
+	}
+	l = len(m.AppProtocol)
+	if l > 0 {
+		n += 1 + l + sovFelixbackend(uint64(l))
+	}
+	return n
+}
+
 func sovFelixbackend(x uint64) (n int) {
 	for {
 		n++
@@ -8390,6 +9415,25 @@ func (m *SyncRequest) Unmarshal(dAtA []byte) error {
 			return fmt.Errorf("proto: SyncRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ApiVersion", wireType)
+			}
+			m.ApiVersion = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ApiVersion |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipFelixbackend(dAtA[iNdEx:])
@@ -9355,6 +10399,70 @@ func (m *ToDataplane) Unmarshal(dAtA []byte) error {
 			}
 			m.Payload = &ToDataplane_GlobalBgpConfigUpdate{v}
 			iNdEx = postIndex
+		case 30:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ActivePolicyDeltaUpdate", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			v := &ActivePolicyDeltaUpdate{}
+			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			m.Payload = &ToDataplane_ActivePolicyDeltaUpdate{v}
+			iNdEx = postIndex
+		case 31:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field WorkloadEndpointStatusUpdate", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			v := &WorkloadEndpointStatusUpdate{}
+			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			m.Payload = &ToDataplane_WorkloadEndpointStatusUpdate{v}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipFelixbackend(dAtA[iNdEx:])
@@ -9616,6 +10724,38 @@ func (m *FromDataplane) Unmarshal(dAtA []byte) error {
 			}
 			m.Payload = &FromDataplane_WireguardStatusUpdate{v}
 			iNdEx = postIndex
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DataplaneCapabilities", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			v := &DataplaneCapabilities{}
+			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			m.Payload = &FromDataplane_DataplaneCapabilities{v}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipFelixbackend(dAtA[iNdEx:])
@@ -9637,7 +10777,7 @@ func (m *FromDataplane) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *ConfigUpdate) Unmarshal(dAtA []byte) error {
+func (m *DataplaneCapabilities) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -9660,17 +10800,17 @@ func (m *ConfigUpdate) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: ConfigUpdate: wiretype end group for non-group")
+			return fmt.Errorf("proto: DataplaneCapabilities: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: ConfigUpdate: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: DataplaneCapabilities: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Config", wireType)
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Ipv6", wireType)
 			}
-			var msglen int
+			var v int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowFelixbackend
@@ -9680,17 +10820,147 @@ func (m *ConfigUpdate) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				v |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
-				return ErrInvalidLengthFelixbackend
+			m.Ipv6 = bool(v != 0)
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Vxlan", wireType)
 			}
-			postIndex := iNdEx + msglen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Vxlan = bool(v != 0)
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Wireguard", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Wireguard = bool(v != 0)
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ApplicationLayerPolicy", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.ApplicationLayerPolicy = bool(v != 0)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipFelixbackend(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ConfigUpdate) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowFelixbackend
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ConfigUpdate: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ConfigUpdate: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Config", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
 			}
 			if m.Config == nil {
 				m.Config = make(map[string]string)
@@ -10723,21 +11993,391 @@ func (m *ActivePolicyRemove) Unmarshal(dAtA []byte) error {
 			if b < 0x80 {
 				break
 			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: ActivePolicyRemove: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: ActivePolicyRemove: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ActivePolicyRemove: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ActivePolicyRemove: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Id == nil {
+				m.Id = &PolicyID{}
+			}
+			if err := m.Id.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipFelixbackend(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ActivePolicyDeltaUpdate) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowFelixbackend
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ActivePolicyDeltaUpdate: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ActivePolicyDeltaUpdate: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Id == nil {
+				m.Id = &PolicyID{}
+			}
+			if err := m.Id.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field InboundRules", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.InboundRules == nil {
+				m.InboundRules = &RuleListDelta{}
+			}
+			if err := m.InboundRules.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field OutboundRules", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.OutboundRules == nil {
+				m.OutboundRules = &RuleListDelta{}
+			}
+			if err := m.OutboundRules.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Namespace", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Namespace = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Untracked", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Untracked = bool(v != 0)
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PreDnat", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.PreDnat = bool(v != 0)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipFelixbackend(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *RuleListDelta) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowFelixbackend
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: RuleListDelta: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: RuleListDelta: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field NumUnchanged", wireType)
+			}
+			m.NumUnchanged = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.NumUnchanged |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field NewRules", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.NewRules = append(m.NewRules, &Rule{})
+			if err := m.NewRules[len(m.NewRules)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field NumUnchangedAtEnd", wireType)
+			}
+			m.NumUnchangedAtEnd = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.NumUnchangedAtEnd |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field UnchangedAtEndRuleIds", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowFelixbackend
@@ -10747,24 +12387,20 @@ func (m *ActivePolicyRemove) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				stringLen |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthFelixbackend
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Id == nil {
-				m.Id = &PolicyID{}
-			}
-			if err := m.Id.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			m.UnchangedAtEndRuleIds = append(m.UnchangedAtEndRuleIds, string(dAtA[iNdEx:postIndex]))
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -12101,6 +13737,35 @@ func (m *Rule) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 124:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DstDomains", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.DstDomains = append(m.DstDomains, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
 		case 201:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field RuleId", wireType)
@@ -13469,6 +15134,155 @@ func (m *WorkloadEndpoint) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Labels", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Labels == nil {
+				m.Labels = make(map[string]string)
+			}
+			var mapkey string
+			var mapvalue string
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowFelixbackend
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= (uint64(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowFelixbackend
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= (uint64(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthFelixbackend
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					var stringLenmapvalue uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowFelixbackend
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapvalue |= (uint64(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapvalue := int(stringLenmapvalue)
+					if intStringLenmapvalue < 0 {
+						return ErrInvalidLengthFelixbackend
+					}
+					postStringIndexmapvalue := iNdEx + intStringLenmapvalue
+					if postStringIndexmapvalue > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapvalue = string(dAtA[iNdEx:postStringIndexmapvalue])
+					iNdEx = postStringIndexmapvalue
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipFelixbackend(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if skippy < 0 {
+						return ErrInvalidLengthFelixbackend
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.Labels[mapkey] = mapvalue
+			iNdEx = postIndex
+		case 16:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field AppProtocols", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.AppProtocols = append(m.AppProtocols, &AppProtocolHint{})
+			if err := m.AppProtocols[len(m.AppProtocols)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipFelixbackend(dAtA[iNdEx:])
@@ -14605,25 +16419,54 @@ func (m *EndpointStatus) Unmarshal(dAtA []byte) error {
 			if iNdEx >= l {
 				return io.ErrUnexpectedEOF
 			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= (uint64(b) & 0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: EndpointStatus: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: EndpointStatus: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: EndpointStatus: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: EndpointStatus: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Status", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Status = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Status", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Reason", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -14648,8 +16491,27 @@ func (m *EndpointStatus) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Status = string(dAtA[iNdEx:postIndex])
+			m.Reason = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PolicyGeneration", wireType)
+			}
+			m.PolicyGeneration = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.PolicyGeneration |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipFelixbackend(dAtA[iNdEx:])
@@ -15517,6 +17379,26 @@ func (m *IPAMPool) Unmarshal(dAtA []byte) error {
 				}
 			}
 			m.Masquerade = bool(v != 0)
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Disabled", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Disabled = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipFelixbackend(dAtA[iNdEx:])
@@ -16895,6 +18777,35 @@ func (m *VXLANTunnelEndpointUpdate) Unmarshal(dAtA []byte) error {
 			}
 			m.ParentDeviceIp = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FabricPlaneIps", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.FabricPlaneIps = append(m.FabricPlaneIps, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipFelixbackend(dAtA[iNdEx:])
@@ -17348,6 +19259,191 @@ func (m *GlobalBGPConfigUpdate) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
+func (m *AppProtocolHint) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowFelixbackend
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: AppProtocolHint: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: AppProtocolHint: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ServiceName", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ServiceName = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Protocol", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Protocol = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Port", wireType)
+			}
+			m.Port = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Port |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PortName", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.PortName = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field AppProtocol", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.AppProtocol = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipFelixbackend(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 func skipFelixbackend(dAtA []byte) (n int, err error) {
 	l := len(dAtA)
 	iNdEx := 0
@@ -17456,221 +19552,253 @@ var (
 func init() { proto1.RegisterFile("felixbackend.proto", fileDescriptorFelixbackend) }
 
 var fileDescriptorFelixbackend = []byte{
-	// 3453 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x5a, 0xdd, 0x6e, 0x1c, 0x47,
-	0x76, 0x66, 0x0f, 0xc9, 0xe1, 0xcc, 0x99, 0x1f, 0xb6, 0x8a, 0x22, 0x39, 0xa4, 0x24, 0x4a, 0x6e,
-	0x59, 0x10, 0xad, 0xc0, 0x92, 0x40, 0x4b, 0x94, 0xe5, 0x04, 0x32, 0x48, 0x0e, 0x2d, 0x8e, 0x4d,
-	0x0d, 0x89, 0x26, 0x2d, 0xc7, 0x81, 0x81, 0x4e, 0xb3, 0xbb, 0x48, 0x76, 0xd4, 0xd3, 0xdd, 0xee,
-	0xae, 0xe1, 0x4f, 0x72, 0x17, 0xe4, 0x22, 0x09, 0x10, 0x24, 0x57, 0xc1, 0x3e, 0xc0, 0x62, 0xaf,
-	0xf6, 0x0d, 0xf6, 0x62, 0xaf, 0x16, 0xb0, 0xef, 0xf6, 0x05, 0x16, 0xd8, 0xf5, 0x3e, 0xc1, 0xbe,
-	0xc1, 0xa2, 0x7e, 0xfb, 0x67, 0x7a, 0x28, 0x69, 0xb1, 0xd8, 0x2b, 0x4e, 0x9d, 0xf3, 0x9d, 0xaf,
-	0x4e, 0x9d, 0x3a, 0x5d, 0x75, 0xaa, 0x8a, 0x80, 0x8e, 0xb1, 0xef, 0x5d, 0x1c, 0xd9, 0xce, 0x1b,
-	0x1c, 0xb8, 0x0f, 0xa3, 0x38, 0x24, 0x21, 0x9a, 0x66, 0x32, 0xa3, 0x05, 0x8d, 0x83, 0xcb, 0xc0,
-	0x31, 0xf1, 0xf7, 0x43, 0x9c, 0x10, 0xe3, 0x0f, 0x3a, 0x34, 0x0e, 0xc3, 0xae, 0x4d, 0xec, 0xc8,
-	0xb7, 0x03, 0x8c, 0x56, 0x61, 0xc6, 0x0b, 0xac, 0xe4, 0x32, 0x70, 0x3a, 0xda, 0x1d, 0x6d, 0xb5,
-	0xb1, 0xd6, 0x7a, 0xc8, 0xec, 0x1e, 0xf6, 0x02, 0x6a, 0xb6, 0x33, 0x61, 0x56, 0x3d, 0xf6, 0x0b,
-	0x3d, 0x83, 0xa6, 0x17, 0x25, 0x98, 0x58, 0xc3, 0xc8, 0xb5, 0x09, 0xee, 0x54, 0x18, 0x1c, 0x49,
-	0xf8, 0xfe, 0x01, 0x26, 0x5f, 0x33, 0xcd, 0xce, 0x84, 0xd9, 0x60, 0x48, 0xde, 0x44, 0x2f, 0x01,
-	0x71, 0x43, 0x17, 0xfb, 0xc4, 0x96, 0xe6, 0x93, 0xcc, 0x7c, 0x31, 0x6b, 0xde, 0xa5, 0x7a, 0xc5,
-	0xa1, 0x33, 0xa3, 0x8c, 0x2c, 0xf5, 0x20, 0xc6, 0x83, 0xf0, 0x0c, 0x77, 0xa6, 0x46, 0x3d, 0x30,
-	0x99, 0x46, 0x79, 0xc0, 0x9b, 0x68, 0x1f, 0xe6, 0x6d, 0x87, 0x78, 0x67, 0xd8, 0x8a, 0xe2, 0xf0,
-	0xd8, 0xf3, 0xb1, 0x74, 0x62, 0x9a, 0x31, 0x2c, 0x0b, 0x86, 0x0d, 0x86, 0xd9, 0xe7, 0x10, 0xe5,
-	0xc7, 0x9c, 0x3d, 0x2a, 0x2e, 0x61, 0x14, 0x3e, 0x55, 0xc7, 0x33, 0x2a, 0xdf, 0xf2, 0x8c, 0xc2,
-	0xc7, 0x57, 0x70, 0x5d, 0x32, 0x86, 0xbe, 0xe7, 0x5c, 0x4a, 0x17, 0x67, 0x18, 0xe1, 0x52, 0x9e,
-	0x90, 0x21, 0x94, 0x87, 0xc8, 0x1e, 0x91, 0x8e, 0xd2, 0x09, 0xff, 0x6a, 0x63, 0xe9, 0x94, 0x7b,
-	0x39, 0xba, 0xd4, 0xbb, 0xd3, 0x30, 0x21, 0x16, 0x0e, 0xdc, 0x28, 0xf4, 0x02, 0x95, 0x04, 0xf5,
-	0x1c, 0xdd, 0x4e, 0x98, 0x90, 0x6d, 0x81, 0x48, 0xbd, 0x3b, 0x1d, 0x91, 0x8e, 0xd2, 0x09, 0xef,
-	0x60, 0x2c, 0x5d, 0xea, 0xdd, 0xe9, 0x88, 0x14, 0x7d, 0x0b, 0x9d, 0xf3, 0x30, 0x7e, 0xe3, 0x87,
-	0xb6, 0x3b, 0xe2, 0x61, 0x83, 0x51, 0xde, 0x12, 0x94, 0xdf, 0x08, 0xd8, 0x88, 0x97, 0x0b, 0xe7,
-	0xa5, 0x9a, 0x72, 0x6a, 0xe1, 0x6d, 0xf3, 0x4a, 0x6a, 0xe5, 0xf1, 0x08, 0xb5, 0xf0, 0xfa, 0x33,
-	0x68, 0x39, 0x61, 0x70, 0xec, 0x9d, 0x48, 0x57, 0x5b, 0x8c, 0x6f, 0x4e, 0xf0, 0x6d, 0x31, 0x9d,
-	0x72, 0xb0, 0xe9, 0x64, 0xda, 0x2a, 0x80, 0x03, 0x4c, 0x6c, 0xd7, 0x4e, 0xbf, 0xaa, 0xf6, 0x48,
-	0x00, 0x5f, 0x09, 0x44, 0x7e, 0x3e, 0xf2, 0x52, 0x74, 0x1f, 0x66, 0x13, 0xba, 0x40, 0x04, 0x0e,
-	0xb6, 0x82, 0xe1, 0xe0, 0x08, 0xc7, 0x9d, 0xd9, 0x3b, 0xda, 0xea, 0x94, 0xd9, 0x96, 0xe2, 0x3e,
-	0x93, 0xa2, 0x0d, 0xd0, 0xbd, 0xc8, 0x1e, 0x58, 0x51, 0x18, 0xfa, 0xb2, 0x4f, 0x9d, 0xf5, 0x39,
-	0xaf, 0x3e, 0xc3, 0x8d, 0x57, 0xfb, 0x61, 0xe8, 0xab, 0xfe, 0xda, 0xd4, 0x20, 0x95, 0xe4, 0x29,
-	0x44, 0x24, 0xaf, 0x95, 0x52, 0xa8, 0x08, 0x2a, 0x8a, 0x42, 0x36, 0xaa, 0xd1, 0x0b, 0x1a, 0x34,
-	0x76, 0xf4, 0xf9, 0xf4, 0xc9, 0x4b, 0xd1, 0x01, 0x2c, 0x24, 0x38, 0x3e, 0xf3, 0x1c, 0x6c, 0xd9,
-	0x8e, 0x13, 0x0e, 0xd3, 0xe4, 0x99, 0x63, 0x84, 0x37, 0x04, 0xe1, 0x01, 0x07, 0x6d, 0x70, 0x8c,
-	0x1a, 0xe0, 0xf5, 0xa4, 0x44, 0x5e, 0x46, 0x2a, 0xbc, 0xbc, 0x7e, 0x05, 0xa9, 0xf2, 0xb3, 0x40,
-	0x2a, 0x3c, 0xdd, 0x02, 0x3d, 0xb0, 0x07, 0x38, 0x89, 0x6c, 0x47, 0xad, 0x61, 0xf3, 0x8c, 0x6e,
-	0x41, 0xd0, 0xf5, 0xa5, 0x5a, 0xb9, 0x37, 0x1b, 0xe4, 0x45, 0x79, 0x12, 0xe1, 0xd3, 0x42, 0x39,
-	0x89, 0x72, 0x27, 0x25, 0x11, 0x9e, 0x3c, 0x83, 0x66, 0x1c, 0x0e, 0x89, 0xf2, 0x62, 0x31, 0xb7,
-	0x16, 0x9b, 0x54, 0x95, 0xee, 0x06, 0x71, 0xda, 0x4c, 0x0d, 0x45, 0xcf, 0x9d, 0x51, 0xc3, 0x74,
-	0x11, 0x8f, 0xd3, 0x26, 0xda, 0x82, 0xc6, 0x19, 0xc1, 0x91, 0xec, 0x70, 0x89, 0xd9, 0xdd, 0x11,
-	0x76, 0xaf, 0xff, 0x71, 0x77, 0xa3, 0x7f, 0x38, 0x0c, 0x02, 0xec, 0x8f, 0x7c, 0xda, 0x40, 0xcd,
-	0xd4, 0xd8, 0x39, 0x89, 0xe8, 0x7c, 0xf9, 0x6d, 0x24, 0xca, 0x15, 0x46, 0x22, 0x3c, 0xf9, 0x0e,
-	0x96, 0xce, 0xbd, 0x18, 0x9f, 0x0c, 0xed, 0x78, 0x74, 0xbd, 0xb9, 0xc1, 0x28, 0x57, 0xe4, 0xa2,
-	0x20, 0x71, 0x23, 0x5e, 0x2d, 0x9e, 0x97, 0xab, 0xc6, 0xb0, 0x0b, 0x87, 0x6f, 0x5e, 0xcd, 0xae,
-	0xdc, 0x1d, 0x65, 0x17, 0xbe, 0x7f, 0x03, 0x9d, 0x13, 0x3f, 0x3c, 0xb2, 0x7d, 0xeb, 0xe8, 0x24,
-	0xb2, 0xf2, 0xeb, 0xcf, 0x2d, 0x46, 0x7e, 0x53, 0x90, 0xbf, 0x64, 0xb0, 0xcd, 0x97, 0xfb, 0x85,
-	0x85, 0x68, 0x9e, 0xdb, 0x6f, 0x9e, 0x44, 0x59, 0xc5, 0x66, 0x1d, 0x66, 0x22, 0xfb, 0x92, 0x2e,
-	0x73, 0xc6, 0xff, 0x4c, 0x43, 0xeb, 0x8b, 0x38, 0x1c, 0xa4, 0x55, 0xc6, 0x3e, 0xcc, 0x47, 0x71,
-	0xe8, 0xe0, 0x24, 0xb1, 0x12, 0x62, 0x93, 0x61, 0x92, 0xaf, 0x02, 0xe4, 0x76, 0xb9, 0xcf, 0x31,
-	0x07, 0x0c, 0x92, 0x6e, 0xc0, 0xd1, 0xa8, 0x18, 0xfd, 0x33, 0xdc, 0xc8, 0xef, 0x20, 0x79, 0x5e,
-	0x5e, 0x1a, 0xdc, 0x2e, 0xd9, 0x48, 0x0a, 0xe4, 0x9d, 0xd3, 0x31, 0xba, 0xb1, 0x3d, 0x88, 0x99,
-	0x98, 0x7e, 0x4b, 0x0f, 0x6a, 0x2a, 0x4a, 0x7a, 0x10, 0x73, 0xe1, 0xc3, 0xed, 0xd1, 0xbd, 0x25,
-	0x3f, 0x0e, 0x5e, 0x4e, 0xdc, 0x1d, 0xb3, 0xc5, 0x14, 0xc6, 0x72, 0xf3, 0xfc, 0x0a, 0xfd, 0x95,
-	0xbd, 0x89, 0x31, 0xcd, 0xbc, 0x43, 0x6f, 0x6a, 0x5c, 0x63, 0x7a, 0x13, 0x63, 0x2b, 0xd9, 0x51,
-	0x6a, 0xa5, 0x3b, 0xca, 0x6b, 0x48, 0x73, 0xb5, 0x30, 0xf8, 0x7a, 0x2e, 0x1f, 0x55, 0xb2, 0x17,
-	0x46, 0x3d, 0x7f, 0x5e, 0xa6, 0xc8, 0xe6, 0xe3, 0xbf, 0x6b, 0xd0, 0xcc, 0xe6, 0x2a, 0x7a, 0x06,
-	0x55, 0x9e, 0xf9, 0x1d, 0xed, 0xce, 0x64, 0x66, 0x16, 0xb3, 0x20, 0xd1, 0xd8, 0x0e, 0x48, 0x7c,
-	0x69, 0x0a, 0xf8, 0xf2, 0x73, 0x68, 0x64, 0xc4, 0x48, 0x87, 0xc9, 0x37, 0xf8, 0x92, 0x15, 0xce,
-	0x75, 0x93, 0xfe, 0x44, 0xd7, 0x61, 0xfa, 0xcc, 0xf6, 0x87, 0xbc, 0x3a, 0xae, 0x9b, 0xbc, 0xf1,
-	0x59, 0xe5, 0x53, 0xcd, 0xa8, 0x41, 0x95, 0x97, 0xd4, 0xc6, 0xcf, 0x34, 0x68, 0x64, 0xca, 0x65,
-	0xd4, 0x86, 0x8a, 0xe7, 0x0a, 0x92, 0x8a, 0xe7, 0xa2, 0x0e, 0xcc, 0x0c, 0x30, 0x8d, 0x4d, 0xd2,
-	0xa9, 0xdc, 0x99, 0x5c, 0xad, 0x9b, 0xb2, 0x89, 0x1e, 0xc3, 0x14, 0xb9, 0x8c, 0xf8, 0x57, 0xd3,
-	0x56, 0x81, 0xc9, 0x70, 0xf1, 0xdf, 0x87, 0x97, 0x11, 0x36, 0x19, 0xd2, 0xf8, 0x18, 0xea, 0x4a,
-	0x84, 0xaa, 0x50, 0xe9, 0xed, 0xeb, 0x13, 0x68, 0x96, 0xf6, 0x6f, 0x6d, 0xf4, 0xbb, 0xd6, 0xfe,
-	0x9e, 0x79, 0xa8, 0x6b, 0x68, 0x06, 0x26, 0xfb, 0xdb, 0x87, 0x7a, 0xc5, 0x88, 0x40, 0x2f, 0x56,
-	0xe2, 0x23, 0xee, 0xdd, 0x85, 0x96, 0xed, 0xba, 0xd8, 0xb5, 0xf2, 0x4e, 0x36, 0x99, 0xf0, 0x95,
-	0xf0, 0xf4, 0x3e, 0xcc, 0xf2, 0x9c, 0x4a, 0x61, 0x93, 0x0c, 0xd6, 0x16, 0x62, 0x01, 0x34, 0x6e,
-	0x89, 0x58, 0x88, 0xb4, 0x29, 0x74, 0x66, 0xd8, 0x30, 0x57, 0x52, 0x95, 0xa3, 0x3b, 0x0a, 0xd6,
-	0x58, 0xd3, 0xd3, 0xc5, 0x83, 0x22, 0x7a, 0x5d, 0xe6, 0xe5, 0x2a, 0xcc, 0x88, 0xca, 0x5c, 0x1c,
-	0x54, 0xda, 0x79, 0x98, 0x29, 0xd5, 0xc6, 0xb3, 0x42, 0x17, 0xc2, 0x93, 0xb7, 0x76, 0x61, 0xdc,
-	0x86, 0xba, 0x12, 0x20, 0x04, 0x53, 0x74, 0x8b, 0x14, 0xae, 0xb3, 0xdf, 0x46, 0x08, 0x33, 0x02,
-	0x80, 0x1e, 0x43, 0xcb, 0x0b, 0x8e, 0xc2, 0x61, 0xe0, 0x5a, 0xf1, 0xd0, 0xc7, 0x89, 0x48, 0xbc,
-	0x86, 0xdc, 0xf6, 0x86, 0x3e, 0x36, 0x9b, 0x02, 0x41, 0x1b, 0x09, 0x5a, 0x83, 0x76, 0x38, 0x24,
-	0x59, 0x93, 0xca, 0xa8, 0x49, 0x4b, 0x42, 0x98, 0x8d, 0xf1, 0x1d, 0xa0, 0xd1, 0x03, 0x02, 0xba,
-	0x9d, 0x19, 0xc9, 0xac, 0x1c, 0x09, 0x03, 0x88, 0x58, 0xdd, 0x83, 0x2a, 0x3f, 0x24, 0x88, 0x50,
-	0xb5, 0x72, 0x20, 0x53, 0x28, 0x8d, 0xa7, 0x79, 0x76, 0x11, 0xa7, 0xb7, 0xb1, 0x1b, 0x6b, 0x50,
-	0x93, 0x6d, 0x1a, 0x25, 0xe2, 0xe1, 0x58, 0x46, 0x89, 0xfe, 0x56, 0x91, 0xab, 0x64, 0x22, 0xf7,
-	0x1b, 0x0d, 0xaa, 0xdc, 0xe8, 0x6f, 0x13, 0x39, 0x74, 0x13, 0xea, 0xc3, 0x80, 0xc4, 0xf4, 0x00,
-	0xed, 0xb2, 0xcf, 0xab, 0x66, 0xa6, 0x02, 0xb4, 0x04, 0xb5, 0x28, 0xc6, 0x96, 0x1b, 0xd8, 0x84,
-	0xed, 0x2c, 0x35, 0x9a, 0x3d, 0xb8, 0x1b, 0xd8, 0x84, 0x1a, 0xaa, 0xd2, 0x88, 0xed, 0x09, 0x75,
-	0x33, 0x15, 0x18, 0xff, 0xdd, 0x86, 0x29, 0xda, 0x01, 0x5a, 0x80, 0x2a, 0x3d, 0x55, 0x85, 0x81,
-	0x18, 0xba, 0x68, 0xa1, 0x47, 0x00, 0x5e, 0x64, 0x9d, 0xe1, 0x38, 0xa1, 0xba, 0x0a, 0xfb, 0xae,
-	0x75, 0xf5, 0x5d, 0xbf, 0xe6, 0x72, 0xb3, 0xee, 0x45, 0xe2, 0x27, 0xfa, 0x3b, 0xea, 0x4a, 0x48,
-	0x42, 0x27, 0xf4, 0xc5, 0xe6, 0x39, 0x9b, 0x26, 0x27, 0x13, 0x9b, 0x0a, 0x80, 0x16, 0x61, 0x26,
-	0x89, 0x1d, 0x2b, 0xc0, 0xd4, 0x6d, 0xfa, 0xf5, 0x55, 0x93, 0xd8, 0xe9, 0x63, 0x82, 0x3e, 0x86,
-	0x3a, 0x55, 0x44, 0x61, 0x4c, 0x92, 0xce, 0x34, 0x8b, 0x8e, 0xca, 0xf1, 0x30, 0x26, 0xa6, 0x1d,
-	0x9c, 0x60, 0xb3, 0x96, 0xc4, 0x0e, 0x6d, 0x25, 0x94, 0xc7, 0x4d, 0x08, 0xe3, 0xa9, 0x72, 0x1e,
-	0x37, 0x21, 0x82, 0x87, 0x2a, 0x38, 0xcf, 0xcc, 0x38, 0x1e, 0x37, 0x21, 0x9c, 0xe7, 0x16, 0xd4,
-	0x3d, 0x67, 0x10, 0x59, 0x6c, 0x11, 0xa3, 0xdb, 0xc1, 0xf4, 0xce, 0x84, 0x59, 0xa3, 0x22, 0xb6,
-	0x3e, 0xbd, 0x80, 0xb6, 0x52, 0x5b, 0x4e, 0xe8, 0xca, 0x1d, 0x40, 0x96, 0xa5, 0x3d, 0x01, 0xdc,
-	0x08, 0xdc, 0xad, 0xd0, 0x65, 0x87, 0x22, 0x69, 0x4b, 0xdb, 0xe8, 0x2e, 0xb4, 0xe9, 0xa8, 0xbc,
-	0xc8, 0x4a, 0x30, 0xb1, 0x3c, 0x37, 0xe9, 0x00, 0xf3, 0xb6, 0x91, 0xc4, 0x4e, 0x2f, 0x3a, 0xc0,
-	0xa4, 0xe7, 0x26, 0x14, 0x44, 0x5d, 0xce, 0x80, 0x1a, 0x1c, 0xe4, 0x26, 0x44, 0x81, 0x9e, 0xc1,
-	0x12, 0x0b, 0x9c, 0x3d, 0xc0, 0x2e, 0x1b, 0x5d, 0x16, 0xdf, 0x64, 0xf8, 0xeb, 0x34, 0x94, 0x54,
-	0x4f, 0x87, 0x96, 0x35, 0x64, 0x91, 0x2a, 0x35, 0x6c, 0x71, 0x43, 0x1a, 0xbb, 0x11, 0xc3, 0x35,
-	0x68, 0x06, 0x21, 0xb1, 0xd4, 0xdc, 0x1e, 0x97, 0xcf, 0x6d, 0x23, 0x08, 0x89, 0x6c, 0xa0, 0x15,
-	0xa0, 0x4d, 0x4b, 0x4e, 0xf1, 0x09, 0xa3, 0xaf, 0x07, 0x21, 0x39, 0xe0, 0xb3, 0xfc, 0x04, 0x5a,
-	0x52, 0xcf, 0x67, 0xe8, 0x74, 0xcc, 0x0c, 0x35, 0xb8, 0x0d, 0x9f, 0x24, 0xc1, 0x2a, 0x27, 0xdc,
-	0x53, 0xac, 0x5d, 0x3e, 0xe7, 0x82, 0x35, 0x9d, 0xf7, 0x7f, 0xb9, 0x82, 0xb5, 0x2b, 0xa7, 0xfe,
-	0x43, 0x6e, 0x95, 0x4e, 0xff, 0x1b, 0x36, 0xfd, 0x1a, 0x43, 0xc9, 0x89, 0x45, 0xdb, 0x80, 0x72,
-	0x28, 0x9e, 0x05, 0xfe, 0x95, 0x59, 0xa0, 0x99, 0xb3, 0x19, 0x0a, 0x96, 0x08, 0x0f, 0x38, 0x4d,
-	0x21, 0x19, 0x06, 0x7c, 0x03, 0xe2, 0x63, 0x55, 0x81, 0x17, 0xd8, 0x42, 0x4e, 0x04, 0x0a, 0xdb,
-	0xcd, 0xa4, 0xc5, 0x0b, 0xb8, 0xa5, 0x02, 0x5e, 0x3a, 0xc3, 0x11, 0x33, 0x5b, 0x14, 0x53, 0x30,
-	0x32, 0xc9, 0xc2, 0x7e, 0x7c, 0x86, 0x7c, 0xaf, 0xec, 0xbb, 0xe5, 0x49, 0x32, 0x1f, 0xc6, 0xde,
-	0x89, 0x17, 0xd8, 0x3e, 0x73, 0x22, 0xc1, 0x3e, 0x76, 0x48, 0x18, 0x77, 0x62, 0xb6, 0xa8, 0xcc,
-	0x49, 0xe5, 0x41, 0xec, 0x1c, 0x08, 0x55, 0xce, 0x86, 0x76, 0xac, 0x6c, 0x92, 0xbc, 0x4d, 0x37,
-	0x21, 0xca, 0x66, 0x1b, 0x6e, 0xe7, 0xfa, 0x49, 0x8f, 0x8b, 0xca, 0x9a, 0x30, 0xeb, 0x9b, 0x99,
-	0x1e, 0xd5, 0xa1, 0xb1, 0x94, 0x46, 0x8e, 0xb9, 0x40, 0x33, 0xcc, 0xd3, 0x88, 0x51, 0xe7, 0x69,
-	0x9e, 0xc3, 0x92, 0xa2, 0x91, 0xe1, 0x57, 0x04, 0x67, 0x8c, 0x60, 0x41, 0x02, 0xfa, 0x2c, 0xf2,
-	0x63, 0x4d, 0x73, 0x01, 0x38, 0x1f, 0x31, 0xcd, 0xc6, 0xe0, 0x6b, 0xbe, 0x04, 0x14, 0xcf, 0xf0,
-	0x03, 0x9b, 0x38, 0xa7, 0x9d, 0x8b, 0xdc, 0xb1, 0x25, 0x7f, 0x84, 0x7f, 0x45, 0x11, 0xe6, 0x42,
-	0x42, 0xdd, 0x18, 0x91, 0x53, 0x5a, 0xee, 0x44, 0x19, 0xed, 0xe5, 0xdb, 0x69, 0x5d, 0xea, 0xe2,
-	0x28, 0xed, 0x23, 0x80, 0x53, 0x42, 0x22, 0xc1, 0xf3, 0xaf, 0xb9, 0xaa, 0x65, 0xe7, 0xf0, 0x70,
-	0x9f, 0x5b, 0xd7, 0x29, 0x46, 0x1a, 0xd4, 0xe4, 0xed, 0x49, 0xe7, 0xdf, 0x72, 0xf7, 0x4e, 0x74,
-	0xbf, 0x52, 0x17, 0x24, 0x0a, 0x44, 0xab, 0x52, 0xba, 0x99, 0x5a, 0x9e, 0xdb, 0xf9, 0x51, 0xec,
-	0x61, 0xb4, 0xdd, 0x73, 0x37, 0xab, 0x30, 0x45, 0x3f, 0xd8, 0x4d, 0x80, 0x9a, 0xfc, 0x78, 0xbf,
-	0xac, 0xd6, 0x7e, 0xd0, 0xf4, 0x1f, 0x35, 0x13, 0xfc, 0xf0, 0xc4, 0x8a, 0x62, 0x7c, 0xec, 0x5d,
-	0x18, 0x2f, 0x61, 0xae, 0xcc, 0xf5, 0x65, 0xa8, 0xa9, 0x29, 0xe1, 0xc4, 0xaa, 0x4d, 0xcb, 0x69,
-	0x96, 0x34, 0xa2, 0xc6, 0xe4, 0x0d, 0xe3, 0xe7, 0x1a, 0xd4, 0xd5, 0xa0, 0x78, 0xb9, 0x4c, 0x4e,
-	0x43, 0x97, 0x97, 0x06, 0xac, 0x5c, 0x66, 0x4d, 0xf4, 0x18, 0xa6, 0x23, 0x9b, 0x9c, 0xca, 0xfd,
-	0x7f, 0xb9, 0x18, 0x8f, 0x87, 0xfb, 0x36, 0x39, 0xe5, 0x91, 0xe1, 0xc0, 0xe5, 0xaf, 0xa0, 0xae,
-	0x64, 0x68, 0x01, 0xa6, 0xf1, 0x85, 0xed, 0x10, 0xee, 0xd5, 0xce, 0x84, 0xc9, 0x9b, 0xa8, 0x03,
-	0x55, 0x3e, 0x22, 0x5e, 0xb2, 0xec, 0x4c, 0x98, 0xa2, 0xbd, 0xd9, 0x04, 0xa0, 0x3c, 0x7c, 0x16,
-	0x8c, 0xff, 0xd7, 0xa0, 0x99, 0x0d, 0x26, 0xfa, 0x02, 0x1a, 0x76, 0x10, 0x84, 0xc4, 0xa6, 0x5b,
-	0xbf, 0x2c, 0x64, 0x3e, 0x2c, 0x09, 0xfb, 0xc3, 0x8d, 0x14, 0xc6, 0x0f, 0x20, 0x59, 0xc3, 0xe5,
-	0x17, 0xa0, 0x17, 0x01, 0xef, 0x75, 0x14, 0x79, 0x0e, 0xb3, 0x85, 0x45, 0x94, 0x15, 0x66, 0x74,
-	0x55, 0xa6, 0xf6, 0xd3, 0xfc, 0xec, 0x40, 0x65, 0x6c, 0xf9, 0xad, 0x70, 0x19, 0xfd, 0x6d, 0xec,
-	0x42, 0x4d, 0x6d, 0x3f, 0x1d, 0xa8, 0x8a, 0x93, 0x9d, 0x26, 0xb6, 0x72, 0xd1, 0x46, 0xd7, 0xb3,
-	0x25, 0xdd, 0xce, 0x04, 0x2f, 0xea, 0x36, 0x75, 0x68, 0x73, 0xbd, 0x15, 0xc6, 0x6c, 0x2d, 0x30,
-	0x9e, 0x42, 0x5d, 0x6d, 0x17, 0xd4, 0xdf, 0x63, 0x2f, 0x4e, 0x88, 0xf0, 0x81, 0x37, 0xa8, 0x13,
-	0xbe, 0x9d, 0x10, 0xe9, 0x04, 0xfd, 0x6d, 0xfc, 0xaf, 0x06, 0xa8, 0x78, 0x38, 0xed, 0x75, 0xe9,
-	0x99, 0x23, 0x8c, 0x9d, 0x53, 0x9c, 0x90, 0xd8, 0x26, 0x61, 0x4c, 0x33, 0x95, 0x0f, 0xbd, 0x9d,
-	0x15, 0xf7, 0x5c, 0x74, 0x1b, 0x1a, 0xea, 0x24, 0xec, 0xf1, 0x72, 0xaf, 0x6e, 0x82, 0x14, 0x71,
-	0x80, 0x3a, 0x21, 0x7b, 0x2e, 0x2b, 0xf9, 0xea, 0x26, 0x48, 0x51, 0xcf, 0xfd, 0x72, 0xaa, 0xa6,
-	0xe9, 0x15, 0xb3, 0x46, 0x4f, 0xf6, 0x6c, 0x20, 0x17, 0xb0, 0x50, 0x7e, 0xb3, 0x8c, 0x3e, 0xca,
-	0x94, 0xc7, 0x4b, 0x63, 0x0e, 0xd6, 0xa2, 0x0c, 0xff, 0x04, 0x6a, 0xb2, 0x0b, 0x71, 0xbb, 0xb0,
-	0x38, 0xee, 0x6a, 0x59, 0x01, 0x8d, 0x5f, 0x54, 0x40, 0x2f, 0xaa, 0x69, 0x28, 0xe9, 0x49, 0x5a,
-	0x9e, 0x46, 0x78, 0xa3, 0xac, 0xd0, 0xa6, 0x69, 0x33, 0xb0, 0x1d, 0x11, 0x02, 0xfa, 0x93, 0x8e,
-	0x5d, 0x3e, 0x69, 0xd0, 0x1d, 0x89, 0xd7, 0x8d, 0x20, 0x44, 0x74, 0x13, 0xba, 0x01, 0x75, 0x2f,
-	0x3a, 0x7b, 0x42, 0x8b, 0x03, 0x5e, 0x3b, 0xd6, 0xcd, 0x1a, 0x15, 0xf4, 0x31, 0x91, 0xca, 0x75,
-	0xae, 0xac, 0x2a, 0xe5, 0x3a, 0x53, 0xde, 0x83, 0x69, 0x5a, 0xf1, 0xcb, 0x4a, 0x51, 0x16, 0x37,
-	0x87, 0x1e, 0x8e, 0x7b, 0xc1, 0x71, 0x68, 0x72, 0x2d, 0xfa, 0x08, 0x6a, 0xbc, 0x03, 0x9b, 0x74,
-	0x6a, 0x0c, 0xd9, 0x56, 0xf7, 0x92, 0x84, 0x01, 0x67, 0x58, 0x7f, 0x36, 0x11, 0xd0, 0x75, 0x06,
-	0xad, 0x8f, 0x85, 0xae, 0xf7, 0x6d, 0x62, 0x6c, 0x8d, 0x4e, 0x91, 0x38, 0xc1, 0xbc, 0xfb, 0x14,
-	0x19, 0x1b, 0xd0, 0xce, 0xde, 0xf4, 0xf4, 0xba, 0xc5, 0x54, 0xa9, 0xbc, 0x35, 0x55, 0x7c, 0x40,
-	0xa3, 0xcf, 0x24, 0xe8, 0x5e, 0xc6, 0x87, 0xf9, 0x92, 0x3b, 0x25, 0x91, 0x22, 0x8f, 0x32, 0x29,
-	0x32, 0x99, 0x5b, 0xb5, 0x73, 0x6f, 0x25, 0x69, 0x7a, 0xfc, 0xa9, 0x02, 0xcd, 0xac, 0xaa, 0xec,
-	0x9c, 0x5a, 0x9c, 0xf2, 0xca, 0xc8, 0x94, 0xab, 0x89, 0x9b, 0xbc, 0x72, 0xe2, 0x1e, 0xc2, 0x1c,
-	0xbe, 0x88, 0xb0, 0x43, 0xb0, 0x6b, 0xb1, 0x19, 0xb4, 0x5d, 0x37, 0x96, 0x29, 0x74, 0x4d, 0xaa,
-	0x7a, 0xd1, 0xd9, 0x93, 0x0d, 0xaa, 0x28, 0xe2, 0xd7, 0x05, 0x7e, 0x7a, 0x04, 0xbf, 0xce, 0xf1,
-	0x9f, 0xc2, 0xac, 0x3a, 0x93, 0x59, 0xdc, 0xa1, 0x6a, 0xb9, 0x43, 0x6d, 0x85, 0x3b, 0x64, 0x9e,
-	0x3d, 0x85, 0xb6, 0x3c, 0xc0, 0x59, 0x57, 0xa6, 0x60, 0x53, 0x9c, 0xeb, 0xb8, 0xd9, 0x13, 0x68,
-	0x1d, 0x87, 0xf1, 0xb9, 0x1d, 0xcb, 0xee, 0x6a, 0x63, 0xac, 0x04, 0x8a, 0x59, 0x19, 0x7f, 0x9f,
-	0x9f, 0x61, 0x91, 0x65, 0xef, 0x36, 0xc3, 0x46, 0x0c, 0x35, 0x49, 0x5b, 0x3a, 0x57, 0x1f, 0x81,
-	0xee, 0x05, 0x27, 0x31, 0x4e, 0x12, 0xfe, 0xb0, 0xe7, 0xa9, 0xcd, 0x71, 0x56, 0xc8, 0xf7, 0x85,
-	0x98, 0xae, 0x87, 0xb8, 0x80, 0x14, 0x77, 0x30, 0x38, 0x07, 0x34, 0x9e, 0xc1, 0x8c, 0xf8, 0x5c,
-	0xd0, 0x3c, 0x54, 0xf1, 0x05, 0x2d, 0x49, 0xe5, 0xd2, 0x81, 0x2f, 0x48, 0x2f, 0xa2, 0x62, 0x96,
-	0xe0, 0x91, 0xdc, 0x4c, 0xa8, 0xc3, 0x91, 0x61, 0xc2, 0x5c, 0xc9, 0x95, 0x2d, 0xba, 0x0b, 0x2d,
-	0x2f, 0x09, 0x2d, 0xe2, 0x0d, 0x70, 0x42, 0xec, 0x81, 0xe4, 0x6a, 0x7a, 0x49, 0x78, 0x28, 0x65,
-	0xf4, 0x44, 0x3c, 0x8c, 0x28, 0x84, 0x51, 0x6a, 0xa6, 0x68, 0x19, 0x11, 0x74, 0xc6, 0x5d, 0xd7,
-	0xbe, 0xeb, 0x57, 0xf2, 0x31, 0x54, 0xf9, 0x45, 0xa2, 0xb8, 0xcf, 0x90, 0xd0, 0xc2, 0x45, 0xa5,
-	0x00, 0x19, 0xab, 0xd0, 0xce, 0x6b, 0xa8, 0x6f, 0x82, 0x40, 0x54, 0x3a, 0x02, 0xb9, 0x51, 0xe6,
-	0xdb, 0xfb, 0xcd, 0xef, 0x05, 0xdc, 0xbc, 0xea, 0x16, 0xf7, 0x7d, 0xf6, 0x8b, 0xf7, 0x1c, 0x66,
-	0x6f, 0x5c, 0xcf, 0xef, 0xbf, 0x0c, 0xae, 0xc3, 0x7c, 0xe9, 0x6d, 0x2c, 0xba, 0x05, 0x10, 0x0d,
-	0x8f, 0x7c, 0xcf, 0xb1, 0xd2, 0x62, 0xa4, 0xce, 0x25, 0x5f, 0xe1, 0x4b, 0xe3, 0x15, 0xff, 0x32,
-	0x0a, 0x8f, 0x8f, 0xcb, 0xa0, 0x56, 0x47, 0x59, 0x00, 0xca, 0xb6, 0xda, 0x6c, 0xe8, 0xca, 0x20,
-	0x72, 0x8f, 0x6d, 0x0e, 0x74, 0x41, 0x28, 0xd2, 0x89, 0x71, 0xfc, 0xc5, 0x74, 0xdb, 0xd0, 0xce,
-	0x3f, 0x5e, 0x96, 0x5c, 0x7d, 0x4e, 0x45, 0x61, 0xe8, 0x8b, 0x78, 0xcf, 0x16, 0x9f, 0x2b, 0x99,
-	0xd2, 0xb8, 0x93, 0xd2, 0x8c, 0xb9, 0xd4, 0x7c, 0x01, 0x35, 0x89, 0x60, 0x45, 0x96, 0xe7, 0xaa,
-	0x1b, 0x31, 0xfa, 0x1b, 0xad, 0x00, 0x0c, 0xec, 0xe4, 0xfb, 0x21, 0x8e, 0x6d, 0x51, 0x7e, 0xd5,
-	0xcc, 0x8c, 0xc4, 0xf8, 0x95, 0x06, 0xd7, 0xcb, 0xde, 0x22, 0xd1, 0xfd, 0xcc, 0x14, 0x2e, 0x96,
-	0x9e, 0x22, 0x44, 0xea, 0x7c, 0x0e, 0x55, 0xdf, 0x3e, 0xc2, 0xbe, 0x2c, 0x8d, 0xef, 0x5f, 0xf1,
-	0xc2, 0xf9, 0x70, 0x97, 0x21, 0xc5, 0x45, 0x38, 0x37, 0x5b, 0x7e, 0x0e, 0x8d, 0x8c, 0xf8, 0xbd,
-	0xaa, 0xcf, 0xcf, 0x8b, 0xce, 0xab, 0x17, 0x83, 0x77, 0x73, 0xde, 0xe8, 0x82, 0x5e, 0x94, 0xe7,
-	0xaf, 0xe1, 0xb4, 0xc2, 0x35, 0x5c, 0xe9, 0x15, 0xe3, 0x2f, 0x35, 0x98, 0x2d, 0x3c, 0x96, 0x22,
-	0x23, 0xe3, 0x02, 0x2a, 0xbe, 0x85, 0x8a, 0xd0, 0x7d, 0x56, 0x08, 0x9d, 0x51, 0xfe, 0xf0, 0xfa,
-	0xd7, 0x8e, 0xda, 0xd3, 0x8c, 0xb7, 0x22, 0x60, 0xef, 0xe0, 0xad, 0xf1, 0x01, 0x34, 0x32, 0xa2,
-	0xd2, 0x5b, 0xea, 0x43, 0x00, 0xfe, 0xe6, 0x79, 0x28, 0x8a, 0x7e, 0x2f, 0x12, 0xcb, 0x7f, 0xcd,
-	0x64, 0xbf, 0x99, 0x57, 0x17, 0xbe, 0x1d, 0x88, 0x54, 0xe4, 0x0d, 0x1a, 0x72, 0xf5, 0xf2, 0x22,
-	0xaf, 0x4c, 0x95, 0xc0, 0xf8, 0x5d, 0x05, 0x1a, 0x99, 0x57, 0x60, 0xf4, 0x61, 0xe6, 0x80, 0x91,
-	0x5e, 0x71, 0x32, 0x44, 0xfa, 0x5c, 0x81, 0x3e, 0x81, 0xa6, 0x17, 0xf1, 0xff, 0x0c, 0x60, 0x68,
-	0x7e, 0x21, 0x7a, 0x4d, 0x7d, 0x68, 0xf4, 0x93, 0x61, 0x70, 0xf0, 0x22, 0xf9, 0x9b, 0x86, 0xd1,
-	0x4d, 0x88, 0xac, 0x61, 0xdd, 0x84, 0x20, 0x03, 0x5a, 0xec, 0xbe, 0x21, 0x74, 0x31, 0x3b, 0x68,
-	0x88, 0x0a, 0xbe, 0xe1, 0x26, 0xa4, 0x1f, 0xba, 0x98, 0x46, 0x04, 0xad, 0x40, 0x43, 0x61, 0xbc,
-	0x48, 0x5e, 0xdd, 0x0a, 0x44, 0x2f, 0xa2, 0x45, 0x51, 0x62, 0x0f, 0xb0, 0x95, 0x0c, 0x8f, 0x02,
-	0x4c, 0xd8, 0xd3, 0x58, 0xcd, 0x04, 0x2a, 0x3a, 0x60, 0x12, 0xf4, 0x01, 0x34, 0x69, 0x39, 0x11,
-	0x0e, 0xc9, 0x49, 0xe8, 0x05, 0x27, 0xec, 0x3e, 0xb3, 0x66, 0x36, 0x02, 0x9b, 0xec, 0x09, 0x11,
-	0xba, 0x07, 0x6d, 0x3f, 0x74, 0x6c, 0xdf, 0x92, 0x67, 0x0b, 0x76, 0xa1, 0x59, 0x33, 0x5b, 0x4c,
-	0x2a, 0x17, 0x57, 0xb4, 0x06, 0x0d, 0xc2, 0x66, 0x80, 0x0f, 0x9a, 0xff, 0x13, 0x8c, 0x1c, 0x74,
-	0x3a, 0x37, 0x26, 0x10, 0xf5, 0xdb, 0xb8, 0x2d, 0xc2, 0x2b, 0x72, 0x41, 0xc4, 0xa0, 0xa2, 0x62,
-	0x60, 0xfc, 0xa7, 0x06, 0x4b, 0x63, 0x5f, 0xc5, 0x59, 0x22, 0xd0, 0xb3, 0x9d, 0x4c, 0x04, 0x7a,
-	0x06, 0x14, 0x67, 0x81, 0x4a, 0x7a, 0x16, 0xc8, 0x2d, 0x97, 0x93, 0xf9, 0xe5, 0x12, 0xad, 0x82,
-	0x1e, 0xd9, 0x31, 0x0e, 0x88, 0xe5, 0x62, 0x76, 0x97, 0xe1, 0x45, 0x22, 0xce, 0x6d, 0x2e, 0xef,
-	0x32, 0x71, 0x2f, 0x32, 0x1e, 0x95, 0x7a, 0x22, 0x3c, 0x2f, 0xf1, 0xc4, 0xf8, 0x0f, 0x0d, 0x16,
-	0xc7, 0xbc, 0x9c, 0x5f, 0xb9, 0xbc, 0xe7, 0xb7, 0x9f, 0x4a, 0x61, 0xfb, 0xa1, 0xf5, 0xa6, 0x17,
-	0x10, 0x1c, 0x1f, 0xdb, 0xcc, 0xdb, 0xfc, 0xc0, 0xae, 0x29, 0x95, 0x2c, 0x50, 0x8d, 0xa7, 0x25,
-	0x5e, 0xbc, 0x7d, 0x93, 0x31, 0x7e, 0xad, 0xc1, 0x7c, 0xe9, 0xe3, 0x39, 0x5a, 0x83, 0x79, 0x79,
-	0xf1, 0xe3, 0xf8, 0xc3, 0x84, 0xe0, 0xd8, 0xa2, 0x0b, 0xbe, 0xbc, 0xb8, 0x98, 0x13, 0xca, 0x2d,
-	0xae, 0xdb, 0xa2, 0x2a, 0xf4, 0x24, 0xfd, 0x3f, 0x12, 0x7c, 0x41, 0x70, 0x1c, 0xd8, 0xbe, 0x30,
-	0xaa, 0x88, 0x7b, 0x68, 0xae, 0xdd, 0x16, 0x4a, 0x6e, 0xf5, 0x0f, 0xb0, 0x2c, 0xad, 0x68, 0x8a,
-	0x1d, 0xd9, 0xbe, 0x1d, 0x38, 0xaa, 0x3b, 0x5e, 0x06, 0x76, 0x04, 0x62, 0x37, 0x03, 0x60, 0xd6,
-	0x0f, 0x56, 0xa1, 0xae, 0x1e, 0x1f, 0xd0, 0x0c, 0x4c, 0x6e, 0xf4, 0xbf, 0xd5, 0x27, 0x50, 0x0d,
-	0xa6, 0x7a, 0xfb, 0xaf, 0x9f, 0xe8, 0x53, 0xe2, 0xd7, 0xba, 0x5e, 0x7d, 0xf0, 0x5f, 0x1a, 0xd4,
-	0xd5, 0x47, 0x8c, 0x5a, 0x50, 0xdf, 0xea, 0x75, 0x4d, 0xab, 0xd7, 0xff, 0x62, 0x4f, 0x9f, 0x40,
-	0x73, 0x30, 0x6b, 0x6e, 0xbf, 0xda, 0x3b, 0xdc, 0xb6, 0xbe, 0xd9, 0x33, 0xbf, 0xda, 0xdd, 0xdb,
-	0xe8, 0xea, 0x1a, 0x9a, 0x85, 0x86, 0x10, 0xee, 0xec, 0x1d, 0x1c, 0xea, 0x15, 0x84, 0xa0, 0xbd,
-	0xbb, 0xb7, 0xb5, 0xb1, 0x9b, 0x82, 0x26, 0x51, 0x1b, 0x80, 0xcb, 0x18, 0x66, 0x0a, 0x5d, 0x83,
-	0x96, 0x30, 0x3a, 0xfc, 0xba, 0xdf, 0xdf, 0xde, 0xd5, 0xa7, 0x91, 0x0e, 0x4d, 0x0e, 0x11, 0x92,
-	0xea, 0x83, 0xe7, 0x00, 0xe9, 0x0a, 0x41, 0x7d, 0xec, 0xef, 0xf5, 0xb7, 0xf5, 0x09, 0xd4, 0x84,
-	0x5a, 0x7f, 0xcf, 0xda, 0xee, 0x6f, 0x6d, 0xec, 0xeb, 0x1a, 0xaa, 0xc3, 0x34, 0x4b, 0x46, 0xbd,
-	0xc2, 0x87, 0xd1, 0xdb, 0xd7, 0x27, 0xd7, 0x5e, 0x00, 0xf0, 0xe7, 0x26, 0xf6, 0x9f, 0x8e, 0x8f,
-	0x61, 0x8a, 0xfd, 0x95, 0x8b, 0x6a, 0xe6, 0xff, 0x27, 0x97, 0xa5, 0x2c, 0xf3, 0x3f, 0x94, 0x8f,
-	0xb5, 0xcd, 0xc5, 0x1f, 0x7e, 0x5a, 0xd1, 0x7e, 0xfb, 0xd3, 0x8a, 0xf6, 0xfb, 0x9f, 0x56, 0xb4,
-	0xff, 0xfb, 0xe3, 0xca, 0xc4, 0x3f, 0x4d, 0xb3, 0x9b, 0xfc, 0xa3, 0x2a, 0xfb, 0xf3, 0xc9, 0x9f,
-	0x03, 0x00, 0x00, 0xff, 0xff, 0x82, 0xf1, 0x6b, 0xf7, 0xa1, 0x29, 0x00, 0x00,
+	// 3965 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbd, 0x1a, 0x4d, 0x73, 0x1c, 0x57,
+	0xd1, 0xbb, 0x92, 0x56, 0xbb, 0xbd, 0x5f, 0xa3, 0xd1, 0xd7, 0x4a, 0xfe, 0xcc, 0x24, 0xa9, 0x38,
+	0x4e, 0xc5, 0x31, 0x4e, 0x22, 0xc7, 0x09, 0xe5, 0x94, 0x2c, 0x29, 0xb6, 0x12, 0x59, 0x52, 0x8d,
+	0x64, 0x87, 0xa4, 0x42, 0x0d, 0xa3, 0xdd, 0x91, 0x34, 0x78, 0x35, 0x33, 0x99, 0x99, 0xd5, 0x07,
+	0x70, 0xa2, 0xb8, 0xe4, 0x04, 0x27, 0x0a, 0xee, 0xdc, 0xa0, 0x38, 0x70, 0xe5, 0xc0, 0x89, 0xaa,
+	0xe4, 0x46, 0x71, 0xa7, 0x8a, 0x02, 0xfe, 0x00, 0xff, 0x80, 0xee, 0xf7, 0x35, 0x1f, 0x3b, 0x2b,
+	0xdb, 0x81, 0xe2, 0x60, 0x79, 0x5f, 0x7f, 0xbd, 0x7e, 0xfd, 0xba, 0xdf, 0xeb, 0xee, 0x37, 0xa0,
+	0xef, 0x3b, 0x7d, 0xf7, 0x74, 0xcf, 0xee, 0x3e, 0x75, 0xbc, 0xde, 0xcd, 0x20, 0xf4, 0x63, 0x5f,
+	0x9f, 0x60, 0x30, 0xe3, 0x26, 0xd4, 0x77, 0xce, 0xbc, 0xae, 0xe9, 0x7c, 0x39, 0x70, 0xa2, 0x58,
+	0xbf, 0x0a, 0x75, 0x3b, 0x70, 0xad, 0x63, 0x27, 0x8c, 0x5c, 0xdf, 0xeb, 0x94, 0xae, 0x95, 0xae,
+	0x37, 0x4d, 0x40, 0xd0, 0x13, 0x0e, 0x31, 0x7e, 0xaf, 0x43, 0x7d, 0xd7, 0x5f, 0xb5, 0x63, 0x3b,
+	0xe8, 0xdb, 0x9e, 0xa3, 0x5f, 0x87, 0x49, 0xd7, 0xb3, 0x22, 0x14, 0xc1, 0x88, 0xeb, 0xb7, 0x9b,
+	0x37, 0x99, 0xe0, 0x9b, 0xeb, 0x1e, 0xc9, 0x7d, 0x78, 0xc1, 0xac, 0xb8, 0xec, 0x97, 0x7e, 0x07,
+	0x1a, 0x6e, 0x10, 0x39, 0xb1, 0x35, 0x08, 0x7a, 0x76, 0xec, 0x74, 0xca, 0x8c, 0x5c, 0x97, 0xe4,
+	0xdb, 0x3b, 0x4e, 0xfc, 0x98, 0x61, 0x90, 0xa7, 0xce, 0x28, 0xf9, 0x50, 0x7f, 0x00, 0x3a, 0x67,
+	0xec, 0x39, 0xfd, 0xd8, 0x96, 0xec, 0x63, 0x8c, 0x7d, 0x3e, 0xcd, 0xbe, 0x4a, 0x78, 0x25, 0x43,
+	0x63, 0x4c, 0x29, 0x58, 0xa2, 0x41, 0xe8, 0x1c, 0xf9, 0xc7, 0x4e, 0x67, 0x7c, 0x58, 0x03, 0x93,
+	0x61, 0x94, 0x06, 0x7c, 0xa8, 0x6f, 0xc3, 0xac, 0xdd, 0x8d, 0xdd, 0x63, 0xc7, 0x42, 0xdb, 0xed,
+	0xbb, 0x7d, 0x47, 0x2a, 0x31, 0xc1, 0x24, 0x2c, 0x0a, 0x09, 0xcb, 0x8c, 0x66, 0x9b, 0x93, 0x28,
+	0x3d, 0xa6, 0xed, 0x61, 0x70, 0x81, 0x44, 0xa1, 0x53, 0x65, 0xb4, 0x44, 0xa5, 0x5b, 0x56, 0xa2,
+	0xd0, 0xf1, 0x11, 0xcc, 0x48, 0x89, 0x7e, 0xdf, 0xed, 0x9e, 0x49, 0x15, 0x27, 0x99, 0xc0, 0x85,
+	0xac, 0x40, 0x46, 0xa1, 0x34, 0xd4, 0xed, 0x21, 0xe8, 0xb0, 0x38, 0xa1, 0x5f, 0x75, 0xa4, 0x38,
+	0xa5, 0x5e, 0x46, 0x5c, 0xa2, 0xdd, 0xa1, 0x1f, 0xc5, 0x16, 0xfa, 0x5f, 0xe0, 0xbb, 0x9e, 0x72,
+	0x82, 0x5a, 0x46, 0xdc, 0x43, 0x24, 0x59, 0x13, 0x14, 0x89, 0x76, 0x87, 0x43, 0xd0, 0x61, 0x71,
+	0x42, 0x3b, 0x18, 0x29, 0x2e, 0xd1, 0xee, 0x70, 0x08, 0xaa, 0x7f, 0x06, 0x9d, 0x13, 0x3f, 0x7c,
+	0xda, 0xf7, 0xed, 0xde, 0x90, 0x86, 0x75, 0x26, 0xf2, 0xb2, 0x10, 0xf9, 0xa9, 0x20, 0x1b, 0xd2,
+	0x72, 0xee, 0xa4, 0x10, 0x53, 0x2c, 0x5a, 0x68, 0xdb, 0x38, 0x57, 0xb4, 0xd2, 0x78, 0x48, 0xb4,
+	0xd0, 0xfa, 0x7d, 0x68, 0x76, 0x7d, 0x6f, 0xdf, 0x3d, 0x90, 0xaa, 0x36, 0x99, 0xbc, 0x69, 0x21,
+	0x6f, 0x85, 0xe1, 0x94, 0x82, 0x8d, 0x6e, 0x6a, 0xac, 0x0c, 0x78, 0xe4, 0xc4, 0x36, 0x02, 0x54,
+	0x54, 0xb5, 0x86, 0x0c, 0xf8, 0x48, 0x50, 0x64, 0xf7, 0x23, 0x0b, 0xd5, 0x5f, 0x83, 0x76, 0x44,
+	0x27, 0x88, 0xd7, 0x75, 0x2c, 0x6f, 0x70, 0xb4, 0xe7, 0x84, 0x9d, 0x36, 0x4a, 0x1a, 0x37, 0x5b,
+	0x12, 0xbc, 0xc9, 0xa0, 0xfa, 0x32, 0x60, 0x58, 0xda, 0x47, 0xe8, 0x54, 0x7e, 0x5f, 0xce, 0xa9,
+	0xb1, 0x39, 0x67, 0x55, 0x18, 0x2e, 0x3f, 0xda, 0x46, 0xac, 0x9a, 0xaf, 0x45, 0x0c, 0x09, 0x24,
+	0x2b, 0x42, 0x58, 0x72, 0xaa, 0x50, 0x84, 0xb2, 0xa0, 0x12, 0x91, 0xf3, 0x46, 0xb5, 0x7a, 0x21,
+	0x46, 0x1f, 0xb9, 0xfa, 0xac, 0xfb, 0x64, 0xa1, 0xfa, 0x0e, 0xcc, 0x45, 0x4e, 0x78, 0xec, 0xe2,
+	0xe2, 0xed, 0x6e, 0xd7, 0x1f, 0x24, 0xce, 0x33, 0xcd, 0x04, 0x5e, 0x14, 0x02, 0x77, 0x38, 0xd1,
+	0x32, 0xa7, 0x51, 0x0b, 0x9c, 0x89, 0x0a, 0xe0, 0x45, 0x42, 0x85, 0x96, 0x33, 0xe7, 0x08, 0x55,
+	0x7a, 0xe6, 0x84, 0x0a, 0x4d, 0x57, 0x40, 0xf3, 0xec, 0x23, 0x27, 0x0a, 0xec, 0xae, 0x3a, 0xc3,
+	0x66, 0x99, 0xb8, 0x39, 0x21, 0x6e, 0x53, 0xa2, 0x95, 0x7a, 0x6d, 0x2f, 0x0b, 0xca, 0x0a, 0x11,
+	0x3a, 0xcd, 0x15, 0x0b, 0x51, 0xea, 0x24, 0x42, 0x84, 0x26, 0x78, 0x16, 0x87, 0xfe, 0x20, 0x56,
+	0x5a, 0xcc, 0x67, 0xce, 0x62, 0x93, 0x50, 0xc9, 0x6d, 0x10, 0x26, 0xc3, 0x84, 0x51, 0xcc, 0xdc,
+	0x19, 0x66, 0x4c, 0x0e, 0xf1, 0x30, 0x19, 0xa2, 0xda, 0xf5, 0xe3, 0xd8, 0x09, 0xe4, 0x84, 0x0b,
+	0x8c, 0xef, 0x9a, 0xe0, 0x7b, 0xf2, 0xbd, 0x8d, 0xe5, 0xcd, 0xdd, 0x81, 0xe7, 0x39, 0xfd, 0xa1,
+	0xd0, 0x06, 0x62, 0x53, 0x6b, 0xe7, 0x42, 0xc4, 0xe4, 0x8b, 0xcf, 0x12, 0xa2, 0x54, 0x61, 0x42,
+	0x84, 0x26, 0x5f, 0xc0, 0xc2, 0x89, 0x1b, 0x3a, 0x07, 0x03, 0x3b, 0x1c, 0x3e, 0x6f, 0x2e, 0x32,
+	0x91, 0x57, 0xe4, 0xa1, 0x20, 0xe9, 0x86, 0xb4, 0x9a, 0x3f, 0x29, 0x46, 0x8d, 0x90, 0x2e, 0x14,
+	0xbe, 0x74, 0xbe, 0x74, 0xa5, 0xee, 0xb0, 0x74, 0xa1, 0xfb, 0xa7, 0xd0, 0x39, 0xe8, 0xfb, 0x7b,
+	0x76, 0xdf, 0xda, 0x3b, 0x08, 0xac, 0xec, 0xf9, 0x73, 0x99, 0x09, 0xbf, 0x24, 0x84, 0x3f, 0x60,
+	0x64, 0xf7, 0x1f, 0x6c, 0xe7, 0x0e, 0xa2, 0x59, 0xce, 0x7f, 0xff, 0x20, 0x48, 0x23, 0xf4, 0xef,
+	0xc3, 0x62, 0xf6, 0xc2, 0xc9, 0xdc, 0xf6, 0x57, 0x32, 0x7a, 0xa7, 0xaf, 0x9d, 0xec, 0xa5, 0x3f,
+	0x6f, 0x17, 0xa3, 0xf4, 0x3e, 0x5c, 0x1d, 0x3e, 0x87, 0xa3, 0xd8, 0x8e, 0x07, 0x91, 0x9c, 0xe3,
+	0x2a, 0x9b, 0xe3, 0xe5, 0x11, 0xc7, 0xf1, 0x0e, 0xa3, 0x55, 0x13, 0x5d, 0x3a, 0x39, 0x07, 0x7f,
+	0xbf, 0x06, 0x93, 0x81, 0x7d, 0x46, 0x68, 0xe3, 0x5f, 0x13, 0xd0, 0xfc, 0x28, 0xf4, 0x8f, 0x92,
+	0x94, 0x09, 0xef, 0x7e, 0xbc, 0xf4, 0xbb, 0x4e, 0x14, 0xe5, 0x14, 0x18, 0xcb, 0xdc, 0xfd, 0xdb,
+	0x9c, 0x26, 0x37, 0xef, 0x74, 0x30, 0x0c, 0xd6, 0x7f, 0x00, 0x17, 0xb3, 0xd7, 0x61, 0x56, 0x2e,
+	0xcf, 0x73, 0xae, 0x16, 0xdc, 0x8a, 0x39, 0xe1, 0x9d, 0xc3, 0x11, 0xb8, 0x91, 0x33, 0x08, 0xb7,
+	0x9a, 0x78, 0xc6, 0x0c, 0xca, 0xaf, 0x0a, 0x66, 0x10, 0x8e, 0xf5, 0x1c, 0x1b, 0x54, 0xf9, 0x9f,
+	0x6d, 0xd0, 0xb9, 0xb3, 0x89, 0x35, 0x4d, 0x3e, 0xc7, 0x6c, 0x6a, 0x5d, 0x23, 0x66, 0x13, 0x6b,
+	0x2b, 0xb8, 0x1e, 0xab, 0x85, 0xd7, 0xe3, 0x13, 0x48, 0x02, 0x2f, 0xb7, 0xf8, 0x5a, 0x26, 0xb8,
+	0x54, 0xe4, 0xe6, 0x56, 0x3d, 0x7b, 0x52, 0x84, 0xd0, 0x1f, 0xc3, 0x5c, 0x4f, 0xfa, 0x9f, 0xd5,
+	0xb5, 0x03, 0x7b, 0xcf, 0xed, 0xbb, 0xb1, 0xeb, 0x44, 0x22, 0x63, 0x92, 0x62, 0x95, 0x93, 0xae,
+	0xa4, 0x68, 0x48, 0x6c, 0xaf, 0x08, 0x91, 0x76, 0xf3, 0x5f, 0x97, 0x60, 0xb6, 0x90, 0x5b, 0xd7,
+	0x61, 0xdc, 0x0d, 0x8e, 0x97, 0x58, 0x79, 0x50, 0x35, 0xd9, 0x6f, 0x7d, 0x06, 0x26, 0x8e, 0x4f,
+	0x91, 0x92, 0x15, 0x01, 0x55, 0x93, 0x0f, 0xf4, 0x4b, 0x50, 0x53, 0xea, 0xb3, 0x60, 0xa8, 0x9a,
+	0x09, 0x40, 0x7f, 0x0f, 0x3a, 0x76, 0x10, 0x60, 0x5c, 0xdb, 0x31, 0x16, 0x22, 0x56, 0xdf, 0x3e,
+	0x73, 0x42, 0x71, 0x56, 0x30, 0x0f, 0xaf, 0x9a, 0x73, 0x29, 0xfc, 0x06, 0xa1, 0xf9, 0x31, 0x60,
+	0xfc, 0xb4, 0x04, 0x8d, 0xcc, 0x59, 0x73, 0x07, 0x2a, 0xfc, 0xe4, 0x42, 0xa5, 0xc6, 0x52, 0x8e,
+	0x9b, 0x26, 0x12, 0x83, 0x35, 0x2f, 0x0e, 0xcf, 0x4c, 0x41, 0xbe, 0x78, 0x17, 0xea, 0x29, 0xb0,
+	0xae, 0xc1, 0xd8, 0x53, 0xe7, 0x8c, 0xad, 0xac, 0x66, 0xd2, 0x4f, 0xb6, 0x30, 0xbb, 0x3f, 0xe0,
+	0xd5, 0x4d, 0xcd, 0xe4, 0x83, 0xf7, 0xcb, 0xef, 0x95, 0x8c, 0x2a, 0x54, 0x78, 0x49, 0x64, 0xfc,
+	0xaa, 0x04, 0xf5, 0x54, 0xb9, 0xa3, 0xb7, 0xa0, 0xec, 0xf6, 0x84, 0x10, 0xfc, 0xa5, 0x77, 0x60,
+	0xf2, 0xc8, 0x21, 0x77, 0x88, 0x50, 0xca, 0x18, 0x02, 0xe5, 0x50, 0xbf, 0x05, 0xe3, 0xf1, 0x59,
+	0xc0, 0x0f, 0x8a, 0x96, 0xda, 0xb4, 0x94, 0x2c, 0xfe, 0x7b, 0x17, 0x69, 0x4c, 0x46, 0x69, 0xbc,
+	0x09, 0x35, 0x05, 0xd2, 0x2b, 0x50, 0x5e, 0xdf, 0xd6, 0x2e, 0xe8, 0x6d, 0x9a, 0xdf, 0x5a, 0xde,
+	0x5c, 0xb5, 0xb6, 0xb7, 0xcc, 0x5d, 0xad, 0xa4, 0x4f, 0xc2, 0xd8, 0xe6, 0xda, 0xae, 0x56, 0x36,
+	0x02, 0xd0, 0xf2, 0x95, 0xd4, 0x90, 0x7a, 0x2f, 0x43, 0xd3, 0xee, 0xf5, 0x9c, 0x9e, 0x95, 0x55,
+	0xb2, 0xc1, 0x80, 0x8f, 0x84, 0xa6, 0xe8, 0xf1, 0x3c, 0x8c, 0x12, 0xb2, 0x31, 0x46, 0xd6, 0x12,
+	0x60, 0x41, 0x68, 0x5c, 0x16, 0xb6, 0x10, 0x91, 0x92, 0x9b, 0xcc, 0xb0, 0x61, 0xba, 0xa0, 0xaa,
+	0xd2, 0xaf, 0x29, 0xb2, 0xfa, 0x6d, 0x2d, 0x39, 0x2f, 0x89, 0x62, 0x7d, 0x95, 0x69, 0x89, 0x75,
+	0xa9, 0xa8, 0xac, 0x44, 0xa1, 0xd9, 0xca, 0x92, 0x99, 0x12, 0x6d, 0xdc, 0xc9, 0x4d, 0x21, 0x34,
+	0x79, 0xe6, 0x14, 0xc6, 0x55, 0xa8, 0x29, 0x00, 0x79, 0x39, 0xa5, 0x38, 0x42, 0x75, 0xf6, 0xdb,
+	0xf0, 0x61, 0x52, 0x10, 0xe0, 0xce, 0x35, 0x5d, 0x6f, 0x0f, 0x33, 0xb1, 0x9e, 0x15, 0x0e, 0xfa,
+	0x18, 0x77, 0xdc, 0xf1, 0xea, 0x32, 0x6d, 0x41, 0x98, 0xd9, 0x10, 0x14, 0x34, 0x88, 0xf4, 0xdb,
+	0xd0, 0xc2, 0xe4, 0x25, 0xcd, 0x52, 0x1e, 0x66, 0x69, 0x4a, 0x12, 0xc6, 0x63, 0x7c, 0x01, 0xfa,
+	0x70, 0x81, 0x87, 0x35, 0x7d, 0xb2, 0x92, 0xb6, 0x5c, 0x09, 0x23, 0x10, 0xb6, 0x7a, 0x15, 0x2a,
+	0x22, 0x8e, 0xca, 0x99, 0x12, 0x5e, 0x54, 0x70, 0x02, 0x69, 0xbc, 0x9b, 0x95, 0x2e, 0xec, 0xf4,
+	0x2c, 0xe9, 0xc6, 0x57, 0x65, 0x98, 0x1f, 0x71, 0x61, 0x3f, 0x5b, 0xb5, 0xbb, 0x79, 0xbb, 0x71,
+	0x0d, 0x67, 0x52, 0x46, 0xd8, 0x70, 0x23, 0xee, 0xaf, 0x39, 0x03, 0x7e, 0x30, 0x64, 0xc0, 0xb1,
+	0x73, 0x78, 0xb3, 0x96, 0xa4, 0xa3, 0x48, 0x65, 0xac, 0xec, 0x74, 0xa9, 0x99, 0x09, 0x80, 0xb0,
+	0x98, 0x53, 0x87, 0xd4, 0x4f, 0xe9, 0xb1, 0xbb, 0x0f, 0x0f, 0x2a, 0x05, 0xd0, 0x17, 0xa0, 0x1a,
+	0x84, 0x8e, 0xd5, 0xf3, 0xec, 0x98, 0x5d, 0x59, 0x55, 0xf2, 0x35, 0x67, 0x15, 0x87, 0xc6, 0x37,
+	0x25, 0x68, 0x66, 0xe6, 0xa5, 0x68, 0xc2, 0x1b, 0xc1, 0x1a, 0x78, 0xdd, 0x43, 0xdb, 0x3b, 0x70,
+	0x7a, 0xa2, 0xe5, 0xd2, 0x40, 0xe0, 0x63, 0x09, 0x43, 0x67, 0xae, 0x79, 0xce, 0xc9, 0x68, 0x37,
+	0xa8, 0x22, 0x96, 0xeb, 0xfd, 0x16, 0xcc, 0x64, 0xc4, 0x59, 0x36, 0xbb, 0xb3, 0xd9, 0xd2, 0x9b,
+	0xe6, 0x54, 0x5a, 0xea, 0x32, 0xdd, 0xc3, 0x78, 0xaa, 0x2e, 0xe4, 0x89, 0xd9, 0x3c, 0x96, 0xdb,
+	0x8b, 0x70, 0xe1, 0x14, 0xb2, 0xb3, 0x83, 0x0c, 0x0b, 0x4d, 0xb4, 0xde, 0x8b, 0x8c, 0xdb, 0x50,
+	0x95, 0x5b, 0x45, 0xde, 0x8f, 0x67, 0x7d, 0x28, 0xbd, 0x9f, 0x7e, 0xab, 0x88, 0x28, 0xa7, 0x22,
+	0xe2, 0xcf, 0x25, 0xa8, 0x70, 0xa6, 0xff, 0x4f, 0x44, 0x64, 0x77, 0x6a, 0xec, 0xbc, 0x9d, 0x1a,
+	0xcf, 0xec, 0x54, 0xd6, 0x01, 0x26, 0x72, 0x0e, 0x60, 0xfc, 0xb6, 0x05, 0xe3, 0x34, 0x81, 0x3e,
+	0x07, 0x15, 0xca, 0x38, 0x45, 0xab, 0xac, 0x66, 0x8a, 0x11, 0xee, 0x03, 0xb8, 0x81, 0x6a, 0xa3,
+	0x95, 0xd9, 0x79, 0xad, 0xa9, 0xf3, 0x5a, 0x34, 0xd3, 0xcc, 0x9a, 0x1b, 0x88, 0x9f, 0xfa, 0x1b,
+	0xa4, 0x8a, 0x1f, 0xfb, 0x5d, 0xbf, 0x2f, 0xfc, 0xb4, 0x9d, 0x1c, 0x3a, 0x0c, 0x6c, 0x2a, 0x02,
+	0x7d, 0x1e, 0x26, 0xa3, 0xb0, 0x6b, 0x79, 0x4e, 0x2c, 0xb6, 0xa8, 0x82, 0xc3, 0x4d, 0x27, 0xd6,
+	0xf1, 0xb8, 0x27, 0x44, 0xe0, 0x87, 0x71, 0x84, 0x5a, 0x8f, 0xa5, 0xcf, 0x2e, 0x84, 0x99, 0xb4,
+	0x8b, 0x66, 0x15, 0x49, 0x68, 0x14, 0x91, 0x9c, 0x1e, 0x26, 0x75, 0x24, 0xa7, 0xc2, 0xe5, 0xe0,
+	0x50, 0xc8, 0x21, 0x04, 0x97, 0x33, 0x39, 0x4a, 0x0e, 0x92, 0x70, 0x39, 0x97, 0xa1, 0xe6, 0x76,
+	0x8f, 0x02, 0x8b, 0x5d, 0x4e, 0x94, 0xd9, 0x4c, 0x60, 0xce, 0x50, 0x25, 0x10, 0xbb, 0x77, 0xee,
+	0x41, 0x4b, 0xa1, 0xb1, 0x64, 0xe8, 0xc9, 0x64, 0x46, 0x96, 0x8b, 0xeb, 0x82, 0x70, 0xd9, 0xeb,
+	0xad, 0x20, 0x96, 0x9a, 0x15, 0x92, 0x97, 0xc6, 0x18, 0x23, 0x2d, 0x5a, 0x15, 0x1a, 0x94, 0x9a,
+	0x77, 0xe4, 0x98, 0xc0, 0xb4, 0xad, 0x23, 0x74, 0x3d, 0xc0, 0xcb, 0x03, 0xdd, 0x91, 0x88, 0x48,
+	0xe5, 0x14, 0x51, 0x9d, 0x13, 0x21, 0x54, 0x11, 0xdd, 0x81, 0x05, 0x66, 0x38, 0xdc, 0xc8, 0x1e,
+	0x5b, 0x5d, 0x9a, 0xbe, 0xc1, 0xe8, 0x67, 0xc8, 0x94, 0x84, 0xa7, 0xa5, 0xa5, 0x19, 0x99, 0xa5,
+	0x0a, 0x19, 0x9b, 0x9c, 0x91, 0x6c, 0x37, 0xc4, 0x78, 0x1b, 0x1a, 0x9e, 0x1f, 0x5b, 0x6a, 0x6f,
+	0xf7, 0x8b, 0xf7, 0xb6, 0x8e, 0x44, 0x72, 0xa0, 0x5f, 0x01, 0x1a, 0x5a, 0x72, 0x8b, 0x0f, 0x98,
+	0xf8, 0x1a, 0x82, 0x76, 0xf8, 0x2e, 0xbf, 0x83, 0x67, 0x86, 0xc0, 0xf3, 0x1d, 0x3a, 0x1c, 0xb1,
+	0x43, 0x75, 0xce, 0xc3, 0x37, 0x49, 0x48, 0x95, 0x1b, 0xee, 0x2a, 0xa9, 0xab, 0x7c, 0xcf, 0x85,
+	0xd4, 0x64, 0xdf, 0x7f, 0x78, 0x8e, 0xd4, 0x55, 0xb9, 0xf5, 0xaf, 0x70, 0xae, 0x64, 0xfb, 0x9f,
+	0xb2, 0xed, 0x2f, 0x31, 0x2a, 0xb9, 0xb1, 0xfa, 0x1a, 0xe8, 0x19, 0x2a, 0xee, 0x05, 0xfd, 0x73,
+	0xbd, 0xa0, 0x64, 0xb6, 0x53, 0x22, 0x98, 0x23, 0xdc, 0xe0, 0x62, 0x72, 0xce, 0x70, 0xc4, 0x13,
+	0x0b, 0xbe, 0x56, 0x65, 0x78, 0x41, 0x9b, 0xf3, 0x09, 0x4f, 0xd1, 0xae, 0xa6, 0xdc, 0xe2, 0x1e,
+	0x5c, 0x56, 0x06, 0x2f, 0xdc, 0xe1, 0x80, 0xb1, 0xcd, 0x8b, 0x2d, 0x18, 0xda, 0x64, 0xc1, 0x3f,
+	0xda, 0x43, 0xbe, 0x54, 0xfc, 0xab, 0xc5, 0x4e, 0x32, 0xeb, 0x87, 0xee, 0x81, 0xeb, 0x61, 0x59,
+	0x4d, 0x4a, 0x44, 0x4e, 0xdf, 0xe9, 0xc6, 0x7e, 0xd8, 0x09, 0xd9, 0xa1, 0x32, 0x2d, 0x91, 0x38,
+	0xf9, 0x8e, 0x40, 0x65, 0x78, 0x68, 0x62, 0xc5, 0x13, 0x65, 0x79, 0x70, 0x42, 0xc5, 0xb3, 0x06,
+	0x57, 0x33, 0xf3, 0x24, 0x6d, 0x1c, 0xc5, 0x1d, 0x33, 0xee, 0x4b, 0xa9, 0x19, 0x55, 0x33, 0xa7,
+	0x50, 0x8c, 0x5c, 0x73, 0x4e, 0xcc, 0x20, 0x2b, 0x46, 0xac, 0x3a, 0x2b, 0xe6, 0x2e, 0x2c, 0x28,
+	0x31, 0xd2, 0xfc, 0x4a, 0xc0, 0x31, 0x13, 0x30, 0x27, 0x09, 0x36, 0x99, 0xe5, 0x47, 0xb2, 0x66,
+	0x0c, 0x70, 0x32, 0xc4, 0x9a, 0xb6, 0xc1, 0x63, 0x7e, 0x04, 0xe4, 0x7b, 0x6b, 0x47, 0x76, 0xdc,
+	0x3d, 0xec, 0x9c, 0x66, 0x2a, 0xf0, 0x6c, 0x6b, 0xed, 0x11, 0x51, 0x98, 0x73, 0x11, 0xa9, 0x31,
+	0x04, 0x27, 0xb1, 0x5c, 0x89, 0x22, 0xb1, 0x67, 0xcf, 0x16, 0xdb, 0x23, 0x15, 0x87, 0xc5, 0xe2,
+	0x3d, 0x72, 0x18, 0xc7, 0x81, 0x90, 0xf3, 0xa3, 0x4c, 0x36, 0xfa, 0x70, 0x77, 0x77, 0x9b, 0x73,
+	0xd7, 0x88, 0x46, 0x32, 0x54, 0x65, 0x57, 0xb3, 0xf3, 0xe3, 0x4c, 0x3f, 0x98, 0xee, 0x2b, 0xd5,
+	0xb8, 0x54, 0x44, 0xf4, 0xe2, 0x43, 0x8a, 0xf7, 0xfc, 0x23, 0xdb, 0xf5, 0xa2, 0xce, 0x4f, 0x98,
+	0xa7, 0x02, 0x82, 0x56, 0x39, 0x84, 0xca, 0x11, 0x91, 0x10, 0x74, 0xbe, 0x11, 0x97, 0x5c, 0xc8,
+	0x52, 0x80, 0xfb, 0x15, 0xac, 0xec, 0x30, 0x3c, 0xef, 0x03, 0x54, 0x65, 0x74, 0x7f, 0x5c, 0xa9,
+	0x7e, 0x5d, 0xd2, 0xbe, 0x29, 0x99, 0xd0, 0xf7, 0x0f, 0xf0, 0xd4, 0x73, 0xf6, 0xdd, 0x53, 0xe3,
+	0x01, 0x4c, 0x17, 0xad, 0x6d, 0x11, 0xaa, 0x6a, 0xcf, 0xb8, 0x60, 0x35, 0xa6, 0x3a, 0x8a, 0x79,
+	0x95, 0x28, 0x2e, 0xf8, 0xc0, 0xf8, 0x4d, 0x09, 0x6a, 0x6a, 0xd5, 0xbc, 0x4e, 0x8a, 0x0f, 0xfd,
+	0x1e, 0xcf, 0x1d, 0x58, 0x9d, 0xc4, 0x86, 0x98, 0x5b, 0x4c, 0x04, 0x76, 0x7c, 0x28, 0x13, 0x84,
+	0xc5, 0xbc, 0xc1, 0x6e, 0x6e, 0x23, 0x96, 0x9b, 0x8e, 0x13, 0x2e, 0x7e, 0x82, 0xb9, 0xbc, 0x84,
+	0xe1, 0xa5, 0x3e, 0xe1, 0x9c, 0xe2, 0x45, 0xce, 0xb5, 0xc2, 0xeb, 0x88, 0x0f, 0x71, 0xc2, 0x0a,
+	0x5f, 0x11, 0xcf, 0x69, 0xe8, 0x6d, 0x8b, 0x8f, 0xef, 0x37, 0x00, 0x48, 0x0e, 0xdf, 0x26, 0xe3,
+	0x97, 0x58, 0x6f, 0xa6, 0xad, 0xad, 0x7f, 0x04, 0x75, 0xdb, 0x43, 0x13, 0xb1, 0xca, 0x54, 0x66,
+	0x3a, 0xaf, 0x14, 0xec, 0xcb, 0xcd, 0xe5, 0x84, 0x8c, 0x57, 0x9e, 0x69, 0xc6, 0xc5, 0x7b, 0xa0,
+	0xe5, 0x09, 0x5e, 0xa8, 0x06, 0xbd, 0x0b, 0xed, 0xdc, 0x29, 0xcb, 0x32, 0x37, 0x3a, 0xb6, 0x89,
+	0x7f, 0x82, 0x17, 0x8d, 0x04, 0x63, 0xe7, 0x73, 0x99, 0xc3, 0xe8, 0xb7, 0xb1, 0x81, 0xd9, 0x9e,
+	0xbc, 0x9f, 0xd0, 0x0e, 0xa2, 0x8b, 0x51, 0x12, 0x77, 0xbd, 0x18, 0xe3, 0xd4, 0xa9, 0x9c, 0x0f,
+	0xe1, 0x6c, 0x74, 0x5f, 0x83, 0x16, 0xc7, 0x5b, 0x7e, 0xc8, 0x0e, 0x0b, 0x2c, 0x25, 0x6a, 0xea,
+	0x3e, 0x21, 0x7d, 0xf7, 0xdd, 0x30, 0x8a, 0x85, 0x0e, 0x7c, 0x40, 0x4a, 0xf4, 0x6d, 0x04, 0x0a,
+	0x25, 0xe8, 0xb7, 0xf1, 0xf3, 0x12, 0xe8, 0xf9, 0x46, 0x0c, 0x66, 0x9f, 0x58, 0x6c, 0xfa, 0x61,
+	0xf7, 0xd0, 0x89, 0x30, 0xaf, 0x43, 0xe7, 0x21, 0x4f, 0xe5, 0x4b, 0x6f, 0xa5, 0xc1, 0xeb, 0x3d,
+	0xf2, 0x75, 0xd5, 0xf5, 0x71, 0x79, 0x3e, 0x88, 0xbe, 0x2e, 0x41, 0x9c, 0x40, 0x75, 0x83, 0x90,
+	0x80, 0x27, 0xfe, 0x20, 0x41, 0xeb, 0xbd, 0x8f, 0xc7, 0xab, 0x25, 0xad, 0x6c, 0x56, 0xa9, 0x8b,
+	0xc5, 0x16, 0x72, 0x0a, 0x73, 0xc5, 0x4f, 0x42, 0xfa, 0xeb, 0xa9, 0xd2, 0x66, 0x61, 0x44, 0x13,
+	0x49, 0x14, 0x39, 0x6f, 0x43, 0x55, 0x4e, 0x21, 0x3a, 0x69, 0xf3, 0xa3, 0xde, 0x84, 0x14, 0xa1,
+	0xf1, 0xd7, 0x71, 0xd0, 0xf2, 0x68, 0x32, 0x25, 0x75, 0x8d, 0x64, 0x19, 0xca, 0x07, 0x45, 0x99,
+	0x38, 0xb9, 0xcd, 0x91, 0xdd, 0x15, 0x26, 0xa0, 0x9f, 0xb4, 0x76, 0xf9, 0x16, 0x99, 0xe4, 0xfe,
+	0x20, 0x40, 0x74, 0x4b, 0x5d, 0xc4, 0x2c, 0x2f, 0x38, 0x7e, 0x87, 0xb2, 0x07, 0x9e, 0x5c, 0x62,
+	0xc0, 0x12, 0x00, 0x93, 0x07, 0x89, 0x5c, 0xe2, 0xc8, 0x8a, 0x42, 0x2e, 0x31, 0xe4, 0xab, 0x30,
+	0x41, 0x25, 0x81, 0x4c, 0x25, 0x65, 0xf6, 0xb3, 0x8b, 0xb0, 0x75, 0x6f, 0xdf, 0x37, 0x39, 0x16,
+	0x4d, 0x56, 0xe5, 0x13, 0x60, 0x3a, 0x5e, 0x65, 0x94, 0x2d, 0xf5, 0xa0, 0x10, 0x33, 0xc2, 0x49,
+	0x36, 0x1f, 0xa6, 0xe7, 0x9c, 0x74, 0x89, 0x91, 0xd6, 0x46, 0x92, 0x2e, 0x11, 0xe9, 0x07, 0x50,
+	0xe9, 0xdb, 0x7b, 0x4e, 0x9f, 0x67, 0x8d, 0xa3, 0x3b, 0x7a, 0x37, 0x37, 0x18, 0x95, 0x68, 0xf8,
+	0x70, 0x16, 0x64, 0x6e, 0xda, 0x41, 0xa0, 0xd2, 0xb7, 0xa8, 0xa3, 0x31, 0x19, 0x32, 0x67, 0x59,
+	0x0e, 0x02, 0x19, 0x15, 0x0f, 0x69, 0x7b, 0x1a, 0x76, 0x02, 0x88, 0xa8, 0x5b, 0x94, 0x92, 0xf9,
+	0x22, 0x91, 0x8a, 0x7e, 0x56, 0xd7, 0x1a, 0xf8, 0xb7, 0xa1, 0x35, 0xf1, 0x6f, 0x53, 0x6b, 0xe1,
+	0xdf, 0x96, 0xd6, 0xc6, 0xbf, 0x6d, 0x4d, 0x33, 0x1b, 0xce, 0x29, 0xfa, 0xb4, 0xc5, 0x5e, 0x37,
+	0x22, 0x73, 0xca, 0xf5, 0x0e, 0x42, 0xea, 0x26, 0xef, 0xd9, 0x5e, 0xef, 0xc4, 0xed, 0xc5, 0x87,
+	0xa6, 0xe6, 0xe4, 0x21, 0xed, 0x23, 0xfb, 0x94, 0xda, 0xf5, 0x9e, 0xc3, 0x0a, 0x94, 0xc8, 0x9c,
+	0xa6, 0x8a, 0x32, 0x01, 0x58, 0x18, 0x23, 0x8e, 0xb1, 0x32, 0xec, 0xce, 0xa2, 0xcc, 0x7f, 0x7e,
+	0x77, 0x36, 0x96, 0xa1, 0x95, 0xee, 0x00, 0x63, 0x80, 0xe6, 0xc2, 0xaa, 0xfc, 0xcc, 0xb0, 0xea,
+	0x83, 0x3e, 0xfc, 0x16, 0x8c, 0x6e, 0x94, 0xe8, 0x30, 0x5b, 0xd0, 0x6b, 0x16, 0xe1, 0xf4, 0x56,
+	0x2a, 0x9c, 0xc6, 0x32, 0x57, 0x60, 0xe6, 0x41, 0x38, 0x09, 0xa5, 0x7f, 0x97, 0xa1, 0x91, 0x46,
+	0x15, 0x35, 0x73, 0xf2, 0xe1, 0x51, 0x1e, 0x0a, 0x0f, 0xe5, 0xe4, 0x63, 0xe7, 0x3a, 0xf9, 0x4d,
+	0x98, 0x76, 0x4e, 0x03, 0x34, 0x3a, 0xa6, 0x89, 0xcc, 0xdb, 0xed, 0x5e, 0x2f, 0x94, 0xe1, 0x36,
+	0x25, 0x51, 0xeb, 0x88, 0x59, 0x26, 0x44, 0x9e, 0x7e, 0x49, 0xd0, 0x4f, 0x0c, 0xd1, 0x2f, 0x71,
+	0xfa, 0xf7, 0xa0, 0xad, 0x0a, 0x5c, 0x8b, 0x2b, 0x54, 0x29, 0x56, 0xa8, 0xa5, 0xe8, 0x76, 0x99,
+	0x66, 0xef, 0x42, 0x4b, 0x56, 0xc3, 0xd6, 0xb9, 0xe1, 0xda, 0x10, 0x45, 0x32, 0x67, 0xc3, 0xba,
+	0x61, 0xdf, 0x0f, 0x4f, 0xa8, 0x63, 0xcd, 0xb9, 0xaa, 0x23, 0xb8, 0x04, 0x15, 0xe3, 0x32, 0x3e,
+	0xc8, 0xee, 0xb0, 0xf0, 0xb2, 0xe7, 0xdb, 0x61, 0x23, 0x84, 0xaa, 0x14, 0x5b, 0xb8, 0x57, 0xaf,
+	0x83, 0x26, 0x63, 0x82, 0xf5, 0xae, 0x5c, 0x95, 0x48, 0xb4, 0x05, 0x7c, 0x5b, 0x80, 0xe9, 0xee,
+	0x70, 0x72, 0x94, 0xa2, 0x51, 0xe9, 0x64, 0x08, 0x8d, 0x3b, 0x30, 0x29, 0x8e, 0x16, 0x7d, 0x16,
+	0x2a, 0x18, 0x80, 0xb8, 0x1b, 0xf2, 0x98, 0xc5, 0xd1, 0x7a, 0x40, 0x60, 0xe6, 0xe0, 0x81, 0x0c,
+	0x67, 0x52, 0x38, 0x30, 0x4c, 0x98, 0x2e, 0x78, 0xca, 0xa1, 0xc6, 0x8f, 0x1b, 0xf9, 0x68, 0x32,
+	0x4c, 0x6c, 0x62, 0xfb, 0x48, 0xca, 0x6a, 0x20, 0x70, 0x57, 0xc2, 0xa8, 0xbd, 0x30, 0x08, 0x88,
+	0x84, 0x89, 0x2c, 0x99, 0x62, 0x64, 0x04, 0xd0, 0x19, 0xf5, 0x8c, 0xf3, 0xbc, 0x51, 0xf2, 0x26,
+	0x54, 0xf8, 0x03, 0x83, 0x68, 0xa9, 0x49, 0xd2, 0xdc, 0x03, 0x86, 0x20, 0x32, 0x8e, 0xa0, 0x95,
+	0xc5, 0x90, 0x6e, 0x42, 0x80, 0xc8, 0x0a, 0x23, 0x05, 0x0f, 0x1d, 0x3b, 0x12, 0x6d, 0x0f, 0xca,
+	0x16, 0xd9, 0x48, 0x7f, 0x03, 0xa6, 0xc4, 0xcb, 0xde, 0x81, 0xe3, 0x39, 0x21, 0xcb, 0x61, 0x58,
+	0x7c, 0x8e, 0x9b, 0x1a, 0x47, 0x3c, 0x50, 0x70, 0x3c, 0x43, 0x3a, 0xa3, 0x5e, 0x91, 0x9e, 0xd7,
+	0x49, 0x4e, 0xe1, 0xd2, 0x79, 0x4f, 0x44, 0x2f, 0x72, 0x41, 0xbf, 0xa0, 0xad, 0xd6, 0x47, 0xcd,
+	0xfc, 0xe2, 0x67, 0xe9, 0x12, 0xcc, 0x16, 0x3e, 0xf5, 0xe8, 0x97, 0x31, 0xe3, 0x1c, 0xec, 0xa1,
+	0xd5, 0xac, 0xe4, 0x4e, 0xa9, 0x71, 0xc8, 0x27, 0xce, 0x99, 0xf1, 0x88, 0x87, 0x57, 0xee, 0x33,
+	0x0d, 0xcc, 0xb8, 0xe5, 0x11, 0x2b, 0x33, 0x6e, 0x39, 0x56, 0xb7, 0x3b, 0x1d, 0x2f, 0x62, 0xe7,
+	0xd8, 0x6d, 0x4c, 0xa7, 0x4a, 0x5e, 0x9c, 0x58, 0xc7, 0xb7, 0x16, 0xb7, 0x06, 0xad, 0xec, 0x67,
+	0x1e, 0x05, 0x8f, 0x0c, 0xe3, 0xf4, 0x7d, 0x87, 0xb0, 0x77, 0x3b, 0xff, 0x61, 0x07, 0x43, 0x1a,
+	0xd7, 0x12, 0x31, 0x23, 0x9e, 0x0f, 0x3e, 0x87, 0xaa, 0xa4, 0x60, 0x59, 0xad, 0xdb, 0x53, 0x3d,
+	0x4a, 0xfa, 0xad, 0x5f, 0x01, 0x38, 0xb2, 0xa3, 0x2f, 0x07, 0xe8, 0x76, 0x22, 0xdf, 0xad, 0x9a,
+	0x29, 0x08, 0xad, 0xb0, 0xe7, 0x46, 0xf6, 0x5e, 0x5f, 0x75, 0x0f, 0xd5, 0xd8, 0xf8, 0x63, 0x09,
+	0x66, 0x8a, 0xbe, 0xe8, 0xc0, 0x23, 0x25, 0xd9, 0xde, 0xf9, 0xc2, 0x9a, 0x4f, 0xb8, 0xd5, 0x87,
+	0x2a, 0x33, 0xe1, 0x75, 0xca, 0x6b, 0xe7, 0x7c, 0x27, 0x52, 0x94, 0x9d, 0xfc, 0x17, 0x09, 0x86,
+	0xf1, 0x61, 0x5e, 0x79, 0xf5, 0x54, 0xf9, 0x7c, 0xca, 0x1b, 0xab, 0xa0, 0xe5, 0xe1, 0xd9, 0xa6,
+	0x69, 0x29, 0xdf, 0x35, 0x2f, 0x6a, 0x08, 0xff, 0xae, 0x04, 0xed, 0xdc, 0x27, 0x27, 0xba, 0x91,
+	0x52, 0x41, 0xcf, 0x7f, 0x51, 0x22, 0x4c, 0xf7, 0x7e, 0xce, 0x74, 0x46, 0xf1, 0xe7, 0x2b, 0xff,
+	0x6b, 0xab, 0xbd, 0x9b, 0xd2, 0x56, 0x18, 0xec, 0x39, 0xb4, 0x35, 0x5e, 0x82, 0x7a, 0x0a, 0x54,
+	0xf8, 0x56, 0xb4, 0x0b, 0xc0, 0xbf, 0x1c, 0xd9, 0x15, 0x15, 0x98, 0x1b, 0x88, 0xfb, 0x85, 0xbd,
+	0x99, 0xba, 0xc1, 0xb7, 0x79, 0x33, 0x35, 0xfe, 0x56, 0x86, 0x7a, 0xea, 0x5b, 0x1a, 0xfd, 0x95,
+	0x54, 0xb5, 0x97, 0x34, 0xa4, 0x19, 0x45, 0xf2, 0x68, 0x88, 0xf5, 0x48, 0xc3, 0x0d, 0xf8, 0xf7,
+	0x55, 0x8c, 0x9a, 0xb7, 0xaf, 0xa7, 0x54, 0x10, 0x52, 0x38, 0x31, 0x72, 0x70, 0x03, 0xf9, 0x9b,
+	0xcc, 0xd8, 0x8b, 0x62, 0x59, 0x50, 0xe0, 0x4f, 0xb4, 0x4c, 0x93, 0x75, 0x87, 0xb0, 0x7c, 0x64,
+	0x55, 0x9f, 0x28, 0xa7, 0xa8, 0xdd, 0xb0, 0x89, 0x30, 0xb2, 0x08, 0x35, 0x25, 0x15, 0x0d, 0xae,
+	0x57, 0x34, 0xda, 0x05, 0x05, 0xde, 0xa9, 0x98, 0x75, 0x45, 0x48, 0x67, 0x45, 0x83, 0x3d, 0x6a,
+	0x5a, 0x4e, 0xf2, 0x08, 0x25, 0xd0, 0x0e, 0x83, 0xe8, 0x2f, 0x41, 0x83, 0xf2, 0x15, 0x5c, 0xc1,
+	0x01, 0x1e, 0x9b, 0x07, 0xac, 0xfb, 0x5c, 0x35, 0xeb, 0x08, 0xdb, 0x12, 0x20, 0xbc, 0x2f, 0x5a,
+	0x7d, 0xbf, 0x6b, 0xf7, 0x2d, 0x59, 0xe8, 0xb1, 0xf6, 0x73, 0xd5, 0x6c, 0x32, 0xa8, 0x3c, 0x78,
+	0xf5, 0xdb, 0x50, 0x8f, 0xd9, 0x0e, 0xf0, 0x45, 0xf3, 0x87, 0x71, 0xb9, 0xe8, 0x64, 0x6f, 0x4c,
+	0x88, 0xd5, 0x6f, 0xe3, 0xaa, 0x30, 0xaf, 0xf0, 0x05, 0x61, 0x83, 0xb2, 0xb2, 0x81, 0xf1, 0x87,
+	0x12, 0x2c, 0x8c, 0xfc, 0xb6, 0x88, 0x39, 0x02, 0x15, 0xda, 0xd2, 0x11, 0xa8, 0x20, 0x17, 0x85,
+	0x59, 0x39, 0x29, 0xcc, 0x32, 0x47, 0xe9, 0x58, 0xf6, 0x28, 0xd5, 0xaf, 0x83, 0x16, 0xd8, 0xa1,
+	0xe3, 0xd1, 0xd7, 0xb1, 0xac, 0xf3, 0x84, 0x56, 0xe4, 0x76, 0x6e, 0x71, 0xf8, 0x2a, 0x03, 0xa3,
+	0x29, 0x91, 0x72, 0xdf, 0xde, 0x0b, 0xf1, 0xc6, 0xe0, 0x9f, 0x01, 0xb8, 0x81, 0xcc, 0x22, 0x5b,
+	0x1c, 0xbe, 0x4d, 0xe0, 0xf5, 0x20, 0x32, 0xde, 0x2a, 0xd4, 0x59, 0xac, 0xb1, 0x40, 0x67, 0xe3,
+	0x67, 0x25, 0x98, 0x1f, 0xf1, 0xa5, 0xd2, 0xb9, 0x97, 0x44, 0xf6, 0x12, 0x2b, 0xe7, 0x2e, 0x31,
+	0x4a, 0x7d, 0x51, 0x8e, 0x13, 0xee, 0xdb, 0x6c, 0x5d, 0x59, 0x13, 0x4c, 0x29, 0x94, 0xcc, 0x95,
+	0x31, 0x3a, 0xe7, 0x47, 0x7c, 0xd1, 0x74, 0x9e, 0x16, 0xc6, 0x9f, 0x4a, 0x30, 0x5b, 0xf8, 0xb1,
+	0x12, 0xf5, 0x58, 0x65, 0x43, 0xaf, 0xdb, 0x1f, 0x44, 0x38, 0x9f, 0x45, 0xd7, 0x86, 0xec, 0x37,
+	0x4d, 0x0b, 0xe4, 0x0a, 0xc7, 0xad, 0x10, 0x0a, 0xd3, 0x61, 0xf5, 0xdd, 0x1e, 0xa6, 0x85, 0x4e,
+	0x48, 0x2d, 0x4a, 0xce, 0x54, 0x16, 0xef, 0x0b, 0x1c, 0xbb, 0x26, 0x90, 0x9c, 0xeb, 0xbb, 0xb0,
+	0x28, 0xb9, 0xc8, 0x19, 0x51, 0x17, 0xdb, 0xeb, 0xaa, 0xe9, 0x78, 0x46, 0xda, 0x11, 0x14, 0x1b,
+	0x29, 0x02, 0xc6, 0x4d, 0x7d, 0xb1, 0x76, 0xae, 0x14, 0xa5, 0xc0, 0x90, 0x12, 0x53, 0xab, 0xae,
+	0x0b, 0x18, 0x0b, 0xbe, 0xc5, 0xd4, 0x9b, 0x93, 0xb8, 0xa2, 0xd5, 0x13, 0x93, 0x4e, 0x17, 0x70,
+	0xc8, 0xe3, 0x79, 0xc2, 0x64, 0xbf, 0xc9, 0x11, 0x59, 0x63, 0x3b, 0x15, 0xcc, 0x55, 0x02, 0x30,
+	0x61, 0x38, 0x5f, 0xba, 0x52, 0x16, 0xa1, 0x5c, 0x4f, 0x15, 0xc4, 0x37, 0xae, 0xd3, 0xc7, 0x08,
+	0xf2, 0xc1, 0x6b, 0x12, 0xc6, 0x96, 0x37, 0x3f, 0xd3, 0x2e, 0xe8, 0x55, 0x18, 0x47, 0xe8, 0x3b,
+	0xda, 0xb8, 0xf8, 0xb5, 0xa4, 0x55, 0x6e, 0x7c, 0x55, 0x82, 0x9a, 0x3a, 0x95, 0xf4, 0x26, 0xd4,
+	0x56, 0xf0, 0x24, 0xb5, 0xd6, 0x37, 0x3f, 0xda, 0x42, 0x86, 0x69, 0x68, 0x9b, 0x6b, 0x8f, 0xb6,
+	0x76, 0xd7, 0xac, 0x4f, 0xb7, 0xcc, 0x4f, 0x36, 0xb6, 0x96, 0x57, 0xb5, 0x12, 0x7d, 0xd3, 0x20,
+	0x80, 0x0f, 0xb7, 0x76, 0x76, 0xb5, 0x32, 0x2e, 0xa0, 0xb5, 0xb1, 0xb5, 0xb2, 0xbc, 0x91, 0x10,
+	0x8d, 0x61, 0x7a, 0x00, 0x1c, 0xc6, 0x68, 0xc6, 0xf5, 0x29, 0x68, 0x0a, 0xa6, 0xdd, 0xc7, 0x9b,
+	0x9b, 0x6b, 0x1b, 0xda, 0x04, 0x86, 0x5f, 0x83, 0x93, 0x08, 0x48, 0xe5, 0xc6, 0x5d, 0x80, 0xe4,
+	0xc8, 0x23, 0x1d, 0x37, 0xb7, 0x36, 0xd7, 0x50, 0x8d, 0x06, 0x54, 0x37, 0xb7, 0xac, 0xb5, 0xcd,
+	0x95, 0xe5, 0x6d, 0x9c, 0xbf, 0x06, 0x13, 0x2c, 0x66, 0x70, 0x66, 0xb6, 0x8c, 0xf5, 0x6d, 0x6d,
+	0xec, 0xf6, 0x3d, 0x00, 0xfe, 0xda, 0xc9, 0x3e, 0x80, 0xbf, 0x05, 0xe3, 0xec, 0x7f, 0x79, 0x4b,
+	0xa4, 0xbe, 0xbb, 0x5f, 0x94, 0xb0, 0xd4, 0xa7, 0xf5, 0xb7, 0x4a, 0xb7, 0xd7, 0x61, 0x4a, 0x0d,
+	0x57, 0x43, 0xf7, 0xd8, 0x09, 0x9f, 0x7c, 0x07, 0x1d, 0x2c, 0x2b, 0x26, 0xc5, 0xb2, 0x28, 0xdf,
+	0xb6, 0x33, 0x1f, 0x9c, 0x5d, 0x2f, 0xdd, 0x2a, 0xdd, 0x9f, 0xff, 0xfa, 0x1f, 0x57, 0x4a, 0x7f,
+	0xc1, 0x7f, 0x7f, 0xc7, 0x7f, 0xbf, 0xf8, 0xe7, 0x95, 0x0b, 0x9f, 0x4f, 0xb0, 0xad, 0xda, 0xab,
+	0xb0, 0xff, 0xde, 0xfe, 0x0f, 0xf5, 0x3b, 0x0f, 0x75, 0x24, 0x30, 0x00, 0x00,
 }
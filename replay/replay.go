@@ -0,0 +1,225 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package replay records the stream of datastore updates that Felix receives from its syncer (or
+// from Typha) to a file, and plays a recording back in place of the syncer.  Replaying a
+// recording from the field through the calculation graph and a (real or simulated) dataplane
+// lets maintainers reproduce issues that depend on the exact sequence of updates.
+//
+// A recording is a sequence of JSON objects, one per line, each holding either a sync status
+// change or a batch of updates, and the time since the start of the recording.
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/libcalico-go/lib/backend/api"
+	"github.com/projectcalico/libcalico-go/lib/backend/model"
+)
+
+type record struct {
+	// Offset is the time since the start of the recording.
+	Offset  time.Duration    `json:"offset"`
+	Status  *api.SyncStatus  `json:"status,omitempty"`
+	Updates []recordedUpdate `json:"updates,omitempty"`
+}
+
+type recordedUpdate struct {
+	Key        string         `json:"key"`
+	Value      *string        `json:"value,omitempty"`
+	Revision   string         `json:"revision,omitempty"`
+	UpdateType api.UpdateType `json:"updateType"`
+}
+
+// Recorder is a SyncerCallbacks that writes the updates it receives to a file before passing
+// them on.  If writing fails, it logs and stops recording; the updates are always passed on.
+type Recorder struct {
+	downstream api.SyncerCallbacks
+
+	lock  sync.Mutex
+	file  *os.File
+	w     *bufio.Writer
+	enc   *json.Encoder
+	start time.Time
+}
+
+// NewRecorder creates a Recorder that writes to the given file.  It fails if the file already
+// exists so that a restart can't overwrite an earlier recording.
+func NewRecorder(filename string, downstream api.SyncerCallbacks) (*Recorder, error) {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	return &Recorder{
+		downstream: downstream,
+		file:       f,
+		w:          w,
+		enc:        json.NewEncoder(w),
+		start:      time.Now(),
+	}, nil
+}
+
+func (r *Recorder) OnStatusUpdated(status api.SyncStatus) {
+	r.write(record{Status: &status})
+	r.downstream.OnStatusUpdated(status)
+}
+
+func (r *Recorder) OnUpdates(updates []api.Update) {
+	rec := record{}
+	for _, u := range updates {
+		ru, err := serializeUpdate(u)
+		if err != nil {
+			log.WithError(err).WithField("key", u.Key).Warn("Failed to record update, skipping it")
+			continue
+		}
+		rec.Updates = append(rec.Updates, ru)
+	}
+	r.write(rec)
+	r.downstream.OnUpdates(updates)
+}
+
+func (r *Recorder) write(rec record) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.file == nil {
+		return
+	}
+	rec.Offset = time.Since(r.start)
+	err := r.enc.Encode(rec)
+	if err == nil {
+		// Flush each record so that the recording is useful even if Felix crashes.
+		err = r.w.Flush()
+	}
+	if err != nil {
+		log.WithError(err).WithField("file", r.file.Name()).Error(
+			"Failed to write datastore recording, stopping recording")
+		_ = r.file.Close()
+		r.file = nil
+	}
+}
+
+func serializeUpdate(u api.Update) (recordedUpdate, error) {
+	path, err := model.KeyToDefaultPath(u.Key)
+	if err != nil {
+		return recordedUpdate{}, err
+	}
+	ru := recordedUpdate{
+		Key:        path,
+		Revision:   u.Revision,
+		UpdateType: u.UpdateType,
+	}
+	if u.Value != nil {
+		data, err := model.SerializeValue(&u.KVPair)
+		if err != nil {
+			return recordedUpdate{}, err
+		}
+		value := string(data)
+		ru.Value = &value
+	}
+	return ru, nil
+}
+
+func parseUpdate(ru recordedUpdate) (api.Update, error) {
+	key := model.KeyFromDefaultPath(ru.Key)
+	if key == nil {
+		return api.Update{}, fmt.Errorf("failed to parse key %q", ru.Key)
+	}
+	u := api.Update{
+		KVPair: model.KVPair{
+			Key:      key,
+			Revision: ru.Revision,
+		},
+		UpdateType: ru.UpdateType,
+	}
+	if ru.Value != nil {
+		value, err := model.ParseValue(key, []byte(*ru.Value))
+		if err != nil {
+			return api.Update{}, err
+		}
+		u.Value = value
+	}
+	return u, nil
+}
+
+// Player plays a recording back to a SyncerCallbacks, with the recorded timing.  It can be
+// used in place of the syncer.
+type Player struct {
+	filename  string
+	callbacks api.SyncerCallbacks
+}
+
+func NewPlayer(filename string, callbacks api.SyncerCallbacks) *Player {
+	return &Player{
+		filename:  filename,
+		callbacks: callbacks,
+	}
+}
+
+// Start plays the recording in the background.
+func (p *Player) Start() {
+	go func() {
+		f, err := os.Open(p.filename)
+		if err != nil {
+			log.WithError(err).WithField("file", p.filename).Error("Failed to open datastore recording")
+			return
+		}
+		defer f.Close()
+		if err := Play(f, p.callbacks, time.Sleep); err != nil {
+			log.WithError(err).WithField("file", p.filename).Error("Failed to replay datastore recording")
+			return
+		}
+		log.WithField("file", p.filename).Info("Finished replaying datastore recording")
+	}()
+}
+
+// Play plays the recording from r to callbacks, using sleep to wait until each record is due.
+func Play(r io.Reader, callbacks api.SyncerCallbacks, sleep func(time.Duration)) error {
+	dec := json.NewDecoder(r)
+	var elapsed time.Duration
+	for {
+		var rec record
+		if err := dec.Decode(&rec); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if rec.Offset > elapsed {
+			sleep(rec.Offset - elapsed)
+			elapsed = rec.Offset
+		}
+		if rec.Status != nil {
+			callbacks.OnStatusUpdated(*rec.Status)
+			continue
+		}
+		var updates []api.Update
+		for _, ru := range rec.Updates {
+			u, err := parseUpdate(ru)
+			if err != nil {
+				log.WithError(err).WithField("key", ru.Key).Warn("Failed to parse recorded update, skipping it")
+				continue
+			}
+			updates = append(updates, u)
+		}
+		callbacks.OnUpdates(updates)
+	}
+}
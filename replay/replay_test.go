@@ -0,0 +1,108 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/libcalico-go/lib/backend/api"
+	"github.com/projectcalico/libcalico-go/lib/backend/model"
+)
+
+type callbackRecorder struct {
+	statuses []api.SyncStatus
+	updates  [][]api.Update
+}
+
+func (c *callbackRecorder) OnStatusUpdated(status api.SyncStatus) {
+	c.statuses = append(c.statuses, status)
+}
+
+func (c *callbackRecorder) OnUpdates(updates []api.Update) {
+	c.updates = append(c.updates, updates)
+}
+
+var _ = Describe("Datastore recording", func() {
+	var (
+		dir      string
+		filename string
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "replay")
+		Expect(err).NotTo(HaveOccurred())
+		filename = filepath.Join(dir, "updates.json")
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(dir)
+	})
+
+	updates := []api.Update{
+		{
+			KVPair: model.KVPair{
+				Key:      model.GlobalConfigKey{Name: "LogSeverityScreen"},
+				Value:    "Debug",
+				Revision: "1",
+			},
+			UpdateType: api.UpdateTypeKVNew,
+		},
+		{
+			KVPair: model.KVPair{
+				Key:      model.HostConfigKey{Hostname: "node1", Name: "IpInIpEnabled"},
+				Revision: "2",
+			},
+			UpdateType: api.UpdateTypeKVDeleted,
+		},
+	}
+
+	It("should refuse to overwrite an existing recording", func() {
+		Expect(ioutil.WriteFile(filename, []byte("earlier recording\n"), 0644)).To(Succeed())
+		_, err := NewRecorder(filename, &callbackRecorder{})
+		Expect(err).To(HaveOccurred())
+		Expect(ioutil.ReadFile(filename)).To(Equal([]byte("earlier recording\n")))
+	})
+
+	It("should pass updates on and replay them", func() {
+		live := &callbackRecorder{}
+		rec, err := NewRecorder(filename, live)
+		Expect(err).NotTo(HaveOccurred())
+		rec.OnStatusUpdated(api.ResyncInProgress)
+		time.Sleep(10 * time.Millisecond)
+		rec.OnUpdates(updates)
+		rec.OnStatusUpdated(api.InSync)
+
+		Expect(live.statuses).To(Equal([]api.SyncStatus{api.ResyncInProgress, api.InSync}))
+		Expect(live.updates).To(Equal([][]api.Update{updates}))
+
+		f, err := os.Open(filename)
+		Expect(err).NotTo(HaveOccurred())
+		defer f.Close()
+		replayed := &callbackRecorder{}
+		var slept time.Duration
+		Expect(Play(f, replayed, func(d time.Duration) { slept += d })).To(Succeed())
+
+		Expect(replayed.statuses).To(Equal(live.statuses))
+		Expect(replayed.updates).To(Equal(live.updates))
+		Expect(slept).To(BeNumerically(">=", 10*time.Millisecond))
+	})
+})
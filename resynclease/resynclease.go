@@ -0,0 +1,258 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package resynclease limits how many Felix instances do their initial resync at once.  There
+// is a fixed number of slots, each backed by a Kubernetes Lease; an instance waits until it holds
+// one of the leases before it starts its resync, and releases it when it is done.  If an instance
+// dies while holding a slot, it stops renewing the lease and the slot becomes free once the lease
+// expires.
+package resynclease
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	leaseNamePrefix = "felix-startup-resync-"
+
+	defaultLeaseDuration = 30 * time.Second
+	defaultRetryInterval = 5 * time.Second
+
+	timeout = 10 * time.Second
+)
+
+type Config struct {
+	// Namespace holds the slot leases.
+	Namespace string
+	// Slots is the number of instances that may hold a slot at once.
+	Slots int
+	// Identity identifies this instance as the holder of a lease; it should be the node name.
+	Identity string
+
+	// LeaseDuration is how long a slot stays held after its holder's last renewal.
+	LeaseDuration time.Duration
+	// RetryInterval is the mean interval between attempts to take a slot while all are held.
+	RetryInterval time.Duration
+}
+
+type Coordinator struct {
+	client kubernetes.Interface
+	config Config
+	clock  clock.Clock
+}
+
+func New(client kubernetes.Interface, config Config) *Coordinator {
+	return newWithClock(client, config, clock.RealClock{})
+}
+
+func newWithClock(client kubernetes.Interface, config Config, c clock.Clock) *Coordinator {
+	if config.LeaseDuration <= 0 {
+		config.LeaseDuration = defaultLeaseDuration
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = defaultRetryInterval
+	}
+	return &Coordinator{
+		client: client,
+		config: config,
+		clock:  c,
+	}
+}
+
+// Acquire blocks until we hold a slot or the context is done.  The returned Slot renews its lease
+// in the background until it is released.
+func (c *Coordinator) Acquire(ctx context.Context) (*Slot, error) {
+	if c.config.Slots <= 0 {
+		return nil, fmt.Errorf("invalid number of startup resync slots: %d", c.config.Slots)
+	}
+
+	// Start the search at a slot derived from our identity so that instances mostly try
+	// different leases rather than all contending for the first.
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(c.config.Identity))
+	first := int(h.Sum32() % uint32(c.config.Slots))
+
+	for {
+		for i := 0; i < c.config.Slots; i++ {
+			name := leaseName((first + i) % c.config.Slots)
+			logCxt := log.WithField("lease", name)
+			acquired, err := c.tryAcquire(ctx, name)
+			if err != nil {
+				logCxt.WithError(err).Warn("Failed to check startup resync lease")
+				continue
+			}
+			if acquired {
+				logCxt.Info("Acquired startup resync slot")
+				slot := &Slot{
+					c:     c,
+					name:  name,
+					stopC: make(chan struct{}),
+					doneC: make(chan struct{}),
+				}
+				go slot.loopRenewing()
+				return slot, nil
+			}
+		}
+
+		log.Info("All startup resync slots are busy, waiting")
+		retry := c.config.RetryInterval/2 + time.Duration(rand.Int63n(int64(c.config.RetryInterval)))
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.clock.After(retry):
+		}
+	}
+}
+
+func (c *Coordinator) tryAcquire(ctx context.Context, name string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	leases := c.client.CoordinationV1().Leases(c.config.Namespace)
+	lease, err := leases.Get(ctx, name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: c.config.Namespace,
+			},
+			Spec: c.heldSpec(),
+		}
+		_, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+		if k8serrors.IsAlreadyExists(err) {
+			// Another instance beat us to it.
+			return false, nil
+		}
+		return err == nil, err
+	} else if err != nil {
+		return false, err
+	}
+
+	if c.heldByOther(lease) {
+		return false, nil
+	}
+	lease.Spec = c.heldSpec()
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	if k8serrors.IsConflict(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// heldByOther returns true if the lease has a holder other than us that has renewed it recently.
+func (c *Coordinator) heldByOther(lease *coordinationv1.Lease) bool {
+	spec := lease.Spec
+	if spec.HolderIdentity == nil || *spec.HolderIdentity == "" || *spec.HolderIdentity == c.config.Identity {
+		return false
+	}
+	if spec.RenewTime == nil || spec.LeaseDurationSeconds == nil {
+		return false
+	}
+	expiry := spec.RenewTime.Add(time.Duration(*spec.LeaseDurationSeconds) * time.Second)
+	return c.clock.Now().Before(expiry)
+}
+
+func (c *Coordinator) heldSpec() coordinationv1.LeaseSpec {
+	identity := c.config.Identity
+	durationSecs := int32((c.config.LeaseDuration + time.Second - 1) / time.Second)
+	now := metav1.NewMicroTime(c.clock.Now())
+	return coordinationv1.LeaseSpec{
+		HolderIdentity:       &identity,
+		LeaseDurationSeconds: &durationSecs,
+		AcquireTime:          &now,
+		RenewTime:            &now,
+	}
+}
+
+func leaseName(idx int) string {
+	return fmt.Sprintf("%s%d", leaseNamePrefix, idx)
+}
+
+// Slot is a startup resync slot that we hold.
+type Slot struct {
+	c    *Coordinator
+	name string
+
+	stopC       chan struct{}
+	doneC       chan struct{}
+	releaseOnce sync.Once
+}
+
+func (s *Slot) loopRenewing() {
+	defer close(s.doneC)
+	for {
+		select {
+		case <-s.stopC:
+			return
+		case <-s.c.clock.After(s.c.config.LeaseDuration / 3):
+		}
+		err := s.update(func(spec *coordinationv1.LeaseSpec) {
+			now := metav1.NewMicroTime(s.c.clock.Now())
+			spec.RenewTime = &now
+		})
+		if err != nil {
+			log.WithError(err).WithField("lease", s.name).Warn("Failed to renew startup resync lease")
+		}
+	}
+}
+
+// Release stops renewing the slot's lease and frees the slot for another instance.
+func (s *Slot) Release() {
+	s.releaseOnce.Do(func() {
+		close(s.stopC)
+		<-s.doneC
+
+		err := s.update(func(spec *coordinationv1.LeaseSpec) {
+			spec.HolderIdentity = nil
+			spec.AcquireTime = nil
+			spec.RenewTime = nil
+		})
+		if err != nil {
+			// The lease will expire in any case.
+			log.WithError(err).WithField("lease", s.name).Warn("Failed to release startup resync lease")
+			return
+		}
+		log.WithField("lease", s.name).Info("Released startup resync slot")
+	})
+}
+
+// update applies the given change to our lease, if we still hold it.
+func (s *Slot) update(f func(spec *coordinationv1.LeaseSpec)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	leases := s.c.client.CoordinationV1().Leases(s.c.config.Namespace)
+	lease, err := leases.Get(ctx, s.name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != s.c.config.Identity {
+		return fmt.Errorf("lease %s is no longer held by %s", s.name, s.c.config.Identity)
+	}
+	f(&lease.Spec)
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return err
+}
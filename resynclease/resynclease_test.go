@@ -0,0 +1,133 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resynclease
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var _ = Describe("Startup resync coordinator", func() {
+	var (
+		client    *fake.Clientset
+		fakeClock *clock.FakeClock
+	)
+
+	BeforeEach(func() {
+		client = fake.NewSimpleClientset()
+		fakeClock = clock.NewFakeClock(time.Now())
+	})
+
+	coordinator := func(identity string, slots int) *Coordinator {
+		return newWithClock(client, Config{
+			Namespace:     "kube-system",
+			Slots:         slots,
+			Identity:      identity,
+			LeaseDuration: 30 * time.Second,
+			RetryInterval: time.Second,
+		}, fakeClock)
+	}
+
+	holder := func(name string) func() string {
+		return func() string {
+			lease, err := client.CoordinationV1().Leases("kube-system").Get(
+				context.Background(), name, metav1.GetOptions{})
+			if err != nil || lease.Spec.HolderIdentity == nil {
+				return ""
+			}
+			return *lease.Spec.HolderIdentity
+		}
+	}
+
+	It("should hand out each slot to one instance at a time", func() {
+		slot1, err := coordinator("node1", 2).Acquire(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		slot2, err := coordinator("node2", 2).Acquire(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(slot1.name).NotTo(Equal(slot2.name))
+		Expect(holder(slot1.name)()).To(Equal("node1"))
+		Expect(holder(slot2.name)()).To(Equal("node2"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = coordinator("node3", 2).Acquire(ctx)
+		Expect(err).To(Equal(context.Canceled))
+	})
+
+	It("should wait for a slot to be released", func() {
+		slot1, err := coordinator("node1", 1).Acquire(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+
+		slot2C := make(chan *Slot, 1)
+		go func() {
+			defer GinkgoRecover()
+			slot, err := coordinator("node2", 1).Acquire(context.Background())
+			Expect(err).NotTo(HaveOccurred())
+			slot2C <- slot
+		}()
+		Consistently(slot2C).ShouldNot(Receive())
+
+		slot1.Release()
+		Expect(holder(slot1.name)()).To(Equal(""))
+
+		// Wake up the waiting instance.
+		Eventually(func() *Slot {
+			fakeClock.Step(2 * time.Second)
+			select {
+			case s := <-slot2C:
+				return s
+			default:
+				return nil
+			}
+		}).ShouldNot(BeNil())
+		Expect(holder(slot1.name)()).To(Equal("node2"))
+	})
+
+	It("should take over a slot whose holder stopped renewing it", func() {
+		_, err := coordinator("node1", 1).tryAcquire(context.Background(), leaseName(0))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(holder(leaseName(0))()).To(Equal("node1"))
+
+		c2 := coordinator("node2", 1)
+		Expect(c2.tryAcquire(context.Background(), leaseName(0))).To(BeFalse())
+		fakeClock.Step(31 * time.Second)
+		Expect(c2.tryAcquire(context.Background(), leaseName(0))).To(BeTrue())
+		Expect(holder(leaseName(0))()).To(Equal("node2"))
+	})
+
+	It("should renew its lease while it holds the slot", func() {
+		slot, err := coordinator("node1", 1).Acquire(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		defer slot.Release()
+
+		renewTime := func() time.Time {
+			lease, err := client.CoordinationV1().Leases("kube-system").Get(
+				context.Background(), slot.name, metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			return lease.Spec.RenewTime.Time
+		}
+		start := renewTime()
+		Eventually(func() time.Time {
+			fakeClock.Step(10 * time.Second)
+			return renewTime()
+		}).Should(BeTemporally(">", start))
+	})
+})
@@ -162,6 +162,13 @@ func (r *RouteRules) RemoveRule(rule *Rule) {
 	}
 }
 
+// RemoveAllRules removes all the rules, so that the next Apply removes all the rules that we've
+// programmed.
+func (r *RouteRules) RemoveAllRules() {
+	r.activeRules = set.New()
+	r.inSync = false
+}
+
 func (r *RouteRules) QueueResync() {
 	r.logCxt.Debug("Queueing a resync of routing rules.")
 	r.inSync = false
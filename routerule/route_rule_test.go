@@ -209,6 +209,13 @@ var _ = Describe("RouteRules", func() {
 			Expect(dataplane.deletedRuleKeys.Contains("10.0.0.2/32-0x200")).To(BeTrue())
 		})
 
+		It("should remove all Calico rules after RemoveAllRules", func() {
+			rrs.RemoveAllRules()
+			err := rrs.Apply()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dataplane.ruleKeyToRule).To(ConsistOf(nonCaliRule))
+		})
+
 		Describe("set rule with specific table idx and fwmark", func() {
 			var netlinkRule netlink.Rule
 			BeforeEach(func() {
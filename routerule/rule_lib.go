@@ -49,6 +49,10 @@ func (r *Rule) LogCxt() *log.Entry {
 	if r.nlRule.Src != nil {
 		src = r.nlRule.Src
 	}
+	var dst interface{}
+	if r.nlRule.Dst != nil {
+		dst = r.nlRule.Dst
+	}
 	return log.WithFields(log.Fields{
 		"ipFamily": r.nlRule.Family,
 		"priority": r.nlRule.Priority,
@@ -56,6 +60,7 @@ func (r *Rule) LogCxt() *log.Entry {
 		"Mark":     r.nlRule.Mark,
 		"Mask":     r.nlRule.Mask,
 		"src":      src,
+		"dst":      dst,
 		"Table":    r.nlRule.Table,
 	})
 }
@@ -90,6 +95,11 @@ func (r *Rule) MatchSrcAddress(ip net.IPNet) *Rule {
 	return r
 }
 
+func (r *Rule) MatchDstAddress(ip net.IPNet) *Rule {
+	r.nlRule.Dst = &ip
+	return r
+}
+
 func (r *Rule) Not() *Rule {
 	r.nlRule.Invert = true
 	return r
@@ -120,3 +130,7 @@ func RulesMatchSrcFWMark(r, p *Rule) bool {
 func RulesMatchSrcFWMarkTable(r, p *Rule) bool {
 	return RulesMatchSrcFWMark(r, p) && (r.nlRule.Table == p.nlRule.Table)
 }
+
+func RulesMatchSrcDstFWMarkTable(r, p *Rule) bool {
+	return RulesMatchSrcFWMarkTable(r, p) && ip.IPNetsEqual(r.nlRule.Dst, p.nlRule.Dst)
+}
@@ -49,6 +49,7 @@ var _ = Describe("RouteRule Rule build cases", func() {
 		Expect(NewRule(4, 100).Not().NetLinkRule().Invert).To(Equal(true))
 		Expect(NewRule(4, 100).GoToTable(10).NetLinkRule().Table).To(Equal(10))
 		Expect(NewRule(4, 100).MatchSrcAddress(*ip).NetLinkRule().Src.String()).To(Equal("10.0.1.0/26"))
+		Expect(NewRule(4, 100).MatchDstAddress(*ip).NetLinkRule().Dst.String()).To(Equal("10.0.1.0/26"))
 		Expect(NewRule(4, 100).Not().
 			MatchFWMark(0x400).
 			MatchSrcAddress(*ip).
@@ -125,4 +126,13 @@ var _ = Describe("RouteRule Rule match cases", func() {
 		Expect(RulesMatchSrcFWMark(r0, new)).To(Equal(false))
 		Expect(RulesMatchSrcFWMarkTable(r0, new)).To(Equal(false))
 	})
+	It("should match on src dst fwmark table", func() {
+		new := r1.Copy()
+		new.NetLinkRule().Table = 10
+		Expect(RulesMatchSrcDstFWMarkTable(r0, new)).To(Equal(true))
+
+		new.NetLinkRule().Dst = mustParseCIDR("10.0.2.0/26")
+		Expect(RulesMatchSrcFWMarkTable(r0, new)).To(Equal(true))
+		Expect(RulesMatchSrcDstFWMarkTable(r0, new)).To(Equal(false))
+	})
 })
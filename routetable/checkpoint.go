@@ -0,0 +1,134 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routetable
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/ip"
+)
+
+// CheckpointKey identifies the route table in a checkpoint.  It's made from the IP version, the
+// table index and the interfaces that the route table manages, which don't change across
+// restarts unless the configuration does.
+func (r *RouteTable) CheckpointKey() string {
+	return fmt.Sprintf("v%d-table-%d-%s-%v",
+		r.ipVersion, r.tableIndex, r.ifacePrefixRegexp, r.includeNoInterface)
+}
+
+// Checkpoint returns the routes that we've programmed, keyed by interface name.
+func (r *RouteTable) Checkpoint() map[string][]string {
+	cp := map[string][]string{}
+	for ifaceName, targets := range r.ifaceNameToTargets {
+		entries := make([]string, 0, len(targets))
+		for _, target := range targets {
+			entries = append(entries, checkpointEntry(target))
+		}
+		sort.Strings(entries)
+		cp[ifaceName] = entries
+	}
+	return cp
+}
+
+// checkpointEntry describes a route in the checkpoint.  It starts with the route's CIDR.
+func checkpointEntry(target Target) string {
+	return fmt.Sprintf("%v type=%v gw=%v mac=%v", target.CIDR, target.Type, target.GW, target.DestMAC)
+}
+
+// LoadCheckpoint loads a checkpoint that was taken by a previous instance of Felix.  The first
+// Apply() skips the full resync of each interface whose routes, once the pending deltas are
+// applied, are the ones in the checkpoint; it assumes that they're still programmed, which the
+// next full resync (from the route refresh, for example) checks.  It also removes the conntrack
+// entries of any route in the checkpoint that we no longer want on that interface, as it would if
+// we'd removed the route ourselves; that covers the routes that went away while Felix wasn't
+// running, for example because their interface was deleted.
+func (r *RouteTable) LoadCheckpoint(cp map[string][]string) {
+	r.checkpoint = cp
+}
+
+// cleanUpConntrackForCheckpoint starts the conntrack deletions for the routes in the checkpoint
+// that we no longer want, and then discards the checkpoint.
+func (r *RouteTable) cleanUpConntrackForCheckpoint() {
+	for ifaceName, entries := range r.checkpoint {
+		for _, entry := range entries {
+			cidr, err := ip.ParseCIDROrIP(strings.SplitN(entry, " ", 2)[0])
+			if err != nil {
+				r.logCxt.WithError(err).WithField("route", entry).Warn("Ignoring bad route in checkpoint.")
+				continue
+			}
+			if r.routeWanted(ifaceName, cidr) {
+				continue
+			}
+			if _, ok := r.pendingConntrackCleanups[cidr.Addr()]; ok {
+				continue
+			}
+			r.logCxt.WithFields(log.Fields{
+				"ifaceName": ifaceName,
+				"cidr":      cidr,
+			}).Debug("Route in checkpoint no longer wanted, removing its conntrack entries.")
+			r.startConntrackDeletion(cidr.Addr())
+		}
+	}
+	r.checkpoint = nil
+}
+
+// routeWanted returns true if, once the pending deltas are applied, we want a route to the CIDR
+// on the interface.
+func (r *RouteTable) routeWanted(ifaceName string, cidr ip.CIDR) bool {
+	if target, ok := r.pendingIfaceNameToDeltaTargets[ifaceName][cidr]; ok {
+		return target != nil
+	}
+	_, ok := r.ifaceNameToTargets[ifaceName][cidr]
+	return ok
+}
+
+// seedFromCheckpoint marks the routes that we want on the interface as programmed, and returns
+// true, if they're the routes that the checkpoint has for it.
+func (r *RouteTable) seedFromCheckpoint(checkpoint map[string][]string, ifaceName string) bool {
+	entries, ok := checkpoint[ifaceName]
+	if !ok {
+		return false
+	}
+	wanted := map[ip.CIDR]Target{}
+	for cidr, target := range r.ifaceNameToTargets[ifaceName] {
+		wanted[cidr] = target
+	}
+	for cidr, target := range r.pendingIfaceNameToDeltaTargets[ifaceName] {
+		if target == nil {
+			delete(wanted, cidr)
+		} else {
+			wanted[cidr] = *target
+		}
+	}
+	if len(wanted) != len(entries) {
+		return false
+	}
+	inCheckpoint := map[string]bool{}
+	for _, entry := range entries {
+		inCheckpoint[entry] = true
+	}
+	for _, target := range wanted {
+		if !inCheckpoint[checkpointEntry(target)] {
+			return false
+		}
+	}
+	r.ifaceNameToTargets[ifaceName] = wanted
+	delete(r.pendingIfaceNameToDeltaTargets, ifaceName)
+	return true
+}
@@ -15,11 +15,14 @@
 package routetable
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 	"syscall"
 	"time"
@@ -37,11 +40,17 @@ import (
 	"github.com/projectcalico/felix/logutils"
 	"github.com/projectcalico/felix/netlinkshim"
 	"github.com/projectcalico/felix/timeshim"
+	"github.com/projectcalico/felix/tracing"
 )
 
 const (
 	cleanupGracePeriod = 10 * time.Second
 	maxConnFailures    = 3
+
+	// After the kernel runs out of memory for routes, we back off before we try to add more
+	// routes, starting at minResourceBackoff and doubling up to maxResourceBackoff.
+	minResourceBackoff = 100 * time.Millisecond
+	maxResourceBackoff = 10 * time.Second
 )
 
 var (
@@ -52,6 +61,9 @@ var (
 	IfaceNotPresent = errors.New("interface not present")
 	IfaceDown       = errors.New("interface down")
 	IfaceGrace      = errors.New("interface in cleanup grace period")
+	// ResourcesExhausted is returned by Apply() after the kernel ran out of memory (ENOBUFS or
+	// ENOMEM) while we were adding routes, and while Apply() is backing off after that.
+	ResourcesExhausted = errors.New("kernel ran out of memory for routes")
 
 	ipV6LinkLocalCIDR = ip.MustParseCIDROrIP("fe80::/64")
 
@@ -63,10 +75,14 @@ var (
 		Name: "felix_route_table_per_iface_sync_seconds",
 		Help: "Time taken to sync each interface",
 	})
+	countResourcesExhausted = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "felix_route_table_resources_exhausted",
+		Help: "Number of times the kernel ran out of memory (ENOBUFS or ENOMEM) while adding routes.",
+	})
 )
 
 func init() {
-	prometheus.MustRegister(listIfaceTime, perIfaceSyncTime)
+	prometheus.MustRegister(listIfaceTime, perIfaceSyncTime, countResourcesExhausted)
 }
 
 const (
@@ -99,8 +115,15 @@ type L2Target struct {
 
 	// For VXLAN targets, this is the IP address of the remote VTEP.
 	GW ip.Addr
+
+	// Flood, if set, adds an all-zeros FDB entry for the node so that broadcast and multicast
+	// frames, which have no FDB entry of their own, are copied to it.
+	Flood bool
 }
 
+// floodMAC is the MAC address of the FDB entries that broadcast and multicast frames use.
+var floodMAC = net.HardwareAddr{0, 0, 0, 0, 0, 0}
+
 type Target struct {
 	Type    TargetType
 	CIDR    ip.CIDR
@@ -162,6 +185,9 @@ type RouteTable struct {
 	ifaceNameToUpdateType map[string]updateType
 	ifacePrefixRegexp     *regexp.Regexp
 	includeNoInterface    bool
+	// ifaceNameToSyncErr holds the last error for each interface that we failed to sync even
+	// after retries.
+	ifaceNameToSyncErr map[string]error
 
 	ifaceNameToTargets             map[string]map[ip.CIDR]Target
 	ifaceNameToL2Targets           map[string][]L2Target
@@ -181,6 +207,21 @@ type RouteTable struct {
 
 	// The route table index. A value of 0 defaults to the main table.
 	tableIndex int
+	// vrfTables, if set, puts the routes of an interface that is enslaved to a VRF into the
+	// VRF's table rather than the main table.
+	vrfTables bool
+
+	// resourceBackoff is the current backoff after the kernel ran out of memory for routes, zero
+	// if the last Apply() didn't hit that.  We don't try to program routes before nextApplyTime.
+	resourceBackoff time.Duration
+	nextApplyTime   time.Time
+
+	// numInconsistencies counts the incorrect and missing routes that we've found when resyncing.
+	numInconsistencies int
+
+	// checkpoint holds the routes from the checkpoint that was loaded by LoadCheckpoint(), until
+	// the first Apply().
+	checkpoint map[string][]string
 
 	// Testing shims, swapped with mock versions for UT
 	newNetlinkHandle  func() (netlinkshim.Interface, error)
@@ -271,6 +312,7 @@ func NewWithShims(
 		pendingIfaceNameToL2Targets:    map[string][]L2Target{},
 		reSync:                         true,
 		ifaceNameToUpdateType:          map[string]updateType{},
+		ifaceNameToSyncErr:             map[string]error{},
 		pendingConntrackCleanups:       map[ip.Addr]chan struct{}{},
 		newNetlinkHandle:               newNetlinkHandle,
 		netlinkTimeout:                 netlinkTimeout,
@@ -286,6 +328,14 @@ func NewWithShims(
 	}
 }
 
+// EnableVRFTables makes the route table program the routes of interfaces that are enslaved to a
+// VRF into the VRF's table, so that they're used for traffic in the VRF.  It only affects a route
+// table that manages the main table.  Enslaving an interface cycles it down and up, which
+// triggers a full resync of its routes.
+func (r *RouteTable) EnableVRFTables() {
+	r.vrfTables = true
+}
+
 func (r *RouteTable) OnIfaceStateChanged(ifaceName string, state ifacemonitor.State) {
 	logCxt := r.logCxt.WithField("ifaceName", ifaceName)
 	if !r.ifacePrefixRegexp.MatchString(ifaceName) {
@@ -403,6 +453,93 @@ func (r *RouteTable) SetL2Routes(ifaceName string, targets []L2Target) {
 	r.markIfaceForUpdate(ifaceName, false)
 }
 
+// RemoveAllRoutes sets the routes of every interface to nothing, so that the next Apply removes
+// all the routes that we've programmed.  It's used to clean up the dataplane before Felix shuts
+// down.
+func (r *RouteTable) RemoveAllRoutes() {
+	ifaceNames := map[string]bool{}
+	for ifaceName := range r.ifaceNameToTargets {
+		ifaceNames[ifaceName] = true
+	}
+	for ifaceName := range r.pendingIfaceNameToDeltaTargets {
+		ifaceNames[ifaceName] = true
+	}
+	for ifaceName := range ifaceNames {
+		r.SetRoutes(ifaceName, nil)
+	}
+	for ifaceName := range r.ifaceNameToL2Targets {
+		r.SetL2Routes(ifaceName, nil)
+	}
+	r.QueueResync()
+}
+
+// DumpState writes the desired routes to w, one line per route, including any updates that are
+// still pending.  It must be called from the thread that owns the RouteTable.
+func (r *RouteTable) DumpState(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "# IPv%d table %d\n", r.ipVersion, r.tableIndex); err != nil {
+		return err
+	}
+	desired := map[string]map[ip.CIDR]Target{}
+	for ifaceName, targets := range r.ifaceNameToTargets {
+		desired[ifaceName] = map[ip.CIDR]Target{}
+		for cidr, t := range targets {
+			desired[ifaceName][cidr] = t
+		}
+	}
+	for ifaceName, deltas := range r.pendingIfaceNameToDeltaTargets {
+		if desired[ifaceName] == nil {
+			desired[ifaceName] = map[ip.CIDR]Target{}
+		}
+		for cidr, t := range deltas {
+			if t == nil {
+				delete(desired[ifaceName], cidr)
+			} else {
+				desired[ifaceName][cidr] = *t
+			}
+		}
+	}
+
+	var lines []string
+	for ifaceName, targets := range desired {
+		for cidr, t := range targets {
+			line := fmt.Sprintf("%s dev %s", cidr, ifaceName)
+			if t.Type != "" {
+				line += " type " + string(t.Type)
+			}
+			if t.GW != nil {
+				line += " via " + t.GW.String()
+			}
+			if t.DestMAC != nil {
+				line += " lladdr " + t.DestMAC.String()
+			}
+			lines = append(lines, line)
+		}
+	}
+	for ifaceName, targets := range r.ifaceNameToL2Targets {
+		if pending, ok := r.pendingIfaceNameToL2Targets[ifaceName]; ok {
+			targets = pending
+		}
+		for _, t := range targets {
+			lines = append(lines, fmt.Sprintf("l2 %s dev %s vtep %s lladdr %s", t.IP, ifaceName, t.GW, t.VTEPMAC))
+		}
+	}
+	for ifaceName, targets := range r.pendingIfaceNameToL2Targets {
+		if _, ok := r.ifaceNameToL2Targets[ifaceName]; ok {
+			continue
+		}
+		for _, t := range targets {
+			lines = append(lines, fmt.Sprintf("l2 %s dev %s vtep %s lladdr %s", t.IP, ifaceName, t.GW, t.VTEPMAC))
+		}
+	}
+	sort.Strings(lines)
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *RouteTable) QueueResync() {
 	r.logCxt.Debug("Queueing a resync of routing table.")
 	r.reSync = true
@@ -448,7 +585,66 @@ func (r *RouteTable) closeNetlink() {
 	r.cachedNetlinkHandle = nil
 }
 
+// NumInconsistencies returns the number of incorrect or missing routes that we've found (and
+// queued for repair) since we were created.
+func (r *RouteTable) NumInconsistencies() int {
+	return r.numInconsistencies
+}
+
+// IfaceSyncErrors returns the interfaces whose routes we failed to sync in spite of retries, with
+// the last error for each.  An interface stays in the map until its routes are synced or it goes
+// away.
+func (r *RouteTable) IfaceSyncErrors() map[string]error {
+	errs := make(map[string]error, len(r.ifaceNameToSyncErr))
+	for ifaceName, err := range r.ifaceNameToSyncErr {
+		errs[ifaceName] = err
+	}
+	return errs
+}
+
+// BackoffRemaining returns how long Apply() will keep backing off after the kernel ran out of
+// memory for routes, or zero if it isn't backing off.
+func (r *RouteTable) BackoffRemaining() time.Duration {
+	if r.nextApplyTime.IsZero() {
+		return 0
+	}
+	if remaining := r.nextApplyTime.Sub(r.time.Now()); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+func (r *RouteTable) startResourceBackoff() {
+	countResourcesExhausted.Inc()
+	r.resourceBackoff *= 2
+	if r.resourceBackoff < minResourceBackoff {
+		r.resourceBackoff = minResourceBackoff
+	} else if r.resourceBackoff > maxResourceBackoff {
+		r.resourceBackoff = maxResourceBackoff
+	}
+	r.nextApplyTime = r.time.Now().Add(r.resourceBackoff)
+	r.logCxt.WithField("backoff", r.resourceBackoff).Warn(
+		"Kernel ran out of memory for routes, backing off before adding more.")
+}
+
 func (r *RouteTable) Apply() error {
+	return r.ApplyWithContext(context.Background())
+}
+
+// ApplyWithContext is Apply, recording its netlink interface listing and the netlink updates of
+// each interface as child spans of the span in ctx.
+func (r *RouteTable) ApplyWithContext(ctx context.Context) error {
+	if r.BackoffRemaining() > 0 {
+		r.logCxt.Debug("Still backing off after the kernel ran out of memory for routes.")
+		return ResourcesExhausted
+	}
+
+	// The checkpoint only applies to the first Apply().
+	checkpoint := r.checkpoint
+	if checkpoint != nil {
+		r.cleanUpConntrackForCheckpoint()
+	}
+
 	if r.reSync {
 		r.opReporter.RecordOperation(fmt.Sprint("resync-routes-v", r.ipVersion))
 
@@ -459,7 +655,10 @@ func (r *RouteTable) Apply() error {
 			r.logCxt.WithError(err).Error("Failed to connect to netlink, retrying...")
 			return ConnectFailed
 		}
+		_, listSpan := tracing.StartSpan(ctx, "netlink.LinkList")
 		links, err := nl.LinkList()
+		listSpan.RecordError(err)
+		listSpan.End()
 		if err != nil {
 			r.logCxt.WithError(err).Error("Failed to list interfaces, retrying...")
 			r.closeNetlink() // Defensive: force a netlink reconnection next time.
@@ -511,6 +710,8 @@ func (r *RouteTable) Apply() error {
 	}
 
 	graceIfaces := 0
+	exhausted := false
+retryLoop:
 	for retry := 0; retry < maxApplyRetries; retry++ {
 	ifaceLoop:
 		for ifaceName, ia := range r.ifaceNameToUpdateType {
@@ -518,6 +719,13 @@ func (r *RouteTable) Apply() error {
 			firstTry := retry == 0
 			lastTry := retry == maxApplyRetries-1
 			fullResync := ia == updateTypeFullResync || lastTry
+			if fullResync && firstTry && checkpoint != nil && r.seedFromCheckpoint(checkpoint, ifaceName) {
+				logCxt.Debug("Routes match the checkpoint, skipping full resync of interface")
+				fullResync = false
+			}
+			_, syncSpan := tracing.StartSpan(ctx, "netlink.SyncRoutes")
+			syncSpan.SetAttribute("ifaceName", ifaceName)
+			syncSpan.SetAttribute("fullResync", fullResync)
 			var err error
 			if r.vxlan {
 				// Sync L2 routes first.
@@ -527,20 +735,25 @@ func (r *RouteTable) Apply() error {
 				// No errors syncing L2, sync L3 routes.
 				err = r.syncRoutesForLink(ifaceName, fullResync, firstTry)
 			}
+			syncSpan.RecordError(err)
+			syncSpan.End()
 
 			// Handle errors from syncing either L2 or L3 routes.
 			switch err {
 			case nil:
 				logCxt.Debug("Synchronised routes on interface")
 				delete(r.ifaceNameToUpdateType, ifaceName)
+				delete(r.ifaceNameToSyncErr, ifaceName)
 				continue ifaceLoop
 			case IfaceNotPresent:
 				logCxt.Info("Interface missing, will retry if it appears.")
 				delete(r.ifaceNameToUpdateType, ifaceName)
+				delete(r.ifaceNameToSyncErr, ifaceName)
 				continue ifaceLoop
 			case IfaceDown:
 				logCxt.Info("Interface down, will retry if it goes up.")
 				delete(r.ifaceNameToUpdateType, ifaceName)
+				delete(r.ifaceNameToSyncErr, ifaceName)
 				continue ifaceLoop
 			case IfaceGrace:
 				if lastTry {
@@ -548,6 +761,12 @@ func (r *RouteTable) Apply() error {
 				}
 				graceIfaces++
 				continue ifaceLoop
+			case ResourcesExhausted:
+				// Retrying straight away would only fail again.  The routes that we didn't
+				// add are queued as deltas, so leave this and the other dirty interfaces
+				// for after the backoff.
+				exhausted = true
+				break retryLoop
 			}
 
 			if lastTry {
@@ -555,12 +774,20 @@ func (r *RouteTable) Apply() error {
 				logCxt.Warn("Failed to sync routes to interface even after retries. " +
 					"Leaving it dirty, requiring a full sync.")
 				r.markIfaceForUpdate(ifaceName, true)
+				r.ifaceNameToSyncErr[ifaceName] = err
 			}
 		}
 	}
 
 	r.cleanUpPendingConntrackDeletions()
 
+	if exhausted {
+		r.startResourceBackoff()
+		return ResourcesExhausted
+	}
+	r.resourceBackoff = 0
+	r.nextApplyTime = time.Time{}
+
 	// Don't return a failure if there are only interfaces in the cleanup grace period.
 	// They'll be retried on the next invocation (the route refresh timer), and we mustn't
 	// count them as Sync Errors.
@@ -642,10 +869,15 @@ func (r *RouteTable) syncRoutesForLink(ifaceName string, fullSync bool, firstTry
 		return ConnectFailed
 	}
 
+	table, err := r.tableForLink(nl, linkAttrs)
+	if err != nil {
+		return r.filterErrorByIfaceState(ifaceName, err, GetFailed, firstTry)
+	}
+
 	// Add the target deletes to the set of routes to delete (we do this first so that we only have one set of deletion
 	// data that we use to tidy up routes and conntrack entries).
 	for _, target := range targetsToDelete {
-		routesToDelete = append(routesToDelete, r.createL3Route(linkAttrs, target))
+		routesToDelete = append(routesToDelete, r.createL3Route(linkAttrs, table, target))
 	}
 
 	// Delete the combined set of routes.
@@ -657,13 +889,21 @@ func (r *RouteTable) syncRoutesForLink(ifaceName string, fullSync bool, firstTry
 	}
 
 	// Now add target routes.
-	for _, target := range targetsToCreate {
-		route := r.createL3Route(linkAttrs, target)
+	for i, target := range targetsToCreate {
+		route := r.createL3Route(linkAttrs, table, target)
 
 		// In case this IP is being re-used, wait for any previous conntrack entry
 		// to be cleaned up.  (No-op if there are no pending deletes.)
 		r.waitForPendingConntrackDeletion(target.CIDR.Addr())
 		if err := nl.RouteAdd(&route); err != nil {
+			if isResourceExhaustedErr(err) {
+				// The remaining routes would fail too; requeue them so that we only
+				// retry the routes that we failed to add.
+				logCxt.WithError(err).WithField("numRoutes", len(targetsToCreate)-i).Warn(
+					"Kernel ran out of memory for routes, will retry adding the remaining routes")
+				r.requeueTargets(ifaceName, targetsToCreate[i:])
+				return ResourcesExhausted
+			}
 			if firstTry {
 				logCxt.WithError(err).Debug("Failed to add route on first attempt, retrying...")
 			} else {
@@ -733,7 +973,26 @@ func (r *RouteTable) applyRouteDeltas(ifaceName string, deletedConnCIDRs set.Set
 	return
 }
 
-func (r *RouteTable) createL3Route(linkAttrs *netlink.LinkAttrs, target Target) netlink.Route {
+// tableForLink returns the table for the link's routes: the table of its VRF, if VRF tables are
+// enabled and the link is enslaved to one, or our table otherwise.
+func (r *RouteTable) tableForLink(nl netlinkshim.Interface, linkAttrs *netlink.LinkAttrs) (int, error) {
+	if !r.vrfTables || r.tableIndex != 0 || linkAttrs == nil || linkAttrs.MasterIndex == 0 {
+		return r.tableIndex, nil
+	}
+	master, err := nl.LinkByIndex(linkAttrs.MasterIndex)
+	if err != nil {
+		r.logCxt.WithError(err).WithField("ifaceName", linkAttrs.Name).Warn(
+			"Failed to look up interface's master device")
+		return 0, err
+	}
+	if vrf, ok := master.(*netlink.Vrf); ok {
+		return int(vrf.Table), nil
+	}
+	// Enslaved to a bridge or bond, say, which doesn't affect routing.
+	return r.tableIndex, nil
+}
+
+func (r *RouteTable) createL3Route(linkAttrs *netlink.LinkAttrs, table int, target Target) netlink.Route {
 	log.Debugf("Create L3 route for: %#v", target)
 	var linkIndex int
 	if linkAttrs != nil {
@@ -747,7 +1006,7 @@ func (r *RouteTable) createL3Route(linkAttrs *netlink.LinkAttrs, target Target)
 		Type:      target.RouteType(),
 		Protocol:  r.deviceRouteProtocol,
 		Scope:     target.RouteScope(),
-		Table:     r.tableIndex,
+		Table:     table,
 	}
 
 	if r.deviceRouteSourceAddress != nil {
@@ -793,11 +1052,15 @@ func (r *RouteTable) fullResyncRoutesForLink(logCxt *log.Entry, ifaceName string
 	// was oper down before we tried to do the sync but that prevented us from removing
 	// routes from an interface in some corner cases (such as being admin up but oper
 	// down).
+	table, err := r.tableForLink(nl, linkAttrs)
+	if err != nil {
+		return nil, r.filterErrorByIfaceState(ifaceName, err, GetFailed, false)
+	}
 	routeFilter := &netlink.Route{
-		Table: r.tableIndex,
+		Table: table,
 	}
 	routeFilterFlags := netlink.RT_FILTER_OIF
-	if r.tableIndex != 0 {
+	if table != 0 {
 		routeFilterFlags |= netlink.RT_FILTER_TABLE
 	}
 	if linkAttrs != nil {
@@ -875,6 +1138,10 @@ func (r *RouteTable) fullResyncRoutesForLink(logCxt *log.Entry, ifaceName string
 		}
 		logCxt.WithField("routeProblems", routeProblems).Info("Remove old route")
 		routesToDelete = append(routesToDelete, route)
+		if !routeExpected {
+			// Incorrect routes that we expect are counted below.
+			r.numInconsistencies++
+		}
 		if dest != nil {
 			deletedConnCIDRs.Add(dest)
 		}
@@ -889,6 +1156,7 @@ func (r *RouteTable) fullResyncRoutesForLink(logCxt *log.Entry, ifaceName string
 		logCxt := logCxt.WithField("cidr", cidr)
 		logCxt.Info("Deleting from expected targets")
 		delete(expectedTargets, cidr)
+		r.numInconsistencies++
 
 		// If we do not have an update that supercedes this entry, then add it back in as an update so that we add
 		// the route.
@@ -936,8 +1204,12 @@ func (r *RouteTable) syncL2RoutesForLink(ifaceName string) error {
 	// so we can compare the expected L2 targets against the programmed ones
 	// for this link.
 	expected := map[string]bool{}
+	expectedFloods := map[string]bool{}
 	for _, target := range expectedTargets {
 		expected[target.VTEPMAC.String()] = true
+		if target.Flood {
+			expectedFloods[target.IP.String()] = true
+		}
 	}
 
 	// Get the current set of neighbors on this interface.
@@ -984,6 +1256,32 @@ func (r *RouteTable) syncL2RoutesForLink(ifaceName string) error {
 		}
 	}
 
+	// Remove the flood entries that we no longer want.  With an underlay multicast group, the
+	// kernel adds a flood entry for the group itself, so we leave multicast destinations alone.
+	existingFDB, err := netlink.NeighList(linkAttrs.Index, syscall.AF_BRIDGE)
+	if err != nil {
+		return err
+	}
+	existingFloods := map[string]bool{}
+	for _, existing := range existingFDB {
+		if existing.IP == nil || existing.IP.IsMulticast() || existing.HardwareAddr.String() != floodMAC.String() {
+			continue
+		}
+		if expectedFloods[existing.IP.String()] {
+			existingFloods[existing.IP.String()] = true
+			continue
+		}
+		n := existing
+		if err := netlink.NeighDel(&n); err != nil {
+			if !strings.Contains(err.Error(), "no such file or directory") {
+				logCxt.WithError(err).Warnf("Failed to delete flood FDB entry %+v", n)
+				updatesFailed = true
+			}
+		} else {
+			logCxt.WithField("neighbor", existing).Info("Removed old flood FDB entry")
+		}
+	}
+
 	// For each expected target, ensure that it is programmed. If the value has changed since last programming, this
 	// will update it.
 	for _, target := range expectedTargets {
@@ -992,6 +1290,23 @@ func (r *RouteTable) syncL2RoutesForLink(ifaceName string) error {
 			updatesFailed = true
 			continue
 		}
+		if target.Flood && !existingFloods[target.IP.String()] {
+			// There's one flood entry per node, all with the same MAC, so we append them.
+			n := &netlink.Neigh{
+				LinkIndex:    linkAttrs.Index,
+				State:        netlink.NUD_PERMANENT,
+				Family:       syscall.AF_BRIDGE,
+				Flags:        netlink.NTF_SELF,
+				IP:           target.IP.AsNetIP(),
+				HardwareAddr: floodMAC,
+			}
+			if err := netlink.NeighAppend(n); err != nil {
+				logCxt.WithError(err).Warnf("Failed to add flood FDB entry %+v", n)
+				updatesFailed = true
+				continue
+			}
+			log.WithField("entry", n).Debug("Programmed flood FDB")
+		}
 	}
 
 	if updatesFailed {
@@ -1168,6 +1483,28 @@ func (r *RouteTable) getLinkAttributes(ifaceName string) (*netlink.LinkAttrs, er
 	return link.Attrs(), nil
 }
 
+// requeueTargets queues targets that we failed to program as deltas again, so that the next
+// sync of the interface retries them.
+func (r *RouteTable) requeueTargets(ifaceName string, targets []Target) {
+	cidrsToTarget := r.ifaceNameToTargets[ifaceName]
+	deltas := r.pendingIfaceNameToDeltaTargets[ifaceName]
+	if deltas == nil {
+		deltas = map[ip.CIDR]*Target{}
+		r.pendingIfaceNameToDeltaTargets[ifaceName] = deltas
+	}
+	for _, target := range targets {
+		delete(cidrsToTarget, target.CIDR)
+		deltas[target.CIDR] = safeTargetPointer(target)
+	}
+	if len(cidrsToTarget) == 0 {
+		delete(r.ifaceNameToTargets, ifaceName)
+	}
+}
+
+func isResourceExhaustedErr(err error) bool {
+	return errors.Is(err, syscall.ENOBUFS) || errors.Is(err, syscall.ENOMEM)
+}
+
 // safeTargetPointer returns a pointer to a Target safely ensuring the pointer is unique.
 func safeTargetPointer(target Target) *Target {
 	return &target
@@ -105,13 +105,8 @@ var _ = Describe("RouteTable", func() {
 	var t *mocktime.MockTime
 	var rt *RouteTable
 
-	BeforeEach(func() {
-		dataplane = mocknetlink.New()
-		t = mocktime.New()
-		// Setting an auto-increment greater than the route cleanup delay effectively
-		// disables the grace period for these tests.
-		t.SetAutoIncrement(11 * time.Second)
-		rt = NewWithShims(
+	newRouteTable := func() *RouteTable {
+		return NewWithShims(
 			[]string{"^cali.*"},
 			4,
 			dataplane.NewMockNetlink,
@@ -126,6 +121,15 @@ var _ = Describe("RouteTable", func() {
 			0,
 			logutils.NewSummarizer("test"),
 		)
+	}
+
+	BeforeEach(func() {
+		dataplane = mocknetlink.New()
+		t = mocktime.New()
+		// Setting an auto-increment greater than the route cleanup delay effectively
+		// disables the grace period for these tests.
+		t.SetAutoIncrement(11 * time.Second)
+		rt = newRouteTable()
 	})
 
 	It("should be constructable", func() {
@@ -216,6 +220,19 @@ var _ = Describe("RouteTable", func() {
 			Expect(dataplane.RouteKeyToRoute).To(ConsistOf(gatewayRoute))
 			Expect(dataplane.AddedRouteKeys).To(BeEmpty())
 		})
+		It("should remove all our routes after RemoveAllRoutes", func() {
+			rt.SetRoutes("cali1", []Target{
+				{CIDR: ip.MustParseCIDROrIP("10.0.0.1/32"), DestMAC: mac1},
+			})
+			err := rt.Apply()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dataplane.RouteKeyToRoute).To(HaveLen(2))
+
+			rt.RemoveAllRoutes()
+			err = rt.Apply()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dataplane.RouteKeyToRoute).To(ConsistOf(gatewayRoute))
+		})
 		It("should delete only our conntrack entries", func() {
 			err := rt.Apply()
 			Expect(err).ToNot(HaveOccurred())
@@ -610,6 +627,10 @@ var _ = Describe("RouteTable", func() {
 				}))
 			})
 
+			It("reports the interface as failed", func() {
+				Expect(rt.IfaceSyncErrors()).To(HaveKey("cali3"))
+			})
+
 			It("resolves on the next apply", func() {
 				err := rt.Apply()
 				Expect(err).ToNot(HaveOccurred())
@@ -621,6 +642,54 @@ var _ = Describe("RouteTable", func() {
 					Protocol:  FelixRouteProtocol,
 					Scope:     netlink.SCOPE_LINK,
 				}))
+				Expect(rt.IfaceSyncErrors()).To(BeEmpty())
+			})
+		})
+
+		Describe("after the kernel runs out of memory for routes", func() {
+			route := func(cidr string) netlink.Route {
+				return netlink.Route{
+					LinkIndex: cali3.LinkAttrs.Index,
+					Dst:       mustParseCIDR(cidr),
+					Type:      syscall.RTN_UNICAST,
+					Protocol:  FelixRouteProtocol,
+					Scope:     netlink.SCOPE_LINK,
+				}
+			}
+
+			JustBeforeEach(func() {
+				err := rt.Apply()
+				Expect(err).NotTo(HaveOccurred())
+				t.SetAutoIncrement(0)
+
+				dataplane.FailuresToSimulate = mocknetlink.FailNextRouteAddNoBufs
+				dataplane.PersistFailures = true
+				rt.RouteUpdate("cali3", Target{CIDR: ip.MustParseCIDROrIP("10.20.30.40")})
+				rt.RouteUpdate("cali3", Target{CIDR: ip.MustParseCIDROrIP("10.20.30.41")})
+				err = rt.Apply()
+				Expect(err).To(Equal(ResourcesExhausted))
+			})
+
+			It("backs off and then adds the routes", func() {
+				Expect(rt.BackoffRemaining()).To(Equal(100 * time.Millisecond))
+
+				dataplane.FailuresToSimulate = 0
+				dataplane.PersistFailures = false
+				Expect(rt.Apply()).To(Equal(ResourcesExhausted))
+				Expect(dataplane.RouteKeyToRoute).NotTo(ContainElement(route("10.20.30.40/32")))
+				Expect(dataplane.RouteKeyToRoute).NotTo(ContainElement(route("10.20.30.41/32")))
+
+				t.IncrementTime(100 * time.Millisecond)
+				Expect(rt.Apply()).NotTo(HaveOccurred())
+				Expect(rt.BackoffRemaining()).To(BeZero())
+				Expect(dataplane.RouteKeyToRoute).To(ContainElement(route("10.20.30.40/32")))
+				Expect(dataplane.RouteKeyToRoute).To(ContainElement(route("10.20.30.41/32")))
+			})
+
+			It("doubles the backoff while the kernel is still out of memory", func() {
+				t.IncrementTime(100 * time.Millisecond)
+				Expect(rt.Apply()).To(Equal(ResourcesExhausted))
+				Expect(rt.BackoffRemaining()).To(Equal(200 * time.Millisecond))
 			})
 		})
 
@@ -750,6 +819,81 @@ var _ = Describe("RouteTable", func() {
 			})
 		})
 
+		Describe("after restarting with a checkpoint", func() {
+			var cp map[string][]string
+
+			BeforeEach(func() {
+				rt.SetRoutes("cali1", []Target{
+					{CIDR: ip.MustParseCIDROrIP("10.0.0.1/32")},
+				})
+				rt.SetRoutes("cali3", []Target{
+					{CIDR: ip.MustParseCIDROrIP("10.0.0.3/32")},
+				})
+				err := rt.Apply()
+				Expect(err).NotTo(HaveOccurred())
+				cp = rt.Checkpoint()
+
+				rt = newRouteTable()
+				rt.LoadCheckpoint(cp)
+			})
+
+			It("should checkpoint the programmed routes", func() {
+				Expect(cp).To(Equal(map[string][]string{
+					"cali1": {"10.0.0.1/32 type= gw=<nil> mac="},
+					"cali3": {"10.0.0.3/32 type= gw=<nil> mac="},
+				}))
+			})
+
+			It("should skip the full resync of interfaces whose routes match the checkpoint", func() {
+				cali1Route := netlink.Route{
+					LinkIndex: cali1.LinkAttrs.Index,
+					Dst:       mustParseCIDR("10.0.0.1/32"),
+					Type:      syscall.RTN_UNICAST,
+					Protocol:  FelixRouteProtocol,
+					Scope:     netlink.SCOPE_LINK,
+				}
+				Expect(dataplane.RouteKeyToRoute).To(ContainElement(cali1Route))
+				dataplane.RemoveMockRoute(&cali1Route)
+				rt.SetRoutes("cali1", []Target{
+					{CIDR: ip.MustParseCIDROrIP("10.0.0.1/32")},
+				})
+				rt.SetRoutes("cali3", []Target{
+					{CIDR: ip.MustParseCIDROrIP("10.0.0.3/32")},
+				})
+				err := rt.Apply()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(dataplane.RouteKeyToRoute).NotTo(ContainElement(cali1Route))
+
+				// The next full resync puts the route back.
+				rt.QueueResync()
+				err = rt.Apply()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(dataplane.RouteKeyToRoute).To(ContainElement(cali1Route))
+			})
+
+			It("should delete conntrack entries for an interface that was deleted while stopped", func() {
+				delete(dataplane.NameToLink, "cali3")
+				rt.SetRoutes("cali1", []Target{
+					{CIDR: ip.MustParseCIDROrIP("10.0.0.1/32")},
+				})
+				err := rt.Apply()
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(dataplane.GetDeletedConntrackEntries).Should(Equal([]net.IP{net.ParseIP("10.0.0.3").To4()}))
+			})
+
+			It("should leave conntrack entries for routes that are still wanted", func() {
+				rt.SetRoutes("cali1", []Target{
+					{CIDR: ip.MustParseCIDROrIP("10.0.0.1/32")},
+				})
+				rt.SetRoutes("cali3", []Target{
+					{CIDR: ip.MustParseCIDROrIP("10.0.0.3/32")},
+				})
+				err := rt.Apply()
+				Expect(err).NotTo(HaveOccurred())
+				Consistently(dataplane.GetDeletedConntrackEntries).Should(BeEmpty())
+			})
+		})
+
 		// We do the following tests in different failure (and non-failure) scenarios.  In
 		// each case, we make the failure transient so that only the first Apply() should
 		// fail.  Then, at most, the second call to Apply() should succeed.
@@ -1286,6 +1430,86 @@ var _ = Describe("Tests to verify netlink interface", func() {
 	})
 })
 
+var _ = Describe("RouteTable (VRF tables)", func() {
+	var dataplane *mocknetlink.MockNetlinkDataplane
+	var t *mocktime.MockTime
+	var rt *RouteTable
+	var cali1, cali2 *mocknetlink.MockLink
+	var cali1MainRoute, cali1StaleVRFRoute netlink.Route
+
+	BeforeEach(func() {
+		dataplane = mocknetlink.New()
+		t = mocktime.New()
+		t.SetAutoIncrement(11 * time.Second)
+		rt = NewWithShims(
+			[]string{"^cali.*"},
+			4,
+			dataplane.NewMockNetlink,
+			false,
+			10*time.Second,
+			dataplane.AddStaticArpEntry,
+			dataplane,
+			t,
+			nil,
+			FelixRouteProtocol,
+			true,
+			0,
+			logutils.NewSummarizer("test"),
+		)
+		rt.EnableVRFTables()
+
+		vrf := dataplane.AddIface(10, "vrf-red", true, true)
+		vrf.LinkType = "vrf"
+		vrf.VRFTable = 100
+		cali1 = dataplane.AddIface(1, "cali1", true, true)
+		cali1.LinkAttrs.MasterIndex = vrf.LinkAttrs.Index
+		cali2 = dataplane.AddIface(2, "cali2", true, true)
+
+		// A route in the main table isn't ours to clean up once the interface is in a VRF.
+		cali1MainRoute = netlink.Route{
+			LinkIndex: cali1.LinkAttrs.Index,
+			Dst:       mustParseCIDR("10.0.0.1/32"),
+			Type:      syscall.RTN_UNICAST,
+			Protocol:  FelixRouteProtocol,
+			Scope:     netlink.SCOPE_LINK,
+		}
+		dataplane.AddMockRoute(&cali1MainRoute)
+		cali1StaleVRFRoute = netlink.Route{
+			LinkIndex: cali1.LinkAttrs.Index,
+			Dst:       mustParseCIDR("10.0.0.3/32"),
+			Type:      syscall.RTN_UNICAST,
+			Protocol:  FelixRouteProtocol,
+			Scope:     netlink.SCOPE_LINK,
+			Table:     100,
+		}
+		dataplane.AddMockRoute(&cali1StaleVRFRoute)
+	})
+
+	It("should program routes into the table of the interface's VRF", func() {
+		rt.SetRoutes("cali1", []Target{{CIDR: ip.MustParseCIDROrIP("10.0.0.2")}})
+		rt.SetRoutes("cali2", []Target{{CIDR: ip.MustParseCIDROrIP("10.0.0.4")}})
+		Expect(rt.Apply()).To(Succeed())
+		Expect(dataplane.RouteKeyToRoute).To(ConsistOf(
+			cali1MainRoute,
+			netlink.Route{
+				LinkIndex: cali1.LinkAttrs.Index,
+				Dst:       mustParseCIDR("10.0.0.2/32"),
+				Type:      syscall.RTN_UNICAST,
+				Protocol:  FelixRouteProtocol,
+				Scope:     netlink.SCOPE_LINK,
+				Table:     100,
+			},
+			netlink.Route{
+				LinkIndex: cali2.LinkAttrs.Index,
+				Dst:       mustParseCIDR("10.0.0.4/32"),
+				Type:      syscall.RTN_UNICAST,
+				Protocol:  FelixRouteProtocol,
+				Scope:     netlink.SCOPE_LINK,
+			},
+		))
+	})
+})
+
 var _ = Describe("Tests to verify ip version is policed", func() {
 	It("Should panic with an invalid IP version", func() {
 		Expect(func() {
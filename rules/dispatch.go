@@ -43,13 +43,22 @@ func (r *DefaultRuleRenderer) WorkloadDispatchChains(
 			Comment: []string{"Unknown interface"},
 		},
 	}
+	fromEndRules := endRules
+	if r.VRFSupportEnabled {
+		// Packets from workloads in a VRF arrive on the VRF device, dispatch them on the
+		// endpoint mark that the mangle table gave them instead.
+		fromEndRules = append([]Rule{{
+			Match:  Match().MarkNotClear(r.IptablesMarkEndpoint),
+			Action: GotoAction{Target: ChainFromVRFWorkloadDispatch},
+		}}, endRules...)
+	}
 	return r.interfaceNameDispatchChains(
 		names,
 		WorkloadFromEndpointPfx,
 		WorkloadToEndpointPfx,
 		ChainFromWorkloadDispatch,
 		ChainToWorkloadDispatch,
-		endRules,
+		fromEndRules,
 		endRules,
 	)
 }
@@ -133,6 +142,99 @@ func (r *DefaultRuleRenderer) EndpointMarkDispatchChains(
 	)
 }
 
+// Once the kernel has received a packet on an interface that is enslaved to a VRF, it presents it
+// to the filter table on the VRF device, so the filter table can't tell which workload sent it.
+// The mangle PREROUTING chain, which sees the packet on both devices, jumps to the set-VRF-mark
+// chain to give packets from those workloads their endpoint mark, and to keep the mark when it
+// sees them again on the VRF device.  The from-VRF-workload dispatch chain then dispatches on
+// that mark.  Both return nil if VRF support is disabled.
+func (r *DefaultRuleRenderer) VRFSetEndpointMarkChains(
+	epMarkMapper EndpointMarkMapper,
+	wlIfaceVRFs map[string]string,
+) []*Chain {
+	if !r.VRFSupportEnabled {
+		return nil
+	}
+	log.WithField("numWorkloadEndpoint", len(wlIfaceVRFs)).Debug("Rendering VRF endpoint mark chain")
+
+	vrfs := map[string]string{}
+	for _, vrf := range wlIfaceVRFs {
+		vrfs[vrf] = vrf
+	}
+	var rules []Rule
+	for _, vrf := range sortedKeys(vrfs) {
+		rules = append(rules, Rule{
+			Match:  Match().InInterface(vrf),
+			Action: ReturnAction{},
+		})
+	}
+	rules = append(rules, Rule{
+		Action: ClearMarkAction{Mark: epMarkMapper.GetMask()},
+	})
+	for _, name := range sortedKeys(wlIfaceVRFs) {
+		endpointMark, err := epMarkMapper.GetEndpointMark(name)
+		if err != nil {
+			log.WithError(err).WithField("ifaceName", name).Error(
+				"Failed to get endpoint mark for workload in VRF, its traffic will be dropped")
+			continue
+		}
+		rules = append(rules, Rule{
+			Match: Match().InInterface(name),
+			Action: SetMaskedMarkAction{
+				Mark: endpointMark,
+				Mask: epMarkMapper.GetMask(),
+			},
+		})
+	}
+
+	return []*Chain{{
+		Name:  ChainSetVRFEndpointMark,
+		Rules: rules,
+	}}
+}
+
+func (r *DefaultRuleRenderer) VRFWorkloadDispatchChains(
+	epMarkMapper EndpointMarkMapper,
+	wlIfaceVRFs map[string]string,
+) []*Chain {
+	if !r.VRFSupportEnabled {
+		return nil
+	}
+	log.WithField("numWorkloadEndpoint", len(wlIfaceVRFs)).Debug("Rendering VRF workload dispatch chain")
+
+	var rules []Rule
+	for _, name := range sortedKeys(wlIfaceVRFs) {
+		endpointMark, err := epMarkMapper.GetEndpointMark(name)
+		if err != nil {
+			continue
+		}
+		rules = append(rules, Rule{
+			Match: Match().InInterface(wlIfaceVRFs[name]).
+				MarkMatchesWithMask(endpointMark, epMarkMapper.GetMask()),
+			Action: GotoAction{Target: EndpointChainName(WorkloadFromEndpointPfx, name)},
+		})
+	}
+	rules = append(rules, Rule{
+		Match:   Match(),
+		Action:  DropAction{},
+		Comment: []string{"Unknown endpoint mark"},
+	})
+
+	return []*Chain{{
+		Name:  ChainFromVRFWorkloadDispatch,
+		Rules: rules,
+	}}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (r *DefaultRuleRenderer) HostDispatchChains(
 	endpoints map[string]proto.HostEndpointID,
 	defaultIfaceName string,
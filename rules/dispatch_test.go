@@ -1120,6 +1120,111 @@ var _ = Describe("Dispatch chains", func() {
 	}
 })
 
+var _ = Describe("VRF chains", func() {
+	var epMarkMapper EndpointMarkMapper
+	var renderer RuleRenderer
+	var wlIfaceVRFs map[string]string
+	expDropRule := iptables.Rule{
+		Match:   iptables.Match(),
+		Action:  iptables.DropAction{},
+		Comment: []string{"Unknown interface"},
+	}
+	BeforeEach(func() {
+		config := Config{
+			IPSetConfigV4:         ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+			IPSetConfigV6:         ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+			IptablesMarkAccept:    0x8,
+			IptablesMarkPass:      0x10,
+			IptablesMarkScratch0:  0x20,
+			IptablesMarkScratch1:  0x40,
+			IptablesMarkEndpoint:  0xff00,
+			WorkloadIfacePrefixes: []string{"cali"},
+			VRFSupportEnabled:     true,
+		}
+		renderer = NewRenderer(config)
+		epMarkMapper = NewEndpointMarkMapper(config.IptablesMarkEndpoint, 0)
+		wlIfaceVRFs = map[string]string{
+			"cali5678": "vrf-red",
+			"cali1234": "vrf-red",
+			"calidead": "vrf-blue",
+		}
+	})
+
+	It("should mark packets from workloads in a VRF and keep the mark on the VRF device", func() {
+		mark := func(name string) uint32 {
+			m, err := epMarkMapper.GetEndpointMark(name)
+			Expect(err).NotTo(HaveOccurred())
+			return m
+		}
+		Expect(renderer.VRFSetEndpointMarkChains(epMarkMapper, wlIfaceVRFs)).To(Equal([]*iptables.Chain{{
+			Name: "cali-set-vrf-endpoint-mark",
+			Rules: []iptables.Rule{
+				{Match: iptables.Match().InInterface("vrf-blue"), Action: iptables.ReturnAction{}},
+				{Match: iptables.Match().InInterface("vrf-red"), Action: iptables.ReturnAction{}},
+				{Action: iptables.ClearMarkAction{Mark: 0xff00}},
+				{
+					Match:  iptables.Match().InInterface("cali1234"),
+					Action: iptables.SetMaskedMarkAction{Mark: mark("cali1234"), Mask: 0xff00},
+				},
+				{
+					Match:  iptables.Match().InInterface("cali5678"),
+					Action: iptables.SetMaskedMarkAction{Mark: mark("cali5678"), Mask: 0xff00},
+				},
+				{
+					Match:  iptables.Match().InInterface("calidead"),
+					Action: iptables.SetMaskedMarkAction{Mark: mark("calidead"), Mask: 0xff00},
+				},
+			},
+		}}))
+	})
+
+	It("should dispatch packets from the VRF devices on their endpoint mark", func() {
+		chains := renderer.VRFWorkloadDispatchChains(epMarkMapper, wlIfaceVRFs)
+		mark, err := epMarkMapper.GetEndpointMark("cali1234")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(chains).To(HaveLen(1))
+		Expect(chains[0].Name).To(Equal("cali-from-vrf-wl-dispatch"))
+		Expect(chains[0].Rules).To(HaveLen(4))
+		Expect(chains[0].Rules[0]).To(Equal(iptables.Rule{
+			Match:  iptables.Match().InInterface("vrf-red").MarkMatchesWithMask(mark, 0xff00),
+			Action: iptables.GotoAction{Target: "cali-fw-cali1234"},
+		}))
+		Expect(chains[0].Rules[3]).To(Equal(iptables.Rule{
+			Match:   iptables.Match(),
+			Action:  iptables.DropAction{},
+			Comment: []string{"Unknown endpoint mark"},
+		}))
+	})
+
+	It("should fall back to the VRF dispatch chain from the workload dispatch chain", func() {
+		chains := renderer.WorkloadDispatchChains(map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint{
+			{WorkloadId: "wl1", EndpointId: "ep1"}: {Name: "cali1234"},
+		})
+		vrfRule := iptables.Rule{
+			Match:  iptables.Match().MarkNotClear(0xff00),
+			Action: iptables.GotoAction{Target: "cali-from-vrf-wl-dispatch"},
+		}
+		from := findChain(chains, "cali-from-wl-dispatch").Rules
+		Expect(from[len(from)-2:]).To(Equal([]iptables.Rule{vrfRule, expDropRule}))
+		to := findChain(chains, "cali-to-wl-dispatch").Rules
+		Expect(to).NotTo(ContainElement(vrfRule))
+	})
+
+	It("should render nothing when VRF support is disabled", func() {
+		renderer = NewRenderer(Config{
+			IPSetConfigV4:        ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+			IPSetConfigV6:        ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+			IptablesMarkAccept:   0x8,
+			IptablesMarkPass:     0x10,
+			IptablesMarkScratch0: 0x20,
+			IptablesMarkScratch1: 0x40,
+			IptablesMarkEndpoint: 0xff00,
+		})
+		Expect(renderer.VRFSetEndpointMarkChains(epMarkMapper, wlIfaceVRFs)).To(BeNil())
+		Expect(renderer.VRFWorkloadDispatchChains(epMarkMapper, wlIfaceVRFs)).To(BeNil())
+	})
+})
+
 func gotoRule(target string) iptables.Rule {
 	return iptables.Rule{
 		Action: iptables.GotoAction{Target: target},
@@ -15,8 +15,12 @@
 package rules
 
 import (
+	"strings"
+
 	log "github.com/sirupsen/logrus"
 
+	"github.com/projectcalico/libcalico-go/lib/backend/k8s/conversion"
+
 	"github.com/projectcalico/felix/hashutils"
 	. "github.com/projectcalico/felix/iptables"
 	"github.com/projectcalico/felix/proto"
@@ -34,9 +38,41 @@ func (r *DefaultRuleRenderer) WorkloadEndpointToIptablesChains(
 	ingressPolicies []string,
 	egressPolicies []string,
 	profileIDs []string,
+	connLimits ConnectionLimits,
 ) []*Chain {
 	allowVXLANEncapFromWorkloads := r.Config.AllowVXLANPacketsFromWorkloads
 	allowIPIPEncapFromWorkloads := r.Config.AllowIPIPPacketsFromWorkloads
+
+	// Workloads that have the same policies share "policy group" chains that hold the jumps to
+	// the policies, rather than each having its own copy of the jumps.
+	var toGroupChain, fromGroupChain *Chain
+	if adminUp {
+		toGroupChain = r.policyGroupChain(PolicyGroupInboundPfx, PolicyInboundPfx, ingressPolicies)
+		fromGroupChain = r.policyGroupChain(PolicyGroupOutboundPfx, PolicyOutboundPfx, egressPolicies)
+	}
+
+	// Chain for traffic _from_ the endpoint.
+	// Encap traffic is blocked by default from workload endpoints
+	// unless explicitly overridden.
+	fromWlChain := r.endpointIptablesChain(
+		egressPolicies,
+		profileIDs,
+		ifaceName,
+		PolicyOutboundPfx,
+		ProfileOutboundPfx,
+		WorkloadFromEndpointPfx,
+		r.clusterServicesFromWlChainName(),
+		chainTypeNormal,
+		adminUp,
+		r.filterAllowAction, // Workload endpoint chains are only used in the filter table
+		allowVXLANEncapFromWorkloads,
+		allowIPIPEncapFromWorkloads,
+		fromGroupChain,
+	)
+	if adminUp {
+		fromWlChain.Rules = r.insertConnLimitRules(fromWlChain.Rules, ifaceName, connLimits)
+	}
+
 	result := []*Chain{}
 	result = append(result,
 		// Chain for traffic _to_ the endpoint.
@@ -47,30 +83,16 @@ func (r *DefaultRuleRenderer) WorkloadEndpointToIptablesChains(
 			PolicyInboundPfx,
 			ProfileInboundPfx,
 			WorkloadToEndpointPfx,
-			"", // No fail-safe chains for workloads.
+			r.clusterServicesToWlChainName(),
 			chainTypeNormal,
 			adminUp,
 			r.filterAllowAction, // Workload endpoint chains are only used in the filter table
 			alwaysAllowVXLANEncap,
 			alwaysAllowIPIPEncap,
+			toGroupChain,
 		),
 		// Chain for traffic _from_ the endpoint.
-		// Encap traffic is blocked by default from workload endpoints
-		// unless explicitly overridden.
-		r.endpointIptablesChain(
-			egressPolicies,
-			profileIDs,
-			ifaceName,
-			PolicyOutboundPfx,
-			ProfileOutboundPfx,
-			WorkloadFromEndpointPfx,
-			"", // No fail-safe chains for workloads.
-			chainTypeNormal,
-			adminUp,
-			r.filterAllowAction, // Workload endpoint chains are only used in the filter table
-			allowVXLANEncapFromWorkloads,
-			allowIPIPEncapFromWorkloads,
-		),
+		fromWlChain,
 	)
 
 	if r.KubeIPVSSupportEnabled {
@@ -84,9 +106,72 @@ func (r *DefaultRuleRenderer) WorkloadEndpointToIptablesChains(
 		)
 	}
 
+	for _, c := range []*Chain{toGroupChain, fromGroupChain} {
+		if c != nil {
+			result = append(result, c)
+		}
+	}
+
 	return result
 }
 
+// policyGroupChain returns the chain that holds the jumps to the given list of policies, which
+// the chains of all the workloads with the same policies jump to.  Its name is derived from the
+// policy names, so that the workloads agree on it.  Returns nil if there are fewer than two
+// policies, since a group wouldn't save any rules.
+func (r *DefaultRuleRenderer) policyGroupChain(
+	groupPrefix string,
+	policyPrefix PolicyChainNamePrefix,
+	policyNames []string,
+) *Chain {
+	if len(policyNames) < 2 {
+		return nil
+	}
+	return &Chain{
+		Name:  PolicyGroupChainName(groupPrefix, policyNames),
+		Rules: r.policyJumpRules(policyNames, policyPrefix, chainTypeNormal),
+	}
+}
+
+// policyJumpRules returns the rules that jump to each policy in turn, skipping the rest once a
+// policy has set the pass mark and returning once one has set the accept mark.
+func (r *DefaultRuleRenderer) policyJumpRules(
+	policyNames []string,
+	policyPrefix PolicyChainNamePrefix,
+	chainType endpointChainType,
+) []Rule {
+	var rules []Rule
+	for _, polID := range policyNames {
+		polChainName := PolicyChainName(
+			policyPrefix,
+			&proto.PolicyID{Name: polID},
+		)
+
+		// If a previous policy didn't set the "pass" mark, jump to the policy.
+		rules = append(rules, Rule{
+			Match:  Match().MarkClear(r.IptablesMarkPass),
+			Action: JumpAction{Target: polChainName},
+		})
+		// If policy marked packet as accepted, it returns, setting the accept
+		// mark bit.
+		if chainType == chainTypeUntracked {
+			// For an untracked policy, map allow to "NOTRACK and ALLOW".
+			rules = append(rules, Rule{
+				Match:  Match().MarkSingleBitSet(r.IptablesMarkAccept),
+				Action: NoTrackAction{},
+			})
+		}
+		// If accept bit is set, return from this chain.  We don't immediately
+		// accept because there may be other policy still to apply.
+		rules = append(rules, Rule{
+			Match:   Match().MarkSingleBitSet(r.IptablesMarkAccept),
+			Action:  ReturnAction{},
+			Comment: []string{"Return if policy accepted"},
+		})
+	}
+	return rules
+}
+
 func (r *DefaultRuleRenderer) HostEndpointToFilterChains(
 	ifaceName string,
 	epMarkMapper EndpointMarkMapper,
@@ -113,6 +198,7 @@ func (r *DefaultRuleRenderer) HostEndpointToFilterChains(
 			r.filterAllowAction,
 			alwaysAllowVXLANEncap,
 			alwaysAllowIPIPEncap,
+			nil, // Host endpoints don't use policy groups.
 		),
 		// Chain for input traffic _from_ the endpoint.
 		r.endpointIptablesChain(
@@ -128,6 +214,7 @@ func (r *DefaultRuleRenderer) HostEndpointToFilterChains(
 			r.filterAllowAction,
 			alwaysAllowVXLANEncap,
 			alwaysAllowIPIPEncap,
+			nil, // Host endpoints don't use policy groups.
 		),
 		// Chain for forward traffic _to_ the endpoint.
 		r.endpointIptablesChain(
@@ -143,6 +230,7 @@ func (r *DefaultRuleRenderer) HostEndpointToFilterChains(
 			r.filterAllowAction,
 			alwaysAllowVXLANEncap,
 			alwaysAllowIPIPEncap,
+			nil, // Host endpoints don't use policy groups.
 		),
 		// Chain for forward traffic _from_ the endpoint.
 		r.endpointIptablesChain(
@@ -158,6 +246,7 @@ func (r *DefaultRuleRenderer) HostEndpointToFilterChains(
 			r.filterAllowAction,
 			alwaysAllowVXLANEncap,
 			alwaysAllowIPIPEncap,
+			nil, // Host endpoints don't use policy groups.
 		),
 	)
 
@@ -198,6 +287,7 @@ func (r *DefaultRuleRenderer) HostEndpointToMangleEgressChains(
 			ReturnAction{},
 			alwaysAllowVXLANEncap,
 			alwaysAllowIPIPEncap,
+			nil, // Host endpoints don't use policy groups.
 		),
 	}
 }
@@ -223,6 +313,7 @@ func (r *DefaultRuleRenderer) HostEndpointToRawChains(
 			AcceptAction{},
 			alwaysAllowVXLANEncap,
 			alwaysAllowIPIPEncap,
+			nil, // Host endpoints don't use policy groups.
 		),
 		// Chain for traffic _from_ the endpoint.
 		r.endpointIptablesChain(
@@ -238,6 +329,7 @@ func (r *DefaultRuleRenderer) HostEndpointToRawChains(
 			AcceptAction{},
 			alwaysAllowVXLANEncap,
 			alwaysAllowIPIPEncap,
+			nil, // Host endpoints don't use policy groups.
 		),
 	}
 }
@@ -263,6 +355,7 @@ func (r *DefaultRuleRenderer) HostEndpointToMangleIngressChains(
 			r.mangleAllowAction,
 			alwaysAllowVXLANEncap,
 			alwaysAllowIPIPEncap,
+			nil, // Host endpoints don't use policy groups.
 		),
 	}
 }
@@ -298,6 +391,30 @@ func (r *DefaultRuleRenderer) endpointSetMarkChain(
 	}
 }
 
+// nflogDir returns the direction to use in NFLOG prefixes for the end-of-chain drops of an
+// endpoint chain that uses the given policy chains.
+func nflogDir(policyPrefix PolicyChainNamePrefix) string {
+	if policyPrefix == PolicyInboundPfx {
+		return NFLOGDirInbound
+	}
+	return NFLOGDirOutbound
+}
+
+// failsafeAuditChainName returns the audit chain for an endpoint chain that uses the given
+// failsafe chain, or "" if failsafe audit mode is disabled or the chain isn't a failsafe chain.
+func (r *DefaultRuleRenderer) failsafeAuditChainName(failsafeChain string) string {
+	if !r.FailsafeAuditEnabled {
+		return ""
+	}
+	switch failsafeChain {
+	case ChainFailsafeIn:
+		return ChainFailsafeInAudit
+	case ChainFailsafeOut:
+		return ChainFailsafeOutAudit
+	}
+	return ""
+}
+
 func (r *DefaultRuleRenderer) endpointIptablesChain(
 	policyNames []string,
 	profileIds []string,
@@ -311,6 +428,7 @@ func (r *DefaultRuleRenderer) endpointIptablesChain(
 	allowAction Action,
 	allowVXLANEncap bool,
 	allowIPIPEncap bool,
+	policyGroupChain *Chain,
 ) *Chain {
 	rules := []Rule{}
 	chainName := EndpointChainName(endpointPrefix, name)
@@ -375,34 +493,18 @@ func (r *DefaultRuleRenderer) endpointIptablesChain(
 			},
 		})
 
-		// Then, jump to each policy in turn.
-		for _, polID := range policyNames {
-			polChainName := PolicyChainName(
-				policyPrefix,
-				&proto.PolicyID{Name: polID},
-			)
-
-			// If a previous policy didn't set the "pass" mark, jump to the policy.
-			rules = append(rules, Rule{
-				Match:  Match().MarkClear(r.IptablesMarkPass),
-				Action: JumpAction{Target: polChainName},
-			})
-			// If policy marked packet as accepted, it returns, setting the accept
-			// mark bit.
-			if chainType == chainTypeUntracked {
-				// For an untracked policy, map allow to "NOTRACK and ALLOW".
-				rules = append(rules, Rule{
-					Match:  Match().MarkSingleBitSet(r.IptablesMarkAccept),
-					Action: NoTrackAction{},
+		if policyGroupChain != nil {
+			// Jump to the policy group, which jumps to each policy in turn.
+			rules = append(rules,
+				Rule{Action: JumpAction{Target: policyGroupChain.Name}},
+				Rule{
+					Match:   Match().MarkSingleBitSet(r.IptablesMarkAccept),
+					Action:  ReturnAction{},
+					Comment: []string{"Return if policy group accepted"},
 				})
-			}
-			// If accept bit is set, return from this chain.  We don't immediately
-			// accept because there may be other policy still to apply.
-			rules = append(rules, Rule{
-				Match:   Match().MarkSingleBitSet(r.IptablesMarkAccept),
-				Action:  ReturnAction{},
-				Comment: []string{"Return if policy accepted"},
-			})
+		} else {
+			// Then, jump to each policy in turn.
+			rules = append(rules, r.policyJumpRules(policyNames, policyPrefix, chainType)...)
 		}
 
 		if chainType == chainTypeNormal || chainType == chainTypeForward {
@@ -411,6 +513,15 @@ func (r *DefaultRuleRenderer) endpointIptablesChain(
 			//
 			// For untracked and pre-DNAT rules, we don't do that because there may be
 			// normal rules still to be applied to the packet in the filter table.
+			if auditChain := r.failsafeAuditChainName(failsafeChain); auditChain != "" {
+				rules = append(rules, Rule{
+					Match:  Match().MarkClear(r.IptablesMarkPass).MarkSingleBitSet(r.FailsafeAuditIptablesMark),
+					Action: JumpAction{Target: auditChain},
+				})
+			}
+			if r.FlowLogsEnabled {
+				rules = append(rules, r.denyLogRule(Match().MarkClear(r.IptablesMarkPass), nflogDir(policyPrefix), NFLOGNoRuleIndex, NFLOGNoPolicyMatched))
+			}
 			rules = append(rules, Rule{
 				Match:   Match().MarkClear(r.IptablesMarkPass),
 				Action:  DropAction{},
@@ -432,6 +543,25 @@ func (r *DefaultRuleRenderer) endpointIptablesChain(
 	}
 
 	if chainType == chainTypeNormal {
+		if r.KubernetesProfilelessMode && kubernetesNamespaceDefaultAllow(profileIds) {
+			// The Kubernetes namespace profiles allow all traffic and the service account
+			// profiles have no rules so, rather than jumping to their chains, apply the
+			// namespace's default allow here.
+			rules = append(rules,
+				Rule{
+					Action:  SetMarkAction{Mark: r.IptablesMarkAccept},
+					Comment: []string{"Allow by Kubernetes namespace default"},
+				},
+				Rule{
+					Action:  ReturnAction{},
+					Comment: []string{"Return for namespace default allow"},
+				})
+			return &Chain{
+				Name:  chainName,
+				Rules: rules,
+			}
+		}
+
 		// Then, jump to each profile in turn.
 		for _, profileID := range profileIds {
 			profChainName := ProfileChainName(profilePrefix, &proto.ProfileID{Name: profileID})
@@ -452,6 +582,15 @@ func (r *DefaultRuleRenderer) endpointIptablesChain(
 		// For untracked rules, we don't do that because there may be tracked rules
 		// still to be applied to the packet in the filter table.
 		//if dropIfNoProfilesMatched {
+		if auditChain := r.failsafeAuditChainName(failsafeChain); auditChain != "" {
+			rules = append(rules, Rule{
+				Match:  Match().MarkSingleBitSet(r.FailsafeAuditIptablesMark),
+				Action: JumpAction{Target: auditChain},
+			})
+		}
+		if r.FlowLogsEnabled {
+			rules = append(rules, r.denyLogRule(Match(), nflogDir(policyPrefix), NFLOGNoRuleIndex, NFLOGNoProfileMatched))
+		}
 		rules = append(rules, Rule{
 			Match:   Match(),
 			Action:  DropAction{},
@@ -466,6 +605,22 @@ func (r *DefaultRuleRenderer) endpointIptablesChain(
 	}
 }
 
+// kubernetesNamespaceDefaultAllow returns true if the profiles are only the ones that Calico
+// generates for a Kubernetes namespace and service account, and include a namespace profile.
+func kubernetesNamespaceDefaultAllow(profileIDs []string) bool {
+	haveNamespace := false
+	for _, id := range profileIDs {
+		switch {
+		case strings.HasPrefix(id, conversion.NamespaceProfileNamePrefix):
+			haveNamespace = true
+		case strings.HasPrefix(id, conversion.ServiceAccountProfileNamePrefix):
+		default:
+			return false
+		}
+	}
+	return haveNamespace
+}
+
 func (r *DefaultRuleRenderer) appendConntrackRules(rules []Rule, allowAction Action) []Rule {
 	// Allow return packets for established connections.
 	if allowAction != (AcceptAction{}) {
@@ -487,14 +642,62 @@ func (r *DefaultRuleRenderer) appendConntrackRules(rules []Rule, allowAction Act
 	if !r.Config.DisableConntrackInvalid {
 		// Drop packets that aren't either a valid handshake or part of an established
 		// connection.
-		rules = append(rules, Rule{
-			Match:  Match().ConntrackState("INVALID"),
-			Action: DropAction{},
-		})
+		if r.SCTPMultihomingEnabled {
+			// The first packets on a secondary path of a multihomed association are
+			// HEARTBEATs and their ACKs, which older kernels consider INVALID.  Let policy
+			// decide on those but still drop any other INVALID SCTP packets.
+			rules = append(rules,
+				Rule{
+					Match:  Match().ConntrackState("INVALID").NotProtocol("sctp"),
+					Action: DropAction{},
+				},
+				Rule{
+					Match: Match().ConntrackState("INVALID").Protocol("sctp").
+						NotSCTPChunkTypesOnly("HEARTBEAT,HEARTBEAT_ACK"),
+					Action: DropAction{},
+				},
+			)
+		} else {
+			rules = append(rules, Rule{
+				Match:  Match().ConntrackState("INVALID"),
+				Action: DropAction{},
+			})
+		}
 	}
 	return rules
 }
 
+// insertConnLimitRules inserts the rules that enforce the workload's connection limits into the
+// rules of its from-workload chain, straight after the conntrack rules so that they only see new
+// connections.
+func (r *DefaultRuleRenderer) insertConnLimitRules(rules []Rule, ifaceName string, connLimits ConnectionLimits) []Rule {
+	var limitRules []Rule
+	if connLimits.MaxConnections > 0 {
+		limitRules = append(limitRules, Rule{
+			Match:   Match().ConntrackState("NEW").ConnLimitAbove(connLimits.MaxConnections),
+			Action:  DropAction{},
+			Comment: []string{"Drop connections over the workload's connection limit"},
+		})
+	}
+	if connLimits.NewConnectionsPerSecond > 0 {
+		// Interface names are unique and short enough to name the rule's hashlimit bucket.
+		limitRules = append(limitRules, Rule{
+			Match: Match().ConntrackState("NEW").HashLimitAbove(
+				ifaceName, connLimits.NewConnectionsPerSecond, connLimits.NewConnectionsPerSecond),
+			Action:  DropAction{},
+			Comment: []string{"Drop connections over the workload's connection rate limit"},
+		})
+	}
+	if len(limitRules) == 0 {
+		return rules
+	}
+	numConntrackRules := len(r.appendConntrackRules(nil, r.filterAllowAction))
+	result := make([]Rule, 0, len(rules)+len(limitRules))
+	result = append(result, rules[:numConntrackRules]...)
+	result = append(result, limitRules...)
+	return append(result, rules[numConntrackRules:]...)
+}
+
 func EndpointChainName(prefix string, ifaceName string) string {
 	return hashutils.GetLengthLimitedID(
 		prefix,
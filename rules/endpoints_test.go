@@ -99,7 +99,8 @@ var _ = Describe("Endpoints", func() {
 					true,
 					nil,
 					nil,
-					nil)).To(Equal(trimSMChain(kubeIPVSEnabled, []*Chain{
+					nil,
+					ConnectionLimits{})).To(Equal(trimSMChain(kubeIPVSEnabled, []*Chain{
 					{
 						Name: "cali-tw-cali1234",
 						Rules: []Rule{
@@ -146,6 +147,7 @@ var _ = Describe("Endpoints", func() {
 					nil,
 					nil,
 					nil,
+					ConnectionLimits{},
 				)).To(Equal(trimSMChain(kubeIPVSEnabled, []*Chain{
 					{
 						Name: "cali-tw-cali1234",
@@ -170,7 +172,46 @@ var _ = Describe("Endpoints", func() {
 				})))
 			})
 
+			It("should render a workload endpoint with connection limits", func() {
+				chains := renderer.WorkloadEndpointToIptablesChains(
+					"cali1234",
+					epMarkMapper,
+					true,
+					nil,
+					nil,
+					nil,
+					ConnectionLimits{MaxConnections: 1000, NewConnectionsPerSecond: 50},
+				)
+				Expect(chains[1]).To(Equal(&Chain{
+					Name: "cali-fw-cali1234",
+					Rules: []Rule{
+						// conntrack rules.
+						{Match: Match().ConntrackState("RELATED,ESTABLISHED"),
+							Action: AcceptAction{}},
+						{Match: Match().ConntrackState("INVALID"),
+							Action: DropAction{}},
+
+						{Match: Match().ConntrackState("NEW").ConnLimitAbove(1000),
+							Action:  DropAction{},
+							Comment: []string{"Drop connections over the workload's connection limit"}},
+						{Match: Match().ConntrackState("NEW").HashLimitAbove("cali1234", 50, 50),
+							Action:  DropAction{},
+							Comment: []string{"Drop connections over the workload's connection rate limit"}},
+
+						{Action: ClearMarkAction{Mark: 0x8}},
+						dropVXLANRule,
+						dropIPIPRule,
+						{Action: DropAction{},
+							Comment: []string{"Drop if no profiles matched"}},
+					},
+				}))
+			})
+
 			It("should render a fully-loaded workload endpoint", func() {
+				inGroup := PolicyGroupChainName(PolicyGroupInboundPfx, []string{"ai", "bi"})
+				outGroup := PolicyGroupChainName(PolicyGroupOutboundPfx, []string{"ae", "be"})
+				Expect(inGroup).To(HavePrefix("cali-gi-"))
+				Expect(inGroup).To(HaveLen(MaxChainNameLength))
 				Expect(renderer.WorkloadEndpointToIptablesChains(
 					"cali1234",
 					epMarkMapper,
@@ -178,6 +219,7 @@ var _ = Describe("Endpoints", func() {
 					[]string{"ai", "bi"},
 					[]string{"ae", "be"},
 					[]string{"prof1", "prof2"},
+					ConnectionLimits{},
 				)).To(Equal(trimSMChain(kubeIPVSEnabled, []*Chain{
 					{
 						Name: "cali-tw-cali1234",
@@ -192,16 +234,10 @@ var _ = Describe("Endpoints", func() {
 
 							{Comment: []string{"Start of policies"},
 								Action: ClearMarkAction{Mark: 0x10}},
-							{Match: Match().MarkClear(0x10),
-								Action: JumpAction{Target: "cali-pi-ai"}},
+							{Action: JumpAction{Target: inGroup}},
 							{Match: Match().MarkSingleBitSet(0x8),
 								Action:  ReturnAction{},
-								Comment: []string{"Return if policy accepted"}},
-							{Match: Match().MarkClear(0x10),
-								Action: JumpAction{Target: "cali-pi-bi"}},
-							{Match: Match().MarkSingleBitSet(0x8),
-								Action:  ReturnAction{},
-								Comment: []string{"Return if policy accepted"}},
+								Comment: []string{"Return if policy group accepted"}},
 							{Match: Match().MarkClear(0x10),
 								Action:  DropAction{},
 								Comment: []string{"Drop if no policies passed packet"}},
@@ -234,16 +270,10 @@ var _ = Describe("Endpoints", func() {
 
 							{Comment: []string{"Start of policies"},
 								Action: ClearMarkAction{Mark: 0x10}},
-							{Match: Match().MarkClear(0x10),
-								Action: JumpAction{Target: "cali-po-ae"}},
-							{Match: Match().MarkSingleBitSet(0x8),
-								Action:  ReturnAction{},
-								Comment: []string{"Return if policy accepted"}},
-							{Match: Match().MarkClear(0x10),
-								Action: JumpAction{Target: "cali-po-be"}},
+							{Action: JumpAction{Target: outGroup}},
 							{Match: Match().MarkSingleBitSet(0x8),
 								Action:  ReturnAction{},
-								Comment: []string{"Return if policy accepted"}},
+								Comment: []string{"Return if policy group accepted"}},
 							{Match: Match().MarkClear(0x10),
 								Action:  DropAction{},
 								Comment: []string{"Drop if no policies passed packet"}},
@@ -267,9 +297,57 @@ var _ = Describe("Endpoints", func() {
 							{Action: SetMaskedMarkAction{Mark: 0xd400, Mask: 0xff00}},
 						},
 					},
+					{
+						Name: inGroup,
+						Rules: []Rule{
+							{Match: Match().MarkClear(0x10),
+								Action: JumpAction{Target: "cali-pi-ai"}},
+							{Match: Match().MarkSingleBitSet(0x8),
+								Action:  ReturnAction{},
+								Comment: []string{"Return if policy accepted"}},
+							{Match: Match().MarkClear(0x10),
+								Action: JumpAction{Target: "cali-pi-bi"}},
+							{Match: Match().MarkSingleBitSet(0x8),
+								Action:  ReturnAction{},
+								Comment: []string{"Return if policy accepted"}},
+						},
+					},
+					{
+						Name: outGroup,
+						Rules: []Rule{
+							{Match: Match().MarkClear(0x10),
+								Action: JumpAction{Target: "cali-po-ae"}},
+							{Match: Match().MarkSingleBitSet(0x8),
+								Action:  ReturnAction{},
+								Comment: []string{"Return if policy accepted"}},
+							{Match: Match().MarkClear(0x10),
+								Action: JumpAction{Target: "cali-po-be"}},
+							{Match: Match().MarkSingleBitSet(0x8),
+								Action:  ReturnAction{},
+								Comment: []string{"Return if policy accepted"}},
+						},
+					},
 				})))
 			})
 
+			It("should share policy group chains between workloads with the same policies", func() {
+				chains := func(iface string, policies ...string) []*Chain {
+					return renderer.WorkloadEndpointToIptablesChains(
+						iface, epMarkMapper, true, policies, nil, nil, ConnectionLimits{})
+				}
+				groupName := func(chains []*Chain) string {
+					for _, c := range chains {
+						if strings.HasPrefix(c.Name, PolicyGroupInboundPfx) {
+							return c.Name
+						}
+					}
+					return ""
+				}
+				Expect(groupName(chains("cali1", "a", "b"))).To(Equal(groupName(chains("cali2", "a", "b"))))
+				Expect(groupName(chains("cali1", "a", "b"))).NotTo(Equal(groupName(chains("cali1", "b", "a"))))
+				Expect(groupName(chains("cali1", "a"))).To(BeEmpty())
+			})
+
 			It("should render a host endpoint", func() {
 				Expect(renderer.HostEndpointToFilterChains("eth0",
 					epMarkMapper,
@@ -527,6 +605,7 @@ var _ = Describe("Endpoints", func() {
 					nil,
 					nil,
 					nil,
+					ConnectionLimits{},
 				)).To(Equal(trimSMChain(kubeIPVSEnabled, []*Chain{
 					{
 						Name: "cali-tw-cali1234",
@@ -613,6 +692,7 @@ var _ = Describe("Endpoints", func() {
 						nil,
 						nil,
 						nil,
+						ConnectionLimits{},
 					)).To(Equal(trimSMChain(kubeIPVSEnabled, []*Chain{
 						{
 							Name: "cali-tw-cali1234",
@@ -664,6 +744,7 @@ var _ = Describe("Endpoints", func() {
 						nil,
 						nil,
 						nil,
+						ConnectionLimits{},
 					)).To(Equal(trimSMChain(kubeIPVSEnabled, []*Chain{
 						{
 							Name: "cali-tw-cali1234",
@@ -716,6 +797,7 @@ var _ = Describe("Endpoints", func() {
 						nil,
 						nil,
 						nil,
+						ConnectionLimits{},
 					)).To(Equal(trimSMChain(kubeIPVSEnabled, []*Chain{
 						{
 							Name: "cali-tw-cali1234",
@@ -759,6 +841,93 @@ var _ = Describe("Endpoints", func() {
 				rrConfigNormalMangleReturn.AllowVXLANPacketsFromWorkloads = false
 			})
 		})
+
+		Describe("with Kubernetes profile-less mode", func() {
+			BeforeEach(func() {
+				config := rrConfigNormalMangleReturn
+				config.KubernetesProfilelessMode = true
+				config.AllowIPIPPacketsFromWorkloads = true
+				config.AllowVXLANPacketsFromWorkloads = true
+				renderer = NewRenderer(config)
+				epMarkMapper = NewEndpointMarkMapper(config.IptablesMarkEndpoint, config.IptablesMarkNonCaliEndpoint)
+			})
+
+			toWlChain := func(profileIDs ...string) *Chain {
+				return renderer.WorkloadEndpointToIptablesChains(
+					"cali1234", epMarkMapper,
+					true,
+					nil,
+					nil,
+					profileIDs,
+					ConnectionLimits{},
+				)[0]
+			}
+			conntrackRules := []Rule{
+				{Match: Match().ConntrackState("RELATED,ESTABLISHED"),
+					Action: AcceptAction{}},
+				{Match: Match().ConntrackState("INVALID"),
+					Action: DropAction{}},
+				{Action: ClearMarkAction{Mark: 0x8}},
+			}
+
+			It("should render the namespace default allow instead of jumping to the profiles", func() {
+				Expect(toWlChain("kns.default", "ksa.default.default")).To(Equal(&Chain{
+					Name: "cali-tw-cali1234",
+					Rules: append(conntrackRules,
+						Rule{Action: SetMarkAction{Mark: 0x8},
+							Comment: []string{"Allow by Kubernetes namespace default"}},
+						Rule{Action: ReturnAction{},
+							Comment: []string{"Return for namespace default allow"}},
+					),
+				}))
+			})
+
+			It("should still jump to other profiles", func() {
+				Expect(toWlChain("kns.default", "prof1").Rules).To(ContainElement(
+					Rule{Action: JumpAction{Target: "cali-pri-prof1"}}))
+				Expect(toWlChain("kns.default", "prof1").Rules).To(ContainElement(
+					Rule{Action: JumpAction{Target: "cali-pri-kns.default"}}))
+			})
+
+			It("should drop if there is no namespace profile", func() {
+				Expect(toWlChain().Rules).To(Equal(append(conntrackRules,
+					Rule{Match: Match(), Action: DropAction{},
+						Comment: []string{"Drop if no profiles matched"}},
+				)))
+			})
+		})
+
+		Describe("with SCTP multihoming enabled", func() {
+			BeforeEach(func() {
+				config := rrConfigNormalMangleReturn
+				config.SCTPMultihomingEnabled = true
+				renderer = NewRenderer(config)
+				epMarkMapper = NewEndpointMarkMapper(config.IptablesMarkEndpoint, config.IptablesMarkNonCaliEndpoint)
+			})
+
+			It("should leave INVALID SCTP heartbeats to policy", func() {
+				for _, chain := range renderer.WorkloadEndpointToIptablesChains(
+					"cali1234", epMarkMapper,
+					true,
+					nil,
+					nil,
+					nil,
+					ConnectionLimits{},
+				)[:2] {
+					Expect(chain.Rules[1:3]).To(Equal([]Rule{
+						{
+							Match:  Match().ConntrackState("INVALID").NotProtocol("sctp"),
+							Action: DropAction{},
+						},
+						{
+							Match: Match().ConntrackState("INVALID").Protocol("sctp").
+								NotSCTPChunkTypesOnly("HEARTBEAT,HEARTBEAT_ACK"),
+							Action: DropAction{},
+						},
+					}), chain.Name)
+				}
+			})
+		})
 	}
 })
 
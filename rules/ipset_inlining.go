@@ -0,0 +1,224 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rules
+
+import (
+	"regexp"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/iptables"
+)
+
+// ipSetMatchRegexp matches the IP set matches that MatchCriteria's SourceIPSet, NotSourceIPSet,
+// DestIPSet and NotDestIPSet render.
+var ipSetMatchRegexp = regexp.MustCompile(`^-m set (! )?--match-set (\S+) (src|dst)$`)
+
+// IPSetMembersFunc returns the members of the IP set with the given name, or false if they
+// can't be inlined.
+type IPSetMembersFunc func(setName string) ([]string, bool)
+
+// InlineIPSetMatches returns the rules with their IP set matches replaced by matches on the IP
+// sets' members, for use when iptables can't match on IP sets.  It also returns the names of the
+// IP sets that couldn't be inlined.
+//
+// A positive match is replaced by a copy of the rule for each member.  Members that are inside
+// other members are dropped first so that a packet matches at most one copy.  A negated match is
+// rendered as a block of rules that clears a scratch mark bit if the packet matches any member,
+// as for negated CIDR matches in policy, so rules with negated IP set matches mustn't be used
+// where the scratch bits are in use.
+//
+// As for policy, a rule that uses an IP set that can't be inlined fails closed: a drop rule is
+// rendered without that match, so that it matches more traffic, and other rules are left out.
+func (r *DefaultRuleRenderer) InlineIPSetMatches(
+	rules []iptables.Rule,
+	ipVersion uint8,
+	members IPSetMembersFunc,
+) ([]iptables.Rule, []string) {
+	var out []iptables.Rule
+	leftOver := map[string]bool{}
+	for _, rule := range rules {
+		out = append(out, r.inlineIPSetMatchesInRule(rule, ipVersion, members, leftOver)...)
+	}
+	var leftOverNames []string
+	for name := range leftOver {
+		leftOverNames = append(leftOverNames, name)
+	}
+	sort.Strings(leftOverNames)
+	return out, leftOverNames
+}
+
+func (r *DefaultRuleRenderer) inlineIPSetMatchesInRule(
+	rule iptables.Rule,
+	ipVersion uint8,
+	members IPSetMembersFunc,
+	leftOver map[string]bool,
+) []iptables.Rule {
+	var otherMatches iptables.MatchCriteria
+	var positive, negated [2][]ip.CIDR
+	var constrained [2]bool
+	usesIPSets := false
+	failed := false
+	for _, m := range rule.Match {
+		parts := ipSetMatchRegexp.FindStringSubmatch(m)
+		if parts == nil {
+			otherMatches = append(otherMatches, m)
+			continue
+		}
+		usesIPSets = true
+		setName := parts[2]
+		sod := src
+		if parts[3] == "dst" {
+			sod = dst
+		}
+		setMembers, ok := members(setName)
+		if !ok {
+			leftOver[setName] = true
+			failed = true
+			continue
+		}
+		cidrs := parseCIDRsOfVersion(setMembers, ipVersion)
+		if parts[1] != "" {
+			negated[sod] = append(negated[sod], cidrs...)
+		} else if !constrained[sod] {
+			positive[sod] = cidrs
+			constrained[sod] = true
+		} else {
+			positive[sod] = ip.IntersectCIDRs(positive[sod], cidrs)
+		}
+	}
+	if !usesIPSets {
+		return []iptables.Rule{rule}
+	}
+	if failed {
+		if _, ok := rule.Action.(iptables.DropAction); !ok {
+			log.WithField("rule", rule).Debug("Leaving out rule that uses an IP set that can't be inlined.")
+			return nil
+		}
+	}
+
+	// Each positive match becomes a list of alternatives; no alternatives means that the rule
+	// can never match.
+	var alternatives [2][]string
+	for _, sod := range []srcOrDst{src, dst} {
+		if !constrained[sod] {
+			alternatives[sod] = []string{""}
+			continue
+		}
+		alternatives[sod] = cidrStrings(outermostCIDRs(positive[sod]))
+		if len(alternatives[sod]) == 0 {
+			return nil
+		}
+	}
+
+	// iptables only allows one source and one destination match per rule so negated matches go
+	// in the rule itself only if there's nothing else in that direction.
+	matchBlockBuilder := matchBlockBuilder{
+		markAllBlocksPass: r.IptablesMarkScratch0,
+		markThisBlockPass: r.IptablesMarkScratch1,
+	}
+	var inlineNegations iptables.MatchCriteria
+	for _, sod := range []srcOrDst{src, dst} {
+		nets := cidrStrings(negated[sod])
+		if len(nets) == 0 {
+			continue
+		}
+		if len(nets) == 1 && !constrained[sod] {
+			inlineNegations = append(inlineNegations, negate(sod.MatchNet(nets[0]))...)
+			continue
+		}
+		matchBlockBuilder.AppendNegatedCIDRMatchBlock(nets, sod)
+	}
+
+	out := matchBlockBuilder.Rules
+	for _, srcNet := range alternatives[src] {
+		for _, dstNet := range alternatives[dst] {
+			match := append(iptables.MatchCriteria(nil), otherMatches...)
+			match = append(match, inlineNegations...)
+			if matchBlockBuilder.UsingMatchBlocks {
+				match = match.MarkSingleBitSet(matchBlockBuilder.markAllBlocksPass)
+			}
+			if srcNet != "" {
+				match = match.SourceNet(srcNet)
+			}
+			if dstNet != "" {
+				match = match.DestNet(dstNet)
+			}
+			out = append(out, iptables.Rule{
+				Match:   match,
+				Action:  rule.Action,
+				Comment: rule.Comment,
+			})
+		}
+	}
+	return out
+}
+
+// negate returns the negated form of a single source or destination net match.
+func negate(m iptables.MatchCriteria) iptables.MatchCriteria {
+	return iptables.MatchCriteria{"! " + m[0]}
+}
+
+func parseCIDRsOfVersion(nets []string, ipVersion uint8) []ip.CIDR {
+	var cidrs []ip.CIDR
+	for _, n := range nets {
+		cidr, err := ip.ParseCIDROrIP(n)
+		if err != nil || cidr.Version() != ipVersion {
+			continue
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs
+}
+
+// outermostCIDRs returns the CIDRs that aren't inside any of the others.
+func outermostCIDRs(cidrs []ip.CIDR) []ip.CIDR {
+	var out []ip.CIDR
+	for i, x := range cidrs {
+		inside := false
+		for j, y := range cidrs {
+			if i == j {
+				continue
+			}
+			if ip.CIDRContains(y, x) && (x.Prefix() != y.Prefix() || j < i) {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+// cidrStrings returns the sorted, de-duplicated string forms of the CIDRs so that the rendered
+// rules, and hence their hashes, are stable.
+func cidrStrings(cidrs []ip.CIDR) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, cidr := range cidrs {
+		s := cidr.String()
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rules_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/ipsets"
+	. "github.com/projectcalico/felix/iptables"
+	. "github.com/projectcalico/felix/rules"
+)
+
+var _ = Describe("IP set inlining", func() {
+	var renderer RuleRenderer
+	members := map[string][]string{
+		"cali40masq-ipam-pools": {"10.65.0.0/16"},
+		"cali40all-ipam-pools":  {"10.65.0.0/16", "10.66.0.0/16", "fd00::/64"},
+		"cali40this-host":       {"10.0.0.1"},
+		"cali40nested":          {"10.1.0.0/16", "192.168.0.1", "10.0.0.0/8"},
+		"cali40empty":           {},
+	}
+	membersFunc := func(setName string) ([]string, bool) {
+		m, ok := members[setName]
+		return m, ok
+	}
+
+	BeforeEach(func() {
+		renderer = NewRenderer(Config{
+			IPSetConfigV4:        ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+			IPSetConfigV6:        ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+			IptablesMarkAccept:   0x8,
+			IptablesMarkPass:     0x10,
+			IptablesMarkScratch0: 0x20,
+			IptablesMarkScratch1: 0x40,
+			IptablesMarkEndpoint: 0xff00,
+		})
+	})
+
+	It("should pass through rules without IP set matches", func() {
+		rules := []Rule{{Match: Match().Protocol("tcp"), Action: AcceptAction{}}}
+		out, leftOver := renderer.InlineIPSetMatches(rules, 4, membersFunc)
+		Expect(out).To(Equal(rules))
+		Expect(leftOver).To(BeEmpty())
+	})
+
+	It("should render the NAT outgoing rule with a block for the negated set", func() {
+		out, leftOver := renderer.InlineIPSetMatches(renderer.NATOutgoingChain(true, 4).Rules, 4, membersFunc)
+		Expect(out).To(Equal([]Rule{
+			{Action: SetMaskedMarkAction{Mark: 0x20, Mask: 0x60}},
+			{Match: Match().DestNet("10.65.0.0/16"), Action: ClearMarkAction{Mark: 0x20}},
+			{Match: Match().DestNet("10.66.0.0/16"), Action: ClearMarkAction{Mark: 0x20}},
+			{
+				Match:  Match().MarkSingleBitSet(0x20).SourceNet("10.65.0.0/16"),
+				Action: MasqAction{},
+			},
+		}))
+		Expect(leftOver).To(BeEmpty())
+	})
+
+	It("should render a single negated member in the rule", func() {
+		out, _ := renderer.InlineIPSetMatches([]Rule{{
+			Match:   Match().NotDestIPSet("cali40this-host"),
+			Action:  JumpAction{Target: "cali-foo"},
+			Comment: []string{"To kubernetes service"},
+		}}, 4, membersFunc)
+		Expect(out).To(Equal([]Rule{{
+			Match:   Match().NotDestNet("10.0.0.1/32"),
+			Action:  JumpAction{Target: "cali-foo"},
+			Comment: []string{"To kubernetes service"},
+		}}))
+	})
+
+	It("should render a copy of the rule for each outermost member of a positive set", func() {
+		out, _ := renderer.InlineIPSetMatches([]Rule{{
+			Match:  Match().SourceIPSet("cali40nested").Protocol("tcp"),
+			Action: CTHelperAction{Helper: "ftp"},
+		}}, 4, membersFunc)
+		Expect(out).To(Equal([]Rule{
+			{Match: Match().Protocol("tcp").SourceNet("10.0.0.0/8"), Action: CTHelperAction{Helper: "ftp"}},
+			{Match: Match().Protocol("tcp").SourceNet("192.168.0.1/32"), Action: CTHelperAction{Helper: "ftp"}},
+		}))
+	})
+
+	It("should drop rules whose positive set is empty", func() {
+		out, _ := renderer.InlineIPSetMatches([]Rule{{
+			Match:  Match().DestIPSet("cali40empty"),
+			Action: AcceptAction{},
+		}}, 4, membersFunc)
+		Expect(out).To(BeEmpty())
+	})
+
+	It("should fail closed for sets that can't be inlined", func() {
+		out, leftOver := renderer.InlineIPSetMatches([]Rule{
+			{Match: Match().ProtocolNum(4).SourceIPSet("cali40all-hosts-net"), Action: AcceptAction{}},
+			{Match: Match().ProtocolNum(4).NotSourceIPSet("cali40all-hosts-net"), Action: DropAction{}},
+		}, 4, membersFunc)
+		Expect(out).To(Equal([]Rule{
+			{Match: Match().ProtocolNum(4), Action: DropAction{}},
+		}))
+		Expect(leftOver).To(Equal([]string{"cali40all-hosts-net"}))
+	})
+})
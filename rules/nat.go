@@ -19,6 +19,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/projectcalico/api/pkg/lib/numorstring"
 	"github.com/projectcalico/felix/bpf/tc"
 	"github.com/projectcalico/felix/iptables"
 )
@@ -58,6 +59,10 @@ func (r *DefaultRuleRenderer) makeNATOutgoingRuleIPTables(ipVersion uint8, proto
 		SourceIPSet(masqIPsSetName).
 		NotDestIPSet(allIPsSetName)
 
+	if r.Config.NATOutgoingExclusionsEnabled {
+		match = match.NotDestIPSet(ipConf.NameForMainIPSet(IPSetIDNATOutgoingExclusions))
+	}
+
 	if protocol != "" {
 		match = match.Protocol(protocol)
 	}
@@ -73,32 +78,126 @@ func (r *DefaultRuleRenderer) makeNATOutgoingRuleIPTables(ipVersion uint8, proto
 	return rule
 }
 
+// NATOutgoingSourcePoolIPSetID returns the ID of the IP set that holds the workloads selected by
+// the NATOutgoingSourcePools entry with the given index.
+func NATOutgoingSourcePoolIPSetID(index int) string {
+	return fmt.Sprintf("%s%d", IPSetIDNATOutgoingSourcePoolPrefix, index)
+}
+
+// EgressGatewayClientIPSetID returns the ID of the IP set that holds the workloads selected by the
+// EgressGatewaySteering rule with the given index.
+func EgressGatewayClientIPSetID(index int) string {
+	return fmt.Sprintf("%s%d", IPSetIDEgressGatewayClientPrefix, index)
+}
+
+// EgressGatewayIPSetID returns the ID of the IP set that holds the gateways selected by the
+// EgressGatewaySteering rule with the given index.
+func EgressGatewayIPSetID(index int) string {
+	return fmt.Sprintf("%s%d", IPSetIDEgressGatewayPrefix, index)
+}
+
+// egressGatewayNATOutgoingRules returns rules that skip NAT outgoing for the traffic that egress
+// gateway steering sends over IPIP.
+func (r *DefaultRuleRenderer) egressGatewayNATOutgoingRules(ipVersion uint8) []iptables.Rule {
+	if ipVersion != 4 {
+		return nil
+	}
+	ipConf := r.ipSetConfig(ipVersion)
+	var rules []iptables.Rule
+	for i := range r.Config.EgressGatewaySteering {
+		rules = append(rules, iptables.Rule{
+			Match: iptables.Match().
+				SourceIPSet(ipConf.NameForMainIPSet(EgressGatewayClientIPSetID(i))).
+				OutInterface("tunl0"),
+			Action: iptables.ReturnAction{},
+		})
+	}
+	return rules
+}
+
+// natOutgoingSourcePoolRules returns the rules that SNAT the workloads in each of the configured
+// source pools to that pool's addresses.  Like the default rules, they limit TCP and UDP to
+// NATPortRange (and so to this node's NATPortRangePartition), apart from the pools that are CIDRs:
+// NETMAP can't remap ports.
+func (r *DefaultRuleRenderer) natOutgoingSourcePoolRules(ipVersion uint8) []iptables.Rule {
+	ipConf := r.ipSetConfig(ipVersion)
+	var rules []iptables.Rule
+	for i, pool := range r.Config.NATOutgoingSourcePools {
+		if pool.IPVersion() != ipVersion {
+			continue
+		}
+		poolRule := func(protocol string, action iptables.Action) iptables.Rule {
+			rule := r.makeNATOutgoingRuleIPTables(ipVersion, protocol, action)
+			rule.Match = rule.Match.SourceIPSet(ipConf.NameForMainIPSet(NATOutgoingSourcePoolIPSetID(i)))
+			return rule
+		}
+		if pool.IsCIDR() {
+			rules = append(rules, poolRule("", iptables.NetmapAction{ToNet: pool.ToSource}))
+			continue
+		}
+		if r.Config.NATPortRange.MaxPort > 0 {
+			portRangeAction := iptables.SNATAction{
+				ToAddr:             snatToSourceWithPorts(pool.ToSource, r.Config.NATPortRange, ipVersion),
+				DisableRandomFully: r.Config.NATOutgoingPreservePorts,
+			}
+			rules = append(rules, poolRule("tcp", portRangeAction), poolRule("udp", portRangeAction))
+		}
+		rules = append(rules, poolRule("", iptables.SNATAction{
+			ToAddr:             pool.ToSource,
+			DisableRandomFully: r.Config.NATOutgoingPreservePorts,
+		}))
+	}
+	return rules
+}
+
+// snatToSourceWithPorts adds the port range to an SNAT --to-source address or "<ip>-<ip>" range.
+// IPv6 addresses need brackets to separate them from the ports.
+func snatToSourceWithPorts(toSource string, ports numorstring.Port, ipVersion uint8) string {
+	if ipVersion == 6 {
+		addrs := strings.Split(toSource, "-")
+		for i := range addrs {
+			addrs[i] = "[" + addrs[i] + "]"
+		}
+		toSource = strings.Join(addrs, "-")
+	}
+	return fmt.Sprintf("%s:%d-%d", toSource, ports.MinPort, ports.MaxPort)
+}
+
 func (r *DefaultRuleRenderer) NATOutgoingChain(natOutgoingActive bool, ipVersion uint8) *iptables.Chain {
 	var rules []iptables.Rule
 	if natOutgoingActive {
-		var defaultSnatRule iptables.Action = iptables.MasqAction{}
+		// Steered egress gateway traffic is left alone.  Workloads in a source pool are SNATed
+		// by the first matching pool rule and skip the default rules below.
+		rules = append(r.egressGatewayNATOutgoingRules(ipVersion), r.natOutgoingSourcePoolRules(ipVersion)...)
+
+		preservePorts := r.Config.NATOutgoingPreservePorts
+		var defaultSnatRule iptables.Action = iptables.MasqAction{DisableRandomFully: preservePorts}
 		if r.Config.NATOutgoingAddress != nil {
-			defaultSnatRule = iptables.SNATAction{ToAddr: r.Config.NATOutgoingAddress.String()}
+			defaultSnatRule = iptables.SNATAction{
+				ToAddr:             r.Config.NATOutgoingAddress.String(),
+				DisableRandomFully: preservePorts,
+			}
 		}
 
 		if r.Config.NATPortRange.MaxPort > 0 {
 			toPorts := fmt.Sprintf("%d-%d", r.Config.NATPortRange.MinPort, r.Config.NATPortRange.MaxPort)
-			var portRangeSnatRule iptables.Action = iptables.MasqAction{ToPorts: toPorts}
+			var portRangeSnatRule iptables.Action = iptables.MasqAction{
+				ToPorts:            toPorts,
+				DisableRandomFully: preservePorts,
+			}
 			if r.Config.NATOutgoingAddress != nil {
 				toAddress := fmt.Sprintf("%s:%s", r.Config.NATOutgoingAddress.String(), toPorts)
-				portRangeSnatRule = iptables.SNATAction{ToAddr: toAddress}
+				portRangeSnatRule = iptables.SNATAction{ToAddr: toAddress, DisableRandomFully: preservePorts}
 			}
-			rules = []iptables.Rule{
+			rules = append(rules,
 				r.MakeNatOutgoingRule("tcp", portRangeSnatRule, ipVersion),
 				r.MakeNatOutgoingRule("tcp", iptables.ReturnAction{}, ipVersion),
 				r.MakeNatOutgoingRule("udp", portRangeSnatRule, ipVersion),
 				r.MakeNatOutgoingRule("udp", iptables.ReturnAction{}, ipVersion),
 				r.MakeNatOutgoingRule("", defaultSnatRule, ipVersion),
-			}
+			)
 		} else {
-			rules = []iptables.Rule{
-				r.MakeNatOutgoingRule("", defaultSnatRule, ipVersion),
-			}
+			rules = append(rules, r.MakeNatOutgoingRule("", defaultSnatRule, ipVersion))
 		}
 	}
 	return &iptables.Chain{
@@ -170,3 +269,48 @@ func (r *DefaultRuleRenderer) BlockedCIDRsToIptablesChains(cidrs []string, ipVer
 		Rules: rules,
 	}}
 }
+
+// cidrBlocklistChains renders the chain for the BlockedCIDRs of the given IP version.  These
+// apply whatever the ServiceLoopPrevention setting and don't depend on the BGP configuration, so
+// the chain is static.  Returns nil if there are no BlockedCIDRs.
+func (r *DefaultRuleRenderer) cidrBlocklistChains(ipVersion uint8) []*iptables.Chain {
+	if len(r.BlockedCIDRs) == 0 {
+		return nil
+	}
+	rules := []iptables.Rule{}
+	for i, blocked := range r.BlockedCIDRs {
+		if blocked.IPVersion() != ipVersion {
+			continue
+		}
+		if r.CIDRBlocklistMetricsEnabled {
+			rules = append(rules, iptables.Rule{
+				Match: iptables.Match().DestNet(blocked.CIDR),
+				Action: iptables.NflogAction{
+					Group:  NFLOGCIDRBlocklistGroup,
+					Prefix: fmt.Sprintf("%s%d", NFLOGCIDRBlocklistPrefix, i),
+				},
+			})
+		}
+		var action iptables.Action = iptables.DropAction{}
+		if blocked.Action == "Reject" {
+			action = iptables.RejectAction{}
+		}
+		rules = append(rules, iptables.Rule{
+			Match:   iptables.Match().DestNet(blocked.CIDR),
+			Action:  action,
+			Comment: []string{"Blocklisted CIDR " + blocked.CIDR},
+		})
+	}
+	return []*iptables.Chain{{
+		Name:  ChainCIDRBlocklist,
+		Rules: rules,
+	}}
+}
+
+// cidrBlocklistJump returns the rule that jumps to ChainCIDRBlocklist, if there are BlockedCIDRs.
+func (r *DefaultRuleRenderer) cidrBlocklistJump() []iptables.Rule {
+	if len(r.BlockedCIDRs) == 0 {
+		return nil
+	}
+	return []iptables.Rule{{Action: iptables.JumpAction{Target: ChainCIDRBlocklist}}}
+}
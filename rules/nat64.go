@@ -0,0 +1,140 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rules
+
+import (
+	"net"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/proto"
+)
+
+// addNAT64Nets returns a copy of the rule in which each IPv4 CIDR is accompanied by its
+// NAT64-mapped equivalent in each of the configured NAT64 prefixes, so that, in an IPv6-only
+// cluster, a rule written for an IPv4 peer also matches the peer's traffic after translation.
+// Rules without IPv4 CIDRs are returned as is.
+//
+// An IPv4-only rule is widened to both IP versions so that FilterRuleToIPVersion keeps its
+// IPv6 form; the IPv4 CIDRs are still filtered out of the IPv6 rules.  That's only done if the
+// rule has a positive IPv4 CIDR match: widening a rule whose only IPv4 CIDRs are negated would
+// give an IPv6 rule that matches all native IPv6 traffic.  ICMP matches are left alone since
+// NAT64 translates ICMP to ICMPv6, which has different types and codes.
+func (r *DefaultRuleRenderer) addNAT64Nets(pRule *proto.Rule) *proto.Rule {
+	ruleCopy := *pRule
+	var positiveMapped, negatedMapped bool
+	ruleCopy.SrcNet, positiveMapped = r.appendNAT64Nets(pRule.SrcNet, positiveMapped)
+	ruleCopy.DstNet, positiveMapped = r.appendNAT64Nets(pRule.DstNet, positiveMapped)
+	ruleCopy.NotSrcNet, negatedMapped = r.appendNAT64Nets(pRule.NotSrcNet, negatedMapped)
+	ruleCopy.NotDstNet, negatedMapped = r.appendNAT64Nets(pRule.NotDstNet, negatedMapped)
+	if !positiveMapped && !negatedMapped {
+		return pRule
+	}
+	if ruleCopy.IpVersion == proto.IPVersion_IPV4 {
+		if !positiveMapped || ruleMatchesICMP(pRule) {
+			return pRule
+		}
+		ruleCopy.IpVersion = proto.IPVersion_ANY
+	}
+	return &ruleCopy
+}
+
+func (r *DefaultRuleRenderer) appendNAT64Nets(nets []string, mapped bool) ([]string, bool) {
+	var out []string
+	for _, n := range nets {
+		ipNet := parseV4Net(n)
+		if ipNet == nil {
+			continue
+		}
+		if out == nil {
+			out = append(out, nets...)
+		}
+		for _, prefix := range r.NAT64Prefixes {
+			mappedNet, err := nat64MappedNet(prefix, ipNet)
+			if err != nil {
+				log.WithError(err).WithField("prefix", prefix).Warn("Invalid NAT64 prefix, ignoring")
+				continue
+			}
+			out = append(out, mappedNet)
+		}
+	}
+	if out == nil {
+		return nets, mapped
+	}
+	return out, true
+}
+
+// parseV4Net parses an IPv4 CIDR or bare IPv4 address, returning nil for anything else.
+func parseV4Net(s string) *net.IPNet {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s).To4()
+		if ip == nil {
+			return nil
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)}
+	}
+	_, ipNet, err := net.ParseCIDR(s)
+	if err != nil || ipNet.IP.To4() == nil {
+		return nil
+	}
+	return ipNet
+}
+
+// nat64MappedNet maps an IPv4 CIDR into a /96 NAT64 prefix, as described in RFC 6052.
+func nat64MappedNet(prefix string, v4Net *net.IPNet) (string, error) {
+	_, prefixNet, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return "", err
+	}
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, prefixNet.IP.To16()[:12])
+	copy(ip[12:], v4Net.IP.To4())
+	ones, _ := v4Net.Mask.Size()
+	mapped := net.IPNet{IP: ip, Mask: net.CIDRMask(96+ones, 128)}
+	return mapped.String(), nil
+}
+
+// NAT64SkipsRule returns true if the rule's IPv4 CIDRs aren't rendered with their NAT64-mapped
+// equivalents because the rule matches ICMP; see addNAT64Nets.
+func NAT64SkipsRule(pRule *proto.Rule) bool {
+	if pRule.IpVersion != proto.IPVersion_IPV4 || !ruleMatchesICMP(pRule) {
+		return false
+	}
+	for _, nets := range [][]string{pRule.SrcNet, pRule.DstNet} {
+		for _, n := range nets {
+			if parseV4Net(n) != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func ruleMatchesICMP(pRule *proto.Rule) bool {
+	if pRule.Icmp != nil || pRule.NotIcmp != nil {
+		return true
+	}
+	if pRule.Protocol == nil {
+		return false
+	}
+	switch p := pRule.Protocol.NumberOrName.(type) {
+	case *proto.Protocol_Name:
+		return strings.ToLower(p.Name) == "icmp"
+	case *proto.Protocol_Number:
+		return p.Number == 1
+	}
+	return false
+}
@@ -24,6 +24,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/projectcalico/felix/config"
 	"github.com/projectcalico/felix/ipsets"
 	. "github.com/projectcalico/felix/iptables"
 )
@@ -77,6 +78,128 @@ var _ = Describe("NAT", func() {
 			},
 		}))
 	})
+	It("should render rules when active with NAT outgoing exclusions", func() {
+		localConfig := rrConfigNormal
+		localConfig.NATOutgoingExclusionsEnabled = true
+		renderer = NewRenderer(localConfig)
+
+		Expect(renderer.NATOutgoingChain(true, 4)).To(Equal(&Chain{
+			Name: "cali-nat-outgoing",
+			Rules: []Rule{
+				{
+					Action: MasqAction{},
+					Match: Match().
+						SourceIPSet("cali40masq-ipam-pools").
+						NotDestIPSet("cali40all-ipam-pools").
+						NotDestIPSet("cali40nat-outgoing-excl"),
+				},
+			},
+		}))
+	})
+	It("should render actions without --random-fully when preserving ports", func() {
+		localConfig := rrConfigNormal
+		localConfig.NATPortRange, _ = numorstring.PortFromRange(99, 100)
+		localConfig.NATOutgoingPreservePorts = true
+		renderer = NewRenderer(localConfig)
+
+		chain := renderer.NATOutgoingChain(true, 4)
+		Expect(chain.Rules).To(HaveLen(5))
+		Expect(chain.Rules[0].Action).To(Equal(MasqAction{ToPorts: "99-100", DisableRandomFully: true}))
+		Expect(chain.Rules[4].Action).To(Equal(MasqAction{DisableRandomFully: true}))
+	})
+	It("should skip NAT outgoing for steered egress gateway traffic", func() {
+		localConfig := rrConfigNormal
+		localConfig.EgressGatewaySteering = []config.EgressGatewayRule{
+			{ClientSelector: "has(a)", GatewaySelector: "has(gw)"},
+		}
+		renderer = NewRenderer(localConfig)
+
+		Expect(renderer.NATOutgoingChain(true, 4).Rules[0]).To(Equal(Rule{
+			Action: ReturnAction{},
+			Match: Match().
+				SourceIPSet("cali40egw-client-0").
+				OutInterface("tunl0"),
+		}))
+		Expect(renderer.NATOutgoingChain(true, 6).Rules).To(HaveLen(1))
+	})
+	It("should render source pool rules ahead of the default rule", func() {
+		localConfig := rrConfigNormal
+		localConfig.NATOutgoingSourcePools = []config.SNATSourcePool{
+			{Selector: "has(a)", ToSource: "10.0.0.1-10.0.0.9"},
+			{Selector: "has(b)", ToSource: "dead:beef::/120"},
+			{Selector: "has(c)", ToSource: "10.1.0.0/28"},
+		}
+		renderer = NewRenderer(localConfig)
+
+		Expect(renderer.NATOutgoingChain(true, 4)).To(Equal(&Chain{
+			Name: "cali-nat-outgoing",
+			Rules: []Rule{
+				{
+					Action: SNATAction{ToAddr: "10.0.0.1-10.0.0.9"},
+					Match: Match().
+						SourceIPSet("cali40masq-ipam-pools").
+						NotDestIPSet("cali40all-ipam-pools").
+						SourceIPSet("cali40nat-src-pool-0"),
+				},
+				{
+					Action: NetmapAction{ToNet: "10.1.0.0/28"},
+					Match: Match().
+						SourceIPSet("cali40masq-ipam-pools").
+						NotDestIPSet("cali40all-ipam-pools").
+						SourceIPSet("cali40nat-src-pool-2"),
+				},
+				{
+					Action: MasqAction{},
+					Match: Match().
+						SourceIPSet("cali40masq-ipam-pools").
+						NotDestIPSet("cali40all-ipam-pools"),
+				},
+			},
+		}))
+		Expect(renderer.NATOutgoingChain(true, 6).Rules[0]).To(Equal(Rule{
+			Action: NetmapAction{ToNet: "dead:beef::/120"},
+			Match: Match().
+				SourceIPSet("cali60masq-ipam-pools").
+				NotDestIPSet("cali60all-ipam-pools").
+				SourceIPSet("cali60nat-src-pool-1"),
+		}))
+	})
+	It("should limit source pool SNAT to the port range", func() {
+		localConfig := rrConfigNormal
+		localConfig.NATPortRange, _ = numorstring.PortFromRange(1024, 2047)
+		localConfig.NATOutgoingSourcePools = []config.SNATSourcePool{
+			{Selector: "has(a)", ToSource: "10.0.0.1-10.0.0.9"},
+			{Selector: "has(b)", ToSource: "dead:beef::1"},
+			{Selector: "has(c)", ToSource: "10.1.0.0/28"},
+		}
+		renderer = NewRenderer(localConfig)
+
+		natOutgoing := func() MatchCriteria {
+			return Match().
+				SourceIPSet("cali40masq-ipam-pools").
+				NotDestIPSet("cali40all-ipam-pools")
+		}
+		Expect(renderer.NATOutgoingChain(true, 4).Rules[:4]).To(Equal([]Rule{
+			{
+				Action: SNATAction{ToAddr: "10.0.0.1-10.0.0.9:1024-2047"},
+				Match:  natOutgoing().Protocol("tcp").SourceIPSet("cali40nat-src-pool-0"),
+			},
+			{
+				Action: SNATAction{ToAddr: "10.0.0.1-10.0.0.9:1024-2047"},
+				Match:  natOutgoing().Protocol("udp").SourceIPSet("cali40nat-src-pool-0"),
+			},
+			{
+				Action: SNATAction{ToAddr: "10.0.0.1-10.0.0.9"},
+				Match:  natOutgoing().SourceIPSet("cali40nat-src-pool-0"),
+			},
+			{
+				Action: NetmapAction{ToNet: "10.1.0.0/28"},
+				Match:  natOutgoing().SourceIPSet("cali40nat-src-pool-2"),
+			},
+		}))
+		Expect(renderer.NATOutgoingChain(true, 6).Rules[0].Action).To(Equal(
+			SNATAction{ToAddr: "[dead:beef::1]:1024-2047"}))
+	})
 	It("should render rules when active with explicit port range", func() {
 
 		//copy struct
@@ -222,4 +345,89 @@ var _ = Describe("NAT", func() {
 			Rules: nil,
 		}))
 	})
+
+	Describe("with a CIDR blocklist", func() {
+		BeforeEach(func() {
+			localConfig := rrConfigNormal
+			localConfig.ServiceLoopPrevention = "Drop"
+			localConfig.BlockedCIDRs = []config.BlockedCIDR{
+				{CIDR: "10.99.0.0/16", Action: "Drop"},
+				{CIDR: "fd00:99::/64", Action: "Reject"},
+				{CIDR: "192.0.2.0/24", Action: "Reject"},
+			}
+			renderer = NewRenderer(localConfig)
+		})
+
+		It("should block the CIDRs of the right IP version in their own chain", func() {
+			Expect(renderer.BlockedCIDRsToIptablesChains([]string{"10.96.0.0/12"}, 4)).To(Equal([]*Chain{{
+				Name:  "cali-cidr-block",
+				Rules: []Rule{{Match: Match().DestNet("10.96.0.0/12"), Action: DropAction{}}},
+			}}))
+			Expect(findChain(renderer.StaticFilterTableChains(4), "cali-cidr-blocklist")).To(Equal(&Chain{
+				Name: "cali-cidr-blocklist",
+				Rules: []Rule{
+					{
+						Match:   Match().DestNet("10.99.0.0/16"),
+						Action:  DropAction{},
+						Comment: []string{"Blocklisted CIDR 10.99.0.0/16"},
+					},
+					{
+						Match:   Match().DestNet("192.0.2.0/24"),
+						Action:  RejectAction{},
+						Comment: []string{"Blocklisted CIDR 192.0.2.0/24"},
+					},
+				},
+			}))
+		})
+
+		It("should block forwarded and host-originated traffic", func() {
+			chains := renderer.StaticFilterTableChains(4)
+			jump := Rule{Action: JumpAction{Target: "cali-cidr-blocklist"}}
+			Expect(findChain(chains, "cali-FORWARD").Rules).To(ContainElement(jump))
+			Expect(findChain(chains, "cali-OUTPUT").Rules[0]).To(Equal(jump))
+		})
+
+		It("should block the CIDRs when service loop prevention is disabled", func() {
+			localConfig := rrConfigNormal
+			localConfig.ServiceLoopPrevention = "Disabled"
+			localConfig.BlockedCIDRs = []config.BlockedCIDR{{CIDR: "fd00:99::/64", Action: "Reject"}}
+			renderer = NewRenderer(localConfig)
+			Expect(renderer.BlockedCIDRsToIptablesChains([]string{"fd00:96::/112"}, 6)).To(Equal([]*Chain{{
+				Name:  "cali-cidr-block",
+				Rules: []Rule{},
+			}}))
+			Expect(findChain(renderer.StaticFilterTableChains(6), "cali-cidr-blocklist")).To(Equal(&Chain{
+				Name: "cali-cidr-blocklist",
+				Rules: []Rule{{
+					Match:   Match().DestNet("fd00:99::/64"),
+					Action:  RejectAction{},
+					Comment: []string{"Blocklisted CIDR fd00:99::/64"},
+				}},
+			}))
+		})
+
+		It("should log blocked packets when metrics are enabled", func() {
+			localConfig := rrConfigNormal
+			localConfig.BlockedCIDRs = []config.BlockedCIDR{
+				{CIDR: "fd00:99::/64", Action: "Reject"},
+				{CIDR: "10.99.0.0/16", Action: "Drop"},
+			}
+			localConfig.CIDRBlocklistMetricsEnabled = true
+			renderer = NewRenderer(localConfig)
+			Expect(findChain(renderer.StaticFilterTableChains(4), "cali-cidr-blocklist")).To(Equal(&Chain{
+				Name: "cali-cidr-blocklist",
+				Rules: []Rule{
+					{
+						Match:  Match().DestNet("10.99.0.0/16"),
+						Action: NflogAction{Group: 22, Prefix: "B|1"},
+					},
+					{
+						Match:   Match().DestNet("10.99.0.0/16"),
+						Action:  DropAction{},
+						Comment: []string{"Blocklisted CIDR 10.99.0.0/16"},
+					},
+				},
+			}))
+		})
+	})
 })
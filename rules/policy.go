@@ -15,7 +15,10 @@
 package rules
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
+	"strconv"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -31,11 +34,11 @@ import (
 func (r *DefaultRuleRenderer) PolicyToIptablesChains(policyID *proto.PolicyID, policy *proto.Policy, ipVersion uint8) []*iptables.Chain {
 	inbound := iptables.Chain{
 		Name:  PolicyChainName(PolicyInboundPfx, policyID),
-		Rules: r.ProtoRulesToIptablesRules(policy.InboundRules, ipVersion),
+		Rules: r.policyRulesToIptablesRules(policy.InboundRules, ipVersion, NFLOGDirInbound, policyID.Name),
 	}
 	outbound := iptables.Chain{
 		Name:  PolicyChainName(PolicyOutboundPfx, policyID),
-		Rules: r.ProtoRulesToIptablesRules(policy.OutboundRules, ipVersion),
+		Rules: r.policyRulesToIptablesRules(policy.OutboundRules, ipVersion, NFLOGDirOutbound, policyID.Name),
 	}
 	return []*iptables.Chain{&inbound, &outbound}
 }
@@ -43,15 +46,65 @@ func (r *DefaultRuleRenderer) PolicyToIptablesChains(policyID *proto.PolicyID, p
 func (r *DefaultRuleRenderer) ProfileToIptablesChains(profileID *proto.ProfileID, profile *proto.Profile, ipVersion uint8) (inbound, outbound *iptables.Chain) {
 	inbound = &iptables.Chain{
 		Name:  ProfileChainName(ProfileInboundPfx, profileID),
-		Rules: r.ProtoRulesToIptablesRules(profile.InboundRules, ipVersion),
+		Rules: r.policyRulesToIptablesRules(profile.InboundRules, ipVersion, NFLOGDirInbound, profileID.Name),
 	}
 	outbound = &iptables.Chain{
 		Name:  ProfileChainName(ProfileOutboundPfx, profileID),
-		Rules: r.ProtoRulesToIptablesRules(profile.OutboundRules, ipVersion),
+		Rules: r.policyRulesToIptablesRules(profile.OutboundRules, ipVersion, NFLOGDirOutbound, profileID.Name),
 	}
 	return
 }
 
+// policyRulesToIptablesRules renders the rules of a policy or profile.  If flow logs are enabled,
+// it inserts an NFLOG rule before each drop rule so that the flow logs collector can attribute
+// denied packets to the named policy or profile and the index of the rule within it.  In failsafe
+// audit mode, it also inserts a jump to the failsafe audit chain for packets that a failsafe port
+// would have accepted.
+func (r *DefaultRuleRenderer) policyRulesToIptablesRules(
+	protoRules []*proto.Rule, ipVersion uint8, dir string, name string,
+) []iptables.Rule {
+	if !r.FlowLogsEnabled && !r.FailsafeAuditEnabled {
+		return r.ProtoRulesToIptablesRules(protoRules, ipVersion)
+	}
+	auditChain := ChainFailsafeInAudit
+	if dir == NFLOGDirOutbound {
+		auditChain = ChainFailsafeOutAudit
+	}
+	var rules []iptables.Rule
+	for i, protoRule := range protoRules {
+		for _, rule := range r.ProtoRuleToIptablesRules(protoRule, ipVersion) {
+			if _, ok := rule.Action.(iptables.DropAction); ok {
+				if r.FailsafeAuditEnabled {
+					// Copy the match so that we don't share its backing array with the drop rule.
+					match := append(iptables.MatchCriteria{}, rule.Match...)
+					rules = append(rules, iptables.Rule{
+						Match:  match.MarkSingleBitSet(r.FailsafeAuditIptablesMark),
+						Action: iptables.JumpAction{Target: auditChain},
+					})
+				}
+				if r.FlowLogsEnabled {
+					rules = append(rules, r.denyLogRule(rule.Match, dir, strconv.Itoa(i), name))
+				}
+			}
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// denyLogRule returns an NFLOG rule for denied packets.  The prefix has the form
+// "D|<direction><rule index>|<name>"; see NFLOGDenyPrefix.
+func (r *DefaultRuleRenderer) denyLogRule(match iptables.MatchCriteria, dir, ruleIdx, name string) iptables.Rule {
+	prefix := NFLOGDenyPrefix + dir + ruleIdx + "|" + name
+	if len(prefix) > maxNFLOGPrefixLen {
+		prefix = prefix[:maxNFLOGPrefixLen]
+	}
+	return iptables.Rule{
+		Match:  match,
+		Action: iptables.NflogAction{Group: NFLOGDenyGroup, Prefix: prefix},
+	}
+}
+
 func (r *DefaultRuleRenderer) ProtoRulesToIptablesRules(protoRules []*proto.Rule, ipVersion uint8) []iptables.Rule {
 	var rules []iptables.Rule
 	for _, protoRule := range protoRules {
@@ -129,6 +182,9 @@ func FilterRuleToIPVersion(ipVersion uint8, pRule *proto.Rule) *proto.Rule {
 
 func (r *DefaultRuleRenderer) ProtoRuleToIptablesRules(pRule *proto.Rule, ipVersion uint8) []iptables.Rule {
 
+	if ipVersion == 6 && len(r.NAT64Prefixes) > 0 {
+		pRule = r.addNAT64Nets(pRule)
+	}
 	ruleCopy := FilterRuleToIPVersion(ipVersion, pRule)
 	if ruleCopy == nil {
 		return nil
@@ -620,6 +676,15 @@ func (r *DefaultRuleRenderer) CalculateRuleMatch(pRule *proto.Rule, ipVersion ui
 		}).Debug("Adding dst IP set match")
 	}
 
+	if len(pRule.DstDomains) > 0 {
+		ipsetName := nameForIPSet(DomainIPSetID(pRule.DstDomains))
+		logCxt.WithFields(log.Fields{
+			"domains":   pRule.DstDomains,
+			"ipSetName": ipsetName,
+		}).Debug("Adding dst domains match")
+		match = match.DestIPSet(ipsetName)
+	}
+
 	if len(pRule.DstPorts) > 0 {
 		logCxt.WithFields(log.Fields{
 			"ports": pRule.SrcPorts,
@@ -782,3 +847,15 @@ func ProfileChainName(prefix ProfileChainNamePrefix, profID *proto.ProfileID) st
 		iptables.MaxChainNameLength,
 	)
 }
+
+// PolicyGroupChainName returns the name of the chain that jumps to the given, ordered, list of
+// policies.  The name is always a hash since the list is usually too long to fit.
+func PolicyGroupChainName(prefix string, policyNames []string) string {
+	hasher := sha256.New()
+	for _, name := range policyNames {
+		// Policy names can't contain newlines so this can't be ambiguous.
+		_, _ = hasher.Write([]byte(name + "\n"))
+	}
+	hash := base64.RawURLEncoding.EncodeToString(hasher.Sum(nil))
+	return prefix + hash[:iptables.MaxChainNameLength-len(prefix)]
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rules_test
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/projectcalico/felix/rules"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/iptables"
+	"github.com/projectcalico/felix/proto"
+)
+
+// The random policy test renders randomly generated policies and checks that the renderer
+// doesn't panic, is deterministic and only produces well-formed iptables rules.  Set
+// FELIX_RANDOM_TEST_SEED to reproduce a failure and FELIX_RANDOM_TEST_ITERATIONS to run it for
+// longer.
+
+func randomTestSeed() int64 {
+	if s, err := strconv.ParseInt(os.Getenv("FELIX_RANDOM_TEST_SEED"), 10, 64); err == nil {
+		return s
+	}
+	return time.Now().UnixNano()
+}
+
+func randomTestIterations(def int) int {
+	if n, err := strconv.Atoi(os.Getenv("FELIX_RANDOM_TEST_ITERATIONS")); err == nil {
+		return n
+	}
+	return def
+}
+
+var randomRuleConfig = Config{
+	IPSetConfigV4:        ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+	IPSetConfigV6:        ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+	IptablesMarkAccept:   0x80,
+	IptablesMarkPass:     0x100,
+	IptablesMarkScratch0: 0x200,
+	IptablesMarkScratch1: 0x400,
+	IptablesMarkEndpoint: 0xff000,
+	IptablesLogPrefix:    "calico-packet",
+}
+
+func randomNets(r *rand.Rand) []string {
+	nets := make([]string, r.Intn(4))
+	for i := range nets {
+		nets[i] = fmt.Sprintf("10.%d.%d.0/%d", r.Intn(256), r.Intn(256), 16+r.Intn(17))
+	}
+	return nets
+}
+
+func randomPorts(r *rand.Rand) []*proto.PortRange {
+	// Sometimes more than fit in one multiport match.
+	ports := make([]*proto.PortRange, r.Intn(20))
+	for i := range ports {
+		first := int32(1 + r.Intn(65535))
+		last := first
+		if r.Intn(2) == 0 {
+			last = first + int32(r.Intn(int(65536-first)))
+		}
+		ports[i] = &proto.PortRange{First: first, Last: last}
+	}
+	return ports
+}
+
+func randomIPSetIDs(r *rand.Rand) []string {
+	ids := make([]string, r.Intn(3))
+	for i := range ids {
+		ids[i] = fmt.Sprintf("s:%x", r.Int63())
+	}
+	return ids
+}
+
+func randomProtoRule(r *rand.Rand) *proto.Rule {
+	rule := &proto.Rule{
+		Action:         []string{"", "allow", "deny", "next-tier", "pass", "log"}[r.Intn(6)],
+		SrcNet:         randomNets(r),
+		DstNet:         randomNets(r),
+		NotSrcNet:      randomNets(r),
+		SrcIpSetIds:    randomIPSetIDs(r),
+		NotDstIpSetIds: randomIPSetIDs(r),
+		RuleId:         fmt.Sprintf("%x", r.Int63()),
+	}
+	switch r.Intn(4) {
+	case 0:
+		rule.Protocol = &proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "tcp"}}
+		rule.DstPorts = randomPorts(r)
+		rule.NotSrcPorts = randomPorts(r)
+		rule.DstNamedPortIpSetIds = randomIPSetIDs(r)
+	case 1:
+		rule.Protocol = &proto.Protocol{NumberOrName: &proto.Protocol_Number{Number: 17}}
+		rule.SrcPorts = randomPorts(r)
+	case 2:
+		rule.Protocol = &proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "icmp"}}
+		rule.Icmp = &proto.Rule_IcmpTypeCode{IcmpTypeCode: &proto.IcmpTypeAndCode{
+			Type: int32(r.Intn(256)),
+			Code: int32(r.Intn(256)),
+		}}
+	}
+	return rule
+}
+
+func randomPolicy(r *rand.Rand) *proto.Policy {
+	policy := &proto.Policy{}
+	for n := r.Intn(10); n > 0; n-- {
+		policy.InboundRules = append(policy.InboundRules, randomProtoRule(r))
+	}
+	for n := r.Intn(10); n > 0; n-- {
+		policy.OutboundRules = append(policy.OutboundRules, randomProtoRule(r))
+	}
+	return policy
+}
+
+var _ = Describe("Rendering random policies", func() {
+	It("should render well-formed rules deterministically", func() {
+		seed := randomTestSeed()
+		r := rand.New(rand.NewSource(seed))
+		renderer := NewRenderer(randomRuleConfig)
+		features := &iptables.Features{}
+		for i := 0; i < randomTestIterations(200); i++ {
+			policyID := &proto.PolicyID{Tier: "default", Name: fmt.Sprint("pol-", i)}
+			policy := randomPolicy(r)
+			chains := renderer.PolicyToIptablesChains(policyID, policy, 4)
+			Expect(renderer.PolicyToIptablesChains(policyID, policy, 4)).To(Equal(chains),
+				"Rendering wasn't deterministic, seed %d", seed)
+			for _, chain := range chains {
+				for _, rule := range chain.Rules {
+					line := rule.RenderAppend(chain.Name, "", features)
+					Expect(line).NotTo(ContainSubstring("\n"), "seed %d", seed)
+					Expect(line).NotTo(ContainSubstring("%!"), "Formatting error, seed %d", seed)
+					Expect(strings.Count(line, `"`)%2).To(BeZero(), "Unbalanced quotes, seed %d", seed)
+					for _, field := range strings.Fields(line) {
+						if strings.Contains(field, ",") && !strings.Contains(field, `"`) {
+							// Multiport only supports 15 ports, with ranges counting as two.
+							Expect(multiportSlots(field)).To(BeNumerically("<=", 15),
+								"Too many ports in %q, seed %d", line, seed)
+						}
+					}
+				}
+			}
+		}
+	})
+})
+
+// multiportSlots returns the number of multiport slots that a comma-separated list of ports and
+// port ranges would take up; it returns 0 for other comma-separated lists.
+func multiportSlots(field string) int {
+	slots := 0
+	for _, p := range strings.Split(field, ",") {
+		for _, part := range strings.Split(p, ":") {
+			if _, err := strconv.Atoi(part); err != nil {
+				return 0
+			}
+		}
+		slots++
+		if strings.Contains(p, ":") {
+			slots++
+		}
+	}
+	return slots
+}
+
+func BenchmarkPolicyToIptablesChains(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	renderer := NewRenderer(randomRuleConfig)
+	policies := make([]*proto.Policy, 100)
+	for i := range policies {
+		policies[i] = randomPolicy(r)
+	}
+	policyID := &proto.PolicyID{Tier: "default", Name: "pol"}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		renderer.PolicyToIptablesChains(policyID, policies[i%len(policies)], 4)
+	}
+}
@@ -26,6 +26,9 @@ import (
 	"github.com/projectcalico/felix/proto"
 )
 
+// domainIPSetName is the (truncated) name of the IP set for the "Dest domains" rule.
+var domainIPSetName = ("cali40" + DomainIPSetID([]string{"*.example.com", "example.org"}))[:31]
+
 var ruleTestData = []TableEntry{
 	Entry("Empty rule", 4, proto.Rule{}, ""),
 
@@ -79,6 +82,9 @@ var ruleTestData = []TableEntry{
 	Entry("Dest IP sets", 4,
 		proto.Rule{DstIpSetIds: []string{"ipsetid1", "ipsetid2"}},
 		"-m set --match-set cali40ipsetid1 dst -m set --match-set cali40ipsetid2 dst"),
+	Entry("Dest domains", 4,
+		proto.Rule{DstDomains: []string{"*.example.com", "example.org"}},
+		"-m set --match-set "+domainIPSetName+" dst"),
 	Entry("Dest ports", 4,
 		proto.Rule{DstPorts: []*proto.PortRange{{First: 10, Last: 12}}},
 		"-m multiport --destination-ports 10:12"),
@@ -288,6 +294,62 @@ var _ = Describe("Protobuf rule to iptables rule conversion", func() {
 		ruleTestData...,
 	)
 
+	It("should log denied packets to NFLOG when flow logs are enabled", func() {
+		rrConfigFlowLogs := rrConfigNormal
+		rrConfigFlowLogs.FlowLogsEnabled = true
+		renderer := NewRenderer(rrConfigFlowLogs)
+		policy := proto.Policy{
+			InboundRules: []*proto.Rule{{Action: "log"}, {Action: "deny"}},
+		}
+		chains := renderer.PolicyToIptablesChains(&proto.PolicyID{Tier: "default", Name: "default.foo"}, &policy, 4)
+		Expect(chains[0].Rules).To(Equal([]iptables.Rule{
+			{
+				Match:  iptables.Match(),
+				Action: iptables.LogAction{Prefix: "calico-packet"},
+			},
+			{
+				Match:  iptables.Match(),
+				Action: iptables.NflogAction{Group: NFLOGDenyGroup, Prefix: "D|I1|default.foo"},
+			},
+			{
+				Match:  iptables.Match(),
+				Action: iptables.DropAction{},
+			},
+		}))
+	})
+
+	It("should jump to the failsafe audit chain before denying in failsafe audit mode", func() {
+		rrConfigAudit := rrConfigNormal
+		rrConfigAudit.FailsafeAuditEnabled = true
+		rrConfigAudit.FailsafeAuditIptablesMark = 0x800
+		renderer := NewRenderer(rrConfigAudit)
+		policy := proto.Policy{
+			InboundRules:  []*proto.Rule{{Action: "deny", Protocol: &proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "tcp"}}}},
+			OutboundRules: []*proto.Rule{{Action: "deny"}},
+		}
+		chains := renderer.PolicyToIptablesChains(&proto.PolicyID{Tier: "default", Name: "default.foo"}, &policy, 4)
+		Expect(chains[0].Rules).To(Equal([]iptables.Rule{
+			{
+				Match:  iptables.Match().Protocol("tcp").MarkSingleBitSet(0x800),
+				Action: iptables.JumpAction{Target: ChainFailsafeInAudit},
+			},
+			{
+				Match:  iptables.Match().Protocol("tcp"),
+				Action: iptables.DropAction{},
+			},
+		}))
+		Expect(chains[1].Rules).To(Equal([]iptables.Rule{
+			{
+				Match:  iptables.Match().MarkSingleBitSet(0x800),
+				Action: iptables.JumpAction{Target: ChainFailsafeOutAudit},
+			},
+			{
+				Match:  iptables.Match(),
+				Action: iptables.DropAction{},
+			},
+		}))
+	})
+
 	const (
 		clearBothMarksRule       = "-A test --jump MARK --set-mark 0x0/0x600"
 		preSetAllBlocksMarkRule  = "-A test --jump MARK --set-mark 0x200/0x600"
@@ -1063,3 +1125,62 @@ var _ = Describe("rule metadata tests", func() {
 		}
 	})
 })
+
+var _ = Describe("NAT64 prefix tests", func() {
+	rrConfigNAT64 := Config{
+		IPIPEnabled:          true,
+		IPIPTunnelAddress:    nil,
+		IPSetConfigV4:        ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+		IPSetConfigV6:        ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+		IptablesMarkAccept:   0x80,
+		IptablesMarkPass:     0x100,
+		IptablesMarkScratch0: 0x200,
+		IptablesMarkScratch1: 0x400,
+		IptablesMarkEndpoint: 0xff000,
+		IptablesLogPrefix:    "calico-packet",
+		NAT64Prefixes:        []string{"64:ff9b::/96"},
+	}
+
+	DescribeTable("IPv6 rendering of IPv4 CIDRs",
+		func(rule proto.Rule, expMatch string) {
+			renderer := NewRenderer(rrConfigNAT64)
+			rules := renderer.ProtoRuleToIptablesRules(&rule, 6)
+			if expMatch == "" {
+				Expect(rules).To(BeEmpty())
+				return
+			}
+			Expect(rules).To(HaveLen(1))
+			Expect(rules[0].Match.Render()).To(Equal(expMatch))
+		},
+		Entry("source CIDR", proto.Rule{SrcNet: []string{"10.0.0.0/16"}},
+			"--source 64:ff9b::a00:0/112"),
+		Entry("IPv4-only rule", proto.Rule{IpVersion: proto.IPVersion_IPV4, DstNet: []string{"10.0.0.1"}},
+			"--destination 64:ff9b::a00:1/128"),
+		Entry("negated destination CIDR", proto.Rule{NotDstNet: []string{"192.168.0.0/24"}},
+			"! --destination 64:ff9b::c0a8:0/120"),
+		Entry("IPv4-only rule with only a negated CIDR",
+			proto.Rule{IpVersion: proto.IPVersion_IPV4, NotSrcNet: []string{"10.0.0.0/8"}},
+			""),
+		Entry("IPv4-only rule with a positive and a negated CIDR",
+			proto.Rule{
+				IpVersion: proto.IPVersion_IPV4,
+				SrcNet:    []string{"10.0.0.0/8"},
+				NotDstNet: []string{"10.1.0.0/16"},
+			},
+			"--source 64:ff9b::a00:0/104 ! --destination 64:ff9b::a01:0/112"),
+		Entry("IPv4-only ICMP rule",
+			proto.Rule{
+				IpVersion: proto.IPVersion_IPV4,
+				Protocol:  &proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "icmp"}},
+				SrcNet:    []string{"10.0.0.0/16"},
+			},
+			""),
+	)
+
+	It("should leave IPv4 rendering alone", func() {
+		renderer := NewRenderer(rrConfigNAT64)
+		rules := renderer.ProtoRuleToIptablesRules(&proto.Rule{SrcNet: []string{"10.0.0.0/16"}}, 4)
+		Expect(rules).To(HaveLen(1))
+		Expect(rules[0].Match.Render()).To(Equal("--source 10.0.0.0/16"))
+	})
+})
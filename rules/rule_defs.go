@@ -15,6 +15,7 @@
 package rules
 
 import (
+	"fmt"
 	"net"
 	"reflect"
 	"strings"
@@ -26,6 +27,7 @@ import (
 	"github.com/projectcalico/felix/ipsets"
 	"github.com/projectcalico/felix/iptables"
 	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/libcalico-go/lib/hash"
 )
 
 const (
@@ -47,6 +49,12 @@ const (
 	ChainFailsafeIn  = ChainNamePrefix + "failsafe-in"
 	ChainFailsafeOut = ChainNamePrefix + "failsafe-out"
 
+	ChainFailsafeInAudit  = ChainNamePrefix + "failsafe-in-audit"
+	ChainFailsafeOutAudit = ChainNamePrefix + "failsafe-out-audit"
+
+	ChainClusterServicesToWl   = ChainNamePrefix + "cluster-svc-to-wl"
+	ChainClusterServicesFromWl = ChainNamePrefix + "cluster-svc-from-wl"
+
 	ChainNATPrerouting  = ChainNamePrefix + "PREROUTING"
 	ChainNATPostrouting = ChainNamePrefix + "POSTROUTING"
 	ChainNATOutput      = ChainNamePrefix + "OUTPUT"
@@ -57,6 +65,22 @@ const (
 
 	IPSetIDNATOutgoingAllPools  = "all-ipam-pools"
 	IPSetIDNATOutgoingMasqPools = "masq-ipam-pools"
+	// IPSetIDNATOutgoingExclusions is the ID of the IP set that the calculation graph populates
+	// with the IPs matching the NATOutgoingExclusionSelector.
+	IPSetIDNATOutgoingExclusions = "nat-outgoing-excl"
+	// IPSetIDNATOutgoingSourcePoolPrefix prefixes the IDs of the IP sets that hold the workloads
+	// selected by each of the NATOutgoingSourcePools.  See NATOutgoingSourcePoolIPSetID.
+	IPSetIDNATOutgoingSourcePoolPrefix = "nat-src-pool-"
+	// IPSetIDEgressGatewayClientPrefix and IPSetIDEgressGatewayPrefix prefix the IDs of the IP
+	// sets that hold the workloads and gateways selected by each EgressGatewaySteering rule.
+	IPSetIDEgressGatewayClientPrefix = "egw-client-"
+	IPSetIDEgressGatewayPrefix       = "egw-gw-"
+	// IPSetIDProxyNeighborPrefix prefixes the IDs of the IP sets that hold the workloads selected
+	// by each WorkloadProxyNeighbors rule.  See ProxyNeighborIPSetID.
+	IPSetIDProxyNeighborPrefix = "proxy-neigh-"
+	// IPSetIDConntrackHelperPrefix prefixes the IDs of the IP sets that hold the workloads
+	// selected by each ConntrackHelpers rule.  See ConntrackHelperIPSetID.
+	IPSetIDConntrackHelperPrefix = "ct-helper-"
 
 	IPSetIDAllHostNets        = "all-hosts-net"
 	IPSetIDAllVXLANSourceNets = "all-vxlan-net"
@@ -66,12 +90,20 @@ const (
 	ChainFIPSnat = ChainNamePrefix + "fip-snat"
 
 	ChainCIDRBlock = ChainNamePrefix + "cidr-block"
+	// ChainCIDRBlocklist holds the rules for the BlockedCIDRs.  It is jumped to from both the
+	// FORWARD and OUTPUT chains, so it blocks host-originated traffic as well as forwarded traffic.
+	ChainCIDRBlocklist = ChainNamePrefix + "cidr-blocklist"
 
 	PolicyInboundPfx   PolicyChainNamePrefix  = ChainNamePrefix + "pi-"
 	PolicyOutboundPfx  PolicyChainNamePrefix  = ChainNamePrefix + "po-"
 	ProfileInboundPfx  ProfileChainNamePrefix = ChainNamePrefix + "pri-"
 	ProfileOutboundPfx ProfileChainNamePrefix = ChainNamePrefix + "pro-"
 
+	// Workloads with the same policies share the chains that jump to them; see
+	// PolicyGroupChainName.
+	PolicyGroupInboundPfx  = ChainNamePrefix + "gi-"
+	PolicyGroupOutboundPfx = ChainNamePrefix + "go-"
+
 	ChainWorkloadToHost       = ChainNamePrefix + "wl-to-host"
 	ChainFromWorkloadDispatch = ChainNamePrefix + "from-wl-dispatch"
 	ChainToWorkloadDispatch   = ChainNamePrefix + "to-wl-dispatch"
@@ -83,6 +115,9 @@ const (
 	ChainDispatchSetEndPointMark         = ChainNamePrefix + "set-endpoint-mark"
 	ChainDispatchFromEndPointMark        = ChainNamePrefix + "from-endpoint-mark"
 
+	ChainSetVRFEndpointMark      = ChainNamePrefix + "set-vrf-endpoint-mark"
+	ChainFromVRFWorkloadDispatch = ChainNamePrefix + "from-vrf-wl-dispatch"
+
 	ChainForwardCheck        = ChainNamePrefix + "forward-check"
 	ChainForwardEndpointMark = ChainNamePrefix + "forward-endpoint-mark"
 
@@ -114,6 +149,33 @@ const (
 		`-A POSTROUTING -o tunl0 -m addrtype ! --src-type LOCAL --limit-iface-out -m addrtype --src-type LOCAL -j MASQUERADE`
 
 	KubeProxyInsertRuleRegex = `-j KUBE-[a-zA-Z0-9-]*SERVICES|-j KUBE-FORWARD`
+
+	// NFLOGDenyGroup is the NFLOG group that denied packets are sent to when flow logs are
+	// enabled.  The NFLOG prefix has the form "D|<direction><rule index>|<name>", where the
+	// direction is NFLOGDirInbound or NFLOGDirOutbound (relative to the endpoint), the rule
+	// index is the index of the denying rule within the policy or profile (or
+	// NFLOGNoRuleIndex for the end-of-chain drops) and the name is that of the policy or
+	// profile, or one of the NFLOGNo... values for the end-of-chain drops.
+	NFLOGDenyGroup        = 20
+	NFLOGDenyPrefix       = "D|"
+	NFLOGDirInbound       = "I"
+	NFLOGDirOutbound      = "O"
+	NFLOGNoRuleIndex      = "-"
+	NFLOGNoPolicyMatched  = "(no-policy-matched)"
+	NFLOGNoProfileMatched = "(no-profile-matched)"
+	// NFLOGFailsafeAuditGroup is the NFLOG group that packets are sent to when, in failsafe audit
+	// mode, they are accepted only because of a failsafe port.  The NFLOG prefix has the form
+	// "A|<direction>|<index>", where the direction is NFLOGDirInbound or NFLOGDirOutbound and the
+	// index is that of the failsafe port in FailsafeInboundHostPorts or FailsafeOutboundHostPorts.
+	NFLOGFailsafeAuditGroup  = 21
+	NFLOGFailsafeAuditPrefix = "A|"
+	// NFLOGCIDRBlocklistGroup is the NFLOG group that packets are sent to when they are blocked by
+	// an entry in the CIDR blocklist.  The NFLOG prefix has the form "B|<index>", where the index is
+	// that of the entry in CIDRBlocklist.
+	NFLOGCIDRBlocklistGroup  = 22
+	NFLOGCIDRBlocklistPrefix = "B|"
+	// maxNFLOGPrefixLen is the kernel's limit on the length of an NFLOG prefix.
+	maxNFLOGPrefixLen = 63
 )
 
 // Typedefs to prevent accidentally passing the wrong prefix to the Policy/ProfileChainName()
@@ -177,6 +239,7 @@ type RuleRenderer interface {
 		ingressPolicies []string,
 		egressPolicies []string,
 		profileIDs []string,
+		connLimits ConnectionLimits,
 	) []*iptables.Chain
 
 	WorkloadInterfaceAllowChains(endpoints map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint) []*iptables.Chain
@@ -186,6 +249,8 @@ type RuleRenderer interface {
 		wlEndpoints map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint,
 		hepEndpoints map[string]proto.HostEndpointID,
 	) []*iptables.Chain
+	VRFSetEndpointMarkChains(epMarkMapper EndpointMarkMapper, wlIfaceVRFs map[string]string) []*iptables.Chain
+	VRFWorkloadDispatchChains(epMarkMapper EndpointMarkMapper, wlIfaceVRFs map[string]string) []*iptables.Chain
 
 	HostDispatchChains(map[string]proto.HostEndpointID, string, bool) []*iptables.Chain
 	FromHostDispatchChains(map[string]proto.HostEndpointID, string) []*iptables.Chain
@@ -226,6 +291,14 @@ type RuleRenderer interface {
 	BlockedCIDRsToIptablesChains(cidrs []string, ipVersion uint8) []*iptables.Chain
 
 	WireguardIncomingMarkChain() *iptables.Chain
+
+	InlineIPSetMatches(rules []iptables.Rule, ipVersion uint8, members IPSetMembersFunc) ([]iptables.Rule, []string)
+}
+
+// ConnectionLimits limits the connections from a workload; zero values mean unlimited.
+type ConnectionLimits struct {
+	MaxConnections          int
+	NewConnectionsPerSecond int
 }
 
 type DefaultRuleRenderer struct {
@@ -236,6 +309,24 @@ type DefaultRuleRenderer struct {
 	blockCIDRAction    iptables.Action
 }
 
+// DomainIPSetID returns the ID of the IP set that holds the addresses that the given (normalised)
+// destination domains have been seen to resolve to.  Rules with the same domains share a set.
+func DomainIPSetID(domains []string) string {
+	return hash.MakeUniqueID("d", strings.Join(domains, ","))
+}
+
+// ProxyNeighborIPSetID returns the ID of the IP set that holds the workloads selected by the
+// WorkloadProxyNeighbors rule with the given index.
+func ProxyNeighborIPSetID(index int) string {
+	return fmt.Sprintf("%s%d", IPSetIDProxyNeighborPrefix, index)
+}
+
+// ConntrackHelperIPSetID returns the ID of the IP set that holds the workloads selected by the
+// ConntrackHelpers rule with the given index.
+func ConntrackHelperIPSetID(index int) string {
+	return fmt.Sprintf("%s%d", IPSetIDConntrackHelperPrefix, index)
+}
+
 func (r *DefaultRuleRenderer) ipSetConfig(ipVersion uint8) *ipsets.IPVersionConfig {
 	if ipVersion == 4 {
 		return r.IPSetConfigV4
@@ -264,6 +355,14 @@ type Config struct {
 
 	KubeNodePortRanges     []numorstring.Port
 	KubeIPVSSupportEnabled bool
+	// VRFSupportEnabled makes us police workloads whose interfaces are enslaved to a VRF.  The
+	// filter table sees their packets on the VRF device, so we mark them with the workload's
+	// endpoint mark in the mangle table and dispatch on that mark.
+	VRFSupportEnabled bool
+	// KubernetesProfilelessMode renders the default allow of the Kubernetes namespace profiles
+	// directly into the endpoint chains of workloads that have only Kubernetes profiles, instead
+	// of jumping to the profiles' chains.
+	KubernetesProfilelessMode bool
 
 	OpenStackMetadataIP          net.IP
 	OpenStackMetadataPort        uint16
@@ -287,6 +386,7 @@ type Config struct {
 	WireguardInterfaceName string
 	WireguardIptablesMark  uint32
 	WireguardListeningPort int
+	WireguardDSCP          uint8
 	RouteSource            string
 
 	IptablesLogPrefix         string
@@ -297,15 +397,74 @@ type Config struct {
 	FailsafeInboundHostPorts  []config.ProtoPort
 	FailsafeOutboundHostPorts []config.ProtoPort
 
+	// FailsafeAuditEnabled causes host endpoint traffic that matches a failsafe port to be
+	// evaluated against policy first.  If policy would deny it, it is accepted anyway and sent to
+	// NFLOG group NFLOGFailsafeAuditGroup.  FailsafeAuditIptablesMark is used to remember that the
+	// packet matched a failsafe port.
+	FailsafeAuditEnabled      bool
+	FailsafeAuditIptablesMark uint32
+
+	// Cluster service allow rules for workload endpoints.  Workloads may reach the node-local
+	// DNS cache on ClusterServiceNodeLocalDNSAddrs; if ClusterServiceAllowKubeletProbes is set,
+	// the host may open TCP connections to workload IPs that it hasn't DNATed; if ClusterServiceKubeletAPIPort is non-zero, workloads may
+	// reach that port on the host.
+	ClusterServiceNodeLocalDNSAddrs  []string
+	ClusterServiceAllowKubeletProbes bool
+	ClusterServiceKubeletAPIPort     int
+
+	// RPFModeOverrides overrides the strict RPF check on matching workload interfaces.
+	RPFModeOverrides []config.RPFModeOverride
+
+	// MulticastEnabled allows IGMP and MLD from workloads to the host.
+	MulticastEnabled bool
+
+	// IptablesHookChains are user-owned chains that we jump to at fixed points in our static
+	// chains.
+	IptablesHookChains []config.HookChain
+
 	DisableConntrackInvalid bool
+	// SCTPMultihomingEnabled exempts SCTP heartbeats from the conntrack INVALID drop, since older
+	// kernels don't track the secondary paths of multihomed associations.
+	SCTPMultihomingEnabled bool
+	// GTPUNotrackPort, if non-zero, is the UDP port of GTP-U traffic that we exempt from
+	// conntrack in the raw table.
+	GTPUNotrackPort uint16
 
 	NATPortRange                       numorstring.Port
 	IptablesNATOutgoingInterfaceFilter string
 
 	NATOutgoingAddress net.IP
-	BPFEnabled         bool
+	// NATOutgoingExclusionsEnabled excludes the destinations in the IPSetIDNATOutgoingExclusions
+	// IP set from NAT outgoing.
+	NATOutgoingExclusionsEnabled bool
+	// NATOutgoingSourcePools SNATs the workloads in each pool's IP set to the pool's addresses
+	// instead of using the default NAT outgoing action.
+	NATOutgoingSourcePools []config.SNATSourcePool
+	// NATOutgoingPreservePorts renders the NAT outgoing actions without --random-fully.
+	NATOutgoingPreservePorts bool
+	// EgressGatewaySteering exempts steered traffic, which leaves over IPIP, from NAT outgoing so
+	// that the egress gateways see the workloads' IPs.
+	EgressGatewaySteering []config.EgressGatewayRule
+	BPFEnabled            bool
 
 	ServiceLoopPrevention string
+	// BlockedCIDRs are blocked, each with its own action, in ChainCIDRBlocklist, independently of
+	// the service CIDRs.  If CIDRBlocklistMetricsEnabled is set, blocked packets
+	// are also sent to NFLOG group NFLOGCIDRBlocklistGroup so that they can be counted.
+	BlockedCIDRs                []config.BlockedCIDR
+	CIDRBlocklistMetricsEnabled bool
+
+	// NAT64Prefixes are the /96 NAT64 prefixes; IPv4 CIDRs in policy rules are also rendered,
+	// mapped into each prefix, in the IPv6 rules.
+	NAT64Prefixes []string
+
+	// ConntrackHelpers attaches conntrack helpers to the connections of the workloads in each
+	// rule's IP set.
+	ConntrackHelpers []config.ConntrackHelperRule
+
+	// FlowLogsEnabled causes denied packets to be sent to NFLOG group NFLOGDenyGroup so that
+	// they can be included in flow logs.
+	FlowLogsEnabled bool
 }
 
 var unusedBitsInBPFMode = map[string]bool{
@@ -16,9 +16,11 @@ package rules
 
 import (
 	"fmt"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/projectcalico/felix/config"
 	. "github.com/projectcalico/felix/iptables"
 	"github.com/projectcalico/felix/proto"
 	cnet "github.com/projectcalico/libcalico-go/lib/net"
@@ -28,10 +30,14 @@ func (r *DefaultRuleRenderer) StaticFilterTableChains(ipVersion uint8) (chains [
 	chains = append(chains, r.StaticFilterForwardChains()...)
 	chains = append(chains, r.StaticFilterInputChains(ipVersion)...)
 	chains = append(chains, r.StaticFilterOutputChains(ipVersion)...)
+	chains = append(chains, r.StaticFilterClusterServiceChains(ipVersion)...)
+	chains = append(chains, r.failsafeAuditChains(ipVersion)...)
+	chains = append(chains, r.cidrBlocklistChains(ipVersion)...)
 	return
 }
 
 const (
+	ProtoIGMP   = 2
 	ProtoIPIP   = 4
 	ProtoTCP    = 6
 	ProtoUDP    = 17
@@ -51,6 +57,22 @@ func (r *DefaultRuleRenderer) StaticFilterInputChains(ipVersion uint8) []*Chain
 	return result
 }
 
+// hookChainJumps returns the rules that jump to the user's hook chains at the given point, in the
+// order that they were configured.
+func (r *DefaultRuleRenderer) hookChainJumps(point string) []Rule {
+	var rules []Rule
+	for _, hook := range r.IptablesHookChains {
+		if hook.Point != point {
+			continue
+		}
+		rules = append(rules, Rule{
+			Action:  JumpAction{Target: hook.Chain},
+			Comment: []string{"Jump to user hook chain"},
+		})
+	}
+	return rules
+}
+
 func (r *DefaultRuleRenderer) acceptAlreadyAccepted() []Rule {
 	return []Rule{
 		{
@@ -205,7 +227,7 @@ func (r *DefaultRuleRenderer) StaticFilterOutputForwardEndpointMarkChain() *Chai
 }
 
 func (r *DefaultRuleRenderer) filterInputChain(ipVersion uint8) *Chain {
-	var inputRules []Rule
+	inputRules := r.hookChainJumps(config.HookPointBeforePolicy)
 
 	if ipVersion == 4 && r.IPIPEnabled {
 		// IPIP is enabled, filter incoming IPIP packets to ensure they come from a
@@ -252,6 +274,15 @@ func (r *DefaultRuleRenderer) filterInputChain(ipVersion uint8) *Chain {
 	// Note that we do not need to do this filtering for wireguard because it already has the peering and allowed IPs
 	// baked into the crypto routing table.
 
+	if r.VRFSupportEnabled {
+		// Packets from workloads in a VRF arrive on the VRF device, marked with their workload's
+		// endpoint mark.  This must come before the IPVS check, which clears the mark.
+		inputRules = append(inputRules, Rule{
+			Match:  Match().MarkNotClear(r.IptablesMarkEndpoint),
+			Action: GotoAction{Target: ChainWorkloadToHost},
+		})
+	}
+
 	if r.KubeIPVSSupportEnabled {
 		// Check if packet belongs to forwarded traffic. (e.g. part of an ipvs connection).
 		// If it is, set endpoint mark and skip "to local host" rules below.
@@ -298,6 +329,7 @@ func (r *DefaultRuleRenderer) filterInputChain(ipVersion uint8) *Chain {
 			Comment: []string{"Host endpoint policy accepted packet."},
 		},
 	)
+	inputRules = append(inputRules, r.hookChainJumps(config.HookPointAfterPolicy)...)
 
 	return &Chain{
 		Name:  ChainFilterInput,
@@ -333,6 +365,26 @@ func (r *DefaultRuleRenderer) filterWorkloadToHostChain(ipVersion uint8) *Chain
 		}
 	}
 
+	if r.MulticastEnabled {
+		// Workloads join multicast groups by sending IGMP or, for IPv6, MLD (the MLDv1 types
+		// are allowed above, 143 is the MLDv2 report) to the host.
+		if ipVersion == 4 {
+			rules = append(rules, Rule{
+				Match:   Match().ProtocolNum(ProtoIGMP),
+				Action:  r.filterAllowAction,
+				Comment: []string{"Allow IGMP from workloads"},
+			})
+		} else {
+			rules = append(rules, Rule{
+				Match: Match().
+					ProtocolNum(ProtoICMPv6).
+					ICMPV6Type(143),
+				Action:  r.filterAllowAction,
+				Comment: []string{"Allow MLDv2 reports from workloads"},
+			})
+		}
+	}
+
 	if r.OpenStackSpecialCasesEnabled {
 		log.Info("Adding OpenStack special-case rules.")
 		if ipVersion == 4 && r.OpenStackMetadataIP != nil {
@@ -401,30 +453,14 @@ func (r *DefaultRuleRenderer) filterWorkloadToHostChain(ipVersion uint8) *Chain
 }
 
 func (r *DefaultRuleRenderer) failsafeInChain(table string, ipVersion uint8) *Chain {
-	rules := []Rule{}
+	rules := r.failsafeAuditPreamble(table)
+	action := r.failsafeAction(table)
 
 	for _, protoPort := range r.Config.FailsafeInboundHostPorts {
-		rule := Rule{
-			Match: Match().
-				Protocol(protoPort.Protocol).
-				DestPorts(protoPort.Port),
-			Action: AcceptAction{},
-		}
-
-		if protoPort.Net != "" {
-			ip, _, err := cnet.ParseCIDROrIP(protoPort.Net)
-			if err != nil {
-				log.WithError(err).Error("Failed to parse CIDR in inbound failsafe rule. Skipping failsafe rule")
-				continue
-			}
-			if int(ipVersion) == ip.Version() {
-				rule.Match = Match().
-					Protocol(protoPort.Protocol).
-					DestPorts(protoPort.Port).
-					SourceNet(protoPort.Net)
-			}
+		if match, ok := failsafeMatch(protoPort, ipVersion,
+			Match().Protocol(protoPort.Protocol).DestPorts(protoPort.Port), false, false); ok {
+			rules = append(rules, Rule{Match: match, Action: action})
 		}
-		rules = append(rules, rule)
 	}
 
 	if table == "raw" {
@@ -433,27 +469,10 @@ func (r *DefaultRuleRenderer) failsafeInChain(table string, ipVersion uint8) *Ch
 		// would get untracked.  If we ACCEPT here then the traffic falls through to the filter
 		// table, where it'll only be accepted if there's a conntrack entry.
 		for _, protoPort := range r.Config.FailsafeOutboundHostPorts {
-			rule := Rule{
-				Match: Match().
-					Protocol(protoPort.Protocol).
-					SourcePorts(protoPort.Port),
-				Action: AcceptAction{},
-			}
-
-			if protoPort.Net != "" {
-				ip, _, err := cnet.ParseCIDROrIP(protoPort.Net)
-				if err != nil {
-					log.WithError(err).Error("Failed to parse CIDR in inbound failsafe rule. Skipping failsafe rule")
-					continue
-				}
-				if int(ipVersion) == ip.Version() {
-					rule.Match = Match().
-						Protocol(protoPort.Protocol).
-						SourcePorts(protoPort.Port).
-						SourceNet(protoPort.Net)
-				}
+			if match, ok := failsafeMatch(protoPort, ipVersion,
+				Match().Protocol(protoPort.Protocol).SourcePorts(protoPort.Port), false, false); ok {
+				rules = append(rules, Rule{Match: match, Action: AcceptAction{}})
 			}
-			rules = append(rules, rule)
 		}
 	}
 
@@ -464,30 +483,14 @@ func (r *DefaultRuleRenderer) failsafeInChain(table string, ipVersion uint8) *Ch
 }
 
 func (r *DefaultRuleRenderer) failsafeOutChain(table string, ipVersion uint8) *Chain {
-	rules := []Rule{}
+	rules := r.failsafeAuditPreamble(table)
+	action := r.failsafeAction(table)
 
 	for _, protoPort := range r.Config.FailsafeOutboundHostPorts {
-		rule := Rule{
-			Match: Match().
-				Protocol(protoPort.Protocol).
-				DestPorts(protoPort.Port),
-			Action: AcceptAction{},
+		if match, ok := failsafeMatch(protoPort, ipVersion,
+			Match().Protocol(protoPort.Protocol).DestPorts(protoPort.Port), true, true); ok {
+			rules = append(rules, Rule{Match: match, Action: action})
 		}
-
-		if protoPort.Net != "" {
-			ip, _, err := cnet.ParseCIDROrIP(protoPort.Net)
-			if err != nil {
-				log.WithError(err).Error("Failed to parse CIDR in outbound failsafe rule. Skipping failsafe rule")
-				continue
-			}
-			if int(ipVersion) == ip.Version() {
-				rule.Match = Match().
-					Protocol(protoPort.Protocol).
-					DestPorts(protoPort.Port).
-					DestNet(protoPort.Net)
-			}
-		}
-		rules = append(rules, rule)
 	}
 
 	if table == "raw" {
@@ -496,27 +499,10 @@ func (r *DefaultRuleRenderer) failsafeOutChain(table string, ipVersion uint8) *C
 		// would get untracked.  If we ACCEPT here then the traffic falls through to the filter
 		// table, where it'll only be accepted if there's a conntrack entry.
 		for _, protoPort := range r.Config.FailsafeInboundHostPorts {
-			rule := Rule{
-				Match: Match().
-					Protocol(protoPort.Protocol).
-					SourcePorts(protoPort.Port),
-				Action: AcceptAction{},
-			}
-
-			if protoPort.Net != "" {
-				ip, _, err := cnet.ParseCIDROrIP(protoPort.Net)
-				if err != nil {
-					log.WithError(err).Error("Failed to parse CIDR in outbound failsafe rule. Skipping failsafe rule")
-					continue
-				}
-				if int(ipVersion) == ip.Version() {
-					rule.Match = Match().
-						Protocol(protoPort.Protocol).
-						SourcePorts(protoPort.Port).
-						SourceNet(protoPort.Net)
-				}
+			if match, ok := failsafeMatch(protoPort, ipVersion,
+				Match().Protocol(protoPort.Protocol).SourcePorts(protoPort.Port), false, true); ok {
+				rules = append(rules, Rule{Match: match, Action: AcceptAction{}})
 			}
-			rules = append(rules, rule)
 		}
 	}
 
@@ -526,9 +512,194 @@ func (r *DefaultRuleRenderer) failsafeOutChain(table string, ipVersion uint8) *C
 	}
 }
 
+// failsafeAuditing returns true if the failsafe chains for the given table should mark, rather
+// than accept, failsafe traffic.  Only the filter table is audited; the raw and mangle tables
+// can't drop traffic that the filter table would accept so they keep accepting failsafe traffic.
+func (r *DefaultRuleRenderer) failsafeAuditing(table string) bool {
+	return r.FailsafeAuditEnabled && table == "filter"
+}
+
+func (r *DefaultRuleRenderer) failsafeAuditPreamble(table string) []Rule {
+	if !r.failsafeAuditing(table) {
+		return []Rule{}
+	}
+	return []Rule{{
+		Action: ClearMarkAction{Mark: r.FailsafeAuditIptablesMark},
+	}}
+}
+
+func (r *DefaultRuleRenderer) failsafeAction(table string) Action {
+	if r.failsafeAuditing(table) {
+		// Mark the packet and let policy decide; the endpoint and policy chains jump to the
+		// audit chains instead of dropping marked packets.
+		return SetMarkAction{Mark: r.FailsafeAuditIptablesMark}
+	}
+	return AcceptAction{}
+}
+
+// failsafeAuditChains renders the chains that policy jumps to, instead of dropping, packets that
+// were marked by the failsafe chains.  Each failsafe port gets an NFLOG rule so that the audited
+// traffic can be counted per port.  The chains are referenced from policy chains, which may be
+// rendered into any table, so they are included in the static chains of all tables.
+func (r *DefaultRuleRenderer) failsafeAuditChains(ipVersion uint8) []*Chain {
+	if !r.FailsafeAuditEnabled {
+		return nil
+	}
+	auditChain := func(name, dir string, protoPorts []config.ProtoPort, outbound bool) *Chain {
+		rules := []Rule{}
+		for i, protoPort := range protoPorts {
+			match, ok := failsafeMatch(protoPort, ipVersion,
+				Match().Protocol(protoPort.Protocol).DestPorts(protoPort.Port), outbound, outbound)
+			if !ok {
+				continue
+			}
+			rules = append(rules, Rule{
+				Match: match,
+				Action: NflogAction{
+					Group:  NFLOGFailsafeAuditGroup,
+					Prefix: fmt.Sprintf("%s%s|%d", NFLOGFailsafeAuditPrefix, dir, i),
+				},
+			})
+		}
+		// Whether or not we logged it, the packet matched a failsafe port so it must be accepted.
+		rules = append(rules, Rule{
+			Action:  AcceptAction{},
+			Comment: []string{"Accept failsafe traffic that policy would deny"},
+		})
+		return &Chain{Name: name, Rules: rules}
+	}
+	return []*Chain{
+		auditChain(ChainFailsafeInAudit, NFLOGDirInbound, r.Config.FailsafeInboundHostPorts, false),
+		auditChain(ChainFailsafeOutAudit, NFLOGDirOutbound, r.Config.FailsafeOutboundHostPorts, true),
+	}
+}
+
+// failsafeMatch adds the CIDR and interface of the given failsafe port to match, which should
+// already match the protocol and port.  The CIDR, if any, is matched as a destination if destNet
+// is set (otherwise as a source) and the interface, if any, is matched as an output interface if
+// outbound is set (otherwise as an input interface).
+//
+// For backwards compatibility, a CIDR of the other IP version is ignored, so the entry opens the
+// port to all addresses of this IP version.  That keeps hosts reachable over IPv6 when only an IPv4
+// management network is configured, say.  failsafeMatch only returns false if the CIDR is invalid.
+func failsafeMatch(
+	protoPort config.ProtoPort,
+	ipVersion uint8,
+	match MatchCriteria,
+	destNet bool,
+	outbound bool,
+) (MatchCriteria, bool) {
+	if protoPort.Net != "" {
+		ip, _, err := cnet.ParseCIDROrIP(protoPort.Net)
+		if err != nil {
+			log.WithError(err).WithField("net", protoPort.Net).Error(
+				"Failed to parse CIDR in failsafe rule. Skipping failsafe rule")
+			return nil, false
+		}
+		if int(ipVersion) == ip.Version() {
+			if destNet {
+				match = match.DestNet(protoPort.Net)
+			} else {
+				match = match.SourceNet(protoPort.Net)
+			}
+		}
+	}
+	if protoPort.Interface != "" {
+		if outbound {
+			match = match.OutInterface(protoPort.Interface)
+		} else {
+			match = match.InInterface(protoPort.Interface)
+		}
+	}
+	return match, true
+}
+
+// clusterServicesToWlChainName returns the name of the chain of cluster service allow rules for
+// traffic to workloads, or "" if there are no such rules.
+func (r *DefaultRuleRenderer) clusterServicesToWlChainName() string {
+	if r.Config.ClusterServiceAllowKubeletProbes {
+		return ChainClusterServicesToWl
+	}
+	return ""
+}
+
+// clusterServicesFromWlChainName returns the name of the chain of cluster service allow rules
+// for traffic from workloads, or "" if there are no such rules.
+func (r *DefaultRuleRenderer) clusterServicesFromWlChainName() string {
+	if len(r.Config.ClusterServiceNodeLocalDNSAddrs) > 0 || r.Config.ClusterServiceKubeletAPIPort != 0 {
+		return ChainClusterServicesFromWl
+	}
+	return ""
+}
+
+// StaticFilterClusterServiceChains renders the cluster service allow rules, which the workload
+// endpoint chains jump to ahead of policy, in the same way that host endpoint chains jump to
+// the failsafe chains.  A chain is rendered (possibly empty) for both IP versions if it is
+// enabled, since the endpoint chains refer to it regardless of IP version.
+func (r *DefaultRuleRenderer) StaticFilterClusterServiceChains(ipVersion uint8) []*Chain {
+	var chains []*Chain
+	if r.clusterServicesToWlChainName() != "" {
+		chains = append(chains, &Chain{
+			Name: ChainClusterServicesToWl,
+			// Kubelet probes are TCP connections from the host straight to the pod IP.  Other
+			// traffic from the host, such as NodePort or service traffic, has been DNATed, so it
+			// still goes through policy.
+			Rules: []Rule{{
+				Match: Match().
+					Protocol("tcp").
+					SrcAddrType(AddrTypeLocal, false).
+					NotConntrackState("DNAT"),
+				Action:  AcceptAction{},
+				Comment: []string{"Allow kubelet probes from the host"},
+			}},
+		})
+	}
+	if r.clusterServicesFromWlChainName() != "" {
+		rules := []Rule{}
+		for _, addr := range r.Config.ClusterServiceNodeLocalDNSAddrs {
+			ip, _, err := cnet.ParseCIDROrIP(addr)
+			if err != nil {
+				log.WithError(err).Error("Failed to parse node-local DNS address. Skipping rule")
+				continue
+			}
+			if int(ipVersion) != ip.Version() {
+				continue
+			}
+			for _, protocol := range []string{"udp", "tcp"} {
+				rules = append(rules, Rule{
+					Match: Match().
+						Protocol(protocol).
+						DestNet(addr).
+						DestPorts(53),
+					Action:  AcceptAction{},
+					Comment: []string{"Allow node-local DNS"},
+				})
+			}
+		}
+		if r.Config.ClusterServiceKubeletAPIPort != 0 {
+			rules = append(rules, Rule{
+				Match: Match().
+					Protocol("tcp").
+					DestAddrType(AddrTypeLocal).
+					DestPorts(uint16(r.Config.ClusterServiceKubeletAPIPort)),
+				Action:  AcceptAction{},
+				Comment: []string{"Allow kubelet API on the host"},
+			})
+		}
+		chains = append(chains, &Chain{
+			Name:  ChainClusterServicesFromWl,
+			Rules: rules,
+		})
+	}
+	return chains
+}
+
 func (r *DefaultRuleRenderer) StaticFilterForwardChains() []*Chain {
 	rules := []Rule{}
 
+	// The user's hook chains see the packet before we touch it.
+	rules = append(rules, r.hookChainJumps(config.HookPointBeforePolicy)...)
+
 	// Rules for filter forward chains dispatches the packet to our dispatch chains if it is going
 	// to/from an interface that we're responsible for.  Note: the dispatch chains represent "allow"
 	// by returning to this chain for further processing; this is required to handle traffic that
@@ -553,6 +724,21 @@ func (r *DefaultRuleRenderer) StaticFilterForwardChains() []*Chain {
 		},
 	)
 
+	if r.VRFSupportEnabled {
+		// Packets from workloads in a VRF arrive on the VRF device, marked with their workload's
+		// endpoint mark.  Clear the mark afterwards so that it doesn't leak into encapsulated
+		// packets.
+		rules = append(rules,
+			Rule{
+				Match:  Match().MarkNotClear(r.IptablesMarkEndpoint),
+				Action: JumpAction{Target: ChainFromWorkloadDispatch},
+			},
+			Rule{
+				Action: ClearMarkAction{Mark: r.IptablesMarkEndpoint},
+			},
+		)
+	}
+
 	// Jump to workload dispatch chains.
 	for _, prefix := range r.WorkloadIfacePrefixes {
 		log.WithField("ifacePrefix", prefix).Debug("Adding workload match rules")
@@ -583,6 +769,8 @@ func (r *DefaultRuleRenderer) StaticFilterForwardChains() []*Chain {
 			Action: JumpAction{Target: ChainCIDRBlock},
 		},
 	)
+	rules = append(rules, r.cidrBlocklistJump()...)
+	rules = append(rules, r.hookChainJumps(config.HookPointAfterPolicy)...)
 
 	return []*Chain{{
 		Name:  ChainFilterForward,
@@ -623,7 +811,10 @@ func (r *DefaultRuleRenderer) StaticFilterOutputChains(ipVersion uint8) []*Chain
 }
 
 func (r *DefaultRuleRenderer) filterOutputChain(ipVersion uint8) *Chain {
-	var rules []Rule
+	rules := r.hookChainJumps(config.HookPointBeforePolicy)
+
+	// Block host-originated traffic to the blocklisted CIDRs, before anything can accept it.
+	rules = append(rules, r.cidrBlocklistJump()...)
 
 	// Accept immediately if we've already accepted this packet in the raw or mangle table.
 	rules = append(rules, r.acceptAlreadyAccepted()...)
@@ -721,6 +912,7 @@ func (r *DefaultRuleRenderer) filterOutputChain(ipVersion uint8) *Chain {
 			Comment: []string{"Host endpoint policy accepted packet."},
 		},
 	)
+	rules = append(rules, r.hookChainJumps(config.HookPointAfterPolicy)...)
 
 	return &Chain{
 		Name:  ChainFilterOutput,
@@ -736,11 +928,10 @@ func (r *DefaultRuleRenderer) StaticNATTableChains(ipVersion uint8) (chains []*C
 }
 
 func (r *DefaultRuleRenderer) StaticNATPreroutingChains(ipVersion uint8) []*Chain {
-	rules := []Rule{
-		{
-			Action: JumpAction{Target: ChainFIPDnat},
-		},
-	}
+	rules := r.hookChainJumps(config.HookPointBeforeNAT)
+	rules = append(rules, Rule{
+		Action: JumpAction{Target: ChainFIPDnat},
+	})
 
 	if ipVersion == 4 && r.OpenStackSpecialCasesEnabled && r.OpenStackMetadataIP != nil {
 		rules = append(rules, Rule{
@@ -764,14 +955,15 @@ func (r *DefaultRuleRenderer) StaticNATPreroutingChains(ipVersion uint8) []*Chai
 }
 
 func (r *DefaultRuleRenderer) StaticNATPostroutingChains(ipVersion uint8) []*Chain {
-	rules := []Rule{
-		{
+	rules := r.hookChainJumps(config.HookPointBeforeNAT)
+	rules = append(rules,
+		Rule{
 			Action: JumpAction{Target: ChainFIPSnat},
 		},
-		{
+		Rule{
 			Action: JumpAction{Target: ChainNATOutgoing},
 		},
-	}
+	)
 
 	var tunnelIfaces []string
 
@@ -827,11 +1019,10 @@ func (r *DefaultRuleRenderer) StaticNATPostroutingChains(ipVersion uint8) []*Cha
 }
 
 func (r *DefaultRuleRenderer) StaticNATOutputChains(ipVersion uint8) []*Chain {
-	rules := []Rule{
-		{
-			Action: JumpAction{Target: ChainFIPDnat},
-		},
-	}
+	rules := r.hookChainJumps(config.HookPointBeforeNAT)
+	rules = append(rules, Rule{
+		Action: JumpAction{Target: ChainFIPDnat},
+	})
 
 	return []*Chain{{
 		Name:  ChainNATOutput,
@@ -848,6 +1039,7 @@ func (r *DefaultRuleRenderer) StaticMangleTableChains(ipVersion uint8) []*Chain
 		r.StaticManglePreroutingChain(ipVersion),
 		r.StaticManglePostroutingChain(ipVersion),
 	)
+	chains = append(chains, r.failsafeAuditChains(ipVersion)...)
 
 	return chains
 }
@@ -855,6 +1047,15 @@ func (r *DefaultRuleRenderer) StaticMangleTableChains(ipVersion uint8) []*Chain
 func (r *DefaultRuleRenderer) StaticManglePreroutingChain(ipVersion uint8) *Chain {
 	rules := []Rule{}
 
+	if r.VRFSupportEnabled {
+		// Mark packets from workloads in a VRF with their endpoint mark while we can still see
+		// their workload interface; the filter table only sees the VRF device.  This must come
+		// before the accept rules below.
+		rules = append(rules, Rule{
+			Action: JumpAction{Target: ChainSetVRFEndpointMark},
+		})
+	}
+
 	// ACCEPT or RETURN immediately if packet matches an existing connection.  Note that we also
 	// have a rule like this at the start of each pre-endpoint chain; the functional difference
 	// with placing this rule here is that it will also apply to packets that may be unrelated
@@ -908,6 +1109,16 @@ func (r *DefaultRuleRenderer) StaticManglePreroutingChain(ipVersion uint8) *Chai
 func (r *DefaultRuleRenderer) StaticManglePostroutingChain(ipVersion uint8) *Chain {
 	rules := []Rule{}
 
+	// Mark outgoing WireGuard packets with the configured DSCP.  The kernel can't inherit it from
+	// the inner packet, which is encrypted.  This rule doesn't terminate the chain; WireGuard
+	// packets carry on through the host endpoint checks below.
+	if ipVersion == 4 && r.WireguardEnabled && r.WireguardDSCP != 0 {
+		rules = append(rules, Rule{
+			Match:  Match().ProtocolNum(ProtoUDP).SourcePorts(uint16(r.WireguardListeningPort)),
+			Action: SetDSCPAction{Value: r.WireguardDSCP},
+		})
+	}
+
 	// Note, we use RETURN as the Allow action in this chain, rather than ACCEPT because the
 	// mangle table is typically used, if at all, for packet manipulations that might need to
 	// apply to our allowed traffic.
@@ -977,13 +1188,14 @@ func (r *DefaultRuleRenderer) StaticManglePostroutingChain(ipVersion uint8) *Cha
 }
 
 func (r *DefaultRuleRenderer) StaticRawTableChains(ipVersion uint8) []*Chain {
-	return []*Chain{
+	chains := []*Chain{
 		r.failsafeInChain("raw", ipVersion),
 		r.failsafeOutChain("raw", ipVersion),
 		r.StaticRawPreroutingChain(ipVersion),
 		r.WireguardIncomingMarkChain(),
-		r.StaticRawOutputChain(),
+		r.StaticRawOutputChain(ipVersion),
 	}
+	return append(chains, r.failsafeAuditChains(ipVersion)...)
 }
 
 func (r *DefaultRuleRenderer) StaticRawPreroutingChain(ipVersion uint8) *Chain {
@@ -1017,8 +1229,22 @@ func (r *DefaultRuleRenderer) StaticRawPreroutingChain(ipVersion uint8) *Chain {
 	// workloads from spoofing their IPs.  Note: non-privileged containers can't
 	// usually spoof but privileged containers and VMs can.
 	//
-	rules = append(rules,
-		RPFilter(ipVersion, markFromWorkload, markFromWorkload, r.OpenStackSpecialCasesEnabled, false)...)
+	if overrides := r.workloadRPFModeOverrides(); len(overrides) == 0 {
+		rules = append(rules,
+			RPFilter(ipVersion, markFromWorkload, markFromWorkload, r.OpenStackSpecialCasesEnabled, false)...)
+	} else {
+		// The user has overridden the RPF mode for some workload interfaces.  Apply the
+		// first matching override and then use markRPFDone to skip the default strict check.
+		markRPFDone := r.IptablesMarkScratch1
+		rules = append(rules, rpfSpecialCaseRules(ipVersion, r.OpenStackSpecialCasesEnabled)...)
+		rules = append(rules, rpfOverrideRules(overrides, markFromWorkload, markRPFDone)...)
+		rules = append(rules,
+			RPFilter(ipVersion, markFromWorkload, markFromWorkload|markRPFDone, false, false)...)
+		rules = append(rules, Rule{Action: ClearMarkAction{Mark: markRPFDone}})
+	}
+
+	rules = append(rules, r.gtpuNotrackRules()...)
+	rules = append(rules, r.conntrackHelperRules(ipVersion)...)
 
 	rules = append(rules,
 		// Send non-workload traffic to the untracked policy chains.
@@ -1040,6 +1266,64 @@ func (r *DefaultRuleRenderer) StaticRawPreroutingChain(ipVersion uint8) *Chain {
 // RPFilter returns rules that implement RPF
 func RPFilter(ipVersion uint8, mark, mask uint32, openStackSpecialCasesEnabled, acceptLocal bool) []Rule {
 	rules := make([]Rule, 0, 2)
+	rules = append(rules, rpfSpecialCaseRules(ipVersion, openStackSpecialCasesEnabled)...)
+	rules = append(rules, Rule{
+		Match:  Match().MarkMatchesWithMask(mark, mask).RPFCheckFailed(acceptLocal),
+		Action: DropAction{},
+	})
+
+	return rules
+}
+
+// workloadRPFModeOverrides returns the RPF mode overrides that may match workload interfaces.
+// Host interfaces are handled via the rp_filter sysctl instead.
+func (r *DefaultRuleRenderer) workloadRPFModeOverrides() (overrides []config.RPFModeOverride) {
+	for _, o := range r.RPFModeOverrides {
+		stem := strings.TrimSuffix(o.InterfacePattern, "+")
+		isPrefix := stem != o.InterfacePattern
+		for _, ifacePrefix := range r.WorkloadIfacePrefixes {
+			if strings.HasPrefix(stem, ifacePrefix) || (isPrefix && strings.HasPrefix(ifacePrefix, stem)) {
+				overrides = append(overrides, o)
+				break
+			}
+		}
+	}
+	return
+}
+
+// rpfOverrideRules returns rules that apply the first matching RPF mode override to packets from
+// workload interfaces.  Packets that have been handled are marked with markRPFDone.
+func rpfOverrideRules(overrides []config.RPFModeOverride, markFromWorkload, markRPFDone uint32) []Rule {
+	var rules []Rule
+	for _, o := range overrides {
+		notDone := func() MatchCriteria {
+			return Match().InInterface(o.InterfacePattern).
+				MarkMatchesWithMask(markFromWorkload, markFromWorkload|markRPFDone)
+		}
+		switch o.Mode {
+		case config.RPFModeStrict:
+			rules = append(rules, Rule{
+				Match:  notDone().RPFCheckFailed(false),
+				Action: DropAction{},
+			})
+		case config.RPFModeLoose:
+			rules = append(rules, Rule{
+				Match:  notDone().RPFCheckFailedLoose(),
+				Action: DropAction{},
+			})
+		}
+		rules = append(rules, Rule{
+			Match:  notDone(),
+			Action: SetMarkAction{Mark: markRPFDone},
+		})
+	}
+	return rules
+}
+
+// rpfSpecialCaseRules returns the rules that accept packets that would otherwise be dropped by the
+// RPF check.
+func rpfSpecialCaseRules(ipVersion uint8, openStackSpecialCasesEnabled bool) []Rule {
+	var rules []Rule
 
 	// For OpenStack, allow DHCP v4 packets with source 0.0.0.0.  These must be allowed before
 	// checking against the iptables rp_filter module, because the rp_filter module in some
@@ -1073,11 +1357,6 @@ func RPFilter(ipVersion uint8, mark, mask uint32, openStackSpecialCasesEnabled,
 		)
 	}
 
-	rules = append(rules, Rule{
-		Match:  Match().MarkMatchesWithMask(mark, mask).RPFCheckFailed(acceptLocal),
-		Action: DropAction{},
-	})
-
 	return rules
 }
 
@@ -1115,20 +1394,61 @@ func (r *DefaultRuleRenderer) WireguardIncomingMarkChain() *Chain {
 	}
 }
 
-func (r *DefaultRuleRenderer) StaticRawOutputChain() *Chain {
+func (r *DefaultRuleRenderer) StaticRawOutputChain(ipVersion uint8) *Chain {
+	// For safety, clear all our mark bits before we start.  (We could be in
+	// append mode and another process' rules could have left the mark bit set.)
+	rules := []Rule{{Action: ClearMarkAction{Mark: r.allCalicoMarkBits()}}}
+	rules = append(rules, r.gtpuNotrackRules()...)
+	rules = append(rules, r.conntrackHelperRules(ipVersion)...)
+	rules = append(rules,
+		// Then, jump to the untracked policy chains.
+		Rule{Action: JumpAction{Target: ChainDispatchToHostEndpoint}},
+		// Then, if the packet was marked as allowed, accept it.  Packets also
+		// return here without the mark bit set if the interface wasn't one that
+		// we're policing.
+		Rule{Match: Match().MarkSingleBitSet(r.IptablesMarkAccept),
+			Action: AcceptAction{}},
+	)
 	return &Chain{
-		Name: ChainRawOutput,
-		Rules: []Rule{
-			// For safety, clear all our mark bits before we start.  (We could be in
-			// append mode and another process' rules could have left the mark bit set.)
-			{Action: ClearMarkAction{Mark: r.allCalicoMarkBits()}},
-			// Then, jump to the untracked policy chains.
-			{Action: JumpAction{Target: ChainDispatchToHostEndpoint}},
-			// Then, if the packet was marked as allowed, accept it.  Packets also
-			// return here without the mark bit set if the interface wasn't one that
-			// we're policing.
-			{Match: Match().MarkSingleBitSet(r.IptablesMarkAccept),
-				Action: AcceptAction{}},
-		},
+		Name:  ChainRawOutput,
+		Rules: rules,
 	}
 }
+
+// gtpuNotrackRules returns the rules that exempt GTP-U traffic from conntrack.  Both ends of a
+// GTP-U tunnel send to the GTP-U port so one rule covers both directions.  Untracked packets skip
+// the nat table, so GTP-U doesn't get service DNAT or NAT outgoing.
+func (r *DefaultRuleRenderer) gtpuNotrackRules() []Rule {
+	if r.GTPUNotrackPort == 0 {
+		return nil
+	}
+	return []Rule{{
+		Match:   Match().Protocol("udp").DestPorts(r.GTPUNotrackPort),
+		Action:  NoTrackAction{},
+		Comment: []string{"Don't track GTP-U"},
+	}}
+}
+
+// conntrackHelperRules returns the rules that attach the configured conntrack helpers to
+// connections to and from the selected workloads.  The CT target only acts on the first packet of
+// a connection, before conntrack has seen it, so there's no need to match on the state.
+func (r *DefaultRuleRenderer) conntrackHelperRules(ipVersion uint8) []Rule {
+	var rules []Rule
+	ipConf := r.ipSetConfig(ipVersion)
+	for i, helperRule := range r.ConntrackHelpers {
+		setName := ipConf.NameForMainIPSet(ConntrackHelperIPSetID(i))
+		for _, helper := range helperRule.Helpers {
+			for _, match := range []MatchCriteria{
+				Match().SourceIPSet(setName),
+				Match().DestIPSet(setName),
+			} {
+				rules = append(rules, Rule{
+					Match:   match.Protocol(helper.Protocol).DestPorts(uint16(helper.Port)),
+					Action:  CTHelperAction{Helper: helper.Name},
+					Comment: []string{fmt.Sprintf("Attach %s conntrack helper", helper.Name)},
+				})
+			}
+		}
+	}
+	return rules
+}
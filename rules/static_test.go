@@ -1357,6 +1357,66 @@ var _ = Describe("Static", func() {
 		}
 	})
 
+	Describe("with RPF mode overrides", func() {
+		BeforeEach(func() {
+			conf = Config{
+				WorkloadIfacePrefixes:       []string{"cali", "tap"},
+				IPSetConfigV4:               ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+				IPSetConfigV6:               ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+				IptablesMarkAccept:          0x10,
+				IptablesMarkPass:            0x20,
+				IptablesMarkScratch0:        0x40,
+				IptablesMarkScratch1:        0x80,
+				IptablesMarkEndpoint:        0xff00,
+				IptablesMarkNonCaliEndpoint: 0x100,
+				RPFModeOverrides: []config.RPFModeOverride{
+					{InterfacePattern: "eth1", Mode: "Loose"},
+					{InterfacePattern: "cali1234", Mode: "Strict"},
+					{InterfacePattern: "cali+", Mode: "Loose"},
+					{InterfacePattern: "ta+", Mode: "Disabled"},
+				},
+			}
+		})
+
+		It("should apply the overrides to workload interfaces", func() {
+			Expect(findChain(rr.StaticRawTableChains(4), "cali-PREROUTING")).To(Equal(&Chain{
+				Name: "cali-PREROUTING",
+				Rules: []Rule{
+					{Action: ClearMarkAction{Mark: 0xf0}},
+					{Match: Match().InInterface("cali+"),
+						Action: SetMarkAction{Mark: 0x40}},
+					{Match: Match().InInterface("tap+"),
+						Action: SetMarkAction{Mark: 0x40}},
+					{Match: Match().InInterface("cali1234").MarkMatchesWithMask(0x40, 0xc0).RPFCheckFailed(false),
+						Action: DropAction{}},
+					{Match: Match().InInterface("cali1234").MarkMatchesWithMask(0x40, 0xc0),
+						Action: SetMarkAction{Mark: 0x80}},
+					{Match: Match().InInterface("cali+").MarkMatchesWithMask(0x40, 0xc0).RPFCheckFailedLoose(),
+						Action: DropAction{}},
+					{Match: Match().InInterface("cali+").MarkMatchesWithMask(0x40, 0xc0),
+						Action: SetMarkAction{Mark: 0x80}},
+					{Match: Match().InInterface("ta+").MarkMatchesWithMask(0x40, 0xc0),
+						Action: SetMarkAction{Mark: 0x80}},
+					{Match: Match().MarkMatchesWithMask(0x40, 0xc0).RPFCheckFailed(false),
+						Action: DropAction{}},
+					{Action: ClearMarkAction{Mark: 0x80}},
+					{Match: Match().MarkClear(0x40),
+						Action: JumpAction{Target: ChainDispatchFromHostEndpoint}},
+					{Match: Match().MarkSingleBitSet(0x10),
+						Action: AcceptAction{}},
+				},
+			}))
+		})
+
+		It("should render the default chain if no overrides match workload interfaces", func() {
+			conf.RPFModeOverrides = []config.RPFModeOverride{{InterfacePattern: "eth+", Mode: "Loose"}}
+			rr = NewRenderer(conf).(*DefaultRuleRenderer)
+			Expect(findChain(rr.StaticRawTableChains(4), "cali-PREROUTING").Rules).To(ContainElement(
+				Rule{Match: Match().MarkSingleBitSet(0x40).RPFCheckFailed(false), Action: DropAction{}},
+			))
+		})
+	})
+
 	Describe("with WireGuard enabled", func() {
 		BeforeEach(func() {
 			conf = Config{
@@ -1411,6 +1471,30 @@ var _ = Describe("Static", func() {
 				},
 			}))
 		})
+
+		It("should not set the DSCP of WireGuard packets by default", func() {
+			Expect(rr.StaticManglePostroutingChain(ipVersion).Rules[0]).To(Equal(Rule{
+				Match:  Match().MarkSingleBitSet(0x10),
+				Action: ReturnAction{},
+			}))
+		})
+
+		Describe("with a WireGuard DSCP", func() {
+			BeforeEach(func() {
+				conf.WireguardDSCP = 46
+			})
+
+			It("should set the DSCP of outgoing WireGuard packets", func() {
+				Expect(rr.StaticManglePostroutingChain(ipVersion).Rules[0]).To(Equal(Rule{
+					Match:  Match().ProtocolNum(17).SourcePorts(51820),
+					Action: SetDSCPAction{Value: 46},
+				}))
+			})
+
+			It("should not set the DSCP for IPv6", func() {
+				Expect(rr.StaticManglePostroutingChain(6).Rules[0].Action).To(Equal(ReturnAction{}))
+			})
+		})
 	})
 })
 
@@ -1422,3 +1506,461 @@ func findChain(chains []*Chain, name string) *Chain {
 	}
 	return nil
 }
+
+var _ = Describe("Cluster service rules", func() {
+	var rrConfig Config
+
+	BeforeEach(func() {
+		rrConfig = Config{
+			IPSetConfigV4:        ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+			IPSetConfigV6:        ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+			IptablesMarkAccept:   0x10,
+			IptablesMarkPass:     0x20,
+			IptablesMarkScratch0: 0x40,
+			IptablesMarkScratch1: 0x80,
+			IptablesMarkEndpoint: 0xff00,
+		}
+	})
+
+	It("should render nothing by default", func() {
+		renderer := NewRenderer(rrConfig)
+		Expect(renderer.(*DefaultRuleRenderer).StaticFilterClusterServiceChains(4)).To(BeEmpty())
+		wlChains := renderer.WorkloadEndpointToIptablesChains("cali1234", nil, true, nil, nil, nil, ConnectionLimits{})
+		for _, c := range wlChains {
+			for _, r := range c.Rules {
+				Expect(r.Action).NotTo(Equal(JumpAction{Target: ChainClusterServicesToWl}))
+				Expect(r.Action).NotTo(Equal(JumpAction{Target: ChainClusterServicesFromWl}))
+			}
+		}
+	})
+
+	Describe("with all rules enabled", func() {
+		var renderer RuleRenderer
+
+		BeforeEach(func() {
+			rrConfig.ClusterServiceNodeLocalDNSAddrs = []string{"169.254.20.10", "fd00::10"}
+			rrConfig.ClusterServiceAllowKubeletProbes = true
+			rrConfig.ClusterServiceKubeletAPIPort = 10250
+			renderer = NewRenderer(rrConfig)
+		})
+
+		for _, ipVersion := range []uint8{4, 6} {
+			ipVersion := ipVersion
+			dnsAddr := "169.254.20.10"
+			if ipVersion == 6 {
+				dnsAddr = "fd00::10"
+			}
+
+			It(fmt.Sprintf("should render the IPv%d chains", ipVersion), func() {
+				chains := renderer.StaticFilterTableChains(ipVersion)
+				Expect(findChain(chains, ChainClusterServicesToWl)).To(Equal(&Chain{
+					Name: ChainClusterServicesToWl,
+					Rules: []Rule{{
+						Match:   Match().Protocol("tcp").SrcAddrType(AddrTypeLocal, false).NotConntrackState("DNAT"),
+						Action:  AcceptAction{},
+						Comment: []string{"Allow kubelet probes from the host"},
+					}},
+				}))
+				Expect(findChain(chains, ChainClusterServicesFromWl)).To(Equal(&Chain{
+					Name: ChainClusterServicesFromWl,
+					Rules: []Rule{
+						{
+							Match:   Match().Protocol("udp").DestNet(dnsAddr).DestPorts(53),
+							Action:  AcceptAction{},
+							Comment: []string{"Allow node-local DNS"},
+						},
+						{
+							Match:   Match().Protocol("tcp").DestNet(dnsAddr).DestPorts(53),
+							Action:  AcceptAction{},
+							Comment: []string{"Allow node-local DNS"},
+						},
+						{
+							Match:   Match().Protocol("tcp").DestAddrType(AddrTypeLocal).DestPorts(10250),
+							Action:  AcceptAction{},
+							Comment: []string{"Allow kubelet API on the host"},
+						},
+					},
+				}))
+			})
+		}
+
+		It("should jump to the chains from the workload chains, after the conntrack rules", func() {
+			epMarkMapper := NewEndpointMarkMapper(rrConfig.IptablesMarkEndpoint, rrConfig.IptablesMarkNonCaliEndpoint)
+			wlChains := renderer.WorkloadEndpointToIptablesChains("cali1234", epMarkMapper, true, nil, nil, nil, ConnectionLimits{})
+			Expect(findChain(wlChains, "cali-tw-cali1234").Rules[2]).To(Equal(Rule{
+				Action: JumpAction{Target: ChainClusterServicesToWl},
+			}))
+			Expect(findChain(wlChains, "cali-fw-cali1234").Rules[2]).To(Equal(Rule{
+				Action: JumpAction{Target: ChainClusterServicesFromWl},
+			}))
+		})
+	})
+})
+
+var _ = Describe("Failsafe ports with CIDR and interface qualifiers", func() {
+	var renderer RuleRenderer
+
+	BeforeEach(func() {
+		renderer = NewRenderer(Config{
+			IPSetConfigV4:        ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+			IPSetConfigV6:        ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+			IptablesMarkAccept:   0x10,
+			IptablesMarkPass:     0x20,
+			IptablesMarkScratch0: 0x40,
+			IptablesMarkScratch1: 0x80,
+			IptablesMarkEndpoint: 0xff00,
+			FailsafeInboundHostPorts: []config.ProtoPort{
+				{Net: "10.0.0.0/8", Protocol: "tcp", Port: 22, Interface: "eth0"},
+				{Protocol: "udp", Port: 68, Interface: "bond+"},
+			},
+			FailsafeOutboundHostPorts: []config.ProtoPort{
+				{Net: "10.1.0.0/16", Protocol: "tcp", Port: 179, Interface: "eth0"},
+			},
+		})
+	})
+
+	It("should render the IPv4 chains with CIDR and interface matches", func() {
+		Expect(findChain(renderer.StaticFilterTableChains(4), ChainFailsafeIn)).To(Equal(&Chain{
+			Name: ChainFailsafeIn,
+			Rules: []Rule{
+				{Match: Match().Protocol("tcp").DestPorts(22).SourceNet("10.0.0.0/8").InInterface("eth0"), Action: AcceptAction{}},
+				{Match: Match().Protocol("udp").DestPorts(68).InInterface("bond+"), Action: AcceptAction{}},
+			},
+		}))
+		Expect(findChain(renderer.StaticRawTableChains(4), ChainFailsafeOut)).To(Equal(&Chain{
+			Name: ChainFailsafeOut,
+			Rules: []Rule{
+				{Match: Match().Protocol("tcp").DestPorts(179).DestNet("10.1.0.0/16").OutInterface("eth0"), Action: AcceptAction{}},
+				{Match: Match().Protocol("tcp").SourcePorts(22).SourceNet("10.0.0.0/8").OutInterface("eth0"), Action: AcceptAction{}},
+				{Match: Match().Protocol("udp").SourcePorts(68).OutInterface("bond+"), Action: AcceptAction{}},
+			},
+		}))
+	})
+
+	It("should render IPv4 entries in the IPv6 chains without their CIDRs", func() {
+		Expect(findChain(renderer.StaticFilterTableChains(6), ChainFailsafeIn)).To(Equal(&Chain{
+			Name: ChainFailsafeIn,
+			Rules: []Rule{
+				{Match: Match().Protocol("tcp").DestPorts(22).InInterface("eth0"), Action: AcceptAction{}},
+				{Match: Match().Protocol("udp").DestPorts(68).InInterface("bond+"), Action: AcceptAction{}},
+			},
+		}))
+		Expect(findChain(renderer.StaticFilterTableChains(6), ChainFailsafeOut)).To(Equal(&Chain{
+			Name: ChainFailsafeOut,
+			Rules: []Rule{
+				{Match: Match().Protocol("tcp").DestPorts(179).OutInterface("eth0"), Action: AcceptAction{}},
+			},
+		}))
+	})
+})
+
+var _ = Describe("Failsafe audit mode", func() {
+	var renderer RuleRenderer
+
+	BeforeEach(func() {
+		renderer = NewRenderer(Config{
+			IPSetConfigV4:             ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+			IPSetConfigV6:             ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+			IptablesMarkAccept:        0x10,
+			IptablesMarkPass:          0x20,
+			IptablesMarkScratch0:      0x40,
+			IptablesMarkScratch1:      0x80,
+			IptablesMarkEndpoint:      0xff00,
+			FailsafeAuditEnabled:      true,
+			FailsafeAuditIptablesMark: 0x100000,
+			FailsafeInboundHostPorts: []config.ProtoPort{
+				{Protocol: "tcp", Port: 22},
+				{Net: "10.0.0.0/8", Protocol: "tcp", Port: 6443},
+			},
+			FailsafeOutboundHostPorts: []config.ProtoPort{
+				{Protocol: "udp", Port: 53},
+			},
+		})
+	})
+
+	It("should mark, rather than accept, failsafe traffic in the filter table", func() {
+		Expect(findChain(renderer.StaticFilterTableChains(4), ChainFailsafeIn)).To(Equal(&Chain{
+			Name: ChainFailsafeIn,
+			Rules: []Rule{
+				{Action: ClearMarkAction{Mark: 0x100000}},
+				{Match: Match().Protocol("tcp").DestPorts(22), Action: SetMarkAction{Mark: 0x100000}},
+				{Match: Match().Protocol("tcp").DestPorts(6443).SourceNet("10.0.0.0/8"), Action: SetMarkAction{Mark: 0x100000}},
+			},
+		}))
+	})
+
+	It("should still accept failsafe traffic in the raw table", func() {
+		Expect(findChain(renderer.StaticRawTableChains(4), ChainFailsafeOut)).To(Equal(&Chain{
+			Name: ChainFailsafeOut,
+			Rules: []Rule{
+				{Match: Match().Protocol("udp").DestPorts(53), Action: AcceptAction{}},
+				{Match: Match().Protocol("tcp").SourcePorts(22), Action: AcceptAction{}},
+				{Match: Match().Protocol("tcp").SourcePorts(6443).SourceNet("10.0.0.0/8"), Action: AcceptAction{}},
+			},
+		}))
+	})
+
+	It("should render the audit chains with an NFLOG rule per failsafe port", func() {
+		for _, chains := range [][]*Chain{
+			renderer.StaticFilterTableChains(6),
+			renderer.StaticMangleTableChains(6),
+			renderer.StaticRawTableChains(6),
+		} {
+			Expect(findChain(chains, ChainFailsafeInAudit)).To(Equal(&Chain{
+				Name: ChainFailsafeInAudit,
+				Rules: []Rule{
+					{Match: Match().Protocol("tcp").DestPorts(22), Action: NflogAction{Group: NFLOGFailsafeAuditGroup, Prefix: "A|I|0"}},
+					{Action: AcceptAction{}, Comment: []string{"Accept failsafe traffic that policy would deny"}},
+				},
+			}))
+			Expect(findChain(chains, ChainFailsafeOutAudit)).To(Equal(&Chain{
+				Name: ChainFailsafeOutAudit,
+				Rules: []Rule{
+					{Match: Match().Protocol("udp").DestPorts(53), Action: NflogAction{Group: NFLOGFailsafeAuditGroup, Prefix: "A|O|0"}},
+					{Action: AcceptAction{}, Comment: []string{"Accept failsafe traffic that policy would deny"}},
+				},
+			}))
+		}
+	})
+
+	It("should jump to the audit chain before the end-of-chain drops of host endpoint chains", func() {
+		chains := renderer.HostEndpointToFilterChains("eth0", nil, []string{"pol1"}, nil, []string{"pol1"}, nil, []string{"prof1"})
+		fromHEP := findChain(chains, "cali-fh-eth0")
+		Expect(fromHEP.Rules).To(ContainElement(Rule{
+			Match:  Match().MarkClear(0x20).MarkSingleBitSet(0x100000),
+			Action: JumpAction{Target: ChainFailsafeInAudit},
+		}))
+		toHEP := findChain(chains, "cali-th-eth0")
+		Expect(toHEP.Rules).To(ContainElement(Rule{
+			Match:  Match().MarkSingleBitSet(0x100000),
+			Action: JumpAction{Target: ChainFailsafeOutAudit},
+		}))
+		fwdFromHEP := findChain(chains, "cali-fhfw-eth0")
+		for _, r := range fwdFromHEP.Rules {
+			Expect(r.Action).NotTo(Equal(JumpAction{Target: ChainFailsafeInAudit}))
+		}
+	})
+})
+
+var _ = Describe("Hook chains", func() {
+	var renderer RuleRenderer
+
+	BeforeEach(func() {
+		renderer = NewRenderer(Config{
+			WorkloadIfacePrefixes: []string{"cali"},
+			IPSetConfigV4:         ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+			IPSetConfigV6:         ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+			IptablesMarkAccept:    0x10,
+			IptablesMarkPass:      0x20,
+			IptablesMarkScratch0:  0x40,
+			IptablesMarkScratch1:  0x80,
+			IptablesMarkEndpoint:  0xff00,
+			IptablesHookChains: []config.HookChain{
+				{Point: config.HookPointBeforePolicy, Chain: "pre1"},
+				{Point: config.HookPointAfterPolicy, Chain: "post"},
+				{Point: config.HookPointBeforePolicy, Chain: "pre2"},
+				{Point: config.HookPointBeforeNAT, Chain: "nat"},
+			},
+		})
+	})
+
+	jump := func(chain string) Rule {
+		return Rule{Action: JumpAction{Target: chain}, Comment: []string{"Jump to user hook chain"}}
+	}
+
+	It("should jump to the policy hooks at the start and end of the filter chains", func() {
+		for _, name := range []string{ChainFilterInput, ChainFilterForward, ChainFilterOutput} {
+			rules := findChain(renderer.StaticFilterTableChains(4), name).Rules
+			Expect(rules[:2]).To(Equal([]Rule{jump("pre1"), jump("pre2")}), name)
+			Expect(rules[len(rules)-1]).To(Equal(jump("post")), name)
+			Expect(rules).NotTo(ContainElement(jump("nat")), name)
+		}
+	})
+
+	It("should jump to the NAT hook at the start of the nat chains", func() {
+		for name, next := range map[string]string{
+			ChainNATPrerouting:  ChainFIPDnat,
+			ChainNATPostrouting: ChainFIPSnat,
+			ChainNATOutput:      ChainFIPDnat,
+		} {
+			rules := findChain(renderer.StaticNATTableChains(6), name).Rules
+			Expect(rules[:2]).To(Equal([]Rule{jump("nat"), {Action: JumpAction{Target: next}}}), name)
+		}
+	})
+})
+
+var _ = Describe("GTP-U notrack", func() {
+	var renderer RuleRenderer
+
+	BeforeEach(func() {
+		renderer = NewRenderer(Config{
+			WorkloadIfacePrefixes: []string{"cali"},
+			IPSetConfigV4:         ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+			IPSetConfigV6:         ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+			IptablesMarkAccept:    0x10,
+			IptablesMarkPass:      0x20,
+			IptablesMarkScratch0:  0x40,
+			IptablesMarkScratch1:  0x80,
+			IptablesMarkEndpoint:  0xff00,
+			GTPUNotrackPort:       2152,
+		})
+	})
+
+	notrack := Rule{
+		Match:   Match().Protocol("udp").DestPorts(2152),
+		Action:  NoTrackAction{},
+		Comment: []string{"Don't track GTP-U"},
+	}
+
+	It("should not track GTP-U before the untracked policy chains", func() {
+		for _, ipVersion := range []uint8{4, 6} {
+			chains := renderer.StaticRawTableChains(ipVersion)
+			for name, dispatch := range map[string]string{
+				ChainRawPrerouting: ChainDispatchFromHostEndpoint,
+				ChainRawOutput:     ChainDispatchToHostEndpoint,
+			} {
+				rules := findChain(chains, name).Rules
+				Expect(rules[len(rules)-3]).To(Equal(notrack), name)
+				Expect(rules[len(rules)-2].Action).To(Equal(JumpAction{Target: dispatch}), name)
+			}
+		}
+	})
+})
+
+var _ = Describe("Multicast", func() {
+	var renderer RuleRenderer
+
+	BeforeEach(func() {
+		renderer = NewRenderer(Config{
+			WorkloadIfacePrefixes: []string{"cali"},
+			IPSetConfigV4:         ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+			IPSetConfigV6:         ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+			IptablesMarkAccept:    0x10,
+			IptablesMarkPass:      0x20,
+			IptablesMarkScratch0:  0x40,
+			IptablesMarkScratch1:  0x80,
+			IptablesMarkEndpoint:  0xff00,
+			MulticastEnabled:      true,
+		})
+	})
+
+	It("should allow IGMP from workloads to the host", func() {
+		rules := findChain(renderer.StaticFilterTableChains(4), ChainWorkloadToHost).Rules
+		Expect(rules).To(ContainElement(Rule{
+			Match:   Match().ProtocolNum(ProtoIGMP),
+			Action:  AcceptAction{},
+			Comment: []string{"Allow IGMP from workloads"},
+		}))
+	})
+
+	It("should allow MLDv2 reports from workloads to the host", func() {
+		rules := findChain(renderer.StaticFilterTableChains(6), ChainWorkloadToHost).Rules
+		Expect(rules).To(ContainElement(Rule{
+			Match:   Match().ProtocolNum(ProtoICMPv6).ICMPV6Type(143),
+			Action:  AcceptAction{},
+			Comment: []string{"Allow MLDv2 reports from workloads"},
+		}))
+	})
+})
+
+var _ = Describe("Conntrack helpers", func() {
+	var renderer RuleRenderer
+
+	BeforeEach(func() {
+		renderer = NewRenderer(Config{
+			WorkloadIfacePrefixes: []string{"cali"},
+			IPSetConfigV4:         ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+			IPSetConfigV6:         ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+			IptablesMarkAccept:    0x10,
+			IptablesMarkPass:      0x20,
+			IptablesMarkScratch0:  0x40,
+			IptablesMarkScratch1:  0x80,
+			IptablesMarkEndpoint:  0xff00,
+			ConntrackHelpers: []config.ConntrackHelperRule{{
+				Selector: "has(ftp)",
+				Helpers:  []config.ConntrackHelper{{Name: "ftp", Protocol: "tcp", Port: 21}},
+			}},
+		})
+	})
+
+	It("should attach the helper to connections to and from the selected workloads", func() {
+		setName := "cali40" + ConntrackHelperIPSetID(0)
+		comment := []string{"Attach ftp conntrack helper"}
+		for name, dispatch := range map[string]string{
+			ChainRawPrerouting: ChainDispatchFromHostEndpoint,
+			ChainRawOutput:     ChainDispatchToHostEndpoint,
+		} {
+			rules := findChain(renderer.StaticRawTableChains(4), name).Rules
+			Expect(rules[len(rules)-4:len(rules)-2]).To(Equal([]Rule{
+				{
+					Match:   Match().SourceIPSet(setName).Protocol("tcp").DestPorts(21),
+					Action:  CTHelperAction{Helper: "ftp"},
+					Comment: comment,
+				},
+				{
+					Match:   Match().DestIPSet(setName).Protocol("tcp").DestPorts(21),
+					Action:  CTHelperAction{Helper: "ftp"},
+					Comment: comment,
+				},
+			}), name)
+			Expect(rules[len(rules)-2].Action).To(Equal(JumpAction{Target: dispatch}), name)
+		}
+	})
+
+	It("should use the IPv6 IP set in the IPv6 cali-OUTPUT chain", func() {
+		setName := "cali60" + ConntrackHelperIPSetID(0)
+		rules := findChain(renderer.StaticRawTableChains(6), ChainRawOutput).Rules
+		Expect(rules).To(ContainElement(Rule{
+			Match:   Match().SourceIPSet(setName).Protocol("tcp").DestPorts(21),
+			Action:  CTHelperAction{Helper: "ftp"},
+			Comment: []string{"Attach ftp conntrack helper"},
+		}))
+		Expect(rules).To(ContainElement(Rule{
+			Match:   Match().DestIPSet(setName).Protocol("tcp").DestPorts(21),
+			Action:  CTHelperAction{Helper: "ftp"},
+			Comment: []string{"Attach ftp conntrack helper"},
+		}))
+	})
+})
+
+var _ = Describe("VRF support", func() {
+	var renderer RuleRenderer
+
+	BeforeEach(func() {
+		renderer = NewRenderer(Config{
+			WorkloadIfacePrefixes: []string{"cali"},
+			IPSetConfigV4:         ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+			IPSetConfigV6:         ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+			IptablesMarkAccept:    0x10,
+			IptablesMarkPass:      0x20,
+			IptablesMarkScratch0:  0x40,
+			IptablesMarkScratch1:  0x80,
+			IptablesMarkEndpoint:  0xff00,
+			VRFSupportEnabled:     true,
+		})
+	})
+
+	It("should set the endpoint mark before accepting anything in mangle PREROUTING", func() {
+		rules := findChain(renderer.StaticMangleTableChains(4), ChainManglePrerouting).Rules
+		Expect(rules[0]).To(Equal(Rule{Action: JumpAction{Target: ChainSetVRFEndpointMark}}))
+	})
+
+	It("should dispatch marked packets to the workload chains in FORWARD", func() {
+		rules := findChain(renderer.StaticFilterTableChains(4), ChainFilterForward).Rules
+		Expect(rules).To(ContainElement(Rule{
+			Match:  Match().MarkNotClear(0xff00),
+			Action: JumpAction{Target: ChainFromWorkloadDispatch},
+		}))
+		Expect(rules).To(ContainElement(Rule{
+			Action: ClearMarkAction{Mark: 0xff00},
+		}))
+	})
+
+	It("should send marked packets to the workload-to-host chain in INPUT", func() {
+		rules := findChain(renderer.StaticFilterTableChains(4), ChainFilterInput).Rules
+		Expect(rules).To(ContainElement(Rule{
+			Match:  Match().MarkNotClear(0xff00),
+			Action: GotoAction{Target: ChainWorkloadToHost},
+		}))
+	})
+})
@@ -0,0 +1,322 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicecidrs
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	apiServerNamespace = "kube-system"
+	apiServerSelector  = "component=kube-apiserver"
+	apiServerFlag      = "--service-cluster-ip-range"
+	kubeadmConfigMap   = "kubeadm-config"
+	kubeadmConfigKey   = "ClusterConfiguration"
+	kubeadmSubnetKey   = "serviceSubnet:"
+
+	timeout          = 20 * time.Second
+	initBackoff      = 5 * time.Second
+	maxBackoff       = 5 * time.Minute
+	maxFailures      = 8
+	redetectInterval = 10 * time.Minute
+)
+
+var (
+	// ErrNotFound is returned by Detect if none of the sources it knows about give the service
+	// CIDRs.
+	ErrNotFound = errors.New("couldn't find the cluster's service CIDRs")
+	// ErrForbidden is returned by Detect if it isn't allowed to read a source and none of the
+	// others give the service CIDRs.
+	ErrForbidden = errors.New("not allowed to list pods or get configmaps in " + apiServerNamespace)
+)
+
+// Detect looks up the cluster's service CIDRs.  Kubernetes has no API for them, so we look for
+// the --service-cluster-ip-range flag on the kube-apiserver pods and then for the serviceSubnet
+// in kubeadm's ClusterConfiguration.  That needs permission to list pods and to get the
+// kubeadm-config configmap in kube-system; without it Detect returns ErrForbidden.  Clusters with a
+// hosted control plane usually have neither, in which case Detect returns ErrNotFound.  Other
+// errors are worth retrying.
+func Detect(ctx context.Context, client kubernetes.Interface) ([]string, error) {
+	forbidden := false
+	pods, err := client.CoreV1().Pods(apiServerNamespace).List(ctx,
+		metav1.ListOptions{LabelSelector: apiServerSelector})
+	if k8serrors.IsForbidden(err) {
+		log.WithError(err).Debug("Not allowed to list kube-apiserver pods")
+		forbidden = true
+	} else if err != nil {
+		return nil, err
+	} else {
+		for _, pod := range pods.Items {
+			for _, c := range pod.Spec.Containers {
+				if value, ok := flagValue(append(c.Command, c.Args...), apiServerFlag); ok {
+					return parseCIDRs(value)
+				}
+			}
+		}
+	}
+
+	notFound := ErrNotFound
+	if forbidden {
+		notFound = ErrForbidden
+	}
+	cm, err := client.CoreV1().ConfigMaps(apiServerNamespace).Get(ctx, kubeadmConfigMap, metav1.GetOptions{})
+	if k8serrors.IsForbidden(err) {
+		log.WithError(err).Debug("Not allowed to get kubeadm config")
+		return nil, ErrForbidden
+	} else if k8serrors.IsNotFound(err) {
+		return nil, notFound
+	} else if err != nil {
+		return nil, err
+	}
+	// Avoid pulling in a YAML parser for one scalar; the key only appears under "networking".
+	for _, line := range strings.Split(cm.Data[kubeadmConfigKey], "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, kubeadmSubnetKey) {
+			value := strings.TrimSpace(strings.TrimPrefix(line, kubeadmSubnetKey))
+			return parseCIDRs(strings.Trim(value, `"'`))
+		}
+	}
+	return nil, notFound
+}
+
+// flagValue finds the value of the flag in the command line, whether it's given as "--flag=value"
+// or as "--flag value".  The command line may also come as a shell command in a single argument.
+func flagValue(args []string, flag string) (string, bool) {
+	var words []string
+	for _, arg := range args {
+		words = append(words, strings.Fields(arg)...)
+	}
+	for i, word := range words {
+		if strings.HasPrefix(word, flag+"=") {
+			return strings.TrimPrefix(word, flag+"="), true
+		}
+		if word == flag && i+1 < len(words) {
+			return words[i+1], true
+		}
+	}
+	return "", false
+}
+
+func parseCIDRs(s string) ([]string, error) {
+	var cidrs []string
+	for _, c := range strings.Split(s, ",") {
+		_, ipNet, err := net.ParseCIDR(strings.TrimSpace(c))
+		if err != nil {
+			return nil, err
+		}
+		cidrs = append(cidrs, ipNet.String())
+	}
+	sort.Strings(cidrs)
+	return cidrs, nil
+}
+
+// Mismatches compares the detected service CIDRs with the configured ones and describes each
+// difference.  A detected CIDR that isn't covered by the configuration leaves part of the service
+// range unprotected by service loop prevention; a configured CIDR that doesn't cover any detected
+// one blocks (or advertises) addresses that aren't services.  A configured CIDR that is bigger
+// than the service range is fine: clusters often configure a range that they can grow into.
+func Mismatches(detected, configured []string) []string {
+	detectedNets := parseNets(detected)
+	configuredNets := parseNets(configured)
+	var problems []string
+	for i, dNet := range detectedNets {
+		if !anyCovers(configuredNets, dNet) {
+			problems = append(problems,
+				"service CIDR "+detected[i]+" is not covered by the configured service cluster IPs")
+		}
+	}
+	for i, cNet := range configuredNets {
+		coversService := false
+		for _, dNet := range detectedNets {
+			if covers(cNet, dNet) {
+				coversService = true
+				break
+			}
+		}
+		if !coversService {
+			problems = append(problems,
+				"configured service cluster IPs "+configured[i]+" don't cover a service CIDR")
+		}
+	}
+	return problems
+}
+
+// parseNets parses the CIDRs, returning nil in place of any that doesn't parse.
+func parseNets(cidrs []string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, c := range cidrs {
+		_, nets[i], _ = net.ParseCIDR(c)
+	}
+	return nets
+}
+
+func anyCovers(outers []*net.IPNet, inner *net.IPNet) bool {
+	for _, outer := range outers {
+		if covers(outer, inner) {
+			return true
+		}
+	}
+	return false
+}
+
+// covers returns true if outer contains all of inner.
+func covers(outer, inner *net.IPNet) bool {
+	if outer == nil || inner == nil || len(outer.IP) != len(inner.IP) {
+		return false
+	}
+	outerOnes, _ := outer.Mask.Size()
+	innerOnes, _ := inner.Mask.Size()
+	return outerOnes <= innerOnes && outer.Contains(inner.IP)
+}
+
+// Checker detects the cluster's service CIDRs in the background and warns when they don't agree
+// with the service cluster IPs that Felix has been configured with (via BGPConfiguration), which
+// otherwise shows up as subtle routing problems.  Detection is repeated periodically in case the
+// service range is changed.  Until the first successful detection, failed detections are retried
+// with backoff, up to maxFailures times in a row, and the checker gives up straight away if the
+// cluster doesn't say what the service CIDRs are, or if Felix isn't allowed to look.  Once it has
+// detected the service CIDRs, it keeps checking against the last good detection and keeps
+// retrying.
+type Checker struct {
+	client kubernetes.Interface
+	clock  clock.Clock
+
+	lock          sync.Mutex
+	configured    []string
+	configuredSet bool
+	kickC         chan struct{}
+
+	detected  []string
+	lastWarns []string
+}
+
+func NewChecker(client kubernetes.Interface) *Checker {
+	return newChecker(client, clock.RealClock{})
+}
+
+func newChecker(client kubernetes.Interface, c clock.Clock) *Checker {
+	return &Checker{
+		client: client,
+		clock:  c,
+		kickC:  make(chan struct{}, 1),
+	}
+}
+
+// Start starts the background goroutine that does the detection and comparison.
+func (c *Checker) Start() {
+	go c.loop()
+}
+
+// OnConfiguredCIDRs records the configured service cluster IPs.  It doesn't block.
+func (c *Checker) OnConfiguredCIDRs(cidrs []string) {
+	c.lock.Lock()
+	c.configured = append([]string(nil), cidrs...)
+	c.configuredSet = true
+	c.lock.Unlock()
+
+	select {
+	case c.kickC <- struct{}{}:
+	default:
+		// Loop already has a kick pending; it'll pick up the latest CIDRs.
+	}
+}
+
+func (c *Checker) loop() {
+	backoff := initBackoff
+	failures := 0
+	detectC := c.clock.After(0)
+	for {
+		select {
+		case <-c.kickC:
+		case <-detectC:
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			detected, err := Detect(ctx, c.client)
+			cancel()
+			if err == ErrNotFound || err == ErrForbidden {
+				if c.detected == nil {
+					log.WithError(err).Warn("Can't detect service CIDRs, disabling the service CIDR check")
+					return
+				}
+				log.WithError(err).Warn("Can't redetect service CIDRs, keeping the last ones detected")
+				detectC = c.clock.After(redetectInterval)
+				continue
+			} else if err != nil {
+				failures++
+				if failures >= maxFailures && c.detected == nil {
+					log.WithError(err).Warn("Repeatedly failed to detect service CIDRs, " +
+						"disabling the service CIDR check")
+					return
+				}
+				log.WithError(err).WithField("retryIn", backoff).Info(
+					"Failed to detect service CIDRs, will retry")
+				detectC = c.clock.After(backoff)
+				backoff *= 2
+				if backoff > maxBackoff {
+					backoff = maxBackoff
+				}
+				continue
+			}
+			backoff = initBackoff
+			failures = 0
+			detectC = c.clock.After(redetectInterval)
+			if !reflect.DeepEqual(detected, c.detected) {
+				log.WithField("cidrs", detected).Info("Detected service CIDRs")
+				c.detected = detected
+			}
+		}
+		c.check()
+	}
+}
+
+func (c *Checker) check() {
+	c.lock.Lock()
+	configured, configuredSet := c.configured, c.configuredSet
+	c.lock.Unlock()
+	if c.detected == nil || !configuredSet {
+		return
+	}
+
+	var warns []string
+	if len(configured) > 0 {
+		warns = Mismatches(c.detected, configured)
+	}
+	if reflect.DeepEqual(warns, c.lastWarns) {
+		return
+	}
+	c.lastWarns = warns
+	if len(warns) == 0 {
+		log.WithField("cidrs", c.detected).Info("Configured service cluster IPs match the service CIDRs")
+		return
+	}
+	for _, w := range warns {
+		log.WithFields(log.Fields{
+			"detected":   c.detected,
+			"configured": configured,
+		}).Warn("Service CIDR mismatch: " + w + "; check BGPConfiguration serviceClusterIPs")
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicecidrs
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var _ = Describe("Service CIDR detection", func() {
+	apiServerPod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kube-apiserver-master",
+			Namespace: "kube-system",
+			Labels:    map[string]string{"component": "kube-apiserver"},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name: "kube-apiserver",
+				Command: []string{
+					"kube-apiserver",
+					"--secure-port=6443",
+					"--service-cluster-ip-range=fd00:96::/108,10.96.0.0/12",
+				},
+			}},
+		},
+	}
+	kubeadmConfig := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubeadm-config", Namespace: "kube-system"},
+		Data: map[string]string{
+			"ClusterConfiguration": "apiVersion: kubeadm.k8s.io/v1beta2\n" +
+				"networking:\n" +
+				"  dnsDomain: cluster.local\n" +
+				"  podSubnet: 192.168.0.0/16\n" +
+				"  serviceSubnet: 10.100.0.0/16\n",
+		},
+	}
+
+	detect := func(objs ...runtime.Object) ([]string, error) {
+		return Detect(context.Background(), fake.NewSimpleClientset(objs...))
+	}
+
+	It("should prefer the kube-apiserver flag", func() {
+		Expect(detect(apiServerPod, kubeadmConfig)).To(Equal([]string{"10.96.0.0/12", "fd00:96::/108"}))
+	})
+
+	It("should parse the kube-apiserver flag with its value as the next argument", func() {
+		pod := apiServerPod.DeepCopy()
+		pod.Spec.Containers[0].Command = []string{"kube-apiserver"}
+		pod.Spec.Containers[0].Args = []string{
+			"--secure-port=6443",
+			"--service-cluster-ip-range", "10.96.0.0/12",
+		}
+		Expect(detect(pod)).To(Equal([]string{"10.96.0.0/12"}))
+	})
+
+	It("should parse the kube-apiserver flag from a shell command", func() {
+		pod := apiServerPod.DeepCopy()
+		pod.Spec.Containers[0].Command = []string{"/bin/sh", "-c",
+			"exec kube-apiserver --secure-port=6443 --service-cluster-ip-range=10.96.0.0/12"}
+		Expect(detect(pod)).To(Equal([]string{"10.96.0.0/12"}))
+	})
+
+	It("should fall back to the kubeadm config", func() {
+		Expect(detect(kubeadmConfig)).To(Equal([]string{"10.100.0.0/16"}))
+	})
+
+	It("should return ErrNotFound if there's nothing to go on", func() {
+		_, err := detect()
+		Expect(err).To(Equal(ErrNotFound))
+	})
+
+	// forbid makes the client refuse the given verb on the given resource.
+	forbid := func(client *fake.Clientset, verb, resource string) {
+		client.PrependReactor(verb, resource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, k8serrors.NewForbidden(schema.GroupResource{Resource: resource}, "", nil)
+		})
+	}
+
+	It("should still use the kubeadm config if it can't list pods", func() {
+		client := fake.NewSimpleClientset(kubeadmConfig)
+		forbid(client, "list", "pods")
+		Expect(Detect(context.Background(), client)).To(Equal([]string{"10.100.0.0/16"}))
+	})
+
+	It("should return ErrForbidden if it can't read the sources", func() {
+		client := fake.NewSimpleClientset()
+		forbid(client, "list", "pods")
+		_, err := Detect(context.Background(), client)
+		Expect(err).To(Equal(ErrForbidden))
+	})
+
+	It("should return other errors so that they can be retried", func() {
+		client := fake.NewSimpleClientset(kubeadmConfig)
+		client.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, k8serrors.NewServiceUnavailable("try again")
+		})
+		_, err := Detect(context.Background(), client)
+		Expect(k8serrors.IsServiceUnavailable(err)).To(BeTrue())
+	})
+
+	It("should stop checking if it can't detect the service CIDRs", func() {
+		client := fake.NewSimpleClientset()
+		forbid(client, "list", "pods")
+		done := make(chan struct{})
+		go func() {
+			newChecker(client, clock.RealClock{}).loop()
+			close(done)
+		}()
+		Eventually(done).Should(BeClosed())
+	})
+
+	It("should keep checking with the last detection if a later detection fails", func() {
+		client := fake.NewSimpleClientset(apiServerPod)
+		fakeClock := clock.NewFakeClock(time.Now())
+		done := make(chan struct{})
+		go func() {
+			newChecker(client, fakeClock).loop()
+			close(done)
+		}()
+		Eventually(fakeClock.HasWaiters).Should(BeTrue())
+		fakeClock.Step(0)
+
+		Eventually(fakeClock.HasWaiters).Should(BeTrue())
+		forbid(client, "list", "pods")
+		fakeClock.Step(redetectInterval)
+
+		Eventually(fakeClock.HasWaiters).Should(BeTrue())
+		Consistently(done).ShouldNot(BeClosed())
+	})
+})
+
+var _ = DescribeTable("Service CIDR mismatches",
+	func(detected, configured []string, expNumProblems int) {
+		Expect(Mismatches(detected, configured)).To(HaveLen(expNumProblems))
+	},
+	Entry("match", []string{"10.96.0.0/12"}, []string{"10.96.0.0/12"}, 0),
+	Entry("configured too small", []string{"10.96.0.0/12"}, []string{"10.96.0.0/16"}, 2),
+	Entry("configured bigger", []string{"10.96.0.0/12"}, []string{"10.0.0.0/8"}, 0),
+	Entry("wrong range", []string{"10.96.0.0/12"}, []string{"172.16.0.0/12"}, 2),
+	Entry("dual stack, v6 not configured", []string{"10.96.0.0/12", "fd00:96::/108"},
+		[]string{"10.96.0.0/12"}, 1),
+)
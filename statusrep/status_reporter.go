@@ -35,6 +35,7 @@ type EndpointStatusReporter struct {
 	stop               chan bool
 	datastore          datastore
 	epStatusIDToStatus map[model.Key]string
+	epStatusIDToReason map[model.Key]string
 	queuedDirtyIDs     set.Set
 	activeDirtyIDs     set.Set
 	reportingDelay     time.Duration
@@ -92,6 +93,7 @@ func newEndpointStatusReporterWithTickerChans(hostname string,
 		inSync:             inSync,
 		stop:               make(chan bool),
 		epStatusIDToStatus: make(map[model.Key]string),
+		epStatusIDToReason: make(map[model.Key]string),
 		queuedDirtyIDs:     set.New(),
 		activeDirtyIDs:     set.New(),
 		resyncTicker:       resyncTicker,
@@ -152,7 +154,7 @@ loop:
 			datamodelInSync = datamodelInSync || inSync
 		case msg := <-esr.endpointUpdates:
 			var statID model.Key
-			var status string
+			var status, reason string
 			switch msg := msg.(type) {
 			case *proto.WorkloadEndpointStatusUpdate:
 				statID = model.WorkloadEndpointStatusKey{
@@ -163,6 +165,7 @@ loop:
 					RegionString:   model.RegionString(esr.region),
 				}
 				status = msg.Status.Status
+				reason = msg.Status.Reason
 			case *proto.WorkloadEndpointStatusRemove:
 				statID = model.WorkloadEndpointStatusKey{
 					Hostname:       esr.hostname,
@@ -177,6 +180,7 @@ loop:
 					EndpointID: msg.Id.EndpointId,
 				}
 				status = msg.Status.Status
+				reason = msg.Status.Reason
 			case *proto.HostEndpointStatusRemove:
 				statID = model.HostEndpointStatusKey{
 					Hostname:   esr.hostname,
@@ -185,6 +189,13 @@ loop:
 			default:
 				log.Panicf("Unexpected message: %#v", msg)
 			}
+			// We only log the reason (see writeEndpointStatus) so a change of reason alone
+			// doesn't need a write.
+			if reason != "" {
+				esr.epStatusIDToReason[statID] = reason
+			} else {
+				delete(esr.epStatusIDToReason, statID)
+			}
 			if esr.epStatusIDToStatus[statID] != status {
 				if status != "" {
 					esr.epStatusIDToStatus[statID] = status
@@ -227,7 +238,7 @@ loop:
 				// Note: the update could be a deletion, in which case
 				// the read from the cache wil return nil.
 				err := esr.writeEndpointStatus(ctx, statID,
-					esr.epStatusIDToStatus[statID])
+					esr.epStatusIDToStatus[statID], esr.epStatusIDToReason[statID])
 				if err != nil {
 					log.WithError(err).Warn(
 						"Failed to write endpoint status; is datastore up?")
@@ -313,12 +324,17 @@ func (esr *EndpointStatusReporter) attemptResync(ctx context.Context) {
 	}
 }
 
-func (esr *EndpointStatusReporter) writeEndpointStatus(ctx context.Context, epID model.Key, status string) (err error) {
+func (esr *EndpointStatusReporter) writeEndpointStatus(ctx context.Context, epID model.Key, status, reason string) (err error) {
 	kv := model.KVPair{Key: epID}
 	logCxt := log.WithFields(log.Fields{
 		"newStatus":  status,
 		"endpointID": epID,
 	})
+	if reason != "" {
+		// The endpoint status model only carries the status string so, for now, the
+		// reason only makes it as far as the log.
+		logCxt = logCxt.WithField("reason", reason)
+	}
 	if status != "" {
 		logCxt.Info("Writing endpoint status")
 		switch epID.(type) {
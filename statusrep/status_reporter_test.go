@@ -206,6 +206,17 @@ var _ = Describe("Status", func() {
 				rateLimitTickerChan <- time.Now()
 				Eventually(datastore.snapshot).Should(BeEmpty())
 			})
+			It("should write an error status that has a reason", func() {
+				epUpdates <- &proto.WorkloadEndpointStatusUpdate{
+					Id:     &protoWlID,
+					Status: &proto.EndpointStatus{Status: "error", Reason: "iptables-restore failed"},
+				}
+				rateLimitTickerChan <- time.Now()
+				rateLimitTickerChan <- time.Now()
+				Eventually(datastore.snapshot).Should(Equal(map[model.Key]interface{}{
+					updatedWlEPKey: model.WorkloadEndpointStatus{Status: "error"},
+				}))
+			})
 			It("should coalesce flapping host EP updates", func() {
 				epUpdates <- &hostEPUpdateUp
 				epUpdates <- &hostEPUpdateUp
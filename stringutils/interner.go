@@ -0,0 +1,101 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stringutils
+
+type internedString struct {
+	value    string
+	refCount int
+}
+
+// Interner deduplicates strings so that equal strings share the same backing array.  It
+// reference counts the strings, so that a string is forgotten once everything that interned it
+// has released it.  It is not thread safe.
+type Interner struct {
+	strings map[string]internedString
+}
+
+func NewInterner() *Interner {
+	return &Interner{
+		strings: map[string]internedString{},
+	}
+}
+
+// Intern returns the canonical copy of s and takes a reference to it.  Each call should be
+// balanced by a call to Release.
+func (i *Interner) Intern(s string) string {
+	if s == "" {
+		return s
+	}
+	is, ok := i.strings[s]
+	if !ok {
+		is.value = s
+	}
+	is.refCount++
+	i.strings[is.value] = is
+	return is.value
+}
+
+// Release releases a reference taken by Intern.
+func (i *Interner) Release(s string) {
+	is, ok := i.strings[s]
+	if !ok {
+		return
+	}
+	is.refCount--
+	if is.refCount <= 0 {
+		delete(i.strings, s)
+		return
+	}
+	i.strings[s] = is
+}
+
+// InternMap returns a copy of m with its keys and values interned.
+func (i *Interner) InternMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	interned := make(map[string]string, len(m))
+	for k, v := range m {
+		interned[i.Intern(k)] = i.Intern(v)
+	}
+	return interned
+}
+
+// ReleaseMap releases the references taken by InternMap.
+func (i *Interner) ReleaseMap(m map[string]string) {
+	for k, v := range m {
+		i.Release(k)
+		i.Release(v)
+	}
+}
+
+// InternSlice interns the strings in s in place.
+func (i *Interner) InternSlice(s []string) {
+	for n, v := range s {
+		s[n] = i.Intern(v)
+	}
+}
+
+// ReleaseSlice releases the references taken by InternSlice.
+func (i *Interner) ReleaseSlice(s []string) {
+	for _, v := range s {
+		i.Release(v)
+	}
+}
+
+// Len returns the number of distinct strings that are currently interned.
+func (i *Interner) Len() int {
+	return len(i.strings)
+}
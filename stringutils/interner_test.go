@@ -0,0 +1,87 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stringutils_test
+
+import (
+	"strings"
+	"unsafe"
+
+	. "github.com/projectcalico/felix/stringutils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// dataPtr returns a pointer to the backing array of s.
+func dataPtr(s string) uintptr {
+	return *(*uintptr)(unsafe.Pointer(&s))
+}
+
+var _ = Describe("Interner", func() {
+	var interner *Interner
+
+	BeforeEach(func() {
+		interner = NewInterner()
+	})
+
+	It("should return the same copy of equal strings", func() {
+		a := interner.Intern(strings.Repeat("a", 10))
+		b := interner.Intern(strings.Repeat("a", 10))
+		Expect(b).To(Equal(a))
+		Expect(dataPtr(b)).To(Equal(dataPtr(a)))
+		Expect(interner.Len()).To(Equal(1))
+	})
+
+	It("should forget a string once it's fully released", func() {
+		interner.Intern("foo")
+		interner.Intern("foo")
+		interner.Release("foo")
+		Expect(interner.Len()).To(Equal(1))
+		interner.Release("foo")
+		Expect(interner.Len()).To(Equal(0))
+	})
+
+	It("should ignore releases of unknown strings", func() {
+		interner.Release("foo")
+		Expect(interner.Len()).To(Equal(0))
+	})
+
+	It("should intern maps", func() {
+		m1 := interner.InternMap(map[string]string{"app": strings.Repeat("b", 3)})
+		m2 := interner.InternMap(map[string]string{"app": strings.Repeat("b", 3), "role": "db"})
+		Expect(m1).To(Equal(map[string]string{"app": "bbb"}))
+		Expect(dataPtr(m2["app"])).To(Equal(dataPtr(m1["app"])))
+		Expect(interner.Len()).To(Equal(4))
+		interner.ReleaseMap(m1)
+		interner.ReleaseMap(m2)
+		Expect(interner.Len()).To(Equal(0))
+	})
+
+	It("should preserve nil and empty maps", func() {
+		Expect(interner.InternMap(nil)).To(BeNil())
+		Expect(interner.InternMap(map[string]string{})).To(Equal(map[string]string{}))
+	})
+
+	It("should intern slices in place", func() {
+		s1 := []string{strings.Repeat("c", 3)}
+		s2 := []string{strings.Repeat("c", 3), "d"}
+		interner.InternSlice(s1)
+		interner.InternSlice(s2)
+		Expect(dataPtr(s2[0])).To(Equal(dataPtr(s1[0])))
+		interner.ReleaseSlice(s1)
+		interner.ReleaseSlice(s2)
+		Expect(interner.Len()).To(Equal(0))
+	})
+})
@@ -0,0 +1,278 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	exportQueueLen  = 2048
+	maxExportBatch  = 512
+	exportTimeout   = 10 * time.Second
+	defaultInterval = 5 * time.Second
+
+	// OTLP span kind and status codes.
+	spanKindInternal = 1
+	statusCodeError  = 2
+)
+
+var (
+	countSpansExported = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "felix_tracing_spans_exported",
+		Help: "Number of trace spans successfully exported to the OTLP collector.",
+	})
+	countSpansDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "felix_tracing_spans_dropped",
+		Help: "Number of trace spans dropped because the export queue was full or the export failed.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(countSpansExported)
+	prometheus.MustRegister(countSpansDropped)
+}
+
+// otlpExporter batches up finished spans and sends them to an OTLP/HTTP receiver.
+type otlpExporter struct {
+	url           string
+	client        *http.Client
+	resource      otlpResource
+	flushInterval time.Duration
+
+	spans chan *Span
+
+	// lastExportFailed is used to avoid spamming the log when the collector is down.
+	lastExportFailed bool
+}
+
+func newOTLPExporter(config Config) *otlpExporter {
+	flushInterval := config.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = defaultInterval
+	}
+	return &otlpExporter{
+		url:    strings.TrimSuffix(config.Endpoint, "/") + "/v1/traces",
+		client: &http.Client{Timeout: exportTimeout},
+		resource: otlpResource{Attributes: []otlpKeyValue{
+			stringKV("service.name", config.ServiceName),
+			stringKV("host.name", config.Hostname),
+		}},
+		flushInterval: flushInterval,
+		spans:         make(chan *Span, exportQueueLen),
+	}
+}
+
+func (e *otlpExporter) enqueue(s *Span) {
+	select {
+	case e.spans <- s:
+	default:
+		// Never block the instrumented code on the exporter.
+		countSpansDropped.Inc()
+	}
+}
+
+func (e *otlpExporter) loop() {
+	ticker := time.NewTicker(e.flushInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	for {
+		select {
+		case s := <-e.spans:
+			batch = append(batch, s)
+			if len(batch) < maxExportBatch {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		e.export(batch)
+		batch = nil
+	}
+}
+
+func (e *otlpExporter) export(batch []*Span) {
+	err := e.send(batch)
+	if err != nil {
+		countSpansDropped.Add(float64(len(batch)))
+		if !e.lastExportFailed {
+			log.WithError(err).WithField("url", e.url).Warn(
+				"Failed to export trace spans; will keep trying but further failures will only be logged at debug level.")
+		} else {
+			log.WithError(err).Debug("Failed to export trace spans.")
+		}
+		e.lastExportFailed = true
+		return
+	}
+	if e.lastExportFailed {
+		log.WithField("url", e.url).Info("Exporting trace spans succeeded after previous failure.")
+	}
+	e.lastExportFailed = false
+	countSpansExported.Add(float64(len(batch)))
+}
+
+func (e *otlpExporter) send(batch []*Span) error {
+	body, err := json.Marshal(e.buildRequest(batch))
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected response from collector: %s", resp.Status)
+	}
+	return nil
+}
+
+func (e *otlpExporter) buildRequest(batch []*Span) *otlpExportRequest {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, s := range batch {
+		spans = append(spans, s.toOTLP())
+	}
+	return &otlpExportRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: e.resource,
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "github.com/projectcalico/felix"},
+				Spans: spans,
+			}},
+		}},
+	}
+}
+
+func (s *Span) toOTLP() otlpSpan {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	o := otlpSpan{
+		TraceID:           s.traceID,
+		SpanID:            s.spanID,
+		ParentSpanID:      s.parentSpanID,
+		Name:              s.name,
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+	}
+	// Sort the attributes so that the output is deterministic.
+	keys := make([]string, 0, len(s.attributes))
+	for k := range s.attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		o.Attributes = append(o.Attributes, anyKV(k, s.attributes[k]))
+	}
+	if s.err != nil {
+		o.Status = &otlpStatus{Code: statusCodeError, Message: s.err.Error()}
+	}
+	return o
+}
+
+// The types below mirror the JSON encoding of the OTLP ExportTraceServiceRequest message.
+
+type otlpExportRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func stringKV(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: &value}}
+}
+
+func anyKV(key string, value interface{}) otlpKeyValue {
+	var v otlpAnyValue
+	switch value := value.(type) {
+	case string:
+		v.StringValue = &value
+	case bool:
+		v.BoolValue = &value
+	case int:
+		s := strconv.FormatInt(int64(value), 10)
+		v.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(value, 10)
+		v.IntValue = &s
+	case uint8:
+		s := strconv.FormatUint(uint64(value), 10)
+		v.IntValue = &s
+	case float64:
+		v.DoubleValue = &value
+	default:
+		s := fmt.Sprint(value)
+		v.StringValue = &s
+	}
+	return otlpKeyValue{Key: key, Value: v}
+}
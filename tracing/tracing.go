@@ -0,0 +1,204 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing provides optional, lightweight trace spans for Felix's update pipeline.  When
+// enabled, finished spans are batched up and exported to an OpenTelemetry collector using the
+// OTLP/HTTP protocol (JSON encoding).  When disabled, StartSpan returns a nil *Span, all of
+// whose methods are no-ops, so instrumented code paths pay very little for the instrumentation.
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"math/rand"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type Config struct {
+	// Endpoint is the base URL of the OTLP/HTTP receiver, for example "http://localhost:4318".
+	// Spans are POSTed to <Endpoint>/v1/traces.
+	Endpoint string
+	// SampleRatio is the fraction of root spans (and hence traces) that are recorded.
+	SampleRatio float64
+	// ServiceName and Hostname are attached to every exported span as resource attributes.
+	ServiceName string
+	Hostname    string
+	// FlushInterval is the maximum time that a finished span waits before being exported.
+	FlushInterval time.Duration
+}
+
+var (
+	tracerLock sync.RWMutex
+	tracer     *spanRecorder
+)
+
+// Start enables tracing with the given config.  Spans started before Start is called are not
+// recorded.
+func Start(config Config) {
+	if config.Endpoint == "" {
+		log.Info("No OTLP endpoint configured, tracing disabled.")
+		return
+	}
+	log.WithField("config", config).Info("Enabling tracing of the update pipeline.")
+	r := &spanRecorder{
+		sampleRatio: config.SampleRatio,
+		exporter:    newOTLPExporter(config),
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	go r.exporter.loop()
+
+	tracerLock.Lock()
+	defer tracerLock.Unlock()
+	tracer = r
+}
+
+// Enabled returns true if tracing has been started.  It can be used to avoid calculating
+// expensive span attributes.
+func Enabled() bool {
+	return currentRecorder() != nil
+}
+
+func currentRecorder() *spanRecorder {
+	tracerLock.RLock()
+	defer tracerLock.RUnlock()
+	return tracer
+}
+
+type spanRecorder struct {
+	sampleRatio float64
+	exporter    *otlpExporter
+
+	randLock sync.Mutex
+	rand     *rand.Rand
+}
+
+func (r *spanRecorder) sample() bool {
+	r.randLock.Lock()
+	defer r.randLock.Unlock()
+	return r.rand.Float64() < r.sampleRatio
+}
+
+func (r *spanRecorder) newID(n int) string {
+	b := make([]byte, n)
+	r.randLock.Lock()
+	_, _ = r.rand.Read(b)
+	r.randLock.Unlock()
+	return hex.EncodeToString(b)
+}
+
+type spanCtxKey struct{}
+
+// Span records the timing of a single operation.  A nil *Span is valid and ignores all calls.
+type Span struct {
+	recorder *spanRecorder
+
+	name         string
+	traceID      string
+	spanID       string
+	parentSpanID string
+	start        time.Time
+	end          time.Time
+
+	lock       sync.Mutex
+	attributes map[string]interface{}
+	err        error
+	ended      bool
+}
+
+// StartSpan starts a new span.  If ctx already contains a span then the new span is created as
+// its child; otherwise, a new trace is started (subject to sampling).  The returned context
+// contains the new span and should be passed to any nested operations.
+func StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	r := currentRecorder()
+	if r == nil {
+		return ctx, nil
+	}
+	span := &Span{
+		recorder: r,
+		name:     name,
+		spanID:   r.newID(8),
+		start:    time.Now(),
+	}
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.traceID = parent.traceID
+		span.parentSpanID = parent.spanID
+	} else if ctx.Value(spanCtxKey{}) != nil || !r.sample() {
+		// Either the parent trace wasn't sampled or we decided not to sample this one.
+		// Store a nil span so that children are consistent with their parent.
+		return context.WithValue(ctx, spanCtxKey{}, (*Span)(nil)), nil
+	} else {
+		span.traceID = r.newID(16)
+	}
+	return context.WithValue(ctx, spanCtxKey{}, span), span
+}
+
+// SpanFromContext returns the span stored in ctx, or nil if there is none.
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanCtxKey{}).(*Span)
+	return span
+}
+
+// SetAttribute attaches a key/value pair to the span.  Strings, bools, ints and floats are
+// exported with their native types; other values are converted to strings.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.attributes == nil {
+		s.attributes = map[string]interface{}{}
+	}
+	s.attributes[key] = value
+}
+
+// RecordError marks the span as failed.  A nil error is ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.err = err
+}
+
+// End finishes the span and queues it for export.  Subsequent calls are ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.lock.Unlock()
+	s.recorder.exporter.enqueue(s)
+}
+
+// BatchStart is sent by the calculation graph ahead of a batch of messages that it flushes to
+// the dataplane.  It carries the context of the flush span, so that the dataplane can record its
+// spans for the batch in the same trace.  It is only sent when the flush is being traced, and
+// only to in-process consumers; it can't be sent to an external dataplane driver.
+type BatchStart struct {
+	Ctx context.Context
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tracing", func() {
+	var (
+		server   *httptest.Server
+		reqsLock sync.Mutex
+		reqs     []map[string]interface{}
+		paths    []string
+		recorder *spanRecorder
+	)
+
+	setRecorder := func(r *spanRecorder) {
+		tracerLock.Lock()
+		defer tracerLock.Unlock()
+		tracer = r
+	}
+
+	BeforeEach(func() {
+		reqs = nil
+		paths = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			Expect(err).NotTo(HaveOccurred())
+			var req map[string]interface{}
+			Expect(json.Unmarshal(body, &req)).To(Succeed())
+			reqsLock.Lock()
+			defer reqsLock.Unlock()
+			reqs = append(reqs, req)
+			paths = append(paths, r.URL.Path)
+		}))
+		recorder = &spanRecorder{
+			sampleRatio: 1,
+			exporter: newOTLPExporter(Config{
+				Endpoint:    server.URL + "/",
+				ServiceName: "calico-felix",
+				Hostname:    "node1",
+			}),
+			rand: rand.New(rand.NewSource(1)),
+		}
+	})
+
+	AfterEach(func() {
+		setRecorder(nil)
+		server.Close()
+	})
+
+	drainSpans := func() (spans []*Span) {
+		for {
+			select {
+			case s := <-recorder.exporter.spans:
+				spans = append(spans, s)
+			default:
+				return
+			}
+		}
+	}
+
+	It("should be a no-op when tracing is disabled", func() {
+		ctx, span := StartSpan(context.Background(), "op")
+		Expect(span).To(BeNil())
+		Expect(SpanFromContext(ctx)).To(BeNil())
+		span.SetAttribute("foo", "bar")
+		span.RecordError(errors.New("ignored"))
+		span.End()
+		Expect(Enabled()).To(BeFalse())
+	})
+
+	It("should create child spans in the same trace", func() {
+		setRecorder(recorder)
+		ctx, parent := StartSpan(context.Background(), "parent")
+		_, child := StartSpan(ctx, "child")
+		Expect(parent).NotTo(BeNil())
+		Expect(child).NotTo(BeNil())
+		Expect(child.traceID).To(Equal(parent.traceID))
+		Expect(child.parentSpanID).To(Equal(parent.spanID))
+		Expect(parent.parentSpanID).To(BeEmpty())
+		Expect(parent.traceID).To(HaveLen(32))
+		Expect(parent.spanID).To(HaveLen(16))
+
+		child.End()
+		parent.End()
+		parent.End()
+		Expect(drainSpans()).To(Equal([]*Span{child, parent}))
+	})
+
+	It("should not record children of unsampled traces", func() {
+		recorder.sampleRatio = 0
+		setRecorder(recorder)
+		ctx, parent := StartSpan(context.Background(), "parent")
+		Expect(parent).To(BeNil())
+		recorder.sampleRatio = 1
+		_, child := StartSpan(ctx, "child")
+		Expect(child).To(BeNil())
+	})
+
+	It("should export spans in OTLP JSON format", func() {
+		setRecorder(recorder)
+		_, span := StartSpan(context.Background(), "dataplane.Apply")
+		span.SetAttribute("table", "filter")
+		span.SetAttribute("ipVersion", uint8(4))
+		span.RecordError(errors.New("iptables-restore failed"))
+		span.End()
+
+		recorder.exporter.export(drainSpans())
+
+		Expect(paths).To(Equal([]string{"/v1/traces"}))
+		Expect(reqs).To(HaveLen(1))
+		rs := reqs[0]["resourceSpans"].([]interface{})[0].(map[string]interface{})
+		Expect(rs["resource"]).To(Equal(map[string]interface{}{
+			"attributes": []interface{}{
+				map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": "calico-felix"}},
+				map[string]interface{}{"key": "host.name", "value": map[string]interface{}{"stringValue": "node1"}},
+			},
+		}))
+		spans := rs["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+		Expect(spans).To(HaveLen(1))
+		exported := spans[0].(map[string]interface{})
+		Expect(exported["name"]).To(Equal("dataplane.Apply"))
+		Expect(exported["traceId"]).To(Equal(span.traceID))
+		Expect(exported["spanId"]).To(Equal(span.spanID))
+		Expect(exported).NotTo(HaveKey("parentSpanId"))
+		Expect(exported["attributes"]).To(Equal([]interface{}{
+			map[string]interface{}{"key": "ipVersion", "value": map[string]interface{}{"intValue": "4"}},
+			map[string]interface{}{"key": "table", "value": map[string]interface{}{"stringValue": "filter"}},
+		}))
+		Expect(exported["status"]).To(Equal(map[string]interface{}{
+			"code":    2.0,
+			"message": "iptables-restore failed",
+		}))
+		Expect(recorder.exporter.lastExportFailed).To(BeFalse())
+	})
+
+	It("should handle export failures", func() {
+		server.Close()
+		setRecorder(recorder)
+		_, span := StartSpan(context.Background(), "op")
+		span.End()
+		recorder.exporter.export(drainSpans())
+		Expect(recorder.exporter.lastExportFailed).To(BeTrue())
+	})
+})
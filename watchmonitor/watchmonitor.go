@@ -0,0 +1,309 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package watchmonitor wraps a datastore backend client to monitor the watches that the syncer
+// makes through it.  It records metrics about watch failures and the resulting gaps and
+// resyncs, and it delays the re-lists that follow a watch failure by a jittered, bounded
+// amount so that, after a datastore blip, a large cluster of Felix instances doesn't hit the
+// datastore with a full list all at the same time.
+package watchmonitor
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/libcalico-go/lib/backend/api"
+	"github.com/projectcalico/libcalico-go/lib/backend/model"
+	cerrors "github.com/projectcalico/libcalico-go/lib/errors"
+	cprometheus "github.com/projectcalico/libcalico-go/lib/prometheus"
+)
+
+var (
+	countWatchFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "felix_datastore_watch_failures",
+		Help: "Number of datastore watches that failed or were closed by the datastore, by resource.",
+	}, []string{"resource"})
+	countResyncs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "felix_datastore_watch_resyncs",
+		Help: "Number of datastore re-lists triggered by a watch failure, by resource.",
+	}, []string{"resource"})
+	summaryWatchGap = prometheus.NewSummaryVec(prometheus.SummaryOpts{
+		Name:       "felix_datastore_watch_gap_seconds",
+		Help:       "Seconds from a datastore watch failing to its replacement being established, by resource.",
+		Objectives: cprometheus.DefObjectives,
+	}, []string{"resource"})
+	summaryResyncDelay = cprometheus.NewSummary(prometheus.SummaryOpts{
+		Name: "felix_datastore_watch_resync_delay_seconds",
+		Help: "Seconds that re-lists were delayed by after a datastore watch failure.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(countWatchFailures)
+	prometheus.MustRegister(countResyncs)
+	prometheus.MustRegister(summaryWatchGap)
+	prometheus.MustRegister(summaryResyncDelay)
+}
+
+// Config controls the delay before a re-list.  The n'th consecutive re-list of a resource is
+// delayed by a random duration between 0 and min(BaseResyncDelay * 2^(n-1), MaxResyncDelay).
+// A zero MaxResyncDelay disables the delay.
+type Config struct {
+	BaseResyncDelay time.Duration
+	MaxResyncDelay  time.Duration
+}
+
+// Client wraps a backend client.  Only List and Watch are intercepted; other calls are passed
+// straight through.
+type Client struct {
+	api.Client
+
+	config Config
+
+	lock      sync.Mutex
+	resources map[string]*resourceState
+
+	// Shims for testing.
+	now       func() time.Time
+	randFloat func() float64
+	sleep     func(ctx context.Context, d time.Duration) error
+}
+
+type resourceState struct {
+	// listed is set once the initial list has succeeded; later lists are resyncs.
+	listed bool
+	// gapStart is the time that the last watch failed, or zero if the watch is healthy.
+	gapStart time.Time
+	// numResyncs counts the consecutive resync attempts since the last stable watch.
+	numResyncs int
+}
+
+func NewClient(client api.Client, config Config) *Client {
+	return &Client{
+		Client:    client,
+		config:    config,
+		resources: map[string]*resourceState{},
+		now:       time.Now,
+		randFloat: rand.Float64,
+		sleep:     sleepCtx,
+	}
+}
+
+func (c *Client) List(ctx context.Context, list model.ListInterface, revision string) (*model.KVPairList, error) {
+	name := resourceName(list)
+	if delay := c.onListStarting(name); delay > 0 {
+		log.WithFields(log.Fields{
+			"resource": name,
+			"delay":    delay,
+		}).Info("Delaying datastore resync after watch failure.")
+		summaryResyncDelay.Observe(delay.Seconds())
+		if err := c.sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+	l, err := c.Client.List(ctx, list, revision)
+	if err == nil {
+		c.onListSucceeded(name)
+	}
+	return l, err
+}
+
+func (c *Client) Watch(ctx context.Context, list model.ListInterface, revision string) (api.WatchInterface, error) {
+	name := resourceName(list)
+	w, err := c.Client.Watch(ctx, list, revision)
+	if err != nil {
+		switch err.(type) {
+		case cerrors.ErrorOperationNotSupported, cerrors.ErrorResourceDoesNotExist:
+			// The syncer polls resources that can't be watched; that's not a failure.
+		default:
+			c.onWatchFailed(name, time.Time{})
+		}
+		return nil, err
+	}
+	start := c.onWatchStarted(name)
+	return newWatcher(c, name, start, w), nil
+}
+
+func (c *Client) state(name string) *resourceState {
+	s := c.resources[name]
+	if s == nil {
+		s = &resourceState{}
+		c.resources[name] = s
+	}
+	return s
+}
+
+// onListStarting returns the time to wait before the list.  Only lists that follow a watch
+// failure are delayed; the initial list and the syncer's polling of unwatchable resources
+// aren't.
+func (c *Client) onListStarting(name string) time.Duration {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	s := c.state(name)
+	if !s.listed || s.gapStart.IsZero() {
+		return 0
+	}
+	countResyncs.WithLabelValues(name).Inc()
+	s.numResyncs++
+	return resyncDelay(c.config, s.numResyncs, c.randFloat())
+}
+
+func (c *Client) onListSucceeded(name string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.state(name).listed = true
+}
+
+func (c *Client) onWatchStarted(name string) time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := c.now()
+	s := c.state(name)
+	if !s.gapStart.IsZero() {
+		gap := now.Sub(s.gapStart)
+		log.WithFields(log.Fields{
+			"resource": name,
+			"gap":      gap,
+		}).Info("Datastore watch re-established after failure.")
+		summaryWatchGap.WithLabelValues(name).Observe(gap.Seconds())
+		s.gapStart = time.Time{}
+	}
+	return now
+}
+
+// onWatchFailed records a watch failure.  watchStart is the time that the failed watch was
+// established, or zero if the watch couldn't be created at all.
+func (c *Client) onWatchFailed(name string, watchStart time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := c.now()
+	countWatchFailures.WithLabelValues(name).Inc()
+	s := c.state(name)
+	if !watchStart.IsZero() && now.Sub(watchStart) >= c.config.MaxResyncDelay {
+		// The watch was stable for a while, start the back off again.
+		s.numResyncs = 0
+	}
+	if s.gapStart.IsZero() {
+		log.WithField("resource", name).Info("Datastore watch failed.")
+		s.gapStart = now
+	}
+}
+
+// resyncDelay calculates the jittered delay for the n'th consecutive resync.
+func resyncDelay(config Config, n int, randValue float64) time.Duration {
+	if config.MaxResyncDelay <= 0 {
+		return 0
+	}
+	limit := config.BaseResyncDelay
+	for i := 1; i < n && limit < config.MaxResyncDelay; i++ {
+		limit *= 2
+	}
+	if limit > config.MaxResyncDelay || limit <= 0 {
+		limit = config.MaxResyncDelay
+	}
+	return time.Duration(randValue * float64(limit))
+}
+
+func resourceName(list model.ListInterface) string {
+	if r, ok := list.(model.ResourceListOptions); ok {
+		return r.Kind
+	}
+	t := reflect.TypeOf(list)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return strings.TrimSuffix(t.Name(), "ListOptions")
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// watcher wraps a backend watch in order to spot when it fails.
+type watcher struct {
+	client *Client
+	name   string
+	start  time.Time
+	inner  api.WatchInterface
+
+	results  chan api.WatchEvent
+	stopOnce sync.Once
+	stopped  chan struct{}
+}
+
+func newWatcher(client *Client, name string, start time.Time, inner api.WatchInterface) *watcher {
+	w := &watcher{
+		client:  client,
+		name:    name,
+		start:   start,
+		inner:   inner,
+		results: make(chan api.WatchEvent),
+		stopped: make(chan struct{}),
+	}
+	go w.loop()
+	return w
+}
+
+func (w *watcher) loop() {
+	defer close(w.results)
+	failed := false
+	for event := range w.inner.ResultChan() {
+		if event.Type == api.WatchError && !failed {
+			failed = true
+			w.client.onWatchFailed(w.name, w.start)
+		}
+		select {
+		case w.results <- event:
+		case <-w.stopped:
+			return
+		}
+	}
+	select {
+	case <-w.stopped:
+	default:
+		if !failed {
+			// Closed by the datastore rather than by Stop().
+			w.client.onWatchFailed(w.name, w.start)
+		}
+	}
+}
+
+func (w *watcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopped)
+	})
+	w.inner.Stop()
+}
+
+func (w *watcher) ResultChan() <-chan api.WatchEvent {
+	return w.results
+}
+
+func (w *watcher) HasTerminated() bool {
+	return w.inner.HasTerminated()
+}
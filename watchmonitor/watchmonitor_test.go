@@ -0,0 +1,218 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watchmonitor
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/libcalico-go/lib/backend/api"
+	"github.com/projectcalico/libcalico-go/lib/backend/model"
+	cerrors "github.com/projectcalico/libcalico-go/lib/errors"
+)
+
+type fakeWatch struct {
+	events  chan api.WatchEvent
+	stopped bool
+}
+
+func (w *fakeWatch) Stop() {
+	if !w.stopped {
+		w.stopped = true
+		close(w.events)
+	}
+}
+
+func (w *fakeWatch) ResultChan() <-chan api.WatchEvent {
+	return w.events
+}
+
+func (w *fakeWatch) HasTerminated() bool {
+	return w.stopped
+}
+
+type fakeBackend struct {
+	api.Client
+
+	numLists int
+	watchErr error
+	watches  []*fakeWatch
+}
+
+func (b *fakeBackend) List(ctx context.Context, list model.ListInterface, revision string) (*model.KVPairList, error) {
+	b.numLists++
+	return &model.KVPairList{}, nil
+}
+
+func (b *fakeBackend) Watch(ctx context.Context, list model.ListInterface, revision string) (api.WatchInterface, error) {
+	if b.watchErr != nil {
+		return nil, b.watchErr
+	}
+	w := &fakeWatch{events: make(chan api.WatchEvent, 10)}
+	b.watches = append(b.watches, w)
+	return w, nil
+}
+
+var _ = Describe("Watch monitor", func() {
+	var (
+		backend *fakeBackend
+		client  *Client
+		now     time.Time
+		delays  []time.Duration
+		list    = model.ResourceListOptions{Kind: "NetworkPolicy"}
+	)
+
+	BeforeEach(func() {
+		backend = &fakeBackend{}
+		client = NewClient(backend, Config{BaseResyncDelay: time.Second, MaxResyncDelay: 10 * time.Second})
+		now = time.Now()
+		delays = nil
+		client.now = func() time.Time { return now }
+		client.randFloat = func() float64 { return 0.5 }
+		client.sleep = func(ctx context.Context, d time.Duration) error {
+			delays = append(delays, d)
+			return nil
+		}
+	})
+
+	listAndWatch := func() api.WatchInterface {
+		_, err := client.List(context.Background(), list, "")
+		Expect(err).NotTo(HaveOccurred())
+		w, err := client.Watch(context.Background(), list, "")
+		Expect(err).NotTo(HaveOccurred())
+		return w
+	}
+
+	failWatch := func(w api.WatchInterface) {
+		backend.watches[len(backend.watches)-1].events <- api.WatchEvent{
+			Type:  api.WatchError,
+			Error: errors.New("revision compacted"),
+		}
+		Eventually(w.ResultChan()).Should(Receive())
+		w.Stop()
+	}
+
+	It("should not delay the initial list", func() {
+		listAndWatch()
+		Expect(delays).To(BeEmpty())
+		Expect(backend.numLists).To(Equal(1))
+	})
+
+	It("should pass events through", func() {
+		w := listAndWatch()
+		backend.watches[0].events <- api.WatchEvent{Type: api.WatchAdded}
+		Eventually(w.ResultChan()).Should(Receive(Equal(api.WatchEvent{Type: api.WatchAdded})))
+	})
+
+	It("should delay the resync after a watch error and record the gap", func() {
+		w := listAndWatch()
+		failWatch(w)
+		now = now.Add(3 * time.Second)
+		listAndWatch()
+		Expect(delays).To(Equal([]time.Duration{500 * time.Millisecond}))
+		Expect(client.resources["NetworkPolicy"].gapStart.IsZero()).To(BeTrue())
+	})
+
+	It("should treat the watch being closed by the datastore as a failure", func() {
+		listAndWatch()
+		close(backend.watches[0].events)
+		Eventually(func() bool {
+			client.lock.Lock()
+			defer client.lock.Unlock()
+			return !client.resources["NetworkPolicy"].gapStart.IsZero()
+		}).Should(BeTrue())
+	})
+
+	It("should back off on repeated failures", func() {
+		w := listAndWatch()
+		for i := 0; i < 6; i++ {
+			failWatch(w)
+			w = listAndWatch()
+		}
+		Expect(delays).To(Equal([]time.Duration{
+			500 * time.Millisecond,
+			time.Second,
+			2 * time.Second,
+			4 * time.Second,
+			5 * time.Second,
+			5 * time.Second,
+		}))
+	})
+
+	It("should reset the back off once the watch is stable", func() {
+		w := listAndWatch()
+		for i := 0; i < 3; i++ {
+			failWatch(w)
+			w = listAndWatch()
+		}
+		now = now.Add(time.Minute)
+		failWatch(w)
+		listAndWatch()
+		Expect(delays[len(delays)-1]).To(Equal(500 * time.Millisecond))
+	})
+
+	It("should not delay polling of resources that can't be watched", func() {
+		backend.watchErr = cerrors.ErrorOperationNotSupported{Operation: "Watch"}
+		for i := 0; i < 3; i++ {
+			_, err := client.List(context.Background(), list, "")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = client.Watch(context.Background(), list, "")
+			Expect(err).To(HaveOccurred())
+		}
+		Expect(delays).To(BeEmpty())
+	})
+
+	It("should delay the resync if the watch can't be created", func() {
+		listAndWatch()
+		backend.watchErr = errors.New("connection refused")
+		_, err := client.Watch(context.Background(), list, "")
+		Expect(err).To(HaveOccurred())
+		_, err = client.List(context.Background(), list, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(delays).To(HaveLen(1))
+	})
+})
+
+var _ = DescribeTable("Resync delay",
+	func(n int, randValue float64, expected time.Duration) {
+		config := Config{BaseResyncDelay: time.Second, MaxResyncDelay: 30 * time.Second}
+		Expect(resyncDelay(config, n, randValue)).To(Equal(expected))
+	},
+	Entry("first", 1, 1.0, time.Second),
+	Entry("second", 2, 1.0, 2*time.Second),
+	Entry("capped", 10, 1.0, 30*time.Second),
+	Entry("jittered", 3, 0.25, time.Second),
+	Entry("zero rand", 3, 0.0, time.Duration(0)),
+)
+
+var _ = Describe("Resync delay disabled", func() {
+	It("should not delay", func() {
+		Expect(resyncDelay(Config{}, 5, 1.0)).To(BeZero())
+	})
+})
+
+var _ = DescribeTable("Resource names",
+	func(list model.ListInterface, expected string) {
+		Expect(resourceName(list)).To(Equal(expected))
+	},
+	Entry("v3 resource", model.ResourceListOptions{Kind: "IPPool"}, "IPPool"),
+	Entry("v1 model", model.HostConfigListOptions{}, "HostConfig"),
+	Entry("pointer", &model.BlockListOptions{}, "Block"),
+)
@@ -129,6 +129,9 @@ type Wireguard struct {
 	ourPublicKey                       *wgtypes.Key
 	ourIPv4InterfaceAddr               ip.Addr
 	ourPublicKeyAgreesWithDataplaneMsg bool
+	// removingAll is set by RemoveAllRoutes; from then on, Apply removes our configuration as if
+	// wireguard were disabled.
+	removingAll bool
 
 	// Local workload information
 	localIPs          set.Set
@@ -586,7 +589,7 @@ func (w *Wireguard) Apply() (err error) {
 	}
 
 	// If wireguard is not enabled, then short-circuit the processing - ensure config is deleted.
-	if !w.config.Enabled {
+	if !w.config.Enabled || w.removingAll {
 		log.Debug("Wireguard is not enabled, skipping sync")
 		if !w.inSyncWireguard {
 			log.Debug("Wireguard is not in-sync - verifying wireguard configuration is removed")
@@ -1494,6 +1497,17 @@ func (w *Wireguard) ensureLinkAddressV4(netlinkClient netlinkshim.Interface) err
 	return nil
 }
 
+// RemoveAllRoutes makes the next Apply remove the wireguard routes, routing rule and device, as if
+// wireguard were disabled.  It's used to clean up the dataplane before Felix shuts down.
+func (w *Wireguard) RemoveAllRoutes() {
+	w.removingAll = true
+	w.inSyncWireguard = false
+	if w.routerule != nil {
+		w.routerule.RemoveAllRules()
+	}
+	w.routetable.RemoveAllRoutes()
+}
+
 // addRouteRule adds a routing rule to use the wireguard table.
 func (w *Wireguard) addRouteRule() {
 	// The netlink library has a bug where it returns -1 for the mark on a rule instead of 0.